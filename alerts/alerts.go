@@ -0,0 +1,221 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/google/uuid"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("alerts")
+
+// The kinds of alert rule
+const (
+	// Alert when more than Threshold deals fail during data transfer within
+	// the Window
+	KindTransferFailures = "transfer-failures"
+	// Alert when more than Threshold deals fail for any reason within the
+	// Window
+	KindDealFailures = "deal-failures"
+	// Alert when more than Threshold deals have been waiting for the publish
+	// storage deals message to be sent or to land on chain for longer than
+	// the Window
+	KindPublishPending = "publish-pending"
+	// Alert when more than Threshold announcements to the network indexer
+	// are waiting to be retried
+	KindAnnounceBacklog = "announce-backlog"
+)
+
+// Kinds are the kinds of alert rule that can be configured
+var Kinds = []string{KindTransferFailures, KindDealFailures, KindPublishPending, KindAnnounceBacklog}
+
+// Rule is an alert rule. The rule fires when the value it measures is
+// greater than the threshold.
+type Rule struct {
+	// The name of the rule, which identifies the rule in alert events
+	Name string
+	// The kind of rule (one of Kinds)
+	Kind string
+	// The alert fires when the measured value is greater than the threshold
+	Threshold int
+	// The period that failures are counted over, or how long a deal must be
+	// waiting to be published before it's counted. Not used by the announce
+	// backlog rule.
+	Window time.Duration
+}
+
+// Config configures the alert rules engine
+type Config struct {
+	// How often to evaluate the rules
+	CheckInterval time.Duration
+	Rules         []Rule
+}
+
+// Engine periodically evaluates the alert rules, and writes an alert event
+// to the audit log (which is sent to any webhooks that subscribe to it) when
+// a rule's condition is met, and an alert-resolved event when it's no longer
+// met.
+type Engine struct {
+	cfg     Config
+	dealsDB *db.DealsDB
+	auditDB *db.AuditLogDB
+	annDB   *db.PendingAnnouncementsDB
+
+	// The names of the rules that are currently firing
+	firing map[string]bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New(cfg Config, dealsDB *db.DealsDB, auditDB *db.AuditLogDB, annDB *db.PendingAnnouncementsDB) (*Engine, error) {
+	names := make(map[string]struct{}, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("alert rule of kind '%s' has no name", r.Kind)
+		}
+		if _, ok := names[r.Name]; ok {
+			return nil, fmt.Errorf("there is more than one alert rule named '%s'", r.Name)
+		}
+		names[r.Name] = struct{}{}
+
+		switch r.Kind {
+		case KindTransferFailures, KindDealFailures, KindPublishPending:
+			if r.Window <= 0 {
+				return nil, fmt.Errorf("alert rule '%s' of kind '%s' must have a window", r.Name, r.Kind)
+			}
+		case KindAnnounceBacklog:
+		default:
+			return nil, fmt.Errorf("alert rule '%s' has unrecognized kind '%s': must be one of %v", r.Name, r.Kind, Kinds)
+		}
+		if r.Threshold < 0 {
+			return nil, fmt.Errorf("alert rule '%s' has negative threshold %d", r.Name, r.Threshold)
+		}
+	}
+
+	return &Engine{
+		cfg:     cfg,
+		dealsDB: dealsDB,
+		auditDB: auditDB,
+		annDB:   annDB,
+		firing:  make(map[string]bool),
+	}, nil
+}
+
+func (e *Engine) Start(ctx context.Context) {
+	e.ctx, e.cancel = context.WithCancel(ctx)
+	if e.cfg.CheckInterval <= 0 {
+		e.cfg.CheckInterval = time.Minute
+	}
+
+	log.Infow("starting alert rules engine", "rules", len(e.cfg.Rules), "check interval", e.cfg.CheckInterval)
+
+	e.wg.Add(1)
+	go e.run(e.ctx)
+}
+
+func (e *Engine) Stop() {
+	if e.cancel != nil {
+		e.cancel()
+	}
+	e.wg.Wait()
+}
+
+func (e *Engine) run(ctx context.Context) {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		e.check(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check evaluates each rule
+func (e *Engine) check(ctx context.Context, now time.Time) {
+	for _, r := range e.cfg.Rules {
+		val, err := e.measure(ctx, r, now)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Errorw("failed to evaluate alert rule", "rule", r.Name, "kind", r.Kind, "err", err)
+			}
+			continue
+		}
+		e.checkRule(ctx, r, val)
+	}
+}
+
+// measure returns the value that the rule compares against its threshold
+func (e *Engine) measure(ctx context.Context, r Rule, now time.Time) (int, error) {
+	switch r.Kind {
+	case KindTransferFailures:
+		return e.auditDB.FailureCount(ctx, now.Add(-r.Window), dealcheckpoints.Accepted.String())
+	case KindDealFailures:
+		return e.auditDB.FailureCount(ctx, now.Add(-r.Window))
+	case KindPublishPending:
+		return e.dealsDB.StalledCount(ctx, now.Add(-r.Window),
+			dealcheckpoints.Transferred.String(), dealcheckpoints.Published.String())
+	case KindAnnounceBacklog:
+		return e.annDB.Count(ctx)
+	default:
+		return 0, fmt.Errorf("unrecognized alert rule kind '%s'", r.Kind)
+	}
+}
+
+func (e *Engine) checkRule(ctx context.Context, r Rule, val int) {
+	isFiring := val > r.Threshold
+	wasFiring := e.firing[r.Name]
+	if isFiring == wasFiring {
+		return
+	}
+	e.firing[r.Name] = isFiring
+
+	evt := &db.AuditEvent{
+		DealUUID: uuid.Nil,
+		Actor:    db.AuditActorSystem,
+		Rule:     r.Name,
+	}
+	if isFiring {
+		evt.Type = db.AuditEventAlert
+		evt.Detail = describe(r, val) + fmt.Sprintf(", above the alert threshold of %d", r.Threshold)
+		log.Warnw("alert rule is firing", "rule", r.Name, "kind", r.Kind, "value", val, "threshold", r.Threshold)
+	} else {
+		evt.Type = db.AuditEventAlertResolved
+		evt.Detail = describe(r, val) + fmt.Sprintf(", back within the alert threshold of %d", r.Threshold)
+		log.Infow("alert rule is resolved", "rule", r.Name, "kind", r.Kind, "value", val, "threshold", r.Threshold)
+	}
+
+	if err := e.auditDB.Insert(ctx, evt); err != nil {
+		log.Errorw("failed to write alert audit event", "rule", r.Name, "err", err)
+	}
+}
+
+// describe returns a human readable description of the value measured by
+// the rule
+func describe(r Rule, val int) string {
+	switch r.Kind {
+	case KindTransferFailures:
+		return fmt.Sprintf("%d deals failed during data transfer in the last %s", val, r.Window)
+	case KindDealFailures:
+		return fmt.Sprintf("%d deals failed in the last %s", val, r.Window)
+	case KindPublishPending:
+		return fmt.Sprintf("%d deals have been waiting to be published for more than %s", val, r.Window)
+	case KindAnnounceBacklog:
+		return fmt.Sprintf("%d announcements to the network indexer are waiting to be retried", val)
+	default:
+		return fmt.Sprintf("%s is %d", r.Kind, val)
+	}
+}
@@ -0,0 +1,119 @@
+package alerts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlerts(t *testing.T) {
+	ctx := context.Background()
+
+	sqldb := db.CreateTestTmpDB(t)
+	require.NoError(t, db.CreateAllBoostTables(ctx, sqldb, sqldb))
+	require.NoError(t, migrations.Migrate(sqldb))
+	auditDB := db.NewAuditLogDB(sqldb)
+	annDB := db.NewPendingAnnouncementsDB(sqldb)
+
+	e, err := New(Config{Rules: []Rule{{
+		Name:      "transfers",
+		Kind:      KindTransferFailures,
+		Threshold: 1,
+		Window:    10 * time.Minute,
+	}, {
+		Name:      "announce",
+		Kind:      KindAnnounceBacklog,
+		Threshold: 0,
+	}}}, db.NewDealsDB(sqldb), auditDB, annDB)
+	require.NoError(t, err)
+
+	alerts := func() []db.AuditEvent {
+		evts, err := auditDB.Since(ctx, 0, 100)
+		require.NoError(t, err)
+		var alerts []db.AuditEvent
+		for _, evt := range evts {
+			if evt.Type == db.AuditEventAlert || evt.Type == db.AuditEventAlertResolved {
+				alerts = append(alerts, evt)
+			}
+		}
+		return alerts
+	}
+	transferFailed := func(at time.Time) {
+		require.NoError(t, auditDB.Insert(ctx, &db.AuditEvent{
+			CreatedAt:      at,
+			DealUUID:       uuid.New(),
+			Type:           db.AuditEventCheckpoint,
+			FromCheckpoint: "Accepted",
+			ToCheckpoint:   "Complete",
+			Detail:         "data-transfer failed",
+		}))
+	}
+
+	// No rule is firing
+	now := time.Now()
+	e.check(ctx, now)
+	require.Empty(t, alerts())
+
+	// Two transfer failures, but one was outside the window
+	transferFailed(now.Add(-time.Hour))
+	transferFailed(now.Add(-time.Minute))
+	e.check(ctx, now)
+	require.Empty(t, alerts())
+
+	// Another transfer failure takes the count above the threshold
+	transferFailed(now)
+	e.check(ctx, now)
+	evts := alerts()
+	require.Len(t, evts, 1)
+	require.Equal(t, db.AuditEventAlert, evts[0].Type)
+	require.Equal(t, "transfers", evts[0].Rule)
+	require.Contains(t, evts[0].Detail, "2 deals failed during data transfer")
+
+	// Expect only one alert while the rule is firing
+	e.check(ctx, now)
+	require.Len(t, alerts(), 1)
+
+	// An announcement fails
+	require.NoError(t, annDB.Add(ctx, uuid.New(), "indexer unavailable", now))
+	e.check(ctx, now)
+	evts = alerts()
+	require.Len(t, evts, 2)
+	require.Equal(t, db.AuditEventAlert, evts[1].Type)
+	require.Equal(t, "announce", evts[1].Rule)
+
+	// The failures move out of the window
+	e.check(ctx, now.Add(time.Hour))
+	evts = alerts()
+	require.Len(t, evts, 3)
+	require.Equal(t, db.AuditEventAlertResolved, evts[2].Type)
+	require.Equal(t, "transfers", evts[2].Rule)
+}
+
+func TestInvalidRules(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		rules []Rule
+	}{{
+		name:  "no name",
+		rules: []Rule{{Kind: KindAnnounceBacklog}},
+	}, {
+		name:  "duplicate name",
+		rules: []Rule{{Name: "a", Kind: KindAnnounceBacklog}, {Name: "a", Kind: KindAnnounceBacklog}},
+	}, {
+		name:  "unrecognized kind",
+		rules: []Rule{{Name: "a", Kind: "unknown"}},
+	}, {
+		name:  "no window",
+		rules: []Rule{{Name: "a", Kind: KindDealFailures}},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(Config{Rules: tc.rules}, nil, nil, nil)
+			require.Error(t, err)
+		})
+	}
+}
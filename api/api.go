@@ -2,7 +2,19 @@ package api
 
 import (
 	"context"
-
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/dealbook"
+	"github.com/filecoin-project/boost/health"
+	"github.com/filecoin-project/boost/retrievalmarket/quota"
+	"github.com/filecoin-project/boost/retrievalmarket/rtvllog"
+	"github.com/filecoin-project/boost/retrievalpolicy"
+	"github.com/filecoin-project/boost/storagemarket/backpressure"
+	"github.com/filecoin-project/boost/storagemarket/funds"
+	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
+	"github.com/filecoin-project/boost/storagemarket/sealingpriority"
+	"github.com/filecoin-project/boost/storagemarket/sectorpacking"
 	smtypes "github.com/filecoin-project/boost/storagemarket/types"
 	"github.com/filecoin-project/go-address"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
@@ -10,6 +22,7 @@ import (
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin/v8/paych"
 	lapi "github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/google/uuid"
@@ -35,10 +48,20 @@ type Boost interface {
 
 	// MethodGroup: Boost
 	BoostIndexerAnnounceAllDeals(ctx context.Context) error                                                                        //perm:admin
+	BoostIndexerAnnounceDeals(ctx context.Context, params IndexerAnnounceParams) (*IndexerAnnounceResult, error)                   //perm:admin
+	BoostIndexerPendingAnnouncements(ctx context.Context) ([]IndexerPendingAnnouncement, error)                                    //perm:read
+	BoostIndexerStatus(ctx context.Context) (*IndexerStatus, error)                                                                //perm:read
+	BoostIndexerDirectAnnounceStatus(ctx context.Context) ([]IndexerDirectAnnounceStatus, error)                                   //perm:read
+	BoostIndexerVerify(ctx context.Context, maxDeals int) (*IndexerVerifyResult, error)                                            //perm:admin
 	BoostOfflineDealWithData(ctx context.Context, dealUuid uuid.UUID, filePath string) (*ProviderDealRejectionInfo, error)         //perm:admin
 	BoostDeal(ctx context.Context, dealUuid uuid.UUID) (*smtypes.ProviderDealState, error)                                         //perm:admin
 	BoostDealBySignedProposalCid(ctx context.Context, proposalCid cid.Cid) (*smtypes.ProviderDealState, error)                     //perm:admin
+	BoostDeals(ctx context.Context, filter DealsFilter) ([]*smtypes.ProviderDealState, error)                                      //perm:read
+	BoostDealsAll(ctx context.Context, filter dealbook.Filter, offset int, limit int) (*dealbook.Page, error)                      //perm:read
 	BoostDummyDeal(context.Context, smtypes.DealParams) (*ProviderDealRejectionInfo, error)                                        //perm:admin
+	BoostFundsForecast(ctx context.Context) (*funds.Forecast, error)                                                               //perm:read
+	BoostFundsHistory(ctx context.Context, since time.Time, until time.Time) ([]db.FundsMovement, error)                           //perm:read
+	BoostHealth(ctx context.Context) (*health.Status, error)                                                                       //perm:read
 	BoostDagstoreRegisterShard(ctx context.Context, key string) error                                                              //perm:admin
 	BoostDagstoreDestroyShard(ctx context.Context, key string) error                                                               //perm:admin
 	BoostDagstoreInitializeShard(ctx context.Context, key string) error                                                            //perm:admin
@@ -48,6 +71,19 @@ type Boost interface {
 	BoostDagstorePiecesContainingMultihash(ctx context.Context, mh multihash.Multihash) ([]cid.Cid, error)                         //perm:read
 	BoostDagstoreListShards(ctx context.Context) ([]DagstoreShardInfo, error)                                                      //perm:admin
 	BoostMakeDeal(context.Context, smtypes.DealParams) (*ProviderDealRejectionInfo, error)                                         //perm:write
+	BoostRetrievalAttemptsAdd(ctx context.Context, attempts []rtvllog.RetrievalAttempt) error                                      //perm:write
+	BoostRetrievalEarnings(ctx context.Context) ([]RetrievalEarnings, error)                                                       //perm:read
+	BoostRetrievalPaymentAddVoucher(ctx context.Context, clientID string, sv *paych.SignedVoucher) (abi.TokenAmount, error)        //perm:write
+	BoostRetrievalPaymentRefund(ctx context.Context, clientID string, amount abi.TokenAmount) (abi.TokenAmount, error)             //perm:write
+	BoostRetrievalPaymentReserve(ctx context.Context, clientID string) (*RetrievalPaymentReservation, error)                       //perm:write
+	BoostRetrievalPaymentTerms(ctx context.Context) (*RetrievalPaymentTerms, error)                                                //perm:read
+	BoostRetrievalPolicy(ctx context.Context) (*retrievalpolicy.Config, error)                                                     //perm:read
+	BoostRetrievalQuota(ctx context.Context, clientID string) (*quota.Status, error)                                               //perm:read
+	BoostSealingBackpressure(ctx context.Context) (*backpressure.Status, error)                                                    //perm:read
+	BoostSealingPipelineStatus(ctx context.Context) (*sealingpipeline.Status, error)                                               //perm:read
+	BoostSealingPriority(ctx context.Context) (*sealingpriority.Status, error)                                                     //perm:read
+	BoostSectorPacking(ctx context.Context) (*sectorpacking.Report, error)                                                         //perm:read
+	BoostSetRetrievalPolicy(ctx context.Context, cfg retrievalpolicy.Config) error                                                 //perm:admin
 
 	// MethodGroup: Blockstore
 	BlockstoreGet(ctx context.Context, c cid.Cid) ([]byte, error)  //perm:read
@@ -73,11 +109,17 @@ type Boost interface {
 	MarketPendingDeals(ctx context.Context) (lapi.PendingDealInfo, error)                                                                                                                //perm:write
 	SectorsRefs(context.Context) (map[string][]lapi.SealedRef, error)                                                                                                                    //perm:read
 
-	PiecesListPieces(ctx context.Context) ([]cid.Cid, error)                                 //perm:read
-	PiecesListCidInfos(ctx context.Context) ([]cid.Cid, error)                               //perm:read
-	PiecesGetPieceInfo(ctx context.Context, pieceCid cid.Cid) (*piecestore.PieceInfo, error) //perm:read
-	PiecesGetCIDInfo(ctx context.Context, payloadCid cid.Cid) (*piecestore.CIDInfo, error)   //perm:read
-	PiecesGetMaxOffset(ctx context.Context, pieceCid cid.Cid) (uint64, error)                //perm:read
+	PiecesListPieces(ctx context.Context) ([]cid.Cid, error)                                            //perm:read
+	PiecesListCidInfos(ctx context.Context) ([]cid.Cid, error)                                          //perm:read
+	PiecesGetPieceInfo(ctx context.Context, pieceCid cid.Cid) (*piecestore.PieceInfo, error)            //perm:read
+	PiecesGetCIDInfo(ctx context.Context, payloadCid cid.Cid) (*piecestore.CIDInfo, error)              //perm:read
+	PiecesGetMaxOffset(ctx context.Context, pieceCid cid.Cid) (uint64, error)                           //perm:read
+	PiecesHealth(ctx context.Context) ([]PieceHealth, error)                                            //perm:read
+	PiecesCheckHealth(ctx context.Context, pieceCid cid.Cid, repair bool) (*PieceHealth, error)         //perm:admin
+	PiecesUnsealedStatus(ctx context.Context, pieceCid cid.Cid) (*PieceUnsealedStatus, error)           //perm:read
+	PiecesUnseal(ctx context.Context, pieceCid cid.Cid) error                                           //perm:admin
+	PiecesRemoveUnsealed(ctx context.Context, pieceCid cid.Cid, force bool) ([]abi.SectorNumber, error) //perm:admin
+	PiecesRemove(ctx context.Context, pieceCid cid.Cid, dryRun bool) (*PieceRemoval, error)             //perm:admin
 
 	// MethodGroup: Actor
 	ActorSectorSize(context.Context, address.Address) (abi.SectorSize, error) //perm:read
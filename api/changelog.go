@@ -0,0 +1,134 @@
+package api
+
+import (
+	"fmt"
+)
+
+// Deprecation describes a JSON-RPC method or graphql field that will be
+// removed in a future version of the API.
+// Clients that call a deprecated method or query a deprecated field get a
+// warning in the response.
+type Deprecation struct {
+	// The JSON-RPC method (eg Filecoin.BoostDeal) or the graphql field, as
+	// Type.field (eg RootQuery.deals)
+	Name string
+	// The API version in which the method or field was deprecated
+	Since string
+	// The API version in which the method or field will be removed
+	RemovedIn string
+	// The method or field to use instead
+	Replacement string
+}
+
+func (d Deprecation) Warning() string {
+	msg := fmt.Sprintf("%s is deprecated since API version %s", d.Name, d.Since)
+	if d.RemovedIn != "" {
+		msg += fmt.Sprintf(" and will be removed in API version %s", d.RemovedIn)
+	}
+	if d.Replacement != "" {
+		msg += fmt.Sprintf(": use %s instead", d.Replacement)
+	}
+	return msg
+}
+
+// ChangelogEntry lists the changes in a version of the API
+type ChangelogEntry struct {
+	Version string
+	Changes []string
+}
+
+// Changelog is served by the JSON-RPC and graphql servers so that external
+// integrations can check for upcoming breaking changes
+type Changelog struct {
+	RPCVersion     string
+	GraphqlVersion string
+	RPC            []ChangelogEntry
+	Graphql        []ChangelogEntry
+	Deprecations   []Deprecation
+}
+
+// RPCDeprecations are the deprecated JSON-RPC methods.
+// When a method is deprecated, add it here and to the changelog.
+var RPCDeprecations = []Deprecation{}
+
+// GraphqlDeprecations are the deprecated graphql fields.
+// When a field is deprecated, add it here, mark it with the @deprecated
+// directive in the schema, and add it to the changelog.
+var GraphqlDeprecations = []Deprecation{}
+
+// RPCChangelog lists the changes to the JSON-RPC API, newest first
+var RPCChangelog = []ChangelogEntry{{
+	Version: "1.1.0",
+	Changes: []string{
+		"Add BoostDealsAll to list boost and legacy deals together, with the source of each deal",
+		"Add BoostDeals to list deals that match a filter",
+		"Add BoostIndexerPendingAnnouncements to list failed announcements that are waiting to be retried",
+		"Add BoostIndexerAnnounceDeals to announce the deals that match a filter, with a rate limit",
+		"Add BoostIndexerDirectAnnounceStatus to get the status of direct HTTP announcements to indexers",
+		"Add BoostIndexerStatus to get the health and sync state of the index provider",
+		"Add BoostIndexerVerify to check that the indexer has ingested announced deals",
+		"Add PiecesHealth and PiecesCheckHealth to check piece indexes against the unsealed data",
+		"Add PiecesUnsealedStatus, PiecesUnseal and PiecesRemoveUnsealed to manage the unsealed copies of pieces",
+		"Add PiecesRemove to remove the records for a piece across subsystems",
+		"Add BoostRetrievalPolicy and BoostSetRetrievalPolicy to manage the policy for retrievals over HTTP and bitswap",
+		"Add BoostRetrievalPaymentTerms, BoostRetrievalPaymentAddVoucher, BoostRetrievalPaymentReserve, BoostRetrievalPaymentRefund and BoostRetrievalEarnings for paid retrievals",
+		"Add BoostRetrievalAttemptsAdd to record the retrievals served by booster-http and booster-bitswap",
+		"Add BoostRetrievalQuota to get a client's retrieval usage and quota",
+		"Add BoostFundsForecast to project the funds needed to publish pending deals",
+		"Add BoostFundsHistory to get the history of escrow adds, collateral locks, publish fees and releases",
+		"Add BoostSealingPipelineStatus to get sector state counts, wait deals sectors and worker utilization",
+		"Add BoostSectorPacking to get the sector utilization achieved by the sector packing policy",
+		"Add BoostSealingBackpressure to get the sealing throughput and the rate at which deals are accepted",
+		"Add BoostSealingPriority to get the projected sealing completion of sectors compared to their deal start epochs",
+		"Add BoostHealth to get the health of each of the components that boost depends on",
+	},
+}, {
+	Version: "1.0.0",
+	Changes: []string{"Initial version"},
+}}
+
+// GraphqlChangelog lists the changes to the graphql API, newest first
+var GraphqlChangelog = []ChangelogEntry{{
+	Version: "1.1.0",
+	Changes: []string{
+		"Add apiVersion query",
+		"Add filter, sort and after arguments to the deals query",
+		"Add auditLog and dealStats queries",
+		"Add dealsRetryPaused and dealsFailPaused mutations",
+		"Add webhooks query and webhookRegister, webhookUnregister and webhookTest mutations",
+		"Add transferProgress subscription",
+		"Add BlockCount and SampleMultihashes fields to PieceStatus",
+		"Add AnnounceAfterSealing and AnnounceRule fields to Deal",
+		"Add indexProviderStatus query",
+		"Add pieceHealth query, pieceCheckHealth mutation and Health field to PieceStatus",
+		"Add Retrievable field to PieceStatus",
+		"Add unsealQueue query",
+		"Add retrievalAsk query",
+		"Add retrievalAttempt, retrievalAttempts and retrievalStats queries",
+		"Add retrievalQuota and retrievalQuotas queries",
+		"Add fundsForecast query",
+		"Add CollateralWallet and CollateralRule fields to Deal",
+		"Add MaxBaseFee and HeldForGas fields to DealPublish",
+		"Add Free field to WaitDealsSector and WorkerUtilization field to SealingPipeline",
+		"Add logSubsystems query and logSetLevel mutation",
+		"Add transferHostTelemetry query",
+		"Add allDeals query to list boost and legacy deals together",
+	},
+}, {
+	Version: "1.0.0",
+	Changes: []string{"Initial version"},
+}}
+
+// GetChangelog returns the changelog for the JSON-RPC and graphql APIs
+func GetChangelog() Changelog {
+	deprecations := make([]Deprecation, 0, len(RPCDeprecations)+len(GraphqlDeprecations))
+	deprecations = append(deprecations, RPCDeprecations...)
+	deprecations = append(deprecations, GraphqlDeprecations...)
+	return Changelog{
+		RPCVersion:     BoostAPIVersion0.String(),
+		GraphqlVersion: GraphqlAPIVersion.String(),
+		RPC:            RPCChangelog,
+		Graphql:        GraphqlChangelog,
+		Deprecations:   deprecations,
+	}
+}
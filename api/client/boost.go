@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/filecoin-project/boost/api"
+	"github.com/filecoin-project/boost/dealbook"
+	smtypes "github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/google/uuid"
+)
+
+// Client is a typed client for the Boost APIs, for storage providers
+// automating boost from Go.
+// Deal listing and data import go over JSON-RPC. Operations that are only
+// exposed by the graphql server (retrying and failing paused deals, and
+// moving funds) go over graphql.
+type Client struct {
+	// The JSON-RPC API, for calls that don't have a wrapper method
+	RPC api.Boost
+	// The graphql API, for calls that don't have a wrapper method
+	Graphql *GraphqlClient
+
+	closer jsonrpc.ClientCloser
+}
+
+// ClientConfig configures the connection to a boost node
+type ClientConfig struct {
+	// The JSON-RPC API address, eg ws://localhost:1288/rpc/v0
+	RPCAddr string
+	// The graphql server address, eg http://localhost:8080
+	GraphqlAddr string
+	// An API token (created with 'boostd auth create-token').
+	// The token's permissions determine which operations are allowed.
+	Token string
+}
+
+// NewClient connects to the boost node's JSON-RPC API and creates a client
+// for its graphql API. Call Close on the client when finished with it.
+func NewClient(ctx context.Context, cfg ClientConfig) (*Client, error) {
+	header := http.Header{}
+	if cfg.Token != "" {
+		header.Set("Authorization", "Bearer "+cfg.Token)
+	}
+
+	// Ask each server for the version of its API that this client was
+	// written against, so that the connection fails if it's not compatible
+	rpcHeader := header.Clone()
+	rpcHeader.Set(api.VersionHeader, majorMinor(api.BoostAPIVersion0))
+	rpc, closer, err := NewBoostRPCV0(ctx, cfg.RPCAddr, rpcHeader)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to boost JSON-RPC API at %s: %w", cfg.RPCAddr, err)
+	}
+
+	gqlHeader := header.Clone()
+	gqlHeader.Set(api.VersionHeader, majorMinor(api.GraphqlAPIVersion))
+	return &Client{
+		RPC:     rpc,
+		Graphql: NewGraphqlClient(cfg.GraphqlAddr, gqlHeader),
+		closer:  closer,
+	}, nil
+}
+
+func majorMinor(v api.Version) string {
+	major, minor, _ := v.Ints()
+	return fmt.Sprintf("%d.%d", major, minor)
+}
+
+// Close closes the connection to the JSON-RPC API
+func (c *Client) Close() {
+	c.closer()
+}
+
+// Deal gets the deal with the given uuid
+func (c *Client) Deal(ctx context.Context, dealUuid uuid.UUID) (*smtypes.ProviderDealState, error) {
+	return c.RPC.BoostDeal(ctx, dealUuid)
+}
+
+// ListDeals lists the deals that match the filter, newest first
+func (c *Client) ListDeals(ctx context.Context, filter api.DealsFilter) ([]*smtypes.ProviderDealState, error) {
+	return c.RPC.BoostDeals(ctx, filter)
+}
+
+// ListAllDeals lists a page of boost deals and legacy deals that match the
+// filter, newest first
+func (c *Client) ListAllDeals(ctx context.Context, filter dealbook.Filter, offset int, limit int) (*dealbook.Page, error) {
+	return c.RPC.BoostDealsAll(ctx, filter, offset, limit)
+}
+
+// ImportData imports the data for an offline deal from a file on the
+// boost node. It returns an error if the deal was rejected.
+func (c *Client) ImportData(ctx context.Context, dealUuid uuid.UUID, filePath string) error {
+	rej, err := c.RPC.BoostOfflineDealWithData(ctx, dealUuid, filePath)
+	if err != nil {
+		return fmt.Errorf("importing data for deal %s: %w", dealUuid, err)
+	}
+	if rej != nil && rej.Reason != "" {
+		return fmt.Errorf("offline deal %s rejected: %s", dealUuid, rej.Reason)
+	}
+	return nil
+}
+
+// RetryPausedDeal retries a deal that was paused because of an error
+func (c *Client) RetryPausedDeal(ctx context.Context, dealUuid uuid.UUID) error {
+	return c.Graphql.DealRetryPaused(ctx, dealUuid)
+}
+
+// FailPausedDeal permanently fails a deal that was paused because of an error
+func (c *Client) FailPausedDeal(ctx context.Context, dealUuid uuid.UUID) error {
+	return c.Graphql.DealFailPaused(ctx, dealUuid)
+}
+
+// CancelDeal cancels a deal that is transferring data
+func (c *Client) CancelDeal(ctx context.Context, dealUuid uuid.UUID) error {
+	return c.Graphql.DealCancel(ctx, dealUuid)
+}
+
+// Funds gets the funds available for making deals
+func (c *Client) Funds(ctx context.Context) (*Funds, error) {
+	return c.Graphql.Funds(ctx)
+}
+
+// MoveFundsToEscrow moves funds from the collateral wallet to the storage
+// market actor escrow, to use as collateral for new deals
+func (c *Client) MoveFundsToEscrow(ctx context.Context, amount big.Int) error {
+	return c.Graphql.FundsMoveToEscrow(ctx, amount)
+}
@@ -0,0 +1,233 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/google/uuid"
+)
+
+// GraphqlClient calls the boost graphql server, for operations that are
+// not available over JSON-RPC (eg retrying paused deals and moving funds)
+type GraphqlClient struct {
+	// The graphql query endpoint, eg http://localhost:8080/graphql/query
+	url           string
+	requestHeader http.Header
+	httpClient    *http.Client
+
+	// If set, called with a warning for each deprecated field used by a
+	// query, so that the caller can find out about upcoming breaking changes
+	OnDeprecation func(warning string)
+}
+
+// NewGraphqlClient creates a client for the graphql server at addr,
+// eg http://localhost:8080.
+// If the graphql server requires authentication, requestHeader should
+// include an Authorization header with an API token.
+func NewGraphqlClient(addr string, requestHeader http.Header) *GraphqlClient {
+	return &GraphqlClient{
+		url:           strings.TrimSuffix(addr, "/") + "/graphql/query",
+		requestHeader: requestHeader,
+		httpClient:    http.DefaultClient,
+	}
+}
+
+type graphqlRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type graphqlError struct {
+	Message string `json:"message"`
+}
+
+type graphqlResponse struct {
+	Data       json.RawMessage `json:"data"`
+	Errors     []graphqlError  `json:"errors"`
+	Extensions struct {
+		Deprecations []string `json:"deprecations"`
+	} `json:"extensions"`
+}
+
+// Query executes a graphql query or mutation and unmarshalls the data in
+// the response into resp
+func (c *GraphqlClient) Query(ctx context.Context, query string, vars map[string]interface{}, resp interface{}) error {
+	body, err := json.Marshal(graphqlRequest{Query: query, Variables: vars})
+	if err != nil {
+		return fmt.Errorf("marshalling graphql request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, vs := range c.requestHeader {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending graphql request to %s: %w", c.url, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("graphql request to %s failed with status %d: %s", c.url, res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var gqlRes graphqlResponse
+	if err := json.NewDecoder(res.Body).Decode(&gqlRes); err != nil {
+		return fmt.Errorf("decoding graphql response: %w", err)
+	}
+	if c.OnDeprecation != nil {
+		for _, warning := range gqlRes.Extensions.Deprecations {
+			c.OnDeprecation(warning)
+		}
+	}
+	if len(gqlRes.Errors) > 0 {
+		msgs := make([]string, 0, len(gqlRes.Errors))
+		for _, e := range gqlRes.Errors {
+			msgs = append(msgs, e.Message)
+		}
+		return errors.New(strings.Join(msgs, "; "))
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.Unmarshal(gqlRes.Data, resp)
+}
+
+// DealCancel cancels the deal with the given uuid
+func (c *GraphqlClient) DealCancel(ctx context.Context, dealUuid uuid.UUID) error {
+	return c.dealMutation(ctx, "dealCancel", dealUuid)
+}
+
+// DealRetryPaused retries a deal that was paused because of an error
+func (c *GraphqlClient) DealRetryPaused(ctx context.Context, dealUuid uuid.UUID) error {
+	return c.dealMutation(ctx, "dealRetryPaused", dealUuid)
+}
+
+// DealFailPaused permanently fails a deal that was paused because of an error
+func (c *GraphqlClient) DealFailPaused(ctx context.Context, dealUuid uuid.UUID) error {
+	return c.dealMutation(ctx, "dealFailPaused", dealUuid)
+}
+
+func (c *GraphqlClient) dealMutation(ctx context.Context, mutation string, dealUuid uuid.UUID) error {
+	qry := fmt.Sprintf("mutation($id: ID!) { %s(id: $id) }", mutation)
+	return c.Query(ctx, qry, map[string]interface{}{"id": dealUuid.String()}, nil)
+}
+
+// DealPublishNow publishes all pending deals immediately
+func (c *GraphqlClient) DealPublishNow(ctx context.Context) error {
+	return c.Query(ctx, "mutation { dealPublishNow }", nil, nil)
+}
+
+// FundsEscrow is the storage provider's balance in the storage market actor
+type FundsEscrow struct {
+	Available big.Int
+	Locked    big.Int
+	Tagged    big.Int
+}
+
+// FundsWallet is the balance of one of the storage provider's wallets
+type FundsWallet struct {
+	Address string
+	Balance big.Int
+	Tagged  big.Int
+}
+
+// Funds are the funds available to the storage provider for making deals
+type Funds struct {
+	Escrow     FundsEscrow
+	Collateral FundsWallet
+	PubMsg     FundsWallet
+}
+
+// Funds gets the funds available for making deals
+func (c *GraphqlClient) Funds(ctx context.Context) (*Funds, error) {
+	qry := `query {
+  funds {
+    Escrow { Available Locked Tagged }
+    Collateral { Address Balance Tagged }
+    PubMsg { Address Balance Tagged }
+  }
+}`
+	var res struct {
+		Funds struct {
+			Escrow struct {
+				Available graphqlBigInt
+				Locked    graphqlBigInt
+				Tagged    graphqlBigInt
+			}
+			Collateral graphqlWallet
+			PubMsg     graphqlWallet
+		}
+	}
+	if err := c.Query(ctx, qry, nil, &res); err != nil {
+		return nil, err
+	}
+
+	fnds := res.Funds
+	return &Funds{
+		Escrow: FundsEscrow{
+			Available: fnds.Escrow.Available.Int,
+			Locked:    fnds.Escrow.Locked.Int,
+			Tagged:    fnds.Escrow.Tagged.Int,
+		},
+		Collateral: fnds.Collateral.toFundsWallet(),
+		PubMsg:     fnds.PubMsg.toFundsWallet(),
+	}, nil
+}
+
+// FundsMoveToEscrow moves the given amount from the collateral wallet to
+// the storage market actor escrow
+func (c *GraphqlClient) FundsMoveToEscrow(ctx context.Context, amount big.Int) error {
+	qry := "mutation($amount: BigInt!) { fundsMoveToEscrow(amount: $amount) }"
+	return c.Query(ctx, qry, map[string]interface{}{"amount": amount.String()}, nil)
+}
+
+type graphqlWallet struct {
+	Address string
+	Balance graphqlBigInt
+	Tagged  graphqlBigInt
+}
+
+func (w graphqlWallet) toFundsWallet() FundsWallet {
+	return FundsWallet{Address: w.Address, Balance: w.Balance.Int, Tagged: w.Tagged.Int}
+}
+
+// graphqlBigInt is the graphql server's representation of a BigInt,
+// eg { "n": "1234" }
+type graphqlBigInt struct {
+	big.Int
+}
+
+func (b *graphqlBigInt) UnmarshalJSON(data []byte) error {
+	var v struct {
+		N string `json:"n"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.N == "" {
+		b.Int = big.Zero()
+		return nil
+	}
+	i, err := big.FromString(v.N)
+	if err != nil {
+		return fmt.Errorf("parsing BigInt %s: %w", v.N, err)
+	}
+	b.Int = i
+	return nil
+}
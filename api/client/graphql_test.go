@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphqlClient(t *testing.T) {
+	ctx := context.Background()
+
+	var lastReq graphqlRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/graphql/query", r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer my-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&lastReq))
+
+		if _, ok := lastReq.Variables["id"]; !ok {
+			_, _ = w.Write([]byte(`{"data":{"funds":{` +
+				`"Escrow":{"Available":{"__typename":"BigInt","n":"10"},"Locked":{"__typename":"BigInt","n":"20"},"Tagged":{"__typename":"BigInt","n":"0"}},` +
+				`"Collateral":{"Address":"f01","Balance":{"__typename":"BigInt","n":"30"},"Tagged":{"__typename":"BigInt","n":"0"}},` +
+				`"PubMsg":{"Address":"f02","Balance":{"__typename":"BigInt","n":"40"},"Tagged":{"__typename":"BigInt","n":"5"}}}}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"errors":[{"message":"deal not paused"}]}`))
+	}))
+	defer srv.Close()
+
+	// Requests without a token should be rejected
+	err := NewGraphqlClient(srv.URL, nil).DealPublishNow(ctx)
+	require.ErrorContains(t, err, "status 401")
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer my-token")
+	gc := NewGraphqlClient(srv.URL+"/", header)
+
+	fnds, err := gc.Funds(ctx)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(10), fnds.Escrow.Available)
+	require.Equal(t, big.NewInt(20), fnds.Escrow.Locked)
+	require.Equal(t, "f01", fnds.Collateral.Address)
+	require.Equal(t, big.NewInt(30), fnds.Collateral.Balance)
+	require.Equal(t, big.NewInt(5), fnds.PubMsg.Tagged)
+
+	// Errors from the graphql server should be returned
+	dealUuid := uuid.New()
+	err = gc.DealRetryPaused(ctx, dealUuid)
+	require.ErrorContains(t, err, "deal not paused")
+	require.Equal(t, dealUuid.String(), lastReq.Variables["id"])
+	require.Contains(t, lastReq.Query, "dealRetryPaused(id: $id)")
+}
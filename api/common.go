@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/filecoin-project/go-jsonrpc/auth"
+	apitypes "github.com/filecoin-project/lotus/api/types"
 )
 
 //                       MODIFYING THE API INTERFACE
@@ -29,6 +30,12 @@ type Common interface {
 	LogList(context.Context) ([]string, error)         //perm:write
 	LogSetLevel(context.Context, string, string) error //perm:write
 
+	// MethodGroup: Common
+
+	// Discover returns an OpenRPC document describing the boost RPC API.
+	// The document is also served over HTTP at /rpc/openrpc.json
+	Discover(ctx context.Context) (apitypes.OpenRPCDocument, error) //perm:read
+
 	//// LogAlerts returns list of all, active and inactive alerts tracked by the
 	//// node
 	//LogAlerts(ctx context.Context) ([]alerting.Alert, error) //perm:admin
@@ -38,9 +45,6 @@ type Common interface {
 	//// Version provides information about API provider
 	//Version(context.Context) (APIVersion, error) //perm:read
 
-	//// Discover returns an OpenRPC document describing an RPC API.
-	//Discover(ctx context.Context) (apitypes.OpenRPCDocument, error) //perm:read
-
 	//// trigger graceful shutdown
 	//Shutdown(context.Context) error //perm:admin
 
@@ -102,7 +102,7 @@ func NewLotusOpenRPCDocument(Comments, GroupDocs map[string]string) *go_openrpc_
 		},
 		GetInfoFn: func() (info *meta_schema.InfoObject) {
 			info = &meta_schema.InfoObject{}
-			title := "Lotus RPC API"
+			title := "Boost RPC API"
 			info.Title = (*meta_schema.InfoObjectProperties)(&title)
 
 			version := build.BuildVersion
@@ -159,3 +159,18 @@ func NewLotusOpenRPCDocument(Comments, GroupDocs map[string]string) *go_openrpc_
 	d.WithReflector(appReflector)
 	return d
 }
+
+// RegisterReceivers registers the receiver with the document, along with the
+// structs embedded in it (eg CommonStruct and NetStruct). The reflector skips
+// methods that are promoted from embedded structs, so they must be registered
+// separately.
+func RegisterReceivers(d *go_openrpc_reflect.Document, name string, receiver interface{}) {
+	d.RegisterReceiverName(name, receiver)
+
+	rv := reflect.ValueOf(receiver).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		if rv.Type().Field(i).Anonymous {
+			d.RegisterReceiverName(name, rv.Field(i).Addr().Interface())
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package docgenopenrpc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/filecoin-project/boost/api/docgen"
+	"github.com/filecoin-project/boost/build"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOpenRPCDocumentUpToDate fails if build/openrpc/boost.json.gz needs to
+// be regenerated with `make docsgen-openrpc`
+func TestOpenRPCDocumentUpToDate(t *testing.T) {
+	comments, groupDocs := docgen.ParseApiASTInfo("../api.go", "Boost", "api", "..")
+	doc := NewLotusOpenRPCDocument(comments, groupDocs)
+	i, _, _ := docgen.GetAPIType("Boost", "api")
+	RegisterReceivers(doc, "Filecoin", i)
+
+	generated, err := doc.Discover()
+	require.NoError(t, err)
+
+	// Compare the JSON encodings, as that's what is written to the file
+	expected, err := json.Marshal(generated)
+	require.NoError(t, err)
+	actual, err := json.Marshal(build.OpenRPCDiscoverJSON_Boost())
+	require.NoError(t, err)
+	require.JSONEq(t, string(expected), string(actual), "build/openrpc/boost.json.gz is out of date: run make docsgen-openrpc")
+}
@@ -104,6 +104,7 @@ func init() {
 	addExample(crypto.SigTypeBLS)
 	addExample(types.KTBLS)
 	addExample(int64(9))
+	addExample(int32(9))
 	addExample(12.3)
 	addExample(123)
 	addExample(uintptr(0))
@@ -266,13 +267,14 @@ func init() {
 	addExample(apitypes.OpenRPCDocument{
 		"openrpc": "1.2.6",
 		"info": map[string]interface{}{
-			"title":   "Lotus RPC API",
+			"title":   "Boost RPC API",
 			"version": "1.2.1/generated=2020-11-22T08:22:42-06:00",
 		},
 		"methods": []interface{}{}},
 	)
 
 	addExample(api.CheckStatusCode(0))
+	addExample(lapi.SectorState("Proving"))
 	addExample(map[string]interface{}{"abc": 123})
 	addExample(api.DagstoreShardResult{
 		Key:   "baga6ea4seaqecmtz7iak33dsfshi627abz4i4665dfuzr3qfs4bmad6dx3iigdq",
@@ -358,12 +360,24 @@ func ExampleValue(method string, t, parent reflect.Type) interface{} {
 		}
 		return out.Interface()
 
+	case reflect.Map:
+		out := reflect.MakeMap(t)
+		out.SetMapIndex(reflect.ValueOf(ExampleValue(method, t.Key(), t)), reflect.ValueOf(ExampleValue(method, t.Elem(), t)))
+		return out.Interface()
 	case reflect.Ptr:
+		if v, ok := ExampleValues[t.Elem()]; ok {
+			out := reflect.New(t.Elem())
+			out.Elem().Set(reflect.ValueOf(v))
+			return out.Interface()
+		}
 		if t.Elem().Kind() == reflect.Struct {
 			es := exampleStruct(method, t.Elem(), t)
 			//ExampleValues[t] = es
 			return es
 		}
+		out := reflect.New(t.Elem())
+		out.Elem().Set(reflect.ValueOf(ExampleValue(method, t.Elem(), t)))
+		return out.Interface()
 	case reflect.Interface:
 		return struct{}{}
 	}
@@ -389,6 +403,8 @@ func exampleStruct(method string, t, parent reflect.Type) interface{} {
 type Visitor struct {
 	Root    string
 	Methods map[string]ast.Node
+	// The names of the interfaces embedded in the Root interface
+	Embedded []string
 }
 
 func (v *Visitor) Visit(node ast.Node) ast.Visitor {
@@ -405,6 +421,8 @@ func (v *Visitor) Visit(node ast.Node) ast.Visitor {
 	for _, m := range iface.Methods.List {
 		if len(m.Names) > 0 {
 			v.Methods[m.Names[0].Name] = m
+		} else if embedded, ok := m.Type.(*ast.Ident); ok {
+			v.Embedded = append(v.Embedded, embedded.Name)
 		}
 	}
 
@@ -437,9 +455,29 @@ func ParseApiASTInfo(apiFile, iface, pkg, dir string) (comments map[string]strin
 
 	cmap := ast.NewCommentMap(fset, f, f.Comments)
 
-	v := &Visitor{iface, make(map[string]ast.Node)}
+	v := &Visitor{Root: iface, Methods: make(map[string]ast.Node)}
 	ast.Walk(v, ap)
 
+	// Include the methods of embedded interfaces (eg Common), along with
+	// the comments from the files they are declared in
+	for _, embedded := range v.Embedded {
+		ev := &Visitor{Root: embedded, Methods: make(map[string]ast.Node)}
+		ast.Walk(ev, ap)
+		for mn, node := range ev.Methods {
+			if _, ok := v.Methods[mn]; !ok {
+				v.Methods[mn] = node
+			}
+		}
+	}
+	for fname, af := range ap.Files {
+		if fname == apiFile {
+			continue
+		}
+		for node, groups := range ast.NewCommentMap(fset, af, af.Comments) {
+			cmap[node] = groups
+		}
+	}
+
 	comments = make(map[string]string)
 	groupDocs = make(map[string]string)
 	for mn, node := range v.Methods {
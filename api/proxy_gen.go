@@ -7,6 +7,17 @@ import (
 	"errors"
 	"time"
 
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/dealbook"
+	"github.com/filecoin-project/boost/health"
+	"github.com/filecoin-project/boost/retrievalmarket/quota"
+	"github.com/filecoin-project/boost/retrievalmarket/rtvllog"
+	"github.com/filecoin-project/boost/retrievalpolicy"
+	"github.com/filecoin-project/boost/storagemarket/backpressure"
+	"github.com/filecoin-project/boost/storagemarket/funds"
+	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
+	"github.com/filecoin-project/boost/storagemarket/sealingpriority"
+	"github.com/filecoin-project/boost/storagemarket/sectorpacking"
 	smtypes "github.com/filecoin-project/boost/storagemarket/types"
 	"github.com/filecoin-project/go-address"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
@@ -15,9 +26,11 @@ import (
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/go-jsonrpc/auth"
 	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin/v8/paych"
 	"github.com/filecoin-project/go-state-types/crypto"
 	lapi "github.com/filecoin-project/lotus/api"
 	lotus_api "github.com/filecoin-project/lotus/api"
+	apitypes "github.com/filecoin-project/lotus/api/types"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/google/uuid"
 	"github.com/ipfs/go-cid"
@@ -64,14 +77,60 @@ type BoostStruct struct {
 
 		BoostDealBySignedProposalCid func(p0 context.Context, p1 cid.Cid) (*smtypes.ProviderDealState, error) `perm:"admin"`
 
+		BoostDeals func(p0 context.Context, p1 DealsFilter) ([]*smtypes.ProviderDealState, error) `perm:"read"`
+
+		BoostDealsAll func(p0 context.Context, p1 dealbook.Filter, p2 int, p3 int) (*dealbook.Page, error) `perm:"read"`
+
 		BoostDummyDeal func(p0 context.Context, p1 smtypes.DealParams) (*ProviderDealRejectionInfo, error) `perm:"admin"`
 
+		BoostFundsForecast func(p0 context.Context) (*funds.Forecast, error) `perm:"read"`
+
+		BoostFundsHistory func(p0 context.Context, p1 time.Time, p2 time.Time) ([]db.FundsMovement, error) `perm:"read"`
+
+		BoostHealth func(p0 context.Context) (*health.Status, error) `perm:"read"`
+
 		BoostIndexerAnnounceAllDeals func(p0 context.Context) error `perm:"admin"`
 
+		BoostIndexerAnnounceDeals func(p0 context.Context, p1 IndexerAnnounceParams) (*IndexerAnnounceResult, error) `perm:"admin"`
+
+		BoostIndexerDirectAnnounceStatus func(p0 context.Context) ([]IndexerDirectAnnounceStatus, error) `perm:"read"`
+
+		BoostIndexerPendingAnnouncements func(p0 context.Context) ([]IndexerPendingAnnouncement, error) `perm:"read"`
+
+		BoostIndexerStatus func(p0 context.Context) (*IndexerStatus, error) `perm:"read"`
+
+		BoostIndexerVerify func(p0 context.Context, p1 int) (*IndexerVerifyResult, error) `perm:"admin"`
+
 		BoostMakeDeal func(p0 context.Context, p1 smtypes.DealParams) (*ProviderDealRejectionInfo, error) `perm:"write"`
 
 		BoostOfflineDealWithData func(p0 context.Context, p1 uuid.UUID, p2 string) (*ProviderDealRejectionInfo, error) `perm:"admin"`
 
+		BoostRetrievalAttemptsAdd func(p0 context.Context, p1 []rtvllog.RetrievalAttempt) error `perm:"write"`
+
+		BoostRetrievalEarnings func(p0 context.Context) ([]RetrievalEarnings, error) `perm:"read"`
+
+		BoostRetrievalPaymentAddVoucher func(p0 context.Context, p1 string, p2 *paych.SignedVoucher) (abi.TokenAmount, error) `perm:"write"`
+
+		BoostRetrievalPaymentRefund func(p0 context.Context, p1 string, p2 abi.TokenAmount) (abi.TokenAmount, error) `perm:"write"`
+
+		BoostRetrievalPaymentReserve func(p0 context.Context, p1 string) (*RetrievalPaymentReservation, error) `perm:"write"`
+
+		BoostRetrievalPaymentTerms func(p0 context.Context) (*RetrievalPaymentTerms, error) `perm:"read"`
+
+		BoostRetrievalPolicy func(p0 context.Context) (*retrievalpolicy.Config, error) `perm:"read"`
+
+		BoostRetrievalQuota func(p0 context.Context, p1 string) (*quota.Status, error) `perm:"read"`
+
+		BoostSealingBackpressure func(p0 context.Context) (*backpressure.Status, error) `perm:"read"`
+
+		BoostSealingPipelineStatus func(p0 context.Context) (*sealingpipeline.Status, error) `perm:"read"`
+
+		BoostSealingPriority func(p0 context.Context) (*sealingpriority.Status, error) `perm:"read"`
+
+		BoostSectorPacking func(p0 context.Context) (*sectorpacking.Report, error) `perm:"read"`
+
+		BoostSetRetrievalPolicy func(p0 context.Context, p1 retrievalpolicy.Config) error `perm:"admin"`
+
 		DealsConsiderOfflineRetrievalDeals func(p0 context.Context) (bool, error) `perm:"admin"`
 
 		DealsConsiderOfflineStorageDeals func(p0 context.Context) (bool, error) `perm:"admin"`
@@ -126,16 +185,28 @@ type BoostStruct struct {
 
 		OnlineBackup func(p0 context.Context, p1 string) error `perm:"admin"`
 
+		PiecesCheckHealth func(p0 context.Context, p1 cid.Cid, p2 bool) (*PieceHealth, error) `perm:"admin"`
+
 		PiecesGetCIDInfo func(p0 context.Context, p1 cid.Cid) (*piecestore.CIDInfo, error) `perm:"read"`
 
 		PiecesGetMaxOffset func(p0 context.Context, p1 cid.Cid) (uint64, error) `perm:"read"`
 
 		PiecesGetPieceInfo func(p0 context.Context, p1 cid.Cid) (*piecestore.PieceInfo, error) `perm:"read"`
 
+		PiecesHealth func(p0 context.Context) ([]PieceHealth, error) `perm:"read"`
+
 		PiecesListCidInfos func(p0 context.Context) ([]cid.Cid, error) `perm:"read"`
 
 		PiecesListPieces func(p0 context.Context) ([]cid.Cid, error) `perm:"read"`
 
+		PiecesRemove func(p0 context.Context, p1 cid.Cid, p2 bool) (*PieceRemoval, error) `perm:"admin"`
+
+		PiecesRemoveUnsealed func(p0 context.Context, p1 cid.Cid, p2 bool) ([]abi.SectorNumber, error) `perm:"admin"`
+
+		PiecesUnseal func(p0 context.Context, p1 cid.Cid) error `perm:"admin"`
+
+		PiecesUnsealedStatus func(p0 context.Context, p1 cid.Cid) (*PieceUnsealedStatus, error) `perm:"read"`
+
 		RuntimeSubsystems func(p0 context.Context) (lapi.MinerSubsystems, error) `perm:"read"`
 
 		SectorsRefs func(p0 context.Context) (map[string][]lapi.SealedRef, error) `perm:"read"`
@@ -165,6 +236,8 @@ type CommonStruct struct {
 
 		AuthVerify func(p0 context.Context, p1 string) ([]auth.Permission, error) `perm:"read"`
 
+		Discover func(p0 context.Context) (apitypes.OpenRPCDocument, error) `perm:"read"`
+
 		LogList func(p0 context.Context) ([]string, error) `perm:"write"`
 
 		LogSetLevel func(p0 context.Context, p1 string, p2 string) error `perm:"write"`
@@ -419,6 +492,28 @@ func (s *BoostStub) BoostDealBySignedProposalCid(p0 context.Context, p1 cid.Cid)
 	return nil, ErrNotSupported
 }
 
+func (s *BoostStruct) BoostDeals(p0 context.Context, p1 DealsFilter) ([]*smtypes.ProviderDealState, error) {
+	if s.Internal.BoostDeals == nil {
+		return *new([]*smtypes.ProviderDealState), ErrNotSupported
+	}
+	return s.Internal.BoostDeals(p0, p1)
+}
+
+func (s *BoostStub) BoostDeals(p0 context.Context, p1 DealsFilter) ([]*smtypes.ProviderDealState, error) {
+	return *new([]*smtypes.ProviderDealState), ErrNotSupported
+}
+
+func (s *BoostStruct) BoostDealsAll(p0 context.Context, p1 dealbook.Filter, p2 int, p3 int) (*dealbook.Page, error) {
+	if s.Internal.BoostDealsAll == nil {
+		return nil, ErrNotSupported
+	}
+	return s.Internal.BoostDealsAll(p0, p1, p2, p3)
+}
+
+func (s *BoostStub) BoostDealsAll(p0 context.Context, p1 dealbook.Filter, p2 int, p3 int) (*dealbook.Page, error) {
+	return nil, ErrNotSupported
+}
+
 func (s *BoostStruct) BoostDummyDeal(p0 context.Context, p1 smtypes.DealParams) (*ProviderDealRejectionInfo, error) {
 	if s.Internal.BoostDummyDeal == nil {
 		return nil, ErrNotSupported
@@ -430,6 +525,39 @@ func (s *BoostStub) BoostDummyDeal(p0 context.Context, p1 smtypes.DealParams) (*
 	return nil, ErrNotSupported
 }
 
+func (s *BoostStruct) BoostFundsForecast(p0 context.Context) (*funds.Forecast, error) {
+	if s.Internal.BoostFundsForecast == nil {
+		return nil, ErrNotSupported
+	}
+	return s.Internal.BoostFundsForecast(p0)
+}
+
+func (s *BoostStub) BoostFundsForecast(p0 context.Context) (*funds.Forecast, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *BoostStruct) BoostFundsHistory(p0 context.Context, p1 time.Time, p2 time.Time) ([]db.FundsMovement, error) {
+	if s.Internal.BoostFundsHistory == nil {
+		return *new([]db.FundsMovement), ErrNotSupported
+	}
+	return s.Internal.BoostFundsHistory(p0, p1, p2)
+}
+
+func (s *BoostStub) BoostFundsHistory(p0 context.Context, p1 time.Time, p2 time.Time) ([]db.FundsMovement, error) {
+	return *new([]db.FundsMovement), ErrNotSupported
+}
+
+func (s *BoostStruct) BoostHealth(p0 context.Context) (*health.Status, error) {
+	if s.Internal.BoostHealth == nil {
+		return nil, ErrNotSupported
+	}
+	return s.Internal.BoostHealth(p0)
+}
+
+func (s *BoostStub) BoostHealth(p0 context.Context) (*health.Status, error) {
+	return nil, ErrNotSupported
+}
+
 func (s *BoostStruct) BoostIndexerAnnounceAllDeals(p0 context.Context) error {
 	if s.Internal.BoostIndexerAnnounceAllDeals == nil {
 		return ErrNotSupported
@@ -441,6 +569,61 @@ func (s *BoostStub) BoostIndexerAnnounceAllDeals(p0 context.Context) error {
 	return ErrNotSupported
 }
 
+func (s *BoostStruct) BoostIndexerAnnounceDeals(p0 context.Context, p1 IndexerAnnounceParams) (*IndexerAnnounceResult, error) {
+	if s.Internal.BoostIndexerAnnounceDeals == nil {
+		return nil, ErrNotSupported
+	}
+	return s.Internal.BoostIndexerAnnounceDeals(p0, p1)
+}
+
+func (s *BoostStub) BoostIndexerAnnounceDeals(p0 context.Context, p1 IndexerAnnounceParams) (*IndexerAnnounceResult, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *BoostStruct) BoostIndexerDirectAnnounceStatus(p0 context.Context) ([]IndexerDirectAnnounceStatus, error) {
+	if s.Internal.BoostIndexerDirectAnnounceStatus == nil {
+		return *new([]IndexerDirectAnnounceStatus), ErrNotSupported
+	}
+	return s.Internal.BoostIndexerDirectAnnounceStatus(p0)
+}
+
+func (s *BoostStub) BoostIndexerDirectAnnounceStatus(p0 context.Context) ([]IndexerDirectAnnounceStatus, error) {
+	return *new([]IndexerDirectAnnounceStatus), ErrNotSupported
+}
+
+func (s *BoostStruct) BoostIndexerPendingAnnouncements(p0 context.Context) ([]IndexerPendingAnnouncement, error) {
+	if s.Internal.BoostIndexerPendingAnnouncements == nil {
+		return *new([]IndexerPendingAnnouncement), ErrNotSupported
+	}
+	return s.Internal.BoostIndexerPendingAnnouncements(p0)
+}
+
+func (s *BoostStub) BoostIndexerPendingAnnouncements(p0 context.Context) ([]IndexerPendingAnnouncement, error) {
+	return *new([]IndexerPendingAnnouncement), ErrNotSupported
+}
+
+func (s *BoostStruct) BoostIndexerStatus(p0 context.Context) (*IndexerStatus, error) {
+	if s.Internal.BoostIndexerStatus == nil {
+		return nil, ErrNotSupported
+	}
+	return s.Internal.BoostIndexerStatus(p0)
+}
+
+func (s *BoostStub) BoostIndexerStatus(p0 context.Context) (*IndexerStatus, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *BoostStruct) BoostIndexerVerify(p0 context.Context, p1 int) (*IndexerVerifyResult, error) {
+	if s.Internal.BoostIndexerVerify == nil {
+		return nil, ErrNotSupported
+	}
+	return s.Internal.BoostIndexerVerify(p0, p1)
+}
+
+func (s *BoostStub) BoostIndexerVerify(p0 context.Context, p1 int) (*IndexerVerifyResult, error) {
+	return nil, ErrNotSupported
+}
+
 func (s *BoostStruct) BoostMakeDeal(p0 context.Context, p1 smtypes.DealParams) (*ProviderDealRejectionInfo, error) {
 	if s.Internal.BoostMakeDeal == nil {
 		return nil, ErrNotSupported
@@ -463,6 +646,149 @@ func (s *BoostStub) BoostOfflineDealWithData(p0 context.Context, p1 uuid.UUID, p
 	return nil, ErrNotSupported
 }
 
+func (s *BoostStruct) BoostRetrievalAttemptsAdd(p0 context.Context, p1 []rtvllog.RetrievalAttempt) error {
+	if s.Internal.BoostRetrievalAttemptsAdd == nil {
+		return ErrNotSupported
+	}
+	return s.Internal.BoostRetrievalAttemptsAdd(p0, p1)
+}
+
+func (s *BoostStub) BoostRetrievalAttemptsAdd(p0 context.Context, p1 []rtvllog.RetrievalAttempt) error {
+	return ErrNotSupported
+}
+
+func (s *BoostStruct) BoostRetrievalEarnings(p0 context.Context) ([]RetrievalEarnings, error) {
+	if s.Internal.BoostRetrievalEarnings == nil {
+		return *new([]RetrievalEarnings), ErrNotSupported
+	}
+	return s.Internal.BoostRetrievalEarnings(p0)
+}
+
+func (s *BoostStub) BoostRetrievalEarnings(p0 context.Context) ([]RetrievalEarnings, error) {
+	return *new([]RetrievalEarnings), ErrNotSupported
+}
+
+func (s *BoostStruct) BoostRetrievalPaymentAddVoucher(p0 context.Context, p1 string, p2 *paych.SignedVoucher) (abi.TokenAmount, error) {
+	if s.Internal.BoostRetrievalPaymentAddVoucher == nil {
+		return *new(abi.TokenAmount), ErrNotSupported
+	}
+	return s.Internal.BoostRetrievalPaymentAddVoucher(p0, p1, p2)
+}
+
+func (s *BoostStub) BoostRetrievalPaymentAddVoucher(p0 context.Context, p1 string, p2 *paych.SignedVoucher) (abi.TokenAmount, error) {
+	return *new(abi.TokenAmount), ErrNotSupported
+}
+
+func (s *BoostStruct) BoostRetrievalPaymentRefund(p0 context.Context, p1 string, p2 abi.TokenAmount) (abi.TokenAmount, error) {
+	if s.Internal.BoostRetrievalPaymentRefund == nil {
+		return *new(abi.TokenAmount), ErrNotSupported
+	}
+	return s.Internal.BoostRetrievalPaymentRefund(p0, p1, p2)
+}
+
+func (s *BoostStub) BoostRetrievalPaymentRefund(p0 context.Context, p1 string, p2 abi.TokenAmount) (abi.TokenAmount, error) {
+	return *new(abi.TokenAmount), ErrNotSupported
+}
+
+func (s *BoostStruct) BoostRetrievalPaymentReserve(p0 context.Context, p1 string) (*RetrievalPaymentReservation, error) {
+	if s.Internal.BoostRetrievalPaymentReserve == nil {
+		return nil, ErrNotSupported
+	}
+	return s.Internal.BoostRetrievalPaymentReserve(p0, p1)
+}
+
+func (s *BoostStub) BoostRetrievalPaymentReserve(p0 context.Context, p1 string) (*RetrievalPaymentReservation, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *BoostStruct) BoostRetrievalPaymentTerms(p0 context.Context) (*RetrievalPaymentTerms, error) {
+	if s.Internal.BoostRetrievalPaymentTerms == nil {
+		return nil, ErrNotSupported
+	}
+	return s.Internal.BoostRetrievalPaymentTerms(p0)
+}
+
+func (s *BoostStub) BoostRetrievalPaymentTerms(p0 context.Context) (*RetrievalPaymentTerms, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *BoostStruct) BoostRetrievalPolicy(p0 context.Context) (*retrievalpolicy.Config, error) {
+	if s.Internal.BoostRetrievalPolicy == nil {
+		return nil, ErrNotSupported
+	}
+	return s.Internal.BoostRetrievalPolicy(p0)
+}
+
+func (s *BoostStub) BoostRetrievalPolicy(p0 context.Context) (*retrievalpolicy.Config, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *BoostStruct) BoostRetrievalQuota(p0 context.Context, p1 string) (*quota.Status, error) {
+	if s.Internal.BoostRetrievalQuota == nil {
+		return nil, ErrNotSupported
+	}
+	return s.Internal.BoostRetrievalQuota(p0, p1)
+}
+
+func (s *BoostStub) BoostRetrievalQuota(p0 context.Context, p1 string) (*quota.Status, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *BoostStruct) BoostSealingBackpressure(p0 context.Context) (*backpressure.Status, error) {
+	if s.Internal.BoostSealingBackpressure == nil {
+		return nil, ErrNotSupported
+	}
+	return s.Internal.BoostSealingBackpressure(p0)
+}
+
+func (s *BoostStub) BoostSealingBackpressure(p0 context.Context) (*backpressure.Status, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *BoostStruct) BoostSealingPipelineStatus(p0 context.Context) (*sealingpipeline.Status, error) {
+	if s.Internal.BoostSealingPipelineStatus == nil {
+		return nil, ErrNotSupported
+	}
+	return s.Internal.BoostSealingPipelineStatus(p0)
+}
+
+func (s *BoostStub) BoostSealingPipelineStatus(p0 context.Context) (*sealingpipeline.Status, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *BoostStruct) BoostSealingPriority(p0 context.Context) (*sealingpriority.Status, error) {
+	if s.Internal.BoostSealingPriority == nil {
+		return nil, ErrNotSupported
+	}
+	return s.Internal.BoostSealingPriority(p0)
+}
+
+func (s *BoostStub) BoostSealingPriority(p0 context.Context) (*sealingpriority.Status, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *BoostStruct) BoostSectorPacking(p0 context.Context) (*sectorpacking.Report, error) {
+	if s.Internal.BoostSectorPacking == nil {
+		return nil, ErrNotSupported
+	}
+	return s.Internal.BoostSectorPacking(p0)
+}
+
+func (s *BoostStub) BoostSectorPacking(p0 context.Context) (*sectorpacking.Report, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *BoostStruct) BoostSetRetrievalPolicy(p0 context.Context, p1 retrievalpolicy.Config) error {
+	if s.Internal.BoostSetRetrievalPolicy == nil {
+		return ErrNotSupported
+	}
+	return s.Internal.BoostSetRetrievalPolicy(p0, p1)
+}
+
+func (s *BoostStub) BoostSetRetrievalPolicy(p0 context.Context, p1 retrievalpolicy.Config) error {
+	return ErrNotSupported
+}
+
 func (s *BoostStruct) DealsConsiderOfflineRetrievalDeals(p0 context.Context) (bool, error) {
 	if s.Internal.DealsConsiderOfflineRetrievalDeals == nil {
 		return false, ErrNotSupported
@@ -760,6 +1086,17 @@ func (s *BoostStub) OnlineBackup(p0 context.Context, p1 string) error {
 	return ErrNotSupported
 }
 
+func (s *BoostStruct) PiecesCheckHealth(p0 context.Context, p1 cid.Cid, p2 bool) (*PieceHealth, error) {
+	if s.Internal.PiecesCheckHealth == nil {
+		return nil, ErrNotSupported
+	}
+	return s.Internal.PiecesCheckHealth(p0, p1, p2)
+}
+
+func (s *BoostStub) PiecesCheckHealth(p0 context.Context, p1 cid.Cid, p2 bool) (*PieceHealth, error) {
+	return nil, ErrNotSupported
+}
+
 func (s *BoostStruct) PiecesGetCIDInfo(p0 context.Context, p1 cid.Cid) (*piecestore.CIDInfo, error) {
 	if s.Internal.PiecesGetCIDInfo == nil {
 		return nil, ErrNotSupported
@@ -793,6 +1130,17 @@ func (s *BoostStub) PiecesGetPieceInfo(p0 context.Context, p1 cid.Cid) (*piecest
 	return nil, ErrNotSupported
 }
 
+func (s *BoostStruct) PiecesHealth(p0 context.Context) ([]PieceHealth, error) {
+	if s.Internal.PiecesHealth == nil {
+		return *new([]PieceHealth), ErrNotSupported
+	}
+	return s.Internal.PiecesHealth(p0)
+}
+
+func (s *BoostStub) PiecesHealth(p0 context.Context) ([]PieceHealth, error) {
+	return *new([]PieceHealth), ErrNotSupported
+}
+
 func (s *BoostStruct) PiecesListCidInfos(p0 context.Context) ([]cid.Cid, error) {
 	if s.Internal.PiecesListCidInfos == nil {
 		return *new([]cid.Cid), ErrNotSupported
@@ -815,6 +1163,50 @@ func (s *BoostStub) PiecesListPieces(p0 context.Context) ([]cid.Cid, error) {
 	return *new([]cid.Cid), ErrNotSupported
 }
 
+func (s *BoostStruct) PiecesRemove(p0 context.Context, p1 cid.Cid, p2 bool) (*PieceRemoval, error) {
+	if s.Internal.PiecesRemove == nil {
+		return nil, ErrNotSupported
+	}
+	return s.Internal.PiecesRemove(p0, p1, p2)
+}
+
+func (s *BoostStub) PiecesRemove(p0 context.Context, p1 cid.Cid, p2 bool) (*PieceRemoval, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *BoostStruct) PiecesRemoveUnsealed(p0 context.Context, p1 cid.Cid, p2 bool) ([]abi.SectorNumber, error) {
+	if s.Internal.PiecesRemoveUnsealed == nil {
+		return *new([]abi.SectorNumber), ErrNotSupported
+	}
+	return s.Internal.PiecesRemoveUnsealed(p0, p1, p2)
+}
+
+func (s *BoostStub) PiecesRemoveUnsealed(p0 context.Context, p1 cid.Cid, p2 bool) ([]abi.SectorNumber, error) {
+	return *new([]abi.SectorNumber), ErrNotSupported
+}
+
+func (s *BoostStruct) PiecesUnseal(p0 context.Context, p1 cid.Cid) error {
+	if s.Internal.PiecesUnseal == nil {
+		return ErrNotSupported
+	}
+	return s.Internal.PiecesUnseal(p0, p1)
+}
+
+func (s *BoostStub) PiecesUnseal(p0 context.Context, p1 cid.Cid) error {
+	return ErrNotSupported
+}
+
+func (s *BoostStruct) PiecesUnsealedStatus(p0 context.Context, p1 cid.Cid) (*PieceUnsealedStatus, error) {
+	if s.Internal.PiecesUnsealedStatus == nil {
+		return nil, ErrNotSupported
+	}
+	return s.Internal.PiecesUnsealedStatus(p0, p1)
+}
+
+func (s *BoostStub) PiecesUnsealedStatus(p0 context.Context, p1 cid.Cid) (*PieceUnsealedStatus, error) {
+	return nil, ErrNotSupported
+}
+
 func (s *BoostStruct) RuntimeSubsystems(p0 context.Context) (lapi.MinerSubsystems, error) {
 	if s.Internal.RuntimeSubsystems == nil {
 		return *new(lapi.MinerSubsystems), ErrNotSupported
@@ -881,6 +1273,17 @@ func (s *CommonStub) AuthVerify(p0 context.Context, p1 string) ([]auth.Permissio
 	return *new([]auth.Permission), ErrNotSupported
 }
 
+func (s *CommonStruct) Discover(p0 context.Context) (apitypes.OpenRPCDocument, error) {
+	if s.Internal.Discover == nil {
+		return *new(apitypes.OpenRPCDocument), ErrNotSupported
+	}
+	return s.Internal.Discover(p0)
+}
+
+func (s *CommonStub) Discover(p0 context.Context) (apitypes.OpenRPCDocument, error) {
+	return *new(apitypes.OpenRPCDocument), ErrNotSupported
+}
+
 func (s *CommonStruct) LogList(p0 context.Context) ([]string, error) {
 	if s.Internal.LogList == nil {
 		return *new([]string), ErrNotSupported
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/google/uuid"
 
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	"github.com/filecoin-project/go-state-types/abi"
@@ -203,3 +205,242 @@ type SealingPipelineState struct {
 	MaxSealingSectors         uint64
 	MaxSealingSectorsForDeals uint64
 }
+
+// DealsFilter filters the deals returned by BoostDeals.
+// Empty fields match all deals.
+type DealsFilter struct {
+	// Search for deals with an ID, piece CID, client address etc that
+	// matches the query
+	Query         string
+	Checkpoint    string
+	ClientAddress string
+	PieceCid      string
+	// Matches deals whose error message contains this string
+	ErrContains string
+	IsVerified  *bool
+	// Matches deals created at or after this time
+	CreatedAfter time.Time
+	// Matches deals created before this time
+	CreatedBefore time.Time
+	// The maximum number of deals to return (newest first)
+	Limit int
+}
+
+// IndexerPendingAnnouncement is an announcement of a deal to the network
+// indexer that failed and is waiting to be retried
+type IndexerPendingAnnouncement struct {
+	DealUuid  uuid.UUID
+	CreatedAt time.Time
+	// The number of failed attempts to announce the deal
+	Attempts int
+	// The time after which the announcement will be retried
+	NextAttempt time.Time
+	LastError   string
+}
+
+// IndexerAnnounceParams selects the active deals to announce to the
+// network indexer. Empty fields match all deals.
+type IndexerAnnounceParams struct {
+	ClientAddress string
+	PieceCid      string
+	IsVerified    *bool
+	// Matches deals created at or after this time
+	CreatedAfter time.Time
+	// Matches deals created before this time
+	CreatedBefore time.Time
+	// The maximum number of announcements per second (unlimited if zero)
+	Rate float64
+}
+
+// IsEmpty returns true if the params match all deals
+func (p IndexerAnnounceParams) IsEmpty() bool {
+	return p.ClientAddress == "" && p.PieceCid == "" && p.IsVerified == nil &&
+		p.CreatedAfter.IsZero() && p.CreatedBefore.IsZero()
+}
+
+// IndexerAnnounceResult counts the deals announced by
+// BoostIndexerAnnounceDeals
+type IndexerAnnounceResult struct {
+	// The number of deals that were announced
+	Announced int
+	// The number of deals that had already been announced
+	AlreadyAnnounced int
+	// The number of deals that the announce policy delayed until sealing
+	// that were skipped because their sector hasn't been sealed yet
+	NotSealed int
+	// The number of deals for which the announcement failed.
+	// Failed announcements are queued to be retried.
+	Failed int
+}
+
+// IndexerDirectAnnounceStatus is the status of the direct HTTP
+// announcements to an indexer
+type IndexerDirectAnnounceStatus struct {
+	URL string
+	// The last advertisement that the indexer accepted an announcement for
+	LastAdvertisement cid.Cid
+	LastSuccess       time.Time
+	LastAttempt       time.Time
+	// The error from the last attempt, if it failed
+	LastError string
+	// The number of consecutive failed attempts
+	Failures int
+	// The time of the next retry, if the last attempt failed
+	NextAttempt time.Time
+}
+
+// IndexerStatus is the health of the index provider, and how far it has
+// got with publishing its advertisement chain
+type IndexerStatus struct {
+	Enabled bool
+	// The latest advertisement in the advertisement chain
+	LatestAdvertisement cid.Cid
+	// The time of the last successful publish of an advertisement (zero if
+	// nothing has been published)
+	LastPublish time.Time
+	// The number of deal announcements that failed and are waiting to be
+	// retried
+	PendingAnnouncements int
+	// The backlog of deals whose entries chunks are being generated, or are
+	// waiting for a slot to generate them (see MaxConcurrentEntriesChains)
+	EntriesBacklog int
+	// The gossipsub topic that advertisements are announced on
+	Topic string
+	// The number of peers in the gossipsub topic
+	GossipsubPeers int
+	// The status of direct HTTP announcements to each configured indexer
+	DirectAnnounce []IndexerDirectAnnounceStatus
+	// The result of the last check that the indexer has ingested announced
+	// deals (nil if there hasn't been a check since boost started)
+	LastVerify *IndexerVerifyResult
+}
+
+// IndexerVerifyResult is the result of checking that the network indexer
+// has ingested the multihashes of announced deals
+type IndexerVerifyResult struct {
+	// The URL of the indexer that was queried
+	Indexer string
+	// The time that the check finished
+	At time.Time
+	// The number of deals that were checked
+	Checked int
+	// The deals for which the indexer is missing some of the sampled
+	// multihashes, or that could not be checked
+	Gaps []IndexerVerifyGap
+}
+
+// IndexerVerifyGap is an announced deal that the indexer has not fully
+// ingested
+type IndexerVerifyGap struct {
+	DealUuid uuid.UUID
+	PieceCid cid.Cid
+	// The number of multihashes sampled from the piece
+	Sampled int
+	// The number of sampled multihashes for which the indexer did not
+	// return this provider
+	Missing int
+	// Set if the deal could not be checked, eg because the piece index
+	// could not be read
+	Error string
+}
+
+// PieceHealth is the result of checking a piece's index against the
+// piece's unsealed data
+type PieceHealth struct {
+	PieceCid cid.Cid
+	// One of "healthy", "missing-index", "corrupt-index", "indexing",
+	// "no-unsealed-copy" or "check-failed"
+	Status string
+	// Details of the problem, if the piece is not healthy
+	Error string
+	// The number of blocks read from the unsealed data
+	BlocksChecked int
+	// The time the piece was checked
+	CheckedAt time.Time
+	// The repair that was started, if any
+	Repair string
+}
+
+// PieceUnsealedStatus describes the unsealed copies of a piece
+type PieceUnsealedStatus struct {
+	PieceCid cid.Cid
+	// Whether the piece has been indexed
+	Indexed bool
+	// Whether any sector containing the piece has an unsealed copy
+	HasUnsealedCopy bool
+	// Whether the piece can be retrieved right now: it has been indexed and
+	// there is an unsealed copy
+	Retrievable bool
+	// Whether an unseal of the piece requested through boost is in progress
+	Unsealing bool
+	Deals     []PieceDealUnsealedStatus
+}
+
+// PieceDealUnsealedStatus describes the unsealed copy of the sector
+// containing a deal for a piece
+type PieceDealUnsealedStatus struct {
+	DealID     abi.DealID
+	SectorID   abi.SectorNumber
+	Offset     abi.PaddedPieceSize
+	Length     abi.PaddedPieceSize
+	IsUnsealed bool
+	// Whether the deal asked for an unsealed copy to be kept
+	KeepUnsealed bool
+	// The error checking if the sector is unsealed, if any
+	Error string
+}
+
+// PieceRemoval lists the records that were removed for a piece (or that
+// would be removed, in a dry run)
+type PieceRemoval struct {
+	PieceCid cid.Cid
+	DryRun   bool
+	// The deals whose announcements to the network indexer were removed
+	RemovedAnnouncements []uuid.UUID
+	// Whether the piece's dagstore shard and index were removed
+	RemovedShard bool
+	// The number of cached multihash lookups for the piece that were removed
+	RemovedCacheEntries int
+	// Whether the piece's copy in the flat store was removed
+	RemovedFlatCopy bool
+	// The payload cids whose block locations in the piece were removed
+	RemovedCidInfos []cid.Cid
+	// Whether the piece's record in the piece store was removed
+	RemovedPieceInfo bool
+}
+
+// RetrievalPaymentTerms are the terms for paid retrievals, from the
+// retrieval ask
+type RetrievalPaymentTerms struct {
+	// The price per byte for retrievals; if it is zero, retrievals are free
+	PricePerByte abi.TokenAmount
+	// The price to unseal a piece; only charged for graphsync retrievals
+	UnsealPrice abi.TokenAmount
+	// The address that payment channels must be created to
+	PaymentAddress address.Address
+}
+
+// RetrievalPaymentReservation is the credit set aside for the bytes sent to
+// a client in an HTTP retrieval
+type RetrievalPaymentReservation struct {
+	// The number of bytes that the reserved amount pays for
+	Bytes uint64
+	// The amount subtracted from the client's credit; the part that isn't
+	// used must be refunded
+	Amount abi.TokenAmount
+	// The client's remaining credit
+	Credit abi.TokenAmount
+}
+
+// RetrievalEarnings is the total of the payments received from a client
+// for retrievals over a protocol
+type RetrievalEarnings struct {
+	// The client's peer ID for graphsync retrievals, or the client's auth
+	// token id (or IP address) for HTTP retrievals
+	ClientID string
+	// The protocol the retrievals were made over: graphsync or http
+	Protocol string
+	// The number of payments received
+	Payments int
+	Total    abi.TokenAmount
+}
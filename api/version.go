@@ -2,6 +2,8 @@ package api
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 type Version uint32
@@ -33,9 +35,50 @@ var (
 	MinerAPIVersion0  = newVer(1, 5, 0)
 	WorkerAPIVersion0 = newVer(1, 7, 0)
 
-	BoostAPIVersion0 = newVer(1, 0, 0)
+	BoostAPIVersion0 = newVer(1, 1, 0)
+
+	// The version of the graphql API served by boostd
+	GraphqlAPIVersion = newVer(1, 1, 0)
 )
 
+// VersionHeader is set on responses to the version of the API that served
+// the request. Clients may set it on requests to the version of the API they
+// were written against: the request is rejected if the server can't serve
+// that version.
+const VersionHeader = "X-Boost-Api-Version"
+
+// ParseVersion parses a version of the form major[.minor[.patch]]
+func ParseVersion(s string) (Version, error) {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "v"), ".")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid version '%s'", s)
+	}
+	var ints [3]uint8
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 8)
+		if err != nil {
+			return 0, fmt.Errorf("invalid version '%s'", s)
+		}
+		ints[i] = uint8(n)
+	}
+	return newVer(ints[0], ints[1], ints[2]), nil
+}
+
+// CheckRequestedVersion returns an error if a client that requests the
+// given version (eg 1.1) can't be served by this version of the API.
+// A client can be served by an API with the same major version, and the same
+// or a later minor version.
+func CheckRequestedVersion(requested string, served Version) error {
+	req, err := ParseVersion(requested)
+	if err != nil {
+		return err
+	}
+	if req&majorMask != served&majorMask || req&minorMask > served&minorMask {
+		return fmt.Errorf("requested API version %s is not supported: this server serves API version %s", requested, served)
+	}
+	return nil
+}
+
 //nolint:varcheck,deadcode
 const (
 	majorMask = 0xff0000
@@ -0,0 +1,41 @@
+package build
+
+import (
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"encoding/json"
+
+	apitypes "github.com/filecoin-project/lotus/api/types"
+)
+
+// The OpenRPC document is generated from the API types with
+// `make docsgen-openrpc`
+//
+//go:embed openrpc
+var openrpcfs embed.FS
+
+func mustReadGzippedOpenRPCDocument(data []byte) apitypes.OpenRPCDocument {
+	zr, err := gzip.NewReader(bytes.NewBuffer(data))
+	if err != nil {
+		panic(err)
+	}
+	m := apitypes.OpenRPCDocument{}
+	err = json.NewDecoder(zr).Decode(&m)
+	if err != nil {
+		panic(err)
+	}
+	err = zr.Close()
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func OpenRPCDiscoverJSON_Boost() apitypes.OpenRPCDocument {
+	data, err := openrpcfs.ReadFile("openrpc/boost.json.gz")
+	if err != nil {
+		panic(err)
+	}
+	return mustReadGzippedOpenRPCDocument(data)
+}
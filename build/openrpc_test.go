@@ -0,0 +1,13 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenRPCDiscoverJSON(t *testing.T) {
+	doc := OpenRPCDiscoverJSON_Boost()
+	require.Contains(t, doc, "openrpc")
+	require.Contains(t, doc, "methods")
+}
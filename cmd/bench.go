@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+	"github.com/filecoin-project/boost/retrievalmarket/retriever"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+)
+
+// BenchResultJson is a retrieval benchmark result, for json output
+type BenchResultJson struct {
+	PayloadCID        string
+	Protocol          string
+	Endpoint          string
+	TimeToFirstByteMs int64
+	DurationMs        int64
+	Size              uint64
+	Throughput        uint64
+	Error             string `json:",omitempty"`
+}
+
+type BenchJson struct {
+	Results   []BenchResultJson
+	Summaries []retriever.BenchSummary
+}
+
+// PrintBenchResults prints the results of a retrieval benchmark and a
+// summary for each protocol
+func PrintBenchResults(results []retriever.BenchResult, asJson bool) error {
+	summaries := retriever.SummarizeBench(results)
+
+	if asJson {
+		out := BenchJson{Results: make([]BenchResultJson, 0, len(results)), Summaries: summaries}
+		for _, res := range results {
+			rj := BenchResultJson{
+				PayloadCID:        res.Root.String(),
+				Protocol:          res.Candidate.Protocol,
+				Endpoint:          benchEndpoint(res.Candidate),
+				TimeToFirstByteMs: res.TimeToFirstByte.Milliseconds(),
+				DurationMs:        res.Duration.Milliseconds(),
+				Size:              res.Size,
+				Throughput:        res.Throughput,
+			}
+			if res.Err != nil {
+				rj.Error = res.Err.Error()
+			}
+			out.Results = append(out.Results, rj)
+		}
+		return PrintJson(out)
+	}
+
+	tw := tablewriter.New(
+		tablewriter.Col("Payload CID"),
+		tablewriter.Col("Protocol"),
+		tablewriter.Col("Endpoint"),
+		tablewriter.Col("TTFB"),
+		tablewriter.Col("Duration"),
+		tablewriter.Col("Size"),
+		tablewriter.Col("Throughput"),
+		tablewriter.NewLineCol("Error"),
+	)
+	for _, res := range results {
+		row := map[string]interface{}{
+			"Payload CID": res.Root.String(),
+			"Protocol":    res.Candidate.Protocol,
+			"Endpoint":    benchEndpoint(res.Candidate),
+			"Duration":    res.Duration.Round(time.Millisecond).String(),
+			"Size":        humanize.IBytes(res.Size),
+		}
+		if res.Err != nil {
+			row["Error"] = color.RedString(res.Err.Error())
+		} else {
+			row["TTFB"] = res.TimeToFirstByte.Round(time.Millisecond).String()
+			row["Throughput"] = humanize.IBytes(res.Throughput) + "/s"
+		}
+		tw.Write(row)
+	}
+	if err := tw.Flush(os.Stdout); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	tw = tablewriter.New(
+		tablewriter.Col("Protocol"),
+		tablewriter.Col("Successes"),
+		tablewriter.Col("Failures"),
+		tablewriter.Col("Avg TTFB"),
+		tablewriter.Col("Avg Throughput"),
+		tablewriter.Col("Total Size"),
+	)
+	for _, s := range summaries {
+		row := map[string]interface{}{
+			"Protocol":   s.Protocol,
+			"Successes":  s.Successes,
+			"Failures":   s.Failures,
+			"Total Size": humanize.IBytes(s.TotalSize),
+		}
+		if s.Failures > 0 {
+			row["Failures"] = color.RedString("%d", s.Failures)
+		}
+		if s.Successes > 0 {
+			row["Avg TTFB"] = s.AverageTimeToFirstByte.Round(time.Millisecond).String()
+			row["Avg Throughput"] = humanize.IBytes(s.AverageThroughput) + "/s"
+		}
+		tw.Write(row)
+	}
+	return tw.Flush(os.Stdout)
+}
+
+func benchEndpoint(c retriever.Candidate) string {
+	if c.Protocol == retriever.ProtocolHTTP {
+		return c.URL
+	}
+	return c.Peer.ID.String()
+}
@@ -17,6 +17,8 @@ import (
 	"github.com/filecoin-project/boost/cmd"
 	"github.com/filecoin-project/boost/markets/utils"
 	rc "github.com/filecoin-project/boost/retrievalmarket/client"
+	"github.com/filecoin-project/boost/retrievalmarket/lp2pimpl"
+	"github.com/filecoin-project/boost/retrievalmarket/retriever"
 	"github.com/filecoin-project/boostd-data/shared/cliutil"
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/lotus/chain/types"
@@ -30,21 +32,29 @@ import (
 	"github.com/ipld/go-car"
 	"github.com/ipld/go-ipld-prime"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
-	"github.com/ipld/go-ipld-prime/node/basicnode"
 	"github.com/ipld/go-ipld-prime/traversal"
-	"github.com/ipld/go-ipld-prime/traversal/selector"
-	"github.com/ipld/go-ipld-prime/traversal/selector/builder"
 	textselector "github.com/ipld/go-ipld-selector-text-lite"
 	"github.com/urfave/cli/v2"
 	"golang.org/x/term"
 	"golang.org/x/xerrors"
 )
 
-var flagProvider = &cli.StringFlag{
-	Name:     "provider",
-	Aliases:  []string{"p"},
-	Usage:    "The miner ID of the Storage Provider",
-	Required: true,
+var flagProvider = &cli.StringSliceFlag{
+	Name:    "provider",
+	Aliases: []string{"p"},
+	Usage:   "The miner ID of a Storage Provider to retrieve from (may be repeated). If not set, providers are found through the network indexer",
+}
+
+var flagIndexer = &cli.StringFlag{
+	Name:  "indexer",
+	Usage: "The network indexer used to find providers of the content",
+	Value: "https://cid.contact",
+}
+
+var flagProtocols = &cli.StringSliceFlag{
+	Name:  "protocols",
+	Usage: "The protocols to retrieve over, in order of preference",
+	Value: cli.NewStringSlice(retriever.DefaultProtocols...),
 }
 
 var flagOutput = &cli.StringFlag{
@@ -63,15 +73,31 @@ var flagDmPathSel = &cli.StringFlag{
 	Usage: "a rudimentary (DM-level-only) text-path selector, allowing for sub-selection within a deal",
 }
 
+var flagBench = &cli.BoolFlag{
+	Name: "bench",
+	Usage: "Benchmark the retrieval instead of saving the data: retrieve the content from each provider over each protocol in turn, " +
+		"and print the time to first byte and throughput of each retrieval",
+}
+
 var retrieveCmd = &cli.Command{
 	Name:      "retrieve",
-	Usage:     "Retrieve a file by payload CID from a miner",
+	Usage:     "Retrieve a file by payload CID from one or more Storage Providers",
 	ArgsUsage: "<cid>",
+	Description: "Retrieves the content from the given Storage Providers, or from the providers returned by the network indexer. " +
+		"The retrieval starts with the preferred protocol and falls back to (or races) the other protocols and providers " +
+		"until the complete DAG has been retrieved, verifying the blocks as they arrive.",
 	Flags: []cli.Flag{
 		flagProvider,
+		flagIndexer,
+		flagProtocols,
 		flagOutput,
 		flagDmPathSel,
 		flagCar,
+		flagBench,
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "Print the benchmark results as json (with --bench)",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		ctx := cliutil.ReqContext(cctx)
@@ -99,9 +125,24 @@ var retrieveCmd = &cli.Command{
 
 		dmSelText := textselector.Expression(cctx.String(flagDmPathSel.Name))
 
-		miner, err := address.NewFromString(cctx.String(flagProvider.Name))
-		if err != nil {
-			return fmt.Errorf("failed to parse miner %s: %w", cctx.String(flagProvider.Name), err)
+		var miners []address.Address
+		for _, p := range cctx.StringSlice(flagProvider.Name) {
+			miner, err := address.NewFromString(p)
+			if err != nil {
+				return fmt.Errorf("failed to parse miner %s: %w", p, err)
+			}
+			miners = append(miners, miner)
+		}
+
+		for _, p := range cctx.StringSlice(flagProtocols.Name) {
+			if p != retriever.ProtocolHTTP && p != retriever.ProtocolBitswap && p != retriever.ProtocolGraphsync {
+				return fmt.Errorf("unknown protocol '%s': must be one of %s", p, strings.Join(retriever.DefaultProtocols, ", "))
+			}
+		}
+
+		bench := cctx.Bool(flagBench.Name)
+		if bench && (dmSelText != "" || cctx.IsSet(flagOutput.Name) || cctx.Bool(flagCar.Name)) {
+			return fmt.Errorf("--bench doesn't save the data, so it can't be used with --%s, --%s or --%s", flagOutput.Name, flagCar.Name, flagDmPathSel.Name)
 		}
 
 		// Get the output path of the file
@@ -114,35 +155,22 @@ var retrieveCmd = &cli.Command{
 		}
 
 		// The output path must not exist already
-		_, err = os.Stat(output)
-		if err == nil {
-			return fmt.Errorf("there is already a file at output path %s", output)
-		}
-		if !os.IsNotExist(err) {
-			return fmt.Errorf("checking output path %s: %w", output, err)
+		if !bench {
+			_, err = os.Stat(output)
+			if err == nil {
+				return fmt.Errorf("there is already a file at output path %s", output)
+			}
+			if !os.IsNotExist(err) {
+				return fmt.Errorf("checking output path %s: %w", output, err)
+			}
 		}
 
-		// Get subselector node
-		var selNode ipld.Node
+		// Check the selector before starting the retrieval. The whole DAG
+		// is retrieved, and the selector is then used to find the sub-root.
 		if dmSelText != "" {
-			ssb := builder.NewSelectorSpecBuilder(basicnode.Prototype.Any)
-
-			selspec, err := textselector.SelectorSpecFromPath(
-				dmSelText,
-				true,
-
-				// URGH - this is a direct copy from https://github.com/filecoin-project/go-fil-markets/blob/v1.12.0/shared/selectors.go#L10-L16
-				// Unable to use it because we need the SelectorSpec, and markets exposes just a reified node
-				ssb.ExploreRecursive(
-					selector.RecursionLimitNone(),
-					ssb.ExploreAll(ssb.ExploreRecursiveEdge()),
-				),
-			)
-			if err != nil {
+			if _, err := textselector.SelectorSpecFromPath(dmSelText, true, nil); err != nil {
 				return xerrors.Errorf("failed to parse text-selector '%s': %w", dmSelText, err)
 			}
-
-			selNode = selspec.Node()
 		}
 
 		api, closer, err := lcli.GetGatewayAPI(cctx)
@@ -165,10 +193,15 @@ var retrieveCmd = &cli.Command{
 		defer os.RemoveAll(bstoreTmpDir)
 
 		bstoreDatastore, err := flatfs.CreateOrOpen(bstoreTmpDir, flatfs.NextToLast(3), false)
-		bstore := blockstore.NewBlockstoreNoPrefix(bstoreDatastore)
+		var bstore blockstore.Blockstore = blockstore.NewBlockstoreNoPrefix(bstoreDatastore)
 		if err != nil {
 			return fmt.Errorf("could not open blockstore: %w", err)
 		}
+		if bench {
+			// Blocks aren't kept between benchmark retrievals, so that each
+			// retrieval fetches all of the data
+			bstore = &retriever.DiscardBlockstore{}
+		}
 
 		// Set up a datastore in a temp directory
 		datastoreTmpDir, err := os.MkdirTemp("", "retrieve-datastore")
@@ -189,30 +222,58 @@ var retrieveCmd = &cli.Command{
 			return err
 		}
 
-		query, err := fc.RetrievalQuery(ctx, miner, c)
-		if err != nil {
-			return fmt.Errorf("retrieval query for miner %s failed: %v", miner, err)
-		}
-
-		proposal, err := rc.RetrievalProposalForAsk(query, c, selNode)
-		if err != nil {
-			return fmt.Errorf("Failed to create retrieval proposal with candidate miner %s: %v", miner, err)
+		// Find the candidates to retrieve from
+		var candidates []retriever.Candidate
+		if len(miners) > 0 {
+			tc := lp2pimpl.NewTransportsClient(node.Host)
+			for _, miner := range miners {
+				minerPeer, err := fc.MinerPeer(ctx, miner)
+				if err != nil {
+					return fmt.Errorf("getting peer for miner %s: %w", miner, err)
+				}
+				minerCandidates, err := retriever.ProviderCandidates(ctx, tc, minerPeer)
+				if err != nil {
+					return fmt.Errorf("getting retrieval transports of miner %s: %w", miner, err)
+				}
+				candidates = append(candidates, minerCandidates...)
+			}
+		} else {
+			finder, err := retriever.NewIndexerFinder(cctx.String(flagIndexer.Name))
+			if err != nil {
+				return err
+			}
+			candidates, err = finder.FindCandidates(ctx, c)
+			if err != nil {
+				return err
+			}
+			if len(candidates) == 0 {
+				return fmt.Errorf("the indexer at %s has no providers for %s", cctx.String(flagIndexer.Name), c)
+			}
 		}
 
 		// Retrieve the data
-		stats, err := fc.RetrieveContentWithProgressCallback(
-			ctx,
-			miner,
-			proposal,
-			func(bytesReceived_ uint64) {
-				printProgress(bytesReceived_)
-			},
-		)
+		r := retriever.New(retriever.Config{
+			Host:       node.Host,
+			Blockstore: bstore,
+			Graphsync:  fc,
+			Protocols:  cctx.StringSlice(flagProtocols.Name),
+		})
+
+		if bench {
+			results := r.Bench(ctx, c, candidates)
+			if len(results) == 0 {
+				return fmt.Errorf("no candidates to retrieve %s from over %v", c, cctx.StringSlice(flagProtocols.Name))
+			}
+			return cmd.PrintBenchResults(results, cctx.Bool("json"))
+		}
+		res, err := r.Retrieve(ctx, c, candidates, func(candidate retriever.Candidate, bytesReceived uint64) {
+			printProgress(candidate.String(), bytesReceived)
+		})
 		if err != nil {
-			return fmt.Errorf("Failed to retrieve content with candidate miner %s: %v", miner, err)
+			return err
 		}
 
-		printRetrievalStats(&FILRetrievalStats{RetrievalStats: *stats})
+		printRetrievalStats(&MultiRetrievalStats{Result: *res})
 
 		dservOffline := merkledag.NewDAGService(blockservice.New(bstore, offline.Exchange(bstore)))
 
@@ -291,6 +352,24 @@ type RetrievalStats interface {
 	GetAverageBytesPerSecond() uint64
 }
 
+// MultiRetrievalStats are the stats of a retrieval that may have been over
+// any protocol
+type MultiRetrievalStats struct {
+	retriever.Result
+}
+
+func (stats *MultiRetrievalStats) GetByteSize() uint64 {
+	return stats.Size
+}
+
+func (stats *MultiRetrievalStats) GetDuration() time.Duration {
+	return stats.Duration
+}
+
+func (stats *MultiRetrievalStats) GetAverageBytesPerSecond() uint64 {
+	return stats.AverageSpeed
+}
+
 type FILRetrievalStats struct {
 	rc.RetrievalStats
 }
@@ -307,8 +386,8 @@ func (stats *FILRetrievalStats) GetAverageBytesPerSecond() uint64 {
 	return stats.AverageSpeed
 }
 
-func printProgress(bytesReceived uint64) {
-	str := fmt.Sprintf("%v (%v)", bytesReceived, humanize.IBytes(bytesReceived))
+func printProgress(prefix string, bytesReceived uint64) {
+	str := fmt.Sprintf("%s: %v (%v)", prefix, bytesReceived, humanize.IBytes(bytesReceived))
 
 	termWidth, _, err := term.GetSize(int(os.Stdin.Fd()))
 	strLen := len(str)
@@ -330,6 +409,25 @@ func printProgress(bytesReceived uint64) {
 
 func printRetrievalStats(stats RetrievalStats) {
 	switch stats := stats.(type) {
+	case *MultiRetrievalStats:
+		fmt.Printf(`RETRIEVAL STATS
+-----
+Size:          %v (%v)
+Duration:      %v
+Average Speed: %v (%v/s)
+Protocol:      %v
+Peer:          %v
+URL:           %v
+Attempts:      %v
+`,
+			stats.Size, humanize.IBytes(stats.Size),
+			stats.Duration,
+			stats.AverageSpeed, humanize.IBytes(stats.AverageSpeed),
+			stats.Candidate.Protocol,
+			stats.Candidate.Peer.ID,
+			stats.Candidate.URL,
+			stats.Attempts,
+		)
 	case *FILRetrievalStats:
 		fmt.Printf(`RETRIEVAL STATS (FIL)
 -----
@@ -17,7 +17,7 @@ import (
 
 var authCmd = &cli.Command{
 	Name:  "auth",
-	Usage: "Manage RPC permissions",
+	Usage: "Manage RPC and graphql permissions",
 	Subcommands: []*cli.Command{
 		AuthCreateAdminToken,
 		AuthApiInfoToken,
@@ -27,6 +27,10 @@ var authCmd = &cli.Command{
 var AuthCreateAdminToken = &cli.Command{
 	Name:  "create-token",
 	Usage: "Create token",
+	Description: `Creates an API token for the JSON-RPC API and the graphql server (when
+Graphql.RequireAuth is enabled in config).
+A read token can be used by monitoring systems, while mutating deals requires
+a write token, and moving funds or updating the storage ask requires an admin token.`,
 	Flags: []cli.Flag{
 		&cli.StringFlag{
 			Name:  "perm",
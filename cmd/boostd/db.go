@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/node/config"
+	"github.com/filecoin-project/boost/node/modules"
+	"github.com/filecoin-project/boost/node/repo"
+	brm "github.com/filecoin-project/boost/retrievalmarket/lib"
+	"github.com/filecoin-project/boost/retrievalmarket/server"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/filecoin-project/dagstore/index"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	piecestoreimpl "github.com/filecoin-project/go-fil-markets/piecestore/impl"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	lotus_modules "github.com/filecoin-project/lotus/node/modules"
+	lotus_repo "github.com/filecoin-project/lotus/node/repo"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	levelds "github.com/ipfs/go-ds-leveldb"
+	"github.com/multiformats/go-multihash"
+	"github.com/urfave/cli/v2"
+)
+
+var dbCmd = &cli.Command{
+	Name:  "db",
+	Usage: "Manage the boost databases",
+	Subcommands: []*cli.Command{
+		dbCheckCmd,
+	},
+}
+
+var dbCheckCmd = &cli.Command{
+	Name:  "check",
+	Usage: "Check the integrity of the boost databases",
+	Description: `Validates that deal logs, funds and storage records refer to deals that exist,
+that each deal row can be decoded, and that each indexed deal is in the piece directory
+(the piece store and the dagstore index).
+Boost must be stopped before running this command.
+
+With --repair:
+- orphaned rows are deleted
+- corrupt deal rows are moved to the ` + db.QuarantinedDealsTable + ` table
+- deals that are missing from their piece in the piece directory are re-added
+
+Pieces that are missing from the dagstore index can't be re-indexed while boost is
+stopped: start boost and re-index them with boostd dagstore register-shard or
+recover-shard.`,
+	Before: before,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "repair",
+			Usage: "repair or quarantine the bad records that are found",
+		},
+		&cli.StringFlag{
+			Name:  "report",
+			Usage: "write a JSON report of the problems found to the given file",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		ctx := cctx.Context
+
+		r, err := lotus_repo.NewFS(cctx.String(FlagBoostRepo))
+		if err != nil {
+			return err
+		}
+		ok, err := r.Exists()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("repo at '%s' is not initialized", cctx.String(FlagBoostRepo))
+		}
+
+		lr, err := r.Lock(repo.Boost)
+		if err != nil {
+			return fmt.Errorf("locking repo: %w. Please stop the boostd process to check the databases", err)
+		}
+		defer lr.Close()
+
+		dealsDB, sqldb, logsDB, err := openDealsDBs(lr)
+		if err != nil {
+			return err
+		}
+		defer sqldb.Close()
+		defer logsDB.Close()
+
+		problems, err := db.CheckIntegrity(ctx, dealsDB, logsDB)
+		if err != nil {
+			return fmt.Errorf("checking db integrity: %w", err)
+		}
+
+		pd, closePD, err := openPieceDirectory(ctx, lr)
+		if err != nil {
+			return err
+		}
+		defer closePD()
+
+		// Listing deals fails if any of the deal rows are corrupt, so only
+		// check the piece directory once the deals table is healthy
+		var missing []missingPieceDirectoryDeal
+		if hasCorruptRows(problems) {
+			fmt.Println("Skipping piece directory check because there are corrupt deal rows (re-run after repair)")
+		} else {
+			missing, err = missingPieceDirectoryDeals(ctx, dealsDB, pd)
+			if err != nil {
+				return err
+			}
+		}
+		for _, m := range missing {
+			p := db.IntegrityProblem{
+				Table:    "PieceDirectory",
+				DealUUID: m.dealUuid,
+				Problem:  db.ProblemMissingPiece,
+				Detail:   fmt.Sprintf("piece %s is missing deal %d", m.pieceCid, m.dealInfo.DealID),
+			}
+			if m.unindexed {
+				p.Problem = db.ProblemUnindexedPiece
+				p.Detail = fmt.Sprintf("payload %s is not indexed in piece %s", m.payloadCid, m.pieceCid)
+			}
+			problems = append(problems, p)
+		}
+
+		repaired := make([]bool, len(problems))
+		if cctx.Bool("repair") {
+			for i, p := range problems {
+				switch p.Problem {
+				case db.ProblemOrphanedRow:
+					rowsDB := sqldb
+					if p.Table == "DealLogs" {
+						rowsDB = logsDB
+					}
+					err = db.DeleteOrphanedRows(ctx, rowsDB, p.Table, p.DealUUID)
+				case db.ProblemCorruptRow:
+					err = dealsDB.QuarantineDeal(ctx, p.DealUUID)
+				default:
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("repairing %s in %s for deal %s: %w", p.Problem, p.Table, p.DealUUID, err)
+				}
+				repaired[i] = true
+			}
+
+			for _, m := range missing {
+				if m.unindexed {
+					continue
+				}
+				if err := pd.AddDealForPiece(ctx, m.pieceCid, m.dealInfo); err != nil {
+					return fmt.Errorf("adding deal %s to piece directory: %w", m.dealUuid, err)
+				}
+			}
+			for i, p := range problems {
+				if p.Problem == db.ProblemMissingPiece {
+					repaired[i] = true
+				}
+			}
+		}
+
+		if len(problems) == 0 {
+			fmt.Println("No problems found")
+		} else {
+			tw := tablewriter.New(
+				tablewriter.Col("Table"),
+				tablewriter.Col("Deal"),
+				tablewriter.Col("Problem"),
+				tablewriter.Col("Repaired"),
+				tablewriter.Col("Detail"),
+			)
+			for i, p := range problems {
+				tw.Write(map[string]interface{}{
+					"Table":    p.Table,
+					"Deal":     p.DealUUID,
+					"Problem":  p.Problem,
+					"Repaired": repaired[i],
+					"Detail":   p.Detail,
+				})
+			}
+			if err := tw.Flush(os.Stdout); err != nil {
+				return err
+			}
+		}
+
+		if reportPath := cctx.String("report"); reportPath != "" {
+			type reportEntry struct {
+				db.IntegrityProblem
+				Repaired bool
+			}
+			report := make([]reportEntry, 0, len(problems))
+			for i, p := range problems {
+				report = append(report, reportEntry{IntegrityProblem: p, Repaired: repaired[i]})
+			}
+			bz, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(reportPath, bz, 0644); err != nil {
+				return fmt.Errorf("writing report to %s: %w", reportPath, err)
+			}
+			fmt.Printf("Wrote report to %s\n", reportPath)
+		}
+
+		return nil
+	},
+}
+
+func hasCorruptRows(problems []db.IntegrityProblem) bool {
+	for _, p := range problems {
+		if p.Problem == db.ProblemCorruptRow {
+			return true
+		}
+	}
+	return false
+}
+
+// openDealsDBs opens the deals and logs sqlite databases in the boost repo
+func openDealsDBs(lr lotus_repo.LockedRepo) (*db.DealsDB, *sql.DB, *sql.DB, error) {
+	sqldb, err := db.SqlDB(path.Join(lr.Path(), db.DealsDBName))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("opening deals db: %w", err)
+	}
+	logsDB, err := db.SqlDB(path.Join(lr.Path(), db.LogsDBName))
+	if err != nil {
+		sqldb.Close()
+		return nil, nil, nil, fmt.Errorf("opening logs db: %w", err)
+	}
+
+	rawCfg, err := lr.Config()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("getting boost config: %w", err)
+	}
+	cfg, ok := rawCfg.(*config.Boost)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("expected boost config, got %T", rawCfg)
+	}
+	if !cfg.Encryption.Enabled {
+		return db.NewDealsDB(sqldb), sqldb, logsDB, nil
+	}
+
+	ks, err := lr.KeyStore()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	key, err := modules.DealsDBEncryptionKey(cfg.Encryption.KeyCommand, ks)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("getting deals db encryption key: %w", err)
+	}
+	c, err := db.NewAESCipher(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return db.NewEncryptedDealsDB(sqldb, c), sqldb, logsDB, nil
+}
+
+// openPieceDirectory opens the piece directory in the boost repo: the piece
+// store, and the dagstore's top-level index of the blocks in each piece
+func openPieceDirectory(ctx context.Context, lr lotus_repo.LockedRepo) (*brm.DagstorePieceDirectory, func(), error) {
+	ps, err := openPieceStore(ctx, lr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dir := filepath.Join(lr.Path(), lotus_modules.DefaultDAGStoreDir, "datastore")
+	dstore, err := levelds.NewDatastore(dir, &levelds.Options{ReadOnly: true})
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening dagstore index at %s: %w", dir, err)
+	}
+
+	idx := &topLevelIndex{idx: index.NewInverted(dstore)}
+	closeIdx := func() { _ = dstore.Close() }
+	return brm.NewDagstorePieceDirectory(idx, ps), closeIdx, nil
+}
+
+// topLevelIndex looks up the shards containing a block in the dagstore's
+// top-level index, without starting the dagstore
+type topLevelIndex struct {
+	idx index.Inverted
+}
+
+func (t *topLevelIndex) ShardsContainingMultihash(ctx context.Context, h multihash.Multihash) ([]shard.Key, error) {
+	return t.idx.GetShardsForMultihash(ctx, h)
+}
+
+func openPieceStore(ctx context.Context, lr lotus_repo.LockedRepo) (piecestore.PieceStore, error) {
+	mds, err := lr.Datastore(ctx, metadataNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("getting metadata datastore: %w", err)
+	}
+
+	ps, err := piecestoreimpl.NewPieceStore(namespace.Wrap(mds, datastore.NewKey("/storagemarket")))
+	if err != nil {
+		return nil, fmt.Errorf("opening piece store: %w", err)
+	}
+
+	ready := make(chan error, 1)
+	ps.OnReady(func(err error) { ready <- err })
+	if err := ps.Start(ctx); err != nil {
+		return nil, fmt.Errorf("starting piece store: %w", err)
+	}
+	select {
+	case err := <-ready:
+		if err != nil {
+			return nil, fmt.Errorf("migrating piece store: %w", err)
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return ps, nil
+}
+
+type missingPieceDirectoryDeal struct {
+	dealUuid   string
+	pieceCid   cid.Cid
+	payloadCid cid.Cid
+	dealInfo   piecestore.DealInfo
+	// The deal is registered against the piece, but the deal's payload is
+	// not in the index of the piece
+	unindexed bool
+}
+
+// missingPieceDirectoryDeals finds deals that have been indexed, but that
+// are not registered against their piece in the piece directory, or whose
+// payload is not in the index of the piece
+func missingPieceDirectoryDeals(ctx context.Context, dealsDB *db.DealsDB, pd server.PieceDirectory) ([]missingPieceDirectoryDeal, error) {
+	deals, err := dealsDB.ListCompleted(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing completed deals: %w", err)
+	}
+	active, err := dealsDB.ListActive(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing active deals: %w", err)
+	}
+	deals = append(deals, active...)
+
+	var missing []missingPieceDirectoryDeal
+	for _, deal := range deals {
+		if deal.Checkpoint < dealcheckpoints.IndexedAndAnnounced || deal.Err != "" {
+			continue
+		}
+
+		pieceCid := deal.ClientDealProposal.Proposal.PieceCID
+		m := missingPieceDirectoryDeal{
+			dealUuid:   deal.DealUuid.String(),
+			pieceCid:   pieceCid,
+			payloadCid: deal.DealDataRoot,
+			dealInfo: piecestore.DealInfo{
+				DealID:   deal.ChainDealID,
+				SectorID: deal.SectorID,
+				Offset:   deal.Offset,
+				Length:   deal.Length,
+			},
+		}
+
+		found := false
+		pi, err := pd.GetPieceInfo(ctx, pieceCid)
+		if err == nil {
+			for _, di := range pi.Deals {
+				if di.DealID == deal.ChainDealID {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			missing = append(missing, m)
+			continue
+		}
+
+		// An identity payload cid isn't stored in the index
+		if deal.DealDataRoot.Prefix().MhType == multihash.IDENTITY {
+			continue
+		}
+		pieces, err := pd.PiecesContainingMultihash(ctx, deal.DealDataRoot.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("getting pieces containing payload %s: %w", deal.DealDataRoot, err)
+		}
+		if !containsCid(pieces, pieceCid) {
+			m.unindexed = true
+			missing = append(missing, m)
+		}
+	}
+
+	return missing, nil
+}
+
+func containsCid(cids []cid.Cid, c cid.Cid) bool {
+	for _, have := range cids {
+		if have == c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+	bcli "github.com/filecoin-project/boost/cli"
+	"github.com/filecoin-project/boost/dealbook"
+	"github.com/urfave/cli/v2"
+)
+
+var dealsCmd = &cli.Command{
+	Name:  "deals",
+	Usage: "Manage boost deals",
+	Subcommands: []*cli.Command{
+		dealsListCmd,
+	},
+}
+
+var dealsListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List boost deals and legacy deals (newest first)",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "source",
+			Usage: "only list deals from this source: boost, legacy or all",
+			Value: "all",
+		},
+		&cli.StringFlag{
+			Name:  "query",
+			Usage: "search for deals with an ID, piece CID, client address etc that matches the query",
+		},
+		&cli.StringFlag{
+			Name:  "client",
+			Usage: "only list deals from this client address",
+		},
+		&cli.StringFlag{
+			Name:  "piece-cid",
+			Usage: "only list deals with this piece CID",
+		},
+		&cli.StringFlag{
+			Name:  "checkpoint",
+			Usage: "only list boost deals at this checkpoint, eg Accepted, Transferred, Published, PublishConfirmed, AddedPiece, IndexedAndAnnounced, Complete",
+		},
+		&cli.StringFlag{
+			Name:  "error",
+			Usage: "only list deals whose error message contains this string",
+		},
+		&cli.BoolFlag{
+			Name:  "verified",
+			Usage: "only list verified deals (or unverified deals with --verified=false)",
+		},
+		&cli.TimestampFlag{
+			Name:   "created-after",
+			Usage:  "only list deals created at or after this time, eg 2023-01-02T15:04:05",
+			Layout: "2006-01-02T15:04:05",
+		},
+		&cli.TimestampFlag{
+			Name:   "created-before",
+			Usage:  "only list deals created before this time, eg 2023-01-02T15:04:05",
+			Layout: "2006-01-02T15:04:05",
+		},
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "the maximum number of deals to list",
+			Value: 100,
+		},
+		&cli.IntFlag{
+			Name:  "offset",
+			Usage: "the number of deals to skip",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		source := cctx.String("source")
+		if source == "all" {
+			source = ""
+		}
+		filter := dealbook.Filter{
+			Source:        source,
+			Query:         cctx.String("query"),
+			Checkpoint:    cctx.String("checkpoint"),
+			ClientAddress: cctx.String("client"),
+			PieceCid:      cctx.String("piece-cid"),
+			ErrContains:   cctx.String("error"),
+		}
+		if cctx.IsSet("verified") {
+			verified := cctx.Bool("verified")
+			filter.IsVerified = &verified
+		}
+		if t := cctx.Timestamp("created-after"); t != nil {
+			filter.CreatedAfter = *t
+		}
+		if t := cctx.Timestamp("created-before"); t != nil {
+			filter.CreatedBefore = *t
+		}
+
+		offset := cctx.Int("offset")
+		page, err := napi.BoostDealsAll(bcli.ReqContext(cctx), filter, offset, cctx.Int("limit"))
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "Created\tSource\tID\tClient\tPieceCid\tSize\tVerified\tStatus\tError\n")
+		for _, deal := range page.Deals {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%t\t%s\t%s\n",
+				deal.CreatedAt.Format(time.RFC3339),
+				deal.Source,
+				deal.ID,
+				deal.ClientAddress,
+				deal.PieceCid,
+				units.BytesSize(float64(deal.PieceSize)),
+				deal.IsVerified,
+				deal.Status,
+				deal.Message,
+			)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		if page.More {
+			fmt.Printf("\nShowing %d of %d deals: use --offset %d to list more\n", len(page.Deals), page.TotalCount, offset+len(page.Deals))
+		}
+		return nil
+	},
+}
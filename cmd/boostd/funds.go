@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	bcli "github.com/filecoin-project/boost/cli"
+	"github.com/filecoin-project/boost/cmd"
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/google/uuid"
+	"github.com/urfave/cli/v2"
+)
+
+var fundsCmd = &cli.Command{
+	Name:  "funds",
+	Usage: "Manage deal collateral and publish message funds",
+	Subcommands: []*cli.Command{
+		fundsForecastCmd,
+		fundsHistoryCmd,
+	},
+}
+
+var fundsForecastCmd = &cli.Command{
+	Name:  "forecast",
+	Usage: "Project the funds needed to publish the deals that have been accepted but not yet published",
+	Description: "Adds up the provider collateral and the publish message funds for the deals that are waiting " +
+		"for data or waiting to be published, and warns if they exceed the available escrow balance or the " +
+		"publish message wallet balance.",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		f, err := napi.BoostFundsForecast(ctx)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(f)
+		}
+
+		fmt.Printf("Pending deals: %d (%d awaiting data, %d awaiting publish)\n", f.PendingDeals, f.AwaitingData, f.AwaitingPublish)
+		fmt.Println()
+		fmt.Println("Escrow")
+		fmt.Printf("  Collateral needed: %s\n", types.FIL(f.Collateral))
+		fmt.Printf("  Available:         %s\n", types.FIL(f.EscrowAvailable))
+		if !f.CollateralShortfall.IsZero() {
+			fmt.Printf("  Shortfall:         %s\n", color.RedString(types.FIL(f.CollateralShortfall).String()))
+		}
+		fmt.Println()
+		fmt.Println("Publish message wallet")
+		fmt.Printf("  Fees reserved:     %s\n", types.FIL(f.PublishFees))
+		fmt.Printf("  Balance:           %s\n", types.FIL(f.PubMsgBalance))
+		if !f.PublishFeesShortfall.IsZero() {
+			fmt.Printf("  Shortfall:         %s\n", color.RedString(types.FIL(f.PublishFeesShortfall).String()))
+		}
+
+		if len(f.Warnings) > 0 {
+			fmt.Println()
+			for _, w := range f.Warnings {
+				fmt.Println(color.YellowString("Warning: " + w))
+			}
+		}
+		return nil
+	},
+}
+
+var fundsHistoryCmd = &cli.Command{
+	Name:  "history",
+	Usage: "Export the history of funds movements, for accounting and tax reporting",
+	Description: "Lists every escrow add, miner balance add, collateral lock, publish fee and release of funds " +
+		"tagged for a failed deal, with the associated deal UUID (if any).",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "the output format: table, csv or json",
+			Value: "table",
+		},
+		&cli.TimestampFlag{
+			Name:   "since",
+			Usage:  "only list funds movements at or after this time, eg 2023-01-02T15:04:05",
+			Layout: "2006-01-02T15:04:05",
+		},
+		&cli.TimestampFlag{
+			Name:   "until",
+			Usage:  "only list funds movements before this time, eg 2023-01-02T15:04:05",
+			Layout: "2006-01-02T15:04:05",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		format := cctx.String("format")
+		if cctx.Bool("json") {
+			format = "json"
+		}
+		switch format {
+		case "table", "csv", "json":
+		default:
+			return fmt.Errorf("unknown format '%s': must be table, csv or json", format)
+		}
+
+		napi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		var since, until time.Time
+		if t := cctx.Timestamp("since"); t != nil {
+			since = *t
+		}
+		if t := cctx.Timestamp("until"); t != nil {
+			until = *t
+		}
+
+		movements, err := napi.BoostFundsHistory(ctx, since, until)
+		if err != nil {
+			return err
+		}
+
+		switch format {
+		case "json":
+			return cmd.PrintJson(movements)
+		case "csv":
+			return writeFundsHistoryCSV(movements)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "Time\tDeal\tType\tAmount\tWallet\tMessage\n")
+		for _, m := range movements {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				m.CreatedAt.Format(time.RFC3339),
+				movementDealUUID(m),
+				m.Type,
+				types.FIL(m.Amount),
+				m.Wallet,
+				m.MessageCID,
+			)
+		}
+		return w.Flush()
+	},
+}
+
+func writeFundsHistoryCSV(movements []db.FundsMovement) error {
+	w := csv.NewWriter(os.Stdout)
+	err := w.Write([]string{"time", "deal_uuid", "type", "amount_fil", "amount_attofil", "wallet", "message_cid", "description"})
+	if err != nil {
+		return err
+	}
+	for _, m := range movements {
+		err := w.Write([]string{
+			m.CreatedAt.UTC().Format(time.RFC3339),
+			movementDealUUID(m),
+			m.Type,
+			types.FIL(m.Amount).Unitless(),
+			m.Amount.String(),
+			m.Wallet,
+			m.MessageCID,
+			m.Text,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// movementDealUUID returns the deal UUID, or an empty string for movements
+// that aren't associated with a deal
+func movementDealUUID(m db.FundsMovement) string {
+	if m.DealUUID == uuid.Nil {
+		return ""
+	}
+	return m.DealUUID.String()
+}
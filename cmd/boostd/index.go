@@ -1,6 +1,12 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/filecoin-project/boost/api"
 	bcli "github.com/filecoin-project/boost/cli"
 	lcli "github.com/filecoin-project/lotus/cli"
 	"github.com/urfave/cli/v2"
@@ -11,13 +17,47 @@ var indexProvCmd = &cli.Command{
 	Usage: "Manage the index provider on Boost",
 	Subcommands: []*cli.Command{
 		indexProvAnnounceAllCmd,
+		indexProvPendingCmd,
+		indexProvDirectAnnounceStatusCmd,
+		indexProvStatusCmd,
+		indexProvVerifyCmd,
 	},
 }
 
 var indexProvAnnounceAllCmd = &cli.Command{
 	Name:  "announce-all",
 	Usage: "Announce all active deals to indexers so they can download the indices",
-	Flags: []cli.Flag{},
+	Description: "Republishes advertisements for active deals, eg to recover after an indexer migration or after the " +
+		"advertisement chain has been reset. If any filter flags are set, only matching boost deals are announced " +
+		"(legacy deals are only announced when no filters are set).",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "client",
+			Usage: "only announce deals from this client address",
+		},
+		&cli.StringFlag{
+			Name:  "piece-cid",
+			Usage: "only announce deals with this piece CID",
+		},
+		&cli.BoolFlag{
+			Name:  "verified",
+			Usage: "only announce verified deals (or unverified deals with --verified=false)",
+		},
+		&cli.TimestampFlag{
+			Name:   "created-after",
+			Usage:  "only announce deals created at or after this time, eg 2023-01-02T15:04:05",
+			Layout: "2006-01-02T15:04:05",
+		},
+		&cli.TimestampFlag{
+			Name:   "created-before",
+			Usage:  "only announce deals created before this time, eg 2023-01-02T15:04:05",
+			Layout: "2006-01-02T15:04:05",
+		},
+		&cli.Float64Flag{
+			Name:  "rate",
+			Usage: "the maximum number of announcements per second (unlimited if zero)",
+		},
+	},
 	Action: func(cctx *cli.Context) error {
 		ctx := lcli.ReqContext(cctx)
 
@@ -28,6 +68,234 @@ var indexProvAnnounceAllCmd = &cli.Command{
 		}
 		defer closer()
 
-		return napi.BoostIndexerAnnounceAllDeals(ctx)
+		params := api.IndexerAnnounceParams{
+			ClientAddress: cctx.String("client"),
+			PieceCid:      cctx.String("piece-cid"),
+			Rate:          cctx.Float64("rate"),
+		}
+		if cctx.IsSet("verified") {
+			verified := cctx.Bool("verified")
+			params.IsVerified = &verified
+		}
+		if t := cctx.Timestamp("created-after"); t != nil {
+			params.CreatedAfter = *t
+		}
+		if t := cctx.Timestamp("created-before"); t != nil {
+			params.CreatedBefore = *t
+		}
+
+		res, err := napi.BoostIndexerAnnounceDeals(ctx, params)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Announced %d deals (%d already announced, %d not sealed yet, %d failed)\n", res.Announced, res.AlreadyAnnounced, res.NotSealed, res.Failed)
+		if res.Failed > 0 {
+			fmt.Println("Failed announcements will be retried: run 'boostd index pending' to see the pending announcements")
+		}
+		return nil
+	},
+}
+
+var indexProvPendingCmd = &cli.Command{
+	Name:  "pending",
+	Usage: "List deal announcements that failed and are waiting to be retried",
+	Flags: []cli.Flag{},
+	Action: func(cctx *cli.Context) error {
+		ctx := lcli.ReqContext(cctx)
+
+		napi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		pending, err := napi.BoostIndexerPendingAnnouncements(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(pending) == 0 {
+			fmt.Println("No pending announcements")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "DealID\tQueued\tAttempts\tNext Attempt\tLast Error\n")
+		for _, ann := range pending {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n",
+				ann.DealUuid,
+				ann.CreatedAt.Format(time.RFC3339),
+				ann.Attempts,
+				ann.NextAttempt.Format(time.RFC3339),
+				ann.LastError,
+			)
+		}
+
+		return w.Flush()
+	},
+}
+
+var indexProvDirectAnnounceStatusCmd = &cli.Command{
+	Name:  "direct-announce-status",
+	Usage: "Show the status of announcements sent directly to indexers over HTTP",
+	Flags: []cli.Flag{},
+	Action: func(cctx *cli.Context) error {
+		ctx := lcli.ReqContext(cctx)
+
+		napi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		status, err := napi.BoostIndexerDirectAnnounceStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(status) == 0 {
+			fmt.Println("No direct announce URLs configured (see Dealmaking.DirectAnnounceURLs)")
+			return nil
+		}
+
+		formatTime := func(t time.Time) string {
+			if t.IsZero() {
+				return "-"
+			}
+			return t.Format(time.RFC3339)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "URL\tLast Success\tLast Advertisement\tLast Attempt\tFailures\tNext Attempt\tLast Error\n")
+		for _, st := range status {
+			lastAd := "-"
+			if st.LastAdvertisement.Defined() {
+				lastAd = st.LastAdvertisement.String()
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+				st.URL,
+				formatTime(st.LastSuccess),
+				lastAd,
+				formatTime(st.LastAttempt),
+				st.Failures,
+				formatTime(st.NextAttempt),
+				st.LastError,
+			)
+		}
+
+		return w.Flush()
+	},
+}
+
+var indexProvStatusCmd = &cli.Command{
+	Name:  "status",
+	Usage: "Show the health of the index provider and the state of the advertisement chain",
+	Flags: []cli.Flag{},
+	Action: func(cctx *cli.Context) error {
+		ctx := lcli.ReqContext(cctx)
+
+		napi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		st, err := napi.BoostIndexerStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		if !st.Enabled {
+			fmt.Println("Index provider is disabled")
+			return nil
+		}
+
+		latest := "none"
+		if st.LatestAdvertisement.Defined() {
+			latest = st.LatestAdvertisement.String()
+		}
+		lastPublish := "none"
+		if !st.LastPublish.IsZero() {
+			lastPublish = fmt.Sprintf("%s (%s ago)", st.LastPublish.Format(time.RFC3339),
+				time.Since(st.LastPublish).Truncate(time.Second))
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "Latest advertisement:\t%s\n", latest)
+		_, _ = fmt.Fprintf(w, "Last publish:\t%s\n", lastPublish)
+		_, _ = fmt.Fprintf(w, "Pending announcements:\t%d\n", st.PendingAnnouncements)
+		_, _ = fmt.Fprintf(w, "Entries chunk backlog:\t%d\n", st.EntriesBacklog)
+		_, _ = fmt.Fprintf(w, "Gossipsub topic:\t%s\n", st.Topic)
+		_, _ = fmt.Fprintf(w, "Gossipsub peers:\t%d\n", st.GossipsubPeers)
+		for _, da := range st.DirectAnnounce {
+			status := "ok"
+			if da.LastError != "" {
+				status = fmt.Sprintf("error (%d failures, retrying at %s): %s",
+					da.Failures, da.NextAttempt.Format(time.RFC3339), da.LastError)
+			} else if da.LastAttempt.IsZero() {
+				status = "not announced yet"
+			}
+			_, _ = fmt.Fprintf(w, "Direct announce %s:\t%s\n", da.URL, status)
+		}
+		if lv := st.LastVerify; lv != nil {
+			_, _ = fmt.Fprintf(w, "Last ingestion check:\t%s: %d deals checked, %d with gaps (%s)\n",
+				lv.At.Format(time.RFC3339), lv.Checked, len(lv.Gaps), lv.Indexer)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		if st.GossipsubPeers == 0 && len(st.DirectAnnounce) == 0 {
+			fmt.Println("\nWarning: there are no peers in the gossipsub topic, so indexers may not receive announcements")
+		}
+		return nil
+	},
+}
+
+var indexProvVerifyCmd = &cli.Command{
+	Name:  "verify",
+	Usage: "Check that the indexer has ingested announced deals",
+	Description: "Samples multihashes from the pieces of announced deals and queries the indexer configured in " +
+		"Dealmaking.IndexerVerifyURL for them. Lists the deals for which the indexer does not return this provider.",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "max-deals",
+			Usage: "the maximum number of deals to check, chosen at random (defaults to Dealmaking.IndexerVerifyMaxDeals)",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		ctx := lcli.ReqContext(cctx)
+
+		napi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		res, err := napi.BoostIndexerVerify(ctx, cctx.Int("max-deals"))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Checked %d deals against %s\n", res.Checked, res.Indexer)
+		if len(res.Gaps) == 0 {
+			fmt.Println("The indexer has ingested all checked deals")
+			return nil
+		}
+
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "DealID\tPiece CID\tMissing\tError\n")
+		for _, gap := range res.Gaps {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%d/%d\t%s\n",
+				gap.DealUuid,
+				gap.PieceCid,
+				gap.Missing,
+				gap.Sampled,
+				gap.Error,
+			)
+		}
+		return w.Flush()
 	},
 }
@@ -171,20 +171,65 @@ var migrateMarketsCmd = &cli.Command{
 var migrateMonolithCmd = &cli.Command{
 	Name:  "migrate-monolith",
 	Usage: "Migrate from an existing monolith lotus-miner repo to Boost",
+	Description: "Imports the legacy deals, storage ask, config, keystore and DAG store from the lotus-miner repo.\n" +
+		"Use --dry-run to see what would be imported without changing anything.\n" +
+		"If the migration fails part way through, run the same command again to resume it.",
 	Flags: append([]cli.Flag{
 		&cli.StringFlag{
 			Name:     "import-miner-repo",
 			Usage:    "initialize boost from an existing monolith lotus-miner repo",
 			Required: true,
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "report what would be migrated without changing anything",
 		}},
 		append(minerApiFlags, migrateFlags...)...,
 	),
 	Before: before,
 	Action: func(cctx *cli.Context) error {
+		if cctx.Bool("dry-run") {
+			return migrateDryRun(cctx, true, cctx.String("import-miner-repo"))
+		}
 		return migrate(cctx, true, cctx.String("import-miner-repo"))
 	},
 }
 
+func migrateDryRun(cctx *cli.Context, fromMonolith bool, mktsRepoPath string) error {
+	ctx := scliutil.ReqContext(cctx)
+
+	fmt.Printf("Opening repo '%s'\n", mktsRepoPath)
+	mktsRepo, err := getMarketsRepo(mktsRepoPath)
+	if err != nil {
+		return err
+	}
+	defer mktsRepo.Close() //nolint:errcheck
+
+	inv, err := inventoryMarketsRepo(ctx, mktsRepo)
+	if err != nil {
+		return err
+	}
+
+	boostRepoPath := cctx.String(FlagBoostRepo)
+	printMigrationPlan(inv, mktsRepoPath, boostRepoPath, fromMonolith)
+
+	r, err := lotus_repo.NewFS(boostRepoPath)
+	if err != nil {
+		return err
+	}
+	exists, err := r.Exists()
+	if err != nil {
+		return err
+	}
+	switch {
+	case exists && migrationInProgress(boostRepoPath):
+		fmt.Printf("\nA migration to '%s' is in progress: running the migration will resume it\n", boostRepoPath)
+	case exists:
+		fmt.Printf("\nWarning: the repo at '%s' is already initialized, so the migration would fail\n", boostRepoPath)
+	}
+	return nil
+}
+
 func migrate(cctx *cli.Context, fromMonolith bool, mktsRepoPath string) error {
 	ctx := scliutil.ReqContext(cctx)
 
@@ -213,48 +258,72 @@ func migrate(cctx *cli.Context, fromMonolith bool, mktsRepoPath string) error {
 		return err
 	}
 
+	progress, err := loadMigrateProgress(boostRepo.Path())
+	if err != nil {
+		return err
+	}
+
 	// Migrate datastore keys
-	fmt.Println("Migrating datastore keys")
-	err = migrateMarketsDatastore(ctx, ds, mktsRepo)
+	err = progress.run(migrateStepDatastore, func() error {
+		fmt.Println("Migrating datastore keys")
+		return migrateMarketsDatastore(ctx, ds, mktsRepo)
+	})
 	if err != nil {
 		return err
 	}
 
 	// Migrate keystore
-	fmt.Println("Migrating keystore")
-	err = backupmgr.CopyKeysBetweenRepos(mktsRepo, boostRepo)
+	err = progress.run(migrateStepKeystore, func() error {
+		fmt.Println("Migrating keystore")
+		return backupmgr.CopyKeysBetweenRepos(mktsRepo, boostRepo)
+	})
 	if err != nil {
 		return err
 	}
 
 	// Migrate config
-	fmt.Println("Migrating markets config")
-	err = migrateMarketsConfig(cctx, mktsRepo, boostRepo, bp, fromMonolith)
+	err = progress.run(migrateStepConfig, func() error {
+		fmt.Println("Migrating markets config")
+		return migrateMarketsConfig(cctx, mktsRepo, boostRepo, bp, fromMonolith)
+	})
 	if err != nil {
 		return err
 	}
 
 	// Add the miner address to the metadata datastore
-	fmt.Printf("Adding miner address %s to datastore\n", bp.minerActor)
-	err = addMinerAddressToDatastore(ds, bp.minerActor)
+	err = progress.run(migrateStepMinerAddr, func() error {
+		fmt.Printf("Adding miner address %s to datastore\n", bp.minerActor)
+		return addMinerAddressToDatastore(ds, bp.minerActor)
+	})
 	if err != nil {
 		return err
 	}
 
 	// Copy the storage.json file if there is one, otherwise create an empty one
-	err = migrateStorageJson(mktsRepo.Path(), boostRepo.Path())
+	err = progress.run(migrateStepStorageJson, func() error {
+		return migrateStorageJson(mktsRepo.Path(), boostRepo.Path())
+	})
 	if err != nil {
 		return err
 	}
 
 	// Create an auth token
-	err = createAuthToken(bp.repo, boostRepo)
+	err = progress.run(migrateStepAuthToken, func() error {
+		return createAuthToken(bp.repo, boostRepo)
+	})
 	if err != nil {
 		return err
 	}
 
 	// Migrate DAG store
-	err = migrateDAGStore(ctx, mktsRepo, boostRepo)
+	err = progress.run(migrateStepDAGStore, func() error {
+		return migrateDAGStore(ctx, mktsRepo, boostRepo)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = progress.complete()
 	if err != nil {
 		return err
 	}
@@ -368,6 +437,13 @@ func migrateDAGStore(ctx context.Context, mktsRepo lotus_repo.LockedRepo, boostR
 		return nil
 	}
 
+	// If a previous attempt at the migration copied or moved the dagstore
+	// but didn't complete, the dagstore is already in the boost repo
+	if _, err := os.Lstat(boostSubdirPath); err == nil {
+		fmt.Printf("Not migrating the dagstore as %s already exists\n", boostSubdirPath)
+		return nil
+	}
+
 	dirInfo, err := os.Lstat(mktsSubdirPath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -607,7 +683,9 @@ func initBoost(ctx context.Context, cctx *cli.Context, marketsRepo lotus_repo.Lo
 	if err != nil {
 		return nil, err
 	}
-	if ok {
+	// If a migration into the repo failed part way through, resume it
+	resume := ok && marketsRepo != nil && migrationInProgress(repoPath)
+	if ok && !resume {
 		return nil, fmt.Errorf("repo at '%s' is already initialized", repoPath)
 	}
 
@@ -624,9 +702,20 @@ func initBoost(ctx context.Context, cctx *cli.Context, marketsRepo lotus_repo.Lo
 		return nil, fmt.Errorf(msg + ". Boost and Lotus Daemon must have the same API version")
 	}
 
-	fmt.Println("Creating boost repo")
-	if err := r.Init(repo.Boost); err != nil {
-		return nil, err
+	if resume {
+		fmt.Printf("Resuming migration into repo at %s\n", repoPath)
+	} else {
+		fmt.Println("Creating boost repo")
+		if err := r.Init(repo.Boost); err != nil {
+			return nil, err
+		}
+		if marketsRepo != nil {
+			// Mark the migration as in progress, so that it can be resumed
+			// if it fails
+			if _, err := loadMigrateProgress(repoPath); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	return &boostParams{
@@ -55,10 +55,17 @@ boost log set-level level
 For example:
 $ boost log set-level info
 
+boost log set-level subsystem level
+For example:
+$ boost log set-level httptransport debug
+
 boost log set-level subsystem=level [subsystem=level]...
 For example:
 $ boost log set-level provider=debug
 
+The log levels are changed immediately, without restarting boost.
+Use boost log list to show the available subsystems.
+
 Note that levels are applied in order, and * is used to set all logs.
 So for example to set all logs to info, provider to warn and gql to debug:
 $ boost log set-level *=info provider=warn gql=debug`,
@@ -89,6 +96,18 @@ $ boost log set-level *=info provider=warn gql=debug`,
 			return nil
 		}
 
+		// If there are two arguments without an =, assume they are the
+		// subsystem and the level
+		if len(args) == 2 && !strings.Contains(args[0], "=") && !strings.Contains(args[1], "=") {
+			subsystem, level := args[0], args[1]
+			err = boostApi.LogSetLevel(ctx, subsystem, level)
+			if err != nil {
+				return fmt.Errorf("setting subsystem %s to level %s: %w", subsystem, level, err)
+			}
+
+			return nil
+		}
+
 		// Split each subsystem=level argument and apply it
 		for _, arg := range args {
 			parts := strings.Split(arg, "=")
@@ -31,6 +31,7 @@ func main() {
 				Value:   "~/.boost",
 			},
 			cmd.FlagJson,
+			cmd.FlagLogFormat,
 			cliutil.FlagVeryVerbose,
 		},
 		Commands: []*cli.Command{
@@ -41,6 +42,12 @@ func main() {
 			migrateMarketsCmd,
 			backupCmd,
 			restoreCmd,
+			dbCmd,
+			replicationCmd,
+			dealsCmd,
+			fundsCmd,
+			sealingCmd,
+			pieceWorkerCmd,
 			dummydealCmd,
 			dataTransfersCmd,
 			retrievalDealsCmd,
@@ -49,7 +56,11 @@ func main() {
 			logCmd,
 			dagstoreCmd,
 			piecesCmd,
+			retrievalPolicyCmd,
+			retrievalPaymentsCmd,
+			retrievalCmd,
 			netCmd,
+			statusCmd,
 		},
 	}
 	app.Setup()
@@ -60,6 +71,11 @@ func main() {
 }
 
 func before(cctx *cli.Context) error {
+	// Set the log format first, as it resets the log levels
+	if err := cmd.SetupLogFormat(cctx); err != nil {
+		return err
+	}
+
 	_ = logging.SetLogLevel("boostd", "INFO")
 	_ = logging.SetLogLevel("db", "INFO")
 	_ = logging.SetLogLevel("boost-prop", "INFO")
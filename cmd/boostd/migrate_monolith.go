@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/filecoin-project/boost/util"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/lotus/chain/types"
+	lotus_config "github.com/filecoin-project/lotus/node/config"
+	lotus_repo "github.com/filecoin-project/lotus/node/repo"
+	"github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+)
+
+// The migration steps, in the order they are performed
+const (
+	migrateStepDatastore   = "datastore"
+	migrateStepKeystore    = "keystore"
+	migrateStepConfig      = "config"
+	migrateStepMinerAddr   = "miner-address"
+	migrateStepStorageJson = "storage.json"
+	migrateStepAuthToken   = "auth-token"
+	migrateStepDAGStore    = "dagstore"
+)
+
+// The name of the file in the boost repo that records which migration steps
+// have completed, so that a migration that fails part way through can be
+// resumed by running the same command again. The file is deleted when the
+// migration completes.
+const migrateProgressFile = "migration-progress.json"
+
+type migrateProgress struct {
+	path      string
+	Completed []string
+}
+
+func migrationInProgress(boostRepoPath string) bool {
+	_, err := os.Stat(path.Join(boostRepoPath, migrateProgressFile))
+	return err == nil
+}
+
+// loadMigrateProgress loads the migration progress from the boost repo, or
+// creates it if the migration is just starting
+func loadMigrateProgress(boostRepoPath string) (*migrateProgress, error) {
+	p := &migrateProgress{path: path.Join(boostRepoPath, migrateProgressFile)}
+	bz, err := os.ReadFile(p.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return p, p.save()
+		}
+		return nil, fmt.Errorf("reading migration progress %s: %w", p.path, err)
+	}
+	if err := json.Unmarshal(bz, p); err != nil {
+		return nil, fmt.Errorf("parsing migration progress %s: %w", p.path, err)
+	}
+	return p, nil
+}
+
+func (p *migrateProgress) done(step string) bool {
+	for _, s := range p.Completed {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
+// run runs the migration step, unless it already completed in a previous
+// attempt, and records that it completed
+func (p *migrateProgress) run(step string, fn func() error) error {
+	if p.done(step) {
+		fmt.Printf("Skipping %s migration: already completed\n", step)
+		return nil
+	}
+	if err := fn(); err != nil {
+		return fmt.Errorf("migrating %s (run the same command again to resume the migration): %w", step, err)
+	}
+	p.Completed = append(p.Completed, step)
+	return p.save()
+}
+
+func (p *migrateProgress) save() error {
+	bz, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(p.path, bz, 0644); err != nil {
+		return fmt.Errorf("saving migration progress %s: %w", p.path, err)
+	}
+	return nil
+}
+
+// complete deletes the migration progress file
+func (p *migrateProgress) complete() error {
+	if err := os.Remove(p.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing migration progress %s: %w", p.path, err)
+	}
+	return nil
+}
+
+// marketsInventory is the state in a legacy markets repo that would be
+// migrated to boost
+type marketsInventory struct {
+	storageDeals       int
+	storageDealsStates map[string]int
+	otherDealKeys      int
+	retrievalKeys      int
+	pieceStoreKeys     int
+	ask                *storagemarket.StorageAsk
+
+	dagstoreDir        string
+	dagstoreCustomRoot bool
+	dagstoreExists     bool
+	dagstoreSize       int64
+	shardIndexes       int
+
+	cfg *lotus_config.StorageMiner
+}
+
+func inventoryMarketsRepo(ctx context.Context, mktsRepo lotus_repo.LockedRepo) (*marketsInventory, error) {
+	inv := &marketsInventory{storageDealsStates: make(map[string]int)}
+
+	rawMktsCfg, err := mktsRepo.Config()
+	if err != nil {
+		return nil, fmt.Errorf("getting markets repo config: %w", err)
+	}
+	mktsCfg, ok := rawMktsCfg.(*lotus_config.StorageMiner)
+	if !ok {
+		return nil, fmt.Errorf("expected legacy markets config, got %T", rawMktsCfg)
+	}
+	inv.cfg = mktsCfg
+
+	mktsDS, err := mktsRepo.Datastore(ctx, metadataNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("opening datastore %s on legacy markets repo %s: %w",
+			metadataNamespace, mktsRepo.Path(), err)
+	}
+
+	// Storage deals and the storage ask
+	err = queryPrefix(ctx, mktsDS, "/deals/provider", func(key string, value []byte) {
+		if strings.HasSuffix(key, "/storage-ask/latest") || strings.HasSuffix(key, "/latest-ask") {
+			var ask storagemarket.SignedStorageAsk
+			if err := ask.UnmarshalCBOR(bytes.NewReader(value)); err == nil && ask.Ask != nil {
+				inv.ask = ask.Ask
+			}
+			return
+		}
+
+		var deal storagemarket.MinerDeal
+		if err := deal.UnmarshalCBOR(bytes.NewReader(value)); err != nil {
+			// eg the version of the deal state machine
+			inv.otherDealKeys++
+			return
+		}
+		inv.storageDeals++
+		inv.storageDealsStates[storagemarket.DealStates[deal.State]]++
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Retrieval deals
+	err = queryPrefix(ctx, mktsDS, "/retrievals/provider", func(string, []byte) { inv.retrievalKeys++ })
+	if err != nil {
+		return nil, err
+	}
+
+	// Piece store
+	err = queryPrefix(ctx, mktsDS, "/storagemarket", func(string, []byte) { inv.pieceStoreKeys++ })
+	if err != nil {
+		return nil, err
+	}
+
+	// DAG store
+	inv.dagstoreDir = path.Join(mktsRepo.Path(), "dagstore")
+	if len(mktsCfg.DAGStore.RootDir) > 0 {
+		inv.dagstoreDir = mktsCfg.DAGStore.RootDir
+		inv.dagstoreCustomRoot = true
+	}
+	if _, err := os.Stat(inv.dagstoreDir); err == nil {
+		inv.dagstoreExists = true
+		inv.dagstoreSize, err = util.DirSize(inv.dagstoreDir)
+		if err != nil {
+			return nil, fmt.Errorf("getting size of %s: %w", inv.dagstoreDir, err)
+		}
+		indexes, err := os.ReadDir(path.Join(inv.dagstoreDir, "index"))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("reading dagstore index directory: %w", err)
+		}
+		for _, idx := range indexes {
+			if strings.HasSuffix(idx.Name(), ".full.idx") {
+				inv.shardIndexes++
+			}
+		}
+	}
+
+	return inv, nil
+}
+
+func queryPrefix(ctx context.Context, ds datastore.Batching, prefix string, each func(key string, value []byte)) error {
+	q, err := ds.Query(ctx, dsq.Query{Prefix: prefix})
+	if err != nil {
+		return fmt.Errorf("legacy markets datastore query: %w", err)
+	}
+	defer q.Close() //nolint:errcheck
+
+	for res := range q.Next() {
+		if res.Error != nil {
+			return fmt.Errorf("legacy markets datastore query %s: %w", prefix, res.Error)
+		}
+		each(res.Key, res.Value)
+	}
+	return nil
+}
+
+// printMigrationPlan prints what would be migrated from the legacy markets
+// repo to boost
+func printMigrationPlan(inv *marketsInventory, mktsRepoPath string, boostRepoPath string, fromMonolith bool) {
+	fmt.Printf("Dry run: nothing will be changed. Migrating '%s' to '%s' would:\n\n", mktsRepoPath, boostRepoPath)
+
+	fmt.Println("Import legacy deals:")
+	fmt.Printf("  %d storage deals\n", inv.storageDeals)
+	states := make([]string, 0, len(inv.storageDealsStates))
+	for st := range inv.storageDealsStates {
+		states = append(states, st)
+	}
+	sort.Strings(states)
+	for _, st := range states {
+		fmt.Printf("    %s: %d\n", st, inv.storageDealsStates[st])
+	}
+	if inv.otherDealKeys > 0 {
+		fmt.Printf("  %d other storage deal datastore keys\n", inv.otherDealKeys)
+	}
+	fmt.Printf("  %d retrieval deal datastore keys\n", inv.retrievalKeys)
+	fmt.Printf("  %d piece store datastore keys\n", inv.pieceStoreKeys)
+	fmt.Println()
+
+	fmt.Println("Import the storage ask:")
+	if inv.ask == nil {
+		fmt.Println("  no storage ask has been set")
+	} else {
+		fmt.Printf("  price: %s / GiB / epoch\n", types.FIL(inv.ask.Price))
+		fmt.Printf("  verified price: %s / GiB / epoch\n", types.FIL(inv.ask.VerifiedPrice))
+		fmt.Printf("  piece size: %s - %s\n", humanize.IBytes(uint64(inv.ask.MinPieceSize)), humanize.IBytes(uint64(inv.ask.MaxPieceSize)))
+	}
+	fmt.Println()
+
+	fmt.Println("Migrate the DAG store:")
+	switch {
+	case inv.dagstoreCustomRoot:
+		fmt.Printf("  a custom dagstore path is set (%s): it must be moved or copied to $BOOST_PATH/dagstore manually\n", inv.dagstoreDir)
+	case !inv.dagstoreExists:
+		fmt.Println("  there is no dagstore directory")
+	default:
+		fmt.Printf("  copy or move %s (%s, %d shard indexes) to %s\n", inv.dagstoreDir,
+			humanize.Bytes(uint64(inv.dagstoreSize)), inv.shardIndexes, path.Join(boostRepoPath, "dagstore"))
+	}
+	fmt.Println()
+
+	fmt.Println("Migrate the config:")
+	if !fromMonolith {
+		fmt.Printf("  API listen address: %s\n", inv.cfg.Common.API.ListenAddress)
+	}
+	fmt.Printf("  libp2p listen addresses: %s\n", strings.Join(inv.cfg.Common.Libp2p.ListenAddresses, ", "))
+	fmt.Printf("  staging area: %s\n", humanize.IBytes(uint64(inv.cfg.Dealmaking.MaxStagingDealsBytes)))
+	fmt.Println("  dealmaking, fees, dagstore and index provider config (the index provider will be enabled)")
+	fmt.Println()
+
+	fmt.Println("Copy the keystore and storage.json, and create an API token")
+}
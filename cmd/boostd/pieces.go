@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 	"text/tabwriter"
+	"time"
 
+	"github.com/filecoin-project/boost/api"
 	bcli "github.com/filecoin-project/boost/cli"
 	"github.com/filecoin-project/boost/cmd"
 	lcli "github.com/filecoin-project/lotus/cli"
@@ -22,6 +24,11 @@ var piecesCmd = &cli.Command{
 		piecesListCidInfosCmd,
 		piecesInfoCmd,
 		piecesCidInfoCmd,
+		piecesHealthCmd,
+		piecesUnsealedStatusCmd,
+		piecesUnsealCmd,
+		piecesRemoveUnsealedCmd,
+		piecesRemoveCmd,
 	},
 }
 
@@ -288,3 +295,267 @@ var piecesCidInfoCmd = &cli.Command{
 		return w.Flush()
 	},
 }
+
+var piecesHealthCmd = &cli.Command{
+	Name:      "health",
+	Usage:     "Show the results of the checks of piece indexes against the unsealed data",
+	ArgsUsage: "[piece cid]",
+	Description: "Without arguments, lists the result of the last check of each piece since boost started.\n" +
+		"With a piece cid, checks the piece now.",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "repair",
+			Usage: "re-index the piece if its index is missing or corrupt (requires a piece cid)",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Bool("repair") && !cctx.Args().Present() {
+			return lcli.ShowHelp(cctx, fmt.Errorf("must specify piece cid to repair"))
+		}
+
+		nodeApi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		var results []api.PieceHealth
+		if cctx.Args().Present() {
+			c, err := cid.Decode(cctx.Args().First())
+			if err != nil {
+				return err
+			}
+
+			h, err := nodeApi.PiecesCheckHealth(ctx, c, cctx.Bool("repair"))
+			if err != nil {
+				return err
+			}
+			results = []api.PieceHealth{*h}
+		} else {
+			results, err = nodeApi.PiecesHealth(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(results)
+		}
+
+		if len(results) == 0 {
+			fmt.Println("No pieces have been checked")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 4, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "Piece\tStatus\tBlocks\tChecked\tRepair\tError")
+		for _, h := range results {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n", h.PieceCid, h.Status, h.BlocksChecked,
+				h.CheckedAt.Format(time.RFC3339), h.Repair, h.Error)
+		}
+		return w.Flush()
+	},
+}
+
+var piecesUnsealedStatusCmd = &cli.Command{
+	Name:      "unsealed-status",
+	Usage:     "Show whether each sector containing the piece has an unsealed copy, and whether the piece is retrievable",
+	ArgsUsage: "<piece cid>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return lcli.ShowHelp(cctx, fmt.Errorf("must specify piece cid"))
+		}
+		pieceCid, err := cid.Decode(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		nodeApi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		st, err := nodeApi.PiecesUnsealedStatus(ctx, pieceCid)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(st)
+		}
+
+		fmt.Println("Piece:", st.PieceCid)
+		fmt.Println("Indexed:", st.Indexed)
+		fmt.Println("Unsealed copy:", st.HasUnsealedCopy)
+		fmt.Println("Retrievable:", st.Retrievable)
+		if st.Unsealing {
+			fmt.Println("Unsealing: in progress")
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 4, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "Deal\tSector\tUnsealed\tKeep Unsealed\tError")
+		for _, d := range st.Deals {
+			fmt.Fprintf(w, "%d\t%d\t%t\t%t\t%s\n", d.DealID, d.SectorID, d.IsUnsealed, d.KeepUnsealed, d.Error)
+		}
+		return w.Flush()
+	},
+}
+
+var piecesUnsealCmd = &cli.Command{
+	Name:      "unseal",
+	Usage:     "Unseal a piece so that it can be retrieved",
+	ArgsUsage: "<piece cid>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return lcli.ShowHelp(cctx, fmt.Errorf("must specify piece cid"))
+		}
+		pieceCid, err := cid.Decode(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		nodeApi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if err := nodeApi.PiecesUnseal(ctx, pieceCid); err != nil {
+			return err
+		}
+
+		fmt.Printf("Started unsealing piece %s\n", pieceCid)
+		fmt.Println("Use 'boostd pieces unsealed-status' to check when the piece is retrievable")
+		return nil
+	},
+}
+
+var piecesRemoveUnsealedCmd = &cli.Command{
+	Name:      "remove-unsealed",
+	Usage:     "Remove the unsealed copy of each sector containing a piece",
+	ArgsUsage: "<piece cid>",
+	Description: "Note that this removes the unsealed copy of all the pieces in the sector.\n" +
+		"A sector is not removed if any of its deals asked to keep an unsealed copy, unless --force is set.",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "remove the unsealed copy even if deals in the sector asked to keep it",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return lcli.ShowHelp(cctx, fmt.Errorf("must specify piece cid"))
+		}
+		pieceCid, err := cid.Decode(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		nodeApi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		sectors, err := nodeApi.PiecesRemoveUnsealed(ctx, pieceCid, cctx.Bool("force"))
+		if err != nil {
+			return err
+		}
+
+		for _, s := range sectors {
+			fmt.Printf("Removed unsealed copy of sector %d\n", s)
+		}
+		return nil
+	},
+}
+
+var piecesRemoveCmd = &cli.Command{
+	Name:      "remove",
+	Usage:     "Remove a piece's announcements, index, cached data and piece store records",
+	ArgsUsage: "<piece cid>",
+	Description: "Publishes removal advertisements for the piece's deals, destroys the piece's dagstore shard,\n" +
+		"and removes the piece from the lookup cache and the piece store.\n" +
+		"The piece's deals are kept in the deals database, and the sector data is not changed.",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "list what would be removed without removing anything",
+		},
+		&cli.BoolFlag{
+			Name:    "assume-yes",
+			Usage:   "remove the piece without asking for confirmation",
+			Aliases: []string{"y", "yes"},
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return lcli.ShowHelp(cctx, fmt.Errorf("must specify piece cid"))
+		}
+		pieceCid, err := cid.Decode(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		nodeApi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if cctx.Bool("dry-run") || !cctx.Bool("assume-yes") {
+			res, err := nodeApi.PiecesRemove(ctx, pieceCid, true)
+			if err != nil {
+				return err
+			}
+			if cctx.Bool("json") && cctx.Bool("dry-run") {
+				return cmd.PrintJson(res)
+			}
+
+			fmt.Println("The following records will be removed:")
+			printPieceRemoval(res)
+			if cctx.Bool("dry-run") {
+				return nil
+			}
+
+			yes, err := confirm(ctx)
+			if err != nil {
+				return err
+			}
+			if !yes {
+				return nil
+			}
+		}
+
+		res, err := nodeApi.PiecesRemove(ctx, pieceCid, false)
+		if err != nil {
+			return err
+		}
+		if cctx.Bool("json") {
+			return cmd.PrintJson(res)
+		}
+
+		fmt.Println("Removed:")
+		printPieceRemoval(res)
+		return nil
+	},
+}
+
+func printPieceRemoval(res *api.PieceRemoval) {
+	fmt.Printf("  Announcements:     %d deals\n", len(res.RemovedAnnouncements))
+	for _, dealUuid := range res.RemovedAnnouncements {
+		fmt.Printf("    %s\n", dealUuid)
+	}
+	fmt.Printf("  Dagstore shard:    %t\n", res.RemovedShard)
+	if !res.DryRun {
+		fmt.Printf("  Cached lookups:    %d\n", res.RemovedCacheEntries)
+	}
+	fmt.Printf("  Flat store copy:   %t\n", res.RemovedFlatCopy)
+	fmt.Printf("  Payload locations: %d\n", len(res.RemovedCidInfos))
+	fmt.Printf("  Piece info:        %t\n", res.RemovedPieceInfo)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/filecoin-project/boost/cmd/lib"
+	"github.com/filecoin-project/boost/pieceworker"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/urfave/cli/v2"
+)
+
+var pieceWorkerCmd = &cli.Command{
+	Name:  "piece-worker",
+	Usage: "Add deal data to sectors and calculate commp on a separate machine to boostd",
+	Subcommands: []*cli.Command{
+		pieceWorkerRunCmd,
+	},
+}
+
+var pieceWorkerRunCmd = &cli.Command{
+	Name:  "run",
+	Usage: "Run a piece worker that serves the piece worker API to boostd",
+	Description: "Configure boostd to use the piece worker by setting the URL and Token in the " +
+		"PieceWorkers.AddPiece and / or PieceWorkers.Commp sections of the boost config",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "listen",
+			Usage: "the address to serve the piece worker API on",
+			Value: "0.0.0.0:8045",
+		},
+		&cli.StringFlag{
+			Name:     "token",
+			Usage:    "the token that boostd must use to authenticate with the piece worker",
+			EnvVars:  []string{"BOOST_PIECE_WORKER_TOKEN"},
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:    "api-sealer",
+			Usage:   "the sealer API info, used to add deal data to sectors (if not set the worker only calculates commp)",
+			EnvVars: []string{"SEALER_API_INFO"},
+		},
+		&cli.IntFlag{
+			Name:  "max-concurrent-commp",
+			Usage: "the maximum number of commp calculations to run in parallel",
+			Value: 1,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		ctx := lcli.ReqContext(cctx)
+
+		var sealer pieceworker.Sealer
+		if cctx.IsSet("api-sealer") {
+			sealerApi, closer, err := lib.GetMinerApi(ctx, cctx.String("api-sealer"), log)
+			if err != nil {
+				return fmt.Errorf("getting sealer API: %w", err)
+			}
+			defer closer()
+			sealer = sealerApi
+		} else {
+			log.Info("No sealer API configured: the piece worker will only calculate commp")
+		}
+
+		worker := pieceworker.NewWorker(sealer, cctx.Int("max-concurrent-commp"))
+		srv := &http.Server{
+			Addr:              cctx.String("listen"),
+			Handler:           pieceworker.Handler(worker, pieceworker.TokenVerifier(cctx.String("token"))),
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+
+		errc := make(chan error, 1)
+		go func() {
+			log.Infof("Piece worker serving API on %s", srv.Addr)
+			errc <- srv.ListenAndServe()
+		}()
+
+		select {
+		case err := <-errc:
+			return fmt.Errorf("serving piece worker API: %w", err)
+		case <-ctx.Done():
+		}
+
+		log.Info("Shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("shutting down piece worker: %w", err)
+		}
+		log.Info("Graceful shutdown successful")
+		return nil
+	},
+}
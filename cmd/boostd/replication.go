@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/filecoin-project/boost/node/repo"
+	"github.com/filecoin-project/boost/replication"
+	lcli "github.com/filecoin-project/lotus/cli"
+	lotus_repo "github.com/filecoin-project/lotus/node/repo"
+	"github.com/urfave/cli/v2"
+)
+
+var replicationCmd = &cli.Command{
+	Name:  "replication",
+	Usage: "Replicate deal state to a standby boost node",
+	Subcommands: []*cli.Command{
+		replicationReceiveCmd,
+	},
+}
+
+var replicationReceiveCmd = &cli.Command{
+	Name:  "receive",
+	Usage: "Receive deal state changes from a primary boost node",
+	Description: `Runs on a standby boost node, and applies the deal state changes streamed by
+the primary node (see the Replication section of the primary's config) to the
+standby's deals database.
+The primary sends the changes over https, using the auth token as a bearer
+token, so the standby needs a TLS certificate for the address in the
+primary's SinkURL (a self-signed certificate can be used by setting the
+primary's SinkCACertFile).
+To fail over, stop this command and start boostd on the standby.`,
+	Before: before,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "listen",
+			Usage: "the address to listen for deal state changes on",
+			Value: "0.0.0.0:8043",
+		},
+		&cli.StringFlag{
+			Name:     "auth-token",
+			Usage:    "the bearer token that the primary must send with each request",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "tls-cert",
+			Usage:    "the path to the PEM encoded TLS certificate to serve",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "tls-key",
+			Usage:    "the path to the PEM encoded private key of the TLS certificate",
+			Required: true,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		ctx := lcli.DaemonContext(cctx)
+
+		r, err := lotus_repo.NewFS(cctx.String(FlagBoostRepo))
+		if err != nil {
+			return err
+		}
+		ok, err := r.Exists()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("repo at '%s' is not initialized", cctx.String(FlagBoostRepo))
+		}
+
+		lr, err := r.Lock(repo.Boost)
+		if err != nil {
+			return fmt.Errorf("locking repo: %w. The standby boostd process must be stopped while receiving", err)
+		}
+		defer lr.Close()
+
+		dealsDB, sqldb, logsDB, err := openDealsDBs(lr)
+		if err != nil {
+			return err
+		}
+		defer sqldb.Close()
+		defer logsDB.Close()
+
+		if err := db.CreateAllBoostTables(ctx, sqldb, logsDB); err != nil {
+			return fmt.Errorf("creating tables: %w", err)
+		}
+		if err := migrations.Migrate(sqldb); err != nil {
+			return fmt.Errorf("migrating deals db: %w", err)
+		}
+
+		rcv, err := replication.NewReceiver(dealsDB, db.NewFundsDB(sqldb), db.NewStorageDB(sqldb), db.NewLogsDB(logsDB),
+			db.NewDealChangeLogDB(sqldb), cctx.String("auth-token"))
+		if err != nil {
+			return err
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle(replication.ChangesPath, rcv)
+		srv := &http.Server{
+			Addr:              cctx.String("listen"),
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+			TLSConfig:         &tls.Config{MinVersion: tls.VersionTLS12},
+		}
+
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = srv.Shutdown(shutdownCtx)
+		}()
+
+		fmt.Printf("Receiving deal state changes on https://%s%s\n", cctx.String("listen"), replication.ChangesPath)
+		if err := srv.ListenAndServeTLS(cctx.String("tls-cert"), cctx.String("tls-key")); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	},
+}
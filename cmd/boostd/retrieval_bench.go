@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	bapi "github.com/filecoin-project/boost/api"
+	bcli "github.com/filecoin-project/boost/cli"
+	"github.com/filecoin-project/boost/cmd"
+	rc "github.com/filecoin-project/boost/retrievalmarket/client"
+	"github.com/filecoin-project/boost/retrievalmarket/lp2pimpl"
+	"github.com/filecoin-project/boost/retrievalmarket/retriever"
+	"github.com/filecoin-project/go-address"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p"
+	"github.com/urfave/cli/v2"
+)
+
+var retrievalCmd = &cli.Command{
+	Name:  "retrieval",
+	Usage: "Tools for checking the retrieval setup",
+	Subcommands: []*cli.Command{
+		retrievalBenchCmd,
+	},
+}
+
+var retrievalBenchCmd = &cli.Command{
+	Name:  "bench",
+	Usage: "Benchmark retrievals from this node over each enabled protocol",
+	Description: "Retrieves sample content stored on this node in the same way that a client would, " +
+		"over each protocol advertised by the node (graphsync, and http and bitswap if booster-http " +
+		"and booster-bitswap are configured), and prints the time to first byte and throughput of each retrieval. " +
+		"The sample content is the data root of recent deals for pieces that can be retrieved right now " +
+		"(that are indexed and have an unsealed copy), unless payload CIDs are given as arguments.",
+	ArgsUsage: "[payload cid...]",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "count",
+			Usage: "the number of sample pieces to retrieve",
+			Value: 3,
+		},
+		&cli.StringSliceFlag{
+			Name:  "protocols",
+			Usage: "the protocols to benchmark",
+			Value: cli.NewStringSlice(retriever.DefaultProtocols...),
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "output the results as json",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		protocols := cctx.StringSlice("protocols")
+		for _, p := range protocols {
+			if p != retriever.ProtocolHTTP && p != retriever.ProtocolBitswap && p != retriever.ProtocolGraphsync {
+				return fmt.Errorf("unknown protocol '%s': must be one of %s", p, strings.Join(retriever.DefaultProtocols, ", "))
+			}
+		}
+
+		// Get the payload CIDs to retrieve
+		var roots []cid.Cid
+		for _, arg := range cctx.Args().Slice() {
+			c, err := cid.Parse(arg)
+			if err != nil {
+				return fmt.Errorf("parsing payload cid %s: %w", arg, err)
+			}
+			roots = append(roots, c)
+		}
+		if len(roots) == 0 {
+			roots, err = sampleRoots(cctx, napi, cctx.Int("count"))
+			if err != nil {
+				return err
+			}
+			if len(roots) == 0 {
+				return fmt.Errorf("there are no deals with pieces that can be retrieved right now: pass payload CIDs as arguments to benchmark")
+			}
+		}
+
+		// The node's libp2p address
+		provider, err := napi.NetAddrsListen(ctx)
+		if err != nil {
+			return fmt.Errorf("getting boost node address: %w", err)
+		}
+
+		// Create a client host to retrieve from the node
+		h, err := libp2p.New()
+		if err != nil {
+			return fmt.Errorf("creating libp2p host: %w", err)
+		}
+		defer h.Close()
+
+		if err := h.Connect(ctx, provider); err != nil {
+			return fmt.Errorf("connecting to boost node at %s: %w", provider, err)
+		}
+		candidates, err := retriever.ProviderCandidates(ctx, lp2pimpl.NewTransportsClient(h), provider)
+		if err != nil {
+			return err
+		}
+
+		// Blocks are discarded as they're retrieved, so that each retrieval
+		// fetches all of the data (and the data doesn't take up any space)
+		bstore := &retriever.DiscardBlockstore{}
+		var gsClient retriever.GraphsyncClient
+		if hasProtocol(protocols, retriever.ProtocolGraphsync) {
+			dataDir, err := os.MkdirTemp("", "boostd-retrieval-bench")
+			if err != nil {
+				return fmt.Errorf("creating temp dir: %w", err)
+			}
+			defer os.RemoveAll(dataDir)
+
+			// The benchmark only makes free retrievals, so the graphsync
+			// client doesn't need a wallet or a chain API
+			gsClient, err = rc.NewClient(h, nil, nil, address.Undef, bstore, dssync.MutexWrap(ds.NewMapDatastore()), dataDir)
+			if err != nil {
+				return fmt.Errorf("creating graphsync retrieval client: %w", err)
+			}
+		}
+
+		r := retriever.New(retriever.Config{
+			Host:       h,
+			Blockstore: bstore,
+			Graphsync:  gsClient,
+			Protocols:  protocols,
+		})
+		var results []retriever.BenchResult
+		for _, root := range roots {
+			if !cctx.Bool("json") {
+				fmt.Fprintf(os.Stderr, "Benchmarking retrieval of %s\n", root)
+			}
+			results = append(results, r.Bench(ctx, root, candidates)...)
+		}
+		if len(results) == 0 {
+			return fmt.Errorf("the boost node doesn't serve retrievals over any of %v", protocols)
+		}
+
+		return cmd.PrintBenchResults(results, cctx.Bool("json"))
+	},
+}
+
+// sampleRoots returns the data roots of recent deals for up to count
+// distinct pieces that can be retrieved without unsealing
+func sampleRoots(cctx *cli.Context, napi bapi.Boost, count int) ([]cid.Cid, error) {
+	ctx := lcli.ReqContext(cctx)
+	deals, err := napi.BoostDeals(ctx, bapi.DealsFilter{Limit: 1000})
+	if err != nil {
+		return nil, fmt.Errorf("listing deals: %w", err)
+	}
+
+	var roots []cid.Cid
+	pieces := make(map[cid.Cid]struct{})
+	for _, d := range deals {
+		if len(roots) >= count {
+			break
+		}
+		pieceCid := d.ClientDealProposal.Proposal.PieceCID
+		if !d.DealDataRoot.Defined() {
+			continue
+		}
+		if _, ok := pieces[pieceCid]; ok {
+			continue
+		}
+		pieces[pieceCid] = struct{}{}
+
+		st, err := napi.PiecesUnsealedStatus(ctx, pieceCid)
+		if err != nil || !st.Retrievable {
+			continue
+		}
+		roots = append(roots, d.DealDataRoot)
+	}
+	return roots, nil
+}
+
+func hasProtocol(protocols []string, protocol string) bool {
+	for _, p := range protocols {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
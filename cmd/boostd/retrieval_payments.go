@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	bcli "github.com/filecoin-project/boost/cli"
+	"github.com/filecoin-project/boost/cmd"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	"github.com/urfave/cli/v2"
+)
+
+var retrievalPaymentsCmd = &cli.Command{
+	Name:  "retrieval-payments",
+	Usage: "Show the terms for paid retrievals and the earnings from retrievals",
+	Description: "The price of retrievals is set with 'boostd retrieval-deals set-ask'. " +
+		"Graphsync retrievals are paid for in the retrieval deal. " +
+		"HTTP retrievals are paid for when booster-http is run with --paid-retrievals: " +
+		"clients send payment channel vouchers to build up credit, which is used up by each download.",
+	Subcommands: []*cli.Command{
+		retrievalPaymentsTermsCmd,
+		retrievalPaymentsEarningsCmd,
+	},
+}
+
+var retrievalPaymentsTermsCmd = &cli.Command{
+	Name:  "terms",
+	Usage: "Show the terms for paid retrievals",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		terms, err := napi.BoostRetrievalPaymentTerms(ctx)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(terms)
+		}
+
+		if terms.PricePerByte.IsZero() {
+			fmt.Println("Retrievals are free")
+		}
+		fmt.Printf("Price per byte: %s\n", types.FIL(terms.PricePerByte))
+		fmt.Printf("Price per GiB: %s\n", types.FIL(big.Mul(terms.PricePerByte, big.NewInt(1<<30))))
+		fmt.Printf("Unseal price: %s\n", types.FIL(terms.UnsealPrice))
+		fmt.Printf("Payment address: %s\n", terms.PaymentAddress)
+		return nil
+	},
+}
+
+var retrievalPaymentsEarningsCmd = &cli.Command{
+	Name:  "earnings",
+	Usage: "Show the total of the payments received from each client for retrievals",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		earnings, err := napi.BoostRetrievalEarnings(ctx)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(earnings)
+		}
+
+		if len(earnings) == 0 {
+			fmt.Println("No payments have been received for retrievals")
+			return nil
+		}
+
+		tw := tablewriter.New(
+			tablewriter.Col("Client"),
+			tablewriter.Col("Protocol"),
+			tablewriter.Col("Payments"),
+			tablewriter.Col("Total"),
+		)
+		total := big.Zero()
+		for _, e := range earnings {
+			tw.Write(map[string]interface{}{
+				"Client":   e.ClientID,
+				"Protocol": e.Protocol,
+				"Payments": e.Payments,
+				"Total":    types.FIL(e.Total).String(),
+			})
+			total = big.Add(total, e.Total)
+		}
+		if err := tw.Flush(os.Stdout); err != nil {
+			return err
+		}
+		fmt.Printf("\nTotal earnings: %s\n", types.FIL(total))
+		return nil
+	},
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	bcli "github.com/filecoin-project/boost/cli"
+	"github.com/filecoin-project/boost/cmd"
+	"github.com/filecoin-project/boost/retrievalpolicy"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/urfave/cli/v2"
+)
+
+var retrievalPolicyCmd = &cli.Command{
+	Name:  "retrieval-policy",
+	Usage: "Manage the policy for retrievals served by booster-http and booster-bitswap",
+	Description: "The retrieval policy is applied by booster-http and booster-bitswap " +
+		"when they are run with --retrieval-policy. They fetch changes to the policy periodically.",
+	Subcommands: []*cli.Command{
+		retrievalPolicyGetCmd,
+		retrievalPolicySetCmd,
+	},
+}
+
+var retrievalPolicyGetCmd = &cli.Command{
+	Name:  "get",
+	Usage: "Show the retrieval policy",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		policy, err := napi.BoostRetrievalPolicy(ctx)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(policy)
+		}
+
+		fmt.Println("Default access: " + policy.DefaultAccess)
+		if len(policy.AllowedClients) > 0 {
+			fmt.Println("Allowed clients: " + strings.Join(policy.AllowedClients, ", "))
+		}
+		for i, r := range policy.Rules {
+			fmt.Printf("Rule %d: %s\n", i+1, r.Access)
+			for _, pc := range r.PieceCids {
+				fmt.Println("  piece " + pc.String())
+			}
+			if len(r.AllowedClients) > 0 {
+				fmt.Println("  allowed clients: " + strings.Join(r.AllowedClients, ", "))
+			}
+		}
+		return nil
+	},
+}
+
+var retrievalPolicySetCmd = &cli.Command{
+	Name:      "set",
+	ArgsUsage: "<policy json file>",
+	Usage:     "Replace the retrieval policy with the policy in a JSON file ('-' to read from stdin)",
+	Description: "The JSON file has the same format as the output of 'boostd --json retrieval-policy get', eg\n" +
+		`{"DefaultAccess": "gated", "AllowedClients": ["12D3KooW..."],` + "\n" +
+		` "Rules": [{"PieceCids": [{"/": "baga6ea4sea..."}], "Access": "free"}]}`,
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return fmt.Errorf("must provide the path to a policy JSON file")
+		}
+
+		var r io.Reader = os.Stdin
+		if path := cctx.Args().First(); path != "-" {
+			f, err := os.Open(path)
+			if err != nil {
+				return fmt.Errorf("opening policy file: %w", err)
+			}
+			defer f.Close() //nolint:errcheck
+			r = f
+		}
+
+		var policy retrievalpolicy.Config
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&policy); err != nil {
+			return fmt.Errorf("parsing policy: %w", err)
+		}
+		if _, err := retrievalpolicy.New(policy); err != nil {
+			return err
+		}
+
+		napi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if err := napi.BoostSetRetrievalPolicy(ctx, policy); err != nil {
+			return err
+		}
+
+		fmt.Println("Updated the retrieval policy")
+		return nil
+	},
+}
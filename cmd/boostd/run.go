@@ -5,6 +5,7 @@ import (
 
 	"github.com/filecoin-project/boost/api"
 	"github.com/filecoin-project/boost/node"
+	"github.com/filecoin-project/boost/node/modules"
 	"github.com/filecoin-project/boost/node/modules/dtypes"
 
 	lapi "github.com/filecoin-project/lotus/api"
@@ -101,7 +102,7 @@ var runCmd = &cli.Command{
 			node.Override(new(dtypes.ShutdownChan), shutdownChan),
 			node.Base(),
 			node.Repo(r),
-			node.Override(new(v1api.FullNode), fullnodeApi),
+			node.Override(new(v1api.FullNode), modules.NewFullNode(fullnodeApi)),
 		)
 		if err != nil {
 			return fmt.Errorf("creating node: %w", err)
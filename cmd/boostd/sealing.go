@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	bcli "github.com/filecoin-project/boost/cli"
+	"github.com/filecoin-project/boost/cmd"
+	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
+	lapi "github.com/filecoin-project/lotus/api"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/urfave/cli/v2"
+)
+
+var sealingCmd = &cli.Command{
+	Name:  "sealing",
+	Usage: "Inspect the sealing pipeline",
+	Subcommands: []*cli.Command{
+		sealingStatusCmd,
+		sealingPackingCmd,
+		sealingBackpressureCmd,
+		sealingDeadlinesCmd,
+	},
+}
+
+var sealingStatusCmd = &cli.Command{
+	Name:  "status",
+	Usage: "Show sector counts by state, sectors waiting for deals and worker utilization",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		st, err := napi.BoostSealingPipelineStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(st)
+		}
+
+		fmt.Printf("Sector size: %s\n", humanize.IBytes(uint64(st.SectorSize)))
+		fmt.Println()
+
+		fmt.Println("Sectors by state")
+		states := make([]string, 0, len(st.SectorStates))
+		for s := range st.SectorStates {
+			states = append(states, string(s))
+		}
+		sort.Strings(states)
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		for _, s := range states {
+			fmt.Fprintf(w, "  %s\t%d\n", s, st.SectorStates[lapi.SectorState(s)])
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		printWaitDealsSectors("Wait deals sectors", st.WaitDealsSectors)
+		printWaitDealsSectors("Snap deals wait deals sectors", st.SnapDealsWaitDealsSectors)
+
+		fmt.Println()
+		fmt.Println("Workers")
+		if len(st.WorkerUtilization) == 0 {
+			fmt.Println("  none")
+			return nil
+		}
+		w = tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "  Hostname\tEnabled\tCPU\tGPU\tMemory\tRunning\tQueued")
+		for _, wu := range st.WorkerUtilization {
+			fmt.Fprintf(w, "  %s\t%t\t%d/%d\t%.1f/%d\t%s/%s\t%d\t%d\n",
+				wu.Hostname, wu.Enabled,
+				wu.CPUsUsed, wu.CPUs,
+				wu.GPUsUsed, wu.GPUs,
+				humanize.IBytes(wu.MemUsed), humanize.IBytes(wu.MemPhysical),
+				wu.RunningJobs, wu.QueuedJobs)
+		}
+		return w.Flush()
+	},
+}
+
+var sealingPackingCmd = &cli.Command{
+	Name:  "packing",
+	Usage: "Show the sector utilization achieved by the sector packing policy",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		r, err := napi.BoostSectorPacking(ctx)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(r)
+		}
+
+		fmt.Printf("Policy: %s\n", r.Policy)
+		if r.LastBatch != nil {
+			fmt.Printf("Last batch: %d deals packed into %d sectors at %s (planned utilization %.1f%%)\n",
+				r.LastBatch.Deals, r.LastBatch.Sectors, r.LastBatch.Time.Format(time.RFC3339), r.LastBatch.PlannedUtilization*100)
+		}
+		fmt.Println()
+
+		if len(r.Sectors) == 0 {
+			fmt.Println("No deals have been added to sectors yet")
+			return nil
+		}
+
+		fmt.Printf("Average utilization: %.1f%%\n", r.AverageUtilization*100)
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "Sector\tDeals\tUsed\tUtilization")
+		for _, su := range r.Sectors {
+			fmt.Fprintf(w, "%d\t%d\t%s\t%.1f%%\n", su.SectorID, su.Deals, humanize.IBytes(uint64(su.Used)), su.Utilization*100)
+		}
+		return w.Flush()
+	},
+}
+
+var sealingBackpressureCmd = &cli.Command{
+	Name:  "backpressure",
+	Usage: "Show the sealing throughput and the rate at which deal data is accepted",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		st, err := napi.BoostSealingBackpressure(ctx)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(st)
+		}
+
+		if !st.Enabled {
+			fmt.Println("Sealing backpressure is disabled")
+			return nil
+		}
+
+		warmingUp := ""
+		if st.WarmingUp {
+			warmingUp = " (still measuring)"
+		}
+		fmt.Printf("Sealing throughput: %.1f sectors/day%s\n", st.SectorsPerDay, warmingUp)
+		fmt.Printf("Backlog:            %s (target %s)\n", humanize.IBytes(st.BacklogBytes), humanize.IBytes(st.TargetBacklogBytes))
+		fmt.Printf("Accept rate:        %s/epoch (sealing %s/epoch)\n",
+			humanize.IBytes(st.AllowedBytesPerEpoch), humanize.IBytes(st.ThroughputBytesPerEpoch))
+		fmt.Printf("Available now:      %s\n", humanize.IBytes(st.AvailableBytes))
+		return nil
+	},
+}
+
+var sealingDeadlinesCmd = &cli.Command{
+	Name:  "deadlines",
+	Usage: "Show when sectors with deals are projected to finish sealing, compared to the deal start epochs",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		st, err := napi.BoostSealingPriority(ctx)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(st)
+		}
+
+		if !st.Enabled {
+			fmt.Println("Sealing prioritization by deal start epoch is disabled")
+			return nil
+		}
+		if len(st.Sectors) == 0 {
+			fmt.Println("No deals are waiting to be sealed")
+			return nil
+		}
+
+		fmt.Printf("Checked at %s\n\n", st.CheckedAt.Format(time.RFC3339))
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "Sector\tState\tDeals\tStart Epoch\tDeadline\tProjected Sealed\tPrioritized\tAt Risk")
+		for _, s := range st.Sectors {
+			fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%s\t%s\t%t\t%t\n", s.SectorID, s.State, len(s.Deals), s.StartEpoch,
+				humanize.Time(s.Deadline), humanize.Time(s.ProjectedSealed), s.Prioritized, s.AtRisk)
+		}
+		return w.Flush()
+	},
+}
+
+func printWaitDealsSectors(title string, sectors []*sealingpipeline.WaitDealsSector) {
+	fmt.Println()
+	fmt.Println(title)
+	if len(sectors) == 0 {
+		fmt.Println("  none")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "  Sector\tDeals\tUsed\tFree")
+	for _, s := range sectors {
+		fmt.Fprintf(w, "  %d\t%d\t%s\t%s\n", s.SectorID, len(s.Pieces),
+			humanize.IBytes(uint64(s.Used)), humanize.IBytes(uint64(s.Free)))
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	bcli "github.com/filecoin-project/boost/cli"
+	"github.com/filecoin-project/boost/cmd"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/urfave/cli/v2"
+)
+
+var statusCmd = &cli.Command{
+	Name:  "status",
+	Usage: "Show the health of each of the components that boost depends on",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := bcli.GetBoostAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		st, err := napi.BoostHealth(ctx)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			return cmd.PrintJson(st)
+		}
+
+		if st.Healthy {
+			fmt.Println("All components are healthy")
+		} else {
+			fmt.Println("Some components are unhealthy")
+		}
+		fmt.Println()
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "Component\tHealthy\tLast Check\tLast Success\tError")
+		for _, c := range st.Components {
+			fmt.Fprintf(w, "%s\t%t\t%s\t%s\t%s\n", c.Name, c.Healthy, humanTime(c.LastCheck), humanTime(c.LastSuccess), c.Error)
+		}
+		return w.Flush()
+	},
+}
+
+func humanTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return humanize.Time(t)
+}
@@ -0,0 +1,51 @@
+package filters
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/filecoin-project/boost/lib/denylist"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// DenylistFilter refuses requests for blocks in a denylist with one entry
+// per line, in the same format as the denylists used by booster-http (see
+// the lib/denylist package)
+type DenylistFilter struct {
+	entriesLk sync.RWMutex
+	entries   denylist.Entries
+}
+
+func NewDenylistFilter() *DenylistFilter {
+	return &DenylistFilter{entries: make(denylist.Entries)}
+}
+
+// FulfillRequest returns false if the CID is in the denylist
+func (df *DenylistFilter) FulfillRequest(p peer.ID, c cid.Cid) (bool, error) {
+	df.entriesLk.RLock()
+	defer df.entriesLk.RUnlock()
+	return !df.entries.Contains(c, nil), nil
+}
+
+// ParseUpdate replaces the denylist with the entries in the stream
+func (df *DenylistFilter) ParseUpdate(stream io.Reader) error {
+	entries, err := denylist.Parse(stream)
+	if err != nil {
+		return fmt.Errorf("parsing denylist: %w", err)
+	}
+	df.entriesLk.Lock()
+	df.entries = entries
+	df.entriesLk.Unlock()
+	return nil
+}
+
+// FetcherForLocation makes a fetcher for an http(s) URL or a local file
+func FetcherForLocation(location string) Fetcher {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		return FetcherForHTTPEndpoint(location, "")
+	}
+	return FetcherForFile(location)
+}
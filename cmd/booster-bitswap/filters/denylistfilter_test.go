@@ -0,0 +1,61 @@
+package filters_test
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/cmd/booster-bitswap/filters"
+	"github.com/filecoin-project/boost/lib/denylist"
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDenylistFilter(t *testing.T) {
+	p := peer.ID("peer1")
+	denied := cid.NewCidV0(testutil.GenerateCid().Hash())
+	deniedInClear := testutil.GenerateCid()
+	allowed := testutil.GenerateCid()
+
+	// The denylist has the same format as the booster-http denylist
+	h := denylist.Hash(denied, nil)
+	list := "# denylist\n//" + hex.EncodeToString(h[:]) + "\n/ipfs/" + deniedInClear.String() + "\n"
+	listFile := filepath.Join(t.TempDir(), "denylist")
+	require.NoError(t, os.WriteFile(listFile, []byte(list), 0644))
+
+	fetch := filters.FetcherForLocation(listFile)
+	updated, stream, err := fetch(time.Time{})
+	require.NoError(t, err)
+	require.True(t, updated)
+	df := filters.NewDenylistFilter()
+	require.NoError(t, df.ParseUpdate(stream))
+	require.NoError(t, stream.Close())
+
+	for _, tc := range []struct {
+		c       cid.Cid
+		fulfill bool
+	}{{denied, false}, {cid.NewCidV1(cid.DagProtobuf, denied.Hash()), false}, {deniedInClear, false}, {allowed, true}} {
+		fulfill, err := df.FulfillRequest(p, tc.c)
+		require.NoError(t, err)
+		require.Equal(t, tc.fulfill, fulfill, tc.c.String())
+	}
+
+	// The file is only read again when it changes
+	updated, _, err = fetch(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	// An invalid denylist is an error, and the previous entries still apply
+	require.NoError(t, os.WriteFile(listFile, []byte("//not-a-hash\n"), 0644))
+	_, stream, err = fetch(time.Time{})
+	require.NoError(t, err)
+	require.Error(t, df.ParseUpdate(stream))
+	require.NoError(t, stream.Close())
+	fulfill, err := df.FulfillRequest(p, deniedInClear)
+	require.NoError(t, err)
+	require.False(t, fulfill)
+}
@@ -59,6 +59,25 @@ func FetcherForHTTPEndpoint(endpoint string, authHeader string) Fetcher {
 	}
 }
 
+// FetcherForFile makes a fetcher that reads from a local file, and only
+// reports an update if the file has been modified since the last fetch
+func FetcherForFile(path string) Fetcher {
+	return func(lastFetchTime time.Time) (bool, io.ReadCloser, error) {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return false, nil, err
+		}
+		if !lastFetchTime.IsZero() && !fi.ModTime().After(lastFetchTime) {
+			return false, nil, nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return false, nil, err
+		}
+		return true, f, nil
+	}
+}
+
 type Handler interface {
 	ParseUpdate(io.Reader) error
 	// FulfillRequest returns true if a request should be fulfilled
@@ -143,8 +162,18 @@ func NewMultiFilter(
 	apiFilterEndpoint string,
 	apiFilterAuth string,
 	BadBitsDenyList []string,
+	denylists []string,
+	peerFilterFile string,
+	peerAddrs PeerAddrsFunc,
 ) *MultiFilter {
 	var filters []FilterDefinition
+	if peerFilterFile != "" {
+		filters = append(filters, FilterDefinition{
+			CacheFile: filepath.Join(cfgDir, "peerfilter.json"),
+			Fetcher:   FetcherForFile(peerFilterFile),
+			Handler:   NewPeerFilter(peerAddrs),
+		})
+	}
 	if len(BadBitsDenyList) > 0 {
 		for i, f := range BadBitsDenyList {
 			filters = append(filters, FilterDefinition{
@@ -154,6 +183,13 @@ func NewMultiFilter(
 			})
 		}
 	}
+	for i, loc := range denylists {
+		filters = append(filters, FilterDefinition{
+			CacheFile: filepath.Join(cfgDir, "linedenylist"+strconv.Itoa(i)+".txt"),
+			Fetcher:   FetcherForLocation(loc),
+			Handler:   NewDenylistFilter(),
+		})
+	}
 	var configFetcher Fetcher
 	if apiFilterEndpoint != "" {
 		configFetcher = FetcherForHTTPEndpoint(apiFilterEndpoint, apiFilterAuth)
@@ -0,0 +1,192 @@
+package filters
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// PeerAddrsFunc returns the remote addresses that a peer is connected from
+type PeerAddrsFunc func(p peer.ID) []ma.Multiaddr
+
+type peerLists struct {
+	allowPeers map[peer.ID]struct{}
+	denyPeers  map[peer.ID]struct{}
+	allowIPs   []*net.IPNet
+	denyIPs    []*net.IPNet
+}
+
+// PeerFilter manages filtering based on a locally configured list of peer IDs
+// and IP addresses that are allowed or denied.
+// A request is denied if the peer ID is in the deny list, or the peer is
+// connected from an IP address in the deny list.
+// If there are any entries in the allow lists, a request is only fulfilled
+// if the peer ID is in the allow list, or the peer is connected from an IP
+// address in the allow list.
+type PeerFilter struct {
+	peerAddrs PeerAddrsFunc
+
+	listsLk sync.RWMutex
+	lists   peerLists
+}
+
+// NewPeerFilter constructs a new peer filter that uses peerAddrs to look up
+// the IP addresses of a peer
+func NewPeerFilter(peerAddrs PeerAddrsFunc) *PeerFilter {
+	return &PeerFilter{
+		peerAddrs: peerAddrs,
+		lists: peerLists{
+			allowPeers: make(map[peer.ID]struct{}),
+			denyPeers:  make(map[peer.ID]struct{}),
+		},
+	}
+}
+
+// FulfillRequest checks if a given peer, or the IP address it is connected
+// from, is in the allow/deny lists and decides whether to fulfill the request
+func (pf *PeerFilter) FulfillRequest(p peer.ID, c cid.Cid) (bool, error) {
+	pf.listsLk.RLock()
+	defer pf.listsLk.RUnlock()
+
+	if _, denied := pf.lists.denyPeers[p]; denied {
+		return false, nil
+	}
+
+	// only look up the peer's addresses if there are IP rules to check
+	var ips []net.IP
+	if len(pf.lists.allowIPs) > 0 || len(pf.lists.denyIPs) > 0 {
+		ips = pf.remoteIPs(p)
+	}
+	if matchesAny(pf.lists.denyIPs, ips) {
+		return false, nil
+	}
+
+	// if there's no allow list, all other peers are allowed
+	if len(pf.lists.allowPeers) == 0 && len(pf.lists.allowIPs) == 0 {
+		return true, nil
+	}
+	if _, allowed := pf.lists.allowPeers[p]; allowed {
+		return true, nil
+	}
+	return matchesAny(pf.lists.allowIPs, ips), nil
+}
+
+func (pf *PeerFilter) remoteIPs(p peer.ID) []net.IP {
+	if pf.peerAddrs == nil {
+		return nil
+	}
+	var ips []net.IP
+	for _, addr := range pf.peerAddrs(p) {
+		ip, err := manet.ToIP(addr)
+		if err != nil {
+			// not an IP address (eg a relay address)
+			continue
+		}
+		ips = append(ips, ip)
+	}
+	return ips
+}
+
+func matchesAny(nets []*net.IPNet, ips []net.IP) bool {
+	for _, n := range nets {
+		for _, ip := range ips {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseIPNet parses an IP address (eg 1.2.3.4) or a CIDR range
+// (eg 10.0.0.0/8)
+func parseIPNet(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, n, err := net.ParseCIDR(s)
+		return n, err
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address '%s'", s)
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bits = 8 * net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// parse a peer filter config to get a new set of allowed/denied peers and IPs
+func (pf *PeerFilter) parsePeerLists(stream io.Reader) (peerLists, error) {
+	type responseType struct {
+		AllowPeers []string `json:"AllowPeers"`
+		DenyPeers  []string `json:"DenyPeers"`
+		AllowIPs   []string `json:"AllowIPs"`
+		DenyIPs    []string `json:"DenyIPs"`
+	}
+
+	var decoded responseType
+	err := json.NewDecoder(stream).Decode(&decoded)
+	if err != nil {
+		return peerLists{}, fmt.Errorf("parsing peer filter: %w", err)
+	}
+
+	parsePeers := func(peerStrings []string) (map[peer.ID]struct{}, error) {
+		peers := make(map[peer.ID]struct{}, len(peerStrings))
+		for _, peerString := range peerStrings {
+			peerID, err := peer.Decode(peerString)
+			if err != nil {
+				return nil, fmt.Errorf("parsing peer filter: %w", err)
+			}
+			peers[peerID] = struct{}{}
+		}
+		return peers, nil
+	}
+	parseIPs := func(ipStrings []string) ([]*net.IPNet, error) {
+		nets := make([]*net.IPNet, 0, len(ipStrings))
+		for _, ipString := range ipStrings {
+			n, err := parseIPNet(ipString)
+			if err != nil {
+				return nil, fmt.Errorf("parsing peer filter: %w", err)
+			}
+			nets = append(nets, n)
+		}
+		return nets, nil
+	}
+
+	var lists peerLists
+	if lists.allowPeers, err = parsePeers(decoded.AllowPeers); err != nil {
+		return peerLists{}, err
+	}
+	if lists.denyPeers, err = parsePeers(decoded.DenyPeers); err != nil {
+		return peerLists{}, err
+	}
+	if lists.allowIPs, err = parseIPs(decoded.AllowIPs); err != nil {
+		return peerLists{}, err
+	}
+	if lists.denyIPs, err = parseIPs(decoded.DenyIPs); err != nil {
+		return peerLists{}, err
+	}
+	return lists, nil
+}
+
+// ParseUpdate parses and updates the peer allow/deny lists from a config file
+func (pf *PeerFilter) ParseUpdate(stream io.Reader) error {
+	lists, err := pf.parsePeerLists(stream)
+	if err != nil {
+		return err
+	}
+	pf.listsLk.Lock()
+	pf.lists = lists
+	pf.listsLk.Unlock()
+	return nil
+}
@@ -0,0 +1,162 @@
+package filters_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/cmd/booster-bitswap/filters"
+	"github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerFilter(t *testing.T) {
+	peer1, err := peer.Decode("Qma9T5YraSnpRDZqRR4krcSJabThc8nwZuJV3LercPHufi")
+	require.NoError(t, err)
+	peer2, err := peer.Decode("QmYyQSo1c1Ym7orWxLYvCrM2EmxFTANf8wXmmE7DWjhx5N")
+	require.NoError(t, err)
+	peer3, err := peer.Decode("QmcfgsJsMtx6qJb74akCw1M24X1zFwgGo11h1cuhwQjtJP")
+	require.NoError(t, err)
+	c, err := cid.Parse("QmWATWQ7fVPP2EFGu71UkfnqhYXDYH566qy47CnJDgvs8u")
+	require.NoError(t, err)
+
+	// peer1 is connected from 10.0.0.1, peer2 from 192.168.1.5 and peer3
+	// has no known addresses (eg it is connected through a proxy)
+	peerAddrs := func(p peer.ID) []ma.Multiaddr {
+		switch p {
+		case peer1:
+			return []ma.Multiaddr{ma.StringCast("/ip4/10.0.0.1/tcp/1234")}
+		case peer2:
+			return []ma.Multiaddr{ma.StringCast("/ip4/192.168.1.5/udp/1234/quic")}
+		}
+		return nil
+	}
+
+	testCases := []struct {
+		name               string
+		config             string
+		expectedParseError error
+		fulfillPeer1       bool
+		fulfillPeer2       bool
+		fulfillPeer3       bool
+	}{
+		{
+			name:         "default behavior",
+			config:       `{}`,
+			fulfillPeer1: true,
+			fulfillPeer2: true,
+			fulfillPeer3: true,
+		},
+		{
+			name: "deny peers",
+			config: `{
+				"DenyPeers": ["Qma9T5YraSnpRDZqRR4krcSJabThc8nwZuJV3LercPHufi"]
+			}`,
+			fulfillPeer1: false,
+			fulfillPeer2: true,
+			fulfillPeer3: true,
+		},
+		{
+			name: "deny IP range",
+			config: `{
+				"DenyIPs": ["192.168.0.0/16"]
+			}`,
+			fulfillPeer1: true,
+			fulfillPeer2: false,
+			fulfillPeer3: true,
+		},
+		{
+			name: "allow peers and IPs",
+			config: `{
+				"AllowPeers": ["QmcfgsJsMtx6qJb74akCw1M24X1zFwgGo11h1cuhwQjtJP"],
+				"AllowIPs": ["10.0.0.1"]
+			}`,
+			fulfillPeer1: true,
+			fulfillPeer2: false,
+			fulfillPeer3: true,
+		},
+		{
+			name: "deny takes precedence over allow",
+			config: `{
+				"AllowIPs": ["10.0.0.0/8", "192.168.1.5"],
+				"DenyPeers": ["QmYyQSo1c1Ym7orWxLYvCrM2EmxFTANf8wXmmE7DWjhx5N"]
+			}`,
+			fulfillPeer1: true,
+			fulfillPeer2: false,
+			fulfillPeer3: false,
+		},
+		{
+			name:               "improperly formatted json",
+			config:             `s{}`,
+			expectedParseError: errors.New("parsing peer filter: invalid character 's' looking for beginning of value"),
+		},
+		{
+			name: "improper peer id",
+			config: `{
+				"AllowPeers": ["apples"]
+			}`,
+			expectedParseError: errors.New("parsing peer filter: failed to parse peer ID: selected encoding not supported"),
+		},
+		{
+			name: "improper IP address",
+			config: `{
+				"DenyIPs": ["10.0.0"]
+			}`,
+			expectedParseError: errors.New("parsing peer filter: invalid IP address '10.0.0'"),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			pf := filters.NewPeerFilter(peerAddrs)
+			err := pf.ParseUpdate(strings.NewReader(testCase.config))
+			if testCase.expectedParseError == nil {
+				require.NoError(t, err)
+				fulfilled, err := pf.FulfillRequest(peer1, c)
+				require.NoError(t, err)
+				require.Equal(t, testCase.fulfillPeer1, fulfilled)
+				fulfilled, err = pf.FulfillRequest(peer2, c)
+				require.NoError(t, err)
+				require.Equal(t, testCase.fulfillPeer2, fulfilled)
+				fulfilled, err = pf.FulfillRequest(peer3, c)
+				require.NoError(t, err)
+				require.Equal(t, testCase.fulfillPeer3, fulfilled)
+			} else {
+				require.EqualError(t, err, testCase.expectedParseError.Error())
+			}
+		})
+	}
+}
+
+func TestFetcherForFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peerfilter.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"DenyPeers": []}`), 0600))
+	fetcher := filters.FetcherForFile(path)
+
+	// the first fetch always reads the file
+	updated, stream, err := fetcher(time.Time{})
+	require.NoError(t, err)
+	require.True(t, updated)
+	data, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+	require.Equal(t, `{"DenyPeers": []}`, string(data))
+
+	// the file hasn't changed since the last fetch
+	lastFetch := time.Now().Add(time.Minute)
+	updated, _, err = fetcher(lastFetch)
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	// the file is read again once it has been modified
+	require.NoError(t, os.Chtimes(path, lastFetch.Add(time.Second), lastFetch.Add(time.Second)))
+	updated, stream, err = fetcher(lastFetch)
+	require.NoError(t, err)
+	require.True(t, updated)
+	require.NoError(t, stream.Close())
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"crypto/rand"
+	"crypto/tls"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -16,6 +17,8 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
 	quic "github.com/libp2p/go-libp2p/p2p/transport/quic"
 	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
+	"github.com/libp2p/go-libp2p/p2p/transport/websocket"
+	webtransport "github.com/libp2p/go-libp2p/p2p/transport/webtransport"
 	"github.com/mitchellh/go-homedir"
 	"github.com/urfave/cli/v2"
 )
@@ -42,23 +45,55 @@ func configureRepo(cfgDir string, createIfNotExist bool) (peer.ID, crypto.PrivKe
 	return selfPid, peerkey, nil
 }
 
-func setupHost(cfgDir string, port int) (host.Host, error) {
+// hostOptions are the optional transports that booster-bitswap listens on,
+// in addition to TCP and QUIC
+type hostOptions struct {
+	// The port to listen for websocket connections on (0 to disable)
+	WebsocketPort int
+	// If set, websocket connections are secured with TLS (wss)
+	WebsocketTLS *tls.Config
+	// The UDP port to listen for WebTransport connections on (0 to disable)
+	WebTransportPort int
+}
+
+func setupHost(cfgDir string, port int, opts hostOptions) (host.Host, error) {
 	_, peerKey, err := configureRepo(cfgDir, false)
 	if err != nil {
 		return nil, err
 	}
-	return libp2p.New(
-		libp2p.ListenAddrStrings(
-			fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", port),
-			fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic", port),
-		),
+
+	listenAddrs := []string{
+		fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", port),
+		fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic", port),
+	}
+	libp2pOpts := []libp2p.Option{
 		libp2p.Transport(tcp.NewTCPTransport),
 		libp2p.Transport(quic.NewTransport),
+	}
+
+	// Browsers can connect over secure websockets or WebTransport
+	if opts.WebsocketPort != 0 {
+		if opts.WebsocketTLS != nil {
+			listenAddrs = append(listenAddrs, fmt.Sprintf("/ip4/0.0.0.0/tcp/%d/wss", opts.WebsocketPort))
+			libp2pOpts = append(libp2pOpts, libp2p.Transport(websocket.New, websocket.WithTLSConfig(opts.WebsocketTLS)))
+		} else {
+			listenAddrs = append(listenAddrs, fmt.Sprintf("/ip4/0.0.0.0/tcp/%d/ws", opts.WebsocketPort))
+			libp2pOpts = append(libp2pOpts, libp2p.Transport(websocket.New))
+		}
+	}
+	if opts.WebTransportPort != 0 {
+		listenAddrs = append(listenAddrs, fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic/webtransport", opts.WebTransportPort))
+		libp2pOpts = append(libp2pOpts, libp2p.Transport(webtransport.New))
+	}
+
+	libp2pOpts = append(libp2pOpts,
+		libp2p.ListenAddrStrings(listenAddrs...),
 		libp2p.Muxer("/mplex/6.7.0", mplex.DefaultTransport),
 		libp2p.Muxer("/yamux/1.0.0", yamux.DefaultTransport),
 		libp2p.Identity(peerKey),
 		libp2p.ResourceManager(network.NullResourceManager),
 	)
+	return libp2p.New(libp2pOpts...)
 }
 
 func loadPeerKey(cfgDir string, createIfNotExists bool) (crypto.PrivKey, error) {
@@ -0,0 +1,206 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/metrics"
+	bsmsg "github.com/ipfs/go-libipfs/bitswap/message"
+	bsnetwork "github.com/ipfs/go-libipfs/bitswap/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"golang.org/x/time/rate"
+)
+
+// Peers that haven't been sent a message for this long are forgotten by the
+// limiter
+const peerExpiry = 10 * time.Minute
+
+// Options are the limits on the blocks sent by bitswap.
+// A zero value means no limit.
+type Options struct {
+	// The maximum number of blocks per second sent to each peer
+	PeerBlocksPerSecond float64
+	// The number of blocks that can be sent to a peer in a burst, before the
+	// PeerBlocksPerSecond limit applies
+	PeerBlockBurst int
+	// The maximum number of bytes per second of block data sent across
+	// all peers
+	BytesPerSecond int64
+}
+
+// Enabled returns true if any limit is set
+func (o Options) Enabled() bool {
+	return o.PeerBlocksPerSecond > 0 || o.BytesPerSecond > 0
+}
+
+// Network wraps a bitswap network, and delays outgoing messages so that the
+// blocks sent to each peer, and the total bandwidth, stay within the limits.
+// The bitswap server sends messages synchronously, so delaying a message
+// applies back-pressure to the peer's queue of wants, rather than refusing
+// to serve them.
+type Network struct {
+	bsnetwork.BitSwapNetwork
+	opts Options
+
+	bandwidth *rate.Limiter
+
+	lk        sync.Mutex
+	peers     map[peer.ID]*limitedPeer
+	throttled int
+	lastSweep time.Time
+}
+
+type limitedPeer struct {
+	blocks    *rate.Limiter
+	lastSeen  time.Time
+	throttled int
+}
+
+var _ bsnetwork.BitSwapNetwork = (*Network)(nil)
+
+// NewNetwork returns a bitswap network that applies the limits to the
+// messages sent over net
+func NewNetwork(net bsnetwork.BitSwapNetwork, opts Options) *Network {
+	if opts.PeerBlockBurst <= 0 {
+		opts.PeerBlockBurst = 1
+	}
+	n := &Network{
+		BitSwapNetwork: net,
+		opts:           opts,
+		peers:          make(map[peer.ID]*limitedPeer),
+		lastSweep:      time.Now(),
+	}
+	if opts.BytesPerSecond > 0 {
+		// Allow up to one second's worth of data in a burst
+		n.bandwidth = rate.NewLimiter(rate.Limit(opts.BytesPerSecond), int(opts.BytesPerSecond))
+	}
+	return n
+}
+
+// SendMessage waits until the message is within the limits, then sends it
+func (n *Network) SendMessage(ctx context.Context, p peer.ID, msg bsmsg.BitSwapMessage) error {
+	blks := msg.Blocks()
+	if len(blks) == 0 {
+		// Only limit messages with blocks: HAVEs and DONT_HAVEs are cheap
+		return n.BitSwapNetwork.SendMessage(ctx, p, msg)
+	}
+
+	lp := n.peer(p)
+	if lp.blocks != nil {
+		if err := n.wait(ctx, lp, lp.blocks, len(blks), "peer-rate"); err != nil {
+			return err
+		}
+	}
+	if n.bandwidth != nil {
+		var size int
+		for _, b := range blks {
+			size += len(b.RawData())
+		}
+		if err := n.wait(ctx, lp, n.bandwidth, size, "bandwidth"); err != nil {
+			return err
+		}
+	}
+
+	return n.BitSwapNetwork.SendMessage(ctx, p, msg)
+}
+
+func (n *Network) peer(p peer.ID) *limitedPeer {
+	n.lk.Lock()
+	defer n.lk.Unlock()
+
+	// Periodically remove peers that haven't been seen for a while so that
+	// the map doesn't grow without bound
+	now := time.Now()
+	if now.Sub(n.lastSweep) > time.Minute {
+		for k, lp := range n.peers {
+			if lp.throttled == 0 && now.Sub(lp.lastSeen) > peerExpiry {
+				delete(n.peers, k)
+			}
+		}
+		n.lastSweep = now
+	}
+
+	lp, ok := n.peers[p]
+	if !ok {
+		lp = &limitedPeer{}
+		if n.opts.PeerBlocksPerSecond > 0 {
+			lp.blocks = rate.NewLimiter(rate.Limit(n.opts.PeerBlocksPerSecond), n.opts.PeerBlockBurst)
+		}
+		n.peers[p] = lp
+	}
+	lp.lastSeen = now
+	return lp
+}
+
+// wait waits until count tokens are available from the limiter, in chunks
+// of at most the limiter's burst size
+func (n *Network) wait(ctx context.Context, lp *limitedPeer, lim *rate.Limiter, count int, reason string) error {
+	throttled := false
+	defer func() {
+		if throttled {
+			n.setThrottled(lp, false)
+		}
+	}()
+
+	for count > 0 {
+		chunk := count
+		if chunk > lim.Burst() {
+			chunk = lim.Burst()
+		}
+		count -= chunk
+
+		res := lim.ReserveN(time.Now(), chunk)
+		delay := res.Delay()
+		if delay == 0 {
+			continue
+		}
+
+		if !throttled {
+			throttled = true
+			n.setThrottled(lp, true)
+			ctx, _ := tag.New(ctx, tag.Upsert(metrics.BitswapThrottleReason, reason))
+			stats.Record(ctx, metrics.BitswapThrottledCount.M(1))
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			res.Cancel()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return nil
+}
+
+// setThrottled keeps track of the number of peers with a message that is
+// currently being delayed
+func (n *Network) setThrottled(lp *limitedPeer, throttled bool) {
+	n.lk.Lock()
+	defer n.lk.Unlock()
+
+	if throttled {
+		lp.throttled++
+		if lp.throttled == 1 {
+			n.throttled++
+		}
+	} else {
+		lp.throttled--
+		if lp.throttled == 0 {
+			n.throttled--
+		}
+	}
+	stats.Record(context.Background(), metrics.BitswapThrottledPeers.M(int64(n.throttled)))
+}
+
+// ThrottledPeers returns the number of peers that currently have a message
+// that is being delayed by the limits
+func (n *Network) ThrottledPeers() int {
+	n.lk.Lock()
+	defer n.lk.Unlock()
+	return n.throttled
+}
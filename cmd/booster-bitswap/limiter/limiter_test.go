@@ -0,0 +1,99 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/testutil"
+	bsmsg "github.com/ipfs/go-libipfs/bitswap/message"
+	bsnetwork "github.com/ipfs/go-libipfs/bitswap/network"
+	"github.com/ipfs/go-libipfs/blocks"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeNetwork struct {
+	bsnetwork.BitSwapNetwork
+
+	lk   sync.Mutex
+	sent map[peer.ID]int
+}
+
+func (n *fakeNetwork) SendMessage(ctx context.Context, p peer.ID, msg bsmsg.BitSwapMessage) error {
+	n.lk.Lock()
+	defer n.lk.Unlock()
+	n.sent[p] += len(msg.Blocks())
+	return nil
+}
+
+func blocksMessage(count int, size int) bsmsg.BitSwapMessage {
+	msg := bsmsg.New(false)
+	for i := 0; i < count; i++ {
+		data := make([]byte, size)
+		copy(data, testutil.GenerateCid().Bytes())
+		msg.AddBlock(blocks.NewBlock(data))
+	}
+	return msg
+}
+
+func TestPeerBlockRate(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeNetwork{sent: make(map[peer.ID]int)}
+	n := NewNetwork(fake, Options{PeerBlocksPerSecond: 20, PeerBlockBurst: 5})
+	peer1 := peer.ID("peer1")
+	peer2 := peer.ID("peer2")
+
+	// A burst of blocks is sent immediately
+	start := time.Now()
+	require.NoError(t, n.SendMessage(ctx, peer1, blocksMessage(5, 16)))
+	require.Less(t, time.Since(start), 25*time.Millisecond)
+
+	// Further blocks to the same peer are delayed, while another peer
+	// is not affected
+	go func() {
+		_ = n.SendMessage(ctx, peer1, blocksMessage(2, 16))
+	}()
+	require.Eventually(t, func() bool { return n.ThrottledPeers() == 1 }, time.Second, time.Millisecond)
+	start = time.Now()
+	require.NoError(t, n.SendMessage(ctx, peer2, blocksMessage(5, 16)))
+	require.Less(t, time.Since(start), 25*time.Millisecond)
+
+	require.Eventually(t, func() bool { return n.ThrottledPeers() == 0 }, time.Second, time.Millisecond)
+	fake.lk.Lock()
+	require.Equal(t, 7, fake.sent[peer1])
+	require.Equal(t, 5, fake.sent[peer2])
+	fake.lk.Unlock()
+
+	// Messages without blocks are never delayed
+	start = time.Now()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, n.SendMessage(ctx, peer1, bsmsg.New(false)))
+	}
+	require.Less(t, time.Since(start), 25*time.Millisecond)
+}
+
+func TestBandwidth(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeNetwork{sent: make(map[peer.ID]int)}
+	n := NewNetwork(fake, Options{BytesPerSecond: 10_000})
+
+	// The first second's worth of data is sent immediately, and the limit
+	// is shared by all peers
+	start := time.Now()
+	require.NoError(t, n.SendMessage(ctx, peer.ID("peer1"), blocksMessage(5, 1000)))
+	require.NoError(t, n.SendMessage(ctx, peer.ID("peer2"), blocksMessage(5, 1000)))
+	require.Less(t, time.Since(start), 50*time.Millisecond)
+
+	// Sending another 2,000 bytes takes about 200ms
+	start = time.Now()
+	require.NoError(t, n.SendMessage(ctx, peer.ID("peer3"), blocksMessage(2, 1000)))
+	require.Greater(t, time.Since(start), 150*time.Millisecond)
+
+	// A message that is waiting can be cancelled
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, n.SendMessage(ctx, peer.ID("peer1"), blocksMessage(1, 5000)), context.DeadlineExceeded)
+	require.Equal(t, 0, n.ThrottledPeers())
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+
+	"github.com/filecoin-project/boost/retrievalmarket/quota"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// quotaFilter applies the per-client retrieval quotas configured in boostd
+// to each block requested by a peer, after the other filters.
+// Bitswap responses can't be throttled, so a peer that has exceeded its
+// quota is refused whether the quota action is block or throttle.
+type quotaFilter struct {
+	Filter
+	cache *quota.Cache
+}
+
+func (f *quotaFilter) FulfillRequest(p peer.ID, c cid.Cid) (bool, error) {
+	fulfill, err := f.Filter.FulfillRequest(p, c)
+	if err != nil || !fulfill {
+		return fulfill, err
+	}
+
+	st, err := f.cache.Status(context.Background(), p.String())
+	if err != nil {
+		return false, err
+	}
+	return st.State == quota.StateOK, nil
+}
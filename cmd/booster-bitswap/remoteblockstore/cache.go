@@ -0,0 +1,62 @@
+package remoteblockstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/boost/metrics"
+	lru "github.com/hnlq715/golang-lru"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipfs/go-libipfs/blocks"
+	"go.opencensus.io/stats"
+)
+
+// CachingBlockstore keeps the most recently fetched blocks in memory, so
+// that popular blocks that are requested by many peers don't have to be
+// fetched from the pieces on the provider each time
+type CachingBlockstore struct {
+	blockstore.Blockstore
+	cache *lru.Cache
+}
+
+var _ blockstore.Blockstore = (*CachingBlockstore)(nil)
+
+// NewCachingBlockstore wraps the blockstore with a cache of up to size
+// blocks
+func NewCachingBlockstore(bs blockstore.Blockstore, size int) (*CachingBlockstore, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, fmt.Errorf("creating block cache: %w", err)
+	}
+	return &CachingBlockstore{Blockstore: bs, cache: cache}, nil
+}
+
+func (cb *CachingBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if v, ok := cb.cache.Get(c); ok {
+		stats.Record(ctx, metrics.BitswapBlockCacheHitCount.M(1))
+		return v.(blocks.Block), nil
+	}
+	stats.Record(ctx, metrics.BitswapBlockCacheMissCount.M(1))
+
+	blk, err := cb.Blockstore.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	cb.cache.Add(c, blk)
+	return blk, nil
+}
+
+func (cb *CachingBlockstore) Has(ctx context.Context, c cid.Cid) (bool, error) {
+	if cb.cache.Contains(c) {
+		return true, nil
+	}
+	return cb.Blockstore.Has(ctx, c)
+}
+
+func (cb *CachingBlockstore) GetSize(ctx context.Context, c cid.Cid) (int, error) {
+	if v, ok := cb.cache.Peek(c); ok {
+		return len(v.(blocks.Block).RawData()), nil
+	}
+	return cb.Blockstore.GetSize(ctx, c)
+}
@@ -0,0 +1,67 @@
+package remoteblockstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipfs/go-libipfs/blocks"
+	"github.com/stretchr/testify/require"
+)
+
+type countingBlockstore struct {
+	blockstore.Blockstore
+	gets int
+}
+
+func (cb *countingBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	cb.gets++
+	return cb.Blockstore.Get(ctx, c)
+}
+
+func TestCachingBlockstore(t *testing.T) {
+	ctx := context.Background()
+	bs := &countingBlockstore{Blockstore: blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))}
+	cb, err := NewCachingBlockstore(bs, 2)
+	require.NoError(t, err)
+
+	var blks []blocks.Block
+	for i := 0; i < 3; i++ {
+		blk := blocks.NewBlock(testutil.GenerateCid().Bytes())
+		require.NoError(t, bs.Put(ctx, blk))
+		blks = append(blks, blk)
+	}
+
+	// The first Get fetches the block from the underlying blockstore, the
+	// next is served from the cache
+	for i := 0; i < 2; i++ {
+		blk, err := cb.Get(ctx, blks[0].Cid())
+		require.NoError(t, err)
+		require.Equal(t, blks[0].RawData(), blk.RawData())
+	}
+	require.Equal(t, 1, bs.gets)
+
+	size, err := cb.GetSize(ctx, blks[0].Cid())
+	require.NoError(t, err)
+	require.Equal(t, len(blks[0].RawData()), size)
+
+	// When the cache is full the least recently used block is evicted
+	_, err = cb.Get(ctx, blks[1].Cid())
+	require.NoError(t, err)
+	_, err = cb.Get(ctx, blks[2].Cid())
+	require.NoError(t, err)
+	_, err = cb.Get(ctx, blks[0].Cid())
+	require.NoError(t, err)
+	require.Equal(t, 4, bs.gets)
+
+	// Errors are not cached
+	_, err = cb.Get(ctx, testutil.GenerateCid())
+	require.Error(t, err)
+	has, err := cb.Has(ctx, blks[2].Cid())
+	require.NoError(t, err)
+	require.True(t, has)
+}
@@ -0,0 +1,140 @@
+package remoteblockstore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/metrics"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-libipfs/blocks"
+	"github.com/ipfs/go-merkledag"
+	"go.opencensus.io/stats"
+)
+
+// The maximum amount of time to spend prefetching a block
+const prefetchTimeout = 30 * time.Second
+
+// PrefetchingBlockstore reads ahead when a peer fetches a block from a DAG:
+// it fetches the blocks that the block links to into the cache, so that
+// when the peer traverses the DAG and requests those blocks they are
+// served from memory, instead of being read from the piece one at a time.
+//
+// Only the links of dag-pb (eg UnixFS) blocks are prefetched. When a
+// prefetched block is requested, its own links are prefetched, so the
+// read-ahead follows the peer down the DAG.
+type PrefetchingBlockstore struct {
+	*CachingBlockstore
+
+	maxLinks int
+	// Limits the number of blocks that are being prefetched at once
+	workers chan struct{}
+
+	lk       sync.Mutex
+	inflight map[cid.Cid]chan struct{}
+}
+
+// NewPrefetchingBlockstore prefetches up to maxLinks of the links of each
+// block that is fetched, with up to concurrency fetches at once
+func NewPrefetchingBlockstore(cb *CachingBlockstore, maxLinks int, concurrency int) *PrefetchingBlockstore {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &PrefetchingBlockstore{
+		CachingBlockstore: cb,
+		maxLinks:          maxLinks,
+		workers:           make(chan struct{}, concurrency),
+		inflight:          make(map[cid.Cid]chan struct{}),
+	}
+}
+
+func (pb *PrefetchingBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	// If the block is being prefetched, wait for the prefetch to complete
+	// rather than fetching it again
+	pb.lk.Lock()
+	done, ok := pb.inflight[c]
+	pb.lk.Unlock()
+	if ok {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-done:
+		}
+	}
+
+	blk, err := pb.CachingBlockstore.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	pb.prefetchLinks(blk)
+	return blk, nil
+}
+
+// prefetchLinks starts fetching the links of the block in the background.
+// If all the prefetch workers are busy, the remaining links are skipped
+// rather than queued, so that prefetching never takes capacity away from
+// requests for blocks that peers actually want.
+func (pb *PrefetchingBlockstore) prefetchLinks(blk blocks.Block) {
+	if blk.Cid().Prefix().Codec != cid.DagProtobuf {
+		return
+	}
+	nd, err := merkledag.DecodeProtobuf(blk.RawData())
+	if err != nil {
+		log.Debugw("decoding block to prefetch links", "cid", blk.Cid(), "err", err)
+		return
+	}
+
+	links := nd.Links()
+	if len(links) > pb.maxLinks {
+		links = links[:pb.maxLinks]
+	}
+	for _, l := range links {
+		if pb.cache.Contains(l.Cid) || !pb.startPrefetch(l.Cid) {
+			continue
+		}
+
+		select {
+		case pb.workers <- struct{}{}:
+		default:
+			// All workers are busy
+			pb.endPrefetch(l.Cid)
+			return
+		}
+
+		go func(c cid.Cid) {
+			defer func() { <-pb.workers }()
+			defer pb.endPrefetch(c)
+
+			ctx, cancel := context.WithTimeout(context.Background(), prefetchTimeout)
+			defer cancel()
+			linked, err := pb.CachingBlockstore.Blockstore.Get(ctx, c)
+			if err != nil {
+				log.Debugw("prefetching block", "cid", c, "err", err)
+				return
+			}
+			pb.cache.Add(c, linked)
+			stats.Record(ctx, metrics.BitswapPrefetchCount.M(1))
+		}(l.Cid)
+	}
+}
+
+// startPrefetch marks the block as being prefetched. It returns false if
+// the block is already being prefetched.
+func (pb *PrefetchingBlockstore) startPrefetch(c cid.Cid) bool {
+	pb.lk.Lock()
+	defer pb.lk.Unlock()
+
+	if _, ok := pb.inflight[c]; ok {
+		return false
+	}
+	pb.inflight[c] = make(chan struct{})
+	return true
+}
+
+func (pb *PrefetchingBlockstore) endPrefetch(c cid.Cid) {
+	pb.lk.Lock()
+	defer pb.lk.Unlock()
+
+	close(pb.inflight[c])
+	delete(pb.inflight, c)
+}
@@ -0,0 +1,86 @@
+package remoteblockstore
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	"github.com/ipfs/go-libipfs/blocks"
+	"github.com/ipfs/go-merkledag"
+	"github.com/stretchr/testify/require"
+)
+
+type lockedCountingBlockstore struct {
+	blockstore.Blockstore
+
+	lk   sync.Mutex
+	gets map[cid.Cid]int
+}
+
+func (cb *lockedCountingBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	cb.lk.Lock()
+	cb.gets[c]++
+	cb.lk.Unlock()
+	return cb.Blockstore.Get(ctx, c)
+}
+
+func TestPrefetchingBlockstore(t *testing.T) {
+	ctx := context.Background()
+	bs := &lockedCountingBlockstore{
+		Blockstore: blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore())),
+		gets:       make(map[cid.Cid]int),
+	}
+
+	// Create a UnixFS file with a root block that links to the leaves
+	dserv := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+	filePath, err := testutil.CreateRandomFile(t.TempDir(), 1, 8*1024)
+	require.NoError(t, err)
+	root, err := testutil.WriteUnixfsDAGTo(filePath, dserv, 1024, 16)
+	require.NoError(t, err)
+	rootNd, err := dserv.Get(ctx, root)
+	require.NoError(t, err)
+	links := rootNd.Links()
+	require.Len(t, links, 8)
+
+	cb, err := NewCachingBlockstore(bs, 100)
+	require.NoError(t, err)
+	pb := NewPrefetchingBlockstore(cb, 4, 2)
+
+	// Fetching the root block prefetches some of the first four links
+	// (depending on how many workers are free)
+	_, err = pb.Get(ctx, root)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		pb.lk.Lock()
+		defer pb.lk.Unlock()
+		return len(pb.inflight) == 0
+	}, time.Second, time.Millisecond)
+	prefetched := 0
+	for _, l := range links {
+		if cb.cache.Contains(l.Cid) {
+			prefetched++
+		}
+	}
+	require.GreaterOrEqual(t, prefetched, 1)
+	require.LessOrEqual(t, prefetched, 4)
+
+	// The prefetched blocks are served from the cache
+	for _, l := range links {
+		blk, err := pb.Get(ctx, l.Cid)
+		require.NoError(t, err)
+		require.Equal(t, l.Cid, blk.Cid())
+	}
+	bs.lk.Lock()
+	defer bs.lk.Unlock()
+	for _, l := range links {
+		require.Equal(t, 1, bs.gets[l.Cid])
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/filecoin-project/boost/metrics"
 	"github.com/filecoin-project/boostd-data/shared/tracing"
@@ -45,7 +46,9 @@ func (ro *RemoteBlockstore) Get(ctx context.Context, c cid.Cid) (b blocks.Block,
 	stats.Record(ctx, metrics.BitswapRblsGetRequestCount.M(1))
 
 	log.Debugw("Get", "cid", c)
+	start := time.Now()
 	data, err := ro.api.BlockstoreGet(ctx, c)
+	stats.Record(ctx, metrics.BitswapRblsGetDuration.M(metrics.SinceInMilliseconds(start)))
 	err = normalizeError(err)
 	log.Debugw("Get response", "cid", c, "size", len(data), "error", err)
 	if err != nil {
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/filecoin-project/boost/retrievalmarket/rtvllog"
+	"github.com/ipfs/go-cid"
+	bsmsg "github.com/ipfs/go-libipfs/bitswap/message"
+	bsnetwork "github.com/ipfs/go-libipfs/bitswap/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// retrievalLogNetwork reports each block sent to a peer to boostd, so that
+// it is recorded in the retrievals DB alongside graphsync and HTTP
+// retrievals
+type retrievalLogNetwork struct {
+	bsnetwork.BitSwapNetwork
+	reporter *rtvllog.Reporter
+}
+
+func (n *retrievalLogNetwork) SendMessage(ctx context.Context, p peer.ID, msg bsmsg.BitSwapMessage) error {
+	start := time.Now()
+	err := n.BitSwapNetwork.SendMessage(ctx, p, msg)
+	duration := time.Since(start)
+
+	for _, b := range msg.Blocks() {
+		a := rtvllog.RetrievalAttempt{
+			CreatedAt:  start,
+			Protocol:   rtvllog.ProtocolBitswap,
+			ClientID:   p.String(),
+			PayloadCID: b.Cid(),
+			Duration:   duration,
+			Result:     rtvllog.ResultSuccess,
+		}
+		if err != nil {
+			a.Result = rtvllog.ResultFailure
+			a.Message = err.Error()
+		} else {
+			a.BytesSent = uint64(len(b.RawData()))
+		}
+		n.reporter.Record(a)
+	}
+	return err
+}
+
+// recordRefused reports a block request that was refused by the filters
+func recordRefused(reporter *rtvllog.Reporter, p peer.ID, c cid.Cid, err error) {
+	a := rtvllog.RetrievalAttempt{
+		CreatedAt:  time.Now(),
+		Protocol:   rtvllog.ProtocolBitswap,
+		ClientID:   p.String(),
+		PayloadCID: c,
+		Result:     rtvllog.ResultRejected,
+		Message:    "refused by filter",
+	}
+	if err != nil {
+		a.Result = rtvllog.ResultFailure
+		a.Message = "running bitswap filter: " + err.Error()
+	}
+	reporter.Record(a)
+}
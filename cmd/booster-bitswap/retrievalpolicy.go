@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/filecoin-project/boost/metrics"
+	"github.com/filecoin-project/boost/retrievalpolicy"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// policyFilter applies the retrieval policy configured in boostd to each
+// block requested by a peer, after the other filters.
+// A client is identified by its peer ID.
+type policyFilter struct {
+	Filter
+	engine *retrievalpolicy.Engine
+	pieces *retrievalpolicy.PieceLookup
+}
+
+func (f *policyFilter) FulfillRequest(p peer.ID, c cid.Cid) (bool, error) {
+	fulfill, err := f.Filter.FulfillRequest(p, c)
+	if err != nil || !fulfill {
+		return fulfill, err
+	}
+
+	err = f.pieces.Check(context.Background(), f.engine, p.String(), c)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, retrievalpolicy.ErrNotAllowed) {
+		ctx, _ := tag.New(context.Background(), tag.Upsert(metrics.RetrievalPolicyRejectReason, retrievalpolicy.RejectReason(err)))
+		stats.Record(ctx, metrics.BitswapPolicyRejectedCount.M(1))
+		return false, nil
+	}
+	return false, err
+}
@@ -2,25 +2,41 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
 	"strings"
+	"time"
 
+	"github.com/docker/go-units"
 	"github.com/filecoin-project/boost/api"
 	bclient "github.com/filecoin-project/boost/api/client"
 	cliutil "github.com/filecoin-project/boost/cli/util"
 	"github.com/filecoin-project/boost/cmd/booster-bitswap/filters"
+	"github.com/filecoin-project/boost/cmd/booster-bitswap/limiter"
 	"github.com/filecoin-project/boost/cmd/booster-bitswap/remoteblockstore"
 	"github.com/filecoin-project/boost/metrics"
+	"github.com/filecoin-project/boost/retrievalmarket/quota"
+	"github.com/filecoin-project/boost/retrievalmarket/rtvllog"
+	"github.com/filecoin-project/boost/retrievalpolicy"
 	"github.com/filecoin-project/boostd-data/shared/tracing"
 	"github.com/filecoin-project/go-jsonrpc"
 	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/mitchellh/go-homedir"
+	"github.com/multiformats/go-multiaddr"
 	"github.com/urfave/cli/v2"
 )
 
+// The number of blocks for which to cache the pieces containing the block,
+// when applying the retrieval policy
+const retrievalPolicyLookupCacheSize = 4096
+
+// The number of peers for which to cache the retrieval quota status
+const retrievalQuotaCacheSize = 4096
+
 var runCmd = &cli.Command{
 	Name:   "run",
 	Usage:  "Start a booster-bitswap process",
@@ -40,6 +56,22 @@ var runCmd = &cli.Command{
 			Usage: "the port to listen for bitswap requests on",
 			Value: 8888,
 		},
+		&cli.IntFlag{
+			Name:  "ws-port",
+			Usage: "the port to listen for bitswap requests over websockets on, eg from browsers (0 to disable)",
+		},
+		&cli.StringFlag{
+			Name:  "wss-cert-file",
+			Usage: "the TLS certificate file for secure websockets (wss): browsers on https pages can only connect over wss",
+		},
+		&cli.StringFlag{
+			Name:  "wss-key-file",
+			Usage: "the TLS key file for secure websockets (wss)",
+		},
+		&cli.IntFlag{
+			Name:  "webtransport-port",
+			Usage: "the UDP port to listen for bitswap requests over WebTransport on, eg from browsers (0 to disable). Must be different to the QUIC port",
+		},
 		&cli.UintFlag{
 			Name:  "metrics-port",
 			Usage: "the http port to serve prometheus metrics on",
@@ -54,6 +86,11 @@ var runCmd = &cli.Command{
 			Name:  "proxy",
 			Usage: "the multiaddr of the libp2p proxy that this node connects through",
 		},
+		&cli.BoolFlag{
+			Name: "proxy-boostd",
+			Usage: "connect through the boostd libp2p host as a proxy, so that bitswap clients connect to the boostd peer id " +
+				"(the boostd address is fetched from the boost API; boostd must be configured with this node's peer id as Dealmaking.BitswapPeerID)",
+		},
 		&cli.BoolFlag{
 			Name:  "tracing",
 			Usage: "enables tracing of booster-bitswap calls",
@@ -77,6 +114,55 @@ var runCmd = &cli.Command{
 			Usage: "the endpoints for fetching one or more custom BadBits list instead of the default one at https://badbits.dwebops.pub/denylist.json",
 			Value: cli.NewStringSlice("https://badbits.dwebops.pub/denylist.json"),
 		},
+		&cli.StringSliceFlag{
+			Name:  "denylist",
+			Usage: "refuse wants for blocks in this denylist, in the same badbits line format as booster-http --denylist (a file or an http(s) URL, may be repeated)",
+		},
+		&cli.StringFlag{
+			Name:  "peer-filter-file",
+			Usage: "the path to a JSON file with lists of peer IDs and IP addresses that are allowed or denied bitswap retrievals (reloaded when the file changes)",
+		},
+		&cli.BoolFlag{
+			Name:  "retrieval-policy",
+			Usage: "apply the retrieval policy configured in boostd (see boostd retrieval-policy), identifying clients by peer ID",
+		},
+		&cli.DurationFlag{
+			Name:  "retrieval-policy-refresh-interval",
+			Usage: "how often to fetch changes to the retrieval policy from boostd",
+			Value: time.Minute,
+		},
+		&cli.BoolFlag{
+			Name:  "retrieval-quotas",
+			Usage: "refuse block requests from peers that have exceeded the retrieval quotas configured in boostd (requires --retrieval-log so that blocks count towards the quotas)",
+		},
+		&cli.DurationFlag{
+			Name:  "retrieval-quotas-refresh-interval",
+			Usage: "how long to cache each peer's quota status fetched from boostd",
+			Value: 30 * time.Second,
+		},
+		&cli.BoolFlag{
+			Name:  "retrieval-log",
+			Usage: "record each block request in the boostd retrievals database, alongside graphsync and HTTP retrievals",
+		},
+		&cli.DurationFlag{
+			Name:  "retrieval-log-interval",
+			Usage: "how often to send the records of block requests to boostd",
+			Value: 10 * time.Second,
+		},
+		&cli.IntFlag{
+			Name:  "block-cache-size",
+			Usage: "the number of recently served blocks to keep in memory, so that popular blocks are not fetched from the pieces for each request (0 to disable the cache)",
+			Value: 256,
+		},
+		&cli.IntFlag{
+			Name:  "prefetch-links",
+			Usage: "when a block is fetched, prefetch up to this many of the blocks it links to into the block cache, to speed up DAG traversals (0 to disable prefetching)",
+		},
+		&cli.IntFlag{
+			Name:  "prefetch-concurrency",
+			Usage: "the maximum number of blocks that are prefetched at the same time",
+			Value: 16,
+		},
 		&cli.IntFlag{
 			Name:  "engine-blockstore-worker-count",
 			Usage: "number of threads for blockstore operations. Used to throttle the number of concurrent requests to the block store",
@@ -102,6 +188,23 @@ var runCmd = &cli.Command{
 			Usage: "Number of threads (goroutines) sending outgoing messages. Throttles the number of concurrent send operations",
 			Value: 128,
 		},
+		&cli.UintFlag{
+			Name:  "max-wants-per-peer",
+			Usage: "maximum number of wants queued for each peer, additional wants are ignored (0 means the bitswap default of 1024)",
+		},
+		&cli.Float64Flag{
+			Name:  "peer-blocks-per-second",
+			Usage: "maximum number of blocks per second sent to each peer (0 means no limit)",
+		},
+		&cli.IntFlag{
+			Name:  "peer-block-burst",
+			Usage: "number of blocks that can be sent to a peer in a burst before the peer-blocks-per-second limit applies",
+			Value: 100,
+		},
+		&cli.StringFlag{
+			Name:  "max-bandwidth",
+			Usage: "the maximum bandwidth per second used to send blocks across all peers, eg 100MiB (empty for no limit)",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		if cctx.Bool("pprof") {
@@ -138,26 +241,100 @@ var runCmd = &cli.Command{
 		defer bcloser()
 
 		remoteStore := remoteblockstore.NewRemoteBlockstore(bapi)
+		if cacheSize := cctx.Int("block-cache-size"); cacheSize > 0 {
+			cachingStore, err := remoteblockstore.NewCachingBlockstore(remoteStore, cacheSize)
+			if err != nil {
+				return err
+			}
+			remoteStore = cachingStore
+
+			if prefetchLinks := cctx.Int("prefetch-links"); prefetchLinks > 0 {
+				log.Infow("prefetching linked blocks", "links", prefetchLinks, "concurrency", cctx.Int("prefetch-concurrency"))
+				remoteStore = remoteblockstore.NewPrefetchingBlockstore(cachingStore, prefetchLinks, cctx.Int("prefetch-concurrency"))
+			}
+		} else if cctx.Int("prefetch-links") > 0 {
+			return fmt.Errorf("prefetch-links requires the block cache to be enabled (block-cache-size must be greater than zero)")
+		}
 		// Create the server API
 		port := cctx.Int("port")
 		repoDir, err := homedir.Expand(cctx.String(FlagRepo.Name))
 		if err != nil {
 			return fmt.Errorf("expanding repo file path: %w", err)
 		}
-		host, err := setupHost(repoDir, port)
+		hostOpts := hostOptions{
+			WebsocketPort:    cctx.Int("ws-port"),
+			WebTransportPort: cctx.Int("webtransport-port"),
+		}
+		if cctx.IsSet("wss-cert-file") || cctx.IsSet("wss-key-file") {
+			if hostOpts.WebsocketPort == 0 {
+				return fmt.Errorf("wss-cert-file and wss-key-file require ws-port to be set")
+			}
+			cert, err := tls.LoadX509KeyPair(cctx.String("wss-cert-file"), cctx.String("wss-key-file"))
+			if err != nil {
+				return fmt.Errorf("loading websocket TLS certificate: %w", err)
+			}
+			hostOpts.WebsocketTLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+		}
+		host, err := setupHost(repoDir, port, hostOpts)
 		if err != nil {
 			return fmt.Errorf("setting up libp2p host: %w", err)
 		}
 
 		// Create the bitswap server
-		multiFilter := filters.NewMultiFilter(repoDir, cctx.String("api-filter-endpoint"), cctx.String("api-filter-auth"), cctx.StringSlice("badbits-denylists"))
+		peerFilterFile := cctx.String("peer-filter-file")
+		if peerFilterFile != "" {
+			peerFilterFile, err = homedir.Expand(peerFilterFile)
+			if err != nil {
+				return fmt.Errorf("expanding peer filter file path: %w", err)
+			}
+			log.Infow("filtering bitswap requests by peer", "config", peerFilterFile)
+		}
+		if cctx.IsSet("denylist") {
+			log.Infow("filtering bitswap requests by denylist", "sources", strings.Join(cctx.StringSlice("denylist"), ", "))
+		}
+		multiFilter := filters.NewMultiFilter(repoDir, cctx.String("api-filter-endpoint"), cctx.String("api-filter-auth"),
+			cctx.StringSlice("badbits-denylists"), cctx.StringSlice("denylist"), peerFilterFile, peerRemoteAddrs(host))
 		err = multiFilter.Start(ctx)
 		if err != nil {
 			return fmt.Errorf("starting block filter: %w", err)
 		}
-		server := NewBitswapServer(remoteStore, host, multiFilter)
+		var filter Filter = multiFilter
+		if cctx.Bool("retrieval-policy") {
+			policy := retrievalpolicy.NewEngine()
+			err = policy.Watch(ctx, bapi.BoostRetrievalPolicy, cctx.Duration("retrieval-policy-refresh-interval"))
+			if err != nil {
+				return err
+			}
+			pieces, err := retrievalpolicy.NewPieceLookup(bapi.BoostDagstorePiecesContainingMultihash, retrievalPolicyLookupCacheSize)
+			if err != nil {
+				return err
+			}
+			filter = &policyFilter{Filter: multiFilter, engine: policy, pieces: pieces}
+			log.Info("applying the retrieval policy configured in boostd")
+		}
+		if cctx.Bool("retrieval-quotas") {
+			if !cctx.Bool("retrieval-log") {
+				log.Warn("retrieval quotas are enabled without --retrieval-log: blocks sent by this instance will not count towards peer quotas")
+			}
+			cache, err := quota.NewCache(bapi.BoostRetrievalQuota, retrievalQuotaCacheSize, cctx.Duration("retrieval-quotas-refresh-interval"))
+			if err != nil {
+				return err
+			}
+			filter = &quotaFilter{Filter: filter, cache: cache}
+			log.Info("applying the retrieval quotas configured in boostd")
+		}
+		var retrievalLog *rtvllog.Reporter
+		if cctx.Bool("retrieval-log") {
+			retrievalLog = rtvllog.NewReporter(bapi.BoostRetrievalAttemptsAdd)
+			go retrievalLog.Run(ctx, cctx.Duration("retrieval-log-interval"))
+			log.Info("recording block requests in the boostd retrievals log")
+		}
+		server := NewBitswapServer(remoteStore, host, filter)
 
 		var proxyAddrInfo *peer.AddrInfo
+		if cctx.IsSet("proxy") && cctx.Bool("proxy-boostd") {
+			return fmt.Errorf("only one of --proxy and --proxy-boostd can be set")
+		}
 		if cctx.IsSet("proxy") {
 			proxy := cctx.String("proxy")
 			proxyAddrInfo, err = peer.AddrInfoFromString(proxy)
@@ -165,6 +342,28 @@ var runCmd = &cli.Command{
 				return fmt.Errorf("parsing proxy multiaddr %s: %w", proxy, err)
 			}
 		}
+		if cctx.Bool("proxy-boostd") {
+			boostAddrInfo, err := bapi.NetAddrsListen(ctx)
+			if err != nil {
+				return fmt.Errorf("getting boostd libp2p address: %w", err)
+			}
+			proxyAddrInfo = &boostAddrInfo
+		}
+
+		limits := limiter.Options{
+			PeerBlocksPerSecond: cctx.Float64("peer-blocks-per-second"),
+			PeerBlockBurst:      cctx.Int("peer-block-burst"),
+		}
+		if cctx.String("max-bandwidth") != "" {
+			limits.BytesPerSecond, err = units.RAMInBytes(cctx.String("max-bandwidth"))
+			if err != nil {
+				return fmt.Errorf("parsing max-bandwidth: %w", err)
+			}
+		}
+		if limits.Enabled() {
+			log.Infow("limiting bitswap responses", "peer-blocks-per-second", limits.PeerBlocksPerSecond,
+				"peer-block-burst", limits.PeerBlockBurst, "max-bandwidth", cctx.String("max-bandwidth"))
+		}
 
 		// Start the bitswap server
 		log.Infof("Starting booster-bitswap node on port %d", port)
@@ -174,6 +373,9 @@ var runCmd = &cli.Command{
 			MaxOutstandingBytesPerPeer:  cctx.Int("max-outstanding-bytes-per-peer"),
 			TargetMessageSize:           cctx.Int("target-message-size"),
 			TaskWorkerCount:             cctx.Int("task-worker-count"),
+			MaxWantsPerPeer:             cctx.Uint("max-wants-per-peer"),
+			Limits:                      limits,
+			RetrievalLog:                retrievalLog,
 		})
 		if err != nil {
 			return err
@@ -207,6 +409,21 @@ var runCmd = &cli.Command{
 	},
 }
 
+// peerRemoteAddrs returns a function that gets the remote addresses of the
+// connections to a peer.
+// Note that when booster-bitswap is behind a proxy, retrieval clients
+// connect to the proxy and their addresses are not known.
+func peerRemoteAddrs(h host.Host) filters.PeerAddrsFunc {
+	return func(p peer.ID) []multiaddr.Multiaddr {
+		conns := h.Network().ConnsToPeer(p)
+		addrs := make([]multiaddr.Multiaddr, 0, len(conns))
+		for _, conn := range conns {
+			addrs = append(addrs, conn.RemoteMultiaddr())
+		}
+		return addrs
+	}
+}
+
 func getBoostAPI(ctx context.Context, ai string) (api.Boost, jsonrpc.ClientCloser, error) {
 	ai = strings.TrimPrefix(strings.TrimSpace(ai), "BOOST_API_INFO=")
 	info := cliutil.ParseApiInfo(ai)
@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/filecoin-project/boost/cmd/booster-bitswap/bitswap"
+	"github.com/filecoin-project/boost/cmd/booster-bitswap/limiter"
+	"github.com/filecoin-project/boost/metrics"
 	"github.com/filecoin-project/boost/protocolproxy"
+	"github.com/filecoin-project/boost/retrievalmarket/rtvllog"
 	"github.com/ipfs/go-cid"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	nilrouting "github.com/ipfs/go-ipfs-routing/none"
@@ -13,6 +17,7 @@ import (
 	"github.com/ipfs/go-libipfs/bitswap/server"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"go.opencensus.io/stats"
 )
 
 type Filter interface {
@@ -35,6 +40,13 @@ type BitswapServerOptions struct {
 	TaskWorkerCount             int
 	TargetMessageSize           int
 	MaxOutstandingBytesPerPeer  int
+	// The maximum number of wants that are queued for each peer
+	// (0 means use the bitswap default)
+	MaxWantsPerPeer uint
+	// Limits on the rate of blocks sent to each peer and on bandwidth
+	Limits limiter.Options
+	// If RetrievalLog is set, each block request is reported to boostd
+	RetrievalLog *rtvllog.Reporter
 }
 
 func NewBitswapServer(
@@ -61,6 +73,18 @@ func (s *BitswapServer) Start(ctx context.Context, proxy *peer.AddrInfo, opts *B
 		}
 		s.host.ConnManager().Protect(proxy.ID, protectTag)
 
+		// The proxy only listens on bitswap protocols if it has been
+		// configured with the peer ID of booster-bitswap
+		supported, err := s.host.Peerstore().SupportsProtocols(proxy.ID, bitswap.ProtocolStrings...)
+		if err != nil {
+			return fmt.Errorf("getting protocols supported by proxy %s: %w", proxy.ID, err)
+		}
+		if len(supported) == 0 {
+			log.Warnw("proxy does not support bitswap protocols: "+
+				"set Dealmaking.BitswapPeerID in the boostd config to the booster-bitswap peer id",
+				"proxy", proxy.ID, "peerId", s.host.ID())
+		}
+
 		// Create a forwarding host that registers routes with the proxy
 		host = protocolproxy.NewForwardingHost(s.host, *proxy)
 	}
@@ -78,6 +102,9 @@ func (s *BitswapServer) Start(ctx context.Context, proxy *peer.AddrInfo, opts *B
 		server.WithTargetMessageSize(opts.TargetMessageSize),
 		server.WithPeerBlockRequestFilter(func(p peer.ID, c cid.Cid) bool {
 			fulfill, err := s.filter.FulfillRequest(p, c)
+			if opts.RetrievalLog != nil && (err != nil || !fulfill) {
+				recordRefused(opts.RetrievalLog, p, c, err)
+			}
 			// peer request filter expects a true if the request should be fulfilled, so
 			// we only return true for requests that aren't filtered and have no errors
 			if err != nil {
@@ -87,10 +114,21 @@ func (s *BitswapServer) Start(ctx context.Context, proxy *peer.AddrInfo, opts *B
 			return fulfill
 		}),
 	}
+	if opts.MaxWantsPerPeer > 0 {
+		bsopts = append(bsopts, server.MaxQueuedWantlistEntriesPerPeer(opts.MaxWantsPerPeer))
+	}
 	net := bsnetwork.NewFromIpfsHost(host, nilRouter)
+	if opts.Limits.Enabled() {
+		net = limiter.NewNetwork(net, opts.Limits)
+	}
+	if opts.RetrievalLog != nil {
+		net = &retrievalLogNetwork{BitSwapNetwork: net, reporter: opts.RetrievalLog}
+	}
 	s.server = server.New(s.ctx, net, s.remoteStore, bsopts...)
 	net.Start(s.server)
 
+	go s.recordStats(s.ctx)
+
 	log.Infow("bitswap server running", "multiaddrs", host.Addrs(), "peerId", host.ID())
 	if proxy != nil {
 		go s.keepProxyConnectionAlive(s.ctx, *proxy)
@@ -108,6 +146,47 @@ func (s *BitswapServer) Stop() error {
 	return s.server.Close()
 }
 
+// The interval at which bitswap server stats are recorded as metrics
+const statsInterval = 10 * time.Second
+
+// recordStats periodically records the number of peers, the size of their
+// wantlists and the blocks served by the bitswap server as metrics
+func (s *BitswapServer) recordStats(ctx context.Context) {
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+
+	var blocksSent, dataSent uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		st, err := s.server.Stat()
+		if err != nil {
+			log.Warnw("getting bitswap server stats", "err", err)
+			continue
+		}
+
+		var wants int
+		for _, ps := range st.Peers {
+			p, err := peer.Decode(ps)
+			if err != nil {
+				continue
+			}
+			wants += len(s.server.WantlistForPeer(p))
+		}
+
+		stats.Record(ctx,
+			metrics.BitswapPeers.M(int64(len(st.Peers))),
+			metrics.BitswapWantlistSize.M(int64(wants)),
+			metrics.BitswapBlocksServedCount.M(int64(st.BlocksSent-blocksSent)),
+			metrics.BitswapBytesServedCount.M(int64(st.DataSent-dataSent)))
+		blocksSent, dataSent = st.BlocksSent, st.DataSent
+	}
+}
+
 func (s *BitswapServer) keepProxyConnectionAlive(ctx context.Context, proxy peer.AddrInfo) {
 	// Periodically ensure that the connection over libp2p to the proxy is alive
 	ticker := time.NewTicker(5 * time.Second)
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/metrics"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// The formats of the access log
+const (
+	// One JSON object per line
+	accessLogFormatJSON = "json"
+	// The Common Log Format used by web servers
+	accessLogFormatCommon = "common"
+)
+
+// The endpoints that downloads are served from
+const (
+	endpointPiece = "piece"
+	endpointIpfs  = "ipfs"
+)
+
+// AccessLogger writes an entry to the access log for each download request
+type AccessLogger struct {
+	format            string
+	trustForwardedFor bool
+
+	lk sync.Mutex
+	w  io.Writer
+}
+
+type accessLogEntry struct {
+	Time       time.Time `json:"time"`
+	ClientIP   string    `json:"client_ip"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Proto      string    `json:"-"`
+	Endpoint   string    `json:"endpoint"`
+	PieceCid   string    `json:"piece_cid,omitempty"`
+	PayloadCid string    `json:"payload_cid,omitempty"`
+	Range      string    `json:"range,omitempty"`
+	Status     int       `json:"status"`
+	Bytes      uint64    `json:"bytes"`
+	DurationMs int64     `json:"duration_ms"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+// NewAccessLogger creates a logger that writes entries in the format
+// (json or common) to w. If trustForwardedFor is true, the client IP is
+// read from the X-Forwarded-For header.
+func NewAccessLogger(w io.Writer, format string, trustForwardedFor bool) (*AccessLogger, error) {
+	switch format {
+	case accessLogFormatJSON, accessLogFormatCommon:
+	default:
+		return nil, fmt.Errorf("unknown access log format '%s': must be %s or %s", format, accessLogFormatJSON, accessLogFormatCommon)
+	}
+	return &AccessLogger{w: w, format: format, trustForwardedFor: trustForwardedFor}, nil
+}
+
+func (l *AccessLogger) log(e *accessLogEntry) {
+	var line []byte
+	if l.format == accessLogFormatJSON {
+		bz, err := json.Marshal(e)
+		if err != nil {
+			log.Warnw("marshalling access log entry", "err", err)
+			return
+		}
+		line = append(bz, '\n')
+	} else {
+		// host ident authuser [date] "request" status bytes
+		line = []byte(fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %d\n",
+			e.ClientIP, e.Time.Format("02/Jan/2006:15:04:05 -0700"), e.Method, e.Path, e.Proto, e.Status, e.Bytes))
+	}
+
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	if _, err := l.w.Write(line); err != nil {
+		log.Warnw("writing to access log", "err", err)
+	}
+}
+
+// instrument returns a handler that records the metrics for the responses
+// to download requests, and writes an entry to the access log (if enabled)
+func (s *HttpServer) instrument(endpoint string, basePath string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		cw := &countingResponseWriter{ResponseWriter: w}
+		handler(cw, r)
+
+		status := cw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		ctx, _ := tag.New(r.Context(),
+			tag.Upsert(metrics.HttpEndpoint, endpoint),
+			tag.Upsert(metrics.HttpStatus, strconv.Itoa(status)))
+		stats.Record(ctx, metrics.HttpResponseCount.M(1), metrics.HttpBytesSent.M(int64(cw.count)))
+
+		if s.opts.AccessLog == nil {
+			return
+		}
+		e := &accessLogEntry{
+			Time:       start,
+			ClientIP:   clientIP(r, s.opts.AccessLog.trustForwardedFor),
+			Method:     r.Method,
+			Path:       r.URL.RequestURI(),
+			Proto:      r.Proto,
+			Endpoint:   endpoint,
+			Range:      r.Header.Get("Range"),
+			Status:     status,
+			Bytes:      cw.count,
+			DurationMs: time.Since(start).Milliseconds(),
+			UserAgent:  r.UserAgent(),
+		}
+		if strings.HasPrefix(r.URL.Path, basePath) {
+			c, _, _ := strings.Cut(r.URL.Path[len(basePath):], "/")
+			if endpoint == endpointPiece {
+				e.PieceCid = c
+			} else {
+				e.PayloadCid = c
+			}
+		}
+		s.opts.AccessLog.log(e)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLog(t *testing.T) {
+	content := []byte("piece data")
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(content) //nolint:errcheck
+	}
+
+	get := func(format string) []byte {
+		var buf bytes.Buffer
+		accessLog, err := NewAccessLogger(&buf, format, false)
+		require.NoError(t, err)
+		s := NewHttpServer("", 7777, false, nil, &HttpServerOptions{AccessLog: accessLog})
+
+		req := httptest.NewRequest("GET", "/piece/bafyPieceCid?format=car", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("Range", "bytes=0-9")
+		rec := httptest.NewRecorder()
+		s.instrument(endpointPiece, s.pieceBasePath(), handler)(rec, req)
+		require.Equal(t, http.StatusPartialContent, rec.Code)
+		require.Equal(t, content, rec.Body.Bytes())
+		return buf.Bytes()
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var e accessLogEntry
+		require.NoError(t, json.Unmarshal(get(accessLogFormatJSON), &e))
+		require.Equal(t, "10.0.0.1", e.ClientIP)
+		require.Equal(t, "GET", e.Method)
+		require.Equal(t, "/piece/bafyPieceCid?format=car", e.Path)
+		require.Equal(t, endpointPiece, e.Endpoint)
+		require.Equal(t, "bafyPieceCid", e.PieceCid)
+		require.Empty(t, e.PayloadCid)
+		require.Equal(t, "bytes=0-9", e.Range)
+		require.Equal(t, http.StatusPartialContent, e.Status)
+		require.EqualValues(t, len(content), e.Bytes)
+	})
+
+	t.Run("common", func(t *testing.T) {
+		line := string(get(accessLogFormatCommon))
+		re := regexp.MustCompile(`^10\.0\.0\.1 - - \[[^\]]+\] "GET /piece/bafyPieceCid\?format=car HTTP/1\.1" 206 10\n$`)
+		require.Regexp(t, re, line)
+	})
+
+	_, err := NewAccessLogger(&bytes.Buffer{}, "xml", false)
+	require.Error(t, err)
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/metrics"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// The query parameters of a signed URL
+const (
+	signedUrlExpiresParam = "expires"
+	signedUrlIdParam      = "id"
+	signedUrlSigParam     = "sig"
+)
+
+// The accounting id for signed URLs that don't have an id
+const defaultSignedUrlId = "signed-url"
+
+// Authenticator checks that download requests have either a valid bearer
+// token, or a valid signed URL, and keeps count of the bytes downloaded by
+// each token (or signed URL id).
+type Authenticator struct {
+	// token -> name of the token
+	tokens map[string]string
+	// The key used to sign URLs
+	signingKey []byte
+
+	lk    sync.Mutex
+	usage map[string]*Usage
+}
+
+// Usage is the number of requests and bytes sent for a token or signed URL
+// id
+type Usage struct {
+	ID        string `json:"id"`
+	Requests  uint64 `json:"requests"`
+	BytesSent uint64 `json:"bytes_sent"`
+}
+
+// NewAuthenticator creates an authenticator that accepts the given tokens
+// (token -> name) and URLs signed by the signing key.
+// Either may be empty, but not both.
+func NewAuthenticator(tokens map[string]string, signingKey []byte) (*Authenticator, error) {
+	if len(tokens) == 0 && len(signingKey) == 0 {
+		return nil, fmt.Errorf("authentication requires at least one token or a signing key")
+	}
+	return &Authenticator{
+		tokens:     tokens,
+		signingKey: signingKey,
+		usage:      make(map[string]*Usage),
+	}, nil
+}
+
+// LoadTokensFile reads a file with one token per line, in the form
+// <name> <token>. The name is used to account for the bytes downloaded with
+// the token. Empty lines and lines starting with # are ignored.
+func LoadTokensFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening tokens file: %w", err)
+	}
+	defer f.Close()
+
+	tokens := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("tokens file line %d: expected <name> <token>", lineNum)
+		}
+		if _, ok := tokens[fields[1]]; ok {
+			return nil, fmt.Errorf("tokens file line %d: duplicate token", lineNum)
+		}
+		tokens[fields[1]] = fields[0]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading tokens file: %w", err)
+	}
+	return tokens, nil
+}
+
+// LoadSigningKeyFile reads the key used to sign URLs from a file
+func LoadSigningKeyFile(path string) ([]byte, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading signing key file: %w", err)
+	}
+	key := []byte(strings.TrimSpace(string(bz)))
+	if len(key) < 32 {
+		return nil, fmt.Errorf("signing key in %s must be at least 32 bytes long", path)
+	}
+	return key, nil
+}
+
+// SignUrlPath returns the query string that signs the URL path until the
+// expiry time. The id is used to account for the bytes downloaded with the
+// URL (it may be empty).
+// The signature only covers the path, so other query parameters (eg format)
+// can be added to the signed URL.
+func SignUrlPath(signingKey []byte, path string, id string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	sig := urlSignature(signingKey, path, id, exp)
+	q := signedUrlExpiresParam + "=" + exp
+	if id != "" {
+		q += "&" + signedUrlIdParam + "=" + url.QueryEscape(id)
+	}
+	return q + "&" + signedUrlSigParam + "=" + sig
+}
+
+func urlSignature(signingKey []byte, path string, id string, expires string) string {
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write([]byte(path + "\n" + id + "\n" + expires)) //nolint:errcheck
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// authenticate returns the accounting id for the request, or an error
+// status and message if the request is not authorized
+func (a *Authenticator) authenticate(r *http.Request) (string, int, string) {
+	if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			return "", http.StatusUnauthorized, "Authorization header must be a bearer token"
+		}
+		token := strings.TrimPrefix(authHeader, "Bearer ")
+		for t, name := range a.tokens {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				return name, 0, ""
+			}
+		}
+		return "", http.StatusUnauthorized, "invalid bearer token"
+	}
+
+	query := r.URL.Query()
+	sig := query.Get(signedUrlSigParam)
+	if sig == "" {
+		return "", http.StatusUnauthorized, "authentication required: set a bearer token or use a signed URL"
+	}
+	if len(a.signingKey) == 0 {
+		return "", http.StatusUnauthorized, "signed URLs are not enabled"
+	}
+
+	exp := query.Get(signedUrlExpiresParam)
+	id := query.Get(signedUrlIdParam)
+	expected := urlSignature(a.signingKey, r.URL.Path, id, exp)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return "", http.StatusForbidden, "invalid URL signature"
+	}
+	expUnix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return "", http.StatusForbidden, "invalid signed URL expiry time"
+	}
+	if time.Now().Unix() > expUnix {
+		return "", http.StatusForbidden, "signed URL has expired"
+	}
+
+	if id == "" {
+		id = defaultSignedUrlId
+	}
+	return id, 0, ""
+}
+
+// authIDKey is the context key for the accounting id of an authenticated
+// request
+type authIDKey struct{}
+
+// authID returns the accounting id the request was authenticated with, if
+// any
+func authID(r *http.Request) (string, bool) {
+	id, ok := r.Context().Value(authIDKey{}).(string)
+	return id, ok
+}
+
+// clientID identifies the client that made the request: by the id of its
+// auth token or signed URL if auth is enabled, otherwise by its IP address
+func clientID(r *http.Request, trustForwardedFor bool) string {
+	if id, ok := authID(r); ok {
+		return id
+	}
+	return clientIP(r, trustForwardedFor)
+}
+
+// wrap returns a handler that only calls the handler if the request is
+// authorized, and that counts the bytes sent in the response
+func (a *Authenticator) wrap(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, status, msg := a.authenticate(r)
+		if status != 0 {
+			if status == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			stats.Record(r.Context(), metrics.HttpAuthFailureCount.M(1))
+			writeError(w, r, status, msg)
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), authIDKey{}, id))
+		cw := &countingResponseWriter{ResponseWriter: w}
+		handler(cw, r)
+		a.record(r, id, cw.count)
+	}
+}
+
+func (a *Authenticator) record(r *http.Request, id string, bytesSent uint64) {
+	a.lk.Lock()
+	u, ok := a.usage[id]
+	if !ok {
+		u = &Usage{ID: id}
+		a.usage[id] = u
+	}
+	u.Requests++
+	u.BytesSent += bytesSent
+	a.lk.Unlock()
+
+	ctx, _ := tag.New(r.Context(), tag.Upsert(metrics.HttpAuthID, id))
+	stats.Record(ctx, metrics.HttpAuthBytesSent.M(int64(bytesSent)))
+}
+
+// Usage returns the usage of each token and signed URL id
+func (a *Authenticator) Usage() []Usage {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	usage := make([]Usage, 0, len(a.usage))
+	for _, u := range a.usage {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].ID < usage[j].ID
+	})
+	return usage
+}
+
+// handleUsage returns the usage for the token (or signed URL id) that the
+// request is authenticated with
+func (a *Authenticator) handleUsage(w http.ResponseWriter, r *http.Request) {
+	id, status, msg := a.authenticate(r)
+	if status != 0 {
+		writeError(w, r, status, msg)
+		return
+	}
+
+	a.lk.Lock()
+	u := Usage{ID: id}
+	if existing, ok := a.usage[id]; ok {
+		u = *existing
+	}
+	a.lk.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(u) //nolint:errcheck
+}
+
+// countingResponseWriter counts the bytes written to the response, and
+// records the response status
+type countingResponseWriter struct {
+	http.ResponseWriter
+	count  uint64
+	status int
+}
+
+func (w *countingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *countingResponseWriter) Write(bz []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(bz)
+	w.count += uint64(n)
+	return n, err
+}
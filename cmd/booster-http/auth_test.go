@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticator(t *testing.T) {
+	tokensFile := filepath.Join(t.TempDir(), "tokens")
+	err := os.WriteFile(tokensFile, []byte("# tokens\nalice token-a\n\nbob token-b\n"), 0600)
+	require.NoError(t, err)
+	tokens, err := LoadTokensFile(tokensFile)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"token-a": "alice", "token-b": "bob"}, tokens)
+
+	signingKey := []byte(strings.Repeat("k", 32))
+	auth, err := NewAuthenticator(tokens, signingKey)
+	require.NoError(t, err)
+
+	content := []byte("piece data")
+	handler := auth.wrap(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content) //nolint:errcheck
+	})
+
+	get := func(target string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	t.Run("bearer token", func(t *testing.T) {
+		rec := get("/piece/bafy", map[string]string{"Authorization": "Bearer token-a"})
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, content, rec.Body.Bytes())
+
+		rec = get("/piece/bafy", map[string]string{"Authorization": "Bearer wrong"})
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		rec = get("/piece/bafy", nil)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+		require.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("signed url", func(t *testing.T) {
+		sig := SignUrlPath(signingKey, "/piece/bafy", "carol", time.Now().Add(time.Hour))
+
+		// Other query parameters may be added to a signed URL
+		rec := get("/piece/bafy?format=car&"+sig, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, content, rec.Body.Bytes())
+
+		// The signature is only valid for the path it was made for
+		rec = get("/piece/other?"+sig, nil)
+		require.Equal(t, http.StatusForbidden, rec.Code)
+
+		// The signature covers the id
+		rec = get("/piece/bafy?"+strings.Replace(sig, "id=carol", "id=dave", 1), nil)
+		require.Equal(t, http.StatusForbidden, rec.Code)
+
+		// A URL signed with a different key is not valid
+		otherSig := SignUrlPath([]byte(strings.Repeat("x", 32)), "/piece/bafy", "carol", time.Now().Add(time.Hour))
+		rec = get("/piece/bafy?"+otherSig, nil)
+		require.Equal(t, http.StatusForbidden, rec.Code)
+
+		expired := SignUrlPath(signingKey, "/piece/bafy", "", time.Now().Add(-time.Minute))
+		rec = get("/piece/bafy?"+expired, nil)
+		require.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	// Bytes sent are accounted to the token name or signed URL id
+	require.Equal(t, []Usage{
+		{ID: "alice", Requests: 1, BytesSent: uint64(len(content))},
+		{ID: "carol", Requests: 1, BytesSent: uint64(len(content))},
+	}, auth.Usage())
+
+	// A token can be used to get its own usage
+	req := httptest.NewRequest("GET", "/usage", nil)
+	req.Header.Set("Authorization", "Bearer token-a")
+	rec := httptest.NewRecorder()
+	auth.handleUsage(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var u Usage
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &u))
+	require.Equal(t, Usage{ID: "alice", Requests: 1, BytesSent: uint64(len(content))}, u)
+
+	_, err = NewAuthenticator(nil, nil)
+	require.Error(t, err)
+}
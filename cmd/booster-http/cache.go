@@ -0,0 +1,355 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/filecoin-project/boost/metrics"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipfs/go-libipfs/blocks"
+	"go.opencensus.io/stats"
+)
+
+// Piece data is cached in chunks of this size
+const pieceCacheChunkSize = 1024 * 1024
+
+// DiskCache is a least-recently-used cache of data (blocks and ranges of
+// pieces) that have been served, kept on disk, so that hot content doesn't
+// need to be fetched from the boost node (and possibly unsealed) each time
+// it's requested.
+type DiskCache struct {
+	dir      string
+	maxBytes uint64
+
+	lk sync.Mutex
+	// The most recently used entry is at the front of the list
+	lru     *list.List
+	entries map[string]*list.Element
+	size    uint64
+}
+
+type diskCacheEntry struct {
+	name string
+	size uint64
+}
+
+// NewDiskCache creates a cache in the directory that holds up to maxBytes
+// of data. Data cached by a previous run is kept.
+func NewDiskCache(dir string, maxBytes uint64) (*DiskCache, error) {
+	if maxBytes == 0 {
+		return nil, errors.New("cache size must be greater than zero")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating cache directory %s: %w", dir, err)
+	}
+
+	c := &DiskCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+
+	// Load the entries cached by a previous run, most recently modified
+	// first
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading cache directory %s: %w", dir, err)
+	}
+	var infos []os.FileInfo
+	for _, de := range des {
+		if de.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(de.Name(), ".tmp") {
+			_ = os.Remove(filepath.Join(dir, de.Name()))
+			continue
+		}
+		fi, err := de.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, fi)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().After(infos[j].ModTime())
+	})
+	for _, fi := range infos {
+		e := &diskCacheEntry{name: fi.Name(), size: uint64(fi.Size())}
+		c.entries[e.name] = c.lru.PushBack(e)
+		c.size += e.size
+	}
+
+	c.lk.Lock()
+	c.evictLocked()
+	c.lk.Unlock()
+
+	return c, nil
+}
+
+func cacheFileName(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+// Get returns the data cached under the key
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+	name := cacheFileName(key)
+
+	c.lk.Lock()
+	el, ok := c.entries[name]
+	if ok {
+		c.lru.MoveToFront(el)
+	}
+	c.lk.Unlock()
+
+	if ok {
+		data, err := os.ReadFile(filepath.Join(c.dir, name))
+		if err == nil {
+			stats.Record(context.Background(), metrics.HttpCacheHitCount.M(1))
+			return data, true
+		}
+
+		// The file was evicted after its entry was found, or could not be
+		// read
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warnw("reading from cache", "key", key, "err", err)
+		}
+	}
+
+	stats.Record(context.Background(), metrics.HttpCacheMissCount.M(1))
+	return nil, false
+}
+
+// Has returns true if there is data cached under the key
+func (c *DiskCache) Has(key string) bool {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	_, ok := c.entries[cacheFileName(key)]
+	return ok
+}
+
+// Put adds the data to the cache under the key, evicting the least recently
+// used entries if the cache is full
+func (c *DiskCache) Put(key string, data []byte) {
+	size := uint64(len(data))
+	if size > c.maxBytes {
+		return
+	}
+
+	name := cacheFileName(key)
+	c.lk.Lock()
+	_, ok := c.entries[name]
+	c.lk.Unlock()
+	if ok {
+		return
+	}
+
+	// Write to a temp file and rename it so that a partially written file
+	// is never read from the cache
+	path := filepath.Join(c.dir, name)
+	tmpPath := path + "." + uuid.New().String() + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		log.Warnw("writing to cache", "key", key, "err", err)
+		_ = os.Remove(tmpPath)
+		return
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		log.Warnw("writing to cache", "key", key, "err", err)
+		_ = os.Remove(tmpPath)
+		return
+	}
+
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	if _, ok := c.entries[name]; ok {
+		// The same data was added concurrently
+		return
+	}
+	c.entries[name] = c.lru.PushFront(&diskCacheEntry{name: name, size: size})
+	c.size += size
+	c.evictLocked()
+}
+
+func (c *DiskCache) evictLocked() {
+	for c.size > c.maxBytes {
+		el := c.lru.Back()
+		if el == nil {
+			break
+		}
+		e := el.Value.(*diskCacheEntry)
+		c.lru.Remove(el)
+		delete(c.entries, e.name)
+		c.size -= e.size
+		if err := os.Remove(filepath.Join(c.dir, e.name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warnw("removing evicted file from cache", "file", e.name, "err", err)
+		}
+	}
+	stats.Record(context.Background(), metrics.HttpCacheBytes.M(int64(c.size)))
+}
+
+// Size returns the number of bytes of data in the cache
+func (c *DiskCache) Size() uint64 {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return c.size
+}
+
+// cachingBlockstore caches the blocks read from the underlying blockstore
+type cachingBlockstore struct {
+	blockstore.Blockstore
+	cache *DiskCache
+}
+
+func blockCacheKey(c cid.Cid) string {
+	return "block/" + c.String()
+}
+
+func (bs *cachingBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if data, ok := bs.cache.Get(blockCacheKey(c)); ok {
+		return blocks.NewBlockWithCid(data, c)
+	}
+
+	blk, err := bs.Blockstore.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	bs.cache.Put(blockCacheKey(c), blk.RawData())
+	return blk, nil
+}
+
+// cachedPieceReader reads a piece in chunks, using the cached chunk if
+// there is one. The reader over the piece data is only opened when a chunk
+// (or the size of the piece) is not in the cache.
+type cachedPieceReader struct {
+	cache    *DiskCache
+	pieceCid cid.Cid
+	open     func() (io.ReadSeeker, error)
+
+	r      io.ReadSeeker
+	size   int64
+	offset int64
+}
+
+var _ io.ReadSeeker = (*cachedPieceReader)(nil)
+
+func pieceSizeCacheKey(pieceCid cid.Cid) string {
+	return "piece-size/" + pieceCid.String()
+}
+
+// newCachedPieceReader returns a reader over the piece. The open function
+// returns a reader over the piece data from the boost node.
+func newCachedPieceReader(cache *DiskCache, pieceCid cid.Cid, open func() (io.ReadSeeker, error)) (*cachedPieceReader, error) {
+	pr := &cachedPieceReader{cache: cache, pieceCid: pieceCid, open: open}
+
+	if bz, ok := cache.Get(pieceSizeCacheKey(pieceCid)); ok && len(bz) == 8 {
+		pr.size = int64(binary.BigEndian.Uint64(bz))
+		return pr, nil
+	}
+
+	// The size of the piece is not cached, so open the piece to get it
+	r, err := pr.reader()
+	if err != nil {
+		return nil, err
+	}
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("getting size of piece %s: %w", pieceCid, err)
+	}
+	pr.size = size
+	bz := make([]byte, 8)
+	binary.BigEndian.PutUint64(bz, uint64(size))
+	cache.Put(pieceSizeCacheKey(pieceCid), bz)
+	return pr, nil
+}
+
+func (pr *cachedPieceReader) reader() (io.ReadSeeker, error) {
+	if pr.r == nil {
+		r, err := pr.open()
+		if err != nil {
+			return nil, err
+		}
+		pr.r = r
+	}
+	return pr.r, nil
+}
+
+func (pr *cachedPieceReader) chunk(idx int64) ([]byte, error) {
+	key := fmt.Sprintf("piece/%s/%d", pr.pieceCid, idx)
+	if data, ok := pr.cache.Get(key); ok {
+		return data, nil
+	}
+
+	r, err := pr.reader()
+	if err != nil {
+		return nil, err
+	}
+	start := idx * pieceCacheChunkSize
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seeking to offset %d in piece %s: %w", start, pr.pieceCid, err)
+	}
+	length := int64(pieceCacheChunkSize)
+	if start+length > pr.size {
+		length = pr.size - start
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("reading piece %s at offset %d: %w", pr.pieceCid, start, err)
+	}
+
+	pr.cache.Put(key, data)
+	return data, nil
+}
+
+func (pr *cachedPieceReader) Read(p []byte) (int, error) {
+	if pr.offset >= pr.size {
+		return 0, io.EOF
+	}
+
+	idx := pr.offset / pieceCacheChunkSize
+	data, err := pr.chunk(idx)
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, data[pr.offset-idx*pieceCacheChunkSize:])
+	pr.offset += int64(n)
+	return n, nil
+}
+
+func (pr *cachedPieceReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += pr.offset
+	case io.SeekEnd:
+		offset += pr.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("invalid offset %d", offset)
+	}
+	pr.offset = offset
+	return offset, nil
+}
+
+func (pr *cachedPieceReader) Close() error {
+	if c, ok := pr.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewDiskCache(dir, 30)
+	require.NoError(t, err)
+
+	c.Put("a", bytes.Repeat([]byte("a"), 10))
+	c.Put("b", bytes.Repeat([]byte("b"), 10))
+	c.Put("c", bytes.Repeat([]byte("c"), 10))
+	require.EqualValues(t, 30, c.Size())
+
+	// Get a so that b is the least recently used entry
+	data, ok := c.Get("a")
+	require.True(t, ok)
+	require.Equal(t, bytes.Repeat([]byte("a"), 10), data)
+
+	// Adding an entry evicts the least recently used entry
+	c.Put("d", bytes.Repeat([]byte("d"), 10))
+	require.EqualValues(t, 30, c.Size())
+	_, ok = c.Get("b")
+	require.False(t, ok)
+	for _, key := range []string{"a", "c", "d"} {
+		_, ok = c.Get(key)
+		require.True(t, ok)
+	}
+
+	// Data larger than the cache is not cached
+	c.Put("e", bytes.Repeat([]byte("e"), 31))
+	_, ok = c.Get("e")
+	require.False(t, ok)
+
+	// Cached data is kept when the cache is reopened, up to the max size
+	c, err = NewDiskCache(dir, 20)
+	require.NoError(t, err)
+	require.EqualValues(t, 20, c.Size())
+	des, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, des, 2)
+}
+
+func TestCachedPieceReader(t *testing.T) {
+	c, err := NewDiskCache(t.TempDir(), 10*pieceCacheChunkSize)
+	require.NoError(t, err)
+
+	pieceCid := testutil.GenerateCid()
+	pieceData := make([]byte, 3*pieceCacheChunkSize+100)
+	rand.New(rand.NewSource(1)).Read(pieceData) //nolint:errcheck
+
+	opens := 0
+	open := func() (io.ReadSeeker, error) {
+		opens++
+		return bytes.NewReader(pieceData), nil
+	}
+
+	// Read part of the piece: the piece is opened to get its size and read
+	// the chunks
+	pr, err := newCachedPieceReader(c, pieceCid, open)
+	require.NoError(t, err)
+	_, err = pr.Seek(pieceCacheChunkSize-10, io.SeekStart)
+	require.NoError(t, err)
+	buf := make([]byte, 20)
+	_, err = io.ReadFull(pr, buf)
+	require.NoError(t, err)
+	require.Equal(t, pieceData[pieceCacheChunkSize-10:pieceCacheChunkSize+10], buf)
+	require.NoError(t, pr.Close())
+	require.Equal(t, 1, opens)
+
+	// Reading the same range again is served from the cache, without
+	// opening the piece
+	pr, err = newCachedPieceReader(c, pieceCid, open)
+	require.NoError(t, err)
+	size, err := pr.Seek(0, io.SeekEnd)
+	require.NoError(t, err)
+	require.EqualValues(t, len(pieceData), size)
+	_, err = pr.Seek(pieceCacheChunkSize-10, io.SeekStart)
+	require.NoError(t, err)
+	_, err = io.ReadFull(pr, buf)
+	require.NoError(t, err)
+	require.Equal(t, pieceData[pieceCacheChunkSize-10:pieceCacheChunkSize+10], buf)
+	require.Equal(t, 1, opens)
+
+	// Reading the whole piece opens the piece to read the chunks that
+	// aren't cached
+	_, err = pr.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	all, err := io.ReadAll(pr)
+	require.NoError(t, err)
+	require.Equal(t, pieceData, all)
+	require.Equal(t, 2, opens)
+	require.NoError(t, pr.Close())
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// The content encodings that responses can be compressed with, in order of
+// preference
+const (
+	encodingZstd = "zstd"
+	encodingGzip = "gzip"
+)
+
+// negotiateEncoding returns the content encoding with the highest quality
+// value in the Accept-Encoding header, or the empty string if the client
+// doesn't accept a compressed response.
+// If encodings have the same quality, zstd is preferred over gzip.
+func negotiateEncoding(acceptEncoding string) string {
+	var best string
+	var bestQ float64
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			parsed, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+
+		switch name {
+		case encodingZstd, encodingGzip:
+		case "*":
+			// Clients that accept any encoding are most likely to support gzip
+			name = encodingGzip
+		default:
+			continue
+		}
+		if q > bestQ || (q == bestQ && name == encodingZstd) {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressedResponseWriter compresses the body of a 200 OK response with
+// the content encoding. Other responses (eg 206 Partial Content or 304 Not
+// Modified) are not compressed.
+type compressedResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	isHead      bool
+	enc         io.WriteCloser
+	wroteHeader bool
+}
+
+// compressResponse returns a writer that compresses the response with the
+// best encoding that the client accepts. Responses to Range requests are
+// never compressed, because the byte ranges refer to the uncompressed
+// content.
+// The writer must be closed to flush the compressed data.
+func compressResponse(w http.ResponseWriter, r *http.Request) *compressedResponseWriter {
+	// The response depends on the Accept-Encoding header, so caches must
+	// take it into account
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	cw := &compressedResponseWriter{ResponseWriter: w, isHead: r.Method == "HEAD"}
+	if r.Header.Get("Range") == "" {
+		cw.encoding = negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	}
+	return cw
+}
+
+func (w *compressedResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if status == http.StatusOK && w.encoding != "" {
+		// The length of the compressed response is not known in advance
+		w.Header().Del("Content-Length")
+		w.Header().Set("Content-Encoding", w.encoding)
+		if !w.isHead {
+			w.enc = newEncoder(w.ResponseWriter, w.encoding)
+		}
+	} else {
+		w.encoding = ""
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *compressedResponseWriter) Write(bz []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.enc == nil {
+		return w.ResponseWriter.Write(bz)
+	}
+	return w.enc.Write(bz)
+}
+
+// Encoding returns the content encoding of the response, or the empty
+// string if the response is not compressed
+func (w *compressedResponseWriter) Encoding() string {
+	return w.encoding
+}
+
+// Close flushes the compressed data to the response
+func (w *compressedResponseWriter) Close() error {
+	if w.enc == nil {
+		return nil
+	}
+	return w.enc.Close()
+}
+
+func newEncoder(w io.Writer, encoding string) io.WriteCloser {
+	if encoding == encodingZstd {
+		// The options are valid so NewWriter won't return an error.
+		// Each response is compressed in the request goroutine, so that
+		// concurrent downloads don't each start a pool of encoder goroutines.
+		enc, _ := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		return enc
+	}
+	return gzip.NewWriter(w)
+}
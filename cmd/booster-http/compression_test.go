@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tcs := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", encodingGzip},
+		{"gzip, deflate, br", encodingGzip},
+		{"gzip, zstd", encodingZstd},
+		{"zstd;q=0.5, gzip", encodingGzip},
+		{"zstd, gzip;q=0", encodingZstd},
+		{"gzip;q=0", ""},
+		{"*", encodingGzip},
+		{"GZIP", encodingGzip},
+		{"zstd;q=bad, gzip;q=0.1", encodingGzip},
+	}
+	for _, tc := range tcs {
+		require.Equal(t, tc.expected, negotiateEncoding(tc.acceptEncoding), tc.acceptEncoding)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/lib/denylist"
+	"github.com/filecoin-project/boost/metrics"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipfs/go-libipfs/blocks"
+	"go.opencensus.io/stats"
+)
+
+// Denylist is a list of content that must not be served, in the format of
+// the badbits denylist (see the lib/denylist package).
+// A denylist source is either a file, which is reloaded when it changes, or
+// an http(s) URL, which is fetched periodically.
+type Denylist struct {
+	sources         []*denylistSource
+	refreshInterval time.Duration
+	client          *http.Client
+}
+
+type denylistSource struct {
+	location string
+
+	lk      sync.RWMutex
+	entries denylist.Entries
+	// The modified time of a file, used to check if it needs to be reloaded
+	modTime time.Time
+	// The etag of the last response from a URL
+	etag string
+}
+
+// NewDenylist loads the denylists from the sources (files or URLs).
+// Files are checked for changes, and URLs are fetched again, at each refresh
+// interval.
+func NewDenylist(ctx context.Context, sources []string, refreshInterval time.Duration) (*Denylist, error) {
+	if refreshInterval <= 0 {
+		return nil, fmt.Errorf("the denylist refresh interval must be positive, got %s", refreshInterval)
+	}
+
+	d := &Denylist{refreshInterval: refreshInterval, client: &http.Client{Timeout: time.Minute}}
+	for _, loc := range sources {
+		src := &denylistSource{location: loc}
+		if _, err := d.refresh(ctx, src); err != nil {
+			return nil, err
+		}
+		d.sources = append(d.sources, src)
+	}
+	d.recordSize()
+	return d, nil
+}
+
+// Start refreshes the denylists periodically until the context is
+// cancelled
+func (d *Denylist) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(d.refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			for _, src := range d.sources {
+				updated, err := d.refresh(ctx, src)
+				if err != nil {
+					// Keep using the entries that were loaded previously
+					log.Warnw("refreshing denylist", "source", src.location, "err", err)
+					continue
+				}
+				if updated {
+					log.Infow("reloaded denylist", "source", src.location, "entries", src.size())
+				}
+			}
+			d.recordSize()
+		}
+	}()
+}
+
+func isUrl(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// refresh reloads the denylist source if it has changed
+func (d *Denylist) refresh(ctx context.Context, src *denylistSource) (bool, error) {
+	if isUrl(src.location) {
+		return d.refreshUrl(ctx, src)
+	}
+	return d.refreshFile(src)
+}
+
+func (d *Denylist) refreshFile(src *denylistSource) (bool, error) {
+	fi, err := os.Stat(src.location)
+	if err != nil {
+		return false, fmt.Errorf("reading denylist file: %w", err)
+	}
+	src.lk.RLock()
+	unchanged := src.entries != nil && fi.ModTime().Equal(src.modTime)
+	src.lk.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	f, err := os.Open(src.location)
+	if err != nil {
+		return false, fmt.Errorf("opening denylist file: %w", err)
+	}
+	defer f.Close()
+
+	entries, err := denylist.Parse(f)
+	if err != nil {
+		return false, fmt.Errorf("parsing denylist file %s: %w", src.location, err)
+	}
+
+	src.lk.Lock()
+	src.entries = entries
+	src.modTime = fi.ModTime()
+	src.lk.Unlock()
+	return true, nil
+}
+
+func (d *Denylist) refreshUrl(ctx context.Context, src *denylistSource) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", src.location, nil)
+	if err != nil {
+		return false, fmt.Errorf("creating denylist request: %w", err)
+	}
+	src.lk.RLock()
+	if src.etag != "" {
+		req.Header.Set("If-None-Match", src.etag)
+	}
+	src.lk.RUnlock()
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("fetching denylist: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("fetching denylist %s: status %d", src.location, resp.StatusCode)
+	}
+
+	entries, err := denylist.Parse(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("parsing denylist %s: %w", src.location, err)
+	}
+
+	src.lk.Lock()
+	src.entries = entries
+	src.etag = resp.Header.Get("Etag")
+	src.lk.Unlock()
+	return true, nil
+}
+
+func (src *denylistSource) size() int {
+	src.lk.RLock()
+	defer src.lk.RUnlock()
+	return len(src.entries)
+}
+
+func (d *Denylist) recordSize() {
+	var size int
+	for _, src := range d.sources {
+		size += src.size()
+	}
+	stats.Record(context.Background(), metrics.HttpDenylistEntries.M(int64(size)))
+}
+
+// IsDenied returns true if the CID, or the CID with any prefix of the path,
+// is in the denylist
+func (d *Denylist) IsDenied(c cid.Cid, path []string) bool {
+	for _, src := range d.sources {
+		src.lk.RLock()
+		denied := src.entries.Contains(c, path)
+		src.lk.RUnlock()
+		if denied {
+			return true
+		}
+	}
+	return false
+}
+
+var errDenied = errors.New("content is in the denylist")
+
+// denylistBlockstore refuses to return blocks that are in the denylist, so
+// that denied content isn't served as part of another DAG (or through a
+// path from another root)
+type denylistBlockstore struct {
+	blockstore.Blockstore
+	denylist *Denylist
+}
+
+func (bs *denylistBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if bs.denylist.IsDenied(c, nil) {
+		return nil, fmt.Errorf("%s: %w", c, errDenied)
+	}
+	return bs.Blockstore.Get(ctx, c)
+}
+
+// writeDenied responds with status 410 (Gone) for content in the denylist
+func writeDenied(w http.ResponseWriter, r *http.Request) {
+	stats.Record(r.Context(), metrics.HttpDeniedCount.M(1))
+	writeError(w, r, http.StatusGone, "this content is not available: it has been blocked by the storage provider")
+}
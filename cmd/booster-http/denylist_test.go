@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mocks_booster_http "github.com/filecoin-project/boost/cmd/booster-http/mocks"
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/golang/mock/gomock"
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	"github.com/ipfs/go-merkledag"
+	uio "github.com/ipfs/go-unixfs/io"
+	"github.com/stretchr/testify/require"
+)
+
+// badbitsEntry returns the hashed denylist entry for the CID and path
+func badbitsEntry(c cid.Cid, path string) string {
+	h := sha256.Sum256([]byte(cid.NewCidV1(c.Type(), c.Hash()).String() + "/" + path))
+	return "//" + hex.EncodeToString(h[:])
+}
+
+func TestDenylist(t *testing.T) {
+	ctx := context.Background()
+	hashed := testutil.GenerateCid()
+	clear := testutil.GenerateCid()
+	withPath := testutil.GenerateCid()
+	other := testutil.GenerateCid()
+
+	listFile := filepath.Join(t.TempDir(), "denylist")
+	list := fmt.Sprintf("# badbits\n%s\n\n%s\n/ipfs/%s/a/b\n", badbitsEntry(hashed, ""), clear, withPath)
+	require.NoError(t, os.WriteFile(listFile, []byte(list), 0644))
+
+	d, err := NewDenylist(ctx, []string{listFile}, time.Hour)
+	require.NoError(t, err)
+	require.True(t, d.IsDenied(hashed, nil))
+	require.True(t, d.IsDenied(hashed, []string{"any", "path"}))
+	require.True(t, d.IsDenied(clear, nil))
+	require.False(t, d.IsDenied(withPath, nil))
+	require.False(t, d.IsDenied(withPath, []string{"a"}))
+	require.True(t, d.IsDenied(withPath, []string{"a", "b"}))
+	require.True(t, d.IsDenied(withPath, []string{"a", "b", "c"}))
+	require.False(t, d.IsDenied(other, nil))
+
+	// A CIDv0 matches the entry for the same CID in v1
+	v0 := cid.NewCidV0(testutil.GenerateCid().Hash())
+	require.NoError(t, os.WriteFile(listFile, []byte(badbitsEntry(v0, "")+"\n"), 0644))
+	require.NoError(t, os.Chtimes(listFile, time.Now(), time.Now().Add(time.Minute)))
+
+	// The file is reloaded when it changes
+	updated, err := d.refresh(ctx, d.sources[0])
+	require.NoError(t, err)
+	require.True(t, updated)
+	require.True(t, d.IsDenied(v0, nil))
+	require.False(t, d.IsDenied(hashed, nil))
+	updated, err = d.refresh(ctx, d.sources[0])
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	// An invalid denylist is an error
+	require.NoError(t, os.WriteFile(listFile, []byte("//not-a-hash\n"), 0644))
+	_, err = NewDenylist(ctx, []string{listFile}, time.Hour)
+	require.Error(t, err)
+
+	// The refresh interval must be positive
+	_, err = NewDenylist(ctx, []string{listFile}, 0)
+	require.ErrorContains(t, err, "refresh interval must be positive")
+}
+
+func TestDenylistUrl(t *testing.T) {
+	ctx := context.Background()
+	denied := testutil.GenerateCid()
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Etag", `"v1"`)
+		w.Write([]byte(badbitsEntry(denied, "") + "\n")) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	d, err := NewDenylist(ctx, []string{srv.URL}, time.Hour)
+	require.NoError(t, err)
+	require.True(t, d.IsDenied(denied, nil))
+
+	// The list is only downloaded again if it has changed
+	updated, err := d.refresh(ctx, d.sources[0])
+	require.NoError(t, err)
+	require.False(t, updated)
+	require.EqualValues(t, 2, atomic.LoadInt32(&requests))
+	require.True(t, d.IsDenied(denied, nil))
+}
+
+func TestHttpDenylist(t *testing.T) {
+	ctx := context.Background()
+
+	// Create a directory with two files
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	dserv := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+	dir := uio.NewDirectory(dserv)
+	var fileCids []cid.Cid
+	for i, name := range []string{"allowed", "denied"} {
+		filePath, err := testutil.CreateRandomFile(t.TempDir(), i, 1024)
+		require.NoError(t, err)
+		root, err := testutil.WriteUnixfsDAGTo(filePath, dserv, 1024, 4)
+		require.NoError(t, err)
+		nd, err := dserv.Get(ctx, root)
+		require.NoError(t, err)
+		require.NoError(t, dir.AddChild(ctx, name, nd))
+		fileCids = append(fileCids, root)
+	}
+	dirNode, err := dir.GetNode()
+	require.NoError(t, err)
+	require.NoError(t, dserv.Add(ctx, dirNode))
+	dirRoot := dirNode.Cid()
+	pieceCid := testutil.GenerateCid()
+
+	listFile := filepath.Join(t.TempDir(), "denylist")
+	list := badbitsEntry(fileCids[1], "") + "\n" + badbitsEntry(pieceCid, "") + "\n"
+	require.NoError(t, os.WriteFile(listFile, []byte(list), 0644))
+	denylist, err := NewDenylist(ctx, []string{listFile}, time.Hour)
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	mockHttpServer := mocks_booster_http.NewMockHttpServerApi(ctrl)
+	mockHttpServer.EXPECT().BlockstoreGet(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, c cid.Cid) ([]byte, error) {
+		blk, err := bs.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		return blk.RawData(), nil
+	})
+	httpServer := NewHttpServer("", 7777, false, mockHttpServer, &HttpServerOptions{Denylist: denylist})
+	httpServer.Start(ctx)
+	defer httpServer.Stop() //nolint:errcheck
+	require.Eventually(t, func() bool {
+		response, err := http.Get("http://localhost:7777/info")
+		if err == nil {
+			response.Body.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	get := func(path string) int {
+		response, err := http.Get("http://localhost:7777" + path)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		return response.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, get("/ipfs/"+dirRoot.String()+"/allowed?format=car"))
+	require.Equal(t, http.StatusGone, get("/ipfs/"+fileCids[1].String()+"?format=raw"))
+	// The denied file can't be reached through a path from another root
+	require.Equal(t, http.StatusGone, get("/ipfs/"+dirRoot.String()+"/denied?format=raw"))
+	require.Equal(t, http.StatusGone, get("/ipfs/"+dirRoot.String()+"/denied?format=car"))
+	require.Equal(t, http.StatusGone, get("/piece/"+pieceCid.String()))
+}
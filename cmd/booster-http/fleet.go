@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/filecoin-project/boost/metrics"
+	"github.com/ipfs/go-cid"
+	"go.opencensus.io/stats"
+)
+
+// The headers set on requests that are forwarded to another member of the
+// fleet
+const (
+	// The URL of the member that forwarded the request, so that the member
+	// that receives it serves it rather than forwarding it again
+	fleetForwardedHeader = "X-Booster-Http-Fleet-Forwarded"
+	// The secret shared by the members of the fleet, that shows the request
+	// was forwarded by a member
+	fleetSecretHeader = "X-Booster-Http-Fleet-Secret"
+	// The IP address of the client that sent the original request, so that
+	// per-IP limits, quotas and logs apply to the client rather than to the
+	// member that forwarded the request
+	fleetClientIPHeader = "X-Booster-Http-Fleet-Client-Ip"
+)
+
+// How often the health of the other members of the fleet is checked
+const fleetHealthCheckInterval = 10 * time.Second
+
+// Fleet routes requests between a fleet of booster-http instances that
+// serve the data from the same boost node.
+// Each piece (or payload CID) has an owner, chosen by rendezvous hashing
+// over the healthy members of the fleet, so that the unsealed (and cached)
+// data for a piece is served by the same instance. When an instance receives
+// a request for data that it doesn't own and hasn't cached, it forwards the
+// request to the owner.
+type Fleet struct {
+	self              string
+	secret            string
+	trustForwardedFor bool
+	members           []*fleetMember
+	client            *http.Client
+}
+
+type fleetMember struct {
+	url   string
+	proxy *httputil.ReverseProxy
+
+	lk        sync.Mutex
+	healthy   bool
+	lastCheck time.Time
+	lastErr   string
+}
+
+// FleetMemberStatus is the status of a member of the fleet
+type FleetMemberStatus struct {
+	URL       string     `json:"url"`
+	Self      bool       `json:"self"`
+	Healthy   bool       `json:"healthy"`
+	LastCheck *time.Time `json:"last_check,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// NewFleet creates a fleet with the base URLs of all of its members.
+// The URL of this instance must be one of the members. All members must be
+// configured with the same secret, which is sent with each forwarded request.
+func NewFleet(self string, members []string, secret string, trustForwardedFor bool) (*Fleet, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("a secret shared by the fleet members is required")
+	}
+
+	self = strings.TrimSuffix(self, "/")
+	f := &Fleet{
+		self:              self,
+		secret:            secret,
+		trustForwardedFor: trustForwardedFor,
+		client:            &http.Client{Timeout: 5 * time.Second},
+	}
+
+	foundSelf := false
+	seen := make(map[string]struct{})
+	for _, m := range members {
+		m = strings.TrimSuffix(m, "/")
+		if _, ok := seen[m]; ok {
+			continue
+		}
+		seen[m] = struct{}{}
+
+		if m == self {
+			foundSelf = true
+			f.members = append(f.members, &fleetMember{url: m, healthy: true})
+			continue
+		}
+
+		target, err := url.Parse(m)
+		if err != nil || target.Scheme == "" || target.Host == "" {
+			return nil, fmt.Errorf("fleet member '%s' must be a URL, eg http://10.0.0.2:7777", m)
+		}
+		f.members = append(f.members, &fleetMember{url: m, healthy: true, proxy: f.newProxy(target)})
+	}
+	if !foundSelf {
+		return nil, fmt.Errorf("the URL of this instance (%s) must be one of the fleet members", self)
+	}
+	return f, nil
+}
+
+func (f *Fleet) newProxy(target *url.URL) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		// Get the client IP before the director adds this instance's own
+		// address to X-Forwarded-For
+		ip := clientIP(r, f.trustForwardedFor)
+		director(r)
+		r.Host = target.Host
+		r.Header.Set(fleetForwardedHeader, f.self)
+		r.Header.Set(fleetSecretHeader, f.secret)
+		r.Header.Set(fleetClientIPHeader, ip)
+	}
+	// Stream CAR files and pieces to the client as they are received
+	proxy.FlushInterval = -1
+	return proxy
+}
+
+// isForwarded indicates whether the request was forwarded by a member of
+// the fleet
+func (f *Fleet) isForwarded(r *http.Request) bool {
+	if r.Header.Get(fleetForwardedHeader) == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get(fleetSecretHeader)), []byte(f.secret)) == 1
+}
+
+// fleetHeadersHandler removes the fleet headers from requests that were not
+// forwarded by a member of the fleet, so that a client can't set its own
+// IP address for limits and quotas, or stop its request from being
+// forwarded to the owner of the data
+func fleetHeadersHandler(f *Fleet, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f == nil || !f.isForwarded(r) {
+			r.Header.Del(fleetForwardedHeader)
+			r.Header.Del(fleetClientIPHeader)
+		}
+		r.Header.Del(fleetSecretHeader)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Start periodically checks the health of the other members of the fleet,
+// until the context is cancelled
+func (f *Fleet) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(fleetHealthCheckInterval)
+		defer ticker.Stop()
+		for {
+			f.checkHealth(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (f *Fleet) checkHealth(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, m := range f.members {
+		if m.proxy == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(m *fleetMember) {
+			defer wg.Done()
+			m.setHealth(f.ping(ctx, m))
+		}(m)
+	}
+	wg.Wait()
+}
+
+func (f *Fleet) ping(ctx context.Context, m *fleetMember) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", m.url+"/info", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (m *fleetMember) setHealth(err error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	wasHealthy := m.healthy
+	m.healthy = err == nil
+	m.lastCheck = time.Now()
+	m.lastErr = ""
+	if err != nil {
+		m.lastErr = err.Error()
+	}
+	if wasHealthy && !m.healthy {
+		log.Warnw("fleet member is unhealthy", "member", m.url, "err", err)
+	} else if !wasHealthy && m.healthy {
+		log.Infow("fleet member is healthy again", "member", m.url)
+	}
+}
+
+func (m *fleetMember) isHealthy() bool {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return m.healthy
+}
+
+// owner returns the healthy member of the fleet that owns the key
+func (f *Fleet) owner(key string) *fleetMember {
+	var best *fleetMember
+	var bestScore []byte
+	for _, m := range f.members {
+		if !m.isHealthy() {
+			continue
+		}
+		h := sha256.Sum256([]byte(m.url + "/" + key))
+		if best == nil || bytes.Compare(h[:], bestScore) > 0 {
+			best, bestScore = m, h[:]
+		}
+	}
+	return best
+}
+
+// Status returns the status of each member of the fleet
+func (f *Fleet) Status() []FleetMemberStatus {
+	status := make([]FleetMemberStatus, 0, len(f.members))
+	for _, m := range f.members {
+		m.lk.Lock()
+		st := FleetMemberStatus{URL: m.url, Self: m.proxy == nil, Healthy: m.healthy, LastError: m.lastErr}
+		if !m.lastCheck.IsZero() {
+			lastCheck := m.lastCheck
+			st.LastCheck = &lastCheck
+		}
+		m.lk.Unlock()
+		status = append(status, st)
+	}
+	return status
+}
+
+func (f *Fleet) handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(f.Status()) //nolint:errcheck
+}
+
+// fleetHandler forwards the request to the member of the fleet that owns
+// the CID in the request path, unless this instance owns it or has
+// already cached its data. If the owner can't be reached, the request is
+// served by this instance.
+// The limits, authentication, quotas and retrieval log are applied by the
+// member that serves the request, using the client IP address in the
+// fleetClientIPHeader.
+func (s *HttpServer) fleetHandler(basePath string, cacheKey func(cid.Cid) string, handler http.HandlerFunc) http.HandlerFunc {
+	if s.opts.Fleet == nil {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The request has already been forwarded by another member
+		if r.Header.Get(fleetForwardedHeader) != "" {
+			handler(w, r)
+			return
+		}
+
+		cidStr, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, basePath), "/")
+		c, err := cid.Parse(cidStr)
+		if err != nil {
+			// Let the handler return the error
+			handler(w, r)
+			return
+		}
+
+		// The data is cheapest to serve from this instance if it's
+		// already in the cache
+		if s.opts.Cache != nil && s.opts.Cache.Has(cacheKey(c)) {
+			handler(w, r)
+			return
+		}
+
+		m := s.opts.Fleet.owner(c.String())
+		if m == nil || m.proxy == nil {
+			handler(w, r)
+			return
+		}
+
+		stats.Record(r.Context(), metrics.HttpFleetForwardedCount.M(1))
+		forwardErr := false
+		proxy := *m.proxy
+		proxy.ErrorHandler = func(w http.ResponseWriter, _ *http.Request, err error) {
+			// The response has not been written yet, so serve the (original)
+			// request from this instance
+			forwardErr = true
+			stats.Record(r.Context(), metrics.HttpFleetForwardErrorCount.M(1))
+			m.setHealth(err)
+			handler(w, r)
+		}
+		proxy.ServeHTTP(w, r)
+		if !forwardErr {
+			alog("%s\tGET %s (forwarded to %s)", color.New(color.FgGreen).Sprint("FLEET"), r.URL, m.url)
+		}
+	}
+}
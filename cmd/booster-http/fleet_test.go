@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mocks_booster_http "github.com/filecoin-project/boost/cmd/booster-http/mocks"
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/golang/mock/gomock"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipfs/go-libipfs/blocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpFleet(t *testing.T) {
+	ctx := context.Background()
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+
+	const urlA = "http://localhost:7777"
+	const urlB = "http://localhost:7778"
+	members := []string{urlA, urlB}
+
+	// Create two servers in a fleet that serve blocks from the same
+	// blockstore, and count the blocks each one serves
+	newServer := func(self string, port int, count *int32) (*HttpServer, *Fleet) {
+		ctrl := gomock.NewController(t)
+		mockHttpServer := mocks_booster_http.NewMockHttpServerApi(ctrl)
+		mockHttpServer.EXPECT().BlockstoreGet(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, c cid.Cid) ([]byte, error) {
+			atomic.AddInt32(count, 1)
+			blk, err := bs.Get(ctx, c)
+			if err != nil {
+				return nil, err
+			}
+			return blk.RawData(), nil
+		})
+		fleet, err := NewFleet(self, members, "secret", false)
+		require.NoError(t, err)
+		s := NewHttpServer("", port, false, mockHttpServer, &HttpServerOptions{Fleet: fleet})
+		s.Start(ctx)
+		return s, fleet
+	}
+	var countA, countB int32
+	serverA, fleetA := newServer(urlA, 7777, &countA)
+	defer serverA.Stop() //nolint:errcheck
+	serverB, _ := newServer(urlB, 7778, &countB)
+
+	// Wait for server B to start, so that server A sees it as healthy
+	require.Eventually(t, func() bool {
+		fleetA.checkHealth(ctx)
+		return fleetA.members[1].isHealthy()
+	}, time.Second, 10*time.Millisecond)
+
+	// Create a block that is owned by server B
+	var blk blocks.Block
+	for blk == nil || fleetA.owner(blk.Cid().String()).url != urlB {
+		blk = blocks.NewBlock(testutil.GenerateCid().Bytes())
+	}
+	require.NoError(t, bs.Put(ctx, blk))
+
+	getBlock := func() {
+		response, err := http.Get(urlA + "/ipfs/" + blk.Cid().String() + "?format=raw")
+		require.NoError(t, err)
+		defer response.Body.Close()
+		require.Equal(t, http.StatusOK, response.StatusCode)
+	}
+
+	// A request to server A for the block is forwarded to server B
+	getBlock()
+	require.EqualValues(t, 0, atomic.LoadInt32(&countA))
+	require.EqualValues(t, 1, atomic.LoadInt32(&countB))
+
+	// A client can't stop its request from being forwarded by pretending
+	// that it was forwarded by a member of the fleet
+	spoofed, err := http.NewRequest("GET", urlA+"/ipfs/"+blk.Cid().String()+"?format=raw", nil)
+	require.NoError(t, err)
+	spoofed.Header.Set(fleetForwardedHeader, urlB)
+	spoofed.Header.Set(fleetSecretHeader, "guess")
+	response, err := http.DefaultClient.Do(spoofed)
+	require.NoError(t, err)
+	response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.EqualValues(t, 0, atomic.LoadInt32(&countA))
+	require.EqualValues(t, 2, atomic.LoadInt32(&countB))
+
+	// When server B is down, server A serves the block itself
+	require.NoError(t, serverB.Stop())
+	getBlock()
+	require.EqualValues(t, 1, atomic.LoadInt32(&countA))
+	require.EqualValues(t, 2, atomic.LoadInt32(&countB))
+
+	// Server B is now unhealthy, so server A owns all the data
+	require.Equal(t, urlA, fleetA.owner(blk.Cid().String()).url)
+	response, err = http.Get(urlA + "/fleet")
+	require.NoError(t, err)
+	defer response.Body.Close()
+	var status []FleetMemberStatus
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&status))
+	require.Len(t, status, 2)
+	require.True(t, status[0].Self)
+	require.True(t, status[0].Healthy)
+	require.False(t, status[1].Healthy)
+	require.NotEmpty(t, status[1].LastError)
+}
+
+func TestFleetClientIP(t *testing.T) {
+	f, err := NewFleet("http://a:7777", []string{"http://a:7777", "http://b:7777"}, "secret", false)
+	require.NoError(t, err)
+
+	// Forward a request from a client through the proxy to member b, and
+	// get the client IP that member b sees
+	var ip string
+	handler := fleetHeadersHandler(f, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip = clientIP(r, false)
+	}))
+	clientReq := httptest.NewRequest("GET", "http://a:7777/ipfs/cid", nil)
+	clientReq.RemoteAddr = "1.2.3.4:5678"
+	f.members[1].proxy.Director(clientReq)
+	require.Equal(t, "secret", clientReq.Header.Get(fleetSecretHeader))
+	forwarded := httptest.NewRequest("GET", "http://b:7777/ipfs/cid", nil)
+	forwarded.Header = clientReq.Header
+	forwarded.RemoteAddr = "10.0.0.1:7777"
+	handler.ServeHTTP(httptest.NewRecorder(), forwarded)
+	require.Equal(t, "1.2.3.4", ip)
+
+	// The client IP header is ignored if the request wasn't forwarded by a
+	// member of the fleet
+	spoofed := httptest.NewRequest("GET", "http://b:7777/ipfs/cid", nil)
+	spoofed.RemoteAddr = "5.6.7.8:1234"
+	spoofed.Header.Set(fleetForwardedHeader, "http://a:7777")
+	spoofed.Header.Set(fleetSecretHeader, "wrong")
+	spoofed.Header.Set(fleetClientIPHeader, "1.2.3.4")
+	handler.ServeHTTP(httptest.NewRecorder(), spoofed)
+	require.Equal(t, "5.6.7.8", ip)
+
+	// Without a fleet the header is always ignored
+	handler = fleetHeadersHandler(nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip = clientIP(r, false)
+	}))
+	spoofed.Header.Set(fleetClientIPHeader, "1.2.3.4")
+	handler.ServeHTTP(httptest.NewRecorder(), spoofed)
+	require.Equal(t, "5.6.7.8", ip)
+}
+
+func TestNewFleet(t *testing.T) {
+	_, err := NewFleet("http://a:7777", []string{"http://b:7777"}, "secret", false)
+	require.Error(t, err)
+	_, err = NewFleet("http://a:7777", []string{"http://a:7777", "b:7777"}, "secret", false)
+	require.Error(t, err)
+	_, err = NewFleet("http://a:7777", []string{"http://a:7777", "http://b:7777"}, "", false)
+	require.Error(t, err)
+
+	f, err := NewFleet("http://a:7777/", []string{"http://a:7777", "http://b:7777", "http://c:7777", "http://b:7777/"}, "secret", false)
+	require.NoError(t, err)
+	require.Len(t, f.members, 3)
+
+	// Each member computes the same owner for a key
+	g, err := NewFleet("http://b:7777", []string{"http://c:7777", "http://b:7777", "http://a:7777"}, "secret", false)
+	require.NoError(t, err)
+	owners := make(map[string]int)
+	for i := 0; i < 100; i++ {
+		key := testutil.GenerateCid().String()
+		require.Equal(t, f.owner(key).url, g.owner(key).url)
+		owners[f.owner(key).url]++
+	}
+	// The keys are spread across the members
+	require.Len(t, owners, 3)
+}
@@ -0,0 +1,655 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/filecoin-project/boost/cmd/booster-bitswap/remoteblockstore"
+	"github.com/filecoin-project/boost/markets/utils"
+	"github.com/filecoin-project/boost/metrics"
+	"github.com/filecoin-project/boost/retrievalpolicy"
+	"github.com/filecoin-project/boostd-data/shared/tracing"
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-libipfs/blocks"
+	"github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-unixfs"
+	uio "github.com/ipfs/go-unixfs/io"
+	unixfspb "github.com/ipfs/go-unixfs/pb"
+	"github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+	ipldprime "github.com/ipld/go-ipld-prime"
+	_ "github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipld/go-ipld-prime/traversal"
+	"github.com/ipld/go-ipld-prime/traversal/selector"
+	"github.com/ipld/go-ipld-prime/traversal/selector/builder"
+	textselector "github.com/ipld/go-ipld-selector-text-lite"
+	"go.opencensus.io/stats"
+)
+
+// The trustless gateway serves verifiable responses (blocks and CAR files)
+// for data in the deals on the node, at /ipfs/<cid>[/path].
+// See https://specs.ipfs.tech/http-gateways/trustless-gateway/
+const (
+	ipldRawMediaType = "application/vnd.ipld.raw"
+	ipldCarMediaType = "application/vnd.ipld.car"
+
+	// CAR responses are CARv1 with the blocks in depth-first order and
+	// without any duplicate blocks
+	carContentType = ipldCarMediaType + "; version=1; order=dfs; dups=n"
+)
+
+// The dag-scope query parameter selects which blocks are in a CAR response
+const (
+	// Only the block for the cid at the end of the path
+	dagScopeBlock = "block"
+	// The blocks needed to read the entity at the end of the path, eg all the
+	// blocks of a file, or the blocks of a directory without its children
+	dagScopeEntity = "entity"
+	// The whole DAG under the cid at the end of the path
+	dagScopeAll = "all"
+)
+
+type gatewayRequest struct {
+	root cid.Cid
+	path []string
+	// The verifiable format of the response (raw or car). If empty, the
+	// response is the deserialized file or directory.
+	format   string
+	dagScope string
+	// The byte range in a file to return with dag-scope=entity.
+	// A nil range means the whole entity.
+	entityBytes *entityBytes
+	// An IPLD selector that selects the blocks in a CAR response, starting
+	// from the cid at the end of the path (instead of dag-scope)
+	selector datamodel.Node
+}
+
+// entityBytes is a byte range in a file, as in the entity-bytes query
+// parameter (from:to). The range is inclusive of to, which may be negative
+// to count from the end of the file, or "*" for the end of the file.
+type entityBytes struct {
+	from int64
+	to   *int64
+}
+
+func (s *HttpServer) ipfsBasePath() string {
+	return s.path + "/ipfs/"
+}
+
+func (s *HttpServer) handleGateway(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	ctx, span := tracing.Tracer.Start(r.Context(), "http.ipfs")
+	defer span.End()
+	stats.Record(ctx, metrics.HttpPayloadByCidRequestCount.M(1))
+
+	req, err := parseGatewayRequest(r, s.ipfsBasePath(), s.opts.ServeFiles)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		stats.Record(ctx, metrics.HttpPayloadByCid400ResponseCount.M(1))
+		return
+	}
+
+	if s.opts.Denylist != nil && s.opts.Denylist.IsDenied(req.root, req.path) {
+		writeDenied(w, r)
+		return
+	}
+	if s.opts.RetrievalPolicy != nil && !s.opts.RetrievalPolicy.allowPayload(w, r, req.root) {
+		return
+	}
+
+	bs := remoteblockstore.NewRemoteBlockstore(s.api)
+	if s.opts.Cache != nil {
+		bs = &cachingBlockstore{Blockstore: bs, cache: s.opts.Cache}
+	}
+	if s.opts.Denylist != nil {
+		bs = &denylistBlockstore{Blockstore: bs, denylist: s.opts.Denylist}
+	}
+	if s.opts.RetrievalPolicy != nil {
+		bs = s.opts.RetrievalPolicy.blockstore(r, bs)
+	}
+	switch req.format {
+	case ipldRawMediaType:
+		err = s.serveRawBlock(ctx, w, r, bs, req)
+	case ipldCarMediaType:
+		err = s.serveCar(ctx, w, r, bs, req)
+	default:
+		err = s.serveFile(ctx, w, r, bs, req)
+	}
+	if err != nil {
+		if errors.Is(err, errDenied) {
+			writeDenied(w, r)
+			return
+		}
+		if errors.Is(err, retrievalpolicy.ErrNotAllowed) {
+			recordPolicyRejected(r, err)
+			writeError(w, r, http.StatusForbidden, err.Error())
+			return
+		}
+		if errors.Is(err, errNotUnixfs) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			stats.Record(ctx, metrics.HttpPayloadByCid400ResponseCount.M(1))
+			return
+		}
+		if isNotFoundError(err) {
+			writeError(w, r, http.StatusNotFound, err.Error())
+			stats.Record(ctx, metrics.HttpPayloadByCid404ResponseCount.M(1))
+			return
+		}
+		log.Errorf("getting content for %s: %s", r.URL.Path, err)
+		msg := fmt.Sprintf("server error getting content for %s", r.URL.Path)
+		writeError(w, r, http.StatusInternalServerError, msg)
+		stats.Record(ctx, metrics.HttpPayloadByCid500ResponseCount.M(1))
+		return
+	}
+
+	stats.Record(ctx, metrics.HttpPayloadByCid200ResponseCount.M(1))
+	stats.Record(ctx, metrics.HttpPayloadByCidRequestDuration.M(float64(time.Since(startTime).Milliseconds())))
+}
+
+// parseGatewayRequest parses the request. If serveFiles is true, requests
+// that are not for a verifiable format are for the deserialized file or
+// directory at the path.
+func parseGatewayRequest(r *http.Request, basePath string, serveFiles bool) (*gatewayRequest, error) {
+	// Remove the path up to the cid
+	if len(r.URL.Path) <= len(basePath) {
+		return nil, fmt.Errorf("path '%s' is missing CID", r.URL.Path)
+	}
+	segments := strings.Split(strings.TrimSuffix(r.URL.Path[len(basePath):], "/"), "/")
+	root, err := cid.Parse(segments[0])
+	if err != nil {
+		return nil, fmt.Errorf("parsing CID '%s': %w", segments[0], err)
+	}
+	req := &gatewayRequest{root: root, path: segments[1:]}
+	for _, seg := range req.path {
+		if seg == "" {
+			return nil, fmt.Errorf("path '%s' has an empty path segment", r.URL.Path)
+		}
+	}
+
+	// The response format can be set by the format query parameter or the
+	// Accept header. Only verifiable formats are supported.
+	query := r.URL.Query()
+	switch query.Get("format") {
+	case "raw":
+		req.format = ipldRawMediaType
+	case "car":
+		req.format = ipldCarMediaType
+	case "":
+		req.format = acceptedFormat(r.Header.Get("Accept"))
+		if req.format == "" && !serveFiles {
+			return nil, fmt.Errorf("booster-http only serves verifiable responses: "+
+				"set the format query parameter to raw or car, or the Accept header to %s or %s", ipldRawMediaType, ipldCarMediaType)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format '%s': must be raw or car", query.Get("format"))
+	}
+
+	req.dagScope = query.Get("dag-scope")
+	switch req.dagScope {
+	case "":
+		req.dagScope = dagScopeAll
+	case dagScopeAll, dagScopeEntity, dagScopeBlock:
+	default:
+		return nil, fmt.Errorf("unsupported dag-scope '%s': must be one of %s, %s, %s",
+			req.dagScope, dagScopeBlock, dagScopeEntity, dagScopeAll)
+	}
+
+	if eb := query.Get("entity-bytes"); eb != "" {
+		if req.dagScope != dagScopeEntity {
+			return nil, fmt.Errorf("entity-bytes requires dag-scope=%s", dagScopeEntity)
+		}
+		req.entityBytes, err = parseEntityBytes(eb)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req.selector, err = parseSelector(query, req.dagScope)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.format != ipldCarMediaType && (query.Has("dag-scope") || query.Has("entity-bytes") || req.selector != nil) {
+		return nil, fmt.Errorf("dag-scope, entity-bytes, selector and dm-path are only supported for CAR responses")
+	}
+
+	return req, nil
+}
+
+// acceptedFormat returns the first verifiable format in the Accept header,
+// or the empty string if there isn't one
+func acceptedFormat(accept string) string {
+	for _, mt := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(mt)
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case ipldRawMediaType:
+			return ipldRawMediaType
+		case ipldCarMediaType:
+			if v, ok := params["version"]; ok && v != "1" {
+				continue
+			}
+			if order, ok := params["order"]; ok && order != "dfs" && order != "unk" {
+				continue
+			}
+			return ipldCarMediaType
+		}
+	}
+	return ""
+}
+
+func parseEntityBytes(eb string) (*entityBytes, error) {
+	fromStr, toStr, ok := strings.Cut(eb, ":")
+	if !ok {
+		return nil, fmt.Errorf("parsing entity-bytes '%s': must be of the form from:to", eb)
+	}
+	from, err := strconv.ParseInt(fromStr, 10, 64)
+	if err != nil || from < 0 {
+		return nil, fmt.Errorf("parsing entity-bytes '%s': from must be a non-negative integer", eb)
+	}
+	res := &entityBytes{from: from}
+	if toStr != "*" {
+		to, err := strconv.ParseInt(toStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing entity-bytes '%s': to must be an integer or *", eb)
+		}
+		if to >= 0 && to < from {
+			return nil, fmt.Errorf("parsing entity-bytes '%s': to must not be less than from", eb)
+		}
+		res.to = &to
+	}
+	return res, nil
+}
+
+// parseSelector parses the IPLD selector for a CAR response, which is
+// either the selector query parameter (a dag-json encoded selector), or the
+// dm-path query parameter (a path through the IPLD data model, eg
+// Links/0/Hash, for data that isn't unixfs). With dm-path, the response has
+// the blocks on the path, and then the blocks selected by dag-scope (all or
+// block) from the node at the end of the path.
+func parseSelector(query url.Values, dagScope string) (datamodel.Node, error) {
+	selStr := query.Get("selector")
+	dmPath := query.Get("dm-path")
+	if selStr == "" && dmPath == "" {
+		return nil, nil
+	}
+	if selStr != "" && dmPath != "" {
+		return nil, fmt.Errorf("only one of selector and dm-path can be set")
+	}
+	if query.Has("entity-bytes") {
+		return nil, fmt.Errorf("entity-bytes can't be combined with selector or dm-path")
+	}
+
+	var sel datamodel.Node
+	if selStr != "" {
+		if query.Has("dag-scope") {
+			return nil, fmt.Errorf("dag-scope can't be combined with selector")
+		}
+		nd, err := ipldprime.Decode([]byte(selStr), dagjson.Decode)
+		if err != nil {
+			return nil, fmt.Errorf("decoding dag-json selector '%s': %w", selStr, err)
+		}
+		sel = nd
+	} else {
+		ssb := builder.NewSelectorSpecBuilder(basicnode.Prototype.Any)
+		var target builder.SelectorSpec
+		switch dagScope {
+		case dagScopeAll:
+			target = ssb.ExploreRecursive(selector.RecursionLimitNone(), ssb.ExploreAll(ssb.ExploreRecursiveEdge()))
+		case dagScopeBlock:
+			target = ssb.Matcher()
+		default:
+			return nil, fmt.Errorf("dm-path only supports dag-scope %s or %s", dagScopeAll, dagScopeBlock)
+		}
+		spec, err := textselector.SelectorSpecFromPath(textselector.Expression(dmPath), true, target)
+		if err != nil {
+			return nil, fmt.Errorf("parsing dm-path '%s': %w", dmPath, err)
+		}
+		sel = spec.Node()
+	}
+
+	if _, err := selector.ParseSelector(sel); err != nil {
+		return nil, fmt.Errorf("parsing selector: %w", err)
+	}
+	return sel, nil
+}
+
+// resolve returns the offsets of the first and last byte of the range in a
+// file of the given size
+func (eb *entityBytes) resolve(size uint64) (uint64, uint64) {
+	last := int64(size) - 1
+	to := last
+	if eb.to != nil {
+		to = *eb.to
+		if to < 0 {
+			to = last + 1 + to
+		}
+		if to > last {
+			to = last
+		}
+	}
+	if to < 0 {
+		to = 0
+	}
+	return uint64(eb.from), uint64(to)
+}
+
+func (s *HttpServer) serveRawBlock(ctx context.Context, w http.ResponseWriter, r *http.Request, bs blockstore.Blockstore, req *gatewayRequest) error {
+	c := req.root
+	if len(req.path) > 0 {
+		dserv := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+		nd, err := resolvePath(ctx, dserv, req.root, req.path)
+		if err != nil {
+			return err
+		}
+		c = nd.Cid()
+	}
+
+	blk, err := bs.Get(ctx, c)
+	if err != nil {
+		return fmt.Errorf("getting block %s: %w", c, err)
+	}
+
+	w.Header().Set("Content-Type", ipldRawMediaType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.bin"`, c))
+	w.Header().Set("Etag", `"`+c.String()+`.raw"`)
+	w.Header().Set("X-Ipfs-Path", r.URL.Path)
+	http.ServeContent(w, r, "", lastModified, bytes.NewReader(blk.RawData()))
+	alog("%s\tGET %s (%d bytes)", color.New(color.FgGreen).Sprintf("%d", http.StatusOK), r.URL, len(blk.RawData()))
+	return nil
+}
+
+func (s *HttpServer) serveCar(ctx context.Context, w http.ResponseWriter, r *http.Request, bs blockstore.Blockstore, req *gatewayRequest) error {
+	// Record the blocks loaded while resolving the path, so that the client
+	// can verify the path
+	rec := &recordingBlockstore{Blockstore: bs}
+	dserv := merkledag.NewDAGService(blockservice.New(rec, offline.Exchange(rec)))
+	nd, err := resolvePath(ctx, dserv, req.root, req.path)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", carContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.car"`, req.root))
+	w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+	w.Header().Set("X-Ipfs-Path", r.URL.Path)
+	compressed := compressResponse(w, r)
+	compressed.WriteHeader(http.StatusOK)
+	if r.Method == "HEAD" {
+		alog("%s\tHEAD %s", color.New(color.FgGreen).Sprintf("%d", http.StatusOK), r.URL)
+		return nil
+	}
+
+	start := time.Now()
+	alogAt(start, "%s\tGET %s", color.New(color.FgGreen).Sprintf("%d", http.StatusOK), r.URL)
+
+	// Once the header has been written errors can't be sent to the client,
+	// so they're just logged (the client will receive a truncated CAR file)
+	cw := &gatewayCarWriter{w: compressed, written: make(map[cid.Cid]struct{}), walked: make(map[cid.Cid]struct{})}
+	err = cw.writeCar(ctx, req, rec.blocks, nd, merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs))))
+	if closeErr := compressed.Close(); err == nil {
+		err = closeErr
+	}
+
+	end := time.Now()
+	completeMsg := fmt.Sprintf("GET %s\n%s - %s: %s / %d blocks, %s bytes transferred",
+		r.URL, end.Format(timeFmt), start.Format(timeFmt), time.Since(start), len(cw.written), addCommas(cw.count))
+	if compressed.Encoding() != "" {
+		completeMsg += fmt.Sprintf(" (%s compressed)", compressed.Encoding())
+	}
+	if err == nil {
+		alogAt(end, "%s\t%s", color.New(color.FgGreen).Sprint("DONE"), completeMsg)
+	} else {
+		alogAt(end, "%s\t%s\n%s", color.New(color.FgRed).Sprint("FAIL"), completeMsg, err)
+	}
+	return nil
+}
+
+// resolvePath follows the path of unixfs directory entries from the root
+func resolvePath(ctx context.Context, dserv ipld.DAGService, root cid.Cid, path []string) (ipld.Node, error) {
+	nd, err := dserv.Get(ctx, root)
+	if err != nil {
+		return nil, fmt.Errorf("getting block %s: %w", root, err)
+	}
+
+	for i, name := range path {
+		dir, err := uio.NewDirectoryFromNode(dserv, nd)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a directory: %w", strings.Join(path[:i], "/"), ErrNotFound)
+		}
+		nd, err = dir.Find(ctx, name)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				err = ErrNotFound
+			}
+			return nil, fmt.Errorf("resolving %s: %w", strings.Join(path[:i+1], "/"), err)
+		}
+	}
+	return nd, nil
+}
+
+// recordingBlockstore records the blocks that are read from it, in the
+// order they are read
+type recordingBlockstore struct {
+	blockstore.Blockstore
+	blocks []blocks.Block
+}
+
+func (b *recordingBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	blk, err := b.Blockstore.Get(ctx, c)
+	if err == nil {
+		b.blocks = append(b.blocks, blk)
+	}
+	return blk, err
+}
+
+type gatewayCarWriter struct {
+	w     io.Writer
+	count uint64
+	// The blocks that have been written to the CAR file
+	written map[cid.Cid]struct{}
+	// The blocks whose whole DAG has been written to the CAR file
+	walked map[cid.Cid]struct{}
+}
+
+func (cw *gatewayCarWriter) writeCar(ctx context.Context, req *gatewayRequest, pathBlocks []blocks.Block, nd ipld.Node, dserv ipld.DAGService) error {
+	if err := car.WriteHeader(&car.CarHeader{Roots: []cid.Cid{req.root}, Version: 1}, cw.w); err != nil {
+		return fmt.Errorf("writing CAR header: %w", err)
+	}
+
+	for _, blk := range pathBlocks {
+		if err := cw.writeBlock(blk.Cid(), blk.RawData()); err != nil {
+			return err
+		}
+	}
+
+	if req.selector != nil {
+		return cw.writeSelected(ctx, dserv, nd, req.selector)
+	}
+
+	switch req.dagScope {
+	case dagScopeBlock:
+		return cw.writeNode(nd)
+	case dagScopeEntity:
+		return cw.writeEntity(ctx, dserv, nd, req.entityBytes)
+	default:
+		return cw.writeAll(ctx, dserv, nd)
+	}
+}
+
+func (cw *gatewayCarWriter) writeBlock(c cid.Cid, data []byte) error {
+	if _, ok := cw.written[c]; ok {
+		return nil
+	}
+	if err := carutil.LdWrite(cw.w, c.Bytes(), data); err != nil {
+		return fmt.Errorf("writing block %s: %w", c, err)
+	}
+	cw.written[c] = struct{}{}
+	cw.count += carutil.LdSize(c.Bytes(), data)
+	return nil
+}
+
+func (cw *gatewayCarWriter) writeNode(nd ipld.Node) error {
+	return cw.writeBlock(nd.Cid(), nd.RawData())
+}
+
+// writeAll writes the whole DAG under the node, depth first
+func (cw *gatewayCarWriter) writeAll(ctx context.Context, dserv ipld.DAGService, nd ipld.Node) error {
+	if _, ok := cw.walked[nd.Cid()]; ok {
+		return nil
+	}
+	if err := cw.writeNode(nd); err != nil {
+		return err
+	}
+	for _, lnk := range nd.Links() {
+		child, err := dserv.Get(ctx, lnk.Cid)
+		if err != nil {
+			return fmt.Errorf("getting block %s: %w", lnk.Cid, err)
+		}
+		if err := cw.writeAll(ctx, dserv, child); err != nil {
+			return err
+		}
+	}
+	cw.walked[nd.Cid()] = struct{}{}
+	return nil
+}
+
+// writeSelected writes the blocks that are loaded by a traversal of the
+// selector from the node, in the order they are loaded (depth first)
+func (cw *gatewayCarWriter) writeSelected(ctx context.Context, dserv ipld.DAGService, nd ipld.Node, sel datamodel.Node) error {
+	wdserv := &carWritingDAGService{DAGService: dserv, cw: cw}
+	return utils.TraverseDag(ctx, wdserv, nd.Cid(), sel, func(traversal.Progress, datamodel.Node, traversal.VisitReason) error {
+		return nil
+	})
+}
+
+// carWritingDAGService writes each block that is read from it to the CAR
+type carWritingDAGService struct {
+	ipld.DAGService
+	cw *gatewayCarWriter
+}
+
+func (d *carWritingDAGService) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	nd, err := d.DAGService.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.cw.writeNode(nd); err != nil {
+		return nil, err
+	}
+	return nd, nil
+}
+
+// writeEntity writes the blocks needed to read the unixfs entity (file or
+// directory) at the node. For data that isn't unixfs, it writes the node's
+// block.
+func (cw *gatewayCarWriter) writeEntity(ctx context.Context, dserv ipld.DAGService, nd ipld.Node, eb *entityBytes) error {
+	pn, ok := nd.(*merkledag.ProtoNode)
+	if !ok {
+		// A raw block is a whole file, and other codecs aren't unixfs
+		return cw.writeNode(nd)
+	}
+	fsn, err := unixfs.FSNodeFromBytes(pn.Data())
+	if err != nil {
+		return cw.writeNode(nd)
+	}
+
+	switch fsn.Type() {
+	case unixfspb.Data_File, unixfspb.Data_Raw:
+		if eb == nil {
+			return cw.writeAll(ctx, dserv, nd)
+		}
+		from, to := eb.resolve(fsn.FileSize())
+		return cw.writeFileRange(ctx, dserv, nd, 0, from, to)
+	case unixfspb.Data_HAMTShard:
+		// A sharded directory is made up of the HAMT shards, but doesn't
+		// include the directory entries
+		if err := cw.writeNode(nd); err != nil {
+			return err
+		}
+		for _, lnk := range nd.Links() {
+			child, err := dserv.Get(ctx, lnk.Cid)
+			if err != nil {
+				return fmt.Errorf("getting block %s: %w", lnk.Cid, err)
+			}
+			if isHAMTShard(child) {
+				if err := cw.writeEntity(ctx, dserv, child, nil); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	default:
+		return cw.writeNode(nd)
+	}
+}
+
+// writeFileRange writes the blocks of a unixfs file that are needed to read
+// the bytes from offset from to offset to. The node is the root of the part
+// of the file that starts at the given offset.
+func (cw *gatewayCarWriter) writeFileRange(ctx context.Context, dserv ipld.DAGService, nd ipld.Node, offset uint64, from uint64, to uint64) error {
+	if err := cw.writeNode(nd); err != nil {
+		return err
+	}
+
+	pn, ok := nd.(*merkledag.ProtoNode)
+	if !ok {
+		// A raw block is a leaf of the file
+		return nil
+	}
+	fsn, err := unixfs.FSNodeFromBytes(pn.Data())
+	if err != nil {
+		return fmt.Errorf("decoding unixfs node %s: %w", nd.Cid(), err)
+	}
+	if fsn.NumChildren() != len(pn.Links()) {
+		return fmt.Errorf("unixfs node %s has %d block sizes for %d links", nd.Cid(), fsn.NumChildren(), len(pn.Links()))
+	}
+
+	// The node's data comes before the data in its children
+	childOffset := offset + uint64(len(fsn.Data()))
+	for i, lnk := range pn.Links() {
+		size := fsn.BlockSize(i)
+		if size > 0 && childOffset <= to && childOffset+size-1 >= from {
+			child, err := dserv.Get(ctx, lnk.Cid)
+			if err != nil {
+				return fmt.Errorf("getting block %s: %w", lnk.Cid, err)
+			}
+			if err := cw.writeFileRange(ctx, dserv, child, childOffset, from, to); err != nil {
+				return err
+			}
+		}
+		childOffset += size
+	}
+	return nil
+}
+
+func isHAMTShard(nd ipld.Node) bool {
+	pn, ok := nd.(*merkledag.ProtoNode)
+	if !ok {
+		return false
+	}
+	fsn, err := unixfs.FSNodeFromBytes(pn.Data())
+	return err == nil && fsn.Type() == unixfspb.Data_HAMTShard
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+	"github.com/ipfs/go-blockservice"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-merkledag"
+	uio "github.com/ipfs/go-unixfs/io"
+)
+
+// The deserialized (path) gateway serves the files and directories in unixfs
+// DAGs at /ipfs/<cid>/<path>, so that the data in deals can be browsed.
+// It is only enabled with the --serve-files flag, because the responses
+// can't be verified by the client.
+// See https://specs.ipfs.tech/http-gateways/path-gateway/
+
+var errNotUnixfs = errors.New("not a unixfs file or directory: " +
+	"set the format query parameter to raw or car to get the block or DAG")
+
+func (s *HttpServer) serveFile(ctx context.Context, w http.ResponseWriter, r *http.Request, bs blockstore.Blockstore, req *gatewayRequest) error {
+	dserv := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+	nd, err := resolvePath(ctx, dserv, req.root, req.path)
+	if err != nil {
+		return err
+	}
+
+	dir, err := uio.NewDirectoryFromNode(dserv, nd)
+	if err != nil {
+		name := req.root.String()
+		if len(req.path) > 0 {
+			name = req.path[len(req.path)-1]
+		}
+		return serveUnixfsFile(ctx, w, r, dserv, nd, name)
+	}
+
+	// Relative links in a directory listing (or index.html) only work if
+	// the directory path ends with a slash
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		u := *r.URL
+		u.Path += "/"
+		http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+		alog("%s\tGET %s", color.New(color.FgGreen).Sprintf("%d", http.StatusMovedPermanently), r.URL)
+		return nil
+	}
+
+	// If the directory has an index.html file, serve it instead of the
+	// directory listing
+	idx, err := dir.Find(ctx, "index.html")
+	if err == nil {
+		return serveUnixfsFile(ctx, w, r, dserv, idx, "index.html")
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("getting index.html in %s: %w", r.URL.Path, err)
+	}
+
+	return serveDirListing(ctx, w, r, dir, nd, len(req.path) > 0)
+}
+
+// serveUnixfsFile serves the contents of the file. The content type is
+// detected from the extension of the file name, or if there is no extension
+// from the first bytes of the file.
+func serveUnixfsFile(ctx context.Context, w http.ResponseWriter, r *http.Request, dserv ipld.DAGService, nd ipld.Node, name string) error {
+	dr, err := uio.NewDagReader(ctx, nd, dserv)
+	if err != nil {
+		if errors.Is(err, uio.ErrUnkownNodeType) || errors.Is(err, uio.ErrCantReadSymlinks) || errors.Is(err, uio.ErrIsDir) {
+			return fmt.Errorf("%s: %w", r.URL.Path, errNotUnixfs)
+		}
+		return fmt.Errorf("reading %s: %w", r.URL.Path, err)
+	}
+	defer dr.Close()
+
+	w.Header().Set("Etag", `"`+nd.Cid().String()+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+	w.Header().Set("X-Ipfs-Path", r.URL.Path)
+
+	// http.ServeContent ignores errors when writing to the stream, so watch
+	// for errors
+	var writeErr error
+	writeErrWatcher := &writeErrorWatcher{ResponseWriter: w, onError: func(e error) {
+		writeErr = e
+	}}
+	compressed := compressResponse(writeErrWatcher, r)
+	http.ServeContent(compressed, r, name, lastModified, dr)
+	if err := compressed.Close(); err != nil && writeErr == nil {
+		writeErr = err
+	}
+	if writeErr != nil {
+		alog("%s\tGET %s\n%s", color.New(color.FgRed).Sprint("FAIL"), r.URL, writeErr)
+		return nil
+	}
+	alog("%s\tGET %s (%s bytes)", color.New(color.FgGreen).Sprintf("%d", writeErrWatcher.status), r.URL, addCommas(writeErrWatcher.count))
+	return nil
+}
+
+type dirListingEntry struct {
+	Name string
+	Href string
+	Size string
+	Cid  string
+}
+
+type dirListing struct {
+	Path    string
+	Parent  bool
+	Cid     string
+	Entries []dirListingEntry
+}
+
+var dirListingTemplate = template.Must(template.New("dir").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <title>{{ .Path }}</title>
+    <style>
+      body { font-family: monospace; }
+      td { padding: 0 1em; }
+      .size { text-align: right; }
+    </style>
+  </head>
+  <body>
+    <h2>Index of {{ .Path }}</h2>
+    <div>{{ .Cid }}</div>
+    <table>
+      {{ if .Parent }}<tr><td><a href="../">..</a></td><td></td><td></td></tr>{{ end }}
+      {{ range .Entries }}<tr>
+        <td><a href="{{ .Href }}">{{ .Name }}</a></td>
+        <td class="size">{{ .Size }}</td>
+        <td>{{ .Cid }}</td>
+      </tr>
+      {{ end }}
+    </table>
+  </body>
+</html>
+`))
+
+// serveDirListing serves an HTML page with links to the entries in the
+// directory
+func serveDirListing(ctx context.Context, w http.ResponseWriter, r *http.Request, dir uio.Directory, nd ipld.Node, hasParent bool) error {
+	links, err := dir.Links(ctx)
+	if err != nil {
+		return fmt.Errorf("listing directory %s: %w", r.URL.Path, err)
+	}
+
+	listing := dirListing{
+		Path:   r.URL.Path,
+		Parent: hasParent,
+		Cid:    nd.Cid().String(),
+	}
+	for _, l := range links {
+		listing.Entries = append(listing.Entries, dirListingEntry{
+			Name: l.Name,
+			Href: url.PathEscape(l.Name),
+			Size: humanize.IBytes(l.Size),
+			Cid:  l.Cid.String(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Etag", `"DirIndex-`+nd.Cid().String()+`"`)
+	w.Header().Set("X-Ipfs-Path", r.URL.Path)
+	compressed := compressResponse(w, r)
+	defer compressed.Close() //nolint:errcheck
+	compressed.WriteHeader(http.StatusOK)
+	if r.Method == "HEAD" {
+		alog("%s\tHEAD %s", color.New(color.FgGreen).Sprintf("%d", http.StatusOK), r.URL)
+		return nil
+	}
+	if err := dirListingTemplate.Execute(compressed, listing); err != nil {
+		alog("%s\tGET %s\n%s", color.New(color.FgRed).Sprint("FAIL"), r.URL, err)
+		return nil
+	}
+	alog("%s\tGET %s (%d entries)", color.New(color.FgGreen).Sprintf("%d", http.StatusOK), r.URL, len(links))
+	return nil
+}
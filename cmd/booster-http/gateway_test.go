@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	mocks_booster_http "github.com/filecoin-project/boost/cmd/booster-http/mocks"
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/golang/mock/gomock"
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-merkledag"
+	uio "github.com/ipfs/go-unixfs/io"
+	"github.com/ipld/go-car"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpGateway(t *testing.T) {
+	ctx := context.Background()
+
+	// Create a directory with a file in it
+	filePath, err := testutil.CreateRandomFile(t.TempDir(), 1, 1024*1024)
+	require.NoError(t, err)
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	dserv := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+	fileRoot, err := testutil.WriteUnixfsDAGTo(filePath, dserv, 16*1024, 4)
+	require.NoError(t, err)
+	fileNode, err := dserv.Get(ctx, fileRoot)
+	require.NoError(t, err)
+	dir := uio.NewDirectory(dserv)
+	require.NoError(t, dir.AddChild(ctx, "file", fileNode))
+	dirNode, err := dir.GetNode()
+	require.NoError(t, err)
+	require.NoError(t, dserv.Add(ctx, dirNode))
+	dirRoot := dirNode.Cid()
+
+	// Get all the cids in the file DAG
+	var fileCids []cid.Cid
+	visited := cid.NewSet()
+	require.NoError(t, merkledag.Walk(ctx, merkledag.GetLinksWithDAG(dserv), fileRoot, func(c cid.Cid) bool {
+		if !visited.Visit(c) {
+			return false
+		}
+		fileCids = append(fileCids, c)
+		return true
+	}))
+	require.Greater(t, len(fileCids), 10)
+
+	// Create a new mock Http server that serves blocks from the blockstore
+	ctrl := gomock.NewController(t)
+	mockHttpServer := mocks_booster_http.NewMockHttpServerApi(ctrl)
+	mockHttpServer.EXPECT().BlockstoreGet(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, c cid.Cid) ([]byte, error) {
+		blk, err := bs.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		return blk.RawData(), nil
+	})
+	httpServer := NewHttpServer("", 7777, false, mockHttpServer, nil)
+	httpServer.Start(context.Background())
+	defer httpServer.Stop() //nolint:errcheck
+
+	get := func(path string, accept string) *http.Response {
+		request, err := http.NewRequest("GET", "http://localhost:7777"+path, nil)
+		require.NoError(t, err)
+		if accept != "" {
+			request.Header.Set("Accept", accept)
+		}
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		return response
+	}
+
+	// readCar reads the CAR file in the response and returns the roots and
+	// the cids of the blocks, checking that each block matches its cid
+	readCar := func(response *http.Response) ([]cid.Cid, []cid.Cid) {
+		defer response.Body.Close()
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, carContentType, response.Header.Get("Content-Type"))
+
+		cr, err := car.NewCarReader(response.Body)
+		require.NoError(t, err)
+		var cids []cid.Cid
+		for {
+			blk, err := cr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			chk, err := blk.Cid().Prefix().Sum(blk.RawData())
+			require.NoError(t, err)
+			require.Equal(t, blk.Cid(), chk)
+			cids = append(cids, blk.Cid())
+		}
+		return cr.Header.Roots, cids
+	}
+
+	t.Run("raw block", func(t *testing.T) {
+		response := get("/ipfs/"+fileRoot.String()+"?format=raw", "")
+		defer response.Body.Close()
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, ipldRawMediaType, response.Header.Get("Content-Type"))
+		out, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		require.Equal(t, fileNode.RawData(), out)
+
+		// The format can also be set with the Accept header
+		response = get("/ipfs/"+dirRoot.String()+"/file", ipldRawMediaType)
+		defer response.Body.Close()
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		out, err = io.ReadAll(response.Body)
+		require.NoError(t, err)
+		require.Equal(t, fileNode.RawData(), out)
+	})
+
+	t.Run("car with the whole dag", func(t *testing.T) {
+		roots, cids := readCar(get("/ipfs/"+fileRoot.String()+"?format=car", ""))
+		require.Equal(t, []cid.Cid{fileRoot}, roots)
+		require.Equal(t, fileCids, cids)
+
+		// The blocks on the path are included
+		roots, cids = readCar(get("/ipfs/"+dirRoot.String()+"/file", ipldCarMediaType+"; version=1"))
+		require.Equal(t, []cid.Cid{dirRoot}, roots)
+		require.Equal(t, append([]cid.Cid{dirRoot}, fileCids...), cids)
+	})
+
+	t.Run("car with dag-scope", func(t *testing.T) {
+		_, cids := readCar(get("/ipfs/"+dirRoot.String()+"?format=car&dag-scope=block", ""))
+		require.Equal(t, []cid.Cid{dirRoot}, cids)
+
+		// The entity for a directory is just the directory node
+		_, cids = readCar(get("/ipfs/"+dirRoot.String()+"?format=car&dag-scope=entity", ""))
+		require.Equal(t, []cid.Cid{dirRoot}, cids)
+
+		// The entity for a file is the whole file
+		_, cids = readCar(get("/ipfs/"+dirRoot.String()+"/file?format=car&dag-scope=entity", ""))
+		require.Equal(t, append([]cid.Cid{dirRoot}, fileCids...), cids)
+	})
+
+	t.Run("car with entity-bytes", func(t *testing.T) {
+		readRange := func(entityBytes string) []byte {
+			_, cids := readCar(get("/ipfs/"+fileRoot.String()+"?format=car&dag-scope=entity&entity-bytes="+entityBytes, ""))
+			require.Less(t, len(cids), len(fileCids))
+
+			// Read the file from just the blocks in the CAR file
+			rangeBs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+			for _, c := range cids {
+				blk, err := bs.Get(ctx, c)
+				require.NoError(t, err)
+				require.NoError(t, rangeBs.Put(ctx, blk))
+			}
+			rangeDserv := merkledag.NewDAGService(blockservice.New(rangeBs, offline.Exchange(rangeBs)))
+			nd, err := rangeDserv.Get(ctx, fileRoot)
+			require.NoError(t, err)
+			r, err := uio.NewDagReader(ctx, nd, rangeDserv)
+			require.NoError(t, err)
+			return readAt(t, r, entityBytes)
+		}
+
+		fileBytes, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		size := len(fileBytes)
+		require.Equal(t, fileBytes[100:200001], readRange("100:200000"))
+		require.Equal(t, fileBytes[size-1000:], readRange(fmt.Sprintf("%d:*", size-1000)))
+		require.Equal(t, fileBytes[500000:size-99], readRange("500000:-100"))
+	})
+
+	t.Run("car with selector", func(t *testing.T) {
+		// A selector for the whole DAG
+		all := url.QueryEscape(`{"R":{"l":{"none":{}},":>":{"a":{">":{"@":{}}}}}}`)
+		roots, cids := readCar(get("/ipfs/"+fileRoot.String()+"?format=car&selector="+all, ""))
+		require.Equal(t, []cid.Cid{fileRoot}, roots)
+		require.Equal(t, fileCids, cids)
+
+		// The selector starts from the node at the end of the path
+		_, cids = readCar(get("/ipfs/"+dirRoot.String()+"/file?format=car&selector="+all, ""))
+		require.Equal(t, append([]cid.Cid{dirRoot}, fileCids...), cids)
+
+		// A data model path through the directory node to the file
+		_, cids = readCar(get("/ipfs/"+dirRoot.String()+"?format=car&dm-path=Links/0/Hash&dag-scope=block", ""))
+		require.Equal(t, []cid.Cid{dirRoot, fileRoot}, cids)
+		_, cids = readCar(get("/ipfs/"+dirRoot.String()+"?format=car&dm-path=Links/0/Hash", ""))
+		require.Equal(t, append([]cid.Cid{dirRoot}, fileCids...), cids)
+
+		for _, query := range []string{
+			"format=car&selector=" + url.QueryEscape(`{"bad":{}}`),
+			"format=car&selector=" + all + "&dag-scope=block",
+			"format=car&selector=" + all + "&dm-path=Links",
+			"format=car&dm-path=Links/0/Hash&dag-scope=entity",
+			"format=raw&dm-path=Links/0/Hash",
+		} {
+			response := get("/ipfs/"+dirRoot.String()+"?"+query, "")
+			response.Body.Close()
+			require.Equal(t, http.StatusBadRequest, response.StatusCode, query)
+		}
+	})
+
+	t.Run("compressed car", func(t *testing.T) {
+		request, err := http.NewRequest("GET", "http://localhost:7777/ipfs/"+fileRoot.String()+"?format=car", nil)
+		require.NoError(t, err)
+		request.Header.Set("Accept-Encoding", "gzip, zstd")
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "zstd", response.Header.Get("Content-Encoding"))
+
+		dec, err := zstd.NewReader(response.Body)
+		require.NoError(t, err)
+		defer dec.Close()
+		response.Body = io.NopCloser(dec)
+		roots, cids := readCar(response)
+		require.Equal(t, []cid.Cid{fileRoot}, roots)
+		require.Equal(t, fileCids, cids)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		response := get("/ipfs/"+fileRoot.String(), "text/html")
+		defer response.Body.Close()
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		response = get("/ipfs/"+fileRoot.String()+"?format=car&dag-scope=all&entity-bytes=0:10", "")
+		defer response.Body.Close()
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		response = get("/ipfs/"+dirRoot.String()+"/missing?format=car", "")
+		defer response.Body.Close()
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+
+		response = get("/ipfs/"+testutil.GenerateCid().String()+"?format=raw", "")
+		defer response.Body.Close()
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+}
+
+func TestHttpGatewayFiles(t *testing.T) {
+	ctx := context.Background()
+
+	// Create a directory with a text file, a file with no extension and a
+	// sub-directory with an index.html file
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	dserv := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+	addFile := func(dir uio.Directory, name string, content []byte) {
+		path := filepath.Join(t.TempDir(), name)
+		require.NoError(t, os.WriteFile(path, content, 0644))
+		root, err := testutil.WriteUnixfsDAGTo(path, dserv, 1024, 4)
+		require.NoError(t, err)
+		nd, err := dserv.Get(ctx, root)
+		require.NoError(t, err)
+		require.NoError(t, dir.AddChild(ctx, name, nd))
+	}
+	addDir := func(dir uio.Directory) ipld.Node {
+		nd, err := dir.GetNode()
+		require.NoError(t, err)
+		require.NoError(t, dserv.Add(ctx, nd))
+		return nd
+	}
+
+	text := []byte(strings.Repeat("hello world\n", 1000))
+	randPath, err := testutil.CreateRandomFile(t.TempDir(), 1, 64*1024)
+	require.NoError(t, err)
+	randBytes, err := os.ReadFile(randPath)
+	require.NoError(t, err)
+	index := []byte("<html><body>index</body></html>")
+
+	subDir := uio.NewDirectory(dserv)
+	addFile(subDir, "index.html", index)
+	dir := uio.NewDirectory(dserv)
+	addFile(dir, "file.txt", text)
+	addFile(dir, "data", randBytes)
+	require.NoError(t, dir.AddChild(ctx, "sub dir", addDir(subDir)))
+	dirRoot := addDir(dir).Cid()
+
+	ctrl := gomock.NewController(t)
+	mockHttpServer := mocks_booster_http.NewMockHttpServerApi(ctrl)
+	mockHttpServer.EXPECT().BlockstoreGet(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, c cid.Cid) ([]byte, error) {
+		blk, err := bs.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		return blk.RawData(), nil
+	})
+	httpServer := NewHttpServer("", 7777, false, mockHttpServer, &HttpServerOptions{ServeFiles: true})
+	httpServer.Start(context.Background())
+	defer httpServer.Stop() //nolint:errcheck
+
+	get := func(path string, headers map[string]string) (*http.Response, []byte) {
+		request, err := http.NewRequest("GET", "http://localhost:7777"+path, nil)
+		require.NoError(t, err)
+		for k, v := range headers {
+			request.Header.Set(k, v)
+		}
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		return response, body
+	}
+
+	t.Run("files", func(t *testing.T) {
+		response, body := get("/ipfs/"+dirRoot.String()+"/file.txt", nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "text/plain; charset=utf-8", response.Header.Get("Content-Type"))
+		require.Equal(t, text, body)
+
+		// The content type of a file with no extension is detected from
+		// its content
+		response, body = get("/ipfs/"+dirRoot.String()+"/data", nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "application/octet-stream", response.Header.Get("Content-Type"))
+		require.Equal(t, randBytes, body)
+
+		response, body = get("/ipfs/"+dirRoot.String()+"/data", map[string]string{"Range": "bytes=1000-1999"})
+		require.Equal(t, http.StatusPartialContent, response.StatusCode)
+		require.Equal(t, randBytes[1000:2000], body)
+	})
+
+	t.Run("directories", func(t *testing.T) {
+		// A directory without a trailing slash is redirected to the path with
+		// a slash
+		response, body := get("/ipfs/"+dirRoot.String(), nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "/ipfs/"+dirRoot.String()+"/", response.Request.URL.Path)
+		require.Equal(t, "text/html; charset=utf-8", response.Header.Get("Content-Type"))
+		for _, link := range []string{`href="file.txt"`, `href="data"`, `href="sub%20dir"`} {
+			require.Contains(t, string(body), link)
+		}
+
+		// A directory with an index.html file serves the file
+		response, body = get("/ipfs/"+dirRoot.String()+"/sub%20dir/", nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "text/html; charset=utf-8", response.Header.Get("Content-Type"))
+		require.Equal(t, index, body)
+	})
+
+	t.Run("compressed", func(t *testing.T) {
+		response, body := get("/ipfs/"+dirRoot.String()+"/file.txt", map[string]string{"Accept-Encoding": "gzip"})
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "gzip", response.Header.Get("Content-Encoding"))
+		require.Less(t, len(body), len(text))
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		out, err := io.ReadAll(gz)
+		require.NoError(t, err)
+		require.Equal(t, text, out)
+
+		// A range request is not compressed
+		response, body = get("/ipfs/"+dirRoot.String()+"/file.txt", map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-99"})
+		require.Equal(t, http.StatusPartialContent, response.StatusCode)
+		require.Empty(t, response.Header.Get("Content-Encoding"))
+		require.Equal(t, text[:100], body)
+	})
+
+	t.Run("verifiable formats", func(t *testing.T) {
+		// Verifiable formats are still served when files are enabled
+		response, _ := get("/ipfs/"+dirRoot.String()+"/file.txt?format=car", nil)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, carContentType, response.Header.Get("Content-Type"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		response, _ := get("/ipfs/"+dirRoot.String()+"/missing", nil)
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+
+		response, _ = get("/ipfs/"+dirRoot.String()+"/file.txt?dag-scope=block", nil)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+}
+
+// readAt reads the range of bytes in entity-bytes format (from:to) from the
+// file
+func readAt(t *testing.T, r uio.DagReader, entityBytes string) []byte {
+	eb, err := parseEntityBytes(entityBytes)
+	require.NoError(t, err)
+	from, to := eb.resolve(r.Size())
+	_, err = r.Seek(int64(from), io.SeekStart)
+	require.NoError(t, err)
+	buf := make([]byte, to-from+1)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(t, err)
+	return buf
+}
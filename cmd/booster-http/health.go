@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The maximum time a readiness check can take before it fails
+const readinessCheckTimeout = 5 * time.Second
+
+// ReadinessCheck checks that a service that booster-http depends on to
+// serve data (eg the boost node or the full node) is available
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type readinessCheckResult struct {
+	Name       string `json:"name"`
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+type readiness struct {
+	Ready  bool                   `json:"ready"`
+	Checks []readinessCheckResult `json:"checks"`
+}
+
+// handleHealthz reports that the process is alive, without checking the
+// services it depends on (for liveness probes)
+func (s *HttpServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok\n")) //nolint:errcheck
+}
+
+// handleReadyz runs the readiness checks, and responds with status 200 if
+// all of them pass, or 503 (Service Unavailable) if any fail, so that a load
+// balancer stops sending requests to an instance that can't serve data
+func (s *HttpServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	res := s.checkReadiness(r.Context())
+
+	status := http.StatusOK
+	if !res.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res) //nolint:errcheck
+}
+
+func (s *HttpServer) checkReadiness(ctx context.Context) *readiness {
+	res := &readiness{Ready: true, Checks: make([]readinessCheckResult, len(s.opts.ReadinessChecks))}
+
+	// Run the checks in parallel
+	var wg sync.WaitGroup
+	for i, check := range s.opts.ReadinessChecks {
+		wg.Add(1)
+		go func(i int, check ReadinessCheck) {
+			defer wg.Done()
+
+			start := time.Now()
+			checkCtx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+			defer cancel()
+			err := check.Check(checkCtx)
+
+			res.Checks[i] = readinessCheckResult{
+				Name:       check.Name,
+				OK:         err == nil,
+				DurationMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				res.Checks[i].Error = err.Error()
+			}
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, c := range res.Checks {
+		if !c.OK {
+			log.Warnw("readiness check failed", "check", c.Name, "err", c.Error)
+			res.Ready = false
+		}
+	}
+	return res
+}
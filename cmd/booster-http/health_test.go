@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpReadiness(t *testing.T) {
+	var storageErr error
+	s := NewHttpServer("", 7777, false, nil, &HttpServerOptions{
+		ReadinessChecks: []ReadinessCheck{{
+			Name:  "boost-api",
+			Check: func(ctx context.Context) error { return nil },
+		}, {
+			Name:  "storage-api",
+			Check: func(ctx context.Context) error { return storageErr },
+		}},
+	})
+
+	readyz := func() (int, readiness) {
+		rec := httptest.NewRecorder()
+		s.handleReadyz(rec, httptest.NewRequest("GET", "/readyz", nil))
+		var res readiness
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return rec.Code, res
+	}
+
+	code, res := readyz()
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, res.Ready)
+	require.Len(t, res.Checks, 2)
+
+	// If any check fails the server is not ready
+	storageErr = errors.New("connection refused")
+	code, res = readyz()
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.False(t, res.Ready)
+	require.True(t, res.Checks[0].OK)
+	require.False(t, res.Checks[1].OK)
+	require.Equal(t, "storage-api", res.Checks[1].Name)
+	require.Equal(t, "connection refused", res.Checks[1].Error)
+
+	// The liveness endpoint doesn't depend on the checks
+	rec := httptest.NewRecorder()
+	s.handleHealthz(rec, httptest.NewRequest("GET", "/healthz", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+}
@@ -4,7 +4,10 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"testing"
@@ -22,7 +25,7 @@ func TestNewHttpServer(t *testing.T) {
 
 	// Create a new mock Http server
 	ctrl := gomock.NewController(t)
-	httpServer := NewHttpServer("", 7777, false, mocks_booster_http.NewMockHttpServerApi(ctrl))
+	httpServer := NewHttpServer("", 7777, false, mocks_booster_http.NewMockHttpServerApi(ctrl), nil)
 	httpServer.Start(context.Background())
 
 	// Check that server is up
@@ -40,7 +43,7 @@ func TestHttpGzipResponse(t *testing.T) {
 	// Create a new mock Http server with custom functions
 	ctrl := gomock.NewController(t)
 	mockHttpServer := mocks_booster_http.NewMockHttpServerApi(ctrl)
-	httpServer := NewHttpServer("", 7777, false, mockHttpServer)
+	httpServer := NewHttpServer("", 7777, false, mockHttpServer, nil)
 	httpServer.Start(context.Background())
 
 	// Create mock unsealed file for piece/car
@@ -100,12 +103,139 @@ func TestHttpGzipResponse(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestHttpRangeRequest(t *testing.T) {
+
+	// Create a new mock Http server with custom functions
+	ctrl := gomock.NewController(t)
+	mockHttpServer := mocks_booster_http.NewMockHttpServerApi(ctrl)
+	httpServer := NewHttpServer("", 7777, false, mockHttpServer, nil)
+	httpServer.Start(context.Background())
+
+	// Create mock unsealed file for piece/car
+	f, err := os.Open(testFile)
+	require.NoError(t, err)
+	defer f.Close()
+	testFileBytes, err := io.ReadAll(f)
+	require.NoError(t, err)
+
+	pieceCid, err := cid.Parse("bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi")
+	require.NoError(t, err)
+	pieceInfo := piecestore.PieceInfo{
+		PieceCID: pieceCid,
+		Deals: []piecestore.DealInfo{{
+			DealID:   1234567,
+			SectorID: 0,
+			Offset:   1233,
+			Length:   123,
+		}},
+	}
+
+	mockHttpServer.EXPECT().UnsealSectorAt(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(f, nil)
+	mockHttpServer.EXPECT().IsUnsealed(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(true, nil)
+	mockHttpServer.EXPECT().GetPieceInfo(gomock.Any()).AnyTimes().Return(&pieceInfo, nil)
+
+	get := func(headers map[string]string) *http.Response {
+		request, err := http.NewRequest("GET", "http://localhost:7777/piece/"+pieceCid.String(), nil)
+		require.NoError(t, err)
+		for k, v := range headers {
+			request.Header.Set(k, v)
+		}
+		// Prevent the http client from adding an Accept-Encoding header
+		response, err := (&http.Transport{DisableCompression: true}).RoundTrip(request)
+		require.NoError(t, err)
+		return response
+	}
+
+	size := len(testFileBytes)
+	require.Greater(t, size, 200)
+
+	t.Run("single range", func(t *testing.T) {
+		// A range request is not gzipped, even if the client accepts gzip
+		response := get(map[string]string{"Range": "bytes=10-109", "Accept-Encoding": "gzip"})
+		defer response.Body.Close()
+		require.Equal(t, http.StatusPartialContent, response.StatusCode)
+		require.Empty(t, response.Header.Get("Content-Encoding"))
+		require.Equal(t, fmt.Sprintf("bytes 10-109/%d", size), response.Header.Get("Content-Range"))
+
+		out, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		require.Equal(t, testFileBytes[10:110], out)
+	})
+
+	t.Run("resume from offset", func(t *testing.T) {
+		response := get(map[string]string{"Range": "bytes=100-"})
+		defer response.Body.Close()
+		require.Equal(t, http.StatusPartialContent, response.StatusCode)
+		require.Equal(t, "bytes", response.Header.Get("Accept-Ranges"))
+
+		out, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		require.Equal(t, testFileBytes[100:], out)
+	})
+
+	t.Run("multiple ranges", func(t *testing.T) {
+		response := get(map[string]string{"Range": "bytes=0-9,100-149"})
+		defer response.Body.Close()
+		require.Equal(t, http.StatusPartialContent, response.StatusCode)
+
+		mediaType, params, err := mime.ParseMediaType(response.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		require.Equal(t, "multipart/byteranges", mediaType)
+
+		mr := multipart.NewReader(response.Body, params["boundary"])
+		expected := []struct {
+			contentRange string
+			data         []byte
+		}{
+			{fmt.Sprintf("bytes 0-9/%d", size), testFileBytes[0:10]},
+			{fmt.Sprintf("bytes 100-149/%d", size), testFileBytes[100:150]},
+		}
+		for _, exp := range expected {
+			part, err := mr.NextPart()
+			require.NoError(t, err)
+			require.Equal(t, exp.contentRange, part.Header.Get("Content-Range"))
+			require.Equal(t, "application/piece", part.Header.Get("Content-Type"))
+			out, err := io.ReadAll(part)
+			require.NoError(t, err)
+			require.Equal(t, exp.data, out)
+		}
+		_, err = mr.NextPart()
+		require.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("if-range", func(t *testing.T) {
+		// If the Etag matches the range is served
+		response := get(map[string]string{"Range": "bytes=10-19", "If-Range": `"` + pieceCid.String() + `"`})
+		defer response.Body.Close()
+		require.Equal(t, http.StatusPartialContent, response.StatusCode)
+		require.Equal(t, `"`+pieceCid.String()+`"`, response.Header.Get("Etag"))
+
+		// If the Etag doesn't match the whole piece is served
+		response = get(map[string]string{"Range": "bytes=10-19", "If-Range": `"other"`})
+		defer response.Body.Close()
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		out, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		require.Equal(t, testFileBytes, out)
+	})
+
+	t.Run("unsatisfiable range", func(t *testing.T) {
+		response := get(map[string]string{"Range": fmt.Sprintf("bytes=%d-", size+10)})
+		defer response.Body.Close()
+		require.Equal(t, http.StatusRequestedRangeNotSatisfiable, response.StatusCode)
+	})
+
+	// Stop the server
+	err = httpServer.Stop()
+	require.NoError(t, err)
+}
+
 func TestHttpInfo(t *testing.T) {
 	var v apiVersion
 
 	// Create a new mock Http server
 	ctrl := gomock.NewController(t)
-	httpServer := NewHttpServer("", 7777, false, mocks_booster_http.NewMockHttpServerApi(ctrl))
+	httpServer := NewHttpServer("", 7777, false, mocks_booster_http.NewMockHttpServerApi(ctrl), nil)
 	httpServer.Start(context.Background())
 
 	response, err := http.Get("http://localhost:7777/info")
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/metrics"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"golang.org/x/time/rate"
+)
+
+// Clients that haven't made a request for this long are forgotten by the
+// limiter
+const limitClientExpiry = 10 * time.Minute
+
+// LimitOptions are the limits that apply to each client IP address.
+// A zero value means no limit.
+type LimitOptions struct {
+	// The maximum number of requests per second
+	RequestsPerSecond float64
+	// The number of requests that can be made in a burst, before the
+	// RequestsPerSecond limit applies
+	RequestBurst int
+	// The maximum number of requests that are being served at the same time
+	MaxConcurrent int
+	// The maximum number of bytes per second sent across all of the
+	// client's responses
+	BytesPerSecond int64
+	// Identify clients by the X-Forwarded-For header instead of the address
+	// of the connection. Only enable this behind a trusted reverse proxy.
+	TrustForwardedFor bool
+}
+
+// Limiter limits the rate of requests, the number of concurrent requests and
+// the bandwidth used by each client IP address, so that a single client
+// can't saturate the uplink or the unsealing capacity of the SP
+type Limiter struct {
+	opts LimitOptions
+
+	lk        sync.Mutex
+	clients   map[string]*limitedClient
+	lastSweep time.Time
+}
+
+type limitedClient struct {
+	requests  *rate.Limiter
+	bandwidth *rate.Limiter
+	active    int
+	lastSeen  time.Time
+}
+
+// NewLimiter creates a limiter with the given per-IP limits
+func NewLimiter(opts LimitOptions) *Limiter {
+	if opts.RequestBurst <= 0 {
+		opts.RequestBurst = 1
+	}
+	return &Limiter{
+		opts:      opts,
+		clients:   make(map[string]*limitedClient),
+		lastSweep: time.Now(),
+	}
+}
+
+// acquire checks whether the client can make a request now. If it can, the
+// request counts towards the client's concurrent requests until release
+// is called. If it can't, acquire returns how long the client should wait
+// before retrying, and the reason the request was limited.
+func (l *Limiter) acquire(ip string, now time.Time) (*limitedClient, time.Duration, string) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	// Periodically remove clients that haven't been seen for a while so that
+	// the map doesn't grow without bound
+	if now.Sub(l.lastSweep) > time.Minute {
+		for k, cl := range l.clients {
+			if cl.active == 0 && now.Sub(cl.lastSeen) > limitClientExpiry {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	cl, ok := l.clients[ip]
+	if !ok {
+		cl = &limitedClient{}
+		if l.opts.RequestsPerSecond > 0 {
+			cl.requests = rate.NewLimiter(rate.Limit(l.opts.RequestsPerSecond), l.opts.RequestBurst)
+		}
+		if l.opts.BytesPerSecond > 0 {
+			// Allow up to one second's worth of data in a burst
+			cl.bandwidth = rate.NewLimiter(rate.Limit(l.opts.BytesPerSecond), int(l.opts.BytesPerSecond))
+		}
+		l.clients[ip] = cl
+	}
+	cl.lastSeen = now
+
+	if l.opts.MaxConcurrent > 0 && cl.active >= l.opts.MaxConcurrent {
+		return nil, time.Second, "concurrency"
+	}
+	if cl.requests != nil {
+		res := cl.requests.ReserveN(now, 1)
+		if delay := res.DelayFrom(now); delay > 0 {
+			res.CancelAt(now)
+			return nil, delay, "rate"
+		}
+	}
+
+	cl.active++
+	return cl, 0, ""
+}
+
+func (l *Limiter) release(cl *limitedClient) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	cl.active--
+	cl.lastSeen = time.Now()
+}
+
+// wrap returns a handler that rejects requests from clients that exceed
+// the request rate or concurrency limits with http status 429 (Too Many
+// Requests), and that limits the bandwidth of the response
+func (l *Limiter) wrap(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r, l.opts.TrustForwardedFor)
+		cl, retryAfter, reason := l.acquire(ip, time.Now())
+		if cl == nil {
+			ctx, _ := tag.New(r.Context(), tag.Upsert(metrics.HttpLimitReason, reason))
+			stats.Record(ctx, metrics.HttpLimitedCount.M(1))
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			writeError(w, r, http.StatusTooManyRequests, "too many requests ("+reason+" limit)")
+			return
+		}
+		defer l.release(cl)
+
+		if cl.bandwidth != nil {
+			w = &throttledResponseWriter{ResponseWriter: w, ctx: r.Context(), lim: cl.bandwidth}
+		}
+		handler(w, r)
+	}
+}
+
+// throttledResponseWriter waits before each write so that the client's
+// responses don't exceed its bandwidth limit
+type throttledResponseWriter struct {
+	http.ResponseWriter
+	ctx context.Context
+	lim *rate.Limiter
+}
+
+func (w *throttledResponseWriter) Write(bz []byte) (int, error) {
+	var written int
+	for len(bz) > 0 {
+		// A wait can't be for more than the burst size
+		n := len(bz)
+		if n > w.lim.Burst() {
+			n = w.lim.Burst()
+		}
+		if err := w.lim.WaitN(w.ctx, n); err != nil {
+			return written, err
+		}
+		count, err := w.ResponseWriter.Write(bz[:n])
+		written += count
+		if err != nil {
+			return written, err
+		}
+		bz = bz[n:]
+	}
+	return written, nil
+}
+
+// clientIP returns the IP address of the client that made the request.
+// If trustForwardedFor is true, the IP address is read from the
+// X-Forwarded-For header set by a reverse proxy.
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	// The header is removed from requests that weren't forwarded by a member
+	// of the fleet (see fleetHeadersHandler), so it can always be trusted
+	if ip := r.Header.Get(fleetClientIPHeader); ip != "" {
+		return ip
+	}
+	if trustForwardedFor {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			// The left-most address is the original client
+			ip, _, _ := strings.Cut(fwd, ",")
+			return strings.TrimSpace(ip)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiterRequestRate(t *testing.T) {
+	l := NewLimiter(LimitOptions{RequestsPerSecond: 1, RequestBurst: 2})
+	handler := l.wrap(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	get := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/piece/bafy", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	// The burst is allowed, then requests are limited
+	require.Equal(t, http.StatusOK, get("10.0.0.1:1234").Code)
+	require.Equal(t, http.StatusOK, get("10.0.0.1:1234").Code)
+	rec := get("10.0.0.1:5678")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	// Other clients have their own limit
+	require.Equal(t, http.StatusOK, get("10.0.0.2:1234").Code)
+}
+
+func TestLimiterConcurrency(t *testing.T) {
+	l := NewLimiter(LimitOptions{MaxConcurrent: 2})
+	now := time.Now()
+
+	cl1, _, _ := l.acquire("10.0.0.1", now)
+	require.NotNil(t, cl1)
+	cl2, _, _ := l.acquire("10.0.0.1", now)
+	require.NotNil(t, cl2)
+	cl, retryAfter, reason := l.acquire("10.0.0.1", now)
+	require.Nil(t, cl)
+	require.Equal(t, time.Second, retryAfter)
+	require.Equal(t, "concurrency", reason)
+	cl, _, _ = l.acquire("10.0.0.2", now)
+	require.NotNil(t, cl)
+
+	// When a request completes another can be made
+	l.release(cl1)
+	cl, _, _ = l.acquire("10.0.0.1", now)
+	require.NotNil(t, cl)
+}
+
+func TestLimiterBandwidth(t *testing.T) {
+	l := NewLimiter(LimitOptions{BytesPerSecond: 1000})
+	content := bytes.Repeat([]byte("a"), 2500)
+	handler := l.wrap(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content) //nolint:errcheck
+	})
+
+	// The first second's worth of data is sent immediately (the burst), the
+	// rest at 1000 bytes per second
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/piece/bafy", nil))
+	require.Equal(t, content, rec.Body.Bytes())
+	require.GreaterOrEqual(t, time.Since(start), 1400*time.Millisecond)
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 10.0.0.1")
+	require.Equal(t, "10.0.0.1", clientIP(req, false))
+	require.Equal(t, "1.2.3.4", clientIP(req, true))
+}
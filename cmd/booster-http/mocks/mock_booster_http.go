@@ -38,6 +38,51 @@ func (m *MockHttpServerApi) EXPECT() *MockHttpServerApiMockRecorder {
 	return m.recorder
 }
 
+// BlockstoreGet mocks base method.
+func (m *MockHttpServerApi) BlockstoreGet(ctx context.Context, c cid.Cid) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockstoreGet", ctx, c)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BlockstoreGet indicates an expected call of BlockstoreGet.
+func (mr *MockHttpServerApiMockRecorder) BlockstoreGet(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockstoreGet", reflect.TypeOf((*MockHttpServerApi)(nil).BlockstoreGet), ctx, c)
+}
+
+// BlockstoreGetSize mocks base method.
+func (m *MockHttpServerApi) BlockstoreGetSize(ctx context.Context, c cid.Cid) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockstoreGetSize", ctx, c)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BlockstoreGetSize indicates an expected call of BlockstoreGetSize.
+func (mr *MockHttpServerApiMockRecorder) BlockstoreGetSize(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockstoreGetSize", reflect.TypeOf((*MockHttpServerApi)(nil).BlockstoreGetSize), ctx, c)
+}
+
+// BlockstoreHas mocks base method.
+func (m *MockHttpServerApi) BlockstoreHas(ctx context.Context, c cid.Cid) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockstoreHas", ctx, c)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BlockstoreHas indicates an expected call of BlockstoreHas.
+func (mr *MockHttpServerApiMockRecorder) BlockstoreHas(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockstoreHas", reflect.TypeOf((*MockHttpServerApi)(nil).BlockstoreHas), ctx, c)
+}
+
 // GetPieceInfo mocks base method.
 func (m *MockHttpServerApi) GetPieceInfo(pieceCID cid.Cid) (*piecestore.PieceInfo, error) {
 	m.ctrl.T.Helper()
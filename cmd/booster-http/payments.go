@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/api"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	paychtypes "github.com/filecoin-project/go-state-types/builtin/v8/paych"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// The headers used for paid retrievals
+const (
+	// A payment channel voucher from the client, encoded as by
+	// 'lotus paych voucher create'
+	paymentVoucherHeader = "X-Payment-Voucher"
+	// The price per byte in attoFIL
+	pricePerByteHeader = "X-Price-Per-Byte"
+	// The address that payment channels must be created to
+	paymentAddressHeader = "X-Payment-Address"
+	// The client's remaining credit in attoFIL, before the response
+	paymentCreditHeader = "X-Payment-Credit"
+)
+
+var errCreditUsedUp = errors.New("the client's credit for paid retrievals has been used up")
+
+// PaymentsApi is the part of the boost API used for paid retrievals.
+// The boost API token must have write permission to add vouchers and
+// reserve credit.
+type PaymentsApi interface {
+	BoostRetrievalPaymentTerms(ctx context.Context) (*api.RetrievalPaymentTerms, error)
+	BoostRetrievalPaymentAddVoucher(ctx context.Context, clientID string, sv *paychtypes.SignedVoucher) (abi.TokenAmount, error)
+	BoostRetrievalPaymentReserve(ctx context.Context, clientID string) (*api.RetrievalPaymentReservation, error)
+	BoostRetrievalPaymentRefund(ctx context.Context, clientID string, amount abi.TokenAmount) (abi.TokenAmount, error)
+}
+
+// Payments requires clients to pay for downloads when the retrieval ask
+// configured in boostd has a non-zero price per byte.
+// Clients prepay: each request may include a payment channel voucher in the
+// X-Payment-Voucher header, which boostd redeems and adds to the client's
+// credit. Before each download the client's credit is reserved, so that
+// concurrent downloads can't spend the same credit, and the price of the
+// bytes that weren't sent is refunded afterwards. The response is cut short
+// when the reserved credit runs out.
+// A client is identified by the id of its auth token or signed URL, so auth
+// must be enabled: IP addresses are shared and easily changed, so they
+// can't be used to keep track of credit.
+type Payments struct {
+	api PaymentsApi
+
+	lk    sync.RWMutex
+	terms *api.RetrievalPaymentTerms
+}
+
+func NewPayments(papi PaymentsApi) *Payments {
+	return &Payments{api: papi}
+}
+
+// Watch fetches the payment terms from boostd, then keeps fetching them at
+// each interval until the context is cancelled
+func (p *Payments) Watch(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("the payment terms refresh interval must be positive, got %s", interval)
+	}
+	if err := p.fetchTerms(ctx); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			// If the terms can't be fetched, keep applying the last terms
+			if err := p.fetchTerms(ctx); err != nil {
+				log.Warnw("updating payment terms", "err", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *Payments) fetchTerms(ctx context.Context) error {
+	terms, err := p.api.BoostRetrievalPaymentTerms(ctx)
+	if err != nil {
+		return fmt.Errorf("fetching payment terms: %w", err)
+	}
+	p.lk.Lock()
+	p.terms = terms
+	p.lk.Unlock()
+	return nil
+}
+
+func (p *Payments) getTerms() *api.RetrievalPaymentTerms {
+	p.lk.RLock()
+	defer p.lk.RUnlock()
+	return p.terms
+}
+
+// wrap returns a handler that only calls the handler if the client has
+// credit for paid retrievals, and that charges the client for the bytes
+// sent
+func (p *Payments) wrap(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		terms := p.getTerms()
+		if terms == nil || terms.PricePerByte.IsZero() {
+			// Retrievals are free
+			handler(w, r)
+			return
+		}
+
+		w.Header().Set(pricePerByteHeader, terms.PricePerByte.String())
+		w.Header().Set(paymentAddressHeader, terms.PaymentAddress.String())
+
+		client, ok := authID(r)
+		if !ok {
+			writeError(w, r, http.StatusUnauthorized, "paid retrievals require an auth token or a signed URL")
+			return
+		}
+		if encoded := r.Header.Get(paymentVoucherHeader); encoded != "" {
+			sv, err := decodeVoucher(encoded)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, "decoding payment voucher: "+err.Error())
+				return
+			}
+			_, err = p.api.BoostRetrievalPaymentAddVoucher(r.Context(), client, sv)
+			if err != nil {
+				writeError(w, r, http.StatusPaymentRequired, "payment voucher was rejected: "+err.Error())
+				return
+			}
+		}
+
+		res, err := p.api.BoostRetrievalPaymentReserve(r.Context(), client)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "reserving credit for paid retrievals: "+err.Error())
+			return
+		}
+
+		w.Header().Set(paymentCreditHeader, big.Add(res.Credit, res.Amount).String())
+		if res.Bytes == 0 {
+			msg := fmt.Sprintf("retrievals cost %s per byte: send a payment channel voucher to %s in the %s header",
+				types.FIL(terms.PricePerByte), terms.PaymentAddress, paymentVoucherHeader)
+			writeError(w, r, http.StatusPaymentRequired, msg)
+			return
+		}
+
+		pw := &paidResponseWriter{ResponseWriter: w, remaining: res.Bytes}
+		handler(pw, r)
+
+		// Only successful responses are charged for: refund the price of
+		// the rest of the reserved bytes
+		sent := pw.count
+		if pw.status >= http.StatusMultipleChoices {
+			sent = 0
+		}
+		refund := unusedAmount(res, sent)
+		if refund.IsZero() {
+			return
+		}
+		// Refund the client even if the request's context was cancelled
+		// because the client disconnected
+		_, err = p.api.BoostRetrievalPaymentRefund(context.Background(), client, refund)
+		if err != nil {
+			log.Errorw("refunding unused credit for paid retrieval", "client", client, "amount", refund, "err", err)
+		}
+	}
+}
+
+// decodeVoucher decodes a voucher in the format output by
+// 'lotus paych voucher create': base64url encoded CBOR
+func decodeVoucher(encoded string) (*paychtypes.SignedVoucher, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var sv paychtypes.SignedVoucher
+	if err := sv.UnmarshalCBOR(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return &sv, nil
+}
+
+// unusedAmount returns the price of the reserved bytes that weren't sent
+func unusedAmount(res *api.RetrievalPaymentReservation, sent uint64) abi.TokenAmount {
+	if sent >= res.Bytes {
+		return big.Zero()
+	}
+	// The reserved amount is the price of the reserved bytes, so the price
+	// of the unused bytes is the same fraction of the amount
+	unused := big.NewIntUnsigned(res.Bytes - sent)
+	return big.Div(big.Mul(res.Amount, unused), big.NewIntUnsigned(res.Bytes))
+}
+
+// paidResponseWriter stops writing the response when the bytes written
+// have used up the client's credit
+type paidResponseWriter struct {
+	http.ResponseWriter
+	remaining uint64
+	count     uint64
+	status    int
+}
+
+func (w *paidResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *paidResponseWriter) Write(bz []byte) (int, error) {
+	if w.remaining == 0 {
+		return 0, errCreditUsedUp
+	}
+
+	var err error
+	if uint64(len(bz)) > w.remaining {
+		bz = bz[:w.remaining]
+		err = errCreditUsedUp
+	}
+	n, writeErr := w.ResponseWriter.Write(bz)
+	w.count += uint64(n)
+	w.remaining -= uint64(n)
+	if writeErr != nil {
+		return n, writeErr
+	}
+	return n, err
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/api"
+	mocks_booster_http "github.com/filecoin-project/boost/cmd/booster-http/mocks"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	paychtypes "github.com/filecoin-project/go-state-types/builtin/v8/paych"
+	"github.com/golang/mock/gomock"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-libipfs/blocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpPaidRetrievals(t *testing.T) {
+	ctx := context.Background()
+
+	// A 1000 byte block, at 2 attoFIL per byte
+	data := bytes.Repeat([]byte("a"), 1000)
+	blk := blocks.NewBlock(data)
+	paymentAddr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	papi := &mockPaymentsApi{
+		terms:  &api.RetrievalPaymentTerms{PricePerByte: abi.NewTokenAmount(2), UnsealPrice: big.Zero(), PaymentAddress: paymentAddr},
+		credit: make(map[string]abi.TokenAmount),
+	}
+	payments := NewPayments(papi)
+	require.Error(t, payments.Watch(ctx, 0))
+	require.NoError(t, payments.Watch(ctx, time.Minute))
+	auth, err := NewAuthenticator(map[string]string{"token-a": "alice"}, nil)
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	mockHttpServer := mocks_booster_http.NewMockHttpServerApi(ctrl)
+	mockHttpServer.EXPECT().BlockstoreGet(gomock.Any(), blk.Cid()).AnyTimes().Return(data, nil)
+	httpServer := NewHttpServer("", 7779, false, mockHttpServer, &HttpServerOptions{Auth: auth, Payments: payments})
+	httpServer.Start(ctx)
+	defer httpServer.Stop() //nolint:errcheck
+	require.Eventually(t, func() bool {
+		response, err := http.Get("http://localhost:7779/info")
+		if err == nil {
+			response.Body.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	get := func(c cid.Cid, voucher *paychtypes.SignedVoucher) (*http.Response, []byte) {
+		req, err := http.NewRequest("GET", "http://localhost:7779/ipfs/"+c.String()+"?format=raw", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer token-a")
+		if voucher != nil {
+			buf := new(bytes.Buffer)
+			require.NoError(t, voucher.MarshalCBOR(buf))
+			req.Header.Set(paymentVoucherHeader, base64.RawURLEncoding.EncodeToString(buf.Bytes()))
+		}
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		return response, body
+	}
+
+	// Without credit the client must pay
+	response, _ := get(blk.Cid(), nil)
+	require.Equal(t, http.StatusPaymentRequired, response.StatusCode)
+	require.Equal(t, "2", response.Header.Get(pricePerByteHeader))
+	require.Equal(t, paymentAddr.String(), response.Header.Get(paymentAddressHeader))
+
+	// An invalid voucher is rejected
+	response, _ = get(blk.Cid(), &paychtypes.SignedVoucher{ChannelAddr: paymentAddr, Amount: abi.NewTokenAmount(-1)})
+	require.Equal(t, http.StatusPaymentRequired, response.StatusCode)
+
+	// With enough credit, the client gets the whole block and is charged for
+	// the bytes sent
+	response, body := get(blk.Cid(), &paychtypes.SignedVoucher{ChannelAddr: paymentAddr, Amount: abi.NewTokenAmount(2500)})
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.Equal(t, "2500", response.Header.Get(paymentCreditHeader))
+	require.Equal(t, data, body)
+	require.Equal(t, "500", papi.getCredit("alice").String())
+
+	// When the credit runs out, the response is cut short
+	_, body = get(blk.Cid(), nil)
+	require.Len(t, body, 250)
+	require.Equal(t, "0", papi.getCredit("alice").String())
+	response, _ = get(blk.Cid(), nil)
+	require.Equal(t, http.StatusPaymentRequired, response.StatusCode)
+
+	// Failed requests are not charged for
+	response, _ = get(blk.Cid(), &paychtypes.SignedVoucher{ChannelAddr: paymentAddr, Amount: abi.NewTokenAmount(10)})
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	missing := blocks.NewBlock([]byte("missing")).Cid()
+	mockHttpServer.EXPECT().BlockstoreGet(gomock.Any(), missing).AnyTimes().Return(nil, errors.New("not found"))
+	response, _ = get(missing, &paychtypes.SignedVoucher{ChannelAddr: paymentAddr, Amount: abi.NewTokenAmount(10)})
+	require.NotEqual(t, http.StatusOK, response.StatusCode)
+	require.Equal(t, "10", papi.getCredit("alice").String())
+
+	// Concurrent downloads can't spend the same credit: there is only
+	// enough credit for one of them
+	papi.setCredit("alice", abi.NewTokenAmount(2000))
+	var wg sync.WaitGroup
+	var okLk sync.Mutex
+	var ok int
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, body := get(blk.Cid(), nil)
+			if response.StatusCode == http.StatusOK && bytes.Equal(data, body) {
+				okLk.Lock()
+				ok++
+				okLk.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 1, ok)
+	require.Equal(t, "0", papi.getCredit("alice").String())
+	papi.setCredit("alice", abi.NewTokenAmount(10))
+
+	// Downloads without an auth token are refused
+	req, err := http.NewRequest("GET", "http://localhost:7779/ipfs/"+blk.Cid().String()+"?format=raw", nil)
+	require.NoError(t, err)
+	response, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	response.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	// When the price is zero, downloads are free
+	papi.lk.Lock()
+	papi.terms = &api.RetrievalPaymentTerms{PricePerByte: big.Zero(), UnsealPrice: big.Zero(), PaymentAddress: paymentAddr}
+	papi.lk.Unlock()
+	require.NoError(t, payments.fetchTerms(ctx))
+	response, body = get(blk.Cid(), nil)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.Equal(t, data, body)
+	require.Equal(t, "10", papi.getCredit("alice").String())
+}
+
+// mockPaymentsApi adds the amount of each voucher to the client's credit,
+// and reserves credit in the same way as boostd
+type mockPaymentsApi struct {
+	lk     sync.Mutex
+	terms  *api.RetrievalPaymentTerms
+	credit map[string]abi.TokenAmount
+}
+
+func (m *mockPaymentsApi) BoostRetrievalPaymentTerms(ctx context.Context) (*api.RetrievalPaymentTerms, error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return m.terms, nil
+}
+
+func (m *mockPaymentsApi) BoostRetrievalPaymentAddVoucher(ctx context.Context, clientID string, sv *paychtypes.SignedVoucher) (abi.TokenAmount, error) {
+	if !sv.Amount.GreaterThan(big.Zero()) {
+		return big.Zero(), errors.New("invalid voucher")
+	}
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	m.credit[clientID] = big.Add(m.getCreditLocked(clientID), sv.Amount)
+	return m.credit[clientID], nil
+}
+
+func (m *mockPaymentsApi) BoostRetrievalPaymentReserve(ctx context.Context, clientID string) (*api.RetrievalPaymentReservation, error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	credit := m.getCreditLocked(clientID)
+	res := &api.RetrievalPaymentReservation{Amount: big.Zero(), Credit: credit}
+	if credit.GreaterThan(big.Zero()) {
+		bytes := big.Div(credit, m.terms.PricePerByte)
+		res.Bytes = bytes.Uint64()
+		res.Amount = big.Mul(bytes, m.terms.PricePerByte)
+		res.Credit = big.Sub(credit, res.Amount)
+		m.credit[clientID] = res.Credit
+	}
+	return res, nil
+}
+
+func (m *mockPaymentsApi) BoostRetrievalPaymentRefund(ctx context.Context, clientID string, amount abi.TokenAmount) (abi.TokenAmount, error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	m.credit[clientID] = big.Add(m.getCreditLocked(clientID), amount)
+	return m.credit[clientID], nil
+}
+
+func (m *mockPaymentsApi) setCredit(clientID string, credit abi.TokenAmount) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	m.credit[clientID] = credit
+}
+
+func (m *mockPaymentsApi) getCredit(clientID string) abi.TokenAmount {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return m.getCreditLocked(clientID)
+}
+
+func (m *mockPaymentsApi) getCreditLocked(clientID string) abi.TokenAmount {
+	credit, ok := m.credit[clientID]
+	if !ok {
+		return big.Zero()
+	}
+	return credit
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/filecoin-project/boost/retrievalmarket/quota"
+	lru "github.com/hnlq715/golang-lru"
+	"golang.org/x/time/rate"
+)
+
+// The maximum number of throttled clients whose bandwidth limiter is kept
+const maxThrottledClients = 1024
+
+// Quotas applies the per-client retrieval quotas configured in boostd to
+// downloads. A client that has exceeded its quota is refused with status
+// 429 (Too Many Requests), or if the quota action is throttle, the
+// bandwidth of all the client's downloads is limited.
+// A client is identified by the id of its auth token or signed URL if auth
+// is enabled, otherwise by its IP address.
+type Quotas struct {
+	cache             *quota.Cache
+	trustForwardedFor bool
+
+	lk        sync.Mutex
+	throttled *lru.Cache
+}
+
+func NewQuotas(cache *quota.Cache, trustForwardedFor bool) (*Quotas, error) {
+	throttled, err := lru.New(maxThrottledClients)
+	if err != nil {
+		return nil, fmt.Errorf("creating throttled clients cache: %w", err)
+	}
+	return &Quotas{cache: cache, trustForwardedFor: trustForwardedFor, throttled: throttled}, nil
+}
+
+// wrap returns a handler that refuses or throttles downloads by clients
+// that have exceeded their quota
+func (q *Quotas) wrap(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client := clientID(r, q.trustForwardedFor)
+		st, err := q.cache.Status(r.Context(), client)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "checking retrieval quota: "+err.Error())
+			return
+		}
+
+		switch st.State {
+		case quota.StateBlocked:
+			writeError(w, r, http.StatusTooManyRequests, "client has exceeded its retrieval quota")
+			return
+		case quota.StateThrottled:
+			w = &throttledResponseWriter{ResponseWriter: w, ctx: r.Context(), lim: q.limiter(client, st.ThrottleBytesPerSecond)}
+		}
+		handler(w, r)
+	}
+}
+
+// limiter returns the bandwidth limiter shared by all of a throttled
+// client's downloads
+func (q *Quotas) limiter(client string, bytesPerSecond uint64) *rate.Limiter {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	if lim, ok := q.throttled.Get(client); ok {
+		l := lim.(*rate.Limiter)
+		if l.Limit() == rate.Limit(bytesPerSecond) {
+			return l
+		}
+	}
+	lim := rate.NewLimiter(rate.Limit(bytesPerSecond), int(bytesPerSecond))
+	q.throttled.Add(client, lim)
+	return lim
+}
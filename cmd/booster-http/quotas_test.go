@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	mocks_booster_http "github.com/filecoin-project/boost/cmd/booster-http/mocks"
+	"github.com/filecoin-project/boost/retrievalmarket/quota"
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/golang/mock/gomock"
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	"github.com/ipfs/go-merkledag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpQuotas(t *testing.T) {
+	ctx := context.Background()
+
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	dserv := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+	filePath, err := testutil.CreateRandomFile(t.TempDir(), 1, 1024)
+	require.NoError(t, err)
+	root, err := testutil.WriteUnixfsDAGTo(filePath, dserv, 1024, 4)
+	require.NoError(t, err)
+
+	// client1 is within its quota, client2 is throttled and client3 is blocked
+	states := map[string]string{"client1": quota.StateOK, "client2": quota.StateThrottled, "client3": quota.StateBlocked}
+	fetch := func(ctx context.Context, client string) (*quota.Status, error) {
+		return &quota.Status{Client: client, State: states[client], ThrottleBytesPerSecond: 512}, nil
+	}
+	cache, err := quota.NewCache(fetch, 16, time.Minute)
+	require.NoError(t, err)
+	quotas, err := NewQuotas(cache, false)
+	require.NoError(t, err)
+
+	auth, err := NewAuthenticator(map[string]string{"token1": "client1", "token2": "client2", "token3": "client3"}, nil)
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	mockHttpServer := mocks_booster_http.NewMockHttpServerApi(ctrl)
+	mockHttpServer.EXPECT().BlockstoreGet(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, c cid.Cid) ([]byte, error) {
+		blk, err := bs.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		return blk.RawData(), nil
+	})
+	httpServer := NewHttpServer("", 7781, false, mockHttpServer, &HttpServerOptions{Auth: auth, Quotas: quotas})
+	httpServer.Start(ctx)
+	defer httpServer.Stop() //nolint:errcheck
+	require.Eventually(t, func() bool {
+		response, err := http.Get("http://localhost:7781/info")
+		if err == nil {
+			response.Body.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	get := func(token string) (int, time.Duration) {
+		start := time.Now()
+		req, err := http.NewRequest("GET", "http://localhost:7781/ipfs/"+root.String()+"?format=car", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		_, err = io.ReadAll(response.Body)
+		require.NoError(t, err)
+		return response.StatusCode, time.Since(start)
+	}
+
+	status, fast := get("token1")
+	require.Equal(t, http.StatusOK, status)
+
+	// The throttled client's download succeeds, but is slower
+	status, slow := get("token2")
+	require.Equal(t, http.StatusOK, status)
+	require.Greater(t, slow, fast)
+	require.Greater(t, slow, time.Second)
+
+	status, _ = get("token3")
+	require.Equal(t, http.StatusTooManyRequests, status)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/boost/retrievalmarket/rtvllog"
+	"github.com/ipfs/go-cid"
+)
+
+// RetrievalLog reports each download to boostd, so that it is recorded
+// in the retrievals DB alongside graphsync and bitswap retrievals.
+// A client is identified by the id of its auth token or signed URL if auth
+// is enabled, otherwise by its IP address.
+type RetrievalLog struct {
+	reporter          *rtvllog.Reporter
+	trustForwardedFor bool
+}
+
+func NewRetrievalLog(reporter *rtvllog.Reporter, trustForwardedFor bool) *RetrievalLog {
+	return &RetrievalLog{reporter: reporter, trustForwardedFor: trustForwardedFor}
+}
+
+type retrievalClientKey struct{}
+
+// wrap returns a handler that reports the result of each download
+func (l *RetrievalLog) wrap(pieceBasePath string, ipfsBasePath string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			handler(w, r)
+			return
+		}
+
+		// The client is identified by its IP address until it has been
+		// authenticated
+		start := time.Now()
+		client := clientIP(r, l.trustForwardedFor)
+		r = r.WithContext(context.WithValue(r.Context(), retrievalClientKey{}, &client))
+		cw := &countingResponseWriter{ResponseWriter: w}
+		handler(cw, r)
+
+		status := cw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		a := rtvllog.RetrievalAttempt{
+			CreatedAt: start,
+			Protocol:  rtvllog.ProtocolHTTP,
+			ClientID:  client,
+			Duration:  time.Since(start),
+			Result:    retrievalResult(status),
+		}
+		// Only count the bytes of data sent, not of error messages
+		if a.Result == rtvllog.ResultSuccess {
+			a.BytesSent = cw.count
+		} else {
+			a.Message = http.StatusText(status)
+		}
+		if strings.HasPrefix(r.URL.Path, pieceBasePath) {
+			c, _, _ := strings.Cut(r.URL.Path[len(pieceBasePath):], "/")
+			if pieceCid, err := cid.Parse(c); err == nil {
+				a.PieceCID = &pieceCid
+			}
+		} else if strings.HasPrefix(r.URL.Path, ipfsBasePath) {
+			c, _, _ := strings.Cut(r.URL.Path[len(ipfsBasePath):], "/")
+			if payloadCid, err := cid.Parse(c); err == nil {
+				a.PayloadCID = payloadCid
+			}
+		}
+		l.reporter.Record(a)
+	}
+}
+
+// identify returns a handler that identifies the client by its auth id.
+// It must be called after the request has been authenticated.
+func (l *RetrievalLog) identify(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if client, ok := r.Context().Value(retrievalClientKey{}).(*string); ok {
+			*client = clientID(r, l.trustForwardedFor)
+		}
+		handler(w, r)
+	}
+}
+
+func retrievalResult(status int) string {
+	switch {
+	case status < 300:
+		return rtvllog.ResultSuccess
+	case status == http.StatusUnauthorized, status == http.StatusPaymentRequired, status == http.StatusForbidden,
+		status == http.StatusTooManyRequests, status == http.StatusUnavailableForLegalReasons:
+		return rtvllog.ResultRejected
+	default:
+		return rtvllog.ResultFailure
+	}
+}
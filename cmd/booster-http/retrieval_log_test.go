@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	mocks_booster_http "github.com/filecoin-project/boost/cmd/booster-http/mocks"
+	"github.com/filecoin-project/boost/retrievalmarket/rtvllog"
+	"github.com/golang/mock/gomock"
+	"github.com/ipfs/go-libipfs/blocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpRetrievalLog(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	data := bytes.Repeat([]byte("a"), 1000)
+	blk := blocks.NewBlock(data)
+
+	submitted := make(chan []rtvllog.RetrievalAttempt, 1)
+	reporter := rtvllog.NewReporter(func(ctx context.Context, attempts []rtvllog.RetrievalAttempt) error {
+		submitted <- attempts
+		return nil
+	})
+	auth, err := NewAuthenticator(map[string]string{"token1": "client1"}, nil)
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	mockHttpServer := mocks_booster_http.NewMockHttpServerApi(ctrl)
+	mockHttpServer.EXPECT().BlockstoreGet(gomock.Any(), blk.Cid()).AnyTimes().Return(data, nil)
+	opts := &HttpServerOptions{
+		Auth:         auth,
+		RetrievalLog: NewRetrievalLog(reporter, false),
+	}
+	httpServer := NewHttpServer("", 7780, false, mockHttpServer, opts)
+	httpServer.Start(ctx)
+	defer httpServer.Stop() //nolint:errcheck
+	require.Eventually(t, func() bool {
+		response, err := http.Get("http://localhost:7780/info")
+		if err == nil {
+			response.Body.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	get := func(token string) int {
+		req, err := http.NewRequest("GET", "http://localhost:7780/ipfs/"+blk.Cid().String()+"?format=raw", nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		_, _ = io.Copy(io.Discard, response.Body)
+		return response.StatusCode
+	}
+
+	require.Equal(t, http.StatusUnauthorized, get(""))
+	require.Equal(t, http.StatusOK, get("token1"))
+
+	// Stop the reporter so that it submits the attempts
+	reporterCtx, stopReporter := context.WithCancel(ctx)
+	stopReporter()
+	reporter.Run(reporterCtx, time.Hour)
+	attempts := <-submitted
+	require.Len(t, attempts, 2)
+
+	// The unauthenticated download is identified by IP address
+	require.Equal(t, rtvllog.ProtocolHTTP, attempts[0].Protocol)
+	require.Equal(t, rtvllog.ResultRejected, attempts[0].Result)
+	require.Equal(t, "127.0.0.1", attempts[0].ClientID)
+	require.Equal(t, blk.Cid(), attempts[0].PayloadCID)
+	require.Zero(t, attempts[0].BytesSent)
+
+	// The authenticated download is identified by the auth token id
+	require.Equal(t, rtvllog.ResultSuccess, attempts[1].Result)
+	require.Equal(t, "client1", attempts[1].ClientID)
+	require.Equal(t, uint64(len(data)), attempts[1].BytesSent)
+}
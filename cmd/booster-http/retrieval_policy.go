@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/filecoin-project/boost/metrics"
+	"github.com/filecoin-project/boost/retrievalpolicy"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipfs/go-libipfs/blocks"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// RetrievalPolicy applies the retrieval policy configured in boostd to
+// downloads.
+// A client is identified by the id of its auth token or signed URL if auth
+// is enabled, otherwise by its IP address.
+type RetrievalPolicy struct {
+	engine            *retrievalpolicy.Engine
+	pieces            *retrievalpolicy.PieceLookup
+	trustForwardedFor bool
+}
+
+func NewRetrievalPolicy(engine *retrievalpolicy.Engine, pieces *retrievalpolicy.PieceLookup, trustForwardedFor bool) *RetrievalPolicy {
+	return &RetrievalPolicy{engine: engine, pieces: pieces, trustForwardedFor: trustForwardedFor}
+}
+
+func (p *RetrievalPolicy) client(r *http.Request) string {
+	return clientID(r, p.trustForwardedFor)
+}
+
+// allowPiece returns true if the policy allows the client to download the
+// piece. Otherwise it writes an error response and returns false.
+func (p *RetrievalPolicy) allowPiece(w http.ResponseWriter, r *http.Request, pieceCid cid.Cid) bool {
+	return p.allow(w, r, p.engine.Check(p.client(r), []cid.Cid{pieceCid}))
+}
+
+// allowPayload returns true if the policy allows the client to download
+// the root block of a DAG. Otherwise it writes an error response and
+// returns false.
+// The other blocks of the DAG may be in other pieces, so they are checked
+// as they are read, with the blockstore returned by blockstore.
+func (p *RetrievalPolicy) allowPayload(w http.ResponseWriter, r *http.Request, root cid.Cid) bool {
+	return p.allow(w, r, p.pieces.Check(r.Context(), p.engine, p.client(r), root))
+}
+
+// blockstore returns a blockstore that refuses to return blocks that the
+// client is not allowed to retrieve
+func (p *RetrievalPolicy) blockstore(r *http.Request, bs blockstore.Blockstore) blockstore.Blockstore {
+	return &policyBlockstore{Blockstore: bs, policy: p, client: p.client(r)}
+}
+
+func (p *RetrievalPolicy) allow(w http.ResponseWriter, r *http.Request, err error) bool {
+	if err == nil {
+		return true
+	}
+
+	switch {
+	case errors.Is(err, retrievalpolicy.ErrNotAllowed):
+		recordPolicyRejected(r, err)
+		writeError(w, r, http.StatusForbidden, err.Error())
+	case isNotFoundError(err):
+		writeError(w, r, http.StatusNotFound, err.Error())
+	default:
+		writeError(w, r, http.StatusInternalServerError, "checking retrieval policy: "+err.Error())
+	}
+	return false
+}
+
+func recordPolicyRejected(r *http.Request, err error) {
+	ctx, _ := tag.New(r.Context(), tag.Upsert(metrics.RetrievalPolicyRejectReason, retrievalpolicy.RejectReason(err)))
+	stats.Record(ctx, metrics.HttpPolicyRejectedCount.M(1))
+}
+
+// policyBlockstore applies the retrieval policy to each block, so that a
+// client can't retrieve blocks in a gated piece through a DAG whose root is
+// in a free piece
+type policyBlockstore struct {
+	blockstore.Blockstore
+	policy *RetrievalPolicy
+	client string
+}
+
+func (bs *policyBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if err := bs.policy.pieces.Check(ctx, bs.policy.engine, bs.client, c); err != nil {
+		return nil, err
+	}
+	return bs.Blockstore.Get(ctx, c)
+}
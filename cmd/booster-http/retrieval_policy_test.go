@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	mocks_booster_http "github.com/filecoin-project/boost/cmd/booster-http/mocks"
+	"github.com/filecoin-project/boost/retrievalpolicy"
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/golang/mock/gomock"
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	"github.com/ipfs/go-merkledag"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpRetrievalPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	// Create a file in a free piece and a file in a gated piece
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	dserv := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+	freePiece := testutil.GenerateCid()
+	gatedPiece := testutil.GenerateCid()
+	var fileCids []cid.Cid
+	for i := 0; i < 3; i++ {
+		filePath, err := testutil.CreateRandomFile(t.TempDir(), i, 4096)
+		require.NoError(t, err)
+		root, err := testutil.WriteUnixfsDAGTo(filePath, dserv, 1024, 4)
+		require.NoError(t, err)
+		fileCids = append(fileCids, root)
+	}
+	piecesByBlock := map[string][]cid.Cid{
+		string(fileCids[0].Hash()): {freePiece},
+		string(fileCids[1].Hash()): {gatedPiece},
+		string(fileCids[2].Hash()): {freePiece},
+	}
+	// The root of the third file is in the free piece, but its leaves are in
+	// the gated piece
+	rootNd, err := dserv.Get(ctx, fileCids[2])
+	require.NoError(t, err)
+	require.NotEmpty(t, rootNd.Links())
+	for _, l := range rootNd.Links() {
+		piecesByBlock[string(l.Cid.Hash())] = []cid.Cid{gatedPiece}
+	}
+
+	engine := retrievalpolicy.NewEngine()
+	require.NoError(t, engine.Update(retrievalpolicy.Config{
+		Rules: []retrievalpolicy.Rule{{
+			PieceCids:      []cid.Cid{gatedPiece},
+			Access:         retrievalpolicy.AccessGated,
+			AllowedClients: []string{"client1"},
+		}},
+	}))
+	pieces, err := retrievalpolicy.NewPieceLookup(func(ctx context.Context, h mh.Multihash) ([]cid.Cid, error) {
+		return piecesByBlock[string(h)], nil
+	}, 16)
+	require.NoError(t, err)
+
+	auth, err := NewAuthenticator(map[string]string{"token1": "client1", "token2": "client2"}, nil)
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	mockHttpServer := mocks_booster_http.NewMockHttpServerApi(ctrl)
+	mockHttpServer.EXPECT().BlockstoreGet(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, c cid.Cid) ([]byte, error) {
+		blk, err := bs.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		return blk.RawData(), nil
+	})
+	opts := &HttpServerOptions{
+		Auth:            auth,
+		RetrievalPolicy: NewRetrievalPolicy(engine, pieces, false),
+	}
+	httpServer := NewHttpServer("", 7777, false, mockHttpServer, opts)
+	httpServer.Start(ctx)
+	defer httpServer.Stop() //nolint:errcheck
+	require.Eventually(t, func() bool {
+		response, err := http.Get("http://localhost:7777/info")
+		if err == nil {
+			response.Body.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	download := func(token string, root cid.Cid, format string) (int, []byte) {
+		req, err := http.NewRequest("GET", "http://localhost:7777/ipfs/"+root.String()+"?format="+format, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		return response.StatusCode, body
+	}
+	get := func(token string, root cid.Cid) int {
+		status, _ := download(token, root, "car")
+		return status
+	}
+
+	// Anyone can download from the free piece, but only client1 can
+	// download from the gated piece
+	require.Equal(t, http.StatusOK, get("token2", fileCids[0]))
+	require.Equal(t, http.StatusForbidden, get("token2", fileCids[1]))
+	require.Equal(t, http.StatusOK, get("token1", fileCids[1]))
+
+	// The policy applies to every block in the DAG, not just the root: the
+	// leaves of the third file are gated
+	leaf := rootNd.Links()[0].Cid
+	status, _ := download("token2", leaf, "raw")
+	require.Equal(t, http.StatusForbidden, status)
+	status, _ = download("token1", leaf, "raw")
+	require.Equal(t, http.StatusOK, status)
+
+	// The root is allowed, so the response has already started by the time
+	// the leaves are refused: the CAR file stops before the gated blocks
+	status, allowedCar := download("token1", fileCids[2], "car")
+	require.Equal(t, http.StatusOK, status)
+	_, refusedCar := download("token2", fileCids[2], "car")
+	require.Less(t, len(refusedCar), len(allowedCar))
+	leafBlk, err := bs.Get(ctx, leaf)
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(refusedCar, leafBlk.RawData()))
+	require.True(t, bytes.Contains(allowedCar, leafBlk.RawData()))
+}
@@ -2,26 +2,45 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	_ "net/http/pprof"
+	"os"
 	"strings"
+	"time"
 
+	"github.com/docker/go-units"
 	"github.com/filecoin-project/boost/api"
 	bclient "github.com/filecoin-project/boost/api/client"
 	cliutil "github.com/filecoin-project/boost/cli/util"
 	"github.com/filecoin-project/boost/cmd/lib"
+	"github.com/filecoin-project/boost/retrievalmarket/quota"
+	"github.com/filecoin-project/boost/retrievalmarket/rtvllog"
+	"github.com/filecoin-project/boost/retrievalpolicy"
 	"github.com/filecoin-project/boostd-data/shared/tracing"
 	"github.com/filecoin-project/dagstore/mount"
 	"github.com/filecoin-project/go-fil-markets/piecestore"
 	"github.com/filecoin-project/go-jsonrpc"
 	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/api/v1api"
 	lcli "github.com/filecoin-project/lotus/cli"
 	"github.com/filecoin-project/lotus/markets/dagstore"
 	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/acme"
 )
 
+// The number of blocks for which to cache the pieces containing the block,
+// when applying the retrieval policy to downloads by payload CID
+const retrievalPolicyLookupCacheSize = 4096
+
+// The number of clients for which to cache the retrieval quota status
+const retrievalQuotaCacheSize = 4096
+
 var runCmd = &cli.Command{
 	Name:   "run",
 	Usage:  "Start a booster-http process",
@@ -46,6 +65,148 @@ var runCmd = &cli.Command{
 			Usage: "allow booster-http to build an index for a CAR file on the fly if necessary (requires doing an extra pass over the CAR file)",
 			Value: false,
 		},
+		&cli.BoolFlag{
+			Name:  "serve-files",
+			Usage: "serve the files and directories in unixfs DAGs at /ipfs/<cid>/<path> (not only verifiable blocks and CAR files)",
+			Value: false,
+		},
+		&cli.StringFlag{
+			Name:  "tls-cert-file",
+			Usage: "serve HTTPS with the certificate in this file (requires --tls-key-file)",
+		},
+		&cli.StringFlag{
+			Name:  "tls-key-file",
+			Usage: "the private key for the certificate in --tls-cert-file",
+		},
+		&cli.StringSliceFlag{
+			Name:  "acme-domain",
+			Usage: "serve HTTPS with a certificate for this domain that is provisioned and renewed automatically by the ACME server (may be repeated)",
+		},
+		&cli.StringFlag{
+			Name:  "acme-email",
+			Usage: "the contact email for the ACME account, used by the ACME server to send notices about certificates",
+		},
+		&cli.StringFlag{
+			Name:  "acme-cache-dir",
+			Usage: "the directory in which the ACME account key and certificates are kept",
+		},
+		&cli.StringFlag{
+			Name:  "acme-directory-url",
+			Usage: "the directory URL of the ACME server",
+			Value: acme.LetsEncryptURL,
+		},
+		&cli.UintFlag{
+			Name:  "acme-http-port",
+			Usage: "the port on which to answer ACME HTTP-01 challenges and redirect HTTP to HTTPS (0 to only answer TLS-ALPN-01 challenges, which requires --port 443)",
+			Value: 80,
+		},
+		&cli.Float64Flag{
+			Name:  "limit-requests-per-second",
+			Usage: "the maximum number of download requests per second from each client IP address (0 for no limit)",
+		},
+		&cli.IntFlag{
+			Name:  "limit-request-burst",
+			Usage: "the number of download requests a client IP address can make in a burst, before --limit-requests-per-second applies",
+			Value: 10,
+		},
+		&cli.IntFlag{
+			Name:  "limit-concurrent-requests",
+			Usage: "the maximum number of downloads that are served to each client IP address at the same time (0 for no limit)",
+		},
+		&cli.StringFlag{
+			Name:  "limit-bandwidth",
+			Usage: "the maximum bandwidth per second used by the downloads for each client IP address, eg 100MiB (empty for no limit)",
+		},
+		&cli.BoolFlag{
+			Name:  "trust-forwarded-for",
+			Usage: "identify clients (for per-IP limits and the access log) by the X-Forwarded-For header instead of the connection address (only enable behind a trusted reverse proxy)",
+		},
+		&cli.StringFlag{
+			Name:  "access-log",
+			Usage: "write an entry for each download request to this file (- for stdout)",
+		},
+		&cli.StringFlag{
+			Name:  "access-log-format",
+			Usage: "the format of the access log: json (one object per line) or common (Common Log Format)",
+			Value: accessLogFormatJSON,
+		},
+		&cli.StringSliceFlag{
+			Name: "fleet-member",
+			Usage: "the base URL of a booster-http instance in the fleet that serves data for the same boost node, including this instance (may be repeated). " +
+				"Requests are forwarded to the instance that owns the data, so that each piece is unsealed and cached by one instance. " +
+				"The instance that serves a request applies the per-IP limits, quotas and authentication to the original client.",
+		},
+		&cli.StringFlag{
+			Name:  "fleet-self",
+			Usage: "the base URL of this instance, as it appears in --fleet-member",
+		},
+		&cli.StringFlag{
+			Name:    "fleet-secret",
+			Usage:   "a secret shared by all the instances in the fleet, that authenticates the requests forwarded between them (required with --fleet-member)",
+			EnvVars: []string{"BOOSTER_HTTP_FLEET_SECRET"},
+		},
+		&cli.StringSliceFlag{
+			Name:  "denylist",
+			Usage: "refuse to serve the content in this badbits-style denylist (a file or an http(s) URL) with status 410 (may be repeated)",
+		},
+		&cli.DurationFlag{
+			Name:  "denylist-refresh-interval",
+			Usage: "how often to reload denylist files that have changed and fetch denylist URLs",
+			Value: 5 * time.Minute,
+		},
+		&cli.BoolFlag{
+			Name:  "retrieval-policy",
+			Usage: "apply the retrieval policy configured in boostd (see boostd retrieval-policy): clients are identified by auth token id, or by IP address if auth is not enabled",
+		},
+		&cli.DurationFlag{
+			Name:  "retrieval-policy-refresh-interval",
+			Usage: "how often to fetch changes to the retrieval policy from boostd",
+			Value: time.Minute,
+		},
+		&cli.BoolFlag{
+			Name:  "retrieval-quotas",
+			Usage: "apply the per-client retrieval quotas configured in boostd (requires --retrieval-log so that downloads count towards the quotas): clients are identified by auth token id, or by IP address if auth is not enabled",
+		},
+		&cli.DurationFlag{
+			Name:  "retrieval-quotas-refresh-interval",
+			Usage: "how long to cache each client's quota status fetched from boostd",
+			Value: 30 * time.Second,
+		},
+		&cli.BoolFlag{
+			Name:  "paid-retrievals",
+			Usage: "charge for downloads at the price in the retrieval ask configured in boostd (see boostd retrieval-payments): clients are identified by auth token id, so auth must be enabled, and the --api-boost token needs write permission",
+		},
+		&cli.DurationFlag{
+			Name:  "paid-retrievals-refresh-interval",
+			Usage: "how often to fetch changes to the retrieval ask from boostd",
+			Value: time.Minute,
+		},
+		&cli.BoolFlag{
+			Name:  "retrieval-log",
+			Usage: "record each download in the boostd retrievals database, alongside graphsync and bitswap retrievals",
+		},
+		&cli.DurationFlag{
+			Name:  "retrieval-log-interval",
+			Usage: "how often to send the records of downloads to boostd",
+			Value: 10 * time.Second,
+		},
+		&cli.StringFlag{
+			Name:  "auth-tokens-file",
+			Usage: "require a bearer token to download pieces: the path to a file with one '<name> <token>' per line",
+		},
+		&cli.StringFlag{
+			Name:  "auth-signing-key-file",
+			Usage: "allow downloads with URLs signed by the key in this file (see booster-http sign-url)",
+		},
+		&cli.StringFlag{
+			Name:  "cache-dir",
+			Usage: "cache recently served blocks and piece data in this directory, so that they don't need to be fetched (and possibly unsealed) again",
+		},
+		&cli.StringFlag{
+			Name:  "cache-size",
+			Usage: "the maximum size of the data in the cache directory",
+			Value: "10GiB",
+		},
 		&cli.StringFlag{
 			Name:     "api-boost",
 			Usage:    "the endpoint for the boost API",
@@ -123,6 +284,118 @@ var runCmd = &cli.Command{
 		}
 		defer storageCloser()
 
+		// Connect to the storage API to check its health
+		storageApi, storageApiCloser, err := lib.GetMinerApi(ctx, storageApiInfo, log)
+		if err != nil {
+			return fmt.Errorf("getting storage API: %w", err)
+		}
+		defer storageApiCloser()
+
+		auth, err := newAuthenticator(cctx)
+		if err != nil {
+			return err
+		}
+
+		var cache *DiskCache
+		if cctx.IsSet("cache-dir") {
+			maxBytes, err := units.RAMInBytes(cctx.String("cache-size"))
+			if err != nil {
+				return fmt.Errorf("parsing cache-size: %w", err)
+			}
+			cache, err = NewDiskCache(cctx.String("cache-dir"), uint64(maxBytes))
+			if err != nil {
+				return err
+			}
+			log.Infow("caching served data", "dir", cctx.String("cache-dir"), "max-size", cctx.String("cache-size"))
+		}
+
+		tlsConfig, err := newTLSConfig(cctx)
+		if err != nil {
+			return err
+		}
+
+		limiter, err := newLimiter(cctx)
+		if err != nil {
+			return err
+		}
+
+		accessLog, closeAccessLog, err := newAccessLogger(cctx)
+		if err != nil {
+			return err
+		}
+		defer closeAccessLog()
+
+		var fleet *Fleet
+		if cctx.IsSet("fleet-member") {
+			fleet, err = NewFleet(cctx.String("fleet-self"), cctx.StringSlice("fleet-member"), cctx.String("fleet-secret"), cctx.Bool("trust-forwarded-for"))
+			if err != nil {
+				return err
+			}
+			log.Infow("Routing requests between fleet members", "self", cctx.String("fleet-self"),
+				"members", strings.Join(cctx.StringSlice("fleet-member"), ", "))
+		}
+
+		var denylist *Denylist
+		if cctx.IsSet("denylist") {
+			denylist, err = NewDenylist(ctx, cctx.StringSlice("denylist"), cctx.Duration("denylist-refresh-interval"))
+			if err != nil {
+				return err
+			}
+			log.Infow("Enforcing denylist", "sources", strings.Join(cctx.StringSlice("denylist"), ", "))
+		}
+
+		var policy *RetrievalPolicy
+		if cctx.Bool("retrieval-policy") {
+			engine := retrievalpolicy.NewEngine()
+			err = engine.Watch(ctx, bapi.BoostRetrievalPolicy, cctx.Duration("retrieval-policy-refresh-interval"))
+			if err != nil {
+				return err
+			}
+			pieces, err := retrievalpolicy.NewPieceLookup(bapi.BoostDagstorePiecesContainingMultihash, retrievalPolicyLookupCacheSize)
+			if err != nil {
+				return err
+			}
+			policy = NewRetrievalPolicy(engine, pieces, cctx.Bool("trust-forwarded-for"))
+			log.Info("Applying the retrieval policy configured in boostd")
+		}
+
+		var quotas *Quotas
+		if cctx.Bool("retrieval-quotas") {
+			if !cctx.Bool("retrieval-log") {
+				log.Warn("Retrieval quotas are enabled without --retrieval-log: downloads from this instance will not count towards client quotas")
+			}
+			cache, err := quota.NewCache(bapi.BoostRetrievalQuota, retrievalQuotaCacheSize, cctx.Duration("retrieval-quotas-refresh-interval"))
+			if err != nil {
+				return err
+			}
+			quotas, err = NewQuotas(cache, cctx.Bool("trust-forwarded-for"))
+			if err != nil {
+				return err
+			}
+			log.Info("Applying the retrieval quotas configured in boostd")
+		}
+
+		var payments *Payments
+		if cctx.Bool("paid-retrievals") {
+			if auth == nil {
+				return errors.New("--paid-retrievals requires --auth-tokens-file or --auth-signing-key-file: the credit for paid retrievals is kept for each auth token")
+			}
+			payments = NewPayments(bapi)
+			err = payments.Watch(ctx, cctx.Duration("paid-retrievals-refresh-interval"))
+			if err != nil {
+				return err
+			}
+			log.Info("Charging for downloads at the price in the retrieval ask configured in boostd")
+		}
+
+		var retrievalLog *RetrievalLog
+		if cctx.Bool("retrieval-log") {
+			reporter := rtvllog.NewReporter(bapi.BoostRetrievalAttemptsAdd)
+			go reporter.Run(ctx, cctx.Duration("retrieval-log-interval"))
+			retrievalLog = NewRetrievalLog(reporter, cctx.Bool("trust-forwarded-for"))
+			log.Info("Recording downloads in the boostd retrievals log")
+		}
+
 		allowIndexing := cctx.Bool("allow-indexing")
 		// Create the server API
 		sapi := serverApi{ctx: ctx, bapi: bapi, sa: sa}
@@ -131,6 +404,22 @@ var runCmd = &cli.Command{
 			cctx.Int("port"),
 			allowIndexing,
 			sapi,
+			&HttpServerOptions{
+				Auth:       auth,
+				Cache:      cache,
+				ServeFiles: cctx.Bool("serve-files"),
+				TLS:        tlsConfig,
+				Limiter:    limiter,
+				AccessLog:  accessLog,
+				Fleet:      fleet,
+				Denylist:   denylist,
+
+				RetrievalPolicy: policy,
+				Quotas:          quotas,
+				Payments:        payments,
+				RetrievalLog:    retrievalLog,
+				ReadinessChecks: newReadinessChecks(bapi, fullnodeApi, storageApi),
+			},
 		)
 
 		// Start the server
@@ -143,6 +432,28 @@ var runCmd = &cli.Command{
 			indexingStr = "Disabled"
 		}
 		log.Info("On-the-fly indexing of CAR files is " + indexingStr)
+		if cctx.Bool("serve-files") {
+			log.Info("Serving files and directories at /ipfs/<cid>/<path>")
+		}
+		if auth == nil {
+			log.Info("Authentication is disabled")
+		} else {
+			log.Info("Authentication is required for downloads")
+		}
+		if limiter != nil {
+			log.Infow("Per-IP download limits are enabled",
+				"requests-per-second", limiter.opts.RequestsPerSecond, "burst", limiter.opts.RequestBurst,
+				"concurrent-requests", limiter.opts.MaxConcurrent, "bandwidth", cctx.String("limit-bandwidth"))
+		}
+		switch {
+		case tlsConfig == nil:
+			log.Info("Serving HTTP (TLS is disabled)")
+		case cctx.IsSet("acme-domain"):
+			log.Infof("Serving HTTPS with certificates from %s for %s",
+				cctx.String("acme-directory-url"), strings.Join(cctx.StringSlice("acme-domain"), ", "))
+		default:
+			log.Infof("Serving HTTPS with the certificate in %s", cctx.String("tls-cert-file"))
+		}
 		server.Start(ctx)
 
 		// Monitor for shutdown.
@@ -154,6 +465,11 @@ var runCmd = &cli.Command{
 		if err != nil {
 			return err
 		}
+		if auth != nil {
+			for _, u := range auth.Usage() {
+				log.Infow("download usage", "id", u.ID, "requests", u.Requests, "bytes-sent", u.BytesSent)
+			}
+		}
 		log.Info("Graceful shutdown successful")
 
 		// Sync all loggers.
@@ -170,6 +486,143 @@ var runCmd = &cli.Command{
 	},
 }
 
+// newAuthenticator returns nil if authentication is not enabled
+func newAuthenticator(cctx *cli.Context) (*Authenticator, error) {
+	if !cctx.IsSet("auth-tokens-file") && !cctx.IsSet("auth-signing-key-file") {
+		return nil, nil
+	}
+
+	var tokens map[string]string
+	if cctx.IsSet("auth-tokens-file") {
+		var err error
+		tokens, err = LoadTokensFile(cctx.String("auth-tokens-file"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var signingKey []byte
+	if cctx.IsSet("auth-signing-key-file") {
+		var err error
+		signingKey, err = LoadSigningKeyFile(cctx.String("auth-signing-key-file"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewAuthenticator(tokens, signingKey)
+}
+
+// newLimiter returns nil if there are no per-IP limits
+func newLimiter(cctx *cli.Context) (*Limiter, error) {
+	opts := LimitOptions{
+		RequestsPerSecond: cctx.Float64("limit-requests-per-second"),
+		RequestBurst:      cctx.Int("limit-request-burst"),
+		MaxConcurrent:     cctx.Int("limit-concurrent-requests"),
+		TrustForwardedFor: cctx.Bool("trust-forwarded-for"),
+	}
+	if cctx.String("limit-bandwidth") != "" {
+		bytesPerSecond, err := units.RAMInBytes(cctx.String("limit-bandwidth"))
+		if err != nil {
+			return nil, fmt.Errorf("parsing limit-bandwidth: %w", err)
+		}
+		opts.BytesPerSecond = bytesPerSecond
+	}
+	if opts.RequestsPerSecond <= 0 && opts.MaxConcurrent <= 0 && opts.BytesPerSecond <= 0 {
+		return nil, nil
+	}
+	return NewLimiter(opts), nil
+}
+
+// newAccessLogger returns nil if the access log is not enabled.
+// The returned function closes the access log file.
+func newAccessLogger(cctx *cli.Context) (*AccessLogger, func(), error) {
+	if !cctx.IsSet("access-log") {
+		return nil, func() {}, nil
+	}
+
+	var w io.Writer = os.Stdout
+	closeLog := func() {}
+	if path := cctx.String("access-log"); path != "-" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening access log: %w", err)
+		}
+		w = f
+		closeLog = func() {
+			if err := f.Close(); err != nil {
+				log.Warnw("closing access log", "err", err)
+			}
+		}
+	}
+
+	accessLog, err := NewAccessLogger(w, cctx.String("access-log-format"), cctx.Bool("trust-forwarded-for"))
+	if err != nil {
+		closeLog()
+		return nil, nil, err
+	}
+	return accessLog, closeLog, nil
+}
+
+// The piece directory readiness check looks up this CID, which is not
+// the CID of any piece. The check passes if the lookup returns not found.
+var readinessProbeCid = func() cid.Cid {
+	mh, _ := multihash.Sum([]byte("booster-http readiness probe"), multihash.IDENTITY, -1)
+	return cid.NewCidV1(cid.Raw, mh)
+}()
+
+// newReadinessChecks returns checks for each of the services that are
+// needed to serve data
+func newReadinessChecks(bapi api.Boost, fullnodeApi v1api.FullNode, storageApi v0api.StorageMiner) []ReadinessCheck {
+	return []ReadinessCheck{{
+		Name: "boost-api",
+		Check: func(ctx context.Context) error {
+			_, err := bapi.RuntimeSubsystems(ctx)
+			return err
+		},
+	}, {
+		// Pieces are looked up in the piece directory through the boost API
+		Name: "piece-directory",
+		Check: func(ctx context.Context) error {
+			_, err := bapi.PiecesGetPieceInfo(ctx, readinessProbeCid)
+			if err != nil && !isNotFoundError(err) {
+				return err
+			}
+			return nil
+		},
+	}, {
+		Name: "full-node-api",
+		Check: func(ctx context.Context) error {
+			_, err := fullnodeApi.ChainHead(ctx)
+			return err
+		},
+	}, {
+		// Unsealed sectors are found and unsealed through the storage API
+		Name: "storage-api",
+		Check: func(ctx context.Context) error {
+			_, err := storageApi.ActorAddress(ctx)
+			return err
+		},
+	}}
+}
+
+// newTLSConfig returns nil if TLS is not enabled
+func newTLSConfig(cctx *cli.Context) (*TLSConfig, error) {
+	if !cctx.IsSet("tls-cert-file") && !cctx.IsSet("tls-key-file") && !cctx.IsSet("acme-domain") {
+		return nil, nil
+	}
+
+	return NewTLSConfig(TLSOptions{
+		CertFile:         cctx.String("tls-cert-file"),
+		KeyFile:          cctx.String("tls-key-file"),
+		ACMEDomains:      cctx.StringSlice("acme-domain"),
+		ACMEEmail:        cctx.String("acme-email"),
+		ACMECacheDir:     cctx.String("acme-cache-dir"),
+		ACMEDirectoryURL: cctx.String("acme-directory-url"),
+		ACMEHTTPPort:     cctx.Int("acme-http-port"),
+	})
+}
+
 type serverApi struct {
 	ctx  context.Context
 	bapi api.Boost
@@ -190,6 +643,18 @@ func (s serverApi) UnsealSectorAt(ctx context.Context, sectorID abi.SectorNumber
 	return s.sa.UnsealSectorAt(ctx, sectorID, offset, length)
 }
 
+func (s serverApi) BlockstoreGet(ctx context.Context, c cid.Cid) ([]byte, error) {
+	return s.bapi.BlockstoreGet(ctx, c)
+}
+
+func (s serverApi) BlockstoreHas(ctx context.Context, c cid.Cid) (bool, error) {
+	return s.bapi.BlockstoreHas(ctx, c)
+}
+
+func (s serverApi) BlockstoreGetSize(ctx context.Context, c cid.Cid) (int, error) {
+	return s.bapi.BlockstoreGetSize(ctx, c)
+}
+
 func getBoostApi(ctx context.Context, ai string) (api.Boost, jsonrpc.ClientCloser, error) {
 	ai = strings.TrimPrefix(strings.TrimSpace(ai), "BOOST_API_INFO=")
 	info := cliutil.ParseApiInfo(ai)
@@ -11,7 +11,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/NYTimes/gziphandler"
 	"github.com/fatih/color"
 	"github.com/filecoin-project/boost/metrics"
 	"github.com/filecoin-project/boostd-data/shared/tracing"
@@ -22,6 +21,7 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
+	format "github.com/ipfs/go-ipld-format"
 	"go.opencensus.io/stats"
 )
 
@@ -43,20 +43,62 @@ type HttpServer struct {
 	port          int
 	allowIndexing bool
 	api           HttpServerApi
+	opts          HttpServerOptions
 
 	ctx    context.Context
 	cancel context.CancelFunc
 	server *http.Server
+	// Answers ACME HTTP-01 challenges (nil if not using ACME)
+	acmeServer *http.Server
 }
 
 type HttpServerApi interface {
 	GetPieceInfo(pieceCID cid.Cid) (*piecestore.PieceInfo, error)
 	IsUnsealed(ctx context.Context, sectorID abi.SectorNumber, offset abi.UnpaddedPieceSize, length abi.UnpaddedPieceSize) (bool, error)
 	UnsealSectorAt(ctx context.Context, sectorID abi.SectorNumber, pieceOffset abi.UnpaddedPieceSize, length abi.UnpaddedPieceSize) (mount.Reader, error)
+	BlockstoreGet(ctx context.Context, c cid.Cid) ([]byte, error)
+	BlockstoreHas(ctx context.Context, c cid.Cid) (bool, error)
+	BlockstoreGetSize(ctx context.Context, c cid.Cid) (int, error)
 }
 
-func NewHttpServer(path string, port int, allowIndexing bool, api HttpServerApi) *HttpServer {
-	return &HttpServer{path: path, port: port, allowIndexing: allowIndexing, api: api}
+// HttpServerOptions are the optional features of the HTTP server
+type HttpServerOptions struct {
+	// If Auth is nil, downloads don't require authentication
+	Auth *Authenticator
+	// If Cache is nil, served data is not cached
+	Cache *DiskCache
+	// Serve the files and directories in unixfs DAGs at /ipfs/<cid>/<path>
+	// (not just blocks and CAR files)
+	ServeFiles bool
+	// If TLS is nil, the server serves HTTP (not HTTPS)
+	TLS *TLSConfig
+	// If Limiter is nil, there are no per-IP limits on downloads
+	Limiter *Limiter
+	// If AccessLog is nil, download requests are not written to an access log
+	AccessLog *AccessLogger
+	// If Fleet is nil, all requests are served by this instance
+	Fleet *Fleet
+	// If Denylist is nil, all content is served
+	Denylist *Denylist
+	// If RetrievalPolicy is nil, all clients can download all content
+	RetrievalPolicy *RetrievalPolicy
+	// If Quotas is nil, clients have no retrieval quota
+	Quotas *Quotas
+	// If Payments is nil, downloads are free
+	Payments *Payments
+	// If RetrievalLog is nil, downloads are not reported to boostd
+	RetrievalLog *RetrievalLog
+	// The checks that must pass for the server to report that it's ready
+	// to serve data at /readyz
+	ReadinessChecks []ReadinessCheck
+}
+
+func NewHttpServer(path string, port int, allowIndexing bool, api HttpServerApi, opts *HttpServerOptions) *HttpServer {
+	s := &HttpServer{path: path, port: port, allowIndexing: allowIndexing, api: api}
+	if opts != nil {
+		s.opts = *opts
+	}
+	return s
 }
 
 func (s *HttpServer) pieceBasePath() string {
@@ -68,14 +110,25 @@ func (s *HttpServer) Start(ctx context.Context) {
 
 	listenAddr := fmt.Sprintf(":%d", s.port)
 	handler := http.NewServeMux()
-	handler.HandleFunc(s.pieceBasePath(), s.handleByPieceCid)
+	handler.HandleFunc(s.pieceBasePath(), s.instrument(endpointPiece, s.pieceBasePath(),
+		s.fleetHandler(s.pieceBasePath(), pieceSizeCacheKey, s.downloadHandler(s.handleByPieceCid))))
+	handler.HandleFunc(s.ipfsBasePath(), s.instrument(endpointIpfs, s.ipfsBasePath(),
+		s.fleetHandler(s.ipfsBasePath(), blockCacheKey, s.downloadHandler(s.handleGateway))))
+	if s.opts.Fleet != nil {
+		handler.HandleFunc(s.path+"/fleet", s.opts.Fleet.handleStatus)
+	}
+	if s.opts.Auth != nil {
+		handler.HandleFunc(s.path+"/usage", s.limitHandler(s.opts.Auth.handleUsage))
+	}
 	handler.HandleFunc("/", s.handleIndex)
 	handler.HandleFunc("/index.html", s.handleIndex)
 	handler.HandleFunc("/info", s.handleInfo)
+	handler.HandleFunc("/healthz", s.handleHealthz)
+	handler.HandleFunc("/readyz", s.handleReadyz)
 	handler.Handle("/metrics", metrics.Exporter("booster_http")) // metrics
 	s.server = &http.Server{
 		Addr:    listenAddr,
-		Handler: handler,
+		Handler: fleetHeadersHandler(s.opts.Fleet, handler),
 		// This context will be the parent of the context associated with all
 		// incoming requests
 		BaseContext: func(listener net.Listener) context.Context {
@@ -83,15 +136,85 @@ func (s *HttpServer) Start(ctx context.Context) {
 		},
 	}
 
+	if s.opts.Fleet != nil {
+		s.opts.Fleet.Start(s.ctx)
+	}
+	if s.opts.Denylist != nil {
+		s.opts.Denylist.Start(s.ctx)
+	}
+
+	if s.opts.TLS == nil {
+		go func() {
+			if err := s.server.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatalf("http.ListenAndServe(): %w", err)
+			}
+		}()
+		return
+	}
+
+	s.server.TLSConfig = s.opts.TLS.config
 	go func() {
-		if err := s.server.ListenAndServe(); err != http.ErrServerClosed {
-			log.Fatalf("http.ListenAndServe(): %w", err)
+		// The certificate is in the TLS config, so no cert or key file is
+		// passed here
+		if err := s.server.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
+			log.Fatalf("http.ListenAndServeTLS(): %w", err)
 		}
 	}()
+
+	if s.opts.TLS.acme != nil && s.opts.TLS.acmeHTTPPort != 0 {
+		// Answer ACME HTTP-01 challenges, and redirect all other HTTP
+		// requests to HTTPS
+		s.acmeServer = &http.Server{
+			Addr:    fmt.Sprintf(":%d", s.opts.TLS.acmeHTTPPort),
+			Handler: s.opts.TLS.acme.HTTPHandler(nil),
+		}
+		go func() {
+			if err := s.acmeServer.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatalf("ACME http.ListenAndServe(): %w", err)
+			}
+		}()
+	}
+}
+
+// downloadHandler applies the per-IP limits, authentication, quotas and
+// payments (if enabled) to a download handler, and reports each download to
+// boostd (if enabled).
+// Limits are checked before authentication so that clients can't make an
+// unlimited number of attempts to guess a token.
+func (s *HttpServer) downloadHandler(handler http.HandlerFunc) http.HandlerFunc {
+	if s.opts.RetrievalLog != nil {
+		handler = s.opts.RetrievalLog.identify(handler)
+	}
+	if s.opts.Payments != nil {
+		handler = s.opts.Payments.wrap(handler)
+	}
+	if s.opts.Quotas != nil {
+		handler = s.opts.Quotas.wrap(handler)
+	}
+	if s.opts.Auth != nil {
+		handler = s.opts.Auth.wrap(handler)
+	}
+	handler = s.limitHandler(handler)
+	if s.opts.RetrievalLog != nil {
+		handler = s.opts.RetrievalLog.wrap(s.pieceBasePath(), s.ipfsBasePath(), handler)
+	}
+	return handler
+}
+
+func (s *HttpServer) limitHandler(handler http.HandlerFunc) http.HandlerFunc {
+	if s.opts.Limiter == nil {
+		return handler
+	}
+	return s.opts.Limiter.wrap(handler)
 }
 
 func (s *HttpServer) Stop() error {
 	s.cancel()
+	if s.acmeServer != nil {
+		if err := s.acmeServer.Close(); err != nil {
+			log.Warnw("closing ACME challenge server", "err", err)
+		}
+	}
 	return s.server.Close()
 }
 
@@ -109,6 +232,41 @@ const idxPage = `
         <td>
           <a href="/piece/bafySomePieceCid" > /piece/<piece cid></a>
         </td>
+      </tr>
+      <tr>
+        <td>
+          Download part of a piece: set the Range header, eg
+        </td>
+        <td>
+          Range: bytes=0-1023,4096-
+        </td>
+      </tr>
+      <tr>
+        <td>
+          Download a block or a CAR file by its CID (trustless gateway)
+        </td>
+        <td>
+          <a href="/ipfs/bafySomeCid?format=car" > /ipfs/<cid>[/path]?format=car&dag-scope=all|entity|block&entity-bytes=from:to</a>
+          or <a href="/ipfs/bafySomeCid?format=raw" > /ipfs/<cid>?format=raw</a>
+        </td>
+      </tr>
+      <tr>
+        <td>
+          Download part of a DAG as a CAR file with an IPLD selector (dag-json) or a data model path
+        </td>
+        <td>
+          /ipfs/<cid>[/path]?format=car&selector=<dag-json selector>
+          or <a href="/ipfs/bafySomeCid?format=car&dm-path=Links/0/Hash" > /ipfs/<cid>[/path]?format=car&dm-path=<path>&dag-scope=all|block</a>
+        </td>
+      </tr>
+      <tr>
+        <td>
+          Download a file or list a directory (if enabled with --serve-files)
+        </td>
+        <td>
+          <a href="/ipfs/bafySomeCid/path/to/file" > /ipfs/<cid>[/path]</a>
+        </td>
+      </tr>
       </tbody>
     </table>
   </body>
@@ -153,8 +311,28 @@ func (s *HttpServer) handleByPieceCid(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if s.opts.Denylist != nil && s.opts.Denylist.IsDenied(pieceCid, nil) {
+		writeDenied(w, r)
+		return
+	}
+	if s.opts.RetrievalPolicy != nil && !s.opts.RetrievalPolicy.allowPiece(w, r, pieceCid) {
+		return
+	}
+
 	// Get a reader over the piece
-	content, err := s.getPieceContent(ctx, pieceCid)
+	var content io.ReadSeeker
+	if s.opts.Cache == nil {
+		content, err = s.getPieceContent(ctx, pieceCid)
+	} else {
+		var pr *cachedPieceReader
+		pr, err = newCachedPieceReader(s.opts.Cache, pieceCid, func() (io.ReadSeeker, error) {
+			return s.getPieceContent(ctx, pieceCid)
+		})
+		if err == nil {
+			defer pr.Close() //nolint:errcheck
+			content = pr
+		}
+	}
 	if err != nil {
 		if isNotFoundError(err) {
 			writeError(w, r, http.StatusNotFound, err.Error())
@@ -168,8 +346,11 @@ func (s *HttpServer) handleByPieceCid(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set an Etag based on the piece cid
-	etag := pieceCid.String()
+	// Set an Etag based on the piece cid.
+	// Note that the Etag must be quoted for http.ServeContent to match it
+	// against an If-Range header, so that an interrupted download can be
+	// resumed with a range request.
+	etag := `"` + pieceCid.String() + `"`
 	w.Header().Set("Etag", etag)
 
 	serveContent(w, r, content)
@@ -197,19 +378,21 @@ func serveContent(w http.ResponseWriter, r *http.Request, content io.ReadSeeker)
 	// Note that the last modified time is a constant value because the data
 	// in a piece identified by a cid will never change.
 	start := time.Now()
-	alogAt(start, "%s\tGET %s", color.New(color.FgGreen).Sprintf("%d", http.StatusOK), r.URL)
-	isGzipped := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
-	if isGzipped {
-		// If Accept-Encoding header contains gzip then send a gzipped response
-
-		gzwriter := gziphandler.GzipResponseWriter{
-			ResponseWriter: writeErrWatcher,
-		}
-		// Close the writer to flush buffer
-		defer gzwriter.Close()
-		writer = &gzwriter
+	rangeHeader := r.Header.Get("Range")
+	if rangeHeader == "" {
+		alogAt(start, "%s\tGET %s", color.New(color.FgGreen).Sprintf("%d", http.StatusOK), r.URL)
+	} else {
+		alogAt(start, "%s\tGET %s (%s)", color.New(color.FgGreen).Sprintf("%d", http.StatusPartialContent), r.URL, rangeHeader)
 	}
 
+	// http.ServeContent serves Range requests (including multi-range
+	// requests) from the content. The byte ranges refer to the uncompressed
+	// content, so responses to Range requests are never compressed.
+	cw := compressResponse(writeErrWatcher, r)
+	// Close the writer to flush the compressed data
+	defer cw.Close() //nolint:errcheck
+	writer = cw
+
 	if r.Method == "HEAD" {
 		// For an HTTP HEAD request ServeContent doesn't send any data (just headers)
 		http.ServeContent(writer, r, "", time.Time{}, content)
@@ -224,8 +407,11 @@ func serveContent(w http.ResponseWriter, r *http.Request, content io.ReadSeeker)
 	end := time.Now()
 	completeMsg := fmt.Sprintf("GET %s\n%s - %s: %s / %s bytes transferred",
 		r.URL, end.Format(timeFmt), start.Format(timeFmt), time.Since(start), addCommas(writeErrWatcher.count))
-	if isGzipped {
-		completeMsg += " (gzipped)"
+	if rangeHeader != "" {
+		completeMsg += fmt.Sprintf(" (range %s: status %d)", rangeHeader, writeErrWatcher.status)
+	}
+	if cw.Encoding() != "" {
+		completeMsg += fmt.Sprintf(" (%s compressed)", cw.Encoding())
 	}
 	if err == nil {
 		alogAt(end, "%s\t%s", color.New(color.FgGreen).Sprint("DONE"), completeMsg)
@@ -248,6 +434,9 @@ func isNotFoundError(err error) bool {
 	if errors.Is(err, retrievalmarket.ErrNotFound) {
 		return true
 	}
+	if format.IsNotFound(err) {
+		return true
+	}
 	return strings.Contains(strings.ToLower(err.Error()), "not found")
 }
 
@@ -333,9 +522,15 @@ func (s *HttpServer) unsealedDeal(ctx context.Context, pieceInfo piecestore.Piec
 type writeErrorWatcher struct {
 	http.ResponseWriter
 	count   uint64
+	status  int
 	onError func(err error)
 }
 
+func (w *writeErrorWatcher) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
 func (w *writeErrorWatcher) Write(bz []byte) (int, error) {
 	count, err := w.ResponseWriter.Write(bz)
 	if err != nil {
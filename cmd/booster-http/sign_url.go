@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+var signUrlCmd = &cli.Command{
+	Name:      "sign-url",
+	Usage:     "Sign a download URL so that it can be used without a bearer token until it expires",
+	ArgsUsage: "<url>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "signing-key-file",
+			Usage:    "the file with the signing key (the same file as booster-http run --auth-signing-key-file)",
+			Required: true,
+		},
+		&cli.DurationFlag{
+			Name:  "expiry",
+			Usage: "how long the signed URL is valid for",
+			Value: 24 * time.Hour,
+		},
+		&cli.StringFlag{
+			Name:  "id",
+			Usage: "the id that downloads with the signed URL are accounted to",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return fmt.Errorf("usage: sign-url <url>")
+		}
+
+		u, err := url.Parse(cctx.Args().First())
+		if err != nil {
+			return fmt.Errorf("parsing url: %w", err)
+		}
+
+		signingKey, err := LoadSigningKeyFile(cctx.String("signing-key-file"))
+		if err != nil {
+			return err
+		}
+
+		sig := SignUrlPath(signingKey, u.Path, cctx.String("id"), time.Now().Add(cctx.Duration("expiry")))
+		if u.RawQuery == "" {
+			u.RawQuery = sig
+		} else {
+			u.RawQuery += "&" + sig
+		}
+		fmt.Println(u.String())
+		return nil
+	},
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLSOptions configures the server to serve HTTPS, either with a certificate
+// from files or with certificates that are provisioned and renewed
+// automatically by an ACME server (eg Let's Encrypt)
+type TLSOptions struct {
+	// The certificate and key files (if the certificate is not from ACME)
+	CertFile string
+	KeyFile  string
+
+	// The domains to get certificates for from the ACME server
+	ACMEDomains []string
+	// The contact email for the ACME account (optional)
+	ACMEEmail string
+	// The directory in which the ACME account key and certificates are kept
+	ACMECacheDir string
+	// The ACME server directory URL. Defaults to Let's Encrypt.
+	ACMEDirectoryURL string
+	// The port on which to answer ACME HTTP-01 challenges (and redirect
+	// HTTP requests to HTTPS). If zero, only TLS-ALPN-01 challenges are
+	// answered, which requires the server to be reachable on port 443.
+	ACMEHTTPPort int
+}
+
+// TLSConfig is the TLS configuration of the server
+type TLSConfig struct {
+	config *tls.Config
+	// acme is nil if the certificate is from files
+	acme         *autocert.Manager
+	acmeHTTPPort int
+}
+
+// NewTLSConfig checks the options and loads the certificate (if the
+// certificate is from files)
+func NewTLSConfig(opts TLSOptions) (*TLSConfig, error) {
+	useFiles := opts.CertFile != "" || opts.KeyFile != ""
+	useACME := len(opts.ACMEDomains) > 0
+	if useFiles && useACME {
+		return nil, errors.New("a TLS certificate can be set with either cert and key files or ACME domains, but not both")
+	}
+
+	if useFiles {
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return nil, errors.New("both a TLS cert file and key file must be set")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading TLS certificate: %w", err)
+		}
+		return &TLSConfig{
+			config: &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{cert},
+			},
+		}, nil
+	}
+
+	if !useACME {
+		return nil, errors.New("TLS requires either cert and key files or ACME domains")
+	}
+	if opts.ACMECacheDir == "" {
+		return nil, errors.New("an ACME cache directory must be set so that certificates are kept between restarts")
+	}
+	if err := os.MkdirAll(opts.ACMECacheDir, 0700); err != nil {
+		return nil, fmt.Errorf("creating ACME cache directory %s: %w", opts.ACMECacheDir, err)
+	}
+
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(opts.ACMECacheDir),
+		HostPolicy: autocert.HostWhitelist(opts.ACMEDomains...),
+		Email:      opts.ACMEEmail,
+	}
+	if opts.ACMEDirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: opts.ACMEDirectoryURL}
+	}
+	config := m.TLSConfig()
+	config.MinVersion = tls.VersionTLS12
+	return &TLSConfig{config: config, acme: m, acmeHTTPPort: opts.ACMEHTTPPort}, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpTLS(t *testing.T) {
+	certFile, keyFile, pool := createTestCert(t)
+	tlsConfig, err := NewTLSConfig(TLSOptions{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+
+	httpServer := NewHttpServer("", 7777, false, nil, &HttpServerOptions{TLS: tlsConfig})
+	httpServer.Start(context.Background())
+	defer httpServer.Stop() //nolint:errcheck
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	var response *http.Response
+	require.Eventually(t, func() bool {
+		response, err = client.Get("https://localhost:7777/info")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.NotNil(t, response.TLS)
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	certFile, keyFile, _ := createTestCert(t)
+
+	// The certificate can be from files or from ACME, but not both
+	_, err := NewTLSConfig(TLSOptions{CertFile: certFile, KeyFile: keyFile, ACMEDomains: []string{"example.com"}})
+	require.Error(t, err)
+	_, err = NewTLSConfig(TLSOptions{CertFile: certFile})
+	require.Error(t, err)
+	_, err = NewTLSConfig(TLSOptions{})
+	require.Error(t, err)
+
+	// ACME requires a cache directory
+	_, err = NewTLSConfig(TLSOptions{ACMEDomains: []string{"example.com"}})
+	require.Error(t, err)
+
+	cacheDir := filepath.Join(t.TempDir(), "acme")
+	cfg, err := NewTLSConfig(TLSOptions{ACMEDomains: []string{"example.com"}, ACMECacheDir: cacheDir, ACMEHTTPPort: 80})
+	require.NoError(t, err)
+	require.NotNil(t, cfg.acme)
+	require.Contains(t, cfg.config.NextProtos, "acme-tls/1")
+	require.DirExists(t, cacheDir)
+
+	// Only whitelisted domains get certificates
+	require.Error(t, cfg.acme.HostPolicy(context.Background(), "other.com"))
+	require.NoError(t, cfg.acme.HostPolicy(context.Background(), "example.com"))
+}
+
+// createTestCert writes a self-signed certificate for localhost and its key
+// to files, and returns the files and a cert pool with the certificate
+func createTestCert(t *testing.T) (string, string, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	require.NoError(t, err)
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/urfave/cli/v2"
+)
+
+var FlagLogFormat = &cli.StringFlag{
+	Name: "log-format",
+	Usage: "the format of log output: 'text' for human readable lines or 'json' for JSON lines. " +
+		"Deal logs include the deal_uuid, client, piece_cid and module fields.",
+	Value:   "text",
+	EnvVars: []string{"BOOST_LOG_FORMAT"},
+}
+
+// SetupLogFormat applies the log format flag. The text format leaves the
+// format set by the GOLOG_LOG_FMT environment variable unchanged.
+func SetupLogFormat(cctx *cli.Context) error {
+	switch format := cctx.String(FlagLogFormat.Name); format {
+	case "", "text":
+		return nil
+	case "json":
+		cfg := logging.GetConfig()
+		cfg.Format = logging.JSONOutput
+		logging.SetupLogging(cfg)
+		return nil
+	default:
+		return fmt.Errorf("unrecognized log format '%s': must be 'text' or 'json'", format)
+	}
+}
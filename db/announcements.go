@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PendingAnnouncement is an announcement of a deal to the network indexer
+// that failed and is waiting to be retried
+type PendingAnnouncement struct {
+	DealUUID  uuid.UUID
+	CreatedAt time.Time
+	// The number of failed attempts to announce the deal
+	Attempts int
+	// The time after which the announcement should be retried
+	NextAttempt time.Time
+	// The error from the last failed attempt
+	LastError string
+}
+
+type PendingAnnouncementsDB struct {
+	db *sql.DB
+}
+
+func NewPendingAnnouncementsDB(db *sql.DB) *PendingAnnouncementsDB {
+	return &PendingAnnouncementsDB{db: db}
+}
+
+// Add adds an announcement to the queue after the first failed attempt.
+// If the deal is already in the queue, its attempt count is reset.
+func (p *PendingAnnouncementsDB) Add(ctx context.Context, dealUuid uuid.UUID, lastErr string, nextAttempt time.Time) error {
+	qry := "INSERT INTO PendingAnnouncements (DealUUID, CreatedAt, Attempts, NextAttempt, LastError) VALUES (?, ?, 1, ?, ?) " +
+		"ON CONFLICT(DealUUID) DO UPDATE SET Attempts = 1, NextAttempt = excluded.NextAttempt, LastError = excluded.LastError"
+	_, err := p.db.ExecContext(ctx, qry, dealUuid, time.Now(), nextAttempt, lastErr)
+	return err
+}
+
+// RecordFailure records another failed attempt to announce the deal
+func (p *PendingAnnouncementsDB) RecordFailure(ctx context.Context, dealUuid uuid.UUID, lastErr string, nextAttempt time.Time) error {
+	qry := "UPDATE PendingAnnouncements SET Attempts = Attempts + 1, NextAttempt = ?, LastError = ? WHERE DealUUID = ?"
+	_, err := p.db.ExecContext(ctx, qry, nextAttempt, lastErr, dealUuid)
+	return err
+}
+
+// Delete removes the announcement from the queue, once the deal has been
+// announced
+func (p *PendingAnnouncementsDB) Delete(ctx context.Context, dealUuid uuid.UUID) error {
+	_, err := p.db.ExecContext(ctx, "DELETE FROM PendingAnnouncements WHERE DealUUID = ?", dealUuid)
+	return err
+}
+
+// Due returns up to limit announcements that are due to be retried at the
+// given time, oldest attempt first
+func (p *PendingAnnouncementsDB) Due(ctx context.Context, now time.Time, limit int) ([]PendingAnnouncement, error) {
+	qry := "SELECT DealUUID, CreatedAt, Attempts, NextAttempt, LastError FROM PendingAnnouncements " +
+		"WHERE NextAttempt <= ? ORDER BY NextAttempt LIMIT ?"
+	return p.list(ctx, qry, now, limit)
+}
+
+// Count returns the number of announcements in the queue
+func (p *PendingAnnouncementsDB) Count(ctx context.Context) (int, error) {
+	var count int
+	err := p.db.QueryRowContext(ctx, "SELECT count(*) FROM PendingAnnouncements").Scan(&count)
+	return count, err
+}
+
+// List returns all announcements in the queue, in order of creation
+func (p *PendingAnnouncementsDB) List(ctx context.Context) ([]PendingAnnouncement, error) {
+	qry := "SELECT DealUUID, CreatedAt, Attempts, NextAttempt, LastError FROM PendingAnnouncements ORDER BY CreatedAt"
+	return p.list(ctx, qry)
+}
+
+func (p *PendingAnnouncementsDB) list(ctx context.Context, qry string, args ...interface{}) ([]PendingAnnouncement, error) {
+	rows, err := p.db.QueryContext(ctx, qry, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var anns []PendingAnnouncement
+	for rows.Next() {
+		var ann PendingAnnouncement
+		err := rows.Scan(&ann.DealUUID, &ann.CreatedAt, &ann.Attempts, &ann.NextAttempt, &ann.LastError)
+		if err != nil {
+			return nil, err
+		}
+		anns = append(anns, ann)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return anns, nil
+}
+
+// The reasons that the announcement of a deal is removed from the network
+// indexer
+const (
+	AnnouncementRemovedExpired    = "expired"
+	AnnouncementRemovedSlashed    = "slashed"
+	AnnouncementRemovedNoUnsealed = "no-unsealed-copy"
+	// The piece was removed by the user (see boostd pieces remove)
+	AnnouncementRemovedPieceRemoved = "piece-removed"
+)
+
+// RemovedAnnouncement is a deal for which a removal advertisement has been
+// published, so that indexers stop routing retrievals to it
+type RemovedAnnouncement struct {
+	DealUUID  uuid.UUID
+	RemovedAt time.Time
+	Reason    string
+}
+
+type RemovedAnnouncementsDB struct {
+	db *sql.DB
+}
+
+func NewRemovedAnnouncementsDB(db *sql.DB) *RemovedAnnouncementsDB {
+	return &RemovedAnnouncementsDB{db: db}
+}
+
+func (r *RemovedAnnouncementsDB) Add(ctx context.Context, dealUuid uuid.UUID, reason string) error {
+	qry := "INSERT INTO RemovedAnnouncements (DealUUID, RemovedAt, Reason) VALUES (?, ?, ?) " +
+		"ON CONFLICT(DealUUID) DO UPDATE SET RemovedAt = excluded.RemovedAt, Reason = excluded.Reason"
+	_, err := r.db.ExecContext(ctx, qry, dealUuid, time.Now(), reason)
+	return err
+}
+
+// Delete is called when a deal is announced again
+func (r *RemovedAnnouncementsDB) Delete(ctx context.Context, dealUuid uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM RemovedAnnouncements WHERE DealUUID = ?", dealUuid)
+	return err
+}
+
+// List returns the removed announcements keyed by deal uuid
+func (r *RemovedAnnouncementsDB) List(ctx context.Context) (map[uuid.UUID]RemovedAnnouncement, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT DealUUID, RemovedAt, Reason FROM RemovedAnnouncements")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	removed := make(map[uuid.UUID]RemovedAnnouncement)
+	for rows.Next() {
+		var ra RemovedAnnouncement
+		if err := rows.Scan(&ra.DealUUID, &ra.RemovedAt, &ra.Reason); err != nil {
+			return nil, err
+		}
+		removed[ra.DealUUID] = ra
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
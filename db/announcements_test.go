@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPendingAnnouncementsDB(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateAllBoostTables(ctx, sqldb, sqldb))
+	req.NoError(migrations.Migrate(sqldb))
+
+	pdb := NewPendingAnnouncementsDB(sqldb)
+
+	now := time.Now()
+	deal1 := uuid.New()
+	deal2 := uuid.New()
+	req.NoError(pdb.Add(ctx, deal1, "indexer unreachable", now.Add(-time.Minute)))
+	req.NoError(pdb.Add(ctx, deal2, "indexer unreachable", now.Add(time.Hour)))
+
+	anns, err := pdb.List(ctx)
+	req.NoError(err)
+	req.Len(anns, 2)
+	req.Equal(deal1, anns[0].DealUUID)
+	req.Equal(1, anns[0].Attempts)
+	req.Equal("indexer unreachable", anns[0].LastError)
+
+	// Only the first announcement is due
+	due, err := pdb.Due(ctx, now, 10)
+	req.NoError(err)
+	req.Len(due, 1)
+	req.Equal(deal1, due[0].DealUUID)
+
+	req.NoError(pdb.RecordFailure(ctx, deal1, "timed out", now.Add(time.Hour)))
+	due, err = pdb.Due(ctx, now, 10)
+	req.NoError(err)
+	req.Empty(due)
+
+	anns, err = pdb.List(ctx)
+	req.NoError(err)
+	req.Equal(2, anns[0].Attempts)
+	req.Equal("timed out", anns[0].LastError)
+
+	// Adding a deal that is already queued resets the attempts
+	req.NoError(pdb.Add(ctx, deal1, "connection refused", now))
+	anns, err = pdb.List(ctx)
+	req.NoError(err)
+	req.Len(anns, 2)
+	req.Equal(1, anns[0].Attempts)
+	req.Equal("connection refused", anns[0].LastError)
+
+	req.NoError(pdb.Delete(ctx, deal1))
+	anns, err = pdb.List(ctx)
+	req.NoError(err)
+	req.Len(anns, 1)
+	req.Equal(deal2, anns[0].DealUUID)
+
+	count, err := pdb.Count(ctx)
+	req.NoError(err)
+	req.Equal(1, count)
+}
+
+func TestRemovedAnnouncementsDB(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateAllBoostTables(ctx, sqldb, sqldb))
+	req.NoError(migrations.Migrate(sqldb))
+
+	rdb := NewRemovedAnnouncementsDB(sqldb)
+
+	deal1 := uuid.New()
+	deal2 := uuid.New()
+	req.NoError(rdb.Add(ctx, deal1, AnnouncementRemovedNoUnsealed))
+	req.NoError(rdb.Add(ctx, deal2, AnnouncementRemovedSlashed))
+
+	removed, err := rdb.List(ctx)
+	req.NoError(err)
+	req.Len(removed, 2)
+	req.Equal(AnnouncementRemovedNoUnsealed, removed[deal1].Reason)
+	req.Equal(AnnouncementRemovedSlashed, removed[deal2].Reason)
+
+	// Adding a deal again updates the reason
+	req.NoError(rdb.Add(ctx, deal1, AnnouncementRemovedExpired))
+	removed, err = rdb.List(ctx)
+	req.NoError(err)
+	req.Len(removed, 2)
+	req.Equal(AnnouncementRemovedExpired, removed[deal1].Reason)
+
+	req.NoError(rdb.Delete(ctx, deal1))
+	removed, err = rdb.List(ctx)
+	req.NoError(err)
+	req.Len(removed, 1)
+	req.Contains(removed, deal2)
+}
@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// AuditEventCheckpoint is recorded when a deal moves to a new checkpoint
+	AuditEventCheckpoint = "checkpoint"
+	// AuditEventAccepted is recorded when a deal proposal is accepted
+	AuditEventAccepted = "accepted"
+	// AuditEventRejected is recorded when a deal proposal is rejected
+	AuditEventRejected = "rejected"
+	// AuditEventOverride is recorded when an admin intervenes in a deal,
+	// eg to retry or fail a paused deal
+	AuditEventOverride = "override"
+	// AuditEventLowBalance is recorded when a tracked balance (eg the escrow
+	// balance or a publish wallet) falls below its alert threshold
+	AuditEventLowBalance = "low-balance"
+	// AuditEventBalanceRestored is recorded when a balance that was below its
+	// alert threshold rises back above it
+	AuditEventBalanceRestored = "balance-restored"
+	// AuditEventStartEpochAtRisk is recorded when the sector that a deal was
+	// added to is projected to finish sealing after the deal's start epoch
+	AuditEventStartEpochAtRisk = "start-epoch-at-risk"
+	// AuditEventAlert is recorded when the condition of an alert rule is
+	// met. The event's rule is the name of the alert rule.
+	AuditEventAlert = "alert"
+	// AuditEventAlertResolved is recorded when the condition of an alert
+	// rule that was firing is no longer met
+	AuditEventAlertResolved = "alert-resolved"
+)
+
+// AuditActorSystem is the actor for events that boost initiated itself
+const AuditActorSystem = "system"
+
+type AuditEvent struct {
+	Seq       uint64
+	CreatedAt time.Time
+	DealUUID  uuid.UUID
+	Type      string
+	// The identity of whoever caused the event
+	Actor          string
+	FromCheckpoint string
+	ToCheckpoint   string
+	// For acceptance decisions, the rule that made the decision
+	Rule   string
+	Detail string
+}
+
+// AuditLogDB is an append-only log of deal events. Rows can't be
+// updated or deleted (this is enforced by triggers in the database).
+type AuditLogDB struct {
+	db *sql.DB
+}
+
+func NewAuditLogDB(db *sql.DB) *AuditLogDB {
+	return &AuditLogDB{db: db}
+}
+
+func (a *AuditLogDB) Insert(ctx context.Context, evt *AuditEvent) error {
+	defer queryTimer(ctx, "audit_insert")()
+
+	if evt.CreatedAt.IsZero() {
+		evt.CreatedAt = time.Now()
+	}
+	qry := "INSERT INTO AuditLog (CreatedAt, DealUUID, EventType, Actor, FromCheckpoint, ToCheckpoint, Rule, Detail) "
+	qry += "VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	values := []interface{}{evt.CreatedAt, evt.DealUUID, evt.Type, evt.Actor, evt.FromCheckpoint, evt.ToCheckpoint, evt.Rule, evt.Detail}
+	_, err := a.db.ExecContext(ctx, qry, values...)
+	return err
+}
+
+// List returns audit events in reverse order of creation.
+// If dealUuid is not nil, only events for that deal are returned.
+// If cursor is not nil, only events with a sequence number less than or
+// equal to the cursor are returned.
+func (a *AuditLogDB) List(ctx context.Context, dealUuid *uuid.UUID, cursor *uint64, offset int, limit int) ([]AuditEvent, error) {
+	qry := "SELECT Seq, CreatedAt, DealUUID, EventType, Actor, FromCheckpoint, ToCheckpoint, Rule, Detail FROM AuditLog"
+	where, args := auditLogWhere(dealUuid)
+	if cursor != nil {
+		if where == "" {
+			where += " WHERE "
+		} else {
+			where += " AND "
+		}
+		where += "Seq <= ?"
+		args = append(args, *cursor)
+	}
+
+	qry += where
+	qry += " ORDER BY Seq DESC"
+
+	if limit > 0 {
+		qry += " LIMIT ?"
+		args = append(args, limit)
+
+		if offset > 0 {
+			qry += " OFFSET ?"
+			args = append(args, offset)
+		}
+	}
+
+	rows, err := a.db.QueryContext(ctx, qry, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAuditEvents(rows, limit)
+}
+
+// Since returns up to limit audit events with a sequence number greater
+// than seq, in order of creation
+func (a *AuditLogDB) Since(ctx context.Context, seq uint64, limit int) ([]AuditEvent, error) {
+	qry := "SELECT Seq, CreatedAt, DealUUID, EventType, Actor, FromCheckpoint, ToCheckpoint, Rule, Detail FROM AuditLog"
+	qry += " WHERE Seq > ? ORDER BY Seq LIMIT ?"
+	rows, err := a.db.QueryContext(ctx, qry, seq, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAuditEvents(rows, limit)
+}
+
+// LatestSeq returns the sequence number of the most recent audit event, or
+// zero if there are no events
+func (a *AuditLogDB) LatestSeq(ctx context.Context) (uint64, error) {
+	var seq uint64
+	row := a.db.QueryRowContext(ctx, "SELECT IFNULL(MAX(Seq), 0) FROM AuditLog")
+	err := row.Scan(&seq)
+	return seq, err
+}
+
+func scanAuditEvents(rows *sql.Rows, limit int) ([]AuditEvent, error) {
+	sz := limit
+	if sz == 0 {
+		sz = 16
+	}
+	evts := make([]AuditEvent, 0, sz)
+	for rows.Next() {
+		var evt AuditEvent
+		err := rows.Scan(
+			&evt.Seq,
+			&evt.CreatedAt,
+			&evt.DealUUID,
+			&evt.Type,
+			&evt.Actor,
+			&evt.FromCheckpoint,
+			&evt.ToCheckpoint,
+			&evt.Rule,
+			&evt.Detail)
+		if err != nil {
+			return nil, fmt.Errorf("getting audit event: %w", err)
+		}
+		evts = append(evts, evt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return evts, nil
+}
+
+func (a *AuditLogDB) Count(ctx context.Context, dealUuid *uuid.UUID) (int, error) {
+	var count int
+	where, args := auditLogWhere(dealUuid)
+	row := a.db.QueryRowContext(ctx, "SELECT count(*) FROM AuditLog"+where, args...)
+	err := row.Scan(&count)
+	return count, err
+}
+
+func auditLogWhere(dealUuid *uuid.UUID) (string, []interface{}) {
+	if dealUuid == nil {
+		return "", nil
+	}
+	return " WHERE DealUUID = ?", []interface{}{*dealUuid}
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLogDB(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateAllBoostTables(ctx, sqldb, sqldb))
+	req.NoError(migrations.Migrate(sqldb))
+
+	adb := NewAuditLogDB(sqldb)
+
+	deal1 := uuid.New()
+	deal2 := uuid.New()
+	req.NoError(adb.Insert(ctx, &AuditEvent{DealUUID: deal1, Type: AuditEventAccepted, Actor: AuditActorSystem, Rule: "default"}))
+	req.NoError(adb.Insert(ctx, &AuditEvent{DealUUID: deal1, Type: AuditEventCheckpoint, Actor: AuditActorSystem, FromCheckpoint: "Accepted", ToCheckpoint: "Transferred"}))
+	req.NoError(adb.Insert(ctx, &AuditEvent{DealUUID: deal2, Type: AuditEventRejected, Actor: AuditActorSystem, Rule: "validation", Detail: "invalid signature"}))
+
+	count, err := adb.Count(ctx, nil)
+	req.NoError(err)
+	req.Equal(3, count)
+
+	count, err = adb.Count(ctx, &deal1)
+	req.NoError(err)
+	req.Equal(2, count)
+
+	// Events should be listed newest first
+	evts, err := adb.List(ctx, &deal1, nil, 0, 0)
+	req.NoError(err)
+	req.Len(evts, 2)
+	req.Equal(AuditEventCheckpoint, evts[0].Type)
+	req.Equal("Transferred", evts[0].ToCheckpoint)
+	req.Equal(AuditEventAccepted, evts[1].Type)
+	req.Equal("default", evts[1].Rule)
+
+	// Use the second event as a cursor
+	evts, err = adb.List(ctx, nil, &evts[0].Seq, 0, 1)
+	req.NoError(err)
+	req.Len(evts, 1)
+	req.Equal(AuditEventCheckpoint, evts[0].Type)
+
+	// The audit log should be append-only
+	_, err = sqldb.ExecContext(ctx, "UPDATE AuditLog SET Actor='someone-else'")
+	req.ErrorContains(err, "append-only")
+	_, err = sqldb.ExecContext(ctx, "DELETE FROM AuditLog")
+	req.ErrorContains(err, "append-only")
+}
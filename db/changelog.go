@@ -0,0 +1,210 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/boost/db/fielddef"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/google/uuid"
+)
+
+// The kinds of change in the deal change log
+const (
+	// A deal was inserted or updated
+	DealChangeKindDeal = "deal"
+	// Funds were tagged for a deal
+	DealChangeKindFundsTagged = "funds-tagged"
+	// The funds tagged for a deal were released
+	DealChangeKindFundsUntagged = "funds-untagged"
+	// Staging area storage was tagged for a deal
+	DealChangeKindStorageTagged = "storage-tagged"
+	// The storage tagged for a deal was released
+	DealChangeKindStorageUntagged = "storage-untagged"
+	// A line was added to a deal's log
+	DealChangeKindLog = "log"
+)
+
+// DealChange is an entry in the deal change log: a change to a deal, or to
+// the funds, storage or logs of a deal. The field that is set depends on the
+// kind of change.
+type DealChange struct {
+	Seq       uint64
+	Kind      string
+	DealUUID  uuid.UUID
+	CreatedAt time.Time
+
+	// The state of the deal after it was inserted or updated
+	Deal *types.ProviderDealState `json:",omitempty"`
+	// The funds that were tagged for the deal
+	FundsTagged *FundsTagged `json:",omitempty"`
+	// The storage that was tagged for the deal
+	StorageTagged *StorageTagged `json:",omitempty"`
+	// The line that was added to the deal's log
+	Log *DealLog `json:",omitempty"`
+}
+
+// DealChangeLogDB is an append-only log of changes to deal state, that can
+// be streamed to a standby node or an external sink
+type DealChangeLogDB struct {
+	db     *sql.DB
+	cipher fielddef.Cipher
+}
+
+func NewDealChangeLogDB(db *sql.DB) *DealChangeLogDB {
+	return &DealChangeLogDB{db: db}
+}
+
+// NewEncryptedDealChangeLogDB returns a DealChangeLogDB that encrypts deal
+// state before writing it to the database
+func NewEncryptedDealChangeLogDB(db *sql.DB, c fielddef.Cipher) *DealChangeLogDB {
+	return &DealChangeLogDB{db: db, cipher: c}
+}
+
+func (c *DealChangeLogDB) Append(ctx context.Context, change *DealChange) error {
+	return c.append(ctx, c.db, change)
+}
+
+func (c *DealChangeLogDB) append(ctx context.Context, exec execer, change *DealChange) error {
+	if change.CreatedAt.IsZero() {
+		change.CreatedAt = time.Now()
+	}
+
+	bz, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("marshalling deal change: %w", err)
+	}
+	if c.cipher != nil {
+		bz, err = c.cipher.Encrypt(bz)
+		if err != nil {
+			return fmt.Errorf("encrypting deal change: %w", err)
+		}
+	}
+
+	qry := "INSERT INTO DealChangeLog (Kind, DealUUID, CreatedAt, Change) VALUES (?, ?, ?, ?)"
+	_, err = exec.ExecContext(ctx, qry, change.Kind, change.DealUUID, change.CreatedAt, bz)
+	return err
+}
+
+// writeWithChange calls write, and appends the change to the change log
+// (if there is one) in the same transaction, so that the change log never
+// misses or invents a change
+func writeWithChange(ctx context.Context, sqldb *sql.DB, cl *DealChangeLogDB, change *DealChange, write func(exec execer) error) error {
+	if cl == nil {
+		return write(sqldb)
+	}
+
+	tx, err := sqldb.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := write(tx); err != nil {
+		return err
+	}
+	if err := cl.append(ctx, tx, change); err != nil {
+		return fmt.Errorf("appending %s change for deal %s to change log: %w", change.Kind, change.DealUUID, err)
+	}
+	return tx.Commit()
+}
+
+// Since returns up to limit changes with a sequence number greater than seq,
+// in order of sequence number
+func (c *DealChangeLogDB) Since(ctx context.Context, seq uint64, limit int) ([]DealChange, error) {
+	qry := "SELECT Seq, Kind, DealUUID, CreatedAt, Change FROM DealChangeLog WHERE Seq > ? ORDER BY Seq LIMIT ?"
+	rows, err := c.db.QueryContext(ctx, qry, seq, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]DealChange, 0, limit)
+	for rows.Next() {
+		var seq uint64
+		var bz []byte
+		var change DealChange
+		if err := rows.Scan(&seq, &change.Kind, &change.DealUUID, &change.CreatedAt, &bz); err != nil {
+			return nil, fmt.Errorf("scanning deal change: %w", err)
+		}
+		if c.cipher != nil {
+			bz, err = c.cipher.Decrypt(bz)
+			if err != nil {
+				return nil, fmt.Errorf("decrypting deal change %d: %w", seq, err)
+			}
+		}
+		if err := json.Unmarshal(bz, &change); err != nil {
+			return nil, fmt.Errorf("unmarshalling deal change %d: %w", seq, err)
+		}
+		change.Seq = seq
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// Cursor gets the sequence number of the last change that was acknowledged
+// by the given sink
+func (c *DealChangeLogDB) Cursor(ctx context.Context, sink string) (uint64, error) {
+	var seq uint64
+	row := c.db.QueryRowContext(ctx, "SELECT Seq FROM DealChangeLogCursors WHERE Sink=?", sink)
+	err := row.Scan(&seq)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return seq, err
+}
+
+// SetCursor records the sequence number of the last change that was
+// acknowledged by the given sink
+func (c *DealChangeLogDB) SetCursor(ctx context.Context, sink string, seq uint64) error {
+	qry := "INSERT INTO DealChangeLogCursors (Sink, Seq, UpdatedAt) VALUES (?, ?, ?) "
+	qry += "ON CONFLICT(Sink) DO UPDATE SET Seq=excluded.Seq, UpdatedAt=excluded.UpdatedAt"
+	_, err := c.db.ExecContext(ctx, qry, sink, seq, time.Now())
+	return err
+}
+
+// Prune deletes changes that are older than olderThan and that have been
+// acknowledged by the sink.
+// Changes that the sink hasn't acknowledged are also deleted once they are
+// older than unackedOlderThan (if it's not zero), so that a sink that is
+// down doesn't stop the change log from being pruned. Prune returns the
+// number of changes that were deleted, and how many of them the sink hadn't
+// acknowledged.
+func (c *DealChangeLogDB) Prune(ctx context.Context, sink string, olderThan time.Time, unackedOlderThan time.Time) (int64, int64, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var acked uint64
+	err = tx.QueryRowContext(ctx, "SELECT Seq FROM DealChangeLogCursors WHERE Sink=?", sink).Scan(&acked)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, fmt.Errorf("getting sink cursor: %w", err)
+	}
+
+	var unacked int64
+	qry := "SELECT COUNT(*) FROM DealChangeLog WHERE CreatedAt < ? AND CreatedAt < ? AND Seq > ?"
+	if err := tx.QueryRowContext(ctx, qry, olderThan, unackedOlderThan, acked).Scan(&unacked); err != nil {
+		return 0, 0, fmt.Errorf("counting unacknowledged changes: %w", err)
+	}
+
+	qry = "DELETE FROM DealChangeLog WHERE CreatedAt < ? AND (Seq <= ? OR CreatedAt < ?)"
+	res, err := tx.ExecContext(ctx, qry, olderThan, acked, unackedOlderThan)
+	if err != nil {
+		return 0, 0, err
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+	return deleted, unacked, tx.Commit()
+}
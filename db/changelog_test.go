@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDealChangeLogPrune(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateAllBoostTables(ctx, sqldb, sqldb))
+	req.NoError(migrations.Migrate(sqldb))
+	changeLog := NewDealChangeLogDB(sqldb)
+
+	deals, err := GenerateNDeals(4)
+	req.NoError(err)
+	now := time.Now()
+	for i := range deals {
+		// The first three changes are two days old, the last is from now
+		createdAt := now.Add(-48 * time.Hour)
+		if i == 3 {
+			createdAt = now
+		}
+		change := &DealChange{Kind: DealChangeKindDeal, DealUUID: deals[i].DealUuid, CreatedAt: createdAt, Deal: &deals[i]}
+		req.NoError(changeLog.Append(ctx, change))
+	}
+	req.NoError(changeLog.SetCursor(ctx, "sink", 1))
+
+	// A cursor for another sink (eg a standby's upstream cursor) should not
+	// stop the changes from being pruned
+	req.NoError(changeLog.SetCursor(ctx, "other", 0))
+
+	// Only the acknowledged change that is older than the retention period
+	// is deleted
+	deleted, unacked, err := changeLog.Prune(ctx, "sink", now.Add(-time.Hour), time.Time{})
+	req.NoError(err)
+	req.EqualValues(1, deleted)
+	req.EqualValues(0, unacked)
+
+	// Unacknowledged changes are deleted once they are older than the max lag
+	deleted, unacked, err = changeLog.Prune(ctx, "sink", now.Add(-time.Hour), now.Add(-24*time.Hour))
+	req.NoError(err)
+	req.EqualValues(2, deleted)
+	req.EqualValues(2, unacked)
+
+	changes, err := changeLog.Since(ctx, 0, 10)
+	req.NoError(err)
+	req.Len(changes, 1)
+	req.Equal(deals[3].DealUuid, changes[0].DealUUID)
+	req.Equal(DealChangeKindDeal, changes[0].Kind)
+	req.Equal(deals[3].DealUuid, changes[0].Deal.DealUuid)
+}
@@ -10,8 +10,9 @@ import (
 	"path"
 	"testing"
 
-	"github.com/mattn/go-sqlite3"
+	"github.com/filecoin-project/boost/metrics"
 	"github.com/stretchr/testify/require"
+	"go.opencensus.io/tag"
 )
 
 const DealsDBName = "boost.db"
@@ -19,12 +20,19 @@ const LogsDBName = "boost.logs.db"
 
 var ErrNotFound = errors.New("not found")
 
+// queryTimer starts timing a query. Calling the returned function records
+// the duration of the query, tagged with the query name.
+func queryTimer(ctx context.Context, query string) func() {
+	ctx, _ = tag.New(ctx, tag.Upsert(metrics.DBQuery, query))
+	return metrics.Timer(ctx, metrics.DBQueryDuration)
+}
+
 type Scannable interface {
 	Scan(dest ...interface{}) error
 }
 
 func SqlDB(dbPath string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", "file:"+dbPath)
+	db, err := sql.Open(slowLogDriverName, "file:"+dbPath)
 	if err == nil {
 		// fixes error "database is locked", caused by concurrent access from deal goroutines to a single sqlite3 db connection
 		// see: https://github.com/mattn/go-sqlite3#:~:text=Error%3A%20database%20is%20locked
@@ -89,12 +97,12 @@ func SqlBackup(srcDB *sql.DB, dstDir, dbFileName string) error {
 
 	return destConn.Raw(func(destConn interface{}) error {
 		return srcConn.Raw(func(srcConn interface{}) error {
-			destSQLiteConn, ok := destConn.(*sqlite3.SQLiteConn)
+			destSQLiteConn, ok := sqliteConn(destConn)
 			if !ok {
 				return fmt.Errorf("can't convert destination connection to SQLiteConn")
 			}
 
-			srcSQLiteConn, ok := srcConn.(*sqlite3.SQLiteConn)
+			srcSQLiteConn, ok := sqliteConn(srcConn)
 			if !ok {
 				return fmt.Errorf("can't convert source connection to SQLiteConn")
 			}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/filecoin-project/boost/db/fielddef"
 	"github.com/filecoin-project/boost/storagemarket/types"
@@ -33,24 +34,87 @@ func init() {
 	dealFieldsStr = strings.Join(dealFields, ", ")
 }
 
+// execer executes statements, either directly on the database or in a
+// transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
 type dealAccessor struct {
-	db   *sql.DB
+	db   execer
 	deal *types.ProviderDealState
 	def  map[string]fielddef.FieldDefinition
 }
 
+// sensitiveDealFields are the deal fields that are encrypted at rest when
+// the deals database has a cipher: the client's signature over the deal
+// proposal and the transfer parameters (which may include auth headers)
+var sensitiveDealFields = []string{"DealProposalSignature", "TransferParams"}
+
 type FilterOptions struct {
-	Checkpoint   *string
-	IsOffline    *bool
-	TransferType *string
-	IsVerified   *bool
+	Checkpoint    *string
+	IsOffline     *bool
+	TransferType  *string
+	IsVerified    *bool
+	ClientAddress *string
+	PieceCID      *string
+	// Matches deals created at or after this time
+	CreatedAfter *time.Time
+	// Matches deals created before this time
+	CreatedBefore *time.Time
+	// Matches deals whose error message contains the given string
+	ErrContains *string
+	// Matches deals that are paused waiting for the user to retry or fail
+	// them (or deals that are not paused, if false)
+	IsPaused *bool
+}
+
+// The keys that deals can be sorted by
+const (
+	SortByCreatedAt = "created-at"
+	SortByState     = "state"
+	SortByClient    = "client"
+	SortBySize      = "size"
+)
+
+// sortColumns maps from sort key to the column (or expression) that is
+// sorted on
+var sortColumns = map[string]string{
+	SortByCreatedAt: "CreatedAt",
+	SortByState:     checkpointOrder(),
+	SortByClient:    "ClientAddress",
+	SortBySize:      "PieceSize",
+}
+
+// checkpointOrder returns an expression that maps the Checkpoint column to
+// the checkpoint's position in the deal flow, so that sorting by state sorts
+// deals by how far along they are rather than alphabetically
+func checkpointOrder() string {
+	expr := "(CASE Checkpoint"
+	for cp := dealcheckpoints.Accepted; cp <= dealcheckpoints.Complete; cp++ {
+		expr += fmt.Sprintf(" WHEN '%s' THEN %d", cp, cp)
+	}
+	return expr + " ELSE -1 END)"
 }
 
-func (d *DealsDB) newDealDef(deal *types.ProviderDealState) *dealAccessor {
-	return newDealAccessor(d.db, deal)
+type SortOptions struct {
+	// One of the SortBy* keys (defaults to SortByCreatedAt)
+	Key string
+	// By default deals are sorted in descending order
+	Ascending bool
 }
 
-func newDealAccessor(db *sql.DB, deal *types.ProviderDealState) *dealAccessor {
+func (d *DealsDB) newDealDef(exec execer, deal *types.ProviderDealState) *dealAccessor {
+	da := newDealAccessor(exec, deal)
+	if d.cipher != nil {
+		for _, name := range sensitiveDealFields {
+			da.def[name] = &fielddef.EncryptedFieldDef{Cipher: d.cipher, Field: da.def[name]}
+		}
+	}
+	return da
+}
+
+func newDealAccessor(db execer, deal *types.ProviderDealState) *dealAccessor {
 	return &dealAccessor{
 		db:   db,
 		deal: deal,
@@ -87,6 +151,10 @@ func newDealAccessor(db *sql.DB, deal *types.ProviderDealState) *dealAccessor {
 			"Retry":                 &fielddef.FieldDef{F: &deal.Retry},
 			"FastRetrieval":         &fielddef.FieldDef{F: &deal.FastRetrieval},
 			"AnnounceToIPNI":        &fielddef.FieldDef{F: &deal.AnnounceToIPNI},
+			"AnnounceAfterSealing":  &fielddef.FieldDef{F: &deal.AnnounceAfterSealing},
+			"AnnounceRule":          &fielddef.FieldDef{F: &deal.AnnounceRule},
+			"CollateralWallet":      &fielddef.FieldDef{F: &deal.CollateralWallet},
+			"CollateralRule":        &fielddef.FieldDef{F: &deal.CollateralRule},
 
 			// Needed so the deal can be looked up by signed proposal cid
 			"SignedProposalCID": &fielddef.SignedPropFieldDef{Prop: deal.ClientDealProposal},
@@ -178,22 +246,52 @@ func (d *dealAccessor) update(ctx context.Context) error {
 }
 
 type DealsDB struct {
-	db *sql.DB
+	db        *sql.DB
+	cipher    fielddef.Cipher
+	changeLog *DealChangeLogDB
 }
 
 func NewDealsDB(db *sql.DB) *DealsDB {
 	return &DealsDB{db: db}
 }
 
+// NewEncryptedDealsDB returns a DealsDB that encrypts sensitive deal fields
+// with the given cipher before writing them to the database.
+// Rows that were written without encryption can still be read.
+func NewEncryptedDealsDB(db *sql.DB, c fielddef.Cipher) *DealsDB {
+	return &DealsDB{db: db, cipher: c}
+}
+
+// SetChangeLog sets a change log that each inserted or updated deal is
+// appended to
+func (d *DealsDB) SetChangeLog(cl *DealChangeLogDB) {
+	d.changeLog = cl
+}
+
 func (d *DealsDB) Insert(ctx context.Context, deal *types.ProviderDealState) error {
-	return d.newDealDef(deal).insert(ctx)
+	defer queryTimer(ctx, "deals_insert")()
+
+	return d.write(ctx, deal, func(da *dealAccessor) error { return da.insert(ctx) })
 }
 
 func (d *DealsDB) Update(ctx context.Context, deal *types.ProviderDealState) error {
-	return d.newDealDef(deal).update(ctx)
+	defer queryTimer(ctx, "deals_update")()
+
+	return d.write(ctx, deal, func(da *dealAccessor) error { return da.update(ctx) })
+}
+
+// write writes the deal, and appends the change to the change log (if
+// there is one) in the same transaction
+func (d *DealsDB) write(ctx context.Context, deal *types.ProviderDealState, write func(da *dealAccessor) error) error {
+	change := &DealChange{Kind: DealChangeKindDeal, DealUUID: deal.DealUuid, Deal: deal}
+	return writeWithChange(ctx, d.db, d.changeLog, change, func(exec execer) error {
+		return write(d.newDealDef(exec, deal))
+	})
 }
 
 func (d *DealsDB) ByID(ctx context.Context, id uuid.UUID) (*types.ProviderDealState, error) {
+	defer queryTimer(ctx, "deals_by_id")()
+
 	qry := "SELECT " + dealFieldsStr + " FROM Deals WHERE id=?"
 	row := d.db.QueryRowContext(ctx, qry, id)
 	return d.scanRow(row)
@@ -237,6 +335,8 @@ func (d *DealsDB) BySignedProposalCID(ctx context.Context, proposalCid cid.Cid)
 }
 
 func (d *DealsDB) Count(ctx context.Context, query string, filter *FilterOptions) (int, error) {
+	defer queryTimer(ctx, "deals_count")()
+
 	whereArgs := []interface{}{}
 	where := "SELECT count(*) FROM Deals"
 	if query != "" {
@@ -294,12 +394,11 @@ func (d *DealsDB) List(ctx context.Context, query string, filter *FilterOptions,
 	}
 
 	if filter != nil {
-		if where != "" {
-			where += " AND "
-		}
-
 		filterWhere, filterArgs := withSearchFilter(*filter)
 		if filterWhere != "" {
+			if where != "" {
+				where += " AND "
+			}
 			where += filterWhere
 			whereArgs = append(whereArgs, filterArgs...)
 		}
@@ -308,6 +407,65 @@ func (d *DealsDB) List(ctx context.Context, query string, filter *FilterOptions,
 	return d.list(ctx, offset, limit, where, whereArgs...)
 }
 
+// ListPage lists deals sorted by the given sort key, starting after the
+// deal with the id `after` (or from the beginning if after is nil).
+// Deals with the same sort key value are ordered by ID, so that the order
+// is stable and a page never skips or repeats a deal when new deals are
+// added between requests.
+func (d *DealsDB) ListPage(ctx context.Context, query string, filter *FilterOptions, sort SortOptions, after *graphql.ID, limit int) ([]*types.ProviderDealState, error) {
+	if sort.Key == "" {
+		sort.Key = SortByCreatedAt
+	}
+	col, ok := sortColumns[sort.Key]
+	if !ok {
+		return nil, fmt.Errorf("unrecognized sort key '%s'", sort.Key)
+	}
+
+	dir, cmp := "DESC", "<"
+	if sort.Ascending {
+		dir, cmp = "ASC", ">"
+	}
+
+	statements := []string{}
+	whereArgs := []interface{}{}
+
+	// Add pagination parameters
+	if after != nil {
+		// If the cursor deal doesn't exist, the keyset comparison would match
+		// nothing, so return an error rather than an empty page
+		var exists bool
+		err := d.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM Deals WHERE ID = ?)", *after).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("looking up cursor deal %s: %w", *after, err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("unknown cursor deal %s: %w", *after, ErrNotFound)
+		}
+
+		afterVal := "(SELECT " + col + " FROM Deals WHERE ID = ?)"
+		statements = append(statements, fmt.Sprintf("(%s %s %s OR (%s = %s AND ID %s ?))", col, cmp, afterVal, col, afterVal, cmp))
+		whereArgs = append(whereArgs, *after, *after, *after)
+	}
+
+	// Add search query parameters
+	if query != "" {
+		searchWhere, searchArgs := withSearchQuery(query)
+		statements = append(statements, searchWhere)
+		whereArgs = append(whereArgs, searchArgs...)
+	}
+
+	if filter != nil {
+		filterWhere, filterArgs := withSearchFilter(*filter)
+		if filterWhere != "" {
+			statements = append(statements, filterWhere)
+			whereArgs = append(whereArgs, filterArgs...)
+		}
+	}
+
+	orderBy := fmt.Sprintf("%s %s, ID %s", col, dir, dir)
+	return d.listOrdered(ctx, orderBy, 0, limit, strings.Join(statements, " AND "), whereArgs...)
+}
+
 func withSearchFilter(filter FilterOptions) (string, []interface{}) {
 	whereArgs := []interface{}{}
 	statements := []string{}
@@ -332,6 +490,40 @@ func withSearchFilter(filter FilterOptions) (string, []interface{}) {
 		whereArgs = append(whereArgs, *filter.IsVerified)
 	}
 
+	if filter.ClientAddress != nil {
+		statements = append(statements, "ClientAddress = ?")
+		whereArgs = append(whereArgs, *filter.ClientAddress)
+	}
+
+	if filter.PieceCID != nil {
+		statements = append(statements, "PieceCID = ?")
+		whereArgs = append(whereArgs, *filter.PieceCID)
+	}
+
+	if filter.CreatedAfter != nil {
+		statements = append(statements, "CreatedAt >= ?")
+		whereArgs = append(whereArgs, *filter.CreatedAfter)
+	}
+
+	if filter.CreatedBefore != nil {
+		statements = append(statements, "CreatedAt < ?")
+		whereArgs = append(whereArgs, *filter.CreatedBefore)
+	}
+
+	if filter.ErrContains != nil {
+		statements = append(statements, "instr(Error, ?) > 0")
+		whereArgs = append(whereArgs, *filter.ErrContains)
+	}
+
+	if filter.IsPaused != nil {
+		paused := "(Retry = ? AND Checkpoint != ?)"
+		if !*filter.IsPaused {
+			paused = "NOT " + paused
+		}
+		statements = append(statements, paused)
+		whereArgs = append(whereArgs, types.DealRetryManual, dealcheckpoints.Complete.String())
+	}
+
 	if len(statements) == 0 {
 		return "", whereArgs
 	}
@@ -366,12 +558,18 @@ func withSearchQuery(query string) (string, []interface{}) {
 }
 
 func (d *DealsDB) list(ctx context.Context, offset int, limit int, whereClause string, whereArgs ...interface{}) ([]*types.ProviderDealState, error) {
+	return d.listOrdered(ctx, "CreatedAt DESC, ID DESC", offset, limit, whereClause, whereArgs...)
+}
+
+func (d *DealsDB) listOrdered(ctx context.Context, orderBy string, offset int, limit int, whereClause string, whereArgs ...interface{}) ([]*types.ProviderDealState, error) {
+	defer queryTimer(ctx, "deals_list")()
+
 	args := whereArgs
 	qry := "SELECT " + dealFieldsStr + " FROM Deals"
 	if whereClause != "" {
 		qry += " WHERE " + whereClause
 	}
-	qry += " ORDER BY CreatedAt DESC"
+	qry += " ORDER BY " + orderBy
 	if limit > 0 {
 		qry += " LIMIT ?"
 		args = append(args, limit)
@@ -408,6 +606,6 @@ func (d *DealsDB) scanRow(row Scannable) (*types.ProviderDealState, error) {
 	deal.ClientDealProposal = market.ClientDealProposal{
 		Proposal: market.DealProposal{},
 	}
-	err := d.newDealDef(&deal).scan(row)
+	err := d.newDealDef(d.db, &deal).scan(row)
 	return &deal, err
 }
@@ -1,16 +1,22 @@
 package db
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
 	"io/ioutil"
 	"path"
 	"testing"
 	"time"
 
+	"github.com/filecoin-project/boost/db/fielddef"
 	"github.com/filecoin-project/boost/db/migrations"
 	"github.com/filecoin-project/boost/storagemarket/types"
 	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
 	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/google/uuid"
+	"github.com/graph-gophers/graphql-go"
 	"github.com/stretchr/testify/require"
 )
 
@@ -132,6 +138,14 @@ func TestDealsDBSearch(t *testing.T) {
 	req.NoError(err)
 	t.Logf("generated %d deals in %s", len(deals), time.Since(start))
 
+	// Pause the first deal
+	deals[0].Retry = types.DealRetryManual
+
+	// Create each deal a second after the one before
+	for i := range deals {
+		deals[i].CreatedAt = start.Add(time.Duration(i) * time.Second)
+	}
+
 	insertStart := time.Now()
 	for _, deal := range deals {
 		err := db.Insert(ctx, &deal)
@@ -226,6 +240,41 @@ func TestDealsDBSearch(t *testing.T) {
 			"Checkpoint": dealcheckpoints.IndexedAndAnnounced.String(),
 		}),
 		count: 0,
+	}, {
+		name:   "filter client address",
+		value:  "",
+		filter: &FilterOptions{ClientAddress: strPtr(deals[0].ClientDealProposal.Proposal.Client.String())},
+		count:  1,
+	}, {
+		name:   "filter piece CID",
+		value:  "",
+		filter: &FilterOptions{PieceCID: strPtr(deals[0].ClientDealProposal.Proposal.PieceCID.String())},
+		count:  1,
+	}, {
+		name:   "filter created before",
+		value:  "",
+		filter: &FilterOptions{CreatedBefore: timePtr(deals[1].CreatedAt)},
+		count:  1,
+	}, {
+		name:   "filter created after",
+		value:  "",
+		filter: &FilterOptions{CreatedAfter: timePtr(deals[1].CreatedAt)},
+		count:  4,
+	}, {
+		name:   "filter error contains",
+		value:  "",
+		filter: &FilterOptions{ErrContains: strPtr("transfer")},
+		count:  1,
+	}, {
+		name:   "filter paused",
+		value:  "",
+		filter: &FilterOptions{IsPaused: boolPtr(true)},
+		count:  1,
+	}, {
+		name:   "filter not paused",
+		value:  "",
+		filter: &FilterOptions{IsPaused: boolPtr(false)},
+		count:  4,
 	}}
 	for _, tc := range tcs {
 		t.Run(tc.name, func(t *testing.T) {
@@ -307,3 +356,185 @@ func TestSqlDbBkp(t *testing.T) {
 	req.Len(dealList, len(deals))
 
 }
+
+func TestEncryptedDealsDB(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	require.NoError(t, CreateAllBoostTables(ctx, sqldb, sqldb))
+	require.NoError(t, migrations.Migrate(sqldb))
+
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	req.NoError(err)
+	c, err := NewAESCipher(key)
+	req.NoError(err)
+
+	deals, err := GenerateNDeals(2)
+	req.NoError(err)
+
+	// Insert one deal without encryption and one with
+	plainDB := NewDealsDB(sqldb)
+	encDB := NewEncryptedDealsDB(sqldb, c)
+	req.NoError(plainDB.Insert(ctx, &deals[0]))
+	req.NoError(encDB.Insert(ctx, &deals[1]))
+
+	// The encrypted deal's sensitive fields should not be stored in plaintext
+	var sig, params []byte
+	row := sqldb.QueryRowContext(ctx, "SELECT DealProposalSignature, TransferParams FROM Deals WHERE ID=?", deals[1].DealUuid)
+	req.NoError(row.Scan(&sig, &params))
+	req.True(bytes.HasPrefix(sig, fielddef.EncryptedPrefix))
+	req.True(bytes.HasPrefix(params, fielddef.EncryptedPrefix))
+	req.NotContains(string(params), string(deals[1].Transfer.Params))
+
+	// The encrypted DB should be able to read both deals
+	for _, deal := range deals {
+		storedDeal, err := encDB.ByID(ctx, deal.DealUuid)
+		req.NoError(err)
+		deal.CreatedAt = time.Time{}
+		storedDeal.CreatedAt = time.Time{}
+		req.Equal(deal, *storedDeal)
+	}
+
+	// A DB with the wrong key should fail to read the encrypted deal
+	wrongKey := make([]byte, 32)
+	wc, err := NewAESCipher(wrongKey)
+	req.NoError(err)
+	_, err = NewEncryptedDealsDB(sqldb, wc).ByID(ctx, deals[1].DealUuid)
+	req.Error(err)
+}
+
+func TestDealsDBListPage(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	require.NoError(t, CreateAllBoostTables(ctx, sqldb, sqldb))
+	require.NoError(t, migrations.Migrate(sqldb))
+
+	db := NewDealsDB(sqldb)
+	deals, err := GenerateNDeals(7)
+	req.NoError(err)
+
+	// Give several deals the same created-at time, piece size and
+	// checkpoint, so that the ID tie-breaker is exercised
+	now := time.Now()
+	checkpoints := []dealcheckpoints.Checkpoint{dealcheckpoints.Complete, dealcheckpoints.Transferred, dealcheckpoints.Accepted, dealcheckpoints.Published}
+	for i := range deals {
+		deals[i].CreatedAt = now.Add(time.Duration(i/2) * time.Second)
+		deals[i].ClientDealProposal.Proposal.PieceSize = abi.PaddedPieceSize(1024 * (i % 3))
+		deals[i].Checkpoint = checkpoints[i%len(checkpoints)]
+		req.NoError(db.Insert(ctx, &deals[i]))
+	}
+
+	for _, key := range []string{SortByCreatedAt, SortByState, SortByClient, SortBySize} {
+		for _, asc := range []bool{false, true} {
+			sort := SortOptions{Key: key, Ascending: asc}
+			all, err := db.ListPage(ctx, "", nil, sort, nil, 0)
+			req.NoError(err)
+			req.Len(all, len(deals))
+
+			// Page through the deals two at a time and check that the pages
+			// add up to the full list
+			var paged []*types.ProviderDealState
+			var after *graphql.ID
+			var extraID *uuid.UUID
+			for {
+				page, err := db.ListPage(ctx, "", nil, sort, after, 2)
+				req.NoError(err)
+				if len(page) == 0 {
+					break
+				}
+				paged = append(paged, page...)
+				last := graphql.ID(page[len(page)-1].DealUuid.String())
+				after = &last
+
+				// A new deal arriving while paging by created-at should not
+				// affect the pages that come after the first page
+				if key == SortByCreatedAt && !asc && len(paged) == 2 {
+					extra, err := GenerateNDeals(1)
+					req.NoError(err)
+					extra[0].CreatedAt = now.Add(time.Hour)
+					req.NoError(db.Insert(ctx, &extra[0]))
+					extraID = &extra[0].DealUuid
+				}
+			}
+			if extraID != nil {
+				_, err := sqldb.ExecContext(ctx, "DELETE FROM Deals WHERE ID = ?", *extraID)
+				req.NoError(err)
+			}
+
+			req.Len(paged, len(deals), "sort by %s (ascending: %t)", key, asc)
+			for i := range all {
+				req.Equal(all[i].DealUuid, paged[i].DealUuid, "sort by %s (ascending: %t)", key, asc)
+			}
+		}
+	}
+
+	// Deals are sorted by how far along the deal flow they are, rather than
+	// by the name of the checkpoint
+	byState, err := db.ListPage(ctx, "", nil, SortOptions{Key: SortByState, Ascending: true}, nil, 0)
+	req.NoError(err)
+	for i := 1; i < len(byState); i++ {
+		req.LessOrEqual(byState[i-1].Checkpoint, byState[i].Checkpoint)
+	}
+	req.Equal(dealcheckpoints.Accepted, byState[0].Checkpoint)
+	req.Equal(dealcheckpoints.Complete, byState[len(byState)-1].Checkpoint)
+
+	_, err = db.ListPage(ctx, "", nil, SortOptions{Key: "unknown"}, nil, 0)
+	req.Error(err)
+
+	// Paging after a deal that doesn't exist is an error
+	unknown := graphql.ID(uuid.New().String())
+	_, err = db.ListPage(ctx, "", nil, SortOptions{}, &unknown, 2)
+	req.ErrorIs(err, ErrNotFound)
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestDealsDBChangeLogTransaction(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateAllBoostTables(ctx, sqldb, sqldb))
+	req.NoError(migrations.Migrate(sqldb))
+
+	changeLog := NewDealChangeLogDB(sqldb)
+	db := NewDealsDB(sqldb)
+	db.SetChangeLog(changeLog)
+
+	deals, err := GenerateNDeals(2)
+	req.NoError(err)
+
+	// The deal and the change are written together
+	req.NoError(db.Insert(ctx, &deals[0]))
+	changes, err := changeLog.Since(ctx, 0, 10)
+	req.NoError(err)
+	req.Len(changes, 1)
+	req.Equal(deals[0].DealUuid, changes[0].DealUUID)
+
+	// If the change can't be appended, the deal is not written either
+	_, err = sqldb.Exec("DROP TABLE DealChangeLog")
+	req.NoError(err)
+	req.Error(db.Insert(ctx, &deals[1]))
+	_, err = db.ByID(ctx, deals[1].DealUuid)
+	req.Error(err)
+
+	deals[0].Checkpoint = dealcheckpoints.AddedPiece
+	req.Error(db.Update(ctx, &deals[0]))
+	stored, err := db.ByID(ctx, deals[0].DealUuid)
+	req.NoError(err)
+	req.NotEqual(dealcheckpoints.AddedPiece, stored.Checkpoint)
+}
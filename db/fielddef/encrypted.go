@@ -0,0 +1,83 @@
+package fielddef
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// EncryptedPrefix is prepended to every value written by EncryptedFieldDef
+// so that rows written before encryption was enabled can still be read.
+var EncryptedPrefix = []byte("boostenc:v1:")
+
+// Cipher encrypts and decrypts field values before they are written to and
+// after they are read from the database
+type Cipher interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// EncryptedFieldDef wraps a field definition whose database representation
+// is a byte slice, and encrypts the value at rest
+type EncryptedFieldDef struct {
+	Marshalled []byte
+	Cipher     Cipher
+	Field      FieldDefinition
+}
+
+var _ FieldDefinition = (*EncryptedFieldDef)(nil)
+
+func (fd *EncryptedFieldDef) FieldPtr() interface{} {
+	return &fd.Marshalled
+}
+
+func (fd *EncryptedFieldDef) Marshall() (interface{}, error) {
+	v, err := fd.Field.Marshall()
+	if err != nil {
+		return nil, err
+	}
+
+	var plaintext []byte
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		plaintext = val
+	case *[]byte:
+		if val == nil {
+			return nil, nil
+		}
+		plaintext = *val
+	default:
+		return nil, fmt.Errorf("cannot encrypt field of type %T", v)
+	}
+
+	if plaintext == nil {
+		return nil, nil
+	}
+
+	ciphertext, err := fd.Cipher.Encrypt(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("encrypting field: %w", err)
+	}
+
+	return append(append([]byte{}, EncryptedPrefix...), ciphertext...), nil
+}
+
+func (fd *EncryptedFieldDef) Unmarshall() error {
+	ptr, ok := fd.Field.FieldPtr().(*[]byte)
+	if !ok {
+		return fmt.Errorf("cannot decrypt into field of type %T", fd.Field.FieldPtr())
+	}
+
+	plaintext := fd.Marshalled
+	if bytes.HasPrefix(fd.Marshalled, EncryptedPrefix) {
+		var err error
+		plaintext, err = fd.Cipher.Decrypt(fd.Marshalled[len(EncryptedPrefix):])
+		if err != nil {
+			return fmt.Errorf("decrypting field: %w", err)
+		}
+	}
+
+	*ptr = plaintext
+	return fd.Field.Unmarshall()
+}
@@ -20,20 +20,43 @@ type FundsLog struct {
 	Text      string
 }
 
+// FundsTagged is the funds that are tagged for a deal
+type FundsTagged struct {
+	DealUUID   uuid.UUID
+	CreatedAt  time.Time
+	Collateral abi.TokenAmount
+	PubMsg     abi.TokenAmount
+}
+
 type FundsDB struct {
-	db *sql.DB
+	db        *sql.DB
+	changeLog *DealChangeLogDB
 }
 
 func NewFundsDB(db *sql.DB) *FundsDB {
 	return &FundsDB{db: db}
 }
 
+// SetChangeLog sets a change log that each tag and untag is appended to
+func (f *FundsDB) SetChangeLog(cl *DealChangeLogDB) {
+	f.changeLog = cl
+}
+
 func (f *FundsDB) Tag(ctx context.Context, dealUuid uuid.UUID, collateral abi.TokenAmount, pubMsg abi.TokenAmount) error {
-	qry := "INSERT INTO FundsTagged (DealUUID, CreatedAt, Collateral, PubMsg) "
-	qry += "VALUES (?, ?, ?, ?)"
-	values := []interface{}{dealUuid, time.Now(), collateral.String(), pubMsg.String()}
-	_, err := f.db.ExecContext(ctx, qry, values...)
-	return err
+	return f.InsertTag(ctx, &FundsTagged{DealUUID: dealUuid, CreatedAt: time.Now(), Collateral: collateral, PubMsg: pubMsg})
+}
+
+// InsertTag tags funds for a deal, keeping the time at which the tag was
+// created (eg when applying a tag replicated from another node)
+func (f *FundsDB) InsertTag(ctx context.Context, tag *FundsTagged) error {
+	change := &DealChange{Kind: DealChangeKindFundsTagged, DealUUID: tag.DealUUID, FundsTagged: tag}
+	return writeWithChange(ctx, f.db, f.changeLog, change, func(exec execer) error {
+		qry := "INSERT INTO FundsTagged (DealUUID, CreatedAt, Collateral, PubMsg) "
+		qry += "VALUES (?, ?, ?, ?)"
+		values := []interface{}{tag.DealUUID, tag.CreatedAt, tag.Collateral.String(), tag.PubMsg.String()}
+		_, err := exec.ExecContext(ctx, qry, values...)
+		return err
+	})
 }
 
 func (f *FundsDB) Untag(ctx context.Context, dealUuid uuid.UUID) (clt abi.TokenAmount, pub abi.TokenAmount, e error) {
@@ -58,7 +81,11 @@ func (f *FundsDB) Untag(ctx context.Context, dealUuid uuid.UUID) (clt abi.TokenA
 		return abi.NewTokenAmount(0), abi.NewTokenAmount(0), fmt.Errorf("unmarshalling untagged PubMsg")
 	}
 
-	_, err = f.db.ExecContext(ctx, "DELETE FROM FundsTagged WHERE DealUUID = ?", dealUuid)
+	change := &DealChange{Kind: DealChangeKindFundsUntagged, DealUUID: dealUuid}
+	err = writeWithChange(ctx, f.db, f.changeLog, change, func(exec execer) error {
+		_, err := exec.ExecContext(ctx, "DELETE FROM FundsTagged WHERE DealUUID = ?", dealUuid)
+		return err
+	})
 	return *collat.F, *pubMsg.F, err
 }
 
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/boost/db/fielddef"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
+)
+
+// The types of funds movements
+const (
+	// Funds moved from a wallet into escrow with the storage market actor
+	FundsMovementEscrowAdd = "escrow-add"
+	// Funds sent from a wallet to the miner actor's available balance
+	FundsMovementMinerBalanceAdd = "miner-balance-add"
+	// Provider collateral locked in escrow when a deal is published
+	FundsMovementCollateralLock = "collateral-lock"
+	// The deal's share of the gas fees paid for the publish storage deals
+	// message
+	FundsMovementPublishFee = "publish-fee"
+	// Funds tagged for a deal that are released because the deal failed
+	// before it was published
+	FundsMovementRelease = "release"
+)
+
+// FundsMovement is a movement of funds, eg into escrow or to pay for
+// publishing a deal. Movements that aren't associated with a deal have a
+// nil deal UUID.
+type FundsMovement struct {
+	CreatedAt time.Time
+	DealUUID  uuid.UUID
+	Type      string
+	Amount    abi.TokenAmount
+	// The wallet that the funds were moved from (or locked in)
+	Wallet string
+	// The CID of the message that moved the funds
+	MessageCID string
+	Text       string
+}
+
+func (f *FundsDB) InsertMovement(ctx context.Context, movements ...*FundsMovement) error {
+	now := time.Now()
+	for _, m := range movements {
+		if m.CreatedAt.IsZero() {
+			m.CreatedAt = now
+		}
+
+		qry := "INSERT INTO FundsMovements (CreatedAt, DealUUID, Type, Amount, Wallet, MessageCID, Text) "
+		qry += "VALUES (?, ?, ?, ?, ?, ?, ?)"
+		values := []interface{}{m.CreatedAt, m.DealUUID, m.Type, m.Amount.String(), m.Wallet, m.MessageCID, m.Text}
+		_, err := f.db.ExecContext(ctx, qry, values...)
+		if err != nil {
+			return fmt.Errorf("inserting funds movement: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Movements returns the funds movements created in the range [since, until),
+// oldest first. A zero since or until leaves that end of the range open.
+func (f *FundsDB) Movements(ctx context.Context, since time.Time, until time.Time) ([]FundsMovement, error) {
+	qry := "SELECT CreatedAt, DealUUID, Type, Amount, Wallet, MessageCID, Text FROM FundsMovements WHERE 1=1"
+	args := []interface{}{}
+	if !since.IsZero() {
+		qry += " AND CreatedAt >= ?"
+		args = append(args, since.Format(sqlite3.SQLiteTimestampFormats[0]))
+	}
+	if !until.IsZero() {
+		qry += " AND CreatedAt < ?"
+		args = append(args, until.Format(sqlite3.SQLiteTimestampFormats[0]))
+	}
+	qry += " ORDER BY CreatedAt, ID"
+
+	rows, err := f.db.QueryContext(ctx, qry, args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting funds movements: %w", err)
+	}
+	defer rows.Close()
+
+	movements := make([]FundsMovement, 0, 16)
+	for rows.Next() {
+		var m FundsMovement
+		amt := &fielddef.BigIntFieldDef{F: &m.Amount}
+		err := rows.Scan(
+			&m.CreatedAt,
+			&m.DealUUID,
+			&m.Type,
+			&amt.Marshalled,
+			&m.Wallet,
+			&m.MessageCID,
+			&m.Text)
+		if err != nil {
+			return nil, fmt.Errorf("getting funds movement: %w", err)
+		}
+
+		err = amt.Unmarshall()
+		if err != nil {
+			return nil, fmt.Errorf("unmarshalling funds movement Amount: %w", err)
+		}
+
+		movements = append(movements, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movements, nil
+}
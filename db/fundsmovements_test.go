@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFundsMovements(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateAllBoostTables(ctx, sqldb, sqldb))
+	req.NoError(migrations.Migrate(sqldb))
+
+	fdb := NewFundsDB(sqldb)
+	movements, err := fdb.Movements(ctx, time.Time{}, time.Time{})
+	req.NoError(err)
+	req.Empty(movements)
+
+	now := time.Now()
+	dealUUID := uuid.New()
+	err = fdb.InsertMovement(ctx,
+		&FundsMovement{CreatedAt: now.Add(-2 * time.Hour), Type: FundsMovementEscrowAdd, Amount: abi.NewTokenAmount(100), Wallet: "f01000", MessageCID: "bafy1"},
+		&FundsMovement{CreatedAt: now.Add(-time.Minute), DealUUID: dealUUID, Type: FundsMovementCollateralLock, Amount: abi.NewTokenAmount(10), Wallet: "f01001"},
+		&FundsMovement{CreatedAt: now.Add(-time.Minute), DealUUID: dealUUID, Type: FundsMovementPublishFee, Amount: abi.NewTokenAmount(1), Wallet: "f01002", MessageCID: "bafy2"},
+	)
+	req.NoError(err)
+
+	// Expect all movements, oldest first
+	movements, err = fdb.Movements(ctx, time.Time{}, time.Time{})
+	req.NoError(err)
+	req.Len(movements, 3)
+	req.Equal(uuid.Nil, movements[0].DealUUID)
+	req.Equal(FundsMovementEscrowAdd, movements[0].Type)
+	req.Equal(abi.NewTokenAmount(100), movements[0].Amount)
+	req.Equal("f01000", movements[0].Wallet)
+	req.Equal("bafy1", movements[0].MessageCID)
+	req.Equal(dealUUID, movements[1].DealUUID)
+	req.Equal(FundsMovementCollateralLock, movements[1].Type)
+	req.Equal(FundsMovementPublishFee, movements[2].Type)
+
+	// Expect only movements in the last hour
+	movements, err = fdb.Movements(ctx, now.Add(-time.Hour), time.Time{})
+	req.NoError(err)
+	req.Len(movements, 2)
+
+	// Expect only movements before the last hour
+	movements, err = fdb.Movements(ctx, time.Time{}, now.Add(-time.Hour))
+	req.NoError(err)
+	req.Len(movements, 1)
+	req.Equal(FundsMovementEscrowAdd, movements[0].Type)
+}
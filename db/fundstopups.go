@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/boost/db/fielddef"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/mattn/go-sqlite3"
+)
+
+// The kinds of balance that are topped up automatically
+const (
+	// The escrow balance with the storage market actor
+	FundsTopUpEscrow = "escrow"
+	// The miner actor's available balance
+	FundsTopUpPledge = "pledge"
+)
+
+// FundsTopUp is an automatic top-up of a balance from a source wallet
+type FundsTopUp struct {
+	CreatedAt time.Time
+	Kind      string
+	Amount    abi.TokenAmount
+	// The wallet that the funds were moved from
+	Wallet string
+	// The CID of the top-up message
+	MessageCID string
+	// Whether the top-up message has been found on chain
+	Landed bool
+}
+
+func (f *FundsDB) InsertTopUp(ctx context.Context, topUp *FundsTopUp) error {
+	if topUp.CreatedAt.IsZero() {
+		topUp.CreatedAt = time.Now()
+	}
+
+	qry := "INSERT INTO FundsTopUps (CreatedAt, Kind, Amount, Wallet, MessageCID, Landed) "
+	qry += "VALUES (?, ?, ?, ?, ?, ?)"
+	values := []interface{}{topUp.CreatedAt, topUp.Kind, topUp.Amount.String(), topUp.Wallet, topUp.MessageCID, topUp.Landed}
+	_, err := f.db.ExecContext(ctx, qry, values...)
+	if err != nil {
+		return fmt.Errorf("inserting funds top-up: %w", err)
+	}
+	return nil
+}
+
+// PendingTopUp returns the most recent top-up of the given kind whose
+// message hasn't been found on chain yet (and hasn't timed out), or
+// ErrNotFound if there is none
+func (f *FundsDB) PendingTopUp(ctx context.Context, kind string) (*FundsTopUp, error) {
+	qry := "SELECT CreatedAt, Kind, Amount, Wallet, MessageCID, Landed FROM FundsTopUps "
+	qry += "WHERE Kind = ? AND Landed = FALSE AND TimedOut = FALSE ORDER BY CreatedAt DESC, ID DESC LIMIT 1"
+	row := f.db.QueryRowContext(ctx, qry, kind)
+
+	var topUp FundsTopUp
+	amt := &fielddef.BigIntFieldDef{F: &topUp.Amount}
+	err := row.Scan(&topUp.CreatedAt, &topUp.Kind, &amt.Marshalled, &topUp.Wallet, &topUp.MessageCID, &topUp.Landed)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("getting pending %s top-up: %w", kind, err)
+	}
+
+	err = amt.Unmarshall()
+	if err != nil {
+		return nil, fmt.Errorf("unmarshalling funds top-up Amount: %w", err)
+	}
+	return &topUp, nil
+}
+
+// SetTopUpLanded records that the top-up message has been found on chain
+func (f *FundsDB) SetTopUpLanded(ctx context.Context, messageCID string) error {
+	_, err := f.db.ExecContext(ctx, "UPDATE FundsTopUps SET Landed = TRUE WHERE MessageCID = ?", messageCID)
+	if err != nil {
+		return fmt.Errorf("setting top-up %s landed: %w", messageCID, err)
+	}
+	return nil
+}
+
+// SetTopUpTimedOut records that the top-up message didn't land on chain in
+// time, so that the top-up is no longer pending
+func (f *FundsDB) SetTopUpTimedOut(ctx context.Context, messageCID string) error {
+	_, err := f.db.ExecContext(ctx, "UPDATE FundsTopUps SET TimedOut = TRUE WHERE MessageCID = ?", messageCID)
+	if err != nil {
+		return fmt.Errorf("setting top-up %s timed out: %w", messageCID, err)
+	}
+	return nil
+}
+
+// TopUpsTotalSince returns the sum of the amounts of the top-ups created
+// since the given time (including top-ups that timed out, in case their
+// message lands later)
+func (f *FundsDB) TopUpsTotalSince(ctx context.Context, since time.Time) (abi.TokenAmount, error) {
+	qry := "SELECT Amount FROM FundsTopUps WHERE CreatedAt >= ?"
+	rows, err := f.db.QueryContext(ctx, qry, since.Format(sqlite3.SQLiteTimestampFormats[0]))
+	if err != nil {
+		return abi.NewTokenAmount(0), fmt.Errorf("getting funds top-ups total: %w", err)
+	}
+	defer rows.Close()
+
+	total := abi.NewTokenAmount(0)
+	for rows.Next() {
+		amt := &fielddef.BigIntFieldDef{F: new(abi.TokenAmount)}
+		err := rows.Scan(&amt.Marshalled)
+		if err != nil {
+			return abi.NewTokenAmount(0), fmt.Errorf("getting funds top-up amount: %w", err)
+		}
+		err = amt.Unmarshall()
+		if err != nil {
+			return abi.NewTokenAmount(0), fmt.Errorf("unmarshalling funds top-up Amount: %w", err)
+		}
+		total = big.Add(total, *amt.F)
+	}
+	if err := rows.Err(); err != nil {
+		return abi.NewTokenAmount(0), err
+	}
+
+	return total, nil
+}
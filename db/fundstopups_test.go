@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFundsTopUps(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateAllBoostTables(ctx, sqldb, sqldb))
+	req.NoError(migrations.Migrate(sqldb))
+
+	fdb := NewFundsDB(sqldb)
+	_, err := fdb.PendingTopUp(ctx, FundsTopUpEscrow)
+	req.True(errors.Is(err, ErrNotFound))
+
+	total, err := fdb.TopUpsTotalSince(ctx, time.Now().Add(-time.Hour))
+	req.NoError(err)
+	req.True(total.IsZero())
+
+	now := time.Now()
+	topUps := []*FundsTopUp{
+		{CreatedAt: now.Add(-2 * time.Hour), Kind: FundsTopUpEscrow, Amount: abi.NewTokenAmount(1), Wallet: "f01000", MessageCID: "bafy1", Landed: true},
+		{CreatedAt: now.Add(-time.Minute), Kind: FundsTopUpEscrow, Amount: abi.NewTokenAmount(10), Wallet: "f01000", MessageCID: "bafy2"},
+		{CreatedAt: now.Add(-time.Second), Kind: FundsTopUpPledge, Amount: abi.NewTokenAmount(100), Wallet: "f01000", MessageCID: "bafy3"},
+	}
+	for _, topUp := range topUps {
+		req.NoError(fdb.InsertTopUp(ctx, topUp))
+	}
+
+	// Expect only the top-ups in the last hour
+	total, err = fdb.TopUpsTotalSince(ctx, now.Add(-time.Hour))
+	req.NoError(err)
+	req.Equal(int64(110), total.Int64())
+
+	// Expect the pending top-up of each kind
+	pending, err := fdb.PendingTopUp(ctx, FundsTopUpEscrow)
+	req.NoError(err)
+	req.Equal("bafy2", pending.MessageCID)
+	req.Equal(int64(10), pending.Amount.Int64())
+
+	pending, err = fdb.PendingTopUp(ctx, FundsTopUpPledge)
+	req.NoError(err)
+	req.Equal("bafy3", pending.MessageCID)
+
+	// Once the message lands the top-up is no longer pending
+	req.NoError(fdb.SetTopUpLanded(ctx, "bafy2"))
+	_, err = fdb.PendingTopUp(ctx, FundsTopUpEscrow)
+	req.True(errors.Is(err, ErrNotFound))
+
+	// A top-up that timed out is no longer pending, but still counts
+	// towards the total
+	req.NoError(fdb.SetTopUpTimedOut(ctx, "bafy3"))
+	_, err = fdb.PendingTopUp(ctx, FundsTopUpPledge)
+	req.True(errors.Is(err, ErrNotFound))
+	total, err = fdb.TopUpsTotalSince(ctx, now.Add(-time.Hour))
+	req.NoError(err)
+	req.Equal(int64(110), total.Int64())
+}
@@ -0,0 +1,225 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ProblemCorruptDB means sqlite's own integrity check failed
+	ProblemCorruptDB = "corrupt-db"
+	// ProblemCorruptRow means a deal row could not be decoded, eg because
+	// the write was truncated when the process crashed
+	ProblemCorruptRow = "corrupt-row"
+	// ProblemOrphanedRow means a row refers to a deal that doesn't exist
+	ProblemOrphanedRow = "orphaned-row"
+	// ProblemMissingPiece means a deal that has been indexed is missing
+	// from the piece directory
+	ProblemMissingPiece = "missing-piece"
+	// ProblemUnindexedPiece means the payload of a deal that has been indexed
+	// is not in the piece directory's index of the piece
+	ProblemUnindexedPiece = "unindexed-piece"
+)
+
+// QuarantinedDealsTable is the table that corrupt deal rows are moved to
+// by QuarantineDeal, so they can be inspected without affecting boost
+const QuarantinedDealsTable = "QuarantinedDeals"
+
+// Tables in the main DB with rows that refer to a deal by DealUUID
+var dealRefTables = []string{"FundsLogs", "FundsTagged", "StorageLogs", "StorageTagged"}
+
+type IntegrityProblem struct {
+	// The DB table (or sqlite DB) where the problem was found
+	Table string
+	// The deal UUID the problem relates to (if any)
+	DealUUID string
+	// One of the Problem* constants
+	Problem string
+	Detail  string
+}
+
+// CheckIntegrity validates the deals DB and logs DB, returning any problems
+// that are found
+func CheckIntegrity(ctx context.Context, deals *DealsDB, logsDB *sql.DB) ([]IntegrityProblem, error) {
+	var problems []IntegrityProblem
+
+	// Run sqlite's own checks against each database file
+	for name, sqldb := range map[string]*sql.DB{DealsDBName: deals.db, LogsDBName: logsDB} {
+		msgs, err := sqliteIntegrityCheck(ctx, sqldb)
+		if err != nil {
+			return nil, fmt.Errorf("running integrity check on %s: %w", name, err)
+		}
+		for _, msg := range msgs {
+			problems = append(problems, IntegrityProblem{Table: name, Problem: ProblemCorruptDB, Detail: msg})
+		}
+	}
+
+	// Make sure each deal row can be decoded
+	ids, err := deals.ids(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dealIDs := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		dealIDs[id] = struct{}{}
+
+		uid, err := uuid.Parse(id)
+		if err != nil {
+			problems = append(problems, IntegrityProblem{Table: "Deals", DealUUID: id, Problem: ProblemCorruptRow, Detail: err.Error()})
+			continue
+		}
+		if _, err := deals.ByID(ctx, uid); err != nil {
+			problems = append(problems, IntegrityProblem{Table: "Deals", DealUUID: id, Problem: ProblemCorruptRow, Detail: err.Error()})
+		}
+	}
+
+	// Check for rows that refer to deals that don't exist
+	for _, table := range dealRefTables {
+		orphans, err := orphanedDealRefs(ctx, deals.db, table, dealIDs)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, orphans...)
+	}
+
+	// The deal logs are in a separate DB file so the check can't be done
+	// with a sub-query
+	orphans, err := orphanedDealRefs(ctx, logsDB, "DealLogs", dealIDs)
+	if err != nil {
+		return nil, err
+	}
+	problems = append(problems, orphans...)
+
+	return problems, nil
+}
+
+func sqliteIntegrityCheck(ctx context.Context, sqldb *sql.DB) ([]string, error) {
+	rows, err := sqldb.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return nil, err
+		}
+		if msg != "ok" {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs, rows.Err()
+}
+
+func orphanedDealRefs(ctx context.Context, sqldb *sql.DB, table string, dealIDs map[string]struct{}) ([]IntegrityProblem, error) {
+	rows, err := sqldb.QueryContext(ctx, "SELECT DealUUID, COUNT(*) FROM "+table+" GROUP BY DealUUID")
+	if err != nil {
+		return nil, fmt.Errorf("listing deal uuids in %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var problems []IntegrityProblem
+	for rows.Next() {
+		var dealUuid sql.NullString
+		var count int
+		if err := rows.Scan(&dealUuid, &count); err != nil {
+			return nil, fmt.Errorf("scanning deal uuid in %s: %w", table, err)
+		}
+		if _, ok := dealIDs[dealUuid.String]; ok {
+			continue
+		}
+		problems = append(problems, IntegrityProblem{
+			Table:    table,
+			DealUUID: dealUuid.String,
+			Problem:  ProblemOrphanedRow,
+			Detail:   fmt.Sprintf("%d rows refer to a deal that does not exist", count),
+		})
+	}
+	return problems, rows.Err()
+}
+
+// DeleteOrphanedRows deletes all rows in the given table that refer to the
+// deal with the given uuid
+func DeleteOrphanedRows(ctx context.Context, sqldb *sql.DB, table string, dealUuid string) error {
+	if !isDealRefTable(table) {
+		return fmt.Errorf("table %s does not refer to deals", table)
+	}
+	_, err := sqldb.ExecContext(ctx, "DELETE FROM "+table+" WHERE DealUUID=?", dealUuid)
+	return err
+}
+
+func isDealRefTable(table string) bool {
+	if table == "DealLogs" {
+		return true
+	}
+	for _, t := range dealRefTables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+// The columns that are copied from the Deals table to the QuarantinedDeals
+// table. When a migration adds a column to Deals, it should add the column
+// to QuarantinedDeals too, so that it can be added here.
+var quarantinedDealColumns = []string{
+	"ID", "CreatedAt", "SignedProposalCID", "DealProposalSignature", "PieceCID", "PieceSize", "IsOffline",
+	"VerifiedDeal", "ClientAddress", "ProviderAddress", "Label", "StartEpoch", "EndEpoch", "StoragePricePerEpoch",
+	"ProviderCollateral", "ClientCollateral", "ClientPeerID", "DealDataRoot", "InboundFilePath", "TransferType",
+	"TransferParams", "TransferSize", "ChainDealID", "PublishCID", "SectorID", "Offset", "Length", "Checkpoint",
+	"Error", "CheckpointAt", "Retry", "FastRetrieval", "AnnounceToIPNI", "AnnounceAfterSealing", "AnnounceRule",
+	"CollateralWallet", "CollateralRule",
+}
+
+// QuarantineDeal moves the deal row with the given id out of the Deals table
+// and into the QuarantinedDeals table
+func (d *DealsDB) QuarantineDeal(ctx context.Context, id string) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	cols := `"` + strings.Join(quarantinedDealColumns, `", "`) + `"`
+	qry := "INSERT INTO " + QuarantinedDealsTable + " (QuarantinedAt, " + cols + ") "
+	qry += "SELECT ?, " + cols + " FROM Deals WHERE ID=?"
+	res, err := tx.ExecContext(ctx, qry, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("copying deal %s to quarantine table: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("deal %s: %w", id, ErrNotFound)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM Deals WHERE ID=?", id); err != nil {
+		return fmt.Errorf("deleting deal %s: %w", id, err)
+	}
+
+	return tx.Commit()
+}
+
+func (d *DealsDB) ids(ctx context.Context) ([]string, error) {
+	rows, err := d.db.QueryContext(ctx, "SELECT ID FROM Deals")
+	if err != nil {
+		return nil, fmt.Errorf("listing deal ids: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scanning deal id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
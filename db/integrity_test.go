@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateAllBoostTables(ctx, sqldb, sqldb))
+	req.NoError(migrations.Migrate(sqldb))
+
+	dealsDB := NewDealsDB(sqldb)
+	logsDB := NewLogsDB(sqldb)
+
+	deals, err := GenerateNDeals(3)
+	req.NoError(err)
+	for _, deal := range deals {
+		req.NoError(dealsDB.Insert(ctx, &deal))
+		req.NoError(logsDB.InsertLog(ctx, &DealLog{DealUUID: deal.DealUuid, CreatedAt: time.Now(), LogMsg: "Test"}))
+	}
+
+	// A healthy DB should have no problems
+	problems, err := CheckIntegrity(ctx, dealsDB, sqldb)
+	req.NoError(err)
+	req.Empty(problems)
+
+	// Add a log for a deal that doesn't exist
+	orphanUuid := uuid.New()
+	req.NoError(logsDB.InsertLog(ctx, &DealLog{DealUUID: orphanUuid, CreatedAt: time.Now(), LogMsg: "Test"}))
+
+	// Corrupt one of the deal rows
+	_, err = sqldb.ExecContext(ctx, "UPDATE Deals SET Checkpoint='Bogus' WHERE ID=?", deals[1].DealUuid)
+	req.NoError(err)
+
+	problems, err = CheckIntegrity(ctx, dealsDB, sqldb)
+	req.NoError(err)
+	req.Len(problems, 2)
+	byDeal := make(map[string]IntegrityProblem)
+	for _, p := range problems {
+		byDeal[p.DealUUID] = p
+	}
+	req.Equal(ProblemOrphanedRow, byDeal[orphanUuid.String()].Problem)
+	req.Equal("DealLogs", byDeal[orphanUuid.String()].Table)
+	req.Equal(ProblemCorruptRow, byDeal[deals[1].DealUuid.String()].Problem)
+
+	// Repair the problems
+	req.NoError(DeleteOrphanedRows(ctx, sqldb, "DealLogs", orphanUuid.String()))
+	req.NoError(dealsDB.QuarantineDeal(ctx, deals[1].DealUuid.String()))
+
+	var count int
+	row := sqldb.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+QuarantinedDealsTable+" WHERE ID=?", deals[1].DealUuid)
+	req.NoError(row.Scan(&count))
+	req.Equal(1, count)
+
+	// The quarantined deal's logs are now orphaned
+	problems, err = CheckIntegrity(ctx, dealsDB, sqldb)
+	req.NoError(err)
+	req.Len(problems, 1)
+	req.Equal(ProblemOrphanedRow, problems[0].Problem)
+	req.Equal(deals[1].DealUuid.String(), problems[0].DealUUID)
+
+	// Only tables that refer to deals can have orphans deleted
+	req.Error(DeleteOrphanedRows(ctx, sqldb, "Deals", deals[0].DealUuid.String()))
+}
+
+func TestQuarantinedDealColumns(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateAllBoostTables(ctx, sqldb, sqldb))
+	req.NoError(migrations.Migrate(sqldb))
+
+	columns := func(table string) []string {
+		rows, err := sqldb.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", table)
+		req.NoError(err)
+		defer rows.Close()
+
+		var cols []string
+		for rows.Next() {
+			var col string
+			req.NoError(rows.Scan(&col))
+			cols = append(cols, col)
+		}
+		req.NoError(rows.Err())
+		return cols
+	}
+
+	// Every column of the Deals table should be copied when a deal is
+	// quarantined
+	req.ElementsMatch(columns("Deals"), quarantinedDealColumns)
+	req.ElementsMatch(columns(QuarantinedDealsTable), append([]string{"QuarantinedAt"}, quarantinedDealColumns...))
+}
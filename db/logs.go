@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,19 +19,37 @@ type DealLog struct {
 }
 
 type LogsDB struct {
-	db *sql.DB
+	db        *sql.DB
+	changeLog *DealChangeLogDB
 }
 
 func NewLogsDB(db *sql.DB) *LogsDB {
-	return &LogsDB{db}
+	return &LogsDB{db: db}
+}
+
+// SetChangeLog sets a change log that each deal log line is appended to.
+// The logs are in a separate database to the change log, so the line is
+// appended after it has been written.
+func (d *LogsDB) SetChangeLog(cl *DealChangeLogDB) {
+	d.changeLog = cl
 }
 
 func (d *LogsDB) InsertLog(ctx context.Context, l *DealLog) error {
+	defer queryTimer(ctx, "logs_insert")()
+
 	qry := "INSERT INTO DealLogs (DealUUID, CreatedAt, LogLevel, LogMsg, LogParams, Subsystem) "
 	qry += "VALUES (?, ?, ?, ?, ?, ?)"
 	values := []interface{}{l.DealUUID.String(), l.CreatedAt, l.LogLevel, l.LogMsg, l.LogParams, l.Subsystem}
 	_, err := d.db.ExecContext(ctx, qry, values...)
-	return err
+	if err != nil || d.changeLog == nil {
+		return err
+	}
+
+	err = d.changeLog.Append(ctx, &DealChange{Kind: DealChangeKindLog, DealUUID: l.DealUUID, Log: l})
+	if err != nil {
+		return fmt.Errorf("appending log for deal %s to change log: %w", l.DealUUID, err)
+	}
+	return nil
 }
 
 func (d *LogsDB) Logs(ctx context.Context, dealID uuid.UUID) ([]DealLog, error) {
@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS QuarantinedDeals (
+    QuarantinedAt DateTime,
+    ID TEXT,
+    CreatedAt DateTime,
+    SignedProposalCID TEXT,
+    DealProposalSignature BLOB,
+    PieceCID TEXT,
+    PieceSize INT,
+    IsOffline BOOL,
+    VerifiedDeal BOOL,
+    ClientAddress TEXT,
+    ProviderAddress TEXT,
+    Label TEXT,
+    StartEpoch INT,
+    EndEpoch INT,
+    StoragePricePerEpoch TEXT,
+    ProviderCollateral TEXT,
+    ClientCollateral TEXT,
+    ClientPeerID TEXT,
+    DealDataRoot TEXT,
+    InboundFilePath TEXT,
+    TransferType TEXT,
+    TransferParams BLOB,
+    TransferSize INT,
+    ChainDealID INT,
+    PublishCID TEXT,
+    SectorID INT,
+    Offset INT,
+    Length INT,
+    Checkpoint TEXT,
+    Error TEXT,
+    CheckpointAt DateTime,
+    Retry TEXT,
+    FastRetrieval BOOL,
+    AnnounceToIPNI BOOL
+);
+
+CREATE INDEX IF NOT EXISTS index_quarantined_deals_id on QuarantinedDeals(ID);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX index_quarantined_deals_id;
+DROP TABLE QuarantinedDeals;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS DealChangeLog (
+    Seq INTEGER PRIMARY KEY AUTOINCREMENT,
+    Kind TEXT,
+    DealUUID TEXT,
+    CreatedAt DateTime,
+    Change BLOB
+);
+
+CREATE INDEX IF NOT EXISTS index_deal_changelog_deal_uuid on DealChangeLog(DealUUID);
+
+CREATE TABLE IF NOT EXISTS DealChangeLogCursors (
+    Sink TEXT,
+    Seq INT,
+    UpdatedAt DateTime,
+    PRIMARY KEY(Sink)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE DealChangeLog;
+DROP TABLE DealChangeLogCursors;
+-- +goose StatementEnd
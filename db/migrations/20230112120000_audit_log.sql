@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS AuditLog (
+    Seq INTEGER PRIMARY KEY AUTOINCREMENT,
+    CreatedAt DateTime,
+    DealUUID TEXT,
+    EventType TEXT,
+    Actor TEXT,
+    FromCheckpoint TEXT,
+    ToCheckpoint TEXT,
+    Rule TEXT,
+    Detail TEXT
+);
+
+CREATE INDEX IF NOT EXISTS index_audit_log_deal_uuid on AuditLog(DealUUID);
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON AuditLog
+BEGIN
+    SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON AuditLog
+BEGIN
+    SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER audit_log_no_update;
+DROP TRIGGER audit_log_no_delete;
+DROP TABLE AuditLog;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS index_deals_created_at_id on Deals(CreatedAt, ID);
+CREATE INDEX IF NOT EXISTS index_deals_checkpoint_id on Deals(Checkpoint, ID);
+CREATE INDEX IF NOT EXISTS index_deals_client_address_id on Deals(ClientAddress, ID);
+CREATE INDEX IF NOT EXISTS index_deals_piece_size_id on Deals(PieceSize, ID);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX index_deals_created_at_id;
+DROP INDEX index_deals_checkpoint_id;
+DROP INDEX index_deals_client_address_id;
+DROP INDEX index_deals_piece_size_id;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS index_audit_log_event_type_created_at on AuditLog(EventType, CreatedAt);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX index_audit_log_event_type_created_at;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS Webhooks (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    CreatedAt DateTime,
+    URL TEXT,
+    Secret TEXT,
+    EventTypes TEXT,
+    LastSeq INT,
+    LastError TEXT
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE Webhooks;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS PendingAnnouncements (
+    DealUUID TEXT PRIMARY KEY,
+    CreatedAt DateTime,
+    Attempts INT,
+    NextAttempt DateTime,
+    LastError TEXT
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE PendingAnnouncements;
+-- +goose StatementEnd
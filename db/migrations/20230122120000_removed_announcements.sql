@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS RemovedAnnouncements (
+    DealUUID TEXT PRIMARY KEY,
+    RemovedAt DateTime,
+    Reason TEXT
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE RemovedAnnouncements;
+-- +goose StatementEnd
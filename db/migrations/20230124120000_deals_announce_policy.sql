@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE Deals
+    ADD AnnounceAfterSealing BOOL DEFAULT FALSE;
+ALTER TABLE Deals
+    ADD AnnounceRule TEXT DEFAULT '';
+ALTER TABLE QuarantinedDeals
+    ADD AnnounceAfterSealing BOOL DEFAULT FALSE;
+ALTER TABLE QuarantinedDeals
+    ADD AnnounceRule TEXT DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
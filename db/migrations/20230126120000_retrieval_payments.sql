@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS RetrievalPayments (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    CreatedAt DateTime,
+    ClientID TEXT,
+    Protocol TEXT,
+    PaymentChannel TEXT,
+    Amount TEXT
+);
+CREATE INDEX IF NOT EXISTS index_retrieval_payments_client_id on RetrievalPayments(ClientID);
+CREATE TABLE IF NOT EXISTS RetrievalCredit (
+    ClientID TEXT PRIMARY KEY,
+    UpdatedAt DateTime,
+    Credit TEXT
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX index_retrieval_payments_client_id;
+DROP TABLE RetrievalPayments;
+DROP TABLE RetrievalCredit;
+-- +goose StatementEnd
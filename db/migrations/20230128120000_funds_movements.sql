@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS FundsMovements (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    CreatedAt DateTime,
+    DealUUID TEXT,
+    Type TEXT,
+    Amount TEXT,
+    Wallet TEXT,
+    MessageCID TEXT,
+    Text TEXT
+);
+CREATE INDEX IF NOT EXISTS index_funds_movements_created_at on FundsMovements(CreatedAt);
+CREATE INDEX IF NOT EXISTS index_funds_movements_deal_uuid on FundsMovements(DealUUID);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX index_funds_movements_created_at;
+DROP INDEX index_funds_movements_deal_uuid;
+DROP TABLE FundsMovements;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE Deals
+    ADD CollateralWallet TEXT DEFAULT '';
+ALTER TABLE Deals
+    ADD CollateralRule TEXT DEFAULT '';
+ALTER TABLE QuarantinedDeals
+    ADD CollateralWallet TEXT DEFAULT '';
+ALTER TABLE QuarantinedDeals
+    ADD CollateralRule TEXT DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS FundsTopUps (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    CreatedAt DateTime,
+    Kind TEXT,
+    Amount TEXT,
+    Wallet TEXT,
+    MessageCID TEXT,
+    Landed BOOL DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS index_funds_top_ups_created_at on FundsTopUps(CreatedAt);
+CREATE INDEX IF NOT EXISTS index_funds_top_ups_kind_landed on FundsTopUps(Kind, Landed);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX index_funds_top_ups_created_at;
+DROP INDEX index_funds_top_ups_kind_landed;
+DROP TABLE FundsTopUps;
+-- +goose StatementEnd
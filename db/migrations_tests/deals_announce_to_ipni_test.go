@@ -23,24 +23,41 @@ func TestDealAnnounceToIPNI(t *testing.T) {
 	req.NoError(goose.UpTo(sqldb, ".", 20230104230242))
 
 	// Generate 1 deal
-	dealsDB := db.NewDealsDB(sqldb)
 	deals, err := db.GenerateNDeals(1)
 	req.NoError(err)
 
-	// Insert the deals in DB
-	err = dealsDB.Insert(ctx, &deals[0])
+	deal := deals[0]
+
+	// Insert the deal with raw SQL, as the deals DB expects the columns that
+	// are added by later migrations
+	_, err = sqldb.Exec(`INSERT INTO Deals ("ID", "CreatedAt", "DealProposalSignature", "PieceCID", "PieceSize",
+                   "VerifiedDeal", "IsOffline", "ClientAddress", "ProviderAddress","Label", "StartEpoch", "EndEpoch",
+                   "StoragePricePerEpoch", "ProviderCollateral", "ClientCollateral", "ClientPeerID", "DealDataRoot",
+                   "InboundFilePath", "TransferType", "TransferParams", "TransferSize", "ChainDealID", "PublishCID",
+                   "SectorID", "Offset", "Length", "Checkpoint", "CheckpointAt", "Error", "Retry", "SignedProposalCID",
+                   "FastRetrieval", "AnnounceToIPNI")
+                   VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		deal.DealUuid, deal.CreatedAt, []byte("test"), deal.ClientDealProposal.Proposal.PieceCID.String(),
+		deal.ClientDealProposal.Proposal.PieceSize, deal.ClientDealProposal.Proposal.VerifiedDeal, deal.IsOffline,
+		deal.ClientDealProposal.Proposal.Client.String(), deal.ClientDealProposal.Proposal.Provider.String(), "test",
+		deal.ClientDealProposal.Proposal.StartEpoch, deal.ClientDealProposal.Proposal.EndEpoch, deal.ClientDealProposal.Proposal.StoragePricePerEpoch.Uint64(),
+		deal.ClientDealProposal.Proposal.ProviderCollateral.Int64(), deal.ClientDealProposal.Proposal.ClientCollateral.Uint64(), deal.ClientPeerID.String(),
+		deal.DealDataRoot.String(), deal.InboundFilePath, deal.Transfer.Type, deal.Transfer.Params, deal.Transfer.Size, deal.ChainDealID,
+		deal.PublishCID.String(), deal.SectorID, deal.Offset, deal.Length, deal.Checkpoint, deal.CheckpointAt, deal.Err, deal.Retry, []byte("test"),
+		deal.FastRetrieval, false)
 	require.NoError(t, err)
 
-	// Get deal state
-	dealState, err := dealsDB.ByID(ctx, deals[0].DealUuid)
-	require.NoError(t, err)
-	require.False(t, dealState.AnnounceToIPNI)
+	announceToIPNI := func() bool {
+		var announce bool
+		err := sqldb.QueryRow("SELECT AnnounceToIPNI FROM Deals WHERE ID = ?", deal.DealUuid).Scan(&announce)
+		require.NoError(t, err)
+		return announce
+	}
+	require.False(t, announceToIPNI())
 
 	//Run migration
 	req.NoError(goose.UpByOne(sqldb, "."))
 
 	// Check the deal state again
-	dealState, err = dealsDB.ByID(ctx, deals[0].DealUuid)
-	require.NoError(t, err)
-	require.True(t, dealState.AnnounceToIPNI)
+	require.True(t, announceToIPNI())
 }
@@ -0,0 +1,240 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/filecoin-project/boost/db/fielddef"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+)
+
+// RetrievalPayment is a payment received from a client for a retrieval
+type RetrievalPayment struct {
+	CreatedAt time.Time
+	// The client's peer ID for graphsync retrievals, or the client's auth
+	// token id (or IP address) for HTTP retrievals
+	ClientID string
+	// The protocol of the retrieval that was paid for, eg "graphsync"
+	Protocol string
+	// The address of the payment channel that the payment was made from
+	PaymentChannel string
+	Amount         abi.TokenAmount
+}
+
+// RetrievalEarnings is the total of the payments received from a client
+// for retrievals over a protocol
+type RetrievalEarnings struct {
+	ClientID string
+	Protocol string
+	Payments int
+	Total    abi.TokenAmount
+}
+
+type RetrievalPaymentsDB struct {
+	db *sql.DB
+}
+
+func NewRetrievalPaymentsDB(db *sql.DB) *RetrievalPaymentsDB {
+	return &RetrievalPaymentsDB{db: db}
+}
+
+// Insert records a payment. If addCredit is true, the amount is also added
+// to the client's credit, which is used up by Reserve.
+func (r *RetrievalPaymentsDB) Insert(ctx context.Context, p *RetrievalPayment, addCredit bool) (abi.TokenAmount, error) {
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = time.Now()
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return big.Zero(), err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	qry := "INSERT INTO RetrievalPayments (CreatedAt, ClientID, Protocol, PaymentChannel, Amount) VALUES (?, ?, ?, ?, ?)"
+	_, err = tx.ExecContext(ctx, qry, p.CreatedAt, p.ClientID, p.Protocol, p.PaymentChannel, p.Amount.String())
+	if err != nil {
+		return big.Zero(), fmt.Errorf("inserting retrieval payment: %w", err)
+	}
+
+	credit, err := credit(ctx, tx, p.ClientID)
+	if err != nil {
+		return big.Zero(), err
+	}
+	if addCredit {
+		credit = big.Add(credit, p.Amount)
+		if err := setCredit(ctx, tx, p.ClientID, credit); err != nil {
+			return big.Zero(), err
+		}
+	}
+	return credit, tx.Commit()
+}
+
+// Credit returns the amount that the client has paid for retrievals that
+// has not yet been used up
+func (r *RetrievalPaymentsDB) Credit(ctx context.Context, clientID string) (abi.TokenAmount, error) {
+	return credit(ctx, r.db, clientID)
+}
+
+// RetrievalReservation is the credit set aside for the bytes sent to a
+// client in a retrieval
+type RetrievalReservation struct {
+	// The number of bytes that the reserved amount pays for
+	Bytes uint64
+	// The amount subtracted from the client's credit
+	Amount abi.TokenAmount
+	// The client's remaining credit
+	Credit abi.TokenAmount
+}
+
+// Reserve subtracts the price of as many bytes as the client's credit pays
+// for, at the price per byte, so that concurrent retrievals can't spend the
+// same credit. The part of the reserved amount that isn't used must be
+// given back with Refund.
+func (r *RetrievalPaymentsDB) Reserve(ctx context.Context, clientID string, pricePerByte abi.TokenAmount) (*RetrievalReservation, error) {
+	if !pricePerByte.GreaterThan(big.Zero()) {
+		return nil, fmt.Errorf("price per byte must be positive, got %s", pricePerByte)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	credit, err := credit(ctx, tx, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &RetrievalReservation{Amount: big.Zero(), Credit: credit}
+	if !credit.GreaterThan(big.Zero()) {
+		return res, nil
+	}
+	bytes := big.Div(credit, pricePerByte)
+	if !bytes.Int.IsUint64() {
+		bytes = big.NewIntUnsigned(math.MaxUint64)
+	}
+	res.Bytes = bytes.Uint64()
+	res.Amount = big.Mul(bytes, pricePerByte)
+	res.Credit = big.Sub(credit, res.Amount)
+	if res.Bytes == 0 {
+		return res, nil
+	}
+	if err := setCredit(ctx, tx, clientID, res.Credit); err != nil {
+		return nil, err
+	}
+	return res, tx.Commit()
+}
+
+// Refund adds the amount back to the client's credit, and returns the
+// client's credit
+func (r *RetrievalPaymentsDB) Refund(ctx context.Context, clientID string, amount abi.TokenAmount) (abi.TokenAmount, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return big.Zero(), err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	credit, err := credit(ctx, tx, clientID)
+	if err != nil {
+		return big.Zero(), err
+	}
+	if amount.IsZero() {
+		return credit, nil
+	}
+	credit = big.Add(credit, amount)
+	if err := setCredit(ctx, tx, clientID, credit); err != nil {
+		return big.Zero(), err
+	}
+	return credit, tx.Commit()
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func credit(ctx context.Context, q queryRower, clientID string) (abi.TokenAmount, error) {
+	credit := &fielddef.BigIntFieldDef{F: new(abi.TokenAmount)}
+	row := q.QueryRowContext(ctx, "SELECT Credit FROM RetrievalCredit WHERE ClientID = ?", clientID)
+	err := row.Scan(&credit.Marshalled)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return big.Zero(), nil
+		}
+		return big.Zero(), fmt.Errorf("getting retrieval credit: %w", err)
+	}
+	if err := credit.Unmarshall(); err != nil {
+		return big.Zero(), fmt.Errorf("unmarshalling retrieval credit: %w", err)
+	}
+	if credit.F.Int == nil {
+		return big.Zero(), nil
+	}
+	return *credit.F, nil
+}
+
+func setCredit(ctx context.Context, tx *sql.Tx, clientID string, credit abi.TokenAmount) error {
+	qry := "INSERT INTO RetrievalCredit (ClientID, UpdatedAt, Credit) VALUES (?, ?, ?) "
+	qry += "ON CONFLICT(ClientID) DO UPDATE SET UpdatedAt = excluded.UpdatedAt, Credit = excluded.Credit"
+	_, err := tx.ExecContext(ctx, qry, clientID, time.Now(), credit.String())
+	if err != nil {
+		return fmt.Errorf("setting retrieval credit: %w", err)
+	}
+	return nil
+}
+
+// Earnings returns the total of the payments received from each client
+// over each protocol, sorted by client then protocol
+func (r *RetrievalPaymentsDB) Earnings(ctx context.Context) ([]RetrievalEarnings, error) {
+	// The amounts are stored as strings, so they are added up here rather
+	// than in the query
+	rows, err := r.db.QueryContext(ctx, "SELECT ClientID, Protocol, Amount FROM RetrievalPayments")
+	if err != nil {
+		return nil, fmt.Errorf("getting retrieval payments: %w", err)
+	}
+	defer rows.Close()
+
+	type key struct{ client, protocol string }
+	byKey := make(map[key]*RetrievalEarnings)
+	for rows.Next() {
+		var k key
+		amt := &fielddef.BigIntFieldDef{F: new(abi.TokenAmount)}
+		if err := rows.Scan(&k.client, &k.protocol, &amt.Marshalled); err != nil {
+			return nil, fmt.Errorf("getting retrieval payment: %w", err)
+		}
+		if err := amt.Unmarshall(); err != nil {
+			return nil, fmt.Errorf("unmarshalling retrieval payment Amount: %w", err)
+		}
+
+		e, ok := byKey[k]
+		if !ok {
+			e = &RetrievalEarnings{ClientID: k.client, Protocol: k.protocol, Total: big.Zero()}
+			byKey[k] = e
+		}
+		e.Payments++
+		if amt.F.Int != nil {
+			e.Total = big.Add(e.Total, *amt.F)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	earnings := make([]RetrievalEarnings, 0, len(byKey))
+	for _, e := range byKey {
+		earnings = append(earnings, *e)
+	}
+	sort.Slice(earnings, func(i, j int) bool {
+		if earnings[i].ClientID != earnings[j].ClientID {
+			return earnings[i].ClientID < earnings[j].ClientID
+		}
+		return earnings[i].Protocol < earnings[j].Protocol
+	})
+	return earnings, nil
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetrievalPaymentsDB(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateAllBoostTables(ctx, sqldb, sqldb))
+	req.NoError(migrations.Migrate(sqldb))
+
+	pdb := NewRetrievalPaymentsDB(sqldb)
+
+	// A client with no payments has no credit
+	credit, err := pdb.Credit(ctx, "client1")
+	req.NoError(err)
+	req.True(credit.IsZero())
+
+	// Payments for http retrievals add to the client's credit
+	credit, err = pdb.Insert(ctx, &RetrievalPayment{ClientID: "client1", Protocol: "http", PaymentChannel: "f01234", Amount: abi.NewTokenAmount(100)}, true)
+	req.NoError(err)
+	req.Equal(abi.NewTokenAmount(100), credit)
+	credit, err = pdb.Insert(ctx, &RetrievalPayment{ClientID: "client1", Protocol: "http", PaymentChannel: "f01234", Amount: abi.NewTokenAmount(50)}, true)
+	req.NoError(err)
+	req.Equal(abi.NewTokenAmount(150), credit)
+
+	// Payments for graphsync retrievals don't
+	credit, err = pdb.Insert(ctx, &RetrievalPayment{ClientID: "client1", Protocol: "graphsync", Amount: abi.NewTokenAmount(30)}, false)
+	req.NoError(err)
+	req.Equal(abi.NewTokenAmount(150), credit)
+	_, err = pdb.Insert(ctx, &RetrievalPayment{ClientID: "client2", Protocol: "graphsync", Amount: abi.NewTokenAmount(20)}, false)
+	req.NoError(err)
+
+	// A reservation uses up as much of the credit as pays for whole bytes
+	res, err := pdb.Reserve(ctx, "client1", abi.NewTokenAmount(40))
+	req.NoError(err)
+	req.Equal(&RetrievalReservation{Bytes: 3, Amount: abi.NewTokenAmount(120), Credit: abi.NewTokenAmount(30)}, res)
+
+	// A concurrent reservation gets what is left
+	res, err = pdb.Reserve(ctx, "client1", abi.NewTokenAmount(40))
+	req.NoError(err)
+	req.Zero(res.Bytes)
+	req.Equal(abi.NewTokenAmount(30), res.Credit)
+
+	// The unused part of a reservation is refunded
+	credit, err = pdb.Refund(ctx, "client1", abi.NewTokenAmount(80))
+	req.NoError(err)
+	req.Equal(abi.NewTokenAmount(110), credit)
+	credit, err = pdb.Credit(ctx, "client1")
+	req.NoError(err)
+	req.Equal(abi.NewTokenAmount(110), credit)
+
+	// A client without credit can't reserve anything
+	res, err = pdb.Reserve(ctx, "client2", abi.NewTokenAmount(40))
+	req.NoError(err)
+	req.Zero(res.Bytes)
+	req.True(res.Amount.IsZero())
+
+	// Reservations don't change the earnings
+	earnings, err := pdb.Earnings(ctx)
+	req.NoError(err)
+	req.Equal([]RetrievalEarnings{
+		{ClientID: "client1", Protocol: "graphsync", Payments: 1, Total: abi.NewTokenAmount(30)},
+		{ClientID: "client1", Protocol: "http", Payments: 2, Total: abi.NewTokenAmount(150)},
+		{ClientID: "client2", Protocol: "graphsync", Payments: 1, Total: abi.NewTokenAmount(20)},
+	}, earnings)
+}
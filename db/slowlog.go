@@ -0,0 +1,183 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/filecoin-project/boost/metrics"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/mattn/go-sqlite3"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+var log = logging.Logger("db")
+
+// The name of the sqlite3 driver that logs slow queries
+const slowLogDriverName = "sqlite3-slowlog"
+
+func init() {
+	sql.Register(slowLogDriverName, &slowLogDriver{})
+}
+
+// The threshold above which a query is logged as slow, in nanoseconds
+var slowQueryThreshold int64
+
+// SetSlowQueryThreshold sets the threshold above which queries are logged
+// (with their parameters and caller) and counted in the slow query metric.
+// The duration of a query includes the time taken to read its rows.
+// A threshold of zero disables slow query logging.
+func SetSlowQueryThreshold(threshold time.Duration) {
+	atomic.StoreInt64(&slowQueryThreshold, int64(threshold))
+}
+
+func checkSlowQuery(query string, args []driver.NamedValue, start time.Time) {
+	threshold := time.Duration(atomic.LoadInt64(&slowQueryThreshold))
+	if threshold <= 0 {
+		return
+	}
+	took := time.Since(start)
+	if took < threshold {
+		return
+	}
+
+	fn, fileLine := queryCaller()
+	log.Warnw("slow query", "took", took.String(), "caller", fn, "at", fileLine,
+		"query", strings.Join(strings.Fields(query), " "), "args", formatQueryArgs(args))
+
+	ctx, _ := tag.New(context.Background(), tag.Upsert(metrics.DBQuery, fn))
+	stats.Record(ctx, metrics.DBSlowQueryCount.M(1))
+}
+
+// queryCaller returns the function outside the database packages that
+// made the query, and its file and line
+func queryCaller() (string, string) {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "database/sql.") && !strings.HasSuffix(frame.File, "/db/slowlog.go") {
+			fn := frame.Function
+			if i := strings.LastIndex(fn, "/"); i >= 0 {
+				fn = fn[i+1:]
+			}
+			return fn, fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+		}
+		if !more {
+			return "unknown", ""
+		}
+	}
+}
+
+func formatQueryArgs(args []driver.NamedValue) []string {
+	formatted := make([]string, 0, len(args))
+	for _, a := range args {
+		switch v := a.Value.(type) {
+		case []byte:
+			formatted = append(formatted, fmt.Sprintf("<%d bytes>", len(v)))
+		case string:
+			if len(v) > 128 {
+				v = v[:128] + "..."
+			}
+			formatted = append(formatted, v)
+		default:
+			formatted = append(formatted, fmt.Sprint(v))
+		}
+	}
+	return formatted
+}
+
+// slowLogDriver is the sqlite3 driver, with each query timed so that slow
+// queries can be logged
+type slowLogDriver struct {
+	sqlite3.SQLiteDriver
+}
+
+func (d *slowLogDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &slowLogConn{SQLiteConn: conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+type slowLogConn struct {
+	*sqlite3.SQLiteConn
+}
+
+func (c *slowLogConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	defer checkSlowQuery(query, args, time.Now())
+	return c.SQLiteConn.ExecContext(ctx, query, args)
+}
+
+func (c *slowLogConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := c.SQLiteConn.QueryContext(ctx, query, args)
+	return wrapRows(rows, err, query, args, start)
+}
+
+func (c *slowLogConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.SQLiteConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &slowLogStmt{SQLiteStmt: stmt.(*sqlite3.SQLiteStmt), query: query}, nil
+}
+
+type slowLogStmt struct {
+	*sqlite3.SQLiteStmt
+	query string
+}
+
+func (s *slowLogStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	defer checkSlowQuery(s.query, args, time.Now())
+	return s.SQLiteStmt.ExecContext(ctx, args)
+}
+
+func (s *slowLogStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := s.SQLiteStmt.QueryContext(ctx, args)
+	return wrapRows(rows, err, s.query, args, start)
+}
+
+// wrapRows wraps the rows returned by a query so that the query is timed
+// until the rows are closed
+func wrapRows(rows driver.Rows, err error, query string, args []driver.NamedValue, start time.Time) (driver.Rows, error) {
+	if err != nil {
+		checkSlowQuery(query, args, start)
+		return nil, err
+	}
+	return &slowLogRows{SQLiteRows: rows.(*sqlite3.SQLiteRows), query: query, args: args, start: start}, nil
+}
+
+type slowLogRows struct {
+	*sqlite3.SQLiteRows
+	query string
+	args  []driver.NamedValue
+	start time.Time
+}
+
+func (r *slowLogRows) Close() error {
+	defer checkSlowQuery(r.query, r.args, r.start)
+	return r.SQLiteRows.Close()
+}
+
+// sqliteConn gets the underlying sqlite3 connection from a raw connection
+func sqliteConn(conn interface{}) (*sqlite3.SQLiteConn, bool) {
+	switch c := conn.(type) {
+	case *slowLogConn:
+		return c.SQLiteConn, true
+	case *sqlite3.SQLiteConn:
+		return c, true
+	default:
+		return nil, false
+	}
+}
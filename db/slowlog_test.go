@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/metrics"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+)
+
+func TestSlowQueryLog(t *testing.T) {
+	ctx := context.Background()
+
+	require.NoError(t, view.Register(metrics.DBSlowQueryCountView))
+	defer view.Unregister(metrics.DBSlowQueryCountView)
+
+	sqldb := CreateTestTmpDB(t)
+	require.NoError(t, CreateAllBoostTables(ctx, sqldb, sqldb))
+	dealsDB := NewDealsDB(sqldb)
+
+	slowQueries := func() int64 {
+		rows, err := view.RetrieveData(metrics.DBSlowQueryCountView.Name)
+		require.NoError(t, err)
+		var count int64
+		for _, row := range rows {
+			for _, tg := range row.Tags {
+				// The caller of the query is the deals db
+				if tg.Key == metrics.DBQuery && strings.Contains(tg.Value, "DealsDB") {
+					count += row.Data.(*view.CountData).Value
+				}
+			}
+		}
+		return count
+	}
+
+	// Slow query logging is disabled
+	SetSlowQueryThreshold(0)
+	_, err := dealsDB.Count(ctx, "", nil)
+	require.NoError(t, err)
+	require.Zero(t, slowQueries())
+
+	// Every query takes longer than the threshold
+	SetSlowQueryThreshold(time.Nanosecond)
+	defer SetSlowQueryThreshold(0)
+	_, err = dealsDB.Count(ctx, "", nil)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return slowQueries() == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestFormatQueryArgs(t *testing.T) {
+	args := formatQueryArgs([]driver.NamedValue{{Value: []byte{1, 2, 3}}, {Value: strings.Repeat("a", 200)}, {Value: int64(5)}})
+	require.Equal(t, "<3 bytes>", args[0])
+	require.Len(t, args[1], 131)
+	require.Equal(t, "5", args[2])
+}
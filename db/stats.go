@@ -0,0 +1,220 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CheckpointStats are the aggregated counts and byte totals of the deals
+// at a particular checkpoint
+type CheckpointStats struct {
+	Checkpoint string
+	Count      int
+	// The number of deals at this checkpoint that have an error
+	// (failed deals are moved to the Complete checkpoint)
+	Failed        int
+	PieceBytes    uint64
+	TransferBytes uint64
+}
+
+// CheckpointStats returns the deal counts and byte totals per checkpoint,
+// for deals created at or after since (or all deals if since is zero)
+func (d *DealsDB) CheckpointStats(ctx context.Context, since time.Time) ([]CheckpointStats, error) {
+	qry := "SELECT Checkpoint, count(*), " +
+		"COALESCE(SUM(CASE WHEN Error != '' THEN 1 ELSE 0 END), 0), " +
+		"COALESCE(SUM(PieceSize), 0), " +
+		"COALESCE(SUM(TransferSize), 0) " +
+		"FROM Deals"
+	var args []interface{}
+	if !since.IsZero() {
+		qry += " WHERE CreatedAt >= ?"
+		args = append(args, since)
+	}
+	qry += " GROUP BY Checkpoint"
+
+	rows, err := d.db.QueryContext(ctx, qry, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []CheckpointStats
+	for rows.Next() {
+		var st CheckpointStats
+		err := rows.Scan(&st.Checkpoint, &st.Count, &st.Failed, &st.PieceBytes, &st.TransferBytes)
+		if err != nil {
+			return nil, fmt.Errorf("getting checkpoint stats: %w", err)
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
+
+// RejectionStats is the number of deal proposals rejected for a
+// particular reason
+type RejectionStats struct {
+	Rule   string
+	Reason string
+	Count  int
+}
+
+// DecisionStats are the aggregated acceptance decisions for deal proposals
+type DecisionStats struct {
+	Accepted int
+	Rejected int
+	// The most common rejection reasons, most common first
+	TopRejections []RejectionStats
+}
+
+// DecisionStats returns the number of deal proposals that were accepted
+// and rejected at or after since (or all time if since is zero), and the
+// top rejection reasons
+func (a *AuditLogDB) DecisionStats(ctx context.Context, since time.Time, topRejections int) (*DecisionStats, error) {
+	where, args := auditLogSinceWhere(since)
+
+	// Offline deals have two accepted events (when the proposal is accepted
+	// and when the data is imported) so count distinct deals
+	qry := "SELECT EventType, count(DISTINCT DealUUID) FROM AuditLog" +
+		" WHERE EventType IN (?, ?)" + where + " GROUP BY EventType"
+	rows, err := a.db.QueryContext(ctx, qry, append([]interface{}{AuditEventAccepted, AuditEventRejected}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats DecisionStats
+	for rows.Next() {
+		var evtType string
+		var count int
+		if err := rows.Scan(&evtType, &count); err != nil {
+			return nil, fmt.Errorf("getting decision stats: %w", err)
+		}
+		if evtType == AuditEventAccepted {
+			stats.Accepted = count
+		} else {
+			stats.Rejected = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if topRejections <= 0 {
+		return &stats, nil
+	}
+
+	qry = "SELECT Rule, Detail, count(*) AS c FROM AuditLog" +
+		" WHERE EventType = ?" + where +
+		" GROUP BY Rule, Detail ORDER BY c DESC, Rule, Detail LIMIT ?"
+	args = append([]interface{}{AuditEventRejected}, args...)
+	rjRows, err := a.db.QueryContext(ctx, qry, append(args, topRejections)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rjRows.Close()
+
+	for rjRows.Next() {
+		var rj RejectionStats
+		if err := rjRows.Scan(&rj.Rule, &rj.Reason, &rj.Count); err != nil {
+			return nil, fmt.Errorf("getting rejection stats: %w", err)
+		}
+		stats.TopRejections = append(stats.TopRejections, rj)
+	}
+	if err := rjRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// DurationStats are the total time and bytes taken by deals to get from
+// creation to a particular checkpoint
+type DurationStats struct {
+	Count        int
+	TotalSeconds float64
+	TotalBytes   uint64
+}
+
+// TransferStats returns the time taken to transfer data for online deals
+// that finished transferring at or after since (or all time if since is zero)
+func (a *AuditLogDB) TransferStats(ctx context.Context, since time.Time) (*DurationStats, error) {
+	return a.durationStats(ctx, since, "Transferred", "d.IsOffline = 0")
+}
+
+// SealStats returns the time taken from deal creation until the deal's
+// sector was sealed, for deals that completed successfully at or after
+// since (or all time if since is zero)
+func (a *AuditLogDB) SealStats(ctx context.Context, since time.Time) (*DurationStats, error) {
+	return a.durationStats(ctx, since, "Complete", "d.Error = ''")
+}
+
+func (a *AuditLogDB) durationStats(ctx context.Context, since time.Time, toCheckpoint string, dealWhere string) (*DurationStats, error) {
+	qry := "SELECT count(*), " +
+		"COALESCE(SUM((julianday(a.CreatedAt) - julianday(d.CreatedAt)) * 86400.0), 0), " +
+		"COALESCE(SUM(d.TransferSize), 0) " +
+		"FROM AuditLog a JOIN Deals d ON d.ID = a.DealUUID " +
+		"WHERE a.EventType = ? AND a.ToCheckpoint = ? AND " + dealWhere
+	args := []interface{}{AuditEventCheckpoint, toCheckpoint}
+	if !since.IsZero() {
+		qry += " AND a.CreatedAt >= ?"
+		args = append(args, since)
+	}
+
+	var stats DurationStats
+	row := a.db.QueryRowContext(ctx, qry, args...)
+	if err := row.Scan(&stats.Count, &stats.TotalSeconds, &stats.TotalBytes); err != nil {
+		return nil, fmt.Errorf("getting %s duration stats: %w", toCheckpoint, err)
+	}
+	return &stats, nil
+}
+
+func auditLogSinceWhere(since time.Time) (string, []interface{}) {
+	if since.IsZero() {
+		return "", nil
+	}
+	return " AND CreatedAt >= ?", []interface{}{since}
+}
+
+// FailureCount returns the number of deals that failed at or after since.
+// If fromCheckpoints is not empty, only deals that failed while at one of
+// the given checkpoints are counted (eg Accepted for transfer failures).
+func (a *AuditLogDB) FailureCount(ctx context.Context, since time.Time, fromCheckpoints ...string) (int, error) {
+	qry := "SELECT count(*) FROM AuditLog " +
+		"WHERE EventType = ? AND ToCheckpoint = ? AND Detail != '' AND CreatedAt >= ?"
+	args := []interface{}{AuditEventCheckpoint, "Complete", since}
+	if len(fromCheckpoints) > 0 {
+		qry += " AND FromCheckpoint IN (?" + strings.Repeat(", ?", len(fromCheckpoints)-1) + ")"
+		for _, cp := range fromCheckpoints {
+			args = append(args, cp)
+		}
+	}
+
+	var count int
+	if err := a.db.QueryRowContext(ctx, qry, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("getting failure count: %w", err)
+	}
+	return count, nil
+}
+
+// StalledCount returns the number of deals without an error that have been
+// at one of the given checkpoints since before the given time
+func (d *DealsDB) StalledCount(ctx context.Context, before time.Time, checkpoints ...string) (int, error) {
+	if len(checkpoints) == 0 {
+		return 0, nil
+	}
+
+	qry := "SELECT count(*) FROM Deals WHERE Error = '' AND CheckpointAt < ? " +
+		"AND Checkpoint IN (?" + strings.Repeat(", ?", len(checkpoints)-1) + ")"
+	args := []interface{}{before}
+	for _, cp := range checkpoints {
+		args = append(args, cp)
+	}
+
+	var count int
+	if err := d.db.QueryRowContext(ctx, qry, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("getting stalled deal count: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,174 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDealStats(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateAllBoostTables(ctx, sqldb, sqldb))
+	req.NoError(migrations.Migrate(sqldb))
+
+	dealsDB := NewDealsDB(sqldb)
+	adb := NewAuditLogDB(sqldb)
+
+	deals, err := GenerateNDeals(4)
+	req.NoError(err)
+
+	now := time.Now()
+	earlier := now.Add(-2 * time.Hour)
+	for i := range deals {
+		deals[i].CreatedAt = now
+		deals[i].IsOffline = false
+		deals[i].Err = ""
+		deals[i].Transfer.Size = 1000
+	}
+	// The first deal was created before the stats window
+	deals[0].CreatedAt = earlier
+	deals[0].Checkpoint = dealcheckpoints.Complete
+	// The second deal is complete but failed
+	deals[1].Checkpoint = dealcheckpoints.Complete
+	deals[1].Err = "data-transfer failed"
+	for _, deal := range deals {
+		req.NoError(dealsDB.Insert(ctx, &deal))
+	}
+
+	// Checkpoint stats
+	stats, err := dealsDB.CheckpointStats(ctx, time.Time{})
+	req.NoError(err)
+	byCheckpoint := make(map[string]CheckpointStats)
+	for _, st := range stats {
+		byCheckpoint[st.Checkpoint] = st
+	}
+	req.Len(byCheckpoint, 2)
+	req.Equal(2, byCheckpoint["Complete"].Count)
+	req.Equal(1, byCheckpoint["Complete"].Failed)
+	req.Equal(uint64(2000), byCheckpoint["Complete"].TransferBytes)
+	req.Equal(2, byCheckpoint["Accepted"].Count)
+	req.Equal(0, byCheckpoint["Accepted"].Failed)
+	req.Equal(uint64(2)*uint64(deals[2].ClientDealProposal.Proposal.PieceSize), byCheckpoint["Accepted"].PieceBytes)
+
+	stats, err = dealsDB.CheckpointStats(ctx, now.Add(-time.Hour))
+	req.NoError(err)
+	var total int
+	for _, st := range stats {
+		total += st.Count
+	}
+	req.Equal(3, total)
+
+	// Decision stats
+	req.NoError(adb.Insert(ctx, &AuditEvent{CreatedAt: earlier, DealUUID: deals[0].DealUuid, Type: AuditEventAccepted, Rule: "default"}))
+	req.NoError(adb.Insert(ctx, &AuditEvent{CreatedAt: now, DealUUID: deals[1].DealUuid, Type: AuditEventAccepted, Rule: "default"}))
+	// An offline deal has two accepted events
+	req.NoError(adb.Insert(ctx, &AuditEvent{CreatedAt: now, DealUUID: deals[1].DealUuid, Type: AuditEventAccepted, Rule: "offline-import"}))
+	req.NoError(adb.Insert(ctx, &AuditEvent{CreatedAt: now, DealUUID: deals[2].DealUuid, Type: AuditEventRejected, Rule: "funds", Detail: "insufficient funds"}))
+	req.NoError(adb.Insert(ctx, &AuditEvent{CreatedAt: now, DealUUID: deals[3].DealUuid, Type: AuditEventRejected, Rule: "funds", Detail: "insufficient funds"}))
+	req.NoError(adb.Insert(ctx, &AuditEvent{CreatedAt: earlier, DealUUID: deals[3].DealUuid, Type: AuditEventRejected, Rule: "validation", Detail: "invalid signature"}))
+
+	dstats, err := adb.DecisionStats(ctx, time.Time{}, 5)
+	req.NoError(err)
+	req.Equal(2, dstats.Accepted)
+	req.Equal(2, dstats.Rejected)
+	req.Len(dstats.TopRejections, 2)
+	req.Equal(RejectionStats{Rule: "funds", Reason: "insufficient funds", Count: 2}, dstats.TopRejections[0])
+	req.Equal(RejectionStats{Rule: "validation", Reason: "invalid signature", Count: 1}, dstats.TopRejections[1])
+
+	dstats, err = adb.DecisionStats(ctx, now.Add(-time.Hour), 1)
+	req.NoError(err)
+	req.Equal(1, dstats.Accepted)
+	req.Equal(2, dstats.Rejected)
+	req.Len(dstats.TopRejections, 1)
+
+	// Duration stats
+	req.NoError(adb.Insert(ctx, &AuditEvent{CreatedAt: earlier.Add(10 * time.Second), DealUUID: deals[0].DealUuid, Type: AuditEventCheckpoint, ToCheckpoint: "Transferred"}))
+	req.NoError(adb.Insert(ctx, &AuditEvent{CreatedAt: earlier.Add(time.Hour), DealUUID: deals[0].DealUuid, Type: AuditEventCheckpoint, ToCheckpoint: "Complete"}))
+	req.NoError(adb.Insert(ctx, &AuditEvent{CreatedAt: now.Add(30 * time.Second), DealUUID: deals[1].DealUuid, Type: AuditEventCheckpoint, ToCheckpoint: "Transferred"}))
+	req.NoError(adb.Insert(ctx, &AuditEvent{CreatedAt: now.Add(time.Minute), DealUUID: deals[1].DealUuid, Type: AuditEventCheckpoint, ToCheckpoint: "Complete"}))
+
+	tstats, err := adb.TransferStats(ctx, time.Time{})
+	req.NoError(err)
+	req.Equal(2, tstats.Count)
+	req.Equal(uint64(2000), tstats.TotalBytes)
+	req.InDelta(40, tstats.TotalSeconds, 0.1)
+
+	tstats, err = adb.TransferStats(ctx, now.Add(-time.Hour))
+	req.NoError(err)
+	req.Equal(1, tstats.Count)
+	req.InDelta(30, tstats.TotalSeconds, 0.1)
+
+	// Only the first deal completed without an error
+	sstats, err := adb.SealStats(ctx, time.Time{})
+	req.NoError(err)
+	req.Equal(1, sstats.Count)
+	req.InDelta(3600, sstats.TotalSeconds, 0.1)
+}
+
+func TestAlertStats(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateAllBoostTables(ctx, sqldb, sqldb))
+	req.NoError(migrations.Migrate(sqldb))
+
+	dealsDB := NewDealsDB(sqldb)
+	adb := NewAuditLogDB(sqldb)
+
+	deals, err := GenerateNDeals(3)
+	req.NoError(err)
+
+	now := time.Now()
+	earlier := now.Add(-2 * time.Hour)
+	for i := range deals {
+		deals[i].Err = ""
+		deals[i].Checkpoint = dealcheckpoints.Transferred
+		deals[i].CheckpointAt = earlier
+	}
+	// The second deal only just reached the checkpoint
+	deals[1].CheckpointAt = now
+	// The third deal failed
+	deals[2].Err = "publish failed"
+	for _, deal := range deals {
+		req.NoError(dealsDB.Insert(ctx, &deal))
+	}
+
+	count, err := dealsDB.StalledCount(ctx, now.Add(-time.Hour), "Transferred", "Published")
+	req.NoError(err)
+	req.Equal(1, count)
+
+	count, err = dealsDB.StalledCount(ctx, now.Add(-time.Hour), "Published")
+	req.NoError(err)
+	req.Equal(0, count)
+
+	// Failures
+	evt := func(at time.Time, from string, detail string) *AuditEvent {
+		return &AuditEvent{CreatedAt: at, DealUUID: deals[0].DealUuid, Type: AuditEventCheckpoint,
+			FromCheckpoint: from, ToCheckpoint: "Complete", Detail: detail}
+	}
+	req.NoError(adb.Insert(ctx, evt(earlier, "Accepted", "data-transfer failed")))
+	req.NoError(adb.Insert(ctx, evt(now, "Accepted", "data-transfer failed")))
+	req.NoError(adb.Insert(ctx, evt(now, "Transferred", "publish failed")))
+	// A deal that completed successfully is not a failure
+	req.NoError(adb.Insert(ctx, evt(now, "IndexedAndAnnounced", "")))
+
+	count, err = adb.FailureCount(ctx, now.Add(-time.Hour))
+	req.NoError(err)
+	req.Equal(2, count)
+
+	count, err = adb.FailureCount(ctx, now.Add(-time.Hour), "Accepted")
+	req.NoError(err)
+	req.Equal(1, count)
+
+	count, err = adb.FailureCount(ctx, earlier.Add(-time.Minute), "Accepted")
+	req.NoError(err)
+	req.Equal(2, count)
+}
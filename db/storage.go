@@ -18,20 +18,43 @@ type StorageLog struct {
 	Text         string
 }
 
+// StorageTagged is the staging area storage that is tagged for a deal
+type StorageTagged struct {
+	DealUUID     uuid.UUID
+	CreatedAt    time.Time
+	TransferSize uint64
+	TransferHost string
+}
+
 type StorageDB struct {
-	db *sql.DB
+	db        *sql.DB
+	changeLog *DealChangeLogDB
 }
 
 func NewStorageDB(db *sql.DB) *StorageDB {
 	return &StorageDB{db: db}
 }
 
+// SetChangeLog sets a change log that each tag and untag is appended to
+func (s *StorageDB) SetChangeLog(cl *DealChangeLogDB) {
+	s.changeLog = cl
+}
+
 func (s *StorageDB) Tag(ctx context.Context, dealUuid uuid.UUID, size uint64, host string) error {
-	qry := "INSERT INTO StorageTagged (DealUUID, CreatedAt, TransferSize, TransferHost) "
-	qry += "VALUES (?, ?, ?, ?)"
-	values := []interface{}{dealUuid, time.Now(), fmt.Sprintf("%d", size), host}
-	_, err := s.db.ExecContext(ctx, qry, values...)
-	return err
+	return s.InsertTag(ctx, &StorageTagged{DealUUID: dealUuid, CreatedAt: time.Now(), TransferSize: size, TransferHost: host})
+}
+
+// InsertTag tags storage for a deal, keeping the time at which the tag was
+// created (eg when applying a tag replicated from another node)
+func (s *StorageDB) InsertTag(ctx context.Context, tag *StorageTagged) error {
+	change := &DealChange{Kind: DealChangeKindStorageTagged, DealUUID: tag.DealUUID, StorageTagged: tag}
+	return writeWithChange(ctx, s.db, s.changeLog, change, func(exec execer) error {
+		qry := "INSERT INTO StorageTagged (DealUUID, CreatedAt, TransferSize, TransferHost) "
+		qry += "VALUES (?, ?, ?, ?)"
+		values := []interface{}{tag.DealUUID, tag.CreatedAt, fmt.Sprintf("%d", tag.TransferSize), tag.TransferHost}
+		_, err := exec.ExecContext(ctx, qry, values...)
+		return err
+	})
 }
 
 func (s *StorageDB) Untag(ctx context.Context, dealUuid uuid.UUID) (uint64, error) {
@@ -51,7 +74,11 @@ func (s *StorageDB) Untag(ctx context.Context, dealUuid uuid.UUID) (uint64, erro
 		return 0, fmt.Errorf("unmarshalling untagged TransferSize")
 	}
 
-	_, err = s.db.ExecContext(ctx, "DELETE FROM StorageTagged WHERE DealUUID = ?", dealUuid)
+	change := &DealChange{Kind: DealChangeKindStorageUntagged, DealUUID: dealUuid}
+	err = writeWithChange(ctx, s.db, s.changeLog, change, func(exec execer) error {
+		_, err := exec.ExecContext(ctx, "DELETE FROM StorageTagged WHERE DealUUID = ?", dealUuid)
+		return err
+	})
 	return (*ps.F).Uint64(), err
 }
 
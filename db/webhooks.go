@@ -0,0 +1,173 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/boost/db/fielddef"
+)
+
+// Webhook is an endpoint that audit events are POSTed to
+type Webhook struct {
+	ID        uint64
+	CreatedAt time.Time
+	URL       string
+	// If set, used to sign the body of each request to the webhook
+	Secret string
+	// The types of audit event to send to the webhook (all types if empty)
+	EventTypes []string
+	// The sequence number of the last audit event delivered to the webhook
+	LastSeq uint64
+	// The error from the last failed delivery, cleared on success
+	LastError string
+}
+
+// Matches returns true if events of the given type should be sent to the
+// webhook
+func (w *Webhook) Matches(evtType string) bool {
+	if len(w.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range w.EventTypes {
+		if t == evtType {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhooksDB struct {
+	db     *sql.DB
+	cipher fielddef.Cipher
+}
+
+func NewWebhooksDB(db *sql.DB) *WebhooksDB {
+	return &WebhooksDB{db: db}
+}
+
+// NewEncryptedWebhooksDB returns a WebhooksDB that encrypts webhook secrets
+// with the given cipher before writing them to the database.
+// Secrets that were written without encryption can still be read.
+func NewEncryptedWebhooksDB(db *sql.DB, c fielddef.Cipher) *WebhooksDB {
+	return &WebhooksDB{db: db, cipher: c}
+}
+
+func (w *WebhooksDB) Insert(ctx context.Context, wh *Webhook) error {
+	if wh.CreatedAt.IsZero() {
+		wh.CreatedAt = time.Now()
+	}
+	secret, err := w.marshallSecret(wh.Secret)
+	if err != nil {
+		return err
+	}
+	qry := "INSERT INTO Webhooks (CreatedAt, URL, Secret, EventTypes, LastSeq, LastError) VALUES (?, ?, ?, ?, ?, ?)"
+	res, err := w.db.ExecContext(ctx, qry, wh.CreatedAt, wh.URL, secret, strings.Join(wh.EventTypes, ","), wh.LastSeq, wh.LastError)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	wh.ID = uint64(id)
+	return nil
+}
+
+// Delete removes the webhook with the given ID.
+// Returns ErrNotFound if there is no such webhook.
+func (w *WebhooksDB) Delete(ctx context.Context, id uint64) error {
+	res, err := w.db.ExecContext(ctx, "DELETE FROM Webhooks WHERE ID = ?", id)
+	if err != nil {
+		return err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ByID returns ErrNotFound if there is no webhook with the given ID
+func (w *WebhooksDB) ByID(ctx context.Context, id uint64) (*Webhook, error) {
+	row := w.db.QueryRowContext(ctx, "SELECT ID, CreatedAt, URL, Secret, EventTypes, LastSeq, LastError FROM Webhooks WHERE ID = ?", id)
+	wh, err := w.scanWebhook(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return wh, err
+}
+
+// List returns all webhooks in order of creation
+func (w *WebhooksDB) List(ctx context.Context) ([]Webhook, error) {
+	rows, err := w.db.QueryContext(ctx, "SELECT ID, CreatedAt, URL, Secret, EventTypes, LastSeq, LastError FROM Webhooks ORDER BY ID")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var whs []Webhook
+	for rows.Next() {
+		wh, err := w.scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		whs = append(whs, *wh)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return whs, nil
+}
+
+// SetDelivered records the sequence number of the last audit event that was
+// delivered to the webhook, and the error from the last delivery attempt
+func (w *WebhooksDB) SetDelivered(ctx context.Context, id uint64, lastSeq uint64, lastErr string) error {
+	_, err := w.db.ExecContext(ctx, "UPDATE Webhooks SET LastSeq = ?, LastError = ? WHERE ID = ?", lastSeq, lastErr, id)
+	return err
+}
+
+// marshallSecret returns the value to store in the Secret column: the
+// secret is encrypted if the database has a cipher
+func (w *WebhooksDB) marshallSecret(secret string) (interface{}, error) {
+	if w.cipher == nil || secret == "" {
+		return secret, nil
+	}
+	bz := []byte(secret)
+	fd := &fielddef.EncryptedFieldDef{Cipher: w.cipher, Field: &fielddef.FieldDef{F: &bz}}
+	return fd.Marshall()
+}
+
+func (w *WebhooksDB) scanWebhook(row Scannable) (*Webhook, error) {
+	var wh Webhook
+	var secret []byte
+	var evtTypes string
+	err := row.Scan(&wh.ID, &wh.CreatedAt, &wh.URL, &secret, &evtTypes, &wh.LastSeq, &wh.LastError)
+	if err != nil {
+		return nil, fmt.Errorf("getting webhook: %w", err)
+	}
+
+	if w.cipher != nil {
+		var plaintext []byte
+		fd := &fielddef.EncryptedFieldDef{Marshalled: secret, Cipher: w.cipher, Field: &fielddef.FieldDef{F: &plaintext}}
+		if err := fd.Unmarshall(); err != nil {
+			return nil, fmt.Errorf("getting webhook %d secret: %w", wh.ID, err)
+		}
+		secret = plaintext
+	} else if bytes.HasPrefix(secret, fielddef.EncryptedPrefix) {
+		return nil, fmt.Errorf("getting webhook %d: secret is encrypted but encryption is not enabled", wh.ID)
+	}
+	wh.Secret = string(secret)
+
+	if evtTypes != "" {
+		wh.EventTypes = strings.Split(evtTypes, ",")
+	}
+	return &wh, nil
+}
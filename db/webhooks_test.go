@@ -0,0 +1,99 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/filecoin-project/boost/db/fielddef"
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhooksDB(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateAllBoostTables(ctx, sqldb, sqldb))
+	req.NoError(migrations.Migrate(sqldb))
+
+	wdb := NewWebhooksDB(sqldb)
+
+	wh1 := &Webhook{URL: "http://localhost:1234/a", LastSeq: 5}
+	req.NoError(wdb.Insert(ctx, wh1))
+	wh2 := &Webhook{URL: "http://localhost:1234/b", Secret: "secret", EventTypes: []string{AuditEventAccepted, AuditEventRejected}}
+	req.NoError(wdb.Insert(ctx, wh2))
+	req.NotEqual(wh1.ID, wh2.ID)
+
+	whs, err := wdb.List(ctx)
+	req.NoError(err)
+	req.Len(whs, 2)
+	req.Equal(wh1.URL, whs[0].URL)
+	req.Equal(uint64(5), whs[0].LastSeq)
+	req.Empty(whs[0].EventTypes)
+	req.True(whs[0].Matches(AuditEventCheckpoint))
+	req.Equal([]string{AuditEventAccepted, AuditEventRejected}, whs[1].EventTypes)
+	req.True(whs[1].Matches(AuditEventRejected))
+	req.False(whs[1].Matches(AuditEventCheckpoint))
+
+	req.NoError(wdb.SetDelivered(ctx, wh2.ID, 10, "connection refused"))
+	got, err := wdb.ByID(ctx, wh2.ID)
+	req.NoError(err)
+	req.Equal(uint64(10), got.LastSeq)
+	req.Equal("connection refused", got.LastError)
+	req.Equal("secret", got.Secret)
+
+	req.NoError(wdb.Delete(ctx, wh1.ID))
+	req.ErrorIs(wdb.Delete(ctx, wh1.ID), ErrNotFound)
+	_, err = wdb.ByID(ctx, wh1.ID)
+	req.ErrorIs(err, ErrNotFound)
+}
+
+func TestEncryptedWebhooksDB(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := CreateTestTmpDB(t)
+	req.NoError(CreateAllBoostTables(ctx, sqldb, sqldb))
+	req.NoError(migrations.Migrate(sqldb))
+
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	req.NoError(err)
+	c, err := NewAESCipher(key)
+	req.NoError(err)
+
+	// Insert one webhook without encryption and one with
+	plainDB := NewWebhooksDB(sqldb)
+	encDB := NewEncryptedWebhooksDB(sqldb, c)
+	plain := &Webhook{URL: "http://localhost:1234/a", Secret: "plain-secret"}
+	req.NoError(plainDB.Insert(ctx, plain))
+	enc := &Webhook{URL: "http://localhost:1234/b", Secret: "enc-secret"}
+	req.NoError(encDB.Insert(ctx, enc))
+	none := &Webhook{URL: "http://localhost:1234/c"}
+	req.NoError(encDB.Insert(ctx, none))
+
+	// The encrypted secret should not be stored in plaintext
+	var secret []byte
+	req.NoError(sqldb.QueryRowContext(ctx, "SELECT Secret FROM Webhooks WHERE ID = ?", enc.ID).Scan(&secret))
+	req.True(bytes.HasPrefix(secret, fielddef.EncryptedPrefix))
+	req.NotContains(string(secret), enc.Secret)
+
+	// The encrypted DB should be able to read all the webhooks
+	whs, err := encDB.List(ctx)
+	req.NoError(err)
+	req.Len(whs, 3)
+	req.Equal(plain.Secret, whs[0].Secret)
+	req.Equal(enc.Secret, whs[1].Secret)
+	req.Empty(whs[2].Secret)
+
+	// A DB without the key should fail to read the encrypted secret
+	_, err = plainDB.ByID(ctx, enc.ID)
+	req.Error(err)
+	wc, err := NewAESCipher(make([]byte, 32))
+	req.NoError(err)
+	_, err = NewEncryptedWebhooksDB(sqldb, wc).ByID(ctx, enc.ID)
+	req.Error(err)
+}
@@ -0,0 +1,256 @@
+package dealbook
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	smtypes "github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+// The source of a deal
+const (
+	// A deal made with boost
+	SourceBoost = "boost"
+	// A deal made with the legacy (go-fil-markets) provider
+	SourceLegacy = "legacy"
+)
+
+// Deal is a boost deal or a legacy deal, with the fields that both kinds
+// of deal have in common
+type Deal struct {
+	// The source of the deal: boost or legacy
+	Source string
+	// The deal UUID for boost deals, or the signed proposal CID for legacy
+	// deals
+	ID            string
+	CreatedAt     time.Time
+	ClientAddress string
+	PieceCid      string
+	PieceSize     abi.PaddedPieceSize
+	IsVerified    bool
+	ChainDealID   abi.DealID
+	SectorID      abi.SectorNumber
+	// The checkpoint of a boost deal, or the state of a legacy deal
+	Status string
+	// The error message of a boost deal, or the message of a legacy deal
+	Message string
+}
+
+// Filter filters the deals in a listing. Empty fields match all deals.
+type Filter struct {
+	// Only list deals from this source (boost or legacy)
+	Source string
+	// Search for deals with an ID, piece CID, client address etc that
+	// matches the query
+	Query         string
+	ClientAddress string
+	PieceCid      string
+	IsVerified    *bool
+	// Matches boost deals at this checkpoint. Legacy deals don't have a
+	// checkpoint, so they never match.
+	Checkpoint string
+	// Matches deals whose error message contains this string
+	ErrContains string
+	// Matches deals created at or after this time
+	CreatedAfter time.Time
+	// Matches deals created before this time
+	CreatedBefore time.Time
+}
+
+// Page is a page of deals, newest first
+type Page struct {
+	// The number of deals that match the filter
+	TotalCount int
+	// Whether there are more deals after this page
+	More  bool
+	Deals []Deal
+}
+
+// legacyDeals lists the deals made with the legacy (go-fil-markets) provider
+type legacyDeals interface {
+	ListLocalDeals() ([]storagemarket.MinerDeal, error)
+}
+
+// List gets a page of boost and legacy deals that match the filter, newest
+// first, so that the whole deal book can be listed in one place while
+// deals are transitioning from the legacy provider to boost.
+// legacy may be nil if there is no legacy provider.
+func List(ctx context.Context, dealsDB *db.DealsDB, legacy legacyDeals, filter Filter, offset int, limit int) (*Page, error) {
+	if filter.Source != "" && filter.Source != SourceBoost && filter.Source != SourceLegacy {
+		return nil, fmt.Errorf("unrecognized deal source '%s': must be %s or %s", filter.Source, SourceBoost, SourceLegacy)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	// The deals on the page may come from either source, so get enough
+	// deals from each source to fill every page up to this one
+	var boostDeals []Deal
+	var boostCount int
+	if filter.Source != SourceLegacy {
+		opts := filter.boostFilterOptions()
+		var err error
+		boostCount, err = dealsDB.Count(ctx, filter.Query, opts)
+		if err != nil {
+			return nil, fmt.Errorf("getting boost deal count: %w", err)
+		}
+		deals, err := dealsDB.List(ctx, filter.Query, opts, nil, 0, offset+limit)
+		if err != nil {
+			return nil, fmt.Errorf("listing boost deals: %w", err)
+		}
+		boostDeals = make([]Deal, 0, len(deals))
+		for _, dl := range deals {
+			boostDeals = append(boostDeals, fromBoostDeal(dl))
+		}
+	}
+
+	var legacyMatches []Deal
+	if filter.Source != SourceBoost && legacy != nil {
+		deals, err := legacy.ListLocalDeals()
+		if err != nil {
+			return nil, fmt.Errorf("listing legacy deals: %w", err)
+		}
+		for _, dl := range deals {
+			if filter.matchesLegacy(dl) {
+				legacyMatches = append(legacyMatches, fromLegacyDeal(dl))
+			}
+		}
+		sort.SliceStable(legacyMatches, func(i, j int) bool {
+			return legacyMatches[i].CreatedAt.After(legacyMatches[j].CreatedAt)
+		})
+	}
+
+	total := boostCount + len(legacyMatches)
+	merged := merge(boostDeals, legacyMatches, offset+limit)
+	if offset > len(merged) {
+		offset = len(merged)
+	}
+
+	return &Page{
+		TotalCount: total,
+		More:       offset+limit < total,
+		Deals:      merged[offset:],
+	}, nil
+}
+
+// merge merges two lists of deals that are sorted newest first, up to the
+// given number of deals
+func merge(a []Deal, b []Deal, max int) []Deal {
+	merged := make([]Deal, 0, max)
+	for len(merged) < max && (len(a) > 0 || len(b) > 0) {
+		if len(b) == 0 || (len(a) > 0 && !b[0].CreatedAt.After(a[0].CreatedAt)) {
+			merged = append(merged, a[0])
+			a = a[1:]
+		} else {
+			merged = append(merged, b[0])
+			b = b[1:]
+		}
+	}
+	return merged
+}
+
+func (f *Filter) boostFilterOptions() *db.FilterOptions {
+	opts := &db.FilterOptions{IsVerified: f.IsVerified}
+	if f.Checkpoint != "" {
+		opts.Checkpoint = &f.Checkpoint
+	}
+	if f.ClientAddress != "" {
+		opts.ClientAddress = &f.ClientAddress
+	}
+	if f.PieceCid != "" {
+		opts.PieceCID = &f.PieceCid
+	}
+	if f.ErrContains != "" {
+		opts.ErrContains = &f.ErrContains
+	}
+	if !f.CreatedAfter.IsZero() {
+		opts.CreatedAfter = &f.CreatedAfter
+	}
+	if !f.CreatedBefore.IsZero() {
+		opts.CreatedBefore = &f.CreatedBefore
+	}
+	return opts
+}
+
+// matchesLegacy applies the filter to a legacy deal, in the same way that
+// the filter is applied to boost deals in the database
+func (f *Filter) matchesLegacy(dl storagemarket.MinerDeal) bool {
+	prop := dl.Proposal
+	switch {
+	case f.Checkpoint != "":
+		return false
+	case f.ClientAddress != "" && prop.Client.String() != f.ClientAddress:
+		return false
+	case f.PieceCid != "" && prop.PieceCID.String() != f.PieceCid:
+		return false
+	case f.IsVerified != nil && prop.VerifiedDeal != *f.IsVerified:
+		return false
+	case f.ErrContains != "" && !strings.Contains(dl.Message, f.ErrContains):
+		return false
+	case !f.CreatedAfter.IsZero() && dl.CreationTime.Time().Before(f.CreatedAfter):
+		return false
+	case !f.CreatedBefore.IsZero() && !dl.CreationTime.Time().Before(f.CreatedBefore):
+		return false
+	}
+
+	query := strings.Trim(f.Query, " \t\n")
+	if query == "" {
+		return true
+	}
+	fields := []string{dl.ProposalCid.String(), prop.PieceCID.String(), prop.Client.String(), prop.Provider.String(), dl.Client.String()}
+	if dl.Ref != nil {
+		fields = append(fields, dl.Ref.Root.String())
+	}
+	if dl.PublishCid != nil {
+		fields = append(fields, dl.PublishCid.String())
+	}
+	if label, err := prop.Label.ToString(); err == nil {
+		fields = append(fields, label)
+	}
+	for _, field := range fields {
+		if field == query {
+			return true
+		}
+	}
+	return strings.Contains(dl.Message, query)
+}
+
+func fromBoostDeal(dl *smtypes.ProviderDealState) Deal {
+	prop := dl.ClientDealProposal.Proposal
+	return Deal{
+		Source:        SourceBoost,
+		ID:            dl.DealUuid.String(),
+		CreatedAt:     dl.CreatedAt,
+		ClientAddress: prop.Client.String(),
+		PieceCid:      prop.PieceCID.String(),
+		PieceSize:     prop.PieceSize,
+		IsVerified:    prop.VerifiedDeal,
+		ChainDealID:   dl.ChainDealID,
+		SectorID:      dl.SectorID,
+		Status:        dl.Checkpoint.String(),
+		Message:       dl.Err,
+	}
+}
+
+func fromLegacyDeal(dl storagemarket.MinerDeal) Deal {
+	prop := dl.Proposal
+	return Deal{
+		Source:        SourceLegacy,
+		ID:            dl.ProposalCid.String(),
+		CreatedAt:     dl.CreationTime.Time(),
+		ClientAddress: prop.Client.String(),
+		PieceCid:      prop.PieceCID.String(),
+		PieceSize:     prop.PieceSize,
+		IsVerified:    prop.VerifiedDeal,
+		ChainDealID:   dl.DealID,
+		SectorID:      dl.SectorNumber,
+		Status:        storagemarket.DealStates[dl.State],
+		Message:       dl.Message,
+	}
+}
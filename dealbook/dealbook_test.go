@@ -0,0 +1,123 @@
+package dealbook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+type mockLegacy struct {
+	deals []storagemarket.MinerDeal
+}
+
+func (m *mockLegacy) ListLocalDeals() ([]storagemarket.MinerDeal, error) {
+	return m.deals, nil
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+
+	sqldb := db.CreateTestTmpDB(t)
+	require.NoError(t, db.CreateAllBoostTables(ctx, sqldb, sqldb))
+	require.NoError(t, migrations.Migrate(sqldb))
+	dealsDB := db.NewDealsDB(sqldb)
+
+	// Boost deals created at 1, 3 and 5 hours ago, and legacy deals created
+	// at 2, 4 and 6 hours ago
+	now := time.Now().Truncate(time.Second)
+	deals, err := db.GenerateNDeals(3)
+	require.NoError(t, err)
+	for i := range deals {
+		deals[i].CreatedAt = now.Add(-time.Duration(2*i+1) * time.Hour)
+		require.NoError(t, dealsDB.Insert(ctx, &deals[i]))
+	}
+
+	clientAddr, err := address.NewIDAddress(1234)
+	require.NoError(t, err)
+	legacy := &mockLegacy{}
+	for i := 0; i < 3; i++ {
+		legacy.deals = append(legacy.deals, storagemarket.MinerDeal{
+			ClientDealProposal: market.ClientDealProposal{
+				Proposal: market.DealProposal{
+					PieceCID:     testutil.GenerateCid(),
+					PieceSize:    2048,
+					VerifiedDeal: i == 0,
+					Client:       clientAddr,
+				},
+			},
+			ProposalCid:  testutil.GenerateCid(),
+			State:        storagemarket.StorageDealActive,
+			CreationTime: cbg.CborTime(now.Add(-time.Duration(2*i+2) * time.Hour)),
+		})
+	}
+
+	// The deals from both sources are merged newest first
+	page, err := List(ctx, dealsDB, legacy, Filter{}, 0, 4)
+	require.NoError(t, err)
+	require.Equal(t, 6, page.TotalCount)
+	require.True(t, page.More)
+	require.Len(t, page.Deals, 4)
+	require.Equal(t, []string{SourceBoost, SourceLegacy, SourceBoost, SourceLegacy}, sources(page.Deals))
+	require.Equal(t, deals[0].DealUuid.String(), page.Deals[0].ID)
+	require.Equal(t, legacy.deals[0].ProposalCid.String(), page.Deals[1].ID)
+	require.Equal(t, "StorageDealActive", page.Deals[1].Status)
+
+	// The last page
+	page, err = List(ctx, dealsDB, legacy, Filter{}, 4, 4)
+	require.NoError(t, err)
+	require.False(t, page.More)
+	require.Equal(t, []string{SourceBoost, SourceLegacy}, sources(page.Deals))
+
+	// Filter by source
+	page, err = List(ctx, dealsDB, legacy, Filter{Source: SourceLegacy}, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, 3, page.TotalCount)
+	require.Equal(t, []string{SourceLegacy, SourceLegacy, SourceLegacy}, sources(page.Deals))
+
+	_, err = List(ctx, dealsDB, legacy, Filter{Source: "other"}, 0, 10)
+	require.Error(t, err)
+
+	// Filters apply to the deals from both sources
+	verified := true
+	page, err = List(ctx, dealsDB, legacy, Filter{IsVerified: &verified}, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, page.TotalCount)
+	require.Equal(t, legacy.deals[0].ProposalCid.String(), page.Deals[0].ID)
+
+	page, err = List(ctx, dealsDB, legacy, Filter{CreatedBefore: now.Add(-3 * time.Hour)}, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, 3, page.TotalCount)
+
+	page, err = List(ctx, dealsDB, legacy, Filter{Query: legacy.deals[2].Proposal.PieceCID.String()}, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, page.TotalCount)
+	require.Equal(t, legacy.deals[2].ProposalCid.String(), page.Deals[0].ID)
+
+	// Legacy deals don't have a checkpoint
+	page, err = List(ctx, dealsDB, legacy, Filter{Checkpoint: deals[0].Checkpoint.String()}, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, 3, page.TotalCount)
+	require.Equal(t, []string{SourceBoost, SourceBoost, SourceBoost}, sources(page.Deals))
+
+	// There may not be a legacy provider
+	page, err = List(ctx, dealsDB, nil, Filter{}, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, 3, page.TotalCount)
+}
+
+func sources(deals []Deal) []string {
+	srcs := make([]string, 0, len(deals))
+	for _, dl := range deals {
+		srcs = append(srcs, dl.Source)
+	}
+	return srcs
+}
@@ -0,0 +1,264 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/retrievalmarket/rtvlevents"
+	"github.com/ipfs/go-datastore"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("eventbus")
+
+// SchemaVersion is the version of the Message schema. It's incremented when
+// a field is removed or changes meaning (but not when a field is added).
+const SchemaVersion = 1
+
+// The kinds of message
+const (
+	// A deal lifecycle event: a checkpoint transition, the decision to
+	// accept or reject a deal proposal, an admin override, or an alert
+	KindDeal = "deal"
+	// A retrieval event: started, bytes-delivered, completed or failed
+	KindRetrieval = "retrieval"
+)
+
+// Message is the schema of each message published to the event bus. Each
+// message is JSON encoded, eg:
+//
+//	{
+//	  "SchemaVersion": 1,
+//	  "Kind": "deal",
+//	  "Deal": {
+//	    "Seq": 42,
+//	    "CreatedAt": "2023-02-01T10:00:00Z",
+//	    "DealUUID": "07070707-0707-0707-0707-070707070707",
+//	    "Type": "checkpoint",
+//	    "Actor": "system",
+//	    "FromCheckpoint": "Transferred",
+//	    "ToCheckpoint": "Published",
+//	    "Rule": "",
+//	    "Detail": ""
+//	  }
+//	}
+//
+// Deal messages are published in the order of the audit log (by Seq), and
+// are keyed by deal uuid. Retrieval messages are keyed by retrieval ID (or
+// client ID for retrievals without an ID).
+type Message struct {
+	SchemaVersion int
+	// KindDeal or KindRetrieval
+	Kind      string
+	Deal      *db.AuditEvent    `json:",omitempty"`
+	Retrieval *rtvlevents.Event `json:",omitempty"`
+}
+
+// Key is the key of the message, so that the events of a deal or
+// retrieval are kept in order when a topic is partitioned
+func (m *Message) Key() string {
+	switch {
+	case m.Deal != nil:
+		return m.Deal.DealUUID.String()
+	case m.Retrieval != nil && m.Retrieval.RetrievalID != "":
+		return m.Retrieval.RetrievalID
+	case m.Retrieval != nil:
+		return m.Retrieval.ClientID
+	default:
+		return ""
+	}
+}
+
+// Sink publishes messages to a topic (a Kafka topic or a NATS subject)
+type Sink interface {
+	Publish(ctx context.Context, topic string, msgs []Message) error
+	Close() error
+}
+
+// Config configures the topics that events are published to
+type Config struct {
+	DealTopic      string
+	RetrievalTopic string
+	// How often to check the audit log for new deal events
+	PollInterval time.Duration
+}
+
+const (
+	// The maximum number of deal events to publish in one batch
+	batchSize = 100
+	// The maximum time to wait before retrying after a publish fails
+	maxBackoff = 5 * time.Minute
+)
+
+// The datastore key of the sequence number of the last deal event that
+// was published
+var lastSeqKey = datastore.NewKey("/eventbus/deals/last-seq")
+
+// Bus tails the audit log and publishes each deal lifecycle event to the
+// deal topic, and publishes retrieval events to the retrieval topic (see
+// RetrievalSink). The position in the audit log is saved after each batch,
+// so publishing resumes where it left off after a failure or a restart: deal
+// events are delivered at least once.
+// Retrieval events are not persisted, so they are delivered at most once
+// (see rtvlevents.Publisher): they are dropped if the in-memory queue of
+// 10,000 events is full, or if the sink fails 3 times in a row.
+type Bus struct {
+	cfg     Config
+	sink    Sink
+	auditDB *db.AuditLogDB
+	ds      datastore.Datastore
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New(cfg Config, sink Sink, auditDB *db.AuditLogDB, ds datastore.Datastore) *Bus {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	return &Bus{cfg: cfg, sink: sink, auditDB: auditDB, ds: ds}
+}
+
+func (b *Bus) Start(ctx context.Context) error {
+	// The first time the bus starts, only publish events that occur from
+	// now on
+	lastSeq, err := b.lastSeq(ctx)
+	if errors.Is(err, datastore.ErrNotFound) {
+		lastSeq, err = b.auditDB.LatestSeq(ctx)
+		if err == nil {
+			err = b.saveLastSeq(ctx, lastSeq)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("getting event bus position in audit log: %w", err)
+	}
+
+	log.Infow("starting event bus", "sink", b.sink, "deal topic", b.cfg.DealTopic,
+		"retrieval topic", b.cfg.RetrievalTopic, "audit log seq", lastSeq)
+
+	ctx, b.cancel = context.WithCancel(ctx)
+	b.wg.Add(1)
+	go b.run(ctx, lastSeq)
+	return nil
+}
+
+func (b *Bus) Stop() error {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.wg.Wait()
+	return b.sink.Close()
+}
+
+func (b *Bus) run(ctx context.Context, lastSeq uint64) {
+	defer b.wg.Done()
+
+	wait := b.cfg.PollInterval
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		seq, err := b.publishDealEvents(ctx, lastSeq)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			// Back off exponentially while the sink is failing
+			wait *= 2
+			if wait > maxBackoff {
+				wait = maxBackoff
+			}
+			log.Warnw("failed to publish deal events", "sink", b.sink, "retry in", wait, "err", err)
+			continue
+		}
+		wait = b.cfg.PollInterval
+		lastSeq = seq
+	}
+}
+
+// publishDealEvents publishes the deal events after lastSeq, and returns
+// the sequence number of the last event that was published
+func (b *Bus) publishDealEvents(ctx context.Context, lastSeq uint64) (uint64, error) {
+	for {
+		evts, err := b.auditDB.Since(ctx, lastSeq, batchSize)
+		if err != nil {
+			return lastSeq, fmt.Errorf("getting audit events: %w", err)
+		}
+		if len(evts) == 0 {
+			return lastSeq, nil
+		}
+
+		msgs := make([]Message, 0, len(evts))
+		for i := range evts {
+			msgs = append(msgs, Message{SchemaVersion: SchemaVersion, Kind: KindDeal, Deal: &evts[i]})
+		}
+		if err := b.sink.Publish(ctx, b.cfg.DealTopic, msgs); err != nil {
+			return lastSeq, err
+		}
+
+		lastSeq = evts[len(evts)-1].Seq
+		if err := b.saveLastSeq(ctx, lastSeq); err != nil {
+			return lastSeq, fmt.Errorf("saving event bus position in audit log: %w", err)
+		}
+	}
+}
+
+func (b *Bus) lastSeq(ctx context.Context) (uint64, error) {
+	bz, err := b.ds.Get(ctx, lastSeqKey)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(string(bz), 10, 64)
+}
+
+func (b *Bus) saveLastSeq(ctx context.Context, seq uint64) error {
+	return b.ds.Put(ctx, lastSeqKey, []byte(strconv.FormatUint(seq, 10)))
+}
+
+// RetrievalSink returns a sink for the retrieval events publisher that
+// publishes retrieval events to the retrieval topic
+func (b *Bus) RetrievalSink() rtvlevents.Sink {
+	return &retrievalSink{bus: b}
+}
+
+type retrievalSink struct {
+	bus *Bus
+}
+
+func (s *retrievalSink) String() string {
+	return fmt.Sprintf("event bus %s %s", s.bus.sink, s.bus.cfg.RetrievalTopic)
+}
+
+func (s *retrievalSink) Send(ctx context.Context, evts []rtvlevents.Event) error {
+	msgs := make([]Message, 0, len(evts))
+	for i := range evts {
+		msgs = append(msgs, Message{SchemaVersion: SchemaVersion, Kind: KindRetrieval, Retrieval: &evts[i]})
+	}
+	return s.bus.sink.Publish(ctx, s.bus.cfg.RetrievalTopic, msgs)
+}
+
+// The bus closes the underlying sink when it's stopped
+func (s *retrievalSink) Close() error {
+	return nil
+}
+
+func marshalMessages(msgs []Message) ([][]byte, error) {
+	bzs := make([][]byte, 0, len(msgs))
+	for i := range msgs {
+		bz, err := json.Marshal(&msgs[i])
+		if err != nil {
+			return nil, fmt.Errorf("marshalling %s event: %w", msgs[i].Kind, err)
+		}
+		bzs = append(bzs, bz)
+	}
+	return bzs, nil
+}
@@ -0,0 +1,164 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/filecoin-project/boost/retrievalmarket/rtvlevents"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-datastore"
+	dss "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+)
+
+type mockSink struct {
+	lk   sync.Mutex
+	err  error
+	msgs map[string][]Message
+}
+
+func (s *mockSink) Publish(_ context.Context, topic string, msgs []Message) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if s.msgs == nil {
+		s.msgs = make(map[string][]Message)
+	}
+	s.msgs[topic] = append(s.msgs[topic], msgs...)
+	return nil
+}
+
+func (s *mockSink) Close() error {
+	return nil
+}
+
+func TestBusDealEvents(t *testing.T) {
+	ctx := context.Background()
+
+	sqldb := db.CreateTestTmpDB(t)
+	require.NoError(t, db.CreateAllBoostTables(ctx, sqldb, sqldb))
+	require.NoError(t, migrations.Migrate(sqldb))
+	auditDB := db.NewAuditLogDB(sqldb)
+	ds := dss.MutexWrap(datastore.NewMapDatastore())
+
+	dealUuid := uuid.New()
+	checkpoint := func(from, to string) {
+		require.NoError(t, auditDB.Insert(ctx, &db.AuditEvent{
+			CreatedAt:      time.Now(),
+			DealUUID:       dealUuid,
+			Type:           db.AuditEventCheckpoint,
+			FromCheckpoint: from,
+			ToCheckpoint:   to,
+		}))
+	}
+
+	// Events that occur before the bus first starts are not published
+	checkpoint("Accepted", "Transferred")
+
+	sink := &mockSink{}
+	cfg := Config{DealTopic: "deals", RetrievalTopic: "retrievals", PollInterval: time.Hour}
+	bus := New(cfg, sink, auditDB, ds)
+	require.NoError(t, bus.Start(ctx))
+	require.NoError(t, bus.Stop())
+
+	lastSeq, err := bus.lastSeq(ctx)
+	require.NoError(t, err)
+	seq, err := bus.publishDealEvents(ctx, lastSeq)
+	require.NoError(t, err)
+	require.Equal(t, lastSeq, seq)
+	require.Empty(t, sink.msgs["deals"])
+
+	// Events are not lost while the sink is failing
+	checkpoint("Transferred", "Published")
+	sink.err = errors.New("connection refused")
+	seq, err = bus.publishDealEvents(ctx, lastSeq)
+	require.Error(t, err)
+	require.Equal(t, lastSeq, seq)
+
+	sink.err = nil
+	checkpoint("Published", "PublishConfirmed")
+	seq, err = bus.publishDealEvents(ctx, lastSeq)
+	require.NoError(t, err)
+	require.Equal(t, lastSeq+2, seq)
+
+	msgs := sink.msgs["deals"]
+	require.Len(t, msgs, 2)
+	require.Equal(t, SchemaVersion, msgs[0].SchemaVersion)
+	require.Equal(t, KindDeal, msgs[0].Kind)
+	require.Equal(t, "Published", msgs[0].Deal.ToCheckpoint)
+	require.Equal(t, "PublishConfirmed", msgs[1].Deal.ToCheckpoint)
+	require.Equal(t, dealUuid.String(), msgs[1].Key())
+
+	// The position in the audit log is saved, so a restarted bus carries on
+	// from where it left off
+	bus = New(cfg, sink, auditDB, ds)
+	lastSeq, err = bus.lastSeq(ctx)
+	require.NoError(t, err)
+	require.Equal(t, seq, lastSeq)
+
+	// Retrieval events are published to the retrieval topic
+	err = bus.RetrievalSink().Send(ctx, []rtvlevents.Event{{Type: "started", RetrievalID: "1234", ClientID: "client"}})
+	require.NoError(t, err)
+	require.Len(t, sink.msgs["retrievals"], 1)
+	require.Equal(t, KindRetrieval, sink.msgs["retrievals"][0].Kind)
+	require.Equal(t, "1234", sink.msgs["retrievals"][0].Key())
+}
+
+func TestKafkaSink(t *testing.T) {
+	ctx := context.Background()
+
+	var reqs []kafkaProduceRequest
+	var fail bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/topics/boost.events.deals", r.URL.Path)
+		require.Equal(t, kafkaJSONContentType, r.Header.Get("Content-Type"))
+		user, pass, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "user", user)
+		require.Equal(t, "pass", pass)
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req kafkaProduceRequest
+		require.NoError(t, json.Unmarshal(body, &req))
+		reqs = append(reqs, req)
+
+		if fail {
+			_, _ = w.Write([]byte(`{"offsets":[{"partition":null,"offset":null,"error_code":2,"error":"topic is not writable"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"offsets":[{"partition":0,"offset":10,"error_code":null,"error":null}]}`))
+	}))
+	defer srv.Close()
+
+	sink, err := NewKafkaSink("http://user:pass@" + srv.Listener.Addr().String())
+	require.NoError(t, err)
+
+	dealUuid := uuid.New()
+	msg := Message{SchemaVersion: SchemaVersion, Kind: KindDeal, Deal: &db.AuditEvent{DealUUID: dealUuid, Type: db.AuditEventCheckpoint}}
+	require.NoError(t, sink.Publish(ctx, "boost.events.deals", []Message{msg}))
+	require.Len(t, reqs, 1)
+	require.Len(t, reqs[0].Records, 1)
+	require.Equal(t, dealUuid.String(), reqs[0].Records[0].Key)
+
+	var value Message
+	require.NoError(t, json.Unmarshal(reqs[0].Records[0].Value, &value))
+	require.Equal(t, KindDeal, value.Kind)
+	require.Equal(t, dealUuid, value.Deal.DealUUID)
+	require.Nil(t, value.Retrieval)
+
+	// The proxy reports an error for a record
+	fail = true
+	require.ErrorContains(t, sink.Publish(ctx, "boost.events.deals", []Message{msg}), "topic is not writable")
+}
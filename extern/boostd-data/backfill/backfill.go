@@ -0,0 +1,295 @@
+package backfill
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/filecoin-project/boost/cmd/boostd-data/model"
+	"github.com/filecoin-project/boost/cmd/boostd-data/svc/types"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipld/go-car/v2/index"
+	"github.com/multiformats/go-multihash"
+)
+
+var log = logging.Logger("boostd-data-backfill")
+
+type Config struct {
+	// The number of pieces to index in parallel
+	Workers int
+	// The file that progress is recorded in, so that a backfill that was
+	// interrupted can be resumed. If empty, progress is not recorded.
+	CheckpointPath string
+}
+
+// Result counts the pieces processed by Backfill
+type Result struct {
+	// The number of pieces that were indexed
+	Indexed int
+	// The number of pieces that were already indexed, or that were indexed
+	// by an earlier run of the backfill
+	Skipped int
+	// The number of pieces that could not be indexed
+	Failed int
+}
+
+// checkpointEntry is a line in the checkpoint file, recording the outcome
+// of indexing a piece
+type checkpointEntry struct {
+	PieceCid string
+	Error    string `json:",omitempty"`
+}
+
+// Backfill builds the index for each piece found by the sources, and adds
+// it to the store, along with the piece's deals.
+// Pieces that are already indexed in the store are not re-indexed (but any
+// missing deals are added), and pieces that the checkpoint file records as
+// done are skipped.
+// If a piece can be found by more than one source, the first source is
+// used.
+func Backfill(ctx context.Context, store types.ServiceImpl, sources []Source, cfg Config) (*Result, error) {
+	workers := cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	done, err := loadCheckpoint(cfg.CheckpointPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var ckpt *checkpointWriter
+	if cfg.CheckpointPath != "" {
+		ckpt, err = openCheckpoint(cfg.CheckpointPath)
+		if err != nil {
+			return nil, err
+		}
+		defer ckpt.close()
+	}
+
+	// Find the pieces that need to be indexed
+	res := &Result{}
+	seen := make(map[cid.Cid]struct{})
+	var todo []Piece
+	for _, src := range sources {
+		pieces, err := src.Pieces(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range pieces {
+			if _, ok := seen[p.PieceCid]; ok {
+				continue
+			}
+			seen[p.PieceCid] = struct{}{}
+
+			if _, ok := done[p.PieceCid]; ok {
+				res.Skipped++
+				continue
+			}
+
+			indexedAt, err := store.IndexedAt(p.PieceCid)
+			if err != nil {
+				return nil, fmt.Errorf("checking if piece %s is indexed: %w", p.PieceCid, err)
+			}
+			if !indexedAt.IsZero() {
+				if err := addMissingDeals(store, p); err != nil {
+					return nil, err
+				}
+				res.Skipped++
+				continue
+			}
+
+			todo = append(todo, p)
+		}
+	}
+
+	log.Infow("backfilling piece indexes", "pieces", len(todo), "skipped", res.Skipped, "workers", workers)
+
+	var lk sync.Mutex
+	var ckptErr error
+	queue := make(chan Piece)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for p := range queue {
+				err := indexPiece(store, p)
+
+				lk.Lock()
+				entry := checkpointEntry{PieceCid: p.PieceCid.String()}
+				if err != nil {
+					res.Failed++
+					entry.Error = err.Error()
+					log.Warnw("failed to index piece", "piece-cid", p.PieceCid, "location", p.Location, "err", err)
+				} else {
+					res.Indexed++
+				}
+				if ckpt != nil && ckptErr == nil {
+					ckptErr = ckpt.write(entry)
+				}
+				processed := res.Indexed + res.Failed
+				lk.Unlock()
+
+				log.Infow("backfilled piece", "piece-cid", p.PieceCid, "progress", fmt.Sprintf("%d/%d", processed, len(todo)))
+			}
+		}()
+	}
+
+feed:
+	for _, p := range todo {
+		select {
+		case <-ctx.Done():
+			break feed
+		case queue <- p:
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if ckptErr != nil {
+		return res, fmt.Errorf("writing checkpoint: %w", ckptErr)
+	}
+	return res, ctx.Err()
+}
+
+func indexPiece(store types.ServiceImpl, p Piece) error {
+	idx, err := p.index()
+	if err != nil {
+		return fmt.Errorf("generating index from %s: %w", p.Location, err)
+	}
+
+	itidx, ok := idx.(index.IterableIndex)
+	if !ok {
+		return fmt.Errorf("index for %s is not iterable", p.Location)
+	}
+
+	var records []model.Record
+	err = itidx.ForEach(func(m multihash.Multihash, offset uint64) error {
+		records = append(records, model.Record{
+			Cid:    cid.NewCidV1(cid.Raw, m),
+			Offset: offset,
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("iterating over index for %s: %w", p.Location, err)
+	}
+
+	if err := store.AddIndex(p.PieceCid, records); err != nil {
+		return fmt.Errorf("adding index: %w", err)
+	}
+	return addMissingDeals(store, p)
+}
+
+// addMissingDeals adds the piece's deals that are not already in the store
+func addMissingDeals(store types.ServiceImpl, p Piece) error {
+	if len(p.Deals) == 0 {
+		return nil
+	}
+
+	existing, err := store.GetPieceDeals(p.PieceCid)
+	if err != nil {
+		return fmt.Errorf("getting deals for piece %s: %w", p.PieceCid, err)
+	}
+	have := make(map[uuid.UUID]struct{}, len(existing))
+	for _, d := range existing {
+		have[d.DealUuid] = struct{}{}
+	}
+
+	for _, d := range p.Deals {
+		if _, ok := have[d.DealUuid]; ok {
+			continue
+		}
+		if err := store.AddDealForPiece(p.PieceCid, d); err != nil {
+			return fmt.Errorf("adding deal %s for piece %s: %w", d.DealUuid, p.PieceCid, err)
+		}
+		have[d.DealUuid] = struct{}{}
+	}
+	return nil
+}
+
+// loadCheckpoint returns the pieces that the checkpoint file records as
+// indexed. Pieces that failed are retried.
+func loadCheckpoint(path string) (map[cid.Cid]struct{}, error) {
+	done := make(map[cid.Cid]struct{})
+	if path == "" {
+		return done, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return done, nil
+		}
+		return nil, fmt.Errorf("opening checkpoint file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry checkpointEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// The last line may be incomplete if the backfill was killed
+			// while writing it
+			log.Warnw("skipping invalid line in checkpoint file", "line", scanner.Text(), "err", err)
+			continue
+		}
+		pieceCid, err := cid.Parse(entry.PieceCid)
+		if err != nil {
+			log.Warnw("skipping invalid piece cid in checkpoint file", "piece-cid", entry.PieceCid, "err", err)
+			continue
+		}
+
+		// the last entry for a piece wins
+		if entry.Error == "" {
+			done[pieceCid] = struct{}{}
+		} else {
+			delete(done, pieceCid)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading checkpoint file: %w", err)
+	}
+
+	return done, nil
+}
+
+// checkpointWriter appends an entry to the checkpoint file for each piece
+// that is processed
+type checkpointWriter struct {
+	f *os.File
+}
+
+func openCheckpoint(path string) (*checkpointWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening checkpoint file: %w", err)
+	}
+	return &checkpointWriter{f: f}, nil
+}
+
+func (c *checkpointWriter) write(entry checkpointEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := c.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return c.f.Sync()
+}
+
+func (c *checkpointWriter) close() {
+	if err := c.f.Close(); err != nil {
+		log.Warnw("closing checkpoint file", "err", err)
+	}
+}
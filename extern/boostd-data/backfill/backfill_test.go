@@ -0,0 +1,209 @@
+package backfill
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/boost/cmd/boostd-data/ldb"
+	"github.com/filecoin-project/boost/cmd/boostd-data/model"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/google/uuid"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car/v2/blockstore"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+const testPieceCid = "baga6ea4seaqnfhocd544oidrgsss2ahoaomvxuaqxfmlsizljtzsuivjl5hamka"
+
+// padChunk is the inverse of unpadChunk
+func padChunk(out []byte, in []byte) {
+	var acc uint16
+	var bits uint
+	i := 0
+	for o := range out {
+		n := uint(8)
+		if o%32 == 31 {
+			n = 6
+		}
+		for bits < n {
+			acc |= uint16(in[i]) << bits
+			i++
+			bits += 8
+		}
+		out[o] = byte(acc) & byte(1<<n-1)
+		acc >>= n
+		bits -= n
+	}
+}
+
+func pad(in []byte) []byte {
+	out := make([]byte, len(in)/unpaddedChunkSize*paddedChunkSize)
+	for c := 0; c < len(in)/unpaddedChunkSize; c++ {
+		padChunk(out[c*paddedChunkSize:(c+1)*paddedChunkSize], in[c*unpaddedChunkSize:(c+1)*unpaddedChunkSize])
+	}
+	return out
+}
+
+func TestUnpadReader(t *testing.T) {
+	data := make([]byte, 4*unpaddedChunkSize)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	padded := pad(data)
+	for i := 31; i < len(padded); i += 32 {
+		require.Zero(t, padded[i]&0xc0, "the two most significant bits of each word must be zero")
+	}
+
+	var out bytes.Buffer
+	_, err := out.ReadFrom(newUnpadReader(bytes.NewReader(padded)))
+	require.NoError(t, err)
+	require.Equal(t, data, out.Bytes())
+}
+
+// createCar writes a CARv1 file with the given number of random blocks,
+// and returns the cids of the blocks
+func createCar(t *testing.T, path string, count int) []cid.Cid {
+	rnd := rand.New(rand.NewSource(2))
+	var blks []blocks.Block
+	for i := 0; i < count; i++ {
+		data := make([]byte, 100+rnd.Intn(100))
+		rnd.Read(data)
+		mh, err := multihash.Sum(data, multihash.SHA2_256, -1)
+		require.NoError(t, err)
+		blk, err := blocks.NewBlockWithCid(data, cid.NewCidV1(cid.Raw, mh))
+		require.NoError(t, err)
+		blks = append(blks, blk)
+	}
+
+	bs, err := blockstore.OpenReadWrite(path, []cid.Cid{blks[0].Cid()}, blockstore.WriteAsCarV1(true))
+	require.NoError(t, err)
+	var cids []cid.Cid
+	for _, blk := range blks {
+		require.NoError(t, bs.Put(context.Background(), blk))
+		cids = append(cids, blk.Cid())
+	}
+	require.NoError(t, bs.Finalize())
+	return cids
+}
+
+func TestBackfillCarDir(t *testing.T) {
+	ctx := context.Background()
+	pieceCid, err := cid.Parse(testPieceCid)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	cids := createCar(t, filepath.Join(dir, pieceCid.String()+".car"), 10)
+	// files that are not named by piece cid are ignored
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hello"), 0644))
+
+	store := ldb.NewStore("")
+	ckptPath := filepath.Join(t.TempDir(), "backfill.jsonl")
+	cfg := Config{Workers: 2, CheckpointPath: ckptPath}
+	sources := []Source{&CarDirSource{Dir: dir}}
+
+	res, err := Backfill(ctx, store, sources, cfg)
+	require.NoError(t, err)
+	require.Equal(t, Result{Indexed: 1}, *res)
+
+	records, err := store.GetRecords(pieceCid)
+	require.NoError(t, err)
+	require.Len(t, records, len(cids))
+	for _, c := range cids {
+		_, err := store.GetOffset(pieceCid, c.Hash())
+		require.NoError(t, err)
+	}
+
+	// The piece is already indexed in the store, so it's skipped
+	res, err = Backfill(ctx, store, sources, cfg)
+	require.NoError(t, err)
+	require.Equal(t, Result{Skipped: 1}, *res)
+
+	// The checkpoint records that the piece was indexed, so it's skipped
+	// even with a store that doesn't have the index
+	res, err = Backfill(ctx, ldb.NewStore(""), sources, cfg)
+	require.NoError(t, err)
+	require.Equal(t, Result{Skipped: 1}, *res)
+}
+
+func TestBackfillUnsealedSector(t *testing.T) {
+	ctx := context.Background()
+	pieceCid, err := cid.Parse(testPieceCid)
+	require.NoError(t, err)
+
+	carPath := filepath.Join(t.TempDir(), "piece.car")
+	cids := createCar(t, carPath, 10)
+	carBytes, err := os.ReadFile(carPath)
+	require.NoError(t, err)
+
+	// Write the zero-padded piece into a sector file, after some other data
+	pieceLen := abi.PaddedPieceSize(256)
+	for pieceLen.Unpadded() < abi.UnpaddedPieceSize(len(carBytes)) {
+		pieceLen *= 2
+	}
+	pieceOffset := abi.PaddedPieceSize(2048)
+	unpadded := make([]byte, pieceLen.Unpadded())
+	copy(unpadded, carBytes)
+	sector := append(make([]byte, pieceOffset), pad(unpadded)...)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "s-t01000-7"), sector, 0644))
+
+	// The deal for the piece gives its location in the sector
+	dealsFile := filepath.Join(t.TempDir(), "deals.jsonl")
+	deal := PieceDeal{
+		PieceCid: pieceCid.String(),
+		DealInfo: model.DealInfo{
+			DealUuid:    uuid.New(),
+			SectorID:    7,
+			PieceOffset: pieceOffset,
+			PieceLength: pieceLen,
+		},
+	}
+	b, err := json.Marshal(deal)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(dealsFile, append(b, '\n'), 0644))
+	deals, err := LoadDealsFile(dealsFile)
+	require.NoError(t, err)
+	require.Equal(t, []PieceDeal{deal}, deals)
+
+	store := ldb.NewStore("")
+	sources := []Source{&UnsealedSectorSource{Dir: dir, Deals: deals}}
+	res, err := Backfill(ctx, store, sources, Config{Workers: 1})
+	require.NoError(t, err)
+	require.Equal(t, Result{Indexed: 1}, *res)
+
+	// The deal is added to the store along with the index
+	dis, err := store.GetPieceDeals(pieceCid)
+	require.NoError(t, err)
+	require.Equal(t, []model.DealInfo{deal.DealInfo}, dis)
+
+	// Running the backfill again doesn't duplicate the deal
+	res, err = Backfill(ctx, store, sources, Config{Workers: 1})
+	require.NoError(t, err)
+	require.Equal(t, Result{Skipped: 1}, *res)
+	dis, err = store.GetPieceDeals(pieceCid)
+	require.NoError(t, err)
+	require.Len(t, dis, 1)
+
+	// The offsets are the same as those in the CAR file
+	cdStore := ldb.NewStore("")
+	carDir := t.TempDir()
+	require.NoError(t, os.Rename(carPath, filepath.Join(carDir, pieceCid.String())))
+	_, err = Backfill(ctx, cdStore, []Source{&CarDirSource{Dir: carDir}}, Config{})
+	require.NoError(t, err)
+
+	for _, c := range cids {
+		offset, err := store.GetOffset(pieceCid, c.Hash())
+		require.NoError(t, err)
+		expected, err := cdStore.GetOffset(pieceCid, c.Hash())
+		require.NoError(t, err)
+		require.Equal(t, expected, offset, fmt.Sprintf("offset of %s", c))
+	}
+}
@@ -0,0 +1,69 @@
+package backfill
+
+import (
+	"io"
+)
+
+// Unsealed sector files store each piece in fr32 padded form: every 32 byte
+// word holds 254 bits of data, with the two most significant bits set to
+// zero. So 128 padded bytes hold 127 bytes of data.
+const (
+	paddedChunkSize   = 128
+	unpaddedChunkSize = 127
+)
+
+// unpadReader reads fr32 padded data from the underlying reader and
+// returns the unpadded data
+type unpadReader struct {
+	r      io.Reader
+	padded [paddedChunkSize]byte
+	buf    [unpaddedChunkSize]byte
+	// the unread part of buf
+	unread []byte
+}
+
+func newUnpadReader(r io.Reader) *unpadReader {
+	return &unpadReader{r: r}
+}
+
+func (u *unpadReader) Read(p []byte) (int, error) {
+	if len(u.unread) == 0 {
+		if _, err := io.ReadFull(u.r, u.padded[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		unpadChunk(u.buf[:], u.padded[:])
+		u.unread = u.buf[:]
+	}
+
+	n := copy(p, u.unread)
+	u.unread = u.unread[n:]
+	return n, nil
+}
+
+// unpadChunk unpads one 128 byte chunk of fr32 padded data into 127 bytes.
+// The data is a stream of bits, least significant bit first, of which the
+// last two bits of each 256 bit word are padding.
+func unpadChunk(out []byte, in []byte) {
+	var acc uint16
+	var bits uint
+	o := 0
+	for i, b := range in {
+		n := uint(8)
+		if i%32 == 31 {
+			// skip the two padding bits
+			n = 6
+			b &= 0x3f
+		}
+		acc |= uint16(b) << bits
+		bits += n
+		if bits >= 8 {
+			out[o] = byte(acc)
+			o++
+			acc >>= 8
+			bits -= 8
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/filecoin-project/boost/cmd/boostd-data/model"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	carv2 "github.com/ipld/go-car/v2"
+	"github.com/ipld/go-car/v2/index"
+	"github.com/multiformats/go-multicodec"
+)
+
+// Piece is a piece that a Source can build an index for
+type Piece struct {
+	PieceCid cid.Cid
+	// Where the piece data is read from
+	Location string
+	// The deals to add to the store for the piece once it's indexed
+	Deals []model.DealInfo
+
+	index func() (index.Index, error)
+}
+
+// PieceDeal is a deal for a piece, as listed in a deals file
+type PieceDeal struct {
+	PieceCid string `json:"piece_cid"`
+	model.DealInfo
+}
+
+// LoadDealsFile reads a file with one PieceDeal in JSON format on each line
+func LoadDealsFile(path string) ([]PieceDeal, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening deals file: %w", err)
+	}
+	defer f.Close()
+
+	var deals []PieceDeal
+	dec := json.NewDecoder(f)
+	for {
+		var d PieceDeal
+		if err := dec.Decode(&d); err != nil {
+			if err == io.EOF {
+				return deals, nil
+			}
+			return nil, fmt.Errorf("reading deal %d from deals file: %w", len(deals)+1, err)
+		}
+		deals = append(deals, d)
+	}
+}
+
+// dealsByPiece groups the deals by piece cid
+func dealsByPiece(deals []PieceDeal) (map[cid.Cid][]model.DealInfo, []cid.Cid, error) {
+	byPiece := make(map[cid.Cid][]model.DealInfo)
+	var pieceCids []cid.Cid
+	for _, d := range deals {
+		pieceCid, err := cid.Parse(d.PieceCid)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing piece cid %s for deal %s: %w", d.PieceCid, d.DealUuid, err)
+		}
+		if _, ok := byPiece[pieceCid]; !ok {
+			pieceCids = append(pieceCids, pieceCid)
+		}
+		byPiece[pieceCid] = append(byPiece[pieceCid], d.DealInfo)
+	}
+	return byPiece, pieceCids, nil
+}
+
+// Source finds the pieces to be indexed
+type Source interface {
+	Pieces(ctx context.Context) ([]Piece, error)
+}
+
+// CarDirSource finds CAR files in a directory (and its sub-directories).
+// The name of each CAR file must be the piece CID, optionally followed by
+// an extension, eg baga6ea4sea....car
+type CarDirSource struct {
+	Dir string
+	// The deals to add to the store for the pieces that are found
+	// (optional)
+	Deals []PieceDeal
+}
+
+var _ Source = (*CarDirSource)(nil)
+
+func (s *CarDirSource) Pieces(ctx context.Context) ([]Piece, error) {
+	deals, _, err := dealsByPiece(s.Deals)
+	if err != nil {
+		return nil, err
+	}
+
+	var pieces []Piece
+	err = filepath.WalkDir(s.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		name := strings.SplitN(d.Name(), ".", 2)[0]
+		pieceCid, err := cid.Parse(name)
+		if err != nil {
+			log.Debugw("skipping file: name is not a piece cid", "path", path)
+			return nil
+		}
+
+		pieces = append(pieces, Piece{
+			PieceCid: pieceCid,
+			Location: path,
+			Deals:    deals[pieceCid],
+			index: func() (index.Index, error) {
+				f, err := os.Open(path)
+				if err != nil {
+					return nil, err
+				}
+				defer f.Close()
+
+				return carv2.ReadOrGenerateIndex(f, carv2.UseIndexCodec(multicodec.CarMultihashIndexSorted))
+			},
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking CAR directory %s: %w", s.Dir, err)
+	}
+
+	return pieces, nil
+}
+
+// UnsealedSectorSource reads pieces from the unsealed sector files in a
+// lotus miner's unsealed directory.
+// The location of each piece in a sector is taken from the piece's deals,
+// so only pieces with a deal in an unsealed sector are found.
+type UnsealedSectorSource struct {
+	Dir   string
+	Deals []PieceDeal
+}
+
+var _ Source = (*UnsealedSectorSource)(nil)
+
+// Unsealed sector files are named s-<miner address>-<sector number>
+var unsealedFileRegexp = regexp.MustCompile(`^s-t0\d+-(\d+)$`)
+
+func (s *UnsealedSectorSource) Pieces(ctx context.Context) ([]Piece, error) {
+	sectors := make(map[abi.SectorNumber]string)
+	err := filepath.WalkDir(s.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		m := unsealedFileRegexp.FindStringSubmatch(d.Name())
+		if m == nil {
+			return nil
+		}
+		sectorID, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			return nil
+		}
+		sectors[abi.SectorNumber(sectorID)] = path
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking unsealed sector directory %s: %w", s.Dir, err)
+	}
+
+	byPiece, pieceCids, err := dealsByPiece(s.Deals)
+	if err != nil {
+		return nil, err
+	}
+
+	var pieces []Piece
+	for _, pieceCid := range pieceCids {
+		deals := byPiece[pieceCid]
+
+		// Read the piece from the first deal that is in an unsealed sector
+		found := false
+		for _, dl := range deals {
+			path, ok := sectors[dl.SectorID]
+			if !ok {
+				continue
+			}
+
+			dl := dl
+			pieces = append(pieces, Piece{
+				PieceCid: pieceCid,
+				Location: fmt.Sprintf("%s@%d", path, dl.PieceOffset),
+				Deals:    deals,
+				index: func() (index.Index, error) {
+					f, err := os.Open(path)
+					if err != nil {
+						return nil, err
+					}
+					defer f.Close()
+
+					// The data in the sector file is padded, so the padded
+					// piece offset and length give its location in the file
+					if _, err := f.Seek(int64(dl.PieceOffset), io.SeekStart); err != nil {
+						return nil, fmt.Errorf("seeking to piece offset %d: %w", dl.PieceOffset, err)
+					}
+					var r io.Reader = newUnpadReader(io.LimitReader(f, int64(dl.PieceLength)))
+					if dl.CarLength > 0 {
+						r = io.LimitReader(r, int64(dl.CarLength))
+					}
+
+					// The piece is zero-padded after the end of the CAR data
+					return carv2.GenerateIndex(r,
+						carv2.UseIndexCodec(multicodec.CarMultihashIndexSorted),
+						carv2.ZeroLengthSectionAsEOF(true))
+				},
+			})
+			found = true
+			break
+		}
+		if !found {
+			log.Warnw("no unsealed sector found for piece", "piece-cid", pieceCid)
+		}
+	}
+
+	return pieces, nil
+}
@@ -114,6 +114,16 @@ func (s *Store) IndexedAt(pieceCid cid.Cid) (time.Time, error) {
 	return ts, nil
 }
 
+func (s *Store) ListPieces() ([]cid.Cid, error) {
+	var resp []cid.Cid
+	err := s.client.Call(&resp, "boostddata_listPieces")
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
 func (s *Store) GetOffset(pieceCid cid.Cid, hash mh.Multihash) (uint64, error) {
 	var resp uint64
 	err := s.client.Call(&resp, "boostddata_getOffset", pieceCid, hash)
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/filecoin-project/boost/cmd/boostd-data/model"
+	"github.com/filecoin-project/boost/cmd/boostd-data/svc/types"
 	"github.com/ipfs/go-cid"
 	ds "github.com/ipfs/go-datastore"
 	logging "github.com/ipfs/go-log/v2"
@@ -20,6 +21,8 @@ import (
 
 var log = logging.Logger("boostd-data-cb")
 
+var _ types.ServiceImpl = (*Store)(nil)
+
 type Store struct {
 	sync.Mutex
 	db *DB
@@ -228,3 +231,7 @@ func (s *Store) IndexedAt(pieceCid cid.Cid) (time.Time, error) {
 
 	return md.IndexedAt, nil
 }
+
+func (s *Store) ListPieces() ([]cid.Cid, error) {
+	return nil, errors.New("not impl")
+}
@@ -0,0 +1,365 @@
+//go:build foundationdb
+
+package foundationdb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/directory"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/filecoin-project/boost/cmd/boostd-data/model"
+	"github.com/filecoin-project/boost/cmd/boostd-data/svc/types"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	logging "github.com/ipfs/go-log/v2"
+	mh "github.com/multiformats/go-multihash"
+)
+
+var log = logging.Logger("boostd-data-fdb")
+
+var _ types.ServiceImpl = (*Store)(nil)
+
+const (
+	// The FoundationDB client API version
+	apiVersion = 710
+
+	// FoundationDB limits the size of a transaction to 10MB, so large
+	// indexes are written (and read) in batches of records
+	batchSize = 10000
+)
+
+// Store keeps the piece directory in FoundationDB, in three subspaces:
+// pieces:  (piece cid) -> metadata
+// offsets: (piece cid, multihash) -> offset
+// mhs:     (multihash, piece cid) -> empty
+type Store struct {
+	db      fdb.Database
+	pieces  subspace.Subspace
+	offsets subspace.Subspace
+	mhs     subspace.Subspace
+}
+
+// NewStore connects to the FoundationDB cluster in the default cluster
+// file (which can be set with the FDB_CLUSTER_FILE environment variable)
+func NewStore() (*Store, error) {
+	return NewStoreInDirectory([]string{"boostd-data"})
+}
+
+// NewStoreInDirectory connects to the FoundationDB cluster in the default
+// cluster file, and keeps the piece directory in the given directory path
+// (eg so that the piece directories of several boost nodes can share a
+// cluster)
+func NewStoreInDirectory(path []string) (*Store, error) {
+	if err := fdb.APIVersion(apiVersion); err != nil {
+		// The API version can only be set once per process, so ignore the
+		// error if it has already been set to the same version
+		if v, verr := fdb.GetAPIVersion(); verr != nil || v != apiVersion {
+			return nil, fmt.Errorf("setting FoundationDB API version %d: %w", apiVersion, err)
+		}
+	}
+
+	db, err := fdb.OpenDefault()
+	if err != nil {
+		return nil, fmt.Errorf("opening FoundationDB database: %w", err)
+	}
+
+	dir, err := directory.CreateOrOpen(db, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("opening FoundationDB directory %v: %w", path, err)
+	}
+
+	return &Store{
+		db:      db,
+		pieces:  dir.Sub("pieces"),
+		offsets: dir.Sub("offsets"),
+		mhs:     dir.Sub("mhs"),
+	}, nil
+}
+
+func (s *Store) AddDealForPiece(pieceCid cid.Cid, dealInfo model.DealInfo) error {
+	log.Debugw("handle.add-deal-for-piece", "piece-cid", pieceCid)
+
+	defer func(now time.Time) {
+		log.Debugw("handled.add-deal-for-piece", "took", fmt.Sprintf("%s", time.Since(now)))
+	}(time.Now())
+
+	_, err := s.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		md, err := s.getMetadata(tr, pieceCid)
+		if err != nil {
+			return nil, err
+		}
+
+		md.Deals = append(md.Deals, dealInfo)
+
+		return nil, s.setMetadata(tr, pieceCid, md)
+	})
+	return err
+}
+
+func (s *Store) GetRecords(pieceCid cid.Cid) ([]model.Record, error) {
+	log.Debugw("handle.get-iterable-index", "piece-cid", pieceCid)
+
+	defer func(now time.Time) {
+		log.Debugw("handled.get-iterable-index", "took", fmt.Sprintf("%s", time.Since(now)))
+	}(time.Now())
+
+	if _, err := s.readMetadata(pieceCid); err != nil {
+		return nil, err
+	}
+
+	var records []model.Record
+	err := s.readRange(s.offsets.Sub(pieceCid.Bytes()), func(kv fdb.KeyValue) error {
+		k, err := s.offsets.Unpack(kv.Key)
+		if err != nil {
+			return err
+		}
+		m, err := mh.Cast(k[1].([]byte))
+		if err != nil {
+			return err
+		}
+
+		offset, _ := binary.Uvarint(kv.Value)
+		records = append(records, model.Record{
+			Cid:    cid.NewCidV1(cid.Raw, m),
+			Offset: offset,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (s *Store) GetOffset(pieceCid cid.Cid, hash mh.Multihash) (uint64, error) {
+	log.Debugw("handle.get-offset", "piece-cid", pieceCid)
+
+	defer func(now time.Time) {
+		log.Debugw("handled.get-offset", "took", fmt.Sprintf("%s", time.Since(now)))
+	}(time.Now())
+
+	v, err := s.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return rtr.Get(s.offsets.Pack(tuple.Tuple{pieceCid.Bytes(), []byte(hash)})).Get()
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	b := v.([]byte)
+	if b == nil {
+		return 0, ds.ErrNotFound
+	}
+
+	offset, _ := binary.Uvarint(b)
+	return offset, nil
+}
+
+func (s *Store) GetPieceDeals(pieceCid cid.Cid) ([]model.DealInfo, error) {
+	log.Debugw("handle.get-piece-deals", "piece-cid", pieceCid)
+
+	defer func(now time.Time) {
+		log.Debugw("handled.get-piece-deals", "took", fmt.Sprintf("%s", time.Since(now)))
+	}(time.Now())
+
+	md, err := s.readMetadata(pieceCid)
+	if err != nil {
+		return nil, err
+	}
+
+	return md.Deals, nil
+}
+
+// Get all pieces that contain a multihash (used when retrieving by payload CID)
+func (s *Store) PiecesContainingMultihash(m mh.Multihash) ([]cid.Cid, error) {
+	log.Debugw("handle.pieces-containing-mh", "mh", m)
+
+	defer func(now time.Time) {
+		log.Debugw("handled.pieces-containing-mh", "took", fmt.Sprintf("%s", time.Since(now)))
+	}(time.Now())
+
+	var pcids []cid.Cid
+	err := s.readRange(s.mhs.Sub([]byte(m)), func(kv fdb.KeyValue) error {
+		k, err := s.mhs.Unpack(kv.Key)
+		if err != nil {
+			return err
+		}
+		_, pieceCid, err := cid.CidFromBytes(k[1].([]byte))
+		if err != nil {
+			return err
+		}
+		pcids = append(pcids, pieceCid)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pcids) == 0 {
+		return nil, fmt.Errorf("failed to get value for multihash %s, err: %w", m, ds.ErrNotFound)
+	}
+
+	return pcids, nil
+}
+
+func (s *Store) GetIndex(pieceCid cid.Cid) ([]model.Record, error) {
+	return s.GetRecords(pieceCid)
+}
+
+func (s *Store) AddIndex(pieceCid cid.Cid, records []model.Record) error {
+	log.Debugw("handle.add-index", "records", len(records))
+
+	defer func(now time.Time) {
+		log.Debugw("handled.add-index", "took", fmt.Sprintf("%s", time.Since(now)))
+	}(time.Now())
+
+	for start := 0; start < len(records); start += batchSize {
+		end := start + batchSize
+		if end > len(records) {
+			end = len(records)
+		}
+
+		_, err := s.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			for _, r := range records[start:end] {
+				m := []byte(r.Cid.Hash())
+
+				value := make([]byte, binary.MaxVarintLen64)
+				n := binary.PutUvarint(value, r.Offset)
+
+				tr.Set(s.offsets.Pack(tuple.Tuple{pieceCid.Bytes(), m}), value[:n])
+				tr.Set(s.mhs.Pack(tuple.Tuple{m, pieceCid.Bytes()}), []byte{})
+			}
+			return nil, nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add records %d-%d: %w", start, end, err)
+		}
+	}
+
+	// mark that indexing is complete, keeping any deals that were already
+	// added for the piece
+	_, err := s.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		md, err := s.getMetadata(tr, pieceCid)
+		if err != nil && !errors.Is(err, ds.ErrNotFound) {
+			return nil, err
+		}
+
+		md.IndexedAt = time.Now()
+
+		return nil, s.setMetadata(tr, pieceCid, md)
+	})
+	return err
+}
+
+func (s *Store) IndexedAt(pieceCid cid.Cid) (time.Time, error) {
+	log.Debugw("handle.indexed-at", "pieceCid", pieceCid)
+
+	defer func(now time.Time) {
+		log.Debugw("handled.indexed-at", "took", fmt.Sprintf("%s", time.Since(now)))
+	}(time.Now())
+
+	md, err := s.readMetadata(pieceCid)
+	if err != nil && !errors.Is(err, ds.ErrNotFound) {
+		return time.Time{}, err
+	}
+
+	return md.IndexedAt, nil
+}
+
+func (s *Store) ListPieces() ([]cid.Cid, error) {
+	log.Debugw("handle.list-pieces")
+
+	defer func(now time.Time) {
+		log.Debugw("handled.list-pieces", "took", fmt.Sprintf("%s", time.Since(now)))
+	}(time.Now())
+
+	var pieceCids []cid.Cid
+	err := s.readRange(s.pieces, func(kv fdb.KeyValue) error {
+		k, err := s.pieces.Unpack(kv.Key)
+		if err != nil {
+			return err
+		}
+		_, pieceCid, err := cid.CidFromBytes(k[0].([]byte))
+		if err != nil {
+			return err
+		}
+		pieceCids = append(pieceCids, pieceCid)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pieceCids, nil
+}
+
+func (s *Store) readMetadata(pieceCid cid.Cid) (model.Metadata, error) {
+	v, err := s.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return s.getMetadata(rtr, pieceCid)
+	})
+	if err != nil {
+		return model.Metadata{}, err
+	}
+	return v.(model.Metadata), nil
+}
+
+func (s *Store) getMetadata(rtr fdb.ReadTransaction, pieceCid cid.Cid) (model.Metadata, error) {
+	var md model.Metadata
+
+	b, err := rtr.Get(s.pieces.Pack(tuple.Tuple{pieceCid.Bytes()})).Get()
+	if err != nil {
+		return md, err
+	}
+	if b == nil {
+		return md, ds.ErrNotFound
+	}
+
+	err = json.Unmarshal(b, &md)
+	return md, err
+}
+
+func (s *Store) setMetadata(tr fdb.Transaction, pieceCid cid.Cid, md model.Metadata) error {
+	b, err := json.Marshal(md)
+	if err != nil {
+		return err
+	}
+
+	tr.Set(s.pieces.Pack(tuple.Tuple{pieceCid.Bytes()}), b)
+	return nil
+}
+
+// readRange calls cb for each key in the subspace. The keys are read in
+// batches, each in a separate transaction, so that reading a large range
+// doesn't exceed the FoundationDB transaction time limit.
+func (s *Store) readRange(ss subspace.Subspace, cb func(kv fdb.KeyValue) error) error {
+	begin, end := ss.FDBRangeKeys()
+	for {
+		v, err := s.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+			kr := fdb.KeyRange{Begin: begin, End: end}
+			return rtr.GetRange(kr, fdb.RangeOptions{Limit: batchSize}).GetSliceWithError()
+		})
+		if err != nil {
+			return err
+		}
+
+		kvs := v.([]fdb.KeyValue)
+		for _, kv := range kvs {
+			if err := cb(kv); err != nil {
+				return err
+			}
+		}
+		if len(kvs) < batchSize {
+			return nil
+		}
+
+		// continue from the key after the last key that was read
+		last := kvs[len(kvs)-1].Key
+		begin = fdb.Key(append(append([]byte{}, last...), 0x00))
+	}
+}
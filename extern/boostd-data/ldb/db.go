@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/filecoin-project/boost/cmd/boostd-data/model"
 	"github.com/ipfs/go-cid"
@@ -178,6 +179,96 @@ func (db *DB) SetMultihashesToPieceCid(ctx context.Context, recs []carindex.Reco
 	return nil
 }
 
+// RemoveMultihashesToPieceCid removes the piece cid from the list of pieces
+// for each of the multihashes
+func (db *DB) RemoveMultihashesToPieceCid(ctx context.Context, mhs []multihash.Multihash, pieceCid cid.Cid) error {
+	batch, err := db.Batch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create ds batch: %w", err)
+	}
+
+	for _, mh := range mhs {
+		key := datastore.NewKey(fmt.Sprintf("%s%s", sprefixMhtoPieceCids, mh.String()))
+
+		val, err := db.Get(ctx, key)
+		if err == ds.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get value for multihash %s, err: %w", mh, err)
+		}
+
+		var pcids []cid.Cid
+		if err := json.Unmarshal(val, &pcids); err != nil {
+			return fmt.Errorf("failed to unmarshal pieceCids slice: %w", err)
+		}
+
+		remaining := make([]cid.Cid, 0, len(pcids))
+		for _, c := range pcids {
+			if !c.Equals(pieceCid) {
+				remaining = append(remaining, c)
+			}
+		}
+
+		if len(remaining) == 0 {
+			if err := batch.Delete(ctx, key); err != nil {
+				return fmt.Errorf("failed to batch delete mh=%s, err=%w", mh, err)
+			}
+			continue
+		}
+
+		b, err := json.Marshal(remaining)
+		if err != nil {
+			return fmt.Errorf("failed to marshal pieceCids slice: %w", err)
+		}
+		if err := batch.Put(ctx, key, b); err != nil {
+			return fmt.Errorf("failed to batch put mh=%s, err=%w", mh, err)
+		}
+	}
+
+	if err := batch.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit batch: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveOffsets removes all the offsets under the cursor
+func (db *DB) RemoveOffsets(ctx context.Context, cursor uint64) error {
+	q := query.Query{Prefix: fmt.Sprintf("%d/", cursor), KeysOnly: true}
+	results, err := db.Query(ctx, q)
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	batch, err := db.Batch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create ds batch: %w", err)
+	}
+	for {
+		r, ok := results.NextSync()
+		if !ok {
+			break
+		}
+		if r.Error != nil {
+			return r.Error
+		}
+		if err := batch.Delete(ctx, datastore.NewKey(r.Key)); err != nil {
+			return fmt.Errorf("failed to batch delete offset %s: %w", r.Key, err)
+		}
+	}
+
+	return batch.Commit(ctx)
+}
+
+// RemovePieceCidToMetadata
+func (db *DB) RemovePieceCidToMetadata(ctx context.Context, pieceCid cid.Cid) error {
+	key := datastore.NewKey(fmt.Sprintf("%s%s", sprefixPieceCidToCursor, pieceCid.String()))
+
+	return db.Delete(ctx, key)
+}
+
 // SetPieceCidToMetadata
 func (db *DB) SetPieceCidToMetadata(ctx context.Context, pieceCid cid.Cid, md model.Metadata) error {
 	b, err := json.Marshal(md)
@@ -209,6 +300,45 @@ func (db *DB) GetPieceCidToMetadata(ctx context.Context, pieceCid cid.Cid) (mode
 	return metadata, nil
 }
 
+// ListPieceCids
+func (db *DB) ListPieceCids(ctx context.Context) ([]cid.Cid, error) {
+	// The piece cid keys can't be selected with a query prefix, because
+	// the key prefix isn't a path. Keys are returned in order, so instead
+	// iterate from the start and stop after the last piece cid key.
+	prefix := datastore.NewKey(sprefixPieceCidToCursor).String()
+	results, err := db.Query(ctx, query.Query{KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var pieceCids []cid.Cid
+	for {
+		r, ok := results.NextSync()
+		if !ok {
+			break
+		}
+		if r.Error != nil {
+			return nil, r.Error
+		}
+
+		if !strings.HasPrefix(r.Key, prefix) {
+			if r.Key > prefix {
+				break
+			}
+			continue
+		}
+
+		pieceCid, err := cid.Parse(r.Key[len(prefix):])
+		if err != nil {
+			return nil, fmt.Errorf("parsing piece cid from key %s: %w", r.Key, err)
+		}
+		pieceCids = append(pieceCids, pieceCid)
+	}
+
+	return pieceCids, nil
+}
+
 // AllRecords
 func (db *DB) AllRecords(ctx context.Context, cursor uint64) ([]model.Record, error) {
 	var records []model.Record
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/filecoin-project/boost/cmd/boostd-data/model"
+	"github.com/filecoin-project/boost/cmd/boostd-data/svc/types"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	ds "github.com/ipfs/go-datastore"
@@ -22,6 +23,8 @@ import (
 
 var log = logging.Logger("boostd-data-ldb")
 
+var _ types.ServiceImpl = (*Store)(nil)
+
 type Store struct {
 	sync.Mutex
 	db *DB
@@ -43,11 +46,12 @@ func NewStore(_repopath string) *Store {
 		panic(err)
 	}
 
-	// tests
-	if _repopath == "" {
-		// prepare db
+	// prepare a new db with the next cursor
+	if _, _, err := db.NextCursor(context.Background()); err == ds.ErrNotFound {
 		log.Debug("preparing db with next cursor")
-		db.SetNextCursor(context.Background(), 100)
+		if err := db.SetNextCursor(context.Background(), 100); err != nil {
+			panic(err)
+		}
 	}
 
 	log.Debugw("new piece meta service", "repo path", repopath)
@@ -290,3 +294,57 @@ func (s *Store) IndexedAt(pieceCid cid.Cid) (time.Time, error) {
 
 	return md.IndexedAt, nil
 }
+
+func (s *Store) ListPieces() ([]cid.Cid, error) {
+	log.Debugw("handle.list-pieces")
+
+	defer func(now time.Time) {
+		log.Debugw("handled.list-pieces", "took", fmt.Sprintf("%s", time.Since(now)))
+	}(time.Now())
+
+	s.Lock()
+	defer s.Unlock()
+
+	ctx := context.Background()
+	return s.db.ListPieceCids(ctx)
+}
+
+// RemovePiece removes the piece's deals and index
+func (s *Store) RemovePiece(pieceCid cid.Cid) error {
+	log.Debugw("handle.remove-piece", "pieceCid", pieceCid)
+
+	defer func(now time.Time) {
+		log.Debugw("handled.remove-piece", "took", fmt.Sprintf("%s", time.Since(now)))
+	}(time.Now())
+
+	s.Lock()
+	defer s.Unlock()
+
+	ctx := context.Background()
+
+	md, err := s.db.GetPieceCidToMetadata(ctx, pieceCid)
+	if err != nil {
+		return err
+	}
+
+	records, err := s.db.AllRecords(ctx, md.Cursor)
+	if err != nil {
+		return err
+	}
+	mhs := make([]mh.Multihash, 0, len(records))
+	for _, r := range records {
+		mhs = append(mhs, r.Cid.Hash())
+	}
+
+	if err := s.db.RemoveMultihashesToPieceCid(ctx, mhs, pieceCid); err != nil {
+		return fmt.Errorf("failed to remove entries from mh to pieceCid: %w", err)
+	}
+	if err := s.db.RemoveOffsets(ctx, md.Cursor); err != nil {
+		return fmt.Errorf("failed to remove offsets: %w", err)
+	}
+	if err := s.db.RemovePieceCidToMetadata(ctx, pieceCid); err != nil {
+		return err
+	}
+
+	return s.db.Sync(ctx, datastore.NewKey(""))
+}
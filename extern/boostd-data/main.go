@@ -3,13 +3,17 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/filecoin-project/boost/cmd/boostd-data/backfill"
 	"github.com/filecoin-project/boost/cmd/boostd-data/svc"
 	logging "github.com/ipfs/go-log/v2"
 )
@@ -18,19 +22,81 @@ var (
 	repopath string
 	db       string
 
+	migrateTo         string
+	migrateToRepopath string
+
+	exportPath string
+	importPath string
+
+	backfillCarDir      string
+	backfillUnsealedDir string
+	backfillDeals       string
+	backfillWorkers     int
+	backfillCheckpoint  string
+
+	rebalance       bool
+	rebalanceDryRun bool
+
 	log = logging.Logger("boostd-data")
 )
 
 func init() {
 	logging.SetLogLevel("*", "debug")
 
-	flag.StringVar(&db, "db", "", "db type for boostd-data (couchbase or ldb)")
-	flag.StringVar(&repopath, "repopath", "", "path for repo")
+	flag.StringVar(&db, "db", "ldb", "db type for boostd-data: "+strings.Join(svc.Backends(), ", ")+
+		" (ldb is embedded and doesn't need a separate database)")
+	flag.StringVar(&repopath, "repopath", "", "path for repo (for ldb-sharded, a list of directories separated by "+string(filepath.ListSeparator)+")")
+	flag.StringVar(&migrateTo, "migrate-to", "", "copy all pieces from the -db backend to this backend, then exit")
+	flag.StringVar(&migrateToRepopath, "migrate-to-repopath", "", "path for repo of the -migrate-to backend")
+	flag.StringVar(&exportPath, "export", "", "export the deals and index of all pieces in the -db backend to this archive file, then exit")
+	flag.StringVar(&importPath, "import", "", "import the pieces in this archive file (written by -export) into the -db backend, then exit")
+	flag.StringVar(&backfillCarDir, "backfill-car-dir", "", "index the CAR files in this directory, named by piece cid, then exit")
+	flag.StringVar(&backfillUnsealedDir, "backfill-unsealed-dir", "", "index the pieces in the unsealed sector files in this directory (requires -backfill-deals), then exit")
+	flag.StringVar(&backfillDeals, "backfill-deals", "", "file with the deals to add for backfilled pieces, one JSON object per line with piece_cid and the deal info fields")
+	flag.IntVar(&backfillWorkers, "backfill-workers", 4, "the number of pieces to index in parallel")
+	flag.StringVar(&backfillCheckpoint, "backfill-checkpoint", "", "file to record backfill progress in, so that it can be resumed (default backfill-checkpoint.jsonl in the repo)")
+	flag.BoolVar(&rebalance, "rebalance", false, "move ldb-sharded pieces into the directory they belong in after directories are added to or removed from -repopath, then exit")
+	flag.BoolVar(&rebalanceDryRun, "rebalance-dry-run", false, "with -rebalance, count the pieces that would be moved without moving them")
 }
 
 func main() {
 	flag.Parse()
 
+	if migrateTo != "" {
+		if err := migrate(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if exportPath != "" {
+		if err := exportArchive(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if importPath != "" {
+		if err := importArchive(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if rebalance {
+		if err := runRebalance(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if backfillCarDir != "" || backfillUnsealedDir != "" {
+		if err := runBackfill(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	done := make(chan struct{})
 
 	srv := svc.New(db, repopath)
@@ -70,3 +136,148 @@ func main() {
 
 	<-done
 }
+
+func migrate() error {
+	if migrateTo == db && migrateToRepopath == repopath {
+		return fmt.Errorf("cannot migrate: the source and destination are the same")
+	}
+
+	from, err := svc.NewService(db, repopath)
+	if err != nil {
+		return err
+	}
+	to, err := svc.NewService(migrateTo, migrateToRepopath)
+	if err != nil {
+		return err
+	}
+
+	log.Infow("migrating pieces", "from", db, "to", migrateTo)
+	res, err := svc.Migrate(from, to)
+	if err != nil {
+		return err
+	}
+
+	log.Infow("migration complete", "migrated", res.Migrated, "already-indexed", res.Skipped)
+	return nil
+}
+
+func exportArchive() error {
+	from, err := svc.NewService(db, repopath)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(exportPath)
+	if err != nil {
+		return fmt.Errorf("creating archive file: %w", err)
+	}
+	defer f.Close()
+
+	log.Infow("exporting pieces", "db", db, "archive", exportPath)
+	count, err := svc.Export(from, f)
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("writing archive file: %w", err)
+	}
+
+	log.Infow("export complete", "pieces", count)
+	return nil
+}
+
+func importArchive() error {
+	to, err := svc.NewService(db, repopath)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(importPath)
+	if err != nil {
+		return fmt.Errorf("opening archive file: %w", err)
+	}
+	defer f.Close()
+
+	log.Infow("importing pieces", "db", db, "archive", importPath)
+	res, err := svc.Import(to, f)
+	if err != nil {
+		return err
+	}
+
+	log.Infow("import complete", "imported", res.Migrated, "already-indexed", res.Skipped)
+	return nil
+}
+
+func runRebalance() error {
+	if db != "ldb-sharded" {
+		return fmt.Errorf("-rebalance requires -db ldb-sharded")
+	}
+
+	store, err := svc.NewShardedLdb(repopath)
+	if err != nil {
+		return err
+	}
+
+	log.Infow("rebalancing pieces", "repopath", repopath, "dry-run", rebalanceDryRun)
+	res, err := store.Rebalance(rebalanceDryRun)
+	if err != nil {
+		return err
+	}
+
+	usage, err := store.Usage()
+	if err != nil {
+		return err
+	}
+	for _, u := range usage {
+		log.Infow("shard", "dir", u.Name, "pieces", u.Pieces, "misplaced", u.Misplaced)
+	}
+
+	log.Infow("rebalance complete", "checked", res.Checked, "moved", res.Moved, "dry-run", rebalanceDryRun)
+	return nil
+}
+
+func runBackfill() error {
+	var deals []backfill.PieceDeal
+	if backfillDeals != "" {
+		var err error
+		deals, err = backfill.LoadDealsFile(backfillDeals)
+		if err != nil {
+			return err
+		}
+	}
+
+	if backfillUnsealedDir != "" && len(deals) == 0 {
+		return fmt.Errorf("-backfill-unsealed-dir requires -backfill-deals, to find the location of each piece in its sector")
+	}
+
+	var sources []backfill.Source
+	if backfillCarDir != "" {
+		sources = append(sources, &backfill.CarDirSource{Dir: backfillCarDir, Deals: deals})
+	}
+	if backfillUnsealedDir != "" {
+		sources = append(sources, &backfill.UnsealedSectorSource{Dir: backfillUnsealedDir, Deals: deals})
+	}
+
+	store, err := svc.NewService(db, repopath)
+	if err != nil {
+		return err
+	}
+
+	checkpoint := backfillCheckpoint
+	if checkpoint == "" {
+		checkpoint = filepath.Join(repopath, "backfill-checkpoint.jsonl")
+	}
+
+	// Stop on interrupt: the backfill can be resumed from the checkpoint
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	res, err := backfill.Backfill(ctx, store, sources, backfill.Config{
+		Workers:        backfillWorkers,
+		CheckpointPath: checkpoint,
+	})
+	if res != nil {
+		log.Infow("backfill finished", "indexed", res.Indexed, "already-indexed", res.Skipped, "failed", res.Failed, "checkpoint", checkpoint)
+	}
+	return err
+}
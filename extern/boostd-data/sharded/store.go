@@ -0,0 +1,290 @@
+package sharded
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/filecoin-project/boost/cmd/boostd-data/model"
+	"github.com/filecoin-project/boost/cmd/boostd-data/svc/types"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	logging "github.com/ipfs/go-log/v2"
+	mh "github.com/multiformats/go-multihash"
+)
+
+var log = logging.Logger("boostd-data-sharded")
+
+var _ types.ServiceImpl = (*Store)(nil)
+
+// Shard is a piece directory store that pieces can be removed from, so that
+// pieces can be moved between shards
+type Shard interface {
+	types.ServiceImpl
+	RemovePiece(pieceCid cid.Cid) error
+}
+
+type shard struct {
+	name string
+	Shard
+}
+
+// Store spreads the piece directory across several shards (eg one per disk).
+// Each piece is kept in the shard chosen by rendezvous hashing of the piece
+// cid with the shard names, so when a shard is added only the pieces that
+// the new shard is chosen for need to be moved (see Rebalance).
+// Until the store is rebalanced, pieces are looked up in the other shards
+// if they're not in the shard chosen for them.
+type Store struct {
+	shards []shard
+}
+
+// NewStore creates a sharded store from the shards, keyed by name.
+// The name of each shard must stay the same for pieces to be found in the
+// shard they were added to.
+func NewStore(shards map[string]Shard) (*Store, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("sharded store must have at least one shard")
+	}
+
+	s := &Store{}
+	for name, sh := range shards {
+		s.shards = append(s.shards, shard{name: name, Shard: sh})
+	}
+	sort.Slice(s.shards, func(i, j int) bool {
+		return s.shards[i].name < s.shards[j].name
+	})
+	return s, nil
+}
+
+// owner returns the shard that the piece should be kept in: the shard with
+// the highest hash of shard name and piece cid
+func (s *Store) owner(pieceCid cid.Cid) int {
+	var owner int
+	var ownerHash [sha256.Size]byte
+	for i, sh := range s.shards {
+		h := sha256.Sum256(append([]byte(sh.name+"/"), pieceCid.Bytes()...))
+		if i == 0 || string(h[:]) > string(ownerHash[:]) {
+			owner = i
+			ownerHash = h
+		}
+	}
+	return owner
+}
+
+// locate returns the shard that has the piece, checking the piece's owner
+// first. If no shard has the piece, it returns the owner.
+func (s *Store) locate(pieceCid cid.Cid) shard {
+	owner := s.owner(pieceCid)
+	if hasPiece(s.shards[owner], pieceCid) {
+		return s.shards[owner]
+	}
+	for i, sh := range s.shards {
+		if i != owner && hasPiece(sh, pieceCid) {
+			return sh
+		}
+	}
+	return s.shards[owner]
+}
+
+func hasPiece(sh shard, pieceCid cid.Cid) bool {
+	_, err := sh.GetPieceDeals(pieceCid)
+	return err == nil
+}
+
+func (s *Store) AddDealForPiece(pieceCid cid.Cid, dealInfo model.DealInfo) error {
+	return s.locate(pieceCid).AddDealForPiece(pieceCid, dealInfo)
+}
+
+func (s *Store) AddIndex(pieceCid cid.Cid, records []model.Record) error {
+	return s.locate(pieceCid).AddIndex(pieceCid, records)
+}
+
+func (s *Store) GetIndex(pieceCid cid.Cid) ([]model.Record, error) {
+	return s.locate(pieceCid).GetIndex(pieceCid)
+}
+
+func (s *Store) GetOffset(pieceCid cid.Cid, hash mh.Multihash) (uint64, error) {
+	return s.locate(pieceCid).GetOffset(pieceCid, hash)
+}
+
+func (s *Store) GetPieceDeals(pieceCid cid.Cid) ([]model.DealInfo, error) {
+	return s.locate(pieceCid).GetPieceDeals(pieceCid)
+}
+
+func (s *Store) GetRecords(pieceCid cid.Cid) ([]model.Record, error) {
+	return s.locate(pieceCid).GetRecords(pieceCid)
+}
+
+func (s *Store) IndexedAt(pieceCid cid.Cid) (time.Time, error) {
+	return s.locate(pieceCid).IndexedAt(pieceCid)
+}
+
+// ListPieces lists the pieces in all shards
+func (s *Store) ListPieces() ([]cid.Cid, error) {
+	seen := make(map[cid.Cid]struct{})
+	var pieceCids []cid.Cid
+	for _, sh := range s.shards {
+		pcids, err := sh.ListPieces()
+		if err != nil {
+			return nil, fmt.Errorf("listing pieces in shard %s: %w", sh.name, err)
+		}
+		for _, c := range pcids {
+			if _, ok := seen[c]; !ok {
+				seen[c] = struct{}{}
+				pieceCids = append(pieceCids, c)
+			}
+		}
+	}
+	return pieceCids, nil
+}
+
+// PiecesContainingMultihash returns the pieces in all shards that contain
+// the multihash
+func (s *Store) PiecesContainingMultihash(m mh.Multihash) ([]cid.Cid, error) {
+	seen := make(map[cid.Cid]struct{})
+	var pieceCids []cid.Cid
+	for _, sh := range s.shards {
+		pcids, err := sh.PiecesContainingMultihash(m)
+		if err != nil {
+			if errors.Is(err, ds.ErrNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("getting pieces containing multihash from shard %s: %w", sh.name, err)
+		}
+		for _, c := range pcids {
+			if _, ok := seen[c]; !ok {
+				seen[c] = struct{}{}
+				pieceCids = append(pieceCids, c)
+			}
+		}
+	}
+
+	if len(pieceCids) == 0 {
+		return nil, fmt.Errorf("failed to get value for multihash %s, err: %w", m, ds.ErrNotFound)
+	}
+	return pieceCids, nil
+}
+
+// ShardUsage is the number of pieces kept in a shard
+type ShardUsage struct {
+	Name   string
+	Pieces int
+	// The number of pieces in the shard that belong in another shard
+	Misplaced int
+}
+
+// Usage returns the number of pieces in each shard
+func (s *Store) Usage() ([]ShardUsage, error) {
+	usage := make([]ShardUsage, 0, len(s.shards))
+	for i, sh := range s.shards {
+		pcids, err := sh.ListPieces()
+		if err != nil {
+			return nil, fmt.Errorf("listing pieces in shard %s: %w", sh.name, err)
+		}
+		u := ShardUsage{Name: sh.name, Pieces: len(pcids)}
+		for _, c := range pcids {
+			if s.owner(c) != i {
+				u.Misplaced++
+			}
+		}
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
+
+// RebalanceResult counts the pieces checked and moved by Rebalance
+type RebalanceResult struct {
+	Checked int
+	// The number of pieces that were moved (or that would be moved, in a
+	// dry run)
+	Moved int
+}
+
+// Rebalance moves each piece that is not in the shard chosen for it into
+// that shard. It should be run after shards are added or removed.
+// If dryRun is true, no pieces are moved and the result counts the pieces
+// that would be moved.
+// A piece is copied to its new shard before it's removed from its old shard
+// so that it can be found while it's being moved, and so that an
+// interrupted rebalance can be run again.
+func (s *Store) Rebalance(dryRun bool) (*RebalanceResult, error) {
+	// List the pieces in each shard before moving any, so that moved pieces
+	// aren't checked twice
+	shardPieces := make([][]cid.Cid, len(s.shards))
+	for i, sh := range s.shards {
+		pcids, err := sh.ListPieces()
+		if err != nil {
+			return nil, fmt.Errorf("listing pieces in shard %s: %w", sh.name, err)
+		}
+		shardPieces[i] = pcids
+	}
+
+	res := &RebalanceResult{}
+	for i, sh := range s.shards {
+		for _, pieceCid := range shardPieces[i] {
+			res.Checked++
+			owner := s.owner(pieceCid)
+			if owner == i {
+				continue
+			}
+
+			res.Moved++
+			if dryRun {
+				continue
+			}
+			if err := movePiece(pieceCid, sh, s.shards[owner]); err != nil {
+				return res, err
+			}
+			log.Infow("moved piece", "piece-cid", pieceCid, "from", sh.name, "to", s.shards[owner].name)
+		}
+	}
+
+	return res, nil
+}
+
+func movePiece(pieceCid cid.Cid, from shard, to shard) error {
+	deals, err := from.GetPieceDeals(pieceCid)
+	if err != nil {
+		return fmt.Errorf("getting deals for piece %s in shard %s: %w", pieceCid, from.name, err)
+	}
+
+	indexedAt, err := to.IndexedAt(pieceCid)
+	if err != nil {
+		return fmt.Errorf("checking if piece %s is indexed in shard %s: %w", pieceCid, to.name, err)
+	}
+	if indexedAt.IsZero() {
+		records, err := from.GetRecords(pieceCid)
+		if err != nil {
+			return fmt.Errorf("getting index for piece %s in shard %s: %w", pieceCid, from.name, err)
+		}
+		if err := to.AddIndex(pieceCid, records); err != nil {
+			return fmt.Errorf("adding index for piece %s to shard %s: %w", pieceCid, to.name, err)
+		}
+	}
+
+	toDeals, err := to.GetPieceDeals(pieceCid)
+	if err != nil && !errors.Is(err, ds.ErrNotFound) {
+		return fmt.Errorf("getting deals for piece %s in shard %s: %w", pieceCid, to.name, err)
+	}
+	existing := make(map[uuid.UUID]struct{}, len(toDeals))
+	for _, d := range toDeals {
+		existing[d.DealUuid] = struct{}{}
+	}
+	for _, d := range deals {
+		if _, ok := existing[d.DealUuid]; ok {
+			continue
+		}
+		if err := to.AddDealForPiece(pieceCid, d); err != nil {
+			return fmt.Errorf("adding deal %s for piece %s to shard %s: %w", d.DealUuid, pieceCid, to.name, err)
+		}
+	}
+
+	if err := from.RemovePiece(pieceCid); err != nil {
+		return fmt.Errorf("removing piece %s from shard %s: %w", pieceCid, from.name, err)
+	}
+	return nil
+}
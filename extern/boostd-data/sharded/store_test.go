@@ -0,0 +1,153 @@
+package sharded
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/boost/cmd/boostd-data/ldb"
+	"github.com/filecoin-project/boost/cmd/boostd-data/model"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedStore(t *testing.T) {
+	dir := t.TempDir()
+	shards := map[string]Shard{}
+	for i := 0; i < 3; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("shard%d", i))
+		shards[name] = ldb.NewStore(name)
+	}
+	s, err := NewStore(shards)
+	require.NoError(t, err)
+
+	// Add some pieces, each with a deal and an index
+	const numPieces = 20
+	pieceCids := make([]cid.Cid, 0, numPieces)
+	deals := make(map[cid.Cid]model.DealInfo)
+	records := make(map[cid.Cid][]model.Record)
+	for i := 0; i < numPieces; i++ {
+		pieceCid := testCid(t, fmt.Sprintf("piece%d", i))
+		pieceCids = append(pieceCids, pieceCid)
+
+		// All pieces contain the same shared block
+		recs := []model.Record{
+			{Cid: testCid(t, "shared"), Offset: 1},
+			{Cid: testCid(t, fmt.Sprintf("block%d", i)), Offset: 2},
+		}
+		records[pieceCid] = recs
+		require.NoError(t, s.AddIndex(pieceCid, recs))
+
+		di := model.DealInfo{
+			DealUuid:    uuid.New(),
+			SectorID:    abi.SectorNumber(i),
+			PieceOffset: 1,
+			PieceLength: 2,
+			CarLength:   3,
+		}
+		deals[pieceCid] = di
+		require.NoError(t, s.AddDealForPiece(pieceCid, di))
+	}
+
+	checkPieces := func(s *Store) {
+		listed, err := s.ListPieces()
+		require.NoError(t, err)
+		require.ElementsMatch(t, pieceCids, listed)
+
+		shared, err := s.PiecesContainingMultihash(testCid(t, "shared").Hash())
+		require.NoError(t, err)
+		require.ElementsMatch(t, pieceCids, shared)
+
+		for i, pieceCid := range pieceCids {
+			pcids, err := s.PiecesContainingMultihash(testCid(t, fmt.Sprintf("block%d", i)).Hash())
+			require.NoError(t, err)
+			require.Equal(t, []cid.Cid{pieceCid}, pcids)
+
+			dis, err := s.GetPieceDeals(pieceCid)
+			require.NoError(t, err)
+			require.Equal(t, []model.DealInfo{deals[pieceCid]}, dis)
+
+			recs, err := s.GetRecords(pieceCid)
+			require.NoError(t, err)
+			require.ElementsMatch(t, records[pieceCid], recs)
+
+			indexedAt, err := s.IndexedAt(pieceCid)
+			require.NoError(t, err)
+			require.False(t, indexedAt.IsZero())
+		}
+	}
+	checkPieces(s)
+
+	// The pieces should be spread across the shards
+	usage, err := s.Usage()
+	require.NoError(t, err)
+	require.Len(t, usage, 3)
+	for _, u := range usage {
+		require.NotZero(t, u.Pieces)
+		require.Zero(t, u.Misplaced)
+	}
+
+	_, err = s.PiecesContainingMultihash(testCid(t, "unknown").Hash())
+	require.Error(t, err)
+
+	// Add a shard: pieces are still found in the shard they were added to
+	name := filepath.Join(dir, "shard3")
+	shards[name] = ldb.NewStore(name)
+	s, err = NewStore(shards)
+	require.NoError(t, err)
+	checkPieces(s)
+
+	usage, err = s.Usage()
+	require.NoError(t, err)
+	var misplaced int
+	for _, u := range usage {
+		misplaced += u.Misplaced
+	}
+	require.NotZero(t, misplaced)
+
+	// A dry run counts the pieces to move without moving them
+	res, err := s.Rebalance(true)
+	require.NoError(t, err)
+	require.Equal(t, &RebalanceResult{Checked: numPieces, Moved: misplaced}, res)
+	usage, err = s.Usage()
+	require.NoError(t, err)
+	require.Zero(t, usage[3].Pieces)
+
+	res, err = s.Rebalance(false)
+	require.NoError(t, err)
+	require.Equal(t, &RebalanceResult{Checked: numPieces, Moved: misplaced}, res)
+	checkPieces(s)
+
+	// Each piece should now only be in the shard chosen for it
+	usage, err = s.Usage()
+	require.NoError(t, err)
+	for _, u := range usage {
+		require.Zero(t, u.Misplaced)
+	}
+	require.Equal(t, misplaced, usage[3].Pieces)
+	for _, pieceCid := range pieceCids {
+		owner := s.owner(pieceCid)
+		for i, sh := range s.shards {
+			_, err := sh.GetPieceDeals(pieceCid)
+			if i == owner {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		}
+	}
+
+	// Rebalancing again doesn't move any pieces
+	res, err = s.Rebalance(false)
+	require.NoError(t, err)
+	require.Equal(t, &RebalanceResult{Checked: numPieces}, res)
+}
+
+func testCid(t *testing.T, data string) cid.Cid {
+	h, err := mh.Sum([]byte(data), mh.SHA2_256, -1)
+	require.NoError(t, err)
+	return cid.NewCidV1(cid.Raw, h)
+}
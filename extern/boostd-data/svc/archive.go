@@ -0,0 +1,123 @@
+package svc
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/filecoin-project/boost/cmd/boostd-data/model"
+	"github.com/filecoin-project/boost/cmd/boostd-data/svc/types"
+	"github.com/ipfs/go-cid"
+)
+
+// The version of the archive format written by Export
+const archiveVersion = 1
+
+// An archive is a gzipped stream of JSON values: an archiveHeader followed
+// by an archivePiece for each piece
+type archiveHeader struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+type archivePiece struct {
+	PieceCid cid.Cid `json:"piece_cid"`
+	// Zero if the piece has not been indexed
+	IndexedAt time.Time        `json:"indexed_at"`
+	Deals     []model.DealInfo `json:"deals"`
+	Records   []model.Record   `json:"records"`
+}
+
+// Export writes the deals and index of every piece in the store to an
+// archive that can be imported into any backend with Import.
+// It returns the number of pieces exported.
+func Export(from types.ServiceImpl, w io.Writer) (int, error) {
+	pieceCids, err := from.ListPieces()
+	if err != nil {
+		return 0, fmt.Errorf("listing pieces: %w", err)
+	}
+
+	gzw := gzip.NewWriter(w)
+	enc := json.NewEncoder(gzw)
+	if err := enc.Encode(archiveHeader{Version: archiveVersion, ExportedAt: time.Now()}); err != nil {
+		return 0, fmt.Errorf("writing archive header: %w", err)
+	}
+
+	for i, pieceCid := range pieceCids {
+		p := archivePiece{PieceCid: pieceCid}
+
+		p.Deals, err = from.GetPieceDeals(pieceCid)
+		if err != nil {
+			return i, fmt.Errorf("getting deals for piece %s: %w", pieceCid, err)
+		}
+		p.IndexedAt, err = from.IndexedAt(pieceCid)
+		if err != nil {
+			return i, fmt.Errorf("checking if piece %s is indexed: %w", pieceCid, err)
+		}
+		if !p.IndexedAt.IsZero() {
+			p.Records, err = from.GetRecords(pieceCid)
+			if err != nil {
+				return i, fmt.Errorf("getting index for piece %s: %w", pieceCid, err)
+			}
+		}
+
+		if err := enc.Encode(p); err != nil {
+			return i, fmt.Errorf("writing piece %s to archive: %w", pieceCid, err)
+		}
+
+		log.Infow("exported piece", "piece-cid", pieceCid, "progress", fmt.Sprintf("%d/%d", i+1, len(pieceCids)))
+	}
+
+	if err := gzw.Close(); err != nil {
+		return len(pieceCids), fmt.Errorf("writing archive: %w", err)
+	}
+	return len(pieceCids), nil
+}
+
+// Import adds the deals and index of each piece in an archive written by
+// Export to the store.
+// As with Migrate, pieces that are already indexed in the store are not
+// re-indexed but any missing deals are added, so an import that was
+// interrupted can be run again.
+func Import(to types.ServiceImpl, r io.Reader) (*MigrateResult, error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+	defer gzr.Close()
+
+	dec := json.NewDecoder(gzr)
+	var hdr archiveHeader
+	if err := dec.Decode(&hdr); err != nil {
+		return nil, fmt.Errorf("reading archive header: %w", err)
+	}
+	if hdr.Version != archiveVersion {
+		return nil, fmt.Errorf("unsupported archive version %d (expected %d)", hdr.Version, archiveVersion)
+	}
+
+	res := &MigrateResult{}
+	for {
+		var p archivePiece
+		if err := dec.Decode(&p); err != nil {
+			if errors.Is(err, io.EOF) {
+				return res, nil
+			}
+			return res, fmt.Errorf("reading piece %d from archive: %w", res.Migrated+res.Skipped+1, err)
+		}
+
+		var getRecords func() ([]model.Record, error)
+		if !p.IndexedAt.IsZero() {
+			getRecords = func() ([]model.Record, error) {
+				return p.Records, nil
+			}
+		}
+		if err := copyPiece(to, p.PieceCid, p.Deals, getRecords, res); err != nil {
+			return res, err
+		}
+
+		log.Infow("imported piece", "piece-cid", p.PieceCid, "exported-at", hdr.ExportedAt)
+	}
+}
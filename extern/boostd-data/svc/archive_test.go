@@ -0,0 +1,108 @@
+package svc
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/filecoin-project/boost/cmd/boostd-data/ldb"
+	"github.com/filecoin-project/boost/cmd/boostd-data/model"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+)
+
+func TestExportImport(t *testing.T) {
+	from := ldb.NewStore("")
+
+	pieceCid, err := cid.Parse("baga6ea4seaqnfhocd544oidrgsss2ahoaomvxuaqxfmlsizljtzsuivjl5hamka")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := loadIndex("fixtures/baga6ea4seaqnfhocd544oidrgsss2ahoaomvxuaqxfmlsizljtzsuivjl5hamka.full.idx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := getRecords(subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = from.AddIndex(pieceCid, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	di := model.DealInfo{
+		DealUuid:    uuid.New(),
+		SectorID:    abi.SectorNumber(1),
+		PieceOffset: 1,
+		PieceLength: 2,
+		CarLength:   3,
+	}
+	err = from.AddDealForPiece(pieceCid, di)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	count, err := Export(from, &archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 piece to be exported, got: %d", count)
+	}
+
+	to := ldb.NewStore("")
+	res, err := Import(to, bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Migrated != 1 || res.Skipped != 0 {
+		t.Fatalf("expected 1 piece to be imported, got: %+v", res)
+	}
+
+	imported, err := to.GetRecords(pieceCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != len(records) {
+		t.Fatalf("expected %d records, got: %d", len(records), len(imported))
+	}
+	for _, r := range records {
+		offset, err := to.GetOffset(pieceCid, r.Cid.Hash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offset != r.Offset {
+			t.Fatalf("expected offset %d for %s, got: %d", r.Offset, r.Cid, offset)
+		}
+	}
+
+	dis, err := to.GetPieceDeals(pieceCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dis) != 1 || dis[0] != di {
+		t.Fatalf("expected deal to be imported, got: %v", dis)
+	}
+
+	// Importing again should skip the piece and not duplicate the deal
+	res, err = Import(to, bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Migrated != 0 || res.Skipped != 1 {
+		t.Fatalf("expected 1 piece to be skipped, got: %+v", res)
+	}
+
+	dis, err = to.GetPieceDeals(pieceCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dis) != 1 {
+		t.Fatalf("expected 1 deal, got: %d", len(dis))
+	}
+}
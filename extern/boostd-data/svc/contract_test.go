@@ -0,0 +1,111 @@
+package svc
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/filecoin-project/boost/cmd/boostd-data/ldb"
+	"github.com/filecoin-project/boost/cmd/boostd-data/model"
+	"github.com/filecoin-project/boost/cmd/boostd-data/svc/types"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+)
+
+func TestLdbServiceImpl(t *testing.T) {
+	testServiceImpl(t, ldb.NewStore(""))
+}
+
+// testServiceImpl checks that a piece directory store backend implements
+// the ServiceImpl contract, so that the backends are interchangeable
+func testServiceImpl(t *testing.T, s types.ServiceImpl) {
+	pieceCid, err := cid.Parse("baga6ea4seaqnfhocd544oidrgsss2ahoaomvxuaqxfmlsizljtzsuivjl5hamka")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := loadIndex("fixtures/baga6ea4seaqnfhocd544oidrgsss2ahoaomvxuaqxfmlsizljtzsuivjl5hamka.full.idx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := getRecords(subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.AddIndex(pieceCid, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	di := model.DealInfo{
+		DealUuid:    uuid.New(),
+		SectorID:    abi.SectorNumber(1),
+		PieceOffset: 1,
+		PieceLength: 2,
+		CarLength:   3,
+	}
+	err = s.AddDealForPiece(pieceCid, di)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := hex.DecodeString("1220ff63d7689e2d9567d1a90a7a68425f430137142e1fbc28fe4780b9ee8a5ef842")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mhash, err := multihash.Cast(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offset, err := s.GetOffset(pieceCid, mhash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 3039040395 {
+		t.Fatalf("expected offset 3039040395, got: %d", offset)
+	}
+
+	pcids, err := s.PiecesContainingMultihash(mhash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pcids) != 1 || !pcids[0].Equals(pieceCid) {
+		t.Fatalf("expected multihash to be in piece %s, got: %v", pieceCid, pcids)
+	}
+
+	dis, err := s.GetPieceDeals(pieceCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dis) != 1 || dis[0] != di {
+		t.Fatalf("expected deal %v, got: %v", di, dis)
+	}
+
+	indexedAt, err := s.IndexedAt(pieceCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if indexedAt.IsZero() {
+		t.Fatal("expected piece to be indexed")
+	}
+
+	recs, err := s.GetRecords(pieceCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != len(records) {
+		t.Fatalf("expected %d records, got: %d", len(records), len(recs))
+	}
+
+	pcids, err = s.ListPieces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pcids) != 1 || !pcids[0].Equals(pieceCid) {
+		t.Fatalf("expected to list piece %s, got: %v", pieceCid, pcids)
+	}
+}
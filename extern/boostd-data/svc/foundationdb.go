@@ -0,0 +1,20 @@
+//go:build foundationdb
+
+package svc
+
+import (
+	"github.com/filecoin-project/boost/cmd/boostd-data/foundationdb"
+	"github.com/filecoin-project/boost/cmd/boostd-data/svc/types"
+)
+
+// The FoundationDB backend needs the FoundationDB client library to be
+// installed, so it's only built with the foundationdb build tag
+func init() {
+	RegisterBackend("fdb", func(string) (types.ServiceImpl, error) {
+		s, err := foundationdb.NewStore()
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+}
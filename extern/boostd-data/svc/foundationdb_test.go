@@ -0,0 +1,121 @@
+//go:build foundationdb
+
+package svc
+
+import (
+	"os"
+	"testing"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/directory"
+	"github.com/filecoin-project/boost/cmd/boostd-data/foundationdb"
+	"github.com/filecoin-project/boost/cmd/boostd-data/ldb"
+	"github.com/filecoin-project/boost/cmd/boostd-data/model"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+)
+
+// These tests need a running FoundationDB cluster, eg
+// FDB_CLUSTER_FILE=/etc/foundationdb/fdb.cluster go test -tags foundationdb ./svc
+
+func TestFdbServiceImpl(t *testing.T) {
+	testServiceImpl(t, newTestFdbStore(t))
+}
+
+func TestMigrateLdbToFdb(t *testing.T) {
+	from := ldb.NewStore("")
+	to := newTestFdbStore(t)
+
+	pieceCid, err := cid.Parse("baga6ea4seaqnfhocd544oidrgsss2ahoaomvxuaqxfmlsizljtzsuivjl5hamka")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := loadIndex("fixtures/baga6ea4seaqnfhocd544oidrgsss2ahoaomvxuaqxfmlsizljtzsuivjl5hamka.full.idx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := getRecords(subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = from.AddIndex(pieceCid, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	di := model.DealInfo{
+		DealUuid:    uuid.New(),
+		SectorID:    abi.SectorNumber(1),
+		PieceOffset: 1,
+		PieceLength: 2,
+		CarLength:   3,
+	}
+	err = from.AddDealForPiece(pieceCid, di)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Migrate(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Migrated != 1 || res.Skipped != 0 {
+		t.Fatalf("expected 1 piece to be migrated, got: %+v", res)
+	}
+
+	migrated, err := to.GetRecords(pieceCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrated) != len(records) {
+		t.Fatalf("expected %d records, got: %d", len(records), len(migrated))
+	}
+
+	dis, err := to.GetPieceDeals(pieceCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dis) != 1 || dis[0] != di {
+		t.Fatalf("expected deal to be migrated, got: %v", dis)
+	}
+
+	// Running the migration again skips the piece that was already migrated
+	res, err = Migrate(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Migrated != 0 || res.Skipped != 1 {
+		t.Fatalf("expected 1 piece to be skipped, got: %+v", res)
+	}
+}
+
+// newTestFdbStore creates a store in a directory of its own, which is
+// removed at the end of the test
+func newTestFdbStore(t *testing.T) *foundationdb.Store {
+	if os.Getenv("FDB_CLUSTER_FILE") == "" {
+		if _, err := os.Stat("/etc/foundationdb/fdb.cluster"); err != nil {
+			t.Skip("no FoundationDB cluster file")
+		}
+	}
+
+	path := []string{"boostd-data-test", uuid.New().String()}
+	s, err := foundationdb.NewStoreInDirectory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db, err := fdb.OpenDefault()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := directory.Root().Remove(db, path); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return s
+}
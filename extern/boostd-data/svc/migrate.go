@@ -0,0 +1,104 @@
+package svc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/filecoin-project/boost/cmd/boostd-data/model"
+	"github.com/filecoin-project/boost/cmd/boostd-data/svc/types"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+)
+
+// MigrateResult counts the pieces copied by Migrate
+type MigrateResult struct {
+	// The number of pieces that were copied to the destination
+	Migrated int
+	// The number of pieces that were already indexed in the destination,
+	// or that have no index to copy (only their deals are copied)
+	Skipped int
+}
+
+// Migrate copies the index and deals for each piece from one piece
+// directory store backend to another.
+// Pieces that are already indexed in the destination are not re-indexed,
+// but any missing deals are added, so a migration that was interrupted can
+// be run again.
+func Migrate(from types.ServiceImpl, to types.ServiceImpl) (*MigrateResult, error) {
+	pieceCids, err := from.ListPieces()
+	if err != nil {
+		return nil, fmt.Errorf("listing pieces: %w", err)
+	}
+
+	res := &MigrateResult{}
+	for i, pieceCid := range pieceCids {
+		deals, err := from.GetPieceDeals(pieceCid)
+		if err != nil {
+			return res, fmt.Errorf("getting deals for piece %s: %w", pieceCid, err)
+		}
+
+		indexedAt, err := from.IndexedAt(pieceCid)
+		if err != nil {
+			return res, fmt.Errorf("checking if piece %s is indexed: %w", pieceCid, err)
+		}
+
+		var getRecords func() ([]model.Record, error)
+		if !indexedAt.IsZero() {
+			pieceCid := pieceCid
+			getRecords = func() ([]model.Record, error) {
+				return from.GetRecords(pieceCid)
+			}
+		}
+		if err := copyPiece(to, pieceCid, deals, getRecords, res); err != nil {
+			return res, err
+		}
+
+		log.Infow("migrated piece", "piece-cid", pieceCid, "progress", fmt.Sprintf("%d/%d", i+1, len(pieceCids)))
+	}
+
+	return res, nil
+}
+
+// copyPiece adds the piece's index to the destination if it's not already
+// indexed there, followed by any of the piece's deals that the destination
+// doesn't have. If getRecords is nil the piece has no index to copy.
+func copyPiece(to types.ServiceImpl, pieceCid cid.Cid, deals []model.DealInfo, getRecords func() ([]model.Record, error), res *MigrateResult) error {
+	indexedAt, err := to.IndexedAt(pieceCid)
+	if err != nil {
+		return fmt.Errorf("checking if piece %s is indexed in destination: %w", pieceCid, err)
+	}
+
+	if indexedAt.IsZero() && getRecords != nil {
+		records, err := getRecords()
+		if err != nil {
+			return fmt.Errorf("getting index for piece %s: %w", pieceCid, err)
+		}
+		if err := to.AddIndex(pieceCid, records); err != nil {
+			return fmt.Errorf("adding index for piece %s: %w", pieceCid, err)
+		}
+		res.Migrated++
+	} else {
+		res.Skipped++
+	}
+
+	toDeals, err := to.GetPieceDeals(pieceCid)
+	if err != nil && !errors.Is(err, ds.ErrNotFound) {
+		return fmt.Errorf("getting deals for piece %s in destination: %w", pieceCid, err)
+	}
+	existing := make(map[uuid.UUID]struct{}, len(toDeals))
+	for _, d := range toDeals {
+		existing[d.DealUuid] = struct{}{}
+	}
+
+	for _, d := range deals {
+		if _, ok := existing[d.DealUuid]; ok {
+			continue
+		}
+		if err := to.AddDealForPiece(pieceCid, d); err != nil {
+			return fmt.Errorf("adding deal %s for piece %s: %w", d.DealUuid, pieceCid, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package svc
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/boost/cmd/boostd-data/ldb"
+	"github.com/filecoin-project/boost/cmd/boostd-data/model"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+)
+
+func TestMigrateLdbToLdb(t *testing.T) {
+	from := ldb.NewStore("")
+	to := ldb.NewStore("")
+
+	pieceCid, err := cid.Parse("baga6ea4seaqnfhocd544oidrgsss2ahoaomvxuaqxfmlsizljtzsuivjl5hamka")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := loadIndex("fixtures/baga6ea4seaqnfhocd544oidrgsss2ahoaomvxuaqxfmlsizljtzsuivjl5hamka.full.idx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := getRecords(subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = from.AddIndex(pieceCid, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	di := model.DealInfo{
+		DealUuid:    uuid.New(),
+		SectorID:    abi.SectorNumber(1),
+		PieceOffset: 1,
+		PieceLength: 2,
+		CarLength:   3,
+	}
+	err = from.AddDealForPiece(pieceCid, di)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pcids, err := from.ListPieces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pcids) != 1 || !pcids[0].Equals(pieceCid) {
+		t.Fatalf("expected to list piece %s, got: %v", pieceCid, pcids)
+	}
+
+	res, err := Migrate(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Migrated != 1 || res.Skipped != 0 {
+		t.Fatalf("expected 1 piece to be migrated, got: %+v", res)
+	}
+
+	migrated, err := to.GetRecords(pieceCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrated) != len(records) {
+		t.Fatalf("expected %d records, got: %d", len(records), len(migrated))
+	}
+
+	dis, err := to.GetPieceDeals(pieceCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dis) != 1 || dis[0] != di {
+		t.Fatalf("expected deal to be migrated, got: %v", dis)
+	}
+
+	// Running the migration again should skip the piece and not duplicate
+	// the deal
+	res, err = Migrate(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Migrated != 0 || res.Skipped != 1 {
+		t.Fatalf("expected 1 piece to be skipped, got: %+v", res)
+	}
+
+	dis, err = to.GetPieceDeals(pieceCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dis) != 1 {
+		t.Fatalf("expected 1 deal, got: %d", len(dis))
+	}
+}
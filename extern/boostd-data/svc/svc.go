@@ -5,11 +5,16 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/filecoin-project/boost/cmd/boostd-data/couchbase"
 	"github.com/filecoin-project/boost/cmd/boostd-data/ldb"
+	"github.com/filecoin-project/boost/cmd/boostd-data/sharded"
+	"github.com/filecoin-project/boost/cmd/boostd-data/svc/types"
 	"github.com/gorilla/mux"
 	logging "github.com/ipfs/go-log/v2"
 )
@@ -18,18 +23,93 @@ var (
 	log = logging.Logger("svc")
 )
 
+// Backend creates a piece directory store.
+// The repo path is the directory that embedded backends keep their data in
+// (a temporary directory is used if it's empty).
+type Backend func(repopath string) (types.ServiceImpl, error)
+
+var backends = map[string]Backend{
+	// embedded leveldb: doesn't need a separate database to be running
+	"ldb": func(repopath string) (types.ServiceImpl, error) {
+		return ldb.NewStore(repopath), nil
+	},
+	// embedded leveldb sharded across several directories (eg one per disk):
+	// the repo path is a list of directories separated by the OS path list
+	// separator (':' on unix)
+	"ldb-sharded": func(repopath string) (types.ServiceImpl, error) {
+		return NewShardedLdb(repopath)
+	},
+	"couchbase": func(string) (types.ServiceImpl, error) {
+		return couchbase.NewStore(), nil
+	},
+}
+
+// NewShardedLdb creates a leveldb store in each of the directories in the
+// repo path list, and shards pieces across them
+func NewShardedLdb(repopath string) (*sharded.Store, error) {
+	shards := make(map[string]sharded.Shard)
+	for _, dir := range filepath.SplitList(repopath) {
+		if dir == "" {
+			continue
+		}
+		dir = filepath.Clean(dir)
+		if _, ok := shards[dir]; ok {
+			return nil, fmt.Errorf("directory %s is listed more than once", dir)
+		}
+		shards[dir] = ldb.NewStore(dir)
+	}
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("ldb-sharded requires the repo path to be a list of directories")
+	}
+
+	return sharded.NewStore(shards)
+}
+
+// RegisterBackend makes a piece directory store backend available under the
+// given db name. It must be called before New.
+func RegisterBackend(db string, b Backend) {
+	backends[db] = b
+}
+
+// Backends returns the names of the available backends
+func Backends() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewService creates the piece directory store for the given db name
+func NewService(db string, repopath string) (types.ServiceImpl, error) {
+	b, ok := backends[db]
+	if !ok {
+		return nil, fmt.Errorf("unknown db: %s (must be one of %s)", db, strings.Join(Backends(), ", "))
+	}
+
+	s, err := b(repopath)
+	if err != nil {
+		return nil, fmt.Errorf("creating %s store: %w", db, err)
+	}
+	return s, nil
+}
+
 func New(db string, repopath string) *http.Server {
-	server := rpc.NewServer()
+	ds, err := NewService(db, repopath)
+	if err != nil {
+		panic(err.Error())
+	}
 
-	switch db {
-	case "couchbase":
-		ds := couchbase.NewStore()
-		server.RegisterName("boostddata", ds)
-	case "ldb":
-		ds := ldb.NewStore(repopath)
-		server.RegisterName("boostddata", ds)
-	default:
-		panic(fmt.Sprintf("unknown db: %s", db))
+	return NewWithService(ds)
+}
+
+// NewWithService creates a server that serves the piece directory API from
+// the given store
+func NewWithService(ds types.ServiceImpl) *http.Server {
+	server := rpc.NewServer()
+	if err := server.RegisterName("boostddata", ds); err != nil {
+		panic(fmt.Sprintf("registering piece directory service: %s", err))
 	}
 
 	router := mux.NewRouter()
@@ -0,0 +1,24 @@
+package types
+
+import (
+	"time"
+
+	"github.com/filecoin-project/boost/cmd/boostd-data/model"
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// ServiceImpl is the interface that a piece directory store backend
+// implements. The methods are exposed over JSON-RPC by the boostd-data
+// service, in the boostddata namespace.
+type ServiceImpl interface {
+	AddDealForPiece(pieceCid cid.Cid, dealInfo model.DealInfo) error
+	AddIndex(pieceCid cid.Cid, records []model.Record) error
+	GetIndex(pieceCid cid.Cid) ([]model.Record, error)
+	GetOffset(pieceCid cid.Cid, hash mh.Multihash) (uint64, error)
+	GetPieceDeals(pieceCid cid.Cid) ([]model.DealInfo, error)
+	GetRecords(pieceCid cid.Cid) ([]model.Record, error)
+	IndexedAt(pieceCid cid.Cid) (time.Time, error)
+	ListPieces() ([]cid.Cid, error)
+	PiecesContainingMultihash(m mh.Multihash) ([]cid.Cid, error)
+}
@@ -0,0 +1,191 @@
+package fundmanager
+
+import (
+	"context"
+	"fmt"
+	stdbig "math/big"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/metrics"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/google/uuid"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// The kinds of balance that are tracked for low-balance alerts
+const (
+	// The escrow balance that's available for new deals
+	BalanceKindEscrow = "escrow"
+	// The balance of a wallet used to send publish storage deals messages
+	BalanceKindPublishMsg = "publish-msg"
+	// The balance of a wallet used as the source of deal collateral
+	BalanceKindCollateral = "collateral"
+)
+
+// BalanceAlertsConfig configures alerts for when the escrow balance or the
+// balance of a publish or collateral wallet falls below a threshold
+type BalanceAlertsConfig struct {
+	// How often to check the balances
+	CheckInterval time.Duration
+	// Alert when the escrow balance available for new deals falls below the
+	// threshold. A zero threshold disables the alert.
+	EscrowThreshold abi.TokenAmount
+	// Alert when the balance of any publish wallet falls below the
+	// threshold. A zero threshold disables the alert.
+	PublishMsgThreshold abi.TokenAmount
+	PublishMsgWallets   []address.Address
+	// Alert when the balance of any collateral wallet falls below the
+	// threshold. A zero threshold disables the alert.
+	CollateralThreshold abi.TokenAmount
+	CollateralWallets   []address.Address
+}
+
+// BalanceAlerts periodically checks the tracked balances, records them as
+// metrics, and writes an event to the audit log (which is sent to any
+// webhooks that subscribe to it) when a balance falls below its threshold,
+// and again when it recovers.
+type BalanceAlerts struct {
+	cfg     BalanceAlertsConfig
+	fm      *FundManager
+	auditDB *db.AuditLogDB
+
+	// The balances that are currently below their threshold
+	low map[trackedBalance]bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type trackedBalance struct {
+	kind   string
+	wallet string
+}
+
+func NewBalanceAlerts(cfg BalanceAlertsConfig, fm *FundManager, auditDB *db.AuditLogDB) *BalanceAlerts {
+	return &BalanceAlerts{
+		cfg:     cfg,
+		fm:      fm,
+		auditDB: auditDB,
+		low:     make(map[trackedBalance]bool),
+	}
+}
+
+func (a *BalanceAlerts) Start(ctx context.Context) {
+	a.ctx, a.cancel = context.WithCancel(ctx)
+	if a.cfg.CheckInterval <= 0 {
+		a.cfg.CheckInterval = 5 * time.Minute
+	}
+
+	log.Infow("starting low balance alerts",
+		"escrow threshold", types.FIL(a.cfg.EscrowThreshold),
+		"publish msg threshold", types.FIL(a.cfg.PublishMsgThreshold), "publish msg wallets", a.cfg.PublishMsgWallets,
+		"collateral threshold", types.FIL(a.cfg.CollateralThreshold), "collateral wallets", a.cfg.CollateralWallets)
+
+	a.wg.Add(1)
+	go a.run(a.ctx)
+}
+
+func (a *BalanceAlerts) Stop() {
+	if a.cancel != nil {
+		a.cancel()
+	}
+	a.wg.Wait()
+}
+
+func (a *BalanceAlerts) run(ctx context.Context) {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(a.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		a.check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check compares each tracked balance against its threshold
+func (a *BalanceAlerts) check(ctx context.Context) {
+	if !a.cfg.EscrowThreshold.IsZero() {
+		tb := trackedBalance{kind: BalanceKindEscrow, wallet: a.fm.cfg.StorageMiner.String()}
+		bal, err := a.fm.AvailableEscrow(ctx)
+		a.checkBalance(ctx, tb, a.cfg.EscrowThreshold, bal, err)
+	}
+	if !a.cfg.PublishMsgThreshold.IsZero() {
+		for _, w := range a.cfg.PublishMsgWallets {
+			bal, err := a.fm.api.WalletBalance(ctx, w)
+			a.checkBalance(ctx, trackedBalance{kind: BalanceKindPublishMsg, wallet: w.String()}, a.cfg.PublishMsgThreshold, bal, err)
+		}
+	}
+	if !a.cfg.CollateralThreshold.IsZero() {
+		for _, w := range a.cfg.CollateralWallets {
+			bal, err := a.fm.api.WalletBalance(ctx, w)
+			a.checkBalance(ctx, trackedBalance{kind: BalanceKindCollateral, wallet: w.String()}, a.cfg.CollateralThreshold, bal, err)
+		}
+	}
+}
+
+func (a *BalanceAlerts) checkBalance(ctx context.Context, tb trackedBalance, threshold, bal abi.TokenAmount, err error) {
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Errorw("failed to get balance for low balance alert", "kind", tb.kind, "wallet", tb.wallet, "err", err)
+		}
+		return
+	}
+
+	isLow := bal.LessThan(threshold)
+	a.recordMetrics(tb, bal, isLow)
+
+	wasLow := a.low[tb]
+	if isLow == wasLow {
+		return
+	}
+	a.low[tb] = isLow
+
+	evt := &db.AuditEvent{
+		DealUUID: uuid.Nil,
+		Actor:    db.AuditActorSystem,
+	}
+	if isLow {
+		evt.Type = db.AuditEventLowBalance
+		evt.Detail = fmt.Sprintf("%s balance of %s (%s) is below the alert threshold of %s",
+			tb.kind, tb.wallet, types.FIL(bal), types.FIL(threshold))
+		log.Warnw("balance is below the alert threshold", "kind", tb.kind, "wallet", tb.wallet,
+			"balance", types.FIL(bal), "threshold", types.FIL(threshold))
+	} else {
+		evt.Type = db.AuditEventBalanceRestored
+		evt.Detail = fmt.Sprintf("%s balance of %s (%s) is back above the alert threshold of %s",
+			tb.kind, tb.wallet, types.FIL(bal), types.FIL(threshold))
+		log.Infow("balance is back above the alert threshold", "kind", tb.kind, "wallet", tb.wallet,
+			"balance", types.FIL(bal), "threshold", types.FIL(threshold))
+	}
+
+	if err := a.auditDB.Insert(ctx, evt); err != nil {
+		log.Errorw("failed to write low balance audit event", "kind", tb.kind, "wallet", tb.wallet, "err", err)
+	}
+}
+
+func (a *BalanceAlerts) recordMetrics(tb trackedBalance, bal abi.TokenAmount, isLow bool) {
+	ctx, err := tag.New(context.Background(), tag.Upsert(metrics.BalanceKind, tb.kind), tag.Upsert(metrics.Wallet, tb.wallet))
+	if err != nil {
+		return
+	}
+
+	balFil, _ := new(stdbig.Float).Quo(new(stdbig.Float).SetInt(bal.Int), stdbig.NewFloat(1e18)).Float64()
+	lowVal := int64(0)
+	if isLow {
+		lowVal = 1
+	}
+	stats.Record(ctx, metrics.FundsBalance.M(balFil), metrics.FundsLowBalance.M(lowVal))
+}
@@ -0,0 +1,78 @@
+package fundmanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBalanceAlerts(t *testing.T) {
+	ctx := context.Background()
+
+	sqldb := db.CreateTestTmpDB(t)
+	require.NoError(t, db.CreateAllBoostTables(ctx, sqldb, sqldb))
+	require.NoError(t, migrations.Migrate(sqldb))
+	auditDB := db.NewAuditLogDB(sqldb)
+
+	api := &mockTopUpApi{
+		escrow: abi.NewTokenAmount(100),
+		pledge: big.Zero(),
+		source: abi.NewTokenAmount(100),
+	}
+	fm := &FundManager{
+		api: api,
+		db:  db.NewFundsDB(sqldb),
+		cfg: Config{StorageMiner: address.TestAddress},
+	}
+	a := NewBalanceAlerts(BalanceAlertsConfig{
+		EscrowThreshold:     abi.NewTokenAmount(50),
+		PublishMsgThreshold: abi.NewTokenAmount(20),
+		PublishMsgWallets:   []address.Address{address.TestAddress2},
+		CollateralThreshold: big.Zero(),
+	}, fm, auditDB)
+
+	events := func() []db.AuditEvent {
+		evts, err := auditDB.Since(ctx, 0, 100)
+		require.NoError(t, err)
+		return evts
+	}
+
+	// All balances are above their thresholds
+	a.check(ctx)
+	require.Empty(t, events())
+
+	// The escrow balance falls below the threshold
+	api.escrow = abi.NewTokenAmount(40)
+	a.check(ctx)
+	evts := events()
+	require.Len(t, evts, 1)
+	require.Equal(t, db.AuditEventLowBalance, evts[0].Type)
+	require.Contains(t, evts[0].Detail, BalanceKindEscrow)
+
+	// Expect only one alert while the balance stays low
+	a.check(ctx)
+	require.Len(t, events(), 1)
+
+	// The publish wallet balance falls below the threshold
+	api.source = abi.NewTokenAmount(10)
+	a.check(ctx)
+	evts = events()
+	require.Len(t, evts, 2)
+	require.Equal(t, db.AuditEventLowBalance, evts[1].Type)
+	require.Contains(t, evts[1].Detail, BalanceKindPublishMsg)
+	require.Contains(t, evts[1].Detail, address.TestAddress2.String())
+
+	// The escrow balance is topped up
+	api.escrow = abi.NewTokenAmount(60)
+	a.check(ctx)
+	evts = events()
+	require.Len(t, evts, 3)
+	require.Equal(t, db.AuditEventBalanceRestored, evts[2].Type)
+	require.Contains(t, evts[2].Detail, BalanceKindEscrow)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/filecoin-project/boost/db"
 	"github.com/filecoin-project/go-address"
@@ -201,9 +202,32 @@ func (m *FundManager) MoveFundsToEscrow(ctx context.Context, amt abi.TokenAmount
 		return cid.Undef, fmt.Errorf("moving %d to escrow wallet %s: %w", amt, m.cfg.StorageMiner, err)
 	}
 
+	m.RecordMovement(ctx, &db.FundsMovement{
+		Type:       db.FundsMovementEscrowAdd,
+		Amount:     amt,
+		Wallet:     m.cfg.CollatWallet.String(),
+		MessageCID: msgCid.String(),
+		Text:       "Move funds to escrow",
+	})
+
 	return msgCid, err
 }
 
+// RecordMovement persists funds movements to the funds movement history.
+// The history is only used for reporting, so errors are logged rather than
+// returned.
+func (m *FundManager) RecordMovement(ctx context.Context, movements ...*db.FundsMovement) {
+	err := m.db.InsertMovement(ctx, movements...)
+	if err != nil {
+		log.Errorw("failed to persist funds movement", "err", err)
+	}
+}
+
+// Movements returns the funds movement history in the range [since, until)
+func (m *FundManager) Movements(ctx context.Context, since time.Time, until time.Time) ([]db.FundsMovement, error) {
+	return m.db.Movements(ctx, since, until)
+}
+
 // BalanceMarket returns available and locked amounts in escrow
 // (on chain with the Storage Market Actor)
 func (m *FundManager) BalanceMarket(ctx context.Context) (storagemarket.Balance, error) {
@@ -215,6 +239,20 @@ func (m *FundManager) BalanceMarket(ctx context.Context) (storagemarket.Balance,
 	return toSharedBalance(bal), nil
 }
 
+// AvailableEscrow returns the escrow balance that's available for new
+// deals: the balance that isn't locked or tagged for deals in progress
+func (m *FundManager) AvailableEscrow(ctx context.Context) (abi.TokenAmount, error) {
+	bal, err := m.BalanceMarket(ctx)
+	if err != nil {
+		return big.Zero(), err
+	}
+	tagged, err := m.TotalTagged(ctx)
+	if err != nil {
+		return big.Zero(), err
+	}
+	return big.Sub(bal.Available, tagged.Collateral), nil
+}
+
 // BalanceDealCollateral returns the amount of funds in the wallet used for
 // collateral for deal making
 func (m *FundManager) BalanceDealCollateral(ctx context.Context) (abi.TokenAmount, error) {
@@ -235,6 +273,12 @@ func (m *FundManager) AddressPublishMsg() address.Address {
 	return m.cfg.PubMsgWallet
 }
 
+// PublishMsgBalMin returns the funds reserved in the publish message wallet
+// for each deal, to pay for publishing the deal
+func (m *FundManager) PublishMsgBalMin() abi.TokenAmount {
+	return m.cfg.PubMsgBalMin
+}
+
 func toSharedBalance(bal api.MarketBalance) storagemarket.Balance {
 	return storagemarket.Balance{
 		Locked:    bal.Locked,
@@ -0,0 +1,328 @@
+package fundmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/builtin"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/v1api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+)
+
+// The text at the start of the funds log for each automatic top-up
+const topUpLogText = "Automatic top-up"
+
+// TopUpConfig configures automatic top-ups of the escrow balance (for deal
+// collateral) and of the miner actor balance (for sector pledge collateral)
+// from a source wallet
+type TopUpConfig struct {
+	// The wallet that funds are moved from
+	SourceWallet address.Address
+	// How often to check the balances
+	CheckInterval time.Duration
+	// When the escrow balance available for new deals falls below the
+	// threshold, it's topped up to the target. A zero threshold disables
+	// escrow top-ups.
+	EscrowThreshold abi.TokenAmount
+	EscrowTarget    abi.TokenAmount
+	// When the available balance of the miner actor falls below the
+	// threshold, it's topped up to the target. A zero threshold disables
+	// pledge top-ups.
+	PledgeThreshold abi.TokenAmount
+	PledgeTarget    abi.TokenAmount
+	// The maximum amount to move in one top-up (zero for no limit)
+	MaxTopUp abi.TokenAmount
+	// The maximum amount to move in any 24 hours (zero for no limit)
+	MaxPerDay abi.TokenAmount
+	// The balance to always leave in the source wallet
+	MinSourceBalance abi.TokenAmount
+	// How long to wait for a top-up message to land on chain before giving
+	// up on it, eg if it was dropped from the message pool
+	LandTimeout time.Duration
+}
+
+type topUpAPI interface {
+	fundManagerAPI
+	StateMinerAvailableBalance(context.Context, address.Address, types.TipSetKey) (types.BigInt, error)
+	MpoolPushMessage(ctx context.Context, msg *types.Message, spec *api.MessageSendSpec) (*types.SignedMessage, error)
+	StateSearchMsg(ctx context.Context, from types.TipSetKey, msg cid.Cid, limit abi.ChainEpoch, allowReplaced bool) (*api.MsgLookup, error)
+}
+
+// TopUp monitors the escrow and pledge balances, and moves funds from the
+// source wallet when they fall below their threshold. It waits for each
+// top-up message to land on chain (up to the land timeout) before topping up
+// the same balance again.
+// The top-ups are kept in the funds database, so that a top-up that is still
+// pending when boost restarts isn't sent again.
+type TopUp struct {
+	cfg TopUpConfig
+	fm  *FundManager
+	api topUpAPI
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewTopUp(cfg TopUpConfig, fm *FundManager, api v1api.FullNode) *TopUp {
+	return &TopUp{
+		cfg: cfg,
+		fm:  fm,
+		api: api,
+	}
+}
+
+func (t *TopUp) Start(ctx context.Context) {
+	t.ctx, t.cancel = context.WithCancel(ctx)
+	if t.cfg.CheckInterval <= 0 {
+		t.cfg.CheckInterval = 5 * time.Minute
+	}
+
+	log.Infow("starting automatic funds top-up", "source", t.cfg.SourceWallet,
+		"escrow threshold", types.FIL(t.cfg.EscrowThreshold), "escrow target", types.FIL(t.cfg.EscrowTarget),
+		"pledge threshold", types.FIL(t.cfg.PledgeThreshold), "pledge target", types.FIL(t.cfg.PledgeTarget))
+
+	t.wg.Add(1)
+	go t.run(t.ctx)
+}
+
+func (t *TopUp) Stop() {
+	if t.cancel != nil {
+		t.cancel()
+	}
+	t.wg.Wait()
+}
+
+func (t *TopUp) run(ctx context.Context) {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(t.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		t.check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// The type of funds movement recorded for a top-up of each kind of balance
+var topUpMovementTypes = map[string]string{
+	db.FundsTopUpEscrow: db.FundsMovementEscrowAdd,
+	db.FundsTopUpPledge: db.FundsMovementMinerBalanceAdd,
+}
+
+// check tops up each balance that is below its threshold
+func (t *TopUp) check(ctx context.Context) {
+	if !t.cfg.EscrowThreshold.IsZero() {
+		err := t.topUp(ctx, db.FundsTopUpEscrow, t.cfg.EscrowThreshold, t.cfg.EscrowTarget, t.availableEscrow, t.sendToEscrow)
+		if err != nil && ctx.Err() == nil {
+			log.Errorw("failed to top up escrow", "err", err)
+		}
+	}
+	if !t.cfg.PledgeThreshold.IsZero() {
+		err := t.topUp(ctx, db.FundsTopUpPledge, t.cfg.PledgeThreshold, t.cfg.PledgeTarget, t.availablePledge, t.sendToMiner)
+		if err != nil && ctx.Err() == nil {
+			log.Errorw("failed to top up miner pledge balance", "err", err)
+		}
+	}
+}
+
+func (t *TopUp) topUp(ctx context.Context, kind string, threshold, target abi.TokenAmount,
+	available func(context.Context) (abi.TokenAmount, error),
+	send func(context.Context, abi.TokenAmount) (cid.Cid, error)) error {
+
+	// Wait for the last top-up to land so that the balance includes it
+	landed, err := t.pendingLanded(ctx, kind)
+	if err != nil || !landed {
+		return err
+	}
+
+	avail, err := available(ctx)
+	if err != nil {
+		return fmt.Errorf("getting available %s balance: %w", kind, err)
+	}
+	if !avail.LessThan(threshold) {
+		return nil
+	}
+
+	amt, reason, err := t.topUpAmount(ctx, big.Sub(target, avail))
+	if err != nil {
+		return err
+	}
+	if !amt.GreaterThan(big.Zero()) {
+		log.Warnw("available balance is below the top-up threshold but funds can't be moved: "+reason,
+			"kind", kind, "available", types.FIL(avail), "threshold", types.FIL(threshold))
+		return nil
+	}
+
+	msgCid, err := send(ctx, amt)
+	if err != nil {
+		return err
+	}
+
+	err = t.fm.db.InsertTopUp(ctx, &db.FundsTopUp{
+		Kind:       kind,
+		Amount:     amt,
+		Wallet:     t.cfg.SourceWallet.String(),
+		MessageCID: msgCid.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("persisting %s top-up (message %s): %w", kind, msgCid, err)
+	}
+
+	err = t.fm.db.InsertLog(ctx, &db.FundsLog{
+		DealUUID: uuid.Nil,
+		Amount:   amt,
+		Text:     fmt.Sprintf("%s of %s from %s (message %s)", topUpLogText, kind, t.cfg.SourceWallet, msgCid),
+	})
+	if err != nil {
+		log.Errorw("failed to persist top-up funds log", "err", err)
+	}
+	t.fm.RecordMovement(ctx, &db.FundsMovement{
+		Type:       topUpMovementTypes[kind],
+		Amount:     amt,
+		Wallet:     t.cfg.SourceWallet.String(),
+		MessageCID: msgCid.String(),
+		Text:       fmt.Sprintf("%s of %s", topUpLogText, kind),
+	})
+
+	log.Infow("topped up balance", "kind", kind, "amount", types.FIL(amt), "available", types.FIL(avail),
+		"threshold", types.FIL(threshold), "target", types.FIL(target), "source", t.cfg.SourceWallet, "msg", msgCid)
+	return nil
+}
+
+// The default time to wait for a top-up message to land on chain
+const defaultLandTimeout = 2 * time.Hour
+
+// pendingLanded returns true if there is no pending top-up of the given kind,
+// or if the pending top-up message has been found on chain, or if it hasn't
+// landed within the land timeout
+func (t *TopUp) pendingLanded(ctx context.Context, kind string) (bool, error) {
+	pending, err := t.fm.db.PendingTopUp(ctx, kind)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	msgCid, err := cid.Parse(pending.MessageCID)
+	if err != nil {
+		return false, fmt.Errorf("parsing %s top-up message cid %s: %w", kind, pending.MessageCID, err)
+	}
+
+	lookup, err := t.api.StateSearchMsg(ctx, types.EmptyTSK, msgCid, api.LookbackNoLimit, true)
+	if err != nil {
+		return false, fmt.Errorf("searching for %s top-up message %s: %w", kind, msgCid, err)
+	}
+	if lookup == nil {
+		if time.Since(pending.CreatedAt) < t.landTimeout() {
+			log.Debugw("waiting for top-up message to land on chain", "kind", kind, "msg", msgCid)
+			return false, nil
+		}
+
+		// The message may have been dropped from the message pool. Stop
+		// waiting for it, so that the balance can be topped up again.
+		// The top-up still counts towards the daily limit, in case the
+		// message lands later.
+		log.Warnw("top-up message did not land on chain in time: no longer waiting for it",
+			"kind", kind, "msg", msgCid, "sent", pending.CreatedAt, "timeout", t.landTimeout())
+		if err := t.fm.db.SetTopUpTimedOut(ctx, pending.MessageCID); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	if lookup.Receipt.ExitCode.IsError() {
+		log.Errorw("top-up message failed", "kind", kind, "msg", msgCid, "exit code", lookup.Receipt.ExitCode)
+	}
+	if err := t.fm.db.SetTopUpLanded(ctx, pending.MessageCID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (t *TopUp) landTimeout() time.Duration {
+	if t.cfg.LandTimeout <= 0 {
+		return defaultLandTimeout
+	}
+	return t.cfg.LandTimeout
+}
+
+// topUpAmount returns the amount to top up by, which is the amount needed
+// limited by the maximum per top-up, the amount left of the daily limit and
+// the funds in the source wallet. If the amount is zero it returns the
+// reason.
+func (t *TopUp) topUpAmount(ctx context.Context, needed abi.TokenAmount) (abi.TokenAmount, string, error) {
+	amt := needed
+	if !t.cfg.MaxTopUp.IsZero() {
+		amt = big.Min(amt, t.cfg.MaxTopUp)
+	}
+
+	if !t.cfg.MaxPerDay.IsZero() {
+		movedToday, err := t.fm.db.TopUpsTotalSince(ctx, time.Now().Add(-24*time.Hour))
+		if err != nil {
+			return big.Zero(), "", fmt.Errorf("getting total top-ups in the last 24 hours: %w", err)
+		}
+		leftToday := big.Sub(t.cfg.MaxPerDay, movedToday)
+		if !leftToday.GreaterThan(big.Zero()) {
+			return big.Zero(), fmt.Sprintf("the daily top-up limit of %s has been reached", types.FIL(t.cfg.MaxPerDay)), nil
+		}
+		amt = big.Min(amt, leftToday)
+	}
+
+	srcBal, err := t.api.WalletBalance(ctx, t.cfg.SourceWallet)
+	if err != nil {
+		return big.Zero(), "", fmt.Errorf("getting balance of source wallet %s: %w", t.cfg.SourceWallet, err)
+	}
+	spendable := big.Sub(srcBal, t.cfg.MinSourceBalance)
+	if !spendable.GreaterThan(big.Zero()) {
+		return big.Zero(), fmt.Sprintf("the source wallet %s balance %s is at or below the minimum %s",
+			t.cfg.SourceWallet, types.FIL(srcBal), types.FIL(t.cfg.MinSourceBalance)), nil
+	}
+	return big.Min(amt, spendable), "", nil
+}
+
+func (t *TopUp) availableEscrow(ctx context.Context) (abi.TokenAmount, error) {
+	return t.fm.AvailableEscrow(ctx)
+}
+
+func (t *TopUp) sendToEscrow(ctx context.Context, amt abi.TokenAmount) (cid.Cid, error) {
+	msgCid, err := t.api.MarketAddBalance(ctx, t.cfg.SourceWallet, t.fm.cfg.StorageMiner, amt)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("moving %s from %s to escrow for %s: %w", types.FIL(amt), t.cfg.SourceWallet, t.fm.cfg.StorageMiner, err)
+	}
+	return msgCid, nil
+}
+
+func (t *TopUp) availablePledge(ctx context.Context) (abi.TokenAmount, error) {
+	return t.api.StateMinerAvailableBalance(ctx, t.fm.cfg.StorageMiner, types.EmptyTSK)
+}
+
+func (t *TopUp) sendToMiner(ctx context.Context, amt abi.TokenAmount) (cid.Cid, error) {
+	smsg, err := t.api.MpoolPushMessage(ctx, &types.Message{
+		From:   t.cfg.SourceWallet,
+		To:     t.fm.cfg.StorageMiner,
+		Value:  amt,
+		Method: builtin.MethodSend,
+	}, nil)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("sending %s from %s to miner %s: %w", types.FIL(amt), t.cfg.SourceWallet, t.fm.cfg.StorageMiner, err)
+	}
+	return smsg.Cid(), nil
+}
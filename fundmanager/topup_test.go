@@ -0,0 +1,337 @@
+package fundmanager
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopUp(t *testing.T) {
+	ctx := context.Background()
+	miner := address.TestAddress
+	source := address.TestAddress2
+
+	newTopUp := func(t *testing.T, cfg TopUpConfig) (*TopUp, *mockTopUpApi, *db.FundsDB) {
+		sqldb := db.CreateTestTmpDB(t)
+		require.NoError(t, db.CreateAllBoostTables(ctx, sqldb, sqldb))
+		require.NoError(t, migrations.Migrate(sqldb))
+		fundsDB := db.NewFundsDB(sqldb)
+
+		api := &mockTopUpApi{
+			escrow:      abi.NewTokenAmount(0),
+			pledge:      abi.NewTokenAmount(0),
+			source:      abi.NewTokenAmount(1000),
+			pending:     make(map[cid.Cid]func()),
+			landOnChain: true,
+		}
+		fm := &FundManager{
+			api: api,
+			db:  fundsDB,
+			cfg: Config{StorageMiner: miner},
+		}
+		cfg.SourceWallet = source
+		tu := &TopUp{cfg: cfg, fm: fm, api: api}
+		return tu, api, fundsDB
+	}
+
+	defaultCfg := func() TopUpConfig {
+		return TopUpConfig{
+			EscrowThreshold:  abi.NewTokenAmount(50),
+			EscrowTarget:     abi.NewTokenAmount(100),
+			PledgeThreshold:  abi.NewTokenAmount(20),
+			PledgeTarget:     abi.NewTokenAmount(40),
+			MaxTopUp:         big.Zero(),
+			MaxPerDay:        big.Zero(),
+			MinSourceBalance: big.Zero(),
+		}
+	}
+
+	t.Run("tops up escrow and pledge to the target", func(t *testing.T) {
+		tu, api, fundsDB := newTopUp(t, defaultCfg())
+		api.escrow = abi.NewTokenAmount(30)
+		api.pledge = abi.NewTokenAmount(10)
+
+		tu.check(ctx)
+		require.EqualValues(t, 100, api.escrow.Int64())
+		require.EqualValues(t, 40, api.pledge.Int64())
+		require.EqualValues(t, 1000-70-30, api.source.Int64())
+
+		logs, err := fundsDB.Logs(ctx, nil, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, logs, 2)
+		for _, l := range logs {
+			require.Equal(t, uuid.Nil, l.DealUUID)
+			require.True(t, strings.HasPrefix(l.Text, topUpLogText))
+		}
+
+		movements, err := fundsDB.Movements(ctx, time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, movements, 2)
+		require.Equal(t, db.FundsMovementEscrowAdd, movements[0].Type)
+		require.EqualValues(t, 70, movements[0].Amount.Int64())
+		require.Equal(t, db.FundsMovementMinerBalanceAdd, movements[1].Type)
+		require.EqualValues(t, 30, movements[1].Amount.Int64())
+		require.Equal(t, source.String(), movements[1].Wallet)
+
+		// The balances are above the threshold so expect no more top-ups
+		tu.check(ctx)
+		require.EqualValues(t, 1000-70-30, api.source.Int64())
+	})
+
+	t.Run("does not top up above the threshold", func(t *testing.T) {
+		tu, api, _ := newTopUp(t, defaultCfg())
+		api.escrow = abi.NewTokenAmount(50)
+		api.pledge = abi.NewTokenAmount(20)
+
+		tu.check(ctx)
+		require.EqualValues(t, 1000, api.source.Int64())
+	})
+
+	t.Run("available escrow excludes funds tagged for deals", func(t *testing.T) {
+		tu, api, fundsDB := newTopUp(t, defaultCfg())
+		api.escrow = abi.NewTokenAmount(60)
+		api.pledge = abi.NewTokenAmount(40)
+		require.NoError(t, fundsDB.Tag(ctx, uuid.New(), abi.NewTokenAmount(20), abi.NewTokenAmount(0)))
+
+		tu.check(ctx)
+		require.EqualValues(t, 120, api.escrow.Int64())
+	})
+
+	t.Run("waits for the pending top-up to land", func(t *testing.T) {
+		tu, api, _ := newTopUp(t, defaultCfg())
+		api.escrow = abi.NewTokenAmount(0)
+		api.pledge = abi.NewTokenAmount(40)
+		api.landOnChain = false
+
+		tu.check(ctx)
+		require.EqualValues(t, 0, api.escrow.Int64())
+		require.EqualValues(t, 900, api.source.Int64())
+
+		// The message hasn't landed so expect no new top-up
+		tu.check(ctx)
+		require.EqualValues(t, 900, api.source.Int64())
+
+		// Once the message lands the balance is above the threshold
+		api.land()
+		tu.check(ctx)
+		require.EqualValues(t, 100, api.escrow.Int64())
+		require.EqualValues(t, 900, api.source.Int64())
+	})
+
+	t.Run("waits for a pending top-up sent before a restart", func(t *testing.T) {
+		tu, api, _ := newTopUp(t, defaultCfg())
+		api.escrow = abi.NewTokenAmount(0)
+		api.pledge = abi.NewTokenAmount(40)
+		api.landOnChain = false
+
+		tu.check(ctx)
+		require.EqualValues(t, 900, api.source.Int64())
+
+		// A new top-up with the same database finds the pending top-up
+		restarted := &TopUp{cfg: tu.cfg, fm: tu.fm, api: api}
+		restarted.check(ctx)
+		require.EqualValues(t, 900, api.source.Int64())
+
+		api.land()
+		restarted.check(ctx)
+		require.EqualValues(t, 100, api.escrow.Int64())
+		require.EqualValues(t, 900, api.source.Int64())
+	})
+
+	t.Run("stops waiting for a top-up that doesn't land", func(t *testing.T) {
+		cfg := defaultCfg()
+		cfg.LandTimeout = time.Millisecond
+		tu, api, fundsDB := newTopUp(t, cfg)
+		api.escrow = abi.NewTokenAmount(0)
+		api.pledge = abi.NewTokenAmount(40)
+		api.landOnChain = false
+
+		tu.check(ctx)
+		require.EqualValues(t, 900, api.source.Int64())
+
+		// The message is dropped, so after the timeout expect another top-up
+		time.Sleep(10 * time.Millisecond)
+		tu.check(ctx)
+		require.EqualValues(t, 800, api.source.Int64())
+
+		_, err := fundsDB.PendingTopUp(ctx, db.FundsTopUpEscrow)
+		require.NoError(t, err)
+		total, err := fundsDB.TopUpsTotalSince(ctx, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		require.EqualValues(t, 200, total.Int64())
+	})
+
+	t.Run("limits each top-up", func(t *testing.T) {
+		cfg := defaultCfg()
+		cfg.MaxTopUp = abi.NewTokenAmount(30)
+		tu, api, _ := newTopUp(t, cfg)
+		api.pledge = abi.NewTokenAmount(40)
+
+		tu.check(ctx)
+		require.EqualValues(t, 30, api.escrow.Int64())
+		tu.check(ctx)
+		require.EqualValues(t, 60, api.escrow.Int64())
+	})
+
+	t.Run("limits top-ups per day", func(t *testing.T) {
+		cfg := defaultCfg()
+		cfg.MaxPerDay = abi.NewTokenAmount(120)
+		tu, api, _ := newTopUp(t, cfg)
+
+		// Escrow is topped up by 100, pledge by the remaining 20
+		tu.check(ctx)
+		require.EqualValues(t, 100, api.escrow.Int64())
+		require.EqualValues(t, 20, api.pledge.Int64())
+
+		// The daily limit has been reached
+		api.escrow = abi.NewTokenAmount(0)
+		api.pledge = abi.NewTokenAmount(0)
+		tu.check(ctx)
+		require.EqualValues(t, 0, api.escrow.Int64())
+		require.EqualValues(t, 0, api.pledge.Int64())
+		require.EqualValues(t, 880, api.source.Int64())
+	})
+
+	t.Run("leaves the minimum balance in the source wallet", func(t *testing.T) {
+		cfg := defaultCfg()
+		cfg.MinSourceBalance = abi.NewTokenAmount(950)
+		tu, api, _ := newTopUp(t, cfg)
+		api.pledge = abi.NewTokenAmount(40)
+
+		tu.check(ctx)
+		require.EqualValues(t, 50, api.escrow.Int64())
+		require.EqualValues(t, 950, api.source.Int64())
+
+		api.escrow = abi.NewTokenAmount(0)
+		tu.check(ctx)
+		require.EqualValues(t, 0, api.escrow.Int64())
+		require.EqualValues(t, 950, api.source.Int64())
+	})
+
+	t.Run("zero threshold disables top-up", func(t *testing.T) {
+		cfg := defaultCfg()
+		cfg.PledgeThreshold = big.Zero()
+		tu, api, _ := newTopUp(t, cfg)
+
+		tu.check(ctx)
+		require.EqualValues(t, 100, api.escrow.Int64())
+		require.EqualValues(t, 0, api.pledge.Int64())
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		cfg := defaultCfg()
+		cfg.CheckInterval = time.Millisecond
+		tu, api, _ := newTopUp(t, cfg)
+
+		tu.Start(ctx)
+		require.Eventually(t, func() bool {
+			api.lk.Lock()
+			defer api.lk.Unlock()
+			return api.escrow.Int64() == 100 && api.pledge.Int64() == 40
+		}, time.Second, time.Millisecond)
+		tu.Stop()
+	})
+}
+
+// mockTopUpApi keeps track of the escrow, pledge and source wallet balances
+type mockTopUpApi struct {
+	lk     sync.Mutex
+	escrow abi.TokenAmount
+	pledge abi.TokenAmount
+	source abi.TokenAmount
+
+	// When landOnChain is false, messages are pending until land is called
+	landOnChain bool
+	pending     map[cid.Cid]func()
+	msgCount    int
+}
+
+var _ topUpAPI = (*mockTopUpApi)(nil)
+
+func (m *mockTopUpApi) MarketAddBalance(ctx context.Context, wallet, addr address.Address, amt types.BigInt) (cid.Cid, error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	m.msgCount++
+	mh, err := multihash.Sum([]byte{byte(m.msgCount)}, multihash.SHA2_256, -1)
+	if err != nil {
+		return cid.Undef, err
+	}
+	msgCid := cid.NewCidV1(cid.Raw, mh)
+	m.push(msgCid, amt, func() { m.escrow = big.Add(m.escrow, amt) })
+	return msgCid, nil
+}
+
+func (m *mockTopUpApi) MpoolPushMessage(ctx context.Context, msg *types.Message, spec *lapi.MessageSendSpec) (*types.SignedMessage, error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	m.msgCount++
+	smsg := &types.SignedMessage{Message: *msg}
+	smsg.Message.Nonce = uint64(m.msgCount)
+	amt := msg.Value
+	m.push(smsg.Cid(), amt, func() { m.pledge = big.Add(m.pledge, amt) })
+	return smsg, nil
+}
+
+// push deducts the amount from the source wallet, and applies the message
+// straight away, or when land is called
+func (m *mockTopUpApi) push(msgCid cid.Cid, amt abi.TokenAmount, apply func()) {
+	m.source = big.Sub(m.source, amt)
+	if m.landOnChain {
+		apply()
+	} else {
+		m.pending[msgCid] = apply
+	}
+}
+
+func (m *mockTopUpApi) land() {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	for c, apply := range m.pending {
+		apply()
+		delete(m.pending, c)
+	}
+}
+
+func (m *mockTopUpApi) StateSearchMsg(ctx context.Context, from types.TipSetKey, msg cid.Cid, limit abi.ChainEpoch, allowReplaced bool) (*lapi.MsgLookup, error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	if _, ok := m.pending[msg]; ok {
+		return nil, nil
+	}
+	return &lapi.MsgLookup{Message: msg, Receipt: types.MessageReceipt{ExitCode: exitcode.Ok}}, nil
+}
+
+func (m *mockTopUpApi) StateMarketBalance(ctx context.Context, a address.Address, key types.TipSetKey) (lapi.MarketBalance, error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return lapi.MarketBalance{Escrow: m.escrow, Locked: big.Zero()}, nil
+}
+
+func (m *mockTopUpApi) StateMinerAvailableBalance(ctx context.Context, a address.Address, key types.TipSetKey) (types.BigInt, error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return m.pledge, nil
+}
+
+func (m *mockTopUpApi) WalletBalance(ctx context.Context, a address.Address) (types.BigInt, error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return m.source, nil
+}
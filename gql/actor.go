@@ -0,0 +1,77 @@
+package gql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/filecoin-project/go-jsonrpc/auth"
+)
+
+type actorCtxKey struct{}
+
+// The actor recorded for requests that weren't made with a valid API token
+const (
+	actorNoToken      = "graphql (no token)"
+	actorInvalidToken = "graphql (invalid token)"
+)
+
+// actorHandler records the identity of the caller in the request context,
+// so that admin actions can be attributed in the audit log.
+// The identity is a fingerprint of the API token that the request was made
+// with (the first bytes of the sha256 hash of the token) and the token's
+// permissions, eg "token 3a7bd3e2 (read,write,admin)".
+type actorHandler struct {
+	verify AuthVerifier
+	sub    http.Handler
+}
+
+func (h *actorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := context.WithValue(r.Context(), actorCtxKey{}, h.actor(r))
+	h.sub.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func (h *actorHandler) actor(r *http.Request) string {
+	token := requestToken(r)
+	if token == "" {
+		return actorNoToken
+	}
+	if h.verify == nil {
+		return actorInvalidToken
+	}
+
+	perms, err := h.verify(r.Context(), token)
+	if err != nil {
+		return actorInvalidToken
+	}
+	return tokenActor(token, perms)
+}
+
+// requestToken gets the API token from the Authorization header, or from
+// the token query parameter (for web sockets)
+func requestToken(r *http.Request) string {
+	token := r.Header.Get("Authorization")
+	if token == "" {
+		return r.URL.Query().Get("token")
+	}
+	return strings.TrimSpace(strings.TrimPrefix(token, "Bearer "))
+}
+
+func tokenActor(token string, perms []auth.Permission) string {
+	sum := sha256.Sum256([]byte(token))
+	permStrs := make([]string, 0, len(perms))
+	for _, p := range perms {
+		permStrs = append(permStrs, string(p))
+	}
+	return fmt.Sprintf("token %s (%s)", hex.EncodeToString(sum[:4]), strings.Join(permStrs, ","))
+}
+
+func actorFromCtx(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorCtxKey{}).(string); ok {
+		return actor
+	}
+	return actorNoToken
+}
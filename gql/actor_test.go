@@ -0,0 +1,58 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/filecoin-project/boost/api"
+	"github.com/filecoin-project/go-jsonrpc/auth"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActorHandler(t *testing.T) {
+	verify := func(ctx context.Context, token string) ([]auth.Permission, error) {
+		if token != "admin-token" {
+			return nil, errors.New("invalid token")
+		}
+		return []auth.Permission{api.PermRead, api.PermWrite, api.PermAdmin}, nil
+	}
+
+	var actor string
+	h := &actorHandler{verify: verify, sub: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor = actorFromCtx(r.Context())
+	})}
+
+	tcs := []struct {
+		name   string
+		header string
+		query  string
+		expect string
+	}{
+		{"no token", "", "", actorNoToken},
+		{"invalid token", "Bearer other-token", "", actorInvalidToken},
+		{"header token", "Bearer admin-token", "", tokenActor("admin-token", []auth.Permission{api.PermRead, api.PermWrite, api.PermAdmin})},
+		{"query token", "", "admin-token", tokenActor("admin-token", []auth.Permission{api.PermRead, api.PermWrite, api.PermAdmin})},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			target := "/graphql/query"
+			if tc.query != "" {
+				target += "?token=" + tc.query
+			}
+			req := httptest.NewRequest(http.MethodPost, target, nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			require.Equal(t, tc.expect, actor)
+		})
+	}
+
+	// The actor identifies the token without including it
+	tokActor := tokenActor("admin-token", []auth.Permission{api.PermRead, api.PermAdmin})
+	require.Regexp(t, `^token [0-9a-f]{8} \(read,admin\)$`, tokActor)
+	require.NotContains(t, tokActor, "admin-token")
+}
@@ -0,0 +1,42 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/filecoin-project/boost/api"
+	"github.com/filecoin-project/go-jsonrpc/auth"
+)
+
+// AuthVerifier verifies an API token and returns the token's permissions
+type AuthVerifier func(ctx context.Context, token string) ([]auth.Permission, error)
+
+// authHandler verifies the API token sent with the request (in the
+// Authorization header, or the token query parameter for web sockets) and
+// rejects requests that don't have at least read permission
+func authHandler(verify AuthVerifier, sub http.Handler) http.Handler {
+	return &auth.Handler{
+		Verify: verify,
+		Next: func(w http.ResponseWriter, r *http.Request) {
+			if !auth.HasPerm(r.Context(), nil, api.PermRead) {
+				log.Warnw("rejecting graphql request without API token", "remote", r.RemoteAddr)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			sub.ServeHTTP(w, r)
+		},
+	}
+}
+
+// checkPerm returns an error if authentication is required and the token
+// that the request was made with doesn't have the given permission
+func (r *resolver) checkPerm(ctx context.Context, perm auth.Permission) error {
+	if !r.cfg.Graphql.RequireAuth {
+		return nil
+	}
+	if !auth.HasPerm(ctx, nil, perm) {
+		return fmt.Errorf("missing permission: the API token must have %s permission", perm)
+	}
+	return nil
+}
@@ -1,16 +1,48 @@
 package gql
 
-import "net/http"
+import (
+	"net/http"
+	"net/url"
+	"strings"
 
-// Sets CORS headers to allow all
+	"github.com/filecoin-project/boost/api"
+)
+
+// Sets CORS headers.
+// If no origins are configured, requests from all origins are allowed.
+// Otherwise requests from other origins (including web socket connections,
+// which are not subject to CORS in the browser) are rejected.
 type corsHandler struct {
-	sub http.Handler
+	allowedOrigins map[string]struct{}
+	sub            http.Handler
+}
+
+func newCorsHandler(allowedOrigins []string, sub http.Handler) *corsHandler {
+	var allowed map[string]struct{}
+	if len(allowedOrigins) > 0 {
+		allowed = make(map[string]struct{}, len(allowedOrigins))
+		for _, o := range allowedOrigins {
+			allowed[strings.ToLower(strings.TrimSuffix(o, "/"))] = struct{}{}
+		}
+	}
+	return &corsHandler{allowedOrigins: allowed, sub: sub}
 }
 
 func (h *corsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if h.allowedOrigins == nil {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else if origin := r.Header.Get("Origin"); origin != "" {
+		if !h.isAllowed(origin, r) {
+			log.Debugw("rejecting request from disallowed origin", "origin", origin, "remote", r.RemoteAddr)
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+	}
 	w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, DELETE, PUT")
 	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Expose-Headers", api.VersionHeader+", Deprecation, Warning")
 	if r.Method == "OPTIONS" {
 		_, _ = w.Write([]byte("OK"))
 		return
@@ -18,3 +50,13 @@ func (h *corsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	h.sub.ServeHTTP(w, r)
 }
+
+func (h *corsHandler) isAllowed(origin string, r *http.Request) bool {
+	if _, ok := h.allowedOrigins[strings.ToLower(origin)]; ok {
+		return true
+	}
+
+	// Always allow requests from the same origin, eg from the boost UI
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
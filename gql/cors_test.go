@@ -0,0 +1,45 @@
+package gql
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorsHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	serve := func(h http.Handler, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "http://boost.local:8080/graphql/query", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	// With no allowed origins configured, all origins are allowed
+	w := serve(newCorsHandler(nil, ok), "https://example.com")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+
+	h := newCorsHandler([]string{"https://Example.com/"}, ok)
+
+	w = serve(h, "https://example.com")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "https://example.com", w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = serve(h, "https://evil.com")
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	// Requests from the same origin and requests without an origin (eg
+	// from scripts) are always allowed
+	w = serve(h, "http://boost.local:8080")
+	require.Equal(t, http.StatusOK, w.Code)
+	w = serve(h, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
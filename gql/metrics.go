@@ -0,0 +1,42 @@
+package gql
+
+import (
+	"context"
+	"time"
+
+	"github.com/filecoin-project/boost/metrics"
+	"github.com/graph-gophers/graphql-go/errors"
+	"github.com/graph-gophers/graphql-go/trace"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// metricsTracer records the count, duration and errors of each call to a
+// graphql field resolver, tagged with the name of the field (eg
+// RootQuery.deals), so that operators can see which resolvers are slow.
+// Fields that are resolved directly from a struct field (trivial fields)
+// are not recorded.
+type metricsTracer struct {
+	trace.OpenTracingTracer
+}
+
+var _ trace.Tracer = (*metricsTracer)(nil)
+var _ trace.ValidationTracerContext = (*metricsTracer)(nil)
+
+func (t *metricsTracer) TraceField(ctx context.Context, label, typeName, fieldName string, trivial bool, args map[string]interface{}) (context.Context, trace.TraceFieldFinishFunc) {
+	spanCtx, finishSpan := t.OpenTracingTracer.TraceField(ctx, label, typeName, fieldName, trivial, args)
+	if trivial {
+		return spanCtx, finishSpan
+	}
+
+	start := time.Now()
+	return spanCtx, func(err *errors.QueryError) {
+		finishSpan(err)
+
+		mctx, _ := tag.New(ctx, tag.Upsert(metrics.GraphqlField, typeName+"."+fieldName))
+		stats.Record(mctx, metrics.GraphqlRequestCount.M(1), metrics.GraphqlRequestDuration.M(metrics.SinceInMilliseconds(start)))
+		if err != nil {
+			stats.Record(mctx, metrics.GraphqlRequestErrorCount.M(1))
+		}
+	}
+}
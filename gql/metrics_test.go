@@ -0,0 +1,40 @@
+package gql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/boost/metrics"
+	"github.com/graph-gophers/graphql-go/errors"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+)
+
+func TestMetricsTracer(t *testing.T) {
+	views := []*view.View{metrics.GraphqlRequestCountView, metrics.GraphqlRequestErrorCountView}
+	require.NoError(t, view.Register(views...))
+	defer view.Unregister(views...)
+
+	ctx := context.Background()
+	tracer := &metricsTracer{}
+
+	// Trivial fields should not be recorded
+	_, finish := tracer.TraceField(ctx, "", "Deal", "ID", true, nil)
+	finish(nil)
+
+	_, finish = tracer.TraceField(ctx, "", "RootQuery", "deals", false, nil)
+	finish(nil)
+	_, finish = tracer.TraceField(ctx, "", "RootQuery", "deals", false, nil)
+	finish(&errors.QueryError{Message: "failed"})
+
+	rows, err := view.RetrieveData(metrics.GraphqlRequestCountView.Name)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, "RootQuery.deals", rows[0].Tags[0].Value)
+	require.EqualValues(t, 2, rows[0].Data.(*view.CountData).Value)
+
+	rows, err = view.RetrieveData(metrics.GraphqlRequestErrorCountView.Name)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.EqualValues(t, 1, rows[0].Data.(*view.CountData).Value)
+}
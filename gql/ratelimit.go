@@ -0,0 +1,132 @@
+package gql
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Clients that haven't made a request for this long are forgotten by the
+// rate limiter
+const rateLimitClientExpiry = 10 * time.Minute
+
+type clientLimiter struct {
+	lim      *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter limits the rate of requests from each client.
+// Clients are identified by their API token if byToken is true, otherwise
+// by their IP address.
+type rateLimiter struct {
+	limit             rate.Limit
+	burst             int
+	byToken           bool
+	trustForwardedFor bool
+
+	lk        sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+func newRateLimiter(perSecond float64, burst int, byToken bool, trustForwardedFor bool) *rateLimiter {
+	if burst <= 0 {
+		burst = 1
+	}
+	return &rateLimiter{
+		limit:             rate.Limit(perSecond),
+		burst:             burst,
+		byToken:           byToken,
+		trustForwardedFor: trustForwardedFor,
+		clients:           make(map[string]*clientLimiter),
+		lastSweep:         time.Now(),
+	}
+}
+
+func (rl *rateLimiter) allow(key string, now time.Time) bool {
+	rl.lk.Lock()
+	defer rl.lk.Unlock()
+
+	// Periodically remove clients that haven't been seen for a while so that
+	// the map doesn't grow without bound
+	if now.Sub(rl.lastSweep) > time.Minute {
+		for k, cl := range rl.clients {
+			if now.Sub(cl.lastSeen) > rateLimitClientExpiry {
+				delete(rl.clients, k)
+			}
+		}
+		rl.lastSweep = now
+	}
+
+	cl, ok := rl.clients[key]
+	if !ok {
+		cl = &clientLimiter{lim: rate.NewLimiter(rl.limit, rl.burst)}
+		rl.clients[key] = cl
+	}
+	cl.lastSeen = now
+	return cl.lim.AllowN(now, 1)
+}
+
+// clientKey identifies the client that made the request
+func (rl *rateLimiter) clientKey(r *http.Request) string {
+	if rl.byToken {
+		// The request has already been through the auth handler, so the
+		// token has been verified
+		if token := r.Header.Get("Authorization"); token != "" {
+			return "token:" + strings.TrimPrefix(token, "Bearer ")
+		}
+		if token := r.URL.Query().Get("token"); token != "" {
+			return "token:" + token
+		}
+	}
+	return "ip:" + clientIP(r, rl.trustForwardedFor)
+}
+
+// clientIP returns the IP address of the client that made the request.
+// If trustForwardedFor is true, the IP address is read from the
+// X-Forwarded-For header set by a reverse proxy.
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			// The left-most address is the original client
+			ip, _, _ := strings.Cut(fwd, ",")
+			return strings.TrimSpace(ip)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimitHandler rejects requests from clients that exceed the rate
+// limit with http status 429 (Too Many Requests)
+func rateLimitHandler(rl *rateLimiter, sub http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := rl.clientKey(r)
+		if !rl.allow(key, time.Now()) {
+			log.Debugw("rate limiting graphql request", "remote", r.RemoteAddr)
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		sub.ServeHTTP(w, r)
+	})
+}
+
+// maxBytesHandler caps the size of the request body
+func maxBytesHandler(maxBytes int64, sub http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxBytes {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		sub.ServeHTTP(w, r)
+	})
+}
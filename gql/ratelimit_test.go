@@ -0,0 +1,41 @@
+package gql
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	rl := newRateLimiter(1, 2, false, false)
+	now := time.Now()
+
+	// The client can make a burst of two requests
+	require.True(t, rl.allow("a", now))
+	require.True(t, rl.allow("a", now))
+	require.False(t, rl.allow("a", now))
+
+	// Each client has its own limit
+	require.True(t, rl.allow("b", now))
+
+	// After a second the client can make another request
+	require.True(t, rl.allow("a", now.Add(time.Second)))
+	require.False(t, rl.allow("a", now.Add(time.Second)))
+
+	// Clients that haven't been seen for a while are removed
+	rl.allow("b", now.Add(rateLimitClientExpiry+2*time.Minute))
+	require.Len(t, rl.clients, 1)
+}
+
+func TestRateLimiterClientKey(t *testing.T) {
+	req := httptest.NewRequest("POST", "/graphql/query", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 10.0.0.2")
+	req.Header.Set("Authorization", "Bearer my-token")
+
+	require.Equal(t, "ip:10.0.0.1", newRateLimiter(1, 1, false, false).clientKey(req))
+	require.Equal(t, "ip:1.2.3.4", newRateLimiter(1, 1, false, true).clientKey(req))
+	require.Equal(t, "token:my-token", newRateLimiter(1, 1, true, false).clientKey(req))
+}
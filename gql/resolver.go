@@ -8,18 +8,25 @@ import (
 	"math"
 
 	"github.com/dustin/go-humanize"
+	"github.com/filecoin-project/boost/api"
 	"github.com/filecoin-project/boost/db"
 	"github.com/filecoin-project/boost/fundmanager"
 	gqltypes "github.com/filecoin-project/boost/gql/types"
+	"github.com/filecoin-project/boost/indexprovider"
+	"github.com/filecoin-project/boost/markets/sectoraccessor"
 	"github.com/filecoin-project/boost/markets/storageadapter"
 	"github.com/filecoin-project/boost/node/config"
+	"github.com/filecoin-project/boost/piecedoctor"
+	"github.com/filecoin-project/boost/retrievalmarket/quota"
 	"github.com/filecoin-project/boost/retrievalmarket/rtvllog"
+	"github.com/filecoin-project/boost/retrievalmarket/server"
 	"github.com/filecoin-project/boost/storagemanager"
 	"github.com/filecoin-project/boost/storagemarket"
 	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
 	"github.com/filecoin-project/boost/storagemarket/types"
 	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
 	"github.com/filecoin-project/boost/transport"
+	"github.com/filecoin-project/boost/webhooks"
 	"github.com/filecoin-project/dagstore"
 	"github.com/filecoin-project/go-fil-markets/piecestore"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
@@ -38,6 +45,8 @@ type dealListResolver struct {
 	TotalCount int32
 	Deals      []*dealResolver
 	More       bool
+	// The cursor to pass as the "after" parameter to get the next page
+	Next *graphql.ID
 }
 
 // resolver translates from a request for a graphql field to the data for
@@ -50,6 +59,7 @@ type resolver struct {
 	logsDB     *db.LogsDB
 	retDB      *rtvllog.RetrievalLogDB
 	plDB       *db.ProposalLogsDB
+	auditDB    *db.AuditLogDB
 	fundsDB    *db.FundsDB
 	fundMgr    *fundmanager.FundManager
 	storageMgr *storagemanager.StorageManager
@@ -62,9 +72,15 @@ type resolver struct {
 	publisher  *storageadapter.DealPublisher
 	spApi      sealingpipeline.API
 	fullNode   v1api.FullNode
+	webhooks   *webhooks.Dispatcher
+	idxProv    *indexprovider.Wrapper
+	doctor     *piecedoctor.Doctor
+	unseals    *sectoraccessor.UnsealQueue
+	rask       *server.RetrievalAsk
+	quotas     *quota.Quotas
 }
 
-func NewResolver(cfg *config.Boost, r lotus_repo.LockedRepo, h host.Host, dealsDB *db.DealsDB, logsDB *db.LogsDB, retDB *rtvllog.RetrievalLogDB, plDB *db.ProposalLogsDB, fundsDB *db.FundsDB, fundMgr *fundmanager.FundManager, storageMgr *storagemanager.StorageManager, spApi sealingpipeline.API, provider *storagemarket.Provider, legacyProv lotus_storagemarket.StorageProvider, legacyDT lotus_dtypes.ProviderDataTransfer, ps piecestore.PieceStore, sa retrievalmarket.SectorAccessor, dagst dagstore.Interface, publisher *storageadapter.DealPublisher, fullNode v1api.FullNode) *resolver {
+func NewResolver(cfg *config.Boost, r lotus_repo.LockedRepo, h host.Host, dealsDB *db.DealsDB, logsDB *db.LogsDB, retDB *rtvllog.RetrievalLogDB, plDB *db.ProposalLogsDB, auditDB *db.AuditLogDB, fundsDB *db.FundsDB, fundMgr *fundmanager.FundManager, storageMgr *storagemanager.StorageManager, spApi sealingpipeline.API, provider *storagemarket.Provider, legacyProv lotus_storagemarket.StorageProvider, legacyDT lotus_dtypes.ProviderDataTransfer, ps piecestore.PieceStore, sa retrievalmarket.SectorAccessor, dagst dagstore.Interface, publisher *storageadapter.DealPublisher, fullNode v1api.FullNode, wh *webhooks.Dispatcher, idxProv *indexprovider.Wrapper, pd *piecedoctor.Doctor, uq *sectoraccessor.UnsealQueue, rask *server.RetrievalAsk, quotas *quota.Quotas) *resolver {
 	return &resolver{
 		cfg:        cfg,
 		repo:       r,
@@ -73,6 +89,7 @@ func NewResolver(cfg *config.Boost, r lotus_repo.LockedRepo, h host.Host, dealsD
 		logsDB:     logsDB,
 		retDB:      retDB,
 		plDB:       plDB,
+		auditDB:    auditDB,
 		fundsDB:    fundsDB,
 		fundMgr:    fundMgr,
 		storageMgr: storageMgr,
@@ -85,6 +102,12 @@ func NewResolver(cfg *config.Boost, r lotus_repo.LockedRepo, h host.Host, dealsD
 		publisher:  publisher,
 		spApi:      spApi,
 		fullNode:   fullNode,
+		webhooks:   wh,
+		idxProv:    idxProv,
+		doctor:     pd,
+		unseals:    uq,
+		rask:       rask,
+		quotas:     quotas,
 	}
 }
 
@@ -104,15 +127,49 @@ func (r *resolver) Deal(ctx context.Context, args struct{ ID graphql.ID }) (*dea
 }
 
 type filterArgs struct {
-	Checkpoint   gqltypes.Checkpoint
-	IsOffline    graphql.NullBool
-	TransferType graphql.NullString
-	IsVerified   graphql.NullBool
+	Checkpoint    gqltypes.Checkpoint
+	IsOffline     graphql.NullBool
+	TransferType  graphql.NullString
+	IsVerified    graphql.NullBool
+	ClientAddress graphql.NullString
+	PieceCid      graphql.NullString
+	ErrContains   graphql.NullString
+	CreatedAfter  *graphql.Time
+	CreatedBefore *graphql.Time
+}
+
+func (f *filterArgs) toFilterOptions() *db.FilterOptions {
+	if f == nil {
+		return nil
+	}
+	filter := &db.FilterOptions{
+		Checkpoint:    f.Checkpoint.Value,
+		IsOffline:     f.IsOffline.Value,
+		TransferType:  f.TransferType.Value,
+		IsVerified:    f.IsVerified.Value,
+		ClientAddress: f.ClientAddress.Value,
+		PieceCID:      f.PieceCid.Value,
+		ErrContains:   f.ErrContains.Value,
+	}
+	if f.CreatedAfter != nil {
+		filter.CreatedAfter = &f.CreatedAfter.Time
+	}
+	if f.CreatedBefore != nil {
+		filter.CreatedBefore = &f.CreatedBefore.Time
+	}
+	return filter
+}
+
+type sortArgs struct {
+	Key       graphql.NullString
+	Ascending graphql.NullBool
 }
 
 type dealsArgs struct {
 	Query  graphql.NullString
 	Filter *filterArgs
+	Sort   *sortArgs
+	After  *graphql.ID
 	Cursor *graphql.ID
 	Offset graphql.NullInt
 	Limit  graphql.NullInt
@@ -135,17 +192,27 @@ func (r *resolver) Deals(ctx context.Context, args dealsArgs) (*dealListResolver
 		query = *args.Query.Value
 	}
 
-	var filter *db.FilterOptions
-	if args.Filter != nil {
-		filter = &db.FilterOptions{
-			Checkpoint:   args.Filter.Checkpoint.Value,
-			IsOffline:    args.Filter.IsOffline.Value,
-			TransferType: args.Filter.TransferType.Value,
-			IsVerified:   args.Filter.IsVerified.Value,
+	filter := args.Filter.toFilterOptions()
+
+	var deals []types.ProviderDealState
+	var count int
+	var more bool
+	var err error
+	if args.Sort != nil || args.After != nil {
+		// Cursor-based pagination
+		var sort db.SortOptions
+		if args.Sort != nil {
+			if args.Sort.Key.Set && args.Sort.Key.Value != nil {
+				sort.Key = *args.Sort.Key.Value
+			}
+			if args.Sort.Ascending.Set && args.Sort.Ascending.Value != nil {
+				sort.Ascending = *args.Sort.Ascending.Value
+			}
 		}
+		deals, count, more, err = r.dealPage(ctx, query, filter, sort, args.After, limit)
+	} else {
+		deals, count, more, err = r.dealList(ctx, query, filter, args.Cursor, offset, limit)
 	}
-
-	deals, count, more, err := r.dealList(ctx, query, filter, args.Cursor, offset, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -156,10 +223,17 @@ func (r *resolver) Deals(ctx context.Context, args dealsArgs) (*dealListResolver
 		resolvers = append(resolvers, newDealResolver(&deal, r.provider, r.dealsDB, r.logsDB, r.spApi))
 	}
 
+	var next *graphql.ID
+	if more {
+		id := graphql.ID(deals[len(deals)-1].DealUuid.String())
+		next = &id
+	}
+
 	return &dealListResolver{
 		TotalCount: int32(count),
 		Deals:      resolvers,
 		More:       more,
+		Next:       next,
 	}, nil
 }
 
@@ -261,35 +335,50 @@ func (r *resolver) DealNew(ctx context.Context) (<-chan *dealNewResolver, error)
 }
 
 // mutation: dealCancel(id): ID
-func (r *resolver) DealCancel(_ context.Context, args struct{ ID graphql.ID }) (graphql.ID, error) {
+func (r *resolver) DealCancel(ctx context.Context, args struct{ ID graphql.ID }) (graphql.ID, error) {
+	if err := r.checkPerm(ctx, api.PermWrite); err != nil {
+		return args.ID, err
+	}
+
 	dealUuid, err := toUuid(args.ID)
 	if err != nil {
 		return args.ID, err
 	}
 
 	err = r.provider.CancelDealDataTransfer(dealUuid)
+	r.auditOverride(ctx, dealUuid, "cancel deal data transfer", err)
 	return args.ID, err
 }
 
 // mutation: dealRetryPaused(id): ID
-func (r *resolver) DealRetryPaused(_ context.Context, args struct{ ID graphql.ID }) (graphql.ID, error) {
+func (r *resolver) DealRetryPaused(ctx context.Context, args struct{ ID graphql.ID }) (graphql.ID, error) {
+	if err := r.checkPerm(ctx, api.PermWrite); err != nil {
+		return args.ID, err
+	}
+
 	dealUuid, err := toUuid(args.ID)
 	if err != nil {
 		return args.ID, err
 	}
 
 	err = r.provider.RetryPausedDeal(dealUuid)
+	r.auditOverride(ctx, dealUuid, "retry paused deal", err)
 	return args.ID, err
 }
 
 // mutation: dealFailPaused(id): ID
-func (r *resolver) DealFailPaused(_ context.Context, args struct{ ID graphql.ID }) (graphql.ID, error) {
+func (r *resolver) DealFailPaused(ctx context.Context, args struct{ ID graphql.ID }) (graphql.ID, error) {
+	if err := r.checkPerm(ctx, api.PermWrite); err != nil {
+		return args.ID, err
+	}
+
 	dealUuid, err := toUuid(args.ID)
 	if err != nil {
 		return args.ID, err
 	}
 
 	err = r.provider.FailPausedDeal(dealUuid)
+	r.auditOverride(ctx, dealUuid, "fail paused deal", err)
 	return args.ID, err
 }
 
@@ -346,6 +435,33 @@ func (r *resolver) dealList(ctx context.Context, query string, filter *db.Filter
 	return dis, count, more, nil
 }
 
+func (r *resolver) dealPage(ctx context.Context, query string, filter *db.FilterOptions, sort db.SortOptions, after *graphql.ID, limit int) ([]types.ProviderDealState, int, bool, error) {
+	// Fetch one extra deal so that we can check if there are more deals
+	// beyond the limit
+	deals, err := r.dealsDB.ListPage(ctx, query, filter, sort, after, limit+1)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	more := len(deals) > limit
+	if more {
+		// Truncate deal list to limit
+		deals = deals[:limit]
+	}
+
+	// Get the total deal count
+	count, err := r.dealsDB.Count(ctx, query, filter)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	dis := make([]types.ProviderDealState, 0, len(deals))
+	for _, deal := range deals {
+		dis = append(dis, *deal)
+	}
+
+	return dis, count, more, nil
+}
+
 type dealResolver struct {
 	types.ProviderDealState
 	provider    *storagemarket.Provider
@@ -394,6 +510,22 @@ func (dr *dealResolver) AnnounceToIPNI() bool {
 	return dr.ProviderDealState.AnnounceToIPNI
 }
 
+func (dr *dealResolver) AnnounceAfterSealing() bool {
+	return dr.ProviderDealState.AnnounceAfterSealing
+}
+
+func (dr *dealResolver) AnnounceRule() string {
+	return dr.ProviderDealState.AnnounceRule
+}
+
+func (dr *dealResolver) CollateralWallet() string {
+	return dr.ProviderDealState.CollateralWallet
+}
+
+func (dr *dealResolver) CollateralRule() string {
+	return dr.ProviderDealState.CollateralRule
+}
+
 func (dr *dealResolver) ProposalLabel() (string, error) {
 	l := dr.ProviderDealState.ClientDealProposal.Proposal.Label
 	if l.IsString() {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/filecoin-project/boost/api"
 	"github.com/filecoin-project/boost/gql/types"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/go-state-types/abi"
@@ -42,6 +43,32 @@ func (r *resolver) StorageAsk(ctx context.Context) (*storageAskResolver, error)
 	}, nil
 }
 
+type retrievalAskResolver struct {
+	PricePerByte            types.BigInt
+	UnsealPrice             types.BigInt
+	PaymentInterval         types.Uint64
+	PaymentIntervalIncrease types.Uint64
+	FreeClients             []*string
+}
+
+func (r *resolver) RetrievalAsk(ctx context.Context) (*retrievalAskResolver, error) {
+	ask := r.rask.GetAsk()
+	freeClients := r.rask.FreeClients()
+	free := make([]*string, 0, len(freeClients))
+	for _, p := range freeClients {
+		id := p.String()
+		free = append(free, &id)
+	}
+
+	return &retrievalAskResolver{
+		PricePerByte:            types.BigInt{Int: ask.PricePerByte},
+		UnsealPrice:             types.BigInt{Int: ask.UnsealPrice},
+		PaymentInterval:         types.Uint64(ask.PaymentInterval),
+		PaymentIntervalIncrease: types.Uint64(ask.PaymentIntervalIncrease),
+		FreeClients:             free,
+	}, nil
+}
+
 type storageAskUpdate struct {
 	Price         *types.BigInt
 	VerifiedPrice *types.BigInt
@@ -49,7 +76,11 @@ type storageAskUpdate struct {
 	MaxPieceSize  *types.Uint64
 }
 
-func (r *resolver) StorageAskUpdate(args struct{ Update storageAskUpdate }) (bool, error) {
+func (r *resolver) StorageAskUpdate(ctx context.Context, args struct{ Update storageAskUpdate }) (bool, error) {
+	if err := r.checkPerm(ctx, api.PermAdmin); err != nil {
+		return false, err
+	}
+
 	signedAsk := r.legacyProv.GetAsk()
 	ask := signedAsk.Ask
 
@@ -0,0 +1,113 @@
+package gql
+
+import (
+	"context"
+
+	"github.com/filecoin-project/boost/db"
+	gqltypes "github.com/filecoin-project/boost/gql/types"
+	"github.com/google/uuid"
+	"github.com/graph-gophers/graphql-go"
+)
+
+type auditEventResolver struct {
+	db.AuditEvent
+}
+
+func (ae *auditEventResolver) Seq() gqltypes.Uint64 {
+	return gqltypes.Uint64(ae.AuditEvent.Seq)
+}
+
+func (ae *auditEventResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: ae.AuditEvent.CreatedAt}
+}
+
+func (ae *auditEventResolver) DealID() graphql.ID {
+	return graphql.ID(ae.AuditEvent.DealUUID.String())
+}
+
+type auditEventListResolver struct {
+	TotalCount int32
+	Events     []*auditEventResolver
+	More       bool
+}
+
+type auditLogArgs struct {
+	DealID *graphql.ID
+	Cursor *gqltypes.Uint64 // Seq of the first event to return
+	Offset graphql.NullInt
+	Limit  graphql.NullInt
+}
+
+// query: auditLog(dealID, cursor, offset, limit) AuditEventList
+func (r *resolver) AuditLog(ctx context.Context, args auditLogArgs) (*auditEventListResolver, error) {
+	var dealUuid *uuid.UUID
+	if args.DealID != nil {
+		id, err := toUuid(*args.DealID)
+		if err != nil {
+			return nil, err
+		}
+		dealUuid = &id
+	}
+
+	offset := 0
+	if args.Offset.Set && args.Offset.Value != nil && *args.Offset.Value > 0 {
+		offset = int(*args.Offset.Value)
+	}
+
+	limit := 10
+	if args.Limit.Set && args.Limit.Value != nil && *args.Limit.Value > 0 {
+		limit = int(*args.Limit.Value)
+	}
+
+	var cursor *uint64
+	if args.Cursor != nil {
+		val := uint64(*args.Cursor)
+		cursor = &val
+	}
+
+	// Fetch one extra event so that we can check if there are more events
+	// beyond the limit
+	evts, err := r.auditDB.List(ctx, dealUuid, cursor, offset, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	more := len(evts) > limit
+	if more {
+		// Truncate event list to limit
+		evts = evts[:limit]
+	}
+
+	// Get the total event count
+	count, err := r.auditDB.Count(ctx, dealUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*auditEventResolver, 0, len(evts))
+	for _, evt := range evts {
+		resolvers = append(resolvers, &auditEventResolver{AuditEvent: evt})
+	}
+
+	return &auditEventListResolver{
+		TotalCount: int32(count),
+		Events:     resolvers,
+		More:       more,
+	}, nil
+}
+
+// auditOverride records an admin intervention in a deal in the audit log
+func (r *resolver) auditOverride(ctx context.Context, dealUuid uuid.UUID, action string, actionErr error) {
+	detail := action
+	if actionErr != nil {
+		detail += ": " + actionErr.Error()
+	}
+	evt := &db.AuditEvent{
+		DealUUID: dealUuid,
+		Type:     db.AuditEventOverride,
+		Actor:    actorFromCtx(ctx),
+		Detail:   detail,
+	}
+	if err := r.auditDB.Insert(ctx, evt); err != nil {
+		log.Warnw("failed to write audit event", "id", dealUuid, "action", action, "err", err)
+	}
+}
@@ -0,0 +1,95 @@
+package gql
+
+import (
+	"context"
+
+	"github.com/filecoin-project/boost/api"
+	"github.com/filecoin-project/boost/db"
+	gqltypes "github.com/filecoin-project/boost/gql/types"
+	"github.com/google/uuid"
+	"github.com/graph-gophers/graphql-go"
+)
+
+type pausedDealsFilterArgs struct {
+	Checkpoint    gqltypes.Checkpoint
+	ClientAddress graphql.NullString
+	ErrContains   graphql.NullString
+	IsOffline     graphql.NullBool
+}
+
+type bulkDealsArgs struct {
+	Filter *pausedDealsFilterArgs
+	DryRun graphql.NullBool
+}
+
+type bulkDealError struct {
+	DealID graphql.ID
+	Error  string
+}
+
+type bulkDealsResult struct {
+	DryRun bool
+	// The number of paused deals that matched the filter
+	Matched int32
+	// The number of deals that were successfully retried / failed
+	Succeeded int32
+	Errors    []*bulkDealError
+}
+
+// mutation: dealsRetryPaused(filter, dryRun): BulkDealsResult
+func (r *resolver) DealsRetryPaused(ctx context.Context, args bulkDealsArgs) (*bulkDealsResult, error) {
+	return r.bulkUpdatePaused(ctx, args, "bulk retry paused deal", r.provider.RetryPausedDeal)
+}
+
+// mutation: dealsFailPaused(filter, dryRun): BulkDealsResult
+func (r *resolver) DealsFailPaused(ctx context.Context, args bulkDealsArgs) (*bulkDealsResult, error) {
+	return r.bulkUpdatePaused(ctx, args, "bulk fail paused deal", r.provider.FailPausedDeal)
+}
+
+// bulkUpdatePaused applies the update function to each paused deal that
+// matches the filter. If dry run is true, it just returns the number of
+// matching deals.
+func (r *resolver) bulkUpdatePaused(ctx context.Context, args bulkDealsArgs, action string, update func(uuid.UUID) error) (*bulkDealsResult, error) {
+	if err := r.checkPerm(ctx, api.PermWrite); err != nil {
+		return nil, err
+	}
+
+	paused := true
+	filter := &db.FilterOptions{IsPaused: &paused}
+	if args.Filter != nil {
+		filter.Checkpoint = args.Filter.Checkpoint.Value
+		filter.ClientAddress = args.Filter.ClientAddress.Value
+		filter.ErrContains = args.Filter.ErrContains.Value
+		filter.IsOffline = args.Filter.IsOffline.Value
+	}
+
+	deals, err := r.dealsDB.ListPage(ctx, "", filter, db.SortOptions{Ascending: true}, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &bulkDealsResult{
+		DryRun:  args.DryRun.Set && args.DryRun.Value != nil && *args.DryRun.Value,
+		Matched: int32(len(deals)),
+		Errors:  []*bulkDealError{},
+	}
+	if res.DryRun {
+		return res, nil
+	}
+
+	for _, deal := range deals {
+		err := update(deal.DealUuid)
+		r.auditOverride(ctx, deal.DealUuid, action, err)
+		if err != nil {
+			res.Errors = append(res.Errors, &bulkDealError{
+				DealID: graphql.ID(deal.DealUuid.String()),
+				Error:  err.Error(),
+			})
+			continue
+		}
+		res.Succeeded++
+	}
+
+	log.Infow(action, "matched", res.Matched, "succeeded", res.Succeeded, "errors", len(res.Errors))
+	return res, nil
+}
@@ -0,0 +1,139 @@
+package gql
+
+import (
+	"context"
+
+	"github.com/filecoin-project/boost/dealbook"
+	gqltypes "github.com/filecoin-project/boost/gql/types"
+	"github.com/graph-gophers/graphql-go"
+)
+
+type allDealsFilterArgs struct {
+	Source        graphql.NullString
+	Checkpoint    gqltypes.Checkpoint
+	IsVerified    graphql.NullBool
+	ClientAddress graphql.NullString
+	PieceCid      graphql.NullString
+	ErrContains   graphql.NullString
+	CreatedAfter  *graphql.Time
+	CreatedBefore *graphql.Time
+}
+
+type allDealsArgs struct {
+	Query  graphql.NullString
+	Filter *allDealsFilterArgs
+	Offset graphql.NullInt
+	Limit  graphql.NullInt
+}
+
+type dealListingResolver struct {
+	dl dealbook.Deal
+}
+
+type dealListingListResolver struct {
+	TotalCount int32
+	More       bool
+	Deals      []*dealListingResolver
+}
+
+// query: allDeals(query, filter, offset, limit) DealListingList
+func (r *resolver) AllDeals(ctx context.Context, args allDealsArgs) (*dealListingListResolver, error) {
+	offset := 0
+	if args.Offset.Set && args.Offset.Value != nil && *args.Offset.Value > 0 {
+		offset = int(*args.Offset.Value)
+	}
+
+	limit := 10
+	if args.Limit.Set && args.Limit.Value != nil && *args.Limit.Value > 0 {
+		limit = int(*args.Limit.Value)
+	}
+
+	var filter dealbook.Filter
+	if args.Query.Set && args.Query.Value != nil {
+		filter.Query = *args.Query.Value
+	}
+	if f := args.Filter; f != nil {
+		filter.IsVerified = f.IsVerified.Value
+		if f.Source.Value != nil {
+			filter.Source = *f.Source.Value
+		}
+		if f.Checkpoint.Value != nil {
+			filter.Checkpoint = *f.Checkpoint.Value
+		}
+		if f.ClientAddress.Value != nil {
+			filter.ClientAddress = *f.ClientAddress.Value
+		}
+		if f.PieceCid.Value != nil {
+			filter.PieceCid = *f.PieceCid.Value
+		}
+		if f.ErrContains.Value != nil {
+			filter.ErrContains = *f.ErrContains.Value
+		}
+		if f.CreatedAfter != nil {
+			filter.CreatedAfter = f.CreatedAfter.Time
+		}
+		if f.CreatedBefore != nil {
+			filter.CreatedBefore = f.CreatedBefore.Time
+		}
+	}
+
+	page, err := dealbook.List(ctx, r.dealsDB, r.legacyProv, filter, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*dealListingResolver, 0, len(page.Deals))
+	for _, dl := range page.Deals {
+		resolvers = append(resolvers, &dealListingResolver{dl: dl})
+	}
+
+	return &dealListingListResolver{
+		TotalCount: int32(page.TotalCount),
+		More:       page.More,
+		Deals:      resolvers,
+	}, nil
+}
+
+func (r *dealListingResolver) ID() graphql.ID {
+	return graphql.ID(r.dl.ID)
+}
+
+func (r *dealListingResolver) Source() string {
+	return r.dl.Source
+}
+
+func (r *dealListingResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.dl.CreatedAt}
+}
+
+func (r *dealListingResolver) ClientAddress() string {
+	return r.dl.ClientAddress
+}
+
+func (r *dealListingResolver) PieceCid() string {
+	return r.dl.PieceCid
+}
+
+func (r *dealListingResolver) PieceSize() gqltypes.Uint64 {
+	return gqltypes.Uint64(r.dl.PieceSize)
+}
+
+func (r *dealListingResolver) IsVerified() bool {
+	return r.dl.IsVerified
+}
+
+func (r *dealListingResolver) ChainDealID() gqltypes.Uint64 {
+	return gqltypes.Uint64(r.dl.ChainDealID)
+}
+
+func (r *dealListingResolver) SectorID() gqltypes.Uint64 {
+	return gqltypes.Uint64(r.dl.SectorID)
+}
+
+func (r *dealListingResolver) Status() string {
+	return r.dl.Status
+}
+
+func (r *dealListingResolver) Message() string {
+	return r.dl.Message
+}
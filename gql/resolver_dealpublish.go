@@ -6,8 +6,12 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/filecoin-project/boost/api"
+
 	"github.com/filecoin-project/boost/gql/types"
 	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
 	"github.com/graph-gophers/graphql-go"
 )
 
@@ -35,17 +39,61 @@ type dealPublishResolver struct {
 	Start          graphql.Time
 	Period         int32
 	MaxDealsPerMsg int32
+	MaxBaseFee     types.BigInt
 	Deals          []*basicDealResolver
+	HeldForGas     []*dealPublishHeldBatchResolver
+}
+
+// A batch of deals whose publish message is held because the base fee is too
+// high
+type dealPublishHeldBatchResolver struct {
+	HeldSince  graphql.Time
+	BaseFee    types.BigInt
+	MaxBaseFee types.BigInt
+	Deals      []*basicDealResolver
 }
 
 // query: dealPublish: DealPublish
 func (r *resolver) DealPublish(ctx context.Context) (*dealPublishResolver, error) {
 	// Get deals pending publish from deal publisher
 	pending := r.publisher.PendingDeals()
+	basicDeals, err := r.basicDeals(ctx, pending.Deals)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get batches of deals that are held waiting for the base fee to fall
+	heldBatches := r.publisher.HeldForGas()
+	held := make([]*dealPublishHeldBatchResolver, 0, len(heldBatches))
+	for _, hb := range heldBatches {
+		deals, err := r.basicDeals(ctx, hb.Deals)
+		if err != nil {
+			return nil, err
+		}
+		held = append(held, &dealPublishHeldBatchResolver{
+			HeldSince:  graphql.Time{Time: hb.HeldSince},
+			BaseFee:    types.BigInt{Int: hb.BaseFee},
+			MaxBaseFee: types.BigInt{Int: hb.MaxBaseFee},
+			Deals:      deals,
+		})
+	}
+
+	return &dealPublishResolver{
+		Deals:          basicDeals,
+		HeldForGas:     held,
+		Period:         int32(pending.PublishPeriod.Seconds()),
+		Start:          graphql.Time{Time: pending.PublishPeriodStart},
+		MaxDealsPerMsg: int32(r.cfg.LotusDealmaking.MaxDealsPerPublishMsg),
+		MaxBaseFee:     types.BigInt{Int: abi.TokenAmount(r.cfg.LotusFees.PublishMaxBaseFee)},
+	}, nil
+}
 
-	legacyDealIDs := make(map[string]struct{}, len(pending.Deals))
-	basicDeals := make([]*basicDealResolver, 0, len(pending.Deals))
-	for _, dp := range pending.Deals {
+// basicDeals looks up the deals with the given proposals in the boost and
+// legacy deal databases
+func (r *resolver) basicDeals(ctx context.Context, props []market.ClientDealProposal) ([]*basicDealResolver, error) {
+	legacyDealIDs := make(map[string]struct{}, len(props))
+	basicDeals := make([]*basicDealResolver, 0, len(props))
+	for _, dp := range props {
 		signedProp, err := cborutil.AsIpld(&dp)
 		if err != nil {
 			return nil, fmt.Errorf("failed to compute signed deal proposal ipld node: %w", err)
@@ -150,16 +198,15 @@ func (r *resolver) DealPublish(ctx context.Context) (*dealPublishResolver, error
 		}
 	}
 
-	return &dealPublishResolver{
-		Deals:          basicDeals,
-		Period:         int32(pending.PublishPeriod.Seconds()),
-		Start:          graphql.Time{Time: pending.PublishPeriodStart},
-		MaxDealsPerMsg: int32(r.cfg.LotusDealmaking.MaxDealsPerPublishMsg),
-	}, nil
+	return basicDeals, nil
 }
 
 // mutation: dealPublishNow(): bool
 func (r *resolver) DealPublishNow(ctx context.Context) (bool, error) {
+	if err := r.checkPerm(ctx, api.PermWrite); err != nil {
+		return false, err
+	}
+
 	r.publisher.ForcePublishPendingDeals()
 	return true, nil
 }
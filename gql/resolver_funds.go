@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/filecoin-project/boost/api"
+
 	gqltypes "github.com/filecoin-project/boost/gql/types"
 	smfunds "github.com/filecoin-project/boost/storagemarket/funds"
 	"github.com/graph-gophers/graphql-go"
@@ -53,6 +55,44 @@ func (r *resolver) Funds(ctx context.Context) (*funds, error) {
 	}, nil
 }
 
+type fundsForecast struct {
+	PendingDeals         int32
+	AwaitingData         int32
+	AwaitingPublish      int32
+	Collateral           gqltypes.BigInt
+	EscrowAvailable      gqltypes.BigInt
+	CollateralShortfall  gqltypes.BigInt
+	PublishFees          gqltypes.BigInt
+	PubMsgBalance        gqltypes.BigInt
+	PublishFeesShortfall gqltypes.BigInt
+	Warnings             []string
+}
+
+// query: fundsForecast: FundsForecast
+func (r *resolver) FundsForecast(ctx context.Context) (*fundsForecast, error) {
+	f, err := smfunds.GetForecast(ctx, r.fundMgr, r.dealsDB)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings := f.Warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+	return &fundsForecast{
+		PendingDeals:         int32(f.PendingDeals),
+		AwaitingData:         int32(f.AwaitingData),
+		AwaitingPublish:      int32(f.AwaitingPublish),
+		Collateral:           gqltypes.BigInt{Int: f.Collateral},
+		EscrowAvailable:      gqltypes.BigInt{Int: f.EscrowAvailable},
+		CollateralShortfall:  gqltypes.BigInt{Int: f.CollateralShortfall},
+		PublishFees:          gqltypes.BigInt{Int: f.PublishFees},
+		PubMsgBalance:        gqltypes.BigInt{Int: f.PubMsgBalance},
+		PublishFeesShortfall: gqltypes.BigInt{Int: f.PublishFeesShortfall},
+		Warnings:             warnings,
+	}, nil
+}
+
 type fundsLogList struct {
 	TotalCount int32
 	Logs       []*fundsLogResolver
@@ -129,6 +169,10 @@ func (r *resolver) FundsLogs(ctx context.Context, args fundsLogsArgs) (*fundsLog
 
 // mutation: moveFundsToEscrow(amount): Boolean
 func (r *resolver) FundsMoveToEscrow(ctx context.Context, args struct{ Amount gqltypes.BigInt }) (bool, error) {
+	if err := r.checkPerm(ctx, api.PermAdmin); err != nil {
+		return false, err
+	}
+
 	_, err := r.fundMgr.MoveFundsToEscrow(ctx, args.Amount.Int)
 	return true, err
 }
@@ -0,0 +1,73 @@
+package gql
+
+import (
+	"context"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+)
+
+type directAnnounceStatusResolver struct {
+	URL               string
+	LastAdvertisement string
+	LastSuccess       *graphql.Time
+	LastAttempt       *graphql.Time
+	LastError         string
+	Failures          int32
+	NextAttempt       *graphql.Time
+}
+
+type indexProviderStatusResolver struct {
+	Enabled              bool
+	LatestAdvertisement  string
+	LastPublish          *graphql.Time
+	PendingAnnouncements int32
+	EntriesBacklog       int32
+	Topic                string
+	GossipsubPeers       int32
+	DirectAnnounce       []*directAnnounceStatusResolver
+}
+
+// query: indexProviderStatus: IndexProviderStatus
+func (r *resolver) IndexProviderStatus(ctx context.Context) (*indexProviderStatusResolver, error) {
+	st, err := r.idxProv.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &indexProviderStatusResolver{
+		Enabled:              st.Enabled,
+		LastPublish:          nullableTime(st.LastPublish),
+		PendingAnnouncements: int32(st.PendingAnnouncements),
+		EntriesBacklog:       int32(st.EntriesBacklog),
+		Topic:                st.Topic,
+		GossipsubPeers:       int32(st.GossipsubPeers),
+		DirectAnnounce:       make([]*directAnnounceStatusResolver, 0, len(st.DirectAnnounce)),
+	}
+	if st.LatestAdvertisement.Defined() {
+		res.LatestAdvertisement = st.LatestAdvertisement.String()
+	}
+	for _, da := range st.DirectAnnounce {
+		dar := &directAnnounceStatusResolver{
+			URL:         da.URL,
+			LastSuccess: nullableTime(da.LastSuccess),
+			LastAttempt: nullableTime(da.LastAttempt),
+			LastError:   da.LastError,
+			Failures:    int32(da.Failures),
+			NextAttempt: nullableTime(da.NextAttempt),
+		}
+		if da.LastAdvertisement.Defined() {
+			dar.LastAdvertisement = da.LastAdvertisement.String()
+		}
+		res.DirectAnnounce = append(res.DirectAnnounce, dar)
+	}
+	return res, nil
+}
+
+// nullableTime returns nil for the zero time
+func nullableTime(t time.Time) *graphql.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &graphql.Time{Time: t}
+}
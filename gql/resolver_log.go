@@ -0,0 +1,34 @@
+package gql
+
+import (
+	"context"
+	"sort"
+
+	"github.com/filecoin-project/boost/api"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+// query: logSubsystems: [String]
+func (r *resolver) LogSubsystems(ctx context.Context) ([]string, error) {
+	subsystems := logging.GetSubsystems()
+	sort.Strings(subsystems)
+	return subsystems, nil
+}
+
+type logSetLevelArgs struct {
+	Subsystem string
+	Level     string
+}
+
+// mutation: logSetLevel(subsystem, level): Boolean
+func (r *resolver) LogSetLevel(ctx context.Context, args logSetLevelArgs) (bool, error) {
+	if err := r.checkPerm(ctx, api.PermAdmin); err != nil {
+		return false, err
+	}
+
+	if err := logging.SetLogLevel(args.Subsystem, args.Level); err != nil {
+		return false, err
+	}
+	log.Infow("set log level", "subsystem", args.Subsystem, "level", args.Level)
+	return true, nil
+}
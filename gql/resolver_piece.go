@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	
+	"sync"
+
 	gqltypes "github.com/filecoin-project/boost/gql/types"
+	"github.com/filecoin-project/boost/piecedoctor"
 	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
 	"github.com/filecoin-project/dagstore"
 	"github.com/filecoin-project/dagstore/shard"
@@ -16,6 +18,7 @@ import (
 	"github.com/graph-gophers/graphql-go"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
+	"github.com/multiformats/go-multihash"
 )
 
 type IndexStatus string
@@ -55,6 +58,86 @@ type pieceResolver struct {
 	IndexStatus    *indexStatus
 	Deals          []*pieceDealResolver
 	PieceInfoDeals []*pieceInfoDeal
+	Retrievable    bool
+
+	pieceCid    cid.Cid
+	dagst       dagstore.Interface
+	pieceDoctor *piecedoctor.Doctor
+
+	// The index is read at most once per query, when the block count or
+	// sample multihashes are requested
+	idxOnce    sync.Once
+	idxErr     error
+	blockCount uint64
+	sample     []string
+}
+
+// The maximum number of multihashes that can be sampled from a piece index
+const maxSampleMultihashes = 100
+
+// query: pieceStatus(pieceCid).BlockCount
+func (p *pieceResolver) BlockCount() (*gqltypes.Uint64, error) {
+	indexed, err := p.readIndex()
+	if err != nil || !indexed {
+		return nil, err
+	}
+	count := gqltypes.Uint64(p.blockCount)
+	return &count, nil
+}
+
+// query: pieceStatus(pieceCid).SampleMultihashes(limit)
+func (p *pieceResolver) SampleMultihashes(args struct{ Limit graphql.NullInt }) ([]string, error) {
+	limit := 10
+	if args.Limit.Set && args.Limit.Value != nil && *args.Limit.Value >= 0 {
+		limit = int(*args.Limit.Value)
+	}
+	if limit > maxSampleMultihashes {
+		return nil, fmt.Errorf("limit %d is greater than the maximum of %d", limit, maxSampleMultihashes)
+	}
+
+	if _, err := p.readIndex(); err != nil {
+		return nil, err
+	}
+	if limit > len(p.sample) {
+		limit = len(p.sample)
+	}
+	return p.sample[:limit], nil
+}
+
+// query: pieceStatus(pieceCid).Health
+func (p *pieceResolver) Health() *pieceHealthResolver {
+	h, ok := p.pieceDoctor.Result(p.pieceCid)
+	if !ok {
+		return nil
+	}
+	return &pieceHealthResolver{h: h}
+}
+
+// readIndex counts the blocks in the piece's index, and samples the first
+// multihashes. It returns false if the piece has not been indexed.
+func (p *pieceResolver) readIndex() (bool, error) {
+	p.idxOnce.Do(func() {
+		idx, err := p.dagst.GetIterableIndex(shard.KeyFromCID(p.pieceCid))
+		if err != nil {
+			if !errors.Is(err, dagstore.ErrShardUnknown) {
+				p.idxErr = fmt.Errorf("getting index for piece %s: %w", p.pieceCid, err)
+			}
+			return
+		}
+
+		p.sample = make([]string, 0, maxSampleMultihashes)
+		p.idxErr = idx.ForEach(func(mh multihash.Multihash, _ uint64) error {
+			if len(p.sample) < maxSampleMultihashes {
+				p.sample = append(p.sample, mh.B58String())
+			}
+			p.blockCount++
+			return nil
+		})
+		if p.idxErr != nil {
+			p.idxErr = fmt.Errorf("iterating over index for piece %s: %w", p.pieceCid, p.idxErr)
+		}
+	})
+	return p.sample != nil, p.idxErr
 }
 
 func (r *resolver) PiecesWithPayloadCid(ctx context.Context, args struct{ PayloadCid string }) ([]string, error) {
@@ -251,11 +334,27 @@ func (r *resolver) PieceStatus(ctx context.Context, args struct{ PieceCid string
 		return nil, err
 	}
 
+	// The piece can be retrieved right now if it has been indexed and there
+	// is an unsealed copy
+	retrievable := false
+	if idxStatus.Status == string(IndexStatusComplete) {
+		for _, pid := range pids {
+			retrievable = retrievable || pid.SealStatus.IsUnsealed
+		}
+		for _, dl := range deals {
+			retrievable = retrievable || dl.SealStatus.IsUnsealed
+		}
+	}
+
 	return &pieceResolver{
 		PieceCid:       args.PieceCid,
 		IndexStatus:    idxStatus,
 		PieceInfoDeals: pids,
 		Deals:          deals,
+		Retrievable:    retrievable,
+		pieceCid:       pieceCid,
+		dagst:          r.dagst,
+		pieceDoctor:    r.doctor,
 	}, nil
 }
 
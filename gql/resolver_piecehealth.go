@@ -0,0 +1,69 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/boost/api"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/ipfs/go-cid"
+)
+
+type pieceHealthResolver struct {
+	h api.PieceHealth
+}
+
+func (p *pieceHealthResolver) PieceCid() string {
+	return p.h.PieceCid.String()
+}
+
+func (p *pieceHealthResolver) Status() string {
+	return p.h.Status
+}
+
+func (p *pieceHealthResolver) Error() string {
+	return p.h.Error
+}
+
+func (p *pieceHealthResolver) BlocksChecked() int32 {
+	return int32(p.h.BlocksChecked)
+}
+
+func (p *pieceHealthResolver) CheckedAt() graphql.Time {
+	return graphql.Time{Time: p.h.CheckedAt}
+}
+
+func (p *pieceHealthResolver) Repair() string {
+	return p.h.Repair
+}
+
+// query: pieceHealth: [PieceHealth]
+func (r *resolver) PieceHealth(ctx context.Context) ([]*pieceHealthResolver, error) {
+	results := r.doctor.Results()
+	resolvers := make([]*pieceHealthResolver, 0, len(results))
+	for _, h := range results {
+		resolvers = append(resolvers, &pieceHealthResolver{h: h})
+	}
+	return resolvers, nil
+}
+
+type pieceCheckHealthArgs struct {
+	PieceCid string
+	Repair   graphql.NullBool
+}
+
+// mutation: pieceCheckHealth(pieceCid, repair): PieceHealth
+func (r *resolver) PieceCheckHealth(ctx context.Context, args pieceCheckHealthArgs) (*pieceHealthResolver, error) {
+	if err := r.checkPerm(ctx, api.PermAdmin); err != nil {
+		return nil, err
+	}
+
+	pieceCid, err := cid.Parse(args.PieceCid)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid piece cid", args.PieceCid)
+	}
+
+	repair := args.Repair.Set && args.Repair.Value != nil && *args.Repair.Value
+	h := r.doctor.CheckPiece(ctx, pieceCid, repair)
+	return &pieceHealthResolver{h: h}, nil
+}
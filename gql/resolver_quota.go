@@ -0,0 +1,80 @@
+package gql
+
+import (
+	"context"
+
+	gqltypes "github.com/filecoin-project/boost/gql/types"
+	"github.com/filecoin-project/boost/retrievalmarket/quota"
+	"github.com/graph-gophers/graphql-go"
+)
+
+type retrievalQuotaResolver struct {
+	Client                 string
+	BytesToday             gqltypes.Uint64
+	BytesThisMonth         gqltypes.Uint64
+	MaxBytesPerDay         gqltypes.Uint64
+	MaxBytesPerMonth       gqltypes.Uint64
+	State                  string
+	ThrottleBytesPerSecond gqltypes.Uint64
+}
+
+func newRetrievalQuotaResolver(st quota.Status) *retrievalQuotaResolver {
+	return &retrievalQuotaResolver{
+		Client:                 st.Client,
+		BytesToday:             gqltypes.Uint64(st.BytesToday),
+		BytesThisMonth:         gqltypes.Uint64(st.BytesThisMonth),
+		MaxBytesPerDay:         gqltypes.Uint64(st.MaxBytesPerDay),
+		MaxBytesPerMonth:       gqltypes.Uint64(st.MaxBytesPerMonth),
+		State:                  st.State,
+		ThrottleBytesPerSecond: gqltypes.Uint64(st.ThrottleBytesPerSecond),
+	}
+}
+
+// query: retrievalQuota(client) RetrievalQuota
+func (r *resolver) RetrievalQuota(ctx context.Context, args struct{ Client string }) (*retrievalQuotaResolver, error) {
+	st, err := r.quotas.Status(ctx, args.Client)
+	if err != nil {
+		return nil, err
+	}
+	return newRetrievalQuotaResolver(*st), nil
+}
+
+type retrievalQuotaListResolver struct {
+	Clients []*retrievalQuotaResolver
+	More    bool
+}
+
+type retrievalQuotasArgs struct {
+	Offset graphql.NullInt
+	Limit  graphql.NullInt
+}
+
+// query: retrievalQuotas(offset, limit) RetrievalQuotaList
+func (r *resolver) RetrievalQuotas(ctx context.Context, args retrievalQuotasArgs) (*retrievalQuotaListResolver, error) {
+	offset := 0
+	if args.Offset.Set && args.Offset.Value != nil && *args.Offset.Value > 0 {
+		offset = int(*args.Offset.Value)
+	}
+
+	limit := 10
+	if args.Limit.Set && args.Limit.Value != nil && *args.Limit.Value > 0 {
+		limit = int(*args.Limit.Value)
+	}
+
+	// Fetch one extra row so that we can check if there are more rows
+	// beyond the limit
+	statuses, err := r.quotas.List(ctx, offset, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	more := len(statuses) > limit
+	if more {
+		statuses = statuses[:limit]
+	}
+
+	resolvers := make([]*retrievalQuotaResolver, 0, len(statuses))
+	for _, st := range statuses {
+		resolvers = append(resolvers, newRetrievalQuotaResolver(st))
+	}
+	return &retrievalQuotaListResolver{Clients: resolvers, More: more}, nil
+}
@@ -0,0 +1,185 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gqltypes "github.com/filecoin-project/boost/gql/types"
+	"github.com/filecoin-project/boost/retrievalmarket/rtvllog"
+	"github.com/graph-gophers/graphql-go"
+)
+
+type retrievalAttemptResolver struct {
+	rtvllog.RetrievalAttempt
+}
+
+func (r *retrievalAttemptResolver) ID() gqltypes.Uint64 {
+	return gqltypes.Uint64(r.RetrievalAttempt.ID)
+}
+
+func (r *retrievalAttemptResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.RetrievalAttempt.CreatedAt}
+}
+
+func (r *retrievalAttemptResolver) PayloadCID() string {
+	if !r.RetrievalAttempt.PayloadCID.Defined() {
+		return ""
+	}
+	return r.RetrievalAttempt.PayloadCID.String()
+}
+
+func (r *retrievalAttemptResolver) PieceCID() string {
+	if r.RetrievalAttempt.PieceCID == nil {
+		return ""
+	}
+	return r.RetrievalAttempt.PieceCID.String()
+}
+
+func (r *retrievalAttemptResolver) BytesSent() gqltypes.Uint64 {
+	return gqltypes.Uint64(r.RetrievalAttempt.BytesSent)
+}
+
+func (r *retrievalAttemptResolver) DurationMs() gqltypes.Uint64 {
+	return gqltypes.Uint64(r.RetrievalAttempt.Duration.Milliseconds())
+}
+
+type retrievalAttemptArgs struct {
+	ID gqltypes.Uint64
+}
+
+// query: retrievalAttempt(id) RetrievalAttempt
+func (r *resolver) RetrievalAttempt(ctx context.Context, args retrievalAttemptArgs) (*retrievalAttemptResolver, error) {
+	a, err := r.retDB.GetAttempt(ctx, uint64(args.ID))
+	if err != nil {
+		return nil, err
+	}
+	return &retrievalAttemptResolver{RetrievalAttempt: *a}, nil
+}
+
+type retrievalAttemptListResolver struct {
+	TotalCount int32
+	Attempts   []*retrievalAttemptResolver
+	More       bool
+}
+
+type retrievalAttemptsArgs struct {
+	Protocol graphql.NullString
+	Cursor   *gqltypes.Uint64 // database row id
+	Offset   graphql.NullInt
+	Limit    graphql.NullInt
+}
+
+// query: retrievalAttempts(protocol, cursor, offset, limit) RetrievalAttemptList
+func (r *resolver) RetrievalAttempts(ctx context.Context, args retrievalAttemptsArgs) (*retrievalAttemptListResolver, error) {
+	protocol := ""
+	if args.Protocol.Set && args.Protocol.Value != nil {
+		protocol = *args.Protocol.Value
+	}
+
+	offset := 0
+	if args.Offset.Set && args.Offset.Value != nil && *args.Offset.Value > 0 {
+		offset = int(*args.Offset.Value)
+	}
+
+	limit := 10
+	if args.Limit.Set && args.Limit.Value != nil && *args.Limit.Value > 0 {
+		limit = int(*args.Limit.Value)
+	}
+
+	// Fetch one extra row so that we can check if there are more rows
+	// beyond the limit
+	var cursor *uint64
+	if args.Cursor != nil {
+		cursorptr := uint64(*args.Cursor)
+		cursor = &cursorptr
+	}
+	rows, err := r.retDB.ListAttempts(ctx, protocol, cursor, offset, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	more := len(rows) > limit
+	if more {
+		// Truncate list to limit
+		rows = rows[:limit]
+	}
+
+	// Get the total row count
+	count, err := r.retDB.CountAttempts(ctx, protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*retrievalAttemptResolver, 0, len(rows))
+	for _, row := range rows {
+		resolvers = append(resolvers, &retrievalAttemptResolver{RetrievalAttempt: row})
+	}
+
+	return &retrievalAttemptListResolver{
+		TotalCount: int32(count),
+		Attempts:   resolvers,
+		More:       more,
+	}, nil
+}
+
+type retrievalProtocolStatsResolver struct {
+	Protocol      string
+	Attempts      int32
+	Successes     int32
+	Rejections    int32
+	Failures      int32
+	SuccessRate   float64
+	BytesSent     gqltypes.Uint64
+	AvgDurationMs float64
+}
+
+type retrievalStatsResolver struct {
+	Window    string
+	Since     *graphql.Time
+	Protocols []*retrievalProtocolStatsResolver
+}
+
+type retrievalStatsArgs struct {
+	Window graphql.NullString
+}
+
+// query: retrievalStats(window) RetrievalStats
+func (r *resolver) RetrievalStats(ctx context.Context, args retrievalStatsArgs) (*retrievalStatsResolver, error) {
+	window := defaultStatsWindow
+	if args.Window.Set && args.Window.Value != nil {
+		window = *args.Window.Value
+	}
+	dur, ok := statsWindows[window]
+	if !ok {
+		return nil, fmt.Errorf("unrecognized stats window '%s': must be one of hour, day, week, month or all", window)
+	}
+
+	res := &retrievalStatsResolver{Window: window}
+	var since time.Time
+	if dur > 0 {
+		since = time.Now().Add(-dur)
+		res.Since = &graphql.Time{Time: since}
+	}
+
+	stats, err := r.retDB.AttemptStats(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	res.Protocols = make([]*retrievalProtocolStatsResolver, 0, len(stats))
+	for _, st := range stats {
+		ps := &retrievalProtocolStatsResolver{
+			Protocol:   st.Protocol,
+			Attempts:   int32(st.Attempts),
+			Successes:  int32(st.Successes),
+			Rejections: int32(st.Rejections),
+			Failures:   int32(st.Failures),
+			BytesSent:  gqltypes.Uint64(st.BytesSent),
+		}
+		if st.Attempts > 0 {
+			ps.SuccessRate = float64(st.Successes) / float64(st.Attempts)
+			ps.AvgDurationMs = float64(st.TotalDuration.Milliseconds()) / float64(st.Attempts)
+		}
+		res.Protocols = append(res.Protocols, ps)
+	}
+	return res, nil
+}
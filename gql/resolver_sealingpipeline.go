@@ -4,67 +4,55 @@ import (
 	"context"
 
 	gqltypes "github.com/filecoin-project/boost/gql/types"
-	"github.com/filecoin-project/go-address"
-	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
 	"github.com/filecoin-project/lotus/api"
-	"github.com/filecoin-project/lotus/api/v1api"
-	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/graph-gophers/graphql-go"
 )
 
 // query: sealingpipeline: [SealingPipeline]
 func (r *resolver) SealingPipeline(ctx context.Context) (*sealingPipelineState, error) {
-	res, err := r.spApi.WorkerJobs(ctx)
+	st, err := sealingpipeline.GetStatus(ctx, r.spApi)
 	if err != nil {
 		return nil, err
 	}
 
-	var workers []*worker
-	for workerId, jobs := range res {
-		for _, j := range jobs {
-			workers = append(workers, &worker{
-				ID:     workerId.String(),
-				Start:  graphql.Time{Time: j.Start},
-				Stage:  j.Task.Short(),
-				Sector: int32(j.Sector.Number),
-			})
-		}
-	}
-
-	summary, err := r.spApi.SectorsSummary(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	minerAddr, err := r.spApi.ActorAddress(ctx)
-	if err != nil {
-		return nil, err
+	workers := make([]*worker, 0, len(st.Workers))
+	for _, w := range st.Workers {
+		workers = append(workers, &worker{
+			ID:     w.ID,
+			Start:  graphql.Time{Time: w.Start},
+			Stage:  w.Stage,
+			Sector: w.Sector,
+		})
 	}
 
-	ssize, err := getSectorSize(ctx, r.fullNode, minerAddr)
+	waitDealsSectors, err := r.populateWaitDealsSectors(ctx, st.WaitDealsSectors, uint64(st.SectorSize))
 	if err != nil {
 		return nil, err
 	}
-
-	wdSectors, err := r.spApi.SectorsListInStates(ctx, []api.SectorState{"WaitDeals"})
+	snapDealsWaitDealsSectors, err := r.populateWaitDealsSectors(ctx, st.SnapDealsWaitDealsSectors, uint64(st.SectorSize))
 	if err != nil {
 		return nil, err
 	}
 
-	sdwdSectors, err := r.spApi.SectorsListInStates(ctx, []api.SectorState{"SnapDealsWaitDeals"})
-	if err != nil {
-		return nil, err
-	}
-
-	waitDealsSectors, err := r.populateWaitDealsSectors(ctx, wdSectors, ssize)
-	if err != nil {
-		return nil, err
-	}
-	snapDealsWaitDealsSectors, err := r.populateWaitDealsSectors(ctx, sdwdSectors, ssize)
-	if err != nil {
-		return nil, err
+	utilization := make([]*workerUtilization, 0, len(st.WorkerUtilization))
+	for _, wu := range st.WorkerUtilization {
+		utilization = append(utilization, &workerUtilization{
+			ID:          wu.ID,
+			Hostname:    wu.Hostname,
+			Enabled:     wu.Enabled,
+			CPUs:        gqltypes.Uint64(wu.CPUs),
+			CPUsUsed:    gqltypes.Uint64(wu.CPUsUsed),
+			GPUs:        int32(wu.GPUs),
+			GPUsUsed:    wu.GPUsUsed,
+			MemPhysical: gqltypes.Uint64(wu.MemPhysical),
+			MemUsed:     gqltypes.Uint64(wu.MemUsed),
+			RunningJobs: int32(wu.RunningJobs),
+			QueuedJobs:  int32(wu.QueuedJobs),
+		})
 	}
 
+	summary := st.SectorStates
 	var ss sectorStates
 	for order, state := range allSectorStates {
 		count, ok := summary[api.SectorState(state)]
@@ -117,6 +105,7 @@ func (r *resolver) SealingPipeline(ctx context.Context) (*sealingPipelineState,
 		SnapDealsWaitDealsSectors: snapDealsWaitDealsSectors,
 		SectorStates:              ss,
 		Workers:                   workers,
+		WorkerUtilization:         utilization,
 	}, nil
 }
 
@@ -136,6 +125,7 @@ type waitDealSector struct {
 	SectorID   gqltypes.Uint64
 	Deals      []*waitDeal
 	Used       gqltypes.Uint64
+	Free       gqltypes.Uint64
 	SectorSize gqltypes.Uint64
 }
 
@@ -153,47 +143,42 @@ type worker struct {
 	Sector int32
 }
 
+type workerUtilization struct {
+	ID          string
+	Hostname    string
+	Enabled     bool
+	CPUs        gqltypes.Uint64
+	CPUsUsed    gqltypes.Uint64
+	GPUs        int32
+	GPUsUsed    float64
+	MemPhysical gqltypes.Uint64
+	MemUsed     gqltypes.Uint64
+	RunningJobs int32
+	QueuedJobs  int32
+}
+
 type sealingPipelineState struct {
 	WaitDealsSectors          []*waitDealSector
 	SnapDealsWaitDealsSectors []*waitDealSector
 	SectorStates              sectorStates
 	Workers                   []*worker
+	WorkerUtilization         []*workerUtilization
 }
 
-func getSectorSize(ctx context.Context, fullNode v1api.FullNode, maddr address.Address) (uint64, error) {
-	mi, err := fullNode.StateMinerInfo(ctx, maddr, types.EmptyTSK)
-	if err != nil {
-		return 0, err
-	}
-
-	return uint64(mi.SectorSize), nil
-}
-
-func (r *resolver) populateWaitDealsSectors(ctx context.Context, sectorNumbers []abi.SectorNumber, ssize uint64) ([]*waitDealSector, error) {
+// populateWaitDealsSectors matches the deals in each sector that is waiting
+// for deals with the deals in the boost and legacy databases
+func (r *resolver) populateWaitDealsSectors(ctx context.Context, sectors []*sealingpipeline.WaitDealsSector, ssize uint64) ([]*waitDealSector, error) {
 	waitDealsSectors := []*waitDealSector{}
-	for _, s := range sectorNumbers {
+	for _, s := range sectors {
 		used := uint64(0)
 		deals := []*waitDeal{}
 
-		wdSectorStatus, err := r.spApi.SectorsStatus(ctx, s, false)
-		if err != nil {
-			return nil, err
-		}
-
-		for _, p := range wdSectorStatus.Pieces {
-			if p.DealInfo == nil {
-				continue
-			}
-
-			publishCid := p.DealInfo.PublishCid
-			if publishCid == nil {
+		for _, p := range s.Pieces {
+			publishCid := p.PublishCid
+			if publishCid == nil || p.ProposalCid == nil {
 				continue
 			}
-
-			dcid, err := p.DealInfo.DealProposal.Cid()
-			if err != nil {
-				return nil, err
-			}
+			dcid := *p.ProposalCid
 
 			ds, err := r.dealsByPublishCID(ctx, *publishCid)
 			if err != nil {
@@ -220,10 +205,10 @@ func (r *resolver) populateWaitDealsSectors(ctx context.Context, sectorNumbers [
 			if i < len(ds) {
 				deals = append(deals, &waitDeal{
 					ID:       graphql.ID(ds[i].DealUuid.String()),
-					Size:     gqltypes.Uint64(p.Piece.Size),
+					Size:     gqltypes.Uint64(p.Size),
 					IsLegacy: false,
 				})
-				used += uint64(p.Piece.Size)
+				used += uint64(p.Size)
 				continue
 			}
 
@@ -257,16 +242,17 @@ func (r *resolver) populateWaitDealsSectors(ctx context.Context, sectorNumbers [
 
 			deals = append(deals, &waitDeal{
 				ID:       graphql.ID(lds[j].ProposalCid.String()),
-				Size:     gqltypes.Uint64(p.Piece.Size),
+				Size:     gqltypes.Uint64(p.Size),
 				IsLegacy: true,
 			})
-			used += uint64(p.Piece.Size)
+			used += uint64(p.Size)
 		}
 
 		waitDealsSectors = append(waitDealsSectors, &waitDealSector{
-			SectorID:   gqltypes.Uint64(s),
+			SectorID:   gqltypes.Uint64(s.SectorID),
 			Deals:      deals,
 			Used:       gqltypes.Uint64(used),
+			Free:       gqltypes.Uint64(s.Free),
 			SectorSize: gqltypes.Uint64(ssize),
 		})
 	}
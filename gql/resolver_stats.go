@@ -0,0 +1,137 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	gqltypes "github.com/filecoin-project/boost/gql/types"
+	"github.com/graph-gophers/graphql-go"
+)
+
+// The windows over which deal stats can be aggregated
+var statsWindows = map[string]time.Duration{
+	"hour":  time.Hour,
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"all":   0,
+}
+
+const defaultStatsWindow = "day"
+
+type checkpointStatsResolver struct {
+	db.CheckpointStats
+}
+
+func (c *checkpointStatsResolver) Count() int32 {
+	return int32(c.CheckpointStats.Count)
+}
+
+func (c *checkpointStatsResolver) Failed() int32 {
+	return int32(c.CheckpointStats.Failed)
+}
+
+func (c *checkpointStatsResolver) PieceBytes() gqltypes.Uint64 {
+	return gqltypes.Uint64(c.CheckpointStats.PieceBytes)
+}
+
+func (c *checkpointStatsResolver) TransferBytes() gqltypes.Uint64 {
+	return gqltypes.Uint64(c.CheckpointStats.TransferBytes)
+}
+
+type rejectionStatsResolver struct {
+	db.RejectionStats
+}
+
+func (r *rejectionStatsResolver) Count() int32 {
+	return int32(r.RejectionStats.Count)
+}
+
+type dealStatsResolver struct {
+	Window              string
+	Since               *graphql.Time
+	Checkpoints         []*checkpointStatsResolver
+	Accepted            int32
+	Rejected            int32
+	AcceptanceRate      float64
+	TopRejectionReasons []*rejectionStatsResolver
+	TransferredDeals    int32
+	// Average transfer throughput in bytes per second
+	AvgTransferThroughput float64
+	SealedDeals           int32
+	// Average time from deal creation until the sector is sealed
+	AvgTimeToSealSeconds float64
+}
+
+type dealStatsArgs struct {
+	Window        graphql.NullString
+	TopRejections graphql.NullInt
+}
+
+// query: dealStats(window, topRejections) DealStats
+func (r *resolver) DealStats(ctx context.Context, args dealStatsArgs) (*dealStatsResolver, error) {
+	window := defaultStatsWindow
+	if args.Window.Set && args.Window.Value != nil {
+		window = *args.Window.Value
+	}
+	dur, ok := statsWindows[window]
+	if !ok {
+		return nil, fmt.Errorf("unrecognized stats window '%s': must be one of hour, day, week, month or all", window)
+	}
+
+	topRejections := 5
+	if args.TopRejections.Set && args.TopRejections.Value != nil && *args.TopRejections.Value >= 0 {
+		topRejections = int(*args.TopRejections.Value)
+	}
+
+	res := &dealStatsResolver{Window: window}
+	var since time.Time
+	if dur > 0 {
+		since = time.Now().Add(-dur)
+		res.Since = &graphql.Time{Time: since}
+	}
+
+	cpStats, err := r.dealsDB.CheckpointStats(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range cpStats {
+		res.Checkpoints = append(res.Checkpoints, &checkpointStatsResolver{CheckpointStats: st})
+	}
+
+	decisions, err := r.auditDB.DecisionStats(ctx, since, topRejections)
+	if err != nil {
+		return nil, err
+	}
+	res.Accepted = int32(decisions.Accepted)
+	res.Rejected = int32(decisions.Rejected)
+	if total := decisions.Accepted + decisions.Rejected; total > 0 {
+		res.AcceptanceRate = float64(decisions.Accepted) / float64(total)
+	}
+	res.TopRejectionReasons = make([]*rejectionStatsResolver, 0, len(decisions.TopRejections))
+	for _, rj := range decisions.TopRejections {
+		res.TopRejectionReasons = append(res.TopRejectionReasons, &rejectionStatsResolver{RejectionStats: rj})
+	}
+
+	transfers, err := r.auditDB.TransferStats(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	res.TransferredDeals = int32(transfers.Count)
+	if transfers.TotalSeconds > 0 {
+		res.AvgTransferThroughput = float64(transfers.TotalBytes) / transfers.TotalSeconds
+	}
+
+	seals, err := r.auditDB.SealStats(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	res.SealedDeals = int32(seals.Count)
+	if seals.Count > 0 {
+		res.AvgTimeToSealSeconds = seals.TotalSeconds / float64(seals.Count)
+	}
+
+	return res, nil
+}
@@ -2,11 +2,15 @@ package gql
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sort"
 	"time"
 
 	gqltypes "github.com/filecoin-project/boost/gql/types"
 	"github.com/filecoin-project/boost/storagemarket"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
 	"github.com/google/uuid"
 	"github.com/graph-gophers/graphql-go"
 )
@@ -54,6 +58,34 @@ func (r *resolver) TransferStats(_ context.Context) *transferStats {
 	}
 }
 
+type hostTransferTelemetry struct {
+	Host          string
+	Completed     int32
+	Failed        int32
+	BytesReceived gqltypes.Uint64
+	Throughput    float64
+	Stalls        int32
+	Retries       int32
+}
+
+// query: transferHostTelemetry: [HostTransferTelemetry]
+func (r *resolver) TransferHostTelemetry(_ context.Context) []*hostTransferTelemetry {
+	hosts := r.provider.TransferTelemetry()
+	telemetry := make([]*hostTransferTelemetry, 0, len(hosts))
+	for _, h := range hosts {
+		telemetry = append(telemetry, &hostTransferTelemetry{
+			Host:          h.Host,
+			Completed:     int32(h.Completed),
+			Failed:        int32(h.Failed),
+			BytesReceived: gqltypes.Uint64(h.BytesReceived),
+			Throughput:    h.Throughput,
+			Stalls:        int32(h.Stalls),
+			Retries:       int32(h.Retries),
+		})
+	}
+	return telemetry
+}
+
 func (r *resolver) getTransferSamples(deals map[uuid.UUID][]storagemarket.TransferPoint, filter []uuid.UUID) []*transferPoint {
 	// If filter is nil, include all deals
 	if filter == nil {
@@ -104,3 +136,129 @@ func (r *resolver) getTransferSamples(deals map[uuid.UUID][]storagemarket.Transf
 
 	return pts
 }
+
+type transferProgress struct {
+	DealID        graphql.ID
+	BytesReceived gqltypes.Uint64
+	TransferSize  gqltypes.Uint64
+	// The average number of bytes transferred per second over the last
+	// few seconds
+	Rate    gqltypes.Uint64
+	Stalled bool
+	// True when the transfer has finished (successfully or not)
+	Complete bool
+}
+
+// subscription: transferProgress(id) <-chan TransferProgress
+func (r *resolver) TransferProgress(ctx context.Context, args struct{ ID graphql.ID }) (<-chan *transferProgress, error) {
+	dealUuid, err := toUuid(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	deal, err := r.dealByID(ctx, dealUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	c := make(chan *transferProgress, 1)
+	transferDone := func(d *types.ProviderDealState) bool {
+		return d.Checkpoint >= dealcheckpoints.Transferred || d.Err != ""
+	}
+
+	// If the transfer has already finished, just send the final state
+	if transferDone(deal) {
+		c <- r.transferProgress(deal, true)
+		close(c)
+		return c, nil
+	}
+
+	// Updates to deal state are used to find out when the transfer is done
+	dealUpdatesSub, err := r.provider.SubscribeDealUpdates(dealUuid)
+	if err != nil {
+		if errors.Is(err, storagemarket.ErrDealHandlerNotFound) {
+			c <- r.transferProgress(deal, true)
+			close(c)
+			return c, nil
+		}
+		return nil, fmt.Errorf("%s: subscribing to deal updates: %w", args.ID, err)
+	}
+
+	c <- r.transferProgress(deal, false)
+
+	go func() {
+		// When the connection ends, unsubscribe from deal updates
+		defer dealUpdatesSub.Close()
+		defer close(c)
+
+		// Send progress once a second while the transfer is ongoing
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		var lastSent uint64
+		for {
+			complete := false
+			select {
+			case <-ctx.Done():
+				return
+			case evti, ok := <-dealUpdatesSub.Out():
+				if !ok {
+					return
+				}
+				di := evti.(types.ProviderDealState)
+				deal = &di
+				complete = transferDone(deal)
+				if !complete {
+					continue
+				}
+			case <-ticker.C:
+			}
+
+			progress := r.transferProgress(deal, complete)
+			if !complete && uint64(progress.BytesReceived) == lastSent && !progress.Stalled {
+				// Nothing has changed since the last update
+				continue
+			}
+			lastSent = uint64(progress.BytesReceived)
+
+			select {
+			case <-ctx.Done():
+				return
+			case c <- progress:
+			}
+
+			if complete {
+				return
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+func (r *resolver) transferProgress(deal *types.ProviderDealState, complete bool) *transferProgress {
+	received := r.provider.NBytesReceived(deal.DealUuid)
+	if complete && deal.Err == "" {
+		received = deal.Transfer.Size
+	}
+
+	// Calculate the transfer rate from the recent samples
+	var rate uint64
+	pts := r.provider.Transfer(deal.DealUuid)
+	if len(pts) > 1 {
+		first, last := pts[0], pts[len(pts)-1]
+		secs := uint64(last.At.Sub(first.At).Seconds())
+		if secs > 0 && last.Bytes > first.Bytes {
+			rate = (last.Bytes - first.Bytes) / secs
+		}
+	}
+
+	return &transferProgress{
+		DealID:        graphql.ID(deal.DealUuid.String()),
+		BytesReceived: gqltypes.Uint64(received),
+		TransferSize:  gqltypes.Uint64(deal.Transfer.Size),
+		Rate:          gqltypes.Uint64(rate),
+		Stalled:       r.provider.IsTransferStalled(deal.DealUuid),
+		Complete:      complete,
+	}
+}
@@ -0,0 +1,51 @@
+package gql
+
+import (
+	"context"
+
+	gqltypes "github.com/filecoin-project/boost/gql/types"
+	"github.com/filecoin-project/boost/markets/sectoraccessor"
+	"github.com/graph-gophers/graphql-go"
+)
+
+type unsealRequestResolver struct {
+	SectorID  gqltypes.Uint64
+	Offset    gqltypes.Uint64
+	Length    gqltypes.Uint64
+	Priority  int32
+	Waiters   int32
+	CreatedAt graphql.Time
+	StartedAt *graphql.Time
+}
+
+type unsealQueueResolver struct {
+	MaxConcurrent int32
+	Running       []*unsealRequestResolver
+	Queued        []*unsealRequestResolver
+}
+
+// query: unsealQueue: UnsealQueue
+func (r *resolver) UnsealQueue(ctx context.Context) (*unsealQueueResolver, error) {
+	st := r.unseals.Status()
+	return &unsealQueueResolver{
+		MaxConcurrent: int32(st.MaxConcurrent),
+		Running:       toUnsealRequestResolvers(st.Running),
+		Queued:        toUnsealRequestResolvers(st.Queued),
+	}, nil
+}
+
+func toUnsealRequestResolvers(reqs []sectoraccessor.UnsealRequest) []*unsealRequestResolver {
+	resolvers := make([]*unsealRequestResolver, 0, len(reqs))
+	for _, req := range reqs {
+		resolvers = append(resolvers, &unsealRequestResolver{
+			SectorID:  gqltypes.Uint64(req.SectorID),
+			Offset:    gqltypes.Uint64(req.Offset),
+			Length:    gqltypes.Uint64(req.Length),
+			Priority:  int32(req.Priority),
+			Waiters:   int32(req.Waiters),
+			CreatedAt: graphql.Time{Time: req.CreatedAt},
+			StartedAt: nullableTime(req.StartedAt),
+		})
+	}
+	return resolvers
+}
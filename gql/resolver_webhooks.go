@@ -0,0 +1,126 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/filecoin-project/boost/api"
+	"github.com/filecoin-project/boost/db"
+	"github.com/graph-gophers/graphql-go"
+)
+
+type webhookResolver struct {
+	wh db.Webhook
+}
+
+func (w *webhookResolver) ID() graphql.ID {
+	return graphql.ID(strconv.FormatUint(w.wh.ID, 10))
+}
+
+func (w *webhookResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: w.wh.CreatedAt}
+}
+
+func (w *webhookResolver) URL() string {
+	return w.wh.URL
+}
+
+func (w *webhookResolver) EventTypes() []string {
+	if w.wh.EventTypes == nil {
+		return []string{}
+	}
+	return w.wh.EventTypes
+}
+
+// The secret is never returned, just whether the webhook has one
+func (w *webhookResolver) HasSecret() bool {
+	return w.wh.Secret != ""
+}
+
+func (w *webhookResolver) LastError() string {
+	return w.wh.LastError
+}
+
+// query: webhooks: [Webhook]
+func (r *resolver) Webhooks(ctx context.Context) ([]*webhookResolver, error) {
+	if err := r.checkPerm(ctx, api.PermAdmin); err != nil {
+		return nil, err
+	}
+
+	whs, err := r.webhooks.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*webhookResolver, 0, len(whs))
+	for _, wh := range whs {
+		resolvers = append(resolvers, &webhookResolver{wh: wh})
+	}
+	return resolvers, nil
+}
+
+type webhookRegisterArgs struct {
+	URL        string
+	EventTypes *[]string
+	Secret     graphql.NullString
+}
+
+// mutation: webhookRegister(url, eventTypes, secret): Webhook
+func (r *resolver) WebhookRegister(ctx context.Context, args webhookRegisterArgs) (*webhookResolver, error) {
+	if err := r.checkPerm(ctx, api.PermAdmin); err != nil {
+		return nil, err
+	}
+
+	var evtTypes []string
+	if args.EventTypes != nil {
+		evtTypes = *args.EventTypes
+	}
+	var secret string
+	if args.Secret.Set && args.Secret.Value != nil {
+		secret = *args.Secret.Value
+	}
+
+	wh, err := r.webhooks.Register(ctx, args.URL, secret, evtTypes)
+	if err != nil {
+		return nil, err
+	}
+	return &webhookResolver{wh: *wh}, nil
+}
+
+// mutation: webhookUnregister(id): Boolean
+func (r *resolver) WebhookUnregister(ctx context.Context, args struct{ ID graphql.ID }) (bool, error) {
+	if err := r.checkPerm(ctx, api.PermAdmin); err != nil {
+		return false, err
+	}
+
+	id, err := toWebhookID(args.ID)
+	if err != nil {
+		return false, err
+	}
+	return true, r.webhooks.Unregister(ctx, id)
+}
+
+// mutation: webhookTest(id): Boolean
+func (r *resolver) WebhookTest(ctx context.Context, args struct{ ID graphql.ID }) (bool, error) {
+	if err := r.checkPerm(ctx, api.PermAdmin); err != nil {
+		return false, err
+	}
+
+	id, err := toWebhookID(args.ID)
+	if err != nil {
+		return false, err
+	}
+	if err := r.webhooks.SendTest(ctx, id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func toWebhookID(id graphql.ID) (uint64, error) {
+	whID, err := strconv.ParseUint(string(id), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing webhook ID '%s': %w", id, err)
+	}
+	return whID, nil
+}
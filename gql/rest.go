@@ -0,0 +1,267 @@
+package gql
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	gqltypes "github.com/filecoin-project/boost/gql/types"
+	"github.com/gorilla/mux"
+	"github.com/graph-gophers/graphql-go"
+)
+
+// The REST API is a facade over the graphql resolvers, for tooling that
+// can't easily speak graphql
+const restPathPrefix = "/api/v1"
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+type restDeal struct {
+	ID                   string
+	CreatedAt            time.Time
+	ClientAddress        string
+	ClientPeerID         string
+	PieceCid             string
+	PieceSize            uint64
+	DealDataRoot         string
+	IsVerified           bool
+	IsOffline            bool
+	StartEpoch           uint64
+	EndEpoch             uint64
+	ChainDealID          uint64
+	PublishCid           string
+	SectorID             uint64
+	Checkpoint           string
+	CheckpointAt         time.Time
+	Retry                string
+	Err                  string
+	Message              string
+	TransferSize         uint64
+	BytesReceived        uint64
+	AnnounceToIPNI       bool
+	AnnounceAfterSealing bool
+	AnnounceRule         string
+	CollateralWallet     string
+	CollateralRule       string
+}
+
+type restDealList struct {
+	TotalCount int
+	Deals      []restDeal
+	More       bool
+	// Pass as the "after" query parameter to get the next page
+	Next string `json:",omitempty"`
+}
+
+type restError struct {
+	Error string
+}
+
+func newRestRouter(r *resolver) http.Handler {
+	rr := &restRouter{resolver: r}
+	m := mux.NewRouter().PathPrefix(restPathPrefix).Subrouter()
+	m.HandleFunc("/openapi.json", rr.openAPI).Methods(http.MethodGet)
+	m.HandleFunc("/deals", rr.listDeals).Methods(http.MethodGet)
+	m.HandleFunc("/deals/publish-now", rr.publishNow).Methods(http.MethodPost)
+	m.HandleFunc("/deals/{uuid}", rr.getDeal).Methods(http.MethodGet)
+	m.HandleFunc("/deals/{uuid}/cancel", rr.dealAction(r.DealCancel)).Methods(http.MethodPost)
+	m.HandleFunc("/deals/{uuid}/retry", rr.dealAction(r.DealRetryPaused)).Methods(http.MethodPost)
+	m.HandleFunc("/deals/{uuid}/fail", rr.dealAction(r.DealFailPaused)).Methods(http.MethodPost)
+	m.HandleFunc("/audit-log", rr.auditLog).Methods(http.MethodGet)
+	return m
+}
+
+type restRouter struct {
+	resolver *resolver
+}
+
+func (rr *restRouter) openAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPISpec)
+}
+
+// GET /api/v1/deals?query=&checkpoint=&sort=&ascending=&after=&limit=
+func (rr *restRouter) listDeals(w http.ResponseWriter, req *http.Request) {
+	qs := req.URL.Query()
+	limit, err := intParam(qs.Get("limit"), 10)
+	if err != nil {
+		writeRestError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var filter *db.FilterOptions
+	if ckpt := qs.Get("checkpoint"); ckpt != "" {
+		filter = &db.FilterOptions{Checkpoint: &ckpt}
+	}
+
+	sort := db.SortOptions{Key: qs.Get("sort"), Ascending: qs.Get("ascending") == "true"}
+	var after *graphql.ID
+	if a := qs.Get("after"); a != "" {
+		id := graphql.ID(a)
+		after = &id
+	}
+
+	ctx := req.Context()
+	deals, count, more, err := rr.resolver.dealPage(ctx, qs.Get("query"), filter, sort, after, limit)
+	if err != nil {
+		writeRestError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	list := restDealList{TotalCount: count, Deals: make([]restDeal, 0, len(deals)), More: more}
+	for i := range deals {
+		deal := &deals[i]
+		deal.NBytesReceived = int64(rr.resolver.provider.NBytesReceived(deal.DealUuid))
+		list.Deals = append(list.Deals, rr.toRestDeal(req, newDealResolver(deal, rr.resolver.provider, rr.resolver.dealsDB, rr.resolver.logsDB, rr.resolver.spApi)))
+	}
+	if more {
+		list.Next = deals[len(deals)-1].DealUuid.String()
+	}
+
+	writeRestJson(w, list)
+}
+
+// GET /api/v1/deals/{uuid}
+func (rr *restRouter) getDeal(w http.ResponseWriter, req *http.Request) {
+	dr, err := rr.resolver.Deal(req.Context(), struct{ ID graphql.ID }{ID: graphql.ID(mux.Vars(req)["uuid"])})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, sql.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		writeRestError(w, status, err)
+		return
+	}
+
+	writeRestJson(w, rr.toRestDeal(req, dr))
+}
+
+// POST /api/v1/deals/{uuid}/cancel|retry|fail
+func (rr *restRouter) dealAction(action func(ctx context.Context, args struct{ ID graphql.ID }) (graphql.ID, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id, err := action(req.Context(), struct{ ID graphql.ID }{ID: graphql.ID(mux.Vars(req)["uuid"])})
+		if err != nil {
+			writeRestError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeRestJson(w, struct{ ID graphql.ID }{ID: id})
+	}
+}
+
+// POST /api/v1/deals/publish-now
+func (rr *restRouter) publishNow(w http.ResponseWriter, req *http.Request) {
+	if _, err := rr.resolver.DealPublishNow(req.Context()); err != nil {
+		writeRestError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeRestJson(w, struct{}{})
+}
+
+// GET /api/v1/audit-log?deal=&cursor=&limit=
+func (rr *restRouter) auditLog(w http.ResponseWriter, req *http.Request) {
+	qs := req.URL.Query()
+	var args auditLogArgs
+	if d := qs.Get("deal"); d != "" {
+		id := graphql.ID(d)
+		args.DealID = &id
+	}
+	if c := qs.Get("cursor"); c != "" {
+		cursor, err := strconv.ParseUint(c, 10, 64)
+		if err != nil {
+			writeRestError(w, http.StatusBadRequest, err)
+			return
+		}
+		gqlCursor := gqltypes.Uint64(cursor)
+		args.Cursor = &gqlCursor
+	}
+	limit, err := intParam(qs.Get("limit"), 10)
+	if err != nil {
+		writeRestError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit32 := int32(limit)
+	args.Limit = graphql.NullInt{Value: &limit32, Set: true}
+
+	res, err := rr.resolver.AuditLog(req.Context(), args)
+	if err != nil {
+		writeRestError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	evts := make([]db.AuditEvent, 0, len(res.Events))
+	for _, evt := range res.Events {
+		evts = append(evts, evt.AuditEvent)
+	}
+	writeRestJson(w, struct {
+		TotalCount int32
+		Events     []db.AuditEvent
+		More       bool
+	}{TotalCount: res.TotalCount, Events: evts, More: res.More})
+}
+
+func (rr *restRouter) toRestDeal(req *http.Request, dr *dealResolver) restDeal {
+	deal := dr.ProviderDealState
+	prop := deal.ClientDealProposal.Proposal
+	return restDeal{
+		ID:                   deal.DealUuid.String(),
+		CreatedAt:            deal.CreatedAt,
+		ClientAddress:        prop.Client.String(),
+		ClientPeerID:         deal.ClientPeerID.String(),
+		PieceCid:             prop.PieceCID.String(),
+		PieceSize:            uint64(prop.PieceSize),
+		DealDataRoot:         deal.DealDataRoot.String(),
+		IsVerified:           prop.VerifiedDeal,
+		IsOffline:            deal.IsOffline,
+		StartEpoch:           uint64(prop.StartEpoch),
+		EndEpoch:             uint64(prop.EndEpoch),
+		ChainDealID:          uint64(deal.ChainDealID),
+		PublishCid:           dr.PublishCid(),
+		SectorID:             uint64(deal.SectorID),
+		Checkpoint:           deal.Checkpoint.String(),
+		CheckpointAt:         deal.CheckpointAt,
+		Retry:                string(deal.Retry),
+		Err:                  deal.Err,
+		Message:              dr.Message(req.Context()),
+		TransferSize:         deal.Transfer.Size,
+		BytesReceived:        dr.transferred,
+		AnnounceToIPNI:       deal.AnnounceToIPNI,
+		AnnounceAfterSealing: deal.AnnounceAfterSealing,
+		AnnounceRule:         deal.AnnounceRule,
+		CollateralWallet:     deal.CollateralWallet,
+		CollateralRule:       deal.CollateralRule,
+	}
+}
+
+func intParam(val string, dflt int) (int, error) {
+	if val == "" {
+		return dflt, nil
+	}
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, err
+	}
+	if i <= 0 {
+		return dflt, nil
+	}
+	return i, nil
+}
+
+func writeRestJson(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warnw("writing rest api response", "err", err)
+	}
+}
+
+func writeRestError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(restError{Error: err.Error()})
+}
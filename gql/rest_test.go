@@ -0,0 +1,50 @@
+package gql
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/stretchr/testify/require"
+)
+
+// Verify that the OpenAPI spec describes all the fields of the types that
+// the REST API returns, so that clients generated from the spec don't miss
+// fields
+func TestOpenAPISpecMatchesTypes(t *testing.T) {
+	var spec struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{}
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(openAPISpec, &spec))
+
+	for name, typ := range map[string]interface{}{
+		"Deal":           restDeal{},
+		"DealList":       restDealList{},
+		"AuditEvent":     db.AuditEvent{},
+		"AuditEventList": struct{ TotalCount, Events, More bool }{},
+	} {
+		schema, ok := spec.Components.Schemas[name]
+		require.True(t, ok, "missing schema %s", name)
+
+		var specFields []string
+		for f := range schema.Properties {
+			specFields = append(specFields, f)
+		}
+		sort.Strings(specFields)
+
+		var typeFields []string
+		rt := reflect.TypeOf(typ)
+		for i := 0; i < rt.NumField(); i++ {
+			typeFields = append(typeFields, rt.Field(i).Name)
+		}
+		sort.Strings(typeFields)
+
+		require.Equal(t, typeFields, specFields, "schema %s", name)
+	}
+}
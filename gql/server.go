@@ -12,9 +12,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/filecoin-project/boost/api"
 	"github.com/filecoin-project/boost/react"
 	"github.com/graph-gophers/graphql-go"
-	"github.com/graph-gophers/graphql-go/relay"
 	"github.com/graph-gophers/graphql-transport-ws/graphqlws"
 	logging "github.com/ipfs/go-log/v2"
 )
@@ -23,12 +23,16 @@ var log = logging.Logger("gql")
 
 type Server struct {
 	resolver *resolver
+	verify   AuthVerifier
 	srv      *http.Server
 	wg       sync.WaitGroup
+	// The rate limiter is shared by all handlers so that the limit applies
+	// to all of a client's requests
+	limiter *rateLimiter
 }
 
-func NewServer(resolver *resolver) *Server {
-	return &Server{resolver: resolver}
+func NewServer(resolver *resolver, verify AuthVerifier) *Server {
+	return &Server{resolver: resolver, verify: verify}
 }
 
 //go:embed schema.graphql
@@ -56,14 +60,18 @@ func (s *Server) Start(ctx context.Context) error {
 
 	// Allow resolving directly to fields (instead of requiring resolvers to
 	// have a method for every GraphQL field)
-	opts := []graphql.SchemaOpt{graphql.UseFieldResolvers()}
+	opts := []graphql.SchemaOpt{graphql.UseFieldResolvers(), graphql.Tracer(newDeprecationTracer(api.GraphqlDeprecations))}
+	gqlCfg := s.resolver.cfg.Graphql
+	if gqlCfg.MaxQueryDepth > 0 {
+		opts = append(opts, graphql.MaxDepth(gqlCfg.MaxQueryDepth))
+	}
 	schema, err := graphql.ParseSchema(string(schemaGraqhql), s.resolver, opts...)
 	if err != nil {
 		return err
 	}
 
 	// GraphQL handler
-	queryHandler := &relay.Handler{Schema: schema}
+	queryHandler := &queryHandler{schema: schema}
 	wsOpts := []graphqlws.Option{
 		// Add a 5 second timeout for writing responses to the web socket.
 		// A lot of people will expose Boost over an ssh tunnel so the
@@ -72,17 +80,41 @@ func (s *Server) Start(ctx context.Context) error {
 	}
 	wsHandler := graphqlws.NewHandlerFunc(schema, queryHandler, wsOpts...)
 
+	if gqlCfg.RateLimit > 0 {
+		s.limiter = newRateLimiter(gqlCfg.RateLimit, gqlCfg.RateLimitBurst, gqlCfg.RequireAuth, gqlCfg.TrustForwardedFor)
+	}
+
+	tlsCfg, err := tlsConfig(gqlCfg.TLS, s.resolver.repo.Path())
+	if err != nil {
+		return err
+	}
+
 	listenAddr := fmt.Sprintf(":%d", port)
-	s.srv = &http.Server{Addr: listenAddr, Handler: mux}
-	fmt.Printf("Graphql server listening on %s\n", listenAddr)
-	mux.Handle("/graphql/subscription", &corsHandler{wsHandler})
-	mux.Handle("/graphql/query", &corsHandler{queryHandler})
+	s.srv = &http.Server{Addr: listenAddr, Handler: mux, TLSConfig: tlsCfg}
+	if tlsCfg != nil {
+		fmt.Printf("Graphql server listening on %s (TLS)\n", listenAddr)
+	} else {
+		fmt.Printf("Graphql server listening on %s\n", listenAddr)
+	}
+	mux.Handle("/graphql/subscription", s.wrapHandler(wsHandler))
+	mux.Handle("/graphql/query", s.wrapHandler(queryHandler))
+	mux.Handle("/graphql/changelog", s.wrapHandler(http.HandlerFunc(changelogHandler)))
+
+	// REST API
+	mux.Handle(restPathPrefix+"/", s.wrapHandler(newRestRouter(s.resolver)))
 
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
 
-		if err := s.srv.ListenAndServe(); err != http.ErrServerClosed {
+		var err error
+		if tlsCfg != nil {
+			// The certificate is in the TLS config
+			err = s.srv.ListenAndServeTLS("", "")
+		} else {
+			err = s.srv.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
 			log.Fatalf("gql.ListenAndServe(): %v", err)
 		}
 	}()
@@ -90,6 +122,24 @@ func (s *Server) Start(ctx context.Context) error {
 	return nil
 }
 
+// wrapHandler applies the request size cap, rate limit and authentication
+// (if configured) to the handler, and checks the API version requested by
+// the client
+func (s *Server) wrapHandler(h http.Handler) http.Handler {
+	gqlCfg := s.resolver.cfg.Graphql
+	if gqlCfg.MaxRequestBytes > 0 {
+		h = maxBytesHandler(gqlCfg.MaxRequestBytes, h)
+	}
+	if s.limiter != nil {
+		h = rateLimitHandler(s.limiter, h)
+	}
+	// If authentication is required, requests must include an API token
+	if gqlCfg.RequireAuth {
+		h = authHandler(s.verify, h)
+	}
+	return newCorsHandler(gqlCfg.CORSAllowedOrigins, versionHandler(api.GraphqlAPIVersion, &actorHandler{verify: s.verify, sub: h}))
+}
+
 // fsPrefix adds a prefix to all Open() calls
 type fsPrefix struct {
 	fs.FS
@@ -0,0 +1,53 @@
+package gql
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/filecoin-project/boost/node/config"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// tlsConfig returns the TLS config for the graphql server, or nil if the
+// server should not use TLS
+func tlsConfig(cfg config.GraphqlTLSConfig, repoPath string) (*tls.Config, error) {
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, errors.New("both CertFile and KeyFile must be set to serve graphql over TLS")
+		}
+
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading TLS certificate %s and key %s: %w", cfg.CertFile, cfg.KeyFile, err)
+		}
+		return &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		}, nil
+	}
+
+	if len(cfg.ACMEDomains) == 0 {
+		return nil, nil
+	}
+
+	cacheDir := cfg.ACMECacheDir
+	if cacheDir == "" {
+		cacheDir = filepath.Join(repoPath, "acme")
+	}
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.ACMEDomains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      cfg.ACMEEmail,
+	}
+	if cfg.ACMEDirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: cfg.ACMEDirectoryURL}
+	}
+
+	tlsCfg := m.TLSConfig()
+	tlsCfg.MinVersion = tls.VersionTLS12
+	return tlsCfg, nil
+}
@@ -0,0 +1,65 @@
+package gql
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/node/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	// No TLS
+	tlsCfg, err := tlsConfig(config.GraphqlTLSConfig{}, dir)
+	require.NoError(t, err)
+	require.Nil(t, tlsCfg)
+
+	// Cert and key must both be set
+	_, err = tlsConfig(config.GraphqlTLSConfig{CertFile: "cert.pem"}, dir)
+	require.Error(t, err)
+
+	// Cert and key files
+	certFile, keyFile := writeTestCert(t, dir)
+	tlsCfg, err = tlsConfig(config.GraphqlTLSConfig{CertFile: certFile, KeyFile: keyFile}, dir)
+	require.NoError(t, err)
+	require.Len(t, tlsCfg.Certificates, 1)
+
+	// ACME
+	tlsCfg, err = tlsConfig(config.GraphqlTLSConfig{ACMEDomains: []string{"boost.example.com"}}, dir)
+	require.NoError(t, err)
+	require.NotNil(t, tlsCfg.GetCertificate)
+}
+
+func writeTestCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
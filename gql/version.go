@@ -0,0 +1,145 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/filecoin-project/boost/api"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/trace"
+)
+
+type apiVersion struct {
+	Graphql string
+	RPC     string
+}
+
+// query: apiVersion: ApiVersion!
+func (r *resolver) ApiVersion() *apiVersion {
+	return &apiVersion{
+		Graphql: api.GraphqlAPIVersion.String(),
+		RPC:     api.BoostAPIVersion0.String(),
+	}
+}
+
+// versionHandler sets the API version header on each response, and rejects
+// requests from clients that were written against an API version that this
+// server can't serve
+func versionHandler(version api.Version, sub http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(api.VersionHeader, version.String())
+		if requested := r.Header.Get(api.VersionHeader); requested != "" {
+			if err := api.CheckRequestedVersion(requested, version); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		sub.ServeHTTP(w, r)
+	})
+}
+
+// changelogHandler serves the changelog of the JSON-RPC and graphql APIs
+func changelogHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(api.GetChangelog())
+}
+
+// deprecationsUsed collects the deprecated fields used by a query
+type deprecationsUsed struct {
+	lk   sync.Mutex
+	used []api.Deprecation
+}
+
+func (d *deprecationsUsed) add(dep api.Deprecation) {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	for _, u := range d.used {
+		if u.Name == dep.Name {
+			return
+		}
+	}
+	d.used = append(d.used, dep)
+}
+
+func (d *deprecationsUsed) warnings() []string {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	warnings := make([]string, 0, len(d.used))
+	for _, u := range d.used {
+		warnings = append(warnings, u.Warning())
+	}
+	return warnings
+}
+
+type deprecationsUsedKey struct{}
+
+// deprecationTracer records the deprecated fields that a query resolves, so
+// that the query handler can warn the client
+type deprecationTracer struct {
+	*metricsTracer
+	deprecated map[string]api.Deprecation
+}
+
+func newDeprecationTracer(deprecations []api.Deprecation) *deprecationTracer {
+	deprecated := make(map[string]api.Deprecation, len(deprecations))
+	for _, d := range deprecations {
+		deprecated[d.Name] = d
+	}
+	return &deprecationTracer{metricsTracer: &metricsTracer{}, deprecated: deprecated}
+}
+
+func (t *deprecationTracer) TraceField(ctx context.Context, label, typeName, fieldName string, trivial bool, args map[string]interface{}) (context.Context, trace.TraceFieldFinishFunc) {
+	if dep, ok := t.deprecated[typeName+"."+fieldName]; ok {
+		if used, ok := ctx.Value(deprecationsUsedKey{}).(*deprecationsUsed); ok {
+			used.add(dep)
+		}
+	}
+	return t.metricsTracer.TraceField(ctx, label, typeName, fieldName, trivial, args)
+}
+
+// queryHandler executes graphql queries sent over HTTP.
+// If a query uses deprecated fields, the response includes a warning for
+// each field in the deprecations extension, and in the Warning header.
+type queryHandler struct {
+	schema *graphql.Schema
+}
+
+func (h *queryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	used := &deprecationsUsed{}
+	ctx := context.WithValue(r.Context(), deprecationsUsedKey{}, used)
+	response := h.schema.Exec(ctx, params.Query, params.OperationName, params.Variables)
+	if warnings := used.warnings(); len(warnings) > 0 {
+		if response.Extensions == nil {
+			response.Extensions = make(map[string]interface{})
+		}
+		response.Extensions["deprecations"] = warnings
+		w.Header().Set("Deprecation", "true")
+		for _, warning := range warnings {
+			w.Header().Add("Warning", "299 - "+strconv.Quote(warning))
+		}
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(responseJSON)
+}
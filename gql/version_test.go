@@ -0,0 +1,95 @@
+package gql
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/filecoin-project/boost/api"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionHandler(t *testing.T) {
+	version, err := api.ParseVersion("1.2.3")
+	require.NoError(t, err)
+	h := versionHandler(version, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tcs := []struct {
+		requested  string
+		expectCode int
+	}{
+		{"", http.StatusOK},
+		{"1", http.StatusOK},
+		{"1.1", http.StatusOK},
+		{"1.2", http.StatusOK},
+		{"1.3", http.StatusBadRequest},
+		{"2.0", http.StatusBadRequest},
+		{"not a version", http.StatusBadRequest},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.requested, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/graphql/query", nil)
+			if tc.requested != "" {
+				req.Header.Set(api.VersionHeader, tc.requested)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			require.Equal(t, tc.expectCode, rec.Code)
+			require.Equal(t, "1.2.3", rec.Header().Get(api.VersionHeader))
+		})
+	}
+}
+
+type testDeprecationsResolver struct{}
+
+func (testDeprecationsResolver) Current() string { return "current" }
+func (testDeprecationsResolver) Old() string     { return "old" }
+
+func TestQueryHandlerDeprecations(t *testing.T) {
+	schemaStr := `
+schema {
+  query: Query
+}
+type Query {
+  current: String!
+  old: String! @deprecated(reason: "use current")
+}
+`
+	tracer := newDeprecationTracer([]api.Deprecation{{
+		Name:        "Query.old",
+		Since:       "1.1.0",
+		Replacement: "Query.current",
+	}})
+	schema, err := graphql.ParseSchema(schemaStr, &testDeprecationsResolver{}, graphql.Tracer(tracer))
+	require.NoError(t, err)
+	h := &queryHandler{schema: schema}
+
+	query := func(q string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		body, err := json.Marshal(map[string]string{"query": q})
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql/query", bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return rec, res
+	}
+
+	// A query that doesn't use deprecated fields has no warnings
+	rec, res := query("{ current }")
+	require.Empty(t, rec.Header().Get("Deprecation"))
+	require.NotContains(t, res, "extensions")
+
+	// A query that uses a deprecated field has a warning
+	rec, res = query("{ current old }")
+	require.Equal(t, "true", rec.Header().Get("Deprecation"))
+	require.Contains(t, rec.Header().Get("Warning"), "Query.old is deprecated")
+	exts := res["extensions"].(map[string]interface{})
+	deps := exts["deprecations"].([]interface{})
+	require.Len(t, deps, 1)
+	require.Equal(t, "Query.old is deprecated since API version 1.1.0: use Query.current instead", deps[0])
+}
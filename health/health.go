@@ -0,0 +1,187 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("health")
+
+const (
+	// How often to check each component
+	defaultCheckInterval = 30 * time.Second
+	// The maximum time a single check may take before it's considered failed
+	checkTimeout = 10 * time.Second
+)
+
+// CheckFunc checks the health of a component, returning an error if the
+// component is unhealthy
+type CheckFunc func(ctx context.Context) error
+
+// Status is the health of each of the components that boost depends on
+type Status struct {
+	// True if all components are healthy
+	Healthy    bool
+	Components []ComponentStatus
+}
+
+// ComponentStatus is the health of a single component
+type ComponentStatus struct {
+	Name    string
+	Healthy bool
+	// The time of the last check (zero if the component hasn't been checked
+	// yet)
+	LastCheck time.Time
+	// The time of the last successful check (zero if no check has succeeded
+	// since boost started)
+	LastSuccess time.Time
+	// The error from the last check, if it failed
+	Error string
+}
+
+type component struct {
+	check  CheckFunc
+	status ComponentStatus
+}
+
+// Checker periodically checks the health of each registered component, so
+// that operators and orchestration can find which dependency is broken
+type Checker struct {
+	interval time.Duration
+
+	lk         sync.Mutex
+	components []*component
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewChecker(interval time.Duration) *Checker {
+	if interval <= 0 {
+		interval = defaultCheckInterval
+	}
+	return &Checker{interval: interval}
+}
+
+// Register adds a component to be checked. Components must be registered
+// before the checker is started.
+func (c *Checker) Register(name string, check CheckFunc) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	c.components = append(c.components, &component{
+		check:  check,
+		status: ComponentStatus{Name: name, Error: "not checked yet"},
+	})
+}
+
+func (c *Checker) Start(ctx context.Context) {
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	c.wg.Add(1)
+	go c.run(c.ctx)
+}
+
+func (c *Checker) Stop() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+}
+
+func (c *Checker) run(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.checkAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkAll checks all components in parallel, so that a component that
+// hangs doesn't delay the checks of the other components
+func (c *Checker) checkAll(ctx context.Context) {
+	c.lk.Lock()
+	components := c.components
+	c.lk.Unlock()
+
+	var wg sync.WaitGroup
+	for _, comp := range components {
+		wg.Add(1)
+		go func(comp *component) {
+			defer wg.Done()
+			c.checkComponent(ctx, comp)
+		}(comp)
+	}
+	wg.Wait()
+}
+
+func (c *Checker) checkComponent(ctx context.Context, comp *component) {
+	checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+	err := comp.check(checkCtx)
+	cancel()
+	if ctx.Err() != nil {
+		// Shutting down
+		return
+	}
+
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	st := &comp.status
+	st.LastCheck = time.Now()
+	if err != nil {
+		if st.Healthy || st.LastSuccess.IsZero() {
+			log.Warnw("component is unhealthy", "component", st.Name, "err", err)
+		}
+		st.Healthy = false
+		st.Error = err.Error()
+		return
+	}
+
+	if !st.Healthy && !st.LastSuccess.IsZero() {
+		log.Infow("component is healthy again", "component", st.Name)
+	}
+	st.Healthy = true
+	st.LastSuccess = st.LastCheck
+	st.Error = ""
+}
+
+// Status returns the result of the latest check of each component
+func (c *Checker) Status() Status {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	st := Status{Healthy: true, Components: make([]ComponentStatus, 0, len(c.components))}
+	for _, comp := range c.components {
+		st.Components = append(st.Components, comp.status)
+		if !comp.status.Healthy {
+			st.Healthy = false
+		}
+	}
+	return st
+}
+
+// ServeHTTP responds with the health status as JSON. The status code is 200
+// if all components are healthy, or 503 if any component is unhealthy.
+func (c *Checker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	st := c.Status()
+	w.Header().Set("Content-Type", "application/json")
+	if !st.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(st)
+}
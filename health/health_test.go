@@ -0,0 +1,63 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecker(t *testing.T) {
+	ctx := context.Background()
+
+	var dbErr error
+	c := NewChecker(0)
+	c.Register("full node", func(ctx context.Context) error { return nil })
+	c.Register("db", func(ctx context.Context) error { return dbErr })
+
+	// Components that haven't been checked yet are unhealthy
+	st := c.Status()
+	require.False(t, st.Healthy)
+	require.Len(t, st.Components, 2)
+	require.Equal(t, "not checked yet", st.Components[0].Error)
+
+	c.checkAll(ctx)
+	st = c.Status()
+	require.True(t, st.Healthy)
+	require.Equal(t, "full node", st.Components[0].Name)
+	require.True(t, st.Components[1].Healthy)
+	lastSuccess := st.Components[1].LastSuccess
+	require.False(t, lastSuccess.IsZero())
+
+	// The db check fails
+	dbErr = errors.New("database is locked")
+	c.checkAll(ctx)
+	st = c.Status()
+	require.False(t, st.Healthy)
+	require.True(t, st.Components[0].Healthy)
+	dbst := st.Components[1]
+	require.False(t, dbst.Healthy)
+	require.Equal(t, "database is locked", dbst.Error)
+	require.Equal(t, lastSuccess, dbst.LastSuccess)
+	require.True(t, dbst.LastCheck.After(lastSuccess))
+
+	// The http handler returns 503 while a component is unhealthy
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	var resp Status
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	require.False(t, resp.Healthy)
+	require.Equal(t, "database is locked", resp.Components[1].Error)
+
+	// The db recovers
+	dbErr = nil
+	c.checkAll(ctx)
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+}
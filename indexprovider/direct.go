@@ -0,0 +1,192 @@
+package indexprovider
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/api"
+	"github.com/ipfs/go-cid"
+)
+
+const (
+	// The time to wait before retrying a failed direct HTTP announcement to
+	// an indexer. The wait doubles with each consecutive failure, up to
+	// directAnnounceMaxBackoff.
+	directAnnounceMinBackoff = 30 * time.Second
+	directAnnounceMaxBackoff = 30 * time.Minute
+)
+
+// httpPublisher is implemented by the index provider engine
+type httpPublisher interface {
+	PublishLatestHTTP(ctx context.Context, announceURLs ...*url.URL) (cid.Cid, error)
+}
+
+// directAnnouncer announces the latest advertisement to each of the
+// configured indexers over HTTP.
+// Each indexer is a separate target with its own retry state, so that an
+// indexer that is down doesn't hold up announcements to the others.
+type directAnnouncer struct {
+	pub     httpPublisher
+	targets []*directTarget
+
+	lk sync.Mutex
+}
+
+type directTarget struct {
+	u         *url.URL
+	published chan struct{}
+
+	// guarded by directAnnouncer.lk
+	status api.IndexerDirectAnnounceStatus
+}
+
+func newDirectAnnouncer(pub httpPublisher, announceURLs []string) (*directAnnouncer, error) {
+	d := &directAnnouncer{pub: pub}
+	seen := make(map[string]struct{}, len(announceURLs))
+	for _, us := range announceURLs {
+		u, err := url.Parse(us)
+		if err != nil {
+			return nil, fmt.Errorf("parsing direct announce URL %s: %w", us, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("direct announce URL %s must be http or https", us)
+		}
+		if _, ok := seen[u.String()]; ok {
+			return nil, fmt.Errorf("direct announce URL %s is configured more than once", us)
+		}
+		seen[u.String()] = struct{}{}
+
+		d.targets = append(d.targets, &directTarget{
+			u:         u,
+			published: make(chan struct{}, 1),
+			status:    api.IndexerDirectAnnounceStatus{URL: u.String()},
+		})
+	}
+	return d, nil
+}
+
+// notify is called when a new advertisement is published.
+// It doesn't block: notifications that arrive while an announcement is in
+// progress are coalesced.
+func (d *directAnnouncer) notify() {
+	for _, t := range d.targets {
+		select {
+		case t.published <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (d *directAnnouncer) run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	var twg sync.WaitGroup
+	for _, t := range d.targets {
+		twg.Add(1)
+		go func(t *directTarget) {
+			defer twg.Done()
+			d.runTarget(ctx, t)
+		}(t)
+	}
+	twg.Wait()
+}
+
+// runTarget announces to the target each time an advertisement is
+// published, and retries with backoff while announcements to it are failing
+func (d *directAnnouncer) runTarget(ctx context.Context, t *directTarget) {
+	// Announce on startup in case advertisements were published while
+	// boost was down, or the indexer was unreachable
+	retry := d.announce(ctx, t)
+	for {
+		var timer *time.Timer
+		var retryC <-chan time.Time
+		if retry > 0 {
+			timer = time.NewTimer(retry)
+			retryC = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-t.published:
+		case <-retryC:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		retry = d.announce(ctx, t)
+	}
+}
+
+// announce sends an announcement for the latest advertisement to the
+// target. It returns the time to wait before retrying, or zero if the
+// announcement succeeded.
+func (d *directAnnouncer) announce(ctx context.Context, t *directTarget) time.Duration {
+	adCid, err := d.pub.PublishLatestHTTP(ctx, t.u)
+	if ctx.Err() != nil {
+		return 0
+	}
+
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	st := &t.status
+	st.LastAttempt = time.Now()
+	if err != nil {
+		st.Failures++
+		st.LastError = err.Error()
+		retry := directAnnounceBackoff(st.Failures)
+		st.NextAttempt = st.LastAttempt.Add(retry)
+		log.Warnw("failed to announce advertisement directly to indexer", "url", t.u,
+			"failures", st.Failures, "next-attempt", st.NextAttempt, "err", err)
+		return retry
+	}
+
+	st.Failures = 0
+	st.LastError = ""
+	st.NextAttempt = time.Time{}
+	if adCid == cid.Undef {
+		// there are no advertisements yet
+		return 0
+	}
+	st.LastSuccess = st.LastAttempt
+	st.LastAdvertisement = adCid
+	log.Debugw("announced advertisement directly to indexer", "url", t.u, "advertisement-cid", adCid)
+	return 0
+}
+
+// directAnnounceBackoff returns the time to wait before retrying an
+// announcement to an indexer that has failed the given number of times in a
+// row
+func directAnnounceBackoff(failures int) time.Duration {
+	backoff := directAnnounceMinBackoff
+	for i := 1; i < failures && backoff < directAnnounceMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > directAnnounceMaxBackoff {
+		backoff = directAnnounceMaxBackoff
+	}
+	return backoff
+}
+
+// Status returns the status of direct announcements to each indexer,
+// ordered by URL
+func (d *directAnnouncer) Status() []api.IndexerDirectAnnounceStatus {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	status := make([]api.IndexerDirectAnnounceStatus, 0, len(d.targets))
+	for _, t := range d.targets {
+		status = append(status, t.status)
+	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].URL < status[j].URL
+	})
+	return status
+}
@@ -0,0 +1,116 @@
+package indexprovider
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+type mockHTTPPublisher struct {
+	adCid cid.Cid
+
+	lk      sync.Mutex
+	failing map[string]bool
+	calls   map[string]int
+}
+
+func (m *mockHTTPPublisher) PublishLatestHTTP(ctx context.Context, announceURLs ...*url.URL) (cid.Cid, error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	u := announceURLs[0].String()
+	m.calls[u]++
+	if m.failing[u] {
+		return cid.Undef, errors.New("indexer unavailable")
+	}
+	return m.adCid, nil
+}
+
+func (m *mockHTTPPublisher) callCount(u string) int {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return m.calls[u]
+}
+
+func TestDirectAnnouncerIndependentTargets(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	adCid, err := cid.Parse("bafy2bzacea3wsdh6y3a36tb3skempjoxqpuyompjbmfeyf34fi3uy6uue42v4")
+	require.NoError(t, err)
+
+	up := "https://up.example.com"
+	down := "https://down.example.com"
+	pub := &mockHTTPPublisher{
+		adCid:   adCid,
+		failing: map[string]bool{down: true},
+		calls:   make(map[string]int),
+	}
+	d, err := newDirectAnnouncer(pub, []string{up, down})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go d.run(ctx, &wg)
+
+	// Both targets are announced to on startup
+	require.Eventually(t, func() bool {
+		return pub.callCount(up) == 1 && pub.callCount(down) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		st := d.Status()
+		return !st[0].LastAttempt.IsZero() && !st[1].LastAttempt.IsZero()
+	}, time.Second, 10*time.Millisecond)
+	st := d.Status()
+	require.Equal(t, down, st[0].URL)
+	require.Equal(t, 1, st[0].Failures)
+	require.NotEmpty(t, st[0].LastError)
+	require.False(t, st[0].NextAttempt.IsZero())
+	require.Equal(t, up, st[1].URL)
+	require.Equal(t, 0, st[1].Failures)
+	require.Equal(t, adCid, st[1].LastAdvertisement)
+
+	// A new advertisement is announced to each target
+	d.notify()
+	require.Eventually(t, func() bool {
+		return pub.callCount(up) == 2 && pub.callCount(down) == 2
+	}, time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return d.Status()[0].Failures == 2
+	}, time.Second, 10*time.Millisecond)
+
+	// Once the indexer comes back, its retry state is reset
+	pub.lk.Lock()
+	pub.failing[down] = false
+	pub.lk.Unlock()
+	d.notify()
+	require.Eventually(t, func() bool {
+		st := d.Status()[0]
+		return st.Failures == 0 && st.LastError == "" && st.NextAttempt.IsZero() && st.LastAdvertisement == adCid
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	wg.Wait()
+}
+
+func TestDirectAnnouncerInvalidURLs(t *testing.T) {
+	_, err := newDirectAnnouncer(nil, []string{"ftp://example.com"})
+	require.Error(t, err)
+
+	_, err = newDirectAnnouncer(nil, []string{"https://example.com", "https://example.com"})
+	require.Error(t, err)
+}
+
+func TestDirectAnnounceBackoff(t *testing.T) {
+	require.Equal(t, directAnnounceMinBackoff, directAnnounceBackoff(1))
+	require.Equal(t, 2*directAnnounceMinBackoff, directAnnounceBackoff(2))
+	require.Equal(t, 4*directAnnounceMinBackoff, directAnnounceBackoff(3))
+	require.Equal(t, directAnnounceMaxBackoff, directAnnounceBackoff(100))
+}
@@ -0,0 +1,201 @@
+package indexprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	ltypes "github.com/filecoin-project/lotus/chain/types"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+	provider "github.com/ipni/index-provider"
+)
+
+// removeEndedAnnouncements periodically checks for deals that the index
+// provider can no longer serve retrievals for, and publishes removal
+// advertisements so that the network indexer stops routing retrievals to
+// them
+func (w *Wrapper) removeEndedAnnouncements(ctx context.Context, interval time.Duration) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := w.checkAnnouncements(ctx); err != nil && ctx.Err() == nil {
+			log.Errorw("failed to check for announcements to remove", "err", err)
+		}
+	}
+}
+
+func (w *Wrapper) checkAnnouncements(ctx context.Context) error {
+	head, err := w.fullnodeApi.ChainHead(ctx)
+	if err != nil {
+		return fmt.Errorf("getting chain head: %w", err)
+	}
+	deals, err := w.dealsDB.ListActive(ctx)
+	if err != nil {
+		return fmt.Errorf("listing active deals: %w", err)
+	}
+	removed, err := w.removedDB.List(ctx)
+	if err != nil {
+		return fmt.Errorf("listing removed announcements: %w", err)
+	}
+
+	var nRemoved, nReannounced int
+	for _, d := range deals {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// only deals that were announced can be removed
+		if d.Checkpoint != dealcheckpoints.IndexedAndAnnounced || !d.AnnounceToIPNI {
+			continue
+		}
+
+		if ra, ok := removed[d.DealUuid]; ok {
+			// If the announcement was removed because there was no unsealed
+			// copy, announce the deal again once there's an unsealed copy
+			if ra.Reason == db.AnnouncementRemovedNoUnsealed && w.dealEnded(ctx, d, head) == "" && w.isUnsealed(ctx, d) {
+				if w.reannounce(ctx, d) {
+					nReannounced++
+				}
+			}
+			continue
+		}
+
+		reason := w.dealEnded(ctx, d, head)
+		if reason == "" && w.cfg.Dealmaking.RemoveAdvertisementsWithoutUnsealedCopy && d.FastRetrieval && !w.isUnsealed(ctx, d) {
+			reason = db.AnnouncementRemovedNoUnsealed
+		}
+		if reason == "" {
+			continue
+		}
+
+		if err := w.removeAnnouncement(ctx, d, reason); err != nil {
+			log.Errorw("failed to remove announcement", "dealId", d.DealUuid, "reason", reason, "err", err)
+			continue
+		}
+		nRemoved++
+	}
+
+	if nRemoved > 0 || nReannounced > 0 {
+		log.Infow("finished checking for announcements to remove", "removed", nRemoved, "re-announced", nReannounced)
+	}
+	return nil
+}
+
+// dealEnded returns the reason that the deal is no longer active on chain,
+// or the empty string if it's still active
+func (w *Wrapper) dealEnded(ctx context.Context, d *types.ProviderDealState, head *ltypes.TipSet) string {
+	if head.Height() >= d.ClientDealProposal.Proposal.EndEpoch {
+		return db.AnnouncementRemovedExpired
+	}
+
+	md, err := w.fullnodeApi.StateMarketStorageDeal(ctx, d.ChainDealID, head.Key())
+	if err != nil {
+		// The deal may not be found, eg if it was terminated and has already
+		// been cleaned up by the market actor. As we can't tell, err on the
+		// side of keeping the announcement.
+		log.Debugw("failed to get market deal state", "dealId", d.DealUuid, "chainDealId", d.ChainDealID, "err", err)
+		return ""
+	}
+	if md.State.SlashEpoch >= 0 {
+		return db.AnnouncementRemovedSlashed
+	}
+	return ""
+}
+
+func (w *Wrapper) isUnsealed(ctx context.Context, d *types.ProviderDealState) bool {
+	isUnsealed, err := w.sa.IsUnsealed(ctx, d.SectorID, d.Offset.Unpadded(), d.Length.Unpadded())
+	if err != nil {
+		// If we can't tell, assume the piece is still unsealed so that the
+		// announcement isn't removed
+		log.Debugw("failed to check if sector is unsealed", "dealId", d.DealUuid, "sector", d.SectorID, "err", err)
+		return true
+	}
+	return isUnsealed
+}
+
+// removeAnnouncement publishes an advertisement that tells the network
+// indexer to remove the deal
+func (w *Wrapper) removeAnnouncement(ctx context.Context, d *types.ProviderDealState, reason string) error {
+	propCid, err := d.SignedProposalCid()
+	if err != nil {
+		return fmt.Errorf("failed to get proposal cid from deal: %w", err)
+	}
+
+	adCid, err := w.prov.NotifyRemove(ctx, "", propCid.Bytes())
+	if err != nil && !errors.Is(err, provider.ErrContextIDNotFound) {
+		return fmt.Errorf("failed to publish removal advertisement: %w", err)
+	}
+	log.Infow("removed deal announcement from Indexer", "dealId", d.DealUuid, "reason", reason, "advertisement-cid", adCid)
+	if adCid != cid.Undef {
+		w.published()
+	}
+
+	if err := w.removedDB.Add(ctx, d.DealUuid, reason); err != nil {
+		return fmt.Errorf("failed to record removed announcement: %w", err)
+	}
+	// there's no point retrying an announcement that was removed
+	if err := w.pendingDB.Delete(ctx, d.DealUuid); err != nil {
+		log.Warnw("failed to remove pending announcement", "dealId", d.DealUuid, "err", err)
+	}
+	return nil
+}
+
+// RemovePieceAnnouncements publishes removal advertisements for the
+// announced deals for the piece, and returns the deals that were (or in a
+// dry run, would be) removed
+func (w *Wrapper) RemovePieceAnnouncements(ctx context.Context, pieceCid cid.Cid, dryRun bool) ([]uuid.UUID, error) {
+	deals, err := w.dealsDB.ByPieceCID(ctx, pieceCid)
+	if err != nil {
+		return nil, fmt.Errorf("getting deals for piece %s: %w", pieceCid, err)
+	}
+	removed, err := w.removedDB.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing removed announcements: %w", err)
+	}
+
+	var dealUuids []uuid.UUID
+	for _, d := range deals {
+		if !d.AnnounceToIPNI || d.Checkpoint < dealcheckpoints.IndexedAndAnnounced {
+			continue
+		}
+		if _, ok := removed[d.DealUuid]; ok {
+			continue
+		}
+
+		if !dryRun {
+			if err := w.removeAnnouncement(ctx, d, db.AnnouncementRemovedPieceRemoved); err != nil {
+				return dealUuids, fmt.Errorf("removing announcement for deal %s: %w", d.DealUuid, err)
+			}
+		}
+		dealUuids = append(dealUuids, d.DealUuid)
+	}
+	return dealUuids, nil
+}
+
+// reannounce announces a deal whose announcement was removed.
+// Returns true if the announcement succeeded.
+func (w *Wrapper) reannounce(ctx context.Context, d *types.ProviderDealState) bool {
+	if _, err := w.AnnounceBoostDeal(ctx, d); err != nil && !errors.Is(err, provider.ErrAlreadyAdvertised) {
+		log.Warnw("failed to re-announce deal to Indexer", "dealId", d.DealUuid, "err", err)
+		return false
+	}
+	if err := w.removedDB.Delete(ctx, d.DealUuid); err != nil {
+		log.Errorw("failed to clear removed announcement", "dealId", d.DealUuid, "err", err)
+		return false
+	}
+	log.Infow("re-announced deal to Indexer", "dealId", d.DealUuid)
+	return true
+}
@@ -0,0 +1,98 @@
+package indexprovider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/filecoin-project/boost/api"
+	"github.com/filecoin-project/boost/build"
+	"github.com/filecoin-project/boost/node/modules/dtypes"
+	lotus_dtypes "github.com/filecoin-project/lotus/node/modules/dtypes"
+)
+
+// The file that the time of the last publish is saved to, so that it's kept
+// across restarts
+var lastPublishFile = ".boost-last-publish"
+
+// TopicName returns the gossipsub topic that the index provider announces
+// advertisements on.
+// If the configured topic name is empty, it's inferred from the network name.
+func TopicName(configured string, nn lotus_dtypes.NetworkName) string {
+	if configured != "" {
+		return configured
+	}
+	// Use the same mechanism as the Dependency Injection (DI) to construct the topic name,
+	// so that we are certain it is consistent with the name allowed by the subscription
+	// filter.
+	//
+	// See: lp2p.GossipSub.
+	return build.IndexerIngestTopic(dtypes.NetworkName(nn))
+}
+
+// Status returns the health of the index provider, so that operators can
+// tell whether indexers are keeping up with the advertisement chain
+func (w *Wrapper) Status(ctx context.Context) (*api.IndexerStatus, error) {
+	st := &api.IndexerStatus{
+		Enabled:        w.enabled,
+		Topic:          w.topic,
+		DirectAnnounce: w.DirectAnnounceStatus(),
+		LastVerify:     w.LastVerification(),
+	}
+	if !w.enabled {
+		return st, nil
+	}
+
+	latest, _, err := w.prov.GetLatestAdv(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting latest advertisement: %w", err)
+	}
+	st.LatestAdvertisement = latest
+
+	pending, err := w.pendingDB.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing pending announcements: %w", err)
+	}
+	st.PendingAnnouncements = len(pending)
+	st.EntriesBacklog = int(atomic.LoadInt64(&w.entriesBacklog))
+
+	if w.ps != nil {
+		st.GossipsubPeers = len(w.ps.ListPeers(w.topic))
+	}
+
+	w.lastPublishLk.Lock()
+	st.LastPublish = w.lastPublish
+	w.lastPublishLk.Unlock()
+
+	return st, nil
+}
+
+func (w *Wrapper) setLastPublish(t time.Time) {
+	w.lastPublishLk.Lock()
+	defer w.lastPublishLk.Unlock()
+	w.lastPublish = t
+
+	path := filepath.Join(w.cfg.DAGStore.RootDir, lastPublishFile)
+	if err := os.WriteFile(path, []byte(t.Format(time.RFC3339Nano)), 0644); err != nil {
+		log.Warnw("failed to save last publish time", "err", err)
+	}
+}
+
+// loadLastPublish returns the time of the last publish before boost was
+// restarted, or the zero time if nothing has been published
+func (w *Wrapper) loadLastPublish() time.Time {
+	bz, err := os.ReadFile(filepath.Join(w.cfg.DAGStore.RootDir, lastPublishFile))
+	if err != nil {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(bz)))
+	if err != nil {
+		log.Warnw("failed to parse last publish time", "err", err)
+		return time.Time{}
+	}
+	return t
+}
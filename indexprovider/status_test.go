@@ -0,0 +1,65 @@
+package indexprovider
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/node/config"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastPublishPersisted(t *testing.T) {
+	cfg := &config.Boost{}
+	cfg.DAGStore.RootDir = t.TempDir()
+
+	w := &Wrapper{cfg: cfg}
+	require.True(t, w.loadLastPublish().IsZero())
+
+	published := time.Now()
+	w.setLastPublish(published)
+
+	// The publish time is loaded again after a restart
+	restarted := &Wrapper{cfg: cfg}
+	require.True(t, published.Equal(restarted.loadLastPublish()))
+}
+
+func TestEntriesBacklog(t *testing.T) {
+	ctx := context.Background()
+	w := &Wrapper{entriesThrottle: make(chan struct{}, 1)}
+	pds := &types.ProviderDealState{DealUuid: uuid.New()}
+
+	release, err := w.acquireEntriesThrottle(ctx, pds)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, atomic.LoadInt64(&w.entriesBacklog))
+
+	// The second deal waits for a slot, and is counted in the backlog
+	acquired := make(chan func())
+	go func() {
+		rel, err := w.acquireEntriesThrottle(ctx, pds)
+		if err == nil {
+			acquired <- rel
+		}
+	}()
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt64(&w.entriesBacklog) == 2
+	}, time.Second, time.Millisecond)
+
+	release()
+	(<-acquired)()
+	require.EqualValues(t, 0, atomic.LoadInt64(&w.entriesBacklog))
+
+	// A deal that gives up waiting is removed from the backlog
+	release, err = w.acquireEntriesThrottle(ctx, pds)
+	require.NoError(t, err)
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = w.acquireEntriesThrottle(cctx, pds)
+	require.Error(t, err)
+	require.EqualValues(t, 1, atomic.LoadInt64(&w.entriesBacklog))
+	release()
+	require.EqualValues(t, 0, atomic.LoadInt64(&w.entriesBacklog))
+}
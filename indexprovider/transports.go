@@ -0,0 +1,140 @@
+package indexprovider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ipni/index-provider/engine/xproviders"
+	"github.com/ipni/index-provider/metadata"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// The file that records the fingerprint of the retrieval transports that
+// were last announced to the indexer
+var announcedTransportsFile = ".boost-announced-transports"
+
+// retrievalTransports returns an extended provider record for each of the
+// retrieval transports (other than graphsync) that are enabled in the config
+func (w *Wrapper) retrievalTransports(key crypto.PrivKey) ([]xproviders.Info, error) {
+	var eps []xproviders.Info
+
+	if !w.bitswapEnabled {
+		log.Info("bitswap is not enabled - announcing bitswap disabled to Indexer")
+	} else {
+		// if we're exposing bitswap publicly, we announce bitswap as an extended provider. If we're not
+		// we announce it as metadata on the main provider
+		meta := metadata.Default.New(metadata.Bitswap{})
+		mbytes, err := meta.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		if len(w.cfg.Dealmaking.BitswapPublicAddresses) > 0 {
+			if w.cfg.Dealmaking.BitswapPrivKeyFile == "" {
+				return nil, fmt.Errorf("missing required configuration key BitswapPrivKeyFile: " +
+					"boost is configured with BitswapPublicAddresses but the BitswapPrivKeyFile configuration key is empty")
+			}
+
+			// we need the private key for bitswaps peerID in order to announce publicly
+			keyFile, err := os.ReadFile(w.cfg.Dealmaking.BitswapPrivKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("opening BitswapPrivKeyFile %s: %w", w.cfg.Dealmaking.BitswapPrivKeyFile, err)
+			}
+			privKey, err := crypto.UnmarshalPrivateKey(keyFile)
+			if err != nil {
+				return nil, fmt.Errorf("unmarshalling BitswapPrivKeyFile %s: %w", w.cfg.Dealmaking.BitswapPrivKeyFile, err)
+			}
+			// setup an extended provider record, containing the booster-bitswap multi addr,
+			// peer ID, private key for signing, and metadata
+			ep := xproviders.Info{
+				ID:       w.cfg.Dealmaking.BitswapPeerID,
+				Addrs:    w.cfg.Dealmaking.BitswapPublicAddresses,
+				Priv:     privKey,
+				Metadata: mbytes,
+			}
+			log.Infof("bitswap is enabled and endpoint is public - "+
+				"announcing bitswap endpoint to indexer as extended provider: %s %s",
+				ep.ID, ep.Addrs)
+			eps = append(eps, ep)
+		} else {
+			log.Infof("bitswap is enabled with boostd as proxy - "+
+				"announcing boostd as endpoint for bitswap to indexer: %s %s",
+				w.h.ID(), w.h.Addrs())
+
+			eps = append(eps, xproviders.NewInfo(w.h.ID(), key, mbytes, w.h.Addrs()))
+		}
+	}
+
+	if w.cfg.Dealmaking.HTTPRetrievalMultiaddr == "" {
+		log.Info("http retrieval is not enabled - announcing http disabled to Indexer")
+	} else {
+		maddr, err := multiaddr.NewMultiaddr(w.cfg.Dealmaking.HTTPRetrievalMultiaddr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing HTTPRetrievalMultiaddr '%s': %w", w.cfg.Dealmaking.HTTPRetrievalMultiaddr, err)
+		}
+		meta := metadata.Default.New(metadata.HTTPV1())
+		mbytes, err := meta.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("http retrieval is enabled - announcing booster-http endpoint to indexer: %s %s", w.h.ID(), maddr)
+		eps = append(eps, xproviders.NewInfo(w.h.ID(), key, mbytes, []multiaddr.Multiaddr{maddr}))
+	}
+
+	return eps, nil
+}
+
+// transportsFingerprint returns a hash of the retrieval transports, so that
+// it's possible to tell when they change
+func transportsFingerprint(addrs []multiaddr.Multiaddr, eps []xproviders.Info) (string, error) {
+	type transport struct {
+		ID       string
+		Addrs    []string
+		Metadata []byte
+	}
+
+	providerAddrs := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		providerAddrs = append(providerAddrs, a.String())
+	}
+	sort.Strings(providerAddrs)
+
+	transports := make([]transport, 0, len(eps))
+	for _, ep := range eps {
+		epAddrs := append([]string{}, ep.Addrs...)
+		sort.Strings(epAddrs)
+		transports = append(transports, transport{ID: ep.ID, Addrs: epAddrs, Metadata: ep.Metadata})
+	}
+
+	bz, err := json.Marshal(struct {
+		Addrs      []string
+		Transports []transport
+	}{providerAddrs, transports})
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(bz)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// lastAnnouncedTransports returns the fingerprint of the retrieval
+// transports that were last announced, or the empty string if they have
+// never been announced
+func (w *Wrapper) lastAnnouncedTransports() string {
+	bz, err := os.ReadFile(filepath.Join(w.cfg.DAGStore.RootDir, announcedTransportsFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(bz))
+}
+
+func (w *Wrapper) saveAnnouncedTransports(fingerprint string) error {
+	path := filepath.Join(w.cfg.DAGStore.RootDir, announcedTransportsFile)
+	return os.WriteFile(path, []byte(fingerprint), 0644)
+}
@@ -0,0 +1,235 @@
+package indexprovider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/filecoin-project/boost/api"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/ipfs/go-cid"
+	carindex "github.com/ipld/go-car/v2/index"
+	finderhttpclient "github.com/ipni/storetheindex/api/v0/finder/client/http"
+	"github.com/ipni/storetheindex/api/v0/finder/model"
+	"github.com/multiformats/go-multihash"
+)
+
+// The defaults used by an ingestion check if the config values are not set
+const (
+	defaultVerifySampleSize = 5
+	defaultVerifyMaxDeals   = 100
+)
+
+// finder queries an indexer for the providers of a multihash
+type finder interface {
+	Find(ctx context.Context, m multihash.Multihash) (*model.FindResponse, error)
+}
+
+// pieceIndexes gets the index of a piece's multihashes
+type pieceIndexes interface {
+	GetIterableIndexForPiece(pieceCid cid.Cid) (carindex.IterableIndex, error)
+}
+
+// verifyIngestionPeriodically checks at the given interval that the network
+// indexer has ingested the advertisements for announced deals
+func (w *Wrapper) verifyIngestionPeriodically(ctx context.Context, interval time.Duration) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		res, err := w.VerifyIngestion(ctx, 0)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Errorw("failed to verify indexer ingestion", "err", err)
+			}
+			continue
+		}
+		for _, gap := range res.Gaps {
+			log.Warnw("indexer has not ingested announced deal", "indexer", res.Indexer, "dealId", gap.DealUuid,
+				"pieceCid", gap.PieceCid, "sampled", gap.Sampled, "missing", gap.Missing, "err", gap.Error)
+		}
+		log.Infow("verified indexer ingestion", "indexer", res.Indexer, "checked", res.Checked, "gaps", len(res.Gaps))
+	}
+}
+
+// VerifyIngestion samples multihashes from the pieces of announced deals,
+// and queries the indexer configured in Dealmaking.IndexerVerifyURL to
+// check that it returns this provider for each multihash.
+// At most maxDeals deals are checked, chosen at random (if maxDeals is zero
+// the configured maximum is used).
+func (w *Wrapper) VerifyIngestion(ctx context.Context, maxDeals int) (*api.IndexerVerifyResult, error) {
+	if !w.enabled {
+		return nil, fmt.Errorf("cannot verify ingestion: index provider is disabled")
+	}
+	if w.cfg.Dealmaking.IndexerVerifyURL == "" {
+		return nil, fmt.Errorf("cannot verify ingestion: no indexer configured (see Dealmaking.IndexerVerifyURL)")
+	}
+	if maxDeals <= 0 {
+		maxDeals = w.cfg.Dealmaking.IndexerVerifyMaxDeals
+	}
+	if maxDeals <= 0 {
+		maxDeals = defaultVerifyMaxDeals
+	}
+
+	fc, err := finderhttpclient.New(w.cfg.Dealmaking.IndexerVerifyURL)
+	if err != nil {
+		return nil, fmt.Errorf("creating indexer client for %s: %w", w.cfg.Dealmaking.IndexerVerifyURL, err)
+	}
+
+	deals, err := w.announcedDeals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(deals) > maxDeals {
+		rand.Shuffle(len(deals), func(i, j int) { deals[i], deals[j] = deals[j], deals[i] })
+		deals = deals[:maxDeals]
+	}
+
+	res := &api.IndexerVerifyResult{Indexer: w.cfg.Dealmaking.IndexerVerifyURL}
+	for _, d := range deals {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		gap := w.verifyDeal(ctx, fc, w.dagStore, d)
+		res.Checked++
+		if gap != nil {
+			res.Gaps = append(res.Gaps, *gap)
+		}
+	}
+	res.At = time.Now()
+
+	w.lastVerifyLk.Lock()
+	w.lastVerify = res
+	w.lastVerifyLk.Unlock()
+
+	return res, nil
+}
+
+// LastVerification returns the result of the last ingestion check, or nil if
+// there hasn't been one since boost started
+func (w *Wrapper) LastVerification() *api.IndexerVerifyResult {
+	w.lastVerifyLk.Lock()
+	defer w.lastVerifyLk.Unlock()
+	return w.lastVerify
+}
+
+// announcedDeals returns the active deals that are announced to the
+// network indexer
+func (w *Wrapper) announcedDeals(ctx context.Context) ([]*types.ProviderDealState, error) {
+	deals, err := w.dealsDB.ListActive(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing active deals: %w", err)
+	}
+	removed, err := w.removedDB.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing removed announcements: %w", err)
+	}
+
+	var announced []*types.ProviderDealState
+	for _, d := range deals {
+		if d.Checkpoint != dealcheckpoints.IndexedAndAnnounced || !d.AnnounceToIPNI {
+			continue
+		}
+		if _, ok := removed[d.DealUuid]; ok {
+			continue
+		}
+		announced = append(announced, d)
+	}
+	return announced, nil
+}
+
+// verifyDeal checks that the indexer returns this provider for a sample of
+// the multihashes in the deal's piece. It returns nil if there are no gaps.
+func (w *Wrapper) verifyDeal(ctx context.Context, fc finder, idxs pieceIndexes, d *types.ProviderDealState) *api.IndexerVerifyGap {
+	gap := &api.IndexerVerifyGap{
+		DealUuid: d.DealUuid,
+		PieceCid: d.ClientDealProposal.Proposal.PieceCID,
+	}
+
+	propCid, err := d.SignedProposalCid()
+	if err != nil {
+		gap.Error = fmt.Sprintf("getting proposal cid: %s", err)
+		return gap
+	}
+
+	sampleSize := w.cfg.Dealmaking.IndexerVerifySampleSize
+	if sampleSize <= 0 {
+		sampleSize = defaultVerifySampleSize
+	}
+	mhs, err := sampleMultihashes(idxs, gap.PieceCid, sampleSize)
+	if err != nil {
+		gap.Error = err.Error()
+		return gap
+	}
+
+	gap.Sampled = len(mhs)
+	for _, mh := range mhs {
+		found, err := w.indexerHasMultihash(ctx, fc, mh, propCid.Bytes())
+		if err != nil {
+			gap.Error = fmt.Sprintf("querying indexer for %s: %s", mh.B58String(), err)
+			return gap
+		}
+		if !found {
+			gap.Missing++
+		}
+	}
+
+	if gap.Missing == 0 {
+		return nil
+	}
+	return gap
+}
+
+// sampleMultihashes picks n multihashes at random from the piece's index
+func sampleMultihashes(idxs pieceIndexes, pieceCid cid.Cid, n int) ([]multihash.Multihash, error) {
+	ii, err := idxs.GetIterableIndexForPiece(pieceCid)
+	if err != nil {
+		return nil, fmt.Errorf("getting index for piece: %w", err)
+	}
+
+	// Reservoir sampling, so that the whole index doesn't need to be held
+	// in memory for large pieces
+	sample := make([]multihash.Multihash, 0, n)
+	var count int
+	err = ii.ForEach(func(mh multihash.Multihash, _ uint64) error {
+		count++
+		if len(sample) < n {
+			sample = append(sample, append(multihash.Multihash(nil), mh...))
+		} else if i := rand.Intn(count); i < n {
+			sample[i] = append(multihash.Multihash(nil), mh...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("iterating over index for piece: %w", err)
+	}
+	return sample, nil
+}
+
+// indexerHasMultihash returns true if the indexer returns a result for the
+// multihash from this provider, with the given context ID
+func (w *Wrapper) indexerHasMultihash(ctx context.Context, fc finder, mh multihash.Multihash, contextID []byte) (bool, error) {
+	resp, err := fc.Find(ctx, mh)
+	if err != nil {
+		return false, err
+	}
+	for _, mhr := range resp.MultihashResults {
+		for _, pr := range mhr.ProviderResults {
+			if pr.Provider.ID == w.h.ID() && bytes.Equal(pr.ContextID, contextID) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,155 @@
+package indexprovider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/node/config"
+	"github.com/ipfs/go-cid"
+	carindex "github.com/ipld/go-car/v2/index"
+	"github.com/ipni/storetheindex/api/v0/finder/model"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+type mockPieceIndexes struct {
+	idx carindex.IterableIndex
+	err error
+}
+
+func (m *mockPieceIndexes) GetIterableIndexForPiece(pieceCid cid.Cid) (carindex.IterableIndex, error) {
+	return m.idx, m.err
+}
+
+type mockFinder struct {
+	results map[string][]model.ProviderResult
+	err     error
+}
+
+func (m *mockFinder) Find(ctx context.Context, mh multihash.Multihash) (*model.FindResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	resp := &model.FindResponse{}
+	if prs, ok := m.results[mh.String()]; ok {
+		resp.MultihashResults = []model.MultihashResult{{Multihash: mh, ProviderResults: prs}}
+	}
+	return resp, nil
+}
+
+func TestVerifyDeal(t *testing.T) {
+	ctx := context.Background()
+
+	mn := mocknet.New()
+	defer mn.Close() //nolint:errcheck
+	h, err := mn.GenPeer()
+	require.NoError(t, err)
+	other, err := mn.GenPeer()
+	require.NoError(t, err)
+
+	deals, err := db.GenerateNDeals(1)
+	require.NoError(t, err)
+	deal := &deals[0]
+	propCid, err := deal.SignedProposalCid()
+	require.NoError(t, err)
+	contextID := propCid.Bytes()
+
+	// Create a piece index with three multihashes
+	idx, err := carindex.New(multicodec.CarMultihashIndexSorted)
+	require.NoError(t, err)
+	var records []carindex.Record
+	var mhs []multihash.Multihash
+	for i := 0; i < 3; i++ {
+		mh, err := multihash.Sum([]byte{byte(i)}, multihash.SHA2_256, -1)
+		require.NoError(t, err)
+		mhs = append(mhs, mh)
+		records = append(records, carindex.Record{Cid: cid.NewCidV1(cid.Raw, mh), Offset: uint64(i)})
+	}
+	require.NoError(t, idx.Load(records))
+	pieceIdxs := &mockPieceIndexes{idx: idx.(carindex.IterableIndex)}
+
+	// indexed returns indexer results for the multihashes with the given
+	// provider and context ID
+	indexed := func(p peer.ID, ctxID []byte, mhs ...multihash.Multihash) map[string][]model.ProviderResult {
+		results := make(map[string][]model.ProviderResult)
+		for _, mh := range mhs {
+			results[mh.String()] = []model.ProviderResult{{ContextID: ctxID, Provider: peer.AddrInfo{ID: p}}}
+		}
+		return results
+	}
+
+	tcs := []struct {
+		name     string
+		idxs     pieceIndexes
+		finder   *mockFinder
+		verified bool
+		missing  int
+		err      string
+	}{{
+		name:     "all multihashes indexed",
+		idxs:     pieceIdxs,
+		finder:   &mockFinder{results: indexed(h.ID(), contextID, mhs...)},
+		verified: true,
+	}, {
+		name:    "some multihashes not indexed",
+		idxs:    pieceIdxs,
+		finder:  &mockFinder{results: indexed(h.ID(), contextID, mhs[0])},
+		missing: 2,
+	}, {
+		name:    "no multihashes indexed",
+		idxs:    pieceIdxs,
+		finder:  &mockFinder{},
+		missing: 3,
+	}, {
+		name:    "indexed for another provider",
+		idxs:    pieceIdxs,
+		finder:  &mockFinder{results: indexed(other.ID(), contextID, mhs...)},
+		missing: 3,
+	}, {
+		name:    "indexed with another context ID",
+		idxs:    pieceIdxs,
+		finder:  &mockFinder{results: indexed(h.ID(), []byte("other"), mhs...)},
+		missing: 3,
+	}, {
+		name:   "indexer query fails",
+		idxs:   pieceIdxs,
+		finder: &mockFinder{err: errors.New("indexer unavailable")},
+		err:    "indexer unavailable",
+	}, {
+		name:   "piece index not found",
+		idxs:   &mockPieceIndexes{err: errors.New("shard not found")},
+		finder: &mockFinder{},
+		err:    "shard not found",
+	}}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Boost{}
+			cfg.Dealmaking.IndexerVerifySampleSize = 5
+			w := &Wrapper{cfg: cfg, h: h}
+
+			gap := w.verifyDeal(ctx, tc.finder, tc.idxs, deal)
+			if tc.verified {
+				require.Nil(t, gap)
+				return
+			}
+
+			require.NotNil(t, gap)
+			require.Equal(t, deal.DealUuid, gap.DealUuid)
+			require.Equal(t, deal.ClientDealProposal.Proposal.PieceCID, gap.PieceCid)
+			if tc.err != "" {
+				require.Contains(t, gap.Error, tc.err)
+				return
+			}
+			require.Empty(t, gap.Error)
+			require.Equal(t, len(mhs), gap.Sampled)
+			require.Equal(t, tc.missing, gap.Missing)
+		})
+	}
+}
@@ -2,23 +2,35 @@ package indexprovider
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"math"
 	"os"
 	"path/filepath"
-
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/lotus/api/v1api"
+	ltypes "github.com/filecoin-project/lotus/chain/types"
+	lotus_dtypes "github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/repo"
-	"github.com/libp2p/go-libp2p/core/crypto"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	host "github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"go.opencensus.io/stats"
 	"go.uber.org/fx"
+	"golang.org/x/time/rate"
 
 	"github.com/filecoin-project/boost/markets/idxprov"
 	dst "github.com/filecoin-project/dagstore"
 	"github.com/filecoin-project/lotus/markets/dagstore"
 
+	"github.com/filecoin-project/boost/api"
 	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/metrics"
 	"github.com/filecoin-project/boost/node/config"
 	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
 	lotus_storagemarket "github.com/filecoin-project/go-fil-markets/storagemarket"
@@ -37,10 +49,25 @@ var log = logging.Logger("index-provider-wrapper")
 var shardRegMarker = ".boost-shard-registration-complete"
 var defaultDagStoreDir = "dagstore"
 
+const (
+	// How often to check for failed announcements that are due to be retried
+	announceRetryInterval = 30 * time.Second
+	// The time to wait before the first retry of a failed announcement.
+	// The wait doubles with each failed attempt, up to announceMaxBackoff.
+	announceMinBackoff = time.Minute
+	announceMaxBackoff = time.Hour
+	// The maximum number of announcements to retry in one pass
+	announceRetryBatchSize = 100
+)
+
 type Wrapper struct {
 	cfg         *config.Boost
 	enabled     bool
 	dealsDB     *db.DealsDB
+	pendingDB   *db.PendingAnnouncementsDB
+	removedDB   *db.RemovedAnnouncementsDB
+	fullnodeApi v1api.FullNode
+	sa          retrievalmarket.SectorAccessor
 	legacyProv  lotus_storagemarket.StorageProvider
 	prov        provider.Interface
 	dagStore    *dagstore.Wrapper
@@ -49,15 +76,41 @@ type Wrapper struct {
 	// bitswapEnabled records whether to announce bitswap as an available
 	// protocol to the network indexer
 	bitswapEnabled bool
+	// direct sends announcements to indexers over HTTP (nil if there are
+	// no direct announce URLs configured)
+	direct *directAnnouncer
+	// limits the number of entries chains that are generated in parallel
+	// (nil if there is no limit)
+	entriesThrottle chan struct{}
+	// the number of deals whose entries chains are being generated or are
+	// waiting to be generated (accessed atomically)
+	entriesBacklog int64
+	// the gossipsub topic that advertisements are announced on
+	ps    *pubsub.PubSub
+	topic string
+	// the time of the last successful publish (persisted across restarts)
+	lastPublishLk sync.Mutex
+	lastPublish   time.Time
+	// the result of the last check that the indexer has ingested announced
+	// deals
+	lastVerifyLk sync.Mutex
+	lastVerify   *api.IndexerVerifyResult
+
+	// background tasks: retrying failed announcements and removing
+	// announcements for deals that have ended
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 }
 
 func NewWrapper(cfg *config.Boost) func(lc fx.Lifecycle, h host.Host, r repo.LockedRepo, dealsDB *db.DealsDB,
-	legacyProv lotus_storagemarket.StorageProvider, prov provider.Interface, dagStore *dagstore.Wrapper,
-	meshCreator idxprov.MeshCreator) (*Wrapper, error) {
+	pendingDB *db.PendingAnnouncementsDB, removedDB *db.RemovedAnnouncementsDB, fullnodeApi v1api.FullNode,
+	sa retrievalmarket.SectorAccessor, legacyProv lotus_storagemarket.StorageProvider, prov provider.Interface,
+	dagStore *dagstore.Wrapper, meshCreator idxprov.MeshCreator, ps *pubsub.PubSub, nn lotus_dtypes.NetworkName) (*Wrapper, error) {
 
 	return func(lc fx.Lifecycle, h host.Host, r repo.LockedRepo, dealsDB *db.DealsDB,
-		legacyProv lotus_storagemarket.StorageProvider, prov provider.Interface, dagStore *dagstore.Wrapper,
-		meshCreator idxprov.MeshCreator) (*Wrapper, error) {
+		pendingDB *db.PendingAnnouncementsDB, removedDB *db.RemovedAnnouncementsDB, fullnodeApi v1api.FullNode,
+		sa retrievalmarket.SectorAccessor, legacyProv lotus_storagemarket.StorageProvider, prov provider.Interface,
+		dagStore *dagstore.Wrapper, meshCreator idxprov.MeshCreator, ps *pubsub.PubSub, nn lotus_dtypes.NetworkName) (*Wrapper, error) {
 		if cfg.DAGStore.RootDir == "" {
 			cfg.DAGStore.RootDir = filepath.Join(r.Path(), defaultDagStoreDir)
 		}
@@ -71,6 +124,10 @@ func NewWrapper(cfg *config.Boost) func(lc fx.Lifecycle, h host.Host, r repo.Loc
 		w := &Wrapper{
 			h:              h,
 			dealsDB:        dealsDB,
+			pendingDB:      pendingDB,
+			removedDB:      removedDB,
+			fullnodeApi:    fullnodeApi,
+			sa:             sa,
 			legacyProv:     legacyProv,
 			prov:           prov,
 			dagStore:       dagStore,
@@ -78,7 +135,27 @@ func NewWrapper(cfg *config.Boost) func(lc fx.Lifecycle, h host.Host, r repo.Loc
 			cfg:            cfg,
 			bitswapEnabled: bitswapEnabled,
 			enabled:        !isDisabled,
+			ps:             ps,
+			topic:          TopicName(cfg.IndexProvider.TopicName, nn),
+		}
+
+		if len(cfg.Dealmaking.DirectAnnounceURLs) > 0 && !isDisabled {
+			pub, ok := prov.(httpPublisher)
+			if !ok {
+				return nil, fmt.Errorf("index provider does not support direct announcements over HTTP")
+			}
+			direct, err := newDirectAnnouncer(pub, cfg.Dealmaking.DirectAnnounceURLs)
+			if err != nil {
+				return nil, err
+			}
+			w.direct = direct
+		}
+
+		if cfg.Dealmaking.MaxConcurrentEntriesChains > 0 {
+			w.entriesThrottle = make(chan struct{}, cfg.Dealmaking.MaxConcurrentEntriesChains)
 		}
+		w.lastPublish = w.loadLastPublish()
+
 		// announce all deals on startup in case of a config change
 		lc.Append(fx.Hook{
 			OnStart: func(ctx context.Context) error {
@@ -90,6 +167,10 @@ func NewWrapper(cfg *config.Boost) func(lc fx.Lifecycle, h host.Host, r repo.Loc
 				}()
 				return nil
 			},
+			OnStop: func(ctx context.Context) error {
+				w.stop()
+				return nil
+			},
 		})
 		return w, nil
 	}
@@ -99,104 +180,60 @@ func (w *Wrapper) Enabled() bool {
 	return w.enabled
 }
 
-// AnnounceExtendedProviders announces changes to Boost configuration in the context of retrieval
-// methods.
+// AnnounceExtendedProviders announces the retrieval transports that Boost
+// supports to the network indexer, so that clients can route retrievals
+// from indexer results alone.
 //
-// The advertisement published by this function covers 3 cases:
+// The advertisement published by this function has an extended provider
+// record for each retrieval transport:
 //
-// 1. bitswap is completely disabled: in which case an advertisement is
-// published with empty extended providers that should wipe previous
-// support on indexer side.
+// 1. graphsync: boostd's peer ID and libp2p addresses. According to the IPNI
+// spec this record is required for signing reasons whenever there are other
+// records, and has empty metadata.
 //
-// 2. bitswap is enabled with public addresses: in which case publish an
-// advertisement with extended providers records corresponding to the
-// public addresses. Note, according the the IPNI spec, the host ID will
-// also be added to the extended providers for signing reasons with empty
-// metadata making a total of 2 extended provider records.
+// 2. bitswap: if bitswap is enabled with public addresses, booster-bitswap's
+// peer ID and public addresses. If bitswap is enabled without public
+// addresses, boostd's peer ID and addresses (boostd proxies bitswap
+// requests to booster-bitswap).
 //
-// 3. bitswap with boostd address: in which case public an advertisement
-// with one extended provider record that just adds bitswap metadata.
+// 3. http: if HTTPRetrievalMultiaddr is configured, boostd's peer ID and the
+// booster-http address.
 //
-// Note that in any case one advertisement is published by boost on startup
-// to reflect on bitswap configuration, even if the config remains the
-// same. Future work should detect config change and only publish ads when
-// config changes.
+// If bitswap and http are both disabled, the advertisement has no extended
+// providers, which wipes out any previously announced transports on the
+// indexer side.
+//
+// The advertisement is only published if the retrieval transports have
+// changed since the last time it was published (or if the advertisement
+// chain is empty).
 func (w *Wrapper) AnnounceExtendedProviders(ctx context.Context) error {
 	if !w.enabled {
 		return errors.New("cannot announce all deals: index provider is disabled")
 	}
-	// for now, only generate an indexer provider announcement if bitswap announcements
-	// are enabled -- all other graphsync announcements are context ID specific
 
-	// build the extended providers announcement
 	key := w.h.Peerstore().PrivKey(w.h.ID())
-	adBuilder := xproviders.NewAdBuilder(w.h.ID(), key, w.h.Addrs())
-
-	if !w.bitswapEnabled {
-		// If bitswap is completely disabled, publish an advertisement with empty extended providers
-		// which should override previously published extended providers associated to w.h.ID().
-		log.Info("bitswap is not enabled - announcing bitswap disabled to Indexer")
-	} else {
-		// if we're exposing bitswap publicly, we announce bitswap as an extended provider. If we're not
-		// we announce it as metadata on the main provider
-
-		// marshal bitswap metadata
-		meta := metadata.Default.New(metadata.Bitswap{})
-		mbytes, err := meta.MarshalBinary()
-		if err != nil {
-			return err
-		}
-		var ep xproviders.Info
-		if len(w.cfg.Dealmaking.BitswapPublicAddresses) > 0 {
-			if w.cfg.Dealmaking.BitswapPrivKeyFile == "" {
-				return fmt.Errorf("missing required configuration key BitswapPrivKeyFile: " +
-					"boost is configured with BitswapPublicAddresses but the BitswapPrivKeyFile configuration key is empty")
-			}
-
-			// we need the private key for bitswaps peerID in order to announce publicly
-			keyFile, err := os.ReadFile(w.cfg.Dealmaking.BitswapPrivKeyFile)
-			if err != nil {
-				return fmt.Errorf("opening BitswapPrivKeyFile %s: %w", w.cfg.Dealmaking.BitswapPrivKeyFile, err)
-			}
-			privKey, err := crypto.UnmarshalPrivateKey(keyFile)
-			if err != nil {
-				return fmt.Errorf("unmarshalling BitswapPrivKeyFile %s: %w", w.cfg.Dealmaking.BitswapPrivKeyFile, err)
-			}
-			// setup an extended provider record, containing the booster-bitswap multi addr,
-			// peer ID, private key for signing, and metadata
-			ep = xproviders.Info{
-				ID:       w.cfg.Dealmaking.BitswapPeerID,
-				Addrs:    w.cfg.Dealmaking.BitswapPublicAddresses,
-				Priv:     privKey,
-				Metadata: mbytes,
-			}
-			log.Infof("bitswap is enabled and endpoint is public - "+
-				"announcing bitswap endpoint to indexer as extended provider: %s %s",
-				ep.ID, ep.Addrs)
-		} else {
-			log.Infof("bitswap is enabled with boostd as proxy - "+
-				"announcing boostd as endpoint for bitswap to indexer: %s %s",
-				w.h.ID(), w.h.Addrs())
-
-			addrs := make([]string, 0, len(w.h.Addrs()))
-			for _, addr := range w.h.Addrs() {
-				addrs = append(addrs, addr.String())
-			}
+	eps, err := w.retrievalTransports(key)
+	if err != nil {
+		return err
+	}
 
-			ep = xproviders.Info{
-				ID:       w.h.ID().String(),
-				Addrs:    addrs,
-				Priv:     key,
-				Metadata: mbytes,
-			}
-		}
-		adBuilder.WithExtendedProviders(ep)
+	fingerprint, err := transportsFingerprint(w.h.Addrs(), eps)
+	if err != nil {
+		return fmt.Errorf("getting retrieval transports fingerprint: %w", err)
 	}
 
 	last, _, err := w.prov.GetLatestAdv(ctx)
 	if err != nil {
 		return err
 	}
+	if last != cid.Undef && w.lastAnnouncedTransports() == fingerprint {
+		log.Info("retrieval transports have not changed since they were last announced to the indexer")
+		return nil
+	}
+
+	// build the extended providers announcement
+	adBuilder := xproviders.NewAdBuilder(w.h.ID(), key, w.h.Addrs())
+	adBuilder.WithExtendedProviders(eps...)
 	adBuilder.WithLastAdID(last)
 	ad, err := adBuilder.BuildAndSign()
 	if err != nil {
@@ -217,31 +254,69 @@ func (w *Wrapper) AnnounceExtendedProviders(ctx context.Context) error {
 	}
 
 	log.Infof("announced endpoint to indexer with advertisement cid %s", adCid)
+	w.published()
 
+	if err := w.saveAnnouncedTransports(fingerprint); err != nil {
+		log.Warnw("failed to save announced retrieval transports", "err", err)
+	}
 	return nil
 }
 
 func (w *Wrapper) IndexerAnnounceAllDeals(ctx context.Context) error {
+	_, err := w.announceDeals(ctx, api.IndexerAnnounceParams{})
+	return err
+}
+
+// IndexerAnnounceDeals announces the active deals that match the params to
+// the network indexer, at up to params.Rate announcements per second.
+// Deals for which the announcement fails are queued to be retried.
+// Legacy deals are only announced if the params match all deals.
+func (w *Wrapper) IndexerAnnounceDeals(ctx context.Context, params api.IndexerAnnounceParams) (*api.IndexerAnnounceResult, error) {
+	res, err := w.announceDeals(ctx, params)
+	if res == nil {
+		return nil, err
+	}
+	if err != nil {
+		log.Warnw("failed to announce some deals to Indexer", "err", err)
+	}
+	return res, nil
+}
+
+func (w *Wrapper) announceDeals(ctx context.Context, params api.IndexerAnnounceParams) (*api.IndexerAnnounceResult, error) {
 	if !w.enabled {
-		return errors.New("cannot announce all deals: index provider is disabled")
+		return nil, errors.New("cannot announce all deals: index provider is disabled")
 	}
 
-	log.Info("announcing all legacy deals to Indexer")
-	err := w.legacyProv.AnnounceAllDealsToIndexer(ctx)
-	if err == nil {
-		log.Infof("finished announcing all legacy deals to Indexer")
+	if params.IsEmpty() {
+		log.Info("announcing all legacy deals to Indexer")
+		err := w.legacyProv.AnnounceAllDealsToIndexer(ctx)
+		if err == nil {
+			log.Infof("finished announcing all legacy deals to Indexer")
+			w.published()
+		} else {
+			log.Warnw("failed to announce legacy deals to Indexer", "err", err)
+		}
 	} else {
-		log.Warnw("failed to announce legacy deals to Indexer", "err", err)
+		log.Info("not announcing legacy deals to Indexer: legacy deals are only announced when announcing all deals")
 	}
 
-	log.Info("announcing all Boost deals to Indexer")
+	log.Infow("announcing Boost deals to Indexer", "params", params)
 	deals, err := w.dealsDB.ListActive(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to list deals: %w", err)
+		return nil, fmt.Errorf("failed to list deals: %w", err)
+	}
+	removed, err := w.removedDB.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list removed announcements: %w", err)
 	}
 
+	var limiter *rate.Limiter
+	if params.Rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(params.Rate), 1)
+	}
+
+	res := &api.IndexerAnnounceResult{}
 	shards := make(map[string]struct{})
-	var nSuccess int
 	var merr error
 
 	for _, d := range deals {
@@ -253,21 +328,86 @@ func (w *Wrapper) IndexerAnnounceAllDeals(ctx context.Context) error {
 		if d.Checkpoint < dealcheckpoints.IndexedAndAnnounced || d.Checkpoint >= dealcheckpoints.Complete {
 			continue
 		}
+		if !matchesAnnounceParams(d, params) {
+			continue
+		}
+		// don't announce deals that the announce policy skipped
+		if !d.AnnounceToIPNI {
+			continue
+		}
+		// don't re-announce deals that the indexer was told to remove
+		if _, ok := removed[d.DealUuid]; ok {
+			continue
+		}
+		// deals that the announce policy delayed until sealing are announced
+		// when the sector is sealed, so skip them until then
+		if d.AnnounceAfterSealing && !w.dealActivated(ctx, d) {
+			res.NotSealed++
+			continue
+		}
+
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				return res, multierror.Append(merr, err)
+			}
+		}
 
 		if _, err := w.AnnounceBoostDeal(ctx, d); err != nil {
 			// don't log already advertised errors as errors - just skip them
-			if !errors.Is(err, provider.ErrAlreadyAdvertised) {
-				merr = multierror.Append(merr, err)
-				log.Errorw("failed to announce boost deal to Indexer", "dealId", d.DealUuid, "err", err)
+			if errors.Is(err, provider.ErrAlreadyAdvertised) {
+				res.AlreadyAnnounced++
+				continue
+			}
+
+			res.Failed++
+			merr = multierror.Append(merr, err)
+			log.Errorw("failed to announce boost deal to Indexer", "dealId", d.DealUuid, "err", err)
+			if qerr := w.QueueAnnounceBoostDeal(ctx, d, err); qerr != nil {
+				log.Errorw("failed to queue boost deal announcement for retry", "dealId", d.DealUuid, "err", qerr)
 			}
 			continue
 		}
 		shards[d.ClientDealProposal.Proposal.PieceCID.String()] = struct{}{}
-		nSuccess++
+		res.Announced++
 	}
 
-	log.Infow("finished announcing all boost deals to Indexer", "number of deals", nSuccess, "number of shards", len(shards))
-	return merr
+	log.Infow("finished announcing boost deals to Indexer", "number of deals", res.Announced,
+		"already announced", res.AlreadyAnnounced, "not sealed", res.NotSealed, "failed", res.Failed, "number of shards", len(shards))
+	return res, merr
+}
+
+func matchesAnnounceParams(d *types.ProviderDealState, params api.IndexerAnnounceParams) bool {
+	prop := d.ClientDealProposal.Proposal
+	if params.ClientAddress != "" && prop.Client.String() != params.ClientAddress {
+		return false
+	}
+	if params.PieceCid != "" && prop.PieceCID.String() != params.PieceCid {
+		return false
+	}
+	if params.IsVerified != nil && prop.VerifiedDeal != *params.IsVerified {
+		return false
+	}
+	if !params.CreatedAfter.IsZero() && d.CreatedAt.Before(params.CreatedAfter) {
+		return false
+	}
+	if !params.CreatedBefore.IsZero() && !d.CreatedAt.Before(params.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// dealActivated returns true if the deal has been activated on chain, ie its
+// sector has been sealed
+func (w *Wrapper) dealActivated(ctx context.Context, d *types.ProviderDealState) bool {
+	if d.ChainDealID == 0 {
+		return false
+	}
+	md, err := w.fullnodeApi.StateMarketStorageDeal(ctx, d.ChainDealID, ltypes.EmptyTSK)
+	if err != nil {
+		log.Debugw("failed to get market deal state", "dealId", d.DealUuid, "chainDealId", d.ChainDealID, "err", err)
+		return false
+	}
+	return md.State.SectorStartEpoch > 0
 }
 
 func (w *Wrapper) Start(ctx context.Context) {
@@ -276,6 +416,29 @@ func (w *Wrapper) Start(ctx context.Context) {
 		log.Errorw("failed to migrate dagstore indices for Boost deals", "err", err)
 	}
 
+	// in the background, retry announcements that failed, remove
+	// announcements for deals that have ended, announce directly to
+	// indexers over HTTP and check that indexers have ingested announced
+	// deals
+	if w.enabled {
+		var bgCtx context.Context
+		bgCtx, w.cancel = context.WithCancel(context.Background())
+		w.wg.Add(1)
+		go w.retryPendingAnnouncements(bgCtx)
+		if interval := time.Duration(w.cfg.Dealmaking.AdvertisementRemovalCheckInterval); interval > 0 {
+			w.wg.Add(1)
+			go w.removeEndedAnnouncements(bgCtx, interval)
+		}
+		if w.direct != nil {
+			w.wg.Add(1)
+			go w.direct.run(bgCtx, &w.wg)
+		}
+		if interval := time.Duration(w.cfg.Dealmaking.IndexerVerifyInterval); interval > 0 && w.cfg.Dealmaking.IndexerVerifyURL != "" {
+			w.wg.Add(1)
+			go w.verifyIngestionPeriodically(bgCtx, interval)
+		}
+	}
+
 	w.prov.RegisterMultihashLister(func(ctx context.Context, pid peer.ID, contextID []byte) (provider.MultihashIterator, error) {
 		provideF := func(pieceCid cid.Cid) (provider.MultihashIterator, error) {
 			ii, err := w.dagStore.GetIterableIndexForPiece(pieceCid)
@@ -341,13 +504,170 @@ func (w *Wrapper) AnnounceBoostDeal(ctx context.Context, pds *types.ProviderDeal
 		return cid.Undef, fmt.Errorf("failed to get proposal cid from deal: %w", err)
 	}
 
+	// NotifyPut generates the entries chain for the piece before it returns
+	release, err := w.acquireEntriesThrottle(ctx, pds)
+	if err != nil {
+		return cid.Undef, err
+	}
 	annCid, err := w.prov.NotifyPut(ctx, nil, propCid.Bytes(), fm)
+	release()
 	if err != nil {
 		return cid.Undef, fmt.Errorf("failed to announce deal to index provider: %w", err)
 	}
+	w.published()
 	return annCid, err
 }
 
+// acquireEntriesThrottle waits until there are fewer than the maximum number
+// of entries chains being generated. The returned function must be called
+// when the entries chain has been generated.
+func (w *Wrapper) acquireEntriesThrottle(ctx context.Context, pds *types.ProviderDealState) (func(), error) {
+	atomic.AddInt64(&w.entriesBacklog, 1)
+	if w.entriesThrottle == nil {
+		return func() { atomic.AddInt64(&w.entriesBacklog, -1) }, nil
+	}
+
+	select {
+	case w.entriesThrottle <- struct{}{}:
+	default:
+		log.Debugw("waiting for entries chain generation slot", "dealId", pds.DealUuid,
+			"max-concurrent", cap(w.entriesThrottle))
+		select {
+		case w.entriesThrottle <- struct{}{}:
+		case <-ctx.Done():
+			atomic.AddInt64(&w.entriesBacklog, -1)
+			return nil, fmt.Errorf("waiting to generate entries chain: %w", ctx.Err())
+		}
+	}
+	return func() {
+		<-w.entriesThrottle
+		atomic.AddInt64(&w.entriesBacklog, -1)
+	}, nil
+}
+
+// published is called after an advertisement is published, to record the
+// time of the publish and announce it directly to indexers over HTTP
+func (w *Wrapper) published() {
+	w.setLastPublish(time.Now())
+	if w.direct != nil {
+		w.direct.notify()
+	}
+}
+
+// DirectAnnounceStatus returns the status of direct HTTP announcements to
+// each of the configured indexers
+func (w *Wrapper) DirectAnnounceStatus() []api.IndexerDirectAnnounceStatus {
+	if w.direct == nil {
+		return []api.IndexerDirectAnnounceStatus{}
+	}
+	return w.direct.Status()
+}
+
+// QueueAnnounceBoostDeal persists an announcement that failed with the
+// given error, so that it will be retried with backoff until the indexer
+// accepts it (including after a restart)
+func (w *Wrapper) QueueAnnounceBoostDeal(ctx context.Context, pds *types.ProviderDealState, announceErr error) error {
+	err := w.pendingDB.Add(ctx, pds.DealUuid, announceErr.Error(), time.Now().Add(announceBackoff(1)))
+	if err != nil {
+		return fmt.Errorf("queueing announcement for deal %s: %w", pds.DealUuid, err)
+	}
+	return nil
+}
+
+// PendingAnnouncements returns the announcements that failed and are
+// waiting to be retried
+func (w *Wrapper) PendingAnnouncements(ctx context.Context) ([]db.PendingAnnouncement, error) {
+	return w.pendingDB.List(ctx)
+}
+
+func (w *Wrapper) stop() {
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	w.wg.Wait()
+}
+
+func (w *Wrapper) retryPendingAnnouncements(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(announceRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if count, err := w.pendingDB.Count(ctx); err == nil {
+			stats.Record(ctx, metrics.IndexerPendingAnnouncements.M(int64(count)))
+		}
+
+		due, err := w.pendingDB.Due(ctx, time.Now(), announceRetryBatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Errorw("failed to get pending announcements", "err", err)
+			}
+			continue
+		}
+
+		for _, ann := range due {
+			if ctx.Err() != nil {
+				return
+			}
+			w.retryAnnouncement(ctx, ann)
+		}
+	}
+}
+
+func (w *Wrapper) retryAnnouncement(ctx context.Context, ann db.PendingAnnouncement) {
+	deal, err := w.dealsDB.ByID(ctx, ann.DealUUID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Errorw("failed to get deal for pending announcement", "dealId", ann.DealUUID, "err", err)
+			return
+		}
+		// The deal no longer exists, so there's nothing to announce
+		log.Warnw("removing pending announcement for unknown deal", "dealId", ann.DealUUID)
+		err = nil
+	} else {
+		_, err = w.AnnounceBoostDeal(ctx, deal)
+		if errors.Is(err, provider.ErrAlreadyAdvertised) {
+			err = nil
+		}
+	}
+
+	if err == nil {
+		log.Infow("announced deal to Indexer after retry", "dealId", ann.DealUUID, "attempts", ann.Attempts+1)
+		if err := w.pendingDB.Delete(ctx, ann.DealUUID); err != nil {
+			log.Errorw("failed to remove pending announcement", "dealId", ann.DealUUID, "err", err)
+		}
+		return
+	}
+
+	attempts := ann.Attempts + 1
+	next := time.Now().Add(announceBackoff(attempts))
+	log.Warnw("failed to announce deal to Indexer", "dealId", ann.DealUUID, "attempts", attempts, "next-attempt", next, "err", err)
+	if err := w.pendingDB.RecordFailure(ctx, ann.DealUUID, err.Error(), next); err != nil {
+		log.Errorw("failed to record failed announcement", "dealId", ann.DealUUID, "err", err)
+	}
+}
+
+// announceBackoff returns the time to wait before retrying an announcement
+// that has failed the given number of times
+func announceBackoff(attempts int) time.Duration {
+	backoff := announceMinBackoff
+	for i := 1; i < attempts && backoff < announceMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > announceMaxBackoff {
+		backoff = announceMaxBackoff
+	}
+	return backoff
+}
+
 func (w *Wrapper) DagstoreReinitBoostDeals(ctx context.Context) (bool, error) {
 	deals, err := w.dealsDB.ListActive(ctx)
 	if err != nil {
@@ -0,0 +1,270 @@
+package legacyingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/dagstore"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("legacyingest")
+
+type Config struct {
+	// How often to check for legacy deals that haven't been ingested
+	CheckInterval time.Duration
+	// The maximum number of pieces to index each time
+	MaxIndexesPerCheck int
+}
+
+// legacyDeals lists the deals made with the legacy (go-fil-markets) provider
+type legacyDeals interface {
+	ListLocalDeals() ([]storagemarket.MinerDeal, error)
+}
+
+// sectorStatus gets the pieces in a sector
+type sectorStatus interface {
+	SectorsStatus(ctx context.Context, sid abi.SectorNumber, showOnChainInfo bool) (lapi.SectorInfo, error)
+}
+
+// shardRegistrar registers the dagstore shard for a piece
+type shardRegistrar interface {
+	RegisterShard(ctx context.Context, pieceCid cid.Cid, carPath string, eagerInit bool, resch chan dagstore.ShardResult) error
+}
+
+// The states of legacy deals whose data has been handed off to the sealer
+var handedOffStates = map[storagemarket.StorageDealStatus]struct{}{
+	storagemarket.StorageDealAwaitingPreCommit: {},
+	storagemarket.StorageDealSealing:           {},
+	storagemarket.StorageDealFinalizing:        {},
+	storagemarket.StorageDealActive:            {},
+}
+
+// Ingester periodically looks for legacy deals that are missing from the
+// piece store or that don't have an index in the dagstore (eg because the
+// deals were migrated from a lotus-miner without the dagstore) and adds
+// them, so that the data in legacy deals can be retrieved in the same way
+// as the data in boost deals (eg with booster-http and booster-bitswap).
+type Ingester struct {
+	cfg    Config
+	legacy legacyDeals
+	sps    sectorStatus
+	ps     piecestore.PieceStore
+	dagst  dagstore.Interface
+	reg    shardRegistrar
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewIngester(cfg Config, legacy legacyDeals, sps sectorStatus, ps piecestore.PieceStore, dagst dagstore.Interface, reg shardRegistrar) *Ingester {
+	if cfg.MaxIndexesPerCheck <= 0 {
+		cfg.MaxIndexesPerCheck = 1
+	}
+	return &Ingester{cfg: cfg, legacy: legacy, sps: sps, ps: ps, dagst: dagst, reg: reg}
+}
+
+func (i *Ingester) Start(ctx context.Context) {
+	if i.cfg.CheckInterval <= 0 {
+		log.Info("legacy deal ingestion is disabled")
+		return
+	}
+
+	ctx, i.cancel = context.WithCancel(ctx)
+	i.wg.Add(1)
+	go i.run(ctx)
+}
+
+func (i *Ingester) Stop() {
+	if i.cancel != nil {
+		i.cancel()
+	}
+	i.wg.Wait()
+}
+
+func (i *Ingester) run(ctx context.Context) {
+	defer i.wg.Done()
+
+	// Check once at startup, and then every check interval
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		res, err := i.ingest(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Errorw("failed to ingest legacy deals", "err", err)
+		} else if res.Added > 0 || res.Indexed > 0 || res.Failed > 0 {
+			log.Infow("ingested legacy deals", "added to piece store", res.Added, "indexed", res.Indexed,
+				"failed", res.Failed, "remaining", res.Remaining)
+		}
+		timer.Reset(i.cfg.CheckInterval)
+	}
+}
+
+type ingestResult struct {
+	// The number of deals that were added to the piece store
+	Added int
+	// The number of pieces that were indexed
+	Indexed int
+	// The number of deals that could not be ingested
+	Failed int
+	// The number of pieces that still need to be indexed
+	Remaining int
+}
+
+// ingest adds each handed off legacy deal that's missing from the piece
+// store, and tries to index up to MaxIndexesPerCheck pieces that don't
+// have an index
+func (i *Ingester) ingest(ctx context.Context) (ingestResult, error) {
+	var res ingestResult
+
+	deals, err := i.legacy.ListLocalDeals()
+	if err != nil {
+		return res, fmt.Errorf("listing legacy deals: %w", err)
+	}
+
+	sectors := make(map[abi.SectorNumber]lapi.SectorInfo)
+	indexed := make(map[cid.Cid]struct{})
+	attempts := 0
+	for _, deal := range deals {
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+		if _, ok := handedOffStates[deal.State]; !ok || deal.DealID == 0 {
+			continue
+		}
+
+		pieceCid := deal.Proposal.PieceCID
+		added, err := i.addToPieceStore(ctx, deal, sectors)
+		if err != nil {
+			res.Failed++
+			log.Warnw("failed to add legacy deal to piece store", "propCid", deal.ProposalCid, "dealID", deal.DealID,
+				"pieceCid", pieceCid, "err", err)
+			continue
+		}
+		if added {
+			res.Added++
+		}
+
+		if _, ok := indexed[pieceCid]; ok {
+			continue
+		}
+		indexed[pieceCid] = struct{}{}
+
+		_, err = i.dagst.GetShardInfo(shard.KeyFromCID(pieceCid))
+		if err == nil {
+			// The piece already has an index
+			continue
+		}
+		if !errors.Is(err, dagstore.ErrShardUnknown) {
+			return res, fmt.Errorf("getting shard info for piece %s: %w", pieceCid, err)
+		}
+
+		if attempts >= i.cfg.MaxIndexesPerCheck {
+			res.Remaining++
+			continue
+		}
+		attempts++
+		if err := i.index(ctx, pieceCid); err != nil {
+			if ctx.Err() != nil {
+				return res, ctx.Err()
+			}
+			res.Failed++
+			log.Warnw("failed to index legacy deal piece", "propCid", deal.ProposalCid, "pieceCid", pieceCid, "err", err)
+			continue
+		}
+		res.Indexed++
+	}
+
+	return res, nil
+}
+
+// addToPieceStore adds the deal to the piece store if it's not already
+// there, and returns true if it was added
+func (i *Ingester) addToPieceStore(ctx context.Context, deal storagemarket.MinerDeal, sectors map[abi.SectorNumber]lapi.SectorInfo) (bool, error) {
+	pieceCid := deal.Proposal.PieceCID
+	pi, err := i.ps.GetPieceInfo(pieceCid)
+	if err != nil && !errors.Is(err, retrievalmarket.ErrNotFound) {
+		return false, fmt.Errorf("getting piece info: %w", err)
+	}
+	for _, di := range pi.Deals {
+		if di.DealID == deal.DealID {
+			return false, nil
+		}
+	}
+
+	// Get the offset of the piece in the sector from the pieces in the sector
+	si, ok := sectors[deal.SectorNumber]
+	if !ok {
+		si, err = i.sps.SectorsStatus(ctx, deal.SectorNumber, false)
+		if err != nil {
+			return false, fmt.Errorf("getting status of sector %d: %w", deal.SectorNumber, err)
+		}
+		sectors[deal.SectorNumber] = si
+	}
+	offset, err := pieceOffset(si, deal.DealID)
+	if err != nil {
+		return false, err
+	}
+
+	err = i.ps.AddDealForPiece(pieceCid, deal.ProposalCid, piecestore.DealInfo{
+		DealID:   deal.DealID,
+		SectorID: deal.SectorNumber,
+		Offset:   offset,
+		Length:   deal.Proposal.PieceSize,
+	})
+	if err != nil {
+		return false, fmt.Errorf("adding deal to piece store: %w", err)
+	}
+	return true, nil
+}
+
+// pieceOffset returns the offset of the deal's piece in the sector
+func pieceOffset(si lapi.SectorInfo, dealID abi.DealID) (abi.PaddedPieceSize, error) {
+	var offset abi.PaddedPieceSize
+	for _, p := range si.Pieces {
+		if p.DealInfo != nil && p.DealInfo.DealID == dealID {
+			return offset, nil
+		}
+		offset += p.Piece.Size
+	}
+	return 0, fmt.Errorf("deal %d not found in sector %d", dealID, si.SectorID)
+}
+
+// index registers the piece with the dagstore, and waits for the piece to
+// be indexed
+func (i *Ingester) index(ctx context.Context, pieceCid cid.Cid) error {
+	resch := make(chan dagstore.ShardResult, 1)
+	if err := i.reg.RegisterShard(ctx, pieceCid, "", true, resch); err != nil {
+		if errors.Is(err, dagstore.ErrShardExists) {
+			return nil
+		}
+		return fmt.Errorf("registering shard: %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case res := <-resch:
+		if res.Error != nil {
+			return fmt.Errorf("indexing shard: %w", res.Error)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package legacyingest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/filecoin-project/dagstore"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+const testPieceCid = "baga6ea4seaqnfhocd544oidrgsss2ahoaomvxuaqxfmlsizljtzsuivjl5hamka"
+
+type mockLegacyDeals struct {
+	deals []storagemarket.MinerDeal
+}
+
+func (m *mockLegacyDeals) ListLocalDeals() ([]storagemarket.MinerDeal, error) {
+	return m.deals, nil
+}
+
+type mockSectorStatus struct {
+	sectors map[abi.SectorNumber]lapi.SectorInfo
+}
+
+func (m *mockSectorStatus) SectorsStatus(ctx context.Context, sid abi.SectorNumber, showOnChainInfo bool) (lapi.SectorInfo, error) {
+	si, ok := m.sectors[sid]
+	if !ok {
+		return lapi.SectorInfo{}, errors.New("sector not found")
+	}
+	return si, nil
+}
+
+type mockPieceStore struct {
+	piecestore.PieceStore
+	pieces map[cid.Cid]piecestore.PieceInfo
+}
+
+func (m *mockPieceStore) GetPieceInfo(pieceCid cid.Cid) (piecestore.PieceInfo, error) {
+	pi, ok := m.pieces[pieceCid]
+	if !ok {
+		return piecestore.PieceInfo{}, retrievalmarket.ErrNotFound
+	}
+	return pi, nil
+}
+
+func (m *mockPieceStore) AddDealForPiece(pieceCid cid.Cid, _ cid.Cid, di piecestore.DealInfo) error {
+	pi := m.pieces[pieceCid]
+	pi.PieceCID = pieceCid
+	pi.Deals = append(pi.Deals, di)
+	m.pieces[pieceCid] = pi
+	return nil
+}
+
+type mockDagstore struct {
+	dagstore.Interface
+	shards map[shard.Key]dagstore.ShardInfo
+}
+
+func (m *mockDagstore) GetShardInfo(k shard.Key) (dagstore.ShardInfo, error) {
+	si, ok := m.shards[k]
+	if !ok {
+		return dagstore.ShardInfo{}, dagstore.ErrShardUnknown
+	}
+	return si, nil
+}
+
+type mockRegistrar struct {
+	dagst *mockDagstore
+	err   error
+}
+
+func (m *mockRegistrar) RegisterShard(ctx context.Context, pieceCid cid.Cid, carPath string, eagerInit bool, resch chan dagstore.ShardResult) error {
+	key := shard.KeyFromCID(pieceCid)
+	m.dagst.shards[key] = dagstore.ShardInfo{ShardState: dagstore.ShardStateAvailable}
+	resch <- dagstore.ShardResult{Key: key, Error: m.err}
+	return nil
+}
+
+func TestIngest(t *testing.T) {
+	ctx := context.Background()
+	pieceCid, err := cid.Parse(testPieceCid)
+	require.NoError(t, err)
+
+	deal := storagemarket.MinerDeal{State: storagemarket.StorageDealActive, DealID: 10, SectorNumber: 1}
+	deal.Proposal.PieceCID = pieceCid
+	deal.Proposal.PieceSize = 1024
+	legacy := &mockLegacyDeals{deals: []storagemarket.MinerDeal{
+		deal,
+		// The deal hasn't been handed off to the sealer yet
+		{State: storagemarket.StorageDealTransferring, DealID: 11},
+	}}
+
+	// The deal's piece is after a filler piece in the sector
+	sps := &mockSectorStatus{sectors: map[abi.SectorNumber]lapi.SectorInfo{
+		1: {SectorID: 1, Pieces: []lapi.SectorPiece{
+			{Piece: abi.PieceInfo{Size: 512}},
+			{Piece: abi.PieceInfo{Size: 1024, PieceCID: pieceCid}, DealInfo: &lapi.PieceDealInfo{DealID: 10}},
+		}},
+	}}
+	ps := &mockPieceStore{pieces: make(map[cid.Cid]piecestore.PieceInfo)}
+	dagst := &mockDagstore{shards: make(map[shard.Key]dagstore.ShardInfo)}
+
+	i := NewIngester(Config{MaxIndexesPerCheck: 1}, legacy, sps, ps, dagst, &mockRegistrar{dagst: dagst})
+
+	res, err := i.ingest(ctx)
+	require.NoError(t, err)
+	require.Equal(t, ingestResult{Added: 1, Indexed: 1}, res)
+	require.Equal(t, []piecestore.DealInfo{{DealID: 10, SectorID: 1, Offset: 512, Length: 1024}}, ps.pieces[pieceCid].Deals)
+	_, ok := dagst.shards[shard.KeyFromCID(pieceCid)]
+	require.True(t, ok)
+
+	// The deal has already been ingested
+	res, err = i.ingest(ctx)
+	require.NoError(t, err)
+	require.Equal(t, ingestResult{}, res)
+	require.Len(t, ps.pieces[pieceCid].Deals, 1)
+}
+
+func TestIngestIndexLimit(t *testing.T) {
+	ctx := context.Background()
+	pieceCid, err := cid.Parse(testPieceCid)
+	require.NoError(t, err)
+	otherPieceCid, err := cid.Parse("baga6ea4seaqlkg6mss5qs56jqtajg5ycrhpkj2b66cgdkukf2qjmmzz6ayksuci")
+	require.NoError(t, err)
+
+	var deals []storagemarket.MinerDeal
+	for n, pc := range []cid.Cid{pieceCid, otherPieceCid} {
+		deal := storagemarket.MinerDeal{State: storagemarket.StorageDealActive, DealID: abi.DealID(n + 1), SectorNumber: 1}
+		deal.Proposal.PieceCID = pc
+		deals = append(deals, deal)
+	}
+	sps := &mockSectorStatus{sectors: map[abi.SectorNumber]lapi.SectorInfo{
+		1: {SectorID: 1, Pieces: []lapi.SectorPiece{
+			{Piece: abi.PieceInfo{Size: 512}, DealInfo: &lapi.PieceDealInfo{DealID: 1}},
+			{Piece: abi.PieceInfo{Size: 512}, DealInfo: &lapi.PieceDealInfo{DealID: 2}},
+		}},
+	}}
+	ps := &mockPieceStore{pieces: make(map[cid.Cid]piecestore.PieceInfo)}
+	dagst := &mockDagstore{shards: make(map[shard.Key]dagstore.ShardInfo)}
+	reg := &mockRegistrar{dagst: dagst, err: errors.New("no unsealed copy")}
+
+	// Only one piece is indexed each time
+	i := NewIngester(Config{MaxIndexesPerCheck: 1}, &mockLegacyDeals{deals: deals}, sps, ps, dagst, reg)
+	res, err := i.ingest(ctx)
+	require.NoError(t, err)
+	require.Equal(t, ingestResult{Added: 2, Failed: 1, Remaining: 1}, res)
+	require.Equal(t, abi.PaddedPieceSize(512), ps.pieces[otherPieceCid].Deals[0].Offset)
+
+	res, err = i.ingest(ctx)
+	require.NoError(t, err)
+	require.Equal(t, ingestResult{Failed: 1}, res)
+}
@@ -0,0 +1,77 @@
+// Package denylist parses denylists in the format of the badbits denylist
+// (https://badbits.dwebops.pub/), so that booster-http and booster-bitswap
+// refuse to serve the same content.
+package denylist
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+)
+
+// Entries is the set of hashed entries in a denylist.
+// Each line of a denylist is one of:
+//   - //<hash>: the hex-encoded sha256 hash of "<CIDv1>/<path>", where the
+//     CID is base32 encoded and the path may be empty
+//   - <cid> or /ipfs/<cid>[/path]: a CID (and optional path) in the clear
+//
+// Empty lines and lines starting with # are ignored.
+type Entries map[[sha256.Size]byte]struct{}
+
+// Hash returns the hash of the CID and path in the badbits format
+func Hash(c cid.Cid, path []string) [sha256.Size]byte {
+	v1 := cid.NewCidV1(c.Type(), c.Hash())
+	return sha256.Sum256([]byte(v1.String() + "/" + strings.Join(path, "/")))
+}
+
+// Parse reads the entries in a denylist
+func Parse(r io.Reader) (Entries, error) {
+	entries := make(Entries)
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// A hashed entry
+		if strings.HasPrefix(line, "//") {
+			bz, err := hex.DecodeString(strings.TrimPrefix(line, "//"))
+			if err != nil || len(bz) != sha256.Size {
+				return nil, fmt.Errorf("line %d: invalid hash '%s'", lineNum, line)
+			}
+			var h [sha256.Size]byte
+			copy(h[:], bz)
+			entries[h] = struct{}{}
+			continue
+		}
+
+		// A CID (and path) in the clear
+		segments := strings.Split(strings.Trim(strings.TrimPrefix(line, "/ipfs/"), "/"), "/")
+		c, err := cid.Parse(segments[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: parsing CID '%s': %w", lineNum, segments[0], err)
+		}
+		entries[Hash(c, segments[1:])] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Contains returns true if the CID, or the CID with any prefix of the path,
+// is in the denylist
+func (e Entries) Contains(c cid.Cid, path []string) bool {
+	for i := 0; i <= len(path); i++ {
+		if _, ok := e[Hash(c, path[:i])]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package denylist
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	hashed := testutil.GenerateCid()
+	clear := testutil.GenerateCid()
+	withPath := testutil.GenerateCid()
+	v0 := cid.NewCidV0(testutil.GenerateCid().Hash())
+
+	h := Hash(hashed, nil)
+	list := fmt.Sprintf("# badbits\n//%s\n\n%s\n/ipfs/%s/a/b\n%s\n", hex.EncodeToString(h[:]), clear, withPath, v0)
+	entries, err := Parse(strings.NewReader(list))
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+
+	require.True(t, entries.Contains(hashed, nil))
+	require.True(t, entries.Contains(hashed, []string{"any", "path"}))
+	require.True(t, entries.Contains(clear, nil))
+	require.False(t, entries.Contains(withPath, []string{"a"}))
+	require.True(t, entries.Contains(withPath, []string{"a", "b", "c"}))
+	// A CIDv0 matches the entry for the same CID in v1, and vice versa
+	require.True(t, entries.Contains(cid.NewCidV1(cid.DagProtobuf, v0.Hash()), nil))
+	require.False(t, entries.Contains(testutil.GenerateCid(), nil))
+
+	// The hash is of the base32 CIDv1 and the path
+	expected := sha256.Sum256([]byte(cid.NewCidV1(cid.DagProtobuf, v0.Hash()).String() + "/a"))
+	require.Equal(t, expected, Hash(v0, []string{"a"}))
+
+	_, err = Parse(strings.NewReader("//not-a-hash\n"))
+	require.Error(t, err)
+	_, err = Parse(strings.NewReader("not-a-cid\n"))
+	require.Error(t, err)
+}
@@ -0,0 +1,286 @@
+package sectoraccessor
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	ds "github.com/filecoin-project/dagstore"
+	"github.com/filecoin-project/dagstore/mount"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/markets/dagstore"
+)
+
+// The priority of an unseal request. Requests with a higher priority are
+// started first.
+type UnsealPriority int
+
+const (
+	// For unseals that are not needed straight away, eg background checks
+	UnsealPriorityLow UnsealPriority = -10
+	// The default priority, used for retrievals
+	UnsealPriorityNormal UnsealPriority = 0
+	// For unseals that a user is waiting on
+	UnsealPriorityHigh UnsealPriority = 10
+)
+
+type unsealPriorityKey struct{}
+
+// WithUnsealPriority sets the priority of any unseal requests made with the
+// context
+func WithUnsealPriority(ctx context.Context, p UnsealPriority) context.Context {
+	return context.WithValue(ctx, unsealPriorityKey{}, p)
+}
+
+// dagstoreWithPriority sets the unseal priority for shards that are
+// acquired through it
+type dagstoreWithPriority struct {
+	ds.Interface
+	priority UnsealPriority
+}
+
+// DagstoreWithUnsealPriority wraps the dagstore so that if acquiring a shard
+// needs the piece to be unsealed, the unseal is queued at the given priority
+func DagstoreWithUnsealPriority(d ds.Interface, p UnsealPriority) ds.Interface {
+	return &dagstoreWithPriority{Interface: d, priority: p}
+}
+
+func (d *dagstoreWithPriority) AcquireShard(ctx context.Context, key shard.Key, out chan ds.ShardResult, opts ds.AcquireOpts) error {
+	return d.Interface.AcquireShard(WithUnsealPriority(ctx, d.priority), key, out, opts)
+}
+
+func unsealPriority(ctx context.Context) UnsealPriority {
+	p, ok := ctx.Value(unsealPriorityKey{}).(UnsealPriority)
+	if !ok {
+		return UnsealPriorityNormal
+	}
+	return p
+}
+
+// UnsealRequest is a request to unseal a piece in a sector that is waiting
+// in the queue or running
+type UnsealRequest struct {
+	SectorID abi.SectorNumber
+	Offset   abi.UnpaddedPieceSize
+	Length   abi.UnpaddedPieceSize
+	Priority UnsealPriority
+	// The number of callers waiting for the piece to be unsealed
+	Waiters   int
+	CreatedAt time.Time
+	// Zero if the request is still in the queue
+	StartedAt time.Time
+}
+
+// UnsealQueueStatus lists the unseal requests in the queue, in the order in
+// which they will be started, and the requests that are running
+type UnsealQueueStatus struct {
+	MaxConcurrent int
+	Running       []UnsealRequest
+	Queued        []UnsealRequest
+}
+
+type unsealKey struct {
+	sectorID abi.SectorNumber
+	offset   abi.UnpaddedPieceSize
+	length   abi.UnpaddedPieceSize
+}
+
+type unsealJob struct {
+	UnsealRequest
+	key     unsealKey
+	running bool
+	done    chan struct{}
+	err     error
+}
+
+// UnsealQueue wraps a sector accessor so that requests to unseal a piece
+// are queued and run with a limit on the number that run concurrently.
+// Requests to unseal the same piece are deduplicated, so that all the
+// callers wait for a single unseal.
+// Queued requests are started in priority order, then by the number of
+// callers waiting, then in the order they were made.
+type UnsealQueue struct {
+	dagstore.SectorAccessor
+	maxConcurrent int
+
+	lk      sync.Mutex
+	jobs    map[unsealKey]*unsealJob
+	running int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+var _ dagstore.SectorAccessor = (*UnsealQueue)(nil)
+
+// NewUnsealQueue creates an unseal queue that runs at most maxConcurrent
+// unseals at a time. If maxConcurrent is zero the number of concurrent
+// unseals is not limited, but requests are still deduplicated.
+func NewUnsealQueue(sa dagstore.SectorAccessor, maxConcurrent int) *UnsealQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &UnsealQueue{
+		SectorAccessor: sa,
+		maxConcurrent:  maxConcurrent,
+		jobs:           make(map[unsealKey]*unsealJob),
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+}
+
+// Close cancels running unseals and waits for them to exit
+func (q *UnsealQueue) Close() {
+	q.cancel()
+	q.wg.Wait()
+}
+
+func (q *UnsealQueue) UnsealSector(ctx context.Context, sectorID abi.SectorNumber, pieceOffset abi.UnpaddedPieceSize, length abi.UnpaddedPieceSize) (io.ReadCloser, error) {
+	return q.UnsealSectorAt(ctx, sectorID, pieceOffset, length)
+}
+
+// UnsealSectorAt returns a reader over the piece. If the piece is not
+// unsealed, it waits in the queue for the piece to be unsealed first.
+func (q *UnsealQueue) UnsealSectorAt(ctx context.Context, sectorID abi.SectorNumber, pieceOffset abi.UnpaddedPieceSize, length abi.UnpaddedPieceSize) (mount.Reader, error) {
+	isUnsealed, err := q.SectorAccessor.IsUnsealed(ctx, sectorID, pieceOffset, length)
+	if err != nil {
+		log.Debugw("failed to check if piece is unsealed, queuing unseal", "sector", sectorID, "err", err)
+	}
+	if err != nil || !isUnsealed {
+		if err := q.waitForUnseal(ctx, unsealKey{sectorID: sectorID, offset: pieceOffset, length: length}); err != nil {
+			return nil, err
+		}
+	}
+
+	// The piece is unsealed, so this just opens a reader over the piece
+	return q.SectorAccessor.UnsealSectorAt(ctx, sectorID, pieceOffset, length)
+}
+
+func (q *UnsealQueue) waitForUnseal(ctx context.Context, key unsealKey) error {
+	priority := unsealPriority(ctx)
+
+	q.lk.Lock()
+	job, ok := q.jobs[key]
+	if !ok {
+		job = &unsealJob{
+			UnsealRequest: UnsealRequest{
+				SectorID:  key.sectorID,
+				Offset:    key.offset,
+				Length:    key.length,
+				Priority:  priority,
+				CreatedAt: time.Now(),
+			},
+			key:  key,
+			done: make(chan struct{}),
+		}
+		q.jobs[key] = job
+		log.Debugw("queued unseal", "sector", key.sectorID, "offset", key.offset, "length", key.length, "priority", priority)
+	} else if priority > job.Priority {
+		job.Priority = priority
+	}
+	job.Waiters++
+	q.scheduleLocked()
+	q.lk.Unlock()
+
+	select {
+	case <-job.done:
+		return job.err
+	case <-ctx.Done():
+		q.lk.Lock()
+		job.Waiters--
+		// If no one is waiting for the unseal any more, and it hasn't
+		// started yet, remove it from the queue
+		if job.Waiters == 0 && !job.running && q.jobs[key] == job {
+			delete(q.jobs, key)
+			log.Debugw("removed unseal from queue: no callers waiting", "sector", key.sectorID)
+		}
+		q.lk.Unlock()
+		return ctx.Err()
+	}
+}
+
+// scheduleLocked starts queued jobs until the concurrency limit is reached
+func (q *UnsealQueue) scheduleLocked() {
+	for q.maxConcurrent <= 0 || q.running < q.maxConcurrent {
+		queued := q.queuedLocked()
+		if len(queued) == 0 {
+			return
+		}
+
+		job := queued[0]
+		job.running = true
+		job.StartedAt = time.Now()
+		q.running++
+		log.Infow("unsealing piece", "sector", job.SectorID, "offset", job.Offset, "length", job.Length,
+			"waiters", job.Waiters, "queued", job.StartedAt.Sub(job.CreatedAt).String())
+
+		q.wg.Add(1)
+		go q.run(job)
+	}
+}
+
+func (q *UnsealQueue) run(job *unsealJob) {
+	defer q.wg.Done()
+
+	// Reading the piece from the sector accessor unseals it
+	r, err := q.SectorAccessor.UnsealSectorAt(q.ctx, job.SectorID, job.Offset, job.Length)
+	if err == nil {
+		_ = r.Close()
+		log.Infow("unsealed piece", "sector", job.SectorID, "took", time.Since(job.StartedAt).String())
+	} else {
+		log.Warnw("failed to unseal piece", "sector", job.SectorID, "offset", job.Offset, "length", job.Length, "err", err)
+	}
+
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	job.err = err
+	close(job.done)
+	delete(q.jobs, job.key)
+	q.running--
+	q.scheduleLocked()
+}
+
+// queuedLocked returns the jobs that haven't started, in the order in which
+// they should be started
+func (q *UnsealQueue) queuedLocked() []*unsealJob {
+	queued := make([]*unsealJob, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		if !job.running {
+			queued = append(queued, job)
+		}
+	}
+	sort.Slice(queued, func(i, j int) bool {
+		a, b := queued[i], queued[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if a.Waiters != b.Waiters {
+			return a.Waiters > b.Waiters
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+	return queued
+}
+
+// Status returns the running and queued unseal requests
+func (q *UnsealQueue) Status() UnsealQueueStatus {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	st := UnsealQueueStatus{MaxConcurrent: q.maxConcurrent}
+	for _, job := range q.jobs {
+		if job.running {
+			st.Running = append(st.Running, job.UnsealRequest)
+		}
+	}
+	sort.Slice(st.Running, func(i, j int) bool {
+		return st.Running[i].StartedAt.Before(st.Running[j].StartedAt)
+	})
+	for _, job := range q.queuedLocked() {
+		st.Queued = append(st.Queued, job.UnsealRequest)
+	}
+	return st
+}
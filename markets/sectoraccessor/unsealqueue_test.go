@@ -0,0 +1,236 @@
+package sectoraccessor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	ds "github.com/filecoin-project/dagstore"
+	"github.com/filecoin-project/dagstore/mount"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/stretchr/testify/require"
+)
+
+type nopCloserReader struct {
+	*bytes.Reader
+}
+
+func (nopCloserReader) Close() error {
+	return nil
+}
+
+// mockSectorAccessor unseals a sector when the unseal for the sector is
+// released
+type mockSectorAccessor struct {
+	lk       sync.Mutex
+	unsealed map[abi.SectorNumber]bool
+	release  map[abi.SectorNumber]chan struct{}
+	unseals  map[abi.SectorNumber]int
+	started  chan abi.SectorNumber
+}
+
+func newMockSectorAccessor() *mockSectorAccessor {
+	return &mockSectorAccessor{
+		unsealed: make(map[abi.SectorNumber]bool),
+		release:  make(map[abi.SectorNumber]chan struct{}),
+		unseals:  make(map[abi.SectorNumber]int),
+		started:  make(chan abi.SectorNumber, 16),
+	}
+}
+
+func (m *mockSectorAccessor) releaseCh(sectorID abi.SectorNumber) chan struct{} {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	ch, ok := m.release[sectorID]
+	if !ok {
+		ch = make(chan struct{})
+		m.release[sectorID] = ch
+	}
+	return ch
+}
+
+func (m *mockSectorAccessor) unsealCount(sectorID abi.SectorNumber) int {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return m.unseals[sectorID]
+}
+
+func (m *mockSectorAccessor) IsUnsealed(ctx context.Context, sectorID abi.SectorNumber, offset abi.UnpaddedPieceSize, length abi.UnpaddedPieceSize) (bool, error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return m.unsealed[sectorID], nil
+}
+
+func (m *mockSectorAccessor) UnsealSector(ctx context.Context, sectorID abi.SectorNumber, offset abi.UnpaddedPieceSize, length abi.UnpaddedPieceSize) (io.ReadCloser, error) {
+	return m.UnsealSectorAt(ctx, sectorID, offset, length)
+}
+
+func (m *mockSectorAccessor) UnsealSectorAt(ctx context.Context, sectorID abi.SectorNumber, offset abi.UnpaddedPieceSize, length abi.UnpaddedPieceSize) (mount.Reader, error) {
+	m.lk.Lock()
+	unsealed := m.unsealed[sectorID]
+	if !unsealed {
+		m.unseals[sectorID]++
+	}
+	m.lk.Unlock()
+
+	if !unsealed {
+		m.started <- sectorID
+		select {
+		case <-m.releaseCh(sectorID):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		m.lk.Lock()
+		m.unsealed[sectorID] = true
+		m.lk.Unlock()
+	}
+
+	return nopCloserReader{bytes.NewReader([]byte("data"))}, nil
+}
+
+func TestUnsealQueueDeduplicates(t *testing.T) {
+	ctx := context.Background()
+	sa := newMockSectorAccessor()
+	q := NewUnsealQueue(sa, 2)
+	defer q.Close()
+
+	// Make several requests to unseal the same piece
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := q.UnsealSectorAt(ctx, 1, 0, 10)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+		}()
+	}
+
+	require.Eventually(t, func() bool {
+		st := q.Status()
+		return len(st.Running) == 1 && st.Running[0].Waiters == 3
+	}, time.Second, time.Millisecond)
+
+	close(sa.releaseCh(1))
+	wg.Wait()
+
+	// The piece should only have been unsealed once
+	require.Equal(t, 1, sa.unsealCount(1))
+	require.Empty(t, q.Status().Running)
+
+	// Once the piece is unsealed, it's read without going through the queue
+	r, err := q.UnsealSectorAt(ctx, 1, 0, 10)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, 1, sa.unsealCount(1))
+}
+
+func TestUnsealQueuePriority(t *testing.T) {
+	ctx := context.Background()
+	sa := newMockSectorAccessor()
+	q := NewUnsealQueue(sa, 1)
+	defer q.Close()
+
+	unseal := func(ctx context.Context, sectorID abi.SectorNumber) {
+		go func() {
+			r, err := q.UnsealSectorAt(ctx, sectorID, 0, 10)
+			if err == nil {
+				_ = r.Close()
+			}
+		}()
+	}
+
+	// Sector 1 takes the only unseal slot
+	unseal(ctx, 1)
+	require.Equal(t, abi.SectorNumber(1), <-sa.started)
+
+	// Queue sector 2 at low priority, sector 3 at normal priority, and
+	// sector 4 at normal priority with two callers waiting
+	unseal(WithUnsealPriority(ctx, UnsealPriorityLow), 2)
+	unseal(ctx, 3)
+	unseal(ctx, 4)
+	unseal(ctx, 4)
+
+	require.Eventually(t, func() bool {
+		st := q.Status()
+		return len(st.Queued) == 3 && st.Queued[0].Waiters == 2
+	}, time.Second, time.Millisecond)
+
+	st := q.Status()
+	require.Equal(t, 1, st.MaxConcurrent)
+	require.Len(t, st.Running, 1)
+	require.Equal(t, abi.SectorNumber(1), st.Running[0].SectorID)
+	require.Equal(t, abi.SectorNumber(4), st.Queued[0].SectorID)
+	require.Equal(t, abi.SectorNumber(3), st.Queued[1].SectorID)
+	require.Equal(t, abi.SectorNumber(2), st.Queued[2].SectorID)
+
+	// Queued requests are started in priority order as each unseal completes
+	running := abi.SectorNumber(1)
+	for _, next := range []abi.SectorNumber{4, 3, 2} {
+		close(sa.releaseCh(running))
+		require.Equal(t, next, <-sa.started)
+		running = next
+	}
+	close(sa.releaseCh(running))
+}
+
+func TestUnsealQueueCancel(t *testing.T) {
+	ctx := context.Background()
+	sa := newMockSectorAccessor()
+	q := NewUnsealQueue(sa, 1)
+	defer q.Close()
+
+	go func() {
+		_, _ = q.UnsealSectorAt(ctx, 1, 0, 10)
+	}()
+	require.Equal(t, abi.SectorNumber(1), <-sa.started)
+
+	// When the only caller waiting for a queued unseal gives up, the unseal
+	// is removed from the queue
+	cctx, cancel := context.WithCancel(ctx)
+	errch := make(chan error, 1)
+	go func() {
+		_, err := q.UnsealSectorAt(cctx, 2, 0, 10)
+		errch <- err
+	}()
+	require.Eventually(t, func() bool {
+		return len(q.Status().Queued) == 1
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-errch, context.Canceled)
+	require.Empty(t, q.Status().Queued)
+
+	close(sa.releaseCh(1))
+	require.Eventually(t, func() bool {
+		return len(q.Status().Running) == 0
+	}, time.Second, time.Millisecond)
+	require.Equal(t, 0, sa.unsealCount(2))
+}
+
+// mockDagstore records the unseal priority of the context that each shard
+// is acquired with
+type mockDagstore struct {
+	ds.Interface
+	priority UnsealPriority
+}
+
+func (m *mockDagstore) AcquireShard(ctx context.Context, key shard.Key, out chan ds.ShardResult, opts ds.AcquireOpts) error {
+	m.priority = unsealPriority(ctx)
+	return nil
+}
+
+func TestDagstoreWithUnsealPriority(t *testing.T) {
+	ctx := context.Background()
+	mds := &mockDagstore{}
+	d := DagstoreWithUnsealPriority(mds, UnsealPriorityLow)
+
+	require.NoError(t, d.AcquireShard(ctx, shard.KeyFromString("key"), make(chan ds.ShardResult, 1), ds.AcquireOpts{}))
+	require.Equal(t, UnsealPriorityLow, mds.priority)
+}
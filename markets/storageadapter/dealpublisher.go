@@ -3,14 +3,18 @@ package storageadapter
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/ipfs/go-cid"
+	"go.opencensus.io/stats"
 	"go.uber.org/fx"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/boost/metrics"
+
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
@@ -29,6 +33,8 @@ import (
 type dealPublisherAPI interface {
 	ChainHead(context.Context) (*types.TipSet, error)
 	MpoolPushMessage(ctx context.Context, msg *types.Message, spec *api.MessageSendSpec) (*types.SignedMessage, error)
+	MpoolGetNonce(context.Context, address.Address) (uint64, error)
+	StateGetActor(ctx context.Context, actor address.Address, tsk types.TipSetKey) (*types.Actor, error)
 	StateMinerInfo(context.Context, address.Address, types.TipSetKey) (api.MinerInfo, error)
 
 	WalletBalance(context.Context, address.Address) (types.BigInt, error)
@@ -49,6 +55,9 @@ type dealPublisherAPI interface {
 type DealPublisher struct {
 	api dealPublisherAPI
 	as  *ctladdr.AddressSelector
+	// Chooses the wallet to send each publish message from, when there are
+	// several publish wallets
+	wallets *publishWallets
 
 	ctx      context.Context
 	Shutdown context.CancelFunc
@@ -57,13 +66,45 @@ type DealPublisher struct {
 	publishPeriod         time.Duration
 	publishSpec           *api.MessageSendSpec
 
+	// Publish messages are held while the base fee is above maxBaseFee
+	maxBaseFee abi.TokenAmount
+	maxFeeCap  abi.TokenAmount
+	maxGasWait time.Duration
+	// How often to check the base fee while a batch is held
+	gasCheckInterval time.Duration
+
 	lk                      sync.Mutex
 	pending                 []*pendingDeal
 	cancelWaitForMoreDeals  context.CancelFunc
 	publishPeriodStart      time.Time
 	startEpochSealingBuffer abi.ChainEpoch
+	// Batches of deals that are held until the base fee falls
+	held map[*heldBatch]struct{}
+	// Closed when held batches should be published immediately
+	releaseHeld chan struct{}
 }
 
+// A batch of deals whose publish message is held until the base fee falls
+// below the maximum
+type heldBatch struct {
+	deals   []*pendingDeal
+	since   time.Time
+	baseFee abi.TokenAmount
+}
+
+// GasHeldBatch is a batch of deals whose publish message is being held
+// because the base fee is above the configured maximum
+type GasHeldBatch struct {
+	Deals      []market.ClientDealProposal
+	HeldSince  time.Time
+	BaseFee    abi.TokenAmount
+	MaxBaseFee abi.TokenAmount
+}
+
+// Publish messages are always sent when the deals are this close to their
+// start epoch (plus the sealing buffer), even if the base fee is high
+const gasHoldStartEpochMargin = abi.ChainEpoch(builtin.EpochsInHour)
+
 // A deal that is queued to be published
 type pendingDeal struct {
 	ctx    context.Context
@@ -94,6 +135,25 @@ type PublishMsgConfig struct {
 	MaxDealsPerMsg uint64
 	// Minimum start epoch buffer to give time for sealing of sector with deal
 	StartEpochSealingBuffer uint64
+	// The wallets to send publish messages from. If there is more than one,
+	// the wallet for each message is chosen according to WalletStrategy, so
+	// that a wallet with a stuck message doesn't block all publish messages.
+	// If empty, the wallet is chosen by the address selector.
+	Wallets []address.Address
+	// How to choose the wallet for each message: PublishWalletsRoundRobin or
+	// PublishWalletsNonceBacklog
+	WalletStrategy string
+	// Hold publish messages while the base fee is above MaxBaseFee.
+	// Zero disables the check.
+	MaxBaseFee abi.TokenAmount
+	// The gas fee cap to set on publish messages. Zero means the fee cap is
+	// estimated by the full node.
+	MaxFeeCap abi.TokenAmount
+	// The maximum amount of time to hold a publish message waiting for the
+	// base fee to fall, after which it is sent anyway. Zero means the message
+	// is held until the base fee falls or the deals get close to their start
+	// epoch.
+	MaxGasWait time.Duration
 }
 
 func NewDealPublisher(
@@ -124,18 +184,36 @@ func newDealPublisher(
 	publishSpec *api.MessageSendSpec,
 ) *DealPublisher {
 	ctx, cancel := context.WithCancel(context.Background())
+	var wallets *publishWallets
+	if len(publishMsgCfg.Wallets) > 1 {
+		wallets = newPublishWallets(publishMsgCfg.WalletStrategy, publishMsgCfg.Wallets)
+	}
 	return &DealPublisher{
 		api:                     dpapi,
 		as:                      as,
+		wallets:                 wallets,
 		ctx:                     ctx,
 		Shutdown:                cancel,
 		maxDealsPerPublishMsg:   publishMsgCfg.MaxDealsPerMsg,
 		publishPeriod:           publishMsgCfg.Period,
 		startEpochSealingBuffer: abi.ChainEpoch(publishMsgCfg.StartEpochSealingBuffer),
 		publishSpec:             publishSpec,
+		maxBaseFee:              zeroIfNil(publishMsgCfg.MaxBaseFee),
+		maxFeeCap:               zeroIfNil(publishMsgCfg.MaxFeeCap),
+		maxGasWait:              publishMsgCfg.MaxGasWait,
+		gasCheckInterval:        time.Duration(build.BlockDelaySecs) * time.Second,
+		held:                    make(map[*heldBatch]struct{}),
+		releaseHeld:             make(chan struct{}),
 	}
 }
 
+func zeroIfNil(amt abi.TokenAmount) abi.TokenAmount {
+	if amt.Int == nil {
+		return big.Zero()
+	}
+	return amt
+}
+
 // PendingDeals returns the list of deals that are queued up to be published
 func (p *DealPublisher) PendingDeals() api.PendingDealInfo {
 	p.lk.Lock()
@@ -161,14 +239,46 @@ func (p *DealPublisher) PendingDeals() api.PendingDealInfo {
 	}
 }
 
+// HeldForGas returns the batches of deals whose publish message is being
+// held because the base fee is too high, oldest first
+func (p *DealPublisher) HeldForGas() []GasHeldBatch {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	batches := make([]GasHeldBatch, 0, len(p.held))
+	for hb := range p.held {
+		deals := make([]market.ClientDealProposal, 0, len(hb.deals))
+		for _, pd := range hb.deals {
+			if pd.ctx.Err() == nil {
+				deals = append(deals, pd.deal)
+			}
+		}
+		batches = append(batches, GasHeldBatch{
+			Deals:      deals,
+			HeldSince:  hb.since,
+			BaseFee:    hb.baseFee,
+			MaxBaseFee: p.maxBaseFee,
+		})
+	}
+	sort.Slice(batches, func(i, j int) bool {
+		return batches[i].HeldSince.Before(batches[j].HeldSince)
+	})
+	return batches
+}
+
 // ForcePublishPendingDeals publishes all pending deals without waiting for
-// the publish period to elapse
+// the publish period to elapse, including deals that are held because the
+// base fee is too high
 func (p *DealPublisher) ForcePublishPendingDeals() {
 	p.lk.Lock()
 	defer p.lk.Unlock()
 
 	log.Infof("force publishing deals")
 	p.publishAllDeals()
+
+	// Release any batches that are held waiting for the base fee to fall
+	close(p.releaseHeld)
+	p.releaseHeld = make(chan struct{})
 }
 
 func (p *DealPublisher) Publish(ctx context.Context, deal market.ClientDealProposal) (cid.Cid, error) {
@@ -293,6 +403,12 @@ func (p *DealPublisher) publishReady(ready []*pendingDeal) {
 		}
 	}
 
+	// Wait for the base fee to fall below the maximum
+	ready = p.waitForGas(ready)
+	if len(ready) == 0 {
+		return
+	}
+
 	// Validate each deal to make sure it can be published
 	validated := make([]*pendingDeal, 0, len(ready))
 	deals := make([]market.ClientDealProposal, 0, len(ready))
@@ -310,6 +426,11 @@ func (p *DealPublisher) publishReady(ready []*pendingDeal) {
 
 	// Send the publish message
 	msgCid, err := p.publishDealProposals(deals)
+	if err != nil {
+		stats.Record(p.ctx, metrics.PublishFailedCount.M(1))
+	} else if len(deals) > 0 {
+		stats.Record(p.ctx, metrics.PublishBatchSize.M(int64(len(deals))))
+	}
 
 	// Signal that each deal has been published
 	for _, pd := range validated {
@@ -317,6 +438,112 @@ func (p *DealPublisher) publishReady(ready []*pendingDeal) {
 	}
 }
 
+// waitForGas holds the batch of deals while the base fee is above the
+// maximum. The batch is released when
+// - the base fee falls below the maximum
+// - the maximum wait time elapses
+// - the deals get close to their start epoch
+// - publishing is forced
+// It returns the deals in the batch that haven't been cancelled.
+func (p *DealPublisher) waitForGas(deals []*pendingDeal) []*pendingDeal {
+	if p.maxBaseFee.IsZero() {
+		return deals
+	}
+
+	var hb *heldBatch
+	defer func() {
+		if hb != nil {
+			p.lk.Lock()
+			delete(p.held, hb)
+			p.lk.Unlock()
+		}
+	}()
+
+	var deadline <-chan time.Time
+	if p.maxGasWait > 0 {
+		timer := build.Clock.Timer(p.maxGasWait)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		deals = filterCancelled(deals)
+		if len(deals) == 0 {
+			return nil
+		}
+
+		head, err := p.api.ChainHead(p.ctx)
+		if err != nil {
+			log.Warnw("getting chain head to check base fee: sending publish message", "err", err)
+			return deals
+		}
+
+		baseFee := head.MinTicketBlock().ParentBaseFee
+		if baseFee.LessThanEqual(p.maxBaseFee) {
+			if hb != nil {
+				log.Infow("base fee has fallen below maximum: releasing held publish message",
+					"base-fee", baseFee, "max-base-fee", p.maxBaseFee, "deals", len(deals), "held", build.Clock.Since(hb.since))
+			}
+			return deals
+		}
+
+		if head.Height()+p.startEpochSealingBuffer+gasHoldStartEpochMargin >= minStartEpoch(deals) {
+			log.Warnw("base fee is above maximum but deals are close to start epoch: sending publish message",
+				"base-fee", baseFee, "max-base-fee", p.maxBaseFee, "deals", len(deals))
+			return deals
+		}
+
+		p.lk.Lock()
+		if hb == nil {
+			hb = &heldBatch{since: build.Clock.Now()}
+			p.held[hb] = struct{}{}
+			log.Infow("base fee is above maximum: holding publish message",
+				"base-fee", baseFee, "max-base-fee", p.maxBaseFee, "deals", len(deals))
+		}
+		hb.deals = deals
+		hb.baseFee = baseFee
+		release := p.releaseHeld
+		p.lk.Unlock()
+
+		timer := build.Clock.Timer(p.gasCheckInterval)
+		select {
+		case <-p.ctx.Done():
+			timer.Stop()
+			return nil
+		case <-release:
+			timer.Stop()
+			log.Infow("force publishing held publish message", "base-fee", baseFee, "deals", len(deals))
+			return filterCancelled(deals)
+		case <-deadline:
+			timer.Stop()
+			log.Warnw("publish message held for max gas wait time: sending publish message",
+				"max-wait", p.maxGasWait, "base-fee", baseFee, "max-base-fee", p.maxBaseFee, "deals", len(deals))
+			return filterCancelled(deals)
+		case <-timer.C:
+		}
+	}
+}
+
+func minStartEpoch(deals []*pendingDeal) abi.ChainEpoch {
+	earliest := deals[0].deal.Proposal.StartEpoch
+	for _, pd := range deals[1:] {
+		if pd.deal.Proposal.StartEpoch < earliest {
+			earliest = pd.deal.Proposal.StartEpoch
+		}
+	}
+	return earliest
+}
+
+func filterCancelled(deals []*pendingDeal) []*pendingDeal {
+	filtered := make([]*pendingDeal, 0, len(deals))
+	for _, pd := range deals {
+		if pd.ctx.Err() == nil {
+			filtered = append(filtered, pd)
+		}
+	}
+	return filtered
+}
+
 // validateDeal checks that the deal proposal start epoch hasn't already
 // elapsed
 func (p *DealPublisher) validateDeal(deal market.ClientDealProposal) error {
@@ -405,18 +632,29 @@ func (p *DealPublisher) publishDealProposals(deals []market.ClientDealProposal)
 		return cid.Undef, xerrors.Errorf("serializing PublishStorageDeals params failed: %w", err)
 	}
 
-	addr, _, err := p.as.AddressFor(p.ctx, p.api, mi, api.DealPublishAddr, big.Zero(), big.Zero())
-	if err != nil {
-		return cid.Undef, xerrors.Errorf("selecting address for publishing deals: %w", err)
+	var addr address.Address
+	if p.wallets != nil {
+		addr = p.wallets.selectWallet(p.ctx, p.api, mi, p.publishSpec.MaxFee)
+	} else {
+		addr, _, err = p.as.AddressFor(p.ctx, p.api, mi, api.DealPublishAddr, big.Zero(), big.Zero())
+		if err != nil {
+			return cid.Undef, xerrors.Errorf("selecting address for publishing deals: %w", err)
+		}
 	}
+	log.Infow("sending publish deals message", "from", addr, "deals", len(deals))
 
-	smsg, err := p.api.MpoolPushMessage(p.ctx, &types.Message{
+	msg := &types.Message{
 		To:     builtin.StorageMarketActorAddr,
 		From:   addr,
 		Value:  types.NewInt(0),
 		Method: builtin.MethodsMarket.PublishStorageDeals,
 		Params: params,
-	}, p.publishSpec)
+	}
+	if !p.maxFeeCap.IsZero() {
+		// The full node only estimates the fee cap if it isn't set
+		msg.GasFeeCap = p.maxFeeCap
+	}
+	smsg, err := p.api.MpoolPushMessage(p.ctx, msg, p.publishSpec)
 
 	if err != nil {
 		return cid.Undef, err
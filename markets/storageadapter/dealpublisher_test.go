@@ -4,6 +4,7 @@ package storageadapter
 import (
 	"bytes"
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -232,6 +233,105 @@ func TestForcePublish(t *testing.T) {
 	checkPublishedDeals(t, dpapi, dealsToPublish, []int{2})
 }
 
+func TestHoldForGas(t *testing.T) {
+	newPublisher := func(t *testing.T, maxGasWait time.Duration) (*dpAPI, *DealPublisher) {
+		dpapi := newDPAPI(t)
+		dpapi.setBaseFee(abi.NewTokenAmount(200))
+		dp := newDealPublisher(dpapi, nil, PublishMsgConfig{
+			Period:         0,
+			MaxDealsPerMsg: 10,
+			MaxBaseFee:     abi.NewTokenAmount(100),
+			MaxFeeCap:      abi.NewTokenAmount(150),
+			MaxGasWait:     maxGasWait,
+		}, &api.MessageSendSpec{MaxFee: abi.NewTokenAmount(1)})
+		dp.gasCheckInterval = 10 * time.Millisecond
+		t.Cleanup(dp.Shutdown)
+		return dpapi, dp
+	}
+
+	// Deals that are far enough from their start epoch to be held
+	publishLaterDeal := func(t *testing.T, dp *DealPublisher) markettypes.ClientDealProposal {
+		deal := markettypes.ClientDealProposal{
+			Proposal: markettypes.DealProposal{
+				PieceCID:   generateCids(1)[0],
+				Client:     getClientActor(t),
+				Provider:   getProviderActor(t),
+				StartEpoch: abi.ChainEpoch(1000),
+				EndEpoch:   abi.ChainEpoch(2000),
+			},
+			ClientSignature: crypto.Signature{
+				Type: crypto.SigTypeSecp256k1,
+				Data: []byte("signature data"),
+			},
+		}
+		go func() {
+			_, _ = dp.Publish(context.Background(), deal)
+		}()
+		return deal
+	}
+
+	requireHeld := func(t *testing.T, dp *DealPublisher, dpapi *dpAPI) {
+		require.Eventually(t, func() bool {
+			return len(dp.HeldForGas()) == 1
+		}, time.Second, time.Millisecond)
+
+		held := dp.HeldForGas()[0]
+		require.Len(t, held.Deals, 1)
+		require.EqualValues(t, 200, held.BaseFee.Int64())
+		require.EqualValues(t, 100, held.MaxBaseFee.Int64())
+
+		// Expect no message to be sent while the batch is held
+		build.Clock.Sleep(50 * time.Millisecond)
+		require.Len(t, dpapi.pushedMsgs, 0)
+	}
+
+	t.Run("released when base fee falls", func(t *testing.T) {
+		dpapi, dp := newPublisher(t, 0)
+		deal := publishLaterDeal(t, dp)
+		requireHeld(t, dp, dpapi)
+
+		dpapi.setBaseFee(abi.NewTokenAmount(50))
+		require.Eventually(t, func() bool {
+			return len(dp.HeldForGas()) == 0
+		}, time.Second, time.Millisecond)
+		checkPublishedDeals(t, dpapi, []markettypes.ClientDealProposal{deal}, []int{1})
+	})
+
+	t.Run("released by force publish", func(t *testing.T) {
+		dpapi, dp := newPublisher(t, 0)
+		deal := publishLaterDeal(t, dp)
+		requireHeld(t, dp, dpapi)
+
+		dp.ForcePublishPendingDeals()
+		checkPublishedDeals(t, dpapi, []markettypes.ClientDealProposal{deal}, []int{1})
+		require.Empty(t, dp.HeldForGas())
+	})
+
+	t.Run("released after max wait", func(t *testing.T) {
+		dpapi, dp := newPublisher(t, 200*time.Millisecond)
+		deal := publishLaterDeal(t, dp)
+		requireHeld(t, dp, dpapi)
+
+		checkPublishedDeals(t, dpapi, []markettypes.ClientDealProposal{deal}, []int{1})
+	})
+
+	t.Run("not held when close to start epoch", func(t *testing.T) {
+		dpapi, dp := newPublisher(t, 0)
+		deal := publishDeal(t, dp, 0, false, false)
+		checkPublishedDeals(t, dpapi, []markettypes.ClientDealProposal{deal}, []int{1})
+	})
+
+	t.Run("fee cap", func(t *testing.T) {
+		dpapi, dp := newPublisher(t, 0)
+		dpapi.setBaseFee(abi.NewTokenAmount(50))
+		publishLaterDeal(t, dp)
+
+		<-dpapi.stateMinerInfoCalls
+		msg := <-dpapi.pushedMsgs
+		require.EqualValues(t, 150, msg.GasFeeCap.Int64())
+	})
+}
+
 func publishDeal(t *testing.T, dp *DealPublisher, invalid int, ctxCancelled bool, expired bool) markettypes.ClientDealProposal {
 	ctx, cancel := context.WithCancel(context.Background())
 	t.Cleanup(cancel)
@@ -342,6 +442,9 @@ type dpAPI struct {
 	t      *testing.T
 	worker address.Address
 
+	lk      sync.Mutex
+	baseFee abi.TokenAmount
+
 	stateMinerInfoCalls chan address.Address
 	pushedMsgs          chan *types.Message
 }
@@ -355,10 +458,25 @@ func newDPAPI(t *testing.T) *dpAPI {
 	}
 }
 
+func (d *dpAPI) setBaseFee(baseFee abi.TokenAmount) {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+	d.baseFee = baseFee
+}
+
 func (d *dpAPI) ChainHead(ctx context.Context) (*types.TipSet, error) {
 	dummyCid, err := cid.Parse("bafkqaaa")
 	require.NoError(d.t, err)
+
+	d.lk.Lock()
+	baseFee := d.baseFee
+	d.lk.Unlock()
+	if baseFee.Int == nil {
+		baseFee = abi.NewTokenAmount(0)
+	}
+
 	return types.NewTipSet([]*types.BlockHeader{{
+		ParentBaseFee:         baseFee,
 		Miner:                 tutils.NewActorAddr(d.t, "miner"),
 		Height:                abi.ChainEpoch(10),
 		ParentStateRoot:       dummyCid,
@@ -379,6 +497,14 @@ func (d *dpAPI) MpoolPushMessage(ctx context.Context, msg *types.Message, spec *
 	return &types.SignedMessage{Message: *msg}, nil
 }
 
+func (d *dpAPI) MpoolGetNonce(ctx context.Context, a address.Address) (uint64, error) {
+	panic("don't call me")
+}
+
+func (d *dpAPI) StateGetActor(ctx context.Context, a address.Address, key types.TipSetKey) (*types.Actor, error) {
+	panic("don't call me")
+}
+
 func (d *dpAPI) WalletBalance(ctx context.Context, a address.Address) (types.BigInt, error) {
 	panic("don't call me")
 }
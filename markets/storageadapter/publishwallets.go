@@ -0,0 +1,166 @@
+package storageadapter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// The strategies for choosing which wallet to send a publish message from
+const (
+	// Use each wallet in turn
+	PublishWalletsRoundRobin = "round-robin"
+	// Use the wallet with the fewest messages waiting in the message pool,
+	// so that a wallet with a stuck message isn't used until it's unstuck
+	PublishWalletsNonceBacklog = "nonce-backlog"
+)
+
+// publishWallets chooses the wallet to send each publish message from, out
+// of several wallets
+type publishWallets struct {
+	strategy string
+	wallets  []address.Address
+
+	lk   sync.Mutex
+	next int
+}
+
+func newPublishWallets(strategy string, wallets []address.Address) *publishWallets {
+	if strategy == "" {
+		strategy = PublishWalletsRoundRobin
+	}
+	return &publishWallets{strategy: strategy, wallets: wallets}
+}
+
+// ValidatePublishWalletStrategy returns an error if the strategy isn't one
+// of the publish wallet strategies
+func ValidatePublishWalletStrategy(strategy string) error {
+	switch strategy {
+	case "", PublishWalletsRoundRobin, PublishWalletsNonceBacklog:
+		return nil
+	}
+	return fmt.Errorf("unknown publish wallet strategy '%s': must be '%s' or '%s'",
+		strategy, PublishWalletsRoundRobin, PublishWalletsNonceBacklog)
+}
+
+// selectWallet returns the wallet to send the next publish message from.
+// Wallets that aren't control addresses of the miner, that aren't in the
+// local wallet or that have less than minFunds are skipped (unless no wallet
+// can be used, in which case it falls back to all the wallets).
+func (w *publishWallets) selectWallet(ctx context.Context, a dealPublisherAPI, mi api.MinerInfo, minFunds abi.TokenAmount) address.Address {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	// Order the wallets starting from the next wallet in the rotation
+	ordered := make([]address.Address, 0, len(w.wallets))
+	for i := range w.wallets {
+		ordered = append(ordered, w.wallets[(w.next+i)%len(w.wallets)])
+	}
+
+	usable := make([]address.Address, 0, len(ordered))
+	for _, addr := range ordered {
+		if w.usable(ctx, a, mi, addr, minFunds) {
+			usable = append(usable, addr)
+		}
+	}
+	if len(usable) == 0 {
+		log.Warnw("none of the publish storage deals wallets can be used: falling back to all wallets", "wallets", w.wallets)
+		usable = ordered
+	}
+
+	selected := usable[0]
+	if w.strategy == PublishWalletsNonceBacklog {
+		selected = w.leastBacklog(ctx, a, usable)
+	}
+
+	for i, addr := range w.wallets {
+		if addr == selected {
+			w.next = (i + 1) % len(w.wallets)
+			break
+		}
+	}
+	return selected
+}
+
+// usable returns true if the address is a control address of the miner with
+// a key in the local wallet and enough funds
+func (w *publishWallets) usable(ctx context.Context, a dealPublisherAPI, mi api.MinerInfo, addr address.Address, minFunds abi.TokenAmount) bool {
+	idAddr, err := a.StateLookupID(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		log.Warnw("looking up publish storage deals wallet", "address", addr, "error", err)
+		return false
+	}
+	isCtl := idAddr == mi.Owner || idAddr == mi.Worker
+	for _, ctl := range mi.ControlAddresses {
+		isCtl = isCtl || idAddr == ctl
+	}
+	if !isCtl {
+		log.Warnw("publish storage deals wallet is not a control address of the miner", "address", addr)
+		return false
+	}
+
+	bal, err := a.WalletBalance(ctx, addr)
+	if err != nil {
+		log.Warnw("checking publish storage deals wallet balance", "address", addr, "error", err)
+		return false
+	}
+	if bal.LessThan(minFunds) {
+		log.Warnw("publish storage deals wallet balance is too low", "address", addr,
+			"balance", types.FIL(bal), "min", types.FIL(minFunds))
+		return false
+	}
+
+	key, err := a.StateAccountKey(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		log.Warnw("getting publish storage deals wallet account key", "address", addr, "error", err)
+		return false
+	}
+	have, err := a.WalletHas(ctx, key)
+	if err != nil || !have {
+		log.Warnw("publish storage deals wallet key is not in the local wallet", "address", addr, "error", err)
+		return false
+	}
+	return true
+}
+
+// leastBacklog returns the wallet with the fewest messages in the message
+// pool that haven't landed on chain. Ties go to the wallet that comes first.
+func (w *publishWallets) leastBacklog(ctx context.Context, a dealPublisherAPI, wallets []address.Address) address.Address {
+	selected := wallets[0]
+	least := uint64(math.MaxUint64)
+	for _, addr := range wallets {
+		backlog, err := nonceBacklog(ctx, a, addr)
+		if err != nil {
+			log.Warnw("getting publish storage deals wallet nonce backlog", "address", addr, "error", err)
+			continue
+		}
+		if backlog < least {
+			selected = addr
+			least = backlog
+		}
+	}
+	return selected
+}
+
+func nonceBacklog(ctx context.Context, a dealPublisherAPI, addr address.Address) (uint64, error) {
+	// The next nonce, including messages in the message pool
+	next, err := a.MpoolGetNonce(ctx, addr)
+	if err != nil {
+		return 0, fmt.Errorf("getting message pool nonce: %w", err)
+	}
+	// The nonce of the last message that landed on chain
+	act, err := a.StateGetActor(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		return 0, fmt.Errorf("getting actor: %w", err)
+	}
+	if next < act.Nonce {
+		return 0, nil
+	}
+	return next - act.Nonce, nil
+}
@@ -0,0 +1,136 @@
+package storageadapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishWallets(t *testing.T) {
+	ctx := context.Background()
+	w1 := mustIDAddr(t, 1001)
+	w2 := mustIDAddr(t, 1002)
+	w3 := mustIDAddr(t, 1003)
+	mi := api.MinerInfo{Worker: w1, ControlAddresses: []address.Address{w2, w3}}
+	minFunds := abi.NewTokenAmount(10)
+
+	newAPI := func() *pwAPI {
+		return &pwAPI{
+			dpAPI: newDPAPI(t),
+			balances: map[address.Address]abi.TokenAmount{
+				w1: abi.NewTokenAmount(100),
+				w2: abi.NewTokenAmount(100),
+				w3: abi.NewTokenAmount(100),
+			},
+			mpoolNonces: map[address.Address]uint64{},
+			chainNonces: map[address.Address]uint64{},
+		}
+	}
+
+	t.Run("round robin", func(t *testing.T) {
+		a := newAPI()
+		pw := newPublishWallets(PublishWalletsRoundRobin, []address.Address{w1, w2, w3})
+
+		var selected []address.Address
+		for i := 0; i < 4; i++ {
+			selected = append(selected, pw.selectWallet(ctx, a, mi, minFunds))
+		}
+		require.Equal(t, []address.Address{w1, w2, w3, w1}, selected)
+	})
+
+	t.Run("round robin skips wallets that can't be used", func(t *testing.T) {
+		a := newAPI()
+		// w2 doesn't have enough funds
+		a.balances[w2] = abi.NewTokenAmount(5)
+		// w4 isn't a control address
+		w4 := mustIDAddr(t, 1004)
+		a.balances[w4] = abi.NewTokenAmount(100)
+		pw := newPublishWallets(PublishWalletsRoundRobin, []address.Address{w1, w2, w3, w4})
+
+		var selected []address.Address
+		for i := 0; i < 3; i++ {
+			selected = append(selected, pw.selectWallet(ctx, a, mi, minFunds))
+		}
+		require.Equal(t, []address.Address{w1, w3, w1}, selected)
+	})
+
+	t.Run("falls back to all wallets if none can be used", func(t *testing.T) {
+		a := newAPI()
+		for w := range a.balances {
+			a.balances[w] = abi.NewTokenAmount(0)
+		}
+		pw := newPublishWallets(PublishWalletsRoundRobin, []address.Address{w1, w2})
+		require.Equal(t, w1, pw.selectWallet(ctx, a, mi, minFunds))
+		require.Equal(t, w2, pw.selectWallet(ctx, a, mi, minFunds))
+	})
+
+	t.Run("nonce backlog", func(t *testing.T) {
+		a := newAPI()
+		// w1 has a stuck message and another message waiting behind it
+		a.chainNonces[w1], a.mpoolNonces[w1] = 5, 7
+		// w2 has one message waiting
+		a.chainNonces[w2], a.mpoolNonces[w2] = 3, 4
+		// w3 has no messages waiting
+		a.chainNonces[w3], a.mpoolNonces[w3] = 8, 8
+		pw := newPublishWallets(PublishWalletsNonceBacklog, []address.Address{w1, w2, w3})
+
+		require.Equal(t, w3, pw.selectWallet(ctx, a, mi, minFunds))
+		a.mpoolNonces[w3]++
+
+		// w2 and w3 both have one message waiting: expect the next wallet
+		// in the rotation after w3
+		require.Equal(t, w2, pw.selectWallet(ctx, a, mi, minFunds))
+	})
+
+	t.Run("validate strategy", func(t *testing.T) {
+		require.NoError(t, ValidatePublishWalletStrategy(""))
+		require.NoError(t, ValidatePublishWalletStrategy(PublishWalletsRoundRobin))
+		require.NoError(t, ValidatePublishWalletStrategy(PublishWalletsNonceBacklog))
+		require.Error(t, ValidatePublishWalletStrategy("random"))
+	})
+}
+
+func mustIDAddr(t *testing.T, id uint64) address.Address {
+	addr, err := address.NewIDAddress(id)
+	require.NoError(t, err)
+	return addr
+}
+
+// pwAPI is a deal publisher API with wallets that have a balance and a
+// nonce on chain and in the message pool
+type pwAPI struct {
+	*dpAPI
+	balances    map[address.Address]abi.TokenAmount
+	mpoolNonces map[address.Address]uint64
+	chainNonces map[address.Address]uint64
+}
+
+func (a *pwAPI) StateLookupID(ctx context.Context, addr address.Address, key types.TipSetKey) (address.Address, error) {
+	return addr, nil
+}
+
+func (a *pwAPI) StateAccountKey(ctx context.Context, addr address.Address, key types.TipSetKey) (address.Address, error) {
+	return addr, nil
+}
+
+func (a *pwAPI) WalletHas(ctx context.Context, addr address.Address) (bool, error) {
+	_, ok := a.balances[addr]
+	return ok, nil
+}
+
+func (a *pwAPI) WalletBalance(ctx context.Context, addr address.Address) (types.BigInt, error) {
+	return a.balances[addr], nil
+}
+
+func (a *pwAPI) MpoolGetNonce(ctx context.Context, addr address.Address) (uint64, error) {
+	return a.mpoolNonces[addr], nil
+}
+
+func (a *pwAPI) StateGetActor(ctx context.Context, addr address.Address, key types.TipSetKey) (*types.Actor, error) {
+	return &types.Actor{Nonce: a.chainNonces[addr]}, nil
+}
@@ -14,6 +14,10 @@ import (
 
 // Distribution
 var defaultMillisecondsDistribution = view.Distribution(0.01, 0.05, 0.1, 0.3, 0.6, 0.8, 1, 2, 3, 4, 5, 6, 8, 10, 13, 16, 20, 25, 30, 40, 50, 65, 80, 100, 130, 160, 200, 250, 300, 400, 500, 650, 800, 1000, 2000, 3000, 4000, 5000, 7500, 10000, 20000, 50000, 100000)
+var dealBatchSizeDistribution = view.Distribution(1, 2, 4, 8, 16, 32, 64, 128, 256, 512)
+var transferThroughputDistribution = view.Distribution(
+	128<<10, 256<<10, 512<<10, 1<<20, 2<<20, 5<<20, 10<<20, 20<<20, 50<<20, 100<<20, 200<<20, 500<<20, 1<<30, // bytes per second
+)
 var workMillisecondsDistribution = view.Distribution(
 	250, 500, 1000, 2000, 5000, 10_000, 30_000, 60_000, 2*60_000, 5*60_000, 10*60_000, 15*60_000, 30*60_000, // short sealing tasks
 	40*60_000, 45*60_000, 50*60_000, 55*60_000, 60*60_000, 65*60_000, 70*60_000, 75*60_000, 80*60_000, 85*60_000, 100*60_000, 120*60_000, // PC2 / C2 range
@@ -40,11 +44,37 @@ var (
 	MsgValid, _     = tag.NewKey("message_valid")
 	Endpoint, _     = tag.NewKey("endpoint")
 	APIInterface, _ = tag.NewKey("api") // to distinguish between gateway api and full node api endpoint calls
+	GraphqlField, _ = tag.NewKey("graphql_field")
 
 	// miner
 	TaskType, _       = tag.NewKey("task_type")
 	WorkerHostname, _ = tag.NewKey("worker_hostname")
 	StorageID, _      = tag.NewKey("storage_id")
+
+	// http
+	HttpAuthID, _      = tag.NewKey("auth_id")
+	HttpLimitReason, _ = tag.NewKey("limit_reason")
+	HttpEndpoint, _    = tag.NewKey("endpoint")
+	HttpStatus, _      = tag.NewKey("status")
+
+	// bitswap
+	BitswapThrottleReason, _ = tag.NewKey("throttle_reason")
+
+	// retrieval policy
+	RetrievalPolicyRejectReason, _ = tag.NewKey("policy_reject_reason")
+
+	// funds
+	BalanceKind, _ = tag.NewKey("balance_kind")
+	Wallet, _      = tag.NewKey("wallet")
+
+	// storage deals
+	DealCheckpoint, _ = tag.NewKey("checkpoint")
+	DealRule, _       = tag.NewKey("deal_rule")
+	TransferType, _   = tag.NewKey("transfer_type")
+	TransferHost, _   = tag.NewKey("transfer_host")
+
+	// db
+	DBQuery, _ = tag.NewKey("db_query")
 )
 
 // Measures
@@ -53,6 +83,7 @@ var (
 	LotusInfo          = stats.Int64("info", "Arbitrary counter to tag lotus info to", stats.UnitDimensionless)
 	PeerCount          = stats.Int64("peer/count", "Current number of FIL peers", stats.UnitDimensionless)
 	APIRequestDuration = stats.Float64("api/request_duration_ms", "Duration of API requests", stats.UnitMilliseconds)
+	APIRequestErrors   = stats.Int64("api/request_errors", "Counter of API requests that returned an error", stats.UnitDimensionless)
 
 	// chain
 	ChainNodeHeight                     = stats.Int64("chain/node_height", "Current Height of the node", stats.UnitDimensionless)
@@ -116,18 +147,36 @@ var (
 	SplitstoreCompactionDead        = stats.Int64("splitstore/dead", "Number of dead blocks in last compaction", stats.UnitDimensionless)
 
 	// http
-	HttpPayloadByCidRequestCount     = stats.Int64("http/payload_by_cid_request_count", "Counter of /payload/<payload-cid> requests", stats.UnitDimensionless)
-	HttpPayloadByCidRequestDuration  = stats.Float64("http/payload_by_cid_request_duration_ms", "Time spent retrieving a payload by cid", stats.UnitMilliseconds)
-	HttpPayloadByCid200ResponseCount = stats.Int64("http/payload_by_cid_200_response_count", "Counter of /payload/<payload-cid> 200 responses", stats.UnitDimensionless)
-	HttpPayloadByCid400ResponseCount = stats.Int64("http/payload_by_cid_400_response_count", "Counter of /payload/<payload-cid> 400 responses", stats.UnitDimensionless)
-	HttpPayloadByCid404ResponseCount = stats.Int64("http/payload_by_cid_404_response_count", "Counter of /payload/<payload-cid> 404 responses", stats.UnitDimensionless)
-	HttpPayloadByCid500ResponseCount = stats.Int64("http/payload_by_cid_500_response_count", "Counter of /payload/<payload-cid> 500 responses", stats.UnitDimensionless)
+	HttpPayloadByCidRequestCount     = stats.Int64("http/payload_by_cid_request_count", "Counter of /ipfs/<cid> requests", stats.UnitDimensionless)
+	HttpPayloadByCidRequestDuration  = stats.Float64("http/payload_by_cid_request_duration_ms", "Time spent retrieving a block or CAR file by cid", stats.UnitMilliseconds)
+	HttpPayloadByCid200ResponseCount = stats.Int64("http/payload_by_cid_200_response_count", "Counter of /ipfs/<cid> 200 responses", stats.UnitDimensionless)
+	HttpPayloadByCid400ResponseCount = stats.Int64("http/payload_by_cid_400_response_count", "Counter of /ipfs/<cid> 400 responses", stats.UnitDimensionless)
+	HttpPayloadByCid404ResponseCount = stats.Int64("http/payload_by_cid_404_response_count", "Counter of /ipfs/<cid> 404 responses", stats.UnitDimensionless)
+	HttpPayloadByCid500ResponseCount = stats.Int64("http/payload_by_cid_500_response_count", "Counter of /ipfs/<cid> 500 responses", stats.UnitDimensionless)
 	HttpPieceByCidRequestCount       = stats.Int64("http/piece_by_cid_request_count", "Counter of /piece/<piece-cid> requests", stats.UnitDimensionless)
 	HttpPieceByCidRequestDuration    = stats.Float64("http/piece_by_cid_request_duration_ms", "Time spent retrieving a piece by cid", stats.UnitMilliseconds)
 	HttpPieceByCid200ResponseCount   = stats.Int64("http/piece_by_cid_200_response_count", "Counter of /piece/<piece-cid> 200 responses", stats.UnitDimensionless)
 	HttpPieceByCid400ResponseCount   = stats.Int64("http/piece_by_cid_400_response_count", "Counter of /piece/<piece-cid> 400 responses", stats.UnitDimensionless)
 	HttpPieceByCid404ResponseCount   = stats.Int64("http/piece_by_cid_404_response_count", "Counter of /piece/<piece-cid> 404 responses", stats.UnitDimensionless)
 	HttpPieceByCid500ResponseCount   = stats.Int64("http/piece_by_cid_500_response_count", "Counter of /piece/<piece-cid> 500 responses", stats.UnitDimensionless)
+	HttpAuthFailureCount             = stats.Int64("http/auth_failure_count", "Counter of requests rejected because they were not authenticated", stats.UnitDimensionless)
+	HttpAuthBytesSent                = stats.Int64("http/auth_bytes_sent", "Bytes sent in responses to authenticated requests", stats.UnitBytes)
+	HttpCacheHitCount                = stats.Int64("http/cache_hit_count", "Counter of blocks and piece chunks served from the booster-http cache", stats.UnitDimensionless)
+	HttpCacheMissCount               = stats.Int64("http/cache_miss_count", "Counter of blocks and piece chunks that were not in the booster-http cache", stats.UnitDimensionless)
+	HttpCacheBytes                   = stats.Int64("http/cache_bytes", "Size of the data in the booster-http cache", stats.UnitBytes)
+	HttpLimitedCount                 = stats.Int64("http/limited_count", "Counter of requests rejected because the client exceeded a per-IP limit", stats.UnitDimensionless)
+	HttpResponseCount                = stats.Int64("http/response_count", "Counter of responses to download requests", stats.UnitDimensionless)
+	HttpBytesSent                    = stats.Int64("http/bytes_sent", "Bytes sent in responses to download requests", stats.UnitBytes)
+	HttpFleetForwardedCount          = stats.Int64("http/fleet_forwarded_count", "Counter of requests forwarded to the booster-http instance in the fleet that owns the data", stats.UnitDimensionless)
+	HttpDeniedCount                  = stats.Int64("http/denied_count", "Counter of requests for content in the denylist", stats.UnitDimensionless)
+	HttpDenylistEntries              = stats.Int64("http/denylist_entries", "Number of entries in the denylist", stats.UnitDimensionless)
+	HttpFleetForwardErrorCount       = stats.Int64("http/fleet_forward_error_count", "Counter of requests that could not be forwarded to another booster-http instance in the fleet", stats.UnitDimensionless)
+	HttpPolicyRejectedCount          = stats.Int64("http/policy_rejected_count", "Counter of requests rejected by the retrieval policy", stats.UnitDimensionless)
+
+	// graphql
+	GraphqlRequestCount      = stats.Int64("graphql/request_count", "Counter of graphql field resolver calls", stats.UnitDimensionless)
+	GraphqlRequestDuration   = stats.Float64("graphql/request_duration_ms", "Time spent in graphql field resolvers", stats.UnitMilliseconds)
+	GraphqlRequestErrorCount = stats.Int64("graphql/request_error_count", "Counter of graphql field resolver calls that returned an error", stats.UnitDimensionless)
 
 	// bitswap
 	BitswapRblsGetRequestCount             = stats.Int64("bitswap/rbls_get_request_count", "Counter of RemoteBlockstore Get requests", stats.UnitDimensionless)
@@ -140,7 +189,41 @@ var (
 	BitswapRblsHasSuccessResponseCount     = stats.Int64("bitswap/rbls_has_success_response_count", "Counter of successful RemoteBlockstore Has responses", stats.UnitDimensionless)
 	BitswapRblsHasFailResponseCount        = stats.Int64("bitswap/rbls_has_fail_response_count", "Counter of failed RemoteBlockstore Has responses", stats.UnitDimensionless)
 	BitswapRblsBytesSentCount              = stats.Int64("bitswap/rbls_bytes_sent_count", "Counter of the number of bytes sent by bitswap since startup", stats.UnitBytes)
+	BitswapRblsGetDuration                 = stats.Float64("bitswap/rbls_get_duration_ms", "Time taken to fetch a block from the pieces on the provider through the boost API", stats.UnitMilliseconds)
+	BitswapBlockCacheHitCount              = stats.Int64("bitswap/block_cache_hit_count", "Counter of blocks served from the booster-bitswap block cache", stats.UnitDimensionless)
+	BitswapBlockCacheMissCount             = stats.Int64("bitswap/block_cache_miss_count", "Counter of blocks that were not in the booster-bitswap block cache", stats.UnitDimensionless)
+	BitswapPrefetchCount                   = stats.Int64("bitswap/prefetch_count", "Counter of blocks prefetched into the booster-bitswap block cache", stats.UnitDimensionless)
+	BitswapPeers                           = stats.Int64("bitswap/peers", "Number of peers the bitswap server has a ledger for", stats.UnitDimensionless)
+	BitswapWantlistSize                    = stats.Int64("bitswap/wantlist_size", "Number of wants queued across all peers", stats.UnitDimensionless)
+	BitswapBlocksServedCount               = stats.Int64("bitswap/blocks_served_count", "Counter of blocks sent by the bitswap server", stats.UnitDimensionless)
+	BitswapBytesServedCount                = stats.Int64("bitswap/bytes_served_count", "Counter of bytes of block data sent by the bitswap server", stats.UnitBytes)
+	BitswapThrottledCount                  = stats.Int64("bitswap/throttled_count", "Counter of bitswap messages that were delayed by a per-peer or bandwidth limit", stats.UnitDimensionless)
+	BitswapThrottledPeers                  = stats.Int64("bitswap/throttled_peers", "Number of peers whose bitswap messages are currently being delayed by a limit", stats.UnitDimensionless)
+	BitswapPolicyRejectedCount             = stats.Int64("bitswap/policy_rejected_count", "Counter of bitswap requests rejected by the retrieval policy", stats.UnitDimensionless)
+
+	// retrieval
+	MultihashLookupCacheHitCount  = stats.Int64("retrieval/mh_lookup_cache_hit_count", "Counter of multihash -> piece lookups served from the cache", stats.UnitDimensionless)
+	MultihashLookupCacheMissCount = stats.Int64("retrieval/mh_lookup_cache_miss_count", "Counter of multihash -> piece lookups not in the cache", stats.UnitDimensionless)
 
+	// funds
+	FundsBalance    = stats.Float64("funds/balance_fil", "Balance of a tracked wallet or of the market escrow available for new deals", stats.UnitDimensionless)
+	FundsLowBalance = stats.Int64("funds/low_balance", "1 if a tracked balance is below its alert threshold, otherwise 0", stats.UnitDimensionless)
+	// storage deals
+	DealsByCheckpoint           = stats.Int64("deals/by_checkpoint", "Number of deals at each checkpoint", stats.UnitDimensionless)
+	DealProposalsAccepted       = stats.Int64("deals/proposals_accepted_count", "Counter of deal proposals accepted", stats.UnitDimensionless)
+	DealProposalsRejected       = stats.Int64("deals/proposals_rejected_count", "Counter of deal proposals rejected", stats.UnitDimensionless)
+	TransferBytesReceived       = stats.Int64("transfer/bytes_received", "Counter of deal data bytes received", stats.UnitBytes)
+	TransferThroughput          = stats.Float64("transfer/throughput_bytes_per_second", "Average throughput of completed deal data transfers", stats.UnitDimensionless)
+	TransferStallCount          = stats.Int64("transfer/stall_count", "Counter of deal data transfers that stalled", stats.UnitDimensionless)
+	TransferRetryCount          = stats.Int64("transfer/retry_count", "Counter of deal data transfers retried after a connection error", stats.UnitDimensionless)
+	PublishBatchSize            = stats.Int64("publish/batch_size", "Number of deals in each publish deals message", stats.UnitDimensionless)
+	PublishFailedCount          = stats.Int64("publish/failed_count", "Counter of publish deals messages that failed to send", stats.UnitDimensionless)
+	IndexerPendingAnnouncements = stats.Int64("indexer/pending_announcements", "Number of deal announcements waiting to be retried", stats.UnitDimensionless)
+	// db
+	DBQueryDuration  = stats.Float64("db/query_duration_ms", "Duration of database queries", stats.UnitMilliseconds)
+	DBSlowQueryCount = stats.Int64("db/slow_query_count", "Counter of database queries that took longer than the slow query threshold", stats.UnitDimensionless)
+	// sealing priority
+	SealingDealsAtRisk = stats.Int64("sealing/deals_at_risk", "Number of deals whose sector is projected to finish sealing after the deal start epoch", stats.UnitDimensionless)
 	// graphsync
 	GraphsyncRequestQueuedCount                 = stats.Int64("graphsync/request_queued_count", "Counter of Graphsync requests queued", stats.UnitDimensionless)
 	GraphsyncRequestQueuedPaidCount             = stats.Int64("graphsync/request_queued_paid_count", "Counter of Graphsync paid requests queued", stats.UnitDimensionless)
@@ -217,6 +300,80 @@ var (
 		Measure:     HttpPieceByCid500ResponseCount,
 		Aggregation: view.Count(),
 	}
+	HttpAuthFailureCountView = &view.View{
+		Measure:     HttpAuthFailureCount,
+		Aggregation: view.Count(),
+	}
+	HttpAuthBytesSentView = &view.View{
+		Measure:     HttpAuthBytesSent,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{HttpAuthID},
+	}
+	HttpCacheHitCountView = &view.View{
+		Measure:     HttpCacheHitCount,
+		Aggregation: view.Count(),
+	}
+	HttpCacheMissCountView = &view.View{
+		Measure:     HttpCacheMissCount,
+		Aggregation: view.Count(),
+	}
+	HttpCacheBytesView = &view.View{
+		Measure:     HttpCacheBytes,
+		Aggregation: view.LastValue(),
+	}
+	HttpLimitedCountView = &view.View{
+		Measure:     HttpLimitedCount,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{HttpLimitReason},
+	}
+	HttpResponseCountView = &view.View{
+		Measure:     HttpResponseCount,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{HttpEndpoint, HttpStatus},
+	}
+	HttpBytesSentView = &view.View{
+		Measure:     HttpBytesSent,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{HttpEndpoint},
+	}
+	HttpFleetForwardedCountView = &view.View{
+		Measure:     HttpFleetForwardedCount,
+		Aggregation: view.Count(),
+	}
+	HttpFleetForwardErrorCountView = &view.View{
+		Measure:     HttpFleetForwardErrorCount,
+		Aggregation: view.Count(),
+	}
+	HttpDeniedCountView = &view.View{
+		Measure:     HttpDeniedCount,
+		Aggregation: view.Count(),
+	}
+	HttpDenylistEntriesView = &view.View{
+		Measure:     HttpDenylistEntries,
+		Aggregation: view.LastValue(),
+	}
+	HttpPolicyRejectedCountView = &view.View{
+		Measure:     HttpPolicyRejectedCount,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{RetrievalPolicyRejectReason},
+	}
+
+	// graphql
+	GraphqlRequestCountView = &view.View{
+		Measure:     GraphqlRequestCount,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{GraphqlField},
+	}
+	GraphqlRequestDurationView = &view.View{
+		Measure:     GraphqlRequestDuration,
+		Aggregation: defaultMillisecondsDistribution,
+		TagKeys:     []tag.Key{GraphqlField},
+	}
+	GraphqlRequestErrorCountView = &view.View{
+		Measure:     GraphqlRequestErrorCount,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{GraphqlField},
+	}
 
 	// bitswap
 	BitswapRblsGetRequestCountView = &view.View{
@@ -259,6 +416,139 @@ var (
 		Measure:     BitswapRblsBytesSentCount,
 		Aggregation: view.Sum(),
 	}
+	BitswapRblsGetDurationView = &view.View{
+		Measure:     BitswapRblsGetDuration,
+		Aggregation: defaultMillisecondsDistribution,
+	}
+	BitswapBlockCacheHitCountView = &view.View{
+		Measure:     BitswapBlockCacheHitCount,
+		Aggregation: view.Count(),
+	}
+	BitswapBlockCacheMissCountView = &view.View{
+		Measure:     BitswapBlockCacheMissCount,
+		Aggregation: view.Count(),
+	}
+	BitswapPrefetchCountView = &view.View{
+		Measure:     BitswapPrefetchCount,
+		Aggregation: view.Count(),
+	}
+	BitswapPeersView = &view.View{
+		Measure:     BitswapPeers,
+		Aggregation: view.LastValue(),
+	}
+	BitswapWantlistSizeView = &view.View{
+		Measure:     BitswapWantlistSize,
+		Aggregation: view.LastValue(),
+	}
+	BitswapBlocksServedCountView = &view.View{
+		Measure:     BitswapBlocksServedCount,
+		Aggregation: view.Sum(),
+	}
+	BitswapBytesServedCountView = &view.View{
+		Measure:     BitswapBytesServedCount,
+		Aggregation: view.Sum(),
+	}
+	BitswapThrottledCountView = &view.View{
+		Measure:     BitswapThrottledCount,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{BitswapThrottleReason},
+	}
+	BitswapPolicyRejectedCountView = &view.View{
+		Measure:     BitswapPolicyRejectedCount,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{RetrievalPolicyRejectReason},
+	}
+	BitswapThrottledPeersView = &view.View{
+		Measure:     BitswapThrottledPeers,
+		Aggregation: view.LastValue(),
+	}
+
+	// retrieval
+	MultihashLookupCacheHitCountView = &view.View{
+		Measure:     MultihashLookupCacheHitCount,
+		Aggregation: view.Count(),
+	}
+	MultihashLookupCacheMissCountView = &view.View{
+		Measure:     MultihashLookupCacheMissCount,
+		Aggregation: view.Count(),
+	}
+
+	// funds
+	FundsBalanceView = &view.View{
+		Measure:     FundsBalance,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{BalanceKind, Wallet},
+	}
+	FundsLowBalanceView = &view.View{
+		Measure:     FundsLowBalance,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{BalanceKind, Wallet},
+	}
+	SealingDealsAtRiskView = &view.View{
+		Measure:     SealingDealsAtRisk,
+		Aggregation: view.LastValue(),
+	}
+
+	// storage deals
+	DealsByCheckpointView = &view.View{
+		Measure:     DealsByCheckpoint,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{DealCheckpoint},
+	}
+	DealProposalsAcceptedView = &view.View{
+		Measure:     DealProposalsAccepted,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{DealRule},
+	}
+	DealProposalsRejectedView = &view.View{
+		Measure:     DealProposalsRejected,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{DealRule},
+	}
+	TransferBytesReceivedView = &view.View{
+		Measure:     TransferBytesReceived,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{TransferType, TransferHost},
+	}
+	TransferThroughputView = &view.View{
+		Measure:     TransferThroughput,
+		Aggregation: transferThroughputDistribution,
+		TagKeys:     []tag.Key{TransferType, TransferHost},
+	}
+	TransferStallCountView = &view.View{
+		Measure:     TransferStallCount,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{TransferType, TransferHost},
+	}
+	TransferRetryCountView = &view.View{
+		Measure:     TransferRetryCount,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{TransferType, TransferHost},
+	}
+	PublishBatchSizeView = &view.View{
+		Measure:     PublishBatchSize,
+		Aggregation: dealBatchSizeDistribution,
+	}
+	PublishFailedCountView = &view.View{
+		Measure:     PublishFailedCount,
+		Aggregation: view.Count(),
+	}
+	IndexerPendingAnnouncementsView = &view.View{
+		Measure:     IndexerPendingAnnouncements,
+		Aggregation: view.LastValue(),
+	}
+
+	// db
+	DBQueryDurationView = &view.View{
+		Measure:     DBQueryDuration,
+		Aggregation: defaultMillisecondsDistribution,
+		TagKeys:     []tag.Key{DBQuery},
+	}
+	DBSlowQueryCountView = &view.View{
+		Measure:     DBSlowQueryCount,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{DBQuery},
+	}
 
 	// graphsync
 	GraphsyncRequestQueuedCountView = &view.View{
@@ -487,6 +777,11 @@ var (
 		Aggregation: defaultMillisecondsDistribution,
 		TagKeys:     []tag.Key{APIInterface, Endpoint},
 	}
+	APIRequestErrorsView = &view.View{
+		Measure:     APIRequestErrors,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{APIInterface, Endpoint},
+	}
 	VMFlushCopyDurationView = &view.View{
 		Measure:     VMFlushCopyDuration,
 		Aggregation: view.Sum(),
@@ -624,6 +919,10 @@ var DefaultViews = func() []*view.View {
 		InfoView,
 		PeerCountView,
 		APIRequestDurationView,
+		APIRequestErrorsView,
+		GraphqlRequestCountView,
+		GraphqlRequestDurationView,
+		GraphqlRequestErrorCountView,
 		HttpPayloadByCidRequestCountView,
 		HttpPayloadByCidRequestDurationView,
 		HttpPayloadByCid200ResponseCountView,
@@ -636,6 +935,19 @@ var DefaultViews = func() []*view.View {
 		HttpPieceByCid400ResponseCountView,
 		HttpPieceByCid404ResponseCountView,
 		HttpPieceByCid500ResponseCountView,
+		HttpAuthFailureCountView,
+		HttpAuthBytesSentView,
+		HttpCacheHitCountView,
+		HttpCacheMissCountView,
+		HttpCacheBytesView,
+		HttpLimitedCountView,
+		HttpResponseCountView,
+		HttpBytesSentView,
+		HttpFleetForwardedCountView,
+		HttpFleetForwardErrorCountView,
+		HttpDeniedCountView,
+		HttpDenylistEntriesView,
+		HttpPolicyRejectedCountView,
 		BitswapRblsGetRequestCountView,
 		BitswapRblsGetSuccessResponseCountView,
 		BitswapRblsGetFailResponseCountView,
@@ -646,6 +958,34 @@ var DefaultViews = func() []*view.View {
 		BitswapRblsHasSuccessResponseCountView,
 		BitswapRblsHasFailResponseCountView,
 		BitswapRblsBytesSentCountView,
+		BitswapRblsGetDurationView,
+		BitswapBlockCacheHitCountView,
+		BitswapBlockCacheMissCountView,
+		BitswapPrefetchCountView,
+		BitswapPeersView,
+		BitswapWantlistSizeView,
+		BitswapBlocksServedCountView,
+		BitswapBytesServedCountView,
+		BitswapThrottledCountView,
+		BitswapThrottledPeersView,
+		BitswapPolicyRejectedCountView,
+		MultihashLookupCacheHitCountView,
+		MultihashLookupCacheMissCountView,
+		FundsBalanceView,
+		FundsLowBalanceView,
+		SealingDealsAtRiskView,
+		DealsByCheckpointView,
+		DealProposalsAcceptedView,
+		DealProposalsRejectedView,
+		TransferBytesReceivedView,
+		TransferThroughputView,
+		TransferStallCountView,
+		TransferRetryCountView,
+		PublishBatchSizeView,
+		PublishFailedCountView,
+		IndexerPendingAnnouncementsView,
+		DBQueryDurationView,
+		DBSlowQueryCountView,
 		GraphsyncRequestQueuedCountView,
 		GraphsyncRequestQueuedPaidCountView,
 		GraphsyncRequestQueuedUnpaidCountView,
@@ -4,6 +4,7 @@ import (
 	"context"
 	"reflect"
 
+	"go.opencensus.io/stats"
 	"go.opencensus.io/tag"
 
 	"github.com/filecoin-project/boost/api"
@@ -34,7 +35,14 @@ func proxy(in interface{}, outstr interface{}) {
 				defer stop()
 				// pass tagged ctx back into function call
 				args[0] = reflect.ValueOf(ctx)
-				return fn.Call(args)
+				results = fn.Call(args)
+				// the last return value of each API method is an error
+				if len(results) > 0 {
+					if err, ok := results[len(results)-1].Interface().(error); ok && err != nil {
+						stats.Record(ctx, metrics.APIRequestErrors.M(1))
+					}
+				}
+				return results
 			}))
 		}
 	}
@@ -10,12 +10,15 @@ import (
 	"github.com/filecoin-project/boost/api"
 	"github.com/filecoin-project/boost/build"
 	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/eventbus"
 	"github.com/filecoin-project/boost/fundmanager"
 	"github.com/filecoin-project/boost/gql"
+	"github.com/filecoin-project/boost/health"
 	"github.com/filecoin-project/boost/indexprovider"
 	lotus_dealfilter "github.com/filecoin-project/boost/markets/dealfilter"
 	"github.com/filecoin-project/boost/markets/idxprov"
 	"github.com/filecoin-project/boost/markets/retrievaladapter"
+	"github.com/filecoin-project/boost/markets/sectoraccessor"
 	lotus_storageadapter "github.com/filecoin-project/boost/markets/storageadapter"
 	"github.com/filecoin-project/boost/node/config"
 	"github.com/filecoin-project/boost/node/impl"
@@ -24,15 +27,24 @@ import (
 	"github.com/filecoin-project/boost/node/modules"
 	"github.com/filecoin-project/boost/node/modules/dtypes"
 	"github.com/filecoin-project/boost/node/repo"
+	"github.com/filecoin-project/boost/piecedoctor"
+	"github.com/filecoin-project/boost/pieceremover"
 	"github.com/filecoin-project/boost/protocolproxy"
+	brm "github.com/filecoin-project/boost/retrievalmarket/lib"
 	"github.com/filecoin-project/boost/retrievalmarket/lp2pimpl"
+	"github.com/filecoin-project/boost/retrievalmarket/payments"
+	"github.com/filecoin-project/boost/retrievalmarket/quota"
+	"github.com/filecoin-project/boost/retrievalmarket/rtvlevents"
 	"github.com/filecoin-project/boost/retrievalmarket/rtvllog"
 	"github.com/filecoin-project/boost/retrievalmarket/server"
+	"github.com/filecoin-project/boost/storage/flatstore"
 	"github.com/filecoin-project/boost/storagemanager"
 	"github.com/filecoin-project/boost/storagemarket"
 	"github.com/filecoin-project/boost/storagemarket/dealfilter"
 	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
 	smtypes "github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/unsealedcopy"
+	"github.com/filecoin-project/boost/webhooks"
 	"github.com/filecoin-project/boostd-data/shared/tracing"
 	"github.com/filecoin-project/dagstore"
 	"github.com/filecoin-project/go-address"
@@ -142,23 +154,34 @@ const (
 	HandlePaymentChannelManagerKey
 
 	// miner
+	HandleSlowQueryLogKey
 	GetParamsKey
 	HandleMigrateProviderFundsKey
 	HandleDealsKey
 	HandleCreateRetrievalTablesKey
 	HandleSetShardSelector
 	HandleSetRetrievalAskGetter
+	HandleSetRetrievalAskKey
 	HandleRetrievalEventsKey
+	HandleRetrievalPaymentsKey
 	HandleRetrievalKey
+	HandleNativeRetrievalQueriesKey
 	HandleRetrievalTransportsKey
+	HandleRetrievalAskKey
 	HandleProtocolProxyKey
 	RunSectorServiceKey
 
 	// boost should be started after legacy markets (HandleDealsKey)
 	HandleBoostDealsKey
 	HandleContractDealsKey
+	HandleLegacyDealIngestKey
 	HandleProposalLogCleanerKey
+	HandleReplicationKey
 	HandleOnlineBackupMgrKey
+	HandleFundsTopUpKey
+	HandleFundsAlertsKey
+	HandleAlertsKey
+	HandleProfilingWatchdogKey
 
 	// daemon
 	ExtractApiKey
@@ -417,10 +440,12 @@ var BoostNode = Options(
 	Override(new(*modules.LogSqlDB), modules.NewLogsSqlDB),
 	Override(new(*modules.RetrievalSqlDB), modules.NewRetrievalSqlDB),
 	Override(HandleCreateRetrievalTablesKey, modules.CreateRetrievalTables),
-	Override(new(*db.DealsDB), modules.NewDealsDB),
-	Override(new(*db.LogsDB), modules.NewLogsDB),
+	Override(new(*db.AuditLogDB), modules.NewAuditLogDB),
+	Override(new(*db.WebhooksDB), modules.NewWebhooksDB),
+	Override(new(*db.PendingAnnouncementsDB), modules.NewPendingAnnouncementsDB),
+	Override(new(*db.RemovedAnnouncementsDB), modules.NewRemovedAnnouncementsDB),
 	Override(new(*db.ProposalLogsDB), modules.NewProposalLogsDB),
-	Override(new(*db.FundsDB), modules.NewFundsDB),
+	Override(new(*db.RetrievalPaymentsDB), modules.NewRetrievalPaymentsDB),
 	Override(new(*rtvllog.RetrievalLogDB), modules.NewRetrievalLogDB),
 )
 
@@ -450,6 +475,17 @@ func ConfigBoost(cfg *config.Boost) Option {
 	if err != nil {
 		return Error(fmt.Errorf("failed to parse cfg.Wallets.PublishStorageDeals: %s; err: %w", cfg.Wallets.PublishStorageDeals, err))
 	}
+	walletsPSD := []address.Address{walletPSD}
+	for _, w := range cfg.Wallets.AdditionalPublishStorageDeals {
+		addr, err := address.NewFromString(w)
+		if err != nil {
+			return Error(fmt.Errorf("failed to parse cfg.Wallets.AdditionalPublishStorageDeals: %s; err: %w", w, err))
+		}
+		walletsPSD = append(walletsPSD, addr)
+	}
+	if err := lotus_storageadapter.ValidatePublishWalletStrategy(cfg.Wallets.PublishStorageDealsStrategy); err != nil {
+		return Error(fmt.Errorf("invalid cfg.Wallets.PublishStorageDealsStrategy: %w", err))
+	}
 	walletMiner, err := address.NewFromString(cfg.Wallets.Miner)
 	if err != nil {
 		return Error(fmt.Errorf("failed to parse cfg.Wallets.Miner: %s; err: %w", cfg.Wallets.Miner, err))
@@ -481,6 +517,12 @@ func ConfigBoost(cfg *config.Boost) Option {
 			PubMsgBalMin: abi.TokenAmount(cfg.LotusFees.MaxPublishDealsFee),
 		})),
 
+		Override(HandleFundsTopUpKey, modules.HandleFundsTopUp(cfg)),
+		Override(HandleFundsAlertsKey, modules.HandleFundsAlerts(cfg)),
+		Override(HandleAlertsKey, modules.HandleAlerts(cfg)),
+		Override(HandleSlowQueryLogKey, modules.HandleSlowQueryLog(cfg)),
+		Override(HandleProfilingWatchdogKey, modules.HandleProfilingWatchdog(cfg)),
+
 		Override(new(*storagemanager.StorageManager), storagemanager.New(storagemanager.Config{
 			MaxStagingDealsBytes:          uint64(cfg.Dealmaking.MaxStagingDealsBytes),
 			MaxStagingDealsPercentPerHost: uint64(cfg.Dealmaking.MaxStagingDealsPercentPerHost),
@@ -495,13 +537,28 @@ func ConfigBoost(cfg *config.Boost) Option {
 		Override(new(sealingpipeline.API), From(new(lotus_modules.MinerStorageService))),
 
 		Override(new(*indexprovider.Wrapper), indexprovider.NewWrapper(cfg)),
+		Override(new(*piecedoctor.Doctor), modules.NewPieceDoctor(cfg)),
+		Override(new(unsealedcopy.MinerAPI), From(new(lotus_modules.MinerStorageService))),
+		Override(new(*unsealedcopy.Manager), modules.NewUnsealedCopyManager),
+		Override(new(*pieceremover.Remover), modules.NewPieceRemover),
+		Override(new(*health.Checker), modules.NewHealthChecker),
 
 		Override(new(*storagemarket.ChainDealManager), modules.NewChainDealManager),
-		Override(new(smtypes.CommpCalculator), From(new(lotus_modules.MinerStorageService))),
-
+		Override(new(smtypes.CommpCalculator), modules.NewCommpCalculator(cfg)),
+		Override(new(smtypes.PieceAdder), modules.NewPieceAdder(cfg)),
+		Override(new(smtypes.SealerAdapter), modules.NewSealerAdapter(cfg)),
+
+		Override(new(*db.AESCipher), modules.NewDealsDBCipher(cfg)),
+		Override(new(*db.DealChangeLogDB), modules.NewDealChangeLogDB),
+		Override(new(*db.DealsDB), modules.NewDealsDB(cfg)),
+		Override(new(*db.FundsDB), modules.NewFundsDB(cfg)),
+		Override(new(*db.StorageDB), modules.NewStorageDB(cfg)),
+		Override(new(*db.LogsDB), modules.NewLogsDB(cfg)),
+		Override(HandleReplicationKey, modules.HandleReplication(cfg)),
 		Override(new(*storagemarket.Provider), modules.NewStorageMarketProvider(walletMiner, cfg)),
 
 		// GraphQL server
+		Override(new(*webhooks.Dispatcher), modules.NewWebhookDispatcher),
 		Override(new(*gql.Server), modules.NewGraphqlServer(cfg)),
 
 		// Tracing
@@ -509,14 +566,18 @@ func ConfigBoost(cfg *config.Boost) Option {
 
 		// Address selector
 		Override(new(*ctladdr.AddressSelector), lotus_modules.AddressSelector(&lotus_config.MinerAddressConfig{
-			DealPublishControl: []string{cfg.Wallets.PublishStorageDeals},
+			DealPublishControl: append([]string{cfg.Wallets.PublishStorageDeals}, cfg.Wallets.AdditionalPublishStorageDeals...),
 		})),
 
 		// Lotus Markets
 		Override(new(lotus_dtypes.ProviderTransferNetwork), modules.NewProviderTransferNetwork),
 		Override(new(*modules.ProxyAskGetter), modules.NewAskGetter),
 		Override(new(server.AskGetter), From(new(*modules.ProxyAskGetter))),
-		Override(new(*server.GraphsyncUnpaidRetrieval), modules.Graphsync(cfg.LotusDealmaking.SimultaneousTransfersForStorage, cfg.LotusDealmaking.SimultaneousTransfersForStoragePerClient, cfg.LotusDealmaking.SimultaneousTransfersForRetrieval)),
+		Override(new(*server.RetrievalAsk), modules.NewRetrievalAsk(cfg)),
+		Override(new(*quota.Quotas), modules.NewRetrievalQuotas(cfg)),
+		Override(new(*eventbus.Bus), modules.NewEventBus(cfg)),
+		Override(new(*rtvlevents.Publisher), modules.NewRetrievalEventPublisher(cfg)),
+		Override(new(*server.GraphsyncUnpaidRetrieval), modules.Graphsync(cfg.LotusDealmaking.SimultaneousTransfersForStorage, cfg.LotusDealmaking.SimultaneousTransfersForStoragePerClient, cfg.LotusDealmaking.SimultaneousTransfersForRetrieval, cfg.Dealmaking.NativeGraphsyncRetrievals)),
 		Override(new(lotus_dtypes.StagingGraphsync), From(new(*server.GraphsyncUnpaidRetrieval))),
 		Override(new(lotus_dtypes.ProviderPieceStore), lotus_modules.NewProviderPieceStore),
 
@@ -531,26 +592,41 @@ func ConfigBoost(cfg *config.Boost) Option {
 		})),
 
 		// DAG Store
-		Override(new(mdagstore.MinerAPI), lotus_modules.NewMinerAPI(cfg.DAGStore)),
+		Override(new(*flatstore.Store), modules.NewFlatStore(cfg)),
+		Override(new(mdagstore.MinerAPI), modules.NewMinerAPI(cfg)),
 		Override(DAGStoreKey, lotus_modules.DAGStore(cfg.DAGStore)),
 		Override(new(dagstore.Interface), From(new(*dagstore.DAGStore))),
 		Override(new(stores.DAGStoreWrapper), From(new(*mdagstore.Wrapper))),
 		Override(new(*modules.ShardSelector), modules.NewShardSelector),
+		Override(new(*brm.MultihashLookupCache), modules.NewMultihashLookupCache(cfg)),
 		Override(new(dtypes.IndexBackedBlockstore), modules.NewIndexBackedBlockstore(cfg)),
-		Override(HandleSetShardSelector, modules.SetShardSelectorFunc),
+		Override(new(server.PieceDirectory), modules.NewPieceDirectory),
+		Override(HandleSetShardSelector, modules.SetShardSelectorFunc(cfg)),
 
 		// Lotus Markets (retrieval)
-		Override(new(mdagstore.SectorAccessor), modules.NewSectorAccessor(cfg)),
+		Override(new(*sectoraccessor.UnsealQueue), modules.NewUnsealQueue(cfg)),
+		Override(new(mdagstore.SectorAccessor), From(new(*sectoraccessor.UnsealQueue))),
 		Override(new(retrievalmarket.SectorAccessor), From(new(mdagstore.SectorAccessor))),
 		Override(new(retrievalmarket.RetrievalProviderNode), retrievaladapter.NewRetrievalProviderNode),
 		Override(new(rmnet.RetrievalMarketNetwork), lotus_modules.RetrievalNetwork),
 		Override(new(retrievalmarket.RetrievalProvider), lotus_modules.RetrievalProvider),
 		Override(HandleSetRetrievalAskGetter, modules.SetAskGetter),
+		Override(HandleSetRetrievalAskKey, modules.HandleSetRetrievalAsk(cfg)),
 		Override(HandleRetrievalEventsKey, modules.HandleRetrievalGraphsyncUpdates(time.Duration(cfg.Dealmaking.RetrievalLogDuration), time.Duration(cfg.Dealmaking.StalledRetrievalTimeout))),
+		Override(new(*payments.Manager), modules.NewRetrievalPayments),
+		Override(HandleRetrievalPaymentsKey, modules.HandleRetrievalPayments),
 		Override(HandleRetrievalKey, lotus_modules.HandleRetrieval),
+		// When serving graphsync retrievals natively, boost answers retrieval
+		// queries itself, and the legacy retrieval provider only serves paid
+		// retrievals
+		If(cfg.Dealmaking.NativeGraphsyncRetrievals,
+			Override(HandleNativeRetrievalQueriesKey, modules.HandleNativeRetrievalQueries),
+		),
 		Override(new(*lp2pimpl.TransportsListener), modules.NewTransportsListener(cfg)),
 		Override(new(*protocolproxy.ProtocolProxy), modules.NewProtocolProxy(cfg)),
 		Override(HandleRetrievalTransportsKey, modules.HandleRetrievalTransports),
+		Override(new(*lp2pimpl.AskListener), modules.NewAskListener),
+		Override(HandleRetrievalAskKey, modules.HandleRetrievalAsk),
 		Override(HandleProtocolProxyKey, modules.HandleProtocolProxy),
 		Override(new(idxprov.MeshCreator), idxprov.NewMeshCreator),
 		Override(new(provider.Interface), modules.IndexProvider(cfg.IndexProvider)),
@@ -565,12 +641,13 @@ func ConfigBoost(cfg *config.Boost) Option {
 		Override(HandleDealsKey, modules.HandleLegacyDeals),
 		Override(HandleBoostDealsKey, modules.HandleBoostLibp2pDeals),
 		Override(HandleContractDealsKey, modules.HandleContractDeals(&cfg.ContractDeals)),
+		Override(HandleLegacyDealIngestKey, modules.HandleLegacyDealIngest(cfg)),
 		Override(HandleProposalLogCleanerKey, modules.HandleProposalLogCleaner(time.Duration(cfg.Dealmaking.DealProposalLogDuration))),
 
 		// Boost storage deal filter
-		Override(new(dtypes.StorageDealFilter), modules.BasicDealFilter(cfg.Dealmaking, nil)),
+		Override(new(dtypes.StorageDealDecider), modules.BasicDealFilter(cfg.Dealmaking, nil)),
 		If(cfg.Dealmaking.Filter != "",
-			Override(new(dtypes.StorageDealFilter), modules.BasicDealFilter(cfg.Dealmaking, dtypes.StorageDealFilter(dealfilter.CliStorageDealFilter(cfg.Dealmaking.Filter)))),
+			Override(new(dtypes.StorageDealDecider), modules.BasicDealFilter(cfg.Dealmaking, dtypes.StorageDealFilter(dealfilter.CliStorageDealFilter(cfg.Dealmaking.Filter)))),
 		),
 
 		// Lotus markets storage deal filter
@@ -581,8 +658,8 @@ func ConfigBoost(cfg *config.Boost) Option {
 
 		// Boost retrieval deal filter
 		Override(new(dtypes.RetrievalDealFilter), modules.RetrievalDealFilter(nil)),
-		If(cfg.Dealmaking.RetrievalFilter != "",
-			Override(new(dtypes.RetrievalDealFilter), modules.RetrievalDealFilter(dtypes.RetrievalDealFilter(dealfilter.CliRetrievalDealFilter(cfg.Dealmaking.RetrievalFilter)))),
+		If(cfg.Dealmaking.RetrievalFilter != "" || cfg.Dealmaking.RetrievalFilterEndpoint != "",
+			Override(new(dtypes.RetrievalDealFilter), modules.RetrievalDealFilter(modules.UserRetrievalDealFilter(cfg.Dealmaking))),
 		),
 
 		// Lotus markets retrieval deal filter
@@ -595,6 +672,11 @@ func ConfigBoost(cfg *config.Boost) Option {
 			Period:                  time.Duration(cfg.LotusDealmaking.PublishMsgPeriod),
 			MaxDealsPerMsg:          cfg.LotusDealmaking.MaxDealsPerPublishMsg,
 			StartEpochSealingBuffer: cfg.LotusDealmaking.StartEpochSealingBuffer,
+			Wallets:                 walletsPSD,
+			WalletStrategy:          cfg.Wallets.PublishStorageDealsStrategy,
+			MaxBaseFee:              abi.TokenAmount(cfg.LotusFees.PublishMaxBaseFee),
+			MaxFeeCap:               abi.TokenAmount(cfg.LotusFees.PublishMaxFeeCap),
+			MaxGasWait:              time.Duration(cfg.LotusFees.PublishMaxGasWait),
 		})),
 
 		Override(new(sealer.Unsealer), From(new(lotus_modules.MinerStorageService))),
@@ -645,6 +727,8 @@ func ConfigBoost(cfg *config.Boost) Option {
 		Override(new(dtypes.GetExpectedSealDurationFunc), modules.NewGetExpectedSealDurationFunc),
 		Override(new(dtypes.SetMaxDealStartDelayFunc), modules.NewSetMaxDealStartDelayFunc),
 		Override(new(dtypes.GetMaxDealStartDelayFunc), modules.NewGetMaxDealStartDelayFunc),
+		Override(new(dtypes.GetRetrievalPolicyFunc), modules.NewGetRetrievalPolicyFunc),
+		Override(new(dtypes.SetRetrievalPolicyFunc), modules.NewSetRetrievalPolicyFunc),
 	)
 }
 
@@ -62,8 +62,16 @@ func DefaultBoost() *Boost {
 			ParallelFetchLimit: 10,
 		},
 
+		Wallets: WalletsConfig{
+			AdditionalPublishStorageDeals: []string{},
+			PublishStorageDealsStrategy:   "round-robin",
+		},
+
 		Graphql: GraphqlConfig{
-			Port: 8080,
+			Port:            8080,
+			RateLimitBurst:  20,
+			MaxRequestBytes: 1 << 20,
+			MaxQueryDepth:   20,
 		},
 
 		Tracing: TracingConfig{
@@ -72,12 +80,148 @@ func DefaultBoost() *Boost {
 			ServiceName: "boostd",
 		},
 
+		Database: DatabaseConfig{
+			SlowQueryThreshold: Duration(time.Second),
+		},
+
+		Profiling: ProfilingConfig{
+			HeapThresholdBytes: 0,
+			GoroutineThreshold: 0,
+			CheckInterval:      Duration(10 * time.Second),
+			CPUProfileDuration: Duration(30 * time.Second),
+			Cooldown:           Duration(10 * time.Minute),
+			MaxCaptures:        10,
+		},
+
 		ContractDeals: ContractDealsConfig{
 			Enabled:            false,
 			AllowlistContracts: []string{},
 			From:               "0x0000000000000000000000000000000000000000",
 		},
 
+		Encryption: EncryptionConfig{
+			Enabled:    false,
+			KeyCommand: "",
+		},
+
+		Replication: ReplicationConfig{
+			Enabled:         false,
+			SinkURL:         "",
+			BatchSize:       100,
+			PollInterval:    Duration(time.Second),
+			RetentionPeriod: Duration(7 * 24 * time.Hour),
+			MaxSinkLag:      Duration(30 * 24 * time.Hour),
+		},
+
+		AnnouncePolicy: AnnouncePolicyConfig{
+			DefaultAction: "announce",
+			Rules:         []AnnouncePolicyRule{},
+		},
+
+		PieceDoctor: PieceDoctorConfig{
+			CheckInterval:  Duration(time.Hour),
+			PiecesPerCheck: 10,
+			BlocksPerPiece: 10,
+			AutoRepair:     false,
+		},
+
+		LegacyDealIngest: LegacyDealIngestConfig{
+			CheckInterval:      Duration(time.Hour),
+			MaxIndexesPerCheck: 5,
+		},
+
+		FlatStore: FlatStoreConfig{
+			Enabled:        false,
+			MaxBytes:       0,
+			EvictionPolicy: "lru",
+		},
+
+		RetrievalPolicy: RetrievalPolicyConfig{
+			DefaultAccess:  "free",
+			AllowedClients: []string{},
+			Rules:          []RetrievalPolicyRule{},
+		},
+
+		RetrievalAsk: RetrievalAskConfig{
+			SetOnStartup:            false,
+			PricePerByte:            types.MustParseFIL("0"),
+			UnsealPrice:             types.MustParseFIL("0"),
+			PaymentInterval:         1 << 20,
+			PaymentIntervalIncrease: 1 << 20,
+			FreeClients:             []string{},
+		},
+
+		RetrievalQuotas: RetrievalQuotaConfig{
+			MaxBytesPerDay:         0,
+			MaxBytesPerMonth:       0,
+			Action:                 "block",
+			ThrottleBytesPerSecond: 1 << 20,
+			Clients:                []RetrievalClientQuota{},
+		},
+
+		RetrievalEvents: RetrievalEventsConfig{
+			NATSSubject:      "boost.retrievals",
+			ProgressInterval: Duration(30 * time.Second),
+		},
+
+		EventBus: EventBusConfig{
+			DealTopic:      "boost.events.deals",
+			RetrievalTopic: "boost.events.retrievals",
+			PollInterval:   Duration(time.Second),
+		},
+
+		FundsTopUp: FundsTopUpConfig{
+			SourceWallet:     "",
+			CheckInterval:    Duration(5 * time.Minute),
+			EscrowThreshold:  types.MustParseFIL("0"),
+			EscrowTarget:     types.MustParseFIL("0"),
+			PledgeThreshold:  types.MustParseFIL("0"),
+			PledgeTarget:     types.MustParseFIL("0"),
+			MaxTopUp:         types.MustParseFIL("0"),
+			MaxPerDay:        types.MustParseFIL("0"),
+			MinSourceBalance: types.MustParseFIL("0"),
+			LandTimeout:      Duration(2 * time.Hour),
+		},
+
+		FundsAlerts: FundsAlertsConfig{
+			CheckInterval:       Duration(5 * time.Minute),
+			EscrowThreshold:     types.MustParseFIL("0"),
+			PublishMsgThreshold: types.MustParseFIL("0"),
+			CollateralThreshold: types.MustParseFIL("0"),
+		},
+		Alerts: AlertsConfig{
+			CheckInterval: Duration(time.Minute),
+			Rules:         []AlertRuleConfig{},
+		},
+		SectorPacking: SectorPackingConfig{
+			Policy:            "arrival",
+			BatchWindow:       Duration(time.Minute),
+			EndEpochTolerance: Duration(30 * 24 * time.Hour),
+		},
+		Backpressure: BackpressureConfig{
+			Enabled:          false,
+			SampleInterval:   Duration(10 * time.Minute),
+			ThroughputWindow: Duration(24 * time.Hour),
+			TargetBacklog:    Duration(24 * time.Hour),
+			BurstDuration:    Duration(time.Hour),
+			MinSectorsPerDay: 1,
+		},
+		SealingPriority: SealingPriorityConfig{
+			Enabled:              false,
+			CheckInterval:        Duration(5 * time.Minute),
+			ExpectedSealDuration: Duration(6 * time.Hour),
+			PriorityMargin:       Duration(2 * time.Hour),
+		},
+		Sealer: SealerConfig{
+			Type: "lotus",
+		},
+		SectorSelection: SectorSelectionConfig{
+			Preference:          "any",
+			MaxSnapPieceSize:    0,
+			MinLifetimeMargin:   Duration(7 * 24 * time.Hour),
+			MaxDurationMismatch: 0,
+		},
+
 		Dealmaking: DealmakingConfig{
 			ConsiderOnlineStorageDeals:     true,
 			ConsiderOfflineStorageDeals:    true,
@@ -97,6 +241,8 @@ func DefaultBoost() *Boost {
 			RetrievalLogDuration:    Duration(time.Hour * 24),
 			StalledRetrievalTimeout: Duration(time.Minute * 30),
 
+			NativeGraphsyncRetrievals: false,
+
 			RetrievalPricing: &lotus_config.RetrievalPricing{
 				Strategy: RetrievalPricingDefaultMode,
 				Default: &lotus_config.RetrievalPricingDefault{
@@ -111,7 +257,24 @@ func DefaultBoost() *Boost {
 			BlockstoreCacheMaxShards: 20, // Match default simultaneous retrievals
 			BlockstoreCacheExpiry:    Duration(30 * time.Second),
 
+			UnsealQueueMaxConcurrent: 5,
+
+			MultihashLookupCacheSize:   100_000,
+			MultihashLookupCacheExpiry: Duration(10 * time.Minute),
+
 			IsUnsealedCacheExpiry: Duration(5 * time.Minute),
+			UnsealedCopyPolicy:    "client",
+			DeduplicatePieces:     true,
+			StreamingAddPiece:     false,
+
+			AdvertisementRemovalCheckInterval:       Duration(time.Hour),
+			RemoveAdvertisementsWithoutUnsealedCopy: true,
+			DirectAnnounceURLs:                      []string{},
+			MaxConcurrentEntriesChains:              2,
+			IndexerVerifyURL:                        "https://cid.contact",
+			IndexerVerifyInterval:                   Duration(0),
+			IndexerVerifySampleSize:                 5,
+			IndexerVerifyMaxDeals:                   100,
 
 			MaxTransferDuration: Duration(24 * 3600 * time.Second),
 
@@ -160,6 +323,9 @@ func DefaultBoost() *Boost {
 		LotusFees: FeeConfig{
 			MaxPublishDealsFee:     types.MustParseFIL("0.05"),
 			MaxMarketBalanceAddFee: types.MustParseFIL("0.007"),
+			PublishMaxBaseFee:      types.MustParseFIL("0"),
+			PublishMaxFeeCap:       types.MustParseFIL("0"),
+			PublishMaxGasWait:      Duration(0),
 		},
 
 		DAGStore: lotus_config.DAGStoreConfig{
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"sort"
+	"time"
 
 	"github.com/filecoin-project/boost/node/impl/backupmgr"
 	"github.com/multiformats/go-multihash"
@@ -15,20 +16,40 @@ import (
 	"github.com/filecoin-project/go-fil-markets/stores"
 
 	"github.com/filecoin-project/boost/api"
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/dealbook"
+	"github.com/filecoin-project/boost/fundmanager"
 	"github.com/filecoin-project/boost/gql"
+	"github.com/filecoin-project/boost/health"
 	"github.com/filecoin-project/boost/indexprovider"
+	"github.com/filecoin-project/boost/markets/sectoraccessor"
 	"github.com/filecoin-project/boost/markets/storageadapter"
 	"github.com/filecoin-project/boost/node/modules/dtypes"
+	"github.com/filecoin-project/boost/piecedoctor"
+	"github.com/filecoin-project/boost/pieceremover"
+	"github.com/filecoin-project/boost/retrievalmarket/payments"
+	"github.com/filecoin-project/boost/retrievalmarket/quota"
+	"github.com/filecoin-project/boost/retrievalmarket/rtvlevents"
+	"github.com/filecoin-project/boost/retrievalmarket/rtvllog"
 	retmarket "github.com/filecoin-project/boost/retrievalmarket/server"
+	"github.com/filecoin-project/boost/retrievalpolicy"
 	"github.com/filecoin-project/boost/storagemarket"
+	"github.com/filecoin-project/boost/storagemarket/backpressure"
+	"github.com/filecoin-project/boost/storagemarket/funds"
 	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
+	"github.com/filecoin-project/boost/storagemarket/sealingpriority"
+	"github.com/filecoin-project/boost/storagemarket/sectorpacking"
 	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/unsealedcopy"
 	"github.com/filecoin-project/boostd-data/shared/tracing"
 	"github.com/filecoin-project/dagstore"
 	"github.com/filecoin-project/dagstore/shard"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	lotus_storagemarket "github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/go-jsonrpc/auth"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin/v8/paych"
+	"github.com/filecoin-project/go-state-types/crypto"
 	lapi "github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/gateway"
 	mktsdagstore "github.com/filecoin-project/lotus/markets/dagstore"
@@ -60,6 +81,12 @@ type BoostAPI struct {
 	// Boost
 	StorageProvider *storagemarket.Provider
 	IndexProvider   *indexprovider.Wrapper
+	DealsDB         *db.DealsDB
+	FundManager     *fundmanager.FundManager
+	PieceDoctor     *piecedoctor.Doctor
+	UnsealedCopies  *unsealedcopy.Manager
+	PieceRemover    *pieceremover.Remover
+	Health          *health.Checker
 
 	// Legacy Lotus
 	LegacyStorageProvider lotus_storagemarket.StorageProvider
@@ -76,6 +103,16 @@ type BoostAPI struct {
 	// Graphsync Unpaid Retrieval
 	GraphsyncUnpaidRetrieval *retmarket.GraphsyncUnpaidRetrieval
 
+	// Payments for retrievals
+	RetrievalPayments *payments.Manager
+
+	// Retrieval logs
+	RetrievalLogDB  *rtvllog.RetrievalLogDB
+	RetrievalEvents *rtvlevents.Publisher
+
+	// Per-client retrieval quotas
+	RetrievalQuotas *quota.Quotas
+
 	// Sealing Pipeline API
 	Sps sealingpipeline.API
 
@@ -107,6 +144,8 @@ type BoostAPI struct {
 	GetSealingConfigFunc                        lotus_dtypes.GetSealingConfigFunc                        `optional:"true"`
 	GetExpectedSealDurationFunc                 lotus_dtypes.GetExpectedSealDurationFunc                 `optional:"true"`
 	SetExpectedSealDurationFunc                 lotus_dtypes.SetExpectedSealDurationFunc                 `optional:"true"`
+	GetRetrievalPolicyFunc                      dtypes.GetRetrievalPolicyFunc                            `optional:"true"`
+	SetRetrievalPolicyFunc                      dtypes.SetRetrievalPolicyFunc                            `optional:"true"`
 }
 
 var _ api.Boost = &BoostAPI{}
@@ -139,6 +178,48 @@ func (sm *BoostAPI) BoostDeal(ctx context.Context, dealUuid uuid.UUID) (*types.P
 	return sm.StorageProvider.Deal(ctx, dealUuid)
 }
 
+func (sm *BoostAPI) BoostDeals(ctx context.Context, filter api.DealsFilter) ([]*types.ProviderDealState, error) {
+	opts := &db.FilterOptions{IsVerified: filter.IsVerified}
+	if filter.Checkpoint != "" {
+		opts.Checkpoint = &filter.Checkpoint
+	}
+	if filter.ClientAddress != "" {
+		opts.ClientAddress = &filter.ClientAddress
+	}
+	if filter.PieceCid != "" {
+		opts.PieceCID = &filter.PieceCid
+	}
+	if filter.ErrContains != "" {
+		opts.ErrContains = &filter.ErrContains
+	}
+	if !filter.CreatedAfter.IsZero() {
+		opts.CreatedAfter = &filter.CreatedAfter
+	}
+	if !filter.CreatedBefore.IsZero() {
+		opts.CreatedBefore = &filter.CreatedBefore
+	}
+
+	deals, err := sm.DealsDB.ListPage(ctx, filter.Query, opts, db.SortOptions{}, nil, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// The client's signature and the transfer parameters (which may include
+	// auth headers) are only returned to callers with admin permission, in
+	// the same way that they are encrypted at rest
+	if !auth.HasPerm(ctx, nil, api.PermAdmin) {
+		for _, deal := range deals {
+			deal.ClientDealProposal.ClientSignature = crypto.Signature{}
+			deal.Transfer.Params = nil
+		}
+	}
+	return deals, nil
+}
+
+func (sm *BoostAPI) BoostDealsAll(ctx context.Context, filter dealbook.Filter, offset int, limit int) (*dealbook.Page, error) {
+	return dealbook.List(ctx, sm.DealsDB, sm.LegacyStorageProvider, filter, offset, limit)
+}
+
 func (sm *BoostAPI) BoostDealBySignedProposalCid(ctx context.Context, proposalCid cid.Cid) (*types.ProviderDealState, error) {
 	return sm.StorageProvider.DealBySignedProposalCid(ctx, proposalCid)
 }
@@ -147,11 +228,159 @@ func (sm *BoostAPI) BoostIndexerAnnounceAllDeals(ctx context.Context) error {
 	return sm.IndexProvider.IndexerAnnounceAllDeals(ctx)
 }
 
+func (sm *BoostAPI) BoostIndexerAnnounceDeals(ctx context.Context, params api.IndexerAnnounceParams) (*api.IndexerAnnounceResult, error) {
+	return sm.IndexProvider.IndexerAnnounceDeals(ctx, params)
+}
+
+func (sm *BoostAPI) BoostIndexerPendingAnnouncements(ctx context.Context) ([]api.IndexerPendingAnnouncement, error) {
+	pending, err := sm.IndexProvider.PendingAnnouncements(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	anns := make([]api.IndexerPendingAnnouncement, 0, len(pending))
+	for _, p := range pending {
+		anns = append(anns, api.IndexerPendingAnnouncement{
+			DealUuid:    p.DealUUID,
+			CreatedAt:   p.CreatedAt,
+			Attempts:    p.Attempts,
+			NextAttempt: p.NextAttempt,
+			LastError:   p.LastError,
+		})
+	}
+	return anns, nil
+}
+
+func (sm *BoostAPI) BoostIndexerDirectAnnounceStatus(ctx context.Context) ([]api.IndexerDirectAnnounceStatus, error) {
+	return sm.IndexProvider.DirectAnnounceStatus(), nil
+}
+
+func (sm *BoostAPI) BoostIndexerVerify(ctx context.Context, maxDeals int) (*api.IndexerVerifyResult, error) {
+	return sm.IndexProvider.VerifyIngestion(ctx, maxDeals)
+}
+
+func (sm *BoostAPI) BoostIndexerStatus(ctx context.Context) (*api.IndexerStatus, error) {
+	return sm.IndexProvider.Status(ctx)
+}
+
 func (sm *BoostAPI) BoostOfflineDealWithData(ctx context.Context, dealUuid uuid.UUID, filePath string) (*api.ProviderDealRejectionInfo, error) {
 	res, err := sm.StorageProvider.ImportOfflineDealData(ctx, dealUuid, filePath)
 	return res, err
 }
 
+func (sm *BoostAPI) BoostRetrievalPolicy(ctx context.Context) (*retrievalpolicy.Config, error) {
+	if sm.GetRetrievalPolicyFunc == nil {
+		return nil, errors.New("retrieval policy is not available")
+	}
+	cfg, err := sm.GetRetrievalPolicyFunc()
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (sm *BoostAPI) BoostFundsForecast(ctx context.Context) (*funds.Forecast, error) {
+	return funds.GetForecast(ctx, sm.FundManager, sm.DealsDB)
+}
+
+func (sm *BoostAPI) BoostHealth(ctx context.Context) (*health.Status, error) {
+	st := sm.Health.Status()
+	return &st, nil
+}
+
+func (sm *BoostAPI) BoostSealingPipelineStatus(ctx context.Context) (*sealingpipeline.Status, error) {
+	return sealingpipeline.GetStatus(ctx, sm.Sps)
+}
+
+func (sm *BoostAPI) BoostSealingBackpressure(ctx context.Context) (*backpressure.Status, error) {
+	st := sm.StorageProvider.SealingBackpressureStatus()
+	return &st, nil
+}
+
+func (sm *BoostAPI) BoostSealingPriority(ctx context.Context) (*sealingpriority.Status, error) {
+	st := sm.StorageProvider.SealingPriorityStatus()
+	return &st, nil
+}
+
+func (sm *BoostAPI) BoostSectorPacking(ctx context.Context) (*sectorpacking.Report, error) {
+	return sm.StorageProvider.SectorPackingReport(), nil
+}
+
+func (sm *BoostAPI) BoostFundsHistory(ctx context.Context, since time.Time, until time.Time) ([]db.FundsMovement, error) {
+	return sm.FundManager.Movements(ctx, since, until)
+}
+
+func (sm *BoostAPI) BoostRetrievalQuota(ctx context.Context, clientID string) (*quota.Status, error) {
+	return sm.RetrievalQuotas.Status(ctx, clientID)
+}
+
+func (sm *BoostAPI) BoostSetRetrievalPolicy(ctx context.Context, cfg retrievalpolicy.Config) error {
+	if sm.SetRetrievalPolicyFunc == nil {
+		return errors.New("retrieval policy is not available")
+	}
+	return sm.SetRetrievalPolicyFunc(cfg)
+}
+
+func (sm *BoostAPI) BoostRetrievalPaymentTerms(ctx context.Context) (*api.RetrievalPaymentTerms, error) {
+	terms, err := sm.RetrievalPayments.Terms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &api.RetrievalPaymentTerms{
+		PricePerByte:   terms.PricePerByte,
+		UnsealPrice:    terms.UnsealPrice,
+		PaymentAddress: terms.PaymentAddress,
+	}, nil
+}
+
+func (sm *BoostAPI) BoostRetrievalPaymentAddVoucher(ctx context.Context, clientID string, sv *paych.SignedVoucher) (abi.TokenAmount, error) {
+	return sm.RetrievalPayments.AddVoucher(ctx, clientID, sv)
+}
+
+func (sm *BoostAPI) BoostRetrievalPaymentReserve(ctx context.Context, clientID string) (*api.RetrievalPaymentReservation, error) {
+	res, err := sm.RetrievalPayments.Reserve(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	return &api.RetrievalPaymentReservation{
+		Bytes:  res.Bytes,
+		Amount: res.Amount,
+		Credit: res.Credit,
+	}, nil
+}
+
+func (sm *BoostAPI) BoostRetrievalPaymentRefund(ctx context.Context, clientID string, amount abi.TokenAmount) (abi.TokenAmount, error) {
+	return sm.RetrievalPayments.Refund(ctx, clientID, amount)
+}
+
+func (sm *BoostAPI) BoostRetrievalEarnings(ctx context.Context) ([]api.RetrievalEarnings, error) {
+	earnings, err := sm.RetrievalPayments.Earnings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]api.RetrievalEarnings, 0, len(earnings))
+	for _, e := range earnings {
+		res = append(res, api.RetrievalEarnings{
+			ClientID: e.ClientID,
+			Protocol: e.Protocol,
+			Payments: e.Payments,
+			Total:    e.Total,
+		})
+	}
+	return res, nil
+}
+
+func (sm *BoostAPI) BoostRetrievalAttemptsAdd(ctx context.Context, attempts []rtvllog.RetrievalAttempt) error {
+	for i := range attempts {
+		if err := sm.RetrievalLogDB.InsertAttempt(ctx, &attempts[i]); err != nil {
+			return fmt.Errorf("inserting retrieval attempt: %w", err)
+		}
+		sm.RetrievalEvents.Publish(rtvllog.AttemptEvent(&attempts[i], ""))
+	}
+	return nil
+}
+
 func (sm *BoostAPI) BoostDagstoreGC(ctx context.Context) ([]api.DagstoreShardResult, error) {
 	if sm.DAGStore == nil {
 		return nil, fmt.Errorf("dagstore not available on this node")
@@ -387,8 +616,11 @@ func (sm *BoostAPI) BoostDagstoreInitializeShard(ctx context.Context, key string
 		return fmt.Errorf("cannot initialize shard; expected state ShardStateNew, was: %s", st.String())
 	}
 
+	// The user is waiting for the shard to be initialized, so if the piece
+	// needs to be unsealed, unseal it ahead of other requests
 	ch := make(chan dagstore.ShardResult, 1)
-	if err = sm.DAGStore.AcquireShard(ctx, k, ch, dagstore.AcquireOpts{}); err != nil {
+	uctx := sectoraccessor.WithUnsealPriority(ctx, sectoraccessor.UnsealPriorityHigh)
+	if err = sm.DAGStore.AcquireShard(uctx, k, ch, dagstore.AcquireOpts{}); err != nil {
 		return fmt.Errorf("failed to acquire shard: %w", err)
 	}
 
@@ -457,7 +689,8 @@ func (sm *BoostAPI) BoostDagstoreRecoverShard(ctx context.Context, key string) e
 	}
 
 	ch := make(chan dagstore.ShardResult, 1)
-	if err = sm.DAGStore.RecoverShard(ctx, k, ch, dagstore.RecoverOpts{}); err != nil {
+	uctx := sectoraccessor.WithUnsealPriority(ctx, sectoraccessor.UnsealPriorityHigh)
+	if err = sm.DAGStore.RecoverShard(uctx, k, ch, dagstore.RecoverOpts{}); err != nil {
 		return fmt.Errorf("failed to recover shard: %w", err)
 	}
 
@@ -493,6 +726,31 @@ func (sm *BoostAPI) BoostDagstoreDestroyShard(ctx context.Context, key string) e
 	return nil
 }
 
+func (sm *BoostAPI) PiecesHealth(ctx context.Context) ([]api.PieceHealth, error) {
+	return sm.PieceDoctor.Results(), nil
+}
+
+func (sm *BoostAPI) PiecesCheckHealth(ctx context.Context, pieceCid cid.Cid, repair bool) (*api.PieceHealth, error) {
+	h := sm.PieceDoctor.CheckPiece(ctx, pieceCid, repair)
+	return &h, nil
+}
+
+func (sm *BoostAPI) PiecesUnsealedStatus(ctx context.Context, pieceCid cid.Cid) (*api.PieceUnsealedStatus, error) {
+	return sm.UnsealedCopies.Status(ctx, pieceCid)
+}
+
+func (sm *BoostAPI) PiecesUnseal(ctx context.Context, pieceCid cid.Cid) error {
+	return sm.UnsealedCopies.Unseal(ctx, pieceCid)
+}
+
+func (sm *BoostAPI) PiecesRemoveUnsealed(ctx context.Context, pieceCid cid.Cid, force bool) ([]abi.SectorNumber, error) {
+	return sm.UnsealedCopies.RemoveUnsealed(ctx, pieceCid, force)
+}
+
+func (sm *BoostAPI) PiecesRemove(ctx context.Context, pieceCid cid.Cid, dryRun bool) (*api.PieceRemoval, error) {
+	return sm.PieceRemover.Remove(ctx, pieceCid, dryRun)
+}
+
 func (sm *BoostAPI) BoostMakeDeal(ctx context.Context, params types.DealParams) (*api.ProviderDealRejectionInfo, error) {
 	log.Infow("received json-rpc deal proposal", "id", params.DealUUID)
 	return sm.StorageProvider.ExecuteDeal(ctx, &params, "json-rpc-deal")
@@ -13,6 +13,7 @@ import (
 	"github.com/filecoin-project/boost/api"
 	"github.com/filecoin-project/boost/build"
 
+	apitypes "github.com/filecoin-project/lotus/api/types"
 	"github.com/filecoin-project/lotus/journal/alerting"
 	lotus_dtypes "github.com/filecoin-project/lotus/node/modules/dtypes"
 )
@@ -48,6 +49,10 @@ func (a *CommonAPI) AuthNew(ctx context.Context, perms []auth.Permission) ([]byt
 	return jwt.Sign(&p, (*jwt.HMACSHA)(a.APISecret))
 }
 
+func (a *CommonAPI) Discover(ctx context.Context) (apitypes.OpenRPCDocument, error) {
+	return build.OpenRPCDiscoverJSON_Boost(), nil
+}
+
 func (a *CommonAPI) Version(context.Context) (api.APIVersion, error) {
 	return api.APIVersion{
 		Version:    build.UserVersion(),
@@ -1,14 +1,18 @@
 package modules
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gbrlsnchs/jwt/v3"
@@ -39,6 +43,9 @@ const (
 const (
 	JWTSecretName   = "auth-jwt-private" //nolint:gosec
 	KTJwtHmacSecret = "jwt-hmac-secret"  //nolint:gosec
+
+	DealsDBKeyName      = "deals-db-encryption" //nolint:gosec
+	KTDealsDBEncryption = "deals-db-aes-key"    //nolint:gosec
 )
 
 var (
@@ -178,6 +185,50 @@ func APISecret(keystore types.KeyStore, lr lotus_repo.LockedRepo) (*dtypes.APIAl
 	return (*dtypes.APIAlg)(jwt.NewHS256(key.PrivateKey)), nil
 }
 
+// DealsDBEncryptionKey gets the key used to encrypt sensitive fields in the
+// deals database. If keyCmd is set, the hex-encoded key is read from the
+// command's output (eg to fetch the key from a KMS). Otherwise the key is
+// read from the keystore, and generated if it doesn't exist yet.
+func DealsDBEncryptionKey(keyCmd string, keystore types.KeyStore) ([]byte, error) {
+	if keyCmd != "" {
+		var stderr bytes.Buffer
+		c := exec.Command("sh", "-c", keyCmd)
+		c.Stderr = &stderr
+		out, err := c.Output()
+		if err != nil {
+			return nil, fmt.Errorf("running key command: %w: %s", err, stderr.String())
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(string(out)))
+		if err != nil {
+			return nil, fmt.Errorf("decoding hex key from key command output: %w", err)
+		}
+		return key, nil
+	}
+
+	key, err := keystore.Get(DealsDBKeyName)
+	if errors.Is(err, types.ErrKeyInfoNotFound) {
+		log.Warn("Generating new deals db encryption key")
+
+		sk, err := ioutil.ReadAll(io.LimitReader(rand.Reader, 32))
+		if err != nil {
+			return nil, err
+		}
+
+		key = types.KeyInfo{
+			Type:       KTDealsDBEncryption,
+			PrivateKey: sk,
+		}
+
+		if err := keystore.Put(DealsDBKeyName, key); err != nil {
+			return nil, fmt.Errorf("writing deals db encryption key: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("could not get deals db encryption key: %w", err)
+	}
+
+	return key.PrivateKey, nil
+}
+
 func ConfigBootstrap(peers []string) func() (dtypes.BootstrapPeers, error) {
 	return func() (dtypes.BootstrapPeers, error) {
 		return addrutil.ParseAddresses(context.TODO(), peers)
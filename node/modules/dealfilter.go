@@ -18,7 +18,7 @@ func BasicDealFilter(cfg config.DealmakingConfig, userCmd dtypes.StorageDealFilt
 	expectedSealTimeFunc dtypes.GetExpectedSealDurationFunc,
 	startDelay dtypes.GetMaxDealStartDelayFunc,
 	r lotus_repo.LockedRepo,
-) dtypes.StorageDealFilter {
+) dtypes.StorageDealDecider {
 	return func(onlineOk dtypes.ConsiderOnlineStorageDealsConfigFunc,
 		offlineOk dtypes.ConsiderOfflineStorageDealsConfigFunc,
 		verifiedOk dtypes.ConsiderVerifiedStorageDealsConfigFunc,
@@ -27,73 +27,98 @@ func BasicDealFilter(cfg config.DealmakingConfig, userCmd dtypes.StorageDealFilt
 		expectedSealTimeFunc dtypes.GetExpectedSealDurationFunc,
 		startDelay dtypes.GetMaxDealStartDelayFunc,
 		r lotus_repo.LockedRepo,
-	) dtypes.StorageDealFilter {
-		return func(ctx context.Context, params dealfilter.DealFilterParams) (bool, string, error) {
+	) dtypes.StorageDealDecider {
+		var userDecider dealfilter.StorageDealDecider
+		if userCmd != nil {
+			userDecider = dealfilter.FilterDecider(dealfilter.StorageRuleFilterCommand, dealfilter.StorageDealFilter(userCmd))
+		}
+
+		return func(ctx context.Context, params dealfilter.DealFilterParams) (dealfilter.StorageDealDecision, error) {
+			reject := func(rule string, reason string) (dealfilter.StorageDealDecision, error) {
+				return dealfilter.StorageDealDecision{Reason: reason, Rule: rule}, nil
+			}
+			minerErr := func(rule string, err error) (dealfilter.StorageDealDecision, error) {
+				return dealfilter.StorageDealDecision{Reason: "miner error", Rule: rule}, err
+			}
+
 			deal := params.DealParams
 			pr := deal.ClientDealProposal.Proposal
 
 			// TODO: maybe handle in userCmd?
 			b, err := onlineOk()
 			if err != nil {
-				return false, "miner error", err
+				return minerErr(dealfilter.StorageRuleOnlineDeals, err)
 			}
 
 			if !deal.IsOffline && !b {
 				log.Warnf("online storage deal consideration disabled; rejecting storage deal proposal from client: %s", deal.ClientDealProposal.Proposal.Client.String())
-				return false, "miner is not considering online storage deals", nil
+				return reject(dealfilter.StorageRuleOnlineDeals, "miner is not considering online storage deals")
 			}
 
 			// TODO: maybe handle in userCmd?
 			b, err = offlineOk()
 			if err != nil {
-				return false, "miner error", err
+				return minerErr(dealfilter.StorageRuleOfflineDeals, err)
 			}
 
 			if deal.IsOffline && !b {
 				log.Warnf("offline storage deal consideration disabled; rejecting storage deal proposal from client: %s", deal.ClientDealProposal.Proposal.Client.String())
-				return false, "miner is not accepting offline storage deals", nil
+				return reject(dealfilter.StorageRuleOfflineDeals, "miner is not accepting offline storage deals")
 			}
 
 			// TODO: maybe handle in userCmd?
 			b, err = verifiedOk()
 			if err != nil {
-				return false, "miner error", err
+				return minerErr(dealfilter.StorageRuleVerifiedDeals, err)
 			}
 
 			if pr.VerifiedDeal && !b {
 				log.Warnf("verified storage deal consideration disabled; rejecting storage deal proposal from client: %s", pr.Client.String())
-				return false, "miner is not accepting verified storage deals", nil
+				return reject(dealfilter.StorageRuleVerifiedDeals, "miner is not accepting verified storage deals")
 			}
 
 			// TODO: maybe handle in userCmd?
 			b, err = unverifiedOk()
 			if err != nil {
-				return false, "miner error", err
+				return minerErr(dealfilter.StorageRuleUnverifiedDeals, err)
 			}
 
 			if !pr.VerifiedDeal && !b {
 				log.Warnf("unverified storage deal consideration disabled; rejecting storage deal proposal from client: %s", pr.Client.String())
-				return false, "miner is not accepting unverified storage deals", nil
+				return reject(dealfilter.StorageRuleUnverifiedDeals, "miner is not accepting unverified storage deals")
 			}
 
 			// TODO: maybe handle in userCmd?
 			blocklist, err := blocklistFunc()
 			if err != nil {
-				return false, "miner error", err
+				return minerErr(dealfilter.StorageRulePieceBlocklist, err)
 			}
 
 			for idx := range blocklist {
 				if deal.ClientDealProposal.Proposal.PieceCID.Equals(blocklist[idx]) {
 					log.Warnf("piece CID in proposal %s is blocklisted; rejecting storage deal proposal from client: %s", pr.PieceCID, pr.Client.String())
-					return false, fmt.Sprintf("miner has blocklisted piece CID %s", pr.PieceCID), nil
+					return reject(dealfilter.StorageRulePieceBlocklist, fmt.Sprintf("miner has blocklisted piece CID %s", pr.PieceCID))
 				}
 			}
 
-			if userCmd != nil {
-				return userCmd(ctx, params)
+			if userDecider != nil {
+				return userDecider(ctx, params)
 			}
 
-			return true, "", nil
+			return dealfilter.StorageDealDecision{Accept: true, Rule: dealfilter.StorageRuleDefault}, nil
 		}
 	}
 }
+
+// UserRetrievalDealFilter creates the retrieval deal filter from the user's
+// filter command and / or filter endpoint
+func UserRetrievalDealFilter(cfg config.DealmakingConfig) dtypes.RetrievalDealFilter {
+	var filters []dealfilter.RetrievalDealFilter
+	if cfg.RetrievalFilter != "" {
+		filters = append(filters, dealfilter.CliRetrievalDealFilter(cfg.RetrievalFilter))
+	}
+	if cfg.RetrievalFilterEndpoint != "" {
+		filters = append(filters, dealfilter.HttpRetrievalDealFilter(cfg.RetrievalFilterEndpoint))
+	}
+	return dtypes.RetrievalDealFilter(dealfilter.RetrievalDealFilters(filters...))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/filecoin-project/boost/retrievalpolicy"
 	"github.com/filecoin-project/boost/storagemarket/dealfilter"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	"github.com/filecoin-project/go-state-types/abi"
@@ -89,7 +90,14 @@ type GetExpectedSealDurationFunc func() (time.Duration, error)
 type SetMaxDealStartDelayFunc func(time.Duration) error
 type GetMaxDealStartDelayFunc func() (time.Duration, error)
 
+// GetRetrievalPolicyFunc reads the retrieval policy from the config
+type GetRetrievalPolicyFunc func() (retrievalpolicy.Config, error)
+
+// SetRetrievalPolicyFunc writes the retrieval policy to the config
+type SetRetrievalPolicyFunc func(retrievalpolicy.Config) error
+
 type StorageDealFilter dealfilter.StorageDealFilter
+type StorageDealDecider dealfilter.StorageDealDecider
 type RetrievalDealFilter dealfilter.RetrievalDealFilter
 
 type RetrievalPricingFunc func(ctx context.Context, dealPricingParams retrievalmarket.PricingInput) (retrievalmarket.Ask, error)
@@ -0,0 +1,46 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/eventbus"
+	"github.com/filecoin-project/boost/node/config"
+	lotus_dtypes "github.com/filecoin-project/lotus/node/modules/dtypes"
+	"go.uber.org/fx"
+)
+
+// NewEventBus creates the event bus that deal and retrieval events are
+// published to. It returns nil if the event bus is disabled.
+func NewEventBus(cfg *config.Boost) func(lc fx.Lifecycle, auditDB *db.AuditLogDB, ds lotus_dtypes.MetadataDS) (*eventbus.Bus, error) {
+	return func(lc fx.Lifecycle, auditDB *db.AuditLogDB, ds lotus_dtypes.MetadataDS) (*eventbus.Bus, error) {
+		ecfg := cfg.EventBus
+		if ecfg.Type == "" {
+			return nil, nil
+		}
+
+		sink, err := eventbus.NewSink(ecfg.Type, ecfg.URL, ecfg.DealTopic)
+		if err != nil {
+			return nil, fmt.Errorf("parsing EventBus config: %w", err)
+		}
+
+		bus := eventbus.New(eventbus.Config{
+			DealTopic:      ecfg.DealTopic,
+			RetrievalTopic: ecfg.RetrievalTopic,
+			PollInterval:   time.Duration(ecfg.PollInterval),
+		}, sink, auditDB, ds)
+
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				return bus.Start(context.Background())
+			},
+			OnStop: func(ctx context.Context) error {
+				return bus.Stop()
+			},
+		})
+
+		return bus, nil
+	}
+}
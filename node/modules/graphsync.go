@@ -3,10 +3,10 @@ package modules
 import (
 	"context"
 	"github.com/filecoin-project/boost/node/modules/dtypes"
+	"github.com/filecoin-project/boost/retrievalmarket/quota"
 	"github.com/filecoin-project/boost/retrievalmarket/server"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	retrievalimpl "github.com/filecoin-project/go-fil-markets/retrievalmarket/impl"
-	"github.com/filecoin-project/go-fil-markets/stores"
 	"github.com/filecoin-project/go-state-types/abi"
 	lotus_modules "github.com/filecoin-project/lotus/node/modules"
 	lotus_dtypes "github.com/filecoin-project/lotus/node/modules/dtypes"
@@ -43,8 +43,10 @@ func SetAskGetter(proxy *ProxyAskGetter, rp retrievalmarket.RetrievalProvider) {
 }
 
 // Graphsync creates a graphsync instance used to serve retrievals.
-func Graphsync(parallelTransfersForStorage uint64, parallelTransfersForStoragePerPeer uint64, parallelTransfersForRetrieval uint64) func(mctx lotus_helpers.MetricsCtx, lc fx.Lifecycle, ibs dtypes.IndexBackedBlockstore, h host.Host, net lotus_dtypes.ProviderTransferNetwork, dealDecider lotus_dtypes.RetrievalDealFilter, dagStore stores.DAGStoreWrapper, pstore lotus_dtypes.ProviderPieceStore, sa retrievalmarket.SectorAccessor, askGetter server.AskGetter) (*server.GraphsyncUnpaidRetrieval, error) {
-	return func(mctx lotus_helpers.MetricsCtx, lc fx.Lifecycle, ibs dtypes.IndexBackedBlockstore, h host.Host, net lotus_dtypes.ProviderTransferNetwork, dealDecider lotus_dtypes.RetrievalDealFilter, dagStore stores.DAGStoreWrapper, pstore lotus_dtypes.ProviderPieceStore, sa retrievalmarket.SectorAccessor, askGetter server.AskGetter) (*server.GraphsyncUnpaidRetrieval, error) {
+// If unsealOnDemand is true, unpaid retrievals of pieces with no unsealed
+// copy are served by unsealing the piece when the data is read.
+func Graphsync(parallelTransfersForStorage uint64, parallelTransfersForStoragePerPeer uint64, parallelTransfersForRetrieval uint64, unsealOnDemand bool) func(mctx lotus_helpers.MetricsCtx, lc fx.Lifecycle, ibs dtypes.IndexBackedBlockstore, h host.Host, net lotus_dtypes.ProviderTransferNetwork, dealDecider dtypes.RetrievalDealFilter, pd server.PieceDirectory, sa retrievalmarket.SectorAccessor, askGetter server.AskGetter, rask *server.RetrievalAsk, quotas *quota.Quotas) (*server.GraphsyncUnpaidRetrieval, error) {
+	return func(mctx lotus_helpers.MetricsCtx, lc fx.Lifecycle, ibs dtypes.IndexBackedBlockstore, h host.Host, net lotus_dtypes.ProviderTransferNetwork, dealDecider dtypes.RetrievalDealFilter, pd server.PieceDirectory, sa retrievalmarket.SectorAccessor, askGetter server.AskGetter, rask *server.RetrievalAsk, quotas *quota.Quotas) (*server.GraphsyncUnpaidRetrieval, error) {
 		// Create a Graphsync instance
 		mkgs := lotus_modules.StagingGraphsync(parallelTransfersForStorage, parallelTransfersForStoragePerPeer, parallelTransfersForRetrieval)
 		gs := mkgs(mctx, lc, ibs, h)
@@ -52,10 +54,14 @@ func Graphsync(parallelTransfersForStorage uint64, parallelTransfersForStoragePe
 		// Wrap the Graphsync instance with a handler for unpaid retrieval requests
 		vdeps := server.ValidationDeps{
 			DealDecider:    retrievalimpl.DealDecider(dealDecider),
-			DagStore:       dagStore,
-			PieceStore:     pstore,
+			PieceDirectory: pd,
 			SectorAccessor: sa,
 			AskStore:       askGetter,
+			FreeClients:    rask,
+			UnsealOnDemand: unsealOnDemand,
+		}
+		if quotas.Enabled() {
+			vdeps.Quotas = quotas
 		}
 		gsupr, err := server.NewGraphsyncUnpaidRetrieval(h.ID(), gs, net, vdeps)
 
@@ -0,0 +1,70 @@
+package modules
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/boost/health"
+	"github.com/filecoin-project/boost/indexprovider"
+	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
+	"github.com/filecoin-project/lotus/api/v1api"
+	"github.com/libp2p/go-libp2p/core/host"
+	"go.uber.org/fx"
+)
+
+// The full node is considered unhealthy if the chain head is older than this
+// (eg because the node is out of sync)
+const maxChainHeadAge = 10 * time.Minute
+
+// NewHealthChecker checks the health of each of the dependencies of boost.
+// The health status is served at /healthz and by `boostd status`.
+func NewHealthChecker(lc fx.Lifecycle, fullnodeApi v1api.FullNode, sps sealingpipeline.API, sqldb *sql.DB, h host.Host, ip *indexprovider.Wrapper) *health.Checker {
+	c := health.NewChecker(0)
+
+	c.Register("full node API", func(ctx context.Context) error {
+		head, err := fullnodeApi.ChainHead(ctx)
+		if err != nil {
+			return err
+		}
+		headTime := time.Unix(int64(head.MinTimestamp()), 0)
+		if age := time.Since(headTime); age > maxChainHeadAge {
+			return fmt.Errorf("chain head at epoch %d is %s old: the node may be out of sync", head.Height(), age.Truncate(time.Second))
+		}
+		return nil
+	})
+	c.Register("lotus-miner API", func(ctx context.Context) error {
+		_, err := sps.ActorAddress(ctx)
+		return err
+	})
+	c.Register("database", func(ctx context.Context) error {
+		return sqldb.PingContext(ctx)
+	})
+	c.Register("libp2p listeners", func(ctx context.Context) error {
+		if len(h.Network().ListenAddresses()) == 0 {
+			return errors.New("not listening on any address")
+		}
+		return nil
+	})
+	if ip.Enabled() {
+		c.Register("index provider", func(ctx context.Context) error {
+			_, err := ip.Status(ctx)
+			return err
+		})
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			c.Start(context.Background())
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			c.Stop()
+			return nil
+		},
+	})
+
+	return c
+}
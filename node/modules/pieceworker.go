@@ -0,0 +1,70 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/boost/node/config"
+	"github.com/filecoin-project/boost/pieceworker"
+	smtypes "github.com/filecoin-project/boost/storagemarket/types"
+	lotus_modules "github.com/filecoin-project/lotus/node/modules"
+	"github.com/filecoin-project/lotus/node/modules/helpers"
+	"github.com/filecoin-project/lotus/storage/sectorblocks"
+	"go.uber.org/fx"
+)
+
+// NewPieceAdder returns the piece adder that deal data is added to sectors
+// with: a remote piece worker if one is configured, or else the sealer
+func NewPieceAdder(cfg *config.Boost) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, secb *sectorblocks.SectorBlocks) (smtypes.PieceAdder, error) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, secb *sectorblocks.SectorBlocks) (smtypes.PieceAdder, error) {
+		workerCfg := cfg.PieceWorkers.AddPiece
+		if workerCfg.URL == "" {
+			return secb, nil
+		}
+
+		pw, err := connectPieceWorker(mctx, lc, workerCfg)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to add piece worker: %w", err)
+		}
+		log.Infow("adding deal data to sectors with remote piece worker", "url", workerCfg.URL)
+		return pieceworker.NewPieceAdder(pw), nil
+	}
+}
+
+// NewCommpCalculator returns the commp calculator that is used when commp is
+// calculated remotely: a remote piece worker if one is configured, or else
+// the sealer
+func NewCommpCalculator(cfg *config.Boost) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, mss lotus_modules.MinerStorageService) (smtypes.CommpCalculator, error) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, mss lotus_modules.MinerStorageService) (smtypes.CommpCalculator, error) {
+		workerCfg := cfg.PieceWorkers.Commp
+		if workerCfg.URL == "" {
+			return mss, nil
+		}
+
+		pw, err := connectPieceWorker(mctx, lc, workerCfg)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to commp piece worker: %w", err)
+		}
+		if !cfg.Dealmaking.RemoteCommp {
+			log.Warnw("commp piece worker is configured but RemoteCommp is false: commp will be calculated locally", "url", workerCfg.URL)
+		} else {
+			log.Infow("calculating commp with remote piece worker", "url", workerCfg.URL)
+		}
+		return pw, nil
+	}
+}
+
+func connectPieceWorker(mctx helpers.MetricsCtx, lc fx.Lifecycle, cfg config.PieceWorkerConfig) (pieceworker.API, error) {
+	ctx := helpers.LifecycleCtx(mctx, lc)
+	pw, closer, err := pieceworker.Connect(ctx, cfg.URL, cfg.Token)
+	if err != nil {
+		return nil, err
+	}
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			closer()
+			return nil
+		},
+	})
+	return pw, nil
+}
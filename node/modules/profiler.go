@@ -0,0 +1,44 @@
+package modules
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/filecoin-project/boost/node/config"
+	"github.com/filecoin-project/boost/profiler"
+	lotus_repo "github.com/filecoin-project/lotus/node/repo"
+	"go.uber.org/fx"
+)
+
+// HandleProfilingWatchdog starts the watchdog that captures profiles when
+// resource usage exceeds the thresholds in the config
+func HandleProfilingWatchdog(cfg *config.Boost) func(lc fx.Lifecycle, r lotus_repo.LockedRepo) {
+	return func(lc fx.Lifecycle, r lotus_repo.LockedRepo) {
+		pcfg := cfg.Profiling
+		if pcfg.HeapThresholdBytes <= 0 && pcfg.GoroutineThreshold <= 0 {
+			return
+		}
+
+		w := profiler.NewWatchdog(profiler.Config{
+			Dir:                filepath.Join(r.Path(), "profiles"),
+			CheckInterval:      time.Duration(pcfg.CheckInterval),
+			HeapThreshold:      uint64(pcfg.HeapThresholdBytes),
+			GoroutineThreshold: pcfg.GoroutineThreshold,
+			CPUProfileDuration: time.Duration(pcfg.CPUProfileDuration),
+			Cooldown:           time.Duration(pcfg.Cooldown),
+			MaxCaptures:        pcfg.MaxCaptures,
+		})
+
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				w.Start(context.Background())
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				w.Stop()
+				return nil
+			},
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/boost/node/config"
+	"github.com/filecoin-project/boost/remotesigner"
+	"github.com/filecoin-project/lotus/api/v1api"
+	lotus_dtypes "github.com/filecoin-project/lotus/node/modules/dtypes"
+	"github.com/filecoin-project/lotus/node/modules/helpers"
+	lotus_repo "github.com/filecoin-project/lotus/node/repo"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"go.uber.org/fx"
+)
+
+// NewFullNode returns the full node API. If a remote signer is configured,
+// the full node API is wrapped so that all signing is delegated to the
+// remote signer.
+func NewFullNode(full v1api.FullNode) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, r lotus_repo.LockedRepo, ds lotus_dtypes.MetadataDS) (v1api.FullNode, error) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, r lotus_repo.LockedRepo, ds lotus_dtypes.MetadataDS) (v1api.FullNode, error) {
+		var cfg config.RemoteSignerConfig
+		err := readCfg(r, func(c *config.Boost) {
+			cfg = c.Wallets.RemoteSigner
+		})
+		if err != nil {
+			return nil, fmt.Errorf("reading remote signer config: %w", err)
+		}
+		if cfg.URL == "" {
+			return full, nil
+		}
+
+		ctx := helpers.LifecycleCtx(mctx, lc)
+		signer, closer, err := remotesigner.Connect(ctx, cfg.URL, cfg.Token)
+		if err != nil {
+			return nil, err
+		}
+		lc.Append(fx.Hook{
+			OnStop: func(ctx context.Context) error {
+				closer()
+				return nil
+			},
+		})
+
+		addrs, err := signer.WalletList(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing remote signer wallet addresses: %w", err)
+		}
+		log.Infow("delegating signing to remote signer", "url", cfg.URL, "addresses", addrs)
+
+		escrowDS := namespace.Wrap(ds, datastore.NewKey("/remotesigner/escrow"))
+		return remotesigner.NewFullNode(full, signer, escrowDS), nil
+	}
+}
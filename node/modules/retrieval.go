@@ -9,13 +9,23 @@ import (
 
 	"github.com/filecoin-project/boost/cmd/booster-bitswap/bitswap"
 	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/eventbus"
 	"github.com/filecoin-project/boost/node/config"
 	"github.com/filecoin-project/boost/protocolproxy"
+	brm "github.com/filecoin-project/boost/retrievalmarket/lib"
 	"github.com/filecoin-project/boost/retrievalmarket/lp2pimpl"
+	"github.com/filecoin-project/boost/retrievalmarket/payments"
+	"github.com/filecoin-project/boost/retrievalmarket/quota"
+	"github.com/filecoin-project/boost/retrievalmarket/rtvlevents"
 	"github.com/filecoin-project/boost/retrievalmarket/rtvllog"
 	"github.com/filecoin-project/boost/retrievalmarket/server"
 	"github.com/filecoin-project/boost/retrievalmarket/types"
+	"github.com/filecoin-project/dagstore"
+	"github.com/filecoin-project/go-address"
 	lotus_retrievalmarket "github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	rmnet "github.com/filecoin-project/go-fil-markets/retrievalmarket/network"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api/v1api"
 	lotus_dtypes "github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/repo"
 	"github.com/libp2p/go-libp2p/core/host"
@@ -117,6 +127,63 @@ func HandleRetrievalTransports(lc fx.Lifecycle, l *lp2pimpl.TransportsListener)
 	})
 }
 
+// NewRetrievalAsk creates the retrieval ask that clients can query, with
+// the free allowlist from the config
+func NewRetrievalAsk(cfg *config.Boost) func(askGetter server.AskGetter) (*server.RetrievalAsk, error) {
+	return func(askGetter server.AskGetter) (*server.RetrievalAsk, error) {
+		freeClients := make([]peer.ID, 0, len(cfg.RetrievalAsk.FreeClients))
+		for _, c := range cfg.RetrievalAsk.FreeClients {
+			p, err := peer.Decode(c)
+			if err != nil {
+				return nil, fmt.Errorf("parsing retrieval ask free client peer id '%s': %w", c, err)
+			}
+			freeClients = append(freeClients, p)
+		}
+		return server.NewRetrievalAsk(askGetter, freeClients), nil
+	}
+}
+
+// HandleSetRetrievalAsk sets the retrieval provider's ask from the config,
+// if the config says to set it on startup
+func HandleSetRetrievalAsk(cfg *config.Boost) func(rp lotus_retrievalmarket.RetrievalProvider) error {
+	return func(rp lotus_retrievalmarket.RetrievalProvider) error {
+		if !cfg.RetrievalAsk.SetOnStartup {
+			return nil
+		}
+
+		ask := &lotus_retrievalmarket.Ask{
+			PricePerByte:            abi.TokenAmount(cfg.RetrievalAsk.PricePerByte),
+			UnsealPrice:             abi.TokenAmount(cfg.RetrievalAsk.UnsealPrice),
+			PaymentInterval:         cfg.RetrievalAsk.PaymentInterval,
+			PaymentIntervalIncrease: cfg.RetrievalAsk.PaymentIntervalIncrease,
+		}
+		log.Infow("setting retrieval ask from config", "price-per-byte", ask.PricePerByte,
+			"unseal-price", ask.UnsealPrice, "payment-interval", ask.PaymentInterval,
+			"payment-interval-increase", ask.PaymentIntervalIncrease)
+		rp.SetAsk(ask)
+		return nil
+	}
+}
+
+func NewAskListener(h host.Host, rask *server.RetrievalAsk) *lp2pimpl.AskListener {
+	return lp2pimpl.NewAskListener(h, rask)
+}
+
+func HandleRetrievalAsk(lc fx.Lifecycle, l *lp2pimpl.AskListener) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			log.Debug("starting retrieval ask listener")
+			l.Start()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			log.Debug("stopping retrieval ask listener")
+			l.Stop()
+			return nil
+		},
+	})
+}
+
 type RetrievalSqlDB struct {
 	db *sql.DB
 }
@@ -143,9 +210,9 @@ func NewRetrievalLogDB(db *RetrievalSqlDB) *rtvllog.RetrievalLogDB {
 }
 
 // Write graphsync retrieval updates to the database
-func HandleRetrievalGraphsyncUpdates(duration time.Duration, stalledDuration time.Duration) func(lc fx.Lifecycle, db *rtvllog.RetrievalLogDB, m lotus_retrievalmarket.RetrievalProvider, dt lotus_dtypes.ProviderDataTransfer, gsur *server.GraphsyncUnpaidRetrieval) {
-	return func(lc fx.Lifecycle, db *rtvllog.RetrievalLogDB, m lotus_retrievalmarket.RetrievalProvider, dt lotus_dtypes.ProviderDataTransfer, gsur *server.GraphsyncUnpaidRetrieval) {
-		rel := rtvllog.NewRetrievalLog(db, duration, dt, stalledDuration, gsur)
+func HandleRetrievalGraphsyncUpdates(duration time.Duration, stalledDuration time.Duration) func(lc fx.Lifecycle, db *rtvllog.RetrievalLogDB, m lotus_retrievalmarket.RetrievalProvider, dt lotus_dtypes.ProviderDataTransfer, gsur *server.GraphsyncUnpaidRetrieval, events *rtvlevents.Publisher) {
+	return func(lc fx.Lifecycle, db *rtvllog.RetrievalLogDB, m lotus_retrievalmarket.RetrievalProvider, dt lotus_dtypes.ProviderDataTransfer, gsur *server.GraphsyncUnpaidRetrieval, events *rtvlevents.Publisher) {
+		rel := rtvllog.NewRetrievalLog(db, duration, dt, stalledDuration, gsur, events)
 
 		relctx, cancel := context.WithCancel(context.Background())
 		type unsubFn func()
@@ -172,6 +239,137 @@ func HandleRetrievalGraphsyncUpdates(duration time.Duration, stalledDuration tim
 	}
 }
 
+// NewRetrievalEventPublisher creates the publisher that sends retrieval
+// events to the sinks in the config, and to the event bus if it's enabled
+func NewRetrievalEventPublisher(cfg *config.Boost) func(lc fx.Lifecycle, bus *eventbus.Bus) (*rtvlevents.Publisher, error) {
+	return func(lc fx.Lifecycle, bus *eventbus.Bus) (*rtvlevents.Publisher, error) {
+		ecfg := cfg.RetrievalEvents
+
+		var sinks []rtvlevents.Sink
+		if ecfg.WebhookURL != "" {
+			sinks = append(sinks, rtvlevents.NewWebhookSink(ecfg.WebhookURL, ecfg.WebhookSecret))
+		}
+		if ecfg.NATSURL != "" {
+			s, err := rtvlevents.NewNATSSink(ecfg.NATSURL, ecfg.NATSSubject)
+			if err != nil {
+				return nil, fmt.Errorf("creating retrieval events NATS sink: %w", err)
+			}
+			sinks = append(sinks, s)
+		}
+		if ecfg.FilePath != "" {
+			s, err := rtvlevents.NewFileSink(ecfg.FilePath)
+			if err != nil {
+				return nil, fmt.Errorf("creating retrieval events file sink: %w", err)
+			}
+			sinks = append(sinks, s)
+		}
+		if bus != nil {
+			sinks = append(sinks, bus.RetrievalSink())
+		}
+
+		p := rtvlevents.NewPublisher(time.Duration(ecfg.ProgressInterval), sinks...)
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				p.Start(context.Background())
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				p.Stop()
+				return nil
+			},
+		})
+		return p, nil
+	}
+}
+
+// NewRetrievalQuotas creates the per-client retrieval quotas, which are
+// counted from the bytes sent in the retrievals DB
+func NewRetrievalQuotas(cfg *config.Boost) func(rdb *rtvllog.RetrievalLogDB) (*quota.Quotas, error) {
+	return func(rdb *rtvllog.RetrievalLogDB) (*quota.Quotas, error) {
+		qcfg := cfg.RetrievalQuotas
+		clients := make([]quota.ClientConfig, 0, len(qcfg.Clients))
+		for _, c := range qcfg.Clients {
+			clients = append(clients, quota.ClientConfig{
+				Client:           c.Client,
+				MaxBytesPerDay:   c.MaxBytesPerDay,
+				MaxBytesPerMonth: c.MaxBytesPerMonth,
+			})
+		}
+		return quota.New(quota.Config{
+			MaxBytesPerDay:         qcfg.MaxBytesPerDay,
+			MaxBytesPerMonth:       qcfg.MaxBytesPerMonth,
+			Action:                 qcfg.Action,
+			ThrottleBytesPerSecond: qcfg.ThrottleBytesPerSecond,
+			Clients:                clients,
+		}, rdb)
+	}
+}
+
+func NewRetrievalPaymentsDB(sqldb *sql.DB) *db.RetrievalPaymentsDB {
+	return db.NewRetrievalPaymentsDB(sqldb)
+}
+
+// NewRetrievalPayments creates the manager for payments for retrievals.
+// Payment channels for retrievals are created to the miner's worker
+// address, as for legacy retrieval deals.
+func NewRetrievalPayments(pdb *db.RetrievalPaymentsDB, a v1api.FullNode, askGetter server.AskGetter, rpn lotus_retrievalmarket.RetrievalProviderNode, maddr lotus_dtypes.MinerAddress) *payments.Manager {
+	paymentAddr := func(ctx context.Context) (address.Address, error) {
+		return rpn.GetMinerWorkerAddress(ctx, address.Address(maddr), nil)
+	}
+	return payments.NewManager(pdb, a, askGetter, paymentAddr)
+}
+
+// NewPieceDirectory creates the piece directory that boost uses to look up
+// pieces for graphsync retrievals and retrieval queries
+func NewPieceDirectory(dagst dagstore.Interface, mhc *brm.MultihashLookupCache, ps lotus_dtypes.ProviderPieceStore) server.PieceDirectory {
+	if mhc != nil {
+		dagst = mhc
+	}
+	return brm.NewDagstorePieceDirectory(dagst, ps)
+}
+
+// HandleNativeRetrievalQueries answers retrieval queries with boost's own
+// query handler, using the same piece directory, ask and free client
+// allowlist as the graphsync retrieval validator. It takes over the query
+// protocol from the legacy retrieval provider, which is still started to
+// serve paid graphsync retrievals.
+func HandleNativeRetrievalQueries(lc fx.Lifecycle, net rmnet.RetrievalMarketNetwork, pd server.PieceDirectory, sa lotus_retrievalmarket.SectorAccessor, askGetter server.AskGetter, rask *server.RetrievalAsk, rpn lotus_retrievalmarket.RetrievalProviderNode, maddr lotus_dtypes.MinerAddress) {
+	paymentAddr := func(ctx context.Context) (address.Address, error) {
+		return rpn.GetMinerWorkerAddress(ctx, address.Address(maddr), nil)
+	}
+	vdeps := server.ValidationDeps{
+		PieceDirectory: pd,
+		SectorAccessor: sa,
+		AskStore:       askGetter,
+		FreeClients:    rask,
+		UnsealOnDemand: true,
+	}
+	qh := server.NewQueryHandler(vdeps, paymentAddr)
+	lc.Append(fx.Hook{
+		// The legacy retrieval provider sets itself as the delegate when it
+		// starts, so this hook must run after the provider's
+		OnStart: func(ctx context.Context) error {
+			return net.SetDelegate(qh)
+		},
+	})
+}
+
+// HandleRetrievalPayments records the payments received for legacy
+// (graphsync) retrieval deals
+func HandleRetrievalPayments(lc fx.Lifecycle, m lotus_retrievalmarket.RetrievalProvider, pm *payments.Manager) {
+	var unsub lotus_retrievalmarket.Unsubscribe
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			unsub = m.SubscribeToEvents(pm.OnLegacyRetrievalEvent)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			unsub()
+			return nil
+		},
+	})
+}
+
 func NewProtocolProxy(cfg *config.Boost) func(h host.Host) (*protocolproxy.ProtocolProxy, error) {
 	return func(h host.Host) (*protocolproxy.ProtocolProxy, error) {
 		peerConfig := map[peer.ID][]protocol.ID{}
@@ -0,0 +1,37 @@
+package modules
+
+import (
+	"fmt"
+
+	"github.com/filecoin-project/boost/node/config"
+	"github.com/filecoin-project/boost/sealeradapter"
+	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
+	smtypes "github.com/filecoin-project/boost/storagemarket/types"
+)
+
+// NewSealerAdapter returns the sealer adapter that deal data is handed off to
+func NewSealerAdapter(cfg *config.Boost) func(pa smtypes.PieceAdder, sps sealingpipeline.API) (smtypes.SealerAdapter, error) {
+	return func(pa smtypes.PieceAdder, sps sealingpipeline.API) (smtypes.SealerAdapter, error) {
+		switch cfg.Sealer.Type {
+		case "", sealeradapter.TypeLotus:
+			return sealeradapter.NewLotus(pa, sps), nil
+		case sealeradapter.TypeCurio:
+			if cfg.PieceWorkers.AddPiece.URL != "" {
+				log.Warnw("add piece worker is configured but the sealer type is curio: deal data will be sent to the sector intake API",
+					"url", cfg.PieceWorkers.AddPiece.URL)
+			}
+			c, err := sealeradapter.NewCurio(sealeradapter.CurioConfig{
+				URL:   cfg.Sealer.Curio.URL,
+				Token: cfg.Sealer.Curio.Token,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("creating curio sealer adapter: %w", err)
+			}
+			log.Infow("handing deal data off to sector intake API", "url", cfg.Sealer.Curio.URL)
+			return c, nil
+		default:
+			return nil, fmt.Errorf("unrecognized sealer type '%s': must be '%s' or '%s'",
+				cfg.Sealer.Type, sealeradapter.TypeLotus, sealeradapter.TypeCurio)
+		}
+	}
+}
@@ -7,27 +7,46 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"path/filepath"
 	"time"
 
+	"github.com/filecoin-project/boost/alerts"
 	"github.com/filecoin-project/boost/db"
 	"github.com/filecoin-project/boost/fundmanager"
 	"github.com/filecoin-project/boost/gql"
 	"github.com/filecoin-project/boost/indexprovider"
+	"github.com/filecoin-project/boost/legacyingest"
 	"github.com/filecoin-project/boost/markets/idxprov"
 	"github.com/filecoin-project/boost/markets/sectoraccessor"
 	"github.com/filecoin-project/boost/markets/storageadapter"
 	"github.com/filecoin-project/boost/node/config"
 	"github.com/filecoin-project/boost/node/impl/backupmgr"
+	"github.com/filecoin-project/boost/node/impl/common"
 	"github.com/filecoin-project/boost/node/modules/dtypes"
+	"github.com/filecoin-project/boost/piecedoctor"
+	"github.com/filecoin-project/boost/pieceremover"
+	"github.com/filecoin-project/boost/replication"
 	brm "github.com/filecoin-project/boost/retrievalmarket/lib"
+	"github.com/filecoin-project/boost/retrievalmarket/quota"
 	"github.com/filecoin-project/boost/retrievalmarket/rtvllog"
+	"github.com/filecoin-project/boost/retrievalmarket/server"
+	"github.com/filecoin-project/boost/retrievalpolicy"
+	"github.com/filecoin-project/boost/storage/flatstore"
 	"github.com/filecoin-project/boost/storagemanager"
 	"github.com/filecoin-project/boost/storagemarket"
+	"github.com/filecoin-project/boost/storagemarket/announcepolicy"
+	"github.com/filecoin-project/boost/storagemarket/backpressure"
+	"github.com/filecoin-project/boost/storagemarket/collateralpolicy"
 	"github.com/filecoin-project/boost/storagemarket/logs"
 	"github.com/filecoin-project/boost/storagemarket/lp2pimpl"
 	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
+	"github.com/filecoin-project/boost/storagemarket/sealingpriority"
+	"github.com/filecoin-project/boost/storagemarket/sectorpacking"
+	"github.com/filecoin-project/boost/storagemarket/sectorselection"
 	"github.com/filecoin-project/boost/storagemarket/types"
 	"github.com/filecoin-project/boost/transport/httptransport"
+	"github.com/filecoin-project/boost/unsealedcopy"
+	"github.com/filecoin-project/boost/webhooks"
 	"github.com/filecoin-project/boostd-data/shared/tracing"
 	"github.com/filecoin-project/dagstore"
 	"github.com/filecoin-project/dagstore/indexbs"
@@ -39,6 +58,8 @@ import (
 	storageimpl "github.com/filecoin-project/go-fil-markets/storagemarket/impl"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/storedask"
 	"github.com/filecoin-project/go-fil-markets/stores"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/builtin"
 	"github.com/filecoin-project/go-state-types/builtin/v9/account"
 	"github.com/filecoin-project/go-state-types/crypto"
@@ -56,6 +77,7 @@ import (
 	"github.com/filecoin-project/lotus/node/modules/helpers"
 	"github.com/filecoin-project/lotus/node/repo"
 	lotus_repo "github.com/filecoin-project/lotus/node/repo"
+	"github.com/filecoin-project/lotus/storage/sealer"
 	"github.com/filecoin-project/lotus/storage/sectorblocks"
 	"github.com/ipfs/go-cid"
 	provider "github.com/ipni/index-provider"
@@ -264,6 +286,57 @@ func NewGetMaxDealStartDelayFunc(r lotus_repo.LockedRepo) (dtypes.GetMaxDealStar
 	}, nil
 }
 
+func NewGetRetrievalPolicyFunc(r lotus_repo.LockedRepo) (dtypes.GetRetrievalPolicyFunc, error) {
+	return func() (out retrievalpolicy.Config, err error) {
+		err = readCfg(r, func(cfg *config.Boost) {
+			out = retrievalPolicyConfig(cfg.RetrievalPolicy)
+		})
+		return
+	}, nil
+}
+
+func NewSetRetrievalPolicyFunc(r lotus_repo.LockedRepo) (dtypes.SetRetrievalPolicyFunc, error) {
+	return func(policy retrievalpolicy.Config) (err error) {
+		// Check that the policy is valid before saving it
+		if _, err := retrievalpolicy.New(policy); err != nil {
+			return err
+		}
+
+		rules := make([]config.RetrievalPolicyRule, 0, len(policy.Rules))
+		for _, r := range policy.Rules {
+			rules = append(rules, config.RetrievalPolicyRule{
+				PieceCids:      r.PieceCids,
+				Access:         r.Access,
+				AllowedClients: r.AllowedClients,
+			})
+		}
+		err = mutateCfg(r, func(cfg *config.Boost) {
+			cfg.RetrievalPolicy = config.RetrievalPolicyConfig{
+				DefaultAccess:  policy.DefaultAccess,
+				AllowedClients: policy.AllowedClients,
+				Rules:          rules,
+			}
+		})
+		return
+	}, nil
+}
+
+func retrievalPolicyConfig(cfg config.RetrievalPolicyConfig) retrievalpolicy.Config {
+	rules := make([]retrievalpolicy.Rule, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		rules = append(rules, retrievalpolicy.Rule{
+			PieceCids:      r.PieceCids,
+			Access:         r.Access,
+			AllowedClients: r.AllowedClients,
+		})
+	}
+	return retrievalpolicy.Config{
+		DefaultAccess:  cfg.DefaultAccess,
+		AllowedClients: cfg.AllowedClients,
+		Rules:          rules,
+	}
+}
+
 func readCfg(r lotus_repo.LockedRepo, accessor func(*config.Boost)) error {
 	raw, err := r.Config()
 	if err != nil {
@@ -311,6 +384,14 @@ func NewBoostDB(r lotus_repo.LockedRepo) (*sql.DB, error) {
 	return db.SqlDB(dbPath)
 }
 
+// HandleSlowQueryLog sets the threshold above which database queries are
+// logged as slow
+func HandleSlowQueryLog(cfg *config.Boost) func() {
+	return func() {
+		db.SetSlowQueryThreshold(time.Duration(cfg.Database.SlowQueryThreshold))
+	}
+}
+
 type LogSqlDB struct {
 	db *sql.DB
 }
@@ -326,20 +407,421 @@ func NewLogsSqlDB(r repo.LockedRepo) (*LogSqlDB, error) {
 	return &LogSqlDB{d}, nil
 }
 
-func NewDealsDB(sqldb *sql.DB) *db.DealsDB {
-	return db.NewDealsDB(sqldb)
+// NewDealsDBCipher returns the cipher used to encrypt sensitive deal data at
+// rest, or nil if encryption is disabled
+func NewDealsDBCipher(cfg *config.Boost) func(ks ltypes.KeyStore) (*db.AESCipher, error) {
+	return func(ks ltypes.KeyStore) (*db.AESCipher, error) {
+		if !cfg.Encryption.Enabled {
+			return nil, nil
+		}
+
+		key, err := DealsDBEncryptionKey(cfg.Encryption.KeyCommand, ks)
+		if err != nil {
+			return nil, fmt.Errorf("getting deals db encryption key: %w", err)
+		}
+
+		return db.NewAESCipher(key)
+	}
+}
+
+func NewDealsDB(cfg *config.Boost) func(sqldb *sql.DB, c *db.AESCipher, cl *db.DealChangeLogDB) *db.DealsDB {
+	return func(sqldb *sql.DB, c *db.AESCipher, cl *db.DealChangeLogDB) *db.DealsDB {
+		dealsDB := db.NewDealsDB(sqldb)
+		if c != nil {
+			dealsDB = db.NewEncryptedDealsDB(sqldb, c)
+		}
+		if cfg.Replication.Enabled {
+			dealsDB.SetChangeLog(cl)
+		}
+		return dealsDB
+	}
+}
+
+func NewDealChangeLogDB(sqldb *sql.DB, c *db.AESCipher) *db.DealChangeLogDB {
+	if c != nil {
+		return db.NewEncryptedDealChangeLogDB(sqldb, c)
+	}
+	return db.NewDealChangeLogDB(sqldb)
+}
+
+// HandleReplication streams deal state changes to the configured sink
+func HandleReplication(cfg *config.Boost) func(lc fx.Lifecycle, cl *db.DealChangeLogDB) error {
+	return func(lc fx.Lifecycle, cl *db.DealChangeLogDB) error {
+		if !cfg.Replication.Enabled {
+			return nil
+		}
+
+		s, err := replication.NewStreamer(replication.Config{
+			SinkURL:         cfg.Replication.SinkURL,
+			AuthToken:       cfg.Replication.AuthToken,
+			CACertFile:      cfg.Replication.SinkCACertFile,
+			BatchSize:       cfg.Replication.BatchSize,
+			PollInterval:    time.Duration(cfg.Replication.PollInterval),
+			RetentionPeriod: time.Duration(cfg.Replication.RetentionPeriod),
+			MaxSinkLag:      time.Duration(cfg.Replication.MaxSinkLag),
+		}, cl)
+		if err != nil {
+			return fmt.Errorf("starting deal state replication: %w", err)
+		}
+
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				s.Start(context.Background())
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				s.Stop()
+				return nil
+			},
+		})
+		return nil
+	}
+}
+
+func NewAuditLogDB(sqldb *sql.DB) *db.AuditLogDB {
+	return db.NewAuditLogDB(sqldb)
+}
+
+func NewPendingAnnouncementsDB(sqldb *sql.DB) *db.PendingAnnouncementsDB {
+	return db.NewPendingAnnouncementsDB(sqldb)
+}
+
+func NewRemovedAnnouncementsDB(sqldb *sql.DB) *db.RemovedAnnouncementsDB {
+	return db.NewRemovedAnnouncementsDB(sqldb)
+}
+
+func NewWebhooksDB(sqldb *sql.DB, c *db.AESCipher) *db.WebhooksDB {
+	if c != nil {
+		return db.NewEncryptedWebhooksDB(sqldb, c)
+	}
+	return db.NewWebhooksDB(sqldb)
+}
+
+// NewWebhookDispatcher sends audit events to the webhooks registered
+// through the graphql API
+func NewWebhookDispatcher(lc fx.Lifecycle, whDB *db.WebhooksDB, auditDB *db.AuditLogDB) *webhooks.Dispatcher {
+	d := webhooks.NewDispatcher(whDB, auditDB, time.Second)
+
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			d.Start(context.Background())
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			d.Stop()
+			return nil
+		},
+	})
+
+	return d
+}
+
+// HandleFundsTopUp starts the automatic top-up of the escrow and miner
+// balances from the source wallet, if one is configured
+func HandleFundsTopUp(cfg *config.Boost) func(lc fx.Lifecycle, fm *fundmanager.FundManager, fullnodeApi v1api.FullNode) error {
+	return func(lc fx.Lifecycle, fm *fundmanager.FundManager, fullnodeApi v1api.FullNode) error {
+		tcfg := cfg.FundsTopUp
+		if tcfg.SourceWallet == "" {
+			return nil
+		}
+
+		src, err := address.NewFromString(tcfg.SourceWallet)
+		if err != nil {
+			return fmt.Errorf("parsing FundsTopUp.SourceWallet '%s': %w", tcfg.SourceWallet, err)
+		}
+		if big.Cmp(big.Int(tcfg.EscrowThreshold), big.Int(tcfg.EscrowTarget)) > 0 {
+			return fmt.Errorf("FundsTopUp.EscrowThreshold %s must not be greater than FundsTopUp.EscrowTarget %s",
+				tcfg.EscrowThreshold, tcfg.EscrowTarget)
+		}
+		if big.Cmp(big.Int(tcfg.PledgeThreshold), big.Int(tcfg.PledgeTarget)) > 0 {
+			return fmt.Errorf("FundsTopUp.PledgeThreshold %s must not be greater than FundsTopUp.PledgeTarget %s",
+				tcfg.PledgeThreshold, tcfg.PledgeTarget)
+		}
+
+		t := fundmanager.NewTopUp(fundmanager.TopUpConfig{
+			SourceWallet:     src,
+			CheckInterval:    time.Duration(tcfg.CheckInterval),
+			EscrowThreshold:  abi.TokenAmount(tcfg.EscrowThreshold),
+			EscrowTarget:     abi.TokenAmount(tcfg.EscrowTarget),
+			PledgeThreshold:  abi.TokenAmount(tcfg.PledgeThreshold),
+			PledgeTarget:     abi.TokenAmount(tcfg.PledgeTarget),
+			MaxTopUp:         abi.TokenAmount(tcfg.MaxTopUp),
+			MaxPerDay:        abi.TokenAmount(tcfg.MaxPerDay),
+			MinSourceBalance: abi.TokenAmount(tcfg.MinSourceBalance),
+			LandTimeout:      time.Duration(tcfg.LandTimeout),
+		}, fm, fullnodeApi)
+
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				t.Start(context.Background())
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				t.Stop()
+				return nil
+			},
+		})
+
+		return nil
+	}
+}
+
+// HandleFundsAlerts starts the low balance alerts, if any alert threshold is
+// configured
+func HandleFundsAlerts(cfg *config.Boost) func(lc fx.Lifecycle, fm *fundmanager.FundManager, auditDB *db.AuditLogDB) error {
+	return func(lc fx.Lifecycle, fm *fundmanager.FundManager, auditDB *db.AuditLogDB) error {
+		acfg := cfg.FundsAlerts
+		escrowThreshold := abi.TokenAmount(acfg.EscrowThreshold)
+		pubMsgThreshold := abi.TokenAmount(acfg.PublishMsgThreshold)
+		collatThreshold := abi.TokenAmount(acfg.CollateralThreshold)
+		if escrowThreshold.IsZero() && pubMsgThreshold.IsZero() && collatThreshold.IsZero() {
+			return nil
+		}
+
+		pubMsgWallets := []address.Address{fm.AddressPublishMsg()}
+		for _, w := range cfg.Wallets.AdditionalPublishStorageDeals {
+			addr, err := address.NewFromString(w)
+			if err != nil {
+				return fmt.Errorf("parsing Wallets.AdditionalPublishStorageDeals '%s': %w", w, err)
+			}
+			pubMsgWallets = append(pubMsgWallets, addr)
+		}
+
+		collatWallets := []address.Address{fm.AddressDealCollateral()}
+		for _, r := range cfg.Wallets.DealCollateralRules {
+			addr, err := address.NewFromString(r.Wallet)
+			if err != nil {
+				return fmt.Errorf("parsing Wallets.DealCollateralRules wallet '%s': %w", r.Wallet, err)
+			}
+			if !containsAddress(collatWallets, addr) {
+				collatWallets = append(collatWallets, addr)
+			}
+		}
+
+		a := fundmanager.NewBalanceAlerts(fundmanager.BalanceAlertsConfig{
+			CheckInterval:       time.Duration(acfg.CheckInterval),
+			EscrowThreshold:     escrowThreshold,
+			PublishMsgThreshold: pubMsgThreshold,
+			PublishMsgWallets:   pubMsgWallets,
+			CollateralThreshold: collatThreshold,
+			CollateralWallets:   collatWallets,
+		}, fm, auditDB)
+
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				a.Start(context.Background())
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				a.Stop()
+				return nil
+			},
+		})
+
+		return nil
+	}
+}
+
+// HandleAlerts starts the alert rules engine, if any alert rules are
+// configured
+func HandleAlerts(cfg *config.Boost) func(lc fx.Lifecycle, dealsDB *db.DealsDB, auditDB *db.AuditLogDB, annDB *db.PendingAnnouncementsDB) error {
+	return func(lc fx.Lifecycle, dealsDB *db.DealsDB, auditDB *db.AuditLogDB, annDB *db.PendingAnnouncementsDB) error {
+		if len(cfg.Alerts.Rules) == 0 {
+			return nil
+		}
+
+		rules := make([]alerts.Rule, 0, len(cfg.Alerts.Rules))
+		for _, r := range cfg.Alerts.Rules {
+			rules = append(rules, alerts.Rule{
+				Name:      r.Name,
+				Kind:      r.Kind,
+				Threshold: r.Threshold,
+				Window:    time.Duration(r.Window),
+			})
+		}
+
+		e, err := alerts.New(alerts.Config{
+			CheckInterval: time.Duration(cfg.Alerts.CheckInterval),
+			Rules:         rules,
+		}, dealsDB, auditDB, annDB)
+		if err != nil {
+			return fmt.Errorf("parsing Alerts config: %w", err)
+		}
+
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				e.Start(context.Background())
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				e.Stop()
+				return nil
+			},
+		})
+
+		return nil
+	}
+}
+
+func containsAddress(addrs []address.Address, addr address.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// NewPieceDoctor periodically checks piece indexes against the unsealed
+// data for each piece
+func NewPieceDoctor(cfg *config.Boost) func(lc fx.Lifecycle, dagst dagstore.Interface, w *mdagstore.Wrapper, ps lotus_dtypes.ProviderPieceStore, sa retrievalmarket.SectorAccessor) (*piecedoctor.Doctor, error) {
+	return func(lc fx.Lifecycle, dagst dagstore.Interface, w *mdagstore.Wrapper, ps lotus_dtypes.ProviderPieceStore, sa retrievalmarket.SectorAccessor) (*piecedoctor.Doctor, error) {
+		d, err := piecedoctor.NewDoctor(piecedoctor.Config{
+			CheckInterval:  time.Duration(cfg.PieceDoctor.CheckInterval),
+			PiecesPerCheck: cfg.PieceDoctor.PiecesPerCheck,
+			BlocksPerPiece: cfg.PieceDoctor.BlocksPerPiece,
+			AutoRepair:     cfg.PieceDoctor.AutoRepair,
+		}, dagst, w, ps, sa)
+		if err != nil {
+			return nil, fmt.Errorf("creating piece doctor: %w", err)
+		}
+
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				d.Start(context.Background())
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				d.Stop()
+				return nil
+			},
+		})
+
+		return d, nil
+	}
+}
+
+// HandleLegacyDealIngest periodically adds legacy deals that are missing
+// from the piece store or dagstore
+func HandleLegacyDealIngest(cfg *config.Boost) func(lc fx.Lifecycle, legacySP lotus_storagemarket.StorageProvider, spApi sealingpipeline.API, ps lotus_dtypes.ProviderPieceStore, dagst dagstore.Interface, w *mdagstore.Wrapper) {
+	return func(lc fx.Lifecycle, legacySP lotus_storagemarket.StorageProvider, spApi sealingpipeline.API, ps lotus_dtypes.ProviderPieceStore, dagst dagstore.Interface, w *mdagstore.Wrapper) {
+		i := legacyingest.NewIngester(legacyingest.Config{
+			CheckInterval:      time.Duration(cfg.LegacyDealIngest.CheckInterval),
+			MaxIndexesPerCheck: cfg.LegacyDealIngest.MaxIndexesPerCheck,
+		}, legacySP, spApi, ps, dagst, w)
+
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				i.Start(context.Background())
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				i.Stop()
+				return nil
+			},
+		})
+	}
+}
+
+// NewFlatStore opens the flat store that keeps a copy of each deal's data
+// after it's handed off to the sealer. It returns nil if the flat store is
+// disabled.
+func NewFlatStore(cfg *config.Boost) func(r repo.LockedRepo) (*flatstore.Store, error) {
+	return func(r repo.LockedRepo) (*flatstore.Store, error) {
+		if !cfg.FlatStore.Enabled {
+			return nil, nil
+		}
+
+		dir := cfg.FlatStore.Dir
+		if dir == "" {
+			dir = filepath.Join(r.Path(), "flatstore")
+		}
+		return flatstore.New(flatstore.Config{
+			Dir:            dir,
+			MaxBytes:       uint64(cfg.FlatStore.MaxBytes),
+			EvictionPolicy: cfg.FlatStore.EvictionPolicy,
+		})
+	}
+}
+
+// NewMinerAPI creates the dagstore mount API. If the flat store is enabled,
+// pieces that have a copy in the flat store are read from the copy instead
+// of being unsealed.
+func NewMinerAPI(cfg *config.Boost) func(lc fx.Lifecycle, r repo.LockedRepo, ps lotus_dtypes.ProviderPieceStore, sa mdagstore.SectorAccessor, fs *flatstore.Store) (mdagstore.MinerAPI, error) {
+	newMinerAPI := modules.NewMinerAPI(cfg.DAGStore)
+	return func(lc fx.Lifecycle, r repo.LockedRepo, ps lotus_dtypes.ProviderPieceStore, sa mdagstore.SectorAccessor, fs *flatstore.Store) (mdagstore.MinerAPI, error) {
+		mountApi, err := newMinerAPI(lc, r, ps, sa)
+		if err != nil || fs == nil {
+			return mountApi, err
+		}
+		return flatstore.NewMinerAPI(mountApi, fs), nil
+	}
+}
+
+// NewUnsealedCopyManager reports on, and unseals or removes, the unsealed
+// copies of pieces
+func NewUnsealedCopyManager(lc fx.Lifecycle, miner unsealedcopy.MinerAPI, ps lotus_dtypes.ProviderPieceStore, sa retrievalmarket.SectorAccessor, dagst dagstore.Interface, dealsDB *db.DealsDB) *unsealedcopy.Manager {
+	m := unsealedcopy.NewManager(miner, ps, sa, dagst, dealsDB)
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			m.Stop()
+			return nil
+		},
+	})
+	return m
+}
+
+// NewPieceRemover removes the records for a piece across subsystems
+func NewPieceRemover(ps lotus_dtypes.ProviderPieceStore, ds lotus_dtypes.MetadataDS, dagst dagstore.Interface, w *mdagstore.Wrapper, ip *indexprovider.Wrapper, mhc *brm.MultihashLookupCache, fs *flatstore.Store) *pieceremover.Remover {
+	// The multihash lookup cache and the flat store are nil when they are
+	// disabled. Pass them as untyped nils, because a nil pointer in an
+	// interface is not equal to nil, and the remover would call it.
+	var cache interface{ RemoveShard(shard.Key) int }
+	if mhc != nil {
+		cache = mhc
+	}
+	var flat interface {
+		Has(cid.Cid) bool
+		Remove(cid.Cid) (bool, error)
+	}
+	if fs != nil {
+		flat = fs
+	}
+	return pieceremover.NewRemover(ps, ds, dagst, w, ip, cache, flat)
 }
 
-func NewLogsDB(logsSqlDB *LogSqlDB) *db.LogsDB {
-	return db.NewLogsDB(logsSqlDB.db)
+func NewLogsDB(cfg *config.Boost) func(logsSqlDB *LogSqlDB, cl *db.DealChangeLogDB) *db.LogsDB {
+	return func(logsSqlDB *LogSqlDB, cl *db.DealChangeLogDB) *db.LogsDB {
+		logsDB := db.NewLogsDB(logsSqlDB.db)
+		if cfg.Replication.Enabled {
+			logsDB.SetChangeLog(cl)
+		}
+		return logsDB
+	}
 }
 
 func NewProposalLogsDB(sqldb *sql.DB) *db.ProposalLogsDB {
 	return db.NewProposalLogsDB(sqldb)
 }
 
-func NewFundsDB(sqldb *sql.DB) *db.FundsDB {
-	return db.NewFundsDB(sqldb)
+func NewFundsDB(cfg *config.Boost) func(sqldb *sql.DB, cl *db.DealChangeLogDB) *db.FundsDB {
+	return func(sqldb *sql.DB, cl *db.DealChangeLogDB) *db.FundsDB {
+		fundsDB := db.NewFundsDB(sqldb)
+		if cfg.Replication.Enabled {
+			fundsDB.SetChangeLog(cl)
+		}
+		return fundsDB
+	}
+}
+
+func NewStorageDB(cfg *config.Boost) func(sqldb *sql.DB, cl *db.DealChangeLogDB) *db.StorageDB {
+	return func(sqldb *sql.DB, cl *db.DealChangeLogDB) *db.StorageDB {
+		storageDB := db.NewStorageDB(sqldb)
+		if cfg.Replication.Enabled {
+			storageDB.SetChangeLog(cl)
+		}
+		return storageDB
+	}
 }
 
 func HandleLegacyDeals(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, lsp lotus_storagemarket.StorageProvider, j journal.Journal) error {
@@ -538,13 +1020,13 @@ func NewLegacyStorageProvider(cfg *config.Boost) func(minerAddress lotus_dtypes.
 	}
 }
 
-func NewStorageMarketProvider(provAddr address.Address, cfg *config.Boost) func(lc fx.Lifecycle, h host.Host, a v1api.FullNode, sqldb *sql.DB, dealsDB *db.DealsDB, fundMgr *fundmanager.FundManager, storageMgr *storagemanager.StorageManager, dp *storageadapter.DealPublisher, secb *sectorblocks.SectorBlocks, commpc types.CommpCalculator, sps sealingpipeline.API, df dtypes.StorageDealFilter, logsSqlDB *LogSqlDB, logsDB *db.LogsDB, dagst *mdagstore.Wrapper, ps lotus_dtypes.ProviderPieceStore, ip *indexprovider.Wrapper, lp lotus_storagemarket.StorageProvider, cdm *storagemarket.ChainDealManager) (*storagemarket.Provider, error) {
-	return func(lc fx.Lifecycle, h host.Host, a v1api.FullNode, sqldb *sql.DB, dealsDB *db.DealsDB,
-		fundMgr *fundmanager.FundManager, storageMgr *storagemanager.StorageManager, dp *storageadapter.DealPublisher, secb *sectorblocks.SectorBlocks,
+func NewStorageMarketProvider(provAddr address.Address, cfg *config.Boost) func(lc fx.Lifecycle, h host.Host, a v1api.FullNode, sqldb *sql.DB, dealsDB *db.DealsDB, auditDB *db.AuditLogDB, fundMgr *fundmanager.FundManager, storageMgr *storagemanager.StorageManager, dp *storageadapter.DealPublisher, sealer types.SealerAdapter, commpc types.CommpCalculator, sps sealingpipeline.API, df dtypes.StorageDealDecider, logsSqlDB *LogSqlDB, logsDB *db.LogsDB, dagst *mdagstore.Wrapper, ps lotus_dtypes.ProviderPieceStore, ip *indexprovider.Wrapper, lp lotus_storagemarket.StorageProvider, cdm *storagemarket.ChainDealManager, fs *flatstore.Store, mapi mdagstore.MinerAPI) (*storagemarket.Provider, error) {
+	return func(lc fx.Lifecycle, h host.Host, a v1api.FullNode, sqldb *sql.DB, dealsDB *db.DealsDB, auditDB *db.AuditLogDB,
+		fundMgr *fundmanager.FundManager, storageMgr *storagemanager.StorageManager, dp *storageadapter.DealPublisher, sealer types.SealerAdapter,
 		commpc types.CommpCalculator, sps sealingpipeline.API,
-		df dtypes.StorageDealFilter, logsSqlDB *LogSqlDB, logsDB *db.LogsDB,
+		df dtypes.StorageDealDecider, logsSqlDB *LogSqlDB, logsDB *db.LogsDB,
 		dagst *mdagstore.Wrapper, ps lotus_dtypes.ProviderPieceStore, ip *indexprovider.Wrapper,
-		lp lotus_storagemarket.StorageProvider, cdm *storagemarket.ChainDealManager) (*storagemarket.Provider, error) {
+		lp lotus_storagemarket.StorageProvider, cdm *storagemarket.ChainDealManager, fs *flatstore.Store, mapi mdagstore.MinerAPI) (*storagemarket.Provider, error) {
 
 		prvCfg := storagemarket.Config{
 			MaxTransferDuration:     time.Duration(cfg.Dealmaking.MaxTransferDuration),
@@ -558,11 +1040,48 @@ func NewStorageMarketProvider(provAddr address.Address, cfg *config.Boost) func(
 			DealLogDurationDays:         cfg.Dealmaking.DealLogDurationDays,
 			StorageFilter:               cfg.Dealmaking.Filter,
 			SealingPipelineCacheTimeout: time.Duration(cfg.Dealmaking.SealingPipelineCacheTimeout),
+			AnnouncePolicy:              announcePolicyConfig(cfg.AnnouncePolicy),
+			CollateralPolicy:            collateralPolicyConfig(cfg.Wallets.DealCollateralRules),
+			UnsealedCopyPolicy:          cfg.Dealmaking.UnsealedCopyPolicy,
+			DeduplicatePieces:           cfg.Dealmaking.DeduplicatePieces,
+			StreamingAddPiece:           cfg.Dealmaking.StreamingAddPiece,
+			SealingBackpressure: backpressure.Config{
+				Enabled:          cfg.Backpressure.Enabled,
+				SampleInterval:   time.Duration(cfg.Backpressure.SampleInterval),
+				ThroughputWindow: time.Duration(cfg.Backpressure.ThroughputWindow),
+				TargetBacklog:    time.Duration(cfg.Backpressure.TargetBacklog),
+				BurstDuration:    time.Duration(cfg.Backpressure.BurstDuration),
+				MinSectorsPerDay: cfg.Backpressure.MinSectorsPerDay,
+			},
+			SectorPacking: sectorpacking.Config{
+				Policy:            cfg.SectorPacking.Policy,
+				BatchWindow:       time.Duration(cfg.SectorPacking.BatchWindow),
+				EndEpochTolerance: abi.ChainEpoch(time.Duration(cfg.SectorPacking.EndEpochTolerance).Seconds()) / builtin.EpochDurationSeconds,
+			},
+			SealingPriority: sealingpriority.Config{
+				Enabled:              cfg.SealingPriority.Enabled,
+				CheckInterval:        time.Duration(cfg.SealingPriority.CheckInterval),
+				ExpectedSealDuration: time.Duration(cfg.SealingPriority.ExpectedSealDuration),
+				PriorityMargin:       time.Duration(cfg.SealingPriority.PriorityMargin),
+			},
+			SectorSelection: sectorselection.Config{
+				Preference:          cfg.SectorSelection.Preference,
+				MaxSnapPieceSize:    abi.PaddedPieceSize(cfg.SectorSelection.MaxSnapPieceSize),
+				MinLifetimeMargin:   abi.ChainEpoch(time.Duration(cfg.SectorSelection.MinLifetimeMargin).Seconds()) / builtin.EpochDurationSeconds,
+				MaxDurationMismatch: abi.ChainEpoch(time.Duration(cfg.SectorSelection.MaxDurationMismatch).Seconds()) / builtin.EpochDurationSeconds,
+			},
 		}
 		dl := logs.NewDealLogger(logsDB)
 		tspt := httptransport.New(h, dl)
-		prov, err := storagemarket.NewProvider(prvCfg, sqldb, dealsDB, fundMgr, storageMgr, a, dp, provAddr, secb, commpc,
-			sps, cdm, df, logsSqlDB.db, logsDB, dagst, ps, ip, lp, &signatureVerifier{a}, dl, tspt)
+		var pcs types.PieceCopyStore
+		if fs != nil {
+			pcs = fs
+		}
+		// Deduplicated deals copy the piece data from the flat store, or from
+		// an unsealed copy of the piece in a sector
+		epc := flatstore.NewPieceCopier(fs, mapi)
+		prov, err := storagemarket.NewProvider(prvCfg, sqldb, dealsDB, auditDB, fundMgr, storageMgr, a, dp, provAddr, sealer, commpc,
+			sps, cdm, df, logsSqlDB.db, logsDB, dagst, ps, ip, lp, &signatureVerifier{a}, dl, tspt, pcs, epc)
 		if err != nil {
 			return nil, err
 		}
@@ -571,14 +1090,43 @@ func NewStorageMarketProvider(provAddr address.Address, cfg *config.Boost) func(
 	}
 }
 
-func NewGraphqlServer(cfg *config.Boost) func(lc fx.Lifecycle, r repo.LockedRepo, h host.Host, prov *storagemarket.Provider, dealsDB *db.DealsDB, logsDB *db.LogsDB, retDB *rtvllog.RetrievalLogDB, plDB *db.ProposalLogsDB, fundsDB *db.FundsDB, fundMgr *fundmanager.FundManager, storageMgr *storagemanager.StorageManager, publisher *storageadapter.DealPublisher, spApi sealingpipeline.API, legacyProv lotus_storagemarket.StorageProvider, legacyDT lotus_dtypes.ProviderDataTransfer, ps lotus_dtypes.ProviderPieceStore, sa retrievalmarket.SectorAccessor, dagst dagstore.Interface, fullNode v1api.FullNode) *gql.Server {
-	return func(lc fx.Lifecycle, r repo.LockedRepo, h host.Host, prov *storagemarket.Provider, dealsDB *db.DealsDB, logsDB *db.LogsDB, retDB *rtvllog.RetrievalLogDB, plDB *db.ProposalLogsDB, fundsDB *db.FundsDB, fundMgr *fundmanager.FundManager,
+func announcePolicyConfig(cfg config.AnnouncePolicyConfig) announcepolicy.Config {
+	rules := make([]announcepolicy.Rule, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		rules = append(rules, announcepolicy.Rule{
+			Clients:     r.Clients,
+			LabelPrefix: r.LabelPrefix,
+			Action:      r.Action,
+		})
+	}
+	return announcepolicy.Config{
+		DefaultAction: cfg.DefaultAction,
+		Rules:         rules,
+	}
+}
+
+func collateralPolicyConfig(cfgRules []config.DealCollateralRule) collateralpolicy.Config {
+	rules := make([]collateralpolicy.Rule, 0, len(cfgRules))
+	for _, r := range cfgRules {
+		rules = append(rules, collateralpolicy.Rule{
+			Clients:      r.Clients,
+			Verified:     r.Verified,
+			MinPieceSize: abi.PaddedPieceSize(r.MinPieceSize),
+			MaxPieceSize: abi.PaddedPieceSize(r.MaxPieceSize),
+			Wallet:       r.Wallet,
+		})
+	}
+	return collateralpolicy.Config{Rules: rules}
+}
+
+func NewGraphqlServer(cfg *config.Boost) func(lc fx.Lifecycle, r repo.LockedRepo, h host.Host, prov *storagemarket.Provider, dealsDB *db.DealsDB, logsDB *db.LogsDB, retDB *rtvllog.RetrievalLogDB, plDB *db.ProposalLogsDB, auditDB *db.AuditLogDB, fundsDB *db.FundsDB, fundMgr *fundmanager.FundManager, storageMgr *storagemanager.StorageManager, publisher *storageadapter.DealPublisher, spApi sealingpipeline.API, legacyProv lotus_storagemarket.StorageProvider, legacyDT lotus_dtypes.ProviderDataTransfer, ps lotus_dtypes.ProviderPieceStore, sa retrievalmarket.SectorAccessor, dagst dagstore.Interface, fullNode v1api.FullNode, apiAlg *lotus_dtypes.APIAlg, wh *webhooks.Dispatcher, ip *indexprovider.Wrapper, pd *piecedoctor.Doctor, uq *sectoraccessor.UnsealQueue, rask *server.RetrievalAsk, quotas *quota.Quotas) *gql.Server {
+	return func(lc fx.Lifecycle, r repo.LockedRepo, h host.Host, prov *storagemarket.Provider, dealsDB *db.DealsDB, logsDB *db.LogsDB, retDB *rtvllog.RetrievalLogDB, plDB *db.ProposalLogsDB, auditDB *db.AuditLogDB, fundsDB *db.FundsDB, fundMgr *fundmanager.FundManager,
 		storageMgr *storagemanager.StorageManager, publisher *storageadapter.DealPublisher, spApi sealingpipeline.API,
 		legacyProv lotus_storagemarket.StorageProvider, legacyDT lotus_dtypes.ProviderDataTransfer,
-		ps lotus_dtypes.ProviderPieceStore, sa retrievalmarket.SectorAccessor, dagst dagstore.Interface, fullNode v1api.FullNode) *gql.Server {
+		ps lotus_dtypes.ProviderPieceStore, sa retrievalmarket.SectorAccessor, dagst dagstore.Interface, fullNode v1api.FullNode, apiAlg *lotus_dtypes.APIAlg, wh *webhooks.Dispatcher, ip *indexprovider.Wrapper, pd *piecedoctor.Doctor, uq *sectoraccessor.UnsealQueue, rask *server.RetrievalAsk, quotas *quota.Quotas) *gql.Server {
 
-		resolver := gql.NewResolver(cfg, r, h, dealsDB, logsDB, retDB, plDB, fundsDB, fundMgr, storageMgr, spApi, prov, legacyProv, legacyDT, ps, sa, dagst, publisher, fullNode)
-		server := gql.NewServer(resolver)
+		resolver := gql.NewResolver(cfg, r, h, dealsDB, logsDB, retDB, plDB, auditDB, fundsDB, fundMgr, storageMgr, spApi, prov, legacyProv, legacyDT, ps, sa, dagst, publisher, fullNode, wh, ip, pd, uq, rask, quotas)
+		server := gql.NewServer(resolver, (&common.CommonAPI{APISecret: apiAlg}).AuthVerify)
 
 		lc.Append(fx.Hook{
 			OnStart: server.Start,
@@ -597,6 +1145,24 @@ func NewSectorAccessor(cfg *config.Boost) sectoraccessor.SectorAccessorConstruct
 	return sectoraccessor.NewCachingSectorAccessor(maxCacheSize, time.Duration(cfg.Dealmaking.IsUnsealedCacheExpiry))
 }
 
+// NewUnsealQueue creates the sector accessor used to read pieces from
+// sectors. Unseals are queued and deduplicated, and the number of unseals
+// that run concurrently is limited.
+func NewUnsealQueue(cfg *config.Boost) func(lc fx.Lifecycle, maddr lotus_dtypes.MinerAddress, secb sectorblocks.SectorBuilder, pp sealer.PieceProvider, full v1api.FullNode) *sectoraccessor.UnsealQueue {
+	newSectorAccessor := NewSectorAccessor(cfg)
+	return func(lc fx.Lifecycle, maddr lotus_dtypes.MinerAddress, secb sectorblocks.SectorBuilder, pp sealer.PieceProvider, full v1api.FullNode) *sectoraccessor.UnsealQueue {
+		sa := newSectorAccessor(maddr, secb, pp, full)
+		q := sectoraccessor.NewUnsealQueue(sa, cfg.Dealmaking.UnsealQueueMaxConcurrent)
+		lc.Append(fx.Hook{
+			OnStop: func(ctx context.Context) error {
+				q.Close()
+				return nil
+			},
+		})
+		return q
+	}
+}
+
 // ShardSelector helps to resolve a circular dependency:
 // The IndexBackedBlockstore has a shard selector, which needs to query the
 // RetrievalProviderNode's ask to find out if it's free to retrieve a
@@ -627,27 +1193,43 @@ func NewShardSelector() *ShardSelector {
 	return ss
 }
 
-func SetShardSelectorFunc(lc fx.Lifecycle, shardSelector *ShardSelector, ps lotus_dtypes.ProviderPieceStore, sa retrievalmarket.SectorAccessor, rp retrievalmarket.RetrievalProvider) error {
-	ctx, cancel := context.WithCancel(context.Background())
-	lc.Append(fx.Hook{
-		OnStop: func(ctx context.Context) error {
-			cancel()
-			return nil
-		},
-	})
+func SetShardSelectorFunc(cfg *config.Boost) func(lc fx.Lifecycle, shardSelector *ShardSelector, ps lotus_dtypes.ProviderPieceStore, sa retrievalmarket.SectorAccessor, rp retrievalmarket.RetrievalProvider) error {
+	return func(lc fx.Lifecycle, shardSelector *ShardSelector, ps lotus_dtypes.ProviderPieceStore, sa retrievalmarket.SectorAccessor, rp retrievalmarket.RetrievalProvider) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		lc.Append(fx.Hook{
+			OnStop: func(ctx context.Context) error {
+				cancel()
+				return nil
+			},
+		})
 
-	ss, err := brm.NewShardSelector(ctx, ps, sa, rp)
-	if err != nil {
-		return fmt.Errorf("creating shard selector: %w", err)
-	}
+		ss, err := brm.NewShardSelector(ctx, ps, sa, rp)
+		if err != nil {
+			return fmt.Errorf("creating shard selector: %w", err)
+		}
+		// When boost serves graphsync retrievals natively, pieces that are
+		// free to unseal are unsealed on demand
+		ss.UnsealOnDemand = cfg.Dealmaking.NativeGraphsyncRetrievals
 
-	shardSelector.Target = ss.ShardSelectorF
+		shardSelector.Target = ss.ShardSelectorF
 
-	return nil
+		return nil
+	}
 }
 
-func NewIndexBackedBlockstore(cfg *config.Boost) func(lc fx.Lifecycle, dagst dagstore.Interface, ss *ShardSelector) (dtypes.IndexBackedBlockstore, error) {
-	return func(lc fx.Lifecycle, dagst dagstore.Interface, ss *ShardSelector) (dtypes.IndexBackedBlockstore, error) {
+// NewMultihashLookupCache caches multihash -> piece lookups for retrievals.
+// It returns nil if the cache is disabled.
+func NewMultihashLookupCache(cfg *config.Boost) func(dagst dagstore.Interface) (*brm.MultihashLookupCache, error) {
+	return func(dagst dagstore.Interface) (*brm.MultihashLookupCache, error) {
+		if cfg.Dealmaking.MultihashLookupCacheSize <= 0 {
+			return nil, nil
+		}
+		return brm.NewMultihashLookupCache(dagst, cfg.Dealmaking.MultihashLookupCacheSize, time.Duration(cfg.Dealmaking.MultihashLookupCacheExpiry))
+	}
+}
+
+func NewIndexBackedBlockstore(cfg *config.Boost) func(lc fx.Lifecycle, dagst dagstore.Interface, mhc *brm.MultihashLookupCache, ss *ShardSelector) (dtypes.IndexBackedBlockstore, error) {
+	return func(lc fx.Lifecycle, dagst dagstore.Interface, mhc *brm.MultihashLookupCache, ss *ShardSelector) (dtypes.IndexBackedBlockstore, error) {
 		ctx, cancel := context.WithCancel(context.Background())
 		lc.Append(fx.Hook{
 			OnStop: func(ctx context.Context) error {
@@ -656,7 +1238,13 @@ func NewIndexBackedBlockstore(cfg *config.Boost) func(lc fx.Lifecycle, dagst dag
 			},
 		})
 
-		ibsds := brm.NewIndexBackedBlockstoreDagstore(dagst)
+		if mhc != nil {
+			dagst = mhc
+		}
+
+		// The blockstore serves retrievals, so queue any unseals it needs at
+		// the priority for retrievals
+		ibsds := brm.NewIndexBackedBlockstoreDagstore(sectoraccessor.DagstoreWithUnsealPriority(dagst, sectoraccessor.UnsealPriorityNormal))
 		rbs, err := indexbs.NewIndexBackedBlockstore(ctx, ibsds, ss.Proxy, cfg.Dealmaking.BlockstoreCacheMaxShards, time.Duration(cfg.Dealmaking.BlockstoreCacheExpiry))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create index backed blockstore: %w", err)
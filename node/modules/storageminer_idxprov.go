@@ -3,9 +3,7 @@ package modules
 import (
 	"context"
 
-	"github.com/filecoin-project/boost/build"
 	"github.com/filecoin-project/boost/indexprovider"
-	"github.com/filecoin-project/boost/node/modules/dtypes"
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/lotus/node/config"
 	lotus_dtypes "github.com/filecoin-project/lotus/node/modules/dtypes"
@@ -34,15 +32,9 @@ func IndexProvider(cfg config.IndexProviderConfig) func(params IdxProv, marketHo
 		}
 	}
 	return func(args IdxProv, marketHost host.Host, dt lotus_dtypes.ProviderDataTransfer, maddr lotus_dtypes.MinerAddress, ps *pubsub.PubSub, nn lotus_dtypes.NetworkName) (provider.Interface, error) {
-		topicName := cfg.TopicName
 		// If indexer topic name is left empty, infer it from the network name.
-		if topicName == "" {
-			// Use the same mechanism as the Dependency Injection (DI) to construct the topic name,
-			// so that we are certain it is consistent with the name allowed by the subscription
-			// filter.
-			//
-			// See: lp2p.GossipSub.
-			topicName = build.IndexerIngestTopic(dtypes.NetworkName(nn))
+		topicName := indexprovider.TopicName(cfg.TopicName, nn)
+		if cfg.TopicName == "" {
 			log.Debugw("Inferred indexer topic from network name", "topic", topicName)
 		}
 
@@ -1,11 +1,15 @@
 package node
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	_ "net/http/pprof"
+	"strconv"
 
 	"github.com/gorilla/mux"
 	logging "github.com/ipfs/go-log/v2"
@@ -70,11 +74,29 @@ func BoostHandler(a api.Boost, permissioned bool) (http.Handler, error) {
 	readerHandler, readerServerOpt := rpcenc.ReaderParamDecoder()
 	rpcServer := jsonrpc.NewServer(readerServerOpt)
 	rpcServer.Register("Filecoin", mapi)
+	rpcServer.AliasMethod("rpc.discover", "Filecoin.Discover")
 
-	m.Handle("/rpc/v0", rpcServer)
+	m.Handle("/rpc/v0", rpcVersionHandler(api.BoostAPIVersion0, api.RPCDeprecations, rpcServer))
 	m.Handle("/rpc/streams/v0/push/{uuid}", readerHandler)
+	m.HandleFunc("/rpc/openrpc.json", func(w http.ResponseWriter, r *http.Request) {
+		doc, err := a.Discover(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(doc)
+	})
+	m.HandleFunc("/rpc/changelog.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(api.GetChangelog())
+	})
 	m.PathPrefix("/remote").HandlerFunc(a.(*impl.BoostAPI).ServeRemote(permissioned))
 
+	// The health check doesn't require a token, so that it can be used by
+	// orchestration probes
+	m.Handle("/healthz", a.(*impl.BoostAPI).Health)
+
 	// debugging
 	m.Handle("/metrics", metrics.Exporter("boost"))
 	m.PathPrefix("/").Handler(http.DefaultServeMux) // pprof
@@ -89,3 +111,68 @@ func BoostHandler(a api.Boost, permissioned bool) (http.Handler, error) {
 	}
 	return ah, nil
 }
+
+// rpcVersionHandler sets the API version header on each response, and
+// rejects requests from clients that were written against an API version
+// that this server can't serve.
+// If a request calls deprecated methods, the response includes a warning for
+// each method in the Warning header. Note that the warnings can only be sent
+// for HTTP requests, not for calls over a websocket connection.
+func rpcVersionHandler(version api.Version, deprecations []api.Deprecation, next http.Handler) http.Handler {
+	deprecated := make(map[string]api.Deprecation, len(deprecations))
+	for _, d := range deprecations {
+		deprecated[d.Name] = d
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(api.VersionHeader, version.String())
+		if requested := r.Header.Get(api.VersionHeader); requested != "" {
+			if err := api.CheckRequestedVersion(requested, version); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if len(deprecated) > 0 && r.Method == http.MethodPost {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			for _, method := range rpcMethods(body) {
+				if d, ok := deprecated[method]; ok {
+					w.Header().Set("Deprecation", "true")
+					w.Header().Add("Warning", "299 - "+strconv.Quote(d.Warning()))
+				}
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rpcMethods returns the names of the methods called by a JSON-RPC request
+// or batch of requests
+func rpcMethods(body []byte) []string {
+	type rpcRequest struct {
+		Method string `json:"method"`
+	}
+
+	var batch []rpcRequest
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		_ = json.Unmarshal(body, &batch)
+	} else {
+		var req rpcRequest
+		if err := json.Unmarshal(body, &req); err == nil {
+			batch = append(batch, req)
+		}
+	}
+
+	methods := make([]string, 0, len(batch))
+	for _, req := range batch {
+		methods = append(methods, req.Method)
+	}
+	return methods
+}
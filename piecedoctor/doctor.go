@@ -0,0 +1,460 @@
+package piecedoctor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/api"
+	"github.com/filecoin-project/boost/markets/sectoraccessor"
+	"github.com/filecoin-project/dagstore"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	carindex "github.com/ipld/go-car/v2/index"
+	"github.com/multiformats/go-multihash"
+)
+
+var log = logging.Logger("piecedoctor")
+
+// The health status of a piece's index
+const (
+	// The index matches the unsealed data
+	StatusHealthy = "healthy"
+	// The piece has deals but there is no shard for it in the dagstore
+	StatusMissingIndex = "missing-index"
+	// The shard is in the errored state, or the index doesn't match the
+	// unsealed data
+	StatusCorruptIndex = "corrupt-index"
+	// The shard is still being initialized or recovered
+	StatusIndexing = "indexing"
+	// There is no unsealed copy of the piece, so the index can't be checked
+	// against the data
+	StatusNoUnsealedCopy = "no-unsealed-copy"
+	// The check could not be completed
+	StatusCheckFailed = "check-failed"
+)
+
+// How long to wait for a shard to be acquired or destroyed
+const shardOpTimeout = 5 * time.Minute
+
+type Config struct {
+	// How often to check a batch of pieces (disabled if zero)
+	CheckInterval time.Duration
+	// The number of pieces to check each time, chosen at random (must be
+	// positive if background checks are enabled)
+	PiecesPerCheck int
+	// The number of blocks to read from the unsealed data of each piece
+	// (must be positive)
+	BlocksPerPiece int
+	// Whether to re-index pieces with a missing or corrupt index
+	AutoRepair bool
+}
+
+// shardRegistrar registers and destroys the dagstore shards for pieces
+type shardRegistrar interface {
+	RegisterShard(ctx context.Context, pieceCid cid.Cid, carPath string, eagerInit bool, resch chan dagstore.ShardResult) error
+	DestroyShard(ctx context.Context, pieceCid cid.Cid, resch chan dagstore.ShardResult) error
+}
+
+// Doctor periodically samples pieces and checks that the index for each
+// piece matches the piece's unsealed data, by reading a sample of blocks
+// through the index and verifying their hashes.
+// Pieces with a missing or corrupt index are flagged, and optionally
+// re-indexed.
+type Doctor struct {
+	cfg   Config
+	dagst dagstore.Interface
+	reg   shardRegistrar
+	ps    piecestore.PieceStore
+	sa    retrievalmarket.SectorAccessor
+
+	lk      sync.Mutex
+	results map[cid.Cid]api.PieceHealth
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewDoctor(cfg Config, dagst dagstore.Interface, reg shardRegistrar, ps piecestore.PieceStore, sa retrievalmarket.SectorAccessor) (*Doctor, error) {
+	// With no blocks to sample, every index would look empty and be flagged
+	// as corrupt
+	if cfg.BlocksPerPiece <= 0 {
+		return nil, fmt.Errorf("the number of blocks to check per piece must be positive, got %d", cfg.BlocksPerPiece)
+	}
+	if cfg.CheckInterval > 0 && cfg.PiecesPerCheck <= 0 {
+		return nil, fmt.Errorf("the number of pieces to check each time must be positive, got %d", cfg.PiecesPerCheck)
+	}
+
+	return &Doctor{
+		cfg:     cfg,
+		dagst:   dagst,
+		reg:     reg,
+		ps:      ps,
+		sa:      sa,
+		results: make(map[cid.Cid]api.PieceHealth),
+	}, nil
+}
+
+func (d *Doctor) Start(ctx context.Context) {
+	d.ctx, d.cancel = context.WithCancel(ctx)
+	if d.cfg.CheckInterval <= 0 {
+		log.Info("piece doctor background checks are disabled")
+		return
+	}
+
+	d.wg.Add(1)
+	go d.run(d.ctx)
+}
+
+func (d *Doctor) Stop() {
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.wg.Wait()
+}
+
+func (d *Doctor) run(ctx context.Context) {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := d.checkBatch(ctx); err != nil && ctx.Err() == nil {
+			log.Errorw("failed to check piece health", "err", err)
+		}
+	}
+}
+
+// checkBatch checks a random sample of pieces
+func (d *Doctor) checkBatch(ctx context.Context) error {
+	pieceCids, err := d.ps.ListPieceInfoKeys()
+	if err != nil {
+		return fmt.Errorf("listing pieces: %w", err)
+	}
+
+	if len(pieceCids) > d.cfg.PiecesPerCheck {
+		rand.Shuffle(len(pieceCids), func(i, j int) { pieceCids[i], pieceCids[j] = pieceCids[j], pieceCids[i] })
+		pieceCids = pieceCids[:d.cfg.PiecesPerCheck]
+	}
+
+	var unhealthy int
+	for _, pieceCid := range pieceCids {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		h := d.CheckPiece(ctx, pieceCid, d.cfg.AutoRepair)
+		switch h.Status {
+		case StatusMissingIndex, StatusCorruptIndex, StatusCheckFailed:
+			unhealthy++
+			log.Warnw("piece index is unhealthy", "pieceCid", pieceCid, "status", h.Status, "err", h.Error, "repair", h.Repair)
+		}
+	}
+
+	log.Infow("checked piece health", "checked", len(pieceCids), "unhealthy", unhealthy)
+	return nil
+}
+
+// CheckPiece checks the piece's index against its unsealed data, and
+// records the result. If repair is true and the index is missing or
+// corrupt, the piece is re-indexed in the background.
+func (d *Doctor) CheckPiece(ctx context.Context, pieceCid cid.Cid, repair bool) api.PieceHealth {
+	h := d.checkPiece(ctx, pieceCid)
+	if repair && (h.Status == StatusMissingIndex || h.Status == StatusCorruptIndex) {
+		h.Repair = d.repair(ctx, pieceCid, h.Status)
+	}
+
+	d.lk.Lock()
+	d.results[pieceCid] = h
+	d.lk.Unlock()
+
+	return h
+}
+
+// Results returns the result of the last check of each piece, ordered by
+// piece cid
+func (d *Doctor) Results() []api.PieceHealth {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	results := make([]api.PieceHealth, 0, len(d.results))
+	for _, h := range d.results {
+		results = append(results, h)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].PieceCid.String() < results[j].PieceCid.String()
+	})
+	return results
+}
+
+// Result returns the result of the last check of the piece, if it has been
+// checked since boost started
+func (d *Doctor) Result(pieceCid cid.Cid) (api.PieceHealth, bool) {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	h, ok := d.results[pieceCid]
+	return h, ok
+}
+
+func (d *Doctor) checkPiece(ctx context.Context, pieceCid cid.Cid) api.PieceHealth {
+	h := api.PieceHealth{PieceCid: pieceCid, CheckedAt: time.Now()}
+
+	key := shard.KeyFromCID(pieceCid)
+	si, err := d.dagst.GetShardInfo(key)
+	if err != nil {
+		if errors.Is(err, dagstore.ErrShardUnknown) {
+			h.Status = StatusMissingIndex
+			return h
+		}
+		h.Status = StatusCheckFailed
+		h.Error = fmt.Sprintf("getting shard info: %s", err)
+		return h
+	}
+
+	switch si.ShardState {
+	case dagstore.ShardStateAvailable, dagstore.ShardStateServing:
+	case dagstore.ShardStateErrored:
+		h.Status = StatusCorruptIndex
+		if si.Error != nil {
+			h.Error = si.Error.Error()
+		}
+		return h
+	default:
+		h.Status = StatusIndexing
+		return h
+	}
+
+	unsealed, err := d.isUnsealed(ctx, pieceCid)
+	if err != nil {
+		h.Status = StatusCheckFailed
+		h.Error = err.Error()
+		return h
+	}
+	if !unsealed {
+		h.Status = StatusNoUnsealedCopy
+		return h
+	}
+
+	checked, err := d.verifyBlocks(ctx, key)
+	h.BlocksChecked = checked
+	if err != nil {
+		var cerr *corruptError
+		if errors.As(err, &cerr) {
+			h.Status = StatusCorruptIndex
+		} else {
+			h.Status = StatusCheckFailed
+		}
+		h.Error = err.Error()
+		return h
+	}
+
+	h.Status = StatusHealthy
+	return h
+}
+
+// isUnsealed returns true if any of the piece's deals is in a sector with
+// an unsealed copy
+func (d *Doctor) isUnsealed(ctx context.Context, pieceCid cid.Cid) (bool, error) {
+	pi, err := d.ps.GetPieceInfo(pieceCid)
+	if err != nil {
+		return false, fmt.Errorf("getting piece info: %w", err)
+	}
+
+	var lastErr error
+	for _, dl := range pi.Deals {
+		isUnsealed, err := d.sa.IsUnsealed(ctx, dl.SectorID, dl.Offset.Unpadded(), dl.Length.Unpadded())
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if isUnsealed {
+			return true, nil
+		}
+	}
+	if lastErr != nil {
+		return false, fmt.Errorf("checking for unsealed copy: %w", lastErr)
+	}
+	return false, nil
+}
+
+// corruptError indicates that the index doesn't match the unsealed data
+type corruptError struct {
+	error
+}
+
+// verifyBlocks reads a sample of blocks through the piece's index, and
+// checks that the data of each block matches its multihash.
+// It returns the number of blocks that were checked.
+func (d *Doctor) verifyBlocks(ctx context.Context, key shard.Key) (int, error) {
+	idx, err := d.dagst.GetIterableIndex(key)
+	if err != nil {
+		return 0, fmt.Errorf("getting index: %w", err)
+	}
+	sample, err := sampleMultihashes(idx, d.cfg.BlocksPerPiece)
+	if err != nil {
+		return 0, err
+	}
+	if len(sample) == 0 {
+		return 0, &corruptError{errors.New("index is empty")}
+	}
+
+	// Health checks run in the background, so if reading the piece needs an
+	// unseal, let unseals for retrievals go first
+	actx, cancel := context.WithTimeout(sectoraccessor.WithUnsealPriority(ctx, sectoraccessor.UnsealPriorityLow), shardOpTimeout)
+	defer cancel()
+
+	resch := make(chan dagstore.ShardResult, 1)
+	if err := d.dagst.AcquireShard(actx, key, resch, dagstore.AcquireOpts{}); err != nil {
+		return 0, fmt.Errorf("acquiring shard: %w", err)
+	}
+	var res dagstore.ShardResult
+	select {
+	case <-actx.Done():
+		return 0, fmt.Errorf("acquiring shard: %w", actx.Err())
+	case res = <-resch:
+	}
+	if res.Error != nil {
+		return 0, fmt.Errorf("acquiring shard: %w", res.Error)
+	}
+	defer res.Accessor.Close() //nolint:errcheck
+
+	bs, err := res.Accessor.Blockstore()
+	if err != nil {
+		return 0, fmt.Errorf("getting shard blockstore: %w", err)
+	}
+
+	for i, mh := range sample {
+		// The blockstore only returns the block if the cid at the indexed
+		// offset matches
+		blk, err := bs.Get(ctx, cid.NewCidV1(cid.Raw, mh))
+		if err != nil {
+			return i + 1, &corruptError{fmt.Errorf("reading block %s at indexed offset: %w", mh.B58String(), err)}
+		}
+
+		dmh, err := multihash.Decode(mh)
+		if err != nil {
+			return i + 1, &corruptError{fmt.Errorf("decoding multihash %s: %w", mh.B58String(), err)}
+		}
+		sum, err := multihash.Sum(blk.RawData(), dmh.Code, dmh.Length)
+		if err != nil {
+			return i + 1, fmt.Errorf("hashing block %s: %w", mh.B58String(), err)
+		}
+		if !bytes.Equal(sum, mh) {
+			return i + 1, &corruptError{fmt.Errorf("data for block %s does not match its hash", mh.B58String())}
+		}
+	}
+
+	return len(sample), nil
+}
+
+// repair re-indexes the piece in the background, and returns a description
+// of the repair that was started
+func (d *Doctor) repair(ctx context.Context, pieceCid cid.Cid, status string) string {
+	key := shard.KeyFromCID(pieceCid)
+
+	// If the shard is available but its index doesn't match the data,
+	// destroy the shard so that it's re-registered with a fresh index
+	if status == StatusCorruptIndex {
+		si, err := d.dagst.GetShardInfo(key)
+		if err != nil {
+			return fmt.Sprintf("failed: getting shard info: %s", err)
+		}
+
+		if si.ShardState == dagstore.ShardStateErrored {
+			resch := make(chan dagstore.ShardResult, 1)
+			if err := d.dagst.RecoverShard(d.bgCtx(), key, resch, dagstore.RecoverOpts{}); err != nil {
+				return fmt.Sprintf("failed: recovering shard: %s", err)
+			}
+			go d.logRepairResult(pieceCid, "recover", resch)
+			return "recovering shard"
+		}
+
+		if err := d.destroyShard(ctx, pieceCid); err != nil {
+			return fmt.Sprintf("failed: %s", err)
+		}
+	}
+
+	resch := make(chan dagstore.ShardResult, 1)
+	if err := d.reg.RegisterShard(d.bgCtx(), pieceCid, "", true, resch); err != nil {
+		return fmt.Sprintf("failed: registering shard: %s", err)
+	}
+	go d.logRepairResult(pieceCid, "re-index", resch)
+	return "re-indexing"
+}
+
+func (d *Doctor) destroyShard(ctx context.Context, pieceCid cid.Cid) error {
+	dctx, cancel := context.WithTimeout(ctx, shardOpTimeout)
+	defer cancel()
+
+	resch := make(chan dagstore.ShardResult, 1)
+	if err := d.reg.DestroyShard(dctx, pieceCid, resch); err != nil {
+		return fmt.Errorf("destroying shard: %w", err)
+	}
+	select {
+	case <-dctx.Done():
+		return fmt.Errorf("destroying shard: %w", dctx.Err())
+	case res := <-resch:
+		if res.Error != nil {
+			return fmt.Errorf("destroying shard: %w", res.Error)
+		}
+	}
+	return nil
+}
+
+func (d *Doctor) logRepairResult(pieceCid cid.Cid, op string, resch chan dagstore.ShardResult) {
+	select {
+	case <-d.bgCtx().Done():
+	case res := <-resch:
+		if res.Error != nil {
+			log.Errorw("failed to repair piece index", "pieceCid", pieceCid, "op", op, "err", res.Error)
+			return
+		}
+		log.Infow("repaired piece index", "pieceCid", pieceCid, "op", op)
+	}
+}
+
+// bgCtx is the context for shard operations that outlive the check that
+// started them. Any unseals that the operations need run at low priority.
+func (d *Doctor) bgCtx() context.Context {
+	ctx := d.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return sectoraccessor.WithUnsealPriority(ctx, sectoraccessor.UnsealPriorityLow)
+}
+
+// sampleMultihashes picks n multihashes at random from the index
+func sampleMultihashes(idx carindex.IterableIndex, n int) ([]multihash.Multihash, error) {
+	sample := make([]multihash.Multihash, 0, n)
+	var count int
+	err := idx.ForEach(func(mh multihash.Multihash, _ uint64) error {
+		count++
+		if len(sample) < n {
+			sample = append(sample, append(multihash.Multihash(nil), mh...))
+		} else if i := rand.Intn(count); i < n {
+			sample[i] = append(multihash.Multihash(nil), mh...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("iterating over index: %w", err)
+	}
+	return sample, nil
+}
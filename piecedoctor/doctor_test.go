@@ -0,0 +1,151 @@
+package piecedoctor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/dagstore"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	carindex "github.com/ipld/go-car/v2/index"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+const testPieceCid = "baga6ea4seaqnfhocd544oidrgsss2ahoaomvxuaqxfmlsizljtzsuivjl5hamka"
+
+type mockDagstore struct {
+	dagstore.Interface
+	shards map[shard.Key]dagstore.ShardInfo
+}
+
+func (m *mockDagstore) GetShardInfo(k shard.Key) (dagstore.ShardInfo, error) {
+	si, ok := m.shards[k]
+	if !ok {
+		return dagstore.ShardInfo{}, dagstore.ErrShardUnknown
+	}
+	return si, nil
+}
+
+type mockPieceStore struct {
+	piecestore.PieceStore
+	pieces map[cid.Cid]piecestore.PieceInfo
+}
+
+func (m *mockPieceStore) GetPieceInfo(pieceCid cid.Cid) (piecestore.PieceInfo, error) {
+	pi, ok := m.pieces[pieceCid]
+	if !ok {
+		return piecestore.PieceInfo{}, retrievalmarket.ErrNotFound
+	}
+	return pi, nil
+}
+
+type mockSectorAccessor struct {
+	retrievalmarket.SectorAccessor
+	unsealed bool
+}
+
+func (m *mockSectorAccessor) IsUnsealed(ctx context.Context, sectorID abi.SectorNumber, offset abi.UnpaddedPieceSize, length abi.UnpaddedPieceSize) (bool, error) {
+	return m.unsealed, nil
+}
+
+func TestCheckPieceStatus(t *testing.T) {
+	ctx := context.Background()
+	pieceCid, err := cid.Parse(testPieceCid)
+	require.NoError(t, err)
+	key := shard.KeyFromCID(pieceCid)
+
+	ps := &mockPieceStore{pieces: map[cid.Cid]piecestore.PieceInfo{
+		pieceCid: {PieceCID: pieceCid, Deals: []piecestore.DealInfo{{SectorID: 1, Length: 128}}},
+	}}
+
+	tcs := []struct {
+		name     string
+		shards   map[shard.Key]dagstore.ShardInfo
+		unsealed bool
+		status   string
+	}{{
+		name:   "missing index",
+		shards: map[shard.Key]dagstore.ShardInfo{},
+		status: StatusMissingIndex,
+	}, {
+		name:   "errored shard",
+		shards: map[shard.Key]dagstore.ShardInfo{key: {ShardState: dagstore.ShardStateErrored, Error: errors.New("bad index")}},
+		status: StatusCorruptIndex,
+	}, {
+		name:   "initializing shard",
+		shards: map[shard.Key]dagstore.ShardInfo{key: {ShardState: dagstore.ShardStateInitializing}},
+		status: StatusIndexing,
+	}, {
+		name:     "no unsealed copy",
+		shards:   map[shard.Key]dagstore.ShardInfo{key: {ShardState: dagstore.ShardStateAvailable}},
+		unsealed: false,
+		status:   StatusNoUnsealedCopy,
+	}}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			dagst := &mockDagstore{shards: tc.shards}
+			d, err := NewDoctor(Config{BlocksPerPiece: 5}, dagst, nil, ps, &mockSectorAccessor{unsealed: tc.unsealed})
+			require.NoError(t, err)
+
+			h := d.CheckPiece(ctx, pieceCid, false)
+			require.Equal(t, tc.status, h.Status)
+			require.Equal(t, pieceCid, h.PieceCid)
+			require.Empty(t, h.Repair)
+
+			res, ok := d.Result(pieceCid)
+			require.True(t, ok)
+			require.Equal(t, h, res)
+			require.Len(t, d.Results(), 1)
+		})
+	}
+}
+
+func TestSampleMultihashes(t *testing.T) {
+	idx, err := carindex.New(multicodec.CarMultihashIndexSorted)
+	require.NoError(t, err)
+
+	var records []carindex.Record
+	for i := 0; i < 20; i++ {
+		mh, err := multihash.Sum([]byte{byte(i)}, multihash.SHA2_256, -1)
+		require.NoError(t, err)
+		records = append(records, carindex.Record{Cid: cid.NewCidV1(cid.Raw, mh), Offset: uint64(i)})
+	}
+	require.NoError(t, idx.Load(records))
+	iterIdx := idx.(carindex.IterableIndex)
+
+	sample, err := sampleMultihashes(iterIdx, 5)
+	require.NoError(t, err)
+	require.Len(t, sample, 5)
+
+	seen := make(map[string]struct{})
+	for _, mh := range sample {
+		seen[mh.String()] = struct{}{}
+	}
+	require.Len(t, seen, 5)
+
+	// If the index has fewer blocks than the sample size, all the blocks are
+	// sampled
+	sample, err = sampleMultihashes(iterIdx, 50)
+	require.NoError(t, err)
+	require.Len(t, sample, 20)
+}
+
+func TestNewDoctorValidatesConfig(t *testing.T) {
+	_, err := NewDoctor(Config{BlocksPerPiece: 0}, nil, nil, nil, nil)
+	require.ErrorContains(t, err, "blocks to check per piece must be positive")
+
+	_, err = NewDoctor(Config{CheckInterval: time.Minute, BlocksPerPiece: 5}, nil, nil, nil, nil)
+	require.ErrorContains(t, err, "pieces to check each time must be positive")
+
+	// The number of pieces per check only matters for background checks
+	_, err = NewDoctor(Config{BlocksPerPiece: 5}, nil, nil, nil, nil)
+	require.NoError(t, err)
+}
@@ -0,0 +1,218 @@
+package pieceremover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/boost/api"
+	"github.com/filecoin-project/dagstore"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-statestore"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("pieceremover")
+
+// How long to wait for the piece's shard to be destroyed
+const destroyShardTimeout = 5 * time.Minute
+
+// The keys under which the piece store keeps piece infos and cid infos in
+// the metadata datastore (see lotus_modules.NewProviderPieceStore)
+var (
+	pieceInfosKey = datastore.NewKey("/storagemarket/pieces/1")
+	cidInfosKey   = datastore.NewKey("/storagemarket/cid-infos/1")
+)
+
+// shardDestroyer destroys the dagstore shard for a piece
+type shardDestroyer interface {
+	DestroyShard(ctx context.Context, pieceCid cid.Cid, resch chan dagstore.ShardResult) error
+}
+
+// announcementRemover publishes removal advertisements for the deals for a
+// piece
+type announcementRemover interface {
+	RemovePieceAnnouncements(ctx context.Context, pieceCid cid.Cid, dryRun bool) ([]uuid.UUID, error)
+}
+
+// lookupCache caches multihash -> piece lookups
+type lookupCache interface {
+	RemoveShard(k shard.Key) int
+}
+
+// flatCopyStore keeps flat copies of pieces for fast access
+type flatCopyStore interface {
+	Has(pieceCid cid.Cid) bool
+	Remove(pieceCid cid.Cid) (bool, error)
+}
+
+// Remover removes all the records for a piece across subsystems: the
+// piece's announcements to the network indexer, its dagstore shard (and
+// index), cached lookups, its copy in the flat store and its records in the
+// piece store.
+// The deals for the piece are kept in the deals database for reference.
+type Remover struct {
+	ps    piecestore.PieceStore
+	psds  datastore.Batching
+	dagst dagstore.Interface
+	sd    shardDestroyer
+	ar    announcementRemover
+	cache lookupCache
+	fs    flatCopyStore
+}
+
+// NewRemover creates a new Remover. psds is the datastore that backs the
+// piece store. cache may be nil if lookups are not cached, and fs may be nil
+// if the flat store is disabled.
+func NewRemover(ps piecestore.PieceStore, psds datastore.Batching, dagst dagstore.Interface, sd shardDestroyer, ar announcementRemover, cache lookupCache, fs flatCopyStore) *Remover {
+	return &Remover{ps: ps, psds: psds, dagst: dagst, sd: sd, ar: ar, cache: cache, fs: fs}
+}
+
+// Remove removes the records for the piece, and returns what was removed.
+// If dryRun is true, nothing is removed, and the result lists what would
+// be removed.
+func (r *Remover) Remove(ctx context.Context, pieceCid cid.Cid, dryRun bool) (*api.PieceRemoval, error) {
+	res := &api.PieceRemoval{PieceCid: pieceCid, DryRun: dryRun}
+
+	// Remove the announcements first, so that the network indexer stops
+	// routing retrievals to the piece before it is removed
+	dealUuids, err := r.ar.RemovePieceAnnouncements(ctx, pieceCid, dryRun)
+	res.RemovedAnnouncements = dealUuids
+	if err != nil {
+		return res, fmt.Errorf("removing announcements: %w", err)
+	}
+
+	key := shard.KeyFromCID(pieceCid)
+	_, err = r.dagst.GetShardInfo(key)
+	switch {
+	case errors.Is(err, dagstore.ErrShardUnknown):
+	case err != nil:
+		return res, fmt.Errorf("getting shard for piece: %w", err)
+	default:
+		if !dryRun {
+			if err := r.destroyShard(ctx, pieceCid); err != nil {
+				return res, err
+			}
+		}
+		res.RemovedShard = true
+	}
+
+	if r.cache != nil && !dryRun {
+		res.RemovedCacheEntries = r.cache.RemoveShard(key)
+	}
+
+	if r.fs != nil {
+		if dryRun {
+			res.RemovedFlatCopy = r.fs.Has(pieceCid)
+		} else {
+			removed, err := r.fs.Remove(pieceCid)
+			if err != nil {
+				return res, fmt.Errorf("removing flat copy: %w", err)
+			}
+			res.RemovedFlatCopy = removed
+		}
+	}
+
+	cidInfos, err := r.removeCidInfos(ctx, pieceCid, dryRun)
+	res.RemovedCidInfos = cidInfos
+	if err != nil {
+		return res, err
+	}
+
+	_, err = r.ps.GetPieceInfo(pieceCid)
+	switch {
+	case errors.Is(err, retrievalmarket.ErrNotFound):
+	case err != nil:
+		return res, fmt.Errorf("getting piece info: %w", err)
+	default:
+		if !dryRun {
+			ss := statestore.New(namespace.Wrap(r.psds, pieceInfosKey))
+			if err := ss.Get(pieceCid).End(); err != nil {
+				return res, fmt.Errorf("removing piece info: %w", err)
+			}
+		}
+		res.RemovedPieceInfo = true
+	}
+
+	if !dryRun {
+		log.Infow("removed piece", "pieceCid", pieceCid, "announcements", len(res.RemovedAnnouncements),
+			"shard", res.RemovedShard, "cache-entries", res.RemovedCacheEntries, "flat-copy", res.RemovedFlatCopy, "cid-infos", len(res.RemovedCidInfos),
+			"piece-info", res.RemovedPieceInfo)
+	}
+	return res, nil
+}
+
+func (r *Remover) destroyShard(ctx context.Context, pieceCid cid.Cid) error {
+	dctx, cancel := context.WithTimeout(ctx, destroyShardTimeout)
+	defer cancel()
+
+	resch := make(chan dagstore.ShardResult, 1)
+	if err := r.sd.DestroyShard(dctx, pieceCid, resch); err != nil {
+		return fmt.Errorf("destroying shard: %w", err)
+	}
+	select {
+	case <-dctx.Done():
+		return fmt.Errorf("destroying shard: %w", dctx.Err())
+	case res := <-resch:
+		if res.Error != nil {
+			return fmt.Errorf("destroying shard: %w", res.Error)
+		}
+	}
+	return nil
+}
+
+// removeCidInfos removes the piece from the block locations of each payload
+// cid, and removes the cid info if the piece was the only location.
+// It returns the payload cids whose block locations included the piece.
+func (r *Remover) removeCidInfos(ctx context.Context, pieceCid cid.Cid, dryRun bool) ([]cid.Cid, error) {
+	payloadCids, err := r.ps.ListCidInfoKeys()
+	if err != nil {
+		return nil, fmt.Errorf("listing cid infos: %w", err)
+	}
+
+	ss := statestore.New(namespace.Wrap(r.psds, cidInfosKey))
+	var removed []cid.Cid
+	for _, c := range payloadCids {
+		if ctx.Err() != nil {
+			return removed, ctx.Err()
+		}
+
+		ci, err := r.ps.GetCIDInfo(c)
+		if err != nil {
+			return removed, fmt.Errorf("getting cid info for %s: %w", c, err)
+		}
+
+		locs := make([]piecestore.PieceBlockLocation, 0, len(ci.PieceBlockLocations))
+		for _, loc := range ci.PieceBlockLocations {
+			if loc.PieceCID != pieceCid {
+				locs = append(locs, loc)
+			}
+		}
+		if len(locs) == len(ci.PieceBlockLocations) {
+			continue
+		}
+
+		if !dryRun {
+			if len(locs) == 0 {
+				err = ss.Get(c).End()
+			} else {
+				err = ss.Get(c).Mutate(func(ci *piecestore.CIDInfo) error {
+					ci.PieceBlockLocations = locs
+					return nil
+				})
+			}
+			if err != nil {
+				return removed, fmt.Errorf("removing piece from cid info for %s: %w", c, err)
+			}
+		}
+		removed = append(removed, c)
+	}
+	return removed, nil
+}
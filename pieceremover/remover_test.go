@@ -0,0 +1,169 @@
+package pieceremover
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/dagstore"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	piecestoreimpl "github.com/filecoin-project/go-fil-markets/piecestore/impl"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testPieceCid   = "baga6ea4seaqnfhocd544oidrgsss2ahoaomvxuaqxfmlsizljtzsuivjl5hamka"
+	otherPieceCid  = "baga6ea4seaqd3ks3ezrcexqy4vjk5cdlyopwqn4rfis4ldttoaehdijgbgty4ba"
+	testPayloadCid = "bafy2bzacea3wsdh6y3a36tb3skempjoxqpuyompjbmfeyf34fi3uy6uue42v4"
+	otherPayload   = "bafkqaaa"
+)
+
+type mockDagstore struct {
+	dagstore.Interface
+	shards map[shard.Key]dagstore.ShardInfo
+}
+
+func (m *mockDagstore) GetShardInfo(k shard.Key) (dagstore.ShardInfo, error) {
+	si, ok := m.shards[k]
+	if !ok {
+		return dagstore.ShardInfo{}, dagstore.ErrShardUnknown
+	}
+	return si, nil
+}
+
+type mockShardDestroyer struct {
+	dagst *mockDagstore
+}
+
+func (m *mockShardDestroyer) DestroyShard(ctx context.Context, pieceCid cid.Cid, resch chan dagstore.ShardResult) error {
+	delete(m.dagst.shards, shard.KeyFromCID(pieceCid))
+	resch <- dagstore.ShardResult{Key: shard.KeyFromCID(pieceCid)}
+	return nil
+}
+
+type mockAnnouncementRemover struct {
+	deals   []uuid.UUID
+	removed bool
+}
+
+func (m *mockAnnouncementRemover) RemovePieceAnnouncements(ctx context.Context, pieceCid cid.Cid, dryRun bool) ([]uuid.UUID, error) {
+	if m.removed {
+		return nil, nil
+	}
+	m.removed = !dryRun
+	return m.deals, nil
+}
+
+type mockLookupCache struct {
+	entries map[shard.Key]int
+}
+
+func (m *mockLookupCache) RemoveShard(k shard.Key) int {
+	n := m.entries[k]
+	delete(m.entries, k)
+	return n
+}
+
+type mockFlatStore struct {
+	pieces map[cid.Cid]struct{}
+}
+
+func (m *mockFlatStore) Has(pieceCid cid.Cid) bool {
+	_, ok := m.pieces[pieceCid]
+	return ok
+}
+
+func (m *mockFlatStore) Remove(pieceCid cid.Cid) (bool, error) {
+	_, ok := m.pieces[pieceCid]
+	delete(m.pieces, pieceCid)
+	return ok, nil
+}
+
+func TestRemove(t *testing.T) {
+	ctx := context.Background()
+	pieceCid, err := cid.Parse(testPieceCid)
+	require.NoError(t, err)
+	otherPiece, err := cid.Parse(otherPieceCid)
+	require.NoError(t, err)
+	payloadCid, err := cid.Parse(testPayloadCid)
+	require.NoError(t, err)
+	otherPayloadCid, err := cid.Parse(otherPayload)
+	require.NoError(t, err)
+
+	// Use the same datastore layout as the piece store in boost
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	ps, err := piecestoreimpl.NewPieceStore(namespace.Wrap(ds, datastore.NewKey("/storagemarket")))
+	require.NoError(t, err)
+	ready := make(chan error, 1)
+	ps.OnReady(func(err error) { ready <- err })
+	require.NoError(t, ps.Start(ctx))
+	require.NoError(t, <-ready)
+
+	require.NoError(t, ps.AddDealForPiece(pieceCid, cid.Undef, piecestore.DealInfo{DealID: 1, SectorID: 1}))
+	require.NoError(t, ps.AddDealForPiece(otherPiece, cid.Undef, piecestore.DealInfo{DealID: 2, SectorID: 2}))
+	// The payload is in both pieces
+	require.NoError(t, ps.AddPieceBlockLocations(pieceCid, map[cid.Cid]piecestore.BlockLocation{payloadCid: {}}))
+	require.NoError(t, ps.AddPieceBlockLocations(otherPiece, map[cid.Cid]piecestore.BlockLocation{payloadCid: {}}))
+	// The other payload is only in the piece that is removed
+	require.NoError(t, ps.AddPieceBlockLocations(pieceCid, map[cid.Cid]piecestore.BlockLocation{otherPayloadCid: {}}))
+
+	key := shard.KeyFromCID(pieceCid)
+	dagst := &mockDagstore{shards: map[shard.Key]dagstore.ShardInfo{key: {ShardState: dagstore.ShardStateAvailable}}}
+	ar := &mockAnnouncementRemover{deals: []uuid.UUID{uuid.New()}}
+	cache := &mockLookupCache{entries: map[shard.Key]int{key: 3}}
+	fs := &mockFlatStore{pieces: map[cid.Cid]struct{}{pieceCid: {}, otherPiece: {}}}
+	r := NewRemover(ps, ds, dagst, &mockShardDestroyer{dagst: dagst}, ar, cache, fs)
+
+	// A dry run doesn't remove anything
+	res, err := r.Remove(ctx, pieceCid, true)
+	require.NoError(t, err)
+	require.Len(t, res.RemovedAnnouncements, 1)
+	require.True(t, res.RemovedShard)
+	require.Len(t, res.RemovedCidInfos, 2)
+	require.True(t, res.RemovedPieceInfo)
+	require.Contains(t, dagst.shards, key)
+	require.Equal(t, 3, cache.entries[key])
+	require.True(t, res.RemovedFlatCopy)
+	require.True(t, fs.Has(pieceCid))
+	_, err = ps.GetPieceInfo(pieceCid)
+	require.NoError(t, err)
+
+	res, err = r.Remove(ctx, pieceCid, false)
+	require.NoError(t, err)
+	require.Len(t, res.RemovedAnnouncements, 1)
+	require.True(t, res.RemovedShard)
+	require.Equal(t, 3, res.RemovedCacheEntries)
+	require.True(t, res.RemovedFlatCopy)
+	require.False(t, fs.Has(pieceCid))
+	require.True(t, fs.Has(otherPiece))
+	require.Len(t, res.RemovedCidInfos, 2)
+	require.True(t, res.RemovedPieceInfo)
+	require.NotContains(t, dagst.shards, key)
+
+	_, err = ps.GetPieceInfo(pieceCid)
+	require.ErrorIs(t, err, retrievalmarket.ErrNotFound)
+	_, err = ps.GetPieceInfo(otherPiece)
+	require.NoError(t, err)
+
+	ci, err := ps.GetCIDInfo(payloadCid)
+	require.NoError(t, err)
+	require.Len(t, ci.PieceBlockLocations, 1)
+	require.Equal(t, otherPiece, ci.PieceBlockLocations[0].PieceCID)
+	_, err = ps.GetCIDInfo(otherPayloadCid)
+	require.ErrorIs(t, err, retrievalmarket.ErrNotFound)
+
+	// Removing the piece again has nothing left to remove
+	res, err = r.Remove(ctx, pieceCid, false)
+	require.NoError(t, err)
+	require.Empty(t, res.RemovedAnnouncements)
+	require.False(t, res.RemovedShard)
+	require.False(t, res.RemovedFlatCopy)
+	require.Empty(t, res.RemovedCidInfos)
+	require.False(t, res.RemovedPieceInfo)
+}
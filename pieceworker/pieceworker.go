@@ -0,0 +1,253 @@
+package pieceworker
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/filecoin-project/boost/lib/rpcenc"
+	commcid "github.com/filecoin-project/go-fil-commcid"
+	commp "github.com/filecoin-project/go-fil-commp-hashhash"
+	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/filecoin-project/go-jsonrpc/auth"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api"
+	cliutil "github.com/filecoin-project/lotus/cli/util"
+	"github.com/filecoin-project/specs-storage/storage"
+	"github.com/gorilla/mux"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("pieceworker")
+
+const (
+	PermRead  auth.Permission = "read"
+	PermWrite auth.Permission = "write"
+)
+
+var AllPermissions = []auth.Permission{PermRead, PermWrite}
+var DefaultPerms = []auth.Permission{PermRead}
+
+var ErrAddPieceNotSupported = errors.New("piece worker is not connected to a sealer: add piece is not supported")
+
+// API is the API served by a piece worker, so that the data-heavy work of
+// adding pieces to sectors and calculating piece commitments can be done on
+// a different machine to boostd.
+// A piece worker client implements the storagemarket CommpCalculator
+// interface, and implements the PieceAdder interface when wrapped with
+// NewPieceAdder.
+type API interface {
+	// SectorAddPieceToAny adds the piece data to a sector
+	SectorAddPieceToAny(ctx context.Context, size abi.UnpaddedPieceSize, r storage.Data, d api.PieceDealInfo) (api.SectorOffset, error) //perm:write
+	// ComputeDataCid calculates the piece commitment of the data
+	ComputeDataCid(ctx context.Context, pieceSize abi.UnpaddedPieceSize, pieceData storage.Data) (abi.PieceInfo, error) //perm:write
+}
+
+type APIStruct struct {
+	Internal struct {
+		SectorAddPieceToAny func(ctx context.Context, size abi.UnpaddedPieceSize, r storage.Data, d api.PieceDealInfo) (api.SectorOffset, error) `perm:"write"`
+		ComputeDataCid      func(ctx context.Context, pieceSize abi.UnpaddedPieceSize, pieceData storage.Data) (abi.PieceInfo, error)            `perm:"write"`
+	}
+}
+
+var _ API = (*APIStruct)(nil)
+
+func (s *APIStruct) SectorAddPieceToAny(ctx context.Context, size abi.UnpaddedPieceSize, r storage.Data, d api.PieceDealInfo) (api.SectorOffset, error) {
+	return s.Internal.SectorAddPieceToAny(ctx, size, r, d)
+}
+
+func (s *APIStruct) ComputeDataCid(ctx context.Context, pieceSize abi.UnpaddedPieceSize, pieceData storage.Data) (abi.PieceInfo, error) {
+	return s.Internal.ComputeDataCid(ctx, pieceSize, pieceData)
+}
+
+// Connect connects to the API of a remote piece worker.
+// The url may be a multiaddr or an http / ws URL.
+func Connect(ctx context.Context, url string, token string) (API, jsonrpc.ClientCloser, error) {
+	ai := cliutil.APIInfo{Addr: url}
+	if token != "" {
+		ai.Token = []byte(token)
+	}
+
+	addr, err := ai.DialArgs("v0")
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing piece worker url '%s': %w", url, err)
+	}
+
+	pushUrl, err := getPushUrl(addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting piece worker data push url: %w", err)
+	}
+
+	var res APIStruct
+	closer, err := jsonrpc.NewMergeClient(ctx, addr, "PieceWorker",
+		[]interface{}{&res.Internal}, ai.AuthHeader(), rpcenc.ReaderParamEncoder(pushUrl))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating piece worker client: %w", err)
+	}
+	return &res, closer, nil
+}
+
+// getPushUrl gets the url that the data for reader parameters is sent to
+// eg /rpc/v0 -> /rpc/streams/v0/push
+func getPushUrl(addr string) (string, error) {
+	pushUrl, err := url.Parse(addr)
+	if err != nil {
+		return "", err
+	}
+	switch pushUrl.Scheme {
+	case "ws":
+		pushUrl.Scheme = "http"
+	case "wss":
+		pushUrl.Scheme = "https"
+	}
+
+	pushUrl.Path = path.Join(pushUrl.Path, "../streams/v0/push")
+	return pushUrl.String(), nil
+}
+
+// Handler returns an http handler that serves the piece worker API.
+// Calls to the API must be authenticated with a token that verify accepts.
+func Handler(a API, verify func(ctx context.Context, token string) ([]auth.Permission, error)) http.Handler {
+	var permissioned APIStruct
+	auth.PermissionedProxy(AllPermissions, DefaultPerms, a, &permissioned.Internal)
+
+	readerHandler, readerServerOpt := rpcenc.ReaderParamDecoder()
+	rpcServer := jsonrpc.NewServer(readerServerOpt)
+	rpcServer.Register("PieceWorker", &permissioned)
+
+	m := mux.NewRouter()
+	m.Handle("/rpc/v0", requireToken(rpcServer))
+	m.Handle("/rpc/streams/v0/push/{uuid}", readerHandler)
+
+	return &auth.Handler{
+		Verify: verify,
+		Next:   m.ServeHTTP,
+	}
+}
+
+// requireToken rejects requests that weren't authenticated with a token.
+// The request is rejected before its parameters are decoded, so that the
+// data for a reader parameter isn't received for a request that would be
+// rejected.
+func requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !auth.HasPerm(r.Context(), nil, PermWrite) {
+			http.Error(w, "missing or invalid piece worker token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// TokenVerifier returns a function that grants all permissions to requests
+// authenticated with the token
+func TokenVerifier(token string) func(ctx context.Context, token string) ([]auth.Permission, error) {
+	return func(ctx context.Context, reqToken string) ([]auth.Permission, error) {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(reqToken)) != 1 {
+			return nil, errors.New("invalid piece worker token")
+		}
+		return AllPermissions, nil
+	}
+}
+
+// PieceAdder adds pieces to sectors with a piece worker. It implements the
+// storagemarket PieceAdder interface.
+type PieceAdder struct {
+	api API
+}
+
+func NewPieceAdder(a API) *PieceAdder {
+	return &PieceAdder{api: a}
+}
+
+func (a *PieceAdder) AddPiece(ctx context.Context, size abi.UnpaddedPieceSize, r io.Reader, d api.PieceDealInfo) (abi.SectorNumber, abi.PaddedPieceSize, error) {
+	so, err := a.api.SectorAddPieceToAny(ctx, size, r, d)
+	if err != nil {
+		return 0, 0, err
+	}
+	return so.Sector, so.Offset, nil
+}
+
+// Sealer adds pieces to sectors, eg the lotus miner API
+type Sealer interface {
+	SectorAddPieceToAny(ctx context.Context, size abi.UnpaddedPieceSize, r storage.Data, d api.PieceDealInfo) (api.SectorOffset, error)
+}
+
+// Worker implements the piece worker API: it calculates piece commitments
+// locally, and adds pieces to sectors with the sealer that it's connected to
+type Worker struct {
+	// nil if the worker only calculates piece commitments
+	sealer        Sealer
+	commpThrottle chan struct{}
+}
+
+var _ API = (*Worker)(nil)
+
+func NewWorker(sealer Sealer, maxConcurrentCommp int) *Worker {
+	// Make sure that max concurrent commp is at least 1
+	if maxConcurrentCommp < 1 {
+		maxConcurrentCommp = 1
+	}
+	return &Worker{
+		sealer:        sealer,
+		commpThrottle: make(chan struct{}, maxConcurrentCommp),
+	}
+}
+
+func (w *Worker) SectorAddPieceToAny(ctx context.Context, size abi.UnpaddedPieceSize, r storage.Data, d api.PieceDealInfo) (api.SectorOffset, error) {
+	if w.sealer == nil {
+		closeReader(r)
+		return api.SectorOffset{}, ErrAddPieceNotSupported
+	}
+
+	log.Infow("adding piece", "deal", d.DealID, "size", size)
+	so, err := w.sealer.SectorAddPieceToAny(ctx, size, r, d)
+	if err != nil {
+		return api.SectorOffset{}, fmt.Errorf("adding piece for deal %d to sector: %w", d.DealID, err)
+	}
+	log.Infow("added piece", "deal", d.DealID, "sector", so.Sector, "offset", so.Offset)
+	return so, nil
+}
+
+func (w *Worker) ComputeDataCid(ctx context.Context, pieceSize abi.UnpaddedPieceSize, pieceData storage.Data) (abi.PieceInfo, error) {
+	// Throttle the number of piece commitments calculated in parallel
+	select {
+	case w.commpThrottle <- struct{}{}:
+	case <-ctx.Done():
+		closeReader(pieceData)
+		return abi.PieceInfo{}, ctx.Err()
+	}
+	defer func() { <-w.commpThrottle }()
+
+	cp := &commp.Calc{}
+	written, err := io.Copy(cp, pieceData)
+	if err != nil {
+		return abi.PieceInfo{}, fmt.Errorf("writing to commp writer: %w", err)
+	}
+	if written != int64(pieceSize) {
+		return abi.PieceInfo{}, fmt.Errorf("number of bytes read %d not equal to the piece size %d", written, pieceSize)
+	}
+
+	rawCommp, size, err := cp.Digest()
+	if err != nil {
+		return abi.PieceInfo{}, fmt.Errorf("calculating commp: %w", err)
+	}
+	commCid, err := commcid.DataCommitmentV1ToCID(rawCommp)
+	if err != nil {
+		return abi.PieceInfo{}, fmt.Errorf("converting commp to cid: %w", err)
+	}
+	return abi.PieceInfo{Size: abi.PaddedPieceSize(size), PieceCID: commCid}, nil
+}
+
+// closeReader closes a reader parameter that won't be read, so that the
+// client stops waiting to send the data
+func closeReader(r io.Reader) {
+	if c, ok := r.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
@@ -0,0 +1,105 @@
+package pieceworker
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/lib/rpcenc"
+
+	commcid "github.com/filecoin-project/go-fil-commcid"
+	commp "github.com/filecoin-project/go-fil-commp-hashhash"
+	"github.com/filecoin-project/go-padreader"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/specs-storage/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPieceWorker(t *testing.T) {
+	ctx := context.Background()
+
+	// Stop waiting for reader parameters that are never read after the
+	// request is rejected
+	rpcenc.Timeout = time.Second
+
+	data := make([]byte, 4000)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+
+	sealer := &mockSealer{}
+	srv := httptest.NewServer(Handler(NewWorker(sealer, 1), TokenVerifier("secret")))
+	defer srv.Close()
+
+	pw, closer, err := Connect(ctx, srv.URL, "secret")
+	require.NoError(t, err)
+	defer closer()
+
+	t.Run("compute commp", func(t *testing.T) {
+		pr, size := padreader.New(bytes.NewReader(data), uint64(len(data)))
+		pi, err := pw.ComputeDataCid(ctx, size, pr)
+		require.NoError(t, err)
+
+		cp := &commp.Calc{}
+		pr, _ = padreader.New(bytes.NewReader(data), uint64(len(data)))
+		_, err = io.Copy(cp, pr)
+		require.NoError(t, err)
+		rawCommp, paddedSize, err := cp.Digest()
+		require.NoError(t, err)
+		expected, err := commcid.DataCommitmentV1ToCID(rawCommp)
+		require.NoError(t, err)
+
+		require.Equal(t, expected, pi.PieceCID)
+		require.EqualValues(t, paddedSize, pi.Size)
+		require.Equal(t, size.Padded(), pi.Size)
+	})
+
+	t.Run("add piece", func(t *testing.T) {
+		sector, offset, err := NewPieceAdder(pw).AddPiece(ctx, abi.UnpaddedPieceSize(len(data)), bytes.NewReader(data), api.PieceDealInfo{DealID: 5})
+		require.NoError(t, err)
+		require.EqualValues(t, 7, sector)
+		require.EqualValues(t, 2048, offset)
+		require.Equal(t, data, sealer.received)
+	})
+
+	t.Run("add piece without a sealer", func(t *testing.T) {
+		srv := httptest.NewServer(Handler(NewWorker(nil, 1), TokenVerifier("secret")))
+		defer srv.Close()
+
+		pw, closer, err := Connect(ctx, srv.URL, "secret")
+		require.NoError(t, err)
+		defer closer()
+
+		_, _, err = NewPieceAdder(pw).AddPiece(ctx, abi.UnpaddedPieceSize(len(data)), bytes.NewReader(data), api.PieceDealInfo{DealID: 5})
+		require.ErrorContains(t, err, ErrAddPieceNotSupported.Error())
+	})
+
+	t.Run("requires a token", func(t *testing.T) {
+		for _, token := range []string{"", "wrong"} {
+			pw, closer, err := Connect(ctx, srv.URL, token)
+			require.NoError(t, err)
+
+			pr, size := padreader.New(bytes.NewReader(data), uint64(len(data)))
+			_, err = pw.ComputeDataCid(ctx, size, pr)
+			require.Error(t, err)
+			closer()
+		}
+	})
+}
+
+type mockSealer struct {
+	received []byte
+}
+
+func (s *mockSealer) SectorAddPieceToAny(ctx context.Context, size abi.UnpaddedPieceSize, r storage.Data, d api.PieceDealInfo) (api.SectorOffset, error) {
+	received, err := io.ReadAll(r)
+	if err != nil {
+		return api.SectorOffset{}, err
+	}
+	s.received = received
+	return api.SectorOffset{Sector: 7, Offset: 2048}, nil
+}
@@ -0,0 +1,225 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("profiler")
+
+type Config struct {
+	// The directory that profiles are written to
+	Dir string
+	// How often to check memory usage and the number of goroutines
+	CheckInterval time.Duration
+	// Capture profiles when the heap is larger than this number of bytes.
+	// Zero disables the heap threshold.
+	HeapThreshold uint64
+	// Capture profiles when there are more than this number of goroutines.
+	// Zero disables the goroutine threshold.
+	GoroutineThreshold int
+	// How long to capture the CPU profile for
+	CPUProfileDuration time.Duration
+	// The minimum time between captures, so that profiles aren't captured
+	// continuously while usage is above a threshold
+	Cooldown time.Duration
+	// The maximum number of captures to keep: the oldest captures are
+	// deleted when there are more
+	MaxCaptures int
+}
+
+// Watchdog periodically checks the size of the heap and the number of
+// goroutines, and when either exceeds its threshold captures a CPU, heap
+// and goroutine profile, so that resource spikes can be diagnosed after the
+// fact. Each capture is written to a directory named after the time and the
+// reason for the capture, eg 20230201T100000-heap/{cpu,heap,goroutine}.pprof
+type Watchdog struct {
+	cfg Config
+
+	lk          sync.Mutex
+	lastCapture time.Time
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewWatchdog(cfg Config) *Watchdog {
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = 10 * time.Second
+	}
+	if cfg.MaxCaptures <= 0 {
+		cfg.MaxCaptures = 1
+	}
+	return &Watchdog{cfg: cfg}
+}
+
+func (w *Watchdog) Start(ctx context.Context) {
+	log.Infow("starting profiling watchdog", "dir", w.cfg.Dir, "heap threshold", w.cfg.HeapThreshold,
+		"goroutine threshold", w.cfg.GoroutineThreshold)
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.wg.Add(1)
+	go w.run(ctx)
+}
+
+func (w *Watchdog) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.wg.Wait()
+}
+
+func (w *Watchdog) run(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reason := w.check()
+			if reason == "" {
+				continue
+			}
+			dir, err := w.capture(ctx, reason, time.Now())
+			if err != nil {
+				log.Errorw("capturing profiles", "reason", reason, "err", err)
+				continue
+			}
+			log.Warnw("captured profiles", "reason", reason, "dir", dir)
+		}
+	}
+}
+
+// check returns the reason to capture profiles, or the empty string if
+// usage is below the thresholds (or the last capture was too recent)
+func (w *Watchdog) check() string {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	if !w.lastCapture.IsZero() && time.Since(w.lastCapture) < w.cfg.Cooldown {
+		return ""
+	}
+
+	if w.cfg.GoroutineThreshold > 0 && runtime.NumGoroutine() > w.cfg.GoroutineThreshold {
+		return "goroutines"
+	}
+	if w.cfg.HeapThreshold > 0 {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		if ms.HeapAlloc > w.cfg.HeapThreshold {
+			return "heap"
+		}
+	}
+	return ""
+}
+
+// capture writes a CPU, heap and goroutine profile to a new directory, and
+// deletes the oldest captures if there are more than the maximum
+func (w *Watchdog) capture(ctx context.Context, reason string, now time.Time) (string, error) {
+	w.lk.Lock()
+	w.lastCapture = now
+	w.lk.Unlock()
+
+	dir := filepath.Join(w.cfg.Dir, now.UTC().Format("20060102T150405")+"-"+reason)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("creating profile directory: %w", err)
+	}
+
+	// Capture the goroutine and heap profiles first, as they show the state
+	// at the time the threshold was exceeded
+	for _, name := range []string{"goroutine", "heap"} {
+		if err := writeProfile(filepath.Join(dir, name+".pprof"), func(f *os.File) error {
+			return pprof.Lookup(name).WriteTo(f, 0)
+		}); err != nil {
+			return dir, fmt.Errorf("writing %s profile: %w", name, err)
+		}
+	}
+
+	if w.cfg.CPUProfileDuration > 0 {
+		err := writeProfile(filepath.Join(dir, "cpu.pprof"), func(f *os.File) error {
+			// Fails if a CPU profile is already being captured (eg through
+			// the /debug/pprof/profile endpoint)
+			if err := pprof.StartCPUProfile(f); err != nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(w.cfg.CPUProfileDuration):
+			}
+			pprof.StopCPUProfile()
+			return nil
+		})
+		if err != nil {
+			log.Warnw("capturing CPU profile", "dir", dir, "err", err)
+		}
+	}
+
+	if err := w.rotate(); err != nil {
+		return dir, fmt.Errorf("deleting old profiles: %w", err)
+	}
+	return dir, nil
+}
+
+func writeProfile(path string, write func(f *os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// rotate deletes the oldest captures so that there are at most
+// MaxCaptures captures
+func (w *Watchdog) rotate() error {
+	captures, err := w.Captures()
+	if err != nil {
+		return err
+	}
+	for len(captures) > w.cfg.MaxCaptures {
+		if err := os.RemoveAll(filepath.Join(w.cfg.Dir, captures[0])); err != nil {
+			return err
+		}
+		captures = captures[1:]
+	}
+	return nil
+}
+
+// Captures returns the name of the directory of each capture, oldest first
+func (w *Watchdog) Captures() ([]string, error) {
+	entries, err := os.ReadDir(w.cfg.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var captures []string
+	for _, e := range entries {
+		if e.IsDir() && strings.Contains(e.Name(), "-") {
+			captures = append(captures, e.Name())
+		}
+	}
+	// The directory names start with the time, so they sort by time
+	sort.Strings(captures)
+	return captures, nil
+}
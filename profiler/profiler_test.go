@@ -0,0 +1,50 @@
+package profiler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchdog(t *testing.T) {
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	w := NewWatchdog(Config{
+		Dir:                dir,
+		GoroutineThreshold: 1,
+		CPUProfileDuration: 10 * time.Millisecond,
+		Cooldown:           time.Hour,
+		MaxCaptures:        2,
+	})
+
+	// There's more than one goroutine
+	require.Equal(t, "goroutines", w.check())
+
+	now := time.Now()
+	capDir, err := w.capture(ctx, "goroutines", now)
+	require.NoError(t, err)
+	for _, name := range []string{"cpu", "heap", "goroutine"} {
+		fi, err := os.Stat(filepath.Join(capDir, name+".pprof"))
+		require.NoError(t, err)
+		require.NotZero(t, fi.Size())
+	}
+
+	// No more profiles are captured until the cooldown expires
+	require.Equal(t, "", w.check())
+
+	// The oldest captures are deleted
+	_, err = w.capture(ctx, "heap", now.Add(time.Minute))
+	require.NoError(t, err)
+	_, err = w.capture(ctx, "heap", now.Add(2*time.Minute))
+	require.NoError(t, err)
+
+	captures, err := w.Captures()
+	require.NoError(t, err)
+	require.Len(t, captures, 2)
+	require.Equal(t, now.Add(time.Minute).UTC().Format("20060102T150405")+"-heap", captures[0])
+}
@@ -158,7 +158,10 @@ export function DealDetail(props) {
                 </tr>
                 <tr>
                     <th>Announce To IPNI</th>
-                    <td>{deal.AnnounceToIPNI ? 'Yes' : 'No'}</td>
+                    <td>
+                        {deal.AnnounceToIPNI ? (deal.AnnounceAfterSealing ? 'After sealing' : 'Yes') : 'No'}
+                        {deal.AnnounceRule ? <span className="aux"> ({deal.AnnounceRule})</span> : null}
+                    </td>
                 </tr>
                 <tr>
                     <th>Piece CID</th>
@@ -180,6 +183,15 @@ export function DealDetail(props) {
                     <th>Provider Collateral</th>
                     <td>{humanFIL(deal.ProviderCollateral)}</td>
                 </tr>
+                {deal.CollateralWallet ? (
+                    <tr>
+                        <th>Collateral Wallet</th>
+                        <td>
+                            {deal.CollateralWallet}
+                            {deal.CollateralRule ? <span className="aux"> ({deal.CollateralRule})</span> : null}
+                        </td>
+                    </tr>
+                ) : null}
                 <tr>
                     <th>Storage Price / epoch / GiB</th>
                     <td>{humanFIL(storagePricePerEpochPerGiB )}</td>
@@ -6,7 +6,7 @@ import {PageContainer, ShortClientAddress, ShortDealID, ShortDealLink} from "./C
 import {Link} from "react-router-dom";
 import sendImg from './bootstrap-icons/icons/send.svg'
 import './DealPublish.css'
-import {humanFileSize} from "./util";
+import {humanFileSize, humanFIL} from "./util";
 import {ShowBanner} from "./Banner";
 
 export function DealPublishPage(props) {
@@ -36,10 +36,17 @@ function DealPublishContent() {
         ShowBanner('Published '+dealCount+' deals')
     }
 
+    async function doPublishHeld() {
+        await publishNow()
+        ShowBanner('Published deals held for gas')
+    }
+
     var period = moment.duration(data.dealPublish.Period, 'seconds')
     var publishTime = moment(data.dealPublish.Start).add(period)
 
     var deals = data.dealPublish.Deals
+    var held = data.dealPublish.HeldForGas
+    var maxBaseFee = data.dealPublish.MaxBaseFee
     return <div>
         {deals.length ? (
             <>
@@ -66,19 +73,46 @@ function DealPublishContent() {
                     <th>Max deals per message</th>
                     <td>{data.dealPublish.MaxDealsPerMsg}</td>
                 </tr>
+                <tr>
+                    <th>Max base fee</th>
+                    <td>{maxBaseFee === '0' ? 'No limit' : humanFIL(maxBaseFee)}</td>
+                </tr>
             </tbody>
         </table>
 
-        { deals.length ? <DealsTable deals={deals} /> : (
+        { held.map((batch, i) => <HeldBatch key={i} batch={batch} onPublish={doPublishHeld} />) }
+
+        { deals.length ? <DealsTable title="Deals" deals={deals} /> : (
             <p>There are no deals in the batch publish queue</p>
         ) }
     </div>
 }
 
+function HeldBatch(props) {
+    const batch = props.batch
+    const heldSince = moment(batch.HeldSince)
+    return (
+        <div className="held-for-gas">
+            <p>
+                {batch.Deals.length} deal{batch.Deals.length === 1 ? '' : 's'} held
+                since <b>{heldSince.format('HH:mm:ss')}</b> ({heldSince.fromNow()}) because
+                the base fee of <b>{humanFIL(batch.BaseFee)}</b> is above the maximum
+                of <b>{humanFIL(batch.MaxBaseFee)}</b>
+            </p>
+
+            <div className="buttons">
+                <div className="button" onClick={props.onPublish}>Publish Now</div>
+            </div>
+
+            <DealsTable title="Held for gas" deals={batch.Deals} />
+        </div>
+    )
+}
+
 function DealsTable(props) {
     return (
         <>
-            <h3>Deals</h3>
+            <h3>{props.title}</h3>
 
             <table className="deals">
                 <tbody>
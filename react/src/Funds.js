@@ -1,6 +1,6 @@
 /* global BigInt */
 import {useMutation, useQuery} from "@apollo/react-hooks";
-import {FundsQuery, FundsLogsQuery, FundsMoveToEscrow} from "./gql";
+import {FundsQuery, FundsForecastQuery, FundsLogsQuery, FundsMoveToEscrow} from "./gql";
 import {useState, useEffect, React}  from "react";
 import moment from "moment";
 import {humanFIL, max, parseFil} from "./util"
@@ -17,6 +17,7 @@ export function FundsPage(props) {
     return (
         <PageContainer pageType="funds" title="Funds">
             <FundsChart />
+            <FundsForecast />
             <FundsLogs />
         </PageContainer>
     )
@@ -260,6 +261,62 @@ function TopupCollateral(props) {
     )
 }
 
+function FundsForecast(props) {
+    const {loading, error, data} = useQuery(FundsForecastQuery, { pollInterval: 5000 })
+
+    if (loading) {
+        return <div>Loading...</div>
+    }
+    if (error) {
+        return <div>Error: {error.message}</div>
+    }
+
+    const forecast = data.fundsForecast
+    return <div className="funds-forecast-section">
+        <h3>
+            Forecast
+            <Info>
+                The funds that will be needed to publish the deals that have
+                been accepted but not yet published.<br/>
+                <br/>
+                When a deal is published the collateral for the deal is locked
+                in escrow, and the fee for the Publish Storage Deals message is
+                paid from the Publish Storage Deals Wallet. If there are not
+                enough funds the deal will fail to publish.
+            </Info>
+        </h3>
+        {forecast.Warnings.map((w, i) => <div key={i} className="funds-forecast-warning">{w}</div>)}
+        <table className="funds-forecast">
+            <tbody>
+                <tr>
+                    <th>Pending deals</th>
+                    <td>{forecast.PendingDeals} ({forecast.AwaitingData} awaiting data, {forecast.AwaitingPublish} awaiting publish)</td>
+                </tr>
+                <tr>
+                    <th>Collateral needed</th>
+                    <td>{humanFIL(forecast.Collateral)} of {humanFIL(forecast.EscrowAvailable)} available in escrow</td>
+                </tr>
+                {forecast.CollateralShortfall > 0n ? (
+                    <tr className="shortfall">
+                        <th>Collateral shortfall</th>
+                        <td>{humanFIL(forecast.CollateralShortfall)}</td>
+                    </tr>
+                ) : null}
+                <tr>
+                    <th>Publish fees reserved</th>
+                    <td>{humanFIL(forecast.PublishFees)} of {humanFIL(forecast.PubMsgBalance)} in the publish wallet</td>
+                </tr>
+                {forecast.PublishFeesShortfall > 0n ? (
+                    <tr className="shortfall">
+                        <th>Publish fees shortfall</th>
+                        <td>{humanFIL(forecast.PublishFeesShortfall)}</td>
+                    </tr>
+                ) : null}
+            </tbody>
+        </table>
+    </div>
+}
+
 function FundsLogs(props) {
     const params = useParams()
     const pageNum = params.pageNum ? parseInt(params.pageNum) : 1
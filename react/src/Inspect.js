@@ -164,6 +164,12 @@ function PieceStatus({pieceCid, pieceStatus, searchQuery}) {
                     <th>Index Status</th>
                     <td>{pieceStatus.IndexStatus.Status}</td>
                 </tr>
+                {pieceStatus.BlockCount !== null ? (
+                    <tr key="block count">
+                        <th>Block Count</th>
+                        <td>{pieceStatus.BlockCount+''}</td>
+                    </tr>
+                ) : null}
                 </tbody>
             </table>
 
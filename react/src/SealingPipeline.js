@@ -32,6 +32,7 @@ function SealingPipelineContent(props) {
         <WaitDeals wdSectors={sealingPipeline.WaitDealsSectors} sdwdSectors={sealingPipeline.SnapDealsWaitDealsSectors} />
         <Sealing states={sealingPipeline.SectorStates} />
         <Workers workers={sealingPipeline.Workers} />
+        <WorkerUtilization workers={sealingPipeline.WorkerUtilization} />
     </div>
 }
 
@@ -63,7 +64,7 @@ function WaitDealsType(props) {
 
 function WaitDealsSector(props) {
     const sector = props.sector
-    const free = sector.SectorSize - sector.Used
+    const free = sector.Free
     const haveDeals = sector.Deals.length > 0
 
     var bars = [{
@@ -192,6 +193,40 @@ function Workers(props) {
     </div>
 }
 
+function WorkerUtilization(props) {
+    if (props.workers.length === 0) {
+        return null
+    }
+
+    return <div className="worker-utilization">
+        <div className="title">Worker Utilization</div>
+        <table>
+            <tbody>
+            <tr>
+                <th className="hostname">Host</th>
+                <th className="worker-id">ID</th>
+                <th className="cpu">CPU</th>
+                <th className="gpu">GPU</th>
+                <th className="memory">Memory</th>
+                <th className="jobs">Running</th>
+                <th className="jobs">Queued</th>
+            </tr>
+            {props.workers.map(w => (
+                <tr key={w.ID} className={w.Enabled ? '' : 'disabled'}>
+                    <td className="hostname">{w.Hostname}{w.Enabled ? '' : ' (disabled)'}</td>
+                    <td className="worker-id">{w.ID}</td>
+                    <td className="cpu">{w.CPUsUsed+''} / {w.CPUs+''}</td>
+                    <td className="gpu">{w.GPUs ? w.GPUsUsed.toFixed(2) + ' / ' + w.GPUs : '-'}</td>
+                    <td className="memory">{humanFileSize(w.MemUsed)} / {humanFileSize(w.MemPhysical)}</td>
+                    <td className="jobs">{w.RunningJobs}</td>
+                    <td className="jobs">{w.QueuedJobs}</td>
+                </tr>
+            ))}
+            </tbody>
+        </table>
+    </div>
+}
+
 export function SealingPipelineMenuItem(props) {
     const {data} = useQuery(SealingPipelineQuery, {
         pollInterval: 5000,
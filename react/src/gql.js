@@ -7,12 +7,24 @@ import { transformResponse } from "./transform";
 
 var graphqlEndpoint = window.location.host
 var graphqlHttpEndpoint = window.location.origin
+// Use a secure web socket if the page was served over TLS
+var graphqlWsScheme = window.location.protocol === 'https:' ? 'wss' : 'ws'
 
 if (process.env.NODE_ENV === 'development') {
     graphqlEndpoint = 'localhost:8080'
     graphqlHttpEndpoint = 'http://' + graphqlEndpoint
+    graphqlWsScheme = 'ws'
 }
 
+// If the boost graphql server requires authentication, the API token can be
+// passed in the URL (eg http://localhost:8080/?token=<token>). It is saved to
+// local storage so that it doesn't need to be passed on every page.
+const tokenParam = new URLSearchParams(window.location.search).get('token')
+if (tokenParam) {
+    window.localStorage.setItem('boost-api-token', tokenParam)
+}
+const apiToken = window.localStorage.getItem('boost-api-token')
+
 // Transform response data (eg convert date string to Date object)
 const transformResponseLink = new ApolloLink((operation, forward) => {
     const res = forward(operation)
@@ -25,11 +37,12 @@ const transformResponseLink = new ApolloLink((operation, forward) => {
 // HTTP Link
 const httpLink = new HttpLink({
     uri: `${graphqlHttpEndpoint}/graphql/query`,
+    headers: apiToken ? { Authorization: `Bearer ${apiToken}` } : {},
 });
 
 // WebSocket Link
 const wsLink = new WebSocketLink({
-    uri: `ws://${graphqlEndpoint}/graphql/subscription`,
+    uri: `${graphqlWsScheme}://${graphqlEndpoint}/graphql/subscription` + (apiToken ? `?token=${encodeURIComponent(apiToken)}` : ''),
     options: {
         reconnect: true,
         minTimeout: 5000,
@@ -169,6 +182,10 @@ const DealSubscription = gql`
             Checkpoint
             CheckpointAt
             AnnounceToIPNI
+            AnnounceAfterSealing
+            AnnounceRule
+            CollateralWallet
+            CollateralRule
             KeepUnsealedCopy
             Retry
             Err
@@ -299,6 +316,19 @@ const DealCancelMutation = gql`
     }
 `;
 
+const TransferProgressSubscription = gql`
+    subscription AppTransferProgressSubscription($id: ID!) {
+        transferProgress(id: $id) {
+            DealID
+            BytesReceived
+            TransferSize
+            Rate
+            Stalled
+            Complete
+        }
+    }
+`;
+
 const DealRetryPausedMutation = gql`
     mutation AppDealRetryMutation($id: ID!) {
         dealRetryPaused(id: $id)
@@ -404,6 +434,7 @@ const PieceStatusQuery = gql`
                 Status
                 Error
             }
+            BlockCount
             Deals {
                 SealStatus {
                     IsUnsealed
@@ -465,6 +496,7 @@ const SealingPipelineQuery = gql`
             WaitDealsSectors {
                 SectorID
                 Used
+                Free
                 SectorSize
                 Deals {
                     ID
@@ -475,6 +507,7 @@ const SealingPipelineQuery = gql`
             SnapDealsWaitDealsSectors {
                 SectorID
                 Used
+                Free
                 SectorSize
                 Deals {
                     ID
@@ -510,6 +543,19 @@ const SealingPipelineQuery = gql`
                 Stage
                 Sector
             }
+            WorkerUtilization {
+                ID
+                Hostname
+                Enabled
+                CPUs
+                CPUsUsed
+                GPUs
+                GPUsUsed
+                MemPhysical
+                MemUsed
+                RunningJobs
+                QueuedJobs
+            }
         }
     }
 `;
@@ -535,6 +581,23 @@ const FundsQuery = gql`
     }
 `;
 
+const FundsForecastQuery = gql`
+    query AppFundsForecastQuery {
+        fundsForecast {
+            PendingDeals
+            AwaitingData
+            AwaitingPublish
+            Collateral
+            EscrowAvailable
+            CollateralShortfall
+            PublishFees
+            PubMsgBalance
+            PublishFeesShortfall
+            Warnings
+        }
+    }
+`;
+
 const TransfersQuery = gql`
     query AppTransfersQuery {
         transfers {
@@ -583,6 +646,7 @@ const DealPublishQuery = gql`
             Start
             Period
             MaxDealsPerMsg
+            MaxBaseFee
             Deals {
                 ID
                 IsLegacy
@@ -593,6 +657,21 @@ const DealPublishQuery = gql`
                 ClientAddress
                 PieceSize
             }
+            HeldForGas {
+                HeldSince
+                BaseFee
+                MaxBaseFee
+                Deals {
+                    ID
+                    IsLegacy
+                    CreatedAt
+                    Transfer {
+                        Size
+                    }
+                    ClientAddress
+                    PieceSize
+                }
+            }
         }
     }
 `;
@@ -668,6 +747,7 @@ export {
     DealRetryPausedMutation,
     DealFailPausedMutation,
     NewDealsSubscription,
+    TransferProgressSubscription,
     ProposalLogsListQuery,
     ProposalLogsCountQuery,
     RetrievalLogQuery,
@@ -679,6 +759,7 @@ export {
     StorageQuery,
     LegacyStorageQuery,
     FundsQuery,
+    FundsForecastQuery,
     FundsLogsQuery,
     DealPublishQuery,
     DealPublishNowMutation,
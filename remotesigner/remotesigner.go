@@ -0,0 +1,406 @@
+package remotesigner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/client"
+	"github.com/filecoin-project/lotus/api/v1api"
+	"github.com/filecoin-project/lotus/chain/actors"
+	marketactor "github.com/filecoin-project/lotus/chain/actors/builtin/market"
+	"github.com/filecoin-project/lotus/chain/types"
+	cliutil "github.com/filecoin-project/lotus/cli/util"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("remotesigner")
+
+// Connect connects to the wallet API of a remote signer, eg a lotus-wallet
+// instance or an HSM-backed signer that implements the lotus wallet API.
+// The url may be a multiaddr or an http / ws URL.
+func Connect(ctx context.Context, url string, token string) (api.Wallet, func(), error) {
+	ai := cliutil.APIInfo{Addr: url}
+	if token != "" {
+		ai.Token = []byte(token)
+	}
+
+	addr, err := ai.DialArgs("v0")
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing remote signer url '%s': %w", url, err)
+	}
+
+	wapi, closer, err := client.NewWalletRPCV0(ctx, addr, ai.AuthHeader())
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating remote signer client: %w", err)
+	}
+	return wapi, closer, nil
+}
+
+// FullNode wraps a lotus full node API, delegating all signing to a remote
+// signer so that private keys never need to be on the boost host or on the
+// full node.
+// Messages are signed by the remote signer and pushed to the full node's
+// message pool, so the full node only needs to relay them.
+// Nonces are assigned one message at a time for each wallet in this
+// process. If another process sends a message from the same wallet with
+// the same nonce, the message is signed again with the next nonce.
+type FullNode struct {
+	v1api.FullNode
+	signer api.Wallet
+
+	lk       sync.Mutex
+	walletLk map[address.Address]*sync.Mutex
+
+	// The funds reserved in each address's escrow balance, and the messages
+	// that add funds to it that haven't landed yet. The state is persisted
+	// in the datastore so that it survives a restart.
+	fundsLk sync.Mutex
+	ds      datastore.Datastore
+	escrow  map[address.Address]*escrowState
+}
+
+// escrowState is the state of the funds reserved in an address's escrow
+// balance with the storage market actor
+type escrowState struct {
+	// The funds reserved by MarketReserveFunds that haven't been released
+	Reserved abi.TokenAmount
+	// The messages sent to add funds to escrow that haven't landed yet
+	Pending []pendingAdd
+}
+
+// pendingAdd is a message that adds funds to escrow
+type pendingAdd struct {
+	MessageCid cid.Cid
+	Amount     abi.TokenAmount
+	SentAt     time.Time
+}
+
+// If an add balance message hasn't landed after this long, it's assumed
+// that it was dropped from the message pool
+const maxPendingAddAge = 2 * time.Hour
+
+// The number of times to try pushing a message, when the nonce was taken by
+// a message sent by another process
+const maxPushAttempts = 3
+
+var _ v1api.FullNode = (*FullNode)(nil)
+
+// NewFullNode wraps the full node API. The escrow reservations are
+// persisted in the datastore.
+func NewFullNode(full v1api.FullNode, signer api.Wallet, ds datastore.Datastore) *FullNode {
+	return &FullNode{
+		FullNode: full,
+		signer:   signer,
+		walletLk: make(map[address.Address]*sync.Mutex),
+		ds:       ds,
+		escrow:   make(map[address.Address]*escrowState),
+	}
+}
+
+// WalletHas indicates whether the remote signer has the key for the address
+func (n *FullNode) WalletHas(ctx context.Context, addr address.Address) (bool, error) {
+	key, err := n.accountKey(ctx, addr)
+	if err != nil {
+		return false, err
+	}
+	return n.signer.WalletHas(ctx, key)
+}
+
+// WalletList lists the addresses in the remote signer's wallet
+func (n *FullNode) WalletList(ctx context.Context) ([]address.Address, error) {
+	return n.signer.WalletList(ctx)
+}
+
+// WalletSign signs the bytes with the remote signer
+func (n *FullNode) WalletSign(ctx context.Context, addr address.Address, b []byte) (*crypto.Signature, error) {
+	key, err := n.accountKey(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	return n.signer.WalletSign(ctx, key, b, api.MsgMeta{Type: api.MTUnknown})
+}
+
+// WalletSignMessage signs the message with the remote signer
+func (n *FullNode) WalletSignMessage(ctx context.Context, addr address.Address, msg *types.Message) (*types.SignedMessage, error) {
+	key, err := n.accountKey(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mb, err := msg.ToStorageBlock()
+	if err != nil {
+		return nil, fmt.Errorf("serializing message: %w", err)
+	}
+
+	sig, err := n.signer.WalletSign(ctx, key, mb.Cid().Bytes(), api.MsgMeta{
+		Type:  api.MTChainMsg,
+		Extra: mb.RawData(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("signing message with remote signer: %w", err)
+	}
+
+	return &types.SignedMessage{Message: *msg, Signature: *sig}, nil
+}
+
+// MpoolPushMessage estimates gas for the message, assigns it a nonce, signs
+// it with the remote signer and pushes it to the message pool
+func (n *FullNode) MpoolPushMessage(ctx context.Context, msg *types.Message, spec *api.MessageSendSpec) (*types.SignedMessage, error) {
+	if msg.Nonce != 0 {
+		return nil, fmt.Errorf("message nonce must be zero as it is assigned by the remote signer wrapper, got %d", msg.Nonce)
+	}
+
+	// Make sure that only one message is assigned a nonce at a time for
+	// each wallet
+	key, err := n.accountKey(ctx, msg.From)
+	if err != nil {
+		return nil, err
+	}
+	lk := n.lockFor(key)
+	lk.Lock()
+	defer lk.Unlock()
+
+	estimated, err := n.GasEstimateMessageGas(ctx, msg, spec, types.EmptyTSK)
+	if err != nil {
+		return nil, fmt.Errorf("estimating gas: %w", err)
+	}
+
+	nonce, err := n.MpoolGetNonce(ctx, estimated.From)
+	if err != nil {
+		return nil, fmt.Errorf("getting nonce for %s: %w", estimated.From, err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		estimated.Nonce = nonce
+		smsg, err := n.WalletSignMessage(ctx, estimated.From, estimated)
+		if err != nil {
+			return nil, err
+		}
+
+		_, pushErr := n.MpoolPush(ctx, smsg)
+		if pushErr == nil {
+			log.Infow("pushed message signed by remote signer", "from", smsg.Message.From, "to", smsg.Message.To,
+				"method", smsg.Message.Method, "nonce", nonce, "cid", smsg.Cid())
+			return smsg, nil
+		}
+
+		// The nonce lock only covers this process: if the nonce has moved
+		// on, the nonce was taken by a message from another process, so
+		// try again with the next nonce
+		if attempt == maxPushAttempts {
+			return nil, fmt.Errorf("pushing message signed by remote signer: %w", pushErr)
+		}
+		next, err := n.MpoolGetNonce(ctx, estimated.From)
+		if err != nil || next <= nonce {
+			return nil, fmt.Errorf("pushing message signed by remote signer: %w", pushErr)
+		}
+		log.Warnw("message nonce was taken by another message from the same wallet: retrying with the next nonce",
+			"from", estimated.From, "nonce", nonce, "next", next, "err", pushErr)
+		nonce = next
+	}
+}
+
+// MpoolBatchPushMessage pushes each message with MpoolPushMessage
+func (n *FullNode) MpoolBatchPushMessage(ctx context.Context, msgs []*types.Message, spec *api.MessageSendSpec) ([]*types.SignedMessage, error) {
+	smsgs := make([]*types.SignedMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		smsg, err := n.MpoolPushMessage(ctx, msg, spec)
+		if err != nil {
+			return smsgs, err
+		}
+		smsgs = append(smsgs, smsg)
+	}
+	return smsgs, nil
+}
+
+// MarketAddBalance sends a message signed by the remote signer to add funds
+// to the address's escrow balance with the storage market actor
+func (n *FullNode) MarketAddBalance(ctx context.Context, wallet, addr address.Address, amt types.BigInt) (cid.Cid, error) {
+	params, aerr := actors.SerializeParams(&addr)
+	if aerr != nil {
+		return cid.Undef, aerr
+	}
+
+	smsg, err := n.MpoolPushMessage(ctx, &types.Message{
+		To:     marketactor.Address,
+		From:   wallet,
+		Value:  amt,
+		Method: marketactor.Methods.AddBalance,
+		Params: params,
+	}, nil)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return smsg.Cid(), nil
+}
+
+// MarketReserveFunds reserves funds in the address's escrow balance with the
+// storage market actor. If the available escrow balance, plus the funds
+// being added by messages that haven't landed yet, doesn't cover all the
+// funds reserved for the address, the shortfall is added from the wallet
+// with a message signed by the remote signer, and the message CID is
+// returned (or cid.Undef if no message was needed).
+func (n *FullNode) MarketReserveFunds(ctx context.Context, wallet address.Address, addr address.Address, amt types.BigInt) (cid.Cid, error) {
+	n.fundsLk.Lock()
+	defer n.fundsLk.Unlock()
+
+	st, err := n.escrowState(ctx, addr)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	// Get the balance and the state of pending messages at the same tipset,
+	// so that the funds from a message that lands in between aren't counted
+	// twice
+	head, err := n.ChainHead(ctx)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("getting chain head: %w", err)
+	}
+	pending, err := n.updatePending(ctx, head.Key(), addr, st)
+	if err != nil {
+		return cid.Undef, err
+	}
+	bal, err := n.StateMarketBalance(ctx, addr, head.Key())
+	if err != nil {
+		return cid.Undef, fmt.Errorf("getting market balance for %s: %w", addr, err)
+	}
+
+	reserved := big.Add(st.Reserved, amt)
+	available := big.Add(big.Sub(bal.Escrow, bal.Locked), pending)
+	shortfall := big.Sub(reserved, available)
+	msgCid := cid.Undef
+	if shortfall.GreaterThan(big.Zero()) {
+		msgCid, err = n.MarketAddBalance(ctx, wallet, addr, shortfall)
+		if err != nil {
+			return cid.Undef, fmt.Errorf("adding %s to escrow for %s: %w", types.FIL(shortfall), addr, err)
+		}
+		st.Pending = append(st.Pending, pendingAdd{MessageCid: msgCid, Amount: shortfall, SentAt: time.Now()})
+		log.Infow("added funds to escrow to cover reservation", "addr", addr, "wallet", wallet,
+			"amount", types.FIL(shortfall), "reserved", types.FIL(reserved), "msg", msgCid)
+	}
+	st.Reserved = reserved
+
+	if err := n.saveEscrowState(ctx, addr, st); err != nil {
+		return cid.Undef, err
+	}
+	return msgCid, nil
+}
+
+// MarketReleaseFunds releases funds reserved with MarketReserveFunds
+func (n *FullNode) MarketReleaseFunds(ctx context.Context, addr address.Address, amt types.BigInt) error {
+	n.fundsLk.Lock()
+	defer n.fundsLk.Unlock()
+
+	st, err := n.escrowState(ctx, addr)
+	if err != nil {
+		return err
+	}
+	st.Reserved = big.Sub(st.Reserved, amt)
+	if st.Reserved.LessThan(big.Zero()) {
+		st.Reserved = big.Zero()
+	}
+	return n.saveEscrowState(ctx, addr, st)
+}
+
+// updatePending removes the messages that have landed (or that have been
+// pending for so long that they were probably dropped) from the pending
+// messages, and returns the total that the remaining messages add
+func (n *FullNode) updatePending(ctx context.Context, tsk types.TipSetKey, addr address.Address, st *escrowState) (abi.TokenAmount, error) {
+	total := big.Zero()
+	var stillPending []pendingAdd
+	for _, p := range st.Pending {
+		lookup, err := n.StateSearchMsg(ctx, tsk, p.MessageCid, api.LookbackNoLimit, true)
+		if err != nil {
+			return big.Zero(), fmt.Errorf("searching for add balance message %s: %w", p.MessageCid, err)
+		}
+		if lookup != nil {
+			if lookup.Receipt.ExitCode.IsError() {
+				log.Warnw("add balance message failed", "addr", addr, "msg", p.MessageCid, "exitcode", lookup.Receipt.ExitCode)
+			}
+			continue
+		}
+		if time.Since(p.SentAt) > maxPendingAddAge {
+			log.Warnw("add balance message has not landed: assuming it was dropped from the message pool",
+				"addr", addr, "msg", p.MessageCid, "amount", types.FIL(p.Amount), "sent", p.SentAt)
+			continue
+		}
+		stillPending = append(stillPending, p)
+		total = big.Add(total, p.Amount)
+	}
+	st.Pending = stillPending
+	return total, nil
+}
+
+func escrowStateKey(addr address.Address) datastore.Key {
+	return datastore.NewKey(addr.String())
+}
+
+// escrowState gets the escrow state for the address, loading it from the
+// datastore the first time. Must be called with fundsLk held.
+func (n *FullNode) escrowState(ctx context.Context, addr address.Address) (*escrowState, error) {
+	if st, ok := n.escrow[addr]; ok {
+		return st, nil
+	}
+
+	st := &escrowState{Reserved: big.Zero()}
+	data, err := n.ds.Get(ctx, escrowStateKey(addr))
+	switch {
+	case errors.Is(err, datastore.ErrNotFound):
+	case err != nil:
+		return nil, fmt.Errorf("getting escrow reservations for %s: %w", addr, err)
+	default:
+		if err := json.Unmarshal(data, st); err != nil {
+			return nil, fmt.Errorf("unmarshalling escrow reservations for %s: %w", addr, err)
+		}
+	}
+	n.escrow[addr] = st
+	return st, nil
+}
+
+func (n *FullNode) saveEscrowState(ctx context.Context, addr address.Address, st *escrowState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("marshalling escrow reservations for %s: %w", addr, err)
+	}
+	if err := n.ds.Put(ctx, escrowStateKey(addr), data); err != nil {
+		return fmt.Errorf("saving escrow reservations for %s: %w", addr, err)
+	}
+	return nil
+}
+
+// accountKey resolves the address to the key address that the remote
+// signer holds the key for
+func (n *FullNode) accountKey(ctx context.Context, addr address.Address) (address.Address, error) {
+	if addr.Protocol() == address.BLS || addr.Protocol() == address.SECP256K1 || addr.Protocol() == address.Delegated {
+		return addr, nil
+	}
+	key, err := n.StateAccountKey(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		return address.Undef, fmt.Errorf("getting account key for %s: %w", addr, err)
+	}
+	return key, nil
+}
+
+func (n *FullNode) lockFor(addr address.Address) *sync.Mutex {
+	n.lk.Lock()
+	defer n.lk.Unlock()
+
+	lk, ok := n.walletLk[addr]
+	if !ok {
+		lk = &sync.Mutex{}
+		n.walletLk[addr] = lk
+	}
+	return lk
+}
@@ -0,0 +1,312 @@
+package remotesigner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/lotus/api"
+	lotusmocks "github.com/filecoin-project/lotus/api/mocks"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/golang/mock/gomock"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFullNode(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	idAddr, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+	keyAddr, err := address.NewSecp256k1Address([]byte("key1"))
+	require.NoError(t, err)
+	otherAddr, err := address.NewSecp256k1Address([]byte("key2"))
+	require.NoError(t, err)
+
+	fn := lotusmocks.NewMockFullNode(ctrl)
+	fn.EXPECT().StateAccountKey(gomock.Any(), idAddr, gomock.Any()).Return(keyAddr, nil).AnyTimes()
+
+	signer := &mockWallet{keys: map[address.Address]bool{keyAddr: true}}
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	n := NewFullNode(fn, signer, ds)
+
+	t.Run("sign bytes", func(t *testing.T) {
+		sig, err := n.WalletSign(ctx, idAddr, []byte("hello"))
+		require.NoError(t, err)
+		require.Equal(t, []byte("hello"), sig.Data)
+		require.Equal(t, api.MsgType(api.MTUnknown), signer.lastMeta.Type)
+		require.Equal(t, keyAddr, signer.lastSigner)
+	})
+
+	t.Run("has key", func(t *testing.T) {
+		has, err := n.WalletHas(ctx, idAddr)
+		require.NoError(t, err)
+		require.True(t, has)
+
+		has, err = n.WalletHas(ctx, otherAddr)
+		require.NoError(t, err)
+		require.False(t, has)
+	})
+
+	t.Run("push message", func(t *testing.T) {
+		msg := &types.Message{From: idAddr, To: otherAddr, Value: abi.NewTokenAmount(10)}
+		fn.EXPECT().GasEstimateMessageGas(gomock.Any(), msg, gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, msg *types.Message, spec *api.MessageSendSpec, tsk types.TipSetKey) (*types.Message, error) {
+				est := *msg
+				est.GasLimit = 100
+				est.GasFeeCap = abi.NewTokenAmount(2)
+				est.GasPremium = abi.NewTokenAmount(1)
+				return &est, nil
+			})
+		fn.EXPECT().MpoolGetNonce(gomock.Any(), idAddr).Return(uint64(7), nil)
+
+		var pushed *types.SignedMessage
+		fn.EXPECT().MpoolPush(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, smsg *types.SignedMessage) (cid.Cid, error) {
+				pushed = smsg
+				return smsg.Cid(), nil
+			})
+
+		smsg, err := n.MpoolPushMessage(ctx, msg, nil)
+		require.NoError(t, err)
+		require.Equal(t, pushed, smsg)
+		require.EqualValues(t, 7, smsg.Message.Nonce)
+		require.EqualValues(t, 100, smsg.Message.GasLimit)
+
+		// Expect the message to be signed by the remote signer
+		require.Equal(t, api.MsgType(api.MTChainMsg), signer.lastMeta.Type)
+		require.Equal(t, keyAddr, signer.lastSigner)
+		mb, err := smsg.Message.ToStorageBlock()
+		require.NoError(t, err)
+		require.Equal(t, mb.Cid().Bytes(), smsg.Signature.Data)
+	})
+
+	// newFullNode returns a full node with its own mock, so that the
+	// expectations of each test don't interfere with each other
+	newFullNode := func(t *testing.T) (*FullNode, *lotusmocks.MockFullNode) {
+		fn := lotusmocks.NewMockFullNode(gomock.NewController(t))
+		fn.EXPECT().StateAccountKey(gomock.Any(), idAddr, gomock.Any()).Return(keyAddr, nil).AnyTimes()
+		return NewFullNode(fn, signer, ds), fn
+	}
+
+	t.Run("reserve funds", func(t *testing.T) {
+		n, fn := newFullNode(t)
+		head := testTipSet(t)
+		fn.EXPECT().ChainHead(gomock.Any()).Return(head, nil).AnyTimes()
+		fn.EXPECT().StateMarketBalance(gomock.Any(), idAddr, head.Key()).Return(
+			api.MarketBalance{Escrow: abi.NewTokenAmount(100), Locked: abi.NewTokenAmount(40)}, nil).AnyTimes()
+		chain := newMockChain(fn, keyAddr)
+
+		// The available balance covers the reservation
+		msgCid, err := n.MarketReserveFunds(ctx, keyAddr, idAddr, abi.NewTokenAmount(50))
+		require.NoError(t, err)
+		require.Equal(t, cid.Undef, msgCid)
+
+		// The shortfall is added with a message signed by the remote signer
+		msgCid, err = n.MarketReserveFunds(ctx, keyAddr, idAddr, abi.NewTokenAmount(30))
+		require.NoError(t, err)
+		pushed := chain.lastPushed()
+		require.Equal(t, pushed.Cid(), msgCid)
+		require.EqualValues(t, 20, pushed.Message.Value.Int64())
+		require.Equal(t, keyAddr, signer.lastSigner)
+
+		// The funds being added by the pending message count towards the
+		// next reservation
+		msgCid, err = n.MarketReserveFunds(ctx, keyAddr, idAddr, abi.NewTokenAmount(10))
+		require.NoError(t, err)
+		require.EqualValues(t, 10, chain.lastPushed().Message.Value.Int64())
+		require.Equal(t, chain.lastPushed().Cid(), msgCid)
+		require.Equal(t, 2, chain.pushedCount())
+
+		// Once the funds are released the available balance covers the
+		// reservation again
+		require.NoError(t, n.MarketReleaseFunds(ctx, idAddr, abi.NewTokenAmount(90)))
+		msgCid, err = n.MarketReserveFunds(ctx, keyAddr, idAddr, abi.NewTokenAmount(60))
+		require.NoError(t, err)
+		require.Equal(t, cid.Undef, msgCid)
+		require.Equal(t, 2, chain.pushedCount())
+
+		// The reservations and pending messages survive a restart
+		restarted := NewFullNode(fn, signer, ds)
+		msgCid, err = restarted.MarketReserveFunds(ctx, keyAddr, idAddr, abi.NewTokenAmount(40))
+		require.NoError(t, err)
+		require.Equal(t, 3, chain.pushedCount())
+		require.EqualValues(t, 10, chain.lastPushed().Message.Value.Int64())
+		require.Equal(t, chain.lastPushed().Cid(), msgCid)
+
+		// Once the messages land, the funds are part of the escrow balance
+		chain.landAll()
+		require.NoError(t, restarted.MarketReleaseFunds(ctx, idAddr, abi.NewTokenAmount(1000)))
+	})
+
+	t.Run("reserve funds in a burst", func(t *testing.T) {
+		n, fn := newFullNode(t)
+		burstAddr, err := address.NewIDAddress(1002)
+		require.NoError(t, err)
+		fn.EXPECT().ChainHead(gomock.Any()).Return(testTipSet(t), nil).AnyTimes()
+		fn.EXPECT().StateMarketBalance(gomock.Any(), burstAddr, gomock.Any()).Return(
+			api.MarketBalance{Escrow: abi.NewTokenAmount(100), Locked: abi.NewTokenAmount(0)}, nil).AnyTimes()
+		chain := newMockChain(fn, keyAddr)
+
+		// Expect exactly the shortfall to be added, however the concurrent
+		// reservations are interleaved
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := n.MarketReserveFunds(ctx, keyAddr, burstAddr, abi.NewTokenAmount(30))
+				require.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+		require.EqualValues(t, 10*30-100, chain.pushedTotal().Int64())
+	})
+
+	t.Run("retry push when the nonce is taken", func(t *testing.T) {
+		n, fn := newFullNode(t)
+		msg := &types.Message{From: idAddr, To: otherAddr, Value: abi.NewTokenAmount(10)}
+		fn.EXPECT().GasEstimateMessageGas(gomock.Any(), msg, gomock.Any(), gomock.Any()).Return(msg, nil)
+		gomock.InOrder(
+			fn.EXPECT().MpoolGetNonce(gomock.Any(), idAddr).Return(uint64(7), nil),
+			fn.EXPECT().MpoolGetNonce(gomock.Any(), idAddr).Return(uint64(8), nil),
+		)
+		gomock.InOrder(
+			fn.EXPECT().MpoolPush(gomock.Any(), gomock.Any()).Return(cid.Undef, errors.New("replace by fee has too low GasPremium")),
+			fn.EXPECT().MpoolPush(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, smsg *types.SignedMessage) (cid.Cid, error) {
+					return smsg.Cid(), nil
+				}),
+		)
+
+		smsg, err := n.MpoolPushMessage(ctx, msg, nil)
+		require.NoError(t, err)
+		require.EqualValues(t, 8, smsg.Message.Nonce)
+	})
+
+	t.Run("reject message with nonce", func(t *testing.T) {
+		msg := &types.Message{From: idAddr, To: otherAddr, Nonce: 1}
+		_, err := n.MpoolPushMessage(ctx, msg, nil)
+		require.Error(t, err)
+	})
+}
+
+// mockWallet is a remote signer whose signature is just the signed bytes
+type mockWallet struct {
+	api.Wallet
+
+	lk         sync.Mutex
+	keys       map[address.Address]bool
+	lastSigner address.Address
+	lastMeta   api.MsgMeta
+}
+
+func (w *mockWallet) WalletHas(ctx context.Context, addr address.Address) (bool, error) {
+	return w.keys[addr], nil
+}
+
+func (w *mockWallet) WalletSign(ctx context.Context, signer address.Address, toSign []byte, meta api.MsgMeta) (*crypto.Signature, error) {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	w.lastSigner = signer
+	w.lastMeta = meta
+	return &crypto.Signature{Type: crypto.SigTypeSecp256k1, Data: toSign}, nil
+}
+
+func testTipSet(t *testing.T) *types.TipSet {
+	mh, err := multihash.Sum([]byte("block"), multihash.SHA2_256, -1)
+	require.NoError(t, err)
+	c := cid.NewCidV1(cid.DagCBOR, mh)
+	miner, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	ts, err := types.NewTipSet([]*types.BlockHeader{{
+		Miner:                 miner,
+		Ticket:                &types.Ticket{VRFProof: []byte{1}},
+		ParentStateRoot:       c,
+		ParentMessageReceipts: c,
+		Messages:              c,
+		Height:                10,
+	}})
+	require.NoError(t, err)
+	return ts
+}
+
+// mockChain keeps track of the messages pushed from a wallet, which stay
+// pending until landAll is called
+type mockChain struct {
+	lk      sync.Mutex
+	nonce   uint64
+	pushed  []*types.SignedMessage
+	pending map[cid.Cid]bool
+}
+
+func newMockChain(fn *lotusmocks.MockFullNode, wallet address.Address) *mockChain {
+	c := &mockChain{pending: make(map[cid.Cid]bool)}
+	fn.EXPECT().GasEstimateMessageGas(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, msg *types.Message, spec *api.MessageSendSpec, tsk types.TipSetKey) (*types.Message, error) {
+			return msg, nil
+		}).AnyTimes()
+	fn.EXPECT().MpoolGetNonce(gomock.Any(), wallet).DoAndReturn(
+		func(ctx context.Context, addr address.Address) (uint64, error) {
+			c.lk.Lock()
+			defer c.lk.Unlock()
+			return c.nonce, nil
+		}).AnyTimes()
+	fn.EXPECT().MpoolPush(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, smsg *types.SignedMessage) (cid.Cid, error) {
+			c.lk.Lock()
+			defer c.lk.Unlock()
+			c.nonce++
+			c.pushed = append(c.pushed, smsg)
+			c.pending[smsg.Cid()] = true
+			return smsg.Cid(), nil
+		}).AnyTimes()
+	fn.EXPECT().StateSearchMsg(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, tsk types.TipSetKey, msg cid.Cid, limit abi.ChainEpoch, allowReplaced bool) (*api.MsgLookup, error) {
+			c.lk.Lock()
+			defer c.lk.Unlock()
+			if c.pending[msg] {
+				return nil, nil
+			}
+			return &api.MsgLookup{Message: msg}, nil
+		}).AnyTimes()
+	return c
+}
+
+func (c *mockChain) lastPushed() *types.SignedMessage {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return c.pushed[len(c.pushed)-1]
+}
+
+func (c *mockChain) pushedCount() int {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return len(c.pushed)
+}
+
+func (c *mockChain) pushedTotal() abi.TokenAmount {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	total := big.Zero()
+	for _, smsg := range c.pushed {
+		total = big.Add(total, smsg.Message.Value)
+	}
+	return total
+}
+
+func (c *mockChain) landAll() {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	c.pending = make(map[cid.Cid]bool)
+}
@@ -0,0 +1,156 @@
+package replication
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/filecoin-project/boost/db"
+)
+
+// upstreamCursor is the name of the cursor that a standby node uses to
+// record the last change it applied from the primary
+const upstreamCursor = "upstream"
+
+// Receiver runs on a standby node. It applies the changes to deals, and to
+// the funds, storage and logs of deals, that are sent by the primary node's
+// Streamer to the standby node's databases, so that the standby can take
+// over without losing accepted deals.
+type Receiver struct {
+	dealsDB   *db.DealsDB
+	fundsDB   *db.FundsDB
+	storageDB *db.StorageDB
+	logsDB    *db.LogsDB
+	changeLog *db.DealChangeLogDB
+	authToken string
+}
+
+func NewReceiver(dealsDB *db.DealsDB, fundsDB *db.FundsDB, storageDB *db.StorageDB, logsDB *db.LogsDB, changeLog *db.DealChangeLogDB, authToken string) (*Receiver, error) {
+	if authToken == "" {
+		return nil, errors.New("an auth token is required to receive deal changes")
+	}
+	return &Receiver{
+		dealsDB:   dealsDB,
+		fundsDB:   fundsDB,
+		storageDB: storageDB,
+		logsDB:    logsDB,
+		changeLog: changeLog,
+		authToken: authToken,
+	}, nil
+}
+
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), []byte("Bearer "+r.authToken)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var batch Batch
+	if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+		http.Error(w, fmt.Sprintf("decoding batch: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := r.Apply(req.Context(), batch.Changes); err != nil {
+		log.Errorw("failed to apply deal changes", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// Apply writes the changes to the standby's databases in order. Changes
+// that have already been applied are skipped, so that it's safe for the
+// primary to resend a batch that it didn't receive a response for.
+func (r *Receiver) Apply(ctx context.Context, changes []db.DealChange) error {
+	last, err := r.changeLog.Cursor(ctx, upstreamCursor)
+	if err != nil {
+		return fmt.Errorf("getting upstream cursor: %w", err)
+	}
+
+	for _, change := range changes {
+		if change.Seq <= last {
+			continue
+		}
+
+		if err := r.apply(ctx, change); err != nil {
+			return fmt.Errorf("applying %s change %d to deal %s: %w", change.Kind, change.Seq, change.DealUUID, err)
+		}
+
+		if err := r.changeLog.SetCursor(ctx, upstreamCursor, change.Seq); err != nil {
+			return fmt.Errorf("setting upstream cursor to %d: %w", change.Seq, err)
+		}
+		last = change.Seq
+	}
+
+	log.Debugw("applied deal changes", "count", len(changes), "seq", last)
+	return nil
+}
+
+// apply writes a single change. The cursor is set after the change is
+// written, so a change may be applied again if the standby stops in between:
+// tags replace any existing tag for the deal so that they aren't counted
+// twice, but the log line may be duplicated.
+func (r *Receiver) apply(ctx context.Context, change db.DealChange) error {
+	switch change.Kind {
+	case db.DealChangeKindDeal:
+		if change.Deal == nil {
+			return errors.New("missing deal state")
+		}
+		_, err := r.dealsDB.ByID(ctx, change.DealUUID)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return r.dealsDB.Insert(ctx, change.Deal)
+		case err == nil:
+			return r.dealsDB.Update(ctx, change.Deal)
+		}
+		return err
+
+	case db.DealChangeKindFundsTagged:
+		if change.FundsTagged == nil {
+			return errors.New("missing tagged funds")
+		}
+		if _, _, err := r.fundsDB.Untag(ctx, change.DealUUID); err != nil && !errors.Is(err, db.ErrNotFound) {
+			return err
+		}
+		return r.fundsDB.InsertTag(ctx, change.FundsTagged)
+
+	case db.DealChangeKindFundsUntagged:
+		if _, _, err := r.fundsDB.Untag(ctx, change.DealUUID); err != nil && !errors.Is(err, db.ErrNotFound) {
+			return err
+		}
+		return nil
+
+	case db.DealChangeKindStorageTagged:
+		if change.StorageTagged == nil {
+			return errors.New("missing tagged storage")
+		}
+		if _, err := r.storageDB.Untag(ctx, change.DealUUID); err != nil && !errors.Is(err, db.ErrNotFound) {
+			return err
+		}
+		return r.storageDB.InsertTag(ctx, change.StorageTagged)
+
+	case db.DealChangeKindStorageUntagged:
+		if _, err := r.storageDB.Untag(ctx, change.DealUUID); err != nil && !errors.Is(err, db.ErrNotFound) {
+			return err
+		}
+		return nil
+
+	case db.DealChangeKindLog:
+		if change.Log == nil {
+			return errors.New("missing log line")
+		}
+		return r.logsDB.InsertLog(ctx, change.Log)
+	}
+
+	// Stop at a change that this node doesn't know how to apply, rather than
+	// skipping it, eg if the primary is running a newer version
+	return fmt.Errorf("unknown change kind '%s'", change.Kind)
+}
@@ -0,0 +1,230 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("replication")
+
+// ChangesPath is the path on a standby node that deal changes are POSTed to
+const ChangesPath = "/replication/v1/changes"
+
+// Batch is the body of a request that sends deal changes to a sink
+type Batch struct {
+	Changes []db.DealChange
+}
+
+type Config struct {
+	// The https URL that batches of changes are POSTed to
+	SinkURL string
+	// Sent in the Authorization header as a bearer token
+	AuthToken string
+	// A PEM file with the CA certificates that the sink's TLS certificate is
+	// verified against. If empty, the system roots are used.
+	CACertFile string
+	// The maximum number of changes to send in one request
+	BatchSize int
+	// How often to check for new changes
+	PollInterval time.Duration
+	// How long to keep changes that have been acknowledged by the sink
+	RetentionPeriod time.Duration
+	// How long to keep changes that have not been acknowledged by the sink.
+	// If zero, changes are kept until the sink acknowledges them.
+	MaxSinkLag time.Duration
+}
+
+// Streamer tails the deal change log, and sends new changes to a sink.
+// The sequence number of the last change acknowledged by the sink is stored
+// in the database, so that streaming resumes where it left off after a
+// restart.
+type Streamer struct {
+	cfg       Config
+	changeLog *db.DealChangeLogDB
+	client    *http.Client
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewStreamer(cfg Config, changeLog *db.DealChangeLogDB) (*Streamer, error) {
+	sinkURL, err := url.Parse(cfg.SinkURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing sink URL: %w", err)
+	}
+	if sinkURL.Scheme != "https" {
+		return nil, fmt.Errorf("sink URL %s must use https", cfg.SinkURL)
+	}
+	if cfg.AuthToken == "" {
+		return nil, errors.New("an auth token is required to send deal changes to the sink")
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CACertFile != "" {
+		pem, err := os.ReadFile(cfg.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading sink CA certificates: %w", err)
+		}
+		tlsCfg.RootCAs = x509.NewCertPool()
+		if !tlsCfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CACertFile)
+		}
+	}
+
+	return &Streamer{
+		cfg:       cfg,
+		changeLog: changeLog,
+		client: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsCfg},
+		},
+		done: make(chan struct{}),
+	}, nil
+}
+
+func (s *Streamer) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	go s.run(ctx)
+}
+
+func (s *Streamer) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+func (s *Streamer) run(ctx context.Context) {
+	defer close(s.done)
+
+	log.Infow("starting deal state replication", "sink", s.cfg.SinkURL)
+
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	lastPrune := time.Now()
+	for {
+		// Send batches until the sink has caught up
+		for {
+			sent, err := s.sendNext(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Warnw("failed to send deal changes to sink", "sink", s.cfg.SinkURL, "err", err)
+				}
+				break
+			}
+			if sent < s.cfg.BatchSize {
+				break
+			}
+		}
+
+		if s.cfg.RetentionPeriod > 0 && time.Since(lastPrune) > time.Minute {
+			lastPrune = time.Now()
+			s.prune(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// prune deletes changes that are older than the retention period and that
+// the sink has acknowledged, or that the sink has fallen too far behind to
+// receive
+func (s *Streamer) prune(ctx context.Context) {
+	var unackedOlderThan time.Time
+	if s.cfg.MaxSinkLag > 0 {
+		unackedOlderThan = time.Now().Add(-s.cfg.MaxSinkLag)
+	}
+	count, unacked, err := s.changeLog.Prune(ctx, s.cfg.SinkURL, time.Now().Add(-s.cfg.RetentionPeriod), unackedOlderThan)
+	if err != nil {
+		log.Warnw("failed to prune deal change log", "err", err)
+		return
+	}
+	if unacked > 0 {
+		log.Warnw("pruned deal changes that the sink has not acknowledged for longer than the max sink lag: "+
+			"the sink has missed these changes and must be re-seeded from a copy of the deals database",
+			"sink", s.cfg.SinkURL, "count", unacked, "max-sink-lag", s.cfg.MaxSinkLag)
+	}
+	if count > 0 {
+		log.Debugw("pruned deal change log", "count", count)
+	}
+}
+
+// sendNext sends the next batch of changes that the sink hasn't yet
+// acknowledged, and returns the number of changes that were sent
+func (s *Streamer) sendNext(ctx context.Context) (int, error) {
+	seq, err := s.changeLog.Cursor(ctx, s.cfg.SinkURL)
+	if err != nil {
+		return 0, fmt.Errorf("getting sink cursor: %w", err)
+	}
+
+	changes, err := s.changeLog.Since(ctx, seq, s.cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("getting changes since %d: %w", seq, err)
+	}
+	if len(changes) == 0 {
+		return 0, nil
+	}
+
+	if err := s.send(ctx, changes); err != nil {
+		return 0, err
+	}
+
+	last := changes[len(changes)-1].Seq
+	if err := s.changeLog.SetCursor(ctx, s.cfg.SinkURL, last); err != nil {
+		return 0, fmt.Errorf("setting sink cursor to %d: %w", last, err)
+	}
+
+	log.Debugw("sent deal changes to sink", "sink", s.cfg.SinkURL, "count", len(changes), "seq", last)
+	return len(changes), nil
+}
+
+func (s *Streamer) send(ctx context.Context, changes []db.DealChange) error {
+	bz, err := json.Marshal(&Batch{Changes: changes})
+	if err != nil {
+		return fmt.Errorf("marshalling changes: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.SinkURL, bytes.NewReader(bz))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.cfg.AuthToken)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("sink returned status %d: %s", resp.StatusCode, msg)
+	}
+	return nil
+}
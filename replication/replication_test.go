@@ -0,0 +1,195 @@
+package replication
+
+import (
+	"context"
+	"database/sql"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplication(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	// Set up the primary and standby databases
+	primary := newTestNode(t)
+	primary.setChangeLog()
+	standby := newTestNode(t)
+
+	rcv, err := NewReceiver(standby.deals, standby.funds, standby.storage, standby.logs, standby.changeLog, "secret")
+	req.NoError(err)
+	srv := httptest.NewTLSServer(rcv)
+	defer srv.Close()
+
+	// Insert some deals on the primary and update one of them
+	deals, err := db.GenerateNDeals(3)
+	req.NoError(err)
+	for i := range deals {
+		req.NoError(primary.deals.Insert(ctx, &deals[i]))
+	}
+	deals[0].Checkpoint = dealcheckpoints.AddedPiece
+	req.NoError(primary.deals.Update(ctx, &deals[0]))
+
+	// Tag funds and storage for the deals, and release the tags for one deal
+	for _, deal := range deals {
+		req.NoError(primary.funds.Tag(ctx, deal.DealUuid, abi.NewTokenAmount(10), abi.NewTokenAmount(1)))
+		req.NoError(primary.storage.Tag(ctx, deal.DealUuid, 100, "host"))
+	}
+	_, _, err = primary.funds.Untag(ctx, deals[1].DealUuid)
+	req.NoError(err)
+	_, err = primary.storage.Untag(ctx, deals[1].DealUuid)
+	req.NoError(err)
+
+	// Add a log line for a deal
+	req.NoError(primary.logs.InsertLog(ctx, &db.DealLog{DealUUID: deals[2].DealUuid, CreatedAt: time.Now(), LogMsg: "hello"}))
+
+	// Stream the changes to the standby
+	s, err := NewStreamer(Config{
+		SinkURL:      srv.URL + ChangesPath,
+		AuthToken:    "secret",
+		CACertFile:   writeCACert(t, srv),
+		BatchSize:    2,
+		PollInterval: 10 * time.Millisecond,
+	}, primary.changeLog)
+	req.NoError(err)
+	s.Start(ctx)
+	defer s.Stop()
+
+	// 4 deal changes, 6 tags, 2 untags and 1 log line
+	req.Eventually(func() bool {
+		seq, err := primary.changeLog.Cursor(ctx, srv.URL+ChangesPath)
+		return err == nil && seq == 13
+	}, 5*time.Second, 10*time.Millisecond)
+
+	for _, deal := range deals {
+		standbyDeal, err := standby.deals.ByID(ctx, deal.DealUuid)
+		req.NoError(err)
+		req.Equal(deal.Checkpoint, standbyDeal.Checkpoint)
+		req.Equal(deal.ClientDealProposal.Proposal.PieceCID, standbyDeal.ClientDealProposal.Proposal.PieceCID)
+	}
+
+	tagged, err := standby.funds.TotalTagged(ctx)
+	req.NoError(err)
+	req.EqualValues(20, tagged.Collateral.Int64())
+	req.EqualValues(2, tagged.PubMsg.Int64())
+	storageTagged, err := standby.storage.TotalTagged(ctx)
+	req.NoError(err)
+	req.EqualValues(200, storageTagged)
+
+	logs, err := standby.logs.Logs(ctx, deals[2].DealUuid)
+	req.NoError(err)
+	req.Len(logs, 1)
+	req.Equal("hello", logs[0].LogMsg)
+
+	// Resending changes that were already applied should have no effect
+	changes, err := primary.changeLog.Since(ctx, 0, 20)
+	req.NoError(err)
+	req.Len(changes, 13)
+	req.NoError(rcv.Apply(ctx, changes[:1]))
+	standbyDeal, err := standby.deals.ByID(ctx, deals[0].DealUuid)
+	req.NoError(err)
+	req.Equal(dealcheckpoints.AddedPiece, standbyDeal.Checkpoint)
+
+	// Applying a funds tag again should not count the funds twice
+	req.NoError(standby.changeLog.SetCursor(ctx, upstreamCursor, 0))
+	for _, change := range changes {
+		if change.Kind == db.DealChangeKindFundsTagged && change.DealUUID == deals[0].DealUuid {
+			req.NoError(rcv.Apply(ctx, []db.DealChange{change}))
+		}
+	}
+	tagged, err = standby.funds.TotalTagged(ctx)
+	req.NoError(err)
+	req.EqualValues(20, tagged.Collateral.Int64())
+}
+
+func TestReceiverRejectsBadToken(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	primary := newTestNode(t)
+	primary.setChangeLog()
+	standby := newTestNode(t)
+
+	rcv, err := NewReceiver(standby.deals, standby.funds, standby.storage, standby.logs, standby.changeLog, "secret")
+	req.NoError(err)
+	srv := httptest.NewTLSServer(rcv)
+	defer srv.Close()
+
+	deals, err := db.GenerateNDeals(1)
+	req.NoError(err)
+	req.NoError(primary.deals.Insert(ctx, &deals[0]))
+
+	s, err := NewStreamer(Config{SinkURL: srv.URL + ChangesPath, AuthToken: "wrong", CACertFile: writeCACert(t, srv)}, primary.changeLog)
+	req.NoError(err)
+	_, err = s.sendNext(ctx)
+	req.ErrorContains(err, "401")
+
+	seq, err := primary.changeLog.Cursor(ctx, srv.URL+ChangesPath)
+	req.NoError(err)
+	req.EqualValues(0, seq)
+}
+
+func TestReplicationRequiresTLSAndToken(t *testing.T) {
+	req := require.New(t)
+
+	standby := newTestNode(t)
+	_, err := NewReceiver(standby.deals, standby.funds, standby.storage, standby.logs, standby.changeLog, "")
+	req.Error(err)
+
+	_, err = NewStreamer(Config{SinkURL: "http://standby" + ChangesPath, AuthToken: "secret"}, standby.changeLog)
+	req.ErrorContains(err, "https")
+	_, err = NewStreamer(Config{SinkURL: "https://standby" + ChangesPath}, standby.changeLog)
+	req.ErrorContains(err, "auth token")
+}
+
+type testNode struct {
+	deals     *db.DealsDB
+	funds     *db.FundsDB
+	storage   *db.StorageDB
+	logs      *db.LogsDB
+	changeLog *db.DealChangeLogDB
+}
+
+func newTestNode(t *testing.T) *testNode {
+	sqldb := createTestDB(t)
+	return &testNode{
+		deals:     db.NewDealsDB(sqldb),
+		funds:     db.NewFundsDB(sqldb),
+		storage:   db.NewStorageDB(sqldb),
+		logs:      db.NewLogsDB(sqldb),
+		changeLog: db.NewDealChangeLogDB(sqldb),
+	}
+}
+
+// setChangeLog appends changes to the node's databases to the change log
+func (n *testNode) setChangeLog() {
+	n.deals.SetChangeLog(n.changeLog)
+	n.funds.SetChangeLog(n.changeLog)
+	n.storage.SetChangeLog(n.changeLog)
+	n.logs.SetChangeLog(n.changeLog)
+}
+
+// writeCACert writes the test server's self-signed certificate to a file
+func writeCACert(t *testing.T, srv *httptest.Server) string {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	bz := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, os.WriteFile(path, bz, 0600))
+	return path
+}
+
+func createTestDB(t *testing.T) *sql.DB {
+	sqldb := db.CreateTestTmpDB(t)
+	require.NoError(t, db.CreateAllBoostTables(context.Background(), sqldb, sqldb))
+	require.NoError(t, migrations.Migrate(sqldb))
+	return sqldb
+}
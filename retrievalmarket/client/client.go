@@ -308,6 +308,30 @@ func (c *Client) RetrievalQuery(ctx context.Context, maddr address.Address, pcid
 		return nil, err
 	}
 
+	return c.retrievalQueryOverStream(ctx, s, pcid)
+}
+
+// RetrievalQueryToPeer sends a retrieval query to a provider peer, for
+// example one found through the network indexer
+func (c *Client) RetrievalQueryToPeer(ctx context.Context, p peer.AddrInfo, pcid cid.Cid) (*retrievalmarket.QueryResponse, error) {
+	ctx, span := Tracer.Start(ctx, "retrievalQueryToPeer", trace.WithAttributes(
+		attribute.Stringer("peer", p.ID),
+	))
+	defer span.End()
+
+	if err := c.host.Connect(ctx, p); err != nil {
+		return nil, err
+	}
+
+	s, err := c.host.NewStream(ctx, p.ID, RetrievalQueryProtocol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream to peer: %w", err)
+	}
+
+	return c.retrievalQueryOverStream(ctx, s, pcid)
+}
+
+func (c *Client) retrievalQueryOverStream(ctx context.Context, s inet.Stream, pcid cid.Cid) (*retrievalmarket.QueryResponse, error) {
 	c.host.ConnManager().Protect(s.Conn().RemotePeer(), "RetrievalQuery")
 	defer func() {
 		c.host.ConnManager().Unprotect(s.Conn().RemotePeer(), "RetrievalQuery")
@@ -358,6 +382,30 @@ func (c *Client) RetrieveContentWithProgressCallback(
 	return c.retrieveContentFromPeerWithProgressCallback(ctx, minerPeer.ID, minerOwnerWallet, proposal, progressCallback, nil)
 }
 
+// RetrieveFromPeer queries a provider peer (for example one found through
+// the network indexer) for the retrieval terms, then retrieves the DAG
+// under root. It returns the number of bytes received.
+func (c *Client) RetrieveFromPeer(ctx context.Context, root cid.Cid, p peer.AddrInfo, progressCallback func(bytesReceived uint64)) (uint64, error) {
+	query, err := c.RetrievalQueryToPeer(ctx, p, root)
+	if err != nil {
+		return 0, fmt.Errorf("retrieval query: %w", err)
+	}
+	if query.Status != retrievalmarket.QueryResponseAvailable {
+		return 0, fmt.Errorf("content is not available: %s", query.Message)
+	}
+
+	proposal, err := RetrievalProposalForAsk(query, root, nil)
+	if err != nil {
+		return 0, fmt.Errorf("creating retrieval proposal: %w", err)
+	}
+
+	stats, err := c.retrieveContentFromPeerWithProgressCallback(ctx, p.ID, address.Undef, proposal, progressCallback, nil)
+	if err != nil {
+		return 0, err
+	}
+	return stats.Size, nil
+}
+
 func (c *Client) retrieveContentFromPeerWithProgressCallback(
 	ctx context.Context,
 	peerID peer.ID,
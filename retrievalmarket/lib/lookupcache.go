@@ -0,0 +1,120 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/metrics"
+	"github.com/filecoin-project/dagstore"
+	"github.com/filecoin-project/dagstore/shard"
+	lru "github.com/hnlq715/golang-lru"
+	ds "github.com/ipfs/go-datastore"
+	mh "github.com/multiformats/go-multihash"
+	"go.opencensus.io/stats"
+)
+
+// The maximum amount of time to cache the result of a lookup for a
+// multihash that is not in any piece. The multihash may be added to the
+// index soon (eg when a new deal is indexed) so don't cache it for long.
+var lookupCacheNotFoundDuration = time.Minute
+
+// MultihashLookupCache wraps a dagstore and caches the results of
+// multihash -> piece lookups, so that retrievals of popular blocks
+// don't have to go to the index on every request.
+// Piece -> offset lookups are served by the shard's own index once the
+// shard has been acquired, and are cached by the index-backed blockstore
+// along with the shard (see BlockstoreCacheMaxShards).
+type MultihashLookupCache struct {
+	dagstore.Interface
+
+	expiry time.Duration
+
+	// The striped lock protects against multiple threads doing a lookup
+	// against the index for the same multihash
+	stripedLock [256]sync.Mutex
+	cache       *lru.Cache
+}
+
+var _ dagstore.Interface = (*MultihashLookupCache)(nil)
+
+func NewMultihashLookupCache(dagst dagstore.Interface, size int, expiry time.Duration) (*MultihashLookupCache, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, fmt.Errorf("creating multihash lookup cache: %w", err)
+	}
+	return &MultihashLookupCache{Interface: dagst, expiry: expiry, cache: cache}, nil
+}
+
+type mhLookupResult struct {
+	shards []shard.Key
+	err    error
+}
+
+// ShardsContainingMultihash returns the cached shards for the multihash if
+// there are any, otherwise it looks up the shards in the dagstore index and
+// caches the result.
+func (c *MultihashLookupCache) ShardsContainingMultihash(ctx context.Context, h mh.Multihash) ([]shard.Key, error) {
+	key := string(h)
+	lk := &c.stripedLock[c.stripedLockIndex(h)]
+	lk.Lock()
+	defer lk.Unlock()
+
+	if v, ok := c.cache.Get(key); ok {
+		stats.Record(ctx, metrics.MultihashLookupCacheHitCount.M(1))
+		res := v.(*mhLookupResult)
+		return res.shards, res.err
+	}
+	stats.Record(ctx, metrics.MultihashLookupCacheMissCount.M(1))
+
+	shards, err := c.Interface.ShardsContainingMultihash(ctx, h)
+	if err != nil {
+		// Only cache the error if the multihash wasn't found, so that a
+		// transient error talking to the index isn't returned for every
+		// subsequent lookup
+		if errors.Is(err, ds.ErrNotFound) {
+			c.cache.AddEx(key, &mhLookupResult{err: err}, c.notFoundExpiry())
+		}
+		return nil, err
+	}
+
+	c.cache.AddEx(key, &mhLookupResult{shards: shards}, c.expiry)
+	return shards, nil
+}
+
+func (c *MultihashLookupCache) notFoundExpiry() time.Duration {
+	if c.expiry < lookupCacheNotFoundDuration {
+		return c.expiry
+	}
+	return lookupCacheNotFoundDuration
+}
+
+func (c *MultihashLookupCache) stripedLockIndex(h mh.Multihash) int {
+	if len(h) == 0 {
+		return 0
+	}
+	return int(h[len(h)-1])
+}
+
+// RemoveShard removes the cached lookups for multihashes in the shard, eg
+// when the shard's piece is removed, and returns the number of entries
+// that were removed
+func (c *MultihashLookupCache) RemoveShard(k shard.Key) int {
+	var removed int
+	for _, key := range c.cache.Keys() {
+		v, ok := c.cache.Peek(key)
+		if !ok {
+			continue
+		}
+		for _, s := range v.(*mhLookupResult).shards {
+			if s == k {
+				c.cache.Remove(key)
+				removed++
+				break
+			}
+		}
+	}
+	return removed
+}
@@ -0,0 +1,118 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/filecoin-project/dagstore"
+	"github.com/filecoin-project/dagstore/shard"
+	ds "github.com/ipfs/go-datastore"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+type mockMhLookupDagstore struct {
+	dagstore.Interface
+
+	shards map[string][]shard.Key
+	err    error
+	calls  int
+}
+
+func (m *mockMhLookupDagstore) ShardsContainingMultihash(ctx context.Context, h mh.Multihash) ([]shard.Key, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	shards, ok := m.shards[string(h)]
+	if !ok {
+		return nil, fmt.Errorf("failed to lookup index for mh %s, err: %w", h, ds.ErrNotFound)
+	}
+	return shards, nil
+}
+
+func TestMultihashLookupCache(t *testing.T) {
+	ctx := context.Background()
+
+	found := testutil.GenerateCid().Hash()
+	notFound := testutil.GenerateCid().Hash()
+	pieceShard := shard.KeyFromCID(testutil.GenerateCid())
+	dagst := &mockMhLookupDagstore{shards: map[string][]shard.Key{string(found): {pieceShard}}}
+
+	c, err := NewMultihashLookupCache(dagst, 16, time.Hour)
+	require.NoError(t, err)
+
+	// The second lookup should be served from the cache
+	for i := 0; i < 2; i++ {
+		shards, err := c.ShardsContainingMultihash(ctx, found)
+		require.NoError(t, err)
+		require.Equal(t, []shard.Key{pieceShard}, shards)
+	}
+	require.Equal(t, 1, dagst.calls)
+
+	// Not found errors are cached too
+	for i := 0; i < 2; i++ {
+		_, err := c.ShardsContainingMultihash(ctx, notFound)
+		require.ErrorIs(t, err, ds.ErrNotFound)
+	}
+	require.Equal(t, 2, dagst.calls)
+
+	// Other errors are not cached
+	other := testutil.GenerateCid().Hash()
+	dagst.err = errors.New("connection refused")
+	for i := 0; i < 2; i++ {
+		_, err := c.ShardsContainingMultihash(ctx, other)
+		require.Error(t, err)
+	}
+	require.Equal(t, 4, dagst.calls)
+}
+
+func TestMultihashLookupCacheExpiry(t *testing.T) {
+	ctx := context.Background()
+
+	found := testutil.GenerateCid().Hash()
+	dagst := &mockMhLookupDagstore{shards: map[string][]shard.Key{string(found): {shard.KeyFromCID(testutil.GenerateCid())}}}
+
+	c, err := NewMultihashLookupCache(dagst, 16, 10*time.Millisecond)
+	require.NoError(t, err)
+
+	_, err = c.ShardsContainingMultihash(ctx, found)
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	_, err = c.ShardsContainingMultihash(ctx, found)
+	require.NoError(t, err)
+	require.Equal(t, 2, dagst.calls)
+}
+
+func TestMultihashLookupCacheRemoveShard(t *testing.T) {
+	ctx := context.Background()
+
+	mh1 := testutil.GenerateCid().Hash()
+	mh2 := testutil.GenerateCid().Hash()
+	shard1 := shard.KeyFromCID(testutil.GenerateCid())
+	shard2 := shard.KeyFromCID(testutil.GenerateCid())
+	dagst := &mockMhLookupDagstore{shards: map[string][]shard.Key{
+		string(mh1): {shard1, shard2},
+		string(mh2): {shard2},
+	}}
+
+	c, err := NewMultihashLookupCache(dagst, 16, time.Hour)
+	require.NoError(t, err)
+
+	for _, h := range []mh.Multihash{mh1, mh2} {
+		_, err := c.ShardsContainingMultihash(ctx, h)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, c.RemoveShard(shard1))
+
+	// The lookup for the multihash in the removed shard goes to the dagstore
+	_, err = c.ShardsContainingMultihash(ctx, mh1)
+	require.NoError(t, err)
+	_, err = c.ShardsContainingMultihash(ctx, mh2)
+	require.NoError(t, err)
+	require.Equal(t, 3, dagst.calls)
+}
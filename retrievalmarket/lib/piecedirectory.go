@@ -0,0 +1,72 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/filecoin-project/boost/retrievalmarket/server"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/multiformats/go-multihash"
+)
+
+// ShardIndex finds the dagstore shards that contain a block. It's
+// implemented by the dagstore (and the MultihashLookupCache).
+type ShardIndex interface {
+	ShardsContainingMultihash(ctx context.Context, h multihash.Multihash) ([]shard.Key, error)
+}
+
+// DagstorePieceDirectory is the piece directory of a boost node: it looks up
+// the pieces containing a block in the dagstore index, and the deals for a
+// piece in the piece store.
+type DagstorePieceDirectory struct {
+	idx ShardIndex
+	ps  piecestore.PieceStore
+}
+
+var _ server.PieceDirectory = (*DagstorePieceDirectory)(nil)
+
+// NewDagstorePieceDirectory creates a piece directory over the dagstore
+// index. Pass a MultihashLookupCache as the index to cache lookups.
+func NewDagstorePieceDirectory(idx ShardIndex, ps piecestore.PieceStore) *DagstorePieceDirectory {
+	return &DagstorePieceDirectory{idx: idx, ps: ps}
+}
+
+func (d *DagstorePieceDirectory) PiecesContainingMultihash(ctx context.Context, m multihash.Multihash) ([]cid.Cid, error) {
+	ks, err := d.idx.ShardsContainingMultihash(ctx, m)
+	if errors.Is(err, ds.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting pieces containing multihash %s from DAG store: %w", m, err)
+	}
+
+	pieceCids := make([]cid.Cid, 0, len(ks))
+	for _, k := range ks {
+		pieceCid, err := cid.Parse(k.String())
+		if err != nil {
+			return nil, fmt.Errorf("parsing DAG store shard key '%s' into cid: %w", k, err)
+		}
+		pieceCids = append(pieceCids, pieceCid)
+	}
+	return pieceCids, nil
+}
+
+func (d *DagstorePieceDirectory) GetPieceInfo(ctx context.Context, pieceCid cid.Cid) (*piecestore.PieceInfo, error) {
+	pi, err := d.ps.GetPieceInfo(pieceCid)
+	if err != nil {
+		return nil, fmt.Errorf("getting piece info for piece %s: %w", pieceCid, err)
+	}
+	return &pi, nil
+}
+
+// AddDealForPiece records that the deal is in the piece
+func (d *DagstorePieceDirectory) AddDealForPiece(ctx context.Context, pieceCid cid.Cid, dealInfo piecestore.DealInfo) error {
+	if err := d.ps.AddDealForPiece(pieceCid, cid.Undef, dealInfo); err != nil {
+		return fmt.Errorf("adding deal %d for piece %s: %w", dealInfo.DealID, pieceCid, err)
+	}
+	return nil
+}
@@ -28,6 +28,11 @@ type ShardSelector struct {
 	sa  retrievalmarket.SectorAccessor
 	rp  retrievalmarket.RetrievalProvider
 
+	// If UnsealOnDemand is true, a shard with no unsealed copy can be
+	// selected if it is free to unseal and retrieve (it will be unsealed
+	// when it is read)
+	UnsealOnDemand bool
+
 	// The striped lock protects against multiple threads doing a lookup
 	// against the sealing subsystem / retrieval ask for the same shard
 	stripedLock [256]sync.Mutex
@@ -141,37 +146,51 @@ func (s *ShardSelector) checkIsAvailable(sk shard.Key) (bool, error) {
 		}
 	}
 
+	deals := unsealedDeals
+	unsealed := true
 	if len(unsealedDeals) == 0 {
-		// It wasn't possible to find an unsealed sector
-		sslog.Debugw("no unsealed deals found", "shard", sk)
-		return false, lastErr
+		if !s.UnsealOnDemand || len(pieceInfo.Deals) == 0 {
+			// It wasn't possible to find an unsealed sector
+			sslog.Debugw("no unsealed deals found", "shard", sk)
+			return false, lastErr
+		}
+
+		// The piece can be unsealed when it is read, if unsealing is free
+		sslog.Debugw("no unsealed deals found, checking if piece can be unsealed on demand", "shard", sk)
+		deals = pieceInfo.Deals
+		unsealed = false
 	}
 
 	// Check if the piece is available for free (zero-cost) retrieval
 	input := retrievalmarket.PricingInput{
 		// Piece from which the payload will be retrieved
 		PieceCID: pieceInfo.PieceCID,
-		Unsealed: true,
+		Unsealed: unsealed,
 	}
 
 	var dealsIds []abi.DealID
-	for _, d := range unsealedDeals {
+	for _, d := range deals {
 		dealsIds = append(dealsIds, d.DealID)
 	}
 
-	sslog.Debugw("getting dynamic asking price for unsealed deals", "shard", sk, "deals", len(unsealedDeals))
+	sslog.Debugw("getting dynamic asking price for deals", "shard", sk, "deals", len(deals), "unsealed", unsealed)
 	ask, err := s.rp.GetDynamicAsk(s.ctx, input, dealsIds)
 	if err != nil {
 		return false, fmt.Errorf("getting retrieval ask: %w", err)
 	}
 
+	if !unsealed && !ask.UnsealPrice.NilOrZero() {
+		sslog.Debugw("asking unseal price for sealed deals is non-zero", "shard", sk, "price", ask.UnsealPrice.String())
+		return false, nil
+	}
+
 	// The piece is available for free retrieval
 	if ask.PricePerByte.NilOrZero() {
-		sslog.Debugw("asking price for unsealed deals is zero", "shard", sk)
+		sslog.Debugw("asking price for deals is zero", "shard", sk)
 		return true, nil
 	}
 
-	sslog.Debugw("asking price-per-byte for unsealed deals is non-zero", "shard", sk, "price", ask.PricePerByte.String())
+	sslog.Debugw("asking price-per-byte for deals is non-zero", "shard", sk, "price", ask.PricePerByte.String())
 	return false, nil
 }
 
@@ -19,11 +19,13 @@ func TestShardSelector(t *testing.T) {
 	ctx := context.Background()
 
 	testCases := []struct {
-		name         string
-		deals        []piecestore.DealInfo
-		isUnsealed   []bool
-		pricePerByte int64
-		expectErr    error
+		name           string
+		deals          []piecestore.DealInfo
+		isUnsealed     []bool
+		pricePerByte   int64
+		unsealPrice    int64
+		unsealOnDemand bool
+		expectErr      error
 	}{{
 		name:      "no deals",
 		deals:     nil,
@@ -45,6 +47,26 @@ func TestShardSelector(t *testing.T) {
 		isUnsealed:   []bool{false, true}, // index corresponds to sector ID
 		pricePerByte: 0,
 		expectErr:    nil,
+	}, {
+		name:           "unseal on demand with zero unseal price",
+		deals:          []piecestore.DealInfo{{SectorID: 0}, {SectorID: 1}},
+		isUnsealed:     []bool{false, false}, // index corresponds to sector ID
+		unsealOnDemand: true,
+		expectErr:      nil,
+	}, {
+		name:           "unseal on demand but non-zero unseal price",
+		deals:          []piecestore.DealInfo{{SectorID: 0}, {SectorID: 1}},
+		isUnsealed:     []bool{false, false}, // index corresponds to sector ID
+		unsealPrice:    1,
+		unsealOnDemand: true,
+		expectErr:      indexbs.ErrNoShardSelected,
+	}, {
+		name:           "unseal on demand but non-zero price",
+		deals:          []piecestore.DealInfo{{SectorID: 0}, {SectorID: 1}},
+		isUnsealed:     []bool{false, false}, // index corresponds to sector ID
+		pricePerByte:   1,
+		unsealOnDemand: true,
+		expectErr:      indexbs.ErrNoShardSelected,
 	}}
 
 	for _, tc := range testCases {
@@ -55,6 +77,7 @@ func TestShardSelector(t *testing.T) {
 			retrievalProv := mock.NewMockRetrievalProvider(ctrl)
 			ss, err := NewShardSelector(ctx, pieceStore, sectorAccessor, retrievalProv)
 			require.NoError(t, err)
+			ss.UnsealOnDemand = tc.unsealOnDemand
 
 			blockCid := testutil.GenerateCid()
 			require.NoError(t, err)
@@ -73,7 +96,10 @@ func TestShardSelector(t *testing.T) {
 				sectorAccessor.EXPECT().IsUnsealed(gomock.Any(), dl.SectorID, gomock.Any(), gomock.Any()).AnyTimes().Return(isUnsealed, nil)
 			}
 
-			ask := retrievalmarket.Ask{PricePerByte: abi.NewTokenAmount(tc.pricePerByte)}
+			ask := retrievalmarket.Ask{
+				PricePerByte: abi.NewTokenAmount(tc.pricePerByte),
+				UnsealPrice:  abi.NewTokenAmount(tc.unsealPrice),
+			}
 			retrievalProv.EXPECT().GetDynamicAsk(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(ask, nil)
 
 			sk, err := ss.ShardSelectorF(blockCid, shards)
@@ -0,0 +1,99 @@
+package lp2pimpl
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/boost/retrievalmarket/server"
+	"github.com/filecoin-project/boost/retrievalmarket/types"
+	"github.com/filecoin-project/go-fil-markets/shared"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// AskProtocolID is the protocol for querying the Storage Provider's
+// retrieval ask (the price of retrievals)
+const AskProtocolID = protocol.ID("/fil/retrieval/ask/1.0.0")
+
+// AskListener responds to retrieval ask queries over libp2p
+type AskListener struct {
+	host host.Host
+	ask  *server.RetrievalAsk
+}
+
+func NewAskListener(h host.Host, ask *server.RetrievalAsk) *AskListener {
+	return &AskListener{host: h, ask: ask}
+}
+
+func (l *AskListener) Start() {
+	l.host.SetStreamHandler(AskProtocolID, l.handleNewAskStream)
+}
+
+func (l *AskListener) Stop() {
+	l.host.RemoveStreamHandler(AskProtocolID)
+}
+
+// Called when the client opens a libp2p stream
+func (l *AskListener) handleNewAskStream(s network.Stream) {
+	defer s.Close()
+
+	p := s.Conn().RemotePeer()
+	slog.Debugw("ask query", "peer", p)
+
+	ask := l.ask.AskFor(p)
+	response := types.AskResponse{
+		PricePerByte:            ask.PricePerByte,
+		UnsealPrice:             ask.UnsealPrice,
+		PaymentInterval:         ask.PaymentInterval,
+		PaymentIntervalIncrease: ask.PaymentIntervalIncrease,
+		Free:                    l.ask.IsFree(p),
+	}
+
+	// Set a deadline on writing to the stream so it doesn't hang
+	_ = s.SetWriteDeadline(time.Now().Add(streamWriteDeadline))
+	defer s.SetWriteDeadline(time.Time{}) // nolint
+
+	// Write the response to the client
+	err := types.BindnodeRegistry.TypeToWriter(&response, s, dagcbor.Encode)
+	if err != nil {
+		slog.Infow("error writing ask response", "peer", p, "err", err)
+		return
+	}
+}
+
+// AskClient queries the retrieval ask of Storage Providers over libp2p
+type AskClient struct {
+	retryStream *shared.RetryStream
+}
+
+func NewAskClient(h host.Host) *AskClient {
+	return &AskClient{retryStream: shared.NewRetryStream(h)}
+}
+
+// SendQuery asks the peer for its retrieval ask
+func (c *AskClient) SendQuery(ctx context.Context, id peer.ID) (*types.AskResponse, error) {
+	clog.Debugw("ask query", "peer", id)
+
+	// Create a libp2p stream to the provider
+	s, err := c.retryStream.OpenStream(ctx, id, []protocol.ID{AskProtocolID})
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.Close() // nolint
+
+	// Set a deadline on reading from the stream so it doesn't hang
+	_ = s.SetReadDeadline(time.Now().Add(streamReadDeadline))
+	defer s.SetReadDeadline(time.Time{}) // nolint
+
+	// Read the response from the stream
+	respi, err := types.BindnodeRegistry.TypeFromReader(s, (*types.AskResponse)(nil), dagcbor.Decode)
+	if err != nil {
+		return nil, fmt.Errorf("reading ask response: %w", err)
+	}
+	return respi.(*types.AskResponse), nil
+}
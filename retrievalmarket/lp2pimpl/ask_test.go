@@ -0,0 +1,64 @@
+package lp2pimpl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/boost/retrievalmarket/server"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type staticAsk struct {
+	ask *retrievalmarket.Ask
+}
+
+func (a *staticAsk) GetAsk() *retrievalmarket.Ask {
+	return a.ask
+}
+
+func TestAskQuery(t *testing.T) {
+	ctx := context.Background()
+
+	mn := mocknet.New()
+	defer mn.Close() //nolint:errcheck
+	provHost, err := mn.GenPeer()
+	require.NoError(t, err)
+	paidClientHost, err := mn.GenPeer()
+	require.NoError(t, err)
+	freeClientHost, err := mn.GenPeer()
+	require.NoError(t, err)
+	require.NoError(t, mn.LinkAll())
+	require.NoError(t, mn.ConnectAllButSelf())
+
+	ask := &retrievalmarket.Ask{
+		PricePerByte:            abi.NewTokenAmount(10),
+		UnsealPrice:             abi.NewTokenAmount(1000),
+		PaymentInterval:         1 << 20,
+		PaymentIntervalIncrease: 1 << 10,
+	}
+	rask := server.NewRetrievalAsk(&staticAsk{ask: ask}, []peer.ID{freeClientHost.ID()})
+	listener := NewAskListener(provHost, rask)
+	listener.Start()
+	defer listener.Stop()
+
+	// A client that is not in the free allowlist gets the ask price
+	resp, err := NewAskClient(paidClientHost).SendQuery(ctx, provHost.ID())
+	require.NoError(t, err)
+	require.Equal(t, "10", resp.PricePerByte.String())
+	require.Equal(t, "1000", resp.UnsealPrice.String())
+	require.Equal(t, uint64(1<<20), resp.PaymentInterval)
+	require.Equal(t, uint64(1<<10), resp.PaymentIntervalIncrease)
+	require.False(t, resp.Free)
+
+	// A client in the free allowlist can retrieve for free
+	resp, err = NewAskClient(freeClientHost).SendQuery(ctx, provHost.ID())
+	require.NoError(t, err)
+	require.True(t, resp.PricePerByte.IsZero())
+	require.True(t, resp.UnsealPrice.IsZero())
+	require.Equal(t, uint64(1<<20), resp.PaymentInterval)
+	require.True(t, resp.Free)
+}
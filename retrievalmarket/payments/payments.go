@@ -0,0 +1,209 @@
+// Package payments handles payments for retrievals.
+//
+// Graphsync retrievals are paid for in the retrieval deal with the legacy
+// (go-fil-markets) retrieval provider, which checks the vouchers sent by the
+// client: the payments are recorded so that the earnings from each client
+// can be reported.
+//
+// HTTP retrievals are prepaid: the client sends payment channel vouchers to
+// build up credit with the provider. Before each retrieval the credit is
+// reserved, and the price of the bytes that weren't sent is refunded
+// afterwards.
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/retrievalmarket/server"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/builtin/v8/paych"
+	"github.com/filecoin-project/lotus/chain/types"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("retrievalpayments")
+
+// The protocols that retrievals are paid for over
+const (
+	ProtocolGraphsync = "graphsync"
+	ProtocolHTTP      = "http"
+)
+
+// ErrNoFunds is returned when a voucher doesn't add any funds to the
+// payment channel, eg because it has already been submitted
+var ErrNoFunds = errors.New("voucher does not add any funds")
+
+// PaychAPI is the subset of the lotus full node API that is used to check
+// and redeem payment channel vouchers
+type PaychAPI interface {
+	PaychVoucherCheckValid(context.Context, address.Address, *paych.SignedVoucher) error
+	PaychVoucherAdd(context.Context, address.Address, *paych.SignedVoucher, []byte, types.BigInt) (types.BigInt, error)
+}
+
+// Terms are the terms for paid retrievals
+type Terms struct {
+	// The price per byte for retrievals; if it is zero, retrievals are free
+	PricePerByte abi.TokenAmount
+	// The price to unseal a piece; only charged for graphsync retrievals
+	UnsealPrice abi.TokenAmount
+	// The address that payment channels must be created to
+	PaymentAddress address.Address
+}
+
+// Manager records payments for retrievals, and keeps track of the credit
+// of clients that prepay for HTTP retrievals
+type Manager struct {
+	db          *db.RetrievalPaymentsDB
+	paych       PaychAPI
+	askGetter   server.AskGetter
+	paymentAddr func(context.Context) (address.Address, error)
+
+	// Makes reading and updating a client's credit atomic
+	lk sync.Mutex
+
+	// The funds received so far for each legacy retrieval deal, so that the
+	// amount of each new payment can be worked out
+	dealFundsLk sync.Mutex
+	dealFunds   map[retrievalmarket.ProviderDealIdentifier]abi.TokenAmount
+}
+
+func NewManager(pdb *db.RetrievalPaymentsDB, paychApi PaychAPI, askGetter server.AskGetter, paymentAddr func(context.Context) (address.Address, error)) *Manager {
+	return &Manager{
+		db:          pdb,
+		paych:       paychApi,
+		askGetter:   askGetter,
+		paymentAddr: paymentAddr,
+		dealFunds:   make(map[retrievalmarket.ProviderDealIdentifier]abi.TokenAmount),
+	}
+}
+
+// Terms returns the current terms for paid retrievals, from the retrieval
+// ask
+func (m *Manager) Terms(ctx context.Context) (*Terms, error) {
+	ask := m.askGetter.GetAsk()
+	addr, err := m.paymentAddr(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting payment address: %w", err)
+	}
+	return &Terms{
+		PricePerByte:   ask.PricePerByte,
+		UnsealPrice:    ask.UnsealPrice,
+		PaymentAddress: addr,
+	}, nil
+}
+
+// AddVoucher redeems a voucher from a client that pays for HTTP retrievals,
+// and adds the funds to the client's credit.
+// Returns the client's credit.
+func (m *Manager) AddVoucher(ctx context.Context, clientID string, sv *paych.SignedVoucher) (abi.TokenAmount, error) {
+	if err := m.paych.PaychVoucherCheckValid(ctx, sv.ChannelAddr, sv); err != nil {
+		return big.Zero(), fmt.Errorf("invalid voucher: %w", err)
+	}
+	received, err := m.paych.PaychVoucherAdd(ctx, sv.ChannelAddr, sv, nil, big.Zero())
+	if err != nil {
+		return big.Zero(), fmt.Errorf("adding voucher: %w", err)
+	}
+	if !received.GreaterThan(big.Zero()) {
+		return big.Zero(), ErrNoFunds
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	credit, err := m.db.Insert(ctx, &db.RetrievalPayment{
+		ClientID:       clientID,
+		Protocol:       ProtocolHTTP,
+		PaymentChannel: sv.ChannelAddr.String(),
+		Amount:         received,
+	}, true)
+	if err != nil {
+		return big.Zero(), err
+	}
+	log.Infow("received payment for http retrieval", "client", clientID, "paych", sv.ChannelAddr, "amount", received, "credit", credit)
+	return credit, nil
+}
+
+// Reserve sets aside as much of the client's credit as pays for whole
+// bytes at the current price per byte, before the bytes are sent. The part
+// of the reservation that isn't used must be given back with Refund.
+// If retrievals are free, the reservation is for an unlimited number of
+// bytes.
+func (m *Manager) Reserve(ctx context.Context, clientID string) (*db.RetrievalReservation, error) {
+	pricePerByte := m.askGetter.GetAsk().PricePerByte
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	if pricePerByte.IsZero() {
+		credit, err := m.db.Credit(ctx, clientID)
+		if err != nil {
+			return nil, err
+		}
+		return &db.RetrievalReservation{Bytes: math.MaxUint64, Amount: big.Zero(), Credit: credit}, nil
+	}
+	return m.db.Reserve(ctx, clientID, pricePerByte)
+}
+
+// Refund adds the unused part of a reservation back to the client's credit.
+// Returns the client's credit.
+func (m *Manager) Refund(ctx context.Context, clientID string, amount abi.TokenAmount) (abi.TokenAmount, error) {
+	if amount.LessThan(big.Zero()) {
+		return big.Zero(), fmt.Errorf("refund amount must not be negative, got %s", amount)
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	return m.db.Refund(ctx, clientID, amount)
+}
+
+// Earnings returns the total of the payments received from each client
+func (m *Manager) Earnings(ctx context.Context) ([]db.RetrievalEarnings, error) {
+	return m.db.Earnings(ctx)
+}
+
+// OnLegacyRetrievalEvent records the payments received for graphsync
+// retrieval deals with the legacy retrieval provider
+func (m *Manager) OnLegacyRetrievalEvent(event retrievalmarket.ProviderEvent, state retrievalmarket.ProviderDealState) {
+	id := state.Identifier()
+	switch event {
+	case retrievalmarket.ProviderEventPaymentReceived:
+	case retrievalmarket.ProviderEventComplete, retrievalmarket.ProviderEventCancelComplete, retrievalmarket.ProviderEventDataTransferError:
+		m.dealFundsLk.Lock()
+		delete(m.dealFunds, id)
+		m.dealFundsLk.Unlock()
+		return
+	default:
+		return
+	}
+
+	m.dealFundsLk.Lock()
+	prev, ok := m.dealFunds[id]
+	if !ok {
+		prev = big.Zero()
+	}
+	m.dealFunds[id] = state.FundsReceived
+	m.dealFundsLk.Unlock()
+
+	received := big.Sub(state.FundsReceived, prev)
+	if !received.GreaterThan(big.Zero()) {
+		return
+	}
+
+	_, err := m.db.Insert(context.Background(), &db.RetrievalPayment{
+		ClientID: state.Receiver.String(),
+		Protocol: ProtocolGraphsync,
+		Amount:   received,
+	}, false)
+	if err != nil {
+		log.Errorw("recording payment for graphsync retrieval", "deal", id, "amount", received, "err", err)
+	}
+}
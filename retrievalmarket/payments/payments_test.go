@@ -0,0 +1,145 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/builtin/v8/paych"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddVoucherAndReserve(t *testing.T) {
+	ctx := context.Background()
+	mgr, paychApi, ask := newTestManager(t)
+	ch, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+
+	terms, err := mgr.Terms(ctx)
+	require.NoError(t, err)
+	require.Equal(t, ask.PricePerByte, terms.PricePerByte)
+	require.Equal(t, "f01000", terms.PaymentAddress.String())
+
+	// Each voucher adds the difference from the previous voucher on the lane
+	credit, err := mgr.AddVoucher(ctx, "client1", &paych.SignedVoucher{ChannelAddr: ch, Amount: abi.NewTokenAmount(1000)})
+	require.NoError(t, err)
+	require.Equal(t, abi.NewTokenAmount(1000), credit)
+	credit, err = mgr.AddVoucher(ctx, "client1", &paych.SignedVoucher{ChannelAddr: ch, Amount: abi.NewTokenAmount(1500)})
+	require.NoError(t, err)
+	require.Equal(t, abi.NewTokenAmount(1500), credit)
+
+	// A voucher that has already been submitted doesn't add any funds
+	_, err = mgr.AddVoucher(ctx, "client1", &paych.SignedVoucher{ChannelAddr: ch, Amount: abi.NewTokenAmount(1500)})
+	require.ErrorIs(t, err, ErrNoFunds)
+
+	// An invalid voucher is rejected
+	paychApi.invalid = true
+	_, err = mgr.AddVoucher(ctx, "client1", &paych.SignedVoucher{ChannelAddr: ch, Amount: abi.NewTokenAmount(2000)})
+	require.Error(t, err)
+
+	// The credit is reserved at the price per byte, and the price of the
+	// bytes that weren't sent is refunded
+	res, err := mgr.Reserve(ctx, "client1")
+	require.NoError(t, err)
+	require.EqualValues(t, 750, res.Bytes)
+	require.Equal(t, abi.NewTokenAmount(1500), res.Amount)
+	require.True(t, res.Credit.IsZero())
+	credit, err = mgr.Refund(ctx, "client1", abi.NewTokenAmount(1300))
+	require.NoError(t, err)
+	require.Equal(t, abi.NewTokenAmount(1300), credit)
+
+	earnings, err := mgr.Earnings(ctx)
+	require.NoError(t, err)
+	require.Len(t, earnings, 1)
+	require.Equal(t, ProtocolHTTP, earnings[0].Protocol)
+	require.Equal(t, 2, earnings[0].Payments)
+	require.Equal(t, abi.NewTokenAmount(1500), earnings[0].Total)
+}
+
+func TestLegacyRetrievalPayments(t *testing.T) {
+	ctx := context.Background()
+	mgr, _, _ := newTestManager(t)
+	client := peer.ID("client")
+
+	state := retrievalmarket.ProviderDealState{Receiver: client, FundsReceived: big.Zero()}
+	state.ID = 1
+	mgr.OnLegacyRetrievalEvent(retrievalmarket.ProviderEventOpen, state)
+
+	// Each payment is recorded as the increase in the funds received
+	for _, funds := range []int64{100, 250, 250} {
+		state.FundsReceived = abi.NewTokenAmount(funds)
+		mgr.OnLegacyRetrievalEvent(retrievalmarket.ProviderEventPaymentReceived, state)
+	}
+	mgr.OnLegacyRetrievalEvent(retrievalmarket.ProviderEventComplete, state)
+
+	earnings, err := mgr.Earnings(ctx)
+	require.NoError(t, err)
+	require.Len(t, earnings, 1)
+	require.Equal(t, client.String(), earnings[0].ClientID)
+	require.Equal(t, ProtocolGraphsync, earnings[0].Protocol)
+	require.Equal(t, 2, earnings[0].Payments)
+	require.Equal(t, abi.NewTokenAmount(250), earnings[0].Total)
+
+	// Graphsync payments don't add to the client's credit for http
+	// retrievals
+	res, err := mgr.Reserve(ctx, client.String())
+	require.NoError(t, err)
+	require.Zero(t, res.Bytes)
+	require.True(t, res.Credit.IsZero())
+}
+
+func newTestManager(t *testing.T) (*Manager, *mockPaychAPI, *retrievalmarket.Ask) {
+	ctx := context.Background()
+	sqldb := db.CreateTestTmpDB(t)
+	require.NoError(t, db.CreateAllBoostTables(ctx, sqldb, sqldb))
+	require.NoError(t, migrations.Migrate(sqldb))
+
+	paychApi := &mockPaychAPI{lanes: make(map[uint64]abi.TokenAmount)}
+	ask := &retrievalmarket.Ask{PricePerByte: abi.NewTokenAmount(2), UnsealPrice: abi.NewTokenAmount(0)}
+	paymentAddr := func(context.Context) (address.Address, error) {
+		return address.NewIDAddress(1000)
+	}
+	return NewManager(db.NewRetrievalPaymentsDB(sqldb), paychApi, &staticAsk{ask}, paymentAddr), paychApi, ask
+}
+
+type staticAsk struct {
+	ask *retrievalmarket.Ask
+}
+
+func (a *staticAsk) GetAsk() *retrievalmarket.Ask {
+	return a.ask
+}
+
+// mockPaychAPI returns the increase in the amount of the voucher over the
+// previous voucher on the same lane, like lotus does
+type mockPaychAPI struct {
+	invalid bool
+	lanes   map[uint64]abi.TokenAmount
+}
+
+func (m *mockPaychAPI) PaychVoucherCheckValid(ctx context.Context, ch address.Address, sv *paych.SignedVoucher) error {
+	if m.invalid {
+		return errors.New("bad signature")
+	}
+	return nil
+}
+
+func (m *mockPaychAPI) PaychVoucherAdd(ctx context.Context, ch address.Address, sv *paych.SignedVoucher, proof []byte, minDelta types.BigInt) (types.BigInt, error) {
+	prev, ok := m.lanes[sv.Lane]
+	if !ok {
+		prev = big.Zero()
+	}
+	if sv.Amount.LessThanEqual(prev) {
+		return big.Zero(), nil
+	}
+	m.lanes[sv.Lane] = sv.Amount
+	return big.Sub(sv.Amount, prev), nil
+}
@@ -0,0 +1,43 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	lru "github.com/hnlq715/golang-lru"
+)
+
+// Fetcher gets the quota status of a client, eg from the boost API
+type Fetcher func(ctx context.Context, client string) (*Status, error)
+
+// Cache caches the quota status of each client, so that a service that
+// serves retrievals (eg booster-http) doesn't need to fetch the status from
+// boostd for every request
+type Cache struct {
+	fetch Fetcher
+	ttl   time.Duration
+	cache *lru.Cache
+}
+
+func NewCache(fetch Fetcher, size int, ttl time.Duration) (*Cache, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, fmt.Errorf("creating retrieval quota cache: %w", err)
+	}
+	return &Cache{fetch: fetch, ttl: ttl, cache: cache}, nil
+}
+
+// Status returns the quota status of the client
+func (c *Cache) Status(ctx context.Context, client string) (*Status, error) {
+	if st, ok := c.cache.Get(client); ok {
+		return st.(*Status), nil
+	}
+
+	st, err := c.fetch(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("fetching retrieval quota for client %s: %w", client, err)
+	}
+	c.cache.AddEx(client, st, c.ttl)
+	return st, nil
+}
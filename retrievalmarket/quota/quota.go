@@ -0,0 +1,162 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/boost/retrievalmarket/rtvllog"
+)
+
+// The actions taken when a client exceeds its quota
+const (
+	// Refuse retrievals
+	ActionBlock = "block"
+	// Limit the download rate (retrievals over protocols that can't be
+	// throttled are refused)
+	ActionThrottle = "throttle"
+)
+
+// The states of a client's quota
+const (
+	// The client is within its quota
+	StateOK = "ok"
+	// The client has exceeded its quota and is throttled
+	StateThrottled = "throttled"
+	// The client has exceeded its quota and is blocked
+	StateBlocked = "blocked"
+)
+
+// ClientConfig sets the quota for a specific client
+type ClientConfig struct {
+	Client           string
+	MaxBytesPerDay   uint64
+	MaxBytesPerMonth uint64
+}
+
+// Config limits the number of bytes that each client can retrieve over all
+// protocols, per (UTC) day and month. A limit of zero is unlimited.
+type Config struct {
+	MaxBytesPerDay   uint64
+	MaxBytesPerMonth uint64
+	// The action taken when a client exceeds its quota: "block" or
+	// "throttle" (block if empty)
+	Action string
+	// The maximum download rate of a throttled client
+	ThrottleBytesPerSecond uint64
+	// Quotas for specific clients, that replace the default quotas
+	Clients []ClientConfig
+}
+
+// Status is a client's usage and quota
+type Status struct {
+	Client           string
+	BytesToday       uint64
+	BytesThisMonth   uint64
+	MaxBytesPerDay   uint64
+	MaxBytesPerMonth uint64
+	// One of StateOK, StateThrottled or StateBlocked
+	State string
+	// The maximum download rate when the client is throttled
+	ThrottleBytesPerSecond uint64
+}
+
+// UsageDB gets the number of bytes sent to clients
+type UsageDB interface {
+	GetClientUsage(ctx context.Context, client string, now time.Time) (*rtvllog.ClientUsage, error)
+	ListClientUsage(ctx context.Context, now time.Time, offset int, limit int) ([]rtvllog.ClientUsage, error)
+}
+
+// Quotas decides whether clients have exceeded their retrieval quota,
+// according to the bytes sent to each client in the retrievals DB
+type Quotas struct {
+	cfg     Config
+	clients map[string]ClientConfig
+	db      UsageDB
+	now     func() time.Time
+}
+
+func New(cfg Config, db UsageDB) (*Quotas, error) {
+	if cfg.Action == "" {
+		cfg.Action = ActionBlock
+	}
+	if cfg.Action != ActionBlock && cfg.Action != ActionThrottle {
+		return nil, fmt.Errorf("unknown retrieval quota action '%s': must be %s or %s", cfg.Action, ActionBlock, ActionThrottle)
+	}
+	if cfg.Action == ActionThrottle && cfg.ThrottleBytesPerSecond == 0 {
+		return nil, fmt.Errorf("retrieval quota action is %s but the throttle rate is zero", ActionThrottle)
+	}
+
+	clients := make(map[string]ClientConfig, len(cfg.Clients))
+	for _, c := range cfg.Clients {
+		if c.Client == "" {
+			return nil, fmt.Errorf("retrieval quota for a client with no client id")
+		}
+		clients[c.Client] = c
+	}
+	return &Quotas{cfg: cfg, clients: clients, db: db, now: time.Now}, nil
+}
+
+// Enabled is false if no client has a quota
+func (q *Quotas) Enabled() bool {
+	return q.cfg.MaxBytesPerDay > 0 || q.cfg.MaxBytesPerMonth > 0 || len(q.clients) > 0
+}
+
+// Status returns the client's usage and quota
+func (q *Quotas) Status(ctx context.Context, client string) (*Status, error) {
+	usage, err := q.db.GetClientUsage(ctx, client, q.now())
+	if err != nil {
+		return nil, fmt.Errorf("getting retrieval usage for client %s: %w", client, err)
+	}
+	st := q.status(*usage)
+	return &st, nil
+}
+
+// Exceeded is true if the client has exceeded its quota (whether it is
+// throttled or blocked)
+func (q *Quotas) Exceeded(ctx context.Context, client string) (bool, error) {
+	st, err := q.Status(ctx, client)
+	if err != nil {
+		return false, err
+	}
+	return st.State != StateOK, nil
+}
+
+// List returns the usage and quota of the clients that have retrieved the
+// most data this month
+func (q *Quotas) List(ctx context.Context, offset int, limit int) ([]Status, error) {
+	usage, err := q.db.ListClientUsage(ctx, q.now(), offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listing retrieval usage: %w", err)
+	}
+	statuses := make([]Status, 0, len(usage))
+	for _, u := range usage {
+		statuses = append(statuses, q.status(u))
+	}
+	return statuses, nil
+}
+
+func (q *Quotas) status(u rtvllog.ClientUsage) Status {
+	maxPerDay, maxPerMonth := q.cfg.MaxBytesPerDay, q.cfg.MaxBytesPerMonth
+	if c, ok := q.clients[u.ClientID]; ok {
+		maxPerDay, maxPerMonth = c.MaxBytesPerDay, c.MaxBytesPerMonth
+	}
+
+	st := Status{
+		Client:           u.ClientID,
+		BytesToday:       u.BytesToday,
+		BytesThisMonth:   u.BytesThisMonth,
+		MaxBytesPerDay:   maxPerDay,
+		MaxBytesPerMonth: maxPerMonth,
+		State:            StateOK,
+	}
+	exceeded := (maxPerDay > 0 && u.BytesToday >= maxPerDay) || (maxPerMonth > 0 && u.BytesThisMonth >= maxPerMonth)
+	if exceeded {
+		st.State = StateBlocked
+		if q.cfg.Action == ActionThrottle {
+			st.State = StateThrottled
+			st.ThrottleBytesPerSecond = q.cfg.ThrottleBytesPerSecond
+		}
+	}
+	return st
+}
@@ -0,0 +1,113 @@
+package quota
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/retrievalmarket/rtvllog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotas(t *testing.T) {
+	ctx := context.Background()
+	sqldb := db.CreateTestTmpDB(t)
+	require.NoError(t, rtvllog.CreateTables(ctx, sqldb))
+	rdb := rtvllog.NewRetrievalLogDB(sqldb)
+
+	now := time.Date(2023, 3, 15, 12, 0, 0, 0, time.UTC)
+	attempts := []rtvllog.RetrievalAttempt{{
+		// Earlier this month
+		CreatedAt: now.AddDate(0, 0, -3),
+		Protocol:  rtvllog.ProtocolHTTP,
+		ClientID:  "client1",
+		BytesSent: 300,
+		Result:    rtvllog.ResultSuccess,
+	}, {
+		// Last month
+		CreatedAt: now.AddDate(0, -1, 0),
+		Protocol:  rtvllog.ProtocolGraphsync,
+		ClientID:  "client1",
+		BytesSent: 1000,
+		Result:    rtvllog.ResultSuccess,
+	}, {
+		// Today over two protocols
+		CreatedAt: now,
+		Protocol:  rtvllog.ProtocolBitswap,
+		ClientID:  "client1",
+		BytesSent: 50,
+		Result:    rtvllog.ResultSuccess,
+	}, {
+		CreatedAt: now,
+		Protocol:  rtvllog.ProtocolHTTP,
+		ClientID:  "client1",
+		BytesSent: 60,
+		Result:    rtvllog.ResultSuccess,
+	}, {
+		CreatedAt: now,
+		Protocol:  rtvllog.ProtocolHTTP,
+		ClientID:  "client2",
+		BytesSent: 200,
+		Result:    rtvllog.ResultSuccess,
+	}}
+	for i := range attempts {
+		require.NoError(t, rdb.InsertAttempt(ctx, &attempts[i]))
+	}
+
+	newQuotas := func(cfg Config) *Quotas {
+		q, err := New(cfg, rdb)
+		require.NoError(t, err)
+		q.now = func() time.Time { return now }
+		return q
+	}
+
+	// No quotas
+	q := newQuotas(Config{})
+	require.False(t, q.Enabled())
+	st, err := q.Status(ctx, "client1")
+	require.NoError(t, err)
+	require.Equal(t, StateOK, st.State)
+	require.EqualValues(t, 110, st.BytesToday)
+	require.EqualValues(t, 410, st.BytesThisMonth)
+
+	// Daily quota exceeded by client2 only
+	q = newQuotas(Config{MaxBytesPerDay: 150})
+	require.True(t, q.Enabled())
+	st, err = q.Status(ctx, "client1")
+	require.NoError(t, err)
+	require.Equal(t, StateOK, st.State)
+	st, err = q.Status(ctx, "client2")
+	require.NoError(t, err)
+	require.Equal(t, StateBlocked, st.State)
+
+	// Monthly quota exceeded by client1 only, and clients are throttled
+	q = newQuotas(Config{MaxBytesPerMonth: 400, Action: ActionThrottle, ThrottleBytesPerSecond: 1024})
+	list, err := q.List(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, "client1", list[0].Client)
+	require.Equal(t, StateThrottled, list[0].State)
+	require.EqualValues(t, 1024, list[0].ThrottleBytesPerSecond)
+	require.Equal(t, "client2", list[1].Client)
+	require.Equal(t, StateOK, list[1].State)
+
+	// A client-specific quota replaces the default quota
+	q = newQuotas(Config{MaxBytesPerDay: 150, Clients: []ClientConfig{{Client: "client2", MaxBytesPerDay: 500}}})
+	st, err = q.Status(ctx, "client2")
+	require.NoError(t, err)
+	require.Equal(t, StateOK, st.State)
+	require.EqualValues(t, 500, st.MaxBytesPerDay)
+
+	// A client with no usage
+	st, err = q.Status(ctx, "client3")
+	require.NoError(t, err)
+	require.Equal(t, StateOK, st.State)
+	require.Zero(t, st.BytesThisMonth)
+
+	// Invalid config
+	_, err = New(Config{Action: "slow down"}, rdb)
+	require.Error(t, err)
+	_, err = New(Config{Action: ActionThrottle}, rdb)
+	require.Error(t, err)
+}
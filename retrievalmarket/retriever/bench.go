@@ -0,0 +1,162 @@
+package retriever
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-libipfs/blocks"
+)
+
+// BenchResult is the result of a benchmark retrieval from one candidate
+type BenchResult struct {
+	Root      cid.Cid
+	Candidate Candidate
+	// The time from the start of the retrieval until the first byte of data
+	// was received
+	TimeToFirstByte time.Duration
+	Duration        time.Duration
+	Size            uint64
+	// The average number of bytes received per second
+	Throughput uint64
+	Err        error
+}
+
+// Bench retrieves the DAG under root from each candidate in turn (without
+// racing or falling back), and measures the time to first byte and the
+// throughput of each retrieval. The retriever should be created with a
+// DiscardBlockstore, so that the blocks retrieved from one candidate aren't
+// reused in the retrieval from the next candidate.
+func (r *Retriever) Bench(ctx context.Context, root cid.Cid, candidates []Candidate) []BenchResult {
+	candidates = r.sortCandidates(candidates)
+	results := make([]BenchResult, 0, len(candidates))
+	for _, c := range candidates {
+		if ctx.Err() != nil {
+			break
+		}
+		results = append(results, r.benchCandidate(ctx, root, c))
+	}
+	return results
+}
+
+func (r *Retriever) benchCandidate(ctx context.Context, root cid.Cid, c Candidate) BenchResult {
+	var firstByteLk sync.Mutex
+	var firstByte time.Time
+	progress := func(received uint64) {
+		firstByteLk.Lock()
+		defer firstByteLk.Unlock()
+		if firstByte.IsZero() && received > 0 {
+			firstByte = time.Now()
+		}
+	}
+
+	log.Infow("benchmarking retrieval", "root", root, "candidate", c)
+	start := time.Now()
+	size, err := r.retrieve(ctx, root, c, progress)
+	res := BenchResult{
+		Root:      root,
+		Candidate: c,
+		Duration:  time.Since(start),
+		Size:      size,
+		Err:       err,
+	}
+
+	firstByteLk.Lock()
+	if !firstByte.IsZero() {
+		res.TimeToFirstByte = firstByte.Sub(start)
+	}
+	firstByteLk.Unlock()
+
+	if res.Duration > 0 {
+		res.Throughput = uint64(float64(size) / res.Duration.Seconds())
+	}
+	return res
+}
+
+// BenchSummary summarizes the benchmark results for one protocol
+type BenchSummary struct {
+	Protocol  string
+	Successes int
+	Failures  int
+	// The averages over the successful retrievals
+	AverageTimeToFirstByte time.Duration
+	AverageThroughput      uint64
+	// The total bytes received over all the retrievals
+	TotalSize uint64
+}
+
+// SummarizeBench summarizes the benchmark results by protocol, in order of
+// protocol name
+func SummarizeBench(results []BenchResult) []BenchSummary {
+	byProtocol := make(map[string]*BenchSummary)
+	for _, res := range results {
+		s, ok := byProtocol[res.Candidate.Protocol]
+		if !ok {
+			s = &BenchSummary{Protocol: res.Candidate.Protocol}
+			byProtocol[res.Candidate.Protocol] = s
+		}
+		s.TotalSize += res.Size
+		if res.Err != nil {
+			s.Failures++
+			continue
+		}
+		s.Successes++
+		s.AverageTimeToFirstByte += res.TimeToFirstByte
+		s.AverageThroughput += res.Throughput
+	}
+
+	summaries := make([]BenchSummary, 0, len(byProtocol))
+	for _, s := range byProtocol {
+		if s.Successes > 0 {
+			s.AverageTimeToFirstByte /= time.Duration(s.Successes)
+			s.AverageThroughput /= uint64(s.Successes)
+		}
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Protocol < summaries[j].Protocol
+	})
+	return summaries
+}
+
+// DiscardBlockstore is a blockstore that doesn't keep any blocks, for
+// benchmark retrievals where the data isn't needed
+type DiscardBlockstore struct{}
+
+var _ blockstore.Blockstore = (*DiscardBlockstore)(nil)
+
+func (b *DiscardBlockstore) DeleteBlock(context.Context, cid.Cid) error {
+	return nil
+}
+
+func (b *DiscardBlockstore) Has(context.Context, cid.Cid) (bool, error) {
+	return false, nil
+}
+
+func (b *DiscardBlockstore) Get(_ context.Context, c cid.Cid) (blocks.Block, error) {
+	return nil, ipld.ErrNotFound{Cid: c}
+}
+
+func (b *DiscardBlockstore) GetSize(_ context.Context, c cid.Cid) (int, error) {
+	return 0, ipld.ErrNotFound{Cid: c}
+}
+
+func (b *DiscardBlockstore) Put(context.Context, blocks.Block) error {
+	return nil
+}
+
+func (b *DiscardBlockstore) PutMany(context.Context, []blocks.Block) error {
+	return nil
+}
+
+func (b *DiscardBlockstore) AllKeysChan(context.Context) (<-chan cid.Cid, error) {
+	ch := make(chan cid.Cid)
+	close(ch)
+	return ch, nil
+}
+
+func (b *DiscardBlockstore) HashOnRead(bool) {}
@@ -0,0 +1,79 @@
+package retriever
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	nilrouting "github.com/ipfs/go-ipfs-routing/none"
+	format "github.com/ipfs/go-ipld-format"
+	ipldlegacy "github.com/ipfs/go-ipld-legacy"
+	"github.com/ipfs/go-libipfs/bitswap/client"
+	bsnetwork "github.com/ipfs/go-libipfs/bitswap/network"
+	"github.com/ipfs/go-merkledag"
+)
+
+// The number of blocks to fetch in parallel in a bitswap retrieval
+const bitswapWalkConcurrency = 16
+
+// bitswapRetriever fetches DAGs over bitswap. There is one bitswap client
+// for the host, shared by all the bitswap retrievals.
+type bitswapRetriever struct {
+	r      *Retriever
+	client *client.Client
+}
+
+// bitswap returns the bitswap retriever, starting the bitswap client the
+// first time that it's needed
+func (r *Retriever) bitswap() (*bitswapRetriever, error) {
+	r.bsLk.Lock()
+	defer r.bsLk.Unlock()
+
+	if r.bs != nil {
+		return r.bs, nil
+	}
+
+	router, err := nilrouting.ConstructNilRouting(context.Background(), nil, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating bitswap router: %w", err)
+	}
+	net := bsnetwork.NewFromIpfsHost(r.cfg.Host, router)
+	bsClient := client.New(context.Background(), net, r.cfg.Blockstore)
+	net.Start(bsClient)
+
+	r.bs = &bitswapRetriever{r: r, client: bsClient}
+	return r.bs, nil
+}
+
+// retrieve walks the DAG under root, fetching each block from the candidate
+// over bitswap. Bitswap derives each block's CID from the block data, so
+// only blocks that are part of the DAG are written to the blockstore.
+func (b *bitswapRetriever) retrieve(ctx context.Context, root cid.Cid, c Candidate, progress func(uint64)) (uint64, error) {
+	if err := b.r.cfg.Host.Connect(ctx, c.Peer); err != nil {
+		return 0, fmt.Errorf("connecting to %s: %w", c.Peer.ID, err)
+	}
+
+	bserv := blockservice.NewSession(ctx, blockservice.New(b.r.cfg.Blockstore, b.client))
+	var received uint64
+	getLinks := func(ctx context.Context, c cid.Cid) ([]*format.Link, error) {
+		blk, err := bserv.GetBlock(ctx, c)
+		if err != nil {
+			return nil, fmt.Errorf("getting block %s: %w", c, err)
+		}
+		progress(atomic.AddUint64(&received, uint64(len(blk.RawData()))))
+
+		nd, err := ipldlegacy.DecodeNode(ctx, blk)
+		if err != nil {
+			return nil, fmt.Errorf("decoding block %s: %w", c, err)
+		}
+		return nd.Links(), nil
+	}
+
+	err := merkledag.Walk(ctx, getLinks, root, cid.NewSet().Visit, merkledag.Concurrency(bitswapWalkConcurrency))
+	if err != nil {
+		return atomic.LoadUint64(&received), err
+	}
+	return atomic.LoadUint64(&received), nil
+}
@@ -0,0 +1,234 @@
+package retriever
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/filecoin-project/boost/retrievalmarket/lp2pimpl"
+	multiaddrutil "github.com/filecoin-project/go-legs/httpsync/multiaddr"
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/index-provider/metadata"
+	finderhttpclient "github.com/ipni/storetheindex/api/v0/finder/client/http"
+	"github.com/ipni/storetheindex/api/v0/finder/model"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+)
+
+// The protocols that content can be retrieved over
+const (
+	ProtocolHTTP      = "http"
+	ProtocolBitswap   = "bitswap"
+	ProtocolGraphsync = "graphsync"
+)
+
+// DefaultProtocols are the protocols that are retrieved over by default, in
+// order of preference
+var DefaultProtocols = []string{ProtocolHTTP, ProtocolBitswap, ProtocolGraphsync}
+
+// Candidate is an endpoint that may serve a retrieval over one protocol
+type Candidate struct {
+	Protocol string
+	// The peer to retrieve from over bitswap or graphsync
+	Peer peer.AddrInfo
+	// The base URL of the endpoint to retrieve from over http
+	URL string
+}
+
+func (c Candidate) String() string {
+	if c.Protocol == ProtocolHTTP {
+		return c.Protocol + " " + c.URL
+	}
+	return c.Protocol + " " + c.Peer.ID.String()
+}
+
+// finder queries an indexer for the providers of a multihash
+type finder interface {
+	Find(ctx context.Context, m multihash.Multihash) (*model.FindResponse, error)
+}
+
+// IndexerFinder finds candidates for a retrieval from the providers that
+// the network indexer returns for the root CID
+type IndexerFinder struct {
+	fc finder
+}
+
+func NewIndexerFinder(indexerURL string) (*IndexerFinder, error) {
+	fc, err := finderhttpclient.New(indexerURL)
+	if err != nil {
+		return nil, fmt.Errorf("creating indexer client for %s: %w", indexerURL, err)
+	}
+	return &IndexerFinder{fc: fc}, nil
+}
+
+// FindCandidates returns a candidate for each protocol that each provider
+// announced to the indexer for the root CID
+func (f *IndexerFinder) FindCandidates(ctx context.Context, root cid.Cid) ([]Candidate, error) {
+	resp, err := f.fc.Find(ctx, root.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("querying indexer for %s: %w", root, err)
+	}
+
+	var candidates []Candidate
+	seen := make(map[string]struct{})
+	for _, mhr := range resp.MultihashResults {
+		for _, pr := range mhr.ProviderResults {
+			for _, c := range candidatesFromProviderResult(pr) {
+				// The same provider may have announced the CID in several
+				// deals
+				if _, ok := seen[c.String()]; ok {
+					continue
+				}
+				seen[c.String()] = struct{}{}
+				candidates = append(candidates, c)
+			}
+		}
+	}
+	return candidates, nil
+}
+
+// The metadata context that can decode all the retrieval transports that
+// boost announces
+var metadataContext = metadata.Default.WithProtocol(multicodec.Http, func() metadata.Protocol { return &httpMetadata{} })
+
+func candidatesFromProviderResult(pr model.ProviderResult) []Candidate {
+	// The version of index-provider used by boost marshals the http
+	// transport metadata to an empty byte array, so an extended provider
+	// with empty metadata and http addresses is an http endpoint
+	if len(pr.Metadata) == 0 {
+		return httpCandidates(pr.Provider)
+	}
+
+	md := metadataContext.New()
+	if err := md.UnmarshalBinary(pr.Metadata); err != nil {
+		log.Debugw("skipping provider with unsupported metadata", "provider", pr.Provider.ID, "err", err)
+		return nil
+	}
+
+	var candidates []Candidate
+	for _, code := range md.Protocols() {
+		switch code {
+		case multicodec.TransportBitswap:
+			candidates = append(candidates, Candidate{Protocol: ProtocolBitswap, Peer: pr.Provider})
+		case multicodec.TransportGraphsyncFilecoinv1:
+			candidates = append(candidates, Candidate{Protocol: ProtocolGraphsync, Peer: pr.Provider})
+		case multicodec.Http:
+			candidates = append(candidates, httpCandidates(pr.Provider)...)
+		}
+	}
+	return candidates
+}
+
+// httpCandidates returns a candidate for each of the provider's http(s)
+// addresses
+func httpCandidates(p peer.AddrInfo) []Candidate {
+	var candidates []Candidate
+	for _, a := range p.Addrs {
+		if !isHttpAddr(a) {
+			continue
+		}
+		u, err := multiaddrutil.ToURL(a)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, Candidate{Protocol: ProtocolHTTP, Peer: p, URL: u.String()})
+	}
+	return candidates
+}
+
+func isHttpAddr(a multiaddr.Multiaddr) bool {
+	for _, p := range a.Protocols() {
+		if p.Code == multiaddr.P_HTTP || p.Code == multiaddr.P_HTTPS {
+			return true
+		}
+	}
+	return false
+}
+
+// httpMetadata decodes the metadata for the http transport, which has no
+// payload
+type httpMetadata struct{}
+
+func (m *httpMetadata) ID() multicodec.Code {
+	return multicodec.Http
+}
+
+func (m *httpMetadata) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(multicodec.Http))
+	return buf[:n], nil
+}
+
+func (m *httpMetadata) UnmarshalBinary(data []byte) error {
+	_, err := m.ReadFrom(bytes.NewReader(data))
+	return err
+}
+
+func (m *httpMetadata) ReadFrom(r io.Reader) (int64, error) {
+	br := &byteReader{r: r}
+	code, err := binary.ReadUvarint(br)
+	if err != nil {
+		return br.read, err
+	}
+	if multicodec.Code(code) != multicodec.Http {
+		return br.read, fmt.Errorf("transport ID does not match %s", multicodec.Http)
+	}
+	return br.read, nil
+}
+
+// byteReader reads one byte at a time from a reader, and counts the bytes
+// read
+type byteReader struct {
+	r    io.Reader
+	read int64
+}
+
+func (b *byteReader) ReadByte() (byte, error) {
+	var buf [1]byte
+	if _, err := io.ReadFull(b.r, buf[:]); err != nil {
+		return 0, err
+	}
+	b.read++
+	return buf[0], nil
+}
+
+// ProviderCandidates returns a candidate for each retrieval transport that
+// a storage provider advertises over the transports protocol. If the
+// provider doesn't support the transports protocol, it's assumed to serve
+// retrievals over graphsync only.
+func ProviderCandidates(ctx context.Context, tc *lp2pimpl.TransportsClient, provider peer.AddrInfo) ([]Candidate, error) {
+	resp, err := tc.SendQuery(ctx, provider.ID)
+	if err != nil {
+		log.Debugw("provider did not respond to transports query: falling back to graphsync", "provider", provider.ID, "err", err)
+		return []Candidate{{Protocol: ProtocolGraphsync, Peer: provider}}, nil
+	}
+
+	var candidates []Candidate
+	for _, p := range resp.Protocols {
+		switch p.Name {
+		case "libp2p":
+			candidates = append(candidates, Candidate{Protocol: ProtocolGraphsync, Peer: provider})
+		case "bitswap":
+			addrInfos, err := peer.AddrInfosFromP2pAddrs(p.Addresses...)
+			if err != nil {
+				return nil, fmt.Errorf("parsing bitswap addresses of provider %s: %w", provider.ID, err)
+			}
+			for _, ai := range addrInfos {
+				candidates = append(candidates, Candidate{Protocol: ProtocolBitswap, Peer: ai})
+			}
+		case "http", "https":
+			for _, a := range p.Addresses {
+				u, err := multiaddrutil.ToURL(a)
+				if err != nil {
+					return nil, fmt.Errorf("parsing http address %s of provider %s: %w", a, provider.ID, err)
+				}
+				candidates = append(candidates, Candidate{Protocol: ProtocolHTTP, Peer: provider, URL: u.String()})
+			}
+		}
+	}
+	return candidates, nil
+}
@@ -0,0 +1,141 @@
+package retriever
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	ipldlegacy "github.com/ipfs/go-ipld-legacy"
+	"github.com/ipfs/go-libipfs/blocks"
+	"github.com/ipld/go-car/v2"
+)
+
+const carMediaType = "application/vnd.ipld.car"
+
+// retrieveHttp downloads the DAG as a CAR file from a booster-http endpoint.
+// The CAR is expected in depth-first order without duplicate blocks, and each
+// block is checked against its CID and its position in the DAG as it
+// arrives, so that a provider can't send data that isn't part of the DAG.
+func (r *Retriever) retrieveHttp(ctx context.Context, root cid.Cid, c Candidate, progress func(uint64)) (uint64, error) {
+	u := strings.TrimSuffix(c.URL, "/") + "/ipfs/" + root.String() + "?format=car"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, fmt.Errorf("creating request for %s: %w", u, err)
+	}
+	req.Header.Set("Accept", carMediaType)
+
+	resp, err := r.cfg.HttpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("requesting %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("requesting %s: status %d: %s", u, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	cr := &countingReader{r: bufio.NewReader(resp.Body), progress: progress}
+	if err := r.readVerifiedCar(ctx, root, cr); err != nil {
+		return cr.count, err
+	}
+	return cr.count, nil
+}
+
+// readVerifiedCar reads the blocks of the DAG under root from a CAR stream
+// in depth-first order, and writes them to the blockstore
+func (r *Retriever) readVerifiedCar(ctx context.Context, root cid.Cid, stream io.Reader) error {
+	br, err := car.NewBlockReader(stream)
+	if err != nil {
+		return fmt.Errorf("reading car header: %w", err)
+	}
+	if len(br.Roots) != 1 || !br.Roots[0].Equals(root) {
+		return fmt.Errorf("car has roots %v but expected root %s", br.Roots, root)
+	}
+
+	// The CIDs that are expected next in the stream, with the next one
+	// at the end
+	expected := []cid.Cid{root}
+	seen := cid.NewSet()
+	for {
+		next, ok := nextExpected(&expected, seen)
+
+		blk, err := br.Next()
+		if err == io.EOF {
+			if ok {
+				return fmt.Errorf("car ended before block %s", next)
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading car: %w", err)
+		}
+		if !ok {
+			return fmt.Errorf("car has block %s that is not part of the DAG", blk.Cid())
+		}
+		if !blk.Cid().Equals(next) {
+			return fmt.Errorf("car has block %s where block %s was expected", blk.Cid(), next)
+		}
+		if err := verifyBlock(blk); err != nil {
+			return err
+		}
+		if err := r.cfg.Blockstore.Put(ctx, blk); err != nil {
+			return fmt.Errorf("writing block %s: %w", blk.Cid(), err)
+		}
+		seen.Add(blk.Cid())
+
+		nd, err := ipldlegacy.DecodeNode(ctx, blk)
+		if err != nil {
+			return fmt.Errorf("decoding block %s: %w", blk.Cid(), err)
+		}
+		// Push the links in reverse order so that the first link is next
+		links := nd.Links()
+		for i := len(links) - 1; i >= 0; i-- {
+			expected = append(expected, links[i].Cid)
+		}
+	}
+}
+
+// nextExpected pops the next expected CID that hasn't already been seen
+func nextExpected(expected *[]cid.Cid, seen *cid.Set) (cid.Cid, bool) {
+	for len(*expected) > 0 {
+		c := (*expected)[len(*expected)-1]
+		*expected = (*expected)[:len(*expected)-1]
+		if !seen.Has(c) {
+			return c, true
+		}
+	}
+	return cid.Undef, false
+}
+
+// verifyBlock checks that the block's data hashes to its CID
+func verifyBlock(blk blocks.Block) error {
+	c, err := blk.Cid().Prefix().Sum(blk.RawData())
+	if err != nil {
+		return fmt.Errorf("hashing block %s: %w", blk.Cid(), err)
+	}
+	if !c.Equals(blk.Cid()) {
+		return fmt.Errorf("block data does not match its CID %s", blk.Cid())
+	}
+	return nil
+}
+
+// countingReader counts the bytes read and reports them to progress
+type countingReader struct {
+	r        io.Reader
+	count    uint64
+	progress func(uint64)
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		c.count += uint64(n)
+		c.progress(c.count)
+	}
+	return n, err
+}
@@ -0,0 +1,222 @@
+package retriever
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+var log = logging.Logger("retriever")
+
+// The defaults used if the config values are not set
+const (
+	defaultConcurrency   = 3
+	defaultFallbackDelay = 5 * time.Second
+)
+
+type Config struct {
+	// The host used for bitswap and graphsync retrievals
+	Host host.Host
+	// The blockstore that retrieved blocks are written to
+	Blockstore blockstore.Blockstore
+	// The client for graphsync retrievals (if nil, graphsync candidates are
+	// skipped)
+	Graphsync GraphsyncClient
+	// The protocols to retrieve over, in order of preference
+	// (DefaultProtocols if empty)
+	Protocols []string
+	// The maximum number of candidates to retrieve from at the same time
+	Concurrency int
+	// How long to wait for a retrieval to succeed before racing it against
+	// the next candidate
+	FallbackDelay time.Duration
+	// The client for http retrievals (http.DefaultClient if nil)
+	HttpClient *http.Client
+}
+
+// GraphsyncClient retrieves a DAG from a peer over graphsync, and returns
+// the number of bytes received (implemented by the boost retrieval client)
+type GraphsyncClient interface {
+	RetrieveFromPeer(ctx context.Context, root cid.Cid, p peer.AddrInfo, progress func(uint64)) (uint64, error)
+}
+
+// Retriever retrieves a DAG from a set of candidates that may serve it over
+// different protocols. It starts with the preferred candidate, and if the
+// retrieval fails or is slow it races or falls back to the next candidates,
+// until one of them serves the complete DAG.
+type Retriever struct {
+	cfg Config
+
+	bsLk sync.Mutex
+	bs   *bitswapRetriever
+}
+
+func New(cfg Config) *Retriever {
+	if len(cfg.Protocols) == 0 {
+		cfg.Protocols = DefaultProtocols
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = defaultConcurrency
+	}
+	if cfg.FallbackDelay <= 0 {
+		cfg.FallbackDelay = defaultFallbackDelay
+	}
+	if cfg.HttpClient == nil {
+		cfg.HttpClient = http.DefaultClient
+	}
+	return &Retriever{cfg: cfg}
+}
+
+// Result describes the retrieval that succeeded
+type Result struct {
+	Candidate    Candidate
+	Size         uint64
+	Duration     time.Duration
+	AverageSpeed uint64
+	// The number of candidates that were tried
+	Attempts int
+}
+
+// ProgressFunc is called with the number of bytes received so far from a
+// candidate. It may be called concurrently.
+type ProgressFunc func(c Candidate, bytesReceived uint64)
+
+type attemptResult struct {
+	candidate Candidate
+	size      uint64
+	err       error
+}
+
+// Retrieve retrieves the DAG under root from the first candidate that can
+// serve all of it
+func (r *Retriever) Retrieve(ctx context.Context, root cid.Cid, candidates []Candidate, progress ProgressFunc) (*Result, error) {
+	candidates = r.sortCandidates(candidates)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no candidates to retrieve %s from over %v", root, r.cfg.Protocols)
+	}
+	if progress == nil {
+		progress = func(Candidate, uint64) {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	start := time.Now()
+	results := make(chan attemptResult, len(candidates))
+	next := 0
+	running := 0
+	startNext := func() {
+		c := candidates[next]
+		next++
+		running++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log.Infow("retrieving", "root", root, "candidate", c)
+			size, err := r.retrieve(ctx, root, c, func(received uint64) { progress(c, received) })
+			results <- attemptResult{candidate: c, size: size, err: err}
+		}()
+	}
+
+	startNext()
+	fallback := time.NewTicker(r.cfg.FallbackDelay)
+	defer fallback.Stop()
+
+	var errs *multierror.Error
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+
+		case <-fallback.C:
+			// The running retrievals are taking a while, so race them
+			// against the next candidate
+			if next < len(candidates) && running < r.cfg.Concurrency {
+				startNext()
+			}
+
+		case res := <-results:
+			running--
+			if res.err == nil {
+				duration := time.Since(start)
+				return &Result{
+					Candidate:    res.candidate,
+					Size:         res.size,
+					Duration:     duration,
+					AverageSpeed: uint64(float64(res.size) / duration.Seconds()),
+					Attempts:     next,
+				}, nil
+			}
+
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Warnw("retrieval failed", "root", root, "candidate", res.candidate, "err", res.err)
+			errs = multierror.Append(errs, fmt.Errorf("%s: %w", res.candidate, res.err))
+
+			// Fall back to the next candidate straight away
+			if next < len(candidates) {
+				startNext()
+				fallback.Reset(r.cfg.FallbackDelay)
+			} else if running == 0 {
+				return nil, fmt.Errorf("retrieving %s failed with all %d candidates: %w", root, len(candidates), errs.ErrorOrNil())
+			}
+		}
+	}
+}
+
+// retrieve retrieves the DAG from one candidate, verifying the blocks as
+// they arrive, and returns the number of bytes received
+func (r *Retriever) retrieve(ctx context.Context, root cid.Cid, c Candidate, progress func(uint64)) (uint64, error) {
+	switch c.Protocol {
+	case ProtocolHTTP:
+		return r.retrieveHttp(ctx, root, c, progress)
+	case ProtocolBitswap:
+		bs, err := r.bitswap()
+		if err != nil {
+			return 0, err
+		}
+		return bs.retrieve(ctx, root, c, progress)
+	case ProtocolGraphsync:
+		// Graphsync verifies each block against the traversal of the DAG
+		// as it arrives
+		return r.cfg.Graphsync.RetrieveFromPeer(ctx, root, c.Peer, progress)
+	}
+	return 0, errors.New("unsupported protocol " + c.Protocol)
+}
+
+// sortCandidates removes the candidates for protocols that aren't enabled
+// and sorts the rest in order of protocol preference
+func (r *Retriever) sortCandidates(candidates []Candidate) []Candidate {
+	rank := make(map[string]int, len(r.cfg.Protocols))
+	for i, p := range r.cfg.Protocols {
+		if p == ProtocolGraphsync && r.cfg.Graphsync == nil {
+			continue
+		}
+		rank[p] = i
+	}
+
+	sorted := make([]Candidate, 0, len(candidates))
+	for _, c := range candidates {
+		if _, ok := rank[c.Protocol]; ok {
+			sorted = append(sorted, c)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return rank[sorted[i].Protocol] < rank[sorted[j].Protocol]
+	})
+	return sorted
+}
@@ -0,0 +1,221 @@
+package retriever
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	"github.com/ipfs/go-merkledag"
+	"github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+	"github.com/ipni/index-provider/metadata"
+	"github.com/ipni/storetheindex/api/v0/finder/model"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetrieveHttp(t *testing.T) {
+	ctx := context.Background()
+
+	// Create a DAG on the "provider"
+	srcBs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	srcDag := merkledag.NewDAGService(blockservice.New(srcBs, offline.Exchange(srcBs)))
+	filePath, err := testutil.CreateRandomFile(t.TempDir(), 1, 64*1024)
+	require.NoError(t, err)
+	root, err := testutil.WriteUnixfsDAGTo(filePath, srcDag, 1024, 4)
+	require.NoError(t, err)
+
+	// Serves the DAG as a CAR in depth-first order
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/ipfs/"+root.String(), r.URL.Path)
+		require.NoError(t, car.WriteCar(r.Context(), srcDag, []cid.Cid{root}, w))
+	}))
+	defer good.Close()
+
+	// Serves the root block followed by a block that isn't part of the DAG
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, car.WriteHeader(&car.CarHeader{Roots: []cid.Cid{root}, Version: 1}, w))
+		rootBlk, err := srcBs.Get(r.Context(), root)
+		require.NoError(t, err)
+		require.NoError(t, carutil.LdWrite(w, root.Bytes(), rootBlk.RawData()))
+		other := testutil.GenerateBlocksOfSize(1, 256)[0]
+		require.NoError(t, carutil.LdWrite(w, other.Cid().Bytes(), other.RawData()))
+	}))
+	defer bad.Close()
+
+	// Refuses the retrieval
+	refused := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer refused.Close()
+
+	newRetriever := func() (*Retriever, blockstore.Blockstore) {
+		bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+		return New(Config{Blockstore: bs, FallbackDelay: time.Minute}), bs
+	}
+	httpCandidate := func(u string) Candidate {
+		return Candidate{Protocol: ProtocolHTTP, URL: u}
+	}
+
+	t.Run("retrieve and verify", func(t *testing.T) {
+		r, bs := newRetriever()
+		res, err := r.Retrieve(ctx, root, []Candidate{httpCandidate(good.URL)}, nil)
+		require.NoError(t, err)
+		require.Equal(t, good.URL, res.Candidate.URL)
+		require.Greater(t, res.Size, uint64(64*1024))
+
+		// All the blocks of the DAG have been retrieved
+		dag := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+		require.NoError(t, merkledag.FetchGraph(ctx, root, dag))
+	})
+
+	t.Run("fall back to the next candidate", func(t *testing.T) {
+		r, _ := newRetriever()
+		candidates := []Candidate{httpCandidate(refused.URL), httpCandidate(bad.URL), httpCandidate(good.URL)}
+		res, err := r.Retrieve(ctx, root, candidates, nil)
+		require.NoError(t, err)
+		require.Equal(t, good.URL, res.Candidate.URL)
+		require.Equal(t, 3, res.Attempts)
+	})
+
+	t.Run("race a slow candidate", func(t *testing.T) {
+		hang := make(chan struct{})
+		defer close(hang)
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-hang:
+			case <-r.Context().Done():
+			}
+		}))
+		defer slow.Close()
+
+		bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+		r := New(Config{Blockstore: bs, FallbackDelay: 50 * time.Millisecond})
+		res, err := r.Retrieve(ctx, root, []Candidate{httpCandidate(slow.URL), httpCandidate(good.URL)}, nil)
+		require.NoError(t, err)
+		require.Equal(t, good.URL, res.Candidate.URL)
+	})
+
+	t.Run("all candidates fail", func(t *testing.T) {
+		r, _ := newRetriever()
+		_, err := r.Retrieve(ctx, root, []Candidate{httpCandidate(bad.URL), httpCandidate(refused.URL)}, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "was expected")
+	})
+}
+
+func TestSortCandidates(t *testing.T) {
+	gs := Candidate{Protocol: ProtocolGraphsync}
+	bs := Candidate{Protocol: ProtocolBitswap}
+	h := Candidate{Protocol: ProtocolHTTP, URL: "http://localhost"}
+
+	// Graphsync candidates are skipped if there's no graphsync client
+	r := New(Config{})
+	require.Equal(t, []Candidate{h, bs}, r.sortCandidates([]Candidate{gs, bs, h}))
+
+	// Only the configured protocols are used, in order of preference
+	r = New(Config{Protocols: []string{ProtocolBitswap, ProtocolHTTP}})
+	require.Equal(t, []Candidate{bs, h}, r.sortCandidates([]Candidate{h, gs, bs}))
+}
+
+func TestCandidatesFromProviderResult(t *testing.T) {
+	p := testutil.GeneratePeer()
+	httpAddr, err := multiaddr.NewMultiaddr("/dns/sp.example.com/tcp/443/https")
+	require.NoError(t, err)
+
+	marshal := func(protos ...metadata.Protocol) []byte {
+		md := metadata.Default.New(protos...)
+		bz, err := md.MarshalBinary()
+		require.NoError(t, err)
+		return bz
+	}
+
+	// Graphsync and bitswap are announced on the provider record, http on
+	// an extended provider record with the http address (with empty
+	// metadata, or with the http transport code)
+	providerResults := []model.ProviderResult{{
+		Provider: peer.AddrInfo{ID: p},
+		Metadata: marshal(&metadata.GraphsyncFilecoinV1{PieceCID: testutil.GenerateCid()}),
+	}, {
+		Provider: peer.AddrInfo{ID: p},
+		Metadata: marshal(metadata.Bitswap{}),
+	}, {
+		Provider: peer.AddrInfo{ID: p, Addrs: []multiaddr.Multiaddr{httpAddr}},
+		Metadata: marshal(metadata.HTTPV1()),
+	}, {
+		Provider: peer.AddrInfo{ID: p, Addrs: []multiaddr.Multiaddr{httpAddr}},
+		Metadata: marshal(&httpMetadata{}),
+	}, {
+		Provider: peer.AddrInfo{ID: p},
+		Metadata: []byte("unknown"),
+	}}
+
+	var candidates []Candidate
+	for _, pr := range providerResults {
+		candidates = append(candidates, candidatesFromProviderResult(pr)...)
+	}
+	require.Len(t, candidates, 4)
+	require.Equal(t, ProtocolGraphsync, candidates[0].Protocol)
+	require.Equal(t, ProtocolBitswap, candidates[1].Protocol)
+	for _, c := range candidates[2:] {
+		require.Equal(t, ProtocolHTTP, c.Protocol)
+		require.Equal(t, "https://sp.example.com:443", c.URL)
+	}
+}
+
+func TestBench(t *testing.T) {
+	ctx := context.Background()
+
+	srcBs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	srcDag := merkledag.NewDAGService(blockservice.New(srcBs, offline.Exchange(srcBs)))
+	filePath, err := testutil.CreateRandomFile(t.TempDir(), 1, 64*1024)
+	require.NoError(t, err)
+	root, err := testutil.WriteUnixfsDAGTo(filePath, srcDag, 1024, 4)
+	require.NoError(t, err)
+
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, car.WriteCar(r.Context(), srcDag, []cid.Cid{root}, w))
+	}))
+	defer good.Close()
+	refused := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer refused.Close()
+
+	// Each candidate is retrieved from, even after a retrieval succeeds
+	r := New(Config{Blockstore: &DiscardBlockstore{}})
+	candidates := []Candidate{
+		{Protocol: ProtocolHTTP, URL: good.URL},
+		{Protocol: ProtocolHTTP, URL: refused.URL},
+		{Protocol: ProtocolHTTP, URL: good.URL},
+	}
+	results := r.Bench(ctx, root, candidates)
+	require.Len(t, results, 3)
+	for _, i := range []int{0, 2} {
+		require.NoError(t, results[i].Err)
+		require.True(t, results[i].Root.Equals(root))
+		require.Greater(t, results[i].Size, uint64(64*1024))
+		require.Greater(t, results[i].TimeToFirstByte, time.Duration(0))
+		require.LessOrEqual(t, results[i].TimeToFirstByte, results[i].Duration)
+		require.Greater(t, results[i].Throughput, uint64(0))
+	}
+	require.Error(t, results[1].Err)
+
+	summaries := SummarizeBench(results)
+	require.Len(t, summaries, 1)
+	require.Equal(t, ProtocolHTTP, summaries[0].Protocol)
+	require.Equal(t, 2, summaries[0].Successes)
+	require.Equal(t, 1, summaries[0].Failures)
+	require.Equal(t, results[0].Size+results[2].Size, summaries[0].TotalSize)
+	require.Greater(t, summaries[0].AverageThroughput, uint64(0))
+}
@@ -0,0 +1,268 @@
+package rtvlevents
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("rtvlevents")
+
+// The types of retrieval event
+const (
+	// The provider started serving a retrieval
+	EventStarted = "started"
+	// Bytes were delivered to the client (sent periodically while a
+	// retrieval is in progress)
+	EventBytesDelivered = "bytes-delivered"
+	// The retrieval completed successfully
+	EventCompleted = "completed"
+	// The retrieval was rejected or failed
+	EventFailed = "failed"
+)
+
+// Event is a structured retrieval event, for consumption by external
+// billing and analytics systems
+type Event struct {
+	Type string
+	Time time.Time
+	// The protocol the retrieval was served over: graphsync, http or bitswap
+	Protocol string
+	// Identifies the retrieval, so that the events of a retrieval can be
+	// correlated. It's empty for retrievals that are only reported when they
+	// finish (http and bitswap).
+	RetrievalID string
+	// The peer ID of the client for graphsync and bitswap retrievals, or the
+	// auth token id or IP address of the client for HTTP retrievals
+	ClientID   string
+	PayloadCID cid.Cid
+	PieceCID   *cid.Cid
+	// The total number of bytes delivered so far
+	BytesSent uint64
+	// The time since the retrieval started (for completed and failed events)
+	Duration time.Duration
+	// The result of the retrieval (for completed and failed events):
+	// success, rejected or failure
+	Result  string
+	Message string
+}
+
+// Final returns true if the event is the last event of a retrieval
+func (e *Event) Final() bool {
+	return e.Type == EventCompleted || e.Type == EventFailed
+}
+
+// Sink is a destination for retrieval events
+type Sink interface {
+	// Send delivers a batch of events to the sink
+	Send(ctx context.Context, evts []Event) error
+	Close() error
+}
+
+const (
+	// The number of events that can wait to be sent before new events are
+	// dropped
+	queueSize = 10000
+	// The maximum number of events to send to a sink in one batch
+	batchSize = 100
+	// The maximum time an event waits to be batched with other events
+	flushInterval = time.Second
+	// The number of times to try to send a batch of events to a sink
+	sendAttempts = 3
+	// The time to wait before retrying a batch of events
+	retryDelay = time.Second
+)
+
+// Publisher sends retrieval events to each of its sinks.
+// Events are queued and sent in batches in the background, so publishing an
+// event never blocks a retrieval. Delivery is at most once: events are held
+// in memory (up to queueSize events), and a batch that fails sendAttempts
+// times is dropped, as are events that are still queued when boost stops
+// uncleanly (the retrieval attempts in the retrieval log are the record of
+// what was served).
+type Publisher struct {
+	sinks            []Sink
+	progressInterval time.Duration
+	queue            chan Event
+
+	lastProgressLk sync.Mutex
+	lastProgress   map[string]time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewPublisher creates a publisher that sends events to the given sinks.
+// Bytes delivered events are sent at most once per progressInterval for
+// each retrieval.
+func NewPublisher(progressInterval time.Duration, sinks ...Sink) *Publisher {
+	return &Publisher{
+		sinks:            sinks,
+		progressInterval: progressInterval,
+		queue:            make(chan Event, queueSize),
+		lastProgress:     make(map[string]time.Time),
+	}
+}
+
+// Enabled returns true if there are any sinks to send events to
+func (p *Publisher) Enabled() bool {
+	return p != nil && len(p.sinks) > 0
+}
+
+// Publish queues an event to be sent to the sinks
+func (p *Publisher) Publish(evt Event) {
+	if !p.Enabled() {
+		return
+	}
+
+	if evt.Time.IsZero() {
+		evt.Time = time.Now()
+	}
+	if !p.allow(evt) {
+		return
+	}
+
+	select {
+	case p.queue <- evt:
+	default:
+		log.Warnw("retrieval event queue is full: dropping event", "type", evt.Type, "retrieval", evt.RetrievalID, "client", evt.ClientID)
+	}
+}
+
+// allow limits bytes delivered events to one per progress interval for each
+// retrieval
+func (p *Publisher) allow(evt Event) bool {
+	if evt.RetrievalID == "" {
+		return true
+	}
+
+	p.lastProgressLk.Lock()
+	defer p.lastProgressLk.Unlock()
+
+	if evt.Final() {
+		delete(p.lastProgress, evt.RetrievalID)
+		return true
+	}
+	if evt.Type != EventBytesDelivered {
+		return true
+	}
+
+	lastAt, ok := p.lastProgress[evt.RetrievalID]
+	if ok && evt.Time.Sub(lastAt) < p.progressInterval {
+		return false
+	}
+	p.lastProgress[evt.RetrievalID] = evt.Time
+	return true
+}
+
+func (p *Publisher) Start(ctx context.Context) {
+	if !p.Enabled() {
+		return
+	}
+
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.done = make(chan struct{})
+	go p.run(ctx)
+}
+
+// Stop sends any queued events and closes the sinks
+func (p *Publisher) Stop() {
+	if p.cancel == nil {
+		return
+	}
+
+	p.cancel()
+	<-p.done
+
+	for _, s := range p.sinks {
+		if err := s.Close(); err != nil {
+			log.Warnw("closing retrieval event sink", "err", err)
+		}
+	}
+}
+
+func (p *Publisher) run(ctx context.Context) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var batch []Event
+	for {
+		select {
+		case <-ctx.Done():
+			// Send the events that are still waiting before shutting down
+			batch = append(batch, p.nextBatch()...)
+			for len(batch) > 0 {
+				p.send(context.Background(), batch)
+				batch = p.nextBatch()
+			}
+			return
+		case evt := <-p.queue:
+			batch = append(batch, evt)
+			if len(batch) < batchSize {
+				continue
+			}
+		case now := <-ticker.C:
+			p.gcProgress(now)
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		p.send(ctx, batch)
+		batch = nil
+	}
+}
+
+// nextBatch takes up to a batch of events from the queue without waiting
+func (p *Publisher) nextBatch() []Event {
+	var batch []Event
+	for len(batch) < batchSize {
+		select {
+		case evt := <-p.queue:
+			batch = append(batch, evt)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// send delivers the batch to each sink, retrying a few times if a sink fails
+func (p *Publisher) send(ctx context.Context, batch []Event) {
+	for _, s := range p.sinks {
+		var err error
+		for i := 0; i < sendAttempts; i++ {
+			if i > 0 {
+				select {
+				case <-ctx.Done():
+				case <-time.After(retryDelay):
+				}
+			}
+			if err = s.Send(ctx, batch); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			log.Errorw("failed to send retrieval events: dropping events", "sink", s, "count", len(batch), "err", err)
+		}
+	}
+}
+
+// gcProgress forgets the last progress time of retrievals that haven't made
+// progress for a while (eg because they stalled), so that the map doesn't
+// grow forever
+func (p *Publisher) gcProgress(now time.Time) {
+	p.lastProgressLk.Lock()
+	defer p.lastProgressLk.Unlock()
+
+	for id, lastAt := range p.lastProgress {
+		if now.Sub(lastAt) > 10*p.progressInterval+time.Minute {
+			delete(p.lastProgress, id)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package rtvlevents
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/filecoin-project/boost/webhooks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher(t *testing.T) {
+	ctx := context.Background()
+	sink := &memSink{}
+	p := NewPublisher(time.Minute, sink)
+	p.Start(ctx)
+
+	now := time.Now()
+	payload := testutil.GenerateCid()
+	evt := func(typ string, sent uint64, at time.Duration) Event {
+		return Event{
+			Type:        typ,
+			Time:        now.Add(at),
+			Protocol:    "graphsync",
+			RetrievalID: "client/1",
+			ClientID:    "client",
+			PayloadCID:  payload,
+			BytesSent:   sent,
+		}
+	}
+
+	p.Publish(evt(EventStarted, 0, 0))
+	p.Publish(evt(EventBytesDelivered, 10, time.Second))
+	// Too soon after the last bytes delivered event
+	p.Publish(evt(EventBytesDelivered, 20, 2*time.Second))
+	p.Publish(evt(EventBytesDelivered, 30, 2*time.Minute))
+	p.Publish(evt(EventCompleted, 40, 3*time.Minute))
+	p.Stop()
+
+	evts := sink.events()
+	require.Len(t, evts, 4)
+	require.Equal(t, EventStarted, evts[0].Type)
+	require.Equal(t, EventBytesDelivered, evts[1].Type)
+	require.EqualValues(t, 10, evts[1].BytesSent)
+	require.EqualValues(t, 30, evts[2].BytesSent)
+	require.Equal(t, EventCompleted, evts[3].Type)
+	require.True(t, evts[3].PayloadCID.Equals(payload))
+
+	// A publisher without sinks ignores events
+	var nilPublisher *Publisher
+	require.False(t, nilPublisher.Enabled())
+	nilPublisher.Publish(evt(EventStarted, 0, 0))
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+	s, err := NewFileSink(path)
+	require.NoError(t, err)
+
+	evts := []Event{{Type: EventStarted, ClientID: "a"}, {Type: EventFailed, ClientID: "a", Message: "oops"}}
+	require.NoError(t, s.Send(context.Background(), evts))
+	require.NoError(t, s.Close())
+
+	// The file is appended to when it's reopened
+	s, err = NewFileSink(path)
+	require.NoError(t, err)
+	require.NoError(t, s.Send(context.Background(), evts[:1]))
+	require.NoError(t, s.Close())
+
+	bz, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(bz)), "\n")
+	require.Len(t, lines, 3)
+	var evt Event
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &evt))
+	require.Equal(t, "oops", evt.Message)
+}
+
+func TestWebhookSink(t *testing.T) {
+	var lk sync.Mutex
+	var received []Event
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		lk.Lock()
+		defer lk.Unlock()
+		signature = r.Header.Get(webhooks.SignatureHeader)
+		require.Equal(t, webhooks.Sign("secret", body), signature)
+
+		var payload WebhookPayload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		received = append(received, payload.Events...)
+	}))
+	defer srv.Close()
+
+	s := NewWebhookSink(srv.URL, "secret")
+	require.NoError(t, s.Send(context.Background(), []Event{{Type: EventCompleted, BytesSent: 100}}))
+
+	lk.Lock()
+	require.Len(t, received, 1)
+	require.EqualValues(t, 100, received[0].BytesSent)
+	require.NotEmpty(t, signature)
+	lk.Unlock()
+
+	// An error response is a failure to send
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	err := NewWebhookSink(failing.URL, "").Send(context.Background(), []Event{{Type: EventCompleted}})
+	require.ErrorContains(t, err, "status 503")
+}
+
+func TestNATSSink(t *testing.T) {
+	srv := newFakeNATSServer(t, "user", "pass")
+	defer srv.close()
+
+	_, err := NewNATSSink("http://"+srv.addr(), "boost.retrievals")
+	require.Error(t, err)
+	_, err = NewNATSSink("nats://"+srv.addr(), "bad subject")
+	require.Error(t, err)
+
+	// Connecting with the wrong credentials fails
+	s, err := NewNATSSink("nats://user:wrong@"+srv.addr(), "boost.retrievals")
+	require.NoError(t, err)
+	err = s.Send(context.Background(), []Event{{Type: EventStarted}})
+	require.ErrorContains(t, err, "Authorization Violation")
+
+	s, err = NewNATSSink("nats://user:pass@"+srv.addr(), "boost.retrievals")
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.Send(context.Background(), []Event{{Type: EventStarted, ClientID: "a"}, {Type: EventCompleted, ClientID: "a"}}))
+
+	// Send returns once the server has processed the messages
+	msgs := srv.messages()
+	require.Len(t, msgs, 2)
+	require.Equal(t, "boost.retrievals", msgs[0].subject)
+	var evt Event
+	require.NoError(t, json.Unmarshal(msgs[1].data, &evt))
+	require.Equal(t, EventCompleted, evt.Type)
+
+	// An error from the server fails the publish, so that it can be retried
+	err = s.Publish(context.Background(), srv.denied, [][]byte{[]byte("{}")})
+	require.ErrorContains(t, err, "Permissions Violation")
+	require.NoError(t, s.Send(context.Background(), []Event{{Type: EventStarted, ClientID: "b"}}))
+	require.Len(t, srv.messages(), 3)
+
+	// If the server drops the connection, the sink reconnects
+	srv.dropConnections()
+	require.Eventually(t, func() bool {
+		return s.Send(context.Background(), []Event{{Type: EventFailed}}) == nil && len(srv.messages()) >= 4
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+type memSink struct {
+	lk   sync.Mutex
+	evts []Event
+}
+
+func (s *memSink) Send(_ context.Context, evts []Event) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.evts = append(s.evts, evts...)
+	return nil
+}
+
+func (s *memSink) Close() error {
+	return nil
+}
+
+func (s *memSink) events() []Event {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return append([]Event{}, s.evts...)
+}
+
+type natsMsg struct {
+	subject string
+	data    []byte
+}
+
+// fakeNATSServer implements enough of the NATS server protocol to accept
+// published messages. Publishing to the denied subject is rejected with an
+// error.
+type fakeNATSServer struct {
+	t          *testing.T
+	l          net.Listener
+	user, pass string
+	denied     string
+
+	lk    sync.Mutex
+	msgs  []natsMsg
+	conns []net.Conn
+}
+
+func newFakeNATSServer(t *testing.T, user, pass string) *fakeNATSServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &fakeNATSServer{t: t, l: l, user: user, pass: pass, denied: "boost.denied"}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.lk.Lock()
+			s.conns = append(s.conns, conn)
+			s.lk.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeNATSServer) addr() string {
+	return s.l.Addr().String()
+}
+
+func (s *fakeNATSServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\",\"auth_required\":true}\r\n")
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "CONNECT "):
+			var cm natsConnect
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "CONNECT ")), &cm); err != nil || cm.User != s.user || cm.Pass != s.pass {
+				fmt.Fprintf(conn, "-ERR 'Authorization Violation'\r\n")
+				return
+			}
+		case line == "PING":
+			fmt.Fprintf(conn, "PONG\r\n")
+		case strings.HasPrefix(line, "PUB "):
+			parts := strings.Fields(line)
+			size, err := strconv.Atoi(parts[2])
+			if err != nil {
+				return
+			}
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return
+			}
+			if parts[1] == s.denied {
+				fmt.Fprintf(conn, "-ERR 'Permissions Violation for Publish to %s'\r\n", parts[1])
+				continue
+			}
+			s.lk.Lock()
+			s.msgs = append(s.msgs, natsMsg{subject: parts[1], data: data[:size]})
+			s.lk.Unlock()
+		}
+	}
+}
+
+func (s *fakeNATSServer) messages() []natsMsg {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return append([]natsMsg{}, s.msgs...)
+}
+
+func (s *fakeNATSServer) waitForMessages(t *testing.T, count int) []natsMsg {
+	require.Eventually(t, func() bool { return len(s.messages()) >= count }, 5*time.Second, 10*time.Millisecond)
+	return s.messages()
+}
+
+func (s *fakeNATSServer) dropConnections() {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+func (s *fakeNATSServer) close() {
+	s.l.Close()
+	s.dropConnections()
+}
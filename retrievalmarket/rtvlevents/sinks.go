@@ -0,0 +1,119 @@
+package rtvlevents
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/webhooks"
+)
+
+// FileSink appends events to a file, one JSON encoded event per line
+type FileSink struct {
+	path string
+
+	lk sync.Mutex
+	f  *os.File
+	w  *bufio.Writer
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening retrieval events file %s: %w", path, err)
+	}
+	return &FileSink{path: path, f: f, w: bufio.NewWriter(f)}, nil
+}
+
+func (s *FileSink) String() string {
+	return "file " + s.path
+}
+
+func (s *FileSink) Send(_ context.Context, evts []Event) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	enc := json.NewEncoder(s.w)
+	for _, evt := range evts {
+		if err := enc.Encode(evt); err != nil {
+			return fmt.Errorf("writing retrieval event to %s: %w", s.path, err)
+		}
+	}
+	if err := s.w.Flush(); err != nil {
+		return fmt.Errorf("writing retrieval events to %s: %w", s.path, err)
+	}
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	return s.f.Close()
+}
+
+// WebhookPayload is the body of a request to the retrieval events webhook
+type WebhookPayload struct {
+	Events []Event
+}
+
+// WebhookSink POSTs batches of events to a URL as JSON. If there is a
+// secret, the request is signed with it in the webhooks.SignatureHeader
+// header, in the same way as the audit event webhooks.
+type WebhookSink struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewWebhookSink(url string, secret string) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *WebhookSink) String() string {
+	return "webhook " + s.url
+}
+
+func (s *WebhookSink) Send(ctx context.Context, evts []Event) error {
+	body, err := json.Marshal(WebhookPayload{Events: evts})
+	if err != nil {
+		return fmt.Errorf("marshalling retrieval events: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request for %s: %w", s.url, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.secret != "" {
+		req.Header.Set(webhooks.SignatureHeader, webhooks.Sign(s.secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting retrieval events to %s: %w", s.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("posting retrieval events to %s: status %d: %s", s.url, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (s *WebhookSink) Close() error {
+	return nil
+}
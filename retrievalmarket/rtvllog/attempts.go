@@ -0,0 +1,293 @@
+package rtvllog
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/boost/retrievalmarket/rtvlevents"
+	"github.com/ipfs/go-cid"
+)
+
+// The protocols over which retrievals are served
+const (
+	ProtocolGraphsync = "graphsync"
+	ProtocolHTTP      = "http"
+	ProtocolBitswap   = "bitswap"
+)
+
+// The results of a retrieval attempt
+const (
+	// The data was sent to the client
+	ResultSuccess = "success"
+	// The retrieval was refused (eg by the retrieval policy, a filter or
+	// because the client didn't pay)
+	ResultRejected = "rejected"
+	// There was an error serving the retrieval
+	ResultFailure = "failure"
+)
+
+// RetrievalAttempt is a record of an attempt by a client to retrieve data,
+// over any protocol
+type RetrievalAttempt struct {
+	// The database row id (set when the attempt is read from the database)
+	ID uint64
+	// The time at which the retrieval started
+	CreatedAt time.Time
+	Protocol  string
+	// The peer ID of the client for graphsync and bitswap retrievals, or the
+	// auth token id or IP address of the client for HTTP retrievals
+	ClientID string
+	// The root CID of the data requested (undefined if the client requested
+	// a whole piece)
+	PayloadCID cid.Cid
+	PieceCID   *cid.Cid
+	BytesSent  uint64
+	Duration   time.Duration
+	Result     string
+	Message    string
+}
+
+// AttemptEvent returns the completed or failed retrieval event for a
+// retrieval attempt
+func AttemptEvent(a *RetrievalAttempt, retrievalID string) rtvlevents.Event {
+	typ := rtvlevents.EventCompleted
+	if a.Result != ResultSuccess {
+		typ = rtvlevents.EventFailed
+	}
+	return rtvlevents.Event{
+		Type:        typ,
+		Protocol:    a.Protocol,
+		RetrievalID: retrievalID,
+		ClientID:    a.ClientID,
+		PayloadCID:  a.PayloadCID,
+		PieceCID:    a.PieceCID,
+		BytesSent:   a.BytesSent,
+		Duration:    a.Duration,
+		Result:      a.Result,
+		Message:     a.Message,
+	}
+}
+
+func (d *RetrievalLogDB) InsertAttempt(ctx context.Context, a *RetrievalAttempt) error {
+	qry := "INSERT INTO RetrievalAttempts (" +
+		"CreatedAt, " +
+		"Protocol, " +
+		"ClientID, " +
+		"PayloadCID, " +
+		"PieceCID, " +
+		"BytesSent, " +
+		"DurationMs, " +
+		"Result, " +
+		"Message" +
+		") "
+	qry += "VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+	createdAt := a.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	payloadCid := ""
+	if a.PayloadCID.Defined() {
+		payloadCid = a.PayloadCID.String()
+	}
+	pieceCid := ""
+	if a.PieceCID != nil {
+		pieceCid = a.PieceCID.String()
+	}
+
+	_, err := d.db.ExecContext(ctx, qry,
+		createdAt,
+		a.Protocol,
+		a.ClientID,
+		payloadCid,
+		pieceCid,
+		a.BytesSent,
+		a.Duration.Milliseconds(),
+		a.Result,
+		a.Message)
+	if err != nil {
+		return err
+	}
+
+	// Count the bytes sent towards the client's usage
+	if a.BytesSent > 0 && a.ClientID != "" {
+		return d.addUsage(ctx, a.ClientID, createdAt, a.BytesSent)
+	}
+	return nil
+}
+
+func (d *RetrievalLogDB) GetAttempt(ctx context.Context, id uint64) (*RetrievalAttempt, error) {
+	rows, err := d.listAttempts(ctx, 0, 0, "RowID = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no retrieval attempt found with id %d", id)
+	}
+	return &rows[0], nil
+}
+
+// ListAttempts lists retrieval attempts, most recent first.
+// If protocol is not empty, only attempts over that protocol are listed.
+func (d *RetrievalLogDB) ListAttempts(ctx context.Context, protocol string, cursor *uint64, offset int, limit int) ([]RetrievalAttempt, error) {
+	where, whereArgs := attemptsWhere(protocol)
+	if cursor != nil {
+		if where != "" {
+			where += " AND "
+		}
+		where += "RowID <= ?"
+		whereArgs = append(whereArgs, *cursor)
+	}
+	return d.listAttempts(ctx, offset, limit, where, whereArgs...)
+}
+
+func (d *RetrievalLogDB) CountAttempts(ctx context.Context, protocol string) (int, error) {
+	qry := "SELECT count(*) FROM RetrievalAttempts"
+	where, whereArgs := attemptsWhere(protocol)
+	if where != "" {
+		qry += " WHERE " + where
+	}
+
+	var count int
+	row := d.db.QueryRowContext(ctx, qry, whereArgs...)
+	err := row.Scan(&count)
+	return count, err
+}
+
+func attemptsWhere(protocol string) (string, []interface{}) {
+	if protocol == "" {
+		return "", nil
+	}
+	return "Protocol = ?", []interface{}{protocol}
+}
+
+func (d *RetrievalLogDB) listAttempts(ctx context.Context, offset int, limit int, where string, whereArgs ...interface{}) ([]RetrievalAttempt, error) {
+	qry := "SELECT " +
+		"RowID, " +
+		"CreatedAt, " +
+		"Protocol, " +
+		"ClientID, " +
+		"PayloadCID, " +
+		"PieceCID, " +
+		"BytesSent, " +
+		"DurationMs, " +
+		"Result, " +
+		"Message " +
+		"FROM RetrievalAttempts"
+
+	if where != "" {
+		qry += " WHERE " + where
+	}
+	qry += " ORDER BY RowID desc"
+
+	args := append([]interface{}{}, whereArgs...)
+	if limit > 0 {
+		qry += " LIMIT ?"
+		args = append(args, limit)
+
+		if offset > 0 {
+			qry += " OFFSET ?"
+			args = append(args, offset)
+		}
+	}
+
+	rows, err := d.db.QueryContext(ctx, qry, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := make([]RetrievalAttempt, 0, 16)
+	for rows.Next() {
+		var payloadCid sql.NullString
+		var pieceCid sql.NullString
+		var durationMs int64
+
+		var a RetrievalAttempt
+		err := rows.Scan(
+			&a.ID,
+			&a.CreatedAt,
+			&a.Protocol,
+			&a.ClientID,
+			&payloadCid,
+			&pieceCid,
+			&a.BytesSent,
+			&durationMs,
+			&a.Result,
+			&a.Message,
+		)
+		if err != nil {
+			return nil, err
+		}
+		a.Duration = time.Duration(durationMs) * time.Millisecond
+
+		if payloadCid.String != "" {
+			a.PayloadCID, err = cid.Parse(payloadCid.String)
+			if err != nil {
+				return nil, fmt.Errorf("parsing payload cid '%s': %w", payloadCid.String, err)
+			}
+		}
+		if pieceCid.String != "" {
+			c, err := cid.Parse(pieceCid.String)
+			if err != nil {
+				return nil, fmt.Errorf("parsing piece cid '%s': %w", pieceCid.String, err)
+			}
+			a.PieceCID = &c
+		}
+
+		attempts = append(attempts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attempts, nil
+}
+
+// AttemptStats are the aggregate stats for retrieval attempts over one
+// protocol
+type AttemptStats struct {
+	Protocol      string
+	Attempts      int
+	Successes     int
+	Rejections    int
+	Failures      int
+	BytesSent     uint64
+	TotalDuration time.Duration
+}
+
+// AttemptStats returns the stats for the retrieval attempts made since the
+// given time (or all attempts if since is zero), for each protocol
+func (d *RetrievalLogDB) AttemptStats(ctx context.Context, since time.Time) ([]AttemptStats, error) {
+	qry := "SELECT Protocol, count(*), " +
+		"coalesce(sum(CASE WHEN Result = ? THEN 1 ELSE 0 END), 0), " +
+		"coalesce(sum(CASE WHEN Result = ? THEN 1 ELSE 0 END), 0), " +
+		"coalesce(sum(CASE WHEN Result = ? THEN 1 ELSE 0 END), 0), " +
+		"coalesce(sum(BytesSent), 0), " +
+		"coalesce(sum(DurationMs), 0) " +
+		"FROM RetrievalAttempts " +
+		"WHERE CreatedAt >= ? " +
+		"GROUP BY Protocol " +
+		"ORDER BY Protocol"
+
+	rows, err := d.db.QueryContext(ctx, qry, ResultSuccess, ResultRejected, ResultFailure, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []AttemptStats
+	for rows.Next() {
+		var st AttemptStats
+		var durationMs int64
+		err := rows.Scan(&st.Protocol, &st.Attempts, &st.Successes, &st.Rejections, &st.Failures, &st.BytesSent, &durationMs)
+		if err != nil {
+			return nil, err
+		}
+		st.TotalDuration = time.Duration(durationMs) * time.Millisecond
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
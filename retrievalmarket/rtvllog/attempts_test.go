@@ -0,0 +1,103 @@
+package rtvllog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetrievalAttempts(t *testing.T) {
+	ctx := context.Background()
+	sqldb := db.CreateTestTmpDB(t)
+	require.NoError(t, CreateTables(ctx, sqldb))
+	rdb := NewRetrievalLogDB(sqldb)
+
+	payloadCid := testutil.GenerateCid()
+	pieceCid := testutil.GenerateCid()
+	attempts := []RetrievalAttempt{{
+		Protocol:   ProtocolGraphsync,
+		ClientID:   "peer1",
+		PayloadCID: payloadCid,
+		PieceCID:   &pieceCid,
+		BytesSent:  100,
+		Duration:   2 * time.Second,
+		Result:     ResultSuccess,
+	}, {
+		Protocol:  ProtocolHTTP,
+		ClientID:  "token1",
+		PieceCID:  &pieceCid,
+		BytesSent: 200,
+		Duration:  time.Second,
+		Result:    ResultSuccess,
+	}, {
+		Protocol: ProtocolHTTP,
+		ClientID: "1.2.3.4",
+		Result:   ResultRejected,
+		Message:  "Forbidden",
+	}, {
+		Protocol:   ProtocolBitswap,
+		ClientID:   "peer2",
+		PayloadCID: payloadCid,
+		Result:     ResultFailure,
+		Message:    "stream reset",
+	}}
+	for i := range attempts {
+		require.NoError(t, rdb.InsertAttempt(ctx, &attempts[i]))
+	}
+
+	// List all attempts, most recent first
+	list, err := rdb.ListAttempts(ctx, "", nil, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, list, 4)
+	require.Equal(t, ProtocolBitswap, list[0].Protocol)
+	require.Equal(t, payloadCid, list[0].PayloadCID)
+	require.Nil(t, list[0].PieceCID)
+	require.Equal(t, ProtocolGraphsync, list[3].Protocol)
+	require.Equal(t, pieceCid, *list[3].PieceCID)
+	require.Equal(t, 2*time.Second, list[3].Duration)
+
+	// List the attempts over one protocol
+	list, err = rdb.ListAttempts(ctx, ProtocolHTTP, nil, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.False(t, list[1].PayloadCID.Defined())
+	count, err := rdb.CountAttempts(ctx, ProtocolHTTP)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	// Get an attempt by id
+	a, err := rdb.GetAttempt(ctx, list[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, "1.2.3.4", a.ClientID)
+	require.Equal(t, "Forbidden", a.Message)
+
+	// Get the stats for each protocol
+	stats, err := rdb.AttemptStats(ctx, time.Time{})
+	require.NoError(t, err)
+	require.Equal(t, []AttemptStats{{
+		Protocol: ProtocolBitswap,
+		Attempts: 1,
+		Failures: 1,
+	}, {
+		Protocol:      ProtocolGraphsync,
+		Attempts:      1,
+		Successes:     1,
+		BytesSent:     100,
+		TotalDuration: 2 * time.Second,
+	}, {
+		Protocol:      ProtocolHTTP,
+		Attempts:      2,
+		Successes:     1,
+		Rejections:    1,
+		BytesSent:     200,
+		TotalDuration: time.Second,
+	}}, stats)
+
+	stats, err = rdb.AttemptStats(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, stats)
+}
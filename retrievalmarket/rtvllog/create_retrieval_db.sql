@@ -43,3 +43,27 @@ CREATE TABLE IF NOT EXISTS RetrievalMarketEvents (
 
 CREATE INDEX IF NOT EXISTS index_retrieval_market_evts_created_at on RetrievalMarketEvents(CreatedAt);
 CREATE INDEX IF NOT EXISTS index_retrieval_market_evts_peer_deal_id on RetrievalMarketEvents(PeerID, DealID);
+
+CREATE TABLE IF NOT EXISTS RetrievalAttempts (
+    CreatedAt DateTime,
+    Protocol TEXT,
+    ClientID TEXT,
+    PayloadCID TEXT,
+    PieceCID TEXT,
+    BytesSent INT,
+    DurationMs INT,
+    Result TEXT,
+    Message TEXT
+);
+
+CREATE INDEX IF NOT EXISTS index_retrieval_attempts_created_at on RetrievalAttempts(CreatedAt);
+CREATE INDEX IF NOT EXISTS index_retrieval_attempts_protocol on RetrievalAttempts(Protocol);
+
+CREATE TABLE IF NOT EXISTS RetrievalClientUsage (
+    ClientID TEXT,
+    Day TEXT,
+    BytesSent INT,
+    PRIMARY KEY (ClientID, Day)
+);
+
+CREATE INDEX IF NOT EXISTS index_retrieval_client_usage_day on RetrievalClientUsage(Day);
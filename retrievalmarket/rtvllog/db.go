@@ -114,6 +114,18 @@ func (d *RetrievalLogDB) Get(ctx context.Context, peerID string, transferID uint
 	return &rows[0], nil
 }
 
+// GetByDeal gets the most recent state of the retrieval deal with the peer
+func (d *RetrievalLogDB) GetByDeal(ctx context.Context, peerID peer.ID, dealID retrievalmarket.DealID) (*RetrievalDealState, error) {
+	rows, err := d.list(ctx, 0, 1, "PeerID = ? AND DealID = ?", peerID.String(), dealID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no retrieval found with peer ID %s and deal ID %d", peerID, dealID)
+	}
+	return &rows[0], nil
+}
+
 func (d *RetrievalLogDB) List(ctx context.Context, cursor *uint64, offset int, limit int) ([]RetrievalDealState, error) {
 	where := ""
 	whereArgs := []interface{}{}
@@ -426,6 +438,11 @@ func (d *RetrievalLogDB) DeleteOlderThan(ctx context.Context, at time.Time) (int
 		return 0, err
 	}
 
+	_, err = d.db.ExecContext(ctx, "DELETE FROM RetrievalAttempts WHERE CreatedAt < ?", at)
+	if err != nil {
+		return 0, err
+	}
+
 	res, err := d.db.ExecContext(ctx, "DELETE FROM RetrievalDealStates WHERE CreatedAt < ?", at)
 	if err != nil {
 		return 0, err
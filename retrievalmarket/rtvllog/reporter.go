@@ -0,0 +1,90 @@
+package rtvllog
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// The maximum number of retrieval attempts that are kept by a Reporter
+// while they can't be submitted. Attempts beyond the limit are dropped.
+const maxPendingAttempts = 10_000
+
+// Submitter sends retrieval attempts to boostd, eg over the boost API
+type Submitter func(ctx context.Context, attempts []RetrievalAttempt) error
+
+// Reporter collects the retrieval attempts served by a process other than
+// boostd (eg booster-http or booster-bitswap), and periodically submits
+// them to boostd so that they are recorded in the retrievals DB
+type Reporter struct {
+	submit Submitter
+
+	lk      sync.Mutex
+	pending []RetrievalAttempt
+	dropped int
+}
+
+func NewReporter(submit Submitter) *Reporter {
+	return &Reporter{submit: submit}
+}
+
+// Record adds a retrieval attempt to the attempts to be submitted
+func (r *Reporter) Record(a RetrievalAttempt) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if len(r.pending) >= maxPendingAttempts {
+		r.dropped++
+		return
+	}
+	r.pending = append(r.pending, a)
+}
+
+// Run submits the pending retrieval attempts at each interval until the
+// context is cancelled
+func (r *Reporter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Submit any remaining attempts before exiting
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			r.flush(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			r.flush(ctx)
+		}
+	}
+}
+
+func (r *Reporter) flush(ctx context.Context) {
+	r.lk.Lock()
+	attempts := r.pending
+	dropped := r.dropped
+	r.pending = nil
+	r.dropped = 0
+	r.lk.Unlock()
+
+	if dropped > 0 {
+		log.Warnw("dropped retrieval attempts because they could not be submitted", "count", dropped)
+	}
+	if len(attempts) == 0 {
+		return
+	}
+
+	if err := r.submit(ctx, attempts); err != nil {
+		log.Warnw("submitting retrieval attempts", "count", len(attempts), "err", err)
+
+		// Keep the attempts so that they are submitted with the next batch
+		r.lk.Lock()
+		r.pending = append(attempts, r.pending...)
+		if len(r.pending) > maxPendingAttempts {
+			r.dropped += len(r.pending) - maxPendingAttempts
+			r.pending = r.pending[:maxPendingAttempts]
+		}
+		r.lk.Unlock()
+	}
+}
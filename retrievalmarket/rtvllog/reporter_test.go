@@ -0,0 +1,34 @@
+package rtvllog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReporter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	submitted := make(chan []RetrievalAttempt, 1)
+	r := NewReporter(func(ctx context.Context, attempts []RetrievalAttempt) error {
+		submitted <- attempts
+		return nil
+	})
+	r.Record(RetrievalAttempt{Protocol: ProtocolHTTP, ClientID: "client1"})
+	r.Record(RetrievalAttempt{Protocol: ProtocolHTTP, ClientID: "client2"})
+
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx, time.Hour)
+		close(done)
+	}()
+
+	// The pending attempts are submitted when the reporter is stopped
+	cancel()
+	<-done
+	attempts := <-submitted
+	require.Len(t, attempts, 2)
+	require.Equal(t, "client1", attempts[0].ClientID)
+}
@@ -2,9 +2,11 @@ package rtvllog
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/filecoin-project/boost/retrievalmarket/rtvlevents"
 	"github.com/filecoin-project/boost/retrievalmarket/server"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
@@ -20,6 +22,7 @@ type RetrievalLog struct {
 	dataTransfer   lotus_dtypes.ProviderDataTransfer
 	gsur           *server.GraphsyncUnpaidRetrieval
 	stalledTimeout time.Duration
+	events         *rtvlevents.Publisher
 	ctx            context.Context
 
 	dbUpdates chan func()
@@ -28,7 +31,7 @@ type RetrievalLog struct {
 	lastUpdate   map[string]time.Time
 }
 
-func NewRetrievalLog(db *RetrievalLogDB, duration time.Duration, dt lotus_dtypes.ProviderDataTransfer, stalledTimeout time.Duration, gsur *server.GraphsyncUnpaidRetrieval) *RetrievalLog {
+func NewRetrievalLog(db *RetrievalLogDB, duration time.Duration, dt lotus_dtypes.ProviderDataTransfer, stalledTimeout time.Duration, gsur *server.GraphsyncUnpaidRetrieval, events *rtvlevents.Publisher) *RetrievalLog {
 	if duration < stalledTimeout {
 		log.Warnf("the RetrievalLogDuration (%s) should exceed the StalledRetrievalTimeout (%s)", duration.String(), stalledTimeout.String())
 	}
@@ -39,6 +42,7 @@ func NewRetrievalLog(db *RetrievalLogDB, duration time.Duration, dt lotus_dtypes
 		dataTransfer:   dt,
 		gsur:           gsur,
 		stalledTimeout: stalledTimeout,
+		events:         events,
 		dbUpdates:      make(chan func(), 256),
 		lastUpdate:     make(map[string]time.Time),
 	}
@@ -100,6 +104,20 @@ func (r *RetrievalLog) OnValidationEvent(evt retrievalmarket.ProviderValidationE
 		if err != nil {
 			log.Errorw("failed to update retrieval deal logger db", "err", err)
 		}
+
+		// The request didn't pass validation, so record a rejected attempt
+		a := &RetrievalAttempt{
+			Protocol:   ProtocolGraphsync,
+			ClientID:   evt.Receiver.String(),
+			PayloadCID: evt.BaseCid,
+			PieceCID:   st.PieceCID,
+			Result:     ResultRejected,
+			Message:    st.Message,
+		}
+		if err := r.db.InsertAttempt(r.ctx, a); err != nil {
+			log.Errorw("failed to insert retrieval attempt into retrieval deal logger db", "err", err)
+		}
+		r.events.Publish(AttemptEvent(a, ""))
 	})
 }
 
@@ -157,6 +175,13 @@ func (r *RetrievalLog) OnRetrievalEvent(event retrievalmarket.ProviderEvent, sta
 		log.Debugw("event", "evt", retrievalmarket.ProviderEvents[event], "status", state.Status)
 	}
 
+	switch event {
+	case retrievalmarket.ProviderEventOpen:
+		r.events.Publish(graphsyncEvent(rtvlevents.EventStarted, state))
+	case retrievalmarket.ProviderEventBlockSent:
+		r.events.Publish(graphsyncEvent(rtvlevents.EventBytesDelivered, state))
+	}
+
 	r.dbUpdate(func() {
 		var err error
 		if event == retrievalmarket.ProviderEventOpen {
@@ -173,6 +198,7 @@ func (r *RetrievalLog) OnRetrievalEvent(event retrievalmarket.ProviderEvent, sta
 				Status:                  state.Status.String(),
 			})
 		} else {
+			r.recordAttempt(state)
 			err = r.db.Update(r.ctx, state)
 		}
 
@@ -187,6 +213,72 @@ func (r *RetrievalLog) OnRetrievalEvent(event retrievalmarket.ProviderEvent, sta
 	})
 }
 
+// The result of the retrieval attempt for each final retrieval deal status
+var attemptResults = map[string]string{
+	retrievalmarket.DealStatusCompleted.String(): ResultSuccess,
+	retrievalmarket.DealStatusRejected.String():  ResultRejected,
+	retrievalmarket.DealStatusErrored.String():   ResultFailure,
+	retrievalmarket.DealStatusCancelled.String(): ResultFailure,
+}
+
+// recordAttempt records a retrieval attempt when a retrieval deal reaches a
+// final status. It must be called before the deal state is updated in the
+// database, so that the attempt is only recorded the first time the deal
+// reaches a final status.
+func (r *RetrievalLog) recordAttempt(state retrievalmarket.ProviderDealState) {
+	result, ok := attemptResults[state.Status.String()]
+	if !ok {
+		return
+	}
+
+	prev, err := r.db.GetByDeal(r.ctx, state.Receiver, state.ID)
+	if err != nil {
+		log.Errorw("failed to get retrieval deal state to record retrieval attempt", "err", err)
+		return
+	}
+	if _, done := attemptResults[prev.Status]; done {
+		return
+	}
+
+	a := &RetrievalAttempt{
+		CreatedAt:  prev.CreatedAt,
+		Protocol:   ProtocolGraphsync,
+		ClientID:   state.Receiver.String(),
+		PayloadCID: state.PayloadCID,
+		PieceCID:   state.PieceCID,
+		BytesSent:  state.TotalSent,
+		Duration:   time.Since(prev.CreatedAt),
+		Result:     result,
+		Message:    state.Message,
+	}
+	if err := r.db.InsertAttempt(r.ctx, a); err != nil {
+		log.Errorw("failed to insert retrieval attempt into retrieval deal logger db", "err", err)
+	}
+	r.events.Publish(AttemptEvent(a, graphsyncRetrievalID(state)))
+}
+
+// graphsyncRetrievalID identifies a graphsync retrieval deal in retrieval
+// events
+func graphsyncRetrievalID(state retrievalmarket.ProviderDealState) string {
+	return fmt.Sprintf("%s/%d", state.Receiver, state.ID)
+}
+
+func graphsyncEvent(typ string, state retrievalmarket.ProviderDealState) rtvlevents.Event {
+	evt := rtvlevents.Event{
+		Type:        typ,
+		Protocol:    ProtocolGraphsync,
+		RetrievalID: graphsyncRetrievalID(state),
+		ClientID:    state.Receiver.String(),
+		PayloadCID:  state.PayloadCID,
+		BytesSent:   state.TotalSent,
+	}
+	if state.PieceCID != nil {
+		pieceCid := *state.PieceCID
+		evt.PieceCID = &pieceCid
+	}
+	return evt
+}
+
 // Some events may be very frequent, so limit events to two per second per retrieval deal
 func (r *RetrievalLog) allowUpdate(key string) bool {
 	now := time.Now()
@@ -246,6 +338,13 @@ func (r *RetrievalLog) gcDatabase(ctx context.Context) {
 			} else if count > 0 {
 				log.Infof("Deleted %d retrieval logs older than %s", count, r.duration)
 			}
+
+			// Delete old client usage (usage is only needed for the current
+			// month, for retrieval quotas)
+			_, err = r.db.DeleteClientUsageBefore(ctx, now.AddDate(0, -2, 0))
+			if err != nil {
+				log.Errorw("error trimming retrieval client usage", "err", err)
+			}
 		}
 	}
 }
@@ -0,0 +1,99 @@
+package rtvllog
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const usageDayFormat = "2006-01-02"
+
+// ClientUsage is the number of bytes sent to a client over all protocols
+type ClientUsage struct {
+	ClientID string
+	// The bytes sent since the start of the current (UTC) day
+	BytesToday uint64
+	// The bytes sent since the start of the current (UTC) month
+	BytesThisMonth uint64
+}
+
+// addUsage adds the bytes sent in a retrieval attempt to the client's
+// usage for the day of the attempt
+func (d *RetrievalLogDB) addUsage(ctx context.Context, client string, at time.Time, bytesSent uint64) error {
+	qry := "INSERT INTO RetrievalClientUsage (ClientID, Day, BytesSent) VALUES (?, ?, ?) " +
+		"ON CONFLICT(ClientID, Day) DO UPDATE SET BytesSent = BytesSent + excluded.BytesSent"
+	_, err := d.db.ExecContext(ctx, qry, client, at.UTC().Format(usageDayFormat), bytesSent)
+	if err != nil {
+		return fmt.Errorf("adding retrieval usage for client %s: %w", client, err)
+	}
+	return nil
+}
+
+// GetClientUsage returns the number of bytes sent to the client today
+// and this month
+func (d *RetrievalLogDB) GetClientUsage(ctx context.Context, client string, now time.Time) (*ClientUsage, error) {
+	rows, err := d.listClientUsage(ctx, "AND ClientID = ? ", now, []interface{}{client}, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return &ClientUsage{ClientID: client}, nil
+	}
+	return &rows[0], nil
+}
+
+// ListClientUsage returns the number of bytes sent to each client today and
+// this month, for the clients that have been sent the most data this month
+func (d *RetrievalLogDB) ListClientUsage(ctx context.Context, now time.Time, offset int, limit int) ([]ClientUsage, error) {
+	return d.listClientUsage(ctx, "", now, nil, offset, limit)
+}
+
+func (d *RetrievalLogDB) listClientUsage(ctx context.Context, where string, now time.Time, whereArgs []interface{}, offset int, limit int) ([]ClientUsage, error) {
+	now = now.UTC()
+	today := now.Format(usageDayFormat)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).Format(usageDayFormat)
+
+	qry := "SELECT ClientID, " +
+		"coalesce(sum(CASE WHEN Day = ? THEN BytesSent ELSE 0 END), 0), " +
+		"coalesce(sum(BytesSent), 0) " +
+		"FROM RetrievalClientUsage " +
+		"WHERE Day >= ? " + where +
+		"GROUP BY ClientID " +
+		"ORDER BY sum(BytesSent) DESC, ClientID"
+	args := append([]interface{}{today, monthStart}, whereArgs...)
+	if limit > 0 {
+		qry += " LIMIT ?"
+		args = append(args, limit)
+
+		if offset > 0 {
+			qry += " OFFSET ?"
+			args = append(args, offset)
+		}
+	}
+
+	rows, err := d.db.QueryContext(ctx, qry, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []ClientUsage
+	for rows.Next() {
+		var u ClientUsage
+		if err := rows.Scan(&u.ClientID, &u.BytesToday, &u.BytesThisMonth); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// DeleteClientUsageBefore deletes the usage records for days before the
+// given time
+func (d *RetrievalLogDB) DeleteClientUsageBefore(ctx context.Context, at time.Time) (int64, error) {
+	res, err := d.db.ExecContext(ctx, "DELETE FROM RetrievalClientUsage WHERE Day < ?", at.UTC().Format(usageDayFormat))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
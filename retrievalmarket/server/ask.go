@@ -0,0 +1,71 @@
+package server
+
+import (
+	"sort"
+
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// FreeClientChecker decides whether a client can retrieve for free, even if
+// the retrieval ask price is non-zero
+type FreeClientChecker interface {
+	IsFree(p peer.ID) bool
+}
+
+// RetrievalAsk is the retrieval ask that clients can query before making a
+// retrieval: the ask set on the retrieval provider, except for clients in
+// the free allowlist, who can retrieve for free
+type RetrievalAsk struct {
+	AskGetter
+	free map[peer.ID]struct{}
+}
+
+var _ FreeClientChecker = (*RetrievalAsk)(nil)
+
+func NewRetrievalAsk(askGetter AskGetter, freeClients []peer.ID) *RetrievalAsk {
+	free := make(map[peer.ID]struct{}, len(freeClients))
+	for _, p := range freeClients {
+		free[p] = struct{}{}
+	}
+	return &RetrievalAsk{AskGetter: askGetter, free: free}
+}
+
+// IsFree returns true if the client is in the free allowlist
+func (a *RetrievalAsk) IsFree(p peer.ID) bool {
+	_, ok := a.free[p]
+	return ok
+}
+
+// FreeClients returns the clients in the free allowlist
+func (a *RetrievalAsk) FreeClients() []peer.ID {
+	free := make([]peer.ID, 0, len(a.free))
+	for p := range a.free {
+		free = append(free, p)
+	}
+	sort.Slice(free, func(i, j int) bool { return free[i] < free[j] })
+	return free
+}
+
+// AskFor returns the retrieval ask for the client
+func (a *RetrievalAsk) AskFor(p peer.ID) *retrievalmarket.Ask {
+	return askForClient(a.AskGetter, a, p)
+}
+
+// askForClient returns the ask with zero prices if the client is in the free
+// allowlist. It returns nil if the ask hasn't been set. The retrieval
+// validator and the query handler both use it so that they agree on which
+// retrievals are free.
+func askForClient(askGetter AskGetter, free FreeClientChecker, p peer.ID) *retrievalmarket.Ask {
+	current := askGetter.GetAsk()
+	if current == nil {
+		return nil
+	}
+	ask := *current
+	if free != nil && free.IsFree(p) {
+		ask.PricePerByte = big.Zero()
+		ask.UnsealPrice = big.Zero()
+	}
+	return &ask
+}
@@ -11,11 +11,9 @@ import (
 	"github.com/filecoin-project/go-data-transfer/network"
 	"github.com/filecoin-project/go-data-transfer/registry"
 	"github.com/filecoin-project/go-data-transfer/transport/graphsync/extension"
-	"github.com/filecoin-project/go-fil-markets/piecestore"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	retrievalimpl "github.com/filecoin-project/go-fil-markets/retrievalmarket/impl"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket/migrations"
-	"github.com/filecoin-project/go-fil-markets/stores"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/hannahhoward/go-pubsub"
 	"github.com/ipfs/go-graphsync"
@@ -71,12 +69,25 @@ type AskGetter interface {
 	GetAsk() *retrievalmarket.Ask
 }
 
+// QuotaChecker decides whether a client has exceeded its retrieval quota
+type QuotaChecker interface {
+	Exceeded(ctx context.Context, client string) (bool, error)
+}
+
 type ValidationDeps struct {
 	DealDecider    retrievalimpl.DealDecider
-	DagStore       stores.DAGStoreWrapper
-	PieceStore     piecestore.PieceStore
+	PieceDirectory PieceDirectory
 	SectorAccessor retrievalmarket.SectorAccessor
 	AskStore       AskGetter
+	// If FreeClients is nil, clients can only make unpaid retrievals if the
+	// ask price is zero
+	FreeClients FreeClientChecker
+	// If UnsealOnDemand is true, unpaid retrievals of pieces with no
+	// unsealed copy are served (the piece is unsealed when the data is read)
+	// if the unseal price is zero
+	UnsealOnDemand bool
+	// If Quotas is nil, clients have no retrieval quota
+	Quotas QuotaChecker
 }
 
 func NewGraphsyncUnpaidRetrieval(peerID peer.ID, gs graphsync.GraphExchange, dtnet network.DataTransferNetwork, vdeps ValidationDeps) (*GraphsyncUnpaidRetrieval, error) {
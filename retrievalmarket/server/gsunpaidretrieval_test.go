@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -35,6 +36,8 @@ import (
 	carv2 "github.com/ipld/go-car/v2"
 	"github.com/ipld/go-car/v2/blockstore"
 	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
 	"github.com/stretchr/testify/require"
 )
 
@@ -45,11 +48,18 @@ type testCase struct {
 	reqPayloadCid             cid.Cid
 	watch                     func(client retrievalmarket.RetrievalClient, gsupr *GraphsyncUnpaidRetrieval)
 	ask                       *retrievalmarket.Ask
+	freeClient                bool
 	noUnsealedCopy            bool
+	unsealOnDemand            bool
+	pricePerByte              int64
+	quotaExceeded             bool
 	expectErr                 bool
 	expectClientCancelEvent   bool
 	expectProviderCancelEvent bool
 	expectRejection           string
+	// The request is expected to be passed through to the legacy
+	// retrieval provider
+	expectLegacy bool
 }
 
 var providerCancelled = errors.New("provider cancelled")
@@ -83,6 +93,13 @@ func TestGS(t *testing.T) {
 		},
 		expectErr:       true,
 		expectRejection: "ask price is non-zero",
+	}, {
+		name: "request from a client in the free allowlist for non-zero price per byte",
+		ask: &retrievalmarket.Ask{
+			UnsealPrice:  abi.NewTokenAmount(0),
+			PricePerByte: abi.NewTokenAmount(1),
+		},
+		freeClient: true,
 	}, {
 		// Note: we disregard the unseal price because we only serve deals
 		// with an unsealed piece, so the unseal price is irrelevant.
@@ -92,6 +109,43 @@ func TestGS(t *testing.T) {
 			UnsealPrice:  abi.NewTokenAmount(1),
 			PricePerByte: abi.NewTokenAmount(0),
 		},
+	}, {
+		name:           "unseal on demand: request for piece with no unsealed sectors and zero unseal price",
+		unsealOnDemand: true,
+		noUnsealedCopy: true,
+	}, {
+		name:           "unseal on demand: request for piece with no unsealed sectors and non-zero unseal price",
+		unsealOnDemand: true,
+		noUnsealedCopy: true,
+		ask: &retrievalmarket.Ask{
+			UnsealPrice:  abi.NewTokenAmount(1),
+			PricePerByte: abi.NewTokenAmount(0),
+		},
+		expectErr:       true,
+		expectRejection: "unseal price is non-zero",
+	}, {
+		name:           "unseal on demand: request from a client in the free allowlist for piece with no unsealed sectors",
+		unsealOnDemand: true,
+		noUnsealedCopy: true,
+		freeClient:     true,
+		ask: &retrievalmarket.Ask{
+			UnsealPrice:  abi.NewTokenAmount(1),
+			PricePerByte: abi.NewTokenAmount(1),
+		},
+	}, {
+		name:           "unseal on demand: request for paid retrieval is passed to the legacy provider",
+		unsealOnDemand: true,
+		ask: &retrievalmarket.Ask{
+			UnsealPrice:  abi.NewTokenAmount(0),
+			PricePerByte: abi.NewTokenAmount(1),
+		},
+		pricePerByte: 1,
+		expectLegacy: true,
+	}, {
+		name:            "request from a client that has exceeded its quota",
+		quotaExceeded:   true,
+		expectErr:       true,
+		expectRejection: "exceeded its retrieval quota",
 	}, {
 		name: "cancel request after sending 2 blocks",
 		watch: func(client retrievalmarket.RetrievalClient, gsupr *GraphsyncUnpaidRetrieval) {
@@ -193,11 +247,16 @@ func runRequestTest(t *testing.T, tc testCase) {
 	pieceStore := tut.NewTestPieceStore()
 	sectorAccessor.ExpectUnseal(sectorID, offset.Unpadded(), abi.UnpaddedPieceSize(len(carData)), carData)
 	dagstoreWrapper := tut.NewMockDagStoreWrapper(pieceStore, sectorAccessor)
+	pieceDirectory := newTestPieceDirectory()
 	vdeps := ValidationDeps{
-		DagStore:       dagstoreWrapper,
-		PieceStore:     pieceStore,
+		PieceDirectory: pieceDirectory,
 		SectorAccessor: sectorAccessor,
 		AskStore:       askStore,
+		UnsealOnDemand: tc.unsealOnDemand,
+		Quotas:         &testQuotas{exceeded: tc.quotaExceeded},
+	}
+	if tc.freeClient {
+		vdeps.FreeClients = NewRetrievalAsk(askStore, []peer.ID{testData.Host1.ID()})
 	}
 
 	expectedPiece := pieceInfo.PieceCID
@@ -210,6 +269,7 @@ func runRequestTest(t *testing.T, tc testCase) {
 		},
 	})
 	dagstoreWrapper.AddBlockToPieceIndex(carRootCid, expectedPiece)
+	pieceDirectory.add(carRootCid, pieceInfo)
 
 	// Create a blockstore over the CAR file blocks
 	carDataBuff := bytes.NewReader(carData)
@@ -246,6 +306,16 @@ func runRequestTest(t *testing.T, tc testCase) {
 		tc.watch(client, gsupr)
 	}
 
+	// Watch for the legacy provider receiving the request
+	legacyChan := make(chan struct{}, 1)
+	provider.SubscribeToEvents(func(event retrievalmarket.ProviderEvent, state retrievalmarket.ProviderDealState) {
+		tlog.Debugf("legacy prov mkt: %s %s %s", retrievalmarket.ProviderEvents[event], state.Status.String(), state.Message)
+		select {
+		case legacyChan <- struct{}{}:
+		default:
+		}
+	})
+
 	// Watch for provider completion
 	providerResChan := make(chan error)
 	gsupr.SubscribeToMarketsEvents(func(event retrievalmarket.ProviderEvent, state retrievalmarket.ProviderDealState) {
@@ -279,7 +349,7 @@ func runRequestTest(t *testing.T, tc testCase) {
 	// Retrieve the data
 	tlog.Infof("Retrieve cid %s from peer %s", carRootCid, retrievalPeer.ID)
 	sel := selectorparse.CommonSelector_ExploreAllRecursively
-	params, err := retrievalmarket.NewParamsV1(abi.NewTokenAmount(0), 0, 0, sel, nil, abi.NewTokenAmount(0))
+	params, err := retrievalmarket.NewParamsV1(abi.NewTokenAmount(tc.pricePerByte), 0, 0, sel, nil, abi.NewTokenAmount(0))
 	require.NoError(t, err)
 	if tc.reqPayloadCid != cid.Undef {
 		carRootCid = tc.reqPayloadCid
@@ -287,6 +357,17 @@ func runRequestTest(t *testing.T, tc testCase) {
 	_, err = client.Retrieve(ctx, 1, carRootCid, params, abi.NewTokenAmount(0), retrievalPeer, address.TestAddress, address.TestAddress2)
 	require.NoError(t, err)
 
+	if tc.expectLegacy {
+		select {
+		case <-legacyChan:
+		case err := <-providerResChan:
+			require.Fail(t, "expected request to be passed to the legacy provider", "unpaid retrieval provider completed with %v", err)
+		case <-ctx.Done():
+			require.Fail(t, "timed out waiting for the legacy provider to receive the request")
+		}
+		return
+	}
+
 	// Wait for provider completion
 	err = waitFor(ctx, t, providerResChan)
 	if tc.expectErr || tc.expectProviderCancelEvent {
@@ -312,6 +393,55 @@ func runRequestTest(t *testing.T, tc testCase) {
 	}
 }
 
+type testQuotas struct {
+	exceeded bool
+}
+
+func (q *testQuotas) Exceeded(ctx context.Context, client string) (bool, error) {
+	return q.exceeded, nil
+}
+
+// testPieceDirectory is a piece directory over an in-memory index
+type testPieceDirectory struct {
+	lk     sync.Mutex
+	index  map[string][]cid.Cid
+	pieces map[cid.Cid]piecestore.PieceInfo
+}
+
+var _ PieceDirectory = (*testPieceDirectory)(nil)
+
+func newTestPieceDirectory() *testPieceDirectory {
+	return &testPieceDirectory{
+		index:  make(map[string][]cid.Cid),
+		pieces: make(map[cid.Cid]piecestore.PieceInfo),
+	}
+}
+
+// add records that the piece contains the block
+func (d *testPieceDirectory) add(blockCid cid.Cid, pieceInfo piecestore.PieceInfo) {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+	key := string(blockCid.Hash())
+	d.index[key] = append(d.index[key], pieceInfo.PieceCID)
+	d.pieces[pieceInfo.PieceCID] = pieceInfo
+}
+
+func (d *testPieceDirectory) PiecesContainingMultihash(ctx context.Context, m multihash.Multihash) ([]cid.Cid, error) {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+	return d.index[string(m)], nil
+}
+
+func (d *testPieceDirectory) GetPieceInfo(ctx context.Context, pieceCid cid.Cid) (*piecestore.PieceInfo, error) {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+	pi, ok := d.pieces[pieceCid]
+	if !ok {
+		return nil, retrievalmarket.ErrNotFound
+	}
+	return &pi, nil
+}
+
 func createRetrievalProvider(ctx context.Context, t *testing.T, testData *tut.Libp2pTestData, pieceStore *tut.TestPieceStore, sectorAccessor *testnodes.TestSectorAccessor, dagstoreWrapper *tut.MockDagStoreWrapper, gs graphsync.GraphExchange, paymentAddress address.Address) retrievalmarket.RetrievalProvider {
 	nw2 := rmnet.NewFromLibp2pHost(testData.Host2, rmnet.RetryParameters(0, 0, 0, 0))
 	dtTransport2 := dtgstransport.NewTransport(testData.Host2.ID(), gs)
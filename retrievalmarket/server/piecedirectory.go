@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+)
+
+// PieceDirectory finds the pieces that contain a block, and the deals for
+// each piece. It's the same lookup that booster-http and booster-bitswap
+// make through the boost API (BoostDagstorePiecesContainingMultihash and
+// PiecesGetPieceInfo).
+type PieceDirectory interface {
+	// PiecesContainingMultihash returns the pieces that contain the block
+	// with the given multihash, or an empty list if there are none
+	PiecesContainingMultihash(ctx context.Context, m multihash.Multihash) ([]cid.Cid, error)
+	// GetPieceInfo returns the deals for the piece
+	GetPieceInfo(ctx context.Context, pieceCid cid.Cid) (*piecestore.PieceInfo, error)
+}
+
+// GetPieceInfosForPayload returns all of the pieces in the piece directory
+// that contain the payload CID.
+// If the payload CID is an identity CID, it returns the pieces that contain
+// all of the links within the identity CID.
+// Note that it is possible to receive a non-nil error as well as a non-zero
+// length PieceInfo slice: in that case there was at least one error getting
+// the deals for a piece.
+func GetPieceInfosForPayload(ctx context.Context, pd PieceDirectory, payloadCID cid.Cid) ([]piecestore.PieceInfo, error) {
+	piecesContaining := func(c cid.Cid) ([]cid.Cid, error) {
+		return pd.PiecesContainingMultihash(ctx, c.Hash())
+	}
+
+	piecesWithTargetBlock, err := piecesContaining(payloadCID)
+	if err == nil && len(piecesWithTargetBlock) == 0 {
+		err = fmt.Errorf("no pieces contain cid %s: %w", payloadCID, retrievalmarket.ErrNotFound)
+	}
+	if err != nil {
+		// this payloadCID may be an identity CID that's in the root of a CAR but
+		// not recorded in the index
+		var idErr error
+		piecesWithTargetBlock, idErr = GetCommonPiecesFromIdentityCidLinks(piecesContaining, payloadCID)
+		if idErr != nil {
+			return []piecestore.PieceInfo{}, idErr
+		}
+		if len(piecesWithTargetBlock) == 0 {
+			return []piecestore.PieceInfo{}, fmt.Errorf("getting pieces for cid %s: %w", payloadCID, err)
+		}
+	}
+
+	pieces := make([]piecestore.PieceInfo, 0, len(piecesWithTargetBlock))
+	var lastErr error
+	for _, pieceCid := range piecesWithTargetBlock {
+		// Get the deals for the piece
+		pieceInfo, err := pd.GetPieceInfo(ctx, pieceCid)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		pieces = append(pieces, *pieceInfo)
+	}
+
+	return pieces, lastErr
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"github.com/filecoin-project/go-fil-markets/piecestore"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
-	"github.com/filecoin-project/go-fil-markets/stores"
 	"github.com/ipfs/go-cid"
 	"github.com/ipld/go-ipld-prime"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
@@ -26,43 +25,6 @@ const MaxIdentityCIDBytes = 2 << 10
 // identity CID that we are willing to check for matching pieces
 const MaxIdentityCIDLinks = 32
 
-// GetAllPieceInfoForPayload returns all of the pieces containing the requested Payload CID.
-// If the Payload CID is an identity CID, then we use getCommonPiecesFromIdentityCidLinks to find
-// pieces containing all of the links within that identity CID.
-// Note that it is possible to receive a non-nil error as well as a non-zero length PieceInfo slice
-// as a return from this function. In that case, there was at least one error encountered querying
-// the piece store.
-func GetAllPieceInfoForPayload(dagStore stores.DAGStoreWrapper, pieceStore piecestore.PieceStore, payloadCID cid.Cid) ([]piecestore.PieceInfo, error) {
-	// Get all pieces that contain the target block
-	piecesWithTargetBlock, err := dagStore.GetPiecesContainingBlock(payloadCID)
-	if err != nil {
-		// this payloadCID may be an identity CID that's in the root of a CAR but
-		// not recorded in the index
-		var idErr error
-		piecesWithTargetBlock, idErr = GetCommonPiecesFromIdentityCidLinks(dagStore.GetPiecesContainingBlock, payloadCID)
-		if idErr != nil {
-			return []piecestore.PieceInfo{}, idErr
-		}
-		if len(piecesWithTargetBlock) == 0 {
-			return []piecestore.PieceInfo{}, fmt.Errorf("getting pieces for cid %s: %w", payloadCID, err)
-		}
-	}
-
-	pieces := make([]piecestore.PieceInfo, 0)
-	var lastErr error
-	for _, pieceWithTargetBlock := range piecesWithTargetBlock {
-		// Get the deals for the piece
-		pieceInfo, err := pieceStore.GetPieceInfo(pieceWithTargetBlock)
-		if err != nil {
-			lastErr = err
-			continue
-		}
-		pieces = append(pieces, pieceInfo)
-	}
-
-	return pieces, lastErr
-}
-
 // GetCommonPiecesFromIdentityCidLinks will inspect a payloadCID and if it has an identity multihash,
 // will determine which pieces contain all of the links within the decoded identity multihash block
 func GetCommonPiecesFromIdentityCidLinks(piecesWithCid func(c cid.Cid) ([]cid.Cid, error), payloadCID cid.Cid) ([]cid.Cid, error) {
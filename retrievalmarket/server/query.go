@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	rmnet "github.com/filecoin-project/go-fil-markets/retrievalmarket/network"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+)
+
+const queryTimeout = 5 * time.Minute
+
+// PaymentAddressGetter returns the address that clients should send
+// payment to
+type PaymentAddressGetter func(ctx context.Context) (address.Address, error)
+
+// QueryHandler answers retrieval queries over the markets retrieval query
+// protocol, in place of the legacy retrieval provider.
+// It looks up pieces in the piece directory, and applies the same ask and
+// free client allowlist as the graphsync retrieval validator. Unpaid
+// graphsync retrievals are served by boost, and paid retrievals by the
+// legacy retrieval provider.
+type QueryHandler struct {
+	ValidationDeps
+	paymentAddr PaymentAddressGetter
+}
+
+var _ rmnet.RetrievalReceiver = (*QueryHandler)(nil)
+
+func NewQueryHandler(vdeps ValidationDeps, paymentAddr PaymentAddressGetter) *QueryHandler {
+	return &QueryHandler{
+		ValidationDeps: vdeps,
+		paymentAddr:    paymentAddr,
+	}
+}
+
+// HandleQueryStream reads a query from the stream and writes the response
+func (h *QueryHandler) HandleQueryStream(stream rmnet.RetrievalQueryStream) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	defer stream.Close()
+	query, err := stream.ReadQuery()
+	if err != nil {
+		return
+	}
+
+	answer := h.answer(ctx, stream, query)
+	if err := stream.WriteQueryResponse(answer); err != nil {
+		log.Errorw("retrieval query: writing query response", "peer", stream.RemotePeer(), "err", err)
+	}
+}
+
+func (h *QueryHandler) answer(ctx context.Context, stream rmnet.RetrievalQueryStream, query retrievalmarket.Query) retrievalmarket.QueryResponse {
+	answer := retrievalmarket.QueryResponse{
+		Status:          retrievalmarket.QueryResponseUnavailable,
+		PieceCIDFound:   retrievalmarket.QueryItemUnavailable,
+		MinPricePerByte: big.Zero(),
+		UnsealPrice:     big.Zero(),
+	}
+
+	// Get the address the client should send payment to
+	paymentAddress, err := h.paymentAddr(ctx)
+	if err != nil {
+		log.Errorw("retrieval query: looking up payment address", "err", err)
+		answer.Status = retrievalmarket.QueryResponseError
+		answer.Message = fmt.Sprintf("failed to look up payment address: %s", err)
+		return answer
+	}
+	answer.PaymentAddress = paymentAddress
+
+	// Get the piece from which the payload will be retrieved. If the client
+	// specified a piece, use that piece, otherwise prefer a piece that has
+	// an unsealed copy.
+	pieceCID := cid.Undef
+	if query.PieceCID != nil {
+		pieceCID = *query.PieceCID
+	}
+	pieces, piecesErr := GetPieceInfosForPayload(ctx, h.PieceDirectory, query.PayloadCID)
+	pieceInfo, isUnsealed := GetBestPieceInfoMatch(ctx, h.SectorAccessor, pieces, pieceCID)
+	if !pieceInfo.Defined() {
+		if piecesErr != nil && !errors.Is(piecesErr, retrievalmarket.ErrNotFound) {
+			log.Errorw("retrieval query: getting pieces for payload", "payload", query.PayloadCID, "err", piecesErr)
+			answer.Status = retrievalmarket.QueryResponseError
+			answer.Message = fmt.Sprintf("failed to fetch piece to retrieve from: %s", piecesErr)
+			return answer
+		}
+		answer.Message = "piece info for cid not found (deal has not been added to a piece yet)"
+		return answer
+	}
+	answer.PieceCIDFound = retrievalmarket.QueryItemAvailable
+	answer.Size = uint64(pieceInfo.Deals[0].Length.Unpadded())
+
+	// Get the ask for the client (prices are zero for clients in the free
+	// allowlist)
+	ask := askForClient(h.AskStore, h.FreeClients, stream.RemotePeer())
+	if ask == nil {
+		answer.Status = retrievalmarket.QueryResponseError
+		answer.Message = "retrieval ask price is not configured"
+		return answer
+	}
+	answer.MinPricePerByte = ask.PricePerByte
+	answer.MaxPaymentInterval = ask.PaymentInterval
+	answer.MaxPaymentIntervalIncrease = ask.PaymentIntervalIncrease
+
+	// There is no charge for unsealing if there is an unsealed copy
+	if !isUnsealed {
+		answer.UnsealPrice = ask.UnsealPrice
+
+		// A free retrieval of a piece with no unsealed copy can only be
+		// served if the piece is unsealed on demand
+		if ask.UnsealPrice.IsZero() && ask.PricePerByte.IsZero() && !h.UnsealOnDemand {
+			answer.Message = "there is no unsealed copy of the piece"
+			return answer
+		}
+	}
+
+	answer.Status = retrievalmarket.QueryResponseAvailable
+	return answer
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket/impl/askstore"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket/impl/testnodes"
+	rmnet "github.com/filecoin-project/go-fil-markets/retrievalmarket/network"
+	tut "github.com/filecoin-project/go-fil-markets/shared_testutil"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryHandler(t *testing.T) {
+	testCases := []struct {
+		name           string
+		ask            retrievalmarket.Ask
+		freeClient     bool
+		noUnsealedCopy bool
+		unknownPayload bool
+		unsealOnDemand bool
+		expectStatus   retrievalmarket.QueryResponseStatus
+	}{{
+		name:         "free retrieval",
+		expectStatus: retrievalmarket.QueryResponseAvailable,
+	}, {
+		name:           "unknown payload cid",
+		unknownPayload: true,
+		expectStatus:   retrievalmarket.QueryResponseUnavailable,
+	}, {
+		// Paid retrievals are served by the legacy retrieval provider
+		name:         "non-zero price per byte",
+		ask:          retrievalmarket.Ask{PricePerByte: abi.NewTokenAmount(1)},
+		expectStatus: retrievalmarket.QueryResponseAvailable,
+	}, {
+		name:         "non-zero price per byte for client in the free allowlist",
+		ask:          retrievalmarket.Ask{PricePerByte: abi.NewTokenAmount(1)},
+		freeClient:   true,
+		expectStatus: retrievalmarket.QueryResponseAvailable,
+	}, {
+		name:           "no unsealed copy with zero unseal price",
+		noUnsealedCopy: true,
+		unsealOnDemand: true,
+		expectStatus:   retrievalmarket.QueryResponseAvailable,
+	}, {
+		name:           "no unsealed copy with zero unseal price without unseal on demand",
+		noUnsealedCopy: true,
+		expectStatus:   retrievalmarket.QueryResponseUnavailable,
+	}, {
+		// Paid unsealing is served by the legacy retrieval provider
+		name:           "no unsealed copy with non-zero unseal price",
+		ask:            retrievalmarket.Ask{UnsealPrice: abi.NewTokenAmount(1)},
+		noUnsealedCopy: true,
+		expectStatus:   retrievalmarket.QueryResponseAvailable,
+	}, {
+		name:           "no unsealed copy with non-zero unseal price for client in the free allowlist",
+		ask:            retrievalmarket.Ask{UnsealPrice: abi.NewTokenAmount(1)},
+		noUnsealedCopy: true,
+		unsealOnDemand: true,
+		freeClient:     true,
+		expectStatus:   retrievalmarket.QueryResponseAvailable,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			testData := tut.NewLibp2pTestData(ctx, t)
+			sectorID := abi.SectorNumber(1)
+			length := abi.UnpaddedPieceSize(1016)
+			pieceInfo := piecestore.PieceInfo{
+				PieceCID: tut.GenerateCids(1)[0],
+				Deals: []piecestore.DealInfo{{
+					DealID:   abi.DealID(1),
+					SectorID: sectorID,
+					Length:   length.Padded(),
+				}},
+			}
+			payloadCid := tut.GenerateCids(1)[0]
+
+			sectorAccessor := testnodes.NewTestSectorAccessor()
+			if !tc.noUnsealedCopy {
+				sectorAccessor.MarkUnsealed(ctx, sectorID, 0, length)
+			}
+			pieceDirectory := newTestPieceDirectory()
+			pieceDirectory.add(payloadCid, pieceInfo)
+
+			askStore, err := askstore.NewAskStore(namespace.Wrap(testData.Ds2, datastore.NewKey("retrieval-ask")), datastore.NewKey("latest"))
+			require.NoError(t, err)
+			ask := tc.ask
+			if ask.PricePerByte.Nil() {
+				ask.PricePerByte = abi.NewTokenAmount(0)
+			}
+			if ask.UnsealPrice.Nil() {
+				ask.UnsealPrice = abi.NewTokenAmount(0)
+			}
+			require.NoError(t, askStore.SetAsk(&ask))
+			var freeClients []peer.ID
+			if tc.freeClient {
+				freeClients = []peer.ID{testData.Host1.ID()}
+			}
+			vdeps := ValidationDeps{
+				PieceDirectory: pieceDirectory,
+				SectorAccessor: sectorAccessor,
+				AskStore:       askStore,
+				FreeClients:    NewRetrievalAsk(askStore, freeClients),
+				UnsealOnDemand: tc.unsealOnDemand,
+			}
+
+			// Serve queries from host 2
+			paymentAddr := func(ctx context.Context) (address.Address, error) {
+				return address.TestAddress2, nil
+			}
+			qh := NewQueryHandler(vdeps, paymentAddr)
+			nw2 := rmnet.NewFromLibp2pHost(testData.Host2, rmnet.RetryParameters(0, 0, 0, 0))
+			require.NoError(t, nw2.SetDelegate(qh))
+			defer nw2.StopHandlingRequests() //nolint:errcheck
+
+			// Query from host 1
+			nw1 := rmnet.NewFromLibp2pHost(testData.Host1, rmnet.RetryParameters(0, 0, 0, 0))
+			stream, err := nw1.NewQueryStream(testData.Host2.ID())
+			require.NoError(t, err)
+			defer stream.Close()
+
+			queryCid := payloadCid
+			if tc.unknownPayload {
+				queryCid = tut.GenerateCids(1)[0]
+			}
+			require.NoError(t, stream.WriteQuery(retrievalmarket.Query{PayloadCID: queryCid}))
+			resp, err := stream.ReadQueryResponse()
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectStatus, resp.Status, resp.Message)
+			require.Equal(t, address.TestAddress2, resp.PaymentAddress)
+			if tc.unknownPayload {
+				require.Equal(t, retrievalmarket.QueryItemUnavailable, resp.PieceCIDFound)
+				return
+			}
+			require.Equal(t, retrievalmarket.QueryItemAvailable, resp.PieceCIDFound)
+			require.Equal(t, uint64(length), resp.Size)
+			expectPrice := tc.ask.PricePerByte
+			expectUnsealPrice := tc.ask.UnsealPrice
+			if tc.freeClient || expectPrice.Nil() {
+				expectPrice = abi.NewTokenAmount(0)
+			}
+			if tc.freeClient || !tc.noUnsealedCopy || expectUnsealPrice.Nil() {
+				expectUnsealPrice = abi.NewTokenAmount(0)
+			}
+			require.Equal(t, expectPrice.String(), resp.MinPricePerByte.String())
+			require.Equal(t, expectUnsealPrice.String(), resp.UnsealPrice.String())
+		})
+	}
+}
@@ -109,39 +109,57 @@ func (rv *requestValidator) acceptDeal(receiver peer.ID, proposal *retrievalmark
 		return errors.New("incorrect selector for this proposal")
 	}
 
-	// Check if the piece is unsealed
-	_, isUnsealed, err := rv.getPiece(proposal.PayloadCID, proposal.PieceCID)
-	if err != nil {
-		if err == retrievalmarket.ErrNotFound {
-			return fmt.Errorf("there is no piece containing payload cid %s: %w", proposal.PayloadCID, err)
-		}
-		return err
-	}
-	if !isUnsealed {
-		return fmt.Errorf("there is no unsealed piece containing payload cid %s", proposal.PayloadCID)
-	}
-
 	// Check the retrieval ask price
-	ask := rv.AskStore.GetAsk()
+	ask := askForClient(rv.AskStore, rv.FreeClients, receiver)
 	if ask == nil {
 		return fmt.Errorf("retrieval ask price is not configured")
 	}
 
-	// Check if the price per byte is non-zero.
-	// Note that we don't check the unseal price, because we only serve
-	// unsealed copies, so the unseal price is irrelevant.
+	// Check if the price per byte is non-zero (it's zero for clients in the
+	// free allowlist)
 	if !ask.PricePerByte.IsZero() {
 		return fmt.Errorf("request for unpaid retrieval but ask price is non-zero: %d per byte", ask.PricePerByte)
 	}
+
+	// Check that the client hasn't exceeded its retrieval quota
+	if rv.Quotas != nil {
+		exceeded, err := rv.Quotas.Exceeded(rv.ctx, receiver.String())
+		if err != nil {
+			return fmt.Errorf("checking retrieval quota: %w", err)
+		}
+		if exceeded {
+			return errors.New("client has exceeded its retrieval quota")
+		}
+	}
+
+	// Check if the piece is unsealed
+	pieceInfo, isUnsealed, err := rv.getPiece(proposal.PayloadCID, proposal.PieceCID)
 	if err != nil {
+		if errors.Is(err, retrievalmarket.ErrNotFound) {
+			return fmt.Errorf("there is no piece containing payload cid %s: %w", proposal.PayloadCID, err)
+		}
 		return err
 	}
+	if !isUnsealed {
+		if !rv.UnsealOnDemand {
+			return fmt.Errorf("there is no unsealed piece containing payload cid %s", proposal.PayloadCID)
+		}
+
+		// The piece is unsealed when the data is read, but only if there is
+		// no charge for unsealing.
+		// Note that when there is an unsealed copy we don't check the unseal
+		// price, because it's irrelevant.
+		if !ask.UnsealPrice.IsZero() {
+			return fmt.Errorf("there is no unsealed piece containing payload cid %s and the unseal price is non-zero: %d", proposal.PayloadCID, ask.UnsealPrice)
+		}
+	}
 
 	// Check the deal filter
 	if rv.DealDecider != nil {
 		state := retrievalmarket.ProviderDealState{
 			DealProposal:    *proposal,
 			Receiver:        receiver,
+			PieceInfo:       &pieceInfo,
 			LegacyProtocol:  legacyProtocol,
 			CurrentInterval: proposal.PaymentInterval,
 		}
@@ -164,7 +182,7 @@ func (rv *requestValidator) getPiece(payloadCid cid.Cid, pieceCID *cid.Cid) (pie
 		inPieceCid = *pieceCID
 	}
 
-	pieces, piecesErr := GetAllPieceInfoForPayload(rv.DagStore, rv.PieceStore, payloadCid)
+	pieces, piecesErr := GetPieceInfosForPayload(rv.ctx, rv.PieceDirectory, payloadCid)
 	pieceInfo, isUnsealed := GetBestPieceInfoMatch(rv.ctx, rv.SectorAccessor, pieces, inPieceCid)
 	if pieceInfo.Defined() {
 		return pieceInfo, isUnsealed, nil
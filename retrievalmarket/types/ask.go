@@ -0,0 +1,52 @@
+package types
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipld/go-ipld-prime/node/bindnode"
+)
+
+type AskResponse struct {
+	PricePerByte            abi.TokenAmount
+	UnsealPrice             abi.TokenAmount
+	PaymentInterval         uint64
+	PaymentIntervalIncrease uint64
+	// Whether the querying client is in the provider's free allowlist
+	Free bool
+}
+
+//go:embed ask.ipldsch
+var embedAskSchema []byte
+
+func tokenAmountFromBytes(b []byte) (interface{}, error) {
+	amt, err := big.FromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	return &amt, nil
+}
+
+func tokenAmountToBytes(iface interface{}) ([]byte, error) {
+	amt, ok := iface.(*abi.TokenAmount)
+	if !ok {
+		return nil, fmt.Errorf("expected *TokenAmount value")
+	}
+	if amt.Int == nil {
+		zero := big.Zero()
+		return zero.Bytes()
+	}
+	return amt.Bytes()
+}
+
+func init() {
+	var dummyAmt abi.TokenAmount
+	var bindnodeOptions = []bindnode.Option{
+		bindnode.TypedBytesConverter(&dummyAmt, tokenAmountFromBytes, tokenAmountToBytes),
+	}
+	if err := BindnodeRegistry.RegisterType((*AskResponse)(nil), string(embedAskSchema), "AskResponse", bindnodeOptions...); err != nil {
+		panic(err.Error())
+	}
+}
@@ -0,0 +1,65 @@
+package retrievalpolicy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	lru "github.com/hnlq715/golang-lru"
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// The amount of time to cache the pieces containing a multihash
+const pieceLookupExpiry = 10 * time.Minute
+
+// PiecesContainingMultihash looks up the pieces that contain a block, eg
+// with the BoostDagstorePiecesContainingMultihash API method
+type PiecesContainingMultihash func(ctx context.Context, h mh.Multihash) ([]cid.Cid, error)
+
+// PieceLookup caches the pieces that contain each block, so that the
+// policy can be checked for each block that is retrieved without going to
+// the index every time
+type PieceLookup struct {
+	lookup PiecesContainingMultihash
+	cache  *lru.Cache
+}
+
+func NewPieceLookup(lookup PiecesContainingMultihash, size int) (*PieceLookup, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, fmt.Errorf("creating piece lookup cache: %w", err)
+	}
+	return &PieceLookup{lookup: lookup, cache: cache}, nil
+}
+
+// PiecesContaining returns the pieces that contain the block with the
+// given CID
+func (l *PieceLookup) PiecesContaining(ctx context.Context, c cid.Cid) ([]cid.Cid, error) {
+	key := string(c.Hash())
+	if v, ok := l.cache.Get(key); ok {
+		return v.([]cid.Cid), nil
+	}
+
+	pieces, err := l.lookup(ctx, c.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("getting pieces containing %s: %w", c, err)
+	}
+	l.cache.AddEx(key, pieces, pieceLookupExpiry)
+	return pieces, nil
+}
+
+// Check applies the policy to a retrieval of the block with the given CID
+// by the client. The pieces containing the block are only looked up if
+// access to some pieces is gated.
+func (l *PieceLookup) Check(ctx context.Context, e *Engine, client string, c cid.Cid) error {
+	var pieces []cid.Cid
+	if e.Policy().Gated() {
+		var err error
+		pieces, err = l.PiecesContaining(ctx, c)
+		if err != nil {
+			return err
+		}
+	}
+	return e.Check(client, pieces)
+}
@@ -0,0 +1,240 @@
+package retrievalpolicy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("retrievalpolicy")
+
+// The access levels for retrievals
+const (
+	// Anyone can retrieve the data
+	AccessFree = "free"
+	// Only the allowed clients can retrieve the data
+	AccessGated = "gated"
+)
+
+// ErrNotAllowed is returned when the client is not allowed to retrieve the
+// data
+var ErrNotAllowed = errors.New("client is not allowed to retrieve this data")
+
+// RejectReason describes the reason that a retrieval was rejected, for
+// metrics
+func RejectReason(err error) string {
+	if errors.Is(err, ErrNotAllowed) {
+		return "not-allowed"
+	}
+	return "error"
+}
+
+// Rule sets the access level for some pieces
+type Rule struct {
+	// The pieces that the rule applies to
+	PieceCids []cid.Cid
+	// The access level for the pieces: "free" or "gated"
+	Access string
+	// The clients that can retrieve the pieces if they're gated, in addition
+	// to the clients that are allowed to retrieve all gated pieces
+	AllowedClients []string
+}
+
+// Config is the retrieval policy, shared by all the services that serve
+// retrievals (eg booster-http and booster-bitswap).
+// A client is identified by its peer ID for bitswap retrievals, and by its
+// auth token id (or its IP address if auth is not enabled) for HTTP
+// retrievals.
+// Limits on the number of bytes each client can retrieve are set with the
+// retrieval quotas (see the retrievalmarket/quota package).
+type Config struct {
+	// The access level for pieces that don't match any rule: "free" or
+	// "gated" (free if empty)
+	DefaultAccess string
+	// The clients that can retrieve all gated pieces
+	AllowedClients []string
+	// Rules set the access level for specific pieces.
+	// If a piece matches more than one rule, the first rule is used.
+	Rules []Rule
+}
+
+type rule struct {
+	access  string
+	clients map[string]struct{}
+}
+
+// Policy decides whether a client can retrieve data
+type Policy struct {
+	cfg     Config
+	clients map[string]struct{}
+	rules   map[cid.Cid]rule
+}
+
+func New(cfg Config) (*Policy, error) {
+	if cfg.DefaultAccess == "" {
+		cfg.DefaultAccess = AccessFree
+	}
+	if err := validateAccess(cfg.DefaultAccess); err != nil {
+		return nil, fmt.Errorf("retrieval policy default access: %w", err)
+	}
+
+	p := &Policy{cfg: cfg, clients: toSet(cfg.AllowedClients), rules: make(map[cid.Cid]rule)}
+	for i, cr := range cfg.Rules {
+		if err := validateAccess(cr.Access); err != nil {
+			return nil, fmt.Errorf("retrieval policy rule %d: %w", i+1, err)
+		}
+		if len(cr.PieceCids) == 0 {
+			return nil, fmt.Errorf("retrieval policy rule %d: no piece CIDs", i+1)
+		}
+		r := rule{access: cr.Access, clients: toSet(cr.AllowedClients)}
+		for _, pc := range cr.PieceCids {
+			if _, ok := p.rules[pc]; !ok {
+				p.rules[pc] = r
+			}
+		}
+	}
+	return p, nil
+}
+
+// Config returns the config the policy was created with
+func (p *Policy) Config() Config {
+	return p.cfg
+}
+
+// Gated is true if the access to some pieces may be gated, in which case
+// the caller needs to find the pieces that contain the requested data
+// before calling Allowed
+func (p *Policy) Gated() bool {
+	return p.cfg.DefaultAccess == AccessGated || len(p.rules) > 0
+}
+
+// Allowed is true if the client can retrieve data that is in any of the
+// pieces. If the pieces are not known (eg if the data is not in any
+// piece), the default access applies.
+func (p *Policy) Allowed(client string, pieceCids []cid.Cid) bool {
+	if len(pieceCids) == 0 {
+		return p.cfg.DefaultAccess == AccessFree || p.isAllowedClient(client, nil)
+	}
+
+	for _, pc := range pieceCids {
+		access := p.cfg.DefaultAccess
+		r, ok := p.rules[pc]
+		if ok {
+			access = r.access
+		}
+		if access == AccessFree || p.isAllowedClient(client, r.clients) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) isAllowedClient(client string, ruleClients map[string]struct{}) bool {
+	if _, ok := p.clients[client]; ok {
+		return true
+	}
+	_, ok := ruleClients[client]
+	return ok
+}
+
+func validateAccess(access string) error {
+	switch access {
+	case AccessFree, AccessGated:
+		return nil
+	}
+	return fmt.Errorf("unknown access '%s': must be %s or %s", access, AccessFree, AccessGated)
+}
+
+func toSet(vals []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(vals))
+	for _, v := range vals {
+		set[v] = struct{}{}
+	}
+	return set
+}
+
+// Engine applies the current retrieval policy
+type Engine struct {
+	lk     sync.RWMutex
+	policy *Policy
+}
+
+// NewEngine creates an engine with a policy that allows all retrievals,
+// until the policy is updated
+func NewEngine() *Engine {
+	p, _ := New(Config{})
+	return &Engine{policy: p}
+}
+
+// Update replaces the current policy
+func (e *Engine) Update(cfg Config) error {
+	p, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	e.lk.Lock()
+	e.policy = p
+	e.lk.Unlock()
+	return nil
+}
+
+// Policy returns the current policy
+func (e *Engine) Policy() *Policy {
+	e.lk.RLock()
+	defer e.lk.RUnlock()
+	return e.policy
+}
+
+// Check returns ErrNotAllowed if the client is not allowed to retrieve data
+// in any of the pieces
+func (e *Engine) Check(client string, pieceCids []cid.Cid) error {
+	if !e.Policy().Allowed(client, pieceCids) {
+		return ErrNotAllowed
+	}
+	return nil
+}
+
+// Fetcher gets the current retrieval policy, eg from the boost API
+type Fetcher func(ctx context.Context) (*Config, error)
+
+// Watch updates the policy from the fetcher, then keeps updating it at
+// each interval until the context is cancelled
+func (e *Engine) Watch(ctx context.Context, fetch Fetcher, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("the retrieval policy refresh interval must be positive, got %s", interval)
+	}
+	if err := e.fetch(ctx, fetch); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			// If the policy can't be fetched, keep applying the last policy
+			if err := e.fetch(ctx, fetch); err != nil {
+				log.Warnw("updating retrieval policy", "err", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func (e *Engine) fetch(ctx context.Context, fetch Fetcher) error {
+	cfg, err := fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("fetching retrieval policy: %w", err)
+	}
+	return e.Update(*cfg)
+}
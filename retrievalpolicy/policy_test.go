@@ -0,0 +1,161 @@
+package retrievalpolicy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy(t *testing.T) {
+	freePiece := testutil.GenerateCid()
+	gatedPiece := testutil.GenerateCid()
+	otherPiece := testutil.GenerateCid()
+
+	p, err := New(Config{
+		DefaultAccess:  AccessGated,
+		AllowedClients: []string{"admin"},
+		Rules: []Rule{{
+			PieceCids: []cid.Cid{freePiece},
+			Access:    AccessFree,
+		}, {
+			PieceCids:      []cid.Cid{gatedPiece},
+			Access:         AccessGated,
+			AllowedClients: []string{"client1"},
+		}, {
+			// Ignored because the piece matches the first rule
+			PieceCids: []cid.Cid{gatedPiece, freePiece},
+			Access:    AccessGated,
+		}},
+	})
+	require.NoError(t, err)
+	require.True(t, p.Gated())
+
+	tcs := []struct {
+		name     string
+		client   string
+		pieces   []cid.Cid
+		expected bool
+	}{{
+		name:     "free piece",
+		client:   "client2",
+		pieces:   []cid.Cid{freePiece},
+		expected: true,
+	}, {
+		name:     "gated piece, allowed by rule",
+		client:   "client1",
+		pieces:   []cid.Cid{gatedPiece},
+		expected: true,
+	}, {
+		name:     "gated piece, allowed for all gated pieces",
+		client:   "admin",
+		pieces:   []cid.Cid{gatedPiece},
+		expected: true,
+	}, {
+		name:     "gated piece, not allowed",
+		client:   "client2",
+		pieces:   []cid.Cid{gatedPiece},
+		expected: false,
+	}, {
+		name:     "data in a gated and a free piece",
+		client:   "client2",
+		pieces:   []cid.Cid{gatedPiece, freePiece},
+		expected: true,
+	}, {
+		name:     "default access",
+		client:   "client1",
+		pieces:   []cid.Cid{otherPiece},
+		expected: false,
+	}, {
+		name:     "unknown piece, allowed for all gated pieces",
+		client:   "admin",
+		expected: true,
+	}, {
+		name:     "unknown piece",
+		client:   "client1",
+		expected: false,
+	}}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, p.Allowed(tc.client, tc.pieces))
+		})
+	}
+}
+
+func TestPolicyValidation(t *testing.T) {
+	_, err := New(Config{DefaultAccess: "paid"})
+	require.Error(t, err)
+
+	_, err = New(Config{Rules: []Rule{{Access: AccessGated}}})
+	require.Error(t, err)
+
+	p, err := New(Config{})
+	require.NoError(t, err)
+	require.False(t, p.Gated())
+	require.True(t, p.Allowed("anyone", nil))
+}
+
+func TestEngineWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	piece := testutil.GenerateCid()
+	configs := make(chan *Config, 1)
+	fetch := func(ctx context.Context) (*Config, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case cfg := <-configs:
+			if cfg == nil {
+				return nil, errors.New("not available")
+			}
+			return cfg, nil
+		}
+	}
+
+	e := NewEngine()
+	require.ErrorContains(t, e.Watch(ctx, fetch, 0), "refresh interval must be positive")
+
+	configs <- &Config{}
+	require.NoError(t, e.Watch(ctx, fetch, 10*time.Millisecond))
+	require.NoError(t, e.Check("client1", []cid.Cid{piece}))
+
+	// The updated policy is fetched at the next interval
+	configs <- &Config{DefaultAccess: AccessGated}
+	require.Eventually(t, func() bool {
+		return errors.Is(e.Check("client1", []cid.Cid{piece}), ErrNotAllowed)
+	}, time.Second, time.Millisecond)
+
+	// When the policy can't be fetched the last policy still applies
+	configs <- nil
+	require.Eventually(t, func() bool { return len(configs) == 0 }, time.Second, time.Millisecond)
+	require.ErrorIs(t, e.Check("client1", []cid.Cid{piece}), ErrNotAllowed)
+}
+
+func TestPieceLookup(t *testing.T) {
+	ctx := context.Background()
+	blk := testutil.GenerateCid()
+	gatedPiece := testutil.GenerateCid()
+
+	var lookups int
+	l, err := NewPieceLookup(func(ctx context.Context, h mh.Multihash) ([]cid.Cid, error) {
+		lookups++
+		return []cid.Cid{gatedPiece}, nil
+	}, 16)
+	require.NoError(t, err)
+
+	// When nothing is gated the pieces are not looked up
+	e := NewEngine()
+	require.NoError(t, l.Check(ctx, e, "client1", blk))
+	require.Equal(t, 0, lookups)
+
+	require.NoError(t, e.Update(Config{Rules: []Rule{{PieceCids: []cid.Cid{gatedPiece}, Access: AccessGated}}}))
+	require.ErrorIs(t, l.Check(ctx, e, "client1", blk), ErrNotAllowed)
+	require.ErrorIs(t, l.Check(ctx, e, "client1", blk), ErrNotAllowed)
+	require.Equal(t, 1, lookups)
+}
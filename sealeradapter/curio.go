@@ -0,0 +1,183 @@
+package sealeradapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("sealeradapter")
+
+// The HTTP sector intake API has three endpoints:
+//
+// POST /intake/pieces
+// Registers a piece that will be added to a sector. The request body is a
+// JSON encoded PieceRequest. The response is a JSON encoded PieceResponse
+// with the ID to upload the piece data to.
+//
+// PUT /intake/pieces/{id}/data
+// Uploads the piece data. The request body is the unpadded piece data, and
+// its length is the Size in the PieceRequest. The response is sent once the
+// piece has been added to a sector, and is a JSON encoded PieceLocation.
+//
+// GET /intake/sectors/{sector number}
+// Gets the sealing state of a sector. The response is a JSON encoded
+// SectorStatus.
+//
+// Requests are authenticated with a bearer token in the Authorization header.
+// Errors are reported with a non-2xx status and the error message as the
+// response body.
+
+// PieceRequest describes the piece to add to a sector
+type PieceRequest struct {
+	DealID       abi.DealID
+	PublishCid   *cid.Cid
+	DealProposal *market.DealProposal
+	StartEpoch   abi.ChainEpoch
+	EndEpoch     abi.ChainEpoch
+	KeepUnsealed bool
+	// The unpadded size of the piece data
+	Size abi.UnpaddedPieceSize
+}
+
+type PieceResponse struct {
+	// The ID that the piece data is uploaded to
+	ID string
+}
+
+// PieceLocation is where the piece was added
+type PieceLocation struct {
+	Sector abi.SectorNumber
+	Offset abi.PaddedPieceSize
+}
+
+type SectorStatus struct {
+	// The lotus sector state name (eg "PreCommit1" or "Proving") that is
+	// equivalent to the sector's sealing state
+	State string
+}
+
+type CurioConfig struct {
+	// The base URL of the sector intake API, eg http://curio.example.com:12310
+	URL string
+	// The token used to authenticate with the sector intake API
+	Token string
+}
+
+// Curio hands deal data off to a sealing stack (such as Curio) that exposes
+// an HTTP sector intake API
+type Curio struct {
+	cfg    CurioConfig
+	base   *url.URL
+	client *http.Client
+}
+
+var _ types.SealerAdapter = (*Curio)(nil)
+
+func NewCurio(cfg CurioConfig) (*Curio, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("the sector intake API URL must be set")
+	}
+	base, err := url.Parse(strings.TrimSuffix(cfg.URL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("parsing sector intake API URL '%s': %w", cfg.URL, err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("sector intake API URL '%s' must be an http or https URL", cfg.URL)
+	}
+
+	return &Curio{
+		cfg:    cfg,
+		base:   base,
+		client: &http.Client{},
+	}, nil
+}
+
+func (c *Curio) AddPiece(ctx context.Context, size abi.UnpaddedPieceSize, r io.Reader, d api.PieceDealInfo) (abi.SectorNumber, abi.PaddedPieceSize, error) {
+	// Register the piece
+	preq := PieceRequest{
+		DealID:       d.DealID,
+		PublishCid:   d.PublishCid,
+		DealProposal: d.DealProposal,
+		StartEpoch:   d.DealSchedule.StartEpoch,
+		EndEpoch:     d.DealSchedule.EndEpoch,
+		KeepUnsealed: d.KeepUnsealed,
+		Size:         size,
+	}
+	body, err := json.Marshal(preq)
+	if err != nil {
+		return 0, 0, fmt.Errorf("marshalling piece request: %w", err)
+	}
+
+	var presp PieceResponse
+	err = c.do(ctx, http.MethodPost, "/intake/pieces", bytes.NewReader(body), int64(len(body)), "application/json", &presp)
+	if err != nil {
+		return 0, 0, fmt.Errorf("registering piece for deal %d: %w", d.DealID, err)
+	}
+
+	// Upload the piece data, and wait for it to be added to a sector
+	log.Infow("uploading piece data", "deal", d.DealID, "id", presp.ID, "size", size)
+	var loc PieceLocation
+	err = c.do(ctx, http.MethodPut, "/intake/pieces/"+url.PathEscape(presp.ID)+"/data", r, int64(size), "application/octet-stream", &loc)
+	if err != nil {
+		return 0, 0, fmt.Errorf("uploading piece data for deal %d: %w", d.DealID, err)
+	}
+
+	log.Infow("piece added to sector", "deal", d.DealID, "id", presp.ID, "sector", loc.Sector, "offset", loc.Offset)
+	return loc.Sector, loc.Offset, nil
+}
+
+func (c *Curio) SectorsStatus(ctx context.Context, sid abi.SectorNumber, showOnChainInfo bool) (api.SectorInfo, error) {
+	var st SectorStatus
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/intake/sectors/%d", sid), nil, 0, "", &st)
+	if err != nil {
+		return api.SectorInfo{}, fmt.Errorf("getting status of sector %d: %w", sid, err)
+	}
+	return api.SectorInfo{SectorID: sid, State: api.SectorState(st.State)}, nil
+}
+
+// do makes a request to the sector intake API and decodes the JSON response
+// into res
+func (c *Curio) do(ctx context.Context, method string, path string, body io.Reader, size int64, contentType string, res interface{}) error {
+	u := *c.base
+	u.Path += path
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.ContentLength = size
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return fmt.Errorf("decoding %s %s response: %w", method, path, err)
+	}
+	return nil
+}
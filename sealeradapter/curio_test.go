@@ -0,0 +1,145 @@
+package sealeradapter
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurio(t *testing.T) {
+	ctx := context.Background()
+
+	intake := newMockIntake("secret")
+	srv := httptest.NewServer(intake)
+	defer srv.Close()
+
+	c, err := NewCurio(CurioConfig{URL: srv.URL + "/", Token: "secret"})
+	require.NoError(t, err)
+
+	data := make([]byte, 1016)
+	_, err = rand.Read(data)
+	require.NoError(t, err)
+
+	d := api.PieceDealInfo{
+		DealID:       5,
+		DealProposal: &market.DealProposal{PieceSize: 1024},
+		DealSchedule: api.DealSchedule{StartEpoch: 100, EndEpoch: 200},
+		KeepUnsealed: true,
+	}
+
+	t.Run("add piece", func(t *testing.T) {
+		sector, offset, err := c.AddPiece(ctx, abi.UnpaddedPieceSize(len(data)), bytes.NewReader(data), d)
+		require.NoError(t, err)
+		require.EqualValues(t, 7, sector)
+		require.EqualValues(t, 0, offset)
+
+		require.Len(t, intake.pieces, 1)
+		p := intake.pieces["1"]
+		require.EqualValues(t, 5, p.req.DealID)
+		require.EqualValues(t, 100, p.req.StartEpoch)
+		require.EqualValues(t, 200, p.req.EndEpoch)
+		require.True(t, p.req.KeepUnsealed)
+		require.Equal(t, data, p.data)
+	})
+
+	t.Run("sector status", func(t *testing.T) {
+		si, err := c.SectorsStatus(ctx, 7, false)
+		require.NoError(t, err)
+		require.EqualValues(t, 7, si.SectorID)
+		require.Equal(t, api.SectorState("PreCommit1"), si.State)
+
+		_, err = c.SectorsStatus(ctx, 8, false)
+		require.ErrorContains(t, err, "sector not found")
+	})
+
+	t.Run("requires a token", func(t *testing.T) {
+		c, err := NewCurio(CurioConfig{URL: srv.URL, Token: "wrong"})
+		require.NoError(t, err)
+		_, err = c.SectorsStatus(ctx, 7, false)
+		require.ErrorContains(t, err, "401")
+	})
+
+	t.Run("invalid url", func(t *testing.T) {
+		_, err := NewCurio(CurioConfig{URL: "/ip4/127.0.0.1/tcp/1234"})
+		require.Error(t, err)
+	})
+}
+
+type mockPiece struct {
+	req  PieceRequest
+	data []byte
+}
+
+// mockIntake implements the sector intake API
+type mockIntake struct {
+	*mux.Router
+	token string
+
+	lk     sync.Mutex
+	pieces map[string]*mockPiece
+}
+
+func newMockIntake(token string) *mockIntake {
+	m := &mockIntake{Router: mux.NewRouter(), token: token, pieces: make(map[string]*mockPiece)}
+	m.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer "+m.token {
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	m.HandleFunc("/intake/pieces", func(w http.ResponseWriter, r *http.Request) {
+		var req PieceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		m.lk.Lock()
+		id := string(rune('0' + len(m.pieces) + 1))
+		m.pieces[id] = &mockPiece{req: req}
+		m.lk.Unlock()
+		_ = json.NewEncoder(w).Encode(PieceResponse{ID: id})
+	}).Methods(http.MethodPost)
+
+	m.HandleFunc("/intake/pieces/{id}/data", func(w http.ResponseWriter, r *http.Request) {
+		m.lk.Lock()
+		p, ok := m.pieces[mux.Vars(r)["id"]]
+		m.lk.Unlock()
+		if !ok {
+			http.Error(w, "piece not found", http.StatusNotFound)
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil || len(data) != int(p.req.Size) {
+			http.Error(w, "short piece data", http.StatusBadRequest)
+			return
+		}
+		p.data = data
+		_ = json.NewEncoder(w).Encode(PieceLocation{Sector: 7, Offset: 0})
+	}).Methods(http.MethodPut)
+
+	m.HandleFunc("/intake/sectors/{sector}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["sector"] != "7" {
+			http.Error(w, "sector not found", http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(SectorStatus{State: "PreCommit1"})
+	}).Methods(http.MethodGet)
+
+	return m
+}
@@ -0,0 +1,40 @@
+package sealeradapter
+
+import (
+	"context"
+	"io"
+
+	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api"
+)
+
+// The kinds of sealer adapter
+const (
+	TypeLotus = "lotus"
+	TypeCurio = "curio"
+)
+
+// Lotus hands deal data off to a lotus miner
+type Lotus struct {
+	pieceAdder types.PieceAdder
+	sps        sealingpipeline.API
+}
+
+var _ types.SealerAdapter = (*Lotus)(nil)
+
+// NewLotus creates a sealer adapter that adds deal data to sectors with the
+// piece adder (eg the lotus miner, or a remote piece worker that is
+// connected to the lotus miner), and gets sector state from the lotus miner
+func NewLotus(pieceAdder types.PieceAdder, sps sealingpipeline.API) *Lotus {
+	return &Lotus{pieceAdder: pieceAdder, sps: sps}
+}
+
+func (l *Lotus) AddPiece(ctx context.Context, size abi.UnpaddedPieceSize, r io.Reader, d api.PieceDealInfo) (abi.SectorNumber, abi.PaddedPieceSize, error) {
+	return l.pieceAdder.AddPiece(ctx, size, r, d)
+}
+
+func (l *Lotus) SectorsStatus(ctx context.Context, sid abi.SectorNumber, showOnChainInfo bool) (api.SectorInfo, error) {
+	return l.sps.SectorsStatus(ctx, sid, showOnChainInfo)
+}
@@ -0,0 +1,65 @@
+package flatstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	mdagstore "github.com/filecoin-project/lotus/markets/dagstore"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+)
+
+// PieceCopier copies the data of pieces that are already on the node, from
+// the flat store if it has a copy of the piece, or else from an unsealed
+// copy of the piece in a sector. It never unseals a piece.
+type PieceCopier struct {
+	store *Store
+	api   mdagstore.MinerAPI
+}
+
+// NewPieceCopier creates a piece copier that reads unsealed copies of
+// pieces through the dagstore mount API. The store is nil if the flat store
+// is disabled.
+func NewPieceCopier(store *Store, api mdagstore.MinerAPI) *PieceCopier {
+	return &PieceCopier{store: store, api: api}
+}
+
+// CopyTo replaces the file at dstPath with the first size bytes of the
+// piece data. It returns false if the piece isn't in the flat store and
+// doesn't have an unsealed copy.
+func (c *PieceCopier) CopyTo(ctx context.Context, pieceCid cid.Cid, size uint64, dstPath string) (bool, error) {
+	if c.store != nil {
+		copied, err := c.store.CopyTo(pieceCid, dstPath)
+		if err != nil || copied {
+			return copied, err
+		}
+	}
+
+	isUnsealed, err := c.api.IsUnsealed(ctx, pieceCid)
+	if err != nil {
+		return false, fmt.Errorf("checking for unsealed copy of piece %s: %w", pieceCid, err)
+	}
+	if !isUnsealed {
+		return false, nil
+	}
+
+	r, err := c.api.FetchUnsealedPiece(ctx, pieceCid)
+	if err != nil {
+		return false, fmt.Errorf("reading unsealed copy of piece %s: %w", pieceCid, err)
+	}
+	defer r.Close()
+
+	// The unsealed piece is padded, so only copy the bytes of the CAR file
+	tmpPath := dstPath + "." + uuid.New().String() + tmpExt
+	if err := copyToTmp(io.LimitReader(r, int64(size)), tmpPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return false, fmt.Errorf("copying piece %s to %s: %w", pieceCid, dstPath, err)
+	}
+	if err := os.Rename(tmpPath, dstPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return false, fmt.Errorf("moving piece %s to %s: %w", pieceCid, dstPath, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,89 @@
+package flatstore
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/boost/testutil"
+	"github.com/filecoin-project/dagstore/mount"
+	mdagstore "github.com/filecoin-project/lotus/markets/dagstore"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPieceCopier(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	_, v1Path := createCarFiles(t, dir)
+	carBytes, err := os.ReadFile(v1Path)
+	require.NoError(t, err)
+
+	dstPath := filepath.Join(dir, "inbound.car")
+	pieceCid := testutil.GenerateCid()
+
+	t.Run("copies from the flat store", func(t *testing.T) {
+		s, err := New(Config{Dir: filepath.Join(t.TempDir(), "flatstore")})
+		require.NoError(t, err)
+		require.NoError(t, s.Retain(pieceCid, v1Path))
+
+		c := NewPieceCopier(s, &mockMinerAPI{})
+		copied, err := c.CopyTo(ctx, pieceCid, uint64(len(carBytes)), dstPath)
+		require.NoError(t, err)
+		require.True(t, copied)
+		actual, err := os.ReadFile(dstPath)
+		require.NoError(t, err)
+		require.Equal(t, carBytes, actual)
+	})
+
+	t.Run("copies from an unsealed copy without the flat store", func(t *testing.T) {
+		// The unsealed piece is padded with zeros
+		padded := append(append([]byte(nil), carBytes...), make([]byte, 1024)...)
+		api := &mockMinerAPI{unsealed: map[cid.Cid][]byte{pieceCid: padded}}
+
+		c := NewPieceCopier(nil, api)
+		copied, err := c.CopyTo(ctx, pieceCid, uint64(len(carBytes)), dstPath)
+		require.NoError(t, err)
+		require.True(t, copied)
+		actual, err := os.ReadFile(dstPath)
+		require.NoError(t, err)
+		require.Equal(t, carBytes, actual)
+	})
+
+	t.Run("does not unseal a sealed piece", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(dstPath, nil, 0644))
+
+		c := NewPieceCopier(nil, &mockMinerAPI{})
+		copied, err := c.CopyTo(ctx, pieceCid, uint64(len(carBytes)), dstPath)
+		require.NoError(t, err)
+		require.False(t, copied)
+		fi, err := os.Stat(dstPath)
+		require.NoError(t, err)
+		require.Zero(t, fi.Size())
+	})
+}
+
+// mockMinerAPI serves the pieces that have an unsealed copy
+type mockMinerAPI struct {
+	mdagstore.MinerAPI
+	unsealed map[cid.Cid][]byte
+}
+
+func (m *mockMinerAPI) IsUnsealed(ctx context.Context, pieceCid cid.Cid) (bool, error) {
+	_, ok := m.unsealed[pieceCid]
+	return ok, nil
+}
+
+func (m *mockMinerAPI) FetchUnsealedPiece(ctx context.Context, pieceCid cid.Cid) (mount.Reader, error) {
+	return &bytesReader{Reader: bytes.NewReader(m.unsealed[pieceCid])}, nil
+}
+
+type bytesReader struct {
+	*bytes.Reader
+}
+
+func (r *bytesReader) Close() error {
+	return nil
+}
@@ -0,0 +1,47 @@
+package flatstore
+
+import (
+	"context"
+
+	"github.com/filecoin-project/dagstore/mount"
+	mdagstore "github.com/filecoin-project/lotus/markets/dagstore"
+	"github.com/ipfs/go-cid"
+)
+
+// MinerAPI wraps the dagstore mount API so that pieces that have a copy in
+// the flat store are read from the copy instead of being unsealed
+type MinerAPI struct {
+	mdagstore.MinerAPI
+	store *Store
+}
+
+var _ mdagstore.MinerAPI = (*MinerAPI)(nil)
+
+func NewMinerAPI(api mdagstore.MinerAPI, store *Store) *MinerAPI {
+	return &MinerAPI{MinerAPI: api, store: store}
+}
+
+func (m *MinerAPI) FetchUnsealedPiece(ctx context.Context, pieceCid cid.Cid) (mount.Reader, error) {
+	r, err := m.store.Open(pieceCid)
+	if err == nil {
+		return r, nil
+	}
+	if err != ErrNotFound {
+		log.Warnw("failed to open piece in flat store, falling back to sealer", "pieceCid", pieceCid, "err", err)
+	}
+	return m.MinerAPI.FetchUnsealedPiece(ctx, pieceCid)
+}
+
+func (m *MinerAPI) GetUnpaddedCARSize(ctx context.Context, pieceCid cid.Cid) (uint64, error) {
+	if size, err := m.store.Size(pieceCid); err == nil {
+		return size, nil
+	}
+	return m.MinerAPI.GetUnpaddedCARSize(ctx, pieceCid)
+}
+
+func (m *MinerAPI) IsUnsealed(ctx context.Context, pieceCid cid.Cid) (bool, error) {
+	if m.store.Has(pieceCid) {
+		return true, nil
+	}
+	return m.MinerAPI.IsUnsealed(ctx, pieceCid)
+}
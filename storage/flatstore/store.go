@@ -0,0 +1,416 @@
+package flatstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	carv2 "github.com/ipld/go-car/v2"
+)
+
+var log = logging.Logger("flatstore")
+
+// The eviction policies decide which piece to remove when the store is full
+const (
+	// Evict the piece that was read least recently
+	EvictLRU = "lru"
+	// Evict the piece that was added first
+	EvictFIFO = "fifo"
+)
+
+const (
+	carExt = ".car"
+	tmpExt = ".tmp"
+)
+
+// ErrNotFound is returned when the store does not have a copy of the piece
+var ErrNotFound = errors.New("piece not found in flat store")
+
+type Config struct {
+	// The directory in which the CAR files are kept
+	Dir string
+	// The maximum number of bytes of CAR files to keep. When a new piece
+	// would exceed the limit, pieces are evicted according to the
+	// eviction policy. 0 is unlimited.
+	MaxBytes uint64
+	// The eviction policy: "lru" or "fifo"
+	EvictionPolicy string
+}
+
+// Usage describes the pieces in the store and how much space they use
+type Usage struct {
+	Pieces    int
+	UsedBytes uint64
+	MaxBytes  uint64
+}
+
+type entry struct {
+	size       uint64
+	added      time.Time
+	lastAccess time.Time
+	// the number of readers that are open against the piece
+	open int
+}
+
+// Store keeps a flat directory of CAR files, one per piece, named by piece
+// cid. It's used to keep a copy of each deal's data after it's handed off to
+// the sealer, so that retrievals can be served without unsealing.
+type Store struct {
+	cfg Config
+
+	lk      sync.Mutex
+	entries map[cid.Cid]*entry
+	used    uint64
+}
+
+// New creates the store directory if it doesn't exist, and loads the pieces
+// that are already in the directory.
+// The time each piece was last read is not persisted, so on startup the
+// pieces are ordered by the time they were added.
+func New(cfg Config) (*Store, error) {
+	switch cfg.EvictionPolicy {
+	case "":
+		cfg.EvictionPolicy = EvictLRU
+	case EvictLRU, EvictFIFO:
+	default:
+		return nil, fmt.Errorf("unknown eviction policy '%s': must be one of '%s', '%s'", cfg.EvictionPolicy, EvictLRU, EvictFIFO)
+	}
+	if cfg.Dir == "" {
+		return nil, errors.New("flat store directory must be set")
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating flat store directory %s: %w", cfg.Dir, err)
+	}
+
+	s := &Store{cfg: cfg, entries: make(map[cid.Cid]*entry)}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	s.lk.Lock()
+	s.evictLocked(cid.Undef)
+	s.lk.Unlock()
+
+	log.Infow("opened flat store", "dir", cfg.Dir, "pieces", len(s.entries), "used", s.used, "max", cfg.MaxBytes)
+	return s, nil
+}
+
+func (s *Store) load() error {
+	dirEntries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("reading flat store directory %s: %w", s.cfg.Dir, err)
+	}
+
+	for _, de := range dirEntries {
+		name := de.Name()
+		if de.IsDir() {
+			continue
+		}
+
+		// Clean up any files that were partially written before a restart
+		if strings.HasSuffix(name, tmpExt) {
+			_ = os.Remove(filepath.Join(s.cfg.Dir, name))
+			continue
+		}
+
+		if !strings.HasSuffix(name, carExt) {
+			continue
+		}
+		pieceCid, err := cid.Parse(strings.TrimSuffix(name, carExt))
+		if err != nil {
+			log.Warnw("ignoring file in flat store directory with invalid piece cid", "file", name, "err", err)
+			continue
+		}
+
+		fi, err := de.Info()
+		if err != nil {
+			return fmt.Errorf("getting info for %s: %w", name, err)
+		}
+		s.entries[pieceCid] = &entry{
+			size:       uint64(fi.Size()),
+			added:      fi.ModTime(),
+			lastAccess: fi.ModTime(),
+		}
+		s.used += uint64(fi.Size())
+	}
+
+	return nil
+}
+
+func (s *Store) path(pieceCid cid.Cid) string {
+	return filepath.Join(s.cfg.Dir, pieceCid.String()+carExt)
+}
+
+// Retain keeps a copy of the CAR file at carPath for the piece.
+// If the CAR file is a CARv1 file it's hard-linked into the store where
+// possible. Otherwise the CAR data is copied, so that the store always
+// contains CARv1 files.
+func (s *Store) Retain(pieceCid cid.Cid, carPath string) error {
+	if s.Has(pieceCid) {
+		return nil
+	}
+
+	// Write to a unique temp file, in case the same piece is being retained
+	// for more than one deal at the same time
+	tmpPath := s.path(pieceCid) + "." + uuid.New().String() + tmpExt
+	size, err := s.writeTmp(carPath, tmpPath)
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	if s.cfg.MaxBytes > 0 && size > s.cfg.MaxBytes {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("piece %s size %d is larger than flat store max size %d", pieceCid, size, s.cfg.MaxBytes)
+	}
+
+	if err := os.Rename(tmpPath, s.path(pieceCid)); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("moving piece %s into flat store: %w", pieceCid, err)
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if _, ok := s.entries[pieceCid]; ok {
+		// The piece was retained for another deal in the meantime
+		return nil
+	}
+	now := time.Now()
+	s.entries[pieceCid] = &entry{size: size, added: now, lastAccess: now}
+	s.used += size
+	s.evictLocked(pieceCid)
+
+	log.Infow("retained piece in flat store", "pieceCid", pieceCid, "size", size, "used", s.used)
+	return nil
+}
+
+// writeTmp writes the CAR data from carPath to tmpPath and returns its size
+func (s *Store) writeTmp(carPath string, tmpPath string) (uint64, error) {
+	rd, err := carv2.OpenReader(carPath)
+	if err != nil {
+		return 0, fmt.Errorf("opening CAR file %s: %w", carPath, err)
+	}
+	defer rd.Close()
+
+	if rd.Version == 1 {
+		if err := os.Link(carPath, tmpPath); err == nil {
+			fi, err := os.Stat(tmpPath)
+			if err != nil {
+				return 0, fmt.Errorf("getting size of %s: %w", tmpPath, err)
+			}
+			return uint64(fi.Size()), nil
+		}
+		// Fall back to copying the data, eg if the file is on a
+		// different filesystem
+	}
+
+	dr, err := rd.DataReader()
+	if err != nil {
+		return 0, fmt.Errorf("getting data reader for CAR file %s: %w", carPath, err)
+	}
+
+	if err := copyToTmp(dr, tmpPath); err != nil {
+		return 0, fmt.Errorf("copying CAR file %s: %w", carPath, err)
+	}
+	fi, err := os.Stat(tmpPath)
+	if err != nil {
+		return 0, fmt.Errorf("getting size of %s: %w", tmpPath, err)
+	}
+	return uint64(fi.Size()), nil
+}
+
+// CopyTo replaces the file at dstPath with the CAR file for the piece.
+// The CAR file is hard-linked where possible, otherwise it's copied.
+// It returns false if the store does not have a copy of the piece.
+func (s *Store) CopyTo(pieceCid cid.Cid, dstPath string) (bool, error) {
+	// Open the piece so that it's not evicted while it's being copied
+	r, err := s.Open(pieceCid)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer r.Close()
+
+	// Write to a temp file first so that the destination file is left as it
+	// was if there's an error
+	tmpPath := dstPath + "." + uuid.New().String() + tmpExt
+	if err := os.Link(s.path(pieceCid), tmpPath); err != nil {
+		if err := copyToTmp(r, tmpPath); err != nil {
+			_ = os.Remove(tmpPath)
+			return false, fmt.Errorf("copying piece %s to %s: %w", pieceCid, dstPath, err)
+		}
+	}
+
+	if err := os.Rename(tmpPath, dstPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return false, fmt.Errorf("moving piece %s to %s: %w", pieceCid, dstPath, err)
+	}
+	return true, nil
+}
+
+func copyToTmp(r io.Reader, tmpPath string) error {
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Has returns true if the store has a copy of the piece
+func (s *Store) Has(pieceCid cid.Cid) bool {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	_, ok := s.entries[pieceCid]
+	return ok
+}
+
+// Size returns the size of the CAR file for the piece
+func (s *Store) Size(pieceCid cid.Cid) (uint64, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	e, ok := s.entries[pieceCid]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return e.size, nil
+}
+
+// Reader reads the CAR file for a piece.
+// The piece is not evicted while a reader is open against it.
+type Reader struct {
+	*os.File
+	s         *Store
+	pieceCid  cid.Cid
+	closeOnce sync.Once
+}
+
+func (r *Reader) Close() error {
+	err := r.File.Close()
+	r.closeOnce.Do(func() {
+		r.s.lk.Lock()
+		defer r.s.lk.Unlock()
+
+		if e, ok := r.s.entries[r.pieceCid]; ok && e.open > 0 {
+			e.open--
+		}
+	})
+	return err
+}
+
+// Open returns a reader over the CAR file for the piece
+func (s *Store) Open(pieceCid cid.Cid) (*Reader, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	e, ok := s.entries[pieceCid]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	f, err := os.Open(s.path(pieceCid))
+	if err != nil {
+		return nil, fmt.Errorf("opening piece %s in flat store: %w", pieceCid, err)
+	}
+
+	e.open++
+	e.lastAccess = time.Now()
+	return &Reader{File: f, s: s, pieceCid: pieceCid}, nil
+}
+
+// Remove removes the copy of the piece from the store.
+// It returns false if the store did not have a copy of the piece.
+func (s *Store) Remove(pieceCid cid.Cid) (bool, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if _, ok := s.entries[pieceCid]; !ok {
+		return false, nil
+	}
+	if err := s.removeLocked(pieceCid); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Usage returns the number of pieces in the store and the space they use
+func (s *Store) Usage() Usage {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	return Usage{
+		Pieces:    len(s.entries),
+		UsedBytes: s.used,
+		MaxBytes:  s.cfg.MaxBytes,
+	}
+}
+
+func (s *Store) removeLocked(pieceCid cid.Cid) error {
+	e := s.entries[pieceCid]
+	if err := os.Remove(s.path(pieceCid)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing piece %s from flat store: %w", pieceCid, err)
+	}
+	delete(s.entries, pieceCid)
+	s.used -= e.size
+	return nil
+}
+
+// evictLocked evicts pieces until the store is within its max size.
+// The keep piece, and pieces that are being read, are not evicted.
+func (s *Store) evictLocked(keep cid.Cid) {
+	for s.cfg.MaxBytes > 0 && s.used > s.cfg.MaxBytes {
+		evict := s.evictionCandidateLocked(keep)
+		if evict == cid.Undef {
+			log.Warnw("flat store is over max size but all pieces are in use", "used", s.used, "max", s.cfg.MaxBytes)
+			return
+		}
+
+		size := s.entries[evict].size
+		if err := s.removeLocked(evict); err != nil {
+			log.Warnw("failed to evict piece from flat store", "pieceCid", evict, "err", err)
+			return
+		}
+		log.Infow("evicted piece from flat store", "pieceCid", evict, "size", size, "policy", s.cfg.EvictionPolicy)
+	}
+}
+
+func (s *Store) evictionCandidateLocked(keep cid.Cid) cid.Cid {
+	candidate := cid.Undef
+	var candidateAt time.Time
+	for pieceCid, e := range s.entries {
+		if pieceCid == keep || e.open > 0 {
+			continue
+		}
+
+		at := e.lastAccess
+		if s.cfg.EvictionPolicy == EvictFIFO {
+			at = e.added
+		}
+		if candidate == cid.Undef || at.Before(candidateAt) {
+			candidate = pieceCid
+			candidateAt = at
+		}
+	}
+	return candidate
+}
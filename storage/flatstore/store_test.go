@@ -0,0 +1,204 @@
+package flatstore
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/boost/testutil"
+	carv2 "github.com/ipld/go-car/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreRetain(t *testing.T) {
+	dir := t.TempDir()
+	v2Path, v1Path := createCarFiles(t, dir)
+	pieceCids := testutil.GenerateCids(2)
+
+	s, err := New(Config{Dir: filepath.Join(dir, "flatstore")})
+	require.NoError(t, err)
+
+	// A CARv2 file is stored as a CARv1 file
+	require.NoError(t, s.Retain(pieceCids[0], v2Path))
+	require.NoError(t, s.Retain(pieceCids[1], v1Path))
+
+	v1Stat, err := os.Stat(v1Path)
+	require.NoError(t, err)
+	for _, pieceCid := range pieceCids {
+		require.True(t, s.Has(pieceCid))
+
+		size, err := s.Size(pieceCid)
+		require.NoError(t, err)
+		require.EqualValues(t, v1Stat.Size(), size)
+
+		r, err := s.Open(pieceCid)
+		require.NoError(t, err)
+		rd, err := carv2.NewReader(r)
+		require.NoError(t, err)
+		require.EqualValues(t, 1, rd.Version)
+		require.NoError(t, r.Close())
+	}
+
+	require.Equal(t, Usage{Pieces: 2, UsedBytes: uint64(2 * v1Stat.Size())}, s.Usage())
+
+	// The pieces are loaded when the store is reopened
+	s, err = New(Config{Dir: filepath.Join(dir, "flatstore")})
+	require.NoError(t, err)
+	require.Equal(t, 2, s.Usage().Pieces)
+
+	removed, err := s.Remove(pieceCids[0])
+	require.NoError(t, err)
+	require.True(t, removed)
+	require.False(t, s.Has(pieceCids[0]))
+	_, err = s.Open(pieceCids[0])
+	require.ErrorIs(t, err, ErrNotFound)
+
+	removed, err = s.Remove(pieceCids[0])
+	require.NoError(t, err)
+	require.False(t, removed)
+	require.Equal(t, 1, s.Usage().Pieces)
+}
+
+func TestStoreCopyTo(t *testing.T) {
+	dir := t.TempDir()
+	_, v1Path := createCarFiles(t, dir)
+	pieceCid := testutil.GenerateCid()
+
+	s, err := New(Config{Dir: filepath.Join(dir, "flatstore")})
+	require.NoError(t, err)
+
+	dstPath := filepath.Join(dir, "inbound.car")
+	require.NoError(t, os.WriteFile(dstPath, nil, 0644))
+
+	// The store doesn't have the piece, so the destination is left as it was
+	copied, err := s.CopyTo(pieceCid, dstPath)
+	require.NoError(t, err)
+	require.False(t, copied)
+	fi, err := os.Stat(dstPath)
+	require.NoError(t, err)
+	require.Zero(t, fi.Size())
+
+	require.NoError(t, s.Retain(pieceCid, v1Path))
+	copied, err = s.CopyTo(pieceCid, dstPath)
+	require.NoError(t, err)
+	require.True(t, copied)
+
+	expected, err := os.ReadFile(v1Path)
+	require.NoError(t, err)
+	actual, err := os.ReadFile(dstPath)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+
+	// Removing the destination file doesn't affect the store
+	require.NoError(t, os.Remove(dstPath))
+	r, err := s.Open(pieceCid)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+}
+
+func TestStoreEviction(t *testing.T) {
+	dir := t.TempDir()
+	_, v1Path := createCarFiles(t, dir)
+	fi, err := os.Stat(v1Path)
+	require.NoError(t, err)
+	size := uint64(fi.Size())
+
+	t.Run("lru", func(t *testing.T) {
+		s, err := New(Config{Dir: t.TempDir(), MaxBytes: 2 * size, EvictionPolicy: EvictLRU})
+		require.NoError(t, err)
+
+		pieceCids := testutil.GenerateCids(3)
+		require.NoError(t, s.Retain(pieceCids[0], v1Path))
+		require.NoError(t, s.Retain(pieceCids[1], v1Path))
+
+		// Read the first piece so that the second piece is least recently used
+		r, err := s.Open(pieceCids[0])
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+
+		require.NoError(t, s.Retain(pieceCids[2], v1Path))
+		require.True(t, s.Has(pieceCids[0]))
+		require.False(t, s.Has(pieceCids[1]))
+		require.True(t, s.Has(pieceCids[2]))
+		require.Equal(t, 2*size, s.Usage().UsedBytes)
+	})
+
+	t.Run("fifo", func(t *testing.T) {
+		s, err := New(Config{Dir: t.TempDir(), MaxBytes: 2 * size, EvictionPolicy: EvictFIFO})
+		require.NoError(t, err)
+
+		pieceCids := testutil.GenerateCids(3)
+		require.NoError(t, s.Retain(pieceCids[0], v1Path))
+		require.NoError(t, s.Retain(pieceCids[1], v1Path))
+
+		r, err := s.Open(pieceCids[0])
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+
+		require.NoError(t, s.Retain(pieceCids[2], v1Path))
+		require.False(t, s.Has(pieceCids[0]))
+		require.True(t, s.Has(pieceCids[1]))
+		require.True(t, s.Has(pieceCids[2]))
+	})
+
+	t.Run("open pieces are not evicted", func(t *testing.T) {
+		s, err := New(Config{Dir: t.TempDir(), MaxBytes: size})
+		require.NoError(t, err)
+
+		pieceCids := testutil.GenerateCids(2)
+		require.NoError(t, s.Retain(pieceCids[0], v1Path))
+		r, err := s.Open(pieceCids[0])
+		require.NoError(t, err)
+
+		require.NoError(t, s.Retain(pieceCids[1], v1Path))
+		require.True(t, s.Has(pieceCids[0]))
+		require.True(t, s.Has(pieceCids[1]))
+
+		// Once the reader is closed the piece can be evicted
+		require.NoError(t, r.Close())
+		require.NoError(t, s.Retain(testutil.GenerateCid(), v1Path))
+		require.False(t, s.Has(pieceCids[0]))
+		require.False(t, s.Has(pieceCids[1]))
+	})
+
+	t.Run("piece larger than max size", func(t *testing.T) {
+		s, err := New(Config{Dir: t.TempDir(), MaxBytes: size - 1})
+		require.NoError(t, err)
+
+		pieceCid := testutil.GenerateCid()
+		require.Error(t, s.Retain(pieceCid, v1Path))
+		require.False(t, s.Has(pieceCid))
+	})
+}
+
+func TestStoreInvalidConfig(t *testing.T) {
+	_, err := New(Config{Dir: t.TempDir(), EvictionPolicy: "random"})
+	require.Error(t, err)
+
+	_, err = New(Config{})
+	require.Error(t, err)
+}
+
+// createCarFiles creates a CARv2 file and a CARv1 file with the same data
+func createCarFiles(t *testing.T, dir string) (string, string) {
+	randFile, err := testutil.CreateRandomFile(dir, 1, 256*1024)
+	require.NoError(t, err)
+	_, v2Path, err := testutil.CreateDenseCARv2(dir, randFile)
+	require.NoError(t, err)
+
+	rd, err := carv2.OpenReader(v2Path)
+	require.NoError(t, err)
+	defer rd.Close()
+	dr, err := rd.DataReader()
+	require.NoError(t, err)
+
+	v1Path := filepath.Join(dir, "v1.car")
+	f, err := os.Create(v1Path)
+	require.NoError(t, err)
+	_, err = io.Copy(f, dr)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	return v2Path, v1Path
+}
@@ -2,7 +2,6 @@ package storagemanager
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
 	"os"
@@ -33,8 +32,8 @@ type StorageManager struct {
 	StagingAreaDirPath string
 }
 
-func New(cfg Config) func(lr lotus_repo.LockedRepo, sqldb *sql.DB) (*StorageManager, error) {
-	return func(lr lotus_repo.LockedRepo, sqldb *sql.DB) (*StorageManager, error) {
+func New(cfg Config) func(lr lotus_repo.LockedRepo, storageDB *db.StorageDB) (*StorageManager, error) {
+	return func(lr lotus_repo.LockedRepo, storageDB *db.StorageDB) (*StorageManager, error) {
 		if cfg.MaxStagingDealsPercentPerHost > 100 {
 			return nil, fmt.Errorf("MaxStagingDealsPercentPerHost is %d but it must be a percentage between 0 - 100", cfg.MaxStagingDealsPercentPerHost)
 		}
@@ -46,7 +45,7 @@ func New(cfg Config) func(lr lotus_repo.LockedRepo, sqldb *sql.DB) (*StorageMana
 		}
 
 		return &StorageManager{
-			db:                 db.NewStorageDB(sqldb),
+			db:                 storageDB,
 			Cfg:                cfg,
 			lr:                 lr,
 			StagingAreaDirPath: stagingPath,
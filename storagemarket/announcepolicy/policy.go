@@ -0,0 +1,110 @@
+package announcepolicy
+
+import (
+	"fmt"
+
+	"github.com/filecoin-project/boost/storagemarket/dealrule"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+)
+
+// The actions that a policy can take for a deal
+const (
+	// Announce the deal to the network indexer as soon as it's indexed
+	ActionAnnounce = "announce"
+	// Don't announce the deal
+	ActionSkip = "skip"
+	// Announce the deal once the sector containing the deal has been sealed
+	ActionAfterSealing = "after-sealing"
+)
+
+// Rule matches deals by client and label. Empty fields match all deals.
+type Rule struct {
+	// Matches deals from any of these client addresses
+	Clients []string
+	// Matches deals with a string label that starts with this prefix
+	LabelPrefix string
+	Action      string
+}
+
+type Config struct {
+	// The action for deals that don't match any rule (announce if empty)
+	DefaultAction string
+	// Rules are checked in order; the first rule that matches a deal
+	// decides the action for the deal
+	Rules []Rule
+}
+
+// Decision is the result of applying the policy to a deal
+type Decision struct {
+	Action string
+	// Describes what decided the action, eg "rule 2"
+	Rule string
+}
+
+// Announce is true if the deal should be announced (now or later)
+func (d Decision) Announce() bool {
+	return d.Action != ActionSkip
+}
+
+// AfterSealing is true if the announcement should wait until the deal's
+// sector has been sealed
+func (d Decision) AfterSealing() bool {
+	return d.Action == ActionAfterSealing
+}
+
+func (d Decision) String() string {
+	return fmt.Sprintf("%s (%s)", d.Action, d.Rule)
+}
+
+type rule struct {
+	*dealrule.Matcher
+	action string
+}
+
+// Policy decides whether and when each deal is announced to the network
+// indexer
+type Policy struct {
+	defaultAction string
+	rules         []rule
+}
+
+func New(cfg Config) (*Policy, error) {
+	p := &Policy{defaultAction: cfg.DefaultAction}
+	if p.defaultAction == "" {
+		p.defaultAction = ActionAnnounce
+	}
+	if err := validateAction(p.defaultAction); err != nil {
+		return nil, fmt.Errorf("announce policy default action: %w", err)
+	}
+
+	for i, cr := range cfg.Rules {
+		if err := validateAction(cr.Action); err != nil {
+			return nil, fmt.Errorf("announce policy rule %d: %w", i+1, err)
+		}
+		m, err := dealrule.NewMatcher(dealrule.Match{Clients: cr.Clients, LabelPrefix: cr.LabelPrefix})
+		if err != nil {
+			return nil, fmt.Errorf("announce policy rule %d: %w", i+1, err)
+		}
+		p.rules = append(p.rules, rule{Matcher: m, action: cr.Action})
+	}
+	return p, nil
+}
+
+// Decide applies the policy to a deal proposal.
+// A deal is never announced if the client asked for it not to be.
+func (p *Policy) Decide(prop market.DealProposal, clientSkip bool) Decision {
+	if clientSkip {
+		return Decision{Action: ActionSkip, Rule: "requested by client"}
+	}
+
+	for i, r := range p.rules {
+		if r.Matches(prop) {
+			return Decision{Action: r.action, Rule: dealrule.Name(i)}
+		}
+	}
+	return Decision{Action: p.defaultAction, Rule: dealrule.Default}
+}
+
+func validateAction(action string) error {
+	return dealrule.ValidateChoice("action", action, ActionAnnounce, ActionSkip, ActionAfterSealing)
+}
@@ -0,0 +1,82 @@
+package announcepolicy
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy(t *testing.T) {
+	client1, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+	client2, err := address.NewIDAddress(1002)
+	require.NoError(t, err)
+
+	p, err := New(Config{
+		DefaultAction: ActionAfterSealing,
+		Rules: []Rule{{
+			Clients: []string{client1.String()},
+			Action:  ActionSkip,
+		}, {
+			LabelPrefix: "public/",
+			Action:      ActionAnnounce,
+		}},
+	})
+	require.NoError(t, err)
+
+	prop := func(client address.Address, label string) market.DealProposal {
+		l, err := market.NewLabelFromString(label)
+		require.NoError(t, err)
+		return market.DealProposal{Client: client, Label: l}
+	}
+
+	tcs := []struct {
+		name       string
+		prop       market.DealProposal
+		clientSkip bool
+		expected   Decision
+	}{{
+		name:       "client requested skip",
+		prop:       prop(client2, "public/data"),
+		clientSkip: true,
+		expected:   Decision{Action: ActionSkip, Rule: "requested by client"},
+	}, {
+		name:     "matches client rule",
+		prop:     prop(client1, "public/data"),
+		expected: Decision{Action: ActionSkip, Rule: "rule 1"},
+	}, {
+		name:     "matches label rule",
+		prop:     prop(client2, "public/data"),
+		expected: Decision{Action: ActionAnnounce, Rule: "rule 2"},
+	}, {
+		name:     "no matching rule",
+		prop:     prop(client2, "private/data"),
+		expected: Decision{Action: ActionAfterSealing, Rule: "default"},
+	}}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, p.Decide(tc.prop, tc.clientSkip))
+		})
+	}
+}
+
+func TestPolicyDefault(t *testing.T) {
+	p, err := New(Config{})
+	require.NoError(t, err)
+	d := p.Decide(market.DealProposal{}, false)
+	require.True(t, d.Announce())
+	require.False(t, d.AfterSealing())
+}
+
+func TestPolicyInvalid(t *testing.T) {
+	_, err := New(Config{DefaultAction: "sometimes"})
+	require.Error(t, err)
+
+	_, err = New(Config{Rules: []Rule{{Action: "sometimes"}}})
+	require.Error(t, err)
+
+	_, err = New(Config{Rules: []Rule{{Clients: []string{"not an address"}, Action: ActionSkip}}})
+	require.Error(t, err)
+}
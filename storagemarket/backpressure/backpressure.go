@@ -0,0 +1,297 @@
+package backpressure
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin"
+	"github.com/filecoin-project/lotus/api"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("backpressure")
+
+// The states of sectors that have finished sealing
+var sealedStates = []api.SectorState{"Proving", "Available"}
+
+// The bounds on the rate at which deal bytes are accepted, as a multiple of
+// the sealing throughput
+const (
+	minRateFactor = 0.1
+	maxRateFactor = 2.0
+)
+
+type Config struct {
+	// Whether to limit the rate at which deals are accepted
+	Enabled bool
+	// How often to sample the sealing pipeline
+	SampleInterval time.Duration
+	// The period over which sealing throughput is measured
+	ThroughputWindow time.Duration
+	// The backlog of deal data waiting to be sealed that the accept rate
+	// converges on, as an amount of sealing time
+	TargetBacklog time.Duration
+	// The amount of accept rate that can be used in a burst
+	BurstDuration time.Duration
+	// The lowest sealing throughput assumed, so that deals are still accepted
+	// when throughput can't be measured (eg before the first sector is sealed)
+	MinSectorsPerDay float64
+}
+
+// BacklogFunc returns the number of bytes of deal data that has been
+// accepted but not yet sealed
+type BacklogFunc func(ctx context.Context) (uint64, error)
+
+// Status describes the sealing throughput and the rate at which deal data
+// is being accepted
+type Status struct {
+	Enabled bool
+	// The sealing throughput measured over the throughput window
+	SectorsPerDay float64
+	// Whether the throughput window hasn't passed since boost started, in
+	// which case the throughput is a partial measurement
+	WarmingUp bool
+	// The bytes of deal data accepted but not yet sealed
+	BacklogBytes uint64
+	// The backlog that the accept rate converges on
+	TargetBacklogBytes uint64
+	// The sealing throughput in bytes per epoch
+	ThroughputBytesPerEpoch uint64
+	// The rate at which deal data is currently accepted
+	AllowedBytesPerEpoch uint64
+	// The bytes of deal data that can be accepted right now
+	AvailableBytes uint64
+}
+
+type sample struct {
+	at     time.Time
+	sealed int
+}
+
+// Limiter limits the rate at which deal data is accepted so that the
+// backlog of data waiting to be sealed converges on a target size.
+// It measures how many sectors the sealing pipeline seals per day, and
+// accepts deal data at that rate, scaled down when the backlog is above the
+// target and up when it's below the target.
+type Limiter struct {
+	cfg     Config
+	sps     sealingpipeline.API
+	backlog BacklogFunc
+	now     func() time.Time
+
+	lk         sync.Mutex
+	started    time.Time
+	sectorSize abi.SectorSize
+	samples    []sample
+	status     Status
+	tokens     float64
+	lastRefill time.Time
+}
+
+func New(cfg Config, sps sealingpipeline.API, backlog BacklogFunc) *Limiter {
+	if cfg.SampleInterval <= 0 {
+		cfg.SampleInterval = 10 * time.Minute
+	}
+	if cfg.ThroughputWindow <= 0 {
+		cfg.ThroughputWindow = 24 * time.Hour
+	}
+	if cfg.TargetBacklog <= 0 {
+		cfg.TargetBacklog = 24 * time.Hour
+	}
+	if cfg.BurstDuration <= 0 {
+		cfg.BurstDuration = time.Hour
+	}
+	if cfg.MinSectorsPerDay <= 0 {
+		cfg.MinSectorsPerDay = 1
+	}
+
+	return &Limiter{
+		cfg:     cfg,
+		sps:     sps,
+		backlog: backlog,
+		now:     time.Now,
+		status:  Status{Enabled: cfg.Enabled},
+	}
+}
+
+// Run samples the sealing pipeline until the context is cancelled
+func (l *Limiter) Run(ctx context.Context) {
+	if !l.cfg.Enabled {
+		return
+	}
+
+	l.lk.Lock()
+	l.started = l.now()
+	l.lk.Unlock()
+
+	ticker := time.NewTicker(l.cfg.SampleInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := l.sample(ctx); err != nil && ctx.Err() == nil {
+			log.Warnw("failed to sample sealing throughput", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sample records the number of sealed sectors, and updates the accept rate
+// from the throughput and the backlog
+func (l *Limiter) sample(ctx context.Context) error {
+	l.lk.Lock()
+	ssize := l.sectorSize
+	l.lk.Unlock()
+
+	if ssize == 0 {
+		maddr, err := l.sps.ActorAddress(ctx)
+		if err != nil {
+			return fmt.Errorf("getting miner address: %w", err)
+		}
+		ssize, err = l.sps.ActorSectorSize(ctx, maddr)
+		if err != nil {
+			return fmt.Errorf("getting sector size: %w", err)
+		}
+	}
+
+	summary, err := l.sps.SectorsSummary(ctx)
+	if err != nil {
+		return fmt.Errorf("getting sector summary: %w", err)
+	}
+	var sealed int
+	for _, st := range sealedStates {
+		sealed += summary[st]
+	}
+
+	backlog, err := l.backlog(ctx)
+	if err != nil {
+		return fmt.Errorf("getting deal backlog: %w", err)
+	}
+
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	now := l.now()
+	l.sectorSize = ssize
+	l.samples = append(l.samples, sample{at: now, sealed: sealed})
+	// Drop samples that are older than the throughput window, keeping one
+	// sample at the start of the window
+	for len(l.samples) > 2 && now.Sub(l.samples[1].at) >= l.cfg.ThroughputWindow {
+		l.samples = l.samples[1:]
+	}
+
+	l.update(now, backlog)
+	return nil
+}
+
+// update recalculates the accept rate. Must be called with the lock held.
+func (l *Limiter) update(now time.Time, backlog uint64) {
+	// Refill the tokens at the old rate up until now
+	l.refill(now)
+
+	sectorsPerDay := 0.0
+	first, last := l.samples[0], l.samples[len(l.samples)-1]
+	if elapsed := last.at.Sub(first.at); elapsed > 0 && last.sealed > first.sealed {
+		sectorsPerDay = float64(last.sealed-first.sealed) / elapsed.Hours() * 24
+	}
+
+	assumedPerDay := math.Max(sectorsPerDay, l.cfg.MinSectorsPerDay)
+	throughputPerEpoch := assumedPerDay * float64(l.sectorSize) / float64(builtin.EpochsInDay)
+	targetBacklog := throughputPerEpoch * l.cfg.TargetBacklog.Seconds() / builtin.EpochDurationSeconds
+
+	// Scale the accept rate so that the backlog converges on the target:
+	// accept less than the sealing throughput when the backlog is above the
+	// target, and more when it's below the target
+	factor := maxRateFactor
+	if backlog > 0 {
+		factor = math.Min(maxRateFactor, math.Max(minRateFactor, targetBacklog/float64(backlog)))
+	}
+	allowedPerEpoch := throughputPerEpoch * factor
+
+	l.status = Status{
+		Enabled:                 true,
+		SectorsPerDay:           sectorsPerDay,
+		WarmingUp:               now.Sub(l.started) < l.cfg.ThroughputWindow,
+		BacklogBytes:            backlog,
+		TargetBacklogBytes:      uint64(targetBacklog),
+		ThroughputBytesPerEpoch: uint64(throughputPerEpoch),
+		AllowedBytesPerEpoch:    uint64(allowedPerEpoch),
+	}
+
+	if l.lastRefill.IsZero() {
+		// Start with a full burst
+		l.lastRefill = now
+		l.tokens = l.burst()
+	}
+	l.tokens = math.Min(l.tokens, l.burst())
+
+	log.Debugw("updated deal accept rate", "sectors/day", sectorsPerDay, "backlog", backlog,
+		"target backlog", uint64(targetBacklog), "allowed bytes/epoch", uint64(allowedPerEpoch))
+}
+
+// refill adds the tokens accumulated at the current rate since the last
+// refill. Must be called with the lock held.
+func (l *Limiter) refill(now time.Time) {
+	if l.lastRefill.IsZero() {
+		return
+	}
+	elapsedEpochs := now.Sub(l.lastRefill).Seconds() / builtin.EpochDurationSeconds
+	l.tokens = math.Min(l.burst(), l.tokens+elapsedEpochs*float64(l.status.AllowedBytesPerEpoch))
+	l.lastRefill = now
+}
+
+// burst is the maximum number of tokens that can accumulate. It's at least
+// one sector, so that a deal for a full sector can always be accepted
+// eventually. Must be called with the lock held.
+func (l *Limiter) burst() float64 {
+	burstEpochs := l.cfg.BurstDuration.Seconds() / builtin.EpochDurationSeconds
+	return math.Max(float64(l.sectorSize), burstEpochs*float64(l.status.AllowedBytesPerEpoch))
+}
+
+// Allow checks whether a deal of the given size can be accepted, and if so
+// counts it against the accept rate
+func (l *Limiter) Allow(size abi.PaddedPieceSize) error {
+	if !l.cfg.Enabled {
+		return nil
+	}
+
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	// Wait for the first sample before limiting
+	if len(l.samples) == 0 {
+		return nil
+	}
+
+	l.refill(l.now())
+	if l.tokens < float64(size) {
+		return fmt.Errorf("deal size %d is more than the %d bytes available at the current accept rate of %d bytes per epoch "+
+			"(sealing backlog %d bytes, target backlog %d bytes)",
+			size, uint64(l.tokens), l.status.AllowedBytesPerEpoch, l.status.BacklogBytes, l.status.TargetBacklogBytes)
+	}
+	l.tokens -= float64(size)
+	l.status.BacklogBytes += uint64(size)
+	return nil
+}
+
+// Status returns the current sealing throughput and accept rate
+func (l *Limiter) Status() Status {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	if l.cfg.Enabled && len(l.samples) > 0 {
+		l.refill(l.now())
+	}
+	st := l.status
+	st.AvailableBytes = uint64(l.tokens)
+	return st
+}
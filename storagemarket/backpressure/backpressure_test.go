@@ -0,0 +1,89 @@
+package backpressure
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/storagemarket/sealingpipeline/mock"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	const sectorSize = abi.SectorSize(32 << 30)
+	maddr, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+
+	var sealed int
+	var backlog uint64
+	sps := mock.NewMockAPI(ctrl)
+	sps.EXPECT().ActorAddress(gomock.Any()).Return(maddr, nil).AnyTimes()
+	sps.EXPECT().ActorSectorSize(gomock.Any(), maddr).Return(sectorSize, nil).AnyTimes()
+	sps.EXPECT().SectorsSummary(gomock.Any()).DoAndReturn(func(ctx context.Context) (map[api.SectorState]int, error) {
+		return map[api.SectorState]int{"Proving": sealed, "PreCommit1": 5}, nil
+	}).AnyTimes()
+
+	now := time.Now()
+	l := New(Config{
+		Enabled:          true,
+		ThroughputWindow: 24 * time.Hour,
+		TargetBacklog:    24 * time.Hour,
+		BurstDuration:    time.Hour,
+	}, sps, func(ctx context.Context) (uint64, error) { return backlog, nil })
+	l.now = func() time.Time { return now }
+	l.started = now
+
+	// Deals are not limited before the first sample
+	require.NoError(t, l.Allow(abi.PaddedPieceSize(sectorSize)))
+
+	// Seal 10 sectors in 12 hours: 20 sectors per day
+	sealed = 100
+	require.NoError(t, l.sample(ctx))
+	now = now.Add(12 * time.Hour)
+	sealed = 110
+	// The backlog is two days of sealing, so deals should be accepted at half
+	// the sealing throughput
+	backlog = 40 * uint64(sectorSize)
+	require.NoError(t, l.sample(ctx))
+
+	st := l.Status()
+	require.InDelta(t, 20, st.SectorsPerDay, 0.001)
+	require.True(t, st.WarmingUp)
+	require.EqualValues(t, 20*uint64(sectorSize), st.TargetBacklogBytes)
+	require.EqualValues(t, 20*uint64(sectorSize)/builtin.EpochsInDay, st.ThroughputBytesPerEpoch)
+	require.EqualValues(t, 10*uint64(sectorSize)/builtin.EpochsInDay, st.AllowedBytesPerEpoch)
+
+	// The burst is one sector (the burst duration's worth of accept rate is
+	// less than a sector)
+	require.EqualValues(t, sectorSize, st.AvailableBytes)
+	require.NoError(t, l.Allow(abi.PaddedPieceSize(sectorSize)))
+	require.Error(t, l.Allow(abi.PaddedPieceSize(sectorSize)))
+
+	// After an hour, an hour's worth of accept rate is available
+	now = now.Add(time.Hour)
+	st = l.Status()
+	require.InDelta(t, float64(10*uint64(sectorSize))/24, float64(st.AvailableBytes), float64(sectorSize)/1000)
+	require.NoError(t, l.Allow(abi.PaddedPieceSize(sectorSize/4)))
+
+	// When the backlog is below the target, accept deals faster than
+	// sealing throughput
+	backlog = 0
+	now = now.Add(time.Hour)
+	require.NoError(t, l.sample(ctx))
+	st = l.Status()
+	require.EqualValues(t, maxRateFactor*st.ThroughputBytesPerEpoch, st.AllowedBytesPerEpoch)
+
+	t.Run("disabled", func(t *testing.T) {
+		l := New(Config{}, sps, func(ctx context.Context) (uint64, error) { return 0, nil })
+		require.NoError(t, l.Allow(abi.PaddedPieceSize(sectorSize)))
+		require.False(t, l.Status().Enabled)
+	})
+}
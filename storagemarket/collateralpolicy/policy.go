@@ -0,0 +1,94 @@
+package collateralpolicy
+
+import (
+	"fmt"
+
+	"github.com/filecoin-project/boost/storagemarket/dealrule"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+)
+
+// The values for matching deals by verified status
+const (
+	// Matches verified deals only
+	VerifiedOnly = dealrule.VerifiedOnly
+	// Matches unverified deals only
+	UnverifiedOnly = dealrule.UnverifiedOnly
+)
+
+// Rule matches deals by client, verified status and piece size. Empty
+// fields match all deals.
+type Rule struct {
+	// Matches deals from any of these client addresses
+	Clients []string
+	// Matches "verified" or "unverified" deals
+	Verified string
+	// Matches deals with a piece size of at least MinPieceSize
+	MinPieceSize abi.PaddedPieceSize
+	// Matches deals with a piece size of at most MaxPieceSize
+	MaxPieceSize abi.PaddedPieceSize
+	// The wallet that funds the collateral for matching deals
+	Wallet string
+}
+
+type Config struct {
+	// The wallet for deals that don't match any rule
+	DefaultWallet address.Address
+	// Rules are checked in order; the first rule that matches a deal
+	// decides the collateral wallet for the deal
+	Rules []Rule
+}
+
+// Decision is the result of applying the policy to a deal
+type Decision struct {
+	Wallet address.Address
+	// Describes what decided the wallet, eg "rule 2"
+	Rule string
+}
+
+func (d Decision) String() string {
+	return fmt.Sprintf("%s (%s)", d.Wallet, d.Rule)
+}
+
+type rule struct {
+	*dealrule.Matcher
+	wallet address.Address
+}
+
+// Policy chooses the wallet that funds the collateral for each deal
+type Policy struct {
+	defaultWallet address.Address
+	rules         []rule
+}
+
+func New(cfg Config) (*Policy, error) {
+	p := &Policy{defaultWallet: cfg.DefaultWallet}
+	for i, cr := range cfg.Rules {
+		wallet, err := address.NewFromString(cr.Wallet)
+		if err != nil {
+			return nil, fmt.Errorf("deal collateral rule %d: parsing wallet address '%s': %w", i+1, cr.Wallet, err)
+		}
+		m, err := dealrule.NewMatcher(dealrule.Match{
+			Clients:      cr.Clients,
+			Verified:     cr.Verified,
+			MinPieceSize: cr.MinPieceSize,
+			MaxPieceSize: cr.MaxPieceSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("deal collateral rule %d: %w", i+1, err)
+		}
+		p.rules = append(p.rules, rule{Matcher: m, wallet: wallet})
+	}
+	return p, nil
+}
+
+// Decide applies the policy to a deal proposal
+func (p *Policy) Decide(prop market.DealProposal) Decision {
+	for i, r := range p.rules {
+		if r.Matches(prop) {
+			return Decision{Wallet: r.wallet, Rule: dealrule.Name(i)}
+		}
+	}
+	return Decision{Wallet: p.defaultWallet, Rule: dealrule.Default}
+}
@@ -0,0 +1,90 @@
+package collateralpolicy
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy(t *testing.T) {
+	client1, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+	client2, err := address.NewIDAddress(1002)
+	require.NoError(t, err)
+	walletA, err := address.NewIDAddress(2001)
+	require.NoError(t, err)
+	walletB, err := address.NewIDAddress(2002)
+	require.NoError(t, err)
+	walletC, err := address.NewIDAddress(2003)
+	require.NoError(t, err)
+	walletDefault, err := address.NewIDAddress(2000)
+	require.NoError(t, err)
+
+	p, err := New(Config{
+		DefaultWallet: walletDefault,
+		Rules: []Rule{{
+			Clients: []string{client1.String()},
+			Wallet:  walletA.String(),
+		}, {
+			Verified: VerifiedOnly,
+			Wallet:   walletB.String(),
+		}, {
+			MinPieceSize: 1 << 30,
+			MaxPieceSize: 32 << 30,
+			Wallet:       walletC.String(),
+		}},
+	})
+	require.NoError(t, err)
+
+	prop := func(client address.Address, verified bool, size abi.PaddedPieceSize) market.DealProposal {
+		return market.DealProposal{Client: client, VerifiedDeal: verified, PieceSize: size}
+	}
+
+	tcs := []struct {
+		name     string
+		prop     market.DealProposal
+		expected Decision
+	}{{
+		name:     "matches client rule",
+		prop:     prop(client1, true, 1<<30),
+		expected: Decision{Wallet: walletA, Rule: "rule 1"},
+	}, {
+		name:     "matches verified rule",
+		prop:     prop(client2, true, 1<<30),
+		expected: Decision{Wallet: walletB, Rule: "rule 2"},
+	}, {
+		name:     "matches piece size rule",
+		prop:     prop(client2, false, 1<<30),
+		expected: Decision{Wallet: walletC, Rule: "rule 3"},
+	}, {
+		name:     "piece too small",
+		prop:     prop(client2, false, 1<<20),
+		expected: Decision{Wallet: walletDefault, Rule: "default"},
+	}, {
+		name:     "piece too big",
+		prop:     prop(client2, false, 64<<30),
+		expected: Decision{Wallet: walletDefault, Rule: "default"},
+	}}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, p.Decide(tc.prop))
+		})
+	}
+}
+
+func TestPolicyInvalid(t *testing.T) {
+	_, err := New(Config{Rules: []Rule{{Wallet: "not an address"}}})
+	require.Error(t, err)
+
+	_, err = New(Config{Rules: []Rule{{Wallet: "f01000", Verified: "sometimes"}}})
+	require.Error(t, err)
+
+	_, err = New(Config{Rules: []Rule{{Wallet: "f01000", MinPieceSize: 2048, MaxPieceSize: 1024}}})
+	require.Error(t, err)
+
+	_, err = New(Config{Rules: []Rule{{Wallet: "f01000", Clients: []string{"not an address"}}}})
+	require.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package storagemarket
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -13,13 +14,17 @@ import (
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/ipfs/go-cid"
 	carv2 "github.com/ipld/go-car/v2"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var ErrCommpMismatch = fmt.Errorf("commp mismatch")
 
 // Verify that the commp provided in the deal proposal matches commp calculated
 // over the downloaded file
-func (p *Provider) verifyCommP(deal *types.ProviderDealState) *dealMakingError {
+func (p *Provider) verifyCommP(ctx context.Context, deal *types.ProviderDealState) (dmerr *dealMakingError) {
+	_, span := startDealSpan(ctx, deal.DealUuid, "Provider.verifyCommP", attribute.Bool("remoteCommp", p.config.RemoteCommp))
+	defer func() { endDealSpan(span, dmerr) }()
+
 	p.dealLogger.Infow(deal.DealUuid, "checking commP")
 	pieceCid, err := p.generatePieceCommitment(deal.InboundFilePath, deal.ClientDealProposal.Proposal.PieceSize)
 	if err != nil {
@@ -73,6 +78,11 @@ func (p *Provider) generatePieceCommitment(filepath string, pieceSize abi.Padded
 		}
 	}
 
+	return padCommP(pi, pieceSize)
+}
+
+// padCommP pads the piece commitment so that it fills the piece size
+func padCommP(pi *abi.PieceInfo, pieceSize abi.PaddedPieceSize) (cid.Cid, *dealMakingError) {
 	// if the data does not fill the whole piece
 	if pi.Size < pieceSize {
 		// pad the data so that it fills the piece
@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"runtime/debug"
 	"time"
 
+	"github.com/filecoin-project/boost/metrics"
+	"github.com/filecoin-project/boost/storagemarket/sectorpacking"
 	"github.com/filecoin-project/boost/storagemarket/types"
 	smtypes "github.com/filecoin-project/boost/storagemarket/types"
 	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
@@ -15,6 +18,7 @@ import (
 	transporttypes "github.com/filecoin-project/boost/transport/types"
 	"github.com/filecoin-project/dagstore"
 	"github.com/filecoin-project/go-fil-markets/piecestore"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	"github.com/filecoin-project/go-fil-markets/stores"
 	"github.com/filecoin-project/go-padreader"
 	"github.com/filecoin-project/go-state-types/abi"
@@ -22,8 +26,13 @@ import (
 	lapi "github.com/filecoin-project/lotus/api"
 	sealing "github.com/filecoin-project/lotus/storage/pipeline"
 	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
 	carv2 "github.com/ipld/go-car/v2"
+	provider "github.com/ipni/index-provider"
 	"github.com/libp2p/go-libp2p/core/event"
+	"go.opencensus.io/stats"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -118,7 +127,11 @@ func (p *Provider) execDeal(deal *smtypes.ProviderDealState, dh *dealHandler) (d
 	}
 
 	// Execute the deal synchronously
-	if derr := p.execDealUptoAddPiece(dh.providerCtx, deal, dh); derr != nil {
+	ctx, span := startDealSpan(p.proposalSpans.parent(dh.providerCtx, deal.DealUuid), deal.DealUuid, "Provider.execDeal",
+		attribute.String("checkpoint", deal.Checkpoint.String()), attribute.Bool("offline", deal.IsOffline))
+	derr := p.execDealUptoAddPiece(ctx, deal, dh)
+	endDealSpan(span, derr)
+	if derr != nil {
 		return derr
 	}
 
@@ -129,10 +142,14 @@ func (p *Provider) execDeal(deal *smtypes.ProviderDealState, dh *dealHandler) (d
 
 	// Watch the sealing status of the deal and fire events for each change
 	p.dealLogger.Infow(deal.DealUuid, "watching deal sealing state changes")
-	p.fireSealingUpdateEvents(dh, deal.DealUuid, deal.SectorID)
+	state := p.fireSealingUpdateEvents(dh, deal.DealUuid, deal.SectorID)
 	p.cleanupDealHandler(deal.DealUuid)
 	p.dealLogger.Infow(deal.DealUuid, "deal sealing reached termination state")
 
+	if state != "" {
+		p.announceAfterSealing(deal, state)
+	}
+
 	// TODO
 	// Watch deal on chain and change state in DB and emit notifications.
 	return nil
@@ -155,15 +172,34 @@ func (p *Provider) execDealUptoAddPiece(ctx context.Context, deal *types.Provide
 				return derr
 			}
 
-			if err := p.transferAndVerify(dh, pub, deal); err != nil {
-				// The transfer has failed. If the user tries to cancel the
-				// transfer after this point it's a no-op.
-				dh.setCancelTransferResponse(nil)
+			if p.copyExistingPiece(ctx, deal) {
+				p.dealLogger.Infow(deal.DealUuid, "skipped deal data transfer: piece data is already on the node",
+					"pieceCid", deal.ClientDealProposal.Proposal.PieceCID)
+				if derr := p.updateCheckpoint(pub, deal, dealcheckpoints.Transferred); derr != nil {
+					return derr
+				}
+			} else if p.streamAddPieceEnabled(deal) {
+				// Verify the data before publishing the deal, so that a deal
+				// with the wrong data is never published
+				if err := p.verifyStreamedData(ctx, pub, deal); err != nil {
+					dh.setCancelTransferResponse(nil)
+					return err
+				}
+				if derr := p.updateCheckpoint(pub, deal, dealcheckpoints.Transferred); derr != nil {
+					return derr
+				}
+				p.dealLogger.Infow(deal.DealUuid, "deal data will be streamed into a sector after the deal is published")
+			} else {
+				if err := p.transferAndVerify(ctx, dh, pub, deal); err != nil {
+					// The transfer has failed. If the user tries to cancel the
+					// transfer after this point it's a no-op.
+					dh.setCancelTransferResponse(nil)
+
+					return err
+				}
 
-				return err
+				p.dealLogger.Infow(deal.DealUuid, "deal data transfer finished successfully")
 			}
-
-			p.dealLogger.Infow(deal.DealUuid, "deal data transfer finished successfully")
 		} else {
 			p.dealLogger.Infow(deal.DealUuid, "deal data transfer has already been completed")
 		}
@@ -172,7 +208,7 @@ func (p *Provider) execDealUptoAddPiece(ctx context.Context, deal *types.Provide
 		p.dealLogger.Infow(deal.DealUuid, "deal data-transfer can no longer be cancelled")
 	} else if deal.Checkpoint < dealcheckpoints.Transferred {
 		// verify CommP matches for an offline deal
-		if err := p.verifyCommP(deal); err != nil {
+		if err := p.verifyCommP(ctx, deal); err != nil {
 			err.error = fmt.Errorf("error when matching commP for imported data for offline deal: %w", err)
 			return err
 		}
@@ -207,11 +243,28 @@ func (p *Provider) execDealUptoAddPiece(ctx context.Context, deal *types.Provide
 
 	// AddPiece
 	if deal.Checkpoint < dealcheckpoints.AddedPiece {
-		if err := p.addPiece(ctx, pub, deal); err != nil {
-			err.error = fmt.Errorf("failed to add piece: %w", err.error)
-			return err
+		if p.isStreamedDeal(deal) {
+			if err := p.streamAddPiece(ctx, pub, deal); err != nil {
+				err.error = fmt.Errorf("failed to stream piece: %w", err.error)
+				return err
+			}
+			p.dealLogger.Infow(deal.DealUuid, "deal data successfully streamed to the sealing subsystem")
+		} else if p.publishedWithoutData(deal) {
+			// The deal was accepted with streaming enabled, but streaming has
+			// since been disabled, so there is no staged data to add
+			return &dealMakingError{
+				retry: types.DealRetryManual,
+				error: errors.New("deal was published without staging its data because streaming add piece was enabled: " +
+					"enable StreamingAddPiece and retry the deal to stream its data into a sector"),
+			}
+		} else {
+			if err := p.addPiece(ctx, pub, deal); err != nil {
+				err.error = fmt.Errorf("failed to add piece: %w", err.error)
+				return err
+			}
+			p.dealLogger.Infow(deal.DealUuid, "deal successfully handed over to the sealing subsystem")
+			p.retainPieceCopy(deal)
 		}
-		p.dealLogger.Infow(deal.DealUuid, "deal successfully handed over to the sealing subsystem")
 	} else {
 		p.dealLogger.Infow(deal.DealUuid, "deal has already been handed over to the sealing subsystem")
 	}
@@ -235,7 +288,7 @@ func (p *Provider) execDealUptoAddPiece(ctx context.Context, deal *types.Provide
 			err.error = fmt.Errorf("failed to add index and announce deal: %w", err.error)
 			return err
 		}
-		if deal.AnnounceToIPNI {
+		if deal.AnnounceToIPNI && !deal.AnnounceAfterSealing {
 			p.dealLogger.Infow(deal.DealUuid, "deal successfully indexed and announced")
 		} else {
 			p.dealLogger.Infow(deal.DealUuid, "deal successfully indexed")
@@ -247,6 +300,22 @@ func (p *Provider) execDealUptoAddPiece(ctx context.Context, deal *types.Provide
 	return nil
 }
 
+// retainPieceCopy keeps a copy of the deal data in the piece copy store, if
+// one is configured, so that the piece can be retrieved without unsealing
+func (p *Provider) retainPieceCopy(deal *types.ProviderDealState) {
+	if p.pieceCopies == nil {
+		return
+	}
+
+	pieceCid := deal.ClientDealProposal.Proposal.PieceCID
+	if err := p.pieceCopies.Retain(pieceCid, deal.InboundFilePath); err != nil {
+		// Retrieval can still be served from the sealer, so just log the error
+		p.dealLogger.Warnw(deal.DealUuid, "failed to retain copy of deal data", "pieceCid", pieceCid, "err", err)
+		return
+	}
+	p.dealLogger.Infow(deal.DealUuid, "retained copy of deal data", "pieceCid", pieceCid)
+}
+
 func (p *Provider) untagStorageSpaceAfterSealing(ctx context.Context, deal *types.ProviderDealState) error {
 	presp := make(chan struct{}, 1)
 	select {
@@ -278,9 +347,67 @@ func (p *Provider) untagFundsAfterPublish(ctx context.Context, deal *types.Provi
 	}
 }
 
-func (p *Provider) transferAndVerify(dh *dealHandler, pub event.Emitter, deal *smtypes.ProviderDealState) *dealMakingError {
-	// Use a context specifically for transfers, that can be cancelled by the user
-	ctx := dh.transferCtx
+// copyExistingPiece checks if the deal's piece is already indexed and has a
+// copy of its data on the node (eg because another client made a deal for a
+// replica of the same data). If so it copies the data to the deal's inbound
+// file so that it doesn't need to be transferred again.
+// The piece's existing index is used when the deal is indexed.
+func (p *Provider) copyExistingPiece(ctx context.Context, deal *types.ProviderDealState) bool {
+	if !p.config.DeduplicatePieces || p.existingPieces == nil {
+		return false
+	}
+
+	pieceCid := deal.ClientDealProposal.Proposal.PieceCID
+	pi, err := p.ps.GetPieceInfo(pieceCid)
+	if err != nil {
+		if !errors.Is(err, retrievalmarket.ErrNotFound) {
+			p.dealLogger.Warnw(deal.DealUuid, "failed to check piece store for existing piece", "pieceCid", pieceCid, "err", err)
+		}
+		return false
+	}
+	if len(pi.Deals) == 0 || !p.pieceIndexed(pieceCid) {
+		return false
+	}
+
+	copied, err := p.existingPieces.CopyTo(ctx, pieceCid, deal.Transfer.Size, deal.InboundFilePath)
+	if err != nil {
+		p.dealLogger.Warnw(deal.DealUuid, "failed to copy existing piece data", "pieceCid", pieceCid, "err", err)
+		return false
+	}
+	if !copied {
+		return false
+	}
+
+	fi, err := os.Stat(deal.InboundFilePath)
+	if err != nil {
+		p.dealLogger.Warnw(deal.DealUuid, "failed to get size of copied piece data", "pieceCid", pieceCid, "err", err)
+		return false
+	}
+	if uint64(fi.Size()) != deal.Transfer.Size {
+		// Fall back to transferring the data from the client
+		p.dealLogger.Warnw(deal.DealUuid, "copied piece data does not match the deal's transfer size",
+			"pieceCid", pieceCid, "copied", fi.Size(), "transfer size", deal.Transfer.Size)
+		return false
+	}
+	deal.NBytesReceived = fi.Size()
+	return true
+}
+
+// pieceIndexed indicates whether the dagstore already has an index for the
+// piece
+func (p *Provider) pieceIndexed(pieceCid cid.Cid) bool {
+	idx, err := p.dagst.GetIterableIndexForPiece(pieceCid)
+	return err == nil && idx != nil
+}
+
+func (p *Provider) transferAndVerify(ctx context.Context, dh *dealHandler, pub event.Emitter, deal *smtypes.ProviderDealState) (dmerr *dealMakingError) {
+	ctx, span := startDealSpan(ctx, deal.DealUuid, "Provider.transferAndVerify",
+		attribute.String("transferType", deal.Transfer.Type), attribute.Int64("transferSize", int64(deal.Transfer.Size)))
+	defer func() { endDealSpan(span, dmerr) }()
+
+	// Use a context specifically for transfers, that can be cancelled by the
+	// user (with the trace span from the deal execution context)
+	ctx = trace.ContextWithSpan(dh.transferCtx, span)
 
 	p.dealLogger.Infow(deal.DealUuid, "deal queued for transfer", "transfer client id", deal.Transfer.ClientID)
 
@@ -311,6 +438,11 @@ func (p *Provider) transferAndVerify(dh *dealHandler, pub event.Emitter, deal *s
 	}
 	defer p.xferLimiter.complete(deal.DealUuid)
 
+	span.AddEvent("transfer started")
+	mctx := transferMetricsCtx(ctx, deal)
+	// The host was already validated when the transfer was queued
+	host, _ := deal.Transfer.Host()
+	startReceived := deal.NBytesReceived
 	p.dealLogger.Infow(deal.DealUuid, "start deal data transfer", "transfer client id", deal.Transfer.ClientID)
 	transferStart := time.Now()
 	tctx, cancel := context.WithDeadline(ctx, transferStart.Add(p.config.MaxTransferDuration))
@@ -330,7 +462,7 @@ func (p *Provider) transferAndVerify(dh *dealHandler, pub event.Emitter, deal *s
 	}
 
 	// wait for data-transfer to finish
-	if err := p.waitForTransferFinish(tctx, handler, pub, deal); err != nil {
+	if err := p.waitForTransferFinish(tctx, handler, pub, deal, host); err != nil {
 		// If the transfer failed because the user cancelled the
 		// transfer, it's non-recoverable
 		if dh.TransferCancelledByUser() {
@@ -352,6 +484,7 @@ func (p *Provider) transferAndVerify(dh *dealHandler, pub event.Emitter, deal *s
 		// Note that the data transfer has automatic retries built in, so if
 		// it fails, it means it's already retried several times and we should
 		// fail the deal
+		p.xferTelemetry.failed(host)
 		return &dealMakingError{
 			retry: smtypes.DealRetryFatal,
 			error: fmt.Errorf("data-transfer failed: %w", err),
@@ -363,9 +496,15 @@ func (p *Provider) transferAndVerify(dh *dealHandler, pub event.Emitter, deal *s
 
 	p.dealLogger.Infow(deal.DealUuid, "deal data-transfer completed successfully", "bytes received", deal.NBytesReceived, "time taken",
 		time.Since(st).String())
+	// Only count the bytes received since the transfer was (re)started
+	received := deal.NBytesReceived - startReceived
+	if secs := time.Since(st).Seconds(); secs > 0 {
+		stats.Record(mctx, metrics.TransferThroughput.M(float64(received)/secs))
+	}
+	p.xferTelemetry.completed(host, uint64(received), time.Since(st))
 
 	// Verify CommP matches
-	if err := p.verifyCommP(deal); err != nil {
+	if err := p.verifyCommP(ctx, deal); err != nil {
 		err.error = fmt.Errorf("failed to verify CommP: %w", err.error)
 		return err
 	}
@@ -376,10 +515,22 @@ func (p *Provider) transferAndVerify(dh *dealHandler, pub event.Emitter, deal *s
 
 const OneGib = 1024 * 1024 * 1024
 
-func (p *Provider) waitForTransferFinish(ctx context.Context, handler transport.Handler, pub event.Emitter, deal *types.ProviderDealState) error {
+func (p *Provider) waitForTransferFinish(ctx context.Context, handler transport.Handler, pub event.Emitter, deal *types.ProviderDealState, host string) error {
 	defer handler.Close()
 	defer p.transfers.complete(deal.DealUuid)
 
+	// Count the bytes received, retries and stalls by the transfer type and
+	// host
+	mctx := transferMetricsCtx(ctx, deal)
+	lastReceived := deal.NBytesReceived
+	var lastRetries int
+
+	// Periodically check if the transfer has stalled, and count each stall
+	// once (until the transfer makes progress again)
+	stallTicker := time.NewTicker(p.xferLimiter.cfg.StallCheckPeriod)
+	defer stallTicker.Stop()
+	stalled := false
+
 	// log transfer progress to the deal log every 10% or every GiB
 	var lastOutput int64
 	logTransferProgress := func(received int64) {
@@ -410,18 +561,40 @@ func (p *Provider) waitForTransferFinish(ctx context.Context, handler transport.
 				return evt.Error
 			}
 			deal.NBytesReceived = evt.NBytesReceived
+			if delta := deal.NBytesReceived - lastReceived; delta > 0 {
+				stats.Record(mctx, metrics.TransferBytesReceived.M(delta))
+				p.xferTelemetry.addBytes(host, uint64(delta))
+				lastReceived = deal.NBytesReceived
+				stalled = false
+			}
+			if delta := evt.Retries - lastRetries; delta > 0 {
+				stats.Record(mctx, metrics.TransferRetryCount.M(int64(delta)))
+				p.xferTelemetry.addRetries(host, delta)
+				lastRetries = evt.Retries
+			}
 			p.transfers.setBytes(deal.DealUuid, uint64(evt.NBytesReceived))
 			p.xferLimiter.setBytes(deal.DealUuid, uint64(evt.NBytesReceived))
 			p.fireEventDealUpdate(pub, deal)
 			logTransferProgress(deal.NBytesReceived)
 
+		case <-stallTicker.C:
+			if !stalled && p.xferLimiter.isStalled(deal.DealUuid) {
+				stalled = true
+				stats.Record(mctx, metrics.TransferStallCount.M(1))
+				p.xferTelemetry.addStall(host)
+				p.dealLogger.Warnw(deal.DealUuid, "transfer stalled", "host", host, "bytes received", deal.NBytesReceived)
+			}
+
 		case <-ctx.Done():
 			return ctx.Err()
 		}
 	}
 }
 
-func (p *Provider) publishDeal(ctx context.Context, pub event.Emitter, deal *types.ProviderDealState) *dealMakingError {
+func (p *Provider) publishDeal(ctx context.Context, pub event.Emitter, deal *types.ProviderDealState) (dmerr *dealMakingError) {
+	ctx, span := startDealSpan(ctx, deal.DealUuid, "Provider.publishDeal")
+	defer func() { endDealSpan(span, dmerr) }()
+
 	// Check that the deal's start epoch hasn't already elapsed
 	if derr := p.checkDealProposalStartEpoch(deal); derr != nil {
 		return derr
@@ -515,12 +688,16 @@ func (p *Provider) publishDeal(ctx context.Context, pub event.Emitter, deal *typ
 	if derr := p.updateCheckpoint(pub, deal, dealcheckpoints.PublishConfirmed); derr != nil {
 		return derr
 	}
+	p.recordPublishMovements(ctx, deal)
 
 	return nil
 }
 
 // addPiece hands off a published deal for sealing and commitment in a sector
-func (p *Provider) addPiece(ctx context.Context, pub event.Emitter, deal *types.ProviderDealState) *dealMakingError {
+func (p *Provider) addPiece(ctx context.Context, pub event.Emitter, deal *types.ProviderDealState) (dmerr *dealMakingError) {
+	ctx, span := startDealSpan(ctx, deal.DealUuid, "Provider.addPiece")
+	defer func() { endDealSpan(span, dmerr) }()
+
 	// Check that the deal's start epoch hasn't already elapsed
 	if derr := p.checkDealProposalStartEpoch(deal); derr != nil {
 		return derr
@@ -574,9 +751,34 @@ func (p *Provider) addPiece(ctx context.Context, pub event.Emitter, deal *types.
 		}
 	}
 
+	return p.addPieceData(ctx, pub, deal, paddedReader)
+}
+
+// addPieceData adds the deal's piece data to a sector. The reader must
+// deliver exactly the unpadded piece size.
+func (p *Provider) addPieceData(ctx context.Context, pub event.Emitter, deal *types.ProviderDealState, paddedReader io.Reader) *dealMakingError {
+	proposal := deal.ClientDealProposal.Proposal
+
+	// Wait for the deal's turn to be added to a sector
+	packed, err := p.sectorPacker.Acquire(ctx, sectorpacking.Piece{
+		DealUuid: deal.DealUuid,
+		Size:     proposal.PieceSize,
+		EndEpoch: proposal.EndEpoch,
+	})
+	if err != nil {
+		return &dealMakingError{
+			retry: types.DealRetryAuto,
+			error: fmt.Errorf("waiting to pack piece %s: %w", proposal.PieceCID, err),
+		}
+	}
+
+	// Choose whether to add the piece to a new sector or a CC sector
+	sectorPath := p.chooseSectorPath(ctx, deal)
+
 	// Add the piece to a sector
 	packingInfo, packingErr := p.AddPieceToSector(ctx, *deal, paddedReader)
 	if packingErr != nil {
+		packed(0, packingErr)
 		if ctx.Err() != nil {
 			p.dealLogger.Warnw(deal.DealUuid, "context timed out while trying to add piece")
 		}
@@ -587,6 +789,9 @@ func (p *Provider) addPiece(ctx context.Context, pub event.Emitter, deal *types.
 		}
 	}
 
+	packed(packingInfo.SectorNumber, nil)
+	p.logSectorPath(ctx, deal, sectorPath, packingInfo.SectorNumber)
+
 	deal.SectorID = packingInfo.SectorNumber
 	deal.Offset = packingInfo.Offset
 	deal.Length = packingInfo.Size
@@ -600,7 +805,11 @@ func (p *Provider) addPiece(ctx context.Context, pub event.Emitter, deal *types.
 	return nil
 }
 
-func (p *Provider) indexAndAnnounce(ctx context.Context, pub event.Emitter, deal *types.ProviderDealState) *dealMakingError {
+func (p *Provider) indexAndAnnounce(ctx context.Context, pub event.Emitter, deal *types.ProviderDealState) (dmerr *dealMakingError) {
+	ctx, span := startDealSpan(ctx, deal.DealUuid, "Provider.indexAndAnnounce",
+		attribute.Bool("announce", deal.AnnounceToIPNI && !deal.AnnounceAfterSealing))
+	defer func() { endDealSpan(span, dmerr) }()
+
 	pc := deal.ClientDealProposal.Proposal.PieceCID
 	propCid, err := deal.ClientDealProposal.Proposal.Cid()
 	if err != nil {
@@ -624,10 +833,11 @@ func (p *Provider) indexAndAnnounce(ctx context.Context, pub event.Emitter, deal
 	}
 	p.dealLogger.Infow(deal.DealUuid, "deal successfully added to piecestore")
 
-	// register with dagstore
-	err = stores.RegisterShardSync(ctx, p.dagst, pc, "", true)
-
-	if err != nil {
+	// register with dagstore, unless the piece has already been indexed (eg
+	// because the deal is for another replica of a piece on the node)
+	if p.pieceIndexed(pc) {
+		p.dealLogger.Infow(deal.DealUuid, "piece has already been indexed in the dagstore")
+	} else if err := stores.RegisterShardSync(ctx, p.dagst, pc, "", true); err != nil {
 		if !errors.Is(err, dagstore.ErrShardExists) {
 			return &dealMakingError{
 				retry: types.DealRetryAuto,
@@ -641,19 +851,15 @@ func (p *Provider) indexAndAnnounce(ctx context.Context, pub event.Emitter, deal
 
 	// if the index provider is enabled
 	if p.ip.Enabled() {
-		if deal.AnnounceToIPNI {
-			// announce to the network indexer but do not fail the deal if the announcement fails,
-			// just retry the next time boost restarts
-			annCid, err := p.ip.AnnounceBoostDeal(ctx, deal)
-			if err != nil {
-				return &dealMakingError{
-					retry: types.DealRetryAuto,
-					error: fmt.Errorf("failed to announce deal to network indexer: %w", err),
-				}
+		if !deal.AnnounceToIPNI {
+			p.dealLogger.Infow(deal.DealUuid, "didn't announce deal because of the announce policy", "decision", deal.AnnounceRule)
+		} else if deal.AnnounceAfterSealing {
+			p.dealLogger.Infow(deal.DealUuid, "deal will be announced to network indexer after the sector is sealed", "decision", deal.AnnounceRule)
+		} else if err := p.announceDeal(ctx, deal); err != nil {
+			return &dealMakingError{
+				retry: types.DealRetryAuto,
+				error: err,
 			}
-			p.dealLogger.Infow(deal.DealUuid, "announced deal to network indexer", "announcement-cid", annCid)
-		} else {
-			p.dealLogger.Infow(deal.DealUuid, "didn't announce deal as requested in the deal proposal")
 		}
 	} else {
 		p.dealLogger.Infow(deal.DealUuid, "didn't announce deal because network indexer is disabled")
@@ -666,9 +872,49 @@ func (p *Provider) indexAndAnnounce(ctx context.Context, pub event.Emitter, deal
 	return nil
 }
 
+// announceDeal announces the deal to the network indexer. If the announcement
+// fails, it's queued to be retried in the background rather than failing the
+// deal. An error is only returned if the announcement can't be queued.
+func (p *Provider) announceDeal(ctx context.Context, deal *types.ProviderDealState) error {
+	annCid, err := p.ip.AnnounceBoostDeal(ctx, deal)
+	if errors.Is(err, provider.ErrAlreadyAdvertised) {
+		p.dealLogger.Infow(deal.DealUuid, "deal has already been announced to network indexer")
+		return nil
+	}
+	if err != nil {
+		if qerr := p.ip.QueueAnnounceBoostDeal(ctx, deal, err); qerr != nil {
+			return fmt.Errorf("failed to announce deal to network indexer: %w (%s)", err, qerr)
+		}
+		p.dealLogger.Warnw(deal.DealUuid, "failed to announce deal to network indexer: queued announcement for retry", "err", err)
+		return nil
+	}
+	p.dealLogger.Infow(deal.DealUuid, "announced deal to network indexer", "announcement-cid", annCid)
+	return nil
+}
+
+// announceAfterSealing announces a deal that the announce policy delayed
+// until the deal's sector was sealed
+func (p *Provider) announceAfterSealing(deal *types.ProviderDealState, state lapi.SectorState) {
+	if !deal.AnnounceToIPNI || !deal.AnnounceAfterSealing || !p.ip.Enabled() {
+		return
+	}
+	if !isSealedState(state) {
+		p.dealLogger.Infow(deal.DealUuid, "not announcing deal to network indexer: sector was not sealed", "state", state)
+		return
+	}
+
+	// Note that if boost restarts after the deal was announced, the sealing
+	// state is checked again and the deal is found to be already announced
+	if err := p.announceDeal(p.ctx, deal); err != nil {
+		p.dealLogger.LogError(deal.DealUuid, "failed to announce deal after sealing", err)
+	}
+}
+
 // fireSealingUpdateEvents periodically checks the sealing status of the deal
-// and fires events for each change
-func (p *Provider) fireSealingUpdateEvents(dh *dealHandler, dealUuid uuid.UUID, sectorNum abi.SectorNumber) {
+// and fires events for each change.
+// Returns the sealing state when the sector reaches a final sealing state, or
+// the empty string if the provider is shutting down.
+func (p *Provider) fireSealingUpdateEvents(dh *dealHandler, dealUuid uuid.UUID, sectorNum abi.SectorNumber) lapi.SectorState {
 	var lastSealingState lapi.SectorState
 	checkStatus := func(force bool) lapi.SectorState {
 		// To avoid overloading the sealing service, only get the sector status
@@ -678,7 +924,7 @@ func (p *Provider) fireSealingUpdateEvents(dh *dealHandler, dealUuid uuid.UUID,
 		}
 
 		// Get the sector status
-		si, err := p.sps.SectorsStatus(p.ctx, sectorNum, false)
+		si, err := p.sealer.SectorsStatus(p.ctx, sectorNum, false)
 		if err == nil && si.State != lastSealingState {
 			lastSealingState = si.State
 
@@ -698,7 +944,7 @@ func (p *Provider) fireSealingUpdateEvents(dh *dealHandler, dealUuid uuid.UUID,
 	// Check status immediately
 	state := checkStatus(true)
 	if isFinalSealingState(state) {
-		return
+		return state
 	}
 
 	// Check status every second
@@ -709,7 +955,7 @@ func (p *Provider) fireSealingUpdateEvents(dh *dealHandler, dealUuid uuid.UUID,
 	for {
 		select {
 		case <-p.ctx.Done():
-			return
+			return ""
 		case <-ticker.C:
 			count++
 			// Force a status check every forceCount seconds, even if there
@@ -721,12 +967,26 @@ func (p *Provider) fireSealingUpdateEvents(dh *dealHandler, dealUuid uuid.UUID,
 			}
 
 			if isFinalSealingState(state) {
-				return
+				return state
 			}
 		}
 	}
 }
 
+// isSealedState returns true if the sector is sealed and can be retrieved
+// from
+func isSealedState(state lapi.SectorState) bool {
+	switch sealing.SectorState(state) {
+	case
+		sealing.Proving,
+		sealing.Available,
+		sealing.UpdateActivating,
+		sealing.ReleaseSectorKey:
+		return true
+	}
+	return false
+}
+
 func isFinalSealingState(state lapi.SectorState) bool {
 	switch sealing.SectorState(state) {
 	case
@@ -747,6 +1007,7 @@ func isFinalSealingState(state lapi.SectorState) bool {
 
 func (p *Provider) failDeal(pub event.Emitter, deal *smtypes.ProviderDealState, err error, cancelled bool) {
 	// Update state in DB with error
+	prev := deal.Checkpoint
 	deal.Checkpoint = dealcheckpoints.Complete
 	deal.Retry = smtypes.DealRetryFatal
 	if cancelled {
@@ -758,6 +1019,7 @@ func (p *Provider) failDeal(pub event.Emitter, deal *smtypes.ProviderDealState,
 	}
 
 	p.saveDealToDB(pub, deal)
+	p.auditCheckpoint(deal, prev.String(), deal.Err)
 	p.cleanupDeal(deal)
 }
 
@@ -783,6 +1045,8 @@ func (p *Provider) cleanupDeal(deal *types.ProviderDealState) {
 	p.dealLogger.Infow(deal.DealUuid, "cleaning up deal")
 	defer p.dealLogger.Infow(deal.DealUuid, "finished cleaning up deal")
 
+	p.proposalSpans.remove(deal.DealUuid)
+
 	// remove the temp file created for inbound deal data if it is not an offline deal
 	if !deal.IsOffline {
 		_ = os.Remove(deal.InboundFilePath)
@@ -844,6 +1108,7 @@ func (p *Provider) updateCheckpoint(pub event.Emitter, deal *types.ProviderDealS
 		}
 	}
 	p.dealLogger.Infow(deal.DealUuid, "updated deal checkpoint in DB", "old checkpoint", prev.String(), "new checkpoint", ckpt.String())
+	p.auditCheckpoint(deal, prev.String(), "")
 	p.fireEventDealUpdate(pub, deal)
 
 	return nil
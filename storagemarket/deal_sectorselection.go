@@ -0,0 +1,145 @@
+package storagemarket
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/storagemarket/sectorselection"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/go-state-types/abi"
+	lapi "github.com/filecoin-project/lotus/api"
+)
+
+// The states of sectors that can receive deals with SnapDeals. Sectors in
+// the Proving state must be marked for upgrade first, and only sectors
+// without deals (committed capacity sectors) can be upgraded.
+var ccSectorStates = []lapi.SectorState{"SnapDealsWaitDeals", "Available", "Proving"}
+
+// ccSectorsCache caches the CC sectors that can receive deals, as getting
+// the sectors requires a call to the sealer for each sector
+type ccSectorsCache struct {
+	// Held while choosing a sector for a deal, so that concurrent deals don't
+	// each mark a different sector for upgrade
+	chooseLk sync.Mutex
+
+	lk      sync.Mutex
+	at      time.Time
+	sectors []sectorselection.CCSector
+}
+
+// chooseSectorPath decides whether to add the deal to a new sector or to a
+// CC sector with SnapDeals, and records the decision in the deal log.
+// The sealing subsystem assigns each piece to a sector, so if a CC sector
+// in the Proving state is chosen it is marked for upgrade so that it's
+// available to receive the deal. The policy only chooses a Proving sector
+// when no CC sector is already available for deals, so at most one sector is
+// marked for upgrade at a time.
+func (p *Provider) chooseSectorPath(ctx context.Context, deal *types.ProviderDealState) *sectorselection.Decision {
+	if !p.sectorSelection.Enabled() {
+		return nil
+	}
+
+	p.ccSectorsCache.chooseLk.Lock()
+	defer p.ccSectorsCache.chooseLk.Unlock()
+
+	candidates, err := p.ccSectors(ctx)
+	if err != nil {
+		p.dealLogger.Warnw(deal.DealUuid, "failed to get CC sectors to choose sector for deal", "err", err)
+	}
+
+	decision := p.sectorSelection.Decide(deal.ClientDealProposal.Proposal, candidates)
+	p.dealLogger.Infow(deal.DealUuid, "chose sector for deal", "path", decision.Path, "reason", decision.Reason)
+
+	if decision.Path == sectorselection.PathSnap && !decision.Sector.IsAvailable() {
+		if err := p.sps.SectorMarkForUpgrade(ctx, decision.Sector.SectorID, true); err != nil {
+			p.dealLogger.Warnw(deal.DealUuid, "failed to mark CC sector for upgrade", "sector", decision.Sector.SectorID, "err", err)
+		} else {
+			p.dealLogger.Infow(deal.DealUuid, "marked CC sector for upgrade", "sector", decision.Sector.SectorID)
+			// Record that the sector is available for deals, so that the
+			// next deal doesn't mark another sector before the cache is
+			// refreshed
+			p.ccSectorsCache.markUpgrading(decision.Sector.SectorID)
+		}
+	}
+
+	return &decision
+}
+
+// logSectorPath records in the deal log whether the deal was added to the
+// kind of sector that was chosen for it
+func (p *Provider) logSectorPath(ctx context.Context, deal *types.ProviderDealState, decision *sectorselection.Decision, sector abi.SectorNumber) {
+	if decision == nil || decision.Path == sectorselection.PathAny {
+		return
+	}
+
+	si, err := p.sealer.SectorsStatus(ctx, sector, false)
+	if err != nil {
+		p.dealLogger.Warnw(deal.DealUuid, "failed to get status of sector that deal was added to", "sector", sector, "err", err)
+		return
+	}
+
+	path := sectorselection.PathNew
+	if strings.HasPrefix(string(si.State), "SnapDeals") {
+		path = sectorselection.PathSnap
+	}
+	if path != decision.Path {
+		// The sector choice is advisory: the sealing subsystem may add a deal
+		// to a CC sector that is already available for deals even if none was
+		// chosen (unless PreferNewSectorsForDeals is set in the sealing config)
+		p.dealLogger.Infow(deal.DealUuid, "sealing subsystem added deal to a different kind of sector than was chosen",
+			"chosen", decision.Path, "actual", path, "sector", sector, "state", si.State)
+		return
+	}
+	p.dealLogger.Infow(deal.DealUuid, "deal added to chosen kind of sector", "path", path, "sector", sector)
+}
+
+// ccSectors returns the sectors that can receive deals with SnapDeals
+func (p *Provider) ccSectors(ctx context.Context) ([]sectorselection.CCSector, error) {
+	c := &p.ccSectorsCache
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	if !c.at.IsZero() && time.Since(c.at) < p.config.SealingPipelineCacheTimeout {
+		return c.sectors, nil
+	}
+
+	sectors, err := p.sps.SectorsListInStates(ctx, ccSectorStates)
+	if err != nil {
+		return nil, fmt.Errorf("listing sectors: %w", err)
+	}
+
+	ccSectors := make([]sectorselection.CCSector, 0, len(sectors))
+	for _, sector := range sectors {
+		si, err := p.sps.SectorsStatus(ctx, sector, true)
+		if err != nil {
+			return nil, fmt.Errorf("getting status of sector %d: %w", sector, err)
+		}
+		// Only sectors without deals can be upgraded
+		if si.State == "Proving" && len(si.Deals) > 0 {
+			continue
+		}
+		ccSectors = append(ccSectors, sectorselection.CCSector{
+			SectorID:   sector,
+			State:      lapi.SectorState(si.State),
+			Expiration: si.Expiration,
+		})
+	}
+
+	c.sectors = ccSectors
+	c.at = time.Now()
+	return ccSectors, nil
+}
+
+// markUpgrading records that the sector has been marked for upgrade
+func (c *ccSectorsCache) markUpgrading(sector abi.SectorNumber) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	for i := range c.sectors {
+		if c.sectors[i].SectorID == sector {
+			c.sectors[i].State = "SnapDealsWaitDeals"
+		}
+	}
+}
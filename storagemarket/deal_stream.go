@@ -0,0 +1,318 @@
+package storagemarket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/filecoin-project/boost/metrics"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/filecoin-project/boost/transport"
+	transporttypes "github.com/filecoin-project/boost/transport/types"
+	"github.com/filecoin-project/go-commp-utils/writer"
+	"github.com/filecoin-project/go-padreader"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+	carv2 "github.com/ipld/go-car/v2"
+	"github.com/libp2p/go-libp2p/core/event"
+	"go.opencensus.io/stats"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// The size of the buffer used to read streamed deal data
+const streamBufferSize = 1024 * 1024
+
+// streamAddPieceEnabled indicates whether the deal's data should be streamed
+// from the transfer directly into a sector, instead of being downloaded to
+// the staging area first.
+// Streamed deals must be published before the data is added to a sector, so
+// that the deal has a chain deal ID. To avoid publishing a deal with the
+// wrong data, the data is streamed once before the deal is published to
+// verify its commp, and then streamed again into the sector after the deal
+// is published, verifying the commp again before the piece is committed.
+// If the client serves different data the second time, the deal fails after
+// it has been published, and the provider's collateral for the deal will be
+// slashed when the deal's start epoch passes without the deal being sealed.
+func (p *Provider) streamAddPieceEnabled(deal *types.ProviderDealState) bool {
+	return p.config.StreamingAddPiece && !deal.IsOffline && p.streamingTransport() != nil
+}
+
+// publishedWithoutData indicates whether the deal was published without its
+// data being staged (because it was accepted with streaming add piece
+// enabled)
+func (p *Provider) publishedWithoutData(deal *types.ProviderDealState) bool {
+	if deal.IsOffline || deal.Checkpoint < dealcheckpoints.Published {
+		return false
+	}
+	st, err := os.Stat(deal.InboundFilePath)
+	if err != nil {
+		return true
+	}
+	return uint64(st.Size()) < deal.Transfer.Size
+}
+
+// isStreamedDeal indicates whether the deal's data should be streamed into a
+// sector
+func (p *Provider) isStreamedDeal(deal *types.ProviderDealState) bool {
+	return p.config.StreamingAddPiece && p.streamingTransport() != nil && p.publishedWithoutData(deal)
+}
+
+func (p *Provider) streamingTransport() transport.StreamingTransport {
+	st, ok := p.Transport.(transport.StreamingTransport)
+	if !ok {
+		return nil
+	}
+	return st
+}
+
+// verifyStreamedData streams the deal data from the transfer, without
+// storing it, to check that its commp matches the deal proposal before the
+// deal is published
+func (p *Provider) verifyStreamedData(ctx context.Context, pub event.Emitter, deal *types.ProviderDealState) (dmerr *dealMakingError) {
+	ctx, span := startDealSpan(ctx, deal.DealUuid, "Provider.verifyStreamedData",
+		attribute.String("transferType", deal.Transfer.Type), attribute.Int64("transferSize", int64(deal.Transfer.Size)))
+	defer func() { endDealSpan(span, dmerr) }()
+
+	p.dealLogger.Infow(deal.DealUuid, "streaming deal data to verify commP before publishing")
+	return p.streamDealData(ctx, pub, deal, func(data io.Reader, size uint64) *dealMakingError {
+		verifier := newCommpVerifier(data, size, deal.ClientDealProposal.Proposal)
+		if _, err := io.Copy(io.Discard, verifier); err != nil {
+			if verifier.err != nil {
+				return &dealMakingError{
+					retry: types.DealRetryFatal,
+					error: fmt.Errorf("verifying commP of streamed data: %w", verifier.err),
+				}
+			}
+			return &dealMakingError{
+				retry: types.DealRetryAuto,
+				error: fmt.Errorf("reading streamed data: %w", err),
+			}
+		}
+		p.dealLogger.Infow(deal.DealUuid, "commP matched successfully: streamed deal-data verified")
+		return nil
+	})
+}
+
+// streamAddPiece streams the deal data from the transfer into a sector,
+// calculating commp as the data arrives. If the commp doesn't match the
+// deal proposal the read that completes the data fails, so that adding the
+// piece fails and the piece is not committed to the sector.
+func (p *Provider) streamAddPiece(ctx context.Context, pub event.Emitter, deal *types.ProviderDealState) (dmerr *dealMakingError) {
+	ctx, span := startDealSpan(ctx, deal.DealUuid, "Provider.streamAddPiece",
+		attribute.String("transferType", deal.Transfer.Type), attribute.Int64("transferSize", int64(deal.Transfer.Size)))
+	defer func() { endDealSpan(span, dmerr) }()
+
+	p.dealLogger.Infow(deal.DealUuid, "stream add piece called")
+	return p.streamDealData(ctx, pub, deal, func(data io.Reader, size uint64) *dealMakingError {
+		proposal := deal.ClientDealProposal.Proposal
+		verifier := newCommpVerifier(data, size, proposal)
+		paddedReader, err := padreader.NewInflator(verifier, size, proposal.PieceSize.Unpadded())
+		if err != nil {
+			return &dealMakingError{
+				retry: types.DealRetryFatal,
+				error: fmt.Errorf("failed to create inflator: %w", err),
+			}
+		}
+
+		derr := p.addPieceData(ctx, pub, deal, paddedReader)
+		if verifier.err != nil {
+			// The data was verified before the deal was published, so the
+			// client must have served different data the second time
+			p.dealLogger.Warnw(deal.DealUuid, "streamed data does not match the data that was verified before publishing: "+
+				"the deal will be slashed if it is not sealed before its start epoch", "err", verifier.err)
+			return &dealMakingError{
+				retry: types.DealRetryFatal,
+				error: fmt.Errorf("verifying commP of streamed data: %w", verifier.err),
+			}
+		}
+		if derr != nil {
+			return derr
+		}
+		p.dealLogger.Infow(deal.DealUuid, "commP matched successfully: streamed deal-data verified")
+		return nil
+	})
+}
+
+// streamDealData starts a streaming transfer of the deal data, and calls
+// handle with the CARv1 data in the stream
+func (p *Provider) streamDealData(ctx context.Context, pub event.Emitter, deal *types.ProviderDealState, handle func(data io.Reader, size uint64) *dealMakingError) *dealMakingError {
+	// Check that the deal's start epoch hasn't already elapsed
+	if derr := p.checkDealProposalStartEpoch(deal); derr != nil {
+		return derr
+	}
+
+	// Wait for a spot in the transfer queue
+	if err := p.xferLimiter.waitInQueue(ctx, deal); err != nil {
+		return &dealMakingError{
+			retry: types.DealRetryAuto,
+			error: fmt.Errorf("waiting to start streaming transfer: %w", err),
+		}
+	}
+	defer p.xferLimiter.complete(deal.DealUuid)
+	defer p.transfers.complete(deal.DealUuid)
+
+	tctx, cancel := context.WithDeadline(ctx, time.Now().Add(p.config.MaxTransferDuration))
+	defer cancel()
+
+	st := time.Now()
+	stream, err := p.streamingTransport().Stream(tctx, deal.Transfer.Params, &transporttypes.TransportDealInfo{
+		DealUuid: deal.DealUuid,
+		DealSize: int64(deal.Transfer.Size),
+	})
+	if err != nil {
+		return &dealMakingError{
+			retry: types.DealRetryAuto,
+			error: fmt.Errorf("failed to start streaming transfer: %w", err),
+		}
+	}
+	defer stream.Close() //nolint:errcheck
+
+	// Keep track of transfer progress as the data is read
+	mctx := transferMetricsCtx(ctx, deal)
+	var lastUpdate time.Time
+	var lastReceived int64
+	progress := &streamProgress{r: stream, onRead: func(received int64) {
+		p.transfers.setBytes(deal.DealUuid, uint64(received))
+		p.xferLimiter.setBytes(deal.DealUuid, uint64(received))
+		deal.NBytesReceived = received
+		if time.Since(lastUpdate) >= time.Second || uint64(received) == deal.Transfer.Size {
+			lastUpdate = time.Now()
+			p.fireEventDealUpdate(pub, deal)
+			stats.Record(mctx, metrics.TransferBytesReceived.M(received-lastReceived))
+			lastReceived = received
+		}
+	}}
+
+	data, size, err := carDataReader(progress, deal.Transfer.Size)
+	if err != nil {
+		return &dealMakingError{
+			retry: types.DealRetryAuto,
+			error: fmt.Errorf("reading CAR header from stream: %w", err),
+		}
+	}
+
+	if derr := handle(data, size); derr != nil {
+		return derr
+	}
+
+	p.dealLogger.Infow(deal.DealUuid, "deal data streamed", "bytes received", deal.NBytesReceived,
+		"time taken", time.Since(st).String())
+	if secs := time.Since(st).Seconds(); secs > 0 {
+		stats.Record(mctx, metrics.TransferThroughput.M(float64(deal.NBytesReceived)/secs))
+	}
+	return nil
+}
+
+// commpVerifier calculates the commp of the data as it's read. When size
+// bytes have been read (or the data ends before that) it checks the commp
+// against the deal proposal, and if it doesn't match returns an error from
+// that Read, so that the reader of the data (eg the sealing subsystem) fails
+// instead of seeing the complete data.
+// The check is made on the read that reaches size, rather than when the
+// underlying reader returns io.EOF, because readers such as the padreader
+// inflator stop reading after exactly size bytes.
+type commpVerifier struct {
+	r        io.Reader
+	commpw   *writer.Writer
+	proposal market.DealProposal
+	size     uint64
+	read     uint64
+	verified bool
+	// The error verifying the commp, if any
+	err error
+}
+
+func newCommpVerifier(r io.Reader, size uint64, proposal market.DealProposal) *commpVerifier {
+	commpw := &writer.Writer{}
+	return &commpVerifier{r: io.TeeReader(r, commpw), commpw: commpw, proposal: proposal, size: size}
+}
+
+func (v *commpVerifier) Read(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	if v.verified {
+		return 0, io.EOF
+	}
+
+	// Don't read past the end of the data, so that the commp only covers
+	// the deal data
+	if remaining := v.size - v.read; uint64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := v.r.Read(p)
+	v.read += uint64(n)
+	if v.read < v.size && err != io.EOF {
+		return n, err
+	}
+
+	v.verified = true
+	if verr := v.verify(); verr != nil {
+		v.err = verr
+		return n, verr
+	}
+	if v.read < v.size {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, nil
+}
+
+func (v *commpVerifier) verify() error {
+	ci, err := v.commpw.Sum()
+	if err != nil {
+		return fmt.Errorf("failed to calculate commP: %w", err)
+	}
+	pieceCid, derr := padCommP(&abi.PieceInfo{Size: ci.PieceSize, PieceCID: ci.PieceCID}, v.proposal.PieceSize)
+	if derr != nil {
+		return fmt.Errorf("failed to pad commP: %w", derr.error)
+	}
+	if pieceCid != v.proposal.PieceCID {
+		return fmt.Errorf("commP expected=%s, actual=%s: %w", v.proposal.PieceCID, pieceCid, ErrCommpMismatch)
+	}
+	return nil
+}
+
+// carDataReader returns a reader over the CARv1 data in the stream: if the
+// stream is a CARv2 file, the CARv2 header is skipped and only the data
+// payload is read
+func carDataReader(r io.Reader, size uint64) (io.Reader, uint64, error) {
+	br := bufio.NewReaderSize(r, streamBufferSize)
+	pragma, err := br.Peek(carv2.PragmaSize)
+	if err != nil || !bytes.Equal(pragma, carv2.Pragma) {
+		// Not a CARv2 file
+		return br, size, nil
+	}
+
+	if _, err := br.Discard(carv2.PragmaSize); err != nil {
+		return nil, 0, err
+	}
+	var hdr carv2.Header
+	if _, err := hdr.ReadFrom(br); err != nil {
+		return nil, 0, fmt.Errorf("reading CARv2 header: %w", err)
+	}
+	if _, err := br.Discard(int(hdr.DataOffset) - carv2.PragmaSize - carv2.HeaderSize); err != nil {
+		return nil, 0, fmt.Errorf("skipping to CARv2 data payload: %w", err)
+	}
+	return io.LimitReader(br, int64(hdr.DataSize)), hdr.DataSize, nil
+}
+
+// streamProgress reports the number of bytes read from a stream
+type streamProgress struct {
+	r        io.Reader
+	received int64
+	onRead   func(received int64)
+}
+
+func (s *streamProgress) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if n > 0 {
+		s.received += int64(n)
+		s.onRead(s.received)
+	}
+	return n, err
+}
@@ -0,0 +1,70 @@
+package storagemarket
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/filecoin-project/go-commp-utils/writer"
+	"github.com/filecoin-project/go-padreader"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommpVerifier(t *testing.T) {
+	data := make([]byte, 1000)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+
+	commpw := &writer.Writer{}
+	_, err = commpw.Write(data)
+	require.NoError(t, err)
+	ci, err := commpw.Sum()
+	require.NoError(t, err)
+
+	pieceSize := abi.PaddedPieceSize(2048)
+	pieceCid, derr := padCommP(&abi.PieceInfo{Size: ci.PieceSize, PieceCID: ci.PieceCID}, pieceSize)
+	require.Nil(t, derr)
+	proposal := market.DealProposal{PieceCID: pieceCid, PieceSize: pieceSize}
+	size := uint64(len(data))
+
+	// Read the data the way it's added to a sector, through the padreader
+	// inflator, which stops reading after size bytes
+	readPadded := func(v *commpVerifier) ([]byte, error) {
+		padded, err := padreader.NewInflator(v, size, pieceSize.Unpadded())
+		require.NoError(t, err)
+		return io.ReadAll(padded)
+	}
+
+	// The data matches the proposal
+	v := newCommpVerifier(bytes.NewReader(data), size, proposal)
+	read, err := readPadded(v)
+	require.NoError(t, err)
+	require.Equal(t, data, read[:size])
+	require.Len(t, read, int(pieceSize.Unpadded()))
+	require.True(t, v.verified)
+	require.NoError(t, v.err)
+
+	// The data doesn't match the proposal, so the read that completes the
+	// data fails
+	other := append([]byte{}, data...)
+	other[0]++
+	v = newCommpVerifier(bytes.NewReader(other), size, proposal)
+	_, err = readPadded(v)
+	require.True(t, errors.Is(err, ErrCommpMismatch))
+	require.True(t, errors.Is(v.err, ErrCommpMismatch))
+
+	// The stream has more data than the deal, which isn't read
+	v = newCommpVerifier(bytes.NewReader(append(append([]byte{}, data...), 1, 2, 3)), size, proposal)
+	read, err = io.ReadAll(v)
+	require.NoError(t, err)
+	require.Equal(t, data, read)
+
+	// The stream ends early
+	v = newCommpVerifier(bytes.NewReader(data[:500]), size, proposal)
+	_, err = readPadded(v)
+	require.True(t, errors.Is(err, ErrCommpMismatch))
+}
@@ -0,0 +1,78 @@
+package storagemarket
+
+import (
+	"context"
+	"sync"
+
+	"github.com/filecoin-project/boostd-data/shared/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Each stage of deal execution (transfer, commp, publish, add piece and
+// indexing) is traced with a span that has the deal uuid as an attribute.
+// The spans for the execution of a deal are children of the span for the
+// deal proposal, so that the whole lifecycle of a deal appears in a single
+// trace. Deals that are restarted when boost starts up get a new trace.
+
+// dealProposalSpans keeps the span context of the proposal of each accepted
+// deal until the deal is executed
+type dealProposalSpans struct {
+	lk    sync.Mutex
+	spans map[uuid.UUID]trace.SpanContext
+}
+
+func newDealProposalSpans() *dealProposalSpans {
+	return &dealProposalSpans{spans: make(map[uuid.UUID]trace.SpanContext)}
+}
+
+// add keeps the span context of the proposal that the deal was accepted in
+func (s *dealProposalSpans) add(ctx context.Context, dealUuid uuid.UUID) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		// Tracing is disabled
+		return
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.spans[dealUuid] = sc
+}
+
+// parent returns a context with the deal's proposal span as the parent span
+func (s *dealProposalSpans) parent(ctx context.Context, dealUuid uuid.UUID) context.Context {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	sc, ok := s.spans[dealUuid]
+	if !ok {
+		return ctx
+	}
+	delete(s.spans, dealUuid)
+	return trace.ContextWithSpanContext(ctx, sc)
+}
+
+func (s *dealProposalSpans) remove(dealUuid uuid.UUID) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	delete(s.spans, dealUuid)
+}
+
+// startDealSpan starts a span for a stage of deal execution
+func startDealSpan(ctx context.Context, dealUuid uuid.UUID, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("dealUuid", dealUuid.String()))
+	return tracing.Tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endDealSpan records the error (if any) from a stage of deal execution and
+// ends the span
+func endDealSpan(span trace.Span, derr *dealMakingError) {
+	if derr != nil {
+		span.RecordError(derr.error)
+		span.SetStatus(codes.Error, derr.Error())
+		span.SetAttributes(attribute.String("retry", string(derr.retry)))
+	}
+	span.End()
+}
@@ -44,19 +44,34 @@ func CliStorageDealFilter(cmd string) StorageDealFilter {
 
 func CliRetrievalDealFilter(cmd string) RetrievalDealFilter {
 	return func(ctx context.Context, deal retrievalmarket.ProviderDealState) (bool, string, error) {
-		d := struct {
-			retrievalmarket.ProviderDealState
-			DealType      string
-			FormatVersion string
-			Agent         string
-		}{
-			ProviderDealState: deal,
-			DealType:          "retrieval",
-			FormatVersion:     jsonVersion,
-			Agent:             agent,
-		}
-		return runDealFilter(ctx, cmd, d)
+		return runDealFilter(ctx, cmd, newRetrievalDeal(deal))
+	}
+}
+
+// retrievalDeal is the JSON that is passed to a retrieval deal filter
+type retrievalDeal struct {
+	retrievalmarket.ProviderDealState
+	// The peer ID of the client
+	Client string
+	// The size of the piece that the data is retrieved from
+	Size          uint64
+	DealType      string
+	FormatVersion string
+	Agent         string
+}
+
+func newRetrievalDeal(deal retrievalmarket.ProviderDealState) retrievalDeal {
+	d := retrievalDeal{
+		ProviderDealState: deal,
+		Client:            deal.Receiver.String(),
+		DealType:          "retrieval",
+		FormatVersion:     jsonVersion,
+		Agent:             agent,
+	}
+	if deal.PieceInfo != nil && len(deal.PieceInfo.Deals) > 0 {
+		d.Size = uint64(deal.PieceInfo.Deals[0].Length.Unpadded())
 	}
+	return d
 }
 
 func runDealFilter(ctx context.Context, cmd string, deal interface{}) (bool, string, error) {
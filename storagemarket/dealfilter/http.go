@@ -0,0 +1,76 @@
+package dealfilter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+)
+
+// The maximum time to wait for a deal filter endpoint to respond
+const httpFilterTimeout = 30 * time.Second
+
+// httpFilterVerdict is the response from a deal filter endpoint
+type httpFilterVerdict struct {
+	Accept bool
+	Reason string
+}
+
+// HttpRetrievalDealFilter posts each retrieval deal as JSON (in the same
+// format as is passed to a retrieval filter command) to the endpoint.
+// The endpoint responds with a JSON verdict, eg
+// {"Accept": false, "Reason": "client is over quota"}
+func HttpRetrievalDealFilter(endpoint string) RetrievalDealFilter {
+	client := &http.Client{Timeout: httpFilterTimeout}
+	return func(ctx context.Context, deal retrievalmarket.ProviderDealState) (bool, string, error) {
+		return runHttpDealFilter(ctx, client, endpoint, newRetrievalDeal(deal))
+	}
+}
+
+func runHttpDealFilter(ctx context.Context, client *http.Client, endpoint string, deal interface{}) (bool, string, error) {
+	j, err := json.Marshal(deal)
+	if err != nil {
+		return false, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(j))
+	if err != nil {
+		return false, "filter endpoint error", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, "filter endpoint error", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return false, "filter endpoint error", fmt.Errorf("filter endpoint returned status %d: %s", resp.StatusCode, body)
+	}
+
+	var verdict httpFilterVerdict
+	if err := json.NewDecoder(resp.Body).Decode(&verdict); err != nil {
+		return false, "filter endpoint error", fmt.Errorf("decoding filter endpoint response: %w", err)
+	}
+	return verdict.Accept, verdict.Reason, nil
+}
+
+// RetrievalDealFilters combines retrieval deal filters: the deal is accepted
+// only if all the filters accept it
+func RetrievalDealFilters(filters ...RetrievalDealFilter) RetrievalDealFilter {
+	return func(ctx context.Context, deal retrievalmarket.ProviderDealState) (bool, string, error) {
+		for _, f := range filters {
+			accept, reason, err := f(ctx, deal)
+			if err != nil || !accept {
+				return accept, reason, err
+			}
+		}
+		return true, "", nil
+	}
+}
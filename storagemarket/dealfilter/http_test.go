@@ -0,0 +1,68 @@
+package dealfilter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpRetrievalDealFilter(t *testing.T) {
+	ctx := context.Background()
+
+	// Reject retrievals of pieces larger than 1024 bytes
+	var received struct {
+		Client   string
+		Size     uint64
+		DealType string
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		verdict := httpFilterVerdict{Accept: received.Size <= 1024}
+		if !verdict.Accept {
+			verdict.Reason = "piece is too large"
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(verdict))
+	}))
+	defer srv.Close()
+
+	client, err := peer.Decode("12D3KooWQYzaT9ZiT4X7RuHvu9mHviZm9yccpMbTtyXtS3YBxW1f")
+	require.NoError(t, err)
+	deal := func(size abi.UnpaddedPieceSize) retrievalmarket.ProviderDealState {
+		return retrievalmarket.ProviderDealState{
+			Receiver: client,
+			PieceInfo: &piecestore.PieceInfo{
+				Deals: []piecestore.DealInfo{{Length: size.Padded()}},
+			},
+		}
+	}
+
+	filter := HttpRetrievalDealFilter(srv.URL)
+	accept, reason, err := filter(ctx, deal(1016))
+	require.NoError(t, err)
+	require.True(t, accept)
+	require.Empty(t, reason)
+	require.Equal(t, client.String(), received.Client)
+	require.Equal(t, "retrieval", received.DealType)
+
+	accept, reason, err = filter(ctx, deal(2032))
+	require.NoError(t, err)
+	require.False(t, accept)
+	require.Equal(t, "piece is too large", reason)
+
+	// An endpoint error rejects the retrieval
+	errSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer errSrv.Close()
+	accept, _, err = RetrievalDealFilters(filter, HttpRetrievalDealFilter(errSrv.URL))(ctx, deal(1016))
+	require.Error(t, err)
+	require.False(t, accept)
+}
@@ -1,6 +1,8 @@
 package dealfilter
 
 import (
+	"context"
+
 	"github.com/filecoin-project/boost/storagemarket/funds"
 	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
 	"github.com/filecoin-project/boost/storagemarket/storagespace"
@@ -14,3 +16,37 @@ type DealFilterParams struct {
 	FundsState           funds.Status
 	StorageState         storagespace.Status
 }
+
+// The rules that a storage deal decision can be made by (recorded in the
+// audit log)
+const (
+	StorageRuleOnlineDeals     = "online-deals"
+	StorageRuleOfflineDeals    = "offline-deals"
+	StorageRuleVerifiedDeals   = "verified-deals"
+	StorageRuleUnverifiedDeals = "unverified-deals"
+	StorageRulePieceBlocklist  = "piece-blocklist"
+	StorageRuleFilterCommand   = "filter-command"
+	// The deal was accepted because no rule rejected it
+	StorageRuleDefault = "default"
+)
+
+// StorageDealDecision is the decision to accept or reject a storage deal,
+// and the rule that made it
+type StorageDealDecision struct {
+	Accept bool
+	// The reason sent to the client when the deal is rejected
+	Reason string
+	Rule   string
+}
+
+// StorageDealDecider decides whether to accept a storage deal
+type StorageDealDecider func(ctx context.Context, deal DealFilterParams) (StorageDealDecision, error)
+
+// FilterDecider wraps a storage deal filter as a decider, so that the
+// decisions are attributed to the given rule
+func FilterDecider(rule string, filter StorageDealFilter) StorageDealDecider {
+	return func(ctx context.Context, deal DealFilterParams) (StorageDealDecision, error) {
+		accept, reason, err := filter(ctx, deal)
+		return StorageDealDecision{Accept: accept, Reason: reason, Rule: rule}, err
+	}
+}
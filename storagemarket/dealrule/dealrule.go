@@ -0,0 +1,143 @@
+// Package dealrule has the deal matching and config parsing that is shared
+// by the policies that decide how each deal is handled (eg which wallet
+// funds the collateral, or whether the deal is announced).
+package dealrule
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+)
+
+// The values for matching deals by verified status
+const (
+	// Matches verified deals only
+	VerifiedOnly = "verified"
+	// Matches unverified deals only
+	UnverifiedOnly = "unverified"
+)
+
+// Default describes a decision that was made because no rule matched
+const Default = "default"
+
+// Name describes a decision that was made by the rule at index i, eg "rule 2"
+func Name(i int) string {
+	return fmt.Sprintf("rule %d", i+1)
+}
+
+// Match is the part of a policy rule that matches deals. Empty fields match
+// all deals.
+type Match struct {
+	// Matches deals from any of these client addresses
+	Clients []string
+	// Matches "verified" or "unverified" deals
+	Verified string
+	// Matches deals with a piece size of at least MinPieceSize
+	MinPieceSize abi.PaddedPieceSize
+	// Matches deals with a piece size of at most MaxPieceSize
+	MaxPieceSize abi.PaddedPieceSize
+	// Matches deals with a string label that starts with this prefix
+	LabelPrefix string
+}
+
+// Matcher matches deal proposals against a parsed Match
+type Matcher struct {
+	clients      map[address.Address]struct{}
+	verified     string
+	minPieceSize abi.PaddedPieceSize
+	maxPieceSize abi.PaddedPieceSize
+	labelPrefix  string
+}
+
+// NewMatcher parses and validates the match
+func NewMatcher(m Match) (*Matcher, error) {
+	if m.Verified != "" {
+		if err := ValidateChoice("verified value", m.Verified, VerifiedOnly, UnverifiedOnly); err != nil {
+			return nil, err
+		}
+	}
+	if m.MaxPieceSize != 0 && m.MaxPieceSize < m.MinPieceSize {
+		return nil, fmt.Errorf("max piece size %d is less than min piece size %d", m.MaxPieceSize, m.MinPieceSize)
+	}
+
+	clients, err := ParseClients(m.Clients)
+	if err != nil {
+		return nil, err
+	}
+	return &Matcher{
+		clients:      clients,
+		verified:     m.Verified,
+		minPieceSize: m.MinPieceSize,
+		maxPieceSize: m.MaxPieceSize,
+		labelPrefix:  m.LabelPrefix,
+	}, nil
+}
+
+// Matches indicates whether the deal proposal matches
+func (m *Matcher) Matches(prop market.DealProposal) bool {
+	if m.clients != nil {
+		if _, ok := m.clients[prop.Client]; !ok {
+			return false
+		}
+	}
+	if m.verified == VerifiedOnly && !prop.VerifiedDeal {
+		return false
+	}
+	if m.verified == UnverifiedOnly && prop.VerifiedDeal {
+		return false
+	}
+	if prop.PieceSize < m.minPieceSize {
+		return false
+	}
+	if m.maxPieceSize != 0 && prop.PieceSize > m.maxPieceSize {
+		return false
+	}
+	if m.labelPrefix != "" {
+		if !prop.Label.IsString() {
+			return false
+		}
+		label, err := prop.Label.ToString()
+		if err != nil || !strings.HasPrefix(label, m.labelPrefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseClients parses a list of client addresses into a set. It returns nil
+// if the list is empty.
+func ParseClients(clients []string) (map[address.Address]struct{}, error) {
+	if len(clients) == 0 {
+		return nil, nil
+	}
+	set := make(map[address.Address]struct{}, len(clients))
+	for _, c := range clients {
+		addr, err := address.NewFromString(c)
+		if err != nil {
+			return nil, fmt.Errorf("parsing client address %s: %w", c, err)
+		}
+		set[addr] = struct{}{}
+	}
+	return set, nil
+}
+
+// ValidateChoice returns an error if the value isn't one of the choices,
+// eg: unknown action 'foo': must be one of announce, skip or after-sealing
+func ValidateChoice(what string, value string, choices ...string) error {
+	for _, c := range choices {
+		if value == c {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown %s '%s': must be one of %s", what, value, joinChoices(choices))
+}
+
+func joinChoices(choices []string) string {
+	if len(choices) < 2 {
+		return strings.Join(choices, "")
+	}
+	return strings.Join(choices[:len(choices)-1], ", ") + " or " + choices[len(choices)-1]
+}
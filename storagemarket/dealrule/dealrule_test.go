@@ -0,0 +1,59 @@
+package dealrule
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatcher(t *testing.T) {
+	client1, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+	client2, err := address.NewIDAddress(1002)
+	require.NoError(t, err)
+
+	label, err := market.NewLabelFromString("dataset-1/file")
+	require.NoError(t, err)
+	prop := market.DealProposal{Client: client1, PieceSize: 1 << 30, VerifiedDeal: true, Label: label}
+
+	matches := func(m Match) bool {
+		matcher, err := NewMatcher(m)
+		require.NoError(t, err)
+		return matcher.Matches(prop)
+	}
+
+	// An empty match matches all deals
+	require.True(t, matches(Match{}))
+
+	require.True(t, matches(Match{Clients: []string{client2.String(), client1.String()}}))
+	require.False(t, matches(Match{Clients: []string{client2.String()}}))
+
+	require.True(t, matches(Match{Verified: VerifiedOnly}))
+	require.False(t, matches(Match{Verified: UnverifiedOnly}))
+
+	require.True(t, matches(Match{MinPieceSize: 1 << 30, MaxPieceSize: 1 << 30}))
+	require.False(t, matches(Match{MinPieceSize: 2 << 30}))
+	require.False(t, matches(Match{MaxPieceSize: 512 << 20}))
+
+	require.True(t, matches(Match{LabelPrefix: "dataset-1/"}))
+	require.False(t, matches(Match{LabelPrefix: "dataset-2/"}))
+
+	// All the fields must match
+	require.False(t, matches(Match{Clients: []string{client1.String()}, Verified: UnverifiedOnly}))
+
+	_, err = NewMatcher(Match{Clients: []string{"not an address"}})
+	require.Error(t, err)
+	_, err = NewMatcher(Match{Verified: "sometimes"})
+	require.Error(t, err)
+	_, err = NewMatcher(Match{MinPieceSize: 2 << 30, MaxPieceSize: 1 << 30})
+	require.Error(t, err)
+}
+
+func TestValidateChoice(t *testing.T) {
+	require.NoError(t, ValidateChoice("action", "skip", "announce", "skip"))
+
+	err := ValidateChoice("action", "foo", "announce", "skip", "after-sealing")
+	require.EqualError(t, err, "unknown action 'foo': must be one of announce, skip or after-sealing")
+}
@@ -0,0 +1,114 @@
+package funds
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/fundmanager"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	ltypes "github.com/filecoin-project/lotus/chain/types"
+)
+
+// Forecast projects the funds that will be needed to publish the deals that
+// have been accepted but not yet published, so that the funds can be topped
+// up before deals start failing
+type Forecast struct {
+	// The number of deals that have been accepted but not yet published
+	PendingDeals int
+	// The number of pending deals that are waiting for data to be
+	// transferred or imported
+	AwaitingData int
+	// The number of pending deals that have their data, waiting to be
+	// published
+	AwaitingPublish int
+
+	// The provider collateral for the pending deals, which will be locked in
+	// escrow when the deals are published
+	Collateral abi.TokenAmount
+	// The escrow balance that isn't locked for published deals
+	EscrowAvailable abi.TokenAmount
+	// The amount by which the collateral exceeds the available escrow
+	// balance (zero if there is enough collateral)
+	CollateralShortfall abi.TokenAmount
+
+	// The funds reserved in the publish message wallet to pay for publishing
+	// the pending deals
+	PublishFees abi.TokenAmount
+	// The balance of the publish message wallet
+	PubMsgBalance abi.TokenAmount
+	// The amount by which the publish fees exceed the publish message wallet
+	// balance (zero if there are enough funds)
+	PublishFeesShortfall abi.TokenAmount
+
+	// Warnings about projected requirements that exceed balances
+	Warnings []string
+}
+
+// GetForecast projects the funds needed for the active deals that haven't
+// been published yet
+func GetForecast(ctx context.Context, fm *fundmanager.FundManager, dealsDB *db.DealsDB) (*Forecast, error) {
+	deals, err := dealsDB.ListActive(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting active deals: %w", err)
+	}
+
+	balMkt, err := fm.BalanceMarket(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting market balance: %w", err)
+	}
+
+	balPubMsg, err := fm.BalancePublishMsg(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting publish message balance: %w", err)
+	}
+
+	return NewForecast(deals, balMkt.Available, balPubMsg, fm.PublishMsgBalMin()), nil
+}
+
+// NewForecast projects the funds needed for the deals that haven't been
+// published yet, given the available escrow balance, the balance of the
+// publish message wallet and the funds reserved per deal for publishing
+func NewForecast(deals []*types.ProviderDealState, escrowAvailable, pubMsgBalance, publishFeePerDeal abi.TokenAmount) *Forecast {
+	f := &Forecast{
+		Collateral:      big.Zero(),
+		EscrowAvailable: escrowAvailable,
+		PublishFees:     big.Zero(),
+		PubMsgBalance:   pubMsgBalance,
+	}
+
+	for _, d := range deals {
+		switch d.Checkpoint {
+		case dealcheckpoints.Accepted:
+			f.AwaitingData++
+		case dealcheckpoints.Transferred:
+			f.AwaitingPublish++
+		default:
+			continue
+		}
+		f.PendingDeals++
+		f.Collateral = big.Add(f.Collateral, d.ClientDealProposal.Proposal.ProviderBalanceRequirement())
+		f.PublishFees = big.Add(f.PublishFees, publishFeePerDeal)
+	}
+
+	f.CollateralShortfall = big.Max(big.Zero(), big.Sub(f.Collateral, escrowAvailable))
+	if !f.CollateralShortfall.IsZero() {
+		f.Warnings = append(f.Warnings, fmt.Sprintf(
+			"the collateral for %d pending deals (%s) exceeds the available escrow balance (%s) by %s: "+
+				"deals will fail to publish unless funds are moved to escrow",
+			f.PendingDeals, ltypes.FIL(f.Collateral), ltypes.FIL(escrowAvailable), ltypes.FIL(f.CollateralShortfall)))
+	}
+
+	f.PublishFeesShortfall = big.Max(big.Zero(), big.Sub(f.PublishFees, pubMsgBalance))
+	if !f.PublishFeesShortfall.IsZero() {
+		f.Warnings = append(f.Warnings, fmt.Sprintf(
+			"the funds reserved to publish %d pending deals (%s) exceed the publish message wallet balance (%s) by %s: "+
+				"deals will fail to publish unless funds are added to the wallet",
+			f.PendingDeals, ltypes.FIL(f.PublishFees), ltypes.FIL(pubMsgBalance), ltypes.FIL(f.PublishFeesShortfall)))
+	}
+
+	return f
+}
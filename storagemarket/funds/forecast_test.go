@@ -0,0 +1,57 @@
+package funds
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForecast(t *testing.T) {
+	dealStates, err := db.GenerateNDeals(4)
+	require.NoError(t, err)
+
+	var deals []*types.ProviderDealState
+	checkpoints := []dealcheckpoints.Checkpoint{
+		dealcheckpoints.Accepted,
+		dealcheckpoints.Transferred,
+		dealcheckpoints.Transferred,
+		// Collateral for published deals is already locked in escrow
+		dealcheckpoints.Published,
+	}
+	for i := range dealStates {
+		d := &dealStates[i]
+		d.Checkpoint = checkpoints[i]
+		d.ClientDealProposal.Proposal.ProviderCollateral = abi.NewTokenAmount(10)
+		deals = append(deals, d)
+	}
+
+	t.Run("enough funds", func(t *testing.T) {
+		f := NewForecast(deals, abi.NewTokenAmount(30), abi.NewTokenAmount(3), abi.NewTokenAmount(1))
+		require.Equal(t, 3, f.PendingDeals)
+		require.Equal(t, 1, f.AwaitingData)
+		require.Equal(t, 2, f.AwaitingPublish)
+		require.EqualValues(t, 30, f.Collateral.Int64())
+		require.EqualValues(t, 3, f.PublishFees.Int64())
+		require.True(t, f.CollateralShortfall.IsZero())
+		require.True(t, f.PublishFeesShortfall.IsZero())
+		require.Empty(t, f.Warnings)
+	})
+
+	t.Run("shortfall", func(t *testing.T) {
+		f := NewForecast(deals, abi.NewTokenAmount(25), abi.NewTokenAmount(1), abi.NewTokenAmount(1))
+		require.EqualValues(t, 5, f.CollateralShortfall.Int64())
+		require.EqualValues(t, 2, f.PublishFeesShortfall.Int64())
+		require.Len(t, f.Warnings, 2)
+	})
+
+	t.Run("no pending deals", func(t *testing.T) {
+		f := NewForecast(nil, abi.NewTokenAmount(0), abi.NewTokenAmount(0), abi.NewTokenAmount(1))
+		require.Equal(t, 0, f.PendingDeals)
+		require.True(t, f.Collateral.IsZero())
+		require.Empty(t, f.Warnings)
+	})
+}
@@ -0,0 +1,93 @@
+package storagemarket
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+	ctypes "github.com/filecoin-project/lotus/chain/types"
+)
+
+// recordPublishMovements records the funds movements for a deal whose
+// publish message has been confirmed on chain: the provider collateral that
+// was locked in escrow, and the deal's share of the fees paid for the
+// publish message
+func (p *Provider) recordPublishMovements(ctx context.Context, deal *types.ProviderDealState) {
+	// Attribute the collateral to the wallet that funds the deal's collateral
+	// (older deals don't have a collateral wallet)
+	collatWallet := deal.CollateralWallet
+	if collatWallet == "" {
+		collatWallet = p.Address.String()
+	}
+
+	msgCid := deal.PublishCID.String()
+	movements := []*db.FundsMovement{{
+		DealUUID:   deal.DealUuid,
+		Type:       db.FundsMovementCollateralLock,
+		Amount:     deal.ClientDealProposal.Proposal.ProviderCollateral,
+		Wallet:     collatWallet,
+		MessageCID: msgCid,
+		Text:       fmt.Sprintf("Lock provider collateral for chain deal %d", deal.ChainDealID),
+	}}
+
+	fee, err := p.publishFee(ctx, deal)
+	if err != nil {
+		p.dealLogger.Warnw(deal.DealUuid, "failed to get publish fee for funds movement history", "err", err)
+	} else {
+		movements = append(movements, fee)
+	}
+
+	p.fundManager.RecordMovement(ctx, movements...)
+}
+
+// publishFee gets the deal's share of the gas fees that were paid for the
+// publish message, which is the total fee divided by the number of deals in
+// the message
+func (p *Provider) publishFee(ctx context.Context, deal *types.ProviderDealState) (*db.FundsMovement, error) {
+	res, err := p.fullnodeApi.StateReplay(ctx, ctypes.EmptyTSK, *deal.PublishCID)
+	if err != nil {
+		return nil, fmt.Errorf("replaying publish message %s: %w", deal.PublishCID, err)
+	}
+	if res.Msg == nil {
+		return nil, fmt.Errorf("publish message %s not found", deal.PublishCID)
+	}
+
+	var params market.PublishStorageDealsParams
+	if err := params.UnmarshalCBOR(bytes.NewReader(res.Msg.Params)); err != nil {
+		return nil, fmt.Errorf("unmarshalling publish message %s params: %w", deal.PublishCID, err)
+	}
+	if len(params.Deals) == 0 {
+		return nil, fmt.Errorf("publish message %s has no deals", deal.PublishCID)
+	}
+
+	dealCount := abi.NewTokenAmount(int64(len(params.Deals)))
+	return &db.FundsMovement{
+		DealUUID:   deal.DealUuid,
+		Type:       db.FundsMovementPublishFee,
+		Amount:     big.Div(res.GasCost.TotalCost, dealCount),
+		Wallet:     res.Msg.From.String(),
+		MessageCID: deal.PublishCID.String(),
+		Text:       fmt.Sprintf("Share of the fee for publishing %d deals", len(params.Deals)),
+	}, nil
+}
+
+// recordFundsRelease records the release of the funds that were tagged for a
+// deal that failed before it was published
+func (p *Provider) recordFundsRelease(deal *types.ProviderDealState, collat, pub abi.TokenAmount) {
+	tot := big.Add(collat, pub)
+	if tot.IsZero() {
+		return
+	}
+	p.fundManager.RecordMovement(p.ctx, &db.FundsMovement{
+		DealUUID: deal.DealUuid,
+		Type:     db.FundsMovementRelease,
+		Amount:   tot,
+		Wallet:   deal.CollateralWallet,
+		Text:     fmt.Sprintf("Release collateral %s and publish message funds %s", ctypes.FIL(collat), ctypes.FIL(pub)),
+	})
+}
@@ -3,26 +3,33 @@ package logs
 import (
 	"context"
 	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/go-address"
 
 	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
 )
 
-var baseLogger = logging.Logger("boost-storage-deal")
+const baseModule = "boost-storage-deal"
+
+var baseLogger = logging.Logger(baseModule)
 
 type DealLogger struct {
 	logger    *logging.ZapEventLogger
 	logsDB    *db.LogsDB
 	subsystem string
+	deals     *trackedDeals
 }
 
 func NewDealLogger(logsDB *db.LogsDB) *DealLogger {
 	return &DealLogger{
 		logger: baseLogger,
 		logsDB: logsDB,
+		deals:  &trackedDeals{fields: make(map[uuid.UUID]dealFields)},
 	}
 }
 
@@ -31,27 +38,37 @@ func (d *DealLogger) Subsystem(name string) *DealLogger {
 		logger:    logging.Logger(d.subsystem + name),
 		logsDB:    d.logsDB,
 		subsystem: name,
+		deals:     d.deals,
 	}
 }
 
-func (d *DealLogger) Infow(dealId uuid.UUID, msg string, kvs ...interface{}) {
-	kvs = paramsWithDealID(dealId, kvs...)
+// Track adds the deal's client and piece cid to each line that is logged for
+// the deal, until Untrack is called
+func (d *DealLogger) Track(dealId uuid.UUID, client address.Address, pieceCid cid.Cid) {
+	d.deals.lk.Lock()
+	defer d.deals.lk.Unlock()
+	d.deals.fields[dealId] = dealFields{client: client.String(), pieceCid: pieceCid.String()}
+}
+
+func (d *DealLogger) Untrack(dealId uuid.UUID) {
+	d.deals.lk.Lock()
+	defer d.deals.lk.Unlock()
+	delete(d.deals.fields, dealId)
+}
 
-	d.logger.Infow(msg, kvs...)
-	d.updateLogDB(dealId, msg, "INFO", kvs...)
+func (d *DealLogger) Infow(dealId uuid.UUID, msg string, kvs ...interface{}) {
+	d.logger.Infow(msg, d.withCorrelationFields(dealId, kvs...)...)
+	d.updateLogDB(dealId, msg, "INFO", paramsWithDealID(dealId, kvs...)...)
 }
 
 func (d *DealLogger) Warnw(dealId uuid.UUID, msg string, kvs ...interface{}) {
-	kvs = paramsWithDealID(dealId, kvs...)
-	d.logger.Warnw(msg, kvs...)
-	d.updateLogDB(dealId, msg, "WARN", kvs...)
+	d.logger.Warnw(msg, d.withCorrelationFields(dealId, kvs...)...)
+	d.updateLogDB(dealId, msg, "WARN", paramsWithDealID(dealId, kvs...)...)
 }
 
 func (d *DealLogger) Errorw(dealId uuid.UUID, errMsg string, kvs ...interface{}) {
-	kvs = paramsWithDealID(dealId, kvs...)
-
-	d.logger.Errorw(errMsg, kvs...)
-	d.updateLogDB(dealId, errMsg, "ERROR", kvs...)
+	d.logger.Errorw(errMsg, d.withCorrelationFields(dealId, kvs...)...)
+	d.updateLogDB(dealId, errMsg, "ERROR", paramsWithDealID(dealId, kvs...)...)
 }
 
 func (d *DealLogger) LogError(dealId uuid.UUID, errMsg string, err error) {
@@ -61,7 +78,7 @@ func (d *DealLogger) LogError(dealId uuid.UUID, errMsg string, err error) {
 func (d *DealLogger) updateLogDB(dealId uuid.UUID, msg string, level string, kvs ...interface{}) {
 	jsn, err := json.Marshal(kvs)
 	if err != nil {
-		d.logger.Warnw("failed to marshal log params to json", "err", err, "id", dealId)
+		d.logger.Warnw("failed to marshal log params to json", "err", err, FieldDealUuid, dealId)
 	}
 
 	l := &db.DealLog{
@@ -74,7 +91,7 @@ func (d *DealLogger) updateLogDB(dealId uuid.UUID, msg string, level string, kvs
 	}
 	// we don't want context cancellations to mess up our logging, so pass a background context
 	if err := d.logsDB.InsertLog(context.Background(), l); err != nil {
-		d.logger.Warnw("failed to persist deal log", "id", dealId, "err", err)
+		d.logger.Warnw("failed to persist deal log", FieldDealUuid, dealId, "err", err)
 	}
 }
 
@@ -83,6 +100,44 @@ func paramsWithDealID(dealId uuid.UUID, kvs ...interface{}) []interface{} {
 	return kvs
 }
 
+// The fields that are added to each log line for a deal, so that log
+// aggregators can correlate the lines for a deal (these are top-level keys
+// when logs are output as JSON)
+const (
+	FieldDealUuid = "deal_uuid"
+	FieldClient   = "client"
+	FieldPieceCid = "piece_cid"
+	FieldModule   = "module"
+)
+
+type dealFields struct {
+	client   string
+	pieceCid string
+}
+
+type trackedDeals struct {
+	lk     sync.RWMutex
+	fields map[uuid.UUID]dealFields
+}
+
+// withCorrelationFields prepends the correlation fields to the log params
+func (d *DealLogger) withCorrelationFields(dealId uuid.UUID, kvs ...interface{}) []interface{} {
+	module := baseModule
+	if d.subsystem != "" {
+		module = d.subsystem
+	}
+	fields := []interface{}{FieldDealUuid, dealId, FieldModule, module}
+
+	d.deals.lk.RLock()
+	df, ok := d.deals.fields[dealId]
+	d.deals.lk.RUnlock()
+	if ok {
+		fields = append(fields, FieldClient, df.client, FieldPieceCid, df.pieceCid)
+	}
+
+	return append(fields, kvs...)
+}
+
 func (d *DealLogger) LogCleanup(ctx context.Context, DealLogDurationDays int) {
 
 	// Create a ticker with an hour tick
@@ -18,11 +18,18 @@ import (
 	"github.com/filecoin-project/boost/markets/utils"
 	"github.com/filecoin-project/boost/node/modules/dtypes"
 	"github.com/filecoin-project/boost/storagemanager"
+	"github.com/filecoin-project/boost/storagemarket/announcepolicy"
+	"github.com/filecoin-project/boost/storagemarket/backpressure"
+	"github.com/filecoin-project/boost/storagemarket/collateralpolicy"
 	"github.com/filecoin-project/boost/storagemarket/logs"
 	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
+	"github.com/filecoin-project/boost/storagemarket/sealingpriority"
+	"github.com/filecoin-project/boost/storagemarket/sectorpacking"
+	"github.com/filecoin-project/boost/storagemarket/sectorselection"
 	"github.com/filecoin-project/boost/storagemarket/types"
 	smtypes "github.com/filecoin-project/boost/storagemarket/types"
 	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/filecoin-project/boost/storagemarket/unsealpolicy"
 	"github.com/filecoin-project/boost/transport"
 	"github.com/filecoin-project/boostd-data/shared/tracing"
 	"github.com/filecoin-project/go-address"
@@ -30,6 +37,7 @@ import (
 	"github.com/filecoin-project/go-fil-markets/shared"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/go-fil-markets/stores"
+	"github.com/filecoin-project/go-state-types/abi"
 	lapi "github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/api/v1api"
 	ctypes "github.com/filecoin-project/lotus/chain/types"
@@ -71,6 +79,30 @@ type Config struct {
 	// Cache timeout for Sealing Pipeline status
 	SealingPipelineCacheTimeout time.Duration
 	StorageFilter               string
+	// Decides whether and when each deal is announced to the network indexer
+	AnnouncePolicy announcepolicy.Config
+	// Decides which wallet funds the collateral for each deal
+	CollateralPolicy collateralpolicy.Config
+	// Decides whether to keep an unsealed copy of each deal's data
+	// (see the unsealpolicy package for the policies)
+	UnsealedCopyPolicy string
+	// Whether to skip the data transfer and indexing for deals for a piece
+	// that is already indexed and has a copy of its data on the node
+	DeduplicatePieces bool
+	// Decides the order in which deals are added to sectors
+	SectorPacking sectorpacking.Config
+	// Whether to publish online deals before the data is transferred, and
+	// stream the data into a sector as it arrives instead of staging it
+	// (only for transports that support streaming)
+	StreamingAddPiece bool
+	// Limits the rate at which deals are accepted based on sealing throughput
+	SealingBackpressure backpressure.Config
+	// Chooses whether deals are added to new sectors or to CC sectors with
+	// SnapDeals
+	SectorSelection sectorselection.Config
+	// Prioritizes sealing of sectors with deals that are close to their start
+	// epoch, and alerts when a deal is projected to miss its start epoch
+	SealingPriority sealingpriority.Config
 }
 
 var log = logging.Logger("boost-provider")
@@ -99,22 +131,35 @@ type Provider struct {
 	spsCache SealingPipelineCache
 
 	// Boost deal filter
-	df dtypes.StorageDealFilter
+	df dtypes.StorageDealDecider
 
 	// Database API
 	db        *sql.DB
 	dealsDB   *db.DealsDB
+	auditDB   *db.AuditLogDB
 	logsSqlDB *sql.DB
 	logsDB    *db.LogsDB
 
-	Transport      transport.Transport
-	xferLimiter    *transferLimiter
-	fundManager    *fundmanager.FundManager
-	storageManager *storagemanager.StorageManager
-	dealPublisher  types.DealPublisher
-	transfers      *dealTransfers
-
-	pieceAdder                  types.PieceAdder
+	// Writes audit events to auditDB in the background
+	auditWriter *auditWriter
+
+	Transport       transport.Transport
+	xferLimiter     *transferLimiter
+	announcePolicy  *announcepolicy.Policy
+	collatPolicy    *collateralpolicy.Policy
+	unsealPolicy    *unsealpolicy.Policy
+	sectorPacker    *sectorpacking.Packer
+	backpressure    *backpressure.Limiter
+	sealingPriority *sealingpriority.Monitor
+	sectorSelection *sectorselection.Policy
+	ccSectorsCache  ccSectorsCache
+	fundManager     *fundmanager.FundManager
+	storageManager  *storagemanager.StorageManager
+	dealPublisher   types.DealPublisher
+	transfers       *dealTransfers
+	xferTelemetry   *transferTelemetry
+
+	sealer                      types.SealerAdapter
 	commpThrottle               chan struct{}
 	commpCalc                   smtypes.CommpCalculator
 	maxDealCollateralMultiplier uint64
@@ -122,6 +167,9 @@ type Provider struct {
 
 	fullnodeApi v1api.FullNode
 
+	// The span context of each accepted deal's proposal (see deal_tracing.go)
+	proposalSpans *dealProposalSpans
+
 	dhsMu sync.RWMutex
 	dhs   map[uuid.UUID]*dealHandler // Map of deal handlers indexed by deal uuid.
 
@@ -129,23 +177,60 @@ type Provider struct {
 
 	dagst stores.DAGStoreWrapper
 	ps    piecestore.PieceStore
+	// Keeps a copy of deal data after add piece (nil if disabled)
+	pieceCopies types.PieceCopyStore
+	// Copies the data of pieces that are already on the node, for deals
+	// that are deduplicated
+	existingPieces types.ExistingPieceCopier
 
 	ip          types.IndexProvider
 	askGetter   types.AskGetter
 	sigVerifier types.SignatureVerifier
 }
 
-func NewProvider(cfg Config, sqldb *sql.DB, dealsDB *db.DealsDB, fundMgr *fundmanager.FundManager, storageMgr *storagemanager.StorageManager,
-	fullnodeApi v1api.FullNode, dp types.DealPublisher, addr address.Address, pa types.PieceAdder, commpCalc smtypes.CommpCalculator,
-	sps sealingpipeline.API, cm types.ChainDealManager, df dtypes.StorageDealFilter, logsSqlDB *sql.DB, logsDB *db.LogsDB,
+func NewProvider(cfg Config, sqldb *sql.DB, dealsDB *db.DealsDB, auditDB *db.AuditLogDB, fundMgr *fundmanager.FundManager, storageMgr *storagemanager.StorageManager,
+	fullnodeApi v1api.FullNode, dp types.DealPublisher, addr address.Address, sealer types.SealerAdapter, commpCalc smtypes.CommpCalculator,
+	sps sealingpipeline.API, cm types.ChainDealManager, df dtypes.StorageDealDecider, logsSqlDB *sql.DB, logsDB *db.LogsDB,
 	dagst stores.DAGStoreWrapper, ps piecestore.PieceStore, ip types.IndexProvider, askGetter types.AskGetter,
-	sigVerifier types.SignatureVerifier, dl *logs.DealLogger, tspt transport.Transport) (*Provider, error) {
+	sigVerifier types.SignatureVerifier, dl *logs.DealLogger, tspt transport.Transport, pcs types.PieceCopyStore,
+	epc types.ExistingPieceCopier) (*Provider, error) {
 
 	xferLimiter, err := newTransferLimiter(cfg.TransferLimiter)
 	if err != nil {
 		return nil, err
 	}
 
+	announcePolicy, err := announcepolicy.New(cfg.AnnouncePolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	collatCfg := cfg.CollateralPolicy
+	if collatCfg.DefaultWallet == address.Undef {
+		collatCfg.DefaultWallet = fundMgr.AddressDealCollateral()
+	}
+	collatPolicy, err := collateralpolicy.New(collatCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	unsealPolicy, err := unsealpolicy.New(cfg.UnsealedCopyPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	sectorPacker, err := sectorpacking.New(cfg.SectorPacking, func(ctx context.Context) (abi.SectorSize, error) {
+		return sps.ActorSectorSize(ctx, addr)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sectorSelection, err := sectorselection.New(cfg.SectorSelection)
+	if err != nil {
+		return nil, err
+	}
+
 	newDealPS, err := newDealPubsub()
 	if err != nil {
 		return nil, err
@@ -161,7 +246,7 @@ func NewProvider(cfg Config, sqldb *sql.DB, dealsDB *db.DealsDB, fundMgr *fundma
 		cfg.SealingPipelineCacheTimeout = 30 * time.Second
 	}
 
-	return &Provider{
+	prov := &Provider{
 		ctx:       ctx,
 		cancel:    cancel,
 		config:    cfg,
@@ -169,6 +254,7 @@ func NewProvider(cfg Config, sqldb *sql.DB, dealsDB *db.DealsDB, fundMgr *fundma
 		newDealPS: newDealPS,
 		db:        sqldb,
 		dealsDB:   dealsDB,
+		auditDB:   auditDB,
 		logsSqlDB: logsSqlDB,
 		sps:       sps,
 		spsCache:  SealingPipelineCache{},
@@ -180,31 +266,47 @@ func NewProvider(cfg Config, sqldb *sql.DB, dealsDB *db.DealsDB, fundMgr *fundma
 		updateRetryStateChan: make(chan updateRetryStateReq),
 		storageSpaceChan:     make(chan storageSpaceDealReq),
 
-		Transport:      tspt,
-		xferLimiter:    xferLimiter,
-		fundManager:    fundMgr,
-		storageManager: storageMgr,
+		Transport:       tspt,
+		xferLimiter:     xferLimiter,
+		announcePolicy:  announcePolicy,
+		collatPolicy:    collatPolicy,
+		unsealPolicy:    unsealPolicy,
+		sectorPacker:    sectorPacker,
+		sectorSelection: sectorSelection,
+		fundManager:     fundMgr,
+		storageManager:  storageMgr,
 
 		dealPublisher:               dp,
 		fullnodeApi:                 fullnodeApi,
-		pieceAdder:                  pa,
+		sealer:                      sealer,
 		commpThrottle:               make(chan struct{}, cfg.MaxConcurrentLocalCommp),
 		commpCalc:                   commpCalc,
 		chainDealManager:            cm,
 		maxDealCollateralMultiplier: 2,
 		transfers:                   newDealTransfers(),
+		xferTelemetry:               newTransferTelemetry(),
 
-		dhs:        make(map[uuid.UUID]*dealHandler),
-		dealLogger: dl,
-		logsDB:     logsDB,
+		proposalSpans: newDealProposalSpans(),
+		dhs:           make(map[uuid.UUID]*dealHandler),
+		dealLogger:    dl,
+		logsDB:        logsDB,
 
-		dagst: dagst,
-		ps:    ps,
+		dagst:          dagst,
+		ps:             ps,
+		pieceCopies:    pcs,
+		existingPieces: epc,
 
 		ip:          ip,
 		askGetter:   askGetter,
 		sigVerifier: sigVerifier,
-	}, nil
+	}
+	prov.backpressure = backpressure.New(cfg.SealingBackpressure, sps, prov.sealingBacklog)
+	prov.sealingPriority = sealingpriority.New(cfg.SealingPriority, sps, prov.chainHeight, prov.dealsWaitingToSeal, auditDB)
+	if auditDB != nil {
+		prov.auditWriter = newAuditWriter(auditDB, dl)
+	}
+
+	return prov, nil
 }
 
 func (p *Provider) Deal(ctx context.Context, dealUuid uuid.UUID) (*types.ProviderDealState, error) {
@@ -282,6 +384,7 @@ func (p *Provider) ExecuteDeal(ctx context.Context, dp *types.DealParams, client
 
 	span.SetAttributes(attribute.String("dealUuid", dp.DealUUID.String())) // Example of adding additional attributes
 
+	p.dealLogger.Track(dp.DealUUID, dp.ClientDealProposal.Proposal.Client, dp.ClientDealProposal.Proposal.PieceCID)
 	p.dealLogger.Infow(dp.DealUUID, "executing deal proposal received from network", "peer", clientPeer)
 
 	ds := types.ProviderDealState{
@@ -292,10 +395,26 @@ func (p *Provider) ExecuteDeal(ctx context.Context, dp *types.DealParams, client
 		Transfer:           dp.Transfer,
 		IsOffline:          dp.IsOffline,
 		Retry:              smtypes.DealRetryAuto,
-		FastRetrieval:      !dp.RemoveUnsealedCopy,
-		AnnounceToIPNI:     !dp.SkipIPNIAnnounce,
 	}
 
+	// Decide whether to keep an unsealed copy of the deal data
+	keepUnsealed := p.unsealPolicy.Decide(dp.ClientDealProposal.Proposal, dp.RemoveUnsealedCopy)
+	ds.FastRetrieval = keepUnsealed.Keep
+	p.dealLogger.Infow(dp.DealUUID, "applied unsealed copy policy to deal", "decision", keepUnsealed.String())
+
+	// Decide whether and when to announce the deal to the network indexer
+	announce := p.announcePolicy.Decide(dp.ClientDealProposal.Proposal, dp.SkipIPNIAnnounce)
+	ds.AnnounceToIPNI = announce.Announce()
+	ds.AnnounceAfterSealing = announce.AfterSealing()
+	ds.AnnounceRule = announce.String()
+	p.dealLogger.Infow(dp.DealUUID, "applied announce policy to deal", "decision", ds.AnnounceRule)
+
+	// Choose the wallet that funds the deal collateral
+	collat := p.collatPolicy.Decide(dp.ClientDealProposal.Proposal)
+	ds.CollateralWallet = collat.Wallet.String()
+	ds.CollateralRule = collat.Rule
+	p.dealLogger.Infow(dp.DealUUID, "applied deal collateral rules to deal", "decision", collat.String())
+
 	// Validate the deal proposal
 	if err := p.validateDealProposal(ds); err != nil {
 		// Send the client a reason for the rejection that doesn't reveal the
@@ -307,6 +426,8 @@ func (p *Provider) ExecuteDeal(ctx context.Context, dp *types.DealParams, client
 
 		// Log the internal error message
 		p.dealLogger.Infow(dp.DealUUID, "deal proposal failed validation", "err", err.Error(), "reason", reason)
+		p.auditDecision(&ds, false, auditRuleValidation, reason)
+		p.dealLogger.Untrack(dp.DealUUID)
 		return &api.ProviderDealRejectionInfo{
 			Reason: fmt.Sprintf("failed validation: %s", reason),
 		}, nil
@@ -342,9 +463,11 @@ func (p *Provider) executeDeal(ctx context.Context, ds smtypes.ProviderDealState
 	}()
 	if err != nil || ri == nil || !ri.Accepted {
 		// if there was an error processing the deal, or the deal was rejected, return
+		p.dealLogger.Untrack(ds.DealUuid)
 		return ri, err
 	}
 
+	p.proposalSpans.add(ctx, ds.DealUuid)
 	if ds.IsOffline {
 		p.dealLogger.Infow(ds.DealUuid, "offline deal accepted, waiting for data import")
 	} else {
@@ -437,7 +560,7 @@ func (p *Provider) Start() error {
 	for _, deal := range activeDeals {
 		// Check if deal is already proving
 		if deal.Checkpoint >= dealcheckpoints.IndexedAndAnnounced {
-			si, err := p.sps.SectorsStatus(p.ctx, deal.SectorID, false)
+			si, err := p.sealer.SectorsStatus(p.ctx, deal.SectorID, false)
 			if err != nil || isFinalSealingState(si.State) {
 				continue
 			}
@@ -483,6 +606,15 @@ func (p *Provider) Start() error {
 	// Start the transfer limiter
 	go p.xferLimiter.run(p.ctx)
 
+	// Start measuring sealing throughput to limit the deal accept rate
+	go p.backpressure.Run(p.ctx)
+
+	// Start prioritizing sealing of deals that are close to their start epoch
+	go p.sealingPriority.Run(p.ctx)
+
+	// Start recording the number of deals at each checkpoint
+	go p.recordDealMetrics(p.ctx)
+
 	// Start hourly deal log cleanup
 	if p.config.DealLogDurationDays > 0 {
 		go p.dealLogger.LogCleanup(p.ctx, p.config.DealLogDurationDays)
@@ -528,6 +660,9 @@ func (p *Provider) Stop() {
 		log.Infow("storage provider: stop run loop")
 		p.cancel()
 		p.runWG.Wait()
+		if p.auditWriter != nil {
+			p.auditWriter.close()
+		}
 		log.Info("storage provider: shutdown complete")
 	})
 }
@@ -598,6 +733,12 @@ func (p *Provider) CancelDealDataTransfer(dealUuid uuid.UUID) error {
 	return err
 }
 
+// SectorPackingReport returns the sector utilization achieved for the
+// sectors that deals were most recently added to
+func (p *Provider) SectorPackingReport() *sectorpacking.Report {
+	return p.sectorPacker.Report()
+}
+
 func (p *Provider) AddPieceToSector(ctx context.Context, deal smtypes.ProviderDealState, pieceData io.Reader) (*storagemarket.PackingResult, error) {
 	// Sanity check - we must have published the deal before handing it off
 	// to the sealing subsystem
@@ -618,7 +759,7 @@ func (p *Provider) AddPieceToSector(ctx context.Context, deal smtypes.ProviderDe
 
 	// Attempt to add the piece to a sector (repeatedly if necessary)
 	pieceSize := deal.ClientDealProposal.Proposal.PieceSize.Unpadded()
-	sectorNum, offset, err := p.pieceAdder.AddPiece(ctx, pieceSize, pieceData, sdInfo)
+	sectorNum, offset, err := p.sealer.AddPiece(ctx, pieceSize, pieceData, sdInfo)
 	curTime := build.Clock.Now()
 
 	for build.Clock.Since(curTime) < addPieceRetryTimeout {
@@ -630,7 +771,7 @@ func (p *Provider) AddPieceToSector(ctx context.Context, deal smtypes.ProviderDe
 		}
 		select {
 		case <-build.Clock.After(addPieceRetryWait):
-			sectorNum, offset, err = p.pieceAdder.AddPiece(ctx, pieceSize, pieceData, sdInfo)
+			sectorNum, offset, err = p.sealer.AddPiece(ctx, pieceSize, pieceData, sdInfo)
 		case <-ctx.Done():
 			return nil, fmt.Errorf("error while waiting to retry AddPiece: %w", ctx.Err())
 		}
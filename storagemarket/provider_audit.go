@@ -0,0 +1,173 @@
+package storagemarket
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/storagemarket/logs"
+	"github.com/filecoin-project/boost/storagemarket/types"
+)
+
+// The acceptance rules that are recorded in the audit log when a deal
+// proposal is accepted or rejected (the deal filter's rules are defined in
+// the dealfilter package)
+const (
+	auditRuleValidation     = "validation"
+	auditRuleUniqueProposal = "unique-proposal"
+	auditRuleUniqueUuid     = "unique-uuid"
+	auditRuleFunds          = "funds"
+	auditRuleStorageSpace   = "storage-space"
+	auditRuleBackpressure   = "sealing-backpressure"
+	auditRuleOfflineImport  = "offline-import"
+	auditRuleServerError    = "server-error"
+)
+
+// auditRule returns the rule that caused the deal to be rejected
+func (aerr *acceptError) auditRule() string {
+	if aerr.rule != "" {
+		return aerr.rule
+	}
+	return auditRuleServerError
+}
+
+// auditCheckpoint records a deal's transition from the checkpoint named
+// `from` to its current checkpoint
+func (p *Provider) auditCheckpoint(deal *types.ProviderDealState, from string, detail string) {
+	p.auditEvent(&db.AuditEvent{
+		DealUUID:       deal.DealUuid,
+		Type:           db.AuditEventCheckpoint,
+		Actor:          db.AuditActorSystem,
+		FromCheckpoint: from,
+		ToCheckpoint:   deal.Checkpoint.String(),
+		Detail:         detail,
+	})
+}
+
+// auditDecision records the decision to accept or reject a deal proposal,
+// and the rule that made the decision
+func (p *Provider) auditDecision(deal *types.ProviderDealState, accepted bool, rule string, detail string) {
+	recordDecision(accepted, rule)
+
+	evtType := db.AuditEventRejected
+	if accepted {
+		evtType = db.AuditEventAccepted
+	}
+	p.auditEvent(&db.AuditEvent{
+		DealUUID: deal.DealUuid,
+		Type:     evtType,
+		Actor:    db.AuditActorSystem,
+		Rule:     rule,
+		Detail:   detail,
+	})
+}
+
+// auditEvent queues an event to be written to the audit log. Failure to
+// write to the audit log is logged but does not affect deal execution.
+func (p *Provider) auditEvent(evt *db.AuditEvent) {
+	if p.auditWriter == nil {
+		return
+	}
+	p.auditWriter.write(evt)
+}
+
+// auditWriter writes audit events to the database in the background, so
+// that a slow database doesn't hold up the provider run loop
+type auditWriter struct {
+	auditDB    *db.AuditLogDB
+	dealLogger *logs.DealLogger
+
+	lk     sync.Mutex
+	queue  []*db.AuditEvent
+	closed bool
+	wake   chan struct{}
+	done   chan struct{}
+}
+
+func newAuditWriter(auditDB *db.AuditLogDB, dealLogger *logs.DealLogger) *auditWriter {
+	w := &auditWriter{
+		auditDB:    auditDB,
+		dealLogger: dealLogger,
+		wake:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *auditWriter) write(evt *db.AuditEvent) {
+	// Set the creation time now so that the event is recorded with the
+	// time it happened, rather than the time it was written
+	if evt.CreatedAt.IsZero() {
+		evt.CreatedAt = time.Now()
+	}
+
+	w.lk.Lock()
+	if w.closed {
+		// The writer has been closed (the provider is shutting down) so
+		// write the event straight away
+		w.lk.Unlock()
+		w.insert(evt)
+		return
+	}
+	w.queue = append(w.queue, evt)
+	w.lk.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *auditWriter) run() {
+	defer close(w.done)
+
+	for range w.wake {
+		for {
+			w.lk.Lock()
+			queue := w.queue
+			w.queue = nil
+			closed := w.closed
+			w.lk.Unlock()
+
+			if len(queue) == 0 {
+				if closed {
+					return
+				}
+				break
+			}
+			for _, evt := range queue {
+				w.insert(evt)
+			}
+		}
+	}
+}
+
+func (w *auditWriter) insert(evt *db.AuditEvent) {
+	// Use a background context so that the event is recorded even if the
+	// provider is shutting down
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := w.auditDB.Insert(ctx, evt); err != nil {
+		w.dealLogger.LogError(evt.DealUUID, "failed to write audit event", err)
+	}
+}
+
+// close waits for the queued events to be written
+func (w *auditWriter) close() {
+	w.lk.Lock()
+	if w.closed {
+		w.lk.Unlock()
+		return
+	}
+	w.closed = true
+	w.lk.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+	<-w.done
+}
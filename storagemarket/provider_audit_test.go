@@ -0,0 +1,40 @@
+package storagemarket
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/filecoin-project/boost/storagemarket/logs"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditWriter(t *testing.T) {
+	ctx := context.Background()
+
+	sqldb := db.CreateTestTmpDB(t)
+	require.NoError(t, db.CreateAllBoostTables(ctx, sqldb, sqldb))
+	require.NoError(t, migrations.Migrate(sqldb))
+	auditDB := db.NewAuditLogDB(sqldb)
+
+	w := newAuditWriter(auditDB, logs.NewDealLogger(db.NewLogsDB(sqldb)))
+
+	dealUuid := uuid.New()
+	for i := 0; i < 10; i++ {
+		w.write(&db.AuditEvent{DealUUID: dealUuid, Type: db.AuditEventCheckpoint, Actor: db.AuditActorSystem})
+	}
+
+	// Closing the writer should wait for the queued events to be written
+	w.close()
+	count, err := auditDB.Count(ctx, &dealUuid)
+	require.NoError(t, err)
+	require.Equal(t, 10, count)
+
+	// Events written after the writer is closed are written straight away
+	w.write(&db.AuditEvent{DealUUID: dealUuid, Type: db.AuditEventAccepted, Actor: db.AuditActorSystem})
+	count, err = auditDB.Count(ctx, &dealUuid)
+	require.NoError(t, err)
+	require.Equal(t, 11, count)
+}
@@ -0,0 +1,48 @@
+package storagemarket
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/boost/storagemarket/backpressure"
+	"github.com/filecoin-project/boost/storagemarket/types"
+)
+
+// checkBackpressure rejects the deal if accepting it would exceed the accept
+// rate for the current sealing throughput
+func (p *Provider) checkBackpressure(deal *types.ProviderDealState) *acceptError {
+	if err := p.backpressure.Allow(deal.ClientDealProposal.Proposal.PieceSize); err != nil {
+		return &acceptError{
+			error:         fmt.Errorf("sealing backpressure: %w", err),
+			reason:        "sealing pipeline is backlogged: try again later",
+			isSevereError: false,
+			rule:          auditRuleBackpressure,
+		}
+	}
+	return nil
+}
+
+// sealingBacklog returns the number of bytes of deal data that has been
+// accepted but not yet sealed
+func (p *Provider) sealingBacklog(ctx context.Context) (uint64, error) {
+	deals, err := p.dealsDB.ListActive(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("getting active deals: %w", err)
+	}
+
+	var backlog uint64
+	for _, d := range deals {
+		// Failed deals won't be sealed
+		if d.Err != "" {
+			continue
+		}
+		backlog += uint64(d.ClientDealProposal.Proposal.PieceSize)
+	}
+	return backlog, nil
+}
+
+// SealingBackpressureStatus returns the sealing throughput and the rate at
+// which deal data is being accepted
+func (p *Provider) SealingBackpressureStatus() backpressure.Status {
+	return p.backpressure.Status()
+}
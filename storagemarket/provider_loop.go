@@ -67,41 +67,45 @@ type acceptError struct {
 	isSevereError bool
 	// The reason sent to the client for why their deal was rejected
 	reason string
+	// The acceptance rule that rejected the deal (recorded in the audit log)
+	rule string
 }
 
 // we still need to call the BasicDealFilter() even when external deal filter is not set.
 // Once BasicDealFilter() completes the checks like are we accepting online deal, verified deal etc.
 // Then it runs the external "cmd" filter. Thus, runDealFilters is not optional of any type of deal
-func (p *Provider) runDealFilters(deal *types.ProviderDealState) *acceptError {
+func (p *Provider) runDealFilters(deal *types.ProviderDealState) (string, *acceptError) {
 
 	// run custom storage deal filter decision logic
 	dealFilterParams, aerr := p.getDealFilterParams(deal)
 	if aerr != nil {
-		return aerr
+		return "", aerr
 	}
-	accept, reason, err := p.df(p.ctx, *dealFilterParams)
+	decision, err := p.df(p.ctx, *dealFilterParams)
 	if err != nil {
-		return &acceptError{
+		return "", &acceptError{
 			error:         fmt.Errorf("failed to invoke deal filter: %w", err),
 			reason:        "server error: deal filter error",
 			isSevereError: true,
+			rule:          decision.Rule,
 		}
 	}
 
-	if !accept {
-		return &acceptError{
-			error:         fmt.Errorf("deal filter rejected deal: %s", reason),
-			reason:        reason,
+	if !decision.Accept {
+		return "", &acceptError{
+			error:         fmt.Errorf("deal filter rejected deal: %s", decision.Reason),
+			reason:        decision.Reason,
 			isSevereError: false,
+			rule:          decision.Rule,
 		}
 	}
-	return nil
+	return decision.Rule, nil
 }
 
-func (p *Provider) processDealProposal(deal *types.ProviderDealState) *acceptError {
+func (p *Provider) processDealProposal(deal *types.ProviderDealState) (string, *acceptError) {
 	host, err := deal.Transfer.Host()
 	if err != nil {
-		return &acceptError{
+		return "", &acceptError{
 			error:         fmt.Errorf("failed to get deal transfer host: %w", err),
 			reason:        fmt.Sprintf("server error: get deal transfer host: %s", err),
 			isSevereError: false,
@@ -110,17 +114,24 @@ func (p *Provider) processDealProposal(deal *types.ProviderDealState) *acceptErr
 
 	// Check that the deal proposal is unique
 	if aerr := p.checkDealPropUnique(deal); aerr != nil {
-		return aerr
+		return "", aerr
 	}
 
 	// Check that the deal uuid is unique
 	if aerr := p.checkDealUuidUnique(deal); aerr != nil {
-		return aerr
+		return "", aerr
 	}
 
 	// we still need to call runDealFilters() even when external deal filter is not set
-	if aerr := p.runDealFilters(deal); aerr != nil {
-		return aerr
+	rule, aerr := p.runDealFilters(deal)
+	if aerr != nil {
+		return "", aerr
+	}
+
+	// Check that accepting the deal won't grow the sealing backlog faster
+	// than the sealing pipeline can seal it
+	if aerr := p.checkBackpressure(deal); aerr != nil {
+		return "", aerr
 	}
 
 	cleanup := func() {
@@ -130,6 +141,7 @@ func (p *Provider) processDealProposal(deal *types.ProviderDealState) *acceptErr
 		} else if errf == nil {
 			p.dealLogger.Infow(deal.DealUuid, "untagged funds for deal cleanup", "untagged publish", pub, "untagged collateral", collat,
 				"err", errf)
+			p.recordFundsRelease(deal, collat, pub)
 		}
 
 		errs := p.storageManager.Untag(p.ctx, deal.DealUuid)
@@ -155,17 +167,21 @@ func (p *Provider) processDealProposal(deal *types.ProviderDealState) *acceptErr
 			error:         err,
 			reason:        "server error: tag funds",
 			isSevereError: true,
+			rule:          auditRuleFunds,
 		}
 		if errors.Is(err, fundmanager.ErrInsufficientFunds) {
 			aerr.reason = "server error: provider has insufficient funds to accept deal"
 			aerr.isSevereError = false
 		}
-		return aerr
+		return "", aerr
 	}
 	p.logFunds(deal.DealUuid, trsp)
 
-	// tag the storage required for the deal in the staging area
-	err = p.storageManager.Tag(p.ctx, deal.DealUuid, deal.Transfer.Size, host)
+	// tag the storage required for the deal in the staging area (streamed
+	// deals are not staged so they don't need storage space)
+	if !p.streamAddPieceEnabled(deal) {
+		err = p.storageManager.Tag(p.ctx, deal.DealUuid, deal.Transfer.Size, host)
+	}
 	if err != nil {
 		cleanup()
 
@@ -174,12 +190,13 @@ func (p *Provider) processDealProposal(deal *types.ProviderDealState) *acceptErr
 			error:         err,
 			reason:        "server error: tag storage",
 			isSevereError: true,
+			rule:          auditRuleStorageSpace,
 		}
 		if errors.Is(err, storagemanager.ErrNoSpaceLeft) {
 			aerr.reason = "server error: provider has no space left for storage deals"
 			aerr.isSevereError = false
 		}
-		return aerr
+		return "", aerr
 	}
 
 	// create a file in the staging area to which we will download the deal data
@@ -187,7 +204,7 @@ func (p *Provider) processDealProposal(deal *types.ProviderDealState) *acceptErr
 	if err != nil {
 		cleanup()
 
-		return &acceptError{
+		return "", &acceptError{
 			error:         fmt.Errorf("failed to create download staging file for deal: %w", err),
 			reason:        "server error: creating download staging file",
 			isSevereError: true,
@@ -204,7 +221,7 @@ func (p *Provider) processDealProposal(deal *types.ProviderDealState) *acceptErr
 	if err != nil {
 		cleanup()
 
-		return &acceptError{
+		return "", &acceptError{
 			error:         fmt.Errorf("failed to insert deal in db: %w", err),
 			reason:        "server error: save to db",
 			isSevereError: true,
@@ -212,26 +229,34 @@ func (p *Provider) processDealProposal(deal *types.ProviderDealState) *acceptErr
 	}
 
 	p.dealLogger.Infow(deal.DealUuid, "inserted deal into deals DB")
+	p.auditCheckpoint(deal, "", "")
 
-	return nil
+	return rule, nil
 }
 
 // processOfflineDealProposal just saves the deal to the database after running deal filters
 // Execution resumes when processImportOfflineDealData is called.
-func (p *Provider) processOfflineDealProposal(ds *smtypes.ProviderDealState, dh *dealHandler) *acceptError {
+func (p *Provider) processOfflineDealProposal(ds *smtypes.ProviderDealState, dh *dealHandler) (string, *acceptError) {
 	// Check that the deal proposal is unique
 	if aerr := p.checkDealPropUnique(ds); aerr != nil {
-		return aerr
+		return "", aerr
 	}
 
 	// Check that the deal uuid is unique
 	if aerr := p.checkDealUuidUnique(ds); aerr != nil {
-		return aerr
+		return "", aerr
 	}
 
 	// we still need to call runDealFilters() even when external deal filter is not set
-	if aerr := p.runDealFilters(ds); aerr != nil {
-		return aerr
+	rule, aerr := p.runDealFilters(ds)
+	if aerr != nil {
+		return "", aerr
+	}
+
+	// Check that accepting the deal won't grow the sealing backlog faster
+	// than the sealing pipeline can seal it
+	if aerr := p.checkBackpressure(ds); aerr != nil {
+		return "", aerr
 	}
 
 	// Save deal to DB
@@ -239,19 +264,20 @@ func (p *Provider) processOfflineDealProposal(ds *smtypes.ProviderDealState, dh
 	ds.Checkpoint = dealcheckpoints.Accepted
 	ds.CheckpointAt = time.Now()
 	if err := p.dealsDB.Insert(p.ctx, ds); err != nil {
-		return &acceptError{
+		return "", &acceptError{
 			error:         fmt.Errorf("failed to insert deal in db: %w", err),
 			reason:        "server error: save to db",
 			isSevereError: true,
 		}
 	}
+	p.auditCheckpoint(ds, "", "")
 
 	// publish "new deal" event
 	p.fireEventDealNew(ds)
 	// publish an event with the current state of the deal
 	p.fireEventDealUpdate(dh.Publisher, ds)
 
-	return nil
+	return rule, nil
 }
 
 func (p *Provider) processImportOfflineDealData(deal *types.ProviderDealState) *acceptError {
@@ -261,6 +287,7 @@ func (p *Provider) processImportOfflineDealData(deal *types.ProviderDealState) *
 			p.dealLogger.LogError(deal.DealUuid, "failed to untag funds during deal cleanup", errf)
 		} else if errf == nil {
 			p.dealLogger.Infow(deal.DealUuid, "untagged funds for deal cleanup", "untagged publish", pub, "untagged collateral", collat)
+			p.recordFundsRelease(deal, collat, pub)
 		}
 	}
 
@@ -275,6 +302,7 @@ func (p *Provider) processImportOfflineDealData(deal *types.ProviderDealState) *
 			error:         err,
 			reason:        "server error: tag funds",
 			isSevereError: true,
+			rule:          auditRuleFunds,
 		}
 		if errors.Is(err, fundmanager.ErrInsufficientFunds) {
 			aerr.reason = "server error: provider has insufficient funds to accept deal"
@@ -318,6 +346,7 @@ func (p *Provider) checkDealPropUnique(deal *smtypes.ProviderDealState) *acceptE
 		error:         err,
 		reason:        err.Error(),
 		isSevereError: false,
+		rule:          auditRuleUniqueProposal,
 	}
 }
 
@@ -342,6 +371,7 @@ func (p *Provider) checkDealUuidUnique(deal *smtypes.ProviderDealState) *acceptE
 		error:         err,
 		reason:        err.Error(),
 		isSevereError: false,
+		rule:          auditRuleUniqueUuid,
 	}
 }
 
@@ -368,6 +398,8 @@ func (p *Provider) run() {
 			p.dealLogger.Infow(deal.DealUuid, "processing deal acceptance request")
 
 			sendErrorResp := func(aerr *acceptError) {
+				p.auditDecision(deal, false, aerr.auditRule(), aerr.reason)
+
 				// If the error is a severe error (eg can't connect to database)
 				if aerr.isSevereError {
 					// Send a rejection message to the client with a reason for rejection
@@ -395,7 +427,7 @@ func (p *Provider) run() {
 					continue
 				}
 
-				aerr := p.processOfflineDealProposal(dealReq.deal, dh)
+				rule, aerr := p.processOfflineDealProposal(dealReq.deal, dh)
 				if aerr != nil {
 					dh.close()
 					p.delDealHandler(deal.DealUuid)
@@ -404,19 +436,21 @@ func (p *Provider) run() {
 				}
 
 				// The deal proposal was successful. Send an Accept response to the client.
+				p.auditDecision(deal, true, rule, "offline deal accepted, waiting for data import")
 				dealReq.rsp <- acceptDealResp{ri: &api.ProviderDealRejectionInfo{Accepted: true}}
 				// Don't execute the deal now, wait for data import.
 				continue
 			}
 
 			var aerr *acceptError
+			rule := auditRuleOfflineImport
 			if deal.IsOffline {
 				// The Storage Provider is importing offline deal data, so tag
 				// funds for the deal and execute it
 				aerr = p.processImportOfflineDealData(dealReq.deal)
 			} else {
 				// Process a regular deal proposal
-				aerr = p.processDealProposal(dealReq.deal)
+				rule, aerr = p.processDealProposal(dealReq.deal)
 			}
 			if aerr != nil {
 				sendErrorResp(aerr)
@@ -438,6 +472,11 @@ func (p *Provider) run() {
 			}
 
 			// send an accept response
+			if dealReq.isImport {
+				p.auditDecision(deal, true, rule, "offline deal data imported")
+			} else {
+				p.auditDecision(deal, true, rule, "deal accepted")
+			}
 			dealReq.rsp <- acceptDealResp{&api.ProviderDealRejectionInfo{Accepted: true}, nil}
 
 		case storageSpaceDealReq := <-p.storageSpaceChan:
@@ -509,6 +548,7 @@ func (p *Provider) run() {
 			} else if errf == nil {
 				p.dealLogger.Infow(deal.DealUuid, "untagged funds for deal as deal finished", "untagged publish", pub, "untagged collateral", collat,
 					"err", errf)
+				p.recordFundsRelease(deal, collat, pub)
 			}
 
 			errs := p.storageManager.Untag(p.ctx, deal.DealUuid)
@@ -541,6 +581,8 @@ func (p *Provider) startDealThread(dh *dealHandler, deal *types.ProviderDealStat
 		}()
 
 		// Run deal
+		p.dealLogger.Track(deal.DealUuid, deal.ClientDealProposal.Proposal.Client, deal.ClientDealProposal.Proposal.PieceCID)
+		defer p.dealLogger.Untrack(deal.DealUuid)
 		p.runDeal(deal, dh)
 		p.dealLogger.Infow(deal.DealUuid, "deal go-routine finished execution")
 	}()
@@ -557,6 +599,7 @@ func (p *Provider) failPausedDeal(dh *dealHandler, deal *smtypes.ProviderDealSta
 	}
 
 	// Update state in DB with error
+	prev := deal.Checkpoint
 	deal.Checkpoint = dealcheckpoints.Complete
 	deal.Retry = smtypes.DealRetryFatal
 	var err error
@@ -568,6 +611,7 @@ func (p *Provider) failPausedDeal(dh *dealHandler, deal *smtypes.ProviderDealSta
 	deal.Err = "user manually terminated the deal"
 	p.dealLogger.LogError(deal.DealUuid, deal.Err, err)
 	p.saveDealToDB(dh.Publisher, deal)
+	p.auditCheckpoint(deal, prev.String(), deal.Err)
 
 	// Call cleanupDeal in a go-routine because it sends a message to the provider
 	// run loop (and failPausedDeal is called from the same run loop so otherwise
@@ -0,0 +1,76 @@
+package storagemarket
+
+import (
+	"context"
+	"time"
+
+	"github.com/filecoin-project/boost/metrics"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// How often to record the number of deals at each checkpoint
+const dealMetricsInterval = time.Minute
+
+// recordDealMetrics periodically records the number of deals at each
+// checkpoint
+func (p *Provider) recordDealMetrics(ctx context.Context) {
+	ticker := time.NewTicker(dealMetricsInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.recordDealsByCheckpoint(ctx); err != nil && ctx.Err() == nil {
+			log.Warnw("failed to record deals by checkpoint metric", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Provider) recordDealsByCheckpoint(ctx context.Context) error {
+	cpStats, err := p.dealsDB.CheckpointStats(ctx, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]int64, len(cpStats))
+	for _, st := range cpStats {
+		counts[st.Checkpoint] = int64(st.Count)
+	}
+
+	// Record every checkpoint, so that a checkpoint that no longer has any
+	// deals is reported as zero
+	for cp := dealcheckpoints.Accepted; cp <= dealcheckpoints.Complete; cp++ {
+		cpCtx, _ := tag.New(ctx, tag.Upsert(metrics.DealCheckpoint, cp.String()))
+		stats.Record(cpCtx, metrics.DealsByCheckpoint.M(counts[cp.String()]))
+	}
+	return nil
+}
+
+// recordDecision counts the accepted and rejected deal proposals, by the
+// rule that made the decision
+func recordDecision(accepted bool, rule string) {
+	ctx, _ := tag.New(context.Background(), tag.Upsert(metrics.DealRule, rule))
+	if accepted {
+		stats.Record(ctx, metrics.DealProposalsAccepted.M(1))
+	} else {
+		stats.Record(ctx, metrics.DealProposalsRejected.M(1))
+	}
+}
+
+// transferMetricsCtx returns a context tagged with the deal's transfer type,
+// and the remote host for transfers that have one
+func transferMetricsCtx(ctx context.Context, deal *types.ProviderDealState) context.Context {
+	mutators := []tag.Mutator{tag.Upsert(metrics.TransferType, deal.Transfer.Type)}
+	if host, err := deal.Transfer.Host(); err == nil {
+		mutators = append(mutators, tag.Upsert(metrics.TransferHost, host))
+	}
+	ctx, _ = tag.New(ctx, mutators...)
+	return ctx
+}
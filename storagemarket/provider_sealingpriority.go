@@ -0,0 +1,46 @@
+package storagemarket
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/boost/storagemarket/sealingpriority"
+	"github.com/filecoin-project/boost/storagemarket/types/dealcheckpoints"
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+// dealsWaitingToSeal returns the deals that have been added to a sector that
+// hasn't yet finished sealing
+func (p *Provider) dealsWaitingToSeal(ctx context.Context) ([]sealingpriority.Deal, error) {
+	deals, err := p.dealsDB.ListActive(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting active deals: %w", err)
+	}
+
+	var waiting []sealingpriority.Deal
+	for _, d := range deals {
+		if d.Err != "" || d.Checkpoint < dealcheckpoints.AddedPiece {
+			continue
+		}
+		waiting = append(waiting, sealingpriority.Deal{
+			DealUuid:   d.DealUuid,
+			SectorID:   d.SectorID,
+			StartEpoch: d.ClientDealProposal.Proposal.StartEpoch,
+		})
+	}
+	return waiting, nil
+}
+
+func (p *Provider) chainHeight(ctx context.Context) (abi.ChainEpoch, error) {
+	head, err := p.fullnodeApi.ChainHead(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return head.Height(), nil
+}
+
+// SealingPriorityStatus returns the projections for when the sectors that
+// deals have been added to will finish sealing
+func (p *Provider) SealingPriorityStatus() sealingpriority.Status {
+	return p.sealingPriority.Status()
+}
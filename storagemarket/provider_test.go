@@ -20,6 +20,7 @@ import (
 
 	"github.com/filecoin-project/boost/db"
 	"github.com/filecoin-project/boost/fundmanager"
+	"github.com/filecoin-project/boost/sealeradapter"
 	"github.com/filecoin-project/boost/storagemanager"
 	"github.com/filecoin-project/boost/storagemarket/dealfilter"
 	"github.com/filecoin-project/boost/storagemarket/logs"
@@ -1506,15 +1507,15 @@ func NewHarness(t *testing.T, opts ...harnessOpt) *ProviderHarness {
 		MaxStagingDealsBytes:          ph.MaxStagingDealBytes,
 		MaxStagingDealsPercentPerHost: ph.MaxStagingDealPercentPerHost,
 	})
-	sm, err := smInitF(lr, sqldb)
+	sm, err := smInitF(lr, db.NewStorageDB(sqldb))
 	require.NoError(t, err)
 
 	// Set a no-op deal filter unless a deal filter was specified as an option
-	df := func(ctx context.Context, deal dealfilter.DealFilterParams) (bool, string, error) {
-		return true, "", nil
+	df := func(ctx context.Context, deal dealfilter.DealFilterParams) (dealfilter.StorageDealDecision, error) {
+		return dealfilter.StorageDealDecision{Accept: true, Rule: dealfilter.StorageRuleDefault}, nil
 	}
 	if pc.dealFilter != nil {
-		df = pc.dealFilter
+		df = dealfilter.FilterDecider(dealfilter.StorageRuleFilterCommand, pc.dealFilter)
 	}
 
 	ps, err := piecestoreimpl.NewPieceStore(dssync.MutexWrap(ds.NewMapDatastore()))
@@ -1535,8 +1536,8 @@ func NewHarness(t *testing.T, opts ...harnessOpt) *ProviderHarness {
 		SealingPipelineCacheTimeout: time.Second,
 		StorageFilter:               "1",
 	}
-	prov, err := NewProvider(prvCfg, sqldb, dealsDB, fm, sm, fn, minerStub, minerAddr, minerStub, minerStub, sps, minerStub, df, sqldb,
-		logsDB, dagStore, ps, minerStub, askStore, &mockSignatureVerifier{true, nil}, dl, tspt)
+	prov, err := NewProvider(prvCfg, sqldb, dealsDB, db.NewAuditLogDB(sqldb), fm, sm, fn, minerStub, minerAddr, sealeradapter.NewLotus(minerStub, sps), minerStub, sps, minerStub, df, sqldb,
+		logsDB, dagStore, ps, minerStub, askStore, &mockSignatureVerifier{true, nil}, dl, tspt, nil, nil)
 	require.NoError(t, err)
 	ph.Provider = prov
 
@@ -1544,6 +1545,7 @@ func NewHarness(t *testing.T, opts ...harnessOpt) *ProviderHarness {
 	chainHead, err := test.MockTipset(minerAddr, 1)
 	require.NoError(t, err)
 	fn.EXPECT().ChainHead(gomock.Any()).Return(chainHead, nil).AnyTimes()
+	fn.EXPECT().StateReplay(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("not supported")).AnyTimes()
 	fn.EXPECT().StateDealProviderCollateralBounds(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(lapi.DealCollateralBounds{
 		Min: abi.NewTokenAmount(1),
 		Max: abi.NewTokenAmount(1),
@@ -1560,6 +1562,10 @@ func NewHarness(t *testing.T, opts ...harnessOpt) *ProviderHarness {
 	ph.MockSealingPipelineAPI.EXPECT().WorkerJobs(gomock.Any()).Return(map[uuid.UUID][]storiface.WorkerJob{}, nil).AnyTimes()
 
 	ph.MockSealingPipelineAPI.EXPECT().SectorsSummary(gomock.Any()).Return(sealingpipelineStatus, nil).AnyTimes()
+	ph.MockSealingPipelineAPI.EXPECT().ActorAddress(gomock.Any()).Return(ph.MinerAddr, nil).AnyTimes()
+	ph.MockSealingPipelineAPI.EXPECT().ActorSectorSize(gomock.Any(), gomock.Any()).Return(abi.SectorSize(32<<30), nil).AnyTimes()
+	ph.MockSealingPipelineAPI.EXPECT().SectorsListInStates(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	ph.MockSealingPipelineAPI.EXPECT().WorkerStats(gomock.Any()).Return(map[uuid.UUID]storiface.WorkerStats{}, nil).AnyTimes()
 
 	secInfo := lapi.SectorInfo{State: lapi.SectorState(sealing.Proving)}
 	ph.MockSealingPipelineAPI.EXPECT().SectorsStatus(gomock.Any(), gomock.Any(), false).Return(secInfo, nil).AnyTimes()
@@ -1587,15 +1593,15 @@ func (h *ProviderHarness) shutdownAndCreateNewProvider(t *testing.T, opts ...har
 	h.Provider.Stop()
 	h.MinerStub = smtestutil.NewMinerStub(h.GoMockCtrl)
 	// no-op deal filter, as we are mostly testing the Provider and provider_loop here
-	df := func(ctx context.Context, deal dealfilter.DealFilterParams) (bool, string, error) {
-		return true, "", nil
+	df := func(ctx context.Context, deal dealfilter.DealFilterParams) (dealfilter.StorageDealDecision, error) {
+		return dealfilter.StorageDealDecision{Accept: true, Rule: dealfilter.StorageRuleDefault}, nil
 	}
 
 	// construct a new provider with pre-existing state
-	prov, err := NewProvider(h.Provider.config, h.Provider.db, h.Provider.dealsDB, h.Provider.fundManager,
-		h.Provider.storageManager, h.Provider.fullnodeApi, h.MinerStub, h.MinerAddr, h.MinerStub, h.MinerStub, h.MockSealingPipelineAPI, h.MinerStub,
+	prov, err := NewProvider(h.Provider.config, h.Provider.db, h.Provider.dealsDB, h.Provider.auditDB, h.Provider.fundManager,
+		h.Provider.storageManager, h.Provider.fullnodeApi, h.MinerStub, h.MinerAddr, h.Provider.sealer, h.MinerStub, h.MockSealingPipelineAPI, h.MinerStub,
 		df, h.Provider.logsSqlDB, h.Provider.logsDB, h.Provider.dagst, h.Provider.ps, h.MinerStub, h.Provider.askGetter,
-		h.Provider.sigVerifier, h.Provider.dealLogger, h.Provider.Transport)
+		h.Provider.sigVerifier, h.Provider.dealLogger, h.Provider.Transport, h.Provider.pieceCopies, h.Provider.existingPieces)
 
 	require.NoError(t, err)
 	h.Provider = prov
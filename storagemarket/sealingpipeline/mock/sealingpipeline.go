@@ -11,6 +11,7 @@ import (
 	address "github.com/filecoin-project/go-address"
 	abi "github.com/filecoin-project/go-state-types/abi"
 	api "github.com/filecoin-project/lotus/api"
+	sealiface "github.com/filecoin-project/lotus/storage/pipeline/sealiface"
 	storiface "github.com/filecoin-project/lotus/storage/sealer/storiface"
 	gomock "github.com/golang/mock/gomock"
 	uuid "github.com/google/uuid"
@@ -54,6 +55,79 @@ func (mr *MockAPIMockRecorder) ActorAddress(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ActorAddress", reflect.TypeOf((*MockAPI)(nil).ActorAddress), arg0)
 }
 
+// ActorSectorSize mocks base method.
+func (m *MockAPI) ActorSectorSize(arg0 context.Context, arg1 address.Address) (abi.SectorSize, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ActorSectorSize", arg0, arg1)
+	ret0, _ := ret[0].(abi.SectorSize)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ActorSectorSize indicates an expected call of ActorSectorSize.
+func (mr *MockAPIMockRecorder) ActorSectorSize(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ActorSectorSize", reflect.TypeOf((*MockAPI)(nil).ActorSectorSize), arg0, arg1)
+}
+
+// SectorCommitFlush mocks base method.
+func (m *MockAPI) SectorCommitFlush(arg0 context.Context) ([]sealiface.CommitBatchRes, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SectorCommitFlush", arg0)
+	ret0, _ := ret[0].([]sealiface.CommitBatchRes)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SectorCommitFlush indicates an expected call of SectorCommitFlush.
+func (mr *MockAPIMockRecorder) SectorCommitFlush(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SectorCommitFlush", reflect.TypeOf((*MockAPI)(nil).SectorCommitFlush), arg0)
+}
+
+// SectorMarkForUpgrade mocks base method.
+func (m *MockAPI) SectorMarkForUpgrade(arg0 context.Context, arg1 abi.SectorNumber, arg2 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SectorMarkForUpgrade", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SectorMarkForUpgrade indicates an expected call of SectorMarkForUpgrade.
+func (mr *MockAPIMockRecorder) SectorMarkForUpgrade(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SectorMarkForUpgrade", reflect.TypeOf((*MockAPI)(nil).SectorMarkForUpgrade), arg0, arg1, arg2)
+}
+
+// SectorPreCommitFlush mocks base method.
+func (m *MockAPI) SectorPreCommitFlush(arg0 context.Context) ([]sealiface.PreCommitBatchRes, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SectorPreCommitFlush", arg0)
+	ret0, _ := ret[0].([]sealiface.PreCommitBatchRes)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SectorPreCommitFlush indicates an expected call of SectorPreCommitFlush.
+func (mr *MockAPIMockRecorder) SectorPreCommitFlush(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SectorPreCommitFlush", reflect.TypeOf((*MockAPI)(nil).SectorPreCommitFlush), arg0)
+}
+
+// SectorStartSealing mocks base method.
+func (m *MockAPI) SectorStartSealing(arg0 context.Context, arg1 abi.SectorNumber) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SectorStartSealing", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SectorStartSealing indicates an expected call of SectorStartSealing.
+func (mr *MockAPIMockRecorder) SectorStartSealing(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SectorStartSealing", reflect.TypeOf((*MockAPI)(nil).SectorStartSealing), arg0, arg1)
+}
+
 // SectorsList mocks base method.
 func (m *MockAPI) SectorsList(arg0 context.Context) ([]abi.SectorNumber, error) {
 	m.ctrl.T.Helper()
@@ -128,3 +202,18 @@ func (mr *MockAPIMockRecorder) WorkerJobs(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WorkerJobs", reflect.TypeOf((*MockAPI)(nil).WorkerJobs), arg0)
 }
+
+// WorkerStats mocks base method.
+func (m *MockAPI) WorkerStats(arg0 context.Context) (map[uuid.UUID]storiface.WorkerStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WorkerStats", arg0)
+	ret0, _ := ret[0].(map[uuid.UUID]storiface.WorkerStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WorkerStats indicates an expected call of WorkerStats.
+func (mr *MockAPIMockRecorder) WorkerStats(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WorkerStats", reflect.TypeOf((*MockAPI)(nil).WorkerStats), arg0)
+}
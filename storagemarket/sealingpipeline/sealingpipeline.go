@@ -4,24 +4,43 @@ package sealingpipeline
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/storage/pipeline/sealiface"
 	"github.com/filecoin-project/lotus/storage/sealer/storiface"
 	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
 )
 
+var log = logging.Logger("sealingpipeline")
+
 type API interface {
 	ActorAddress(context.Context) (address.Address, error)
+	ActorSectorSize(context.Context, address.Address) (abi.SectorSize, error)
 	WorkerJobs(context.Context) (map[uuid.UUID][]storiface.WorkerJob, error)
+	WorkerStats(context.Context) (map[uuid.UUID]storiface.WorkerStats, error)
 	SectorsStatus(ctx context.Context, sid abi.SectorNumber, showOnChainInfo bool) (api.SectorInfo, error)
 	SectorsList(context.Context) ([]abi.SectorNumber, error)
 	SectorsSummary(ctx context.Context) (map[api.SectorState]int, error)
 	SectorsListInStates(context.Context, []api.SectorState) ([]abi.SectorNumber, error)
+	SectorMarkForUpgrade(ctx context.Context, id abi.SectorNumber, snap bool) error
+	SectorStartSealing(context.Context, abi.SectorNumber) error
+	SectorPreCommitFlush(ctx context.Context) ([]sealiface.PreCommitBatchRes, error)
+	SectorCommitFlush(ctx context.Context) ([]sealiface.CommitBatchRes, error)
 }
 
+// The states of sectors that are waiting for deals to be added to them
+const (
+	WaitDeals          = api.SectorState("WaitDeals")
+	SnapDealsWaitDeals = api.SectorState("SnapDealsWaitDeals")
+)
+
 func GetStatus(ctx context.Context, api API) (*Status, error) {
 	res, err := api.WorkerJobs(ctx)
 	if err != nil {
@@ -45,14 +64,126 @@ func GetStatus(ctx context.Context, api API) (*Status, error) {
 		return nil, err
 	}
 
+	maddr, err := api.ActorAddress(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ssize, err := api.ActorSectorSize(ctx, maddr)
+	if err != nil {
+		return nil, fmt.Errorf("getting sector size: %w", err)
+	}
+
+	waitDeals, err := getWaitDealsSectors(ctx, api, WaitDeals, ssize)
+	if err != nil {
+		return nil, err
+	}
+	snapWaitDeals, err := getWaitDealsSectors(ctx, api, SnapDealsWaitDeals, ssize)
+	if err != nil {
+		return nil, err
+	}
+
 	st := &Status{
-		SectorStates: summary,
-		Workers:      workers,
+		SectorStates:              summary,
+		Workers:                   workers,
+		SectorSize:                ssize,
+		WaitDealsSectors:          waitDeals,
+		SnapDealsWaitDealsSectors: snapWaitDeals,
+		WorkerUtilization:         getWorkerUtilization(ctx, api, res),
 	}
 
 	return st, nil
 }
 
+// getWaitDealsSectors gets the sectors in the given wait deals state, with
+// the pieces that have been added to each sector and its remaining capacity
+func getWaitDealsSectors(ctx context.Context, sp API, state api.SectorState, ssize abi.SectorSize) ([]*WaitDealsSector, error) {
+	sectorNumbers, err := sp.SectorsListInStates(ctx, []api.SectorState{state})
+	if err != nil {
+		return nil, fmt.Errorf("listing sectors in state %s: %w", state, err)
+	}
+
+	sectors := make([]*WaitDealsSector, 0, len(sectorNumbers))
+	for _, sn := range sectorNumbers {
+		si, err := sp.SectorsStatus(ctx, sn, false)
+		if err != nil {
+			return nil, fmt.Errorf("getting status of sector %d: %w", sn, err)
+		}
+
+		sector := &WaitDealsSector{SectorID: sn}
+		for _, p := range si.Pieces {
+			sector.Used += p.Piece.Size
+
+			piece := WaitDealsPiece{Size: p.Piece.Size}
+			if p.DealInfo != nil {
+				piece.DealID = p.DealInfo.DealID
+				piece.PublishCid = p.DealInfo.PublishCid
+				if p.DealInfo.DealProposal != nil {
+					propCid, err := p.DealInfo.DealProposal.Cid()
+					if err != nil {
+						return nil, fmt.Errorf("getting proposal cid for deal in sector %d: %w", sn, err)
+					}
+					piece.ProposalCid = &propCid
+				}
+			}
+			sector.Pieces = append(sector.Pieces, piece)
+		}
+		if abi.PaddedPieceSize(ssize) > sector.Used {
+			sector.Free = abi.PaddedPieceSize(ssize) - sector.Used
+		}
+		sectors = append(sectors, sector)
+	}
+
+	sort.Slice(sectors, func(i, j int) bool {
+		return sectors[i].SectorID < sectors[j].SectorID
+	})
+	return sectors, nil
+}
+
+// getWorkerUtilization gets the resource usage and job counts for each
+// worker. Getting worker stats requires an admin token for the sealing
+// service API, so if it fails the error is logged and no utilization is
+// returned, rather than failing the whole status.
+func getWorkerUtilization(ctx context.Context, sp API, jobs map[uuid.UUID][]storiface.WorkerJob) []*WorkerUtilization {
+	stats, err := sp.WorkerStats(ctx)
+	if err != nil {
+		log.Warnw("getting worker stats", "err", err)
+		return nil
+	}
+
+	utilization := make([]*WorkerUtilization, 0, len(stats))
+	for id, st := range stats {
+		res := st.Info.Resources
+		wu := &WorkerUtilization{
+			ID:          id.String(),
+			Hostname:    st.Info.Hostname,
+			Enabled:     st.Enabled,
+			CPUs:        res.CPUs,
+			CPUsUsed:    st.CpuUse,
+			GPUs:        len(res.GPUs),
+			GPUsUsed:    st.GpuUsed,
+			MemPhysical: res.MemPhysical,
+			MemUsed:     st.MemUsedMax,
+		}
+		for _, j := range jobs[id] {
+			switch {
+			case j.RunWait == storiface.RWRunning:
+				wu.RunningJobs++
+			case j.RunWait > storiface.RWRunning:
+				wu.QueuedJobs++
+			}
+		}
+		utilization = append(utilization, wu)
+	}
+
+	sort.Slice(utilization, func(i, j int) bool {
+		if utilization[i].Hostname != utilization[j].Hostname {
+			return utilization[i].Hostname < utilization[j].Hostname
+		}
+		return utilization[i].ID < utilization[j].ID
+	})
+	return utilization
+}
+
 type worker struct {
 	ID     string
 	Start  time.Time
@@ -60,8 +191,56 @@ type worker struct {
 	Sector int32
 }
 
+// WaitDealsSector is a sector that is waiting for deals to be added to it
+type WaitDealsSector struct {
+	SectorID abi.SectorNumber
+	Pieces   []WaitDealsPiece
+	// The space used by pieces in the sector
+	Used abi.PaddedPieceSize
+	// The space remaining for deals in the sector
+	Free abi.PaddedPieceSize
+}
+
+// WaitDealsPiece is a piece that has been added to a sector that is waiting
+// for deals
+type WaitDealsPiece struct {
+	Size abi.PaddedPieceSize
+	// The deal fields are empty for pieces that are not deals (eg filler
+	// pieces)
+	DealID      abi.DealID
+	PublishCid  *cid.Cid
+	ProposalCid *cid.Cid
+}
+
+// WorkerUtilization is the resource usage and job counts for a sealing
+// worker
+type WorkerUtilization struct {
+	ID       string
+	Hostname string
+	Enabled  bool
+
+	CPUs        uint64
+	CPUsUsed    uint64
+	GPUs        int
+	GPUsUsed    float64
+	MemPhysical uint64
+	MemUsed     uint64
+
+	// The number of jobs running on the worker
+	RunningJobs int
+	// The number of jobs assigned to the worker that haven't started yet
+	QueuedJobs int
+}
+
 // TODO: maybe add json tags
 type Status struct {
 	SectorStates map[api.SectorState]int
 	Workers      []*worker
+
+	SectorSize abi.SectorSize
+	// The sectors waiting for deals, and the remaining capacity of each
+	WaitDealsSectors          []*WaitDealsSector
+	SnapDealsWaitDealsSectors []*WaitDealsSector
+	// The resource usage of each sealing worker
+	WorkerUtilization []*WorkerUtilization
 }
@@ -0,0 +1,330 @@
+package sealingpriority
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/metrics"
+	"github.com/filecoin-project/boost/storagemarket/sealingpipeline"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/google/uuid"
+	logging "github.com/ipfs/go-log/v2"
+	"go.opencensus.io/stats"
+)
+
+var log = logging.Logger("sealingpriority")
+
+type Config struct {
+	// Whether to prioritize sealing by deal start epoch
+	Enabled bool
+	// How often to check the sectors that deals have been added to
+	CheckInterval time.Duration
+	// The expected amount of time to seal a sector, from when it starts
+	// sealing until it is proving
+	ExpectedSealDuration time.Duration
+	// Sectors are prioritized when they are projected to finish sealing less
+	// than this amount of time before their earliest deal start epoch
+	PriorityMargin time.Duration
+}
+
+// Deal is a deal that has been added to a sector that hasn't finished sealing
+type Deal struct {
+	DealUuid   uuid.UUID
+	SectorID   abi.SectorNumber
+	StartEpoch abi.ChainEpoch
+}
+
+// DealsFunc returns the deals that are waiting for their sector to be sealed
+type DealsFunc func(ctx context.Context) ([]Deal, error)
+
+// HeightFunc returns the current chain height
+type HeightFunc func(ctx context.Context) (abi.ChainEpoch, error)
+
+// SectorDeadline is the projection for when a sector will finish sealing,
+// compared to the start epoch of the earliest deal in the sector
+type SectorDeadline struct {
+	SectorID abi.SectorNumber
+	State    api.SectorState
+	// The earliest start epoch of the deals in the sector
+	StartEpoch abi.ChainEpoch
+	// The time at which the start epoch is expected to be reached
+	Deadline time.Time
+	// The time at which the sector is projected to finish sealing
+	ProjectedSealed time.Time
+	Deals           []uuid.UUID
+	// Whether sealing of the sector was prioritized
+	Prioritized bool
+	// Whether the sector is projected to finish sealing after the start epoch
+	AtRisk bool
+}
+
+type Status struct {
+	Enabled   bool
+	CheckedAt time.Time
+	// Sorted by deadline
+	Sectors []SectorDeadline
+}
+
+// Monitor periodically projects when the sectors that deals have been added
+// to will finish sealing. Sectors with deals that are close to their start
+// epoch are prioritized: sectors waiting for deals start sealing
+// immediately, and batched pre-commit and commit messages are sent
+// immediately. When a deal is projected to miss its start epoch, an event is
+// written to the audit log (which is sent to any webhooks that subscribe to
+// it).
+type Monitor struct {
+	cfg     Config
+	sps     sealingpipeline.API
+	height  HeightFunc
+	deals   DealsFunc
+	auditDB *db.AuditLogDB
+	now     func() time.Time
+
+	lk     sync.Mutex
+	status Status
+	// The sectors that have been prioritized in their current state
+	prioritized map[abi.SectorNumber]api.SectorState
+	// The deals that have been reported as at risk of missing their start
+	// epoch
+	atRisk map[uuid.UUID]struct{}
+}
+
+func New(cfg Config, sps sealingpipeline.API, height HeightFunc, deals DealsFunc, auditDB *db.AuditLogDB) *Monitor {
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = 5 * time.Minute
+	}
+	if cfg.ExpectedSealDuration <= 0 {
+		cfg.ExpectedSealDuration = 6 * time.Hour
+	}
+	return &Monitor{
+		cfg:         cfg,
+		sps:         sps,
+		height:      height,
+		deals:       deals,
+		auditDB:     auditDB,
+		now:         time.Now,
+		status:      Status{Enabled: cfg.Enabled},
+		prioritized: make(map[abi.SectorNumber]api.SectorState),
+		atRisk:      make(map[uuid.UUID]struct{}),
+	}
+}
+
+func (m *Monitor) Run(ctx context.Context) {
+	if !m.cfg.Enabled {
+		return
+	}
+
+	log.Infow("starting start epoch sealing prioritization", "check interval", m.cfg.CheckInterval,
+		"expected seal duration", m.cfg.ExpectedSealDuration, "priority margin", m.cfg.PriorityMargin)
+
+	ticker := time.NewTicker(m.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.check(ctx); err != nil && ctx.Err() == nil {
+			log.Errorw("failed to check sealing deadlines", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the projections from the most recent check
+func (m *Monitor) Status() Status {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	st := m.status
+	st.Sectors = append([]SectorDeadline(nil), m.status.Sectors...)
+	return st
+}
+
+func (m *Monitor) check(ctx context.Context) error {
+	deals, err := m.deals(ctx)
+	if err != nil {
+		return fmt.Errorf("getting deals waiting to be sealed: %w", err)
+	}
+	head, err := m.height(ctx)
+	if err != nil {
+		return fmt.Errorf("getting chain head: %w", err)
+	}
+
+	// Group the deals by sector
+	sectors := make(map[abi.SectorNumber]*SectorDeadline)
+	for _, d := range deals {
+		sd, ok := sectors[d.SectorID]
+		if !ok {
+			sd = &SectorDeadline{SectorID: d.SectorID, StartEpoch: d.StartEpoch}
+			sectors[d.SectorID] = sd
+		}
+		if d.StartEpoch < sd.StartEpoch {
+			sd.StartEpoch = d.StartEpoch
+		}
+		sd.Deals = append(sd.Deals, d.DealUuid)
+	}
+
+	now := m.now()
+	var atRiskCount int64
+	deadlines := make([]SectorDeadline, 0, len(sectors))
+	for _, sd := range sectors {
+		si, err := m.sps.SectorsStatus(ctx, sd.SectorID, false)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Warnw("failed to get sector status", "sector", sd.SectorID, "err", err)
+			continue
+		}
+
+		sd.State = si.State
+		remaining, ok := remainingSealFraction(si.State)
+		if !ok {
+			// The sector has finished sealing or has failed
+			continue
+		}
+
+		sd.Deadline = now.Add(time.Duration(sd.StartEpoch-head) * time.Duration(builtin.EpochDurationSeconds) * time.Second)
+		sd.ProjectedSealed = now.Add(time.Duration(remaining * float64(m.cfg.ExpectedSealDuration)))
+		sd.AtRisk = sd.ProjectedSealed.After(sd.Deadline)
+		if sd.ProjectedSealed.Add(m.cfg.PriorityMargin).After(sd.Deadline) {
+			sd.Prioritized = m.prioritize(ctx, sd)
+		}
+		if sd.AtRisk {
+			atRiskCount += int64(len(sd.Deals))
+			m.alertAtRisk(ctx, sd, head)
+		}
+
+		deadlines = append(deadlines, *sd)
+	}
+
+	sort.Slice(deadlines, func(i, j int) bool {
+		return deadlines[i].Deadline.Before(deadlines[j].Deadline)
+	})
+
+	stats.Record(ctx, metrics.SealingDealsAtRisk.M(atRiskCount))
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	m.status = Status{Enabled: true, CheckedAt: now, Sectors: deadlines}
+
+	// Forget about sectors and deals that are no longer waiting to be sealed
+	for sector := range m.prioritized {
+		if _, ok := sectors[sector]; !ok {
+			delete(m.prioritized, sector)
+		}
+	}
+	waiting := make(map[uuid.UUID]struct{}, len(deals))
+	for _, d := range deals {
+		waiting[d.DealUuid] = struct{}{}
+	}
+	for dealUuid := range m.atRisk {
+		if _, ok := waiting[dealUuid]; !ok {
+			delete(m.atRisk, dealUuid)
+		}
+	}
+
+	return nil
+}
+
+// prioritize moves the sector on to the next stage of sealing without
+// waiting, if the sector is waiting for more deals or for a batch to fill up.
+// It returns true if the sector has been prioritized in its current state.
+func (m *Monitor) prioritize(ctx context.Context, sd *SectorDeadline) bool {
+	m.lk.Lock()
+	prevState, done := m.prioritized[sd.SectorID]
+	m.lk.Unlock()
+	if done && prevState == sd.State {
+		return true
+	}
+
+	var err error
+	switch sd.State {
+	case sealingpipeline.WaitDeals, sealingpipeline.SnapDealsWaitDeals:
+		err = m.sps.SectorStartSealing(ctx, sd.SectorID)
+	case "PreCommitBatchWait":
+		_, err = m.sps.SectorPreCommitFlush(ctx)
+	case "SubmitCommitAggregate":
+		_, err = m.sps.SectorCommitFlush(ctx)
+	default:
+		// The sector is already being sealed as fast as it can be
+		return false
+	}
+
+	if err != nil {
+		log.Warnw("failed to prioritize sealing of sector", "sector", sd.SectorID, "state", sd.State, "err", err)
+		return false
+	}
+
+	log.Infow("prioritized sealing of sector with deals close to their start epoch",
+		"sector", sd.SectorID, "state", sd.State, "start epoch", sd.StartEpoch, "deadline", sd.Deadline)
+
+	m.lk.Lock()
+	m.prioritized[sd.SectorID] = sd.State
+	m.lk.Unlock()
+	return true
+}
+
+// alertAtRisk writes an audit event for each deal in the sector that hasn't
+// already been reported as at risk of missing its start epoch
+func (m *Monitor) alertAtRisk(ctx context.Context, sd *SectorDeadline, head abi.ChainEpoch) {
+	for _, dealUuid := range sd.Deals {
+		m.lk.Lock()
+		_, reported := m.atRisk[dealUuid]
+		m.atRisk[dealUuid] = struct{}{}
+		m.lk.Unlock()
+		if reported {
+			continue
+		}
+
+		log.Warnw("deal is projected to miss its start epoch", "id", dealUuid, "sector", sd.SectorID,
+			"state", sd.State, "start epoch", sd.StartEpoch, "head", head, "projected sealed", sd.ProjectedSealed)
+
+		evt := &db.AuditEvent{
+			DealUUID: dealUuid,
+			Type:     db.AuditEventStartEpochAtRisk,
+			Actor:    db.AuditActorSystem,
+			Detail: fmt.Sprintf("sector %d (%s) is projected to finish sealing at %s, after the deal start epoch %d (expected at %s)",
+				sd.SectorID, sd.State, sd.ProjectedSealed.Format(time.RFC3339), sd.StartEpoch, sd.Deadline.Format(time.RFC3339)),
+		}
+		if err := m.auditDB.Insert(ctx, evt); err != nil {
+			log.Errorw("failed to write start epoch at risk audit event", "id", dealUuid, "err", err)
+		}
+	}
+}
+
+// remainingSealFraction returns the approximate fraction of the sealing time
+// that remains for a sector in the given state. It returns false if the
+// sector has finished sealing or has failed.
+func remainingSealFraction(state api.SectorState) (float64, bool) {
+	switch state {
+	case "WaitDeals", "AddPiece", "Packing", "GetTicket",
+		"SnapDealsWaitDeals", "SnapDealsAddPiece", "SnapDealsPacking":
+		return 1, true
+	case "PreCommit1":
+		return 0.9, true
+	case "PreCommit2", "UpdateReplica":
+		return 0.5, true
+	case "PreCommitting", "PreCommitBatchWait", "SubmitPreCommitBatch", "PreCommitWait",
+		"ProveReplicaUpdate":
+		return 0.4, true
+	case "WaitSeed":
+		return 0.3, true
+	case "Committing", "SubmitReplicaUpdate":
+		return 0.2, true
+	case "CommitFinalize", "SubmitCommit", "SubmitCommitAggregate", "CommitAggregateWait", "CommitWait",
+		"FinalizeSector", "ReplicaUpdateWait", "FinalizeReplicaUpdate", "UpdateActivating":
+		return 0.1, true
+	}
+	return 0, false
+}
@@ -0,0 +1,112 @@
+package sealingpriority
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/filecoin-project/boost/storagemarket/sealingpipeline/mock"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitor(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	sqldb := db.CreateTestTmpDB(t)
+	require.NoError(t, db.CreateAllBoostTables(ctx, sqldb, sqldb))
+	require.NoError(t, migrations.Migrate(sqldb))
+	auditDB := db.NewAuditLogDB(sqldb)
+
+	const head = abi.ChainEpoch(1000)
+	epochsIn := func(d time.Duration) abi.ChainEpoch {
+		return head + abi.ChainEpoch(d.Seconds())/builtin.EpochDurationSeconds
+	}
+
+	// sector 1: waiting for deals, with a deal that starts in 4 hours
+	// sector 2: in PC1, with a deal that starts in 12 hours
+	// sector 3: waiting for deals, with deals that start in 2 and 24 hours
+	// sector 4: proving
+	dealUuids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()}
+	deals := []Deal{
+		{DealUuid: dealUuids[0], SectorID: 1, StartEpoch: epochsIn(4 * time.Hour)},
+		{DealUuid: dealUuids[1], SectorID: 2, StartEpoch: epochsIn(12 * time.Hour)},
+		{DealUuid: dealUuids[2], SectorID: 3, StartEpoch: epochsIn(24 * time.Hour)},
+		{DealUuid: dealUuids[3], SectorID: 3, StartEpoch: epochsIn(2 * time.Hour)},
+		{DealUuid: dealUuids[4], SectorID: 4, StartEpoch: epochsIn(time.Hour)},
+	}
+	states := map[abi.SectorNumber]api.SectorState{1: "WaitDeals", 2: "PreCommit1", 3: "WaitDeals", 4: "Proving"}
+
+	sps := mock.NewMockAPI(ctrl)
+	sps.EXPECT().SectorsStatus(gomock.Any(), gomock.Any(), false).DoAndReturn(
+		func(ctx context.Context, sid abi.SectorNumber, showOnChainInfo bool) (api.SectorInfo, error) {
+			return api.SectorInfo{SectorID: sid, State: states[sid]}, nil
+		}).AnyTimes()
+
+	m := New(Config{
+		Enabled:              true,
+		ExpectedSealDuration: 3 * time.Hour,
+		PriorityMargin:       2 * time.Hour,
+	}, sps, func(ctx context.Context) (abi.ChainEpoch, error) {
+		return head, nil
+	}, func(ctx context.Context) ([]Deal, error) {
+		return deals, nil
+	}, auditDB)
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	// Sector 1 is projected to finish sealing one hour before its deal
+	// starts, which is within the priority margin.
+	// Sector 3 is projected to finish sealing after its earliest deal starts.
+	sps.EXPECT().SectorStartSealing(gomock.Any(), abi.SectorNumber(1)).Return(nil)
+	sps.EXPECT().SectorStartSealing(gomock.Any(), abi.SectorNumber(3)).Return(nil)
+	require.NoError(t, m.check(ctx))
+
+	st := m.Status()
+	require.True(t, st.Enabled)
+	require.Len(t, st.Sectors, 3)
+	require.EqualValues(t, []abi.SectorNumber{3, 1, 2},
+		[]abi.SectorNumber{st.Sectors[0].SectorID, st.Sectors[1].SectorID, st.Sectors[2].SectorID})
+	require.True(t, st.Sectors[0].AtRisk)
+	require.True(t, st.Sectors[0].Prioritized)
+	require.Len(t, st.Sectors[0].Deals, 2)
+	require.False(t, st.Sectors[1].AtRisk)
+	require.True(t, st.Sectors[1].Prioritized)
+	require.False(t, st.Sectors[2].AtRisk)
+	require.False(t, st.Sectors[2].Prioritized)
+
+	// Expect an alert for each deal in the at risk sector
+	atRisk := func() []db.AuditEvent {
+		evts, err := auditDB.List(ctx, nil, nil, 0, 0)
+		require.NoError(t, err)
+		var res []db.AuditEvent
+		for _, evt := range evts {
+			if evt.Type == db.AuditEventStartEpochAtRisk {
+				res = append(res, evt)
+			}
+		}
+		return res
+	}
+	evts := atRisk()
+	require.Len(t, evts, 2)
+	require.ElementsMatch(t, []uuid.UUID{dealUuids[2], dealUuids[3]}, []uuid.UUID{evts[0].DealUUID, evts[1].DealUUID})
+
+	// Checking again should not prioritize the same sectors again, or alert
+	// for the same deals again
+	require.NoError(t, m.check(ctx))
+	require.Len(t, atRisk(), 2)
+
+	// When sector 3 moves to the pre-commit batch, the batch should be sent
+	// immediately
+	states[3] = "PreCommitBatchWait"
+	sps.EXPECT().SectorPreCommitFlush(gomock.Any()).Return(nil, nil)
+	require.NoError(t, m.check(ctx))
+	require.Len(t, atRisk(), 2)
+}
@@ -0,0 +1,385 @@
+package sectorpacking
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/google/uuid"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("sectorpacking")
+
+// The policies for handing deals to the sealing subsystem
+const (
+	// Hand each deal to the sealing subsystem as soon as it's ready
+	PolicyArrival = "arrival"
+	// Collect the deals that are ready over a window, and hand them to the
+	// sealing subsystem in an order that fills sectors with as little
+	// padding as possible, keeping deals with similar end epochs together
+	PolicyBinPack = "bin-pack"
+)
+
+// The number of sectors for which utilization is reported
+const maxReportedSectors = 100
+
+type Config struct {
+	// PolicyArrival or PolicyBinPack
+	Policy string
+	// The amount of time to collect deals before packing them
+	BatchWindow time.Duration
+	// Deals with end epochs that are no further apart than this are packed
+	// into the same sectors
+	EndEpochTolerance abi.ChainEpoch
+}
+
+// Piece is a deal piece that is ready to be added to a sector
+type Piece struct {
+	DealUuid uuid.UUID
+	Size     abi.PaddedPieceSize
+	EndEpoch abi.ChainEpoch
+}
+
+// Bin is a group of pieces that are planned to fill one sector
+type Bin struct {
+	Pieces []Piece
+	Used   abi.PaddedPieceSize
+}
+
+// Plan packs the pieces into bins the size of a sector.
+// Pieces are first grouped by end epoch, so that a sector isn't kept alive
+// by a single long deal. Within each group the pieces are placed largest
+// first into the first bin with space for them. As piece sizes are powers
+// of two, adding pieces to a sector largest first means that every piece is
+// aligned without padding.
+func Plan(pieces []Piece, sectorSize abi.SectorSize, endEpochTolerance abi.ChainEpoch) []*Bin {
+	sorted := make([]Piece, len(pieces))
+	copy(sorted, pieces)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].EndEpoch < sorted[j].EndEpoch
+	})
+
+	var bins []*Bin
+	for len(sorted) > 0 {
+		// Collect the pieces with an end epoch within the tolerance of the
+		// earliest end epoch
+		n := 1
+		for n < len(sorted) && sorted[n].EndEpoch-sorted[0].EndEpoch <= endEpochTolerance {
+			n++
+		}
+		bins = append(bins, packGroup(sorted[:n], abi.PaddedPieceSize(sectorSize))...)
+		sorted = sorted[n:]
+	}
+	return bins
+}
+
+func packGroup(group []Piece, capacity abi.PaddedPieceSize) []*Bin {
+	sort.SliceStable(group, func(i, j int) bool {
+		return group[i].Size > group[j].Size
+	})
+
+	var bins []*Bin
+	for _, p := range group {
+		var bin *Bin
+		for _, b := range bins {
+			if b.Used+p.Size <= capacity {
+				bin = b
+				break
+			}
+		}
+		if bin == nil {
+			bin = &Bin{}
+			bins = append(bins, bin)
+		}
+		bin.Pieces = append(bin.Pieces, p)
+		bin.Used += p.Size
+	}
+	return bins
+}
+
+// SectorUtilization is the space in a sector that is used by the deals
+// that boost added to the sector since it started
+type SectorUtilization struct {
+	SectorID    abi.SectorNumber
+	Deals       int
+	Used        abi.PaddedPieceSize
+	Utilization float64
+}
+
+// BatchPlan describes the last batch of deals that was packed
+type BatchPlan struct {
+	Time    time.Time
+	Deals   int
+	Sectors int
+	// The sector utilization expected from the plan
+	PlannedUtilization float64
+}
+
+// Report describes the sector utilization achieved by packing
+type Report struct {
+	Policy     string
+	SectorSize abi.SectorSize
+	// The sectors that deals were most recently added to
+	Sectors []*SectorUtilization
+	// The average utilization of the sectors
+	AverageUtilization float64
+	// The last batch of deals that was packed (nil if there hasn't been a
+	// batch yet)
+	LastBatch *BatchPlan
+}
+
+type request struct {
+	piece    Piece
+	ready    chan struct{}
+	released bool
+	finished chan struct{}
+	once     sync.Once
+}
+
+func (r *request) finish() {
+	r.once.Do(func() { close(r.finished) })
+}
+
+// Packer decides the order in which deals are handed to the sealing
+// subsystem.
+// The sealing subsystem assigns each piece to a sector itself, so the
+// packer controls the order in which pieces arrive: pieces that are planned
+// for the same sector are added one after another, largest first.
+type Packer struct {
+	cfg        Config
+	sectorSize func(context.Context) (abi.SectorSize, error)
+
+	// seqLk makes sure that only one batch is handed to the sealing
+	// subsystem at a time
+	seqLk sync.Mutex
+
+	lk         sync.Mutex
+	ssize      abi.SectorSize
+	pending    []*request
+	sectors    map[abi.SectorNumber]*SectorUtilization
+	sectorList []abi.SectorNumber
+	lastBatch  *BatchPlan
+}
+
+func New(cfg Config, sectorSize func(context.Context) (abi.SectorSize, error)) (*Packer, error) {
+	if cfg.Policy == "" {
+		cfg.Policy = PolicyArrival
+	}
+	switch cfg.Policy {
+	case PolicyArrival, PolicyBinPack:
+	default:
+		return nil, fmt.Errorf("unknown sector packing policy '%s': must be %s or %s", cfg.Policy, PolicyArrival, PolicyBinPack)
+	}
+	if cfg.BatchWindow <= 0 {
+		cfg.BatchWindow = time.Minute
+	}
+
+	return &Packer{
+		cfg:        cfg,
+		sectorSize: sectorSize,
+		sectors:    make(map[abi.SectorNumber]*SectorUtilization),
+	}, nil
+}
+
+// Acquire waits until it's the piece's turn to be added to a sector.
+// The caller must call the returned function with the result of adding the
+// piece, so that the next piece can be added.
+func (p *Packer) Acquire(ctx context.Context, piece Piece) (func(abi.SectorNumber, error), error) {
+	if p.cfg.Policy == PolicyArrival {
+		return func(sector abi.SectorNumber, err error) {
+			if err == nil {
+				p.record(ctx, sector, piece)
+			}
+		}, nil
+	}
+
+	req := &request{
+		piece:    piece,
+		ready:    make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	done := func(sector abi.SectorNumber, err error) {
+		if err == nil {
+			p.record(ctx, sector, piece)
+		}
+		req.finish()
+	}
+
+	p.lk.Lock()
+	p.pending = append(p.pending, req)
+	if len(p.pending) == 1 {
+		// This is the first piece in a new batch
+		time.AfterFunc(p.cfg.BatchWindow, p.releaseBatch)
+	}
+	p.lk.Unlock()
+
+	select {
+	case <-req.ready:
+		return done, nil
+	case <-ctx.Done():
+	}
+
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	if req.released {
+		// The piece's turn came at the same time as the context was
+		// cancelled, so let the next piece go
+		req.finish()
+		return nil, ctx.Err()
+	}
+	for i, r := range p.pending {
+		if r == req {
+			p.pending = append(p.pending[:i], p.pending[i+1:]...)
+			break
+		}
+	}
+	return nil, ctx.Err()
+}
+
+// releaseBatch plans the pending pieces and hands them to the sealing
+// subsystem one by one in the planned order
+func (p *Packer) releaseBatch() {
+	p.seqLk.Lock()
+	defer p.seqLk.Unlock()
+
+	p.lk.Lock()
+	batch := p.pending
+	p.pending = nil
+	p.lk.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	ordered := p.plan(batch)
+	for _, req := range ordered {
+		p.lk.Lock()
+		req.released = true
+		p.lk.Unlock()
+
+		close(req.ready)
+		<-req.finished
+	}
+}
+
+func (p *Packer) plan(batch []*request) []*request {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	ssize, err := p.getSectorSize(ctx)
+	if err != nil {
+		log.Warnw("failed to get sector size, adding deals in the order they arrived", "err", err)
+		return batch
+	}
+
+	byDeal := make(map[uuid.UUID]*request, len(batch))
+	pieces := make([]Piece, 0, len(batch))
+	for _, req := range batch {
+		byDeal[req.piece.DealUuid] = req
+		pieces = append(pieces, req.piece)
+	}
+
+	bins := Plan(pieces, ssize, p.cfg.EndEpochTolerance)
+	ordered := make([]*request, 0, len(batch))
+	var used abi.PaddedPieceSize
+	for _, b := range bins {
+		used += b.Used
+		for _, pc := range b.Pieces {
+			ordered = append(ordered, byDeal[pc.DealUuid])
+		}
+	}
+
+	plan := &BatchPlan{
+		Time:               time.Now(),
+		Deals:              len(batch),
+		Sectors:            len(bins),
+		PlannedUtilization: float64(used) / float64(uint64(len(bins))*uint64(ssize)),
+	}
+	log.Infow("packed deals into sectors", "deals", plan.Deals, "sectors", plan.Sectors,
+		"utilization", fmt.Sprintf("%.1f%%", plan.PlannedUtilization*100))
+
+	p.lk.Lock()
+	p.lastBatch = plan
+	p.lk.Unlock()
+
+	return ordered
+}
+
+func (p *Packer) getSectorSize(ctx context.Context) (abi.SectorSize, error) {
+	p.lk.Lock()
+	ssize := p.ssize
+	p.lk.Unlock()
+	if ssize != 0 {
+		return ssize, nil
+	}
+
+	ssize, err := p.sectorSize(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	p.lk.Lock()
+	p.ssize = ssize
+	p.lk.Unlock()
+	return ssize, nil
+}
+
+// record adds the piece to the utilization of the sector it was added to
+func (p *Packer) record(ctx context.Context, sector abi.SectorNumber, piece Piece) {
+	ssize, err := p.getSectorSize(ctx)
+	if err != nil {
+		log.Warnw("failed to get sector size to record sector utilization", "err", err)
+	}
+
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	su, ok := p.sectors[sector]
+	if !ok {
+		su = &SectorUtilization{SectorID: sector}
+		p.sectors[sector] = su
+		p.sectorList = append(p.sectorList, sector)
+		if len(p.sectorList) > maxReportedSectors {
+			delete(p.sectors, p.sectorList[0])
+			p.sectorList = p.sectorList[1:]
+		}
+	}
+	su.Deals++
+	su.Used += piece.Size
+	if ssize != 0 {
+		su.Utilization = float64(su.Used) / float64(ssize)
+	}
+}
+
+// Report returns the sector utilization achieved for the sectors that deals
+// were most recently added to
+func (p *Packer) Report() *Report {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	r := &Report{
+		Policy:     p.cfg.Policy,
+		SectorSize: p.ssize,
+		Sectors:    make([]*SectorUtilization, 0, len(p.sectorList)),
+	}
+	if p.lastBatch != nil {
+		lb := *p.lastBatch
+		r.LastBatch = &lb
+	}
+
+	var total float64
+	for _, sector := range p.sectorList {
+		su := *p.sectors[sector]
+		r.Sectors = append(r.Sectors, &su)
+		total += su.Utilization
+	}
+	if len(r.Sectors) > 0 {
+		r.AverageUtilization = total / float64(len(r.Sectors))
+	}
+	return r
+}
@@ -0,0 +1,129 @@
+package sectorpacking
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlan(t *testing.T) {
+	piece := func(size abi.PaddedPieceSize, end abi.ChainEpoch) Piece {
+		return Piece{DealUuid: uuid.New(), Size: size, EndEpoch: end}
+	}
+
+	t.Run("largest first", func(t *testing.T) {
+		pieces := []Piece{piece(2, 100), piece(8, 100), piece(4, 100), piece(2, 100)}
+		bins := Plan(pieces, 16, 0)
+		require.Len(t, bins, 1)
+		require.EqualValues(t, 16, bins[0].Used)
+		require.Equal(t, []abi.PaddedPieceSize{8, 4, 2, 2}, sizes(bins[0]))
+	})
+
+	t.Run("first fit", func(t *testing.T) {
+		pieces := []Piece{piece(8, 100), piece(8, 100), piece(8, 100), piece(4, 100), piece(4, 100)}
+		bins := Plan(pieces, 16, 0)
+		require.Len(t, bins, 2)
+		require.Equal(t, []abi.PaddedPieceSize{8, 8}, sizes(bins[0]))
+		require.Equal(t, []abi.PaddedPieceSize{8, 4, 4}, sizes(bins[1]))
+	})
+
+	t.Run("group by end epoch", func(t *testing.T) {
+		pieces := []Piece{piece(4, 1000), piece(4, 100), piece(4, 1010), piece(4, 110)}
+		bins := Plan(pieces, 16, 20)
+		require.Len(t, bins, 2)
+		require.Equal(t, []abi.ChainEpoch{100, 110}, endEpochs(bins[0]))
+		require.Equal(t, []abi.ChainEpoch{1000, 1010}, endEpochs(bins[1]))
+	})
+}
+
+func TestPacker(t *testing.T) {
+	ctx := context.Background()
+	sectorSize := func(context.Context) (abi.SectorSize, error) { return 16, nil }
+
+	t.Run("bin-pack releases pieces in planned order", func(t *testing.T) {
+		p, err := New(Config{Policy: PolicyBinPack, BatchWindow: 50 * time.Millisecond}, sectorSize)
+		require.NoError(t, err)
+
+		var lk sync.Mutex
+		var order []abi.PaddedPieceSize
+		var wg sync.WaitGroup
+		for _, size := range []abi.PaddedPieceSize{2, 8, 4} {
+			size := size
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				done, err := p.Acquire(ctx, Piece{DealUuid: uuid.New(), Size: size, EndEpoch: 100})
+				require.NoError(t, err)
+				lk.Lock()
+				order = append(order, size)
+				lk.Unlock()
+				done(1, nil)
+			}()
+		}
+		wg.Wait()
+
+		require.Equal(t, []abi.PaddedPieceSize{8, 4, 2}, order)
+
+		r := p.Report()
+		require.Len(t, r.Sectors, 1)
+		require.EqualValues(t, 3, r.Sectors[0].Deals)
+		require.EqualValues(t, 14, r.Sectors[0].Used)
+		require.InDelta(t, 14.0/16, r.AverageUtilization, 0.001)
+		require.NotNil(t, r.LastBatch)
+		require.Equal(t, 3, r.LastBatch.Deals)
+		require.Equal(t, 1, r.LastBatch.Sectors)
+	})
+
+	t.Run("cancelled piece is removed from the batch", func(t *testing.T) {
+		p, err := New(Config{Policy: PolicyBinPack, BatchWindow: time.Hour}, sectorSize)
+		require.NoError(t, err)
+
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err = p.Acquire(cctx, Piece{DealUuid: uuid.New(), Size: 4})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Empty(t, p.pending)
+	})
+
+	t.Run("arrival", func(t *testing.T) {
+		p, err := New(Config{}, sectorSize)
+		require.NoError(t, err)
+
+		done, err := p.Acquire(ctx, Piece{DealUuid: uuid.New(), Size: 8})
+		require.NoError(t, err)
+		done(3, nil)
+
+		r := p.Report()
+		require.Equal(t, PolicyArrival, r.Policy)
+		require.Len(t, r.Sectors, 1)
+		require.EqualValues(t, 3, r.Sectors[0].SectorID)
+		require.InDelta(t, 0.5, r.Sectors[0].Utilization, 0.001)
+		require.Nil(t, r.LastBatch)
+	})
+
+	t.Run("unknown policy", func(t *testing.T) {
+		_, err := New(Config{Policy: "random"}, sectorSize)
+		require.Error(t, err)
+	})
+}
+
+func sizes(b *Bin) []abi.PaddedPieceSize {
+	var s []abi.PaddedPieceSize
+	for _, p := range b.Pieces {
+		s = append(s, p.Size)
+	}
+	return s
+}
+
+func endEpochs(b *Bin) []abi.ChainEpoch {
+	var e []abi.ChainEpoch
+	for _, p := range b.Pieces {
+		e = append(e, p.EndEpoch)
+	}
+	return e
+}
@@ -0,0 +1,194 @@
+package sectorselection
+
+import (
+	"fmt"
+
+	"github.com/filecoin-project/boost/storagemarket/dealrule"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+	"github.com/filecoin-project/lotus/api"
+)
+
+// The preferences for the kind of sector that a deal is added to
+const (
+	// Let the sealing subsystem choose the sector
+	PreferAny = "any"
+	// Never mark CC sectors for upgrade. This is advisory: boost can't stop
+	// the sealing subsystem from adding a deal to a CC sector that is
+	// already available for deals (to prevent that, set
+	// PreferNewSectorsForDeals in the sealing config)
+	PreferNoSnap = "no-snap"
+	// The old name for PreferNoSnap
+	preferNewDeprecated = "new"
+	// Add deals to committed capacity (CC) sectors with SnapDeals, when
+	// there is a CC sector that lives long enough for the deal
+	PreferSnap = "snap"
+	// Choose for each deal, based on the deal's piece size and how closely
+	// the remaining lifetime of a CC sector matches the deal's duration
+	PreferAuto = "auto"
+)
+
+// The kinds of sector that a deal can be added to
+const (
+	PathAny = "any"
+	// Don't mark a CC sector for upgrade for the deal, so that the deal is
+	// added to a new sector (or a sector that's already available for deals)
+	PathNew  = "new"
+	PathSnap = "snap"
+)
+
+// The states of CC sectors that are already available to receive deals.
+// Sectors in any other state (ie Proving) must be marked for upgrade first.
+var availableStates = map[api.SectorState]struct{}{
+	"SnapDealsWaitDeals": {},
+	"Available":          {},
+}
+
+// IsAvailable indicates whether the sector can receive deals without being
+// marked for upgrade
+func (c CCSector) IsAvailable() bool {
+	_, ok := availableStates[c.State]
+	return ok
+}
+
+type Config struct {
+	// PreferAny, PreferNoSnap, PreferSnap or PreferAuto
+	Preference string
+	// With PreferAuto, only deals with a piece size of at most this size are
+	// added to CC sectors (0 for no limit)
+	MaxSnapPieceSize abi.PaddedPieceSize
+	// A CC sector must expire at least this many epochs after the deal ends
+	MinLifetimeMargin abi.ChainEpoch
+	// With PreferAuto, a CC sector must expire at most this many epochs after
+	// the deal ends, so that a sector isn't kept for much longer than the
+	// deal (0 for no limit)
+	MaxDurationMismatch abi.ChainEpoch
+}
+
+// CCSector is a sector that can receive deals with SnapDeals
+type CCSector struct {
+	SectorID   abi.SectorNumber
+	State      api.SectorState
+	Expiration abi.ChainEpoch
+}
+
+// Decision is the result of applying the policy to a deal
+type Decision struct {
+	// PathAny, PathNew or PathSnap
+	Path string
+	// The CC sector to add the deal to (only for PathSnap)
+	Sector *CCSector
+	// Describes why the path was chosen
+	Reason string
+}
+
+func (d Decision) String() string {
+	return fmt.Sprintf("%s (%s)", d.Path, d.Reason)
+}
+
+// Policy chooses whether each deal is added to a new sector or to a CC
+// sector with SnapDeals
+type Policy struct {
+	cfg Config
+}
+
+func New(cfg Config) (*Policy, error) {
+	switch cfg.Preference {
+	case "":
+		cfg.Preference = PreferAny
+	case preferNewDeprecated:
+		cfg.Preference = PreferNoSnap
+	}
+	if err := dealrule.ValidateChoice("sector preference", cfg.Preference, PreferAny, PreferNoSnap, PreferSnap, PreferAuto); err != nil {
+		return nil, err
+	}
+	return &Policy{cfg: cfg}, nil
+}
+
+// Enabled indicates whether the policy makes a choice, or leaves it to the
+// sealing subsystem
+func (p *Policy) Enabled() bool {
+	return p.cfg.Preference != PreferAny
+}
+
+// Decide chooses the kind of sector to add the deal to, given the CC sectors
+// that can receive deals.
+// CC sectors that are already available for deals are preferred. A CC
+// sector in the Proving state is only chosen (to be marked for upgrade) when
+// there are no available CC sectors, so that at most one sector is marked
+// for upgrade at a time.
+func (p *Policy) Decide(prop market.DealProposal, candidates []CCSector) Decision {
+	switch p.cfg.Preference {
+	case PreferAny:
+		return Decision{Path: PathAny, Reason: "sealing subsystem chooses the sector"}
+	case PreferNoSnap:
+		return Decision{Path: PathNew, Reason: "policy prefers not to upgrade CC sectors"}
+	}
+
+	auto := p.cfg.Preference == PreferAuto
+	if auto && p.cfg.MaxSnapPieceSize != 0 && prop.PieceSize > p.cfg.MaxSnapPieceSize {
+		return Decision{
+			Path:   PathNew,
+			Reason: fmt.Sprintf("piece size %d is larger than the maximum snap deals piece size %d", prop.PieceSize, p.cfg.MaxSnapPieceSize),
+		}
+	}
+
+	var available, proving []CCSector
+	for _, c := range candidates {
+		if c.IsAvailable() {
+			available = append(available, c)
+		} else {
+			proving = append(proving, c)
+		}
+	}
+
+	best := p.closest(prop, available)
+	if best == nil && len(available) > 0 {
+		return Decision{
+			Path: PathNew,
+			Reason: fmt.Sprintf("CC sector %d is already available for deals but doesn't expire at least %d epochs after the deal end epoch %d",
+				available[0].SectorID, p.cfg.MinLifetimeMargin, prop.EndEpoch),
+		}
+	}
+	if best == nil {
+		best = p.closest(prop, proving)
+	}
+
+	if best == nil {
+		return Decision{
+			Path:   PathNew,
+			Reason: fmt.Sprintf("no CC sector expires at least %d epochs after the deal end epoch %d", p.cfg.MinLifetimeMargin, prop.EndEpoch),
+		}
+	}
+
+	mismatch := best.Expiration - prop.EndEpoch
+	if auto && p.cfg.MaxDurationMismatch != 0 && mismatch > p.cfg.MaxDurationMismatch {
+		return Decision{
+			Path: PathNew,
+			Reason: fmt.Sprintf("the closest CC sector %d expires %d epochs after the deal ends, more than the maximum of %d",
+				best.SectorID, mismatch, p.cfg.MaxDurationMismatch),
+		}
+	}
+
+	return Decision{
+		Path:   PathSnap,
+		Sector: best,
+		Reason: fmt.Sprintf("CC sector %d expires %d epochs after the deal ends", best.SectorID, mismatch),
+	}
+}
+
+// closest returns the CC sector that expires soonest after the deal ends, so
+// that the sector's remaining lifetime is the closest match to the deal
+func (p *Policy) closest(prop market.DealProposal, candidates []CCSector) *CCSector {
+	var best *CCSector
+	for i := range candidates {
+		c := &candidates[i]
+		if c.Expiration < prop.EndEpoch+p.cfg.MinLifetimeMargin {
+			continue
+		}
+		if best == nil || c.Expiration < best.Expiration {
+			best = c
+		}
+	}
+	return best
+}
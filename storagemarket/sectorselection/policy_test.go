@@ -0,0 +1,103 @@
+package sectorselection
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy(t *testing.T) {
+	prop := market.DealProposal{PieceSize: 8 << 30, EndEpoch: 1000}
+	candidates := []CCSector{
+		{SectorID: 1, State: "Proving", Expiration: 900},
+		{SectorID: 2, State: "Proving", Expiration: 5000},
+		{SectorID: 3, State: "Available", Expiration: 1200},
+	}
+
+	t.Run("any", func(t *testing.T) {
+		p, err := New(Config{})
+		require.NoError(t, err)
+		require.False(t, p.Enabled())
+		require.Equal(t, PathAny, p.Decide(prop, candidates).Path)
+	})
+
+	t.Run("no snap", func(t *testing.T) {
+		for _, pref := range []string{PreferNoSnap, "new"} {
+			p, err := New(Config{Preference: pref})
+			require.NoError(t, err)
+			require.True(t, p.Enabled())
+			require.Equal(t, PathNew, p.Decide(prop, candidates).Path)
+		}
+	})
+
+	t.Run("snap chooses the closest expiration", func(t *testing.T) {
+		p, err := New(Config{Preference: PreferSnap})
+		require.NoError(t, err)
+		d := p.Decide(prop, candidates)
+		require.Equal(t, PathSnap, d.Path)
+		require.EqualValues(t, 3, d.Sector.SectorID)
+	})
+
+	t.Run("snap prefers sectors that are already available", func(t *testing.T) {
+		p, err := New(Config{Preference: PreferSnap})
+		require.NoError(t, err)
+		available := []CCSector{
+			{SectorID: 1, State: "Proving", Expiration: 1100},
+			{SectorID: 2, State: "SnapDealsWaitDeals", Expiration: 5000},
+		}
+		d := p.Decide(prop, available)
+		require.Equal(t, PathSnap, d.Path)
+		require.EqualValues(t, 2, d.Sector.SectorID)
+	})
+
+	t.Run("snap marks at most one sector for upgrade", func(t *testing.T) {
+		// Sector 3 is already available for deals but isn't suitable, so
+		// sector 2 is not marked for upgrade
+		p, err := New(Config{Preference: PreferSnap, MinLifetimeMargin: 500})
+		require.NoError(t, err)
+		require.Equal(t, PathNew, p.Decide(prop, candidates).Path)
+
+		// When there are no available sectors, a proving sector is chosen
+		d := p.Decide(prop, candidates[:2])
+		require.Equal(t, PathSnap, d.Path)
+		require.EqualValues(t, 2, d.Sector.SectorID)
+		require.False(t, d.Sector.IsAvailable())
+	})
+
+	t.Run("snap respects the lifetime margin", func(t *testing.T) {
+		p, err := New(Config{Preference: PreferSnap, MinLifetimeMargin: 500})
+		require.NoError(t, err)
+		d := p.Decide(prop, candidates[:2])
+		require.Equal(t, PathSnap, d.Path)
+		require.EqualValues(t, 2, d.Sector.SectorID)
+
+		p, err = New(Config{Preference: PreferSnap, MinLifetimeMargin: 10000})
+		require.NoError(t, err)
+		require.Equal(t, PathNew, p.Decide(prop, candidates).Path)
+	})
+
+	t.Run("auto", func(t *testing.T) {
+		p, err := New(Config{Preference: PreferAuto, MaxSnapPieceSize: 16 << 30, MaxDurationMismatch: 100})
+		require.NoError(t, err)
+
+		// The closest CC sector expires 200 epochs after the deal
+		require.Equal(t, PathNew, p.Decide(prop, candidates).Path)
+
+		// The deal duration matches the CC sector
+		matching := market.DealProposal{PieceSize: 8 << 30, EndEpoch: 1150}
+		d := p.Decide(matching, candidates)
+		require.Equal(t, PathSnap, d.Path)
+		require.EqualValues(t, 3, d.Sector.SectorID)
+
+		// The piece is too large
+		large := market.DealProposal{PieceSize: abi.PaddedPieceSize(32 << 30), EndEpoch: 1150}
+		require.Equal(t, PathNew, p.Decide(large, candidates).Path)
+	})
+
+	t.Run("unknown preference", func(t *testing.T) {
+		_, err := New(Config{Preference: "random"})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,106 @@
+package storagemarket
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// HostTransferTelemetry is the transfer telemetry for a remote host, since
+// boost started
+type HostTransferTelemetry struct {
+	Host string
+	// The number of transfers from the host that completed successfully
+	Completed int
+	// The number of transfers from the host that failed
+	Failed int
+	// The total number of bytes received from the host
+	BytesReceived uint64
+	// The average throughput of the completed transfers from the host, in
+	// bytes per second
+	Throughput float64
+	// The number of times a transfer from the host stalled
+	Stalls int
+	// The number of times a transfer from the host was retried after a
+	// connection error
+	Retries int
+}
+
+type hostTelemetry struct {
+	HostTransferTelemetry
+	completedBytes    uint64
+	completedDuration time.Duration
+}
+
+// transferTelemetry keeps the transfer telemetry for each remote host, so
+// that consistently slow hosts can be identified
+type transferTelemetry struct {
+	lk    sync.Mutex
+	hosts map[string]*hostTelemetry
+}
+
+func newTransferTelemetry() *transferTelemetry {
+	return &transferTelemetry{hosts: make(map[string]*hostTelemetry)}
+}
+
+// host must be called with the lock held
+func (t *transferTelemetry) host(host string) *hostTelemetry {
+	ht, ok := t.hosts[host]
+	if !ok {
+		ht = &hostTelemetry{HostTransferTelemetry: HostTransferTelemetry{Host: host}}
+		t.hosts[host] = ht
+	}
+	return ht
+}
+
+func (t *transferTelemetry) addBytes(host string, bytes uint64) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	t.host(host).BytesReceived += bytes
+}
+
+func (t *transferTelemetry) addStall(host string) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	t.host(host).Stalls++
+}
+
+func (t *transferTelemetry) addRetries(host string, retries int) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	t.host(host).Retries += retries
+}
+
+func (t *transferTelemetry) completed(host string, bytes uint64, duration time.Duration) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	ht := t.host(host)
+	ht.Completed++
+	ht.completedBytes += bytes
+	ht.completedDuration += duration
+}
+
+func (t *transferTelemetry) failed(host string) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	t.host(host).Failed++
+}
+
+// list returns the telemetry for each host, sorted by host
+func (t *transferTelemetry) list() []HostTransferTelemetry {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	hosts := make([]HostTransferTelemetry, 0, len(t.hosts))
+	for _, ht := range t.hosts {
+		h := ht.HostTransferTelemetry
+		if secs := ht.completedDuration.Seconds(); secs > 0 {
+			h.Throughput = float64(ht.completedBytes) / secs
+		}
+		hosts = append(hosts, h)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].Host < hosts[j].Host
+	})
+	return hosts
+}
@@ -0,0 +1,43 @@
+package storagemarket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferTelemetry(t *testing.T) {
+	tt := newTransferTelemetry()
+	require.Empty(t, tt.list())
+
+	tt.addBytes("b.example.com", 1000)
+	tt.addRetries("b.example.com", 2)
+	tt.addStall("b.example.com")
+	tt.completed("b.example.com", 1000, 10*time.Second)
+
+	tt.addBytes("a.example.com", 500)
+	tt.addBytes("a.example.com", 1500)
+	tt.completed("a.example.com", 2000, time.Second)
+	tt.addBytes("a.example.com", 100)
+	tt.failed("a.example.com")
+
+	hosts := tt.list()
+	require.Len(t, hosts, 2)
+
+	a := hosts[0]
+	require.Equal(t, "a.example.com", a.Host)
+	require.Equal(t, 1, a.Completed)
+	require.Equal(t, 1, a.Failed)
+	require.EqualValues(t, 2100, a.BytesReceived)
+	// Only completed transfers count towards throughput
+	require.InDelta(t, 2000, a.Throughput, 0.01)
+	require.Zero(t, a.Stalls)
+
+	b := hosts[1]
+	require.Equal(t, "b.example.com", b.Host)
+	require.Equal(t, 1, b.Completed)
+	require.InDelta(t, 100, b.Throughput, 0.01)
+	require.Equal(t, 1, b.Stalls)
+	require.Equal(t, 2, b.Retries)
+}
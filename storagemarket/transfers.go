@@ -196,3 +196,9 @@ func (p *Provider) IsTransferStalled(dealUuid uuid.UUID) bool {
 func (p *Provider) TransferStats() []*HostTransferStats {
 	return p.xferLimiter.stats()
 }
+
+// TransferTelemetry returns the throughput, stalls and retries of the
+// transfers from each remote host since boost started
+func (p *Provider) TransferTelemetry() []HostTransferTelemetry {
+	return p.xferTelemetry.list()
+}
@@ -64,6 +64,16 @@ type ProviderDealState struct {
 
 	//Announce deal to the IPNI(Index Provider)
 	AnnounceToIPNI bool
+	// Wait until the sector has been sealed before announcing the deal
+	AnnounceAfterSealing bool
+	// The announce policy rule that decided whether and when to announce
+	// the deal
+	AnnounceRule string
+
+	// The wallet that funds the provider collateral for the deal
+	CollateralWallet string
+	// The deal collateral rule that chose the collateral wallet
+	CollateralRule string
 }
 
 func (d *ProviderDealState) String() string {
@@ -223,6 +223,20 @@ func (mr *MockIndexProviderMockRecorder) Enabled() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enabled", reflect.TypeOf((*MockIndexProvider)(nil).Enabled))
 }
 
+// QueueAnnounceBoostDeal mocks base method.
+func (m *MockIndexProvider) QueueAnnounceBoostDeal(arg0 context.Context, arg1 *types.ProviderDealState, arg2 error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueueAnnounceBoostDeal", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// QueueAnnounceBoostDeal indicates an expected call of QueueAnnounceBoostDeal.
+func (mr *MockIndexProviderMockRecorder) QueueAnnounceBoostDeal(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueueAnnounceBoostDeal", reflect.TypeOf((*MockIndexProvider)(nil).QueueAnnounceBoostDeal), arg0, arg1, arg2)
+}
+
 // Start mocks base method.
 func (m *MockIndexProvider) Start(arg0 context.Context) {
 	m.ctrl.T.Helper()
@@ -145,6 +145,32 @@ type PieceAdder interface {
 	AddPiece(ctx context.Context, size abi.UnpaddedPieceSize, r io.Reader, d api.PieceDealInfo) (abi.SectorNumber, abi.PaddedPieceSize, error)
 }
 
+// SealerAdapter is the interface to the sealing stack that deal data is handed
+// off to. It adds deal data to sectors and reports the sealing state of
+// those sectors (see the sealeradapter package for the implementations).
+type SealerAdapter interface {
+	PieceAdder
+	// SectorsStatus returns the sealing state of the sector. Sector states
+	// are reported with the lotus sector state names.
+	SectorsStatus(ctx context.Context, sid abi.SectorNumber, showOnChainInfo bool) (api.SectorInfo, error)
+}
+
+// PieceCopyStore keeps a copy of a deal's data after the deal has been
+// handed off to the sealer, so that it can be retrieved without unsealing
+type PieceCopyStore interface {
+	Retain(pieceCid cid.Cid, carPath string) error
+}
+
+// ExistingPieceCopier copies the data of a piece that is already on the
+// node, so that a deal for another replica of the piece doesn't need to
+// transfer the data again
+type ExistingPieceCopier interface {
+	// CopyTo replaces the file at dstPath with the first size bytes of the
+	// piece data. It returns false if there is no copy of the piece data
+	// that can be read without unsealing.
+	CopyTo(ctx context.Context, pieceCid cid.Cid, size uint64, dstPath string) (bool, error)
+}
+
 type CommpCalculator interface {
 	ComputeDataCid(ctx context.Context, pieceSize abi.UnpaddedPieceSize, pieceData storage.Data) (abi.PieceInfo, error)
 }
@@ -160,6 +186,9 @@ type ChainDealManager interface {
 type IndexProvider interface {
 	Enabled() bool
 	AnnounceBoostDeal(ctx context.Context, pds *ProviderDealState) (cid.Cid, error)
+	// QueueAnnounceBoostDeal persists a failed announcement so that it is
+	// retried until the indexer accepts it
+	QueueAnnounceBoostDeal(ctx context.Context, pds *ProviderDealState, announceErr error) error
 	Start(ctx context.Context)
 }
 
@@ -0,0 +1,64 @@
+package unsealpolicy
+
+import (
+	"fmt"
+
+	"github.com/filecoin-project/boost/storagemarket/dealrule"
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+)
+
+// The policies for keeping an unsealed copy of a deal's data
+const (
+	// Keep an unsealed copy unless the client asked for it to be removed
+	// (with the RemoveUnsealedCopy deal parameter)
+	PolicyClient = "client"
+	// Always keep an unsealed copy for verified deals, and honour the
+	// client's request for other deals
+	PolicyVerified = "verified"
+	// Always keep an unsealed copy
+	PolicyAlways = "always"
+)
+
+// Decision is the result of applying the policy to a deal
+type Decision struct {
+	// Whether to keep an unsealed copy of the deal's data
+	Keep bool
+	// Describes what decided whether to keep the unsealed copy
+	Reason string
+}
+
+func (d Decision) String() string {
+	if d.Keep {
+		return fmt.Sprintf("keep (%s)", d.Reason)
+	}
+	return fmt.Sprintf("remove (%s)", d.Reason)
+}
+
+// Policy decides whether to keep an unsealed copy of each deal's data
+type Policy struct {
+	policy string
+}
+
+func New(policy string) (*Policy, error) {
+	if policy == "" {
+		policy = PolicyClient
+	}
+	if err := dealrule.ValidateChoice("unsealed copy policy", policy, PolicyClient, PolicyVerified, PolicyAlways); err != nil {
+		return nil, err
+	}
+	return &Policy{policy: policy}, nil
+}
+
+// Decide applies the policy to a deal proposal. removeRequested is true if
+// the client asked for the unsealed copy to be removed.
+func (p *Policy) Decide(prop market.DealProposal, removeRequested bool) Decision {
+	switch {
+	case !removeRequested:
+		return Decision{Keep: true, Reason: "requested by client"}
+	case p.policy == PolicyAlways:
+		return Decision{Keep: true, Reason: "policy keeps all unsealed copies"}
+	case p.policy == PolicyVerified && prop.VerifiedDeal:
+		return Decision{Keep: true, Reason: "policy keeps unsealed copies of verified deals"}
+	}
+	return Decision{Keep: false, Reason: "requested by client"}
+}
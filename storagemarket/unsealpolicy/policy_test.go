@@ -0,0 +1,38 @@
+package unsealpolicy
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/builtin/v9/market"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy(t *testing.T) {
+	verified := market.DealProposal{VerifiedDeal: true}
+	unverified := market.DealProposal{}
+
+	testCases := []struct {
+		policy          string
+		prop            market.DealProposal
+		removeRequested bool
+		keep            bool
+	}{
+		{policy: "", prop: unverified, removeRequested: false, keep: true},
+		{policy: "", prop: verified, removeRequested: true, keep: false},
+		{policy: PolicyClient, prop: verified, removeRequested: true, keep: false},
+		{policy: PolicyVerified, prop: verified, removeRequested: true, keep: true},
+		{policy: PolicyVerified, prop: unverified, removeRequested: true, keep: false},
+		{policy: PolicyVerified, prop: unverified, removeRequested: false, keep: true},
+		{policy: PolicyAlways, prop: unverified, removeRequested: true, keep: true},
+	}
+
+	for _, tc := range testCases {
+		p, err := New(tc.policy)
+		require.NoError(t, err)
+		d := p.Decide(tc.prop, tc.removeRequested)
+		require.Equal(t, tc.keep, d.Keep, "policy %q verified %t remove %t", tc.policy, tc.prop.VerifiedDeal, tc.removeRequested)
+	}
+
+	_, err := New("never")
+	require.Error(t, err)
+}
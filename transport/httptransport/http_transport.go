@@ -38,6 +38,7 @@ type httpError struct {
 }
 
 var _ transport.Transport = (*httpTransport)(nil)
+var _ transport.StreamingTransport = (*httpTransport)(nil)
 
 type Option func(*httpTransport)
 
@@ -148,30 +149,9 @@ func (h *httpTransport) Execute(ctx context.Context, transportInfo []byte, dealI
 		}
 	}
 
-	// If this is a libp2p URL
-	if u.Scheme == util.Libp2pScheme {
-		h.dl.Infow(duuid, "libp2p-http url", "url", tInfo.URL, "peer id", u.PeerID, "multiaddr", u.Multiaddr)
-
-		// Use the libp2p client
-		t.client = h.libp2pClient
-
-		// Add the peer's address to the peerstore so we can dial it
-		addrTtl := time.Hour
-		if deadline, ok := ctx.Deadline(); ok {
-			addrTtl = time.Until(deadline)
-		}
-		h.libp2pHost.Peerstore().AddAddr(u.PeerID, u.Multiaddr, addrTtl)
-
-		// Protect the connection for the lifetime of the data transfer
-		tag := uuid.New().String()
-		h.libp2pHost.ConnManager().Protect(u.PeerID, tag)
-		cleanupFns = append(cleanupFns, func() {
-			h.libp2pHost.ConnManager().Unprotect(u.PeerID, tag)
-		})
-	} else {
-		t.client = http.DefaultClient
-		h.dl.Infow(duuid, "http url", "url", tInfo.URL)
-	}
+	client, closeClient := h.client(ctx, duuid, u)
+	t.client = client
+	cleanupFns = append(cleanupFns, closeClient)
 
 	// is the transfer already complete ? we check this by comparing the number of bytes
 	// in the output file with the deal size.
@@ -198,6 +178,112 @@ func (h *httpTransport) Execute(ctx context.Context, transportInfo []byte, dealI
 	return t, nil
 }
 
+// Stream requests the deal data and returns a reader over the response.
+// Unlike Execute, the data is not written to a file, and the request is not
+// retried if the connection fails, because the data that has already been
+// read can't be requested again.
+func (h *httpTransport) Stream(ctx context.Context, transportInfo []byte, dealInfo *types.TransportDealInfo) (io.ReadCloser, error) {
+	duuid := dealInfo.DealUuid
+	h.dl.Infow(duuid, "stream transfer", "deal size", dealInfo.DealSize)
+
+	// de-serialize transport opaque token
+	tInfo := &types.HttpRequest{}
+	if err := json.Unmarshal(transportInfo, tInfo); err != nil {
+		return nil, fmt.Errorf("failed to de-serialize transport info bytes, bytes:%s, err:%w", string(transportInfo), err)
+	}
+
+	if len(tInfo.URL) == 0 {
+		return nil, errors.New("deal url is empty")
+	}
+
+	// parse request URL
+	u, err := util.ParseUrl(tInfo.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse request url: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.Url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http req: %w", err)
+	}
+	for name, val := range tInfo.Headers {
+		req.Header.Set(name, val)
+	}
+
+	client, closeClient := h.client(ctx, duuid, u)
+	resp, err := client.Do(req)
+	if err != nil {
+		closeClient()
+		return nil, fmt.Errorf("failed to send http req: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		closeClient()
+		return nil, fmt.Errorf("http req failed: code: %d, status: %s", resp.StatusCode, resp.Status)
+	}
+
+	return &streamReader{
+		body:      resp.Body,
+		r:         io.LimitReader(resp.Body, dealInfo.DealSize),
+		remaining: dealInfo.DealSize,
+		close:     closeClient,
+	}, nil
+}
+
+// client returns the http client for the url, and a function that cleans up
+// the resources associated with the client once the transfer is done
+func (h *httpTransport) client(ctx context.Context, duuid uuid.UUID, u *util.TransportUrl) (*http.Client, func()) {
+	// If this is not a libp2p URL, use the default client
+	if u.Scheme != util.Libp2pScheme {
+		h.dl.Infow(duuid, "http url", "url", u.Url)
+		return http.DefaultClient, func() {}
+	}
+
+	h.dl.Infow(duuid, "libp2p-http url", "url", u.Url, "peer id", u.PeerID, "multiaddr", u.Multiaddr)
+
+	// Add the peer's address to the peerstore so we can dial it
+	addrTtl := time.Hour
+	if deadline, ok := ctx.Deadline(); ok {
+		addrTtl = time.Until(deadline)
+	}
+	h.libp2pHost.Peerstore().AddAddr(u.PeerID, u.Multiaddr, addrTtl)
+
+	// Protect the connection for the lifetime of the data transfer
+	tag := uuid.New().String()
+	h.libp2pHost.ConnManager().Protect(u.PeerID, tag)
+	return h.libp2pClient, func() {
+		h.libp2pHost.ConnManager().Unprotect(u.PeerID, tag)
+	}
+}
+
+// streamReader reads the deal data from an http response, and fails if the
+// response ends before the whole deal has been read
+type streamReader struct {
+	body      io.ReadCloser
+	r         io.Reader
+	remaining int64
+	close     func()
+	closeOnce sync.Once
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.remaining -= int64(n)
+	if err == io.EOF && s.remaining > 0 {
+		return n, fmt.Errorf("http response ended with %d bytes of the deal remaining: %w", s.remaining, io.ErrUnexpectedEOF)
+	}
+	return n, err
+}
+
+func (s *streamReader) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		err = s.body.Close()
+		s.close()
+	})
+	return err
+}
+
 type transfer struct {
 	closeOnce sync.Once
 	cancel    context.CancelFunc
@@ -210,6 +296,7 @@ type transfer struct {
 	wg       sync.WaitGroup
 
 	nBytesReceived int64
+	nRetries       int
 
 	backoff              *backoff.Backoff
 	maxReconnectAttempts float64
@@ -299,6 +386,7 @@ func (t *transfer) execute(ctx context.Context) error {
 		select {
 		case <-bt.C:
 			t.dl.Infow(duuid, "back-off complete, retrying http request", "backoff time", duration.String())
+			t.nRetries++
 		case <-ctx.Done():
 			t.dl.LogError(duuid, "did not retry http request: context cancelled", ctx.Err())
 			return fmt.Errorf("transfer canceled after %.0f attempts to finish transfer, lastErr=%s, contextErr=%w", t.backoff.Attempt(), err, ctx.Err())
@@ -370,7 +458,7 @@ func (t *transfer) doHttp(ctx context.Context, req *http.Request, dst io.Writer,
 			t.nBytesReceived = t.nBytesReceived + int64(nw)
 
 			// emit event updating the number of bytes received
-			t.emitEvent(types.TransportEvent{NBytesReceived: t.nBytesReceived})
+			t.emitEvent(types.TransportEvent{NBytesReceived: t.nBytesReceived, Retries: t.nRetries})
 		}
 		// the http stream we're reading from has sent us an EOF, nothing to do here.
 		if readErr == io.EOF {
@@ -110,6 +110,52 @@ func TestSimpleTransfer(t *testing.T) {
 	}
 }
 
+func TestStreamTransfer(t *testing.T) {
+	ctx := context.Background()
+	rawSize := (10 * readBufferSize) + 30
+	st := newServerTest(t, rawSize)
+	carSize := len(st.carBytes)
+	svcs := serversWithRangeHandler(st)
+
+	for name, init := range svcs {
+		t.Run(name, func(t *testing.T) {
+			reqFn, closer, h := init(t)
+			defer closer()
+
+			bz, err := json.Marshal(reqFn())
+			require.NoError(t, err)
+
+			ht := New(h, newDealLogger(t, ctx))
+			stream, err := ht.Stream(ctx, bz, &types.TransportDealInfo{DealSize: int64(carSize)})
+			require.NoError(t, err)
+			defer stream.Close() //nolint:errcheck
+
+			received, err := io.ReadAll(stream)
+			require.NoError(t, err)
+			require.Equal(t, st.carBytes, received)
+		})
+	}
+
+	t.Run("response shorter than deal", func(t *testing.T) {
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+			w.Write(st.carBytes[:carSize/2]) //nolint:errcheck
+		}))
+		defer svr.Close()
+
+		bz, err := json.Marshal(types.HttpRequest{URL: svr.URL})
+		require.NoError(t, err)
+
+		ht := New(nil, newDealLogger(t, ctx))
+		stream, err := ht.Stream(ctx, bz, &types.TransportDealInfo{DealSize: int64(carSize)})
+		require.NoError(t, err)
+		defer stream.Close() //nolint:errcheck
+
+		_, err = io.ReadAll(stream)
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+}
+
 func TestTransportRespectsContext(t *testing.T) {
 	t.Skip("hangs on the CI")
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
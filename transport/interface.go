@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 
 	smtypes "github.com/filecoin-project/boost/storagemarket/types"
 	"github.com/filecoin-project/boost/transport/types"
@@ -20,6 +21,13 @@ type Handler interface {
 	Close()
 }
 
+// StreamingTransport is implemented by transports that can deliver the deal
+// data sequentially as a stream, so that the data can be added to a sector
+// as it arrives instead of being staged in a file first
+type StreamingTransport interface {
+	Stream(ctx context.Context, transportInfo []byte, dealInfo *types.TransportDealInfo) (io.ReadCloser, error)
+}
+
 func TransferParamsAsJson(transfer smtypes.Transfer) (string, error) {
 	if transfer.Type != "http" && transfer.Type != "libp2p" {
 		return "", fmt.Errorf("cannot parse params for unrecognized transfer type '%s'", transfer.Type)
@@ -31,7 +31,10 @@ type TransportDealInfo struct {
 // TransportEvent is fired as a transfer progresses
 type TransportEvent struct {
 	NBytesReceived int64
-	Error          error
+	// The number of times the transfer has been retried after a connection
+	// error
+	Retries int
+	Error   error
 }
 
 // TransferStatus describes the status of a transfer (started, completed etc)
@@ -0,0 +1,289 @@
+package unsealedcopy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/filecoin-project/boost/api"
+	"github.com/filecoin-project/boost/markets/sectoraccessor"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/dagstore"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/storage/sealer/storiface"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("unsealedcopy")
+
+// MinerAPI is the subset of the miner API used to remove unsealed copies of
+// sectors
+type MinerAPI interface {
+	ActorAddress(context.Context) (address.Address, error)
+	SectorsStatus(ctx context.Context, sid abi.SectorNumber, showOnChainInfo bool) (lapi.SectorInfo, error)
+	StorageFindSector(ctx context.Context, sector abi.SectorID, ft storiface.SectorFileType, ssize abi.SectorSize, allowFetch bool) ([]storiface.SectorStorageInfo, error)
+	StorageDropSector(ctx context.Context, storageID storiface.ID, s abi.SectorID, ft storiface.SectorFileType) error
+}
+
+// dealsDB looks up the boost deals for a piece
+type dealsDB interface {
+	ByPieceCID(ctx context.Context, pieceCid cid.Cid) ([]*types.ProviderDealState, error)
+}
+
+// Manager reports which pieces have an unsealed copy, and unseals pieces or
+// removes the unsealed copy of pieces on request
+type Manager struct {
+	miner MinerAPI
+	ps    piecestore.PieceStore
+	sa    retrievalmarket.SectorAccessor
+	dagst dagstore.Interface
+	db    dealsDB
+
+	lk        sync.Mutex
+	unsealing map[cid.Cid]struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewManager(miner MinerAPI, ps piecestore.PieceStore, sa retrievalmarket.SectorAccessor, dagst dagstore.Interface, db dealsDB) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		miner:     miner,
+		ps:        ps,
+		sa:        sa,
+		dagst:     dagst,
+		db:        db,
+		unsealing: make(map[cid.Cid]struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Stop cancels any unseal operations that are in progress
+func (m *Manager) Stop() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// Status returns the unsealed status of each deal for the piece, and
+// whether the piece can be retrieved right now
+func (m *Manager) Status(ctx context.Context, pieceCid cid.Cid) (*api.PieceUnsealedStatus, error) {
+	pi, err := m.ps.GetPieceInfo(pieceCid)
+	if err != nil {
+		return nil, fmt.Errorf("getting piece info for piece %s: %w", pieceCid, err)
+	}
+
+	keep, err := m.keepUnsealed(ctx, pieceCid)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &api.PieceUnsealedStatus{
+		PieceCid:  pieceCid,
+		Indexed:   m.isIndexed(pieceCid),
+		Unsealing: m.isUnsealing(pieceCid),
+		Deals:     make([]api.PieceDealUnsealedStatus, 0, len(pi.Deals)),
+	}
+	for _, dl := range pi.Deals {
+		ds := api.PieceDealUnsealedStatus{
+			DealID:       dl.DealID,
+			SectorID:     dl.SectorID,
+			Offset:       dl.Offset,
+			Length:       dl.Length,
+			KeepUnsealed: keep[dl.DealID],
+		}
+		isUnsealed, err := m.sa.IsUnsealed(ctx, dl.SectorID, dl.Offset.Unpadded(), dl.Length.Unpadded())
+		if err != nil {
+			ds.Error = err.Error()
+		}
+		ds.IsUnsealed = isUnsealed
+		if isUnsealed {
+			st.HasUnsealedCopy = true
+		}
+		st.Deals = append(st.Deals, ds)
+	}
+	st.Retrievable = st.Indexed && st.HasUnsealedCopy
+
+	return st, nil
+}
+
+// Unseal starts unsealing the piece in the background, from the first
+// sector that contains the piece. The unseal goes through the sector
+// accessor's unseal queue at high priority, as a user is waiting for it.
+func (m *Manager) Unseal(ctx context.Context, pieceCid cid.Cid) error {
+	st, err := m.Status(ctx, pieceCid)
+	if err != nil {
+		return err
+	}
+	if st.HasUnsealedCopy {
+		return fmt.Errorf("piece %s already has an unsealed copy", pieceCid)
+	}
+	if len(st.Deals) == 0 {
+		return fmt.Errorf("piece %s is not in any sector", pieceCid)
+	}
+	dl := st.Deals[0]
+
+	m.lk.Lock()
+	if _, ok := m.unsealing[pieceCid]; ok {
+		m.lk.Unlock()
+		return fmt.Errorf("piece %s is already being unsealed", pieceCid)
+	}
+	m.unsealing[pieceCid] = struct{}{}
+	m.lk.Unlock()
+
+	log.Infow("unsealing piece", "pieceCid", pieceCid, "sector", dl.SectorID)
+
+	// Unsealing can take hours, so don't wait for it to complete
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer func() {
+			m.lk.Lock()
+			delete(m.unsealing, pieceCid)
+			m.lk.Unlock()
+		}()
+
+		// Reading the piece from the sector accessor unseals it
+		uctx := sectoraccessor.WithUnsealPriority(m.ctx, sectoraccessor.UnsealPriorityHigh)
+		r, err := m.sa.UnsealSector(uctx, dl.SectorID, dl.Offset.Unpadded(), dl.Length.Unpadded())
+		if err != nil {
+			log.Errorw("failed to unseal piece", "pieceCid", pieceCid, "sector", dl.SectorID, "err", err)
+			return
+		}
+		_ = r.Close()
+		log.Infow("unsealed piece", "pieceCid", pieceCid, "sector", dl.SectorID)
+	}()
+
+	return nil
+}
+
+// RemoveUnsealed removes the unsealed copy of each sector that contains the
+// piece, and returns the sectors that were removed.
+// Note that this removes the unsealed copy of all pieces in the sector, so
+// unless force is true a sector is not removed if any of its deals asked
+// for an unsealed copy to be kept.
+func (m *Manager) RemoveUnsealed(ctx context.Context, pieceCid cid.Cid, force bool) ([]abi.SectorNumber, error) {
+	st, err := m.Status(ctx, pieceCid)
+	if err != nil {
+		return nil, err
+	}
+	if m.isUnsealing(pieceCid) {
+		return nil, fmt.Errorf("piece %s is being unsealed", pieceCid)
+	}
+
+	var removed []abi.SectorNumber
+	seen := make(map[abi.SectorNumber]struct{})
+	for _, ds := range st.Deals {
+		if !ds.IsUnsealed {
+			continue
+		}
+		if _, ok := seen[ds.SectorID]; ok {
+			continue
+		}
+		seen[ds.SectorID] = struct{}{}
+
+		ref, si, err := m.sectorRef(ctx, ds.SectorID)
+		if err != nil {
+			return removed, err
+		}
+		if !force {
+			for _, p := range si.Pieces {
+				if p.DealInfo != nil && p.DealInfo.KeepUnsealed {
+					return removed, fmt.Errorf("deal %d in sector %d asked to keep an unsealed copy (use force to remove it anyway)",
+						p.DealInfo.DealID, ds.SectorID)
+				}
+			}
+		}
+
+		if err := m.dropUnsealed(ctx, ref.ID); err != nil {
+			return removed, fmt.Errorf("removing unsealed copy of sector %d: %w", ds.SectorID, err)
+		}
+		log.Infow("removed unsealed copy of sector", "pieceCid", pieceCid, "sector", ds.SectorID)
+		removed = append(removed, ds.SectorID)
+	}
+
+	if len(removed) == 0 {
+		return nil, fmt.Errorf("piece %s has no unsealed copy", pieceCid)
+	}
+	return removed, nil
+}
+
+func (m *Manager) dropUnsealed(ctx context.Context, sid abi.SectorID) error {
+	infos, err := m.miner.StorageFindSector(ctx, sid, storiface.FTUnsealed, 0, false)
+	if err != nil {
+		return fmt.Errorf("finding unsealed copy: %w", err)
+	}
+	for _, info := range infos {
+		if err := m.miner.StorageDropSector(ctx, info.ID, sid, storiface.FTUnsealed); err != nil {
+			return fmt.Errorf("dropping unsealed copy from storage %s: %w", info.ID, err)
+		}
+	}
+	return nil
+}
+
+func (m *Manager) sectorRef(ctx context.Context, sectorID abi.SectorNumber) (storiface.SectorRef, lapi.SectorInfo, error) {
+	maddr, err := m.miner.ActorAddress(ctx)
+	if err != nil {
+		return storiface.SectorRef{}, lapi.SectorInfo{}, fmt.Errorf("getting miner address: %w", err)
+	}
+	mid, err := address.IDFromAddress(maddr)
+	if err != nil {
+		return storiface.SectorRef{}, lapi.SectorInfo{}, fmt.Errorf("getting miner id: %w", err)
+	}
+
+	si, err := m.miner.SectorsStatus(ctx, sectorID, false)
+	if err != nil {
+		return storiface.SectorRef{}, lapi.SectorInfo{}, fmt.Errorf("getting status of sector %d: %w", sectorID, err)
+	}
+
+	ref := storiface.SectorRef{
+		ID:        abi.SectorID{Miner: abi.ActorID(mid), Number: sectorID},
+		ProofType: si.SealProof,
+	}
+	return ref, si, nil
+}
+
+// keepUnsealed returns whether each of the piece's boost deals asked to keep
+// an unsealed copy, by chain deal id
+func (m *Manager) keepUnsealed(ctx context.Context, pieceCid cid.Cid) (map[abi.DealID]bool, error) {
+	deals, err := m.db.ByPieceCID(ctx, pieceCid)
+	if err != nil {
+		return nil, fmt.Errorf("getting deals for piece %s: %w", pieceCid, err)
+	}
+	keep := make(map[abi.DealID]bool, len(deals))
+	for _, d := range deals {
+		if d.ChainDealID != 0 {
+			keep[d.ChainDealID] = d.FastRetrieval
+		}
+	}
+	return keep, nil
+}
+
+// isIndexed returns true if the piece's shard has been initialized and can
+// be served
+func (m *Manager) isIndexed(pieceCid cid.Cid) bool {
+	info, err := m.dagst.GetShardInfo(shard.KeyFromCID(pieceCid))
+	if err != nil {
+		if !errors.Is(err, dagstore.ErrShardUnknown) {
+			log.Warnw("getting shard info", "pieceCid", pieceCid, "err", err)
+		}
+		return false
+	}
+	return info.ShardState == dagstore.ShardStateAvailable || info.ShardState == dagstore.ShardStateServing
+}
+
+func (m *Manager) isUnsealing(pieceCid cid.Cid) bool {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	_, ok := m.unsealing[pieceCid]
+	return ok
+}
@@ -0,0 +1,214 @@
+package unsealedcopy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/markets/sectoraccessor"
+	"github.com/filecoin-project/boost/storagemarket/types"
+	"github.com/filecoin-project/dagstore"
+	"github.com/filecoin-project/dagstore/mount"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	lapi "github.com/filecoin-project/lotus/api"
+	mdagstore "github.com/filecoin-project/lotus/markets/dagstore"
+	"github.com/filecoin-project/lotus/storage/sealer/storiface"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+const testPieceCid = "baga6ea4seaqnfhocd544oidrgsss2ahoaomvxuaqxfmlsizljtzsuivjl5hamka"
+
+type mockDagstore struct {
+	dagstore.Interface
+	shards map[shard.Key]dagstore.ShardInfo
+}
+
+func (m *mockDagstore) GetShardInfo(k shard.Key) (dagstore.ShardInfo, error) {
+	si, ok := m.shards[k]
+	if !ok {
+		return dagstore.ShardInfo{}, dagstore.ErrShardUnknown
+	}
+	return si, nil
+}
+
+type mockPieceStore struct {
+	piecestore.PieceStore
+	pieces map[cid.Cid]piecestore.PieceInfo
+}
+
+func (m *mockPieceStore) GetPieceInfo(pieceCid cid.Cid) (piecestore.PieceInfo, error) {
+	pi, ok := m.pieces[pieceCid]
+	if !ok {
+		return piecestore.PieceInfo{}, retrievalmarket.ErrNotFound
+	}
+	return pi, nil
+}
+
+type mockSectorAccessor struct {
+	retrievalmarket.SectorAccessor
+	unsealed map[abi.SectorNumber]bool
+}
+
+func (m *mockSectorAccessor) IsUnsealed(ctx context.Context, sectorID abi.SectorNumber, offset abi.UnpaddedPieceSize, length abi.UnpaddedPieceSize) (bool, error) {
+	return m.unsealed[sectorID], nil
+}
+
+type mockDealsDB struct {
+	deals []*types.ProviderDealState
+}
+
+func (m *mockDealsDB) ByPieceCID(ctx context.Context, pieceCid cid.Cid) ([]*types.ProviderDealState, error) {
+	return m.deals, nil
+}
+
+type mockMiner struct {
+	sectors map[abi.SectorNumber]lapi.SectorInfo
+	dropped []abi.SectorID
+}
+
+func (m *mockMiner) ActorAddress(context.Context) (address.Address, error) {
+	return address.NewIDAddress(1000)
+}
+
+func (m *mockMiner) SectorsStatus(ctx context.Context, sid abi.SectorNumber, showOnChainInfo bool) (lapi.SectorInfo, error) {
+	return m.sectors[sid], nil
+}
+
+func (m *mockMiner) StorageFindSector(ctx context.Context, sector abi.SectorID, ft storiface.SectorFileType, ssize abi.SectorSize, allowFetch bool) ([]storiface.SectorStorageInfo, error) {
+	return []storiface.SectorStorageInfo{{ID: "storage"}}, nil
+}
+
+func (m *mockMiner) StorageDropSector(ctx context.Context, storageID storiface.ID, s abi.SectorID, ft storiface.SectorFileType) error {
+	m.dropped = append(m.dropped, s)
+	return nil
+}
+
+func TestUnsealedStatus(t *testing.T) {
+	ctx := context.Background()
+	pieceCid, err := cid.Parse(testPieceCid)
+	require.NoError(t, err)
+
+	ps := &mockPieceStore{pieces: map[cid.Cid]piecestore.PieceInfo{
+		pieceCid: {PieceCID: pieceCid, Deals: []piecestore.DealInfo{
+			{DealID: 10, SectorID: 1, Length: 128},
+			{DealID: 11, SectorID: 2, Length: 128},
+		}},
+	}}
+	sa := &mockSectorAccessor{unsealed: map[abi.SectorNumber]bool{2: true}}
+	db := &mockDealsDB{deals: []*types.ProviderDealState{{ChainDealID: 11, FastRetrieval: true}}}
+	dagst := &mockDagstore{shards: map[shard.Key]dagstore.ShardInfo{}}
+	m := NewManager(&mockMiner{}, ps, sa, dagst, db)
+	defer m.Stop()
+
+	// The piece has an unsealed copy but it hasn't been indexed
+	st, err := m.Status(ctx, pieceCid)
+	require.NoError(t, err)
+	require.True(t, st.HasUnsealedCopy)
+	require.False(t, st.Indexed)
+	require.False(t, st.Retrievable)
+	require.Len(t, st.Deals, 2)
+	require.False(t, st.Deals[0].IsUnsealed)
+	require.False(t, st.Deals[0].KeepUnsealed)
+	require.True(t, st.Deals[1].IsUnsealed)
+	require.True(t, st.Deals[1].KeepUnsealed)
+
+	dagst.shards[shard.KeyFromCID(pieceCid)] = dagstore.ShardInfo{ShardState: dagstore.ShardStateAvailable}
+	st, err = m.Status(ctx, pieceCid)
+	require.NoError(t, err)
+	require.True(t, st.Retrievable)
+
+	// The piece is already unsealed
+	require.Error(t, m.Unseal(ctx, pieceCid))
+}
+
+func TestRemoveUnsealed(t *testing.T) {
+	ctx := context.Background()
+	pieceCid, err := cid.Parse(testPieceCid)
+	require.NoError(t, err)
+
+	ps := &mockPieceStore{pieces: map[cid.Cid]piecestore.PieceInfo{
+		pieceCid: {PieceCID: pieceCid, Deals: []piecestore.DealInfo{{DealID: 10, SectorID: 1, Length: 128}}},
+	}}
+	sa := &mockSectorAccessor{unsealed: map[abi.SectorNumber]bool{1: true}}
+	miner := &mockMiner{sectors: map[abi.SectorNumber]lapi.SectorInfo{
+		1: {Pieces: []lapi.SectorPiece{{DealInfo: &lapi.PieceDealInfo{DealID: 12, KeepUnsealed: true}}}},
+	}}
+	m := NewManager(miner, ps, sa, &mockDagstore{}, &mockDealsDB{})
+	defer m.Stop()
+
+	// Another deal in the sector asked to keep an unsealed copy
+	_, err = m.RemoveUnsealed(ctx, pieceCid, false)
+	require.Error(t, err)
+	require.Empty(t, miner.dropped)
+
+	removed, err := m.RemoveUnsealed(ctx, pieceCid, true)
+	require.NoError(t, err)
+	require.Equal(t, []abi.SectorNumber{1}, removed)
+	require.Equal(t, []abi.SectorID{{Miner: 1000, Number: 1}}, miner.dropped)
+
+	// There is no unsealed copy to remove
+	sa.unsealed[1] = false
+	_, err = m.RemoveUnsealed(ctx, pieceCid, true)
+	require.Error(t, err)
+}
+
+func TestUnsealGoesThroughQueue(t *testing.T) {
+	ctx := context.Background()
+	pieceCid, err := cid.Parse(testPieceCid)
+	require.NoError(t, err)
+
+	ps := &mockPieceStore{pieces: map[cid.Cid]piecestore.PieceInfo{
+		pieceCid: {PieceCID: pieceCid, Deals: []piecestore.DealInfo{{DealID: 10, SectorID: 1, Length: 128}}},
+	}}
+	sa := &blockingSectorAccessor{unblock: make(chan struct{})}
+	q := sectoraccessor.NewUnsealQueue(sa, 1)
+	defer q.Close()
+	m := NewManager(&mockMiner{}, ps, q, &mockDagstore{}, &mockDealsDB{})
+	defer m.Stop()
+
+	require.NoError(t, m.Unseal(ctx, pieceCid))
+
+	// The unseal should be running in the queue at high priority
+	require.Eventually(t, func() bool {
+		st := q.Status()
+		return len(st.Running) == 1 && st.Running[0].Priority == sectoraccessor.UnsealPriorityHigh
+	}, time.Second, time.Millisecond)
+	require.Error(t, m.Unseal(ctx, pieceCid))
+
+	close(sa.unblock)
+	require.Eventually(t, func() bool {
+		return !m.isUnsealing(pieceCid)
+	}, time.Second, time.Millisecond)
+}
+
+// blockingSectorAccessor blocks unseals until unblock is closed
+type blockingSectorAccessor struct {
+	mdagstore.SectorAccessor
+	unblock chan struct{}
+}
+
+func (b *blockingSectorAccessor) IsUnsealed(ctx context.Context, sectorID abi.SectorNumber, offset abi.UnpaddedPieceSize, length abi.UnpaddedPieceSize) (bool, error) {
+	return false, nil
+}
+
+func (b *blockingSectorAccessor) UnsealSectorAt(ctx context.Context, sectorID abi.SectorNumber, offset abi.UnpaddedPieceSize, length abi.UnpaddedPieceSize) (mount.Reader, error) {
+	select {
+	case <-b.unblock:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &nopReader{}, nil
+}
+
+type nopReader struct {
+	mount.Reader
+}
+
+func (r *nopReader) Close() error {
+	return nil
+}
@@ -0,0 +1,345 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/google/uuid"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("webhooks")
+
+// SignatureHeader is the header that contains the hex encoded HMAC-SHA256
+// of the request body, keyed with the webhook secret (if the webhook has a
+// secret)
+const SignatureHeader = "X-Boost-Signature"
+
+// EventTypeTest is the type of the event sent to test a webhook receiver
+const EventTypeTest = "test"
+
+// EventTypes are the types of audit event that can be sent to a webhook
+var EventTypes = []string{db.AuditEventCheckpoint, db.AuditEventAccepted, db.AuditEventRejected, db.AuditEventOverride,
+	db.AuditEventLowBalance, db.AuditEventBalanceRestored, db.AuditEventStartEpochAtRisk,
+	db.AuditEventAlert, db.AuditEventAlertResolved}
+
+// Payload is the body of a request to a webhook
+type Payload struct {
+	WebhookID uint64
+	Event     db.AuditEvent
+}
+
+const (
+	// The maximum number of events to send to a webhook in one pass
+	batchSize = 100
+	// The maximum time to wait before retrying a webhook that failed
+	maxBackoff = 5 * time.Minute
+	// The maximum time to wait for a webhook to respond to a request
+	defaultSendTimeout = 10 * time.Second
+)
+
+// Dispatcher tails the audit log and POSTs new events to each registered
+// webhook. Each webhook records the sequence number of the last event
+// delivered to it, so delivery resumes where it left off after a failure or
+// a restart.
+// Events are delivered to each webhook in its own goroutine, so that a
+// webhook that is slow to respond doesn't hold up delivery to the others.
+type Dispatcher struct {
+	webhooksDB   *db.WebhooksDB
+	auditDB      *db.AuditLogDB
+	client       *http.Client
+	pollInterval time.Duration
+	sendTimeout  time.Duration
+
+	// Webhooks that failed are retried with exponential backoff
+	backoffLk sync.Mutex
+	backoff   map[uint64]backoff
+	// The webhooks that events are currently being delivered to
+	// (guarded by backoffLk)
+	inFlight map[uint64]struct{}
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	done   chan struct{}
+}
+
+type backoff struct {
+	wait time.Duration
+	next time.Time
+}
+
+func NewDispatcher(webhooksDB *db.WebhooksDB, auditDB *db.AuditLogDB, pollInterval time.Duration) *Dispatcher {
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+	return &Dispatcher{
+		webhooksDB:   webhooksDB,
+		auditDB:      auditDB,
+		client:       &http.Client{},
+		pollInterval: pollInterval,
+		sendTimeout:  defaultSendTimeout,
+		backoff:      make(map[uint64]backoff),
+		inFlight:     make(map[uint64]struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Register adds a webhook that receives audit events of the given types
+// (or all types if eventTypes is empty). Only events that occur after the
+// webhook is registered are sent to it.
+func (d *Dispatcher) Register(ctx context.Context, webhookUrl string, secret string, eventTypes []string) (*db.Webhook, error) {
+	u, err := url.Parse(webhookUrl)
+	if err != nil {
+		return nil, fmt.Errorf("parsing webhook url '%s': %w", webhookUrl, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("webhook url '%s' must have scheme http or https", webhookUrl)
+	}
+	for _, t := range eventTypes {
+		if !isEventType(t) {
+			return nil, fmt.Errorf("unrecognized event type '%s': must be one of %v", t, EventTypes)
+		}
+	}
+
+	seq, err := d.auditDB.LatestSeq(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting latest audit event: %w", err)
+	}
+
+	wh := &db.Webhook{
+		URL:        webhookUrl,
+		Secret:     secret,
+		EventTypes: eventTypes,
+		LastSeq:    seq,
+	}
+	if err := d.webhooksDB.Insert(ctx, wh); err != nil {
+		return nil, fmt.Errorf("saving webhook: %w", err)
+	}
+
+	log.Infow("registered webhook", "id", wh.ID, "url", wh.URL, "events", eventTypes)
+	return wh, nil
+}
+
+// Unregister removes the webhook with the given id
+func (d *Dispatcher) Unregister(ctx context.Context, id uint64) error {
+	if err := d.webhooksDB.Delete(ctx, id); err != nil {
+		return fmt.Errorf("removing webhook %d: %w", id, err)
+	}
+
+	d.backoffLk.Lock()
+	delete(d.backoff, id)
+	d.backoffLk.Unlock()
+
+	log.Infow("unregistered webhook", "id", id)
+	return nil
+}
+
+// List returns all registered webhooks
+func (d *Dispatcher) List(ctx context.Context) ([]db.Webhook, error) {
+	return d.webhooksDB.List(ctx)
+}
+
+// SendTest sends a test event to the webhook with the given id, and returns
+// an error if the receiver doesn't accept it
+func (d *Dispatcher) SendTest(ctx context.Context, id uint64) error {
+	wh, err := d.webhooksDB.ByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("getting webhook %d: %w", id, err)
+	}
+
+	return d.send(ctx, wh, db.AuditEvent{
+		CreatedAt: time.Now(),
+		DealUUID:  uuid.Nil,
+		Type:      EventTypeTest,
+		Actor:     db.AuditActorSystem,
+		Detail:    "test event",
+	})
+}
+
+func (d *Dispatcher) Start(ctx context.Context) {
+	ctx, d.cancel = context.WithCancel(ctx)
+	go d.run(ctx)
+}
+
+func (d *Dispatcher) Stop() {
+	if d.cancel == nil {
+		return
+	}
+	d.cancel()
+	<-d.done
+}
+
+func (d *Dispatcher) run(ctx context.Context) {
+	defer close(d.done)
+	defer d.wg.Wait()
+
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		whs, err := d.webhooksDB.List(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Errorw("listing webhooks", "err", err)
+			}
+			continue
+		}
+
+		now := time.Now()
+		for i := range whs {
+			wh := &whs[i]
+			if !d.startDelivery(wh.ID, now) {
+				continue
+			}
+
+			d.wg.Add(1)
+			go func() {
+				defer d.wg.Done()
+
+				err := d.deliver(ctx, wh)
+				if ctx.Err() != nil {
+					return
+				}
+				d.recordResult(wh.ID, err, time.Now())
+				if err != nil {
+					log.Warnw("delivering events to webhook", "id", wh.ID, "url", wh.URL, "err", err)
+				}
+			}()
+		}
+	}
+}
+
+// deliver sends the audit events that occurred since the last delivery to
+// the webhook
+func (d *Dispatcher) deliver(ctx context.Context, wh *db.Webhook) error {
+	evts, err := d.auditDB.Since(ctx, wh.LastSeq, batchSize)
+	if err != nil {
+		return fmt.Errorf("getting audit events: %w", err)
+	}
+
+	lastSeq := wh.LastSeq
+	var sendErr error
+	for _, evt := range evts {
+		if wh.Matches(evt.Type) {
+			if sendErr = d.send(ctx, wh, evt); sendErr != nil {
+				break
+			}
+		}
+		lastSeq = evt.Seq
+	}
+
+	lastErr := ""
+	if sendErr != nil {
+		lastErr = sendErr.Error()
+	}
+	if lastSeq != wh.LastSeq || lastErr != wh.LastError {
+		if err := d.webhooksDB.SetDelivered(ctx, wh.ID, lastSeq, lastErr); err != nil {
+			return fmt.Errorf("recording delivery: %w", err)
+		}
+	}
+	return sendErr
+}
+
+func (d *Dispatcher) send(ctx context.Context, wh *db.Webhook, evt db.AuditEvent) error {
+	body, err := json.Marshal(Payload{WebhookID: wh.ID, Event: evt})
+	if err != nil {
+		return fmt.Errorf("marshalling event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.sendTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if wh.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(wh.Secret, body))
+	}
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending event %d to %s: %w", evt.Seq, wh.URL, err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 1024))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("sending event %d to %s: receiver responded with status %d", evt.Seq, wh.URL, res.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the body, keyed with secret.
+// Receivers can use it to check that a request came from boost.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// startDelivery returns true, and marks the webhook as in flight, if events
+// are not already being delivered to the webhook and it is not waiting to
+// be retried
+func (d *Dispatcher) startDelivery(id uint64, now time.Time) bool {
+	d.backoffLk.Lock()
+	defer d.backoffLk.Unlock()
+
+	if _, ok := d.inFlight[id]; ok {
+		return false
+	}
+	if b, ok := d.backoff[id]; ok && now.Before(b.next) {
+		return false
+	}
+	d.inFlight[id] = struct{}{}
+	return true
+}
+
+func (d *Dispatcher) recordResult(id uint64, err error, now time.Time) {
+	d.backoffLk.Lock()
+	defer d.backoffLk.Unlock()
+
+	delete(d.inFlight, id)
+	if err == nil {
+		delete(d.backoff, id)
+		return
+	}
+
+	b := d.backoff[id]
+	b.wait *= 2
+	if b.wait == 0 {
+		b.wait = d.pollInterval
+	}
+	if b.wait > maxBackoff {
+		b.wait = maxBackoff
+	}
+	b.next = now.Add(b.wait)
+	d.backoff[id] = b
+}
+
+func isEventType(t string) bool {
+	for _, et := range EventTypes {
+		if et == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/boost/db"
+	"github.com/filecoin-project/boost/db/migrations"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type testReceiver struct {
+	lk       sync.Mutex
+	payloads []Payload
+	fail     bool
+}
+
+func (r *testReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if r.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil || req.Header.Get(SignatureHeader) != Sign("secret", body) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var p Payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.payloads = append(r.payloads, p)
+}
+
+func (r *testReceiver) received() []Payload {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	return append([]Payload{}, r.payloads...)
+}
+
+func (r *testReceiver) setFail(fail bool) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.fail = fail
+}
+
+func TestDispatcher(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := db.CreateTestTmpDB(t)
+	req.NoError(db.CreateAllBoostTables(ctx, sqldb, sqldb))
+	req.NoError(migrations.Migrate(sqldb))
+
+	auditDB := db.NewAuditLogDB(sqldb)
+	webhooksDB := db.NewWebhooksDB(sqldb)
+
+	rcv := &testReceiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	// Events from before the webhook is registered should not be sent
+	deal := uuid.New()
+	req.NoError(auditDB.Insert(ctx, &db.AuditEvent{DealUUID: deal, Type: db.AuditEventAccepted}))
+
+	d := NewDispatcher(webhooksDB, auditDB, 10*time.Millisecond)
+	_, err := d.Register(ctx, "ftp://example.com", "", nil)
+	req.ErrorContains(err, "scheme")
+	_, err = d.Register(ctx, srv.URL, "", []string{"unknown"})
+	req.ErrorContains(err, "unrecognized event type")
+
+	wh, err := d.Register(ctx, srv.URL, "secret", []string{db.AuditEventCheckpoint})
+	req.NoError(err)
+
+	// Test events should be sent immediately
+	req.NoError(d.SendTest(ctx, wh.ID))
+	req.Len(rcv.received(), 1)
+	req.Equal(EventTypeTest, rcv.received()[0].Event.Type)
+	req.Equal(wh.ID, rcv.received()[0].WebhookID)
+
+	d.Start(ctx)
+	defer d.Stop()
+
+	// Only events that match the webhook's event types should be sent
+	req.NoError(auditDB.Insert(ctx, &db.AuditEvent{DealUUID: deal, Type: db.AuditEventRejected}))
+	req.NoError(auditDB.Insert(ctx, &db.AuditEvent{DealUUID: deal, Type: db.AuditEventCheckpoint, ToCheckpoint: "Transferred"}))
+	req.Eventually(func() bool { return len(rcv.received()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	req.Equal("Transferred", rcv.received()[1].Event.ToCheckpoint)
+
+	// When the receiver fails, the event should be retried
+	rcv.setFail(true)
+	req.NoError(auditDB.Insert(ctx, &db.AuditEvent{DealUUID: deal, Type: db.AuditEventCheckpoint, ToCheckpoint: "Published"}))
+	req.Eventually(func() bool {
+		wh, err := webhooksDB.ByID(ctx, wh.ID)
+		return err == nil && wh.LastError != ""
+	}, 5*time.Second, 10*time.Millisecond)
+	rcv.setFail(false)
+	req.Eventually(func() bool { return len(rcv.received()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	req.Equal("Published", rcv.received()[2].Event.ToCheckpoint)
+
+	req.Eventually(func() bool {
+		wh, err := webhooksDB.ByID(ctx, wh.ID)
+		return err == nil && wh.LastError == ""
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// After unregistering, events should no longer be sent
+	req.NoError(d.Unregister(ctx, wh.ID))
+	req.ErrorIs(d.Unregister(ctx, wh.ID), db.ErrNotFound)
+	req.NoError(auditDB.Insert(ctx, &db.AuditEvent{DealUUID: deal, Type: db.AuditEventCheckpoint, ToCheckpoint: "Complete"}))
+	time.Sleep(50 * time.Millisecond)
+	req.Len(rcv.received(), 3)
+}
+
+func TestDispatcherSlowReceiver(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	sqldb := db.CreateTestTmpDB(t)
+	req.NoError(db.CreateAllBoostTables(ctx, sqldb, sqldb))
+	req.NoError(migrations.Migrate(sqldb))
+
+	auditDB := db.NewAuditLogDB(sqldb)
+	webhooksDB := db.NewWebhooksDB(sqldb)
+
+	// The slow receiver doesn't respond until the test ends
+	unblock := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-unblock:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(unblock)
+
+	rcv := &testReceiver{}
+	fast := httptest.NewServer(rcv)
+	defer fast.Close()
+
+	d := NewDispatcher(webhooksDB, auditDB, 10*time.Millisecond)
+	d.sendTimeout = 500 * time.Millisecond
+	slowWh, err := d.Register(ctx, slow.URL, "", nil)
+	req.NoError(err)
+	_, err = d.Register(ctx, fast.URL, "secret", nil)
+	req.NoError(err)
+
+	d.Start(ctx)
+	defer d.Stop()
+
+	// Events should be delivered to the fast receiver while the slow
+	// receiver is waiting to respond
+	deal := uuid.New()
+	req.NoError(auditDB.Insert(ctx, &db.AuditEvent{DealUUID: deal, Type: db.AuditEventAccepted}))
+	req.Eventually(func() bool { return len(rcv.received()) == 1 }, 250*time.Millisecond, 10*time.Millisecond)
+	req.NoError(auditDB.Insert(ctx, &db.AuditEvent{DealUUID: deal, Type: db.AuditEventRejected}))
+	req.Eventually(func() bool { return len(rcv.received()) == 2 }, 250*time.Millisecond, 10*time.Millisecond)
+
+	// The request to the slow receiver should time out
+	req.Eventually(func() bool {
+		wh, err := webhooksDB.ByID(ctx, slowWh.ID)
+		return err == nil && wh.LastError != ""
+	}, 5*time.Second, 10*time.Millisecond)
+	wh, err := webhooksDB.ByID(ctx, slowWh.ID)
+	req.NoError(err)
+	req.Contains(wh.LastError, "deadline exceeded")
+	req.Equal(slowWh.LastSeq, wh.LastSeq)
+}